@@ -10,6 +10,7 @@ set -euo pipefail
 #   ./scripts/build.sh monitor            # web monitor + web assets
 #   ./scripts/build.sh web                # web assets のみ
 #   ./scripts/build.sh detector           # ビルド不要、restart のみ
+#   ./scripts/build.sh petcam             # petcam CLI (supervise: 単一プロセス起動)
 #   ./scripts/build.sh album              # GitHub artifact download
 #   ./scripts/build.sh --no-restart ...   # restart をスキップ
 
@@ -27,7 +28,7 @@ for arg in "$@"; do
   case "${arg}" in
     --no-restart) DO_RESTART=0 ;;
     -h|--help)
-      sed -n '3,14p' "$0"
+      sed -n '3,15p' "$0"
       exit 0
       ;;
     *) MODULES+=("${arg}") ;;
@@ -66,9 +67,18 @@ build_web() {
   echo "[build] web done"
 }
 
+# Stamp the Go binaries with the build (see internal/buildinfo; -version prints it)
+go_ldflags() {
+  local pkg="github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/buildinfo"
+  local version commit
+  version="$(git -C "${REPO_ROOT}" describe --tags --always --dirty 2>/dev/null || echo dev)"
+  commit="$(git -C "${REPO_ROOT}" rev-parse --short HEAD 2>/dev/null)"
+  echo "-X ${pkg}.Version=${version} -X ${pkg}.Commit=${commit} -X ${pkg}.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+}
+
 build_streaming() {
   echo "[build] streaming server (Go)..."
-  (cd "${STREAMING_DIR}" && CGO_ENABLED=1 go build -o "${BUILD_DIR}/streaming-server" ./cmd/server) >/dev/null
+  (cd "${STREAMING_DIR}" && CGO_ENABLED=1 go build -ldflags "$(go_ldflags)" -o "${BUILD_DIR}/streaming-server" ./cmd/server) >/dev/null
   echo "[build] streaming done"
   restart_service pet-camera-streaming.service
 }
@@ -77,11 +87,19 @@ build_monitor() {
   # monitor embeds web assets, so build web first
   build_web
   echo "[build] web monitor (Go)..."
-  (cd "${STREAMING_DIR}" && go build -o "${BUILD_DIR}/web_monitor" ./cmd/web_monitor) >/dev/null
+  (cd "${STREAMING_DIR}" && go build -ldflags "$(go_ldflags)" -o "${BUILD_DIR}/web_monitor" ./cmd/web_monitor) >/dev/null
   echo "[build] monitor done"
   restart_service pet-camera-monitor.service
 }
 
+build_petcam() {
+  # petcam embeds the web monitor, so build web first
+  build_web
+  echo "[build] petcam CLI (Go)..."
+  (cd "${STREAMING_DIR}" && CGO_ENABLED=1 go build -ldflags "$(go_ldflags)" -o "${BUILD_DIR}/petcam" ./cmd/petcam) >/dev/null
+  echo "[build] petcam done"
+}
+
 build_detector() {
   # Python — no build step, just restart
   echo "[build] detector (Python, no build needed)"
@@ -113,11 +131,12 @@ for module in "${MODULES[@]}"; do
     streaming) build_streaming ;;
     monitor)   build_monitor ;;
     detector)  build_detector ;;
+    petcam)    build_petcam ;;
     album)     build_album ;;
     yolo-daemon) build_yolo_daemon ;;
     *)
       echo "[error] Unknown module: ${module}" >&2
-      echo "        Available: capture, web, streaming, monitor, detector, album, yolo-daemon" >&2
+      echo "        Available: capture, web, streaming, monitor, detector, petcam, album, yolo-daemon" >&2
       exit 1
       ;;
   esac
@@ -176,6 +176,14 @@ int main(int argc, char* argv[]) {
         return 1;
     }
 
+    // Capture control SHM: Go consumers report demand so the encoder can pause
+    // when nobody is watching or recording. Recreated so stale slots don't linger.
+    shm_unlink(SHM_NAME_CONTROL);
+    CaptureControl* control_shm = shm_control_create();
+    if (!control_shm) {
+        LOG_WARN("Main", "Failed to create capture control SHM, encoder always on");
+    }
+
     // Create pipelines
     const int num_cameras = (single_camera >= 0) ? 1 : 2;
     int camera_indices[2] = {0, 1};
@@ -204,6 +212,7 @@ int main(int argc, char* argv[]) {
             any_failed = true;
             continue;
         }
+        g_pipelines[cam].control = control_shm;
     }
 
     // In dual-camera mode, all pipelines must succeed.
@@ -215,6 +224,7 @@ int main(int argc, char* argv[]) {
             pipeline_destroy(&g_pipelines[camera_indices[i]]);
         }
         shm_detection_destroy(detection_shm);
+        shm_control_destroy(control_shm);
         return 1;
     }
 
@@ -296,6 +306,7 @@ int main(int argc, char* argv[]) {
     }
 
     shm_detection_destroy(detection_shm);
+    shm_control_destroy(control_shm);
     LOG_INFO("Main", "Unified Camera Daemon Stopped");
     return 0;
 }
@@ -190,6 +190,8 @@ int pipeline_run(camera_pipeline_t* pipeline, volatile bool* running_flag) {
     // Per-pipeline state (not static — each thread has its own pipeline)
     isp_brightness_result_t cached_brightness = {.valid = false};
     bool prev_active = false;
+    bool encode_paused = false;
+    uint32_t power_mode = CAPTURE_POWER_FULL;
 
     while (*running_flag) {
         int ret;
@@ -302,7 +304,10 @@ int pipeline_run(camera_pipeline_t* pipeline, volatile bool* running_flag) {
         }
 
         if (write_active) {
-            clock_gettime(CLOCK_REALTIME, &frame_timestamp);
+            clock_gettime(CAPTURE_FRAME_CLOCK, &frame_timestamp);
+        }
+        if (frame_number % 30 == 0) {
+            shm_control_publish_clock(pipeline->control);
         }
 
         // Brightness: switcher thread reads ISP directly (no SHM write needed)
@@ -310,8 +315,40 @@ int pipeline_run(camera_pipeline_t* pipeline, volatile bool* running_flag) {
         // Push VSE Ch0 frame to encoder thread (zero-copy via phys_addr)
         // On success, encoder thread owns the VSE buffer and will release it.
         // On failure (queue full) or inactive camera, we must release it here.
+        // Pause encoding while no Go consumer wants H.265 (no viewers, no recording).
+        // The night-assist TCP relay is an in-process consumer, so keep encoding for it.
+        // Resuming is immediate; the next IDR arrives within one GOP (1s).
+        const bool encode_wanted = pipeline->encoder_thread.tcp_relay ||
+                                   shm_control_encode_wanted(pipeline->control);
+        if (write_active && encode_wanted == encode_paused) {
+            encode_paused = !encode_wanted;
+            LOG_INFO(Pipeline_log_header, "H.265 encoder %s (frame %lu)",
+                     encode_paused ? "paused: no consumers" : "resumed", frame_number);
+        }
+
+        // Idle power saving (requested by web_monitor while nobody watches):
+        // thin out detector and MJPEG frames. Frames not published are still
+        // dequeued and released at once so the VSE channels keep cycling.
+        const uint32_t power = shm_control_power_mode(pipeline->control);
+        if (write_active && power != power_mode) {
+            power_mode = power;
+            LOG_INFO(Pipeline_log_header, "Power mode %s (frame %lu)",
+                     power == CAPTURE_POWER_SUSPEND ? "suspend: detector frames stopped"
+                     : power == CAPTURE_POWER_LOW   ? "low: detector/MJPEG frames reduced"
+                                                    : "full",
+                     frame_number);
+        }
+        const bool publish_yolo =
+            power == CAPTURE_POWER_FULL ||
+            (power == CAPTURE_POWER_LOW && frame_number % CONTROL_LOW_DIVISOR == 0);
+        const bool publish_mjpeg =
+            power == CAPTURE_POWER_FULL ||
+            frame_number % (power == CAPTURE_POWER_LOW ? CONTROL_LOW_DIVISOR
+                                                       : CONTROL_SUSPEND_DIVISOR) ==
+                0;
+
         bool frame_owned_by_encoder = false;
-        if (write_active) {
+        if (write_active && !encode_paused) {
             ret = encoder_thread_push_frame(&pipeline->encoder_thread, &vio_frame, frame_number,
                                             pipeline->camera_index, frame_timestamp);
 
@@ -329,7 +366,12 @@ int pipeline_run(camera_pipeline_t* pipeline, volatile bool* running_flag) {
 
         // Get YOLO input frame from VSE Channel 1 (1280x720 for ROI detection)
         // Zero-copy: share VIO buffer via share_id, consumer imports via hb_mem
-        if (write_active) {
+        if (write_active && !publish_yolo) {
+            hbn_vnode_image_t skipped = {0};
+            if (vio_get_frame_ch1(&pipeline->vio, &skipped, 0) == 0) {
+                vio_release_frame_ch1(&pipeline->vio, &skipped);
+            }
+        } else if (write_active) {
             hbn_vnode_image_t yolo_frame = {0};
             ret = vio_get_frame_ch1(&pipeline->vio, &yolo_frame, 10);
             if (ret == 0) {
@@ -438,6 +480,14 @@ int pipeline_run(camera_pipeline_t* pipeline, volatile bool* running_flag) {
                 if (pipeline->shm_roi_zc[i] == NULL)
                     continue;
 
+                if (!publish_yolo) {
+                    hbn_vnode_image_t skipped = {0};
+                    if (vio_get_frame_roi(&pipeline->vio, i, &skipped, 0) == 0) {
+                        vio_release_frame_roi(&pipeline->vio, i, &skipped);
+                    }
+                    continue;
+                }
+
                 hbn_vnode_image_t roi_frame = {0};
                 int roi_ret = vio_get_frame_roi(&pipeline->vio, i, &roi_frame, 10);
                 if (roi_ret == 0) {
@@ -475,7 +525,12 @@ int pipeline_run(camera_pipeline_t* pipeline, volatile bool* running_flag) {
 
         // Get MJPEG frame from VSE Channel 2 (768x432, 16:9)
         // This frame is writable by web_monitor for overlay drawing (zero-copy)
-        if (write_active) {
+        if (write_active && !publish_mjpeg) {
+            hbn_vnode_image_t skipped = {0};
+            if (vio_get_frame_ch2(&pipeline->vio, &skipped, 0) == 0) {
+                vio_release_frame_ch2(&pipeline->vio, &skipped);
+            }
+        } else if (write_active) {
             hbn_vnode_image_t mjpeg_frame = {0};
             ret = vio_get_frame_ch2(&pipeline->vio, &mjpeg_frame, 10);
             if (ret == 0) {
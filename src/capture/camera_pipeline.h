@@ -49,6 +49,9 @@ typedef struct {
     // Shared state (same process, no SHM needed)
     volatile int* active_camera;
 
+    // Consumer demand from Go (NULL = always encode). Set by the daemon after create.
+    CaptureControl* control;
+
     // Condition variable for inactive camera blocking
     pthread_mutex_t switch_mutex;
     pthread_cond_t switch_cond;
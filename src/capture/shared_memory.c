@@ -247,3 +247,101 @@ int shm_h265_zc_write(H265ZeroCopyBuffer* shm, const H265ZeroCopyFrame* frame) {
     sem_post(&shm->new_frame_sem);
     return 0;
 }
+
+// ============================================================================
+// Capture Control
+// ============================================================================
+
+CaptureControl* shm_control_create(void) {
+    bool created_new = false;
+    CaptureControl* shm = (CaptureControl*)shm_create_or_open_ex(
+        SHM_NAME_CONTROL, sizeof(CaptureControl), true, &created_new);
+    if (shm && created_new) {
+        memset(shm, 0, sizeof(CaptureControl));
+        LOG_INFO("SharedMemory", "Capture control SHM created: %s (%zu bytes)", SHM_NAME_CONTROL,
+                 sizeof(CaptureControl));
+    }
+    shm_control_publish_clock(shm);
+    return shm;
+}
+
+void shm_control_publish_clock(CaptureControl* shm) {
+    if (!shm)
+        return;
+
+    struct timespec real, frame, mono;
+    clock_gettime(CLOCK_REALTIME, &real);
+    clock_gettime(CAPTURE_FRAME_CLOCK, &frame);
+    clock_gettime(CLOCK_MONOTONIC, &mono);
+    const int64_t base_ns = ((int64_t)real.tv_sec - frame.tv_sec) * 1000000000LL +
+                            ((int64_t)real.tv_nsec - frame.tv_nsec);
+
+    // Both pipelines may publish around a camera switch: the writer that
+    // makes seq odd owns the update, the other skips it
+    CaptureClock* c = &shm->clock;
+    uint32_t seq = __atomic_load_n(&c->seq, __ATOMIC_ACQUIRE);
+    if ((seq & 1) || !__atomic_compare_exchange_n(&c->seq, &seq, seq + 1, false, __ATOMIC_ACQ_REL,
+                                                  __ATOMIC_RELAXED))
+        return;
+    __atomic_thread_fence(__ATOMIC_RELEASE);
+    __atomic_store_n(&c->clock_id, (uint32_t)CAPTURE_FRAME_CLOCK_ID, __ATOMIC_RELAXED);
+    __atomic_store_n(&c->realtime_base_ns, base_ns, __ATOMIC_RELAXED);
+    __atomic_store_n(&c->updated_ms, (int64_t)mono.tv_sec * 1000 + mono.tv_nsec / 1000000,
+                     __ATOMIC_RELAXED);
+    __atomic_store_n(&c->seq, seq + 2, __ATOMIC_RELEASE);
+}
+
+void shm_control_destroy(CaptureControl* shm) {
+    if (shm) {
+        munmap(shm, sizeof(CaptureControl));
+        shm_unlink(SHM_NAME_CONTROL);
+    }
+}
+
+bool shm_control_encode_wanted(const CaptureControl* shm) {
+    if (!shm)
+        return true;
+
+    struct timespec now;
+    clock_gettime(CLOCK_MONOTONIC, &now);
+    const int64_t now_ms = (int64_t)now.tv_sec * 1000 + now.tv_nsec / 1000000;
+
+    bool any_fresh = false;
+    for (int i = 0; i < CONTROL_MAX_CLIENTS; i++) {
+        const CaptureDemandSlot* slot = &shm->slots[i];
+        if (__atomic_load_n(&slot->pid, __ATOMIC_ACQUIRE) == 0)
+            continue;
+        const int64_t hb = __atomic_load_n(&slot->heartbeat_ms, __ATOMIC_ACQUIRE);
+        if (now_ms - hb > CONTROL_STALE_MS)
+            continue;
+        any_fresh = true;
+        if (__atomic_load_n(&slot->consumers, __ATOMIC_ACQUIRE) > 0)
+            return true;
+    }
+    return !any_fresh;
+}
+
+uint32_t shm_control_power_mode(const CaptureControl* shm) {
+    if (!shm)
+        return CAPTURE_POWER_FULL;
+
+    struct timespec now;
+    clock_gettime(CLOCK_MONOTONIC, &now);
+    const int64_t now_ms = (int64_t)now.tv_sec * 1000 + now.tv_nsec / 1000000;
+
+    uint32_t mode = CAPTURE_POWER_FULL;
+    for (int i = 0; i < CONTROL_MAX_CLIENTS; i++) {
+        const CaptureDemandSlot* slot = &shm->slots[i];
+        if (__atomic_load_n(&slot->pid, __ATOMIC_ACQUIRE) == 0)
+            continue;
+        const int64_t hb = __atomic_load_n(&slot->heartbeat_ms, __ATOMIC_ACQUIRE);
+        if (now_ms - hb > CONTROL_STALE_MS)
+            continue;
+        if (__atomic_load_n(&slot->consumers, __ATOMIC_ACQUIRE) > 0)
+            return CAPTURE_POWER_FULL;
+        const uint32_t power = __atomic_load_n(&slot->power, __ATOMIC_ACQUIRE);
+        if (power > mode && power <= CAPTURE_POWER_SUSPEND)
+            mode = power;
+    }
+    return mode;
+}
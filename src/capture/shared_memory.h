@@ -6,6 +6,7 @@
  *   yolo_zc  — YOLO NV12 zero-copy (camera → Python detector)
  *   mjpeg_zc — MJPEG NV12 zero-copy (camera → Go web_monitor)
  *   detections — Detection results (Python → Go web_monitor)
+ *   control  — H.265 consumer demand (Go → encoder gating)
  */
 
 #ifndef SHARED_MEMORY_H
@@ -117,4 +118,58 @@ int shm_detection_write(LatestDetectionResult* shm, const DetectionEntry* detect
 uint32_t shm_detection_read(const LatestDetectionResult* shm, DetectionEntry* out_detections,
                             int* out_count);
 
+// ============================================================================
+// Capture Control (consumer demand → encoder gating)
+// ============================================================================
+
+// One slot per consumer process. The consumer claims a free slot (pid == 0)
+// and refreshes heartbeat_ms (CLOCK_MONOTONIC) about once a second with the
+// number of H.265 consumers it currently serves (WebRTC viewers, recordings).
+// Hysteresis (hold-off before reporting zero) is the consumer's job.
+// power is the CAPTURE_POWER_* mode the consumer asks for while idle.
+typedef struct {
+    volatile int32_t pid;          // Owning process, 0 = free
+    volatile uint32_t consumers;   // Active H.265 consumers in that process
+    volatile uint32_t power;       // Requested CAPTURE_POWER_* mode (0 = full)
+    volatile int64_t heartbeat_ms; // CLOCK_MONOTONIC milliseconds of last update
+} CaptureDemandSlot;
+
+// Clock the camera daemon stamps frames with (timestamp of ZeroCopyFrame and
+// H265ZeroCopyFrame). CAPTURE_FRAME_CLOCK_ID is what it publishes for it.
+#define CAPTURE_FRAME_CLOCK    CLOCK_REALTIME
+#define CAPTURE_FRAME_CLOCK_ID CAPTURE_CLOCK_REALTIME
+
+// Clock-domain handshake, written by the daemon only. seq is a seqlock: odd
+// while being written, 0 until first published. realtime_base_ns converts a
+// frame timestamp to wall-clock time (0 for CAPTURE_CLOCK_REALTIME) and is
+// refreshed about once a second, so NTP steps are picked up.
+typedef struct {
+    volatile uint32_t seq;
+    volatile uint32_t clock_id;        // CAPTURE_CLOCK_*
+    volatile int64_t realtime_base_ns; // CLOCK_REALTIME - frame clock, in ns
+    volatile int64_t updated_ms;       // CLOCK_MONOTONIC milliseconds of last update
+} CaptureClock;
+
+typedef struct {
+    CaptureDemandSlot slots[CONTROL_MAX_CLIENTS];
+    CaptureClock clock;
+} CaptureControl;
+
+CaptureControl* shm_control_create(void);
+void shm_control_destroy(CaptureControl* shm);
+
+// Returns true when the encoder should run at full rate: some fresh slot
+// reports consumers, or no fresh slot exists at all (no control-aware
+// consumer is running, so behave as before this channel existed).
+bool shm_control_encode_wanted(const CaptureControl* shm);
+
+// Returns the CAPTURE_POWER_* mode for detector and MJPEG frames: the deepest
+// mode requested by a fresh slot, or CAPTURE_POWER_FULL while any fresh slot
+// reports consumers (or none is fresh).
+uint32_t shm_control_power_mode(const CaptureControl* shm);
+
+// Publishes the frame clock domain (CAPTURE_FRAME_CLOCK_ID) and its current
+// offset to CLOCK_REALTIME. Called at creation and from the capture loop.
+void shm_control_publish_clock(CaptureControl* shm);
+
 #endif // SHARED_MEMORY_H
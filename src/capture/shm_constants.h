@@ -16,6 +16,7 @@
  *   /pet_camera_yolo_zc    — YOLO input zero-copy (camera → Python detector)
  *   /pet_camera_detections — Detection results (Python detector → Go web_monitor)
  *   /pet_camera_mjpeg_frame — MJPEG NV12 (camera → Go web_monitor, TODO: zero-copy)
+ *   /pet_camera_control    — Consumer demand (Go → camera daemon encoder gating)
  */
 
 #ifndef SHM_CONSTANTS_H
@@ -26,6 +27,7 @@
 #define SHM_NAME_YOLO_ZC    "/pet_camera_yolo_zc" // YOLO input zero-copy (unified, replaces zc_0/zc_1)
 #define SHM_NAME_DETECTIONS "/pet_camera_detections" // YOLO detection results
 #define SHM_NAME_MJPEG_ZC   "/pet_camera_mjpeg_zc" // MJPEG NV12 zero-copy (camera → Go web_monitor)
+#define SHM_NAME_CONTROL    "/pet_camera_control"  // Capture control (Go consumers → camera daemon)
 
 // Buffer sizes
 #define RING_BUFFER_SIZE 6  // 200ms buffer at 30fps (MJPEG only)
@@ -38,6 +40,25 @@
 #define SHM_NAME_ROI_ZC_1 "/pet_camera_roi_zc_1"
 #define NUM_ROI_REGIONS   2
 
+// Capture control: one demand slot per consumer process (streaming server, web_monitor).
+// A slot whose heartbeat is older than CONTROL_STALE_MS is ignored (crashed consumer).
+#define CONTROL_MAX_CLIENTS 4
+#define CONTROL_STALE_MS    5000
+
+// Power modes a consumer may request through its slot (see shm_control_power_mode).
+// Reduced modes thin out the detector and MJPEG frames; the H.265 encoder is
+// gated by demand alone, so a consumer that wants video always gets every frame.
+#define CAPTURE_POWER_FULL      0  // every frame published
+#define CAPTURE_POWER_LOW       1  // detector + MJPEG frames at 1/CONTROL_LOW_DIVISOR
+#define CAPTURE_POWER_SUSPEND   2  // no detector frames, MJPEG at 1/CONTROL_SUSPEND_DIVISOR
+#define CONTROL_LOW_DIVISOR     6  // 30fps -> 5fps
+#define CONTROL_SUSPEND_DIVISOR 30 // 30fps -> 1fps, enough for motion wake-up
+
+// Clock domains of frame timestamps, published in CaptureControl.clock so
+// consumers convert them to their wall clock (see shm_control_publish_clock).
+#define CAPTURE_CLOCK_REALTIME  0
+#define CAPTURE_CLOCK_MONOTONIC 1
+
 // Zero-copy constants
 #define ZEROCOPY_MAX_PLANES     2   // NV12: Y + UV
 #define HB_MEM_GRAPHIC_BUF_SIZE 160 // sizeof(hb_mem_graphic_buf_t)
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/rtppack"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/srtp"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/types"
+)
+
+// runBench measures the per-viewer send path (RTP packetization + SRTP
+// encryption) on synthetic H.265 frames, to size -max-clients for the board.
+func runBench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	duration := fs.Duration("duration", 5*time.Second, "Benchmark length")
+	bitrate := fs.Int("bitrate", 2000, "Simulated stream bitrate in kbps")
+	fps := fs.Int("fps", 30, "Simulated frame rate (GOP = 1s)")
+	mtu := fs.Int("mtu", 1200, "RTP MTU")
+	fs.Parse(args)
+	if *fps <= 0 || *bitrate <= 0 {
+		return fmt.Errorf("-fps and -bitrate must be positive")
+	}
+
+	ctx, err := srtp.NewContext(make([]byte, 16), make([]byte, 14))
+	if err != nil {
+		return err
+	}
+
+	// One GOP: the IDR carries about a third of the GOP's bits
+	gopBytes := *bitrate * 1000 / 8
+	idrSize := gopBytes / 3
+	pSize := (gopBytes - idrSize) / max(*fps-1, 1)
+	gop := make([]*types.VideoFrame, *fps)
+	rng := rand.New(rand.NewSource(1))
+	for i := range gop {
+		size, nalType := pSize, uint8(1) // TRAIL_R
+		if i == 0 {
+			size, nalType = idrSize, 19 // IDR_W_RADL
+		}
+		gop[i] = syntheticFrame(rng, size, nalType, i == 0)
+	}
+
+	const ssrc = 0x50455443
+	var (
+		seq     uint16
+		frames  int
+		packets int
+		bytes   int64
+		buf     []byte
+	)
+	start := time.Now()
+	for time.Since(start) < *duration {
+		frame := gop[frames%len(gop)]
+		pkts, next := rtppack.PacketizeH265(frame, ssrc, seq, uint32(frames*3000), *mtu)
+		for i, pkt := range pkts {
+			buf, err = ctx.EncryptRTP(buf[:0], pkt, 12, seq+uint16(i), ssrc)
+			if err != nil {
+				return err
+			}
+			bytes += int64(len(buf))
+		}
+		seq = next
+		packets += len(pkts)
+		frames++
+	}
+	elapsed := time.Since(start).Seconds()
+
+	fmt.Printf("frames:   %d (%.0f fps)\n", frames, float64(frames)/elapsed)
+	fmt.Printf("packets:  %d (%.0f pps)\n", packets, float64(packets)/elapsed)
+	fmt.Printf("output:   %.1f Mbps\n", float64(bytes)*8/elapsed/1e6)
+	fmt.Printf("viewers:  ~%.0f at %d fps / %d kbps (single core)\n", float64(frames)/elapsed/float64(*fps), *fps, *bitrate)
+	return nil
+}
+
+// syntheticFrame builds a one-NALU frame of random payload.
+func syntheticFrame(rng *rand.Rand, size int, nalType uint8, idr bool) *types.VideoFrame {
+	data := make([]byte, 4+size)
+	copy(data, []byte{0, 0, 0, 1})
+	rng.Read(data[4:])
+	data[4] = nalType << 1
+	data[5] = 1 // TID
+	return &types.VideoFrame{
+		Data:  data,
+		IsIDR: idr,
+		NALUs: []types.NALBound{{Offset: 4, Length: size, Type: nalType}},
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Client-side subcommands talk to a running web monitor over its HTTP API.
+
+const defaultServer = "http://localhost:8080"
+
+var (
+	httpClient     = &http.Client{Timeout: 30 * time.Second}
+	downloadClient = &http.Client{} // clips can be large: no total timeout
+)
+
+// serverBase returns the API base URL for -server: an http(s) URL, or
+// unix:/path for a monitor listening on a Unix socket (-socket), which
+// points both clients at the socket.
+func serverBase(server string) string {
+	path, ok := strings.CutPrefix(server, "unix:")
+	if !ok {
+		return strings.TrimRight(server, "/")
+	}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}
+	httpClient.Transport = transport
+	downloadClient.Transport = transport
+	return "http://localhost"
+}
+
+// apiCall sends a request and decodes a JSON response into out (if non-nil).
+func apiCall(method, rawURL string, out any) error {
+	req, err := http.NewRequest(method, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("%s %s: %s", method, rawURL, apiErr.Error)
+		}
+		return fmt.Errorf("%s %s: %s", method, rawURL, resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// download streams a GET response into path.
+func download(rawURL, path string) (int64, error) {
+	resp, err := downloadClient.Get(rawURL)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, resp.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return n, err
+}
+
+// runRecord records a clip of fixed length through the web monitor.
+func runRecord(args []string) error {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	server := fs.String("server", defaultServer, "Web monitor base URL, or unix:/path of its -socket")
+	duration := fs.Duration("duration", 30*time.Second, "Recording length")
+	fs.Parse(args)
+	base := serverBase(*server)
+
+	// The monitor stops the clip by itself at the end of the GOP running
+	// at the deadline; an interrupt stops it early
+	var rec struct {
+		File     string    `json:"file"`
+		Until    time.Time `json:"until"`
+		Extended bool      `json:"extended"`
+	}
+	if err := apiCall(http.MethodPost, base+"/api/record?duration="+url.QueryEscape(duration.String()), &rec); err != nil {
+		return err
+	}
+	if rec.Extended {
+		fmt.Fprintf(os.Stderr, "Extended %s until %s...\n", rec.File, rec.Until.Local().Format(time.TimeOnly))
+	} else {
+		fmt.Fprintf(os.Stderr, "Recording %s until %s...\n", rec.File, rec.Until.Local().Format(time.TimeOnly))
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	timer := time.NewTimer(time.Until(rec.Until))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		if err := apiCall(http.MethodPost, base+"/api/recording/stop", nil); err != nil {
+			return err
+		}
+	}
+	fmt.Println(rec.File)
+	return nil
+}
+
+// recordingInfo mirrors webmonitor.RecordingInfo.
+type recordingInfo struct {
+	Name      string    `json:"name"`
+	SizeBytes int64     `json:"size_bytes"`
+	CreatedAt time.Time `json:"created_at"`
+	Proxy     string    `json:"proxy,omitempty"`
+}
+
+// runExport downloads the recordings created in a time range.
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	server := fs.String("server", defaultServer, "Web monitor base URL, or unix:/path of its -socket")
+	from := fs.String("from", "", "Start of range (RFC 3339 or 2006-01-02 15:04, local time; empty = oldest)")
+	to := fs.String("to", "", "End of range (same formats; empty = now)")
+	outDir := fs.String("o", ".", "Output directory")
+	quality := fs.String("quality", "full", "full, or proxy for the low-res copies (full where a recording has none)")
+	fs.Parse(args)
+	base := serverBase(*server)
+	if *quality != "full" && *quality != "proxy" {
+		return fmt.Errorf("-quality: want full or proxy, got %q", *quality)
+	}
+
+	fromT, err := parseTime(*from)
+	if err != nil {
+		return fmt.Errorf("-from: %w", err)
+	}
+	toT, err := parseTime(*to)
+	if err != nil {
+		return fmt.Errorf("-to: %w", err)
+	}
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		return err
+	}
+
+	var list struct {
+		Recordings []recordingInfo `json:"recordings"`
+	}
+	if err := apiCall(http.MethodGet, base+"/api/recordings", &list); err != nil {
+		return err
+	}
+	count := 0
+	for _, rec := range list.Recordings {
+		if !fromT.IsZero() && rec.CreatedAt.Before(fromT) {
+			continue
+		}
+		if !toT.IsZero() && rec.CreatedAt.After(toT) {
+			continue
+		}
+		name, src := rec.Name, base+"/api/recordings/"+url.PathEscape(rec.Name)
+		if *quality == "proxy" {
+			if rec.Proxy != "" {
+				name, src = rec.Proxy, src+"?quality=proxy"
+			} else {
+				fmt.Fprintf(os.Stderr, "%s has no proxy, exporting it in full\n", rec.Name)
+			}
+		}
+		path := filepath.Join(*outDir, filepath.Base(name))
+		n, err := download(src, path)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\t%d bytes\n", path, n)
+		count++
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d of %d recordings\n", count, len(list.Recordings))
+	return nil
+}
+
+// parseTime accepts RFC 3339 or a local "2006-01-02[ 15:04[:05]]".
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time %q", s)
+}
+
+// runSnapshot saves the current frame as JPEG.
+func runSnapshot(args []string) error {
+	fs := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	server := fs.String("server", defaultServer, "Web monitor base URL, or unix:/path of its -socket")
+	out := fs.String("o", "snapshot.jpg", "Output file")
+	fs.Parse(args)
+
+	n, err := download(serverBase(*server)+"/api/snapshot", *out)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s\t%d bytes\n", *out, n)
+	return nil
+}
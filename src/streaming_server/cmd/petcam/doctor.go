@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/config"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/diagnostics"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/webmonitor"
+)
+
+// runDoctor checks the host for the problems that usually keep the stack
+// from starting and prints a hint for each. It accepts the monitor flags so
+// it checks the same addresses and paths the monitor would use.
+func runDoctor(args []string) error {
+	cfg := webmonitor.DefaultConfig()
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	config.BindMonitor(fs, &cfg)
+	asJSON := fs.Bool("json", false, "Print results as JSON (same shape as /api/diagnostics)")
+	fs.Parse(args)
+	config.ApplyMonitorEnv(&cfg)
+
+	results := diagnostics.Run(webmonitor.DiagnosticsOptions(cfg))
+	failed := diagnostics.Failed(results)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(map[string]any{"ok": !failed, "results": results}); err != nil {
+			return err
+		}
+	} else {
+		for _, r := range results {
+			fmt.Printf("[%-4s] %-28s %s\n", r.Status, r.Name, r.Detail)
+			if r.Hint != "" {
+				fmt.Printf("       %-28s -> %s\n", "", r.Hint)
+			}
+		}
+	}
+	if failed {
+		return fmt.Errorf("environment checks failed")
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// Command petcam is the single entry point for running the camera stack.
+//
+//	petcam serve [flags]       WebRTC streaming server (streaming-server)
+//	petcam monitor [flags]     web monitor (web_monitor)
+//	petcam supervise [flags]   run capture, detector and streaming server as
+//	                           children of an in-process web monitor
+//	petcam record -duration    record a clip through a running web monitor
+//	petcam export -from -to    download recordings in a time range
+//	petcam snapshot -o FILE    save the current frame as JPEG
+//	petcam bench               measure the WebRTC send path on this board
+//	petcam doctor              check shm, ports and storage
+//	petcam update -pubkey KEY  install a signed release, rolling back on failure
+//	petcam verify CLIP         check a watermarked recording and its manifest
+//	petcam version             print the build (also -version)
+//
+// serve, monitor and supervise share their flags with the standalone
+// binaries through internal/config.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/buildinfo"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands = []command{
+	{"serve", "Run the WebRTC streaming server", runServe},
+	{"monitor", "Run the web monitor", runMonitor},
+	{"supervise", "Run capture, detector and streaming server under one supervisor", runSupervise},
+	{"record", "Record a clip of fixed length", runRecord},
+	{"export", "Download recordings in a time range", runExport},
+	{"snapshot", "Save the current frame as JPEG", runSnapshot},
+	{"bench", "Benchmark RTP packetization and SRTP encryption", runBench},
+	{"doctor", "Check shared memory, ports and storage", runDoctor},
+	{"update", "Install a signed release build with health-checked rollback", runUpdate},
+	{"verify", "Verify a recording's hash chain and signed manifest", runVerify},
+	{"version", "Print the build version", runVersion},
+}
+
+func runVersion(args []string) error {
+	fmt.Println("petcam", buildinfo.String())
+	return nil
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: petcam <command> [flags]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun 'petcam <command> -h' for command flags.\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	name := os.Args[1]
+	if name == "-h" || name == "--help" || name == "help" {
+		usage()
+		return
+	}
+	if name == "-version" || name == "--version" {
+		name = "version"
+	}
+	for _, c := range commands {
+		if c.name == name {
+			if err := c.run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "petcam %s: %v\n", name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "petcam: unknown command %q\n\n", name)
+	usage()
+	os.Exit(2)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os/signal"
+	"syscall"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/config"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/streamserver"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/webmonitor"
+)
+
+// signalContext is cancelled on SIGINT/SIGTERM.
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+}
+
+// runServe runs the WebRTC streaming server (same flags as streaming-server).
+func runServe(args []string) error {
+	cfg := streamserver.DefaultConfig()
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	config.BindStreaming(fs, &cfg)
+	logOpts := config.BindLog(fs)
+	fs.Parse(args)
+
+	if err := logOpts.Init(); err != nil {
+		return err
+	}
+	ctx, stop := signalContext()
+	defer stop()
+	return streamserver.Run(ctx, cfg)
+}
+
+// runMonitor runs the web monitor (same flags as web_monitor).
+func runMonitor(args []string) error {
+	cfg := webmonitor.DefaultConfig()
+	fs := flag.NewFlagSet("monitor", flag.ExitOnError)
+	config.BindMonitor(fs, &cfg)
+	logOpts := config.BindLog(fs)
+	fs.Parse(args)
+	config.ApplyMonitorEnv(&cfg)
+
+	if err := logOpts.Init(); err != nil {
+		return err
+	}
+	ctx, stop := signalContext()
+	defer stop()
+	return webmonitor.Run(ctx, cfg, nil)
+}
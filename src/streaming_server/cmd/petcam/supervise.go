@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/config"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/supervisor"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/webmonitor"
+)
+
+// runSupervise starts the helper processes as supervised children and serves
+// the web monitor in-process, so /readyz reflects the whole stack.
+func runSupervise(args []string) error {
+	cfg := webmonitor.DefaultConfig()
+	// Relative to the repository root, where the systemd units run
+	cfg.AssetsDir = "src/web"
+	cfg.BuildAssetsDir = "build/web"
+	fs := flag.NewFlagSet("supervise", flag.ExitOnError)
+
+	var (
+		captureCmd   string
+		detectorCmd  string
+		streamingCmd string
+		shmTimeout   time.Duration
+	)
+	fs.StringVar(&captureCmd, "capture", "build/camera_daemon_drobotics -W 1280 -H 720", "Capture daemon command (empty disables)")
+	fs.StringVar(&detectorCmd, "detector", `uv run src/detector/yolo_detector_daemon.py --model-path "$(scripts/resolve-model.sh ${PET_CAMERA_YOLO_MODEL:-v26n})"`, "Detector command, run through /bin/sh (empty disables)")
+	fs.StringVar(&streamingCmd, "streaming", "build/streaming-server -record-path ${RECORDING_PATH:-./recordings}", "Streaming server command (empty disables)")
+	fs.DurationVar(&shmTimeout, "shm-timeout", 30*time.Second, "How long to wait for the capture SHM before starting the web monitor")
+	config.BindMonitor(fs, &cfg)
+	logOpts := config.BindLog(fs)
+	fs.Parse(args)
+	config.ApplyMonitorEnv(&cfg)
+
+	if err := logOpts.Init(); err != nil {
+		return err
+	}
+
+	sup := supervisor.New()
+	for _, spec := range []supervisor.Spec{
+		{Name: "capture", Command: captureCmd},
+		{Name: "detector", Command: detectorCmd},
+		{Name: "streaming", Command: streamingCmd},
+	} {
+		if spec.Command != "" {
+			sup.Add(spec)
+		}
+	}
+	sup.Start()
+	defer sup.Stop()
+
+	// The web monitor attaches its SHM readers once at startup
+	if captureCmd != "" {
+		if !waitForSHM(cfg.FrameShmName, shmTimeout) {
+			logger.Warn("Supervise", "%s not created within %v, starting web monitor without it", cfg.FrameShmName, shmTimeout)
+		}
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	return webmonitor.Run(ctx, cfg, func(server *webmonitor.Server) {
+		server.AddReadyCheck("children", func() (bool, any) {
+			return sup.Healthy(), sup.Status()
+		})
+	})
+}
+
+// waitForSHM polls /dev/shm until the named segment exists.
+func waitForSHM(name string, timeout time.Duration) bool {
+	path := "/dev/shm/" + strings.TrimPrefix(name, "/")
+	deadline := time.Now().Add(timeout)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/watermark"
+)
+
+// runVerify checks a watermarked recording against its signed manifest.
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	pubKey := fs.String("pubkey", "", "Device public key (base64, logged by the server at startup) or path to a file holding it; empty trusts the key in the manifest")
+	manifestPath := fs.String("manifest", "", "Manifest file (default: CLIP.manifest.json)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: petcam verify [flags] CLIP\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("one clip is required")
+	}
+	clip := fs.Arg(0)
+	if *manifestPath == "" {
+		*manifestPath = watermark.ManifestPath(clip)
+	}
+
+	var key ed25519.PublicKey
+	if *pubKey != "" {
+		var err error
+		if key, err = loadPublicKey(*pubKey); err != nil {
+			return err
+		}
+	}
+	m, err := watermark.ReadManifest(*manifestPath)
+	if err != nil {
+		return err
+	}
+	if err := m.VerifySignature(key); err != nil {
+		return err
+	}
+
+	f, err := os.Open(clip)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	rep, err := watermark.Verify(f, m)
+	if err != nil {
+		return fmt.Errorf("%s: %w (%d anchors verified, last captured %s)", clip, err, rep.Anchors, formatTime(rep.Last))
+	}
+
+	fmt.Printf("OK %s\n", clip)
+	fmt.Printf("  device:   %s\n", m.DeviceID)
+	fmt.Printf("  recorded: %s - %s\n", m.Started.Local().Format(time.RFC3339), m.Stopped.Local().Format(time.RFC3339))
+	fmt.Printf("  anchors:  %d (%s - %s), %d bytes\n", rep.Anchors, formatTime(rep.First), formatTime(rep.Last), rep.Bytes)
+	if key == nil {
+		fmt.Printf("  signed by %s (not pinned: pass -pubkey to check the device)\n", base64.StdEncoding.EncodeToString(m.PublicKey))
+	}
+	return nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
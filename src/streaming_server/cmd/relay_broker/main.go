@@ -0,0 +1,87 @@
+// Command relay_broker is the small self-hosted broker for remote access.
+// Cameras connect out to it over WSS (-relay-url on web_monitor); viewers
+// browse the broker and their requests are tunneled to the camera.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/buildinfo"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/relay"
+)
+
+func main() {
+	var (
+		addr           = flag.String("http", ":8443", "Listen address")
+		tlsCert        = flag.String("tls-cert", "", "TLS certificate file (recommended; cameras then use wss://)")
+		tlsKey         = flag.String("tls-key", "", "TLS private key file")
+		cameras        = flag.String("cameras", os.Getenv("RELAY_CAMERAS"), "Allowed cameras as id=token[,id=token...] (env RELAY_CAMERAS)")
+		viewerUser     = flag.String("viewer-user", "viewer", "Viewer basic-auth user")
+		viewerPassword = flag.String("viewer-password", os.Getenv("RELAY_VIEWER_PASSWORD"), "Viewer basic-auth password, empty disables auth (env RELAY_VIEWER_PASSWORD)")
+		logLevel       = flag.String("log-level", "info", "Log level (debug, info, warn, error, silent)")
+		version        = flag.Bool("version", false, "Print the build version and exit")
+	)
+	flag.Parse()
+	if *version {
+		fmt.Println(buildinfo.String())
+		return
+	}
+
+	level, err := logger.ParseLevel(*logLevel)
+	if err != nil {
+		log.Fatalf("Invalid log level: %v", err)
+	}
+	logger.Init(level, os.Stderr, true)
+
+	tokens := make(map[string]string)
+	for _, pair := range strings.Split(*cameras, ",") {
+		id, token, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || id == "" || token == "" {
+			continue
+		}
+		tokens[id] = token
+	}
+	if len(tokens) == 0 {
+		log.Fatal("No cameras configured (-cameras id=token)")
+	}
+	if *viewerPassword == "" {
+		logger.Warn("Main", "Viewer auth disabled: anyone reaching this broker can watch the cameras")
+	}
+
+	broker := relay.NewBroker(tokens)
+	broker.ViewerUser = *viewerUser
+	broker.ViewerPassword = *viewerPassword
+
+	httpServer := &http.Server{Addr: *addr, Handler: broker}
+	go func() {
+		var err error
+		if *tlsCert != "" && *tlsKey != "" {
+			logger.Info("Main", "Relay broker listening on %s (HTTPS, %d cameras)", *addr, len(tokens))
+			err = httpServer.ListenAndServeTLS(*tlsCert, *tlsKey)
+		} else {
+			logger.Info("Main", "Relay broker listening on %s (HTTP, %d cameras)", *addr, len(tokens))
+			err = httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatalf("server error: %v", err)
+		}
+	}()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	<-sigChan
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	httpServer.Shutdown(ctx)
+}
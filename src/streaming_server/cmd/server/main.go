@@ -2,535 +2,37 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
-	_ "net/http/pprof" // Enable pprof
-	"os"
-	ossignal "os/signal"
-	"sync"
+	"os/signal"
 	"syscall"
-	"time"
 
-	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/codec"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/buildinfo"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/config"
 	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
-	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/metrics"
-	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/recorder"
-	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/rtppack"
-	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/shm"
-	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/signal"
-	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/types"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/streamserver"
 )
 
-var (
-	// Command-line flags
-	shmName     = flag.String("shm", "/pet_camera_h265_zc", "H.265 zero-copy shared memory name")
-	httpAddr    = flag.String("http", ":8081", "HTTP server address")
-	metricsAddr = flag.String("metrics", ":9090", "Metrics server address")
-	pprofAddr   = flag.String("pprof", ":6060", "pprof server address")
-	recordPath  = flag.String("record-path", "./recordings", "Recording output path")
-	maxClients  = flag.Int("max-clients", 10, "Maximum WebRTC clients")
-	logLevel    = flag.String("log-level", "info", "Log level (debug, info, warn, error, silent)")
-	logColor    = flag.Bool("log-color", true, "Enable colored log output")
-)
-
-// Server is the main streaming server
-type Server struct {
-	ctx        context.Context
-	cancel     context.CancelFunc
-	wg         sync.WaitGroup
-	metrics    *metrics.Metrics
-	shmReader  *shm.Reader
-	processor  *codec.Processor
-	signal     *signal.Server
-	recorder   *recorder.Recorder
-	httpServer *http.Server
-
-	// Channels for goroutine communication
-	recorderChan chan *types.VideoFrame
-
-	// Pool for recorder frame buffers — avoids per-frame heap allocation
-	recorderBufPool sync.Pool
-	// Pool for SHM read buffers — avoids per-frame allocation in ReadLatestCopy
-	shmBufPool sync.Pool
-}
-
 func main() {
+	cfg := streamserver.DefaultConfig()
+	config.BindStreaming(flag.CommandLine, &cfg)
+	logOpts := config.BindLog(flag.CommandLine)
+	version := config.BindVersion(flag.CommandLine)
 	flag.Parse()
-
-	// Initialize logger
-	level, err := logger.ParseLevel(*logLevel)
-	if err != nil {
-		log.Fatalf("Invalid log level: %v", err)
-	}
-	logger.Init(level, os.Stderr, *logColor)
-
-	logger.Info("Main", "Streaming server starting...")
-	logger.Info("Main", "Log level: %s", level)
-
-	// Create recordings directory
-	if err := os.MkdirAll(*recordPath, 0755); err != nil {
-		log.Fatalf("Failed to create recordings directory: %v", err)
-	}
-
-	// Create server
-	srv, err := NewServer()
-	if err != nil {
-		log.Fatalf("Failed to create server: %v", err)
-	}
-
-	// Start server
-	if err := srv.Start(); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
-	}
-
-	// Wait for shutdown signal
-	sigChan := make(chan os.Signal, 1)
-	ossignal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
-
-	log.Println("Shutting down...")
-
-	// Graceful shutdown
-	if err := srv.Shutdown(); err != nil {
-		log.Printf("Error during shutdown: %v", err)
-	}
-
-	log.Println("Server stopped")
-}
-
-// NewServer creates a new streaming server
-func NewServer() (*Server, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-
-	// Create metrics
-	m := metrics.New()
-
-	// Create shared memory reader
-	reader, err := shm.NewReader(*shmName)
-	if err != nil {
-		cancel()
-		return nil, fmt.Errorf("failed to create shared memory reader: %w", err)
-	}
-
-	// Create H.264 processor
-	processor := codec.NewProcessor()
-
-	// Create signal server (self-contained WebRTC: SDP + ICE-lite + DTLS + SRTP)
-	signalSrv, err := signal.NewServer(*maxClients)
-	if err != nil {
-		cancel()
-		reader.Close()
-		return nil, fmt.Errorf("failed to create signal server: %w", err)
-	}
-
-	// Create recorder
-	rec := recorder.NewRecorder(*recordPath)
-
-	// Create HTTP server
-	mux := http.NewServeMux()
-	httpServer := &http.Server{
-		Addr:    *httpAddr,
-		Handler: mux,
-	}
-
-	srv := &Server{
-		ctx:          ctx,
-		cancel:       cancel,
-		metrics:      m,
-		shmReader:    reader,
-		processor:    processor,
-		signal:       signalSrv,
-		recorder:     rec,
-		httpServer:   httpServer,
-		recorderChan: make(chan *types.VideoFrame, 60),
-		recorderBufPool: sync.Pool{
-			New: func() interface{} {
-				// Pre-allocate 512KB — typical H.265 frame size
-				buf := make([]byte, 0, 512*1024)
-				return &buf
-			},
-		},
-		shmBufPool: sync.Pool{
-			New: func() interface{} {
-				// Pre-allocate 512KB — typical H.265 frame size
-				buf := make([]byte, 0, 512*1024)
-				return &buf
-			},
-		},
-	}
-
-	// Setup HTTP routes
-	srv.setupRoutes(mux)
-
-	return srv, nil
-}
-
-// Start starts all server components
-func (s *Server) Start() error {
-	log.Printf("Starting streaming server...")
-	log.Printf("  Shared memory: %s", *shmName)
-	log.Printf("  HTTP server: %s", *httpAddr)
-	log.Printf("  Metrics server: %s", *metricsAddr)
-	log.Printf("  pprof server: %s", *pprofAddr)
-	log.Printf("  Recording path: %s", *recordPath)
-
-	// Start pprof server
-	go func() {
-		log.Printf("Starting pprof server on %s", *pprofAddr)
-		if err := http.ListenAndServe(*pprofAddr, nil); err != nil {
-			log.Printf("pprof server error: %v", err)
-		}
-	}()
-
-	// Start metrics server
-	go func() {
-		log.Printf("Starting metrics server on %s", *metricsAddr)
-		if err := s.metrics.StartServer(*metricsAddr); err != nil {
-			log.Printf("Metrics server error: %v", err)
-		}
-	}()
-
-	// Start HTTP server
-	go func() {
-		log.Printf("Starting HTTP server on %s", *httpAddr)
-		if err := s.httpServer.ListenAndServe(); err != http.ErrServerClosed {
-			log.Printf("HTTP server error: %v", err)
-		}
-	}()
-
-	// Start goroutines
-	// readFrames: 2-stage pipeline — SHM read (ReadLatestCopy) + async WebRTC send
-	s.wg.Add(2)
-	go s.readFrames()
-	go s.distributeRecorder()
-
-	log.Println("Server started successfully")
-	return nil
-}
-
-// readFrames reads frames from shared memory using a 2-stage pipeline.
-//
-// Stage 1 (this goroutine): ReadLatestCopy → Process → recorder copy → sendCh
-// Stage 2 (sender goroutine): sendCh → SendFrame (blocks per-frame on wg.Wait)
-//
-// ReadLatestCopy returns an independent Go-owned copy of the VPU buffer, so
-// the sender goroutine can hold frame.Data safely while Stage 1 immediately
-// calls ReadLatestCopy again for the next frame. This breaks the serialisation
-// that existed when ReadLatest (zero-copy, valid only until next ReadLatest)
-// was used together with the blocking SendFrame.
-func (s *Server) readFrames() {
-	defer s.wg.Done()
-
-	// Stage 2: async sender using self-contained WebRTC (signal package).
-	// Replaces pion's SendFrame with our own RTP packetization + SRTP encryption.
-	sendCh := make(chan *types.VideoFrame, 1)
-	var sendWg sync.WaitGroup
-	sendWg.Add(1)
-	var rtpSeq uint16
-	var rtpSSRC uint32 = 0x12345678
-	go func() {
-		defer sendWg.Done()
-		for frame := range sendCh {
-			ts := uint32(frame.FrameNumber * 3000) // 90kHz / 30fps = 3000 ticks
-			packets, nextSeq := rtppack.PacketizeH265(frame, rtpSSRC, rtpSeq, ts, 1200)
-			rtpSeq = nextSeq
-			s.signal.SendFrame(packets)
-			s.metrics.WebRTCFramesSent.Add(1)
-			// Return the SHM read buffer to pool
-			buf := frame.Data
-			s.shmBufPool.Put(&buf)
-		}
-	}()
-
-	// Ensure the sender goroutine is drained and exited before readFrames returns.
-	defer func() {
-		close(sendCh)
-		sendWg.Wait()
-	}()
-
-	// Measure camera frame interval and sync to frame boundary.
-	interval := s.shmReader.MeasureFrameInterval(5)
-	logger.Info("Reader", "Frame interval: %v (double-buffered)", interval)
-
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	missCount := 0
-	lastVer := s.shmReader.Version()
-
-	for {
-		select {
-		case <-s.ctx.Done():
-			return
-		case <-ticker.C:
-		}
-
-		// Skip reading if no clients and not recording.
-		if s.signal.GetClientCount() == 0 && !s.recorder.IsRecording() {
-			lastVer = s.shmReader.Version()
-			continue
-		}
-
-		// Check for new frame.
-		ver := s.shmReader.Version()
-		if ver == lastVer {
-			missCount++
-			// Camera switch or stall — re-sync after 5 consecutive misses.
-			if missCount > 5 {
-				interval = s.shmReader.MeasureFrameInterval(3)
-				ticker.Reset(interval)
-				lastVer = s.shmReader.Version()
-				missCount = 0
-				logger.Debug("Reader", "Re-synced frame interval: %v", interval)
-			}
-			continue
-		}
-		lastVer = ver
-		missCount = 0
-
-		// Read latest frame into a pooled buffer (import + memcpy + VPU free).
-		// frame.Data is a plain Go []byte; no VPU lifetime dependency.
-		// The Stage 2 sender goroutine returns frame.Data to shmBufPool after SendFrame.
-		shmBufPtr := s.shmBufPool.Get().(*[]byte)
-		frame, err := s.shmReader.ReadLatestCopyBuf(*shmBufPtr)
-		if err != nil {
-			s.shmBufPool.Put(shmBufPtr)
-			s.metrics.ReadErrors.Add(1)
-			logger.Warn("Reader", "Read error: %v", err)
-			continue
-		}
-		if frame == nil {
-			s.shmBufPool.Put(shmBufPtr)
-			continue
-		}
-
-		s.metrics.FramesRead.Add(1)
-		s.metrics.UpdateFrameLatency(frame.Timestamp)
-
-		// Process (NAL parsing, header extraction) — safe on our owned copy.
-		if err := s.processor.Process(frame); err != nil {
-			s.metrics.ProcessErrors.Add(1)
-			buf := frame.Data
-			s.shmBufPool.Put(&buf)
-			continue
-		}
-		if s.processor.HasHeaders() {
-			s.recorder.UpdateHeaders(s.processor.GetVPS(), s.processor.GetSPS(), s.processor.GetPPS())
-		}
-		s.metrics.FramesProcessed.Add(1)
-
-		// Recorder path: copy frame.Data into a pool buffer.
-		// This copy is separate from the WebRTC frame so that distributeRecorder
-		// can call recorderBufPool.Put after the recorder consumes it, while the
-		// WebRTC sender still holds frame.Data independently.
-		if s.recorder.IsRecording() {
-			bufPtr := s.recorderBufPool.Get().(*[]byte)
-			buf := (*bufPtr)[:0]
-			if cap(buf) < len(frame.Data) {
-				buf = make([]byte, len(frame.Data))
-			} else {
-				buf = buf[:len(frame.Data)]
-			}
-			copy(buf, frame.Data)
-			recFrame := *frame
-			recFrame.Data = buf
-			select {
-			case s.recorderChan <- &recFrame:
-			default:
-				s.recorderBufPool.Put(&buf)
-				s.metrics.RecorderFramesDropped.Add(1)
-			}
-		}
-
-		// Hand frame off to the async sender (Stage 2).
-		// sendCh has capacity 1; if the sender is still busy with the previous
-		// frame we drop rather than block — the recorder path above has already
-		// captured this frame independently.
-		select {
-		case sendCh <- frame:
-		default:
-			// Sender busy; drop WebRTC frame for this tick (recorder already saved it).
-			// Return the SHM buffer immediately since Stage 2 won't see this frame.
-			buf := frame.Data
-			s.shmBufPool.Put(&buf)
-			logger.Debug("Reader", "WebRTC sender busy, dropping frame %d", frame.FrameNumber)
-		}
-	}
-}
-
-// distributeRecorder distributes frames to recorder
-func (s *Server) distributeRecorder() {
-	defer s.wg.Done()
-
-	for {
-		select {
-		case <-s.ctx.Done():
-			return
-		case frame := <-s.recorderChan:
-			// frame.Data is already copied by readFrames (VPU buffer is transient)
-			if s.recorder.SendFrame(frame) {
-				s.metrics.RecorderFramesSent.Add(1)
-			}
-			s.recorderBufPool.Put(&frame.Data) // return buffer to pool
-
-			// Update recording metrics
-			status := s.recorder.GetStatus()
-			if status.Recording {
-				s.metrics.RecordingActive.Store(1)
-				s.metrics.RecordingBytes.Store(status.BytesWritten)
-				s.metrics.RecordingFrames.Store(status.FrameCount)
-			} else {
-				s.metrics.RecordingActive.Store(0)
-			}
-		}
-	}
-}
-
-// setupRoutes sets up HTTP routes
-func (s *Server) setupRoutes(mux *http.ServeMux) {
-	// CORS middleware
-	corsMiddleware := func(next http.HandlerFunc) http.HandlerFunc {
-		return func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-			if r.Method == "OPTIONS" {
-				w.WriteHeader(http.StatusOK)
-				return
-			}
-
-			next(w, r)
-		}
-	}
-
-	// WebRTC signaling
-	mux.HandleFunc("/offer", corsMiddleware(s.handleOffer))
-
-	// Recording control
-	mux.HandleFunc("/start", corsMiddleware(s.handleStartRecording))
-	mux.HandleFunc("/stop", corsMiddleware(s.handleStopRecording))
-	mux.HandleFunc("/status", corsMiddleware(s.handleStatus))
-
-	// Client count API
-	mux.HandleFunc("/api/clients/count", corsMiddleware(s.handleClientCount))
-
-	// Health check
-	mux.HandleFunc("/health", s.handleHealth)
-}
-
-// handleOffer handles WebRTC offer
-func (s *Server) handleOffer(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	offerJSON, err := io.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, "Failed to read body", http.StatusBadRequest)
-		return
-	}
-
-	answerJSON, err := s.signal.HandleOffer(offerJSON)
-	if err != nil {
-		log.Printf("[HTTP] WebRTC offer error: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to handle offer: %v", err), http.StatusInternalServerError)
-		return
-	}
-
-	s.metrics.TotalClients.Add(1)
-
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(answerJSON)
-}
-
-// handleStartRecording handles start recording request
-func (s *Server) handleStartRecording(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	if err := s.recorder.Start(); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to start recording: %v", err), http.StatusInternalServerError)
+	if *version {
+		fmt.Println(buildinfo.String())
 		return
 	}
 
-	status := s.recorder.GetStatus()
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"status":  status,
-	})
-}
-
-// handleStopRecording handles stop recording request
-func (s *Server) handleStopRecording(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	if err := s.recorder.Stop(); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to stop recording: %v", err), http.StatusInternalServerError)
-		return
+	if err := logOpts.Init(); err != nil {
+		log.Fatalf("Invalid log level: %v", err)
 	}
+	logger.Info("Main", "Streaming server %s starting...", buildinfo.String())
 
-	status := s.recorder.GetStatus()
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"status":  status,
-	})
-}
-
-// handleStatus handles status request
-func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
-	status := s.recorder.GetStatus()
-	json.NewEncoder(w).Encode(status)
-}
-
-// handleHealth handles health check
-func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":         "ok",
-		"webrtc_clients": s.signal.GetClientCount(),
-		"recording":      s.recorder.IsRecording(),
-		"has_headers":    s.processor.HasHeaders(),
-	})
-}
-
-// handleClientCount returns the current WebRTC client count
-func (s *Server) handleClientCount(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"count": s.signal.GetClientCount(),
-	})
-}
-
-// Shutdown gracefully shuts down the server
-func (s *Server) Shutdown() error {
-	// Cancel context to stop goroutines
-	s.cancel()
-
-	// Wait for goroutines
-	s.wg.Wait()
-
-	// Stop recording if active
-	if s.recorder.IsRecording() {
-		s.recorder.Stop()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := streamserver.Run(ctx, cfg); err != nil {
+		log.Fatal(err)
 	}
-
-	// Close components
-	s.recorder.Close()
-	s.signal.Close()
-	s.shmReader.Close()
-
-	// Shutdown HTTP server
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	return s.httpServer.Shutdown(ctx)
 }
@@ -0,0 +1,92 @@
+// Package buildinfo reports which build is running. Version, Commit and Date
+// are set at link time (scripts/build.sh does this):
+//
+//	go build -ldflags "-X $PKG.Version=1.4.0 -X $PKG.Commit=$(git rev-parse --short HEAD) -X $PKG.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// with PKG=github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/buildinfo.
+// Without them the commit and date come from the VCS stamp go build embeds
+// in a git checkout, if any.
+package buildinfo
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// Set with -ldflags -X.
+var (
+	Version = "dev"
+	Commit  = ""
+	Date    = "" // RFC 3339
+)
+
+var started = time.Now()
+
+// Info is the server_info block of status responses.
+type Info struct {
+	Version       string  `json:"version"`
+	Commit        string  `json:"commit,omitempty"`
+	BuildDate     string  `json:"build_date,omitempty"`
+	GoVersion     string  `json:"go_version"`
+	UptimeSeconds float64 `json:"uptime_seconds"`
+}
+
+// Get returns the build of this binary and how long it has been running.
+func Get() Info {
+	info := Info{
+		Version:       Version,
+		Commit:        Commit,
+		BuildDate:     Date,
+		GoVersion:     runtime.Version(),
+		UptimeSeconds: time.Since(started).Truncate(time.Second).Seconds(),
+	}
+	if info.Commit == "" || info.BuildDate == "" {
+		if bi, ok := debug.ReadBuildInfo(); ok {
+			fillFromVCS(&info, bi.Settings)
+		}
+	}
+	return info
+}
+
+// fillFromVCS sets the commit and date still missing from the VCS stamp.
+func fillFromVCS(info *Info, settings []debug.BuildSetting) {
+	var revision string
+	dirty := false
+	for _, s := range settings {
+		switch s.Key {
+		case "vcs.revision":
+			if len(s.Value) >= 7 {
+				revision = s.Value[:7]
+			}
+		case "vcs.time":
+			if info.BuildDate == "" {
+				info.BuildDate = s.Value
+			}
+		case "vcs.modified":
+			dirty = s.Value == "true"
+		}
+	}
+	if info.Commit == "" && revision != "" {
+		info.Commit = revision
+		if dirty {
+			info.Commit += "-dirty"
+		}
+	}
+}
+
+// String is the -version output, e.g. "1.4.0 (commit 1a2b3c4, built
+// 2026-03-01T10:00:00Z, go1.25.5)".
+func String() string {
+	info := Get()
+	commit := info.Commit
+	if commit == "" {
+		commit = "unknown"
+	}
+	s := info.Version + " (commit " + commit
+	if info.BuildDate != "" {
+		s += ", built " + info.BuildDate
+	}
+	return fmt.Sprintf("%s, %s)", s, info.GoVersion)
+}
@@ -0,0 +1,38 @@
+package buildinfo
+
+import (
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+func TestFillFromVCS(t *testing.T) {
+	settings := []debug.BuildSetting{
+		{Key: "vcs.revision", Value: "0123456789abcdef"},
+		{Key: "vcs.time", Value: "2026-03-01T10:00:00Z"},
+		{Key: "vcs.modified", Value: "true"},
+	}
+	info := Info{Version: "dev"}
+	fillFromVCS(&info, settings)
+	if info.Commit != "0123456-dirty" || info.BuildDate != "2026-03-01T10:00:00Z" {
+		t.Fatalf("%+v", info)
+	}
+
+	// Link-time values win
+	info = Info{Commit: "abcdef0", BuildDate: "2026-01-01T00:00:00Z"}
+	fillFromVCS(&info, settings)
+	if info.Commit != "abcdef0" || info.BuildDate != "2026-01-01T00:00:00Z" {
+		t.Fatalf("overrode ldflags: %+v", info)
+	}
+}
+
+func TestString(t *testing.T) {
+	Version, Commit, Date = "1.4.0", "1a2b3c4", "2026-03-01T10:00:00Z"
+	defer func() { Version, Commit, Date = "dev", "", "" }()
+	if s := String(); !strings.HasPrefix(s, "1.4.0 (commit 1a2b3c4, built 2026-03-01T10:00:00Z, go") {
+		t.Errorf("String() = %q", s)
+	}
+	if info := Get(); info.UptimeSeconds < 0 || info.GoVersion == "" {
+		t.Errorf("%+v", info)
+	}
+}
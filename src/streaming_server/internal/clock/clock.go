@@ -0,0 +1,179 @@
+// Package clock holds the display time zone shared by overlays and file
+// names, and detects wall-clock jumps such as the first NTP sync after the
+// board boots without a valid RTC time.
+package clock
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FileStampLayout is the timestamp layout used in recording and snapshot names.
+const FileStampLayout = "20060102_150405"
+
+var location atomic.Pointer[time.Location]
+
+// SetLocation sets the display time zone.
+func SetLocation(loc *time.Location) {
+	location.Store(loc)
+}
+
+// Location returns the display time zone (time.Local until SetLocation).
+func Location() *time.Location {
+	if loc := location.Load(); loc != nil {
+		return loc
+	}
+	return time.Local
+}
+
+// FileStamp formats t for file names in the display time zone.
+func FileStamp(t time.Time) string {
+	return t.In(Location()).Format(FileStampLayout)
+}
+
+// LoadLocation resolves a time zone name: "" or "Local" for the system zone,
+// an IANA name ("Asia/Tokyo"), or a fixed offset ("+09:00", "UTC+9").
+// Asia/Tokyo falls back to a fixed JST offset when the board has no tzdata.
+func LoadLocation(name string) (*time.Location, error) {
+	switch name {
+	case "", "Local":
+		return time.Local, nil
+	case "JST":
+		return time.FixedZone("JST", 9*3600), nil
+	}
+	if loc, err := time.LoadLocation(name); err == nil {
+		return loc, nil
+	} else if name == "Asia/Tokyo" {
+		return time.FixedZone("JST", 9*3600), nil
+	} else if loc, ok := parseOffset(name); ok {
+		return loc, nil
+	} else {
+		return nil, err
+	}
+}
+
+// parseOffset parses "+09:00", "-0530", "UTC+9" and "GMT-3".
+func parseOffset(name string) (*time.Location, bool) {
+	s := strings.TrimPrefix(strings.TrimPrefix(name, "UTC"), "GMT")
+	if len(s) < 2 || (s[0] != '+' && s[0] != '-') {
+		return nil, false
+	}
+	sign := 1
+	if s[0] == '-' {
+		sign = -1
+	}
+	hh, mm := strings.ReplaceAll(s[1:], ":", ""), ""
+	if len(hh) > 2 {
+		hh, mm = hh[:len(hh)-2], hh[len(hh)-2:]
+	}
+	h, err := strconv.Atoi(hh)
+	if err != nil || h > 14 {
+		return nil, false
+	}
+	m := 0
+	if mm != "" {
+		if m, err = strconv.Atoi(mm); err != nil || m >= 60 {
+			return nil, false
+		}
+	}
+	return time.FixedZone(name, sign*(h*3600+m*60)), true
+}
+
+// ntpSyncedFlag is created by systemd-timesyncd once the clock is synchronized.
+var ntpSyncedFlag = "/run/systemd/timesync/synchronized"
+
+// NTPSynced reports whether systemd-timesyncd has synchronized the clock.
+func NTPSynced() bool {
+	_, err := os.Stat(ntpSyncedFlag)
+	return err == nil
+}
+
+// Jump is a wall-clock step not matched by the monotonic clock.
+type Jump struct {
+	Delta     time.Duration // positive: the wall clock moved forward
+	At        time.Time     // wall time after the jump
+	NTPSynced bool
+}
+
+func (j Jump) String() string {
+	return fmt.Sprintf("clock jumped %+v (ntp synced: %v)", j.Delta.Round(time.Second), j.NTPSynced)
+}
+
+// JumpDetector compares wall and monotonic elapsed time every Interval and
+// reports differences larger than Threshold.
+type JumpDetector struct {
+	Interval  time.Duration
+	Threshold time.Duration
+
+	onJump  func(Jump)
+	mu      sync.Mutex
+	stop    chan struct{}
+	stopped bool
+}
+
+// NewJumpDetector creates a detector with default settings.
+func NewJumpDetector() *JumpDetector {
+	return &JumpDetector{
+		Interval:  5 * time.Second,
+		Threshold: 2 * time.Second,
+		stop:      make(chan struct{}),
+	}
+}
+
+// SetOnJump sets the callback invoked for each detected jump.
+func (d *JumpDetector) SetOnJump(fn func(Jump)) {
+	d.mu.Lock()
+	d.onJump = fn
+	d.mu.Unlock()
+}
+
+// Start begins monitoring.
+func (d *JumpDetector) Start() {
+	go d.run()
+}
+
+// Stop halts monitoring.
+func (d *JumpDetector) Stop() {
+	d.mu.Lock()
+	if !d.stopped {
+		close(d.stop)
+		d.stopped = true
+	}
+	d.mu.Unlock()
+}
+
+func (d *JumpDetector) run() {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	base := time.Now()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		delta := drift(base.Round(0), now.Round(0), now.Sub(base))
+		base = now
+		if delta > -d.Threshold && delta < d.Threshold {
+			continue
+		}
+		d.mu.Lock()
+		fn := d.onJump
+		d.mu.Unlock()
+		if fn != nil {
+			fn(Jump{Delta: delta, At: now, NTPSynced: NTPSynced()})
+		}
+	}
+}
+
+// drift is how far the wall clock moved beyond the monotonic elapsed time.
+func drift(baseWall, nowWall time.Time, monoElapsed time.Duration) time.Duration {
+	return nowWall.Sub(baseWall) - monoElapsed
+}
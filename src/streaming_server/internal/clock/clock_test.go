@@ -0,0 +1,58 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadLocation(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset int // seconds east of UTC
+	}{
+		{"JST", 9 * 3600},
+		{"Asia/Tokyo", 9 * 3600},
+		{"UTC", 0},
+		{"+09:00", 9 * 3600},
+		{"-0530", -(5*3600 + 30*60)},
+		{"UTC+9", 9 * 3600},
+		{"GMT-3", -3 * 3600},
+	}
+	ref := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		loc, err := LoadLocation(tt.name)
+		if err != nil {
+			t.Errorf("LoadLocation(%q): %v", tt.name, err)
+			continue
+		}
+		if _, off := ref.In(loc).Zone(); off != tt.offset {
+			t.Errorf("LoadLocation(%q) offset = %d, want %d", tt.name, off, tt.offset)
+		}
+	}
+
+	for _, bad := range []string{"Mars/Olympus", "+25:00", "UTC+x"} {
+		if _, err := LoadLocation(bad); err == nil {
+			t.Errorf("LoadLocation(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestFileStamp(t *testing.T) {
+	defer SetLocation(nil)
+	SetLocation(time.FixedZone("JST", 9*3600))
+	ts := time.Date(2025, 3, 1, 15, 4, 5, 0, time.UTC)
+	if got := FileStamp(ts); got != "20250302_000405" {
+		t.Errorf("FileStamp = %q", got)
+	}
+}
+
+func TestDrift(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if d := drift(base, base.Add(5*time.Second), 5*time.Second); d != 0 {
+		t.Errorf("steady clock drift = %v", d)
+	}
+	// NTP stepped the clock forward an hour between two 5s samples
+	if d := drift(base, base.Add(time.Hour+5*time.Second), 5*time.Second); d != time.Hour {
+		t.Errorf("forward jump drift = %v, want 1h", d)
+	}
+}
@@ -0,0 +1,115 @@
+package codec
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// gopHistory is how many complete GOPs GOPTracker remembers.
+	gopHistory = 8
+	// gopChangeRatio is how far a GOP's duration must move from the one
+	// before it to count as a change of keyframe interval (frame drops and
+	// capture jitter move it less).
+	gopChangeRatio = 0.25
+	// MaxIDRWait bounds IDRWait, so a stream that stopped sending IDRs
+	// cannot hold a cut indefinitely.
+	MaxIDRWait = 10 * time.Second
+)
+
+// GOPTracker measures the GOPs of a stream from the frames Process sees, so
+// the code cutting at IDRs can follow the encoder's keyframe interval
+// instead of assuming the camera's 1s. It is safe for concurrent use.
+type GOPTracker struct {
+	mu      sync.Mutex
+	frames  int       // frames since the last IDR, including it
+	start   time.Time // capture time of the last IDR
+	started bool      // an IDR has been seen
+	recent  [gopHistory]GOP
+	n       int // complete GOPs seen
+	changes uint64
+}
+
+// GOP is one complete GOP: an IDR and the frames up to the next one.
+type GOP struct {
+	Frames   int
+	Duration time.Duration
+}
+
+// GOPStats summarizes the recent GOPs.
+type GOPStats struct {
+	GOPs          int     `json:"gops"`            // complete GOPs seen
+	Frames        int     `json:"frames"`          // of the last complete GOP
+	DurationMs    float64 `json:"duration_ms"`     // of the last complete GOP
+	MaxFrames     int     `json:"max_frames"`      // longest of the recent GOPs
+	MaxDurationMs float64 `json:"max_duration_ms"` // longest of the recent GOPs
+	Current       int     `json:"current_frames"`  // frames since the last IDR
+	Changes       uint64  `json:"changes"`         // keyframe interval changes seen
+}
+
+// Observe accounts one frame with its capture time.
+func (t *GOPTracker) Observe(isIDR bool, ts time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !isIDR {
+		if t.started {
+			t.frames++
+		}
+		return
+	}
+	if t.started {
+		gop := GOP{Frames: t.frames}
+		if !ts.IsZero() && ts.After(t.start) {
+			gop.Duration = ts.Sub(t.start)
+		}
+		if t.n > 0 {
+			prev := t.recent[(t.n-1)%gopHistory]
+			if diff := gop.Duration - prev.Duration; float64(diff.Abs()) > gopChangeRatio*float64(prev.Duration) {
+				t.changes++
+			}
+		}
+		t.recent[t.n%gopHistory] = gop
+		t.n++
+	}
+	t.started, t.frames, t.start = true, 1, ts
+}
+
+// Stats returns the recent GOPs' summary.
+func (t *GOPTracker) Stats() GOPStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := GOPStats{GOPs: t.n, Current: t.frames, Changes: t.changes}
+	if t.n == 0 {
+		return st
+	}
+	last := t.recent[(t.n-1)%gopHistory]
+	st.Frames, st.DurationMs = last.Frames, float64(last.Duration)/float64(time.Millisecond)
+	longest := t.longestLocked()
+	st.MaxFrames, st.MaxDurationMs = longest.Frames, float64(longest.Duration)/float64(time.Millisecond)
+	return st
+}
+
+// longestLocked returns the longest recent GOP. Caller holds t.mu.
+func (t *GOPTracker) longestLocked() GOP {
+	var longest GOP
+	for i := 0; i < min(t.n, gopHistory); i++ {
+		g := t.recent[i]
+		longest.Frames = max(longest.Frames, g.Frames)
+		longest.Duration = max(longest.Duration, g.Duration)
+	}
+	return longest
+}
+
+// IDRWait returns how long a cut should wait for the next IDR: half again
+// the longest recent GOP, so a GOP that just grew is still waited out, but
+// never less than floor and at most MaxIDRWait. Before a GOP was measured
+// it is floor.
+func (t *GOPTracker) IDRWait(floor time.Duration) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.n == 0 {
+		return floor
+	}
+	wait := t.longestLocked().Duration * 3 / 2
+	return min(max(wait, floor), max(floor, MaxIDRWait))
+}
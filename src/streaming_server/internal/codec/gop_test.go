@@ -0,0 +1,65 @@
+package codec
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/types"
+)
+
+func TestGOPTracker(t *testing.T) {
+	var g GOPTracker
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	frame := 0
+	// feed sends n frames at 30 fps, the first an IDR if idr is set
+	feed := func(n int, idr bool) {
+		for i := 0; i < n; i++ {
+			g.Observe(idr && i == 0, start.Add(time.Duration(frame)*time.Second/30))
+			frame++
+		}
+	}
+
+	g.Observe(false, start) // before the first IDR: not counted
+	if st := g.Stats(); st != (GOPStats{}) || g.IDRWait(2*time.Second) != 2*time.Second {
+		t.Errorf("no GOP yet: %+v", st)
+	}
+	feed(30, true)
+	feed(30, true)
+	feed(1, true) // the IDR closing the second GOP
+	if st := g.Stats(); st.GOPs != 2 || st.Frames != 30 || st.DurationMs != 1000 || st.Changes != 0 || st.Current != 1 {
+		t.Errorf("1s GOPs: %+v", st)
+	}
+	if w := g.IDRWait(2 * time.Second); w != 2*time.Second {
+		t.Errorf("1s GOPs wait %v", w)
+	}
+
+	// The encoder switches to 4s GOPs: the wait follows
+	feed(119, false)
+	feed(1, true)
+	if st := g.Stats(); st.Frames != 120 || st.MaxDurationMs != 4000 || st.Changes != 1 {
+		t.Errorf("4s GOP: %+v", st)
+	}
+	if w := g.IDRWait(2 * time.Second); w != 6*time.Second {
+		t.Errorf("4s GOP wait %v", w)
+	}
+	feed(599, false)
+	feed(1, true)
+	if w := g.IDRWait(2 * time.Second); w != MaxIDRWait {
+		t.Errorf("20s GOP wait %v", w)
+	}
+}
+
+func TestProcessorTracksGOPs(t *testing.T) {
+	p := NewProcessor()
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for n := 0; n < 11; n++ {
+		nal := byte(0x02) // TRAIL_R
+		if n%5 == 0 {
+			nal = 0x26 // IDR_W_RADL
+		}
+		p.Process(&types.VideoFrame{Data: []byte{0, 0, 0, 1, nal, 0x01}, Timestamp: start.Add(time.Duration(n) * 100 * time.Millisecond)})
+	}
+	if st := p.GOP().Stats(); st.GOPs != 2 || st.Frames != 5 || st.DurationMs != 500 {
+		t.Errorf("stats %+v", st)
+	}
+}
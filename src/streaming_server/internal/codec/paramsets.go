@@ -0,0 +1,95 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/types"
+)
+
+// ParamSetsFile is the file name, inside a recordings directory, under which
+// the last seen parameter sets are kept across restarts. Hidden and without
+// a video extension so recording listings skip it.
+const ParamSetsFile = ".paramsets"
+
+// ParamSets is a VPS/SPS/PPS triple, each NAL including its start code.
+// Concatenated they form a valid Annex-B prefix for an IDR frame.
+type ParamSets struct {
+	VPS []byte
+	SPS []byte
+	PPS []byte
+}
+
+// Complete reports whether all three parameter sets are present.
+func (ps ParamSets) Complete() bool {
+	return len(ps.VPS) > 0 && len(ps.SPS) > 0 && len(ps.PPS) > 0
+}
+
+// Equal reports whether ps and other hold the same parameter sets.
+func (ps ParamSets) Equal(other ParamSets) bool {
+	return bytes.Equal(ps.VPS, other.VPS) && bytes.Equal(ps.SPS, other.SPS) && bytes.Equal(ps.PPS, other.PPS)
+}
+
+// Bytes returns VPS+SPS+PPS as one Annex-B buffer.
+func (ps ParamSets) Bytes() []byte {
+	out := make([]byte, 0, len(ps.VPS)+len(ps.SPS)+len(ps.PPS))
+	out = append(out, ps.VPS...)
+	out = append(out, ps.SPS...)
+	return append(out, ps.PPS...)
+}
+
+// ParamSets returns the cached parameter sets (empty until an IDR with
+// headers has been processed or Prime was called).
+func (p *Processor) ParamSets() ParamSets {
+	return ParamSets{VPS: p.vpsCache, SPS: p.spsCache, PPS: p.ppsCache}
+}
+
+// Prime seeds the header cache from parameter sets seen earlier (e.g. by a
+// previous process), so a recording started before the next IDR's headers
+// arrive is still playable. Headers already parsed from the live stream are
+// kept; incomplete sets are ignored.
+func (p *Processor) Prime(ps ParamSets) {
+	if p.hasHeaders || !ps.Complete() {
+		return
+	}
+	p.vpsCache = append([]byte(nil), ps.VPS...)
+	p.spsCache = append([]byte(nil), ps.SPS...)
+	p.ppsCache = append([]byte(nil), ps.PPS...)
+	p.hasHeaders = true
+}
+
+// SaveParamSets writes ps to path as an Annex-B file (temp file + rename).
+func SaveParamSets(path string, ps ParamSets) error {
+	if !ps.Complete() {
+		return fmt.Errorf("incomplete parameter sets")
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, ps.Bytes(), 0644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadParamSets reads parameter sets written by SaveParamSets. A missing
+// file yields empty sets and no error.
+func LoadParamSets(path string) (ParamSets, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ParamSets{}, nil
+		}
+		return ParamSets{}, err
+	}
+	p := NewProcessor()
+	if err := p.Process(&types.VideoFrame{Data: data}); err != nil {
+		return ParamSets{}, err
+	}
+	if !p.HasHeaders() {
+		return ParamSets{}, fmt.Errorf("%s: no VPS/SPS/PPS", path)
+	}
+	return p.ParamSets(), nil
+}
@@ -0,0 +1,59 @@
+package codec
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/types"
+)
+
+func TestParamSetsSaveLoadPrime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "paramsets.hevc")
+
+	if ps, err := LoadParamSets(path); err != nil || ps.Complete() {
+		t.Fatalf("missing file: got %+v, %v", ps, err)
+	}
+
+	live := NewProcessor()
+	if err := live.Process(&types.VideoFrame{Data: makeIDRFrameWithHeaders()}); err != nil {
+		t.Fatal(err)
+	}
+	saved := live.ParamSets()
+	if err := SaveParamSets(path, saved); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadParamSets(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Equal(saved) {
+		t.Fatal("loaded parameter sets differ from saved")
+	}
+
+	// A fresh processor primed from disk prepends headers to a bare IDR.
+	p := NewProcessor()
+	p.Prime(loaded)
+	if !p.HasHeaders() {
+		t.Fatal("Prime did not install headers")
+	}
+	idr := buildFrame(struct {
+		t   uint8
+		len int
+	}{types.NALTypeH265IDRWRADL, 100})
+	out, _ := p.PrependHeaders(idr)
+	if !bytes.Equal(out, append(saved.Bytes(), idr...)) {
+		t.Error("PrependHeaders did not use primed parameter sets")
+	}
+
+	// Priming never overrides headers already taken from the stream.
+	p.Prime(ParamSets{VPS: []byte{1}, SPS: []byte{2}, PPS: []byte{3}})
+	if !p.ParamSets().Equal(saved) {
+		t.Error("Prime replaced existing headers")
+	}
+
+	if err := SaveParamSets(path, ParamSets{VPS: saved.VPS}); err == nil {
+		t.Error("SaveParamSets accepted incomplete sets")
+	}
+}
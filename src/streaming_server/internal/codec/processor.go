@@ -19,6 +19,7 @@ type Processor struct {
 	spsCache   []byte // Cached SPS NAL unit
 	ppsCache   []byte // Cached PPS NAL unit
 	hasHeaders bool   // True if VPS/SPS/PPS are cached
+	gop        GOPTracker
 }
 
 // NewProcessor creates a new H.265 NAL processor
@@ -101,9 +102,15 @@ func (p *Processor) Process(frame *types.VideoFrame) error {
 		offset = nalEnd
 	}
 
+	p.gop.Observe(frame.IsIDR, frame.Timestamp)
 	return nil
 }
 
+// GOP returns the tracker of the GOPs processed so far.
+func (p *Processor) GOP() *GOPTracker {
+	return &p.gop
+}
+
 // containsIDR scans raw H.265 data and returns true if any NAL unit is an IDR.
 // Zero-allocation: avoids the full parseNALUnits copy path used only to check
 // for IDR presence. Called only from PrependHeaders (cold path, IDR frames).
@@ -233,7 +240,7 @@ func (p *Processor) parseNALUnits(data []byte) ([]types.NALUnit, error) {
 }
 
 // findNextStartCode finds the next start code (0x000001 or 0x00000001) at or
-// after offset.
+// after offset (a negative offset searches from the start).
 //
 // Implementation uses bytes.Index which leverages NEON SIMD on ARM64 for a
 // ~10x speedup over per-byte scanning on typical H.265 frame sizes (>1KB).
@@ -245,6 +252,7 @@ func (p *Processor) findNextStartCode(data []byte, offset int) int {
 	if offset >= len(data) {
 		return -1
 	}
+	offset = max(offset, 0)
 	sub := data[offset:]
 	i := bytes.Index(sub, startCode3)
 	if i < 0 {
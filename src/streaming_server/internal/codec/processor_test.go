@@ -268,6 +268,144 @@ func TestPrependHeaders(t *testing.T) {
 	}
 }
 
+// --- Fuzz tests ---
+
+// Parameter sets of a 1280x720 Main profile stream as hardware encoders emit
+// them, with emulation prevention bytes (00 00 03) inside the NAL units.
+var (
+	seedVPS = []byte{0x40, 0x01, 0x0c, 0x01, 0xff, 0xff, 0x01, 0x60, 0x00, 0x00, 0x03, 0x00, 0x90,
+		0x00, 0x00, 0x03, 0x00, 0x00, 0x03, 0x00, 0x5d, 0x95, 0x98, 0x09}
+	seedSPS = []byte{0x42, 0x01, 0x01, 0x01, 0x60, 0x00, 0x00, 0x03, 0x00, 0x90, 0x00, 0x00, 0x03,
+		0x00, 0x00, 0x03, 0x00, 0x5d, 0xa0, 0x02, 0x80, 0x80, 0x2d, 0x16, 0x59, 0x59, 0xa4, 0x93,
+		0x2b, 0xc0, 0x5a, 0x70, 0x80, 0x00, 0x01, 0xf4, 0x80, 0x00, 0x3a, 0x98, 0x04}
+	seedPPS = []byte{0x44, 0x01, 0xc1, 0x72, 0xb4, 0x62, 0x40}
+)
+
+// addFrameSeeds adds whole frames and the ways they arrive damaged.
+func addFrameSeeds(f *testing.F) {
+	var encoded []byte
+	for _, nal := range [][]byte{seedVPS, seedSPS, seedPPS, {0x26, 0x01, 0xaf, 0x00, 0x00, 0x03, 0x01, 0x80}} {
+		encoded = append(encoded, startCode4...)
+		encoded = append(encoded, nal...)
+	}
+	idr := makeIDRFrameWithHeaders()
+	for _, seed := range [][]byte{
+		encoded,
+		idr,
+		idr[:len(idr)/2],
+		makeLargeTrailFrame(100),
+		buildFrame3(struct {
+			t   uint8
+			len int
+		}{types.NALTypeH265IDRNLP, 8}),
+		{0x00, 0x00, 0x01},
+		{0x00, 0x00, 0x00, 0x01},
+		{0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x01},
+		{0xff, 0x00, 0x00},
+	} {
+		f.Add(seed)
+	}
+}
+
+// FuzzProcess checks that every NAL bound Process records lies inside the
+// frame, in order, right after a start code, and that the cached parameter
+// sets are whole NAL units.
+func FuzzProcess(f *testing.F) {
+	addFrameSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p := NewProcessor()
+		frame := &types.VideoFrame{Data: data}
+		if err := p.Process(frame); err != nil {
+			t.Fatal(err)
+		}
+		end, idr := 0, false
+		for i, nal := range frame.NALUs {
+			if nal.Offset < end+3 || nal.Length < 1 || nal.Offset+nal.Length > len(data) {
+				t.Fatalf("NAL %d at %d+%d: outside %d bytes or overlapping the previous one (end %d)",
+					i, nal.Offset, nal.Length, len(data), end)
+			}
+			if !bytes.Equal(data[nal.Offset-3:nal.Offset], startCode3) {
+				t.Fatalf("NAL %d at %d does not follow a start code", i, nal.Offset)
+			}
+			if nal.Type != extractNALType(data[nal.Offset]) {
+				t.Fatalf("NAL %d: type %d, header says %d", i, nal.Type, extractNALType(data[nal.Offset]))
+			}
+			idr = idr || nal.Type == types.NALTypeH265IDRWRADL || nal.Type == types.NALTypeH265IDRNLP
+			end = nal.Offset + nal.Length
+		}
+		if frame.IsIDR != idr || p.containsIDR(data) != idr {
+			t.Fatalf("IsIDR = %v, containsIDR = %v, NAL types say %v", frame.IsIDR, p.containsIDR(data), idr)
+		}
+		for _, ps := range [][]byte{p.GetVPS(), p.GetSPS(), p.GetPPS()} {
+			if len(ps) > 0 && !bytes.HasPrefix(ps, startCode3) && !bytes.HasPrefix(ps, startCode4) {
+				t.Fatalf("cached parameter set %x lacks its start code", ps)
+			}
+		}
+		out, err := p.PrependHeaders(data)
+		if err != nil || !bytes.HasSuffix(out, data) {
+			t.Fatalf("PrependHeaders changed the frame itself (err %v)", err)
+		}
+		ExtractNALType(data)
+	})
+}
+
+// FuzzParseNALUnits checks that parseNALUnits finds the same NAL units as
+// Process, each a copy starting with its start code.
+func FuzzParseNALUnits(f *testing.F) {
+	addFrameSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p := NewProcessor()
+		nals, err := p.parseNALUnits(data)
+		if len(data) == 0 {
+			if err == nil {
+				t.Fatal("empty data parsed without error")
+			}
+			return
+		}
+		frame := &types.VideoFrame{Data: data}
+		p.Process(frame)
+		if len(nals) != len(frame.NALUs) {
+			t.Fatalf("parseNALUnits found %d NAL units, Process %d", len(nals), len(frame.NALUs))
+		}
+		for i, nal := range nals {
+			bound := frame.NALUs[i]
+			header := len(nal.Data) - bound.Length
+			if header != 3 && header != 4 || !bytes.Equal(nal.Data[header:], data[bound.Offset:bound.Offset+bound.Length]) {
+				t.Fatalf("NAL %d: %x, Process bounds %d+%d", i, nal.Data, bound.Offset, bound.Length)
+			}
+			if nal.Type != data[bound.Offset] {
+				t.Fatalf("NAL %d: header byte %#x, want %#x", i, nal.Type, data[bound.Offset])
+			}
+		}
+	})
+}
+
+// FuzzFindNextStartCode checks findNextStartCode against a plain scan, for
+// any offset including ones outside the data.
+func FuzzFindNextStartCode(f *testing.F) {
+	for _, c := range [][]byte{{}, {0x00, 0x00, 0x01}, {0xff, 0x00, 0x00, 0x00, 0x01}, makeIDRFrameWithHeaders()} {
+		f.Add(c, 0)
+		f.Add(c, 1)
+	}
+	f.Add([]byte{0x00, 0x00, 0x01}, -1)
+	f.Fuzz(func(t *testing.T, data []byte, offset int) {
+		got := NewProcessor().findNextStartCode(data, offset)
+		want := -1
+		for i := max(offset, 0); i+3 <= len(data); i++ {
+			if bytes.Equal(data[i:i+3], startCode3) {
+				want = i
+				if i > max(offset, 0) && data[i-1] == 0x00 {
+					want = i - 1
+				}
+				break
+			}
+		}
+		if got != want {
+			t.Fatalf("findNextStartCode(%x, %d) = %d, want %d", data, offset, got, want)
+		}
+	})
+}
+
 // --- Benchmarks ---
 
 // makeLargeTrailFrame builds a realistic trail frame with no VPS/SPS/PPS.
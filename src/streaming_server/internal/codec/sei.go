@@ -0,0 +1,205 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/types"
+)
+
+// TimestampSEIUUID identifies the wall-clock SEI (user_data_unregistered)
+// this package writes. Its payload is the capture time as big-endian Unix
+// microseconds followed by the camera name in UTF-8.
+var TimestampSEIUUID = [16]byte{'p', 'e', 't', 'c', 'a', 'm', '-', 't', 'i', 'm', 'e', 's', 't', 'a', 'm', 'p'}
+
+// FrameNumberSEIUUID identifies the frame number SEI. Its payload is the
+// capture frame number (as in detection events) as a big-endian uint64.
+var FrameNumberSEIUUID = [16]byte{'p', 'e', 't', 'c', 'a', 'm', '-', 'f', 'r', 'a', 'm', 'e', '-', 'n', 'u', 'm'}
+
+const seiPayloadUserDataUnregistered = 5
+
+// TimestampSEI builds an Annex-B prefix SEI NAL carrying t and camera.
+// Decoders that do not know the UUID ignore it, so the picture is untouched.
+func TimestampSEI(t time.Time, camera string) []byte {
+	payload := make([]byte, 0, 8+len(camera))
+	payload = binary.BigEndian.AppendUint64(payload, uint64(t.UnixMicro()))
+	payload = append(payload, camera...)
+	return UserDataSEI(TimestampSEIUUID, payload)
+}
+
+// ParseTimestampSEI decodes a NAL written by TimestampSEI. nal starts at the
+// 2-byte NAL header (no start code), as described by types.NALBound.
+func ParseTimestampSEI(nal []byte) (t time.Time, camera string, ok bool) {
+	uuid, payload, ok := ParseUserDataSEI(nal)
+	if !ok || uuid != TimestampSEIUUID || len(payload) < 8 {
+		return time.Time{}, "", false
+	}
+	us := int64(binary.BigEndian.Uint64(payload[:8]))
+	return time.UnixMicro(us), string(payload[8:]), true
+}
+
+// FrameNumberSEI builds an Annex-B prefix SEI NAL carrying the capture
+// frame number n, which lets a client reading the decoded stream (e.g.
+// through WebRTC encoded transforms) match frames to detection events.
+func FrameNumberSEI(n uint64) []byte {
+	return UserDataSEI(FrameNumberSEIUUID, binary.BigEndian.AppendUint64(nil, n))
+}
+
+// ParseFrameNumberSEI decodes a NAL written by FrameNumberSEI. nal starts
+// at the 2-byte NAL header (no start code).
+func ParseFrameNumberSEI(nal []byte) (uint64, bool) {
+	uuid, payload, ok := ParseUserDataSEI(nal)
+	if !ok || uuid != FrameNumberSEIUUID || len(payload) != 8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(payload), true
+}
+
+// UserDataSEI builds an Annex-B prefix SEI NAL holding one
+// user_data_unregistered message: uuid followed by payload.
+func UserDataSEI(uuid [16]byte, payload []byte) []byte {
+	rbsp := make([]byte, 0, 16+len(payload)+8)
+	rbsp = append(rbsp, seiPayloadUserDataUnregistered)
+	size := 16 + len(payload)
+	for ; size >= 0xFF; size -= 0xFF {
+		rbsp = append(rbsp, 0xFF)
+	}
+	rbsp = append(rbsp, byte(size))
+	rbsp = append(rbsp, uuid[:]...)
+	rbsp = append(rbsp, payload...)
+	rbsp = append(rbsp, 0x80) // rbsp_trailing_bits
+
+	nal := make([]byte, 0, len(startCode4)+2+len(rbsp)+len(rbsp)/64)
+	nal = append(nal, startCode4...)
+	nal = append(nal, types.NALTypeH265SEI<<1, 0x01) // layer 0, temporal id 0
+	return AppendEmulationPrevented(nal, rbsp)
+}
+
+// ParseUserDataSEI decodes the first message of an SEI NAL if it is
+// user_data_unregistered. nal starts at the 2-byte NAL header (no start
+// code).
+func ParseUserDataSEI(nal []byte) (uuid [16]byte, payload []byte, ok bool) {
+	if len(nal) < 3 || extractNALType(nal[0]) != types.NALTypeH265SEI {
+		return uuid, nil, false
+	}
+	rbsp := RemoveEmulationPrevention(nal[2:])
+
+	var payloadType, size int
+	i := 0
+	for i < len(rbsp) && rbsp[i] == 0xFF {
+		payloadType += 0xFF
+		i++
+	}
+	if i >= len(rbsp) {
+		return uuid, nil, false
+	}
+	payloadType += int(rbsp[i])
+	i++
+	for i < len(rbsp) && rbsp[i] == 0xFF {
+		size += 0xFF
+		i++
+	}
+	if i >= len(rbsp) {
+		return uuid, nil, false
+	}
+	size += int(rbsp[i])
+	i++
+
+	if payloadType != seiPayloadUserDataUnregistered || size < 16 || i+size > len(rbsp) {
+		return uuid, nil, false
+	}
+	copy(uuid[:], rbsp[i:i+16])
+	return uuid, rbsp[i+16 : i+size], true
+}
+
+// InsertNAL inserts an Annex-B NAL (start code included) into frame right
+// before its first VCL NAL, where prefix SEI belongs, and updates
+// frame.NALUs. frame must have been through Processor.Process. The existing
+// buffer is reused when it has room. Returns false if frame has no VCL NAL.
+func InsertNAL(frame *types.VideoFrame, nal []byte) bool {
+	idx := -1
+	for i, b := range frame.NALUs {
+		if b.Type < types.NALTypeH265VPS {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return false
+	}
+
+	// Insert before the VCL NAL's start code
+	hdr := frame.NALUs[idx].Offset
+	pos := hdr - len(startCode3)
+	if hdr >= len(startCode4) && bytes.Equal(frame.Data[hdr-len(startCode4):hdr], startCode4) {
+		pos = hdr - len(startCode4)
+	}
+
+	n := len(frame.Data)
+	if cap(frame.Data) >= n+len(nal) {
+		frame.Data = frame.Data[:n+len(nal)]
+		copy(frame.Data[pos+len(nal):], frame.Data[pos:n])
+	} else {
+		grown := make([]byte, n+len(nal))
+		copy(grown, frame.Data[:pos])
+		copy(grown[pos+len(nal):], frame.Data[pos:n])
+		frame.Data = grown
+	}
+	copy(frame.Data[pos:], nal)
+
+	for i := idx; i < len(frame.NALUs); i++ {
+		frame.NALUs[i].Offset += len(nal)
+	}
+	hdrLen := len(startCode3)
+	if bytes.HasPrefix(nal, startCode4) {
+		hdrLen = len(startCode4)
+	}
+	frame.NALUs = append(frame.NALUs, types.NALBound{})
+	copy(frame.NALUs[idx+1:], frame.NALUs[idx:])
+	frame.NALUs[idx] = types.NALBound{
+		Offset: pos + hdrLen,
+		Length: len(nal) - hdrLen,
+		Type:   extractNALType(nal[hdrLen]),
+	}
+	return true
+}
+
+// AppendEmulationPrevented appends rbsp to dst, inserting 0x03 after every
+// pair of zero bytes that would otherwise be followed by 0x00-0x03.
+func AppendEmulationPrevented(dst, rbsp []byte) []byte {
+	zeros := 0
+	for _, b := range rbsp {
+		if zeros >= 2 && b <= 0x03 {
+			dst = append(dst, 0x03)
+			zeros = 0
+		}
+		dst = append(dst, b)
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	return dst
+}
+
+// RemoveEmulationPrevention strips the 0x03 bytes added by
+// AppendEmulationPrevented.
+func RemoveEmulationPrevention(ebsp []byte) []byte {
+	out := make([]byte, 0, len(ebsp))
+	zeros := 0
+	for _, b := range ebsp {
+		if zeros >= 2 && b == 0x03 {
+			zeros = 0
+			continue
+		}
+		out = append(out, b)
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	return out
+}
@@ -0,0 +1,86 @@
+package codec
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/types"
+)
+
+func TestTimestampSEIRoundTrip(t *testing.T) {
+	// 0x000000 inside the timestamp exercises emulation prevention
+	ts := time.UnixMicro(0x0000_0100_0000_0002)
+	camera := strings.Repeat("c", 300) // payload size needs 0xFF extension
+
+	sei := TimestampSEI(ts, camera)
+	if !bytes.HasPrefix(sei, startCode4) || ExtractNALType(sei) != types.NALTypeH265SEI {
+		t.Fatalf("not an Annex-B prefix SEI: % x", sei[:6])
+	}
+	if bytes.Contains(sei[4:], []byte{0, 0, 0}) || bytes.Contains(sei[4:], []byte{0, 0, 1}) {
+		t.Fatal("start code emulation in SEI body")
+	}
+
+	got, gotCamera, ok := ParseTimestampSEI(sei[4:])
+	if !ok || !got.Equal(ts) || gotCamera != camera {
+		t.Fatalf("got %v %q %v", got, gotCamera, ok)
+	}
+}
+
+func TestFrameNumberSEIRoundTrip(t *testing.T) {
+	for _, n := range []uint64{0, 1, 0x0000_0001_0000_0300} {
+		sei := FrameNumberSEI(n)
+		if got, ok := ParseFrameNumberSEI(sei[4:]); !ok || got != n {
+			t.Errorf("%d: got %d %v", n, got, ok)
+		}
+	}
+	if _, ok := ParseFrameNumberSEI(TimestampSEI(time.Now(), "cam")[4:]); ok {
+		t.Error("timestamp SEI parsed as a frame number")
+	}
+}
+
+func TestInsertNAL(t *testing.T) {
+	for _, room := range []int{0, 1024} {
+		data := makeIDRFrameWithHeaders()
+		buf := make([]byte, len(data), len(data)+room)
+		copy(buf, data)
+		frame := &types.VideoFrame{Data: buf}
+		p := NewProcessor()
+		if err := p.Process(frame); err != nil {
+			t.Fatal(err)
+		}
+
+		sei := TimestampSEI(time.Now(), "cam")
+		if !InsertNAL(frame, sei) {
+			t.Fatal("InsertNAL returned false")
+		}
+
+		// Bounds kept in sync match a fresh parse of the new buffer
+		want := append([]types.NALBound(nil), frame.NALUs...)
+		if err := p.Process(frame); err != nil {
+			t.Fatal(err)
+		}
+		if len(want) != len(frame.NALUs) {
+			t.Fatalf("room=%d: %d bounds, reparse found %d", room, len(want), len(frame.NALUs))
+		}
+		for i := range want {
+			if want[i] != frame.NALUs[i] {
+				t.Fatalf("room=%d bound %d: %+v, reparse %+v", room, i, want[i], frame.NALUs[i])
+			}
+		}
+		order := []uint8{types.NALTypeH265VPS, types.NALTypeH265SPS, types.NALTypeH265PPS, types.NALTypeH265SEI, types.NALTypeH265IDRWRADL}
+		for i, nt := range order {
+			if frame.NALUs[i].Type != nt {
+				t.Fatalf("room=%d NAL %d type %d, want %d", room, i, frame.NALUs[i].Type, nt)
+			}
+		}
+		if !frame.IsIDR {
+			t.Fatal("IDR lost")
+		}
+	}
+
+	if InsertNAL(&types.VideoFrame{}, TimestampSEI(time.Now(), "")) {
+		t.Fatal("inserted into a frame without VCL")
+	}
+}
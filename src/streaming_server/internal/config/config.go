@@ -0,0 +1,221 @@
+// Package config binds the server configurations to command-line flags and
+// environment variables, so the standalone binaries and every petcam
+// subcommand accept the same options.
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/streamserver"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/webmonitor"
+)
+
+// Log holds the logging flags shared by every command.
+type Log struct {
+	Level string
+	Color bool
+}
+
+// BindLog registers -log-level and -log-color.
+func BindLog(fs *flag.FlagSet) *Log {
+	l := &Log{}
+	fs.StringVar(&l.Level, "log-level", "info", "Log level (debug, info, warn, error, silent)")
+	fs.BoolVar(&l.Color, "log-color", true, "Enable colored log output")
+	return l
+}
+
+// BindVersion registers -version. When it is set after parsing, the command
+// prints buildinfo.String() and exits instead of running.
+func BindVersion(fs *flag.FlagSet) *bool {
+	return fs.Bool("version", false, "Print the build version and exit")
+}
+
+// Init initializes the global logger.
+func (l *Log) Init() error {
+	level, err := logger.ParseLevel(l.Level)
+	if err != nil {
+		return err
+	}
+	logger.Init(level, os.Stderr, l.Color)
+	logger.Info("Main", "Log level: %s", level)
+	return nil
+}
+
+// fileMode is an octal permission flag ("0660").
+type fileMode struct{ mode *os.FileMode }
+
+func (f fileMode) String() string {
+	if f.mode == nil {
+		return ""
+	}
+	return fmt.Sprintf("%#o", uint32(*f.mode))
+}
+
+func (f fileMode) Set(s string) error {
+	v, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || v > 0o777 {
+		return fmt.Errorf("want an octal mode such as 0660")
+	}
+	*f.mode = os.FileMode(v)
+	return nil
+}
+
+// BindStreaming registers the streaming server flags.
+func BindStreaming(fs *flag.FlagSet, cfg *streamserver.Config) {
+	fs.StringVar(&cfg.ShmName, "shm", cfg.ShmName, "H.265 zero-copy shared memory name")
+	fs.StringVar(&cfg.HTTPAddr, "http", cfg.HTTPAddr, "HTTP server address")
+	fs.StringVar(&cfg.MetricsAddr, "metrics", cfg.MetricsAddr, "Metrics server address")
+	fs.StringVar(&cfg.PprofAddr, "pprof", cfg.PprofAddr, "pprof server address")
+	fs.StringVar(&cfg.HTTPSocket, "http-socket", cfg.HTTPSocket, "Unix socket that also serves the HTTP API (with -http \"\" the only listener)")
+	fs.StringVar(&cfg.MetricsSocket, "metrics-socket", cfg.MetricsSocket, "Unix socket that also serves /metrics (with -metrics \"\" the only listener)")
+	fs.Var(fileMode{&cfg.SocketMode}, "socket-mode", "Permissions of -http-socket and -metrics-socket (octal)")
+	fs.StringVar(&cfg.RecordPath, "record-path", cfg.RecordPath, "Recording output path")
+	fs.IntVar(&cfg.MaxClients, "max-clients", cfg.MaxClients, "Maximum WebRTC clients")
+	fs.StringVar(&cfg.ICEFamilies, "ice-families", cfg.ICEFamilies, "Address families offered as WebRTC host candidates, preferred first (ipv4,ipv6 / ipv6,ipv4 / ipv4 / ipv6)")
+	fs.DurationVar(&cfg.EncoderIdleHoldOff, "encoder-idle-holdoff", cfg.EncoderIdleHoldOff, "Let the camera pause H.265 encoding after no viewers/recording for this long (0 = never pause)")
+	fs.Float64Var(&cfg.DegradeCPUHigh, "degrade-cpu-high", cfg.DegradeCPUHigh, "CPU percent that steps up degradation (0 = disable degradation)")
+	fs.Float64Var(&cfg.DegradeCPULow, "degrade-cpu-low", cfg.DegradeCPULow, "CPU percent that steps degradation back down")
+	fs.StringVar(&cfg.Timezone, "timezone", cfg.Timezone, "Time zone for recording file names (IANA name, +09:00, or Local)")
+	fs.BoolVar(&cfg.SEITimestamp, "sei-timestamp", cfg.SEITimestamp, "Insert a capture-time SEI (user data unregistered) into every H.265 frame")
+	fs.BoolVar(&cfg.SEIFrameNumber, "sei-frame-number", cfg.SEIFrameNumber, "Insert an SEI carrying the capture frame number (as in detection events) into every H.265 frame")
+	fs.StringVar(&cfg.CameraName, "camera-name", cfg.CameraName, "Camera name carried in the timestamp SEI and the metrics camera label (default: hostname)")
+	fs.IntVar(&cfg.Pipeline.WebRTCQueue, "webrtc-queue", cfg.Pipeline.WebRTCQueue, "Frames queued for the WebRTC sender (1 = always newest)")
+	fs.IntVar(&cfg.Pipeline.WebRTCPacing, "webrtc-pacing", cfg.Pipeline.WebRTCPacing, "Release WebRTC frames at their capture cadence behind a jitter buffer of this many frames (0 = send as they arrive; needs -webrtc-queue above it)")
+	fs.IntVar(&cfg.Pipeline.RecorderQueue, "recorder-queue", cfg.Pipeline.RecorderQueue, "Frames queued for the recorder distributor")
+	fs.IntVar(&cfg.Pipeline.RecorderWriterQueue, "recorder-writer-queue", cfg.Pipeline.RecorderWriterQueue, "Frames queued for the recording file writer")
+	fs.StringVar(&cfg.Pipeline.SpoolDir, "recorder-spool-dir", cfg.Pipeline.SpoolDir, "Directory for the temporary file frames overflow into while the recording writer stalls; keep it off the recordings' disk (default: the temporary directory, a tmpfs on the board)")
+	fs.IntVar(&cfg.Pipeline.SpoolMaxMB, "recorder-spool-mb", cfg.Pipeline.SpoolMaxMB, "Size limit of the recorder overflow spool in MiB, capped at half the free space of -recorder-spool-dir (0 = drop frames when the writer queue is full)")
+	fs.IntVar(&cfg.Pipeline.StartupMB, "recorder-startup-mb", cfg.Pipeline.StartupMB, "Keep up to this many MiB of the GOP in progress while not recording, so a recording starts at its IDR instead of the next one (0 = off)")
+	fs.BoolVar(&cfg.LowLatency, "low-latency", cfg.LowLatency, "Packetize and send WebRTC frames on the reader goroutine (no WebRTC queue; recorder stays decoupled)")
+	fs.BoolVar(&cfg.AccessLog, "access-log", cfg.AccessLog, "Log every HTTP request (method, path, status, bytes, duration, remote, token id)")
+	fs.BoolVar(&cfg.Compress, "http-compress", cfg.Compress, "Compress JSON and SSE responses (gzip/deflate) for clients sending Accept-Encoding")
+	fs.StringVar(&cfg.CrashDir, "crash-dir", cfg.CrashDir, "Directory for crash reports of recovered panics (empty = log only)")
+	fs.DurationVar(&cfg.DrainReconnect, "drain-reconnect-after", cfg.DrainReconnect, "Default delay viewers wait before reconnecting after POST /admin/drain")
+	fs.DurationVar(&cfg.DrainGrace, "drain-grace", cfg.DrainGrace, "Default time between POST /admin/drain and exit")
+	fs.StringVar(&cfg.Transcode, "transcode", cfg.Transcode, "Re-encode to vp9 or av1 for browsers whose offer lacks H.265 (empty = disabled; a software encode costs a lot of CPU)")
+	fs.StringVar(&cfg.TranscodeCommand, "transcode-cmd", cfg.TranscodeCommand, "Encoder command for -transcode, run with ffmpeg arguments (H.265 on stdin, IVF on stdout)")
+	fs.StringVar(&cfg.E2EProfiles, "e2e-profiles", cfg.E2EProfiles, "Client profiles JSON file for end-to-end (SFrame) frame encryption on relayed streams (empty = disabled)")
+	fs.StringVar(&cfg.DeviceKey, "device-key", cfg.DeviceKey, "Ed25519 device key file signing each recording's hash chain manifest, created if missing (empty = no watermark)")
+	fs.IntVar(&cfg.TimingSampleEvery, "timing-sample-every", cfg.TimingSampleEvery, "Stream a frame latency sample every N frames on /api/webrtc/timing (0 = disable)")
+}
+
+// BindMonitor registers the web monitor flags.
+func BindMonitor(fs *flag.FlagSet, cfg *webmonitor.Config) {
+	fs.StringVar(&cfg.Addr, "http", cfg.Addr, "HTTP server address")
+	fs.StringVar(&cfg.HTTPOnlyAddr, "http-only", cfg.HTTPOnlyAddr, "HTTP-only server address for MJPEG stream (e.g., :8082)")
+	fs.StringVar(&cfg.Socket, "socket", cfg.Socket, "Unix socket that also serves the API, plain HTTP (with -http \"\" the only listener)")
+	fs.Var(fileMode{&cfg.SocketMode}, "socket-mode", "Permissions of -socket (octal)")
+	fs.StringVar(&cfg.AssetsDir, "assets", cfg.AssetsDir, "Web assets directory")
+	fs.StringVar(&cfg.BuildAssetsDir, "assets-build", cfg.BuildAssetsDir, "Build assets directory")
+	fs.StringVar(&cfg.FrameShmName, "frame-shm", cfg.FrameShmName, "Frame shared memory name")
+	fs.StringVar(&cfg.ProxyShmName, "proxy-shm", cfg.ProxyShmName, "H.265 shared memory of the low-res substream, recorded as a proxy next to each recording when present (empty = none)")
+	fs.StringVar(&cfg.DetectionShmName, "detection-shm", cfg.DetectionShmName, "Detection shared memory name")
+	fs.StringVar(&cfg.DetectionSource, "detection-source", cfg.DetectionSource, "Detection input: shm, ndjson (Unix socket) or grpc (DetectionService stream)")
+	fs.StringVar(&cfg.DetectionAddr, "detection-addr", cfg.DetectionAddr, "Socket path (ndjson) or host:port (grpc) for -detection-source")
+	fs.Var(&cfg.DetectorInput, "detector-input", "Image the detector's boxes are measured in, WxH or WxH@x,y,w,h with the video's rectangle in it (e.g. 640x640@0,140,640,360 for letterboxing); boxes are mapped to 1280x720. Detectors reporting frame.detector override it")
+	fs.BoolVar(&cfg.DetectionBBoxNorm, "detection-bbox-norm", cfg.DetectionBBoxNorm, "Add bbox_norm (fractions 0-1 of the video) to every detection in events and the status API")
+	fs.IntVar(&cfg.DetectionHistoryDepth, "detection-history-depth", cfg.DetectionHistoryDepth, "Recent detection results kept in /api/status")
+	fs.DurationVar(&cfg.DetectionHistoryRetention, "detection-history-retention", cfg.DetectionHistoryRetention, "How long /api/detections/history keeps detection summaries")
+	fs.BoolVar(&cfg.AccessLog, "access-log", cfg.AccessLog, "Log every HTTP request (method, path, status, bytes, duration, remote, token id)")
+	fs.BoolVar(&cfg.Compress, "http-compress", cfg.Compress, "Compress JSON and SSE responses (gzip/deflate) for clients sending Accept-Encoding")
+	fs.StringVar(&cfg.CrashDir, "crash-dir", cfg.CrashDir, "Directory for crash reports of recovered panics (empty = log only)")
+	fs.IntVar(&cfg.LogBufferLines, "log-buffer", cfg.LogBufferLines, "Recent log lines kept per level for /api/logs")
+	fs.StringVar(&cfg.WebRTCBaseURL, "webrtc-base", cfg.WebRTCBaseURL, "WebRTC Go server base URL")
+	fs.IntVar(&cfg.TargetFPS, "fps", cfg.TargetFPS, "Target FPS for stats")
+	fs.IntVar(&cfg.JPEGQuality, "jpeg-quality", cfg.JPEGQuality, "JPEG encoding quality 1-100 (lower = smaller bandwidth)")
+	fs.StringVar(&cfg.TLSCertFile, "tls-cert", cfg.TLSCertFile, "TLS certificate file (enables HTTPS)")
+	fs.StringVar(&cfg.TLSKeyFile, "tls-key", cfg.TLSKeyFile, "TLS private key file")
+	fs.StringVar(&cfg.RecordingFallback, "recording-fallback", cfg.RecordingFallback, "Comma-separated directories to record to, in order, while the recording path (RECORDING_PATH) fails its health check, e.g. a USB drive falling back to internal storage; only the last is created")
+	fs.DurationVar(&cfg.RecordingPathCheck, "recording-path-check", cfg.RecordingPathCheck, "How often the recording path and -recording-fallback directories are probed")
+	fs.StringVar(&cfg.RecordingStorage, "recording-storage", cfg.RecordingStorage, "Where finished clips are stored: directory (NFS/SMB mount) or s3://bucket/prefix?region=&endpoint= (default: recording path)")
+	fs.StringVar(&cfg.SettingsPath, "settings-db", cfg.SettingsPath, "Settings store of rules, masks, classes, tokens, peers, pets, push subscriptions, PTZ presets and the dashboard layout: BoltDB file, or mem: to keep them in memory")
+	fs.StringVar(&cfg.RulesPath, "rules", cfg.RulesPath, "Notification rules JSON file of older versions, imported into -settings-db on first start")
+	fs.StringVar(&cfg.DetectionClassesPath, "detection-classes", cfg.DetectionClassesPath, "Detection class allowlist JSON file of older versions, imported into -settings-db on first start")
+	fs.StringVar(&cfg.MasksPath, "masks", cfg.MasksPath, "Detection ignore-mask JSON file of older versions, imported into -settings-db on first start")
+	fs.StringVar(&cfg.Locale, "locale", cfg.Locale, "Default locale of alerts, notifications, event labels and dashboard labels (en, ja); a token or ?locale= overrides it")
+	fs.StringVar(&cfg.UIConfigPath, "ui-config", cfg.UIConfigPath, "Dashboard layout JSON file of older versions, imported into -settings-db on first start")
+	fs.Float64Var(&cfg.DayMinConfidence, "day-min-confidence", cfg.DayMinConfidence, "Drop detections below this confidence while the day camera is active (default profile; 0 keeps all)")
+	fs.Float64Var(&cfg.NightMinConfidence, "night-min-confidence", cfg.NightMinConfidence, "Drop detections below this confidence while the IR night camera is active (default profile)")
+	fs.StringVar(&cfg.TimeseriesPath, "timeseries", cfg.TimeseriesPath, "Metric history file for /api/timeseries (fps, CPU, bitrate, jitter; empty keeps history in memory only)")
+	fs.StringVar(&cfg.StatusHistoryPath, "status-history", cfg.StatusHistoryPath, "BoltDB file of the per-minute status snapshots served by /api/status/history (empty keeps them in memory only)")
+	fs.DurationVar(&cfg.StatusHistoryRetention, "status-history-retention", cfg.StatusHistoryRetention, "How long /api/status/history keeps status snapshots (0 disables)")
+	fs.StringVar(&cfg.AuditLogPath, "audit-log", cfg.AuditLogPath, "Append events, alerts, detector health and push notifications as JSON lines to this file (empty disables)")
+	fs.StringVar(&cfg.PeersPath, "peers", cfg.PeersPath, "Federated camera peers JSON file of older versions, imported into -settings-db on first start")
+	fs.StringVar(&cfg.PushKeyPath, "push-key", cfg.PushKeyPath, "Web Push VAPID private key (PEM, created if missing; empty disables push)")
+	fs.StringVar(&cfg.PushSubject, "push-subject", cfg.PushSubject, "Web Push VAPID contact (mailto: or https: URI)")
+	fs.StringVar(&cfg.OutboxPath, "outbox", cfg.OutboxPath, "Directory of the disk-backed queue retrying push notifications and uploads across outages (empty = send once)")
+	fs.IntVar(&cfg.MJPEGClientBuffer, "mjpeg-client-buffer", cfg.MJPEGClientBuffer, "Frames queued per MJPEG viewer before frames are dropped")
+	fs.IntVar(&cfg.MJPEGMaxPerIP, "mjpeg-max-per-ip", cfg.MJPEGMaxPerIP, "Concurrent MJPEG streams allowed per client IP (0 = unlimited)")
+	fs.Float64Var(&cfg.MJPEGMaxKbps, "mjpeg-max-kbps", cfg.MJPEGMaxKbps, "Total MJPEG bandwidth cap in kbit/s; frames are skipped above it (0 = unlimited)")
+	fs.DurationVar(&cfg.MJPEGEncodeBudget, "mjpeg-encode-budget", cfg.MJPEGEncodeBudget, "Mean JPEG encode time per frame above which MJPEG quality, then resolution, is lowered (0 = fixed quality)")
+	fs.IntVar(&cfg.MJPEGMinQuality, "mjpeg-min-quality", cfg.MJPEGMinQuality, "Lowest adaptive JPEG quality before MJPEG resolution is halved")
+	fs.IntVar(&cfg.SSEClientBuffer, "sse-client-buffer", cfg.SSEClientBuffer, "Events queued per detection/status SSE client before events are dropped")
+	fs.StringVar(&cfg.ICEServers, "stun", cfg.ICEServers, "Comma-separated ICE servers for browsers (stun:, stuns:, turn:, turns: URLs)")
+	fs.StringVar(&cfg.TURNUsername, "turn-username", cfg.TURNUsername, "Username for turn:/turns: servers in -stun (credential from PET_CAMERA_TURN_CREDENTIAL)")
+	fs.StringVar(&cfg.TURNSecretFile, "turn-secret-file", cfg.TURNSecretFile, "Secret shared with the TURN server for time-limited REST TURN credentials (created if missing, re-read on SIGHUP; replaces PET_CAMERA_TURN_CREDENTIAL)")
+	fs.DurationVar(&cfg.TURNCredentialTTL, "turn-ttl", cfg.TURNCredentialTTL, "Lifetime of TURN credentials issued with -turn-secret-file")
+	fs.StringVar(&cfg.RelayURL, "relay-url", cfg.RelayURL, "Remote access broker endpoint (wss://host/relay/connect, empty disables)")
+	fs.StringVar(&cfg.RelayCameraID, "relay-id", cfg.RelayCameraID, "Camera ID registered with the relay broker (default: hostname)")
+	fs.Var(&cfg.Triggers, "triggers", "Named external triggers that record when fired, name[=duration] comma-separated (e.g. cat_flap=45s,doorbell; default 30s)")
+	fs.StringVar(&cfg.MQTTURL, "mqtt-url", cfg.MQTTURL, "MQTT broker whose messages fire -triggers (mqtt://[user@]host[:port] or mqtts://; password from PET_CAMERA_MQTT_PASSWORD; empty disables)")
+	fs.StringVar(&cfg.MQTTTopic, "mqtt-topic", cfg.MQTTTopic, "MQTT topic prefix; a message on <prefix>/<trigger> fires the trigger")
+	fs.StringVar(&cfg.DetectorProxyURL, "detector-proxy", cfg.DetectorProxyURL, "Serve the detection daemon's debug UI at /detector/ from this upstream (token from PET_CAMERA_DETECTOR_PROXY_TOKEN, empty disables)")
+	fs.BoolVar(&cfg.MotionFallback, "motion-fallback", cfg.MotionFallback, "Emit frame-differencing motion events while the detection daemon is down")
+	fs.IntVar(&cfg.MotionSensitivity, "motion-sensitivity", cfg.MotionSensitivity, "Motion fallback per-cell luma delta threshold (0-255)")
+	fs.Float64Var(&cfg.MotionMinArea, "motion-min-area", cfg.MotionMinArea, "Motion fallback minimum changed area fraction (0-1)")
+	fs.StringVar(&cfg.ForwardURL, "forward-url", cfg.ForwardURL, "Send downscaled frames to this inference service (http(s)://... or grpc://host:port) and ingest its detections")
+	fs.DurationVar(&cfg.ForwardInterval, "forward-interval", cfg.ForwardInterval, "Minimum gap between frames sent to -forward-url")
+	fs.IntVar(&cfg.ForwardMaxWidth, "forward-max-width", cfg.ForwardMaxWidth, "Downscale forwarded frames to at most this width")
+	fs.StringVar(&cfg.PetEmbedURL, "pet-embed-url", cfg.PetEmbedURL, "Embedding service for telling enrolled pets apart (POST image/jpeg → {\"embedding\": [...]})")
+	fs.StringVar(&cfg.PetProfilesPath, "pet-profiles", cfg.PetProfilesPath, "Enrolled pets JSON file of older versions, imported into -settings-db on first start")
+	fs.Float64Var(&cfg.PetMatchThreshold, "pet-match-threshold", cfg.PetMatchThreshold, "Cosine similarity required to identify an enrolled pet (0-1)")
+	fs.Float64Var(&cfg.SoundThresholdDB, "sound-threshold", cfg.SoundThresholdDB, "Audio RMS level in dBFS that emits sound_detected events")
+	fs.DurationVar(&cfg.DetectionStaleAfter, "detection-stale-after", cfg.DetectionStaleAfter, "Mark the detection daemon unhealthy after no new results for this long")
+	fs.DurationVar(&cfg.DetectionAlertAfter, "detection-alert-after", cfg.DetectionAlertAfter, "Raise a detection daemon alert after no new results for this long")
+	fs.DurationVar(&cfg.EncoderIdleHoldOff, "encoder-idle-holdoff", cfg.EncoderIdleHoldOff, "Let the camera pause H.265 encoding after no recording for this long (0 = never pause)")
+	fs.StringVar(&cfg.Timezone, "timezone", cfg.Timezone, "Time zone for the overlay clock and file names (IANA name, +09:00, or Local)")
+	fs.BoolVar(&cfg.SEITimestamp, "sei-timestamp", cfg.SEITimestamp, "Insert a capture-time SEI (user data unregistered) into every recorded H.265 frame")
+	fs.StringVar(&cfg.CameraName, "camera-name", cfg.CameraName, "Camera name carried in the timestamp SEI and the metrics camera label (default: hostname)")
+	fs.DurationVar(&cfg.ScrubInterval, "scrub-interval", cfg.ScrubInterval, "Re-verify finished recordings (container, index, checksum) this often (0 = disable)")
+	fs.Var(&cfg.RecordStopTrim, "record-stop-trim", "On stop, cut at once and record the cut point (cut) or write through the end of the current GOP (gop)")
+	fs.DurationVar(&cfg.RecordGOPWait, "record-gop-wait", cfg.RecordGOPWait, "Wait for the next IDR with -record-stop-trim gop, longer if the recording's GOPs are (1.5 GOPs, at most 10s)")
+	fs.StringVar(&cfg.StatePath, "state", cfg.StatePath, "JSON file for monitor state saved across restarts (empty disables)")
+	fs.BoolVar(&cfg.ResumeRecording, "resume-recording", cfg.ResumeRecording, "Resume a recording interrupted by a restart")
+	fs.Float64Var(&cfg.DegradeCPUHigh, "degrade-cpu-high", cfg.DegradeCPUHigh, "CPU percent that steps up degradation: lower MJPEG fps, then pause comic capture (0 = disable)")
+	fs.Float64Var(&cfg.DegradeCPULow, "degrade-cpu-low", cfg.DegradeCPULow, "CPU percent that steps degradation back down")
+	fs.DurationVar(&cfg.PowerSaveIdleAfter, "power-save-idle", cfg.PowerSaveIdleAfter, "Drop detector/MJPEG frames to 5 fps after no viewer, recording or rule schedule for this long (0 = disable power saving)")
+	fs.DurationVar(&cfg.PowerSaveSuspendAfter, "power-save-suspend", cfg.PowerSaveSuspendAfter, "Suspend the detector after idle this long; motion (fallback) wakes it (0 = never suspend)")
+	fs.StringVar(&cfg.PowerSaveHours, "power-save-hours", cfg.PowerSaveHours, "Only save power inside this daily window, e.g. 23:00-06:00 (empty = any time)")
+	fs.StringVar(&cfg.PTZBackend, "ptz", cfg.PTZBackend, "Pan-tilt mount: pwm:/sys/class/pwm/pwmchip0?pan=0&tilt=1 (servos) or pelco:/dev/ttyUSB0?baud=9600&addr=1 (Pelco-D)")
+	fs.StringVar(&cfg.PTZPresetsPath, "ptz-presets", cfg.PTZPresetsPath, "PTZ presets JSON file of older versions, imported into -settings-db on first start")
+	fs.BoolVar(&cfg.PTZTrack, "ptz-track", cfg.PTZTrack, "Start with PTZ auto-tracking of the most confident pet on")
+	fs.StringVar(&cfg.ShareKeyFile, "share-key-file", cfg.ShareKeyFile, "HMAC key signing the expiring /share/ links to clips and snapshots (created if missing; replace it to revoke all links; empty = no sharing)")
+	fs.DurationVar(&cfg.ShareMaxTTL, "share-max-ttl", cfg.ShareMaxTTL, "Longest validity of a shared link")
+	fs.StringVar(&cfg.TokensPath, "tokens-file", cfg.TokensPath, "Access tokens JSON file of older versions, imported into -settings-db on first start")
+	fs.Float64Var(&cfg.FollowZoom, "follow-zoom", cfg.FollowZoom, "Digital zoom of the pet-following /stream?profile=follow crop (<= 1 disables the crop)")
+}
+
+// ApplyMonitorEnv applies the environment overrides the systemd units rely on.
+// Call after flag parsing.
+func ApplyMonitorEnv(cfg *webmonitor.Config) {
+	// Override recording path from env (matches systemd RECORDING_PATH)
+	if v := os.Getenv("RECORDING_PATH"); v != "" {
+		cfg.RecordingOutputPath = v
+	}
+
+	// Tokens and TURN credential from env only (keeps them out of the process list)
+	cfg.RelayToken = os.Getenv("PET_CAMERA_RELAY_TOKEN")
+	cfg.TURNCredential = os.Getenv("PET_CAMERA_TURN_CREDENTIAL")
+	cfg.DetectorProxyToken = os.Getenv("PET_CAMERA_DETECTOR_PROXY_TOKEN")
+	cfg.AdminToken = os.Getenv("PET_CAMERA_ADMIN_TOKEN")
+	cfg.MQTTPassword = os.Getenv("PET_CAMERA_MQTT_PASSWORD")
+
+	// Override detect port from env if not set via flag
+	if v := os.Getenv("PET_CAMERA_DETECT_PORT"); v != "" {
+		cfg.DetectPort = v
+	}
+}
@@ -0,0 +1,149 @@
+// Package crash contains panics: HTTP middleware and helpers for pipeline
+// goroutines recover them, log the stack trace, count them in
+// panics_recovered_total and write a crash report, so one bad request or
+// frame does not take down every viewer and the active recording.
+package crash
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MaxReports is how many crash reports are kept; older ones are deleted.
+const MaxReports = 20
+
+var (
+	mu  sync.Mutex
+	dir string // "" = no report files
+
+	panics = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "panics_recovered_total",
+			Help: "Panics recovered in HTTP handlers and pipeline goroutines, by site",
+		},
+		[]string{"where"},
+	)
+)
+
+// SetDir sets the directory crash reports are written to ("" disables them).
+func SetDir(d string) {
+	mu.Lock()
+	defer mu.Unlock()
+	dir = d
+}
+
+// Collector returns the panic counter for a metrics registry.
+func Collector() prometheus.Collector {
+	return panics
+}
+
+// Do runs fn and reports whether it panicked. The panic is recovered and
+// reported under where; use it around one unit of work (a frame, a
+// detection result) so the surrounding loop keeps going.
+func Do(where string, fn func()) (panicked bool) {
+	defer func() {
+		if v := recover(); v != nil {
+			report(where, "", v, debug.Stack())
+			panicked = true
+		}
+	}()
+	fn()
+	return false
+}
+
+// Recover reports a panic in progress; call it as `defer crash.Recover(where)`.
+// The function it is deferred in returns its zero values.
+func Recover(where string) {
+	if v := recover(); v != nil {
+		report(where, "", v, debug.Stack())
+	}
+}
+
+// Middleware recovers handler panics, reports them under "http" and answers
+// 500 if nothing was written yet. http.ErrAbortHandler is re-raised: it is
+// how handlers deliberately abort a response.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			report("http", r.Method+" "+r.URL.Path, v, debug.Stack())
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func report(where, context string, v any, stack []byte) {
+	panics.WithLabelValues(where).Inc()
+	label := where
+	if context != "" {
+		label += " (" + context + ")"
+	}
+	logger.Error("Crash", "Recovered panic in %s: %v\n%s", label, v, stack)
+
+	mu.Lock()
+	d := dir
+	mu.Unlock()
+	if d == "" {
+		return
+	}
+	path, err := writeReport(d, time.Now(), label, v, stack)
+	if err != nil {
+		logger.Warn("Crash", "Failed to write crash report: %v", err)
+		return
+	}
+	logger.Info("Crash", "Crash report written to %s", path)
+}
+
+// writeReport writes crash-<time>-<where>.txt and prunes old reports.
+func writeReport(d string, at time.Time, label string, v any, stack []byte) (string, error) {
+	if err := os.MkdirAll(d, 0755); err != nil {
+		return "", err
+	}
+	site := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(strings.Fields(label)[0]))
+	name := fmt.Sprintf("crash-%s-%s.txt", at.Format("20060102-150405.000"), site)
+	path := filepath.Join(d, name)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "time: %s\n", at.Format(time.RFC3339Nano))
+	fmt.Fprintf(&b, "where: %s\n", label)
+	fmt.Fprintf(&b, "panic: %v\n", v)
+	fmt.Fprintf(&b, "go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		fmt.Fprintf(&b, "module: %s %s\n", bi.Main.Path, bi.Main.Version)
+	}
+	fmt.Fprintf(&b, "\n%s", stack)
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return "", err
+	}
+
+	if reports, _ := filepath.Glob(filepath.Join(d, "crash-*.txt")); len(reports) > MaxReports {
+		slices.Sort(reports) // names sort by time
+		for _, old := range reports[:len(reports)-MaxReports] {
+			os.Remove(old)
+		}
+	}
+	return path, nil
+}
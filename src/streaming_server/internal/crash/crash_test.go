@@ -0,0 +1,89 @@
+package crash
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func counterValue(t *testing.T, where string) float64 {
+	t.Helper()
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(Collector())
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			if m.GetLabel()[0].GetValue() == where {
+				return m.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
+
+func TestDoRecoversAndReports(t *testing.T) {
+	dir := t.TempDir()
+	SetDir(dir)
+	defer SetDir("")
+
+	before := counterValue(t, "test-loop")
+	var m map[string]int
+	if !Do("test-loop", func() { m["x"] = 1 }) {
+		t.Fatal("panic not reported")
+	}
+	if Do("test-loop", func() {}) {
+		t.Fatal("normal return reported as panic")
+	}
+	if got := counterValue(t, "test-loop"); got != before+1 {
+		t.Fatalf("counter %v, want %v", got, before+1)
+	}
+
+	reports, _ := filepath.Glob(filepath.Join(dir, "crash-*-test-loop.txt"))
+	if len(reports) != 1 {
+		t.Fatalf("reports %v", reports)
+	}
+	data, _ := os.ReadFile(reports[0])
+	if !strings.Contains(string(data), "assignment to entry in nil map") || !strings.Contains(string(data), "crash_test.go") {
+		t.Fatalf("report lacks panic value or stack:\n%s", data)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	h := Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic("boom") }))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/status", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status %d", w.Code)
+	}
+
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Fatalf("ErrAbortHandler not re-raised: %v", v)
+		}
+	}()
+	Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic(http.ErrAbortHandler) })).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/stream", nil))
+}
+
+func TestWriteReportPrunes(t *testing.T) {
+	dir := t.TempDir()
+	at := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	for i := range MaxReports + 3 {
+		if _, err := writeReport(dir, at.Add(time.Duration(i)*time.Second), "http (GET /)", "x", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reports, _ := filepath.Glob(filepath.Join(dir, "crash-*.txt"))
+	if len(reports) != MaxReports || !strings.Contains(reports[0], "120003") {
+		t.Fatalf("%d reports, oldest %s", len(reports), reports[0])
+	}
+}
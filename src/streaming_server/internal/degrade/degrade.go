@@ -0,0 +1,355 @@
+// Package degrade sheds optional work when the board runs out of CPU.
+//
+// The Controller samples system-wide CPU usage from /proc/stat (plus the
+// process goroutine count) and steps through degradation levels in a fixed
+// priority order. Each process applies the steps it owns: the web monitor
+// reduces MJPEG fps and pauses annotated comic capture, the streaming server
+// decimates WebRTC. Both read the same /proc/stat with the same thresholds,
+// so they converge on the same level without talking to each other.
+package degrade
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+// Level is a degradation step. Higher levels include all lower ones.
+type Level int
+
+const (
+	LevelNormal         Level = iota
+	LevelReduceMJPEG          // MJPEG overlay stream at reduced fps
+	LevelPauseAnnotated       // comic (annotated panel) capture paused
+	LevelDecimateWebRTC       // WebRTC sends a decodable subset of each GOP
+
+	MaxLevel = LevelDecimateWebRTC
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelNormal:
+		return "normal"
+	case LevelReduceMJPEG:
+		return "reduce_mjpeg"
+	case LevelPauseAnnotated:
+		return "pause_annotated"
+	case LevelDecimateWebRTC:
+		return "decimate_webrtc"
+	default:
+		return fmt.Sprintf("level_%d", int(l))
+	}
+}
+
+// Status is a point-in-time view of the controller.
+type Status struct {
+	Level      string  `json:"level"`
+	LevelNum   int     `json:"level_num"`
+	CPUPercent float64 `json:"cpu_percent"`
+	Goroutines int     `json:"goroutines"`
+	Since      float64 `json:"since"` // unix seconds of the last level change (0 = never)
+}
+
+// Controller raises the level one step after CPU has stayed at or above High
+// for UpAfter, and lowers it one step after CPU has stayed at or below Low for
+// DownAfter. Readings between Low and High hold the current level.
+type Controller struct {
+	// Configurable parameters
+	High          float64       // CPU percent considered overloaded
+	Low           float64       // CPU percent considered relaxed
+	UpAfter       time.Duration // sustained overload before each step up
+	DownAfter     time.Duration // sustained headroom before each step down
+	Interval      time.Duration // sampling interval
+	MaxGoroutines int           // goroutine budget; above it counts as overloaded (0 = unchecked)
+
+	statPath   string
+	goroutines func() int
+
+	mu        sync.Mutex
+	level     Level
+	changedAt time.Time
+	overSince time.Time
+	calmSince time.Time
+	cpu       float64
+	lastG     int
+	prev      cpuTimes
+	onChange  func(from, to Level, st Status)
+	onSample  func(st Status)
+	stop      chan struct{}
+	stopped   bool
+}
+
+// NewController creates a controller with default thresholds.
+func NewController() *Controller {
+	return &Controller{
+		High:          90,
+		Low:           70,
+		UpAfter:       10 * time.Second,
+		DownAfter:     30 * time.Second,
+		Interval:      2 * time.Second,
+		MaxGoroutines: 2000,
+		statPath:      "/proc/stat",
+		goroutines:    runtime.NumGoroutine,
+		stop:          make(chan struct{}),
+	}
+}
+
+// SetOnChange sets a callback fired after every level change.
+func (c *Controller) SetOnChange(callback func(from, to Level, st Status)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onChange = callback
+}
+
+// SetOnSample sets a callback fired after every CPU sample (e.g. to export gauges).
+func (c *Controller) SetOnSample(callback func(st Status)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onSample = callback
+}
+
+// Start begins sampling.
+func (c *Controller) Start() {
+	go c.run()
+}
+
+// Stop halts sampling. The level is left where it was.
+func (c *Controller) Stop() {
+	c.mu.Lock()
+	if !c.stopped {
+		close(c.stop)
+		c.stopped = true
+	}
+	c.mu.Unlock()
+}
+
+// Level returns the current degradation level.
+func (c *Controller) Level() Level {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.level
+}
+
+// Status returns the current level and the last readings.
+func (c *Controller) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.statusLocked()
+}
+
+func (c *Controller) statusLocked() Status {
+	st := Status{
+		Level:      c.level.String(),
+		LevelNum:   int(c.level),
+		CPUPercent: c.cpu,
+		Goroutines: c.lastG,
+	}
+	if !c.changedAt.IsZero() {
+		st.Since = float64(c.changedAt.UnixNano()) / 1e9
+	}
+	return st
+}
+
+func (c *Controller) run() {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case now := <-ticker.C:
+			t, err := readCPUTimes(c.statPath)
+			if err != nil {
+				logger.Debug("Degrade", "CPU sample failed: %v", err)
+				continue
+			}
+			c.mu.Lock()
+			prev := c.prev
+			c.prev = t
+			c.mu.Unlock()
+			if prev.total == 0 {
+				continue // first sample only sets the baseline
+			}
+			c.observe(t.usageSince(prev), c.goroutines(), now)
+		}
+	}
+}
+
+// observe applies one sample and fires onChange on a level transition.
+func (c *Controller) observe(cpu float64, goroutines int, now time.Time) {
+	c.mu.Lock()
+	c.cpu = cpu
+	c.lastG = goroutines
+	overBudget := c.MaxGoroutines > 0 && goroutines > c.MaxGoroutines
+
+	from := c.level
+	switch {
+	case cpu >= c.High || overBudget:
+		c.calmSince = time.Time{}
+		if c.overSince.IsZero() {
+			c.overSince = now
+		}
+		if c.level < MaxLevel && now.Sub(c.overSince) >= c.UpAfter {
+			c.level++
+			c.overSince = now // next step needs another full UpAfter
+		}
+	case cpu <= c.Low:
+		c.overSince = time.Time{}
+		if c.calmSince.IsZero() {
+			c.calmSince = now
+		}
+		if c.level > LevelNormal && now.Sub(c.calmSince) >= c.DownAfter {
+			c.level--
+			c.calmSince = now
+		}
+	default:
+		c.overSince = time.Time{}
+		c.calmSince = time.Time{}
+	}
+
+	to := c.level
+	if to != from {
+		c.changedAt = now
+	}
+	onSample, onChange := c.onSample, c.onChange
+	st := c.statusLocked()
+	c.mu.Unlock()
+
+	if onSample != nil {
+		onSample(st)
+	}
+	if to == from {
+		return
+	}
+
+	if to > from {
+		logger.Warn("Degrade", "CPU overloaded (%.0f%%, %d goroutines): %s -> %s", cpu, goroutines, from, to)
+	} else {
+		logger.Info("Degrade", "CPU recovered (%.0f%%): %s -> %s", cpu, from, to)
+	}
+	if onChange != nil {
+		onChange(from, to, st)
+	}
+}
+
+// CPUMeter samples system CPU usage from /proc/stat for callers that need
+// the reading without a Controller (which samples on its own).
+type CPUMeter struct {
+	statPath string
+	prev     cpuTimes
+}
+
+// NewCPUMeter creates a meter; its first Sample only sets the baseline.
+func NewCPUMeter() *CPUMeter {
+	return &CPUMeter{statPath: "/proc/stat"}
+}
+
+// Sample returns busy CPU percent since the previous call. ok is false on
+// the first call and when /proc/stat cannot be read.
+func (m *CPUMeter) Sample() (percent float64, ok bool) {
+	t, err := readCPUTimes(m.statPath)
+	if err != nil {
+		return 0, false
+	}
+	prev := m.prev
+	m.prev = t
+	if prev.total == 0 {
+		return 0, false
+	}
+	return t.usageSince(prev), true
+}
+
+// cpuTimes is the aggregate "cpu" line of /proc/stat, in jiffies.
+type cpuTimes struct {
+	total uint64
+	idle  uint64 // idle + iowait
+}
+
+// usageSince returns busy CPU percent between two samples.
+func (t cpuTimes) usageSince(prev cpuTimes) float64 {
+	total := t.total - prev.total
+	if total == 0 || t.total < prev.total {
+		return 0
+	}
+	idle := t.idle - prev.idle
+	if idle > total {
+		return 0
+	}
+	return float64(total-idle) * 100 / float64(total)
+}
+
+func readCPUTimes(path string) (cpuTimes, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return cpuTimes{}, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		return cpuTimes{}, fmt.Errorf("%s: empty", path)
+	}
+	return parseCPULine(scanner.Text())
+}
+
+// parseCPULine parses "cpu  user nice system idle iowait irq softirq steal ...".
+func parseCPULine(line string) (cpuTimes, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return cpuTimes{}, fmt.Errorf("unexpected stat line %q", line)
+	}
+	var t cpuTimes
+	// guest/guest_nice (fields 9, 10) are already included in user/nice
+	for i, f := range fields[1:] {
+		if i >= 8 {
+			break
+		}
+		v, err := strconv.ParseUint(f, 10, 64)
+		if err != nil {
+			return cpuTimes{}, fmt.Errorf("stat field %d: %w", i+1, err)
+		}
+		t.total += v
+		if i == 3 || i == 4 {
+			t.idle += v
+		}
+	}
+	return t, nil
+}
+
+// GOPDecimator keeps WebRTC decodable while cutting its frame rate. P-frames
+// reference the previous frame, so instead of dropping every other frame it
+// sends the IDR and the first Keep frames of each GOP and skips the rest.
+// Once a frame is skipped nothing more is sent until the next IDR, including
+// after decimation is switched off mid-GOP.
+type GOPDecimator struct {
+	Keep     int // frames sent per GOP while active, including the IDR
+	sinceIDR int
+	skipping bool
+}
+
+// Send reports whether a frame should be sent. It must see every frame, with
+// active telling whether decimation is currently wanted.
+func (d *GOPDecimator) Send(isIDR, active bool) bool {
+	if isIDR {
+		d.sinceIDR = 0
+		d.skipping = false
+	} else {
+		d.sinceIDR++
+	}
+	if d.skipping {
+		return false
+	}
+	if active && d.sinceIDR >= d.Keep {
+		d.skipping = true
+		return false
+	}
+	return true
+}
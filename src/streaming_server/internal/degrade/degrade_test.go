@@ -0,0 +1,116 @@
+package degrade
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseCPULine(t *testing.T) {
+	prev, err := parseCPULine("cpu  100 0 100 700 100 0 0 0 0 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cur, err := parseCPULine("cpu  250 0 150 750 150 0 0 0 50 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 300 jiffies elapsed, 100 of them idle/iowait; guest is not double counted
+	if got := cur.usageSince(prev); got < 66.6 || got > 66.7 {
+		t.Fatalf("usage = %.2f, want 66.67", got)
+	}
+	if _, err := parseCPULine("intr 1 2 3"); err == nil {
+		t.Fatal("non-cpu line accepted")
+	}
+}
+
+func TestCPUMeter(t *testing.T) {
+	m := &CPUMeter{statPath: filepath.Join(t.TempDir(), "stat")}
+	os.WriteFile(m.statPath, []byte("cpu  100 0 100 700 100 0 0 0 0 0\n"), 0644)
+	if _, ok := m.Sample(); ok {
+		t.Fatal("first sample reported usage")
+	}
+	os.WriteFile(m.statPath, []byte("cpu  200 0 200 750 150 0 0 0 0 0\n"), 0644)
+	if got, ok := m.Sample(); !ok || got < 66.6 || got > 66.7 {
+		t.Fatalf("usage = %.2f (%v), want 66.67", got, ok)
+	}
+}
+
+func TestControllerSteps(t *testing.T) {
+	c := NewController()
+	c.UpAfter = 10 * time.Second
+	c.DownAfter = 20 * time.Second
+	var changes []Level
+	c.SetOnChange(func(from, to Level, st Status) {
+		if st.Level != to.String() {
+			t.Errorf("status level %q, want %q", st.Level, to)
+		}
+		changes = append(changes, to)
+	})
+
+	t0 := time.Unix(1000, 0)
+	at := func(sec int) time.Time { return t0.Add(time.Duration(sec) * time.Second) }
+
+	steps := []struct {
+		sec  int
+		cpu  float64
+		want Level
+	}{
+		{0, 95, LevelNormal},
+		{8, 95, LevelNormal},
+		{10, 95, LevelReduceMJPEG}, // 10s sustained
+		{12, 80, LevelReduceMJPEG}, // in the band: hold, reset timers
+		{14, 95, LevelReduceMJPEG}, // overload timer restarts
+		{24, 95, LevelPauseAnnotated},
+		{34, 95, LevelDecimateWebRTC},
+		{60, 99, LevelDecimateWebRTC}, // capped
+		{61, 50, LevelDecimateWebRTC},
+		{81, 50, LevelPauseAnnotated}, // 20s of headroom
+		{101, 50, LevelReduceMJPEG},
+		{121, 50, LevelNormal},
+		{200, 50, LevelNormal},
+	}
+	for _, s := range steps {
+		c.observe(s.cpu, 10, at(s.sec))
+		if got := c.Level(); got != s.want {
+			t.Fatalf("t=%ds cpu=%.0f: level %s, want %s", s.sec, s.cpu, got, s.want)
+		}
+	}
+	if len(changes) != 6 {
+		t.Fatalf("changes = %v", changes)
+	}
+
+	// Goroutine budget counts as overload even with idle CPU
+	g := NewController()
+	g.MaxGoroutines = 100
+	g.observe(10, 500, at(0))
+	g.observe(10, 500, at(10))
+	if g.Level() != LevelReduceMJPEG {
+		t.Fatalf("goroutine budget: level %s", g.Level())
+	}
+}
+
+func TestGOPDecimator(t *testing.T) {
+	d := GOPDecimator{Keep: 3}
+	// I P P P P | I P P P with decimation switched on at the second frame and
+	// off again at the fifth: the GOP stays cut until the next IDR.
+	frames := []struct {
+		idr, active, want bool
+	}{
+		{true, false, true},
+		{false, true, true},
+		{false, true, true},
+		{false, true, false},
+		{false, false, false},
+		{true, false, true},
+		{false, false, true},
+		{false, false, true},
+		{false, false, true},
+	}
+	for i, f := range frames {
+		if got := d.Send(f.idr, f.active); got != f.want {
+			t.Fatalf("frame %d: send=%v, want %v", i, got, f.want)
+		}
+	}
+}
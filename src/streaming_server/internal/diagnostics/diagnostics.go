@@ -0,0 +1,196 @@
+// Package diagnostics checks the host environment the camera stack depends
+// on (shared memory segments, listening ports, writable recording storage)
+// and turns each failure into an actionable hint.
+package diagnostics
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Status of a single check.
+const (
+	StatusOK   = "ok"
+	StatusWarn = "warn"
+	StatusFail = "fail"
+)
+
+// Result is the outcome of one check.
+type Result struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+	Hint   string `json:"hint,omitempty"` // what to do about a warn/fail
+}
+
+// Port is a TCP service the stack is expected to listen on.
+type Port struct {
+	Name  string // e.g. "web monitor"
+	Addr  string // e.g. "localhost:8080"
+	Probe string // HTTP path that must answer 2xx, to tell our service from a port conflict ("" = connect only)
+	TLS   bool   // probe over HTTPS (certificate not verified)
+}
+
+// Options selects what Run checks.
+type Options struct {
+	ShmDir        string           // where POSIX shm segments live (default /dev/shm)
+	ShmNames      []string         // segment names as passed to shm_open, e.g. "/pet_camera_h265_zc"
+	ShmSizes      map[string]int64 // expected segment sizes (shm.SegmentSizes); missing = unchecked
+	Ports         []Port
+	RecordingsDir string // "" skips the check
+}
+
+// DefaultShmNames are the segments created by the capture and detector daemons.
+var DefaultShmNames = []string{
+	"/pet_camera_h265_zc",
+	"/pet_camera_mjpeg_zc",
+	"/pet_camera_yolo_zc",
+	"/pet_camera_detections",
+	"/pet_camera_control",
+}
+
+// Run executes every configured check in order.
+func Run(opts Options) []Result {
+	dir := opts.ShmDir
+	if dir == "" {
+		dir = "/dev/shm"
+	}
+	var results []Result
+	results = append(results, checkShmDir(dir))
+	for _, name := range opts.ShmNames {
+		results = append(results, checkSHM(dir, name, opts.ShmSizes[name]))
+	}
+	for _, p := range opts.Ports {
+		results = append(results, checkPort(p))
+	}
+	if opts.RecordingsDir != "" {
+		results = append(results, checkWritable(opts.RecordingsDir))
+	}
+	return results
+}
+
+// Failed reports whether any result is a failure.
+func Failed(results []Result) bool {
+	for _, r := range results {
+		if r.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+func checkShmDir(dir string) Result {
+	r := Result{Name: "shm dir"}
+	fi, err := os.Stat(dir)
+	if err != nil {
+		r.Status, r.Detail = StatusFail, err.Error()
+		r.Hint = "mount tmpfs on " + dir + " (mount -t tmpfs tmpfs " + dir + ")"
+		return r
+	}
+	if !fi.IsDir() {
+		r.Status, r.Detail = StatusFail, dir+" is not a directory"
+		return r
+	}
+	probe, err := os.CreateTemp(dir, ".petcam-doctor-*")
+	if err != nil {
+		r.Status, r.Detail = StatusFail, err.Error()
+		r.Hint = "run the stack as a user that can write " + dir + " (expected mode 1777)"
+		return r
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	r.Status, r.Detail = StatusOK, fmt.Sprintf("%s writable (mode %v)", dir, fi.Mode().Perm()|fi.Mode()&os.ModeSticky)
+	return r
+}
+
+// checkSHM verifies a segment exists, is accessible and has the size of its
+// C struct. The semaphores live inside the segments, so a truncated or
+// foreign-layout segment means sem_wait/sem_post operate on garbage.
+func checkSHM(dir, name string, want int64) Result {
+	r := Result{Name: "shm " + name}
+	path := filepath.Join(dir, strings.TrimPrefix(name, "/"))
+	fi, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		r.Status, r.Detail = StatusWarn, "not created"
+		r.Hint = "start the daemon that owns it (pet-camera-capture / pet-camera-detector)"
+		return r
+	}
+	if err != nil {
+		r.Status, r.Detail = StatusFail, err.Error()
+		return r
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		r.Status, r.Detail = StatusFail, err.Error()
+		r.Hint = "segment was created by another user; remove it (rm " + path + ") and restart the capture daemon"
+		return r
+	}
+	f.Close()
+	switch {
+	case fi.Size() == 0:
+		r.Status, r.Detail = StatusFail, "created but never sized; semaphores not initialized"
+		r.Hint = "the creating daemon crashed during setup; remove " + path + " and restart it"
+	case want > 0 && fi.Size() != want:
+		r.Status, r.Detail = StatusFail, fmt.Sprintf("size %d bytes, expected %d; semaphore layout mismatch", fi.Size(), want)
+		r.Hint = "segment is left over from a different build; stop the stack, remove " + path + " and rebuild/restart all components"
+	default:
+		r.Status, r.Detail = StatusOK, fmt.Sprintf("%d bytes", fi.Size())
+	}
+	return r
+}
+
+func checkPort(p Port) Result {
+	r := Result{Name: "port " + p.Name}
+	conn, err := net.DialTimeout("tcp", p.Addr, time.Second)
+	if err != nil {
+		r.Status, r.Detail = StatusWarn, "nothing listening on "+p.Addr
+		r.Hint = "start the " + p.Name + " or check its logs (journalctl -u pet-camera-*)"
+		return r
+	}
+	conn.Close()
+	if p.Probe != "" {
+		client := &http.Client{Timeout: 2 * time.Second}
+		scheme := "http://"
+		if p.TLS {
+			scheme = "https://"
+			client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+		}
+		resp, err := client.Get(scheme + p.Addr + p.Probe)
+		if err == nil {
+			resp.Body.Close()
+		}
+		if err != nil || resp.StatusCode/100 != 2 {
+			r.Status, r.Detail = StatusFail, p.Addr+" is taken by another service"
+			_, port, _ := net.SplitHostPort(p.Addr)
+			r.Hint = "find the owner with: ss -ltnp 'sport = :" + port + "', then stop it or move the " + p.Name + " to another port"
+			return r
+		}
+	}
+	r.Status, r.Detail = StatusOK, "listening on "+p.Addr
+	return r
+}
+
+func checkWritable(dir string) Result {
+	r := Result{Name: "recordings dir"}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		r.Status, r.Detail = StatusFail, err.Error()
+		r.Hint = "create " + dir + " and chown it to the service user"
+		return r
+	}
+	probe, err := os.CreateTemp(dir, ".petcam-doctor-*")
+	if err != nil {
+		r.Status, r.Detail = StatusFail, err.Error()
+		r.Hint = "chown " + dir + " to the service user or set RECORDING_PATH"
+		return r
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	r.Status, r.Detail = StatusOK, dir+" writable"
+	return r
+}
@@ -0,0 +1,80 @@
+package diagnostics
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunChecks(t *testing.T) {
+	shmDir := t.TempDir()
+	for name, size := range map[string]int{"pet_camera_h265_zc": 64, "pet_camera_mjpeg_zc": 32, "pet_camera_control": 0} {
+		if err := os.WriteFile(filepath.Join(shmDir, name), make([]byte, size), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ours := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer ours.Close()
+	ourAddr := strings.TrimPrefix(ours.URL, "http://")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	results := Run(Options{
+		ShmDir:   shmDir,
+		ShmNames: []string{"/pet_camera_h265_zc", "/pet_camera_mjpeg_zc", "/pet_camera_control", "/pet_camera_detections"},
+		ShmSizes: map[string]int64{"/pet_camera_h265_zc": 64, "/pet_camera_mjpeg_zc": 64},
+		Ports: []Port{
+			{Name: "up", Addr: ln.Addr().String()},
+			{Name: "down", Addr: closedAddr},
+			{Name: "ours", Addr: ourAddr, Probe: "/health"},
+			{Name: "conflict", Addr: ourAddr, Probe: "/readyz"},
+		},
+		RecordingsDir: filepath.Join(t.TempDir(), "recordings"),
+	})
+	want := map[string]string{
+		"shm dir":                    StatusOK,
+		"shm /pet_camera_h265_zc":    StatusOK,
+		"shm /pet_camera_mjpeg_zc":   StatusFail, // truncated
+		"shm /pet_camera_control":    StatusFail, // never sized
+		"shm /pet_camera_detections": StatusWarn,
+		"port up":                    StatusOK,
+		"port down":                  StatusWarn,
+		"port ours":                  StatusOK,
+		"port conflict":              StatusFail,
+		"recordings dir":             StatusOK,
+	}
+	if len(results) != len(want) {
+		t.Fatalf("results = %+v", results)
+	}
+	for _, r := range results {
+		if r.Status != want[r.Name] {
+			t.Errorf("%s: status %s (%s), want %s", r.Name, r.Status, r.Detail, want[r.Name])
+		}
+		if r.Status != StatusOK && r.Hint == "" {
+			t.Errorf("%s: no hint", r.Name)
+		}
+	}
+	if !Failed(results) {
+		t.Error("Failed = false with failing checks")
+	}
+	if !Failed(Run(Options{ShmDir: filepath.Join(shmDir, "missing")})) {
+		t.Error("missing shm dir not reported as failure")
+	}
+}
@@ -0,0 +1,364 @@
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/sframe"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/shm"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/streamserver"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/webmonitor"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/client"
+)
+
+// Frames each client must receive intact: more than a GOP, so the stream
+// crosses an IDR after the one it starts on.
+const wantFrames = GOPLength + GOPLength/2
+
+// stack is the fake camera with the streaming server and the web monitor
+// in front of it, as deployed on the board.
+type stack struct {
+	cam *FakeCamera
+	api *client.Client
+	dir string // working directory: every relative default path lands here
+}
+
+// startStack starts the stack; opts adjust the streaming server's settings.
+func startStack(t *testing.T, opts ...func(*streamserver.Config)) *stack {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("end-to-end test")
+	}
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	cam, err := NewFakeCamera(fmt.Sprintf("e2e_%d", os.Getpid()))
+	if err != nil {
+		t.Skipf("no POSIX shared memory: %v", err)
+	}
+	shm.SetImporter(cam.Import)
+	cam.Start()
+	t.Cleanup(func() {
+		cam.Close()
+		shm.SetImporter(nil)
+	})
+
+	scfg := streamserver.DefaultConfig()
+	scfg.ShmName = cam.H265Name
+	scfg.HTTPAddr = freeAddr(t)
+	scfg.MetricsAddr = ""
+	scfg.PprofAddr = ""
+	scfg.RecordPath = "stream"
+	scfg.ICEFamilies = "ipv4"
+	scfg.DegradeCPUHigh = 0
+	scfg.AccessLog = false
+	scfg.CrashDir = ""
+	for _, opt := range opts {
+		opt(&scfg)
+	}
+	if err := os.MkdirAll(scfg.RecordPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	srv, err := streamserver.NewServer(scfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Shutdown() })
+
+	mcfg := webmonitor.DefaultConfig()
+	mcfg.Addr = ""
+	mcfg.Socket = filepath.Join(dir, "monitor.sock")
+	mcfg.FrameShmName = cam.H265Name + "_absent" // MJPEG is not under test
+	mcfg.StreamShmName = cam.H265Name
+	mcfg.DetectionShmName = cam.DetectionName
+	mcfg.WebRTCBaseURL = "http://" + scfg.HTTPAddr
+	mcfg.ICEServers = ""
+	mcfg.PushKeyPath = ""
+	mcfg.ShareKeyFile = ""
+	mcfg.OutboxPath = ""
+	mcfg.ScrubInterval = 0
+	mcfg.ResumeRecording = false
+	mcfg.MotionFallback = false
+	mcfg.DegradeCPUHigh = 0
+	mcfg.AccessLog = false
+	mcfg.CrashDir = ""
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- webmonitor.Run(ctx, mcfg, nil) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("web monitor: %v", err)
+		}
+	})
+
+	api := client.New("unix:" + mcfg.Socket)
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if _, err = api.Status(context.Background()); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("web monitor not serving: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return &stack{cam: cam, api: api, dir: dir}
+}
+
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+var errEnough = errors.New("enough")
+
+func TestDetectionsReachSSE(t *testing.T) {
+	s := startStack(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var got *client.DetectionEvent
+	err := s.api.DetectionStream(ctx, func(ev *client.DetectionEvent) error {
+		if len(ev.Detections) == 0 {
+			return nil
+		}
+		got = ev
+		return errEnough
+	})
+	if err != errEnough {
+		t.Fatalf("DetectionStream: %v", err)
+	}
+	if got.FrameNumber%DetectEvery != 0 || len(got.Detections) != 1 {
+		t.Fatalf("detection event %+v: want one detection on a multiple of frame %d", got, DetectEvery)
+	}
+	d := got.Detections[0]
+	box := [4]int{d.BBox.X, d.BBox.Y, d.BBox.W, d.BBox.H}
+	if d.ClassName != DetectionClass || d.Confidence != DetectionConfidence || box != DetectionBox {
+		t.Errorf("detection %+v, want %s %.3f %v", d, DetectionClass, DetectionConfidence, DetectionBox)
+	}
+}
+
+func TestWebRTCDeliversFrames(t *testing.T) {
+	s := startStack(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	wc, err := newWebRTCClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wc.Close()
+	answer, err := s.api.Offer(ctx, client.SessionDescription{Type: "offer", SDP: wc.Offer()})
+	if err != nil {
+		t.Fatalf("Offer: %v", err)
+	}
+	if err := wc.Connect(ctx, answer.SDP); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	var last uint64
+	for i := 0; i < wantFrames; i++ {
+		ts, nals, err := wc.ReadFrame(ctx)
+		if err != nil {
+			t.Fatalf("after %d frames: %v", i, err)
+		}
+		n := uint64(ts / 3000)
+		if i > 0 && n <= last {
+			t.Fatalf("frame %d after frame %d", n, last)
+		}
+		last = n
+		if err := sameNALs(nals, FrameNALs(n)); err != nil {
+			t.Fatalf("frame %d: %v", n, err)
+		}
+	}
+}
+
+func TestWebRTCEndToEndEncryption(t *testing.T) {
+	secret := []byte("secret shared with the phone at pairing")
+	profiles := filepath.Join(t.TempDir(), "profiles.json")
+	data, _ := json.Marshal(map[string]sframe.Profile{"phone": {Secret: secret, E2E: true}})
+	if err := os.WriteFile(profiles, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	s := startStack(t, func(cfg *streamserver.Config) { cfg.E2EProfiles = profiles })
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	wc, err := newWebRTCClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wc.Close()
+	nonce := make([]byte, sframe.MinNonceSize)
+	rand.Read(nonce)
+	answer, err := s.api.Offer(ctx, client.SessionDescription{
+		Type: "offer",
+		SDP:  wc.Offer(),
+		E2E:  &client.E2E{Profile: "phone", Nonce: nonce},
+	})
+	if err != nil {
+		t.Fatalf("Offer: %v", err)
+	}
+	if answer.E2E == nil {
+		t.Fatal("answer without key grant")
+	}
+	key, err := sframe.UnwrapKey(secret, "phone", nonce, &sframe.Grant{
+		KID: answer.E2E.KID, Suite: answer.E2E.CipherSuite, WrappedKey: answer.E2E.WrappedKey,
+	})
+	if err != nil {
+		t.Fatalf("UnwrapKey: %v", err)
+	}
+	receiver := sframe.NewReceiver()
+	if err := receiver.AddKey(answer.E2E.KID, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := wc.Connect(ctx, answer.SDP); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	for i := 0; i < GOPLength+1; i++ {
+		ts, nals, err := wc.ReadFrame(ctx)
+		if err != nil {
+			t.Fatalf("after %d frames: %v", i, err)
+		}
+		n := uint64(ts / 3000)
+		want := FrameNALs(n)
+		if len(nals) != len(want) {
+			t.Fatalf("frame %d: %d NAL units, want %d", n, len(nals), len(want))
+		}
+		slice := nals[len(nals)-1]
+		if bytes.Contains(slice, want[len(want)-1][2:18]) {
+			t.Fatalf("frame %d: slice sent in the clear", n)
+		}
+		for j, nal := range nals {
+			if nals[j], err = receiver.UnprotectH265(nal); err != nil {
+				t.Fatalf("frame %d NAL unit %d: %v", n, j, err)
+			}
+		}
+		if err := sameNALs(nals, want); err != nil {
+			t.Fatalf("frame %d: %v", n, err)
+		}
+	}
+}
+
+func TestRecordingIsPlayable(t *testing.T) {
+	s := startStack(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	started, err := s.api.StartRecording(ctx, "e2e")
+	if err != nil {
+		t.Fatalf("StartRecording: %v", err)
+	}
+	for {
+		st, err := s.api.RecorderStatus(ctx)
+		if err != nil {
+			t.Fatalf("RecorderStatus: %v", err)
+		}
+		if st.FrameCount >= wantFrames {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	stopped, err := s.api.StopRecording(ctx)
+	if err != nil || stopped.File != started.File {
+		t.Fatalf("StopRecording = %+v, %v; started %s", stopped, err, started.File)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(s.dir, "recordings", stopped.File))
+	if errors.Is(err, os.ErrNotExist) {
+		// ffmpeg already remuxed it; the MP4 must be listed instead
+		recs, err := s.api.Recordings(ctx)
+		if err != nil || len(recs) == 0 {
+			t.Fatalf("raw recording gone but Recordings = %v, %v", recs, err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	nals := splitAnnexB(raw)
+	if len(nals) < 4 || !bytes.Equal(nals[0], testVPS) || !bytes.Equal(nals[1], testSPS) || !bytes.Equal(nals[2], testPPS) {
+		t.Fatalf("recording does not start with VPS/SPS/PPS (%d NAL units)", len(nals))
+	}
+	// The recorder primes the first IDR with its cached parameter sets, so
+	// they may repeat before it; otherwise frames are the test vector's.
+	var frames int
+	var last uint64
+	var params [][]byte
+	for i, nal := range nals {
+		if bytes.Equal(nal, testVPS) || bytes.Equal(nal, testSPS) || bytes.Equal(nal, testPPS) {
+			params = append(params, nal)
+			continue
+		}
+		n, ok := FrameNumberOf(nal)
+		if !ok {
+			t.Fatalf("NAL unit %d is not from the test vector", i)
+		}
+		want := FrameNALs(n)
+		if frames == 0 && n%GOPLength != 0 {
+			t.Fatalf("recording starts on frame %d, not an IDR", n)
+		}
+		if frames > 0 && n <= last {
+			t.Fatalf("frame %d after frame %d", n, last)
+		}
+		if !bytes.Equal(nal, want[len(want)-1]) {
+			t.Fatalf("frame %d: slice differs", n)
+		}
+		if len(want) > 1 && (len(params) < 3 || sameNALs(params[len(params)-3:], want[:3]) != nil) {
+			t.Fatalf("IDR frame %d without VPS/SPS/PPS", n)
+		}
+		if len(want) == 1 && len(params) > 0 {
+			t.Fatalf("parameter sets before non-IDR frame %d", n)
+		}
+		params = nil
+		last = n
+		frames++
+	}
+	if frames != stopped.Stats.FrameCount {
+		t.Errorf("recording holds %d frames, recorder reported %d", frames, stopped.Stats.FrameCount)
+	}
+}
+
+// sameNALs compares received NAL units with the test vector's.
+func sameNALs(got, want [][]byte) error {
+	if len(got) != len(want) {
+		return fmt.Errorf("%d NAL units, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !bytes.Equal(got[i], want[i]) {
+			return fmt.Errorf("NAL unit %d differs (%d bytes, want %d)", i, len(got[i]), len(want[i]))
+		}
+	}
+	return nil
+}
+
+// splitAnnexB splits a stream on 4-byte start codes; the test vector never
+// contains a zero pair inside a NAL unit.
+func splitAnnexB(data []byte) [][]byte {
+	var nals [][]byte
+	for _, part := range bytes.Split(data, []byte{0, 0, 0, 1}) {
+		if len(part) > 0 {
+			nals = append(nals, part)
+		}
+	}
+	return nals
+}
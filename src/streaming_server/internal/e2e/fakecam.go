@@ -0,0 +1,292 @@
+// Package e2e runs the streaming server and the web monitor against an
+// emulated camera: FakeCamera writes the camera daemon's H.265 and detection
+// SHM segments (the C structs from shared_memory.h, written the way
+// shared_memory.c writes them) from a known test vector, so the whole
+// pipeline — SHM readers, WebRTC, recording, SSE — runs in CI without the
+// board. Only the VPU buffer import is emulated (see shm.SetImporter): frame
+// descriptors carry the frame number instead of an hb_mem buffer.
+package e2e
+
+/*
+#cgo CFLAGS: -I../../../capture
+#cgo LDFLAGS: -lrt -lpthread
+
+#include <stdint.h>
+#include <stdlib.h>
+#include <string.h>
+#include <time.h>
+#include <fcntl.h>
+#include <unistd.h>
+#include <semaphore.h>
+#include <sys/mman.h>
+#include "shared_memory.h"
+
+static void* fake_shm_create(const char* name, size_t size) {
+    int fd = shm_open(name, O_CREAT | O_RDWR | O_TRUNC, 0666);
+    if (fd == -1) return NULL;
+    if (ftruncate(fd, size) != 0) {
+        close(fd);
+        return NULL;
+    }
+    void* p = mmap(NULL, size, PROT_READ | PROT_WRITE, MAP_SHARED, fd, 0);
+    close(fd);
+    if (p == MAP_FAILED) return NULL;
+    memset(p, 0, size);
+    return p;
+}
+
+static H265ZeroCopyBuffer* fake_h265_create(const char* name) {
+    H265ZeroCopyBuffer* shm = fake_shm_create(name, sizeof(H265ZeroCopyBuffer));
+    if (shm) {
+        sem_init(&shm->new_frame_sem, 1, 0);
+        sem_init(&shm->consumed_sem, 1, 0);
+    }
+    return shm;
+}
+
+// Mirrors shm_h265_zc_write; the descriptor holds the frame number.
+static void fake_h265_write(H265ZeroCopyBuffer* shm, uint64_t frame_number,
+                            int width, int height, uint32_t data_size) {
+    H265ZeroCopyFrame f;
+    memset(&f, 0, sizeof(f));
+    f.frame_number = frame_number;
+    clock_gettime(CLOCK_REALTIME, &f.timestamp);
+    f.width = width;
+    f.height = height;
+    f.data_size = data_size;
+    memcpy(f.hb_mem_buf_data, &frame_number, sizeof(frame_number));
+    const uint32_t ver = __atomic_load_n(&shm->frame.version, __ATOMIC_ACQUIRE);
+    memcpy(&shm->frame, &f, sizeof(f));
+    __atomic_store_n(&shm->frame.version, ver + 1, __ATOMIC_RELEASE);
+    sem_post(&shm->new_frame_sem);
+}
+
+static void fake_h265_destroy(H265ZeroCopyBuffer* shm, const char* name) {
+    sem_destroy(&shm->new_frame_sem);
+    sem_destroy(&shm->consumed_sem);
+    munmap(shm, sizeof(H265ZeroCopyBuffer));
+    shm_unlink(name);
+}
+
+static LatestDetectionResult* fake_detection_create(const char* name) {
+    LatestDetectionResult* shm = fake_shm_create(name, sizeof(LatestDetectionResult));
+    if (shm) sem_init(&shm->detection_update_sem, 1, 0);
+    return shm;
+}
+
+// Mirrors shm_detection_write for a single detection.
+static void fake_detection_write(LatestDetectionResult* shm, uint64_t frame_number, double timestamp,
+                                 const char* class_name, float confidence, int x, int y, int w, int h) {
+    DetectionEntry d;
+    memset(&d, 0, sizeof(d));
+    strncpy(d.class_name, class_name, sizeof(d.class_name) - 1);
+    d.confidence = confidence;
+    d.bbox.x = x;
+    d.bbox.y = y;
+    d.bbox.w = w;
+    d.bbox.h = h;
+    shm->frame_number = frame_number;
+    shm->timestamp = timestamp;
+    shm->num_detections = 1;
+    memcpy(shm->detections, &d, sizeof(d));
+    __atomic_fetch_add(&shm->version, 1, __ATOMIC_RELEASE);
+    sem_post(&shm->detection_update_sem);
+}
+
+static void fake_detection_destroy(LatestDetectionResult* shm, const char* name) {
+    sem_destroy(&shm->detection_update_sem);
+    munmap(shm, sizeof(LatestDetectionResult));
+    shm_unlink(name);
+}
+*/
+import "C"
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// Test vector geometry.
+const (
+	FrameWidth    = 1280
+	FrameHeight   = 720
+	FrameInterval = time.Second / 30
+	GOPLength     = 30 // frames per IDR
+	DetectEvery   = 10 // frames per detection result
+
+	idrSliceSize   = 4000 // larger than the RTP MTU: exercises FU fragmentation
+	trailSliceSize = 400
+	keptFrames     = 64 // bitstreams the importer can still resolve
+)
+
+// The detection FakeCamera reports every DetectEvery frames.
+const (
+	DetectionClass      = "cat"
+	DetectionConfidence = 0.875
+)
+
+// DetectionBox is the box of the reported detection.
+var DetectionBox = [4]int{100, 120, 64, 48}
+
+// Fixed parameter sets of the test vector (NAL header + marker payload; the
+// server caches and forwards them, it does not decode them).
+var (
+	testVPS = []byte{0x40, 0x01, 'v', 'p', 's'}
+	testSPS = []byte{0x42, 0x01, 's', 'p', 's'}
+	testPPS = []byte{0x44, 0x01, 'p', 'p', 's'}
+)
+
+// FrameNALs returns the NAL units (without start codes) of test vector frame
+// n: VPS, SPS, PPS and an IDR slice every GOPLength frames, else a TRAIL_R
+// slice. Slice payloads start with the frame number in ASCII and are filled
+// with bytes >= 0x80, so no start code can appear inside them.
+func FrameNALs(n uint64) [][]byte {
+	var nals [][]byte
+	header, size := []byte{0x02, 0x01}, trailSliceSize // TRAIL_R
+	if n%GOPLength == 0 {
+		nals = append(nals, testVPS, testSPS, testPPS)
+		header, size = []byte{0x26, 0x01}, idrSliceSize // IDR_W_RADL
+	}
+	slice := append(header, fmt.Sprintf("frame-%08d:", n)...)
+	for len(slice) < size {
+		slice = append(slice, byte(0x80+(uint64(len(slice))+n)%0x7f))
+	}
+	return append(nals, slice)
+}
+
+// FrameNumberOf returns the frame number encoded in a test vector slice
+// (as produced by FrameNALs).
+func FrameNumberOf(slice []byte) (uint64, bool) {
+	var n uint64
+	if len(slice) < 17 || string(slice[2:8]) != "frame-" {
+		return 0, false
+	}
+	if _, err := fmt.Sscanf(string(slice[8:16]), "%08d", &n); err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// AnnexB joins NAL units with 4-byte start codes, as the encoder emits them.
+func AnnexB(nals [][]byte) []byte {
+	var out []byte
+	for _, nal := range nals {
+		out = append(out, 0, 0, 0, 1)
+		out = append(out, nal...)
+	}
+	return out
+}
+
+// FakeCamera plays the camera daemon and the detection daemon.
+type FakeCamera struct {
+	H265Name      string
+	DetectionName string
+
+	h265 *C.H265ZeroCopyBuffer
+	det  *C.LatestDetectionResult
+
+	mu     sync.Mutex
+	frames map[uint64][]byte // recent bitstreams by frame number
+	next   uint64
+
+	started bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewFakeCamera creates the SHM segments under names unique to prefix.
+func NewFakeCamera(prefix string) (*FakeCamera, error) {
+	c := &FakeCamera{
+		H265Name:      "/" + prefix + "_h265_zc",
+		DetectionName: "/" + prefix + "_detections",
+		frames:        make(map[uint64][]byte),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	h265Name := C.CString(c.H265Name)
+	defer C.free(unsafe.Pointer(h265Name))
+	if c.h265 = C.fake_h265_create(h265Name); c.h265 == nil {
+		return nil, fmt.Errorf("create %s failed", c.H265Name)
+	}
+	detName := C.CString(c.DetectionName)
+	defer C.free(unsafe.Pointer(detName))
+	if c.det = C.fake_detection_create(detName); c.det == nil {
+		C.fake_h265_destroy(c.h265, h265Name)
+		return nil, fmt.Errorf("create %s failed", c.DetectionName)
+	}
+	return c, nil
+}
+
+// Start writes a frame every FrameInterval, and a detection result every
+// DetectEvery frames, until Close.
+func (c *FakeCamera) Start() {
+	c.started = true
+	go func() {
+		defer close(c.done)
+		ticker := time.NewTicker(FrameInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+				c.WriteFrame()
+			}
+		}
+	}()
+}
+
+// WriteFrame writes the next test vector frame and returns its number.
+func (c *FakeCamera) WriteFrame() uint64 {
+	c.mu.Lock()
+	n := c.next
+	c.next++
+	data := AnnexB(FrameNALs(n))
+	c.frames[n] = data
+	delete(c.frames, n-keptFrames)
+	c.mu.Unlock()
+
+	C.fake_h265_write(c.h265, C.uint64_t(n), FrameWidth, FrameHeight, C.uint32_t(len(data)))
+	if n%DetectEvery == 0 {
+		class := C.CString(DetectionClass)
+		defer C.free(unsafe.Pointer(class))
+		b := DetectionBox
+		C.fake_detection_write(c.det, C.uint64_t(n), C.double(float64(time.Now().UnixNano())/1e9),
+			class, DetectionConfidence, C.int(b[0]), C.int(b[1]), C.int(b[2]), C.int(b[3]))
+	}
+	return n
+}
+
+// Import resolves a frame descriptor written by WriteFrame; install it with
+// shm.SetImporter.
+func (c *FakeCamera) Import(desc, dst []byte) error {
+	n := binary.NativeEndian.Uint64(desc)
+	c.mu.Lock()
+	data, ok := c.frames[n]
+	c.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("frame %d no longer buffered", n)
+	}
+	if len(data) != len(dst) {
+		return fmt.Errorf("frame %d is %d bytes, descriptor says %d", n, len(data), len(dst))
+	}
+	copy(dst, data)
+	return nil
+}
+
+// Close stops writing and removes the SHM segments; call it once.
+func (c *FakeCamera) Close() {
+	close(c.stop)
+	if c.started {
+		<-c.done
+	}
+	h265Name := C.CString(c.H265Name)
+	defer C.free(unsafe.Pointer(h265Name))
+	C.fake_h265_destroy(c.h265, h265Name)
+	detName := C.CString(c.DetectionName)
+	defer C.free(unsafe.Pointer(detName))
+	C.fake_detection_destroy(c.det, detName)
+}
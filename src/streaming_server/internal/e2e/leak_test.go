@@ -0,0 +1,99 @@
+package e2e
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/metrics"
+	"testing"
+	"time"
+)
+
+// sample is one reading of the test process (both servers run in it) during
+// a soak run.
+type sample struct {
+	At          time.Duration // since the run started
+	Goroutines  int
+	HeapBytes   uint64 // live heap objects after a collection
+	HeapObjects uint64
+}
+
+func (s sample) String() string {
+	return fmt.Sprintf("%v: %d goroutines, %.1f MiB heap in %d objects",
+		s.At.Round(time.Second), s.Goroutines, float64(s.HeapBytes)/(1<<20), s.HeapObjects)
+}
+
+// takeSample forces a collection, so the heap figures are live memory
+// rather than garbage waiting for the next cycle.
+func takeSample(start time.Time) sample {
+	runtime.GC()
+	m := []metrics.Sample{
+		{Name: "/memory/classes/heap/objects:bytes"},
+		{Name: "/gc/heap/objects:objects"},
+	}
+	metrics.Read(m)
+	return sample{
+		At:          time.Since(start),
+		Goroutines:  runtime.NumGoroutine(),
+		HeapBytes:   m[0].Value.Uint64(),
+		HeapObjects: m[1].Value.Uint64(),
+	}
+}
+
+// growth looks for a leak in one series of samples: the series is cut into
+// windows and each window's floor (its minimum) taken, so the clients that
+// happen to be connected at a sample do not count. A leak is a floor that
+// rises from window to window, by more than limit overall; a series that
+// levels off or dips anywhere is warm-up or churn. It returns the rise of
+// the floor from the first window to the last.
+func growth(series []float64, windows int, limit float64) (rise float64, leak bool) {
+	if windows < 2 || len(series) < windows {
+		return 0, false
+	}
+	floors := make([]float64, windows)
+	for w := range floors {
+		part := series[w*len(series)/windows : (w+1)*len(series)/windows]
+		floors[w] = part[0]
+		for _, v := range part[1:] {
+			floors[w] = min(floors[w], v)
+		}
+	}
+	rise = floors[windows-1] - floors[0]
+	for w := 1; w < windows; w++ {
+		if floors[w] <= floors[w-1] {
+			return rise, false
+		}
+	}
+	return rise, rise > limit
+}
+
+func TestGrowth(t *testing.T) {
+	ramp := func(n int, step float64, noise ...float64) []float64 {
+		s := make([]float64, n)
+		for i := range s {
+			s[i] = 100 + float64(i)*step
+			if len(noise) > 0 {
+				s[i] += noise[i%len(noise)]
+			}
+		}
+		return s
+	}
+	churn := []float64{0, 12, 3, 25, 8}
+	for _, c := range []struct {
+		name   string
+		series []float64
+		limit  float64
+		leak   bool
+	}{
+		{"flat", ramp(40, 0), 10, false},
+		{"flat with churn", ramp(40, 0, churn...), 10, false},
+		{"one leaked per sample", ramp(40, 1, churn...), 10, true},
+		{"slow leak under the limit", ramp(40, 0.1), 10, false},
+		{"warm-up then flat", append(ramp(10, 5), ramp(30, 0, 50)...), 10, false},
+		{"too few samples", ramp(3, 10), 10, false},
+	} {
+		rise, leak := growth(c.series, 4, c.limit)
+		if leak != c.leak {
+			t.Errorf("%s: leak = %v (rise %.1f), want %v", c.name, leak, rise, c.leak)
+		}
+	}
+}
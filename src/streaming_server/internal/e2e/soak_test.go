@@ -0,0 +1,195 @@
+//go:build soak
+
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/streamserver"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/client"
+)
+
+var (
+	soakDuration   = flag.Duration("soak.duration", 2*time.Hour, "how long the soak test churns clients")
+	soakWarmup     = flag.Duration("soak.warmup", 5*time.Minute, "time before the first sample (pools and caches fill)")
+	soakSample     = flag.Duration("soak.sample", time.Minute, "interval between goroutine and heap samples")
+	soakViewers    = flag.Int("soak.viewers", 4, "WebRTC viewers connecting and leaving concurrently")
+	soakGoroutines = flag.Int("soak.goroutines", 20, "goroutine growth that fails the test")
+	soakHeapMB     = flag.Int("soak.heap-mb", 16, "live heap growth in MiB that fails the test")
+)
+
+// soakWindows is the number of windows growth compares; a leak must raise
+// the floor of every one.
+const soakWindows = 4
+
+// soakStats counts what the churners did, to tell a quiet run from a
+// broken one.
+type soakStats struct {
+	sessions, sessionErrors atomic.Int64
+	streams, streamErrors   atomic.Int64
+	recordings, recErrors   atomic.Int64
+}
+
+// TestSoak runs the stack for -soak.duration while viewers, SSE
+// subscribers and recordings come and go, samples the process's goroutines
+// and heap every -soak.sample after -soak.warmup, and fails if either keeps
+// growing (see growth). Run it with
+//
+//	go test -tags soak ./internal/e2e -run Soak -timeout 0 -v
+func TestSoak(t *testing.T) {
+	// A viewer that leaves without a goodbye holds its slot until the
+	// server's read deadline (30s), so allow for many more than are watching
+	s := startStack(t, func(cfg *streamserver.Config) { cfg.MaxClients = 10 * *soakViewers })
+	ctx, cancel := context.WithTimeout(context.Background(), *soakDuration)
+	defer cancel()
+
+	var st soakStats
+	var wg sync.WaitGroup
+	churn := func(fn func(context.Context, *stack, *soakStats)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				fn(ctx, s, &st)
+				pause(ctx, time.Duration(rand.IntN(2000))*time.Millisecond)
+			}
+		}()
+	}
+	for range *soakViewers {
+		churn(churnViewer)
+	}
+	churn(churnStream)
+	churn(churnStream)
+	churn(churnRecording)
+
+	start := time.Now()
+	var samples []sample
+	pause(ctx, *soakWarmup)
+	ticker := time.NewTicker(*soakSample)
+	defer ticker.Stop()
+	for ctx.Err() == nil {
+		sm := takeSample(start)
+		samples = append(samples, sm)
+		t.Logf("%v (%d sessions, %d streams, %d recordings)", sm, st.sessions.Load(), st.streams.Load(), st.recordings.Load())
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+	wg.Wait()
+
+	t.Logf("%d viewer sessions (%d failed), %d SSE streams (%d failed), %d recordings (%d failed)",
+		st.sessions.Load(), st.sessionErrors.Load(), st.streams.Load(), st.streamErrors.Load(),
+		st.recordings.Load(), st.recErrors.Load())
+	if st.sessions.Load() == 0 || st.sessionErrors.Load()*2 > st.sessions.Load() {
+		t.Errorf("most viewer sessions failed; the run says nothing about leaks")
+	}
+	if len(samples) < 2*soakWindows {
+		t.Fatalf("only %d samples; run longer or sample more often", len(samples))
+	}
+
+	goroutines := make([]float64, len(samples))
+	heap := make([]float64, len(samples))
+	for i, sm := range samples {
+		goroutines[i] = float64(sm.Goroutines)
+		heap[i] = float64(sm.HeapBytes)
+	}
+	leaked := false
+	if rise, leak := growth(goroutines, soakWindows, float64(*soakGoroutines)); leak {
+		t.Errorf("goroutines keep growing: +%.0f over the run", rise)
+		leaked = true
+	}
+	if rise, leak := growth(heap, soakWindows, float64(*soakHeapMB)*(1<<20)); leak {
+		t.Errorf("live heap keeps growing: +%.1f MiB over the run", rise/(1<<20))
+		leaked = true
+	}
+	if leaked {
+		var buf bytes.Buffer
+		pprof.Lookup("goroutine").WriteTo(&buf, 1)
+		t.Logf("goroutines at the end:\n%s", buf.String())
+	}
+}
+
+// churnViewer connects a WebRTC viewer, watches a few seconds of video and
+// leaves without a goodbye, the way a phone dropping off Wi-Fi does.
+func churnViewer(ctx context.Context, s *stack, st *soakStats) {
+	wc, err := newWebRTCClient()
+	if err != nil {
+		st.sessionErrors.Add(1)
+		return
+	}
+	defer wc.Close()
+	st.sessions.Add(1)
+	sctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	answer, err := s.api.Offer(sctx, client.SessionDescription{Type: "offer", SDP: wc.Offer()})
+	if err == nil {
+		err = wc.Connect(sctx, answer.SDP)
+	}
+	for n := 30 + rand.IntN(300); err == nil && n > 0; n-- {
+		_, _, err = wc.ReadFrame(sctx)
+	}
+	if err != nil && ctx.Err() == nil {
+		st.sessionErrors.Add(1)
+	}
+}
+
+// churnStream follows the detection or the status stream for a while.
+func churnStream(ctx context.Context, s *stack, st *soakStats) {
+	st.streams.Add(1)
+	sctx, cancel := context.WithTimeout(ctx, time.Duration(1+rand.IntN(10))*time.Second)
+	defer cancel()
+	var err error
+	if rand.IntN(2) == 0 {
+		err = s.api.DetectionStream(sctx, func(*client.DetectionEvent) error { return nil })
+	} else {
+		err = s.api.StatusStream(sctx, func(*client.Status) error { return nil })
+	}
+	if err != nil && sctx.Err() == nil {
+		st.streamErrors.Add(1)
+	}
+}
+
+// churnRecording records for 10-40 seconds, heartbeating like the
+// dashboard, and removes the file so the disk does not fill over hours.
+func churnRecording(ctx context.Context, s *stack, st *soakStats) {
+	st.recordings.Add(1)
+	if _, err := s.api.StartRecording(ctx, "soak"); err != nil {
+		if ctx.Err() == nil {
+			st.recErrors.Add(1)
+		}
+		return
+	}
+	end := time.Now().Add(time.Duration(10+rand.IntN(30)) * time.Second)
+	for time.Now().Before(end) && pause(ctx, time.Second) {
+		s.api.Heartbeat(ctx)
+	}
+	stopped, err := s.api.StopRecording(context.Background())
+	if err != nil {
+		st.recErrors.Add(1)
+		return
+	}
+	os.Remove(filepath.Join(s.dir, "recordings", stopped.File))
+}
+
+// pause sleeps for d, returning false if ctx ends first.
+func pause(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
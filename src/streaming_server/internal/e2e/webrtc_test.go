@@ -0,0 +1,343 @@
+package e2e
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/dtls/v3"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/srtp"
+)
+
+// webrtcClient is the smallest receiver the camera's ICE-lite endpoint
+// accepts: one STUN binding request, a DTLS client handshake
+// (setup:active), then SRTP-protected H.265 over RTP (RFC 7798).
+type webrtcClient struct {
+	conn  *net.UDPConn
+	cert  tls.Certificate
+	ufrag string
+	pwd   string
+
+	remote *net.UDPAddr
+	dtls   *dtls.Conn
+	srtp   *srtp.Context
+	demux  *demuxConn
+}
+
+func newWebRTCClient() (*webrtcClient, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		return nil, err
+	}
+	cert, err := selfSignedCert()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &webrtcClient{conn: conn, cert: cert, ufrag: "e2etest", pwd: "e2etestpassword0123456789"}, nil
+}
+
+func selfSignedCert() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// Offer returns a recvonly H.265 offer for this client.
+func (c *webrtcClient) Offer() string {
+	sum := sha256.Sum256(c.cert.Certificate[0])
+	fp := strings.ToUpper(hex.EncodeToString(sum[:]))
+	var pairs []string
+	for i := 0; i < len(fp); i += 2 {
+		pairs = append(pairs, fp[i:i+2])
+	}
+	lines := []string{
+		"v=0",
+		"o=- 1 1 IN IP4 127.0.0.1",
+		"s=-",
+		"t=0 0",
+		"a=group:BUNDLE 0",
+		"m=video 9 UDP/TLS/RTP/SAVPF 96",
+		"c=IN IP4 0.0.0.0",
+		"a=mid:0",
+		"a=ice-ufrag:" + c.ufrag,
+		"a=ice-pwd:" + c.pwd,
+		"a=fingerprint:sha-256 " + strings.Join(pairs, ":"),
+		"a=setup:active",
+		"a=recvonly",
+		"a=rtcp-mux",
+		"a=rtpmap:96 H265/90000",
+	}
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+var reCandidatePort = regexp.MustCompile(`a=candidate:\S+ 1 udp \d+ \S+ (\d+) typ host`)
+
+// Connect runs ICE and DTLS against the candidate port of answer (the
+// camera listens on every address, so loopback reaches it) and derives the
+// SRTP keys of the camera's stream.
+func (c *webrtcClient) Connect(ctx context.Context, answer string) error {
+	m := reCandidatePort.FindStringSubmatch(answer)
+	if m == nil {
+		return fmt.Errorf("no host candidate in answer:\n%s", answer)
+	}
+	port, _ := strconv.Atoi(m[1])
+	c.remote = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+
+	if err := c.bind(ctx); err != nil {
+		return err
+	}
+
+	c.demux = newDemuxConn(c.conn)
+	conn, err := dtls.Client(c.demux, c.remote, &dtls.Config{
+		Certificates:           []tls.Certificate{c.cert},
+		ExtendedMasterSecret:   dtls.RequireExtendedMasterSecret,
+		SRTPProtectionProfiles: []dtls.SRTPProtectionProfile{dtls.SRTP_AES128_CM_HMAC_SHA1_80},
+		InsecureSkipVerify:     true, // the answer's fingerprint is all there is to check
+	})
+	if err != nil {
+		return fmt.Errorf("dtls: %w", err)
+	}
+	c.dtls = conn
+	if err := conn.HandshakeContext(ctx); err != nil {
+		return fmt.Errorf("dtls handshake: %w", err)
+	}
+	state, ok := conn.ConnectionState()
+	if !ok {
+		return errors.New("dtls: no connection state")
+	}
+	km, err := state.ExportKeyingMaterial("EXTRACTOR-dtls_srtp", nil, 60)
+	if err != nil {
+		return fmt.Errorf("dtls: export keying material: %w", err)
+	}
+	// The camera's write key: AES-CM is a stream cipher, so "encrypting"
+	// with it decrypts what the camera sent.
+	c.srtp, err = srtp.FromKeyMaterial(km, 16, 14, false)
+	return err
+}
+
+// bind sends STUN binding requests until the camera answers one.
+func (c *webrtcClient) bind(ctx context.Context) error {
+	req := make([]byte, 20)
+	binary.BigEndian.PutUint16(req[0:2], 0x0001) // Binding Request, no attributes
+	binary.BigEndian.PutUint32(req[4:8], 0x2112A442)
+	rand.Read(req[8:20])
+	buf := make([]byte, 1500)
+	for ctx.Err() == nil {
+		if _, err := c.conn.WriteToUDP(req, c.remote); err != nil {
+			return err
+		}
+		c.conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		n, _, err := c.conn.ReadFromUDP(buf)
+		if err == nil && n >= 20 && binary.BigEndian.Uint16(buf[0:2]) == 0x0101 && string(buf[8:20]) == string(req[8:20]) {
+			c.conn.SetReadDeadline(time.Time{})
+			return nil
+		}
+	}
+	return fmt.Errorf("ice: no binding response: %w", ctx.Err())
+}
+
+// ReadFrame returns the next complete access unit: the RTP timestamp and
+// NAL units of every packet up to the marker bit.
+func (c *webrtcClient) ReadFrame(ctx context.Context) (uint32, [][]byte, error) {
+	var nals [][]byte
+	var fu []byte
+	for {
+		var pkt []byte
+		select {
+		case <-ctx.Done():
+			return 0, nil, ctx.Err()
+		case pkt = <-c.demux.rtp:
+		}
+		if len(pkt) < 12+10 || pkt[1]&0x7f != 96 {
+			continue // RTCP
+		}
+		headerLen := 12 + 4*int(pkt[0]&0x0f)
+		if pkt[0]&0x10 != 0 {
+			headerLen += 4 + 4*int(binary.BigEndian.Uint16(pkt[headerLen+2:]))
+		}
+		seq := binary.BigEndian.Uint16(pkt[2:4])
+		ts := binary.BigEndian.Uint32(pkt[4:8])
+		ssrc := binary.BigEndian.Uint32(pkt[8:12])
+		plain, err := c.srtp.EncryptRTP(nil, pkt[:len(pkt)-10], headerLen, seq, ssrc)
+		if err != nil {
+			return 0, nil, err
+		}
+		payload := plain[headerLen : len(plain)-10]
+		if len(payload) < 3 {
+			return 0, nil, fmt.Errorf("rtp seq %d: %d byte payload", seq, len(payload))
+		}
+
+		switch payload[0] >> 1 & 0x3f {
+		case 48: // aggregation packet
+			for p := payload[2:]; len(p) >= 2; {
+				size := int(binary.BigEndian.Uint16(p))
+				if len(p) < 2+size {
+					return 0, nil, fmt.Errorf("rtp seq %d: truncated aggregation packet", seq)
+				}
+				nals = append(nals, append([]byte(nil), p[2:2+size]...))
+				p = p[2+size:]
+			}
+		case 49: // fragmentation unit
+			fuHeader := payload[2]
+			if fuHeader&0x80 != 0 {
+				fu = []byte{payload[0]&0x81 | (fuHeader&0x3f)<<1, payload[1]}
+			}
+			if fu == nil {
+				return 0, nil, fmt.Errorf("rtp seq %d: fragment without start", seq)
+			}
+			fu = append(fu, payload[3:]...)
+			if fuHeader&0x40 != 0 {
+				nals = append(nals, fu)
+				fu = nil
+			}
+		default:
+			nals = append(nals, append([]byte(nil), payload...))
+		}
+		if pkt[1]&0x80 != 0 {
+			return ts, nals, nil
+		}
+	}
+}
+
+func (c *webrtcClient) Close() {
+	if c.dtls != nil {
+		c.dtls.Close() // closes demux, not conn
+	}
+	if c.demux != nil {
+		c.demux.stop()
+	}
+	c.conn.Close()
+}
+
+// demuxConn hands DTLS records to pion/dtls and queues RTP for ReadFrame,
+// the way the camera's own adapter splits its socket.
+type demuxConn struct {
+	conn *net.UDPConn
+	dtls chan []byte
+	rtp  chan []byte
+
+	mu       sync.Mutex
+	deadline time.Time
+	changed  chan struct{} // closed when deadline changes
+	done     chan struct{}
+	once     sync.Once
+}
+
+func newDemuxConn(conn *net.UDPConn) *demuxConn {
+	d := &demuxConn{
+		conn:    conn,
+		dtls:    make(chan []byte, 64),
+		rtp:     make(chan []byte, 4096),
+		changed: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go d.read()
+	return d
+}
+
+func (d *demuxConn) read() {
+	for {
+		buf := make([]byte, 1500)
+		n, _, err := d.conn.ReadFromUDP(buf)
+		if err != nil {
+			d.stop()
+			return
+		}
+		switch {
+		case n > 0 && buf[0] >= 20 && buf[0] <= 63:
+			select {
+			case d.dtls <- buf[:n]:
+			case <-d.done:
+				return
+			}
+		case n > 0 && buf[0] >= 128 && buf[0] <= 191:
+			select {
+			case d.rtp <- buf[:n]:
+			default: // the test stopped reading
+			}
+		}
+	}
+}
+
+func (d *demuxConn) stop() { d.once.Do(func() { close(d.done) }) }
+
+func (d *demuxConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	for {
+		d.mu.Lock()
+		deadline, changed := d.deadline, d.changed
+		d.mu.Unlock()
+		timer := time.NewTimer(time.Hour)
+		if !deadline.IsZero() {
+			timer.Reset(time.Until(deadline))
+		}
+		select {
+		case pkt := <-d.dtls:
+			timer.Stop()
+			return copy(b, pkt), d.conn.LocalAddr(), nil
+		case <-timer.C:
+			return 0, nil, timeoutError{}
+		case <-d.done:
+			timer.Stop()
+			return 0, nil, net.ErrClosed
+		case <-changed:
+			timer.Stop()
+		}
+	}
+}
+
+func (d *demuxConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	return d.conn.WriteTo(b, addr)
+}
+
+func (d *demuxConn) Close() error {
+	return nil // webrtcClient.Close closes the socket
+}
+
+func (d *demuxConn) LocalAddr() net.Addr { return d.conn.LocalAddr() }
+
+func (d *demuxConn) SetDeadline(t time.Time) error { return d.SetReadDeadline(t) }
+
+func (d *demuxConn) SetReadDeadline(t time.Time) error {
+	d.mu.Lock()
+	d.deadline = t
+	close(d.changed)
+	d.changed = make(chan struct{})
+	d.mu.Unlock()
+	return nil
+}
+
+func (d *demuxConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
@@ -0,0 +1,213 @@
+// Package eventbus is a typed in-process publish/subscribe bus. Producers
+// publish to a Topic without knowing who consumes; consumers subscribe
+// either synchronously (called in the publisher's goroutine, in
+// subscription order) or through a buffered channel that drops when full,
+// so a slow consumer never stalls a producer. A Bus groups the topics of a
+// process and exports their counters to Prometheus.
+package eventbus
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Topic is a named stream of values of type T.
+type Topic[T any] struct {
+	name      string
+	published atomic.Uint64
+
+	mu     sync.RWMutex
+	subs   []*subscriber[T]
+	closed map[string]SubscriberStats // counters of cancelled subscribers, by name
+}
+
+type subscriber[T any] struct {
+	name      string
+	fn        func(T) // synchronous subscriber
+	ch        chan T  // channel subscriber
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+// NewTopic creates a topic and attaches it to b (nil: no metrics).
+func NewTopic[T any](b *Bus, name string) *Topic[T] {
+	t := &Topic[T]{name: name, closed: make(map[string]SubscriberStats)}
+	if b != nil {
+		b.Attach(t)
+	}
+	return t
+}
+
+// Name returns the topic name.
+func (t *Topic[T]) Name() string {
+	return t.name
+}
+
+// Publish delivers v to every subscriber in subscription order, to
+// channel subscribers without blocking.
+func (t *Topic[T]) Publish(v T) {
+	t.published.Add(1)
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, s := range t.subs {
+		if s.fn != nil {
+			s.fn(v)
+			s.delivered.Add(1)
+			continue
+		}
+		select {
+		case s.ch <- v:
+			s.delivered.Add(1)
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// Subscribe calls fn for every value published from now on, in the
+// publisher's goroutine, so fn must be quick and must not publish or
+// subscribe to t. name labels the subscriber's metrics.
+func (t *Topic[T]) Subscribe(name string, fn func(T)) (cancel func()) {
+	return t.add(&subscriber[T]{name: name, fn: fn})
+}
+
+// SubscribeChan returns a channel receiving initial, then every value
+// published from now on. Values are dropped (and counted) while the
+// channel's buffer is full. cancel closes the channel.
+func (t *Topic[T]) SubscribeChan(name string, buffer int, initial ...T) (ch <-chan T, cancel func()) {
+	s := &subscriber[T]{name: name, ch: make(chan T, max(buffer, len(initial)))}
+	for _, v := range initial {
+		s.ch <- v
+	}
+	return s.ch, t.add(s)
+}
+
+func (t *Topic[T]) add(s *subscriber[T]) func() {
+	t.mu.Lock()
+	t.subs = append(t.subs, s)
+	t.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.subs = slices.DeleteFunc(t.subs, func(x *subscriber[T]) bool { return x == s })
+			st := t.closed[s.name]
+			st.Name = s.name
+			st.Delivered += s.delivered.Load()
+			st.Dropped += s.dropped.Load()
+			t.closed[s.name] = st
+			if s.ch != nil {
+				close(s.ch)
+			}
+		})
+	}
+}
+
+// TopicStats are the counters of a topic. Subscribers sharing a name (e.g.
+// one per SSE client) are summed, including cancelled ones.
+type TopicStats struct {
+	Name        string            `json:"name"`
+	Published   uint64            `json:"published"`
+	Active      int               `json:"subscribers"`
+	Subscribers []SubscriberStats `json:"by_subscriber"`
+}
+
+// SubscriberStats are the counters of the subscribers with one name.
+type SubscriberStats struct {
+	Name      string `json:"name"`
+	Delivered uint64 `json:"delivered"`
+	Dropped   uint64 `json:"dropped"`
+}
+
+// Stats returns the topic's counters.
+func (t *Topic[T]) Stats() TopicStats {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	byName := make(map[string]SubscriberStats, len(t.closed)+len(t.subs))
+	for name, st := range t.closed {
+		byName[name] = st
+	}
+	for _, s := range t.subs {
+		st := byName[s.name]
+		st.Name = s.name
+		st.Delivered += s.delivered.Load()
+		st.Dropped += s.dropped.Load()
+		byName[s.name] = st
+	}
+	stats := TopicStats{Name: t.name, Published: t.published.Load(), Active: len(t.subs)}
+	for _, st := range byName {
+		stats.Subscribers = append(stats.Subscribers, st)
+	}
+	slices.SortFunc(stats.Subscribers, func(a, b SubscriberStats) int { return strings.Compare(a.Name, b.Name) })
+	return stats
+}
+
+// Observable is a topic as seen by a Bus.
+type Observable interface {
+	Stats() TopicStats
+}
+
+// Bus groups topics for metrics and introspection. It is a
+// prometheus.Collector.
+type Bus struct {
+	mu     sync.Mutex
+	topics []Observable
+}
+
+// New creates an empty bus.
+func New() *Bus {
+	return &Bus{}
+}
+
+// Attach adds a topic created without a bus (see NewTopic). Topic names
+// must be unique within a bus.
+func (b *Bus) Attach(t Observable) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.topics = append(b.topics, t)
+}
+
+// Stats returns the counters of every topic, in attach order.
+func (b *Bus) Stats() []TopicStats {
+	b.mu.Lock()
+	topics := slices.Clone(b.topics)
+	b.mu.Unlock()
+	out := make([]TopicStats, len(topics))
+	for i, t := range topics {
+		out[i] = t.Stats()
+	}
+	return out
+}
+
+var (
+	publishedDesc   = prometheus.NewDesc("eventbus_published_total", "Values published to an event bus topic", []string{"topic"}, nil)
+	subscribersDesc = prometheus.NewDesc("eventbus_subscribers", "Active subscribers of an event bus topic", []string{"topic"}, nil)
+	deliveredDesc   = prometheus.NewDesc("eventbus_delivered_total", "Values delivered to the subscribers of a topic", []string{"topic", "subscriber"}, nil)
+	droppedDesc     = prometheus.NewDesc("eventbus_dropped_total", "Values dropped because a channel subscriber's buffer was full", []string{"topic", "subscriber"}, nil)
+)
+
+// Describe implements prometheus.Collector.
+func (b *Bus) Describe(ch chan<- *prometheus.Desc) {
+	ch <- publishedDesc
+	ch <- subscribersDesc
+	ch <- deliveredDesc
+	ch <- droppedDesc
+}
+
+// Collect implements prometheus.Collector.
+func (b *Bus) Collect(ch chan<- prometheus.Metric) {
+	for _, t := range b.Stats() {
+		ch <- prometheus.MustNewConstMetric(publishedDesc, prometheus.CounterValue, float64(t.Published), t.Name)
+		ch <- prometheus.MustNewConstMetric(subscribersDesc, prometheus.GaugeValue, float64(t.Active), t.Name)
+		for _, s := range t.Subscribers {
+			ch <- prometheus.MustNewConstMetric(deliveredDesc, prometheus.CounterValue, float64(s.Delivered), t.Name, s.Name)
+			ch <- prometheus.MustNewConstMetric(droppedDesc, prometheus.CounterValue, float64(s.Dropped), t.Name, s.Name)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package eventbus
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestTopicSubscribers(t *testing.T) {
+	b := New()
+	topic := NewTopic[int](b, "numbers")
+
+	var order []string
+	cancelA := topic.Subscribe("a", func(v int) { order = append(order, "a") })
+	topic.Subscribe("b", func(v int) { order = append(order, "b") })
+	ch, cancelCh := topic.SubscribeChan("sse", 1, -1)
+
+	topic.Publish(1) // channel full with the initial value: dropped
+	if got := <-ch; got != -1 {
+		t.Fatalf("initial value %d", got)
+	}
+	topic.Publish(2)
+	if got := <-ch; got != 2 {
+		t.Fatalf("channel got %d, want 2", got)
+	}
+	if want := []string{"a", "b", "a", "b"}; !slices.Equal(order, want) {
+		t.Fatalf("sync order %v, want %v", order, want)
+	}
+
+	cancelA()
+	cancelA() // idempotent
+	cancelCh()
+	if _, ok := <-ch; ok {
+		t.Fatal("channel open after cancel")
+	}
+	topic.Publish(3)
+	if len(order) != 5 {
+		t.Fatalf("cancelled subscriber still called: %v", order)
+	}
+
+	// A second subscriber with the same name adds to the first one's counters
+	_, cancel := topic.SubscribeChan("sse", 4)
+	topic.Publish(4)
+	cancel()
+
+	st := b.Stats()
+	if len(st) != 1 || st[0].Name != "numbers" || st[0].Published != 4 || st[0].Active != 1 {
+		t.Fatalf("topic stats %+v", st)
+	}
+	want := []SubscriberStats{
+		{Name: "a", Delivered: 2},
+		{Name: "b", Delivered: 4},
+		{Name: "sse", Delivered: 2, Dropped: 1},
+	}
+	if !slices.Equal(st[0].Subscribers, want) {
+		t.Fatalf("subscriber stats %+v, want %+v", st[0].Subscribers, want)
+	}
+}
+
+func TestBusCollector(t *testing.T) {
+	b := New()
+	topic := NewTopic[string](b, "events")
+	topic.Subscribe("log", func(string) {})
+	topic.Publish("x")
+	NewTopic[string](nil, "detached").Publish("y") // not attached: not exported
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(b)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]float64{}
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			key := mf.GetName()
+			for _, l := range m.GetLabel() { // sorted by name: subscriber, topic
+				key += " " + l.GetValue()
+			}
+			values[key] = m.GetCounter().GetValue() + m.GetGauge().GetValue()
+		}
+	}
+	want := map[string]float64{
+		"eventbus_published_total events":     1,
+		"eventbus_subscribers events":         1,
+		"eventbus_delivered_total log events": 1,
+		"eventbus_dropped_total log events":   0,
+	}
+	for k, v := range want {
+		if got, ok := values[k]; !ok || got != v {
+			t.Errorf("%s = %v (present %v), want %v", k, got, ok, v)
+		}
+	}
+	if len(values) != len(want) {
+		t.Errorf("series %v", values)
+	}
+}
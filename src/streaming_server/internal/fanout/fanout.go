@@ -0,0 +1,290 @@
+// Package fanout holds the queues between a producer (the SHM reader) and
+// its consumers. Each consumer belongs to a class that decides what happens
+// when it falls behind:
+//
+//   - RealTime (WebRTC): newest frame wins. A full queue evicts its oldest
+//     entry, so a consumer that catches up resumes with the latest frames.
+//   - Reliable (the recorder): every frame, in order. A full queue overflows
+//     into its Spill; without one the new entry is refused.
+//   - BestEffort (timing samples): loss is fine. A full queue refuses the
+//     new entry.
+//
+// Push never waits for a consumer, so a slow consumer cannot stall the
+// producer or the other consumers.
+package fanout
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Class is a consumer's queue policy.
+type Class int
+
+const (
+	RealTime Class = iota
+	Reliable
+	BestEffort
+)
+
+// String returns the class name used in stats.
+func (c Class) String() string {
+	switch c {
+	case RealTime:
+		return "realtime"
+	case Reliable:
+		return "reliable"
+	case BestEffort:
+		return "best_effort"
+	}
+	return "unknown"
+}
+
+// Spill keeps, oldest first, what a full Reliable queue cannot hold in
+// memory. The queue serializes its calls outside its own lock, so a Spill
+// may do disk I/O without holding up Stats or the other end of the queue.
+type Spill[T any] interface {
+	Put(v T) error
+	Get() (T, bool) // the oldest entry; false when empty
+	Len() int
+}
+
+// Options configure a Queue.
+type Options[T any] struct {
+	Class    Class
+	Capacity int      // entries held in memory (at least 1)
+	Release  func(T)  // frees an entry a RealTime queue evicts
+	Spill    Spill[T] // overflow of a Reliable queue (nil = refuse)
+}
+
+// Stats are a queue's occupancy and counters, as shown by /debug/pipeline.
+type Stats struct {
+	Name      string `json:"name"`
+	Class     string `json:"class"`
+	Queued    int    `json:"queued"`
+	Capacity  int    `json:"capacity"`
+	Spilled   int    `json:"spilled,omitempty"` // entries in the Spill right now
+	Pushed    uint64 `json:"pushed"`
+	Delivered uint64 `json:"delivered"`
+	Dropped   uint64 `json:"dropped"` // refused or evicted
+}
+
+// Queue is a bounded FIFO with one consumer class's overflow policy. It is
+// safe for concurrent use; entries are meant for a single consumer.
+type Queue[T any] struct {
+	name string
+	opts Options[T]
+
+	mu      sync.Mutex
+	ring    []T
+	head    int // index of the oldest entry
+	n       int // entries in ring
+	spilled int // entries in the Spill, or being written to it
+	closed  bool
+	ready   chan struct{} // holds a token while entries may be waiting
+
+	// Serializes the Spill's calls. Taken after mu, never the other way
+	// round; held without mu across the Spill's I/O.
+	spillMu sync.Mutex
+
+	pushed    atomic.Uint64
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+// NewQueue creates a queue named name (the /debug/pipeline sink name).
+func NewQueue[T any](name string, opts Options[T]) *Queue[T] {
+	opts.Capacity = max(opts.Capacity, 1)
+	return &Queue[T]{
+		name:  name,
+		opts:  opts,
+		ring:  make([]T, opts.Capacity),
+		ready: make(chan struct{}, 1),
+	}
+}
+
+// Push queues v without waiting. It returns false if v was refused (a full
+// Reliable queue without room in its Spill, a full BestEffort queue, or a
+// closed queue); v then still belongs to the caller. A RealTime queue always
+// takes v, releasing its oldest entry if full.
+func (q *Queue[T]) Push(v T) bool {
+	q.pushed.Add(1)
+	q.mu.Lock()
+	var evicted T
+	evict := false
+	switch {
+	case q.closed:
+		q.mu.Unlock()
+		q.dropped.Add(1)
+		return false
+	case q.opts.Class == Reliable && q.opts.Spill != nil && (q.n == len(q.ring) || q.spilled > 0):
+		// Once anything is spilled, newer entries queue behind it
+		return q.spill(v)
+	case q.n < len(q.ring):
+		q.put(v)
+	case q.opts.Class == RealTime:
+		evicted, evict = q.take(), true
+		q.put(v)
+	default:
+		q.mu.Unlock()
+		q.dropped.Add(1)
+		return false
+	}
+	q.mu.Unlock()
+
+	q.signal()
+	if evict {
+		q.dropped.Add(1)
+		if q.opts.Release != nil {
+			q.opts.Release(evicted)
+		}
+	}
+	return true
+}
+
+// spill writes v to the Spill. Called with q.mu held, which it releases:
+// the slot is taken under q.mu, the write happens after, with spillMu
+// taken first so concurrent Pushes spill in the order they took slots.
+func (q *Queue[T]) spill(v T) bool {
+	q.spilled++
+	q.spillMu.Lock()
+	q.mu.Unlock()
+	err := q.opts.Spill.Put(v)
+	q.spillMu.Unlock()
+	if err != nil {
+		q.mu.Lock()
+		q.spilled--
+		q.mu.Unlock()
+		q.dropped.Add(1)
+		return false
+	}
+	q.signal()
+	return true
+}
+
+// Pop returns the oldest entry, waiting for one. It returns false when ctx
+// is done, or when the queue is closed and empty.
+func (q *Queue[T]) Pop(ctx context.Context) (T, bool) {
+	for {
+		v, ok, closed := q.next()
+		if ok || closed {
+			return v, ok
+		}
+		select {
+		case <-ctx.Done():
+			var zero T
+			return zero, false
+		case <-q.ready:
+		}
+	}
+}
+
+// TryPop returns the oldest entry without waiting.
+func (q *Queue[T]) TryPop() (T, bool) {
+	v, ok, _ := q.next()
+	return v, ok
+}
+
+// next takes the oldest entry: memory first, then the Spill (which only
+// holds entries newer than everything in memory).
+func (q *Queue[T]) next() (v T, ok, closed bool) {
+	q.mu.Lock()
+	fromSpill := q.n == 0 && q.spilled > 0
+	if q.n > 0 {
+		v, ok = q.take(), true
+	}
+	q.mu.Unlock()
+	if fromSpill {
+		v, ok = q.unspill()
+	}
+
+	q.mu.Lock()
+	more := q.n > 0 || q.spilled > 0
+	closed = q.closed
+	q.mu.Unlock()
+
+	if ok {
+		q.delivered.Add(1)
+		if more {
+			q.signal() // another consumer may be waiting
+		}
+	}
+	return v, ok, closed && !ok
+}
+
+// unspill reads the oldest entry back from the Spill. Entries the Spill
+// lost (e.g. to a read error) count as dropped.
+func (q *Queue[T]) unspill() (T, bool) {
+	q.spillMu.Lock()
+	before := q.opts.Spill.Len()
+	v, ok := q.opts.Spill.Get()
+	gone := before - q.opts.Spill.Len()
+	q.spillMu.Unlock()
+
+	q.mu.Lock()
+	q.spilled -= gone
+	q.mu.Unlock()
+	if ok {
+		gone--
+	}
+	if gone > 0 {
+		q.dropped.Add(uint64(gone))
+	}
+	return v, ok
+}
+
+// Close refuses further entries; Pop returns what is queued, then false.
+func (q *Queue[T]) Close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.signal()
+}
+
+// Len returns the entries waiting, including spilled ones.
+func (q *Queue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.n + q.spilled
+}
+
+// Stats returns the queue's occupancy and counters.
+func (q *Queue[T]) Stats() Stats {
+	q.mu.Lock()
+	st := Stats{
+		Name:     q.name,
+		Class:    q.opts.Class.String(),
+		Queued:   q.n,
+		Capacity: len(q.ring),
+		Spilled:  q.spilled,
+	}
+	q.mu.Unlock()
+	st.Pushed = q.pushed.Load()
+	st.Delivered = q.delivered.Load()
+	st.Dropped = q.dropped.Load()
+	return st
+}
+
+// put appends v. Caller holds q.mu and has checked there is room.
+func (q *Queue[T]) put(v T) {
+	q.ring[(q.head+q.n)%len(q.ring)] = v
+	q.n++
+}
+
+// take removes the oldest entry. Caller holds q.mu and has checked q.n > 0.
+func (q *Queue[T]) take() T {
+	var zero T
+	v := q.ring[q.head]
+	q.ring[q.head] = zero
+	q.head = (q.head + 1) % len(q.ring)
+	q.n--
+	return v
+}
+
+func (q *Queue[T]) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
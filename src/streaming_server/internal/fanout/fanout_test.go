@@ -0,0 +1,192 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// memSpill is an unbounded in-memory Spill that can be made to fail, lose
+// its entries, or stall its writes.
+type memSpill struct {
+	items   []int
+	full    bool
+	lose    bool          // Get fails, discarding every entry
+	writing chan struct{} // Put waits for a receive
+}
+
+func (s *memSpill) Put(v int) error {
+	if s.writing != nil {
+		s.writing <- struct{}{}
+	}
+	if s.full {
+		return errors.New("spill full")
+	}
+	s.items = append(s.items, v)
+	return nil
+}
+
+func (s *memSpill) Get() (int, bool) {
+	if s.lose {
+		s.items = nil
+	}
+	if len(s.items) == 0 {
+		return 0, false
+	}
+	v := s.items[0]
+	s.items = s.items[1:]
+	return v, true
+}
+
+func (s *memSpill) Len() int { return len(s.items) }
+
+func drain(q *Queue[int]) []int {
+	var out []int
+	for {
+		v, ok := q.TryPop()
+		if !ok {
+			return out
+		}
+		out = append(out, v)
+	}
+}
+
+func TestQueueClasses(t *testing.T) {
+	var released []int
+	rt := NewQueue("webrtc", Options[int]{Class: RealTime, Capacity: 2, Release: func(v int) { released = append(released, v) }})
+	be := NewQueue("timing", Options[int]{Class: BestEffort, Capacity: 2})
+	rel := NewQueue("recorder", Options[int]{Class: Reliable, Capacity: 2})
+	for v := 1; v <= 4; v++ {
+		if !rt.Push(v) {
+			t.Errorf("realtime refused %d", v)
+		}
+		if be.Push(v) != (v <= 2) || rel.Push(v) != (v <= 2) {
+			t.Errorf("push %d: wrong refusal", v)
+		}
+	}
+
+	// Newest frame wins; the lossy classes keep the oldest
+	if got := drain(rt); !slices.Equal(got, []int{3, 4}) || !slices.Equal(released, []int{1, 2}) {
+		t.Errorf("realtime got %v, released %v", got, released)
+	}
+	if got := drain(be); !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("best effort got %v", got)
+	}
+	st := rel.Stats()
+	if st.Class != "reliable" || st.Queued != 2 || st.Pushed != 4 || st.Dropped != 2 {
+		t.Errorf("reliable stats %+v", st)
+	}
+}
+
+func TestQueueSpillKeepsOrder(t *testing.T) {
+	spill := &memSpill{}
+	q := NewQueue("recorder", Options[int]{Class: Reliable, Capacity: 2, Spill: spill})
+	for v := 1; v <= 4; v++ {
+		q.Push(v)
+	}
+	if q.Len() != 4 || spill.Len() != 2 {
+		t.Fatalf("len %d, spilled %d", q.Len(), spill.Len())
+	}
+
+	// Room in memory again, but 5 must still wait behind the spilled 3 and 4
+	q.TryPop()
+	q.Push(5)
+	spill.full = true
+	if q.Push(6) {
+		t.Error("accepted a frame the spill could not take")
+	}
+	if got := drain(q); !slices.Equal(got, []int{2, 3, 4, 5}) {
+		t.Errorf("order %v", got)
+	}
+	if st := q.Stats(); st.Delivered != 5 || st.Dropped != 1 || st.Spilled != 0 {
+		t.Errorf("stats %+v", st)
+	}
+}
+
+func TestQueueSpillWritesOutsideLock(t *testing.T) {
+	spill := &memSpill{writing: make(chan struct{})}
+	q := NewQueue("recorder", Options[int]{Class: Reliable, Capacity: 1, Spill: spill})
+	q.Push(1)
+	pushed := make(chan bool)
+	go func() { pushed <- q.Push(2) }()
+
+	// The write is stalled, yet the queue answers and hands out memory
+	<-spill.writing
+	if st := q.Stats(); st.Queued != 1 || st.Spilled != 1 {
+		t.Errorf("stats during the write %+v", st)
+	}
+	if v, ok := q.TryPop(); !ok || v != 1 {
+		t.Errorf("pop during the write: %d %v", v, ok)
+	}
+	close(spill.writing)
+	if !<-pushed {
+		t.Fatal("spill refused")
+	}
+	if v, ok := q.Pop(context.Background()); !ok || v != 2 {
+		t.Errorf("spilled entry: %d %v", v, ok)
+	}
+}
+
+func TestQueueSpillLoss(t *testing.T) {
+	spill := &memSpill{}
+	q := NewQueue("recorder", Options[int]{Class: Reliable, Capacity: 1, Spill: spill})
+	for v := 1; v <= 3; v++ {
+		q.Push(v)
+	}
+	spill.lose = true
+	if got := drain(q); !slices.Equal(got, []int{1}) {
+		t.Errorf("got %v", got)
+	}
+	// Nothing is left spilled, so memory takes entries again
+	spill.lose = false
+	q.Push(4)
+	if st := q.Stats(); st.Queued != 1 || st.Spilled != 0 || st.Dropped != 2 || q.Len() != 1 {
+		t.Errorf("stats after the loss %+v", st)
+	}
+}
+
+func TestQueuePopAndClose(t *testing.T) {
+	q := NewQueue("timing", Options[int]{Class: BestEffort, Capacity: 4})
+	got := make(chan int)
+	go func() {
+		for {
+			v, ok := q.Pop(context.Background())
+			if !ok {
+				close(got)
+				return
+			}
+			got <- v
+		}
+	}()
+	q.Push(1)
+	select {
+	case v := <-got:
+		if v != 1 {
+			t.Errorf("popped %d", v)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Pop did not wake up")
+	}
+
+	// Queued entries are still delivered after Close
+	q.Push(2)
+	q.Close()
+	if q.Push(3) {
+		t.Error("closed queue accepted an entry")
+	}
+	var rest []int
+	for v := range got {
+		rest = append(rest, v)
+	}
+	if !slices.Equal(rest, []int{2}) {
+		t.Errorf("after close %v", rest)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, ok := NewQueue[int]("x", Options[int]{}).Pop(ctx); ok {
+		t.Error("Pop returned from an empty queue")
+	}
+}
@@ -4,13 +4,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
-	"strings"
 	"testing"
 	"time"
+
+	apiclient "github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/client"
 )
 
 const (
@@ -21,6 +23,7 @@ const (
 type specClient struct {
 	baseURL string
 	client  *http.Client
+	api     *apiclient.Client
 }
 
 func newSpecClient(t *testing.T) *specClient {
@@ -35,9 +38,13 @@ func newSpecClient(t *testing.T) *specClient {
 		t.Skipf("spec server not reachable at %s (set SPEC_BASE_URL to run)", baseURL)
 	}
 
+	api := apiclient.New(baseURL)
+	api.Timeout = defaultRequestTimeout
+
 	return &specClient{
 		baseURL: baseURL,
 		client:  client,
+		api:     api,
 	}
 }
 
@@ -108,60 +115,22 @@ func (c *specClient) postJSON(t *testing.T, path string, payload any) (*http.Res
 	return resp, body
 }
 
-func readSSEEvent(url string, timeout time.Duration) (string, http.Header, error) {
+// readSSEEvent returns the data of the first event on the stream at path;
+// the client rejects answers that are not text/event-stream.
+func (c *specClient) readSSEEvent(path string, timeout time.Duration) ([]byte, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return "", nil, fmt.Errorf("build request: %w", err)
-	}
-	client := &http.Client{Timeout: timeout}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", nil, fmt.Errorf("request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	buf := make([]byte, 0, 4096)
-	tmp := make([]byte, 256)
-	for {
-		n, readErr := resp.Body.Read(tmp)
-		if n > 0 {
-			buf = append(buf, tmp[:n]...)
-			if idx := bytes.Index(buf, []byte("\n\n")); idx >= 0 {
-				event := string(buf[:idx])
-				return event, resp.Header, nil
-			}
-		}
-		if readErr != nil {
-			if readErr == io.EOF {
-				return "", nil, fmt.Errorf("sse stream closed before event")
-			}
-			return "", nil, fmt.Errorf("read sse: %w", readErr)
-		}
-		select {
-		case <-ctx.Done():
-			return "", nil, fmt.Errorf("timeout waiting for sse event")
-		default:
-		}
-	}
-}
-
-func parseSSEData(t *testing.T, event string) map[string]any {
-	t.Helper()
-	lines := strings.Split(event, "\n")
-	for _, line := range lines {
-		if strings.HasPrefix(line, "data:") {
-			payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
-			if payload == "" {
-				t.Fatalf("empty sse data line")
-			}
-			return decodeJSONMap(t, []byte(payload))
-		}
+	var data []byte
+	errGot := errors.New("got event")
+	err := c.api.Stream(ctx, path, func(ev apiclient.SSEEvent) error {
+		data = ev.Data
+		return errGot
+	})
+	if !errors.Is(err, errGot) {
+		return nil, err
 	}
-	t.Fatalf("no data line in sse event: %q", event)
-	return nil
+	return data, nil
 }
 
 func decodeJSONMap(t *testing.T, body []byte) map[string]any {
@@ -1,9 +1,12 @@
 package flaskcompat
 
 import (
+	"encoding/json"
 	"net/http"
 	"os"
 	"testing"
+
+	apiclient "github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/client"
 )
 
 func TestFlaskCompatRecordingLifecycle(t *testing.T) {
@@ -13,47 +16,60 @@ func TestFlaskCompatRecordingLifecycle(t *testing.T) {
 	client := newSpecClient(t)
 
 	resp, body := client.get(t, "/api/recording/status")
-	if resp.StatusCode != http.StatusOK {
-		t.Fatalf("GET /api/recording/status status = %d", resp.StatusCode)
-	}
-	payload := decodeJSONMap(t, body)
+	var status apiclient.RecorderStatus
+	payload := decodeRecordingResponse(t, "GET /api/recording/status", resp, body, &status)
 	if payload["recording"] == nil {
 		t.Fatalf("recording status missing 'recording'")
 	}
 
 	resp, body = client.postJSON(t, "/api/recording/start", map[string]any{})
-	if resp.StatusCode != http.StatusOK {
-		t.Fatalf("POST /api/recording/start status = %d", resp.StatusCode)
+	var started apiclient.RecordingStarted
+	payload = decodeRecordingResponse(t, "POST /api/recording/start", resp, body, &started)
+	requireString(t, payload["status"], "status")
+	requireString(t, payload["file"], "file")
+	requireNumber(t, payload["started_at"], "started_at")
+	if started.Status != "recording" {
+		t.Fatalf("start status = %q", started.Status)
 	}
-	startPayload := decodeJSONMap(t, body)
-	status := requireString(t, startPayload["status"], "status")
-	if status != "recording" {
-		t.Fatalf("start status = %q", status)
+	if started.File == "" || started.StartedAt <= 0 {
+		t.Fatalf("start payload %+v", started)
 	}
-	requireString(t, startPayload["file"], "file")
-	requireNumber(t, startPayload["started_at"], "started_at")
 
 	resp, body = client.get(t, "/api/recording/status")
-	if resp.StatusCode != http.StatusOK {
-		t.Fatalf("GET /api/recording/status status = %d", resp.StatusCode)
+	payload = decodeRecordingResponse(t, "GET /api/recording/status", resp, body, &status)
+	requireNumber(t, payload["frame_count"], "frame_count")
+	requireNumber(t, payload["bytes_written"], "bytes_written")
+	if !status.Recording {
+		t.Fatalf("recording status expected true, got %+v", status)
 	}
-	statusPayload := decodeJSONMap(t, body)
-	if statusPayload["recording"] != true {
-		t.Fatalf("recording status expected true, got %v", statusPayload["recording"])
-	}
-	requireNumber(t, statusPayload["frame_count"], "frame_count")
-	requireNumber(t, statusPayload["bytes_written"], "bytes_written")
 
 	resp, body = client.postJSON(t, "/api/recording/stop", map[string]any{})
+	var stopped apiclient.RecordingStopped
+	payload = decodeRecordingResponse(t, "POST /api/recording/stop", resp, body, &stopped)
+	requireString(t, payload["status"], "status")
+	requireString(t, payload["file"], "file")
+	requireNumber(t, payload["stopped_at"], "stopped_at")
+	stats := requireMap(t, payload["stats"], "stats")
+	requireNumber(t, stats["frame_count"], "stats.frame_count")
+	requireNumber(t, stats["bytes_written"], "stats.bytes_written")
+	if stopped.Status != "stopped" {
+		t.Fatalf("stop status = %q", stopped.Status)
+	}
+	if stopped.File == "" || stopped.StoppedAt <= 0 {
+		t.Fatalf("stop payload %+v", stopped)
+	}
+}
+
+// decodeRecordingResponse decodes body both into the client type v and as
+// raw JSON: the typed structs zero-fill missing keys, so the key checks of
+// the compat contract run on the returned map.
+func decodeRecordingResponse(t *testing.T, what string, resp *http.Response, body []byte, v any) map[string]any {
+	t.Helper()
 	if resp.StatusCode != http.StatusOK {
-		t.Fatalf("POST /api/recording/stop status = %d", resp.StatusCode)
+		t.Fatalf("%s status = %d", what, resp.StatusCode)
 	}
-	stopPayload := decodeJSONMap(t, body)
-	stopStatus := requireString(t, stopPayload["status"], "status")
-	if stopStatus != "stopped" {
-		t.Fatalf("stop status = %q", stopStatus)
+	if err := json.Unmarshal(body, v); err != nil {
+		t.Fatalf("%s: decode %T: %v\nbody=%s", what, v, err, body)
 	}
-	requireString(t, stopPayload["file"], "file")
-	requireNumber(t, stopPayload["stopped_at"], "stopped_at")
-	requireMap(t, stopPayload["stats"], "stats")
+	return decodeJSONMap(t, body)
 }
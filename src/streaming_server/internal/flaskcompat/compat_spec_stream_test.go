@@ -1,10 +1,13 @@
 package flaskcompat
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 	"testing"
 	"time"
+
+	apiclient "github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/client"
 )
 
 func TestFlaskCompatMJPEGStream(t *testing.T) {
@@ -24,26 +27,23 @@ func TestFlaskCompatMJPEGStream(t *testing.T) {
 
 func TestFlaskCompatStatusStream(t *testing.T) {
 	client := newSpecClient(t)
-	event, headers, err := readSSEEvent(client.baseURL+"/api/status/stream", 3*time.Second)
+	data, err := client.readSSEEvent("/api/status/stream", 3*time.Second)
 	if err != nil {
 		t.Fatalf("status stream error: %v", err)
 	}
-	if !strings.Contains(headers.Get("Content-Type"), "text/event-stream") {
-		t.Fatalf("status stream content-type = %q", headers.Get("Content-Type"))
-	}
-	payload := parseSSEData(t, event)
+	payload := decodeJSONMap(t, data)
 	assertStatusPayload(t, payload)
 }
 
 func TestFlaskCompatDetectionsStream(t *testing.T) {
 	client := newSpecClient(t)
-	event, headers, err := readSSEEvent(client.baseURL+"/api/detections/stream", 3*time.Second)
+	data, err := client.readSSEEvent("/api/detections/stream", 3*time.Second)
+	if errors.Is(err, apiclient.ErrNotEventStream) {
+		t.Fatalf("detections stream: %v", err)
+	}
 	if err != nil {
 		t.Skipf("detections stream unavailable: %v", err)
 	}
-	if !strings.Contains(headers.Get("Content-Type"), "text/event-stream") {
-		t.Fatalf("detections stream content-type = %q", headers.Get("Content-Type"))
-	}
-	payload := parseSSEData(t, event)
+	payload := decodeJSONMap(t, data)
 	assertDetectionPayload(t, payload)
 }
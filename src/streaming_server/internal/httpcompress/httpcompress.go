@@ -0,0 +1,332 @@
+// Package httpcompress compresses the JSON responses and SSE streams of the
+// streaming server and the web monitor with gzip or deflate, as negotiated
+// through Accept-Encoding. Video, images and anything else are passed
+// through untouched.
+package httpcompress
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultMinSize is the body size below which a response that is not
+// flushed early stays uncompressed: the headers of a small JSON reply
+// outweigh what compression saves.
+const DefaultMinSize = 512
+
+// Encodings offered, in order of preference.
+const (
+	Gzip    = "gzip"
+	Deflate = "deflate"
+)
+
+// Middleware compresses responses. It is a prometheus.Collector; register
+// it with the server's metrics registry.
+type Middleware struct {
+	// Enabled turns compression on; a disabled middleware passes every
+	// response through.
+	Enabled bool
+	// MinSize is the smallest body worth compressing (see DefaultMinSize).
+	// Streams are compressed from their first flush whatever their size.
+	MinSize int
+
+	responses *prometheus.CounterVec
+	bytesIn   *prometheus.CounterVec
+	bytesOut  *prometheus.CounterVec
+
+	gzipPool  sync.Pool
+	flatePool sync.Pool
+}
+
+// New creates an enabled middleware whose metrics carry server="<server>".
+func New(server string) *Middleware {
+	labels := prometheus.Labels{"server": server}
+	return &Middleware{
+		Enabled: true,
+		MinSize: DefaultMinSize,
+		responses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "http_compressed_responses_total",
+			Help:        "Responses sent compressed, by encoding",
+			ConstLabels: labels,
+		}, []string{"encoding"}),
+		bytesIn: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "http_compression_input_bytes_total",
+			Help:        "Body bytes of compressed responses before compression, by encoding",
+			ConstLabels: labels,
+		}, []string{"encoding"}),
+		bytesOut: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "http_compression_output_bytes_total",
+			Help:        "Body bytes of compressed responses as sent, by encoding",
+			ConstLabels: labels,
+		}, []string{"encoding"}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *Middleware) Describe(ch chan<- *prometheus.Desc) {
+	m.responses.Describe(ch)
+	m.bytesIn.Describe(ch)
+	m.bytesOut.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (m *Middleware) Collect(ch chan<- prometheus.Metric) {
+	m.responses.Collect(ch)
+	m.bytesIn.Collect(ch)
+	m.bytesOut.Collect(ch)
+}
+
+// Wrap returns next with response compression. A nil Middleware returns
+// next unchanged.
+func (m *Middleware) Wrap(next http.Handler) http.Handler {
+	if m == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := Negotiate(r.Header.Get("Accept-Encoding"))
+		if !m.Enabled || encoding == "" || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &responseWriter{ResponseWriter: w, m: m, encoding: encoding}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// Negotiate picks the encoding to answer an Accept-Encoding header with:
+// gzip, deflate, or "" for none. Quality values are honored; q=0 refuses
+// an encoding, and ties go to gzip.
+func Negotiate(accept string) string {
+	best, bestQ := "", 0.0
+	star := -1.0
+	q := map[string]float64{}
+	for part := range strings.SplitSeq(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		weight := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			weight = f
+		}
+		if name == "*" {
+			star = weight
+			continue
+		}
+		q[name] = weight
+	}
+	for _, enc := range []string{Gzip, Deflate} {
+		weight, ok := q[enc]
+		if !ok {
+			weight = max(star, 0)
+		}
+		if weight > bestQ {
+			best, bestQ = enc, weight
+		}
+	}
+	return best
+}
+
+// compressible reports whether a response of content type ct is compressed:
+// JSON, NDJSON and server-sent events.
+func compressible(ct string) bool {
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	return mt == "application/json" || mt == "text/event-stream" || mt == "application/x-ndjson" ||
+		strings.HasSuffix(mt, "+json")
+}
+
+// compressor is a gzip or flate writer.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+func (m *Middleware) getCompressor(encoding string, w io.Writer) compressor {
+	if encoding == Gzip {
+		if z, ok := m.gzipPool.Get().(*gzip.Writer); ok {
+			z.Reset(w)
+			return z
+		}
+		return gzip.NewWriter(w)
+	}
+	if z, ok := m.flatePool.Get().(*flate.Writer); ok {
+		z.Reset(w)
+		return z
+	}
+	z, _ := flate.NewWriter(w, flate.DefaultCompression)
+	return z
+}
+
+func (m *Middleware) putCompressor(encoding string, z compressor) {
+	if encoding == Gzip {
+		m.gzipPool.Put(z)
+	} else {
+		m.flatePool.Put(z)
+	}
+}
+
+// responseWriter decides at the first write whether to compress: only a
+// compressible, complete (2xx but 206, or an error) response that does not
+// carry its own Content-Encoding. Small bodies are held until MinSize is
+// reached, the handler flushes or returns.
+type responseWriter struct {
+	http.ResponseWriter
+	m        *Middleware
+	encoding string
+
+	status  int
+	decided bool
+	z       compressor   // nil: passing through
+	counter *countWriter // bytes the compressor wrote
+	in      int64
+	buf     []byte // held until the decision
+}
+
+// countWriter counts what the compressor writes to the client.
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if code >= 100 && code < 200 {
+		w.ResponseWriter.WriteHeader(code) // informational: the real one follows
+		return
+	}
+	if w.status != 0 {
+		return
+	}
+	w.status = code
+	if code == http.StatusNoContent || code == http.StatusNotModified || code == http.StatusPartialContent || !w.eligible() {
+		w.decide(false)
+	}
+}
+
+// eligible reports whether the headers allow compressing the response.
+func (w *responseWriter) eligible() bool {
+	h := w.Header()
+	return h.Get("Content-Encoding") == "" && h.Get("Content-Range") == "" && compressible(h.Get("Content-Type"))
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) >= w.m.MinSize {
+			if err := w.decide(true); err != nil {
+				return 0, err
+			}
+		}
+		return len(b), nil
+	}
+	if w.z == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	w.in += int64(len(b))
+	return w.z.Write(b)
+}
+
+// decide starts the response, compressed if compress is set and the
+// headers allow it, and writes what was held.
+func (w *responseWriter) decide(compress bool) error {
+	if w.decided {
+		return nil
+	}
+	w.decided = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	h := w.Header()
+	if compressible(h.Get("Content-Type")) {
+		h.Add("Vary", "Accept-Encoding")
+	}
+	if compress && w.eligible() {
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+		w.counter = &countWriter{w: w.ResponseWriter}
+		w.z = w.m.getCompressor(w.encoding, w.counter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// Flush sends what was written so far: SSE events go out as they are
+// written, compressed or not.
+func (w *responseWriter) Flush() {
+	if !w.decided {
+		w.decide(true)
+	}
+	if w.z != nil {
+		w.z.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// close finishes the response once the handler returned.
+func (w *responseWriter) close() {
+	if !w.decided {
+		if w.status == 0 && len(w.buf) == 0 {
+			return // nothing written: net/http answers 200 with no body
+		}
+		w.decide(false)
+	}
+	if w.z == nil {
+		return
+	}
+	w.z.Close()
+	w.m.putCompressor(w.encoding, w.z)
+	w.z = nil
+	w.m.responses.WithLabelValues(w.encoding).Inc()
+	w.m.bytesIn.WithLabelValues(w.encoding).Add(float64(w.in))
+	w.m.bytesOut.WithLabelValues(w.encoding).Add(float64(w.counter.n))
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	w.decided = true
+	return hj.Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,167 @@
+package httpcompress
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestNegotiate(t *testing.T) {
+	for accept, want := range map[string]string{
+		"":                          "",
+		"identity":                  "",
+		"gzip, deflate, br":         Gzip,
+		"deflate":                   Deflate,
+		"gzip;q=0.5, deflate":       Deflate,
+		"GZIP;q=0.8":                Gzip,
+		"gzip;q=0, deflate;q=0":     "",
+		"*":                         Gzip,
+		"*;q=0.3, gzip;q=0":         Deflate,
+		"br, *;q=0":                 "",
+		"gzip;q=nope, deflate;q=.1": Deflate,
+	} {
+		if got := Negotiate(accept); got != want {
+			t.Errorf("Negotiate(%q) = %q, want %q", accept, got, want)
+		}
+	}
+}
+
+func get(h http.Handler, path, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if accept != "" {
+		req.Header.Set("Accept-Encoding", accept)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddleware(t *testing.T) {
+	status := `{"fps":30,"detections":[` + strings.Repeat(`{"label":"cat"},`, 100) + `{}]}`
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, status)
+	})
+	mux.HandleFunc("/api/small", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"ok":true}`)
+	})
+	mux.HandleFunc("/snapshot.jpg", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write(make([]byte, 4096))
+	})
+	mux.HandleFunc("/api/gone", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	m := New("test")
+	h := m.Wrap(mux)
+
+	for _, enc := range []string{Gzip, Deflate} {
+		rec := get(h, "/api/status", enc)
+		if rec.Header().Get("Content-Encoding") != enc || rec.Header().Get("Vary") != "Accept-Encoding" {
+			t.Fatalf("%s: headers %v", enc, rec.Header())
+		}
+		var r io.Reader
+		if enc == Gzip {
+			zr, err := gzip.NewReader(rec.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r = zr
+		} else {
+			r = flate.NewReader(rec.Body)
+		}
+		body, err := io.ReadAll(r)
+		if err != nil || string(body) != status {
+			t.Errorf("%s: body %q, %v", enc, body, err)
+		}
+	}
+
+	// Passed through: no Accept-Encoding, too small, not JSON, no body
+	for _, tc := range []struct{ path, accept string }{
+		{"/api/status", ""},
+		{"/api/small", "gzip"},
+		{"/snapshot.jpg", "gzip"},
+		{"/api/gone", "gzip"},
+	} {
+		rec := get(h, tc.path, tc.accept)
+		if enc := rec.Header().Get("Content-Encoding"); enc != "" {
+			t.Errorf("%s (%q) compressed with %s", tc.path, tc.accept, enc)
+		}
+	}
+	if rec := get(h, "/api/small", "gzip"); rec.Body.String() != `{"ok":true}` {
+		t.Errorf("small body %q", rec.Body.String())
+	}
+
+	m.Enabled = false
+	if rec := get(h, "/api/status", "gzip"); rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != status {
+		t.Error("disabled middleware compressed")
+	}
+
+	if n := testutil.ToFloat64(m.responses.WithLabelValues(Gzip)); n != 1 {
+		t.Errorf("gzip responses %v", n)
+	}
+	in, out := testutil.ToFloat64(m.bytesIn.WithLabelValues(Gzip)), testutil.ToFloat64(m.bytesOut.WithLabelValues(Gzip))
+	if in != float64(len(status)) || out <= 0 || out >= in {
+		t.Errorf("gzip bytes in %v out %v", in, out)
+	}
+	if n, err := testutil.GatherAndCount(registry(m), "http_compressed_responses_total"); err != nil || n != 2 {
+		t.Errorf("registered series %d, %v", n, err)
+	}
+}
+
+func registry(m *Middleware) *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(m)
+	return reg
+}
+
+func TestMiddlewareStreamsEvents(t *testing.T) {
+	events := make(chan string)
+	h := New("test").Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		for ev := range events {
+			io.WriteString(w, "data: "+ev+"\n\n")
+			w.(http.Flusher).Flush()
+		}
+	}))
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	defer close(events)
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Accept-Encoding", "gzip") // set by hand: the transport leaves the body compressed
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Encoding") != Gzip {
+		t.Fatalf("headers %v", resp.Header)
+	}
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bufio.NewReader(zr)
+	// Each event arrives on its flush, long before MinSize
+	for _, ev := range []string{"one", "two"} {
+		events <- ev
+		line, err := lines.ReadString('\n')
+		if err != nil || line != "data: "+ev+"\n" {
+			t.Fatalf("event %q: %q, %v", ev, line, err)
+		}
+		lines.ReadString('\n')
+	}
+}
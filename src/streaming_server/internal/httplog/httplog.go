@@ -0,0 +1,136 @@
+// Package httplog provides the HTTP access log and per-route latency
+// histograms shared by the streaming server and the web monitor.
+package httplog
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Middleware logs every request and observes its duration per route. It is
+// a prometheus.Collector; register it with the server's metrics registry.
+type Middleware struct {
+	// Log enables the access log lines (metrics are always collected).
+	Log bool
+
+	duration *prometheus.HistogramVec
+}
+
+// New creates a middleware whose metrics carry server="<server>".
+func New(server string) *Middleware {
+	return &Middleware{
+		Log: true,
+		duration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:        "http_request_duration_seconds",
+				Help:        "HTTP request duration by route pattern, method and status (streams observe their whole lifetime)",
+				ConstLabels: prometheus.Labels{"server": server},
+				Buckets:     []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 10, 60, 600},
+			},
+			[]string{"route", "method", "code"},
+		),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *Middleware) Describe(ch chan<- *prometheus.Desc) { m.duration.Describe(ch) }
+
+// Collect implements prometheus.Collector.
+func (m *Middleware) Collect(ch chan<- prometheus.Metric) { m.duration.Collect(ch) }
+
+// Wrap returns next with access logging and metrics. A nil Middleware
+// returns next unchanged.
+func (m *Middleware) Wrap(next http.Handler) http.Handler {
+	if m == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w}
+		next.ServeHTTP(rw, r)
+		elapsed := time.Since(start)
+
+		status := rw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		// ServeMux records the matched pattern on the request, which keeps
+		// the label set bounded (/api/recordings/{file} is one route).
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		m.duration.WithLabelValues(route, r.Method, strconv.Itoa(status)).Observe(elapsed.Seconds())
+
+		if m.Log {
+			logger.Info("Access", "method=%s path=%s status=%d bytes=%d duration=%s remote=%s token=%s",
+				r.Method, r.URL.Path, status, rw.bytes, elapsed.Round(time.Microsecond), r.RemoteAddr, TokenID(r))
+		}
+	})
+}
+
+// TokenID identifies the bearer token of a request without logging it: the
+// first 8 hex digits of its SHA-256, or "-" without one.
+func TokenID(r *http.Request) string {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return "-"
+	}
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:4])
+}
+
+// responseWriter records the status and body size. It keeps Flush and
+// Hijack working for SSE, MJPEG and WebSocket handlers.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return hj.Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,58 @@
+package httplog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMiddlewareRoutesAndStatus(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/recordings/{name}", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Flusher); !ok {
+			t.Error("Flusher lost by the wrapper")
+		}
+		http.Error(w, "gone", http.StatusGone)
+	})
+	m := New("test")
+	m.Log = false
+	h := m.Wrap(mux)
+
+	for _, name := range []string{"a.mp4", "b.mp4"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/recordings/"+name, nil))
+	}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/nope", nil))
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(m)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]uint64{}
+	for _, mf := range families {
+		for _, metric := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range metric.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			counts[labels["route"]+" "+labels["code"]] = metric.GetHistogram().GetSampleCount()
+		}
+	}
+	if counts["/api/recordings/{name} 410"] != 2 || counts["unmatched 404"] != 1 {
+		t.Fatalf("samples by route: %v", counts)
+	}
+}
+
+func TestTokenID(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	if id := TokenID(r); id != "-" {
+		t.Fatalf("no token: %q", id)
+	}
+	r.Header.Set("Authorization", "Bearer s3cret")
+	if id := TokenID(r); len(id) != 8 || id == "s3cret" {
+		t.Fatalf("token id %q", id)
+	}
+}
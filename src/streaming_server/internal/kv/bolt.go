@@ -0,0 +1,142 @@
+package kv
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Bolt is a Store in a BoltDB file. Every Put is its own fsynced
+// transaction; settings change rarely.
+type Bolt struct {
+	db *bolt.DB
+}
+
+// OpenBolt opens (or creates) the database at path. A second process
+// holding it makes OpenBolt fail after a second instead of waiting.
+func OpenBolt(path string) (*Bolt, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	return &Bolt{db: db}, nil
+}
+
+func (b *Bolt) Get(bucket, key string) ([]byte, error) {
+	var v []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bk := tx.Bucket([]byte(bucket))
+		if bk == nil {
+			return ErrNotFound
+		}
+		if v = bk.Get([]byte(key)); v == nil {
+			return ErrNotFound
+		}
+		v = slices.Clone(v) // only valid inside the transaction
+		return nil
+	})
+	return v, err
+}
+
+func (b *Bolt) Put(bucket, key string, value []byte) error {
+	if err := checkPut(bucket, key, value); err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bk, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		return bk.Put([]byte(key), value)
+	})
+}
+
+func (b *Bolt) Delete(bucket, key string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		if bk := tx.Bucket([]byte(bucket)); bk != nil {
+			return bk.Delete([]byte(key))
+		}
+		return nil
+	})
+}
+
+func (b *Bolt) Keys(bucket string) ([]string, error) {
+	var keys []string
+	err := b.db.View(func(tx *bolt.Tx) error {
+		if bk := tx.Bucket([]byte(bucket)); bk != nil {
+			return bk.ForEach(func(k, _ []byte) error {
+				keys = append(keys, string(k))
+				return nil
+			})
+		}
+		return nil
+	})
+	return keys, err
+}
+
+func (b *Bolt) Snapshot() (Snapshot, error) {
+	snap := make(Snapshot)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, bk *bolt.Bucket) error {
+			if string(name) == MetaBucket {
+				return nil
+			}
+			docs := make(map[string]json.RawMessage)
+			bk.ForEach(func(k, v []byte) error {
+				docs[string(k)] = slices.Clone(v)
+				return nil
+			})
+			if len(docs) > 0 {
+				snap[string(name)] = docs
+			}
+			return nil
+		})
+	})
+	return snap, err
+}
+
+func (b *Bolt) Restore(snap Snapshot) error {
+	if err := snap.Validate(); err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		var old [][]byte
+		tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			if string(name) != MetaBucket {
+				old = append(old, slices.Clone(name))
+			}
+			return nil
+		})
+		for _, name := range old {
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+		}
+		for bucket, docs := range snap {
+			bk, err := tx.CreateBucket([]byte(bucket))
+			if err != nil {
+				return err
+			}
+			for key, v := range docs {
+				if err := bk.Put([]byte(key), v); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (b *Bolt) Close() error { return b.db.Close() }
+
+func (b *Bolt) String() string { return "bolt " + b.db.Path() }
@@ -0,0 +1,157 @@
+// Package kv is the settings store of the web monitor: JSON documents under
+// keys in named buckets, one bucket per subsystem (rules, masks, tokens,
+// ...). BoltDB keeps them in a single file by default; Memory keeps them for
+// the life of the process. Migrate upgrades the stored schema in steps, and
+// Snapshot/Restore move the whole store through /api/backup and
+// /api/restore.
+package kv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotFound is returned by Get for a missing key or bucket.
+var ErrNotFound = errors.New("kv: not found")
+
+// MetaBucket holds the store's own bookkeeping (the schema version); it is
+// not part of a Snapshot.
+const MetaBucket = "_meta"
+
+// Store is a set of buckets of JSON documents. Implementations are safe
+// for concurrent use.
+type Store interface {
+	// Get returns the document under key in bucket, or ErrNotFound.
+	Get(bucket, key string) ([]byte, error)
+	// Put stores value under key, creating the bucket if needed. value
+	// must be valid JSON.
+	Put(bucket, key string, value []byte) error
+	// Delete removes key; deleting a missing key is not an error.
+	Delete(bucket, key string) error
+	// Keys returns the keys of bucket in byte order; a missing bucket has
+	// none.
+	Keys(bucket string) ([]string, error)
+	// Snapshot returns every bucket but MetaBucket.
+	Snapshot() (Snapshot, error)
+	// Restore replaces every bucket but MetaBucket with snap, atomically.
+	Restore(snap Snapshot) error
+	// Close releases the store.
+	Close() error
+	// String describes the backend for logs.
+	String() string
+}
+
+// Snapshot is the contents of a store: documents by bucket and key.
+type Snapshot map[string]map[string]json.RawMessage
+
+// Validate checks bucket names and that every value is JSON.
+func (snap Snapshot) Validate() error {
+	for bucket, docs := range snap {
+		if err := validBucket(bucket); err != nil {
+			return err
+		}
+		for key, v := range docs {
+			if key == "" {
+				return fmt.Errorf("kv: empty key in bucket %q", bucket)
+			}
+			if !json.Valid(v) {
+				return fmt.Errorf("kv: %s/%s is not JSON", bucket, key)
+			}
+		}
+	}
+	return nil
+}
+
+// checkPut validates a Put: MetaBucket is only written by Migrate.
+func checkPut(bucket, key string, value []byte) error {
+	if bucket != MetaBucket {
+		if err := validBucket(bucket); err != nil {
+			return err
+		}
+	}
+	if key == "" {
+		return fmt.Errorf("kv: empty key in bucket %q", bucket)
+	}
+	if !json.Valid(value) {
+		return fmt.Errorf("kv: %s/%s is not JSON", bucket, key)
+	}
+	return nil
+}
+
+func validBucket(name string) error {
+	if name == "" || strings.HasPrefix(name, "_") {
+		return fmt.Errorf("kv: invalid bucket name %q", name)
+	}
+	return nil
+}
+
+// Open opens the store described by spec:
+//
+//	"" or mem:               in memory (settings are lost at exit)
+//	recordings/settings.db   BoltDB file, created if missing
+//	bolt:/var/lib/petcam.db  same as above
+func Open(spec string) (Store, error) {
+	switch {
+	case spec == "" || spec == "mem:":
+		return NewMemory(), nil
+	case strings.HasPrefix(spec, "bolt:"):
+		return OpenBolt(strings.TrimPrefix(spec, "bolt:"))
+	case strings.Contains(spec, "://"):
+		return nil, fmt.Errorf("unsupported settings store: %s", spec)
+	default:
+		return OpenBolt(spec)
+	}
+}
+
+// Doc is one JSON document of a subsystem. The zero Doc has no store: it
+// loads nothing and saves nowhere, for subsystems kept in memory.
+type Doc struct {
+	Store  Store
+	Bucket string
+	Key    string
+}
+
+// Load unmarshals the document into v; ok is false if there is none.
+func (d Doc) Load(v any) (ok bool, err error) {
+	if d.Store == nil {
+		return false, nil
+	}
+	data, err := d.Store.Get(d.Bucket, d.Key)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("%s/%s: %w", d.Bucket, d.Key, err)
+	}
+	return true, nil
+}
+
+// Save marshals v as the document.
+func (d Doc) Save(v any) error {
+	if d.Store == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return d.Store.Put(d.Bucket, d.Key, data)
+}
+
+// Delete removes the document.
+func (d Doc) Delete() error {
+	if d.Store == nil {
+		return nil
+	}
+	return d.Store.Delete(d.Bucket, d.Key)
+}
+
+// Persistent reports whether the document has a store.
+func (d Doc) Persistent() bool {
+	return d.Store != nil
+}
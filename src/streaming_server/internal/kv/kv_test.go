@@ -0,0 +1,134 @@
+package kv
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func stores(t *testing.T) map[string]Store {
+	b, err := OpenBolt(filepath.Join(t.TempDir(), "sub", "settings.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return map[string]Store{"memory": NewMemory(), "bolt": b}
+}
+
+func TestStore(t *testing.T) {
+	for name, s := range stores(t) {
+		if _, err := s.Get("rules", "doc"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: Get from a missing bucket: %v", name, err)
+		}
+		for _, bad := range []struct{ bucket, key, value string }{
+			{"rules", "doc", "{"},
+			{"", "doc", "{}"},
+			{"_private", "doc", "{}"},
+			{"rules", "", "{}"},
+		} {
+			if err := s.Put(bad.bucket, bad.key, []byte(bad.value)); err == nil {
+				t.Errorf("%s: Put(%q, %q, %q) accepted", name, bad.bucket, bad.key, bad.value)
+			}
+		}
+		s.Put("rules", "doc", []byte(`[{"id":"a"}]`))
+		s.Put("masks", "doc", []byte(`[]`))
+		s.Put("masks", "other", []byte(`1`))
+		if v, err := s.Get("rules", "doc"); err != nil || string(v) != `[{"id":"a"}]` {
+			t.Errorf("%s: Get = %s, %v", name, v, err)
+		}
+		s.Put("masks", "a", []byte(`2`))
+		if keys, err := s.Keys("masks"); err != nil || !slices.Equal(keys, []string{"a", "doc", "other"}) {
+			t.Errorf("%s: Keys = %q, %v", name, keys, err)
+		}
+		if keys, err := s.Keys("absent"); err != nil || len(keys) != 0 {
+			t.Errorf("%s: Keys of a missing bucket = %q, %v", name, keys, err)
+		}
+		s.Delete("masks", "a")
+		s.Delete("masks", "other")
+		s.Delete("masks", "absent")
+		SetSchemaVersion(s, 3)
+
+		snap, err := s.Snapshot()
+		if err != nil || len(snap) != 2 || len(snap["masks"]) != 1 || string(snap["rules"]["doc"]) != `[{"id":"a"}]` {
+			t.Errorf("%s: Snapshot = %v, %v", name, snap, err)
+		}
+
+		if err := s.Restore(Snapshot{"tokens": {"doc": []byte(`{}`)}, "bad": {"doc": []byte(`x`)}}); err == nil {
+			t.Errorf("%s: Restore accepted a non-JSON value", name)
+		}
+		if _, err := s.Get("rules", "doc"); err != nil {
+			t.Errorf("%s: failed Restore changed the store: %v", name, err)
+		}
+		if err := s.Restore(Snapshot{"tokens": {"doc": []byte(`{"a":1}`)}}); err != nil {
+			t.Fatalf("%s: Restore: %v", name, err)
+		}
+		if _, err := s.Get("rules", "doc"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: Restore kept the rules bucket: %v", name, err)
+		}
+		if v, _ := SchemaVersion(s); v != 3 {
+			t.Errorf("%s: Restore reset the schema version to %d", name, v)
+		}
+	}
+}
+
+func TestBoltPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.db")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := Doc{Store: s, Bucket: "ui", Key: "doc"}
+	if err := d.Save(map[string]string{"theme": "light"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open("bolt:" + path); err == nil {
+		t.Error("opened a database another store holds")
+	}
+	s.Close()
+
+	s, err = Open("bolt:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	var got map[string]string
+	if ok, err := (Doc{Store: s, Bucket: "ui", Key: "doc"}).Load(&got); !ok || err != nil || got["theme"] != "light" {
+		t.Errorf("reopened: %v %v %v", got, ok, err)
+	}
+	if ok, err := (Doc{}).Load(&got); ok || err != nil {
+		t.Errorf("zero Doc: %v %v", ok, err)
+	}
+	if _, err := Open("redis://localhost"); err == nil {
+		t.Error("unsupported scheme accepted")
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	s := NewMemory()
+	var ran []string
+	step := func(name string) Migration {
+		return Migration{Name: name, Up: func(Store) error { ran = append(ran, name); return nil }}
+	}
+	migrations := []Migration{step("import"), step("split")}
+	if applied, err := Migrate(s, migrations[:1]); err != nil || !slices.Equal(applied, []string{"import"}) {
+		t.Fatalf("first start: %v %v", applied, err)
+	}
+	if applied, err := Migrate(s, migrations); err != nil || !slices.Equal(applied, []string{"split"}) {
+		t.Fatalf("upgrade: %v %v", applied, err)
+	}
+	if applied, err := Migrate(s, migrations); err != nil || len(applied) != 0 || !slices.Equal(ran, []string{"import", "split"}) {
+		t.Fatalf("again: %v %v, ran %v", applied, err, ran)
+	}
+	if _, err := Migrate(s, migrations[:1]); err == nil {
+		t.Error("downgrade accepted")
+	}
+
+	failing := append(migrations, Migration{Name: "broken", Up: func(Store) error { return errors.New("boom") }})
+	if _, err := Migrate(s, failing); err == nil {
+		t.Error("failed migration not reported")
+	}
+	if v, _ := SchemaVersion(s); v != 2 {
+		t.Errorf("version %d after a failed migration", v)
+	}
+}
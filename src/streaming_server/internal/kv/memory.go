@@ -0,0 +1,97 @@
+package kv
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// Memory is a Store that lives as long as the process.
+type Memory struct {
+	mu      sync.RWMutex
+	buckets map[string]map[string][]byte
+}
+
+// NewMemory returns an empty in-memory store.
+func NewMemory() *Memory {
+	return &Memory{buckets: make(map[string]map[string][]byte)}
+}
+
+func (m *Memory) Get(bucket, key string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	v, ok := m.buckets[bucket][key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return slices.Clone(v), nil
+}
+
+func (m *Memory) Put(bucket, key string, value []byte) error {
+	if err := checkPut(bucket, key, value); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.buckets[bucket] == nil {
+		m.buckets[bucket] = make(map[string][]byte)
+	}
+	m.buckets[bucket][key] = slices.Clone(value)
+	return nil
+}
+
+func (m *Memory) Delete(bucket, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.buckets[bucket], key)
+	return nil
+}
+
+func (m *Memory) Keys(bucket string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := slices.Collect(maps.Keys(m.buckets[bucket]))
+	slices.Sort(keys)
+	return keys, nil
+}
+
+func (m *Memory) Snapshot() (Snapshot, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	snap := make(Snapshot)
+	for bucket, docs := range m.buckets {
+		if bucket == MetaBucket || len(docs) == 0 {
+			continue
+		}
+		snap[bucket] = make(map[string]json.RawMessage, len(docs))
+		for key, v := range docs {
+			snap[bucket][key] = slices.Clone(v)
+		}
+	}
+	return snap, nil
+}
+
+func (m *Memory) Restore(snap Snapshot) error {
+	if err := snap.Validate(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	meta := m.buckets[MetaBucket]
+	m.buckets = make(map[string]map[string][]byte, len(snap)+1)
+	if meta != nil {
+		m.buckets[MetaBucket] = meta
+	}
+	for bucket, docs := range snap {
+		m.buckets[bucket] = make(map[string][]byte, len(docs))
+		for key, v := range docs {
+			m.buckets[bucket][key] = slices.Clone(v)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) Close() error { return nil }
+
+func (m *Memory) String() string { return "memory" }
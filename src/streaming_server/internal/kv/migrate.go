@@ -0,0 +1,60 @@
+package kv
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const schemaKey = "schema"
+
+// Migration is one step of the settings schema: importing legacy files,
+// reshaping a subsystem's document. Migrations run in order, each once;
+// the store records how many ran.
+type Migration struct {
+	Name string
+	Up   func(Store) error
+}
+
+// SchemaVersion returns the number of migrations applied to s.
+func SchemaVersion(s Store) (int, error) {
+	data, err := s.Get(MetaBucket, schemaKey)
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(data))
+}
+
+// SetSchemaVersion records that the first v migrations were applied, e.g.
+// after restoring a backup taken at that version.
+func SetSchemaVersion(s Store, v int) error {
+	return s.Put(MetaBucket, schemaKey, []byte(strconv.Itoa(v)))
+}
+
+// Migrate applies the migrations s has not seen and returns their names.
+// A store written by a newer schema is an error: its documents may not
+// parse the way this version expects.
+func Migrate(s Store, migrations []Migration) ([]string, error) {
+	v, err := SchemaVersion(s)
+	if err != nil {
+		return nil, err
+	}
+	if v > len(migrations) {
+		return nil, fmt.Errorf("kv: settings schema %d is newer than this build's %d", v, len(migrations))
+	}
+	var applied []string
+	for ; v < len(migrations); v++ {
+		m := migrations[v]
+		if err := m.Up(s); err != nil {
+			return applied, fmt.Errorf("kv: migration %d (%s): %w", v+1, m.Name, err)
+		}
+		if err := SetSchemaVersion(s, v+1); err != nil {
+			return applied, err
+		}
+		applied = append(applied, m.Name)
+	}
+	return applied, nil
+}
@@ -0,0 +1,215 @@
+package logger
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Entry is a log line as seen by hooks.
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Level   LogLevel  `json:"level"`
+	Module  string    `json:"module"`
+	Format  string    `json:"-"` // unformatted message; stable across repeats of the same log call
+	Message string    `json:"message"`
+}
+
+// MarshalText encodes the level by name ("WARN").
+func (l LogLevel) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// Hook consumes log entries, e.g. to raise alerts or keep recent lines.
+type Hook interface {
+	Fire(Entry)
+}
+
+// HookFunc adapts a function to Hook.
+type HookFunc func(Entry)
+
+// Fire calls f.
+func (f HookFunc) Fire(e Entry) { f(e) }
+
+// HookOptions selects which entries a hook receives. Only lines that pass
+// the logger's own level are dispatched.
+type HookOptions struct {
+	MinLevel LogLevel // lowest level delivered (default DEBUG)
+	Modules  []string // deliver only these modules (empty = all)
+	Buffer   int      // queued entries before dropping (default 256)
+}
+
+// Registration is a registered hook. Entries are queued and delivered in
+// order on the hook's own goroutine, so a slow hook never blocks logging;
+// when its queue is full, entries are dropped and counted.
+type Registration struct {
+	hook    Hook
+	opts    HookOptions
+	queue   chan Entry
+	done    chan struct{}
+	once    sync.Once
+	dropped atomic.Uint64
+}
+
+var (
+	hooksMu sync.RWMutex
+	hooks   []*Registration
+	nHooks  atomic.Int32 // fast path: no hooks registered
+)
+
+// Register adds a hook and starts its delivery goroutine.
+func Register(h Hook, opts HookOptions) *Registration {
+	if opts.Buffer <= 0 {
+		opts.Buffer = 256
+	}
+	r := &Registration{
+		hook:  h,
+		opts:  opts,
+		queue: make(chan Entry, opts.Buffer),
+		done:  make(chan struct{}),
+	}
+	go r.run()
+
+	hooksMu.Lock()
+	hooks = append(hooks, r)
+	nHooks.Store(int32(len(hooks)))
+	hooksMu.Unlock()
+	return r
+}
+
+// Unregister stops delivery to the hook. Queued entries are discarded.
+func (r *Registration) Unregister() {
+	r.once.Do(func() {
+		hooksMu.Lock()
+		hooks = slices.DeleteFunc(hooks, func(h *Registration) bool { return h == r })
+		nHooks.Store(int32(len(hooks)))
+		hooksMu.Unlock()
+		close(r.done)
+	})
+}
+
+// Dropped returns how many entries were discarded because the queue was full.
+func (r *Registration) Dropped() uint64 {
+	return r.dropped.Load()
+}
+
+func (r *Registration) run() {
+	for {
+		select {
+		case <-r.done:
+			return
+		case e := <-r.queue:
+			r.hook.Fire(e)
+		}
+	}
+}
+
+func (r *Registration) accepts(e Entry) bool {
+	return e.Level >= r.opts.MinLevel && (len(r.opts.Modules) == 0 || slices.Contains(r.opts.Modules, e.Module))
+}
+
+// dispatch queues e for every matching hook without blocking.
+func dispatch(e Entry) {
+	if nHooks.Load() == 0 {
+		return
+	}
+	hooksMu.RLock()
+	defer hooksMu.RUnlock()
+	for _, r := range hooks {
+		if !r.accepts(e) {
+			continue
+		}
+		select {
+		case r.queue <- e:
+		default:
+			r.dropped.Add(1)
+		}
+	}
+}
+
+// Ring is a Hook keeping the last Size entries in memory.
+type Ring struct {
+	mu      sync.Mutex
+	entries []Entry
+	next    int
+	full    bool
+}
+
+// NewRing creates a ring buffer holding size entries.
+func NewRing(size int) *Ring {
+	return &Ring{entries: make([]Entry, size)}
+}
+
+// Fire stores e, overwriting the oldest entry when full.
+func (r *Ring) Fire(e Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.entries) == 0 {
+		return
+	}
+	r.entries[r.next] = e
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Entries returns the stored entries at minLevel or above, oldest first,
+// keeping the newest limit (0 = all).
+func (r *Ring) Entries(minLevel LogLevel, limit int) []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ordered := r.entries[:r.next]
+	if r.full {
+		ordered = append(slices.Clone(r.entries[r.next:]), r.entries[:r.next]...)
+	}
+	out := make([]Entry, 0, len(ordered))
+	for _, e := range ordered {
+		if e.Level >= minLevel {
+			out = append(out, e)
+		}
+	}
+	if limit > 0 && len(out) > limit {
+		out = out[len(out)-limit:]
+	}
+	return out
+}
+
+// LevelRing is a Hook keeping the last N entries of each level, so
+// a burst of DEBUG/INFO lines cannot push out the warnings before it.
+type LevelRing struct {
+	rings map[LogLevel]*Ring
+}
+
+// NewLevelRing creates per-level ring buffers of perLevel entries each.
+func NewLevelRing(perLevel int) *LevelRing {
+	lr := &LevelRing{rings: make(map[LogLevel]*Ring)}
+	for _, l := range []LogLevel{DEBUG, INFO, WARN, ERROR} {
+		lr.rings[l] = NewRing(perLevel)
+	}
+	return lr
+}
+
+// Fire stores e in its level's ring.
+func (lr *LevelRing) Fire(e Entry) {
+	if r := lr.rings[e.Level]; r != nil {
+		r.Fire(e)
+	}
+}
+
+// Entries returns the stored entries at minLevel or above, merged oldest
+// first, keeping the newest limit (0 = all).
+func (lr *LevelRing) Entries(minLevel LogLevel, limit int) []Entry {
+	var out []Entry
+	for l, r := range lr.rings {
+		if l >= minLevel {
+			out = append(out, r.Entries(minLevel, 0)...)
+		}
+	}
+	slices.SortStableFunc(out, func(a, b Entry) int { return a.Time.Compare(b.Time) })
+	if limit > 0 && len(out) > limit {
+		out = out[len(out)-limit:]
+	}
+	return out
+}
@@ -0,0 +1,74 @@
+package logger
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestRegisterFiltersAndDrops(t *testing.T) {
+	l := New(DEBUG, io.Discard, false)
+
+	got := make(chan Entry, 10)
+	r := Register(HookFunc(func(e Entry) { got <- e }), HookOptions{MinLevel: WARN, Modules: []string{"Recorder"}})
+	l.Warn("Server", "other module")
+	l.Info("Recorder", "below level")
+	l.Warn("Recorder", "disk %s", "full")
+	select {
+	case e := <-got:
+		if e.Message != "disk full" || e.Format != "disk %s" || e.Level != WARN {
+			t.Fatalf("entry %+v", e)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("hook not called")
+	}
+	r.Unregister()
+	l.Warn("Recorder", "after unregister")
+	select {
+	case e := <-got:
+		t.Fatalf("delivered after unregister: %+v", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// A blocked hook must not block logging; overflow is counted.
+	block := make(chan struct{})
+	slow := Register(HookFunc(func(Entry) { <-block }), HookOptions{Buffer: 1})
+	defer slow.Unregister()
+	defer close(block)
+	for range 10 {
+		l.Error("Recorder", "flood")
+	}
+	if slow.Dropped() < 8 {
+		t.Fatalf("dropped %d, want >= 8", slow.Dropped())
+	}
+}
+
+func TestRingEntries(t *testing.T) {
+	r := NewRing(3)
+	for i, lvl := range []LogLevel{INFO, WARN, INFO, ERROR} {
+		r.Fire(Entry{Level: lvl, Message: string(rune('a' + i))})
+	}
+	all := r.Entries(DEBUG, 0)
+	if len(all) != 3 || all[0].Message != "b" || all[2].Message != "d" {
+		t.Fatalf("entries %+v", all)
+	}
+	if warn := r.Entries(WARN, 1); len(warn) != 1 || warn[0].Message != "d" {
+		t.Fatalf("warn entries %+v", warn)
+	}
+}
+
+func TestLevelRingKeepsWarningsThroughFloods(t *testing.T) {
+	lr := NewLevelRing(2)
+	base := time.Now()
+	lr.Fire(Entry{Time: base, Level: WARN, Message: "warn"})
+	for i := range 100 {
+		lr.Fire(Entry{Time: base.Add(time.Duration(i+1) * time.Millisecond), Level: DEBUG, Message: "noise"})
+	}
+	all := lr.Entries(DEBUG, 0)
+	if len(all) != 3 || all[0].Message != "warn" {
+		t.Fatalf("entries %+v", all)
+	}
+	if warn := lr.Entries(WARN, 0); len(warn) != 1 {
+		t.Fatalf("warn entries %+v", warn)
+	}
+}
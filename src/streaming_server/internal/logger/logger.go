@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"sync"
+	"time"
 )
 
 // LogLevel represents the severity of a log message
@@ -131,6 +132,8 @@ func (l *Logger) log(level LogLevel, module string, format string, args ...inter
 
 	message := fmt.Sprintf(format, args...)
 	logger.Printf("%s %s", prefix, message)
+
+	dispatch(Entry{Time: time.Now(), Level: level, Module: module, Format: format, Message: message})
 }
 
 // Debug logs a debug message
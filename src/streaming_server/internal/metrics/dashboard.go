@@ -0,0 +1,213 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// dashboardRows groups metric families into dashboard rows by name; the
+// first matching row wins, families matching none go to "System".
+var dashboardRows = []struct {
+	title string
+	match *regexp.Regexp
+}{
+	{"Latency", regexp.MustCompile(`latency|_ms$|duration_seconds`)},
+	{"Drops and errors", regexp.MustCompile(`dropped|drop_rate|skipped|decimated|errors|reaped|panics|corrupt`)},
+	{"Frame rate", regexp.MustCompile(`frames_(read|processed|sent)_total|annotations_total`)},
+	{"Clients", regexp.MustCompile(`clients|sessions|mjpeg_bytes`)},
+	{"Disk", regexp.MustCompile(`disk`)},
+	{"Recording", regexp.MustCompile(`recording|recorder|scrub`)},
+	{"System", regexp.MustCompile(``)},
+}
+
+// Dashboard builds a Grafana dashboard with one panel per metric family
+// currently in g (histogram vectors appear after their first sample),
+// grouped into rows (latency, drops, frame rate, clients, disk,
+// recording). Counters are graphed as per-second rates, histograms
+// as p50/p95/p99, and every query is filtered by a $camera variable. The
+// datasource is an import input, so the JSON imports as is.
+func Dashboard(g prometheus.Gatherer, title string) (map[string]any, error) {
+	families, err := g.Gather()
+	if err != nil {
+		return nil, err
+	}
+	byRow := make([][]map[string]any, len(dashboardRows))
+	for _, mf := range families {
+		for i, row := range dashboardRows {
+			if row.match.MatchString(mf.GetName()) {
+				byRow[i] = append(byRow[i], dashboardPanel(mf))
+				break
+			}
+		}
+	}
+
+	var panels []map[string]any
+	id, y := 1, 0
+	for i, row := range dashboardRows {
+		if len(byRow[i]) == 0 {
+			continue
+		}
+		panels = append(panels, map[string]any{
+			"id":        id,
+			"type":      "row",
+			"title":     row.title,
+			"collapsed": false,
+			"gridPos":   map[string]int{"x": 0, "y": y, "w": 24, "h": 1},
+			"panels":    []any{},
+		})
+		id++
+		y++
+		for j, p := range byRow[i] {
+			p["id"] = id
+			p["gridPos"] = map[string]int{"x": 12 * (j % 2), "y": y + 8*(j/2), "w": 12, "h": 8}
+			panels = append(panels, p)
+			id++
+		}
+		y += 8 * ((len(byRow[i]) + 1) / 2)
+	}
+
+	return map[string]any{
+		"__inputs": []map[string]string{{
+			"name":       "DS_PROMETHEUS",
+			"label":      "Prometheus",
+			"type":       "datasource",
+			"pluginId":   "prometheus",
+			"pluginName": "Prometheus",
+		}},
+		"title":         title,
+		"uid":           dashboardUID(title),
+		"tags":          []string{"pet-camera"},
+		"schemaVersion": 39,
+		"editable":      true,
+		"refresh":       "30s",
+		"time":          map[string]string{"from": "now-6h", "to": "now"},
+		"templating": map[string]any{"list": []map[string]any{{
+			"name":       "camera",
+			"label":      "Camera",
+			"type":       "query",
+			"datasource": dashboardDatasource,
+			"query":      "label_values(camera)",
+			"refresh":    1,
+			"multi":      true,
+			"includeAll": true,
+			"allValue":   ".*",
+			"current":    map[string]any{"text": "All", "value": "$__all"},
+		}}},
+		"panels": panels,
+	}, nil
+}
+
+var dashboardDatasource = map[string]string{"type": "prometheus", "uid": "${DS_PROMETHEUS}"}
+
+// dashboardPanel returns the time series panel of one metric family.
+func dashboardPanel(mf *dto.MetricFamily) map[string]any {
+	name := mf.GetName()
+	var labels []string
+	if ms := mf.GetMetric(); len(ms) > 0 {
+		for _, l := range ms[0].GetLabel() {
+			labels = append(labels, l.GetName())
+		}
+	}
+	legend := make([]string, len(labels))
+	for i, l := range labels {
+		legend[i] = "{{" + l + "}}"
+	}
+	selector := `{camera=~"$camera"}`
+
+	var targets []map[string]any
+	unit := dashboardUnit(name)
+	title := name
+	switch {
+	case mf.GetType() == dto.MetricType_HISTOGRAM:
+		by := strings.Join(append([]string{"le"}, labels...), ", ")
+		for _, p := range []string{"50", "95", "99"} {
+			targets = append(targets, map[string]any{
+				"expr":         "histogram_quantile(0." + p + ", sum by (" + by + ") (rate(" + name + "_bucket" + selector + "[$__rate_interval])))",
+				"legendFormat": "p" + p + " " + strings.Join(legend, " "),
+			})
+		}
+	case mf.GetType() == dto.MetricType_COUNTER || strings.HasSuffix(name, "_total"):
+		targets = append(targets, map[string]any{
+			"expr":         "rate(" + name + selector + "[$__rate_interval])",
+			"legendFormat": strings.Join(legend, " "),
+		})
+		title += " /s"
+		if unit == "bytes" {
+			unit = "Bps"
+		}
+	default:
+		targets = append(targets, map[string]any{
+			"expr":         name + selector,
+			"legendFormat": strings.Join(legend, " "),
+		})
+	}
+	for i, t := range targets {
+		t["refId"] = string(rune('A' + i))
+		t["datasource"] = dashboardDatasource
+	}
+
+	return map[string]any{
+		"type":        "timeseries",
+		"title":       title,
+		"description": mf.GetHelp(),
+		"datasource":  dashboardDatasource,
+		"targets":     targets,
+		"fieldConfig": map[string]any{
+			"defaults":  map[string]any{"unit": unit},
+			"overrides": []any{},
+		},
+	}
+}
+
+// dashboardUnit picks the Grafana unit from the metric name's suffix.
+func dashboardUnit(name string) string {
+	name = strings.TrimSuffix(name, "_total")
+	switch {
+	case strings.HasSuffix(name, "_timestamp_seconds"):
+		return "dateTimeFromNow"
+	case strings.HasSuffix(name, "_seconds"):
+		return "s"
+	case strings.HasSuffix(name, "_ms"):
+		return "ms"
+	case strings.HasSuffix(name, "_bytes"):
+		return "bytes"
+	case strings.HasSuffix(name, "_percent"):
+		return "percent"
+	}
+	return "short"
+}
+
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// dashboardUID derives a stable Grafana uid (at most 40 characters) from
+// the title, so a re-import replaces the dashboard.
+func dashboardUID(title string) string {
+	uid := strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(title), "-"), "-")
+	if len(uid) > 40 {
+		uid = uid[:40]
+	}
+	return uid
+}
+
+// DashboardHandler serves Dashboard(g, title) as JSON.
+func DashboardHandler(g prometheus.Gatherer, title string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		d, err := Dashboard(g, title)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+dashboardUID(title)+`.json"`)
+		json.NewEncoder(w).Encode(d)
+	})
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestDashboard(t *testing.T) {
+	r := NewRegistry()
+	m := r.Camera("living")
+	now := time.Now()
+	m.ObserveFrameTiming(&types.VideoFrame{Timestamp: now, Timing: types.FrameTiming{Read: now}})
+	r.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: "recordings_disk_free_bytes", Help: "free"}, func() float64 { return 1 }))
+
+	d, err := Dashboard(r.registry, "Pet camera streaming")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d["uid"] != "pet-camera-streaming" {
+		t.Fatalf("uid %v", d["uid"])
+	}
+
+	rows := map[string]bool{}
+	exprs := map[string]string{}
+	units := map[string]string{}
+	ids := map[int]bool{}
+	for _, p := range d["panels"].([]map[string]any) {
+		id := p["id"].(int)
+		if ids[id] {
+			t.Fatalf("duplicate panel id %d", id)
+		}
+		ids[id] = true
+		if p["type"] == "row" {
+			rows[p["title"].(string)] = true
+			continue
+		}
+		targets := p["targets"].([]map[string]any)
+		exprs[p["title"].(string)] = targets[len(targets)-1]["expr"].(string)
+		units[p["title"].(string)] = p["fieldConfig"].(map[string]any)["defaults"].(map[string]any)["unit"].(string)
+	}
+	for _, row := range []string{"Frame rate", "Latency", "Drops and errors", "Clients", "Recording", "Disk", "System"} {
+		if !rows[row] {
+			t.Errorf("row %q missing (have %v)", row, rows)
+		}
+	}
+
+	if e := exprs["streaming_frames_read_total /s"]; e != `rate(streaming_frames_read_total{camera=~"$camera"}[$__rate_interval])` {
+		t.Errorf("frames read expr %q", e)
+	}
+	if e := exprs["streaming_frame_hop_latency_seconds"]; !strings.HasPrefix(e, "histogram_quantile(0.99, sum by (le, camera, hop, mode) (rate(streaming_frame_hop_latency_seconds_bucket") {
+		t.Errorf("hop latency expr %q", e)
+	}
+	if e := exprs["streaming_active_clients"]; e != `streaming_active_clients{camera=~"$camera"}` {
+		t.Errorf("active clients expr %q", e)
+	}
+	if u := units["recordings_disk_free_bytes"]; u != "bytes" {
+		t.Errorf("disk unit %q", u)
+	}
+	if u := units["streaming_frame_latency_ms"]; u != "ms" {
+		t.Errorf("latency unit %q", u)
+	}
+}
@@ -1,253 +1,266 @@
 package metrics
 
 import (
+	"maps"
+	"net"
 	"net/http"
+	"os"
+	"slices"
+	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/types"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-// Metrics holds all application metrics
+// MainProfile is the "profile" label of the camera's own H.265 stream;
+// transcoded fallback streams use their codec name in lower case.
+const MainProfile = "main"
+
+// CameraLabel returns the "camera" label value for a configured camera
+// name: the name itself, or the hostname when it is empty.
+func CameraLabel(name string) string {
+	if name != "" {
+		return name
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	return "camera"
+}
+
+// Registry is the Prometheus registry of one process. Every metric carries
+// a "camera" label and stream metrics also a "profile" label; the series of
+// a camera or profile exist from Camera/Profile until RemoveCamera/
+// RemoveProfile, so removed ones do not linger with stale values.
+type Registry struct {
+	registry   *prometheus.Registry
+	hopLatency *prometheus.HistogramVec
+
+	mu      sync.Mutex
+	cameras map[string]*Metrics
+
+	serveOnce sync.Once // handlers registered on http.DefaultServeMux
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	r := &Registry{
+		registry: prometheus.NewRegistry(),
+		cameras:  make(map[string]*Metrics),
+	}
+	r.hopLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "streaming_frame_hop_latency_seconds",
+			Help:    "Per-frame latency of each pipeline hop (capture=SHM write, read, processed, sent) by pipeline mode",
+			Buckets: []float64{.0005, .001, .002, .005, .01, .02, .033, .05, .1, .2, .5},
+		},
+		[]string{"camera", "hop", "mode"},
+	)
+	r.registry.MustRegister(r.hopLatency, collector{r})
+	return r
+}
+
+// Camera returns the metrics of camera, creating them on first use.
+func (r *Registry) Camera(camera string) *Metrics {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if m, ok := r.cameras[camera]; ok {
+		return m
+	}
+	m := &Metrics{
+		camera:       camera,
+		reg:          r,
+		pipelineMode: "pipelined",
+		profiles:     make(map[string]*Stream),
+	}
+	m.Main = m.Profile(MainProfile)
+	r.cameras[camera] = m
+	return m
+}
+
+// RemoveCamera deletes every series of camera, including the collectors
+// added with its Metrics.Register. A later Camera call starts from zero.
+func (r *Registry) RemoveCamera(camera string) {
+	r.mu.Lock()
+	m, ok := r.cameras[camera]
+	delete(r.cameras, camera)
+	r.mu.Unlock()
+	if !ok {
+		return
+	}
+	r.hopLatency.DeletePartialMatch(prometheus.Labels{"camera": camera})
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.collectors {
+		m.registerer().Unregister(c)
+	}
+	m.collectors = nil
+}
+
+// Register adds a process-wide collector without a camera label.
+func (r *Registry) Register(c prometheus.Collector) {
+	r.registry.MustRegister(c)
+}
+
+// Handler returns the Prometheus HTTP handler
+func (r *Registry) Handler() http.Handler {
+	return promhttp.HandlerFor(r.registry, promhttp.HandlerOpts{})
+}
+
+// DashboardHandler serves a Grafana dashboard of the registry's metrics
+// (see Dashboard).
+func (r *Registry) DashboardHandler() http.Handler {
+	return DashboardHandler(r.registry, "Pet camera streaming")
+}
+
+// Serve serves the metrics HTTP server on an already-bound listener
+// (e.g. a systemd-activated socket), with the dashboard at
+// /metrics/dashboard.json. It may be called for several listeners.
+func (r *Registry) Serve(ln net.Listener) error {
+	r.serveOnce.Do(func() {
+		http.Handle("/metrics", r.Handler())
+		http.Handle("/metrics/dashboard.json", r.DashboardHandler())
+	})
+	return http.Serve(ln, nil)
+}
+
+// Metrics holds the metrics of one camera
 type Metrics struct {
+	camera string
+	reg    *Registry
+
 	// Frame processing counters
 	FramesRead            atomic.Uint64
 	FramesProcessed       atomic.Uint64
 	FramesDropped         atomic.Uint64
-	WebRTCFramesSent      atomic.Uint64
-	WebRTCFramesDropped   atomic.Uint64
 	RecorderFramesSent    atomic.Uint64
 	RecorderFramesDropped atomic.Uint64
 
 	// Error counters
 	ReadErrors     atomic.Uint64
 	ProcessErrors  atomic.Uint64
-	WebRTCErrors   atomic.Uint64
 	RecorderErrors atomic.Uint64
 
 	// Latency tracking
 	FrameLatencyMs         atomic.Uint64 // Average frame latency in ms
 	ProcessLatencyMs       atomic.Uint64 // Average processing latency in ms
 	RecorderWriteLatencyMs atomic.Uint64 // Latest recorder write latency in ms
-	WebRTCSendLatencyMs    atomic.Uint64 // Latest WebRTC SendFrame latency in ms
 
 	// Buffer usage
-	WebRTCBufferUsage   atomic.Uint64 // Percentage (0-100)
 	RecorderBufferUsage atomic.Uint64 // Percentage (0-100)
 
 	// SHM / recorder queue depth
 	SHMFrameDropRate   atomic.Uint64 // Cumulative count of SHM frame version jumps (missed frames)
 	RecorderQueueDepth atomic.Uint64 // Current recorder channel occupancy
 
+	// Reads dropped because the frame number repeated (see shm.DupGuard)
+	DuplicateFramesSkipped atomic.Uint64
+
 	// WebRTC client tracking
-	ActiveClients atomic.Uint64
-	TotalClients  atomic.Uint64
+	ActiveClients        atomic.Uint64
+	TotalClients         atomic.Uint64
+	WebRTCSessionsReaped atomic.Uint64 // sessions that never finished ICE/DTLS
 
 	// Recording state
 	RecordingActive atomic.Uint64 // 0 = inactive, 1 = active
 	RecordingBytes  atomic.Uint64
 	RecordingFrames atomic.Uint64
 
-	// Prometheus collectors
-	registry *prometheus.Registry
-}
+	// CPU degradation (see internal/degrade)
+	DegradationLevel atomic.Uint64 // 0 = normal, 3 = WebRTC decimated
+	CPUUsagePercent  atomic.Uint64
 
-// New creates a new Metrics instance with Prometheus collectors
-func New() *Metrics {
-	m := &Metrics{
-		registry: prometheus.NewRegistry(),
-	}
+	// Main is the camera's H.265 WebRTC stream (profile MainProfile)
+	Main *Stream
 
-	// Register Prometheus gauges
-	m.registerPrometheusMetrics()
+	pipelineMode string // "mode" label of the hop histogram; set once before frames flow
 
-	return m
+	mu         sync.Mutex
+	profiles   map[string]*Stream
+	collectors []prometheus.Collector // added by Register, removed with the camera
 }
 
-// registerPrometheusMetrics registers all metrics with Prometheus
-func (m *Metrics) registerPrometheusMetrics() {
-	// Frame processing metrics
-	m.registry.MustRegister(prometheus.NewGaugeFunc(
-		prometheus.GaugeOpts{
-			Name: "streaming_frames_read_total",
-			Help: "Total frames read from shared memory",
-		},
-		func() float64 { return float64(m.FramesRead.Load()) },
-	))
-
-	m.registry.MustRegister(prometheus.NewGaugeFunc(
-		prometheus.GaugeOpts{
-			Name: "streaming_frames_processed_total",
-			Help: "Total frames processed",
-		},
-		func() float64 { return float64(m.FramesProcessed.Load()) },
-	))
-
-	m.registry.MustRegister(prometheus.NewGaugeFunc(
-		prometheus.GaugeOpts{
-			Name: "streaming_frames_dropped_total",
-			Help: "Total frames dropped",
-		},
-		func() float64 { return float64(m.FramesDropped.Load()) },
-	))
-
-	m.registry.MustRegister(prometheus.NewGaugeFunc(
-		prometheus.GaugeOpts{
-			Name: "streaming_webrtc_frames_sent_total",
-			Help: "Total frames sent to WebRTC clients",
-		},
-		func() float64 { return float64(m.WebRTCFramesSent.Load()) },
-	))
-
-	m.registry.MustRegister(prometheus.NewGaugeFunc(
-		prometheus.GaugeOpts{
-			Name: "streaming_webrtc_frames_dropped_total",
-			Help: "Total WebRTC frames dropped",
-		},
-		func() float64 { return float64(m.WebRTCFramesDropped.Load()) },
-	))
-
-	// Error metrics
-	m.registry.MustRegister(prometheus.NewGaugeFunc(
-		prometheus.GaugeOpts{
-			Name: "streaming_read_errors_total",
-			Help: "Total shared memory read errors",
-		},
-		func() float64 { return float64(m.ReadErrors.Load()) },
-	))
-
-	m.registry.MustRegister(prometheus.NewGaugeFunc(
-		prometheus.GaugeOpts{
-			Name: "streaming_process_errors_total",
-			Help: "Total frame processing errors",
-		},
-		func() float64 { return float64(m.ProcessErrors.Load()) },
-	))
-
-	m.registry.MustRegister(prometheus.NewGaugeFunc(
-		prometheus.GaugeOpts{
-			Name: "streaming_webrtc_errors_total",
-			Help: "Total WebRTC errors",
-		},
-		func() float64 { return float64(m.WebRTCErrors.Load()) },
-	))
-
-	// Latency metrics
-	m.registry.MustRegister(prometheus.NewGaugeFunc(
-		prometheus.GaugeOpts{
-			Name: "streaming_frame_latency_ms",
-			Help: "Average frame latency in milliseconds",
-		},
-		func() float64 { return float64(m.FrameLatencyMs.Load()) },
-	))
-
-	m.registry.MustRegister(prometheus.NewGaugeFunc(
-		prometheus.GaugeOpts{
-			Name: "streaming_process_latency_ms",
-			Help: "Average processing latency in milliseconds",
-		},
-		func() float64 { return float64(m.ProcessLatencyMs.Load()) },
-	))
-
-	m.registry.MustRegister(prometheus.NewGaugeFunc(
-		prometheus.GaugeOpts{
-			Name: "streaming_recorder_write_latency_ms",
-			Help: "Latest recorder write latency in milliseconds",
-		},
-		func() float64 { return float64(m.RecorderWriteLatencyMs.Load()) },
-	))
-
-	m.registry.MustRegister(prometheus.NewGaugeFunc(
-		prometheus.GaugeOpts{
-			Name: "streaming_webrtc_send_latency_ms",
-			Help: "Latest WebRTC SendFrame latency in milliseconds",
-		},
-		func() float64 { return float64(m.WebRTCSendLatencyMs.Load()) },
-	))
-
-	m.registry.MustRegister(prometheus.NewGaugeFunc(
-		prometheus.GaugeOpts{
-			Name: "streaming_shm_frame_drop_rate_total",
-			Help: "Cumulative count of SHM frame version jumps (missed frames)",
-		},
-		func() float64 { return float64(m.SHMFrameDropRate.Load()) },
-	))
-
-	m.registry.MustRegister(prometheus.NewGaugeFunc(
-		prometheus.GaugeOpts{
-			Name: "streaming_recorder_queue_depth",
-			Help: "Current recorder channel occupancy",
-		},
-		func() float64 { return float64(m.RecorderQueueDepth.Load()) },
-	))
-
-	// Buffer usage metrics
-	m.registry.MustRegister(prometheus.NewGaugeFunc(
-		prometheus.GaugeOpts{
-			Name: "streaming_webrtc_buffer_usage_percent",
-			Help: "WebRTC buffer usage percentage",
-		},
-		func() float64 { return float64(m.WebRTCBufferUsage.Load()) },
-	))
-
-	m.registry.MustRegister(prometheus.NewGaugeFunc(
-		prometheus.GaugeOpts{
-			Name: "streaming_recorder_buffer_usage_percent",
-			Help: "Recorder buffer usage percentage",
-		},
-		func() float64 { return float64(m.RecorderBufferUsage.Load()) },
-	))
+// Stream holds the metrics of one WebRTC stream profile of a camera.
+type Stream struct {
+	FramesSent      atomic.Uint64
+	FramesDropped   atomic.Uint64
+	FramesDecimated atomic.Uint64
+	Errors          atomic.Uint64
+	SendLatencyMs   atomic.Uint64 // Latest SendFrame latency in ms
+	BufferUsage     atomic.Uint64 // Percentage (0-100)
+}
 
-	// Client metrics
-	m.registry.MustRegister(prometheus.NewGaugeFunc(
-		prometheus.GaugeOpts{
-			Name: "streaming_active_clients",
-			Help: "Number of active WebRTC clients",
-		},
-		func() float64 { return float64(m.ActiveClients.Load()) },
-	))
+// New creates the metrics of camera in a registry of their own.
+func New(camera string) *Metrics {
+	return NewRegistry().Camera(camera)
+}
 
-	m.registry.MustRegister(prometheus.NewGaugeFunc(
-		prometheus.GaugeOpts{
-			Name: "streaming_total_clients",
-			Help: "Total WebRTC clients connected",
-		},
-		func() float64 { return float64(m.TotalClients.Load()) },
-	))
+// Camera returns the "camera" label value.
+func (m *Metrics) Camera() string {
+	return m.camera
+}
 
-	// Recording metrics
-	m.registry.MustRegister(prometheus.NewGaugeFunc(
-		prometheus.GaugeOpts{
-			Name: "streaming_recording_active",
-			Help: "Recording active (0=inactive, 1=active)",
-		},
-		func() float64 { return float64(m.RecordingActive.Load()) },
-	))
+// Registry returns the registry the camera belongs to.
+func (m *Metrics) Registry() *Registry {
+	return m.reg
+}
 
-	m.registry.MustRegister(prometheus.NewGaugeFunc(
-		prometheus.GaugeOpts{
-			Name: "streaming_recording_bytes",
-			Help: "Total bytes written to recording",
-		},
-		func() float64 { return float64(m.RecordingBytes.Load()) },
-	))
+// Profile returns the metrics of stream profile name, creating them on
+// first use.
+func (m *Metrics) Profile(name string) *Stream {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.profiles[name]; ok {
+		return s
+	}
+	s := &Stream{}
+	m.profiles[name] = s
+	return s
+}
 
-	m.registry.MustRegister(prometheus.NewGaugeFunc(
-		prometheus.GaugeOpts{
-			Name: "streaming_recording_frames",
-			Help: "Total frames written to recording",
-		},
-		func() float64 { return float64(m.RecordingFrames.Load()) },
-	))
+// RemoveProfile deletes the series of stream profile name. The main
+// profile lives as long as the camera.
+func (m *Metrics) RemoveProfile(name string) {
+	if name == MainProfile {
+		return
+	}
+	m.mu.Lock()
+	delete(m.profiles, name)
+	m.mu.Unlock()
 }
 
-// UpdateFrameLatency updates the average frame latency
+// UpdateFrameLatency updates the average frame latency. captureTime must be
+// on the wall clock (see shm.FrameClock); a capture time in the future counts
+// as no latency rather than wrapping around.
 func (m *Metrics) UpdateFrameLatency(captureTime time.Time) {
-	latency := time.Since(captureTime).Milliseconds()
+	latency := max(time.Since(captureTime).Milliseconds(), 0)
 	m.FrameLatencyMs.Store(uint64(latency))
 }
 
+// SetPipelineMode sets the "mode" label of the hop latency histogram
+// (pipelined or low_latency). Call before the first ObserveFrameTiming.
+func (m *Metrics) SetPipelineMode(mode string) {
+	m.pipelineMode = mode
+}
+
+// ObserveFrameTiming records each completed hop of a sent frame in the
+// streaming_frame_hop_latency_seconds histogram.
+func (m *Metrics) ObserveFrameTiming(frame *types.VideoFrame) {
+	t := frame.Timing
+	observe := func(hop string, from, to time.Time) {
+		if !from.IsZero() && !to.IsZero() {
+			m.reg.hopLatency.WithLabelValues(m.camera, hop, m.pipelineMode).Observe(to.Sub(from).Seconds())
+		}
+	}
+	observe("capture_to_read", frame.Timestamp, t.Read)
+	observe("read_to_processed", t.Read, t.Processed)
+	observe("processed_to_sent", t.Processed, t.Sent)
+	observe("capture_to_sent", frame.Timestamp, t.Sent)
+}
+
 // UpdateProcessLatency updates the average processing latency
 func (m *Metrics) UpdateProcessLatency(duration time.Duration) {
 	m.ProcessLatencyMs.Store(uint64(duration.Milliseconds()))
@@ -258,16 +271,17 @@ func (m *Metrics) UpdateRecorderWriteLatency(duration time.Duration) {
 	m.RecorderWriteLatencyMs.Store(uint64(duration.Milliseconds()))
 }
 
-// UpdateWebRTCSendLatency records the latest WebRTC SendFrame latency.
+// UpdateWebRTCSendLatency records the latest SendFrame latency of the
+// main stream.
 func (m *Metrics) UpdateWebRTCSendLatency(duration time.Duration) {
-	m.WebRTCSendLatencyMs.Store(uint64(duration.Milliseconds()))
+	m.Main.SendLatencyMs.Store(uint64(duration.Milliseconds()))
 }
 
 // UpdateBufferUsage updates buffer usage percentages
 func (m *Metrics) UpdateBufferUsage(webrtcUsed, webrtcCap, recorderUsed, recorderCap int) {
 	if webrtcCap > 0 {
 		usage := uint64(webrtcUsed * 100 / webrtcCap)
-		m.WebRTCBufferUsage.Store(usage)
+		m.Main.BufferUsage.Store(usage)
 	}
 	if recorderCap > 0 {
 		usage := uint64(recorderUsed * 100 / recorderCap)
@@ -275,13 +289,151 @@ func (m *Metrics) UpdateBufferUsage(webrtcUsed, webrtcCap, recorderUsed, recorde
 	}
 }
 
+// Register adds a collector owned by another package (e.g. httplog) to the
+// metrics registry, labeled with this camera.
+func (m *Metrics) Register(c prometheus.Collector) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.registerer().MustRegister(c)
+	m.collectors = append(m.collectors, c)
+}
+
+func (m *Metrics) registerer() prometheus.Registerer {
+	return prometheus.WrapRegistererWith(prometheus.Labels{"camera": m.camera}, m.reg.registry)
+}
+
 // Handler returns the Prometheus HTTP handler
 func (m *Metrics) Handler() http.Handler {
-	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+	return m.reg.Handler()
 }
 
 // StartServer starts the metrics HTTP server
 func (m *Metrics) StartServer(addr string) error {
 	http.Handle("/metrics", m.Handler())
+	http.Handle("/metrics/dashboard.json", m.reg.DashboardHandler())
 	return http.ListenAndServe(addr, nil)
 }
+
+// Serve serves the metrics HTTP server on an already-bound listener
+// (e.g. a systemd-activated socket).
+func (m *Metrics) Serve(ln net.Listener) error {
+	return m.reg.Serve(ln)
+}
+
+var (
+	cameraLabels = []string{"camera"}
+	streamLabels = []string{"camera", "profile"}
+)
+
+// cameraGauges are the per-camera series, read from the Metrics fields at
+// scrape time.
+var cameraGauges = []struct {
+	desc  *prometheus.Desc
+	value func(*Metrics) *atomic.Uint64
+}{
+	// Frame processing metrics
+	{prometheus.NewDesc("streaming_frames_read_total", "Total frames read from shared memory", cameraLabels, nil),
+		func(m *Metrics) *atomic.Uint64 { return &m.FramesRead }},
+	{prometheus.NewDesc("streaming_frames_processed_total", "Total frames processed", cameraLabels, nil),
+		func(m *Metrics) *atomic.Uint64 { return &m.FramesProcessed }},
+	{prometheus.NewDesc("streaming_frames_dropped_total", "Total frames dropped", cameraLabels, nil),
+		func(m *Metrics) *atomic.Uint64 { return &m.FramesDropped }},
+
+	// Error metrics
+	{prometheus.NewDesc("streaming_read_errors_total", "Total shared memory read errors", cameraLabels, nil),
+		func(m *Metrics) *atomic.Uint64 { return &m.ReadErrors }},
+	{prometheus.NewDesc("streaming_process_errors_total", "Total frame processing errors", cameraLabels, nil),
+		func(m *Metrics) *atomic.Uint64 { return &m.ProcessErrors }},
+
+	// Latency metrics
+	{prometheus.NewDesc("streaming_frame_latency_ms", "Average frame latency in milliseconds", cameraLabels, nil),
+		func(m *Metrics) *atomic.Uint64 { return &m.FrameLatencyMs }},
+	{prometheus.NewDesc("streaming_process_latency_ms", "Average processing latency in milliseconds", cameraLabels, nil),
+		func(m *Metrics) *atomic.Uint64 { return &m.ProcessLatencyMs }},
+	{prometheus.NewDesc("streaming_recorder_write_latency_ms", "Latest recorder write latency in milliseconds", cameraLabels, nil),
+		func(m *Metrics) *atomic.Uint64 { return &m.RecorderWriteLatencyMs }},
+	{prometheus.NewDesc("streaming_shm_frame_drop_rate_total", "Cumulative count of SHM frame version jumps (missed frames)", cameraLabels, nil),
+		func(m *Metrics) *atomic.Uint64 { return &m.SHMFrameDropRate }},
+	{prometheus.NewDesc("streaming_duplicate_frames_skipped_total", "SHM reads dropped because the frame number repeated the previous read", cameraLabels, nil),
+		func(m *Metrics) *atomic.Uint64 { return &m.DuplicateFramesSkipped }},
+	{prometheus.NewDesc("streaming_recorder_queue_depth", "Current recorder channel occupancy", cameraLabels, nil),
+		func(m *Metrics) *atomic.Uint64 { return &m.RecorderQueueDepth }},
+
+	// Buffer usage metrics
+	{prometheus.NewDesc("streaming_recorder_buffer_usage_percent", "Recorder buffer usage percentage", cameraLabels, nil),
+		func(m *Metrics) *atomic.Uint64 { return &m.RecorderBufferUsage }},
+
+	// Client metrics
+	{prometheus.NewDesc("streaming_active_clients", "Number of active WebRTC clients", cameraLabels, nil),
+		func(m *Metrics) *atomic.Uint64 { return &m.ActiveClients }},
+	{prometheus.NewDesc("streaming_total_clients", "Total WebRTC clients connected", cameraLabels, nil),
+		func(m *Metrics) *atomic.Uint64 { return &m.TotalClients }},
+	{prometheus.NewDesc("streaming_webrtc_sessions_reaped_total", "WebRTC sessions removed because ICE/DTLS did not complete in time", cameraLabels, nil),
+		func(m *Metrics) *atomic.Uint64 { return &m.WebRTCSessionsReaped }},
+
+	// Recording metrics
+	{prometheus.NewDesc("streaming_recording_active", "Recording active (0=inactive, 1=active)", cameraLabels, nil),
+		func(m *Metrics) *atomic.Uint64 { return &m.RecordingActive }},
+	{prometheus.NewDesc("streaming_recording_bytes", "Total bytes written to recording", cameraLabels, nil),
+		func(m *Metrics) *atomic.Uint64 { return &m.RecordingBytes }},
+	{prometheus.NewDesc("streaming_recording_frames", "Total frames written to recording", cameraLabels, nil),
+		func(m *Metrics) *atomic.Uint64 { return &m.RecordingFrames }},
+
+	// Degradation metrics
+	{prometheus.NewDesc("streaming_degradation_level", "CPU degradation level (0=normal, 1=reduce MJPEG, 2=pause annotated, 3=decimate WebRTC)", cameraLabels, nil),
+		func(m *Metrics) *atomic.Uint64 { return &m.DegradationLevel }},
+	{prometheus.NewDesc("streaming_cpu_usage_percent", "System CPU usage sampled by the degradation controller", cameraLabels, nil),
+		func(m *Metrics) *atomic.Uint64 { return &m.CPUUsagePercent }},
+}
+
+// streamGauges are the per-profile series, read from the Stream fields at
+// scrape time.
+var streamGauges = []struct {
+	desc  *prometheus.Desc
+	value func(*Stream) *atomic.Uint64
+}{
+	{prometheus.NewDesc("streaming_webrtc_frames_sent_total", "Total frames sent to WebRTC clients", streamLabels, nil),
+		func(s *Stream) *atomic.Uint64 { return &s.FramesSent }},
+	{prometheus.NewDesc("streaming_webrtc_frames_dropped_total", "Total WebRTC frames dropped (fallback profiles: H.265 frames the transcoder could not keep up with)", streamLabels, nil),
+		func(s *Stream) *atomic.Uint64 { return &s.FramesDropped }},
+	{prometheus.NewDesc("streaming_webrtc_frames_decimated_total", "WebRTC frames skipped by GOP decimation under CPU pressure", streamLabels, nil),
+		func(s *Stream) *atomic.Uint64 { return &s.FramesDecimated }},
+	{prometheus.NewDesc("streaming_webrtc_errors_total", "Total WebRTC errors", streamLabels, nil),
+		func(s *Stream) *atomic.Uint64 { return &s.Errors }},
+	{prometheus.NewDesc("streaming_webrtc_send_latency_ms", "Latest WebRTC SendFrame latency in milliseconds", streamLabels, nil),
+		func(s *Stream) *atomic.Uint64 { return &s.SendLatencyMs }},
+	{prometheus.NewDesc("streaming_webrtc_buffer_usage_percent", "WebRTC buffer usage percentage", streamLabels, nil),
+		func(s *Stream) *atomic.Uint64 { return &s.BufferUsage }},
+}
+
+// collector exports the cameras and profiles currently in the registry.
+type collector struct{ r *Registry }
+
+func (c collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, g := range cameraGauges {
+		ch <- g.desc
+	}
+	for _, g := range streamGauges {
+		ch <- g.desc
+	}
+}
+
+func (c collector) Collect(ch chan<- prometheus.Metric) {
+	c.r.mu.Lock()
+	cameras := slices.Collect(maps.Values(c.r.cameras))
+	c.r.mu.Unlock()
+
+	for _, m := range cameras {
+		for _, g := range cameraGauges {
+			ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, float64(g.value(m).Load()), m.camera)
+		}
+		m.mu.Lock()
+		profiles := maps.Clone(m.profiles)
+		m.mu.Unlock()
+		for name, s := range profiles {
+			for _, g := range streamGauges {
+				ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, float64(g.value(s).Load()), m.camera, name)
+			}
+		}
+	}
+}
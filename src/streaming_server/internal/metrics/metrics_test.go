@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// series returns the value of every gathered series of name keyed by its
+// camera and profile labels.
+func series(t *testing.T, r *Registry, name string) map[string]float64 {
+	t.Helper()
+	families, err := r.registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := map[string]float64{}
+	for _, mf := range families {
+		if mf.GetName() != name {
+			continue
+		}
+		for _, metric := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range metric.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			key := labels["camera"]
+			if p, ok := labels["profile"]; ok {
+				key += "/" + p
+			}
+			switch {
+			case metric.GetGauge() != nil:
+				out[key] = metric.GetGauge().GetValue()
+			case metric.GetCounter() != nil:
+				out[key] = metric.GetCounter().GetValue()
+			case metric.GetHistogram() != nil:
+				out[key] = float64(metric.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	return out
+}
+
+func TestRegistryCameraAndProfileLifecycle(t *testing.T) {
+	r := NewRegistry()
+	living, porch := r.Camera("living"), r.Camera("porch")
+	if r.Camera("living") != living {
+		t.Fatal("Camera did not return the existing metrics")
+	}
+	living.FramesRead.Add(3)
+	porch.FramesRead.Add(5)
+	living.Main.FramesSent.Add(2)
+	living.Profile("vp9").FramesSent.Add(1)
+
+	if got := series(t, r, "streaming_frames_read_total"); len(got) != 2 || got["living"] != 3 || got["porch"] != 5 {
+		t.Fatalf("frames read: %v", got)
+	}
+	sent := series(t, r, "streaming_webrtc_frames_sent_total")
+	if len(sent) != 3 || sent["living/main"] != 2 || sent["living/vp9"] != 1 || sent["porch/main"] != 0 {
+		t.Fatalf("frames sent: %v", sent)
+	}
+
+	living.RemoveProfile("vp9")
+	living.RemoveProfile(MainProfile) // ignored
+	if sent := series(t, r, "streaming_webrtc_frames_sent_total"); len(sent) != 2 || sent["living/main"] != 2 {
+		t.Fatalf("after RemoveProfile: %v", sent)
+	}
+
+	now := time.Now()
+	porch.ObserveFrameTiming(&types.VideoFrame{Timestamp: now, Timing: types.FrameTiming{Read: now, Processed: now, Sent: now}})
+	extra := prometheus.NewGauge(prometheus.GaugeOpts{Name: "extra_gauge", Help: "test"})
+	porch.Register(extra)
+	if got := series(t, r, "extra_gauge"); len(got) != 1 || got["porch"] != 0 {
+		t.Fatalf("registered collector: %v", got)
+	}
+
+	r.RemoveCamera("porch")
+	for _, name := range []string{"streaming_frames_read_total", "streaming_frame_hop_latency_seconds", "extra_gauge"} {
+		for key := range series(t, r, name) {
+			if key == "porch" || key == "porch/main" {
+				t.Fatalf("%s still has %s after RemoveCamera", name, key)
+			}
+		}
+	}
+	if got := series(t, r, "streaming_frames_read_total"); got["living"] != 3 {
+		t.Fatalf("living removed with porch: %v", got)
+	}
+
+	// The same camera can come back, starting from zero
+	r.Camera("porch").Register(extra)
+	if got := series(t, r, "streaming_frames_read_total"); got["porch"] != 0 || len(got) != 2 {
+		t.Fatalf("re-added camera: %v", got)
+	}
+}
@@ -0,0 +1,447 @@
+// Package mqtt is a minimal MQTT 3.1.1 client for receiving commands from a
+// home automation broker: it connects, subscribes at QoS 1 and hands every
+// message to a callback, reconnecting with backoff. It does not publish and
+// never asks for QoS 2.
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+// Control packet types (high nibble of the fixed header).
+const (
+	typeConnect    = 1
+	typeConnack    = 2
+	typePublish    = 3
+	typePuback     = 4
+	typeSubscribe  = 8
+	typeSuback     = 9
+	typePingreq    = 12
+	typePingresp   = 13
+	typeDisconnect = 14
+)
+
+// maxPacketSize bounds an incoming packet; commands are small.
+const maxPacketSize = 256 << 10
+
+// Message is a received PUBLISH.
+type Message struct {
+	Topic   string
+	Payload []byte
+}
+
+// Status describes the broker connection for health endpoints.
+type Status struct {
+	Connected  bool      `json:"connected"`
+	Broker     string    `json:"broker"` // without credentials
+	Since      time.Time `json:"since,omitempty"`
+	Reconnects int       `json:"reconnects"`
+	LastError  string    `json:"last_error,omitempty"`
+	Received   uint64    `json:"received"` // messages handed to the callback
+}
+
+// Client keeps a subscription to filters open and calls handle for every
+// message, in order, from a single goroutine. Retained messages are
+// skipped: a command kept by the broker would run again on every connect.
+type Client struct {
+	url      *url.URL
+	clientID string
+	filters  []string
+	handle   func(Message)
+
+	mu      sync.Mutex
+	status  Status
+	stop    chan struct{}
+	stopped bool
+	conn    net.Conn
+
+	// Configurable parameters
+	TLSConfig  *tls.Config
+	Password   string // used instead of the URL's when set
+	KeepAlive  time.Duration
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// NewClient creates a client for brokerURL, mqtt://[user[:password]@]host[:1883]
+// or mqtts:// (TLS, port 8883 by default).
+func NewClient(brokerURL, clientID string, filters []string, handle func(Message)) (*Client, error) {
+	u, err := url.Parse(brokerURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "mqtt", "tcp":
+		if u.Port() == "" {
+			u.Host = net.JoinHostPort(u.Hostname(), "1883")
+		}
+	case "mqtts", "ssl", "tls":
+		if u.Port() == "" {
+			u.Host = net.JoinHostPort(u.Hostname(), "8883")
+		}
+	default:
+		return nil, fmt.Errorf("mqtt: unsupported scheme %q (want mqtt:// or mqtts://)", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, errors.New("mqtt: broker URL has no host")
+	}
+	if len(filters) == 0 {
+		return nil, errors.New("mqtt: nothing to subscribe to")
+	}
+	return &Client{
+		url:        u,
+		clientID:   clientID,
+		filters:    filters,
+		handle:     handle,
+		status:     Status{Broker: u.Scheme + "://" + u.Host, Since: time.Now()},
+		stop:       make(chan struct{}),
+		KeepAlive:  30 * time.Second,
+		MinBackoff: time.Second,
+		MaxBackoff: time.Minute,
+	}, nil
+}
+
+// Start connects in the background and reconnects until Stop.
+func (c *Client) Start() {
+	go c.run()
+}
+
+// Stop disconnects and halts reconnection.
+func (c *Client) Stop() {
+	c.mu.Lock()
+	if !c.stopped {
+		close(c.stop)
+		c.stopped = true
+	}
+	conn := c.conn
+	c.mu.Unlock()
+
+	if conn != nil {
+		conn.Write([]byte{typeDisconnect << 4, 0})
+		conn.Close()
+	}
+}
+
+// Status returns a snapshot of the connection state.
+func (c *Client) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status
+}
+
+func (c *Client) run() {
+	backoff := c.MinBackoff
+	for {
+		select {
+		case <-c.stop:
+			return
+		default:
+		}
+
+		start := time.Now()
+		err := c.connectAndServe()
+
+		c.mu.Lock()
+		wasConnected := c.status.Connected
+		c.status.Connected = false
+		c.status.Since = time.Now()
+		if err != nil {
+			c.status.LastError = err.Error()
+		}
+		c.status.Reconnects++
+		c.conn = nil
+		c.mu.Unlock()
+
+		select {
+		case <-c.stop:
+			return
+		default:
+		}
+		if wasConnected {
+			logger.Warn("MQTT", "Disconnected from %s: %v", c.status.Broker, err)
+		} else {
+			logger.Debug("MQTT", "Connect failed: %v", err)
+		}
+
+		// A connection that stayed up for a while resets the backoff
+		if time.Since(start) > c.MaxBackoff {
+			backoff = c.MinBackoff
+		}
+		select {
+		case <-c.stop:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, c.MaxBackoff)
+	}
+}
+
+func (c *Client) dial() (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if c.url.Scheme == "mqtt" || c.url.Scheme == "tcp" {
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", c.url.Host)
+	}
+	cfg := c.TLSConfig
+	if cfg == nil {
+		cfg = &tls.Config{ServerName: c.url.Hostname()}
+	}
+	d := tls.Dialer{Config: cfg}
+	return d.DialContext(ctx, "tcp", c.url.Host)
+}
+
+func (c *Client) connectAndServe() error {
+	conn, err := c.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	s := &session{conn: conn, br: bufio.NewReader(conn)}
+
+	// CONNECT, then wait for CONNACK
+	conn.SetDeadline(time.Now().Add(15 * time.Second))
+	if err := s.write(typeConnect<<4, c.connectBody()); err != nil {
+		return err
+	}
+	typ, body, err := s.read()
+	if err != nil {
+		return err
+	}
+	if typ>>4 != typeConnack || len(body) != 2 {
+		return fmt.Errorf("mqtt: expected CONNACK, got packet type %d", typ>>4)
+	}
+	if body[1] != 0 {
+		return fmt.Errorf("mqtt: connection refused (%s)", connackReason(body[1]))
+	}
+	if err := s.write(typeSubscribe<<4|0x02, c.subscribeBody(1)); err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+
+	c.mu.Lock()
+	if c.stopped {
+		c.mu.Unlock()
+		return nil
+	}
+	c.conn = conn
+	c.status.Connected = true
+	c.status.Since = time.Now()
+	c.status.LastError = ""
+	c.mu.Unlock()
+	logger.Info("MQTT", "Connected to %s, subscribed to %v", c.status.Broker, c.filters)
+
+	return c.serve(s)
+}
+
+// serve handles packets until the connection fails.
+func (c *Client) serve(s *session) error {
+	done := make(chan struct{})
+	defer close(done)
+	// Keepalive pings; the broker drops us after 1.5x KeepAlive without one
+	go func() {
+		ticker := time.NewTicker(c.KeepAlive / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := s.write(typePingreq<<4, nil); err != nil {
+					s.conn.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		s.conn.SetReadDeadline(time.Now().Add(c.KeepAlive * 3 / 2))
+		typ, body, err := s.read()
+		if err != nil {
+			return err
+		}
+		switch typ >> 4 {
+		case typeSuback:
+			if len(body) < 3 {
+				return errors.New("mqtt: short SUBACK")
+			}
+			for i, code := range body[2:] {
+				if code == 0x80 && i < len(c.filters) {
+					return fmt.Errorf("mqtt: subscription to %q refused", c.filters[i])
+				}
+			}
+		case typePublish:
+			msg, id, retained, err := parsePublish(typ, body)
+			if err != nil {
+				return err
+			}
+			if id != 0 {
+				if err := s.write(typePuback<<4, binary.BigEndian.AppendUint16(nil, id)); err != nil {
+					return err
+				}
+			}
+			if retained {
+				logger.Debug("MQTT", "Skipping retained message on %s", msg.Topic)
+				continue
+			}
+			c.mu.Lock()
+			c.status.Received++
+			c.mu.Unlock()
+			c.handle(msg)
+		case typePingresp:
+		default:
+			logger.Debug("MQTT", "Ignoring packet type %d", typ>>4)
+		}
+	}
+}
+
+func (c *Client) connectBody() []byte {
+	keepAlive := uint16(min(c.KeepAlive/time.Second, 65535))
+	flags := byte(0x02) // clean session
+	var b []byte
+	b = appendString(b, "MQTT")
+	b = append(b, 4) // protocol level 3.1.1
+	user := c.url.User
+	password, hasPassword := "", false
+	if user != nil {
+		password, hasPassword = user.Password()
+		flags |= 0x80
+	}
+	if c.Password != "" {
+		password, hasPassword = c.Password, true
+	}
+	if hasPassword && user != nil {
+		flags |= 0x40
+	}
+	b = append(b, flags)
+	b = binary.BigEndian.AppendUint16(b, keepAlive)
+	b = appendString(b, c.clientID)
+	if user != nil {
+		b = appendString(b, user.Username())
+		if hasPassword {
+			b = appendString(b, password)
+		}
+	}
+	return b
+}
+
+func (c *Client) subscribeBody(id uint16) []byte {
+	b := binary.BigEndian.AppendUint16(nil, id)
+	for _, f := range c.filters {
+		b = appendString(b, f)
+		b = append(b, 1) // QoS 1
+	}
+	return b
+}
+
+func connackReason(code byte) string {
+	switch code {
+	case 1:
+		return "unacceptable protocol version"
+	case 2:
+		return "client identifier rejected"
+	case 3:
+		return "server unavailable"
+	case 4:
+		return "bad user name or password"
+	case 5:
+		return "not authorized"
+	}
+	return fmt.Sprintf("code %d", code)
+}
+
+// parsePublish decodes a PUBLISH; id is 0 for QoS 0.
+func parsePublish(header byte, body []byte) (msg Message, id uint16, retained bool, err error) {
+	if len(body) < 2 {
+		return Message{}, 0, false, errors.New("mqtt: short PUBLISH")
+	}
+	n := int(binary.BigEndian.Uint16(body))
+	if len(body) < 2+n {
+		return Message{}, 0, false, errors.New("mqtt: short PUBLISH topic")
+	}
+	msg.Topic = string(body[2 : 2+n])
+	rest := body[2+n:]
+	if qos := header >> 1 & 3; qos > 0 {
+		if len(rest) < 2 {
+			return Message{}, 0, false, errors.New("mqtt: short PUBLISH packet id")
+		}
+		id, rest = binary.BigEndian.Uint16(rest), rest[2:]
+	}
+	msg.Payload = rest
+	return msg, id, header&1 != 0, nil
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// session frames packets on one connection. Writes are serialized; reads
+// come from the serve goroutine only.
+type session struct {
+	conn    net.Conn
+	br      *bufio.Reader
+	writeMu sync.Mutex
+}
+
+func (s *session) write(header byte, body []byte) error {
+	pkt := []byte{header}
+	for n := len(body); ; {
+		digit := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			digit |= 0x80
+		}
+		pkt = append(pkt, digit)
+		if n == 0 {
+			break
+		}
+	}
+	pkt = append(pkt, body...)
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	_, err := s.conn.Write(pkt)
+	return err
+}
+
+func (s *session) read() (byte, []byte, error) {
+	header, err := s.br.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, shift := 0, 0
+	for {
+		digit, err := s.br.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n |= int(digit&0x7f) << shift
+		if digit&0x80 == 0 {
+			break
+		}
+		if shift += 7; shift > 21 {
+			return 0, nil, errors.New("mqtt: malformed remaining length")
+		}
+	}
+	if n > maxPacketSize {
+		return 0, nil, fmt.Errorf("mqtt: %d-byte packet exceeds %d", n, maxPacketSize)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(s.br, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
@@ -0,0 +1,152 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeBroker accepts one connection at a time and records what it got.
+type fakeBroker struct {
+	ln       net.Listener
+	connects chan []byte // CONNECT bodies
+	subs     chan []byte // SUBSCRIBE bodies
+	acks     chan uint16 // PUBACK packet ids
+	conns    chan *session
+}
+
+func newFakeBroker(t *testing.T) *fakeBroker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &fakeBroker{
+		ln:       ln,
+		connects: make(chan []byte, 4),
+		subs:     make(chan []byte, 4),
+		acks:     make(chan uint16, 4),
+		conns:    make(chan *session, 4),
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(&session{conn: conn, br: bufio.NewReader(conn)})
+		}
+	}()
+	return b
+}
+
+func (b *fakeBroker) serve(s *session) {
+	defer s.conn.Close()
+	for {
+		typ, body, err := s.read()
+		if err != nil {
+			return
+		}
+		switch typ >> 4 {
+		case typeConnect:
+			b.connects <- body
+			s.write(typeConnack<<4, []byte{0, 0})
+		case typeSubscribe:
+			b.subs <- body
+			s.write(typeSuback<<4, append(body[:2:2], 1))
+			b.conns <- s
+		case typePuback:
+			b.acks <- binary.BigEndian.Uint16(body)
+		case typePingreq:
+			s.write(typePingresp<<4, nil)
+		case typeDisconnect:
+			return
+		}
+	}
+}
+
+func publish(s *session, topic, payload string, qos byte, id uint16, retain bool) {
+	header := byte(typePublish<<4) | qos<<1
+	if retain {
+		header |= 1
+	}
+	body := appendString(nil, topic)
+	if qos > 0 {
+		body = binary.BigEndian.AppendUint16(body, id)
+	}
+	s.write(header, append(body, payload...))
+}
+
+func recv[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out")
+		panic("unreachable")
+	}
+}
+
+func TestClient(t *testing.T) {
+	b := newFakeBroker(t)
+	msgs := make(chan Message, 4)
+	c, err := NewClient("mqtt://cam:secret@"+b.ln.Addr().String(), "pet-camera", []string{"pet-camera/trigger/+"}, func(m Message) { msgs <- m })
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.MinBackoff = 10 * time.Millisecond
+	c.Start()
+	defer c.Stop()
+
+	connect := recv(t, b.connects)
+	if !bytes.Contains(connect, []byte("pet-camera")) || !bytes.HasSuffix(connect, appendString(appendString(nil, "cam"), "secret")) || connect[7]&0xc2 != 0xc2 {
+		t.Errorf("CONNECT body %q", connect)
+	}
+	if sub := recv(t, b.subs); !bytes.Equal(sub[2:], append(appendString(nil, "pet-camera/trigger/+"), 1)) {
+		t.Errorf("SUBSCRIBE body %q", sub)
+	}
+	s := recv(t, b.conns)
+
+	publish(s, "pet-camera/trigger/old", "", 0, 0, true) // retained: skipped
+	publish(s, "pet-camera/trigger/doorbell", `{"source":"front"}`, 1, 7, false)
+	if id := recv(t, b.acks); id != 7 {
+		t.Errorf("PUBACK id %d", id)
+	}
+	if m := recv(t, msgs); m.Topic != "pet-camera/trigger/doorbell" || string(m.Payload) != `{"source":"front"}` {
+		t.Errorf("message %+v", m)
+	}
+	if st := c.Status(); !st.Connected || st.Received != 1 || st.Broker != "mqtt://"+b.ln.Addr().String() {
+		t.Errorf("status %+v", st)
+	}
+
+	// Dropped by the broker: reconnects and subscribes again
+	s.conn.Close()
+	recv(t, b.connects)
+	s = recv(t, b.conns)
+	publish(s, "pet-camera/trigger/motion", "", 0, 0, false)
+	if m := recv(t, msgs); m.Topic != "pet-camera/trigger/motion" {
+		t.Errorf("after reconnect %+v", m)
+	}
+	if st := c.Status(); st.Reconnects != 1 {
+		t.Errorf("reconnects %d", st.Reconnects)
+	}
+}
+
+func TestNewClientRejects(t *testing.T) {
+	for _, u := range []string{"http://broker", "mqtt://", "://"} {
+		if _, err := NewClient(u, "id", []string{"a"}, nil); err == nil {
+			t.Errorf("accepted %q", u)
+		}
+	}
+	if _, err := NewClient("mqtt://broker", "id", nil, nil); err == nil {
+		t.Error("accepted no filters")
+	}
+	c, err := NewClient("mqtts://broker", "id", []string{"a"}, nil)
+	if err != nil || c.url.Host != "broker:8883" {
+		t.Errorf("mqtts default port: %v", err)
+	}
+}
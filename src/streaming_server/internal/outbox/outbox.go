@@ -0,0 +1,466 @@
+// Package outbox is a disk-backed queue for outbound work that must survive
+// a dropped internet connection or a restart: push notifications, uploads.
+// Every job is a JSON file in the queue directory until its handler
+// succeeds. Failures are retried with exponential backoff; jobs that keep
+// failing past MaxAttempts or MaxAge, or fail with a Permanent error, move
+// to the dead/ subdirectory, where they stay until requeued or purged.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const deadDir = "dead"
+
+// Job is one queued operation, stored as <dir>/<ID>.json.
+type Job struct {
+	ID        string          `json:"id"` // enqueue time and sequence; sorts in enqueue order
+	Kind      string          `json:"kind"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+	Attempts  int             `json:"attempts"`
+	NextAt    time.Time       `json:"next_at"`
+	LastError string          `json:"last_error,omitempty"`
+}
+
+// Handler performs a job of one kind. A nil error removes the job.
+type Handler func(ctx context.Context, payload json.RawMessage) error
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error retrying cannot fix (bad payload, rejected
+// request); the job is dead-lettered at once.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// Stats summarizes the queue for the API and metrics.
+type Stats struct {
+	Pending       map[string]int `json:"pending"` // by kind
+	Dead          map[string]int `json:"dead"`    // by kind
+	Delivered     uint64         `json:"delivered"`
+	Retries       uint64         `json:"retries"`
+	OldestPending float64        `json:"oldest_pending_sec,omitempty"` // age of the oldest pending job
+	LastError     string         `json:"last_error,omitempty"`
+}
+
+// Queue is a disk-backed job queue with one worker.
+type Queue struct {
+	dir string
+
+	MaxAttempts int           // dead-letter after this many failures (0 = until MaxAge)
+	MaxAge      time.Duration // dead-letter failing jobs older than this (0 = never)
+	BaseBackoff time.Duration // delay after the first failure, doubled per failure
+	MaxBackoff  time.Duration
+	Timeout     time.Duration // per attempt
+
+	mu        sync.Mutex
+	handlers  map[string]Handler
+	pending   map[string]*Job
+	dead      map[string]*Job
+	seq       uint64
+	lastError string
+
+	delivered atomic.Uint64
+	retries   atomic.Uint64
+
+	wake    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	cancel  context.CancelFunc
+	stopped bool
+	now     func() time.Time
+}
+
+// Open loads the jobs left in dir (created if missing) by a previous run.
+func Open(dir string) (*Queue, error) {
+	if err := os.MkdirAll(filepath.Join(dir, deadDir), 0o755); err != nil {
+		return nil, err
+	}
+	q := &Queue{
+		dir:         dir,
+		MaxAge:      24 * time.Hour,
+		BaseBackoff: 5 * time.Second,
+		MaxBackoff:  10 * time.Minute,
+		Timeout:     10 * time.Minute,
+		handlers:    make(map[string]Handler),
+		wake:        make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+		now:         time.Now,
+	}
+	var err error
+	if q.pending, err = loadJobs(dir); err != nil {
+		return nil, err
+	}
+	if q.dead, err = loadJobs(filepath.Join(dir, deadDir)); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func loadJobs(dir string) (map[string]*Job, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	jobs := make(map[string]*Job)
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var j Job
+		if err := json.Unmarshal(data, &j); err != nil || j.ID+".json" != e.Name() {
+			logger.Warn("Outbox", "Skipping unreadable job %s: %v", e.Name(), err)
+			continue
+		}
+		jobs[j.ID] = &j
+	}
+	return jobs, nil
+}
+
+// writeJob stores j in dir atomically (temp file + rename).
+func writeJob(dir string, j *Job) error {
+	data, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, j.ID+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Register sets the handler of a kind. Call before Start.
+func (q *Queue) Register(kind string, h Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[kind] = h
+}
+
+// Enqueue stores a job for the kind's handler. It returns once the job is
+// on disk; delivery happens in the background.
+func (q *Queue) Enqueue(kind string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	q.mu.Lock()
+	now := q.now()
+	q.seq++
+	j := &Job{
+		ID:        fmt.Sprintf("%d-%06d", now.UnixNano(), q.seq%1000000),
+		Kind:      kind,
+		Payload:   data,
+		CreatedAt: now,
+		NextAt:    now,
+	}
+	err = writeJob(q.dir, j)
+	if err == nil {
+		q.pending[j.ID] = j
+	}
+	q.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	q.signal()
+	return nil
+}
+
+func (q *Queue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the worker.
+func (q *Queue) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	q.cancel = cancel
+	go q.run(ctx)
+}
+
+// Stop halts the worker, cancelling the job in flight (it stays queued).
+func (q *Queue) Stop() {
+	q.mu.Lock()
+	if q.stopped || q.cancel == nil {
+		q.stopped = true
+		q.mu.Unlock()
+		return
+	}
+	q.stopped = true
+	close(q.stop)
+	q.mu.Unlock()
+	q.cancel()
+	<-q.done
+}
+
+func (q *Queue) run(ctx context.Context) {
+	defer close(q.done)
+	for {
+		wait := q.runDue(ctx)
+		var timer *time.Timer
+		var fire <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			fire = timer.C
+		}
+		select {
+		case <-q.stop:
+			return
+		case <-q.wake:
+		case <-fire:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// due returns the pending jobs whose time has come, oldest first, and how
+// long until the next one otherwise (0 = none pending).
+func (q *Queue) due(now time.Time) ([]*Job, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var jobs []*Job
+	var next time.Duration
+	for _, j := range q.pending {
+		if !j.NextAt.After(now) {
+			jobs = append(jobs, j)
+		} else if d := j.NextAt.Sub(now); next == 0 || d < next {
+			next = d
+		}
+	}
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].ID < jobs[b].ID })
+	return jobs, next
+}
+
+// runDue attempts every due job once and returns how long until the next
+// one is due (0 = none pending).
+func (q *Queue) runDue(ctx context.Context) time.Duration {
+	for {
+		jobs, next := q.due(q.now())
+		if len(jobs) == 0 || ctx.Err() != nil {
+			return next
+		}
+		for _, j := range jobs {
+			if ctx.Err() != nil {
+				break
+			}
+			q.attempt(ctx, j)
+		}
+	}
+}
+
+func (q *Queue) attempt(ctx context.Context, j *Job) {
+	q.mu.Lock()
+	h := q.handlers[j.Kind]
+	q.mu.Unlock()
+
+	var err error
+	if h == nil {
+		err = Permanent(fmt.Errorf("no handler for %q", j.Kind))
+	} else {
+		actx, cancel := context.WithTimeout(ctx, q.Timeout)
+		err = h(actx, j.Payload)
+		cancel()
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pending[j.ID] != j {
+		return // purged or requeued meanwhile
+	}
+	if err == nil {
+		delete(q.pending, j.ID)
+		if rmErr := os.Remove(filepath.Join(q.dir, j.ID+".json")); rmErr != nil && !os.IsNotExist(rmErr) {
+			logger.Warn("Outbox", "Failed to remove delivered job %s: %v", j.ID, rmErr)
+		}
+		q.delivered.Add(1)
+		return
+	}
+	if ctx.Err() != nil {
+		return // shutting down; not the job's fault
+	}
+
+	now := q.now()
+	j.Attempts++
+	j.LastError = err.Error()
+	q.lastError = j.Kind + ": " + j.LastError
+	var perm permanentError
+	if errors.As(err, &perm) || (q.MaxAttempts > 0 && j.Attempts >= q.MaxAttempts) || (q.MaxAge > 0 && now.Sub(j.CreatedAt) >= q.MaxAge) {
+		q.deadLetterLocked(j)
+		return
+	}
+	q.retries.Add(1)
+	j.NextAt = now.Add(q.backoff(j.Attempts))
+	if werr := writeJob(q.dir, j); werr != nil {
+		logger.Warn("Outbox", "Failed to persist job %s: %v", j.ID, werr)
+	}
+	logger.Debug("Outbox", "%s job %s failed (attempt %d, retry at %s): %v", j.Kind, j.ID, j.Attempts, j.NextAt.Format(time.TimeOnly), err)
+}
+
+// backoff is the delay after the n-th failure.
+func (q *Queue) backoff(n int) time.Duration {
+	d := q.BaseBackoff
+	for i := 1; i < n && d < q.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, q.MaxBackoff)
+}
+
+func (q *Queue) deadLetterLocked(j *Job) {
+	delete(q.pending, j.ID)
+	if err := writeJob(filepath.Join(q.dir, deadDir), j); err != nil {
+		logger.Warn("Outbox", "Failed to dead-letter job %s: %v", j.ID, err)
+	}
+	os.Remove(filepath.Join(q.dir, j.ID+".json"))
+	q.dead[j.ID] = j
+	logger.Warn("Outbox", "%s job %s dead-lettered after %d attempt(s): %s", j.Kind, j.ID, j.Attempts, j.LastError)
+}
+
+// Flush makes every pending job due now, and with requeueDead also moves
+// the dead letters back with their attempts reset. It returns how many
+// jobs are now due.
+func (q *Queue) Flush(requeueDead bool) (int, error) {
+	q.mu.Lock()
+	now := q.now()
+	var err error
+	if requeueDead {
+		for id, j := range q.dead {
+			j.Attempts, j.CreatedAt = 0, now
+			if werr := writeJob(q.dir, j); werr != nil {
+				err = werr
+				break
+			}
+			os.Remove(filepath.Join(q.dir, deadDir, id+".json"))
+			delete(q.dead, id)
+			q.pending[id] = j
+		}
+	}
+	for _, j := range q.pending {
+		j.NextAt = now
+	}
+	n := len(q.pending)
+	q.mu.Unlock()
+	q.signal()
+	return n, err
+}
+
+// PurgeDead deletes the dead letters and returns how many there were.
+func (q *Queue) PurgeDead() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := len(q.dead)
+	for id := range q.dead {
+		os.Remove(filepath.Join(q.dir, deadDir, id+".json"))
+	}
+	q.dead = make(map[string]*Job)
+	return n
+}
+
+// DeadLetters returns up to limit dead letters, newest first.
+func (q *Queue) DeadLetters(limit int) []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := make([]Job, 0, len(q.dead))
+	for _, j := range q.dead {
+		out = append(out, *j)
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].ID > out[b].ID })
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+// Stats returns queue depths by kind and delivery counters.
+func (q *Queue) Stats() Stats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	st := Stats{
+		Pending:   make(map[string]int),
+		Dead:      make(map[string]int),
+		Delivered: q.delivered.Load(),
+		Retries:   q.retries.Load(),
+		LastError: q.lastError,
+	}
+	var oldest time.Time
+	for _, j := range q.pending {
+		st.Pending[j.Kind]++
+		if oldest.IsZero() || j.CreatedAt.Before(oldest) {
+			oldest = j.CreatedAt
+		}
+	}
+	for _, j := range q.dead {
+		st.Dead[j.Kind]++
+	}
+	if !oldest.IsZero() {
+		st.OldestPending = q.now().Sub(oldest).Seconds()
+	}
+	return st
+}
+
+// kinds lists the registered kinds, so every kind has a series.
+func (q *Queue) kinds() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	kinds := make([]string, 0, len(q.handlers))
+	for k := range q.handlers {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+var (
+	pendingDesc   = prometheus.NewDesc("outbox_pending", "Jobs waiting in the outbound queue", []string{"kind"}, nil)
+	deadDesc      = prometheus.NewDesc("outbox_dead_letters", "Jobs given up on, kept in the dead-letter directory", []string{"kind"}, nil)
+	deliveredDesc = prometheus.NewDesc("outbox_delivered_total", "Jobs completed by their handler", nil, nil)
+	retriesDesc   = prometheus.NewDesc("outbox_retries_total", "Failed attempts rescheduled with backoff", nil, nil)
+	oldestDesc    = prometheus.NewDesc("outbox_oldest_pending_seconds", "Age of the oldest pending job (0 when empty)", nil, nil)
+)
+
+// Describe implements prometheus.Collector.
+func (q *Queue) Describe(ch chan<- *prometheus.Desc) {
+	ch <- pendingDesc
+	ch <- deadDesc
+	ch <- deliveredDesc
+	ch <- retriesDesc
+	ch <- oldestDesc
+}
+
+// Collect implements prometheus.Collector.
+func (q *Queue) Collect(ch chan<- prometheus.Metric) {
+	st := q.Stats()
+	for _, kind := range q.kinds() {
+		ch <- prometheus.MustNewConstMetric(pendingDesc, prometheus.GaugeValue, float64(st.Pending[kind]), kind)
+		ch <- prometheus.MustNewConstMetric(deadDesc, prometheus.GaugeValue, float64(st.Dead[kind]), kind)
+	}
+	ch <- prometheus.MustNewConstMetric(deliveredDesc, prometheus.CounterValue, float64(st.Delivered))
+	ch <- prometheus.MustNewConstMetric(retriesDesc, prometheus.CounterValue, float64(st.Retries))
+	ch <- prometheus.MustNewConstMetric(oldestDesc, prometheus.GaugeValue, st.OldestPending)
+}
@@ -0,0 +1,132 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQueueRetryAndReload(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	q.now = func() time.Time { return now }
+	offline := true
+	var sent []string
+	q.Register("push", func(ctx context.Context, payload json.RawMessage) error {
+		if offline {
+			return errors.New("dial tcp: network is unreachable")
+		}
+		var msg string
+		json.Unmarshal(payload, &msg)
+		sent = append(sent, msg)
+		return nil
+	})
+	q.Enqueue("push", "first")
+	q.Enqueue("push", "second")
+
+	// Two failures: 5s, then 10s of backoff
+	if wait := q.runDue(context.Background()); wait != 5*time.Second {
+		t.Fatalf("first backoff %v", wait)
+	}
+	now = now.Add(5 * time.Second)
+	if wait := q.runDue(context.Background()); wait != 10*time.Second {
+		t.Fatalf("second backoff %v", wait)
+	}
+	st := q.Stats()
+	if st.Pending["push"] != 2 || st.Retries != 4 || st.OldestPending != 5 || st.LastError == "" {
+		t.Fatalf("stats %+v", st)
+	}
+
+	// The jobs survive a restart; a flush delivers them in order at once
+	q, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.now = func() time.Time { return now }
+	q.Register("push", func(ctx context.Context, payload json.RawMessage) error {
+		var msg string
+		json.Unmarshal(payload, &msg)
+		sent = append(sent, msg)
+		return nil
+	})
+	if n, _ := q.Flush(false); n != 2 {
+		t.Fatalf("flushed %d", n)
+	}
+	if wait := q.runDue(context.Background()); wait != 0 || len(sent) != 2 || sent[0] != "first" {
+		t.Fatalf("after flush: wait %v, sent %v", wait, sent)
+	}
+	if st := q.Stats(); st.Pending["push"] != 0 || st.Delivered != 2 {
+		t.Errorf("stats %+v", st)
+	}
+}
+
+func TestQueueDeadLetters(t *testing.T) {
+	dir := t.TempDir()
+	q, _ := Open(dir)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	q.now = func() time.Time { return now }
+	q.MaxAge = time.Hour
+	fail := errors.New("503")
+	q.Register("upload", func(ctx context.Context, payload json.RawMessage) error { return fail })
+	q.Register("push", func(ctx context.Context, payload json.RawMessage) error {
+		return Permanent(errors.New("400 bad request"))
+	})
+	q.Enqueue("upload", map[string]string{"path": "a.mp4"})
+	q.Enqueue("push", "x")
+	q.Enqueue("webhook", "no handler")
+
+	q.runDue(context.Background())
+	if st := q.Stats(); st.Pending["upload"] != 1 || st.Dead["push"] != 1 || st.Dead["webhook"] != 1 {
+		t.Fatalf("permanent failures: %+v", st)
+	}
+	now = now.Add(time.Hour)
+	q.runDue(context.Background())
+	dead := q.DeadLetters(0)
+	if len(dead) != 3 || dead[0].Kind != "webhook" || dead[0].LastError == "" {
+		t.Fatalf("dead letters %+v", dead)
+	}
+
+	// Dead letters are kept across restarts until requeued or purged
+	q, _ = Open(dir)
+	q.now = func() time.Time { return now }
+	q.Register("upload", func(ctx context.Context, payload json.RawMessage) error { return nil })
+	if n, err := q.Flush(true); n != 3 || err != nil {
+		t.Fatalf("requeued %d: %v", n, err)
+	}
+	q.runDue(context.Background())
+	if st := q.Stats(); st.Delivered != 1 || st.Dead["upload"] != 0 || len(q.DeadLetters(0)) != 2 {
+		t.Fatalf("after requeue: %+v", st)
+	}
+	if n := q.PurgeDead(); n != 2 {
+		t.Errorf("purged %d", n)
+	}
+	if q, _ = Open(dir); len(q.DeadLetters(0)) != 0 || len(q.pending) != 0 {
+		t.Error("purged jobs came back")
+	}
+}
+
+func TestQueueWorker(t *testing.T) {
+	q, _ := Open(t.TempDir())
+	done := make(chan string, 1)
+	q.Register("push", func(ctx context.Context, payload json.RawMessage) error {
+		done <- string(payload)
+		return nil
+	})
+	q.Start()
+	defer q.Stop()
+	q.Enqueue("push", "hello")
+	select {
+	case got := <-done:
+		if got != `"hello"` {
+			t.Errorf("payload %s", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("job not delivered")
+	}
+}
@@ -0,0 +1,114 @@
+package ptz
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Pelco-D extended commands for absolute positioning. The argument is the
+// angle in hundredths of a degree, 0-35999.
+const (
+	pelcoSync        = 0xFF
+	pelcoSetPanPos   = 0x4B
+	pelcoSetTiltPos  = 0x4D
+	pelcoMaxAddress  = 255
+	pelcoCentidegree = 36000
+)
+
+// PelcoD drives a serial pan-tilt head or gimbal with Pelco-D absolute
+// positioning ("set pan position" / "set tilt position"). Negative angles
+// are sent as 360° minus the angle, the usual convention for heads that
+// accept them.
+type PelcoD struct {
+	device  string
+	address byte
+	port    io.WriteCloser
+}
+
+// NewPelcoD opens device (e.g. /dev/ttyUSB0) at baud, 8N1, for the head at
+// address (1-255).
+func NewPelcoD(device string, baud, address int) (*PelcoD, error) {
+	if address < 1 || address > pelcoMaxAddress {
+		return nil, fmt.Errorf("pelco-d address %d out of range (1-%d)", address, pelcoMaxAddress)
+	}
+	speed, ok := baudRates[baud]
+	if !ok {
+		return nil, fmt.Errorf("unsupported baud rate %d", baud)
+	}
+	f, err := os.OpenFile(device, os.O_WRONLY|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := setRaw(f, speed); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("configure %s: %w", device, err)
+	}
+	return &PelcoD{device: device, address: byte(address), port: f}, nil
+}
+
+var baudRates = map[int]uint32{
+	2400:   syscall.B2400,
+	4800:   syscall.B4800,
+	9600:   syscall.B9600,
+	19200:  syscall.B19200,
+	38400:  syscall.B38400,
+	115200: syscall.B115200,
+}
+
+// setRaw puts the tty in raw 8N1 mode at speed.
+func setRaw(f *os.File, speed uint32) error {
+	var t syscall.Termios
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), syscall.TCGETS, uintptr(unsafe.Pointer(&t))); errno != 0 {
+		return errno
+	}
+	t.Iflag = 0
+	t.Oflag = 0
+	t.Lflag = 0
+	t.Cflag = syscall.CS8 | syscall.CREAD | syscall.CLOCAL | speed
+	t.Ispeed, t.Ospeed = speed, speed
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), syscall.TCSETS, uintptr(unsafe.Pointer(&t))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// pelcoFrame builds a 7-byte Pelco-D message: sync, address, command 1 and
+// 2, data 1 and 2, and the checksum (sum of bytes 2-6 mod 256).
+func pelcoFrame(address, cmd2 byte, value uint16) []byte {
+	b := []byte{pelcoSync, address, 0x00, cmd2, byte(value >> 8), byte(value), 0}
+	var sum byte
+	for _, c := range b[1:6] {
+		sum += c
+	}
+	b[6] = sum
+	return b
+}
+
+// pelcoAngle converts degrees to the 0-35999 centidegree argument.
+func pelcoAngle(degrees float64) uint16 {
+	v := int(math.Round(degrees*100)) % pelcoCentidegree
+	if v < 0 {
+		v += pelcoCentidegree
+	}
+	return uint16(v)
+}
+
+// SetPosition sends the pan and tilt positions.
+func (p *PelcoD) SetPosition(pos Position) error {
+	msg := append(pelcoFrame(p.address, pelcoSetPanPos, pelcoAngle(pos.Pan)),
+		pelcoFrame(p.address, pelcoSetTiltPos, pelcoAngle(pos.Tilt))...)
+	_, err := p.port.Write(msg)
+	return err
+}
+
+// Name implements Backend.
+func (p *PelcoD) Name() string { return "pelco:" + p.device }
+
+// Close closes the serial port.
+func (p *PelcoD) Close() error {
+	return p.port.Close()
+}
@@ -0,0 +1,349 @@
+// Package ptz drives a pan-tilt camera mount.
+//
+// A Controller keeps the commanded position, clamps it to the mount's
+// limits, stores named presets and optionally follows a target (auto-
+// tracking). The mount itself is a Backend: hobby servos on PWM channels via
+// sysfs, or a gimbal speaking Pelco-D over a serial port. Backends are
+// position-only and open-loop: the controller assumes a commanded position
+// is reached, as neither kind of mount reports its angle back.
+//
+// Commands share one JSON shape (Command) so the REST API and any future
+// message transport decode the same thing.
+package ptz
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+)
+
+// Position is a mount orientation in degrees; 0,0 is home (straight ahead,
+// level). Positive pan turns right, positive tilt looks up.
+type Position struct {
+	Pan  float64 `json:"pan"`
+	Tilt float64 `json:"tilt"`
+}
+
+// Limits bound the commanded position, in degrees.
+type Limits struct {
+	PanMin  float64 `json:"pan_min"`
+	PanMax  float64 `json:"pan_max"`
+	TiltMin float64 `json:"tilt_min"`
+	TiltMax float64 `json:"tilt_max"`
+}
+
+func (l Limits) clamp(p Position) Position {
+	return Position{
+		Pan:  math.Max(l.PanMin, math.Min(l.PanMax, p.Pan)),
+		Tilt: math.Max(l.TiltMin, math.Min(l.TiltMax, p.Tilt)),
+	}
+}
+
+// Backend moves the mount.
+type Backend interface {
+	// SetPosition moves to p, already clamped to the limits.
+	SetPosition(p Position) error
+	// Name describes the backend for status output.
+	Name() string
+	Close() error
+}
+
+// Command actions.
+const (
+	ActionMove         = "move"          // absolute: Pan/Tilt (either may be omitted)
+	ActionRelative     = "relative"      // by Pan/Tilt degrees
+	ActionHome         = "home"          // to 0,0
+	ActionPreset       = "preset"        // to preset Name
+	ActionSavePreset   = "save_preset"   // store the current position as Name
+	ActionDeletePreset = "delete_preset" // remove preset Name
+	ActionTrack        = "track"         // auto-tracking on/off per Enabled
+)
+
+// Command is one PTZ request, e.g. {"action":"relative","pan":-5}.
+type Command struct {
+	Action  string   `json:"action"`
+	Pan     *float64 `json:"pan,omitempty"`
+	Tilt    *float64 `json:"tilt,omitempty"`
+	Name    string   `json:"name,omitempty"`
+	Enabled *bool    `json:"enabled,omitempty"`
+}
+
+// Status is the controller state served by the API.
+type Status struct {
+	Backend  string              `json:"backend"`
+	Position Position            `json:"position"`
+	Limits   Limits              `json:"limits"`
+	Presets  map[string]Position `json:"presets"`
+	Tracking bool                `json:"tracking"`
+	Moves    uint64              `json:"moves"`
+}
+
+// maxPresets bounds the stored presets; preset names are at most 64 bytes.
+const (
+	maxPresets    = 32
+	maxPresetName = 64
+)
+
+// Controller commands a Backend. Safe for concurrent use.
+type Controller struct {
+	// Auto-tracking parameters
+	HFOV, VFOV    float64       // field of view in degrees, to turn frame offsets into angles
+	TrackGain     float64       // fraction of the offset corrected per step (0-1)
+	TrackDeadband float64       // ignore offsets below this fraction of the half frame
+	TrackInterval time.Duration // minimum gap between tracking moves (servo settle + detection lag)
+
+	backend   Backend
+	limits    Limits
+	presetDoc kv.Doc
+
+	mu        sync.Mutex
+	pos       Position
+	presets   map[string]Position
+	tracking  bool
+	lastTrack time.Time
+	moves     uint64
+}
+
+// NewController creates a controller for backend, persisting presets to
+// presetDoc (the zero Doc = in memory only). The mount is not moved until
+// the first command.
+func NewController(backend Backend, limits Limits, presetDoc kv.Doc) *Controller {
+	return &Controller{
+		HFOV:          62,
+		VFOV:          37,
+		TrackGain:     0.5,
+		TrackDeadband: 0.15,
+		TrackInterval: 500 * time.Millisecond,
+		backend:       backend,
+		limits:        limits,
+		presetDoc:     presetDoc,
+		presets:       make(map[string]Position),
+	}
+}
+
+// DefaultLimits suit a 180° pan / 90° tilt servo hat.
+func DefaultLimits() Limits {
+	return Limits{PanMin: -90, PanMax: 90, TiltMin: -45, TiltMax: 45}
+}
+
+// LoadPresets replaces the presets with the persisted ones; without a
+// document there are none.
+func (c *Controller) LoadPresets() error {
+	var presets map[string]Position
+	if _, err := c.presetDoc.Load(&presets); err != nil {
+		return err
+	}
+	if presets == nil {
+		presets = make(map[string]Position)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.presets = presets
+	return nil
+}
+
+// savePresetsLocked persists the presets.
+func (c *Controller) savePresetsLocked() error {
+	return c.presetDoc.Save(c.presets)
+}
+
+var (
+	// ErrUnknownPreset is returned for a preset that does not exist.
+	ErrUnknownPreset = errors.New("unknown preset")
+	// ErrBackend wraps errors of the mount itself, as opposed to bad commands.
+	ErrBackend = errors.New("ptz backend")
+)
+
+// Apply executes cmd and returns the resulting status.
+func (c *Controller) Apply(cmd Command) (Status, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var err error
+	switch cmd.Action {
+	case ActionMove:
+		target := c.pos
+		if cmd.Pan != nil {
+			target.Pan = *cmd.Pan
+		}
+		if cmd.Tilt != nil {
+			target.Tilt = *cmd.Tilt
+		}
+		err = c.moveLocked(target)
+	case ActionRelative:
+		target := c.pos
+		if cmd.Pan != nil {
+			target.Pan += *cmd.Pan
+		}
+		if cmd.Tilt != nil {
+			target.Tilt += *cmd.Tilt
+		}
+		err = c.moveLocked(target)
+	case ActionHome:
+		err = c.moveLocked(Position{})
+	case ActionPreset:
+		p, ok := c.presets[cmd.Name]
+		if !ok {
+			return c.statusLocked(), fmt.Errorf("%w %q", ErrUnknownPreset, cmd.Name)
+		}
+		err = c.moveLocked(p)
+	case ActionSavePreset:
+		if cmd.Name == "" || len(cmd.Name) > maxPresetName {
+			return c.statusLocked(), fmt.Errorf("preset name must be 1-%d bytes", maxPresetName)
+		}
+		if _, exists := c.presets[cmd.Name]; !exists && len(c.presets) >= maxPresets {
+			return c.statusLocked(), fmt.Errorf("at most %d presets", maxPresets)
+		}
+		c.presets[cmd.Name] = c.pos
+		err = c.savePresetsLocked()
+	case ActionDeletePreset:
+		if _, ok := c.presets[cmd.Name]; !ok {
+			return c.statusLocked(), fmt.Errorf("%w %q", ErrUnknownPreset, cmd.Name)
+		}
+		delete(c.presets, cmd.Name)
+		err = c.savePresetsLocked()
+	case ActionTrack:
+		if cmd.Enabled == nil {
+			return c.statusLocked(), fmt.Errorf("track needs enabled")
+		}
+		c.tracking = *cmd.Enabled
+	default:
+		return c.statusLocked(), fmt.Errorf("unknown action %q", cmd.Action)
+	}
+	return c.statusLocked(), err
+}
+
+// moveLocked clamps target and moves the mount there. The position is only
+// updated when the backend accepted the move.
+func (c *Controller) moveLocked(target Position) error {
+	target = c.limits.clamp(target)
+	if err := c.backend.SetPosition(target); err != nil {
+		return fmt.Errorf("%w %s: %w", ErrBackend, c.backend.Name(), err)
+	}
+	c.pos = target
+	c.moves++
+	return nil
+}
+
+// Track nudges the mount toward a target whose center is at (x, y) as a
+// fraction of the frame (0,0 top left, 1,1 bottom right). It does nothing
+// unless tracking is enabled, the target is outside the deadband and
+// TrackInterval has passed since the last tracking move. It reports
+// whether the mount moved.
+func (c *Controller) Track(x, y float64, now time.Time) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.tracking || now.Sub(c.lastTrack) < c.TrackInterval {
+		return false, nil
+	}
+	// Offsets from the center, -1..1 of the half frame
+	dx, dy := 2*x-1, 2*y-1
+	if math.Abs(dx) < c.TrackDeadband && math.Abs(dy) < c.TrackDeadband {
+		return false, nil
+	}
+	target := c.pos
+	if math.Abs(dx) >= c.TrackDeadband {
+		target.Pan += dx * c.HFOV / 2 * c.TrackGain
+	}
+	if math.Abs(dy) >= c.TrackDeadband {
+		target.Tilt -= dy * c.VFOV / 2 * c.TrackGain // image y grows downward
+	}
+	if c.limits.clamp(target) == c.pos {
+		return false, nil // at a limit
+	}
+	c.lastTrack = now
+	return true, c.moveLocked(target)
+}
+
+// Tracking reports whether auto-tracking is enabled.
+func (c *Controller) Tracking() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tracking
+}
+
+// Status returns the current state.
+func (c *Controller) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.statusLocked()
+}
+
+func (c *Controller) statusLocked() Status {
+	presets := make(map[string]Position, len(c.presets))
+	for name, p := range c.presets {
+		presets[name] = p
+	}
+	return Status{
+		Backend:  c.backend.Name(),
+		Position: c.pos,
+		Limits:   c.limits,
+		Presets:  presets,
+		Tracking: c.tracking,
+		Moves:    c.moves,
+	}
+}
+
+// Close releases the backend.
+func (c *Controller) Close() error {
+	return c.backend.Close()
+}
+
+// Open creates the backend named by spec:
+//
+//	pwm:/sys/class/pwm/pwmchip0?pan=0&tilt=1
+//	pelco:/dev/ttyUSB0?baud=9600&addr=1
+//
+// See NewPWM and NewPelcoD for the parameters.
+func Open(spec string) (Backend, error) {
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("ptz backend %q: %w", spec, err)
+	}
+	q := u.Query()
+	intParam := func(name string, def int) (int, error) {
+		v := q.Get(name)
+		if v == "" {
+			return def, nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, fmt.Errorf("ptz backend %q: %s: %w", spec, name, err)
+		}
+		return n, nil
+	}
+	path := u.Opaque
+	if path == "" {
+		path = u.Path
+	}
+	switch u.Scheme {
+	case "pwm":
+		pan, err := intParam("pan", 0)
+		if err != nil {
+			return nil, err
+		}
+		tilt, err := intParam("tilt", 1)
+		if err != nil {
+			return nil, err
+		}
+		return NewPWM(path, pan, tilt)
+	case "pelco":
+		baud, err := intParam("baud", 9600)
+		if err != nil {
+			return nil, err
+		}
+		addr, err := intParam("addr", 1)
+		if err != nil {
+			return nil, err
+		}
+		return NewPelcoD(path, baud, addr)
+	default:
+		return nil, fmt.Errorf("ptz backend %q: want pwm:<pwmchip dir> or pelco:<serial device>", spec)
+	}
+}
@@ -0,0 +1,172 @@
+package ptz
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+)
+
+type fakeBackend struct {
+	moves []Position
+	fail  error
+}
+
+func (f *fakeBackend) SetPosition(p Position) error {
+	if f.fail != nil {
+		return f.fail
+	}
+	f.moves = append(f.moves, p)
+	return nil
+}
+func (f *fakeBackend) Name() string { return "fake" }
+func (f *fakeBackend) Close() error { return nil }
+
+func ptr(v float64) *float64 { return &v }
+
+func TestControllerCommands(t *testing.T) {
+	b := &fakeBackend{}
+	doc := kv.Doc{Store: kv.NewMemory(), Bucket: "ptz_presets", Key: "presets"}
+	c := NewController(b, DefaultLimits(), doc)
+
+	st, err := c.Apply(Command{Action: ActionMove, Pan: ptr(30), Tilt: ptr(10)})
+	if err != nil || st.Position != (Position{30, 10}) {
+		t.Fatalf("move: %+v, %v", st.Position, err)
+	}
+	st, _ = c.Apply(Command{Action: ActionRelative, Pan: ptr(100)})
+	if st.Position != (Position{90, 10}) {
+		t.Fatalf("relative not clamped: %+v", st.Position)
+	}
+	if _, err := c.Apply(Command{Action: ActionSavePreset, Name: "door"}); err != nil {
+		t.Fatal(err)
+	}
+	c.Apply(Command{Action: ActionHome})
+	st, err = c.Apply(Command{Action: ActionPreset, Name: "door"})
+	if err != nil || st.Position != (Position{90, 10}) || st.Moves != 4 {
+		t.Fatalf("preset: %+v, %v", st, err)
+	}
+	if _, err := c.Apply(Command{Action: ActionPreset, Name: "bed"}); !errors.Is(err, ErrUnknownPreset) {
+		t.Errorf("unknown preset: %v", err)
+	}
+	for _, bad := range []Command{{Action: "zoom"}, {Action: ActionTrack}, {Action: ActionSavePreset}} {
+		if _, err := c.Apply(bad); err == nil {
+			t.Errorf("%+v accepted", bad)
+		}
+	}
+
+	// Presets survive a restart
+	c2 := NewController(b, DefaultLimits(), doc)
+	if err := c2.LoadPresets(); err != nil {
+		t.Fatal(err)
+	}
+	if p := c2.Status().Presets["door"]; p != (Position{90, 10}) {
+		t.Errorf("reloaded preset %+v", p)
+	}
+
+	// A failed move keeps the last position
+	b.fail = errors.New("servo unplugged")
+	if _, err := c.Apply(Command{Action: ActionHome}); !errors.Is(err, ErrBackend) {
+		t.Errorf("backend error: %v", err)
+	}
+	if c.Status().Position != (Position{90, 10}) {
+		t.Error("position changed by a failed move")
+	}
+}
+
+func TestControllerTrack(t *testing.T) {
+	b := &fakeBackend{}
+	c := NewController(b, DefaultLimits(), kv.Doc{})
+	now := time.Now()
+
+	if moved, _ := c.Track(1, 0.5, now); moved {
+		t.Fatal("moved with tracking off")
+	}
+	on := true
+	c.Apply(Command{Action: ActionTrack, Enabled: &on})
+
+	if moved, _ := c.Track(0.55, 0.45, now); moved {
+		t.Error("moved inside the deadband")
+	}
+	// Target at the right edge, above center: pan right by half of HFOV/2, tilt up
+	moved, err := c.Track(1, 0.25, now)
+	if err != nil || !moved {
+		t.Fatalf("no tracking move: %v", err)
+	}
+	if p := c.Status().Position; p.Pan != 15.5 || p.Tilt != 4.625 {
+		t.Errorf("tracked to %+v", p)
+	}
+	if moved, _ := c.Track(1, 0.25, now.Add(100*time.Millisecond)); moved {
+		t.Error("moved again within TrackInterval")
+	}
+	if moved, _ := c.Track(0, 0.5, now.Add(time.Second)); !moved || c.Status().Position.Pan != 0 {
+		t.Errorf("tracking left: %+v", c.Status().Position)
+	}
+}
+
+func TestPWM(t *testing.T) {
+	chip := t.TempDir()
+	for _, ch := range []string{"pwm0", "pwm1"} {
+		os.Mkdir(filepath.Join(chip, ch), 0o755)
+	}
+	p, err := NewPWM(chip, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetPosition(Position{Pan: 90, Tilt: -45}); err != nil {
+		t.Fatal(err)
+	}
+	read := func(ch, attr string) string {
+		b, _ := os.ReadFile(filepath.Join(chip, ch, attr))
+		return string(b)
+	}
+	if read("pwm0", "period") != "20000000" || read("pwm0", "duty_cycle") != "2499990" ||
+		read("pwm1", "duty_cycle") != "1000005" || read("pwm1", "enable") != "1" {
+		t.Errorf("sysfs: pan %s/%s, tilt %s/%s", read("pwm0", "period"), read("pwm0", "duty_cycle"),
+			read("pwm1", "duty_cycle"), read("pwm1", "enable"))
+	}
+	p.Close()
+	if read("pwm0", "enable") != "0" {
+		t.Error("outputs left enabled")
+	}
+}
+
+type bufCloser struct{ bytes.Buffer }
+
+func (*bufCloser) Close() error { return nil }
+
+func TestPelcoD(t *testing.T) {
+	port := &bufCloser{}
+	p := &PelcoD{address: 1, port: port}
+	if err := p.SetPosition(Position{Pan: 90, Tilt: -10}); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0xFF, 0x01, 0x00, 0x4B, 0x23, 0x28, 0x97, // pan 9000
+		0xFF, 0x01, 0x00, 0x4D, 0x88, 0xB8, 0x8E, // tilt 35000 (-10°)
+	}
+	if !bytes.Equal(port.Bytes(), want) {
+		t.Errorf("frames % X, want % X", port.Bytes(), want)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	for _, spec := range []string{"gimbal:/dev/ttyS0", "pwm:/x?pan=a", "pelco:/dev/null?addr=0", "pelco:/dev/null?baud=1234"} {
+		if _, err := Open(spec); err == nil {
+			t.Errorf("Open(%q) accepted", spec)
+		}
+	}
+	chip := t.TempDir()
+	os.Mkdir(filepath.Join(chip, "pwm2"), 0o755)
+	os.Mkdir(filepath.Join(chip, "pwm3"), 0o755)
+	b, err := Open("pwm:" + chip + "?pan=2&tilt=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Name() != "pwm:"+chip {
+		t.Errorf("name %q", b.Name())
+	}
+}
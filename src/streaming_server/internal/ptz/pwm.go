@@ -0,0 +1,107 @@
+package ptz
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Hobby servo timing: a 50 Hz frame whose pulse width sets the angle,
+// 1.5 ms at center and ±1 ms at ±90°.
+const (
+	servoPeriodNs    = 20_000_000
+	servoCenterNs    = 1_500_000
+	servoNsPerDegree = 1_000_000 / 90
+)
+
+// PWM drives two hobby servos (pan, tilt) on the channels of a sysfs PWM
+// chip (e.g. /sys/class/pwm/pwmchip0 on the RDK X5's 40-pin header, with the
+// PWM pins enabled in the device tree).
+type PWM struct {
+	chip      string
+	pan, tilt string // channel directories
+}
+
+// NewPWM exports the pan and tilt channels of chip if needed and sets the
+// servo period. The servos are not enabled until the first SetPosition.
+func NewPWM(chip string, panChannel, tiltChannel int) (*PWM, error) {
+	p := &PWM{chip: chip}
+	var err error
+	if p.pan, err = exportPWM(chip, panChannel); err != nil {
+		return nil, err
+	}
+	if p.tilt, err = exportPWM(chip, tiltChannel); err != nil {
+		return nil, err
+	}
+	for _, ch := range []string{p.pan, p.tilt} {
+		if err := writeSysfs(ch, "period", servoPeriodNs); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// exportPWM returns the channel directory, exporting it first if the kernel
+// has not created it yet.
+func exportPWM(chip string, channel int) (string, error) {
+	dir := filepath.Join(chip, "pwm"+strconv.Itoa(channel))
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+	if err := writeSysfs(chip, "export", channel); err != nil {
+		return "", err
+	}
+	// udev may take a moment to create the attributes
+	for range 20 {
+		if _, err := os.Stat(filepath.Join(dir, "period")); err == nil {
+			return dir, nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return "", fmt.Errorf("pwm channel %d of %s did not appear after export", channel, chip)
+}
+
+func writeSysfs(dir, attr string, v int) error {
+	path := filepath.Join(dir, attr)
+	if err := os.WriteFile(path, []byte(strconv.Itoa(v)), 0); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
+
+// servoPulseNs converts an angle to a pulse width.
+func servoPulseNs(degrees float64) int {
+	return servoCenterNs + int(degrees*servoNsPerDegree)
+}
+
+// SetPosition sets both pulse widths and enables the outputs.
+func (p *PWM) SetPosition(pos Position) error {
+	for _, ch := range []struct {
+		dir     string
+		degrees float64
+	}{{p.pan, pos.Pan}, {p.tilt, pos.Tilt}} {
+		if err := writeSysfs(ch.dir, "duty_cycle", servoPulseNs(ch.degrees)); err != nil {
+			return err
+		}
+		if err := writeSysfs(ch.dir, "enable", 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Name implements Backend.
+func (p *PWM) Name() string { return "pwm:" + p.chip }
+
+// Close disables the outputs, leaving the servos unpowered where they are.
+func (p *PWM) Close() error {
+	var first error
+	for _, ch := range []string{p.pan, p.tilt} {
+		if err := writeSysfs(ch, "enable", 0); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -1,15 +1,26 @@
 package recorder
 
 import (
+	"context"
+	"crypto/ed25519"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
 	"time"
 
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/clock"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/codec"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/fanout"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/watermark"
 	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/types"
 )
 
+// ErrAlreadyRecording is returned by Start while a recording is active.
+var ErrAlreadyRecording = errors.New("already recording")
+
 // Recorder records H.264 frames to file
 type Recorder struct {
 	mu           sync.RWMutex
@@ -20,15 +31,44 @@ type Recorder struct {
 	frameCount   uint64
 	bytesWritten uint64
 	startTime    time.Time
-	frameChan    chan *types.VideoFrame
-	closeChan    chan struct{}
+	queue        *fanout.Queue[*types.VideoFrame] // frames waiting for the writer, per recording
+	queueSize    int
+	release      func(*types.VideoFrame) // called once the writer is done with a frame
+	spoolDir     string                  // overflow spool of the queue ("" = none)
+	spoolMax     int64
+	spool        *frameSpool // of the current (or last) recording
 	wg           sync.WaitGroup
 
 	// Header management
-	vpsCache        []byte
-	spsCache        []byte
-	ppsCache        []byte
-	firstIDRWritten bool
+	vpsCache []byte
+	spsCache []byte
+	ppsCache []byte
+
+	// Keyframe gating: frames before the first IDR cannot be decoded, so
+	// they are dropped until one arrives (at most one GOP, 1s).
+	waitingKeyframe bool
+	skippedFrames   uint64
+
+	// Timed recordings (RecordFor): the deadline, and once it passed, the
+	// wait for the IDR that ends the last GOP.
+	until     time.Time
+	timedStop chan struct{}     // closed by Stop; nil for an open-ended recording
+	stopAtIDR bool              // the next IDR ends the recording
+	gopEnded  chan struct{}     // closed at that IDR
+	gopClosed bool              // that IDR was seen; no more frames are written
+	gop       *codec.GOPTracker // measures the GOPs for that wait (nil = 1s GOPs)
+
+	// Startup buffer (SetStartupBuffer): the GOP in progress while not
+	// recording, written ahead of the first live frame
+	startup       *startupBuffer
+	startupFrames int // frames the current recording started with
+
+	// Watermarking (SetWatermark): a hash chain over the file, anchored at
+	// every IDR and signed into a manifest on Stop. chain is only touched
+	// by the writer goroutine while recording.
+	deviceID  string
+	deviceKey ed25519.PrivateKey
+	chain     *watermark.Chain
 }
 
 // NewRecorder creates a new recorder
@@ -36,22 +76,78 @@ func NewRecorder(basePath string) *Recorder {
 	return &Recorder{
 		basePath:  basePath,
 		recording: false,
-		frameChan: make(chan *types.VideoFrame, 60), // Buffer 2 seconds
-		closeChan: make(chan struct{}),
+		queueSize: 60, // 2 seconds
+	}
+}
+
+// SetQueueSize sets how many frames may wait for the writer goroutine
+// (default 60, 2 seconds). Takes effect with the next recording.
+func (r *Recorder) SetQueueSize(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if n > 0 {
+		r.queueSize = n
+	}
+}
+
+// SetRelease sets a function called with every frame SendFrame accepted,
+// once the writer has written or skipped it, e.g. to return its buffer to
+// a pool.
+func (r *Recorder) SetRelease(release func(*types.VideoFrame)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.release = release
+}
+
+// SetSpool lets frames overflow the writer queue into a temporary file in
+// dir of at most maxBytes while the writer is stalled, instead of being
+// dropped. dir should not be on the recordings' disk; a tmpfs is best.
+// Takes effect with the next recording.
+func (r *Recorder) SetSpool(dir string, maxBytes int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spoolDir, r.spoolMax = dir, maxBytes
+}
+
+// SetWatermark makes every recording tamper-evident: a hash chain anchored
+// in the stream and a manifest signed with key (see package watermark).
+func (r *Recorder) SetWatermark(deviceID string, key ed25519.PrivateKey) error {
+	if len(deviceID) > watermark.MaxDeviceID {
+		return fmt.Errorf("device ID is %d bytes, at most %d", len(deviceID), watermark.MaxDeviceID)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deviceID = deviceID
+	r.deviceKey = key
+	return nil
+}
+
+// QueueStats returns the writer queue of the current (or last) recording.
+func (r *Recorder) QueueStats() fanout.Stats {
+	r.mu.RLock()
+	q, size := r.queue, r.queueSize
+	r.mu.RUnlock()
+	if q == nil {
+		return fanout.Stats{Name: "recorder_writer", Class: fanout.Reliable.String(), Capacity: size}
 	}
+	return q.Stats()
 }
 
 // Start starts recording to a new file
 func (r *Recorder) Start() error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	return r.startLocked()
+}
 
+// startLocked starts a recording. Caller holds r.mu.
+func (r *Recorder) startLocked() error {
 	if r.recording {
-		return fmt.Errorf("already recording")
+		return ErrAlreadyRecording
 	}
 
 	// Generate filename with timestamp
-	timestamp := time.Now().Format("20060102_150405")
+	timestamp := clock.FileStamp(time.Now())
 	filename := fmt.Sprintf("recording_%s.hevc", timestamp)
 	filepath := filepath.Join(r.basePath, filename)
 
@@ -60,6 +156,13 @@ func (r *Recorder) Start() error {
 	if err != nil {
 		return fmt.Errorf("failed to create file: %w", err)
 	}
+	r.chain = nil
+	if r.deviceKey != nil {
+		if r.chain, err = watermark.NewChain(r.deviceID); err != nil {
+			file.Close()
+			return err
+		}
+	}
 
 	// Initialize state
 	r.file = file
@@ -68,11 +171,30 @@ func (r *Recorder) Start() error {
 	r.frameCount = 0
 	r.bytesWritten = 0
 	r.startTime = time.Now()
-	r.firstIDRWritten = false
+	r.waitingKeyframe = true
+	r.skippedFrames = 0
+	r.until = time.Time{}
+	r.stopAtIDR, r.gopEnded, r.gopClosed = false, nil, false
+	opts := fanout.Options[*types.VideoFrame]{
+		Class:    fanout.Reliable,
+		Capacity: r.queueSize,
+	}
+	r.spool = nil
+	if r.spoolDir != "" && r.spoolMax > 0 {
+		if r.spool, err = newFrameSpool(r.spoolDir, r.spoolMax, r.release); err != nil {
+			logger.Warn("Recorder", "No overflow spool, frames are dropped while the writer stalls: %v", err)
+			r.spool = nil
+		} else {
+			opts.Spill = r.spool
+		}
+	}
+	r.queue = fanout.NewQueue("recorder_writer", opts)
+
+	r.queueStartupLocked()
 
 	// Start recorder goroutine
 	r.wg.Add(1)
-	go r.writeFrames()
+	go r.writeFrames(r.queue, r.spool, r.release)
 
 	return nil
 }
@@ -87,9 +209,14 @@ func (r *Recorder) Stop() error {
 	}
 
 	r.recording = false
+	r.queue.Close()
+	if r.timedStop != nil {
+		close(r.timedStop)
+		r.timedStop = nil
+	}
 	r.mu.Unlock()
 
-	// Wait for write goroutine to finish
+	// Wait for write goroutine to finish the queued frames
 	r.wg.Wait()
 
 	// Close file
@@ -105,6 +232,17 @@ func (r *Recorder) Stop() error {
 		}
 		r.file = nil
 	}
+	if r.chain != nil {
+		m := r.chain.Manifest(r.filename, r.startTime, time.Now())
+		r.chain = nil
+		if err := m.Sign(r.deviceKey); err != nil {
+			return err
+		}
+		path := watermark.ManifestPath(filepath.Join(r.basePath, r.filename))
+		if err := watermark.WriteManifest(path, m); err != nil {
+			return fmt.Errorf("failed to write manifest: %w", err)
+		}
+	}
 
 	return nil
 }
@@ -128,49 +266,34 @@ func (r *Recorder) UpdateHeaders(vps, sps, pps []byte) {
 	}
 }
 
-// SendFrame sends a frame to the recorder (non-blocking)
+// SendFrame queues a frame for the writer without waiting. It returns false
+// when not recording or the queue is full; the frame then stays the
+// caller's, otherwise it is handed to the release function after writing.
 func (r *Recorder) SendFrame(frame *types.VideoFrame) bool {
 	r.mu.RLock()
+	q := r.queue
 	recording := r.recording
 	r.mu.RUnlock()
 
-	if !recording {
-		return false
-	}
-
-	// Non-blocking send
-	select {
-	case r.frameChan <- frame:
-		return true
-	default:
-		// Channel full, drop frame
-		return false
-	}
+	return recording && q.Push(frame)
 }
 
-// writeFrames writes frames to file
-func (r *Recorder) writeFrames() {
+// writeFrames writes the frames of q, including those it spooled, to file
+// until Stop closes it.
+func (r *Recorder) writeFrames(q *fanout.Queue[*types.VideoFrame], spool *frameSpool, release func(*types.VideoFrame)) {
 	defer r.wg.Done()
 
 	for {
-		r.mu.RLock()
-		recording := r.recording
-		r.mu.RUnlock()
-
-		if !recording {
-			// Drain remaining frames
-			for len(r.frameChan) > 0 {
-				frame := <-r.frameChan
-				r.writeFrame(frame)
+		frame, ok := q.Pop(context.Background())
+		if !ok {
+			if spool != nil {
+				spool.Close()
 			}
 			return
 		}
-
-		select {
-		case frame := <-r.frameChan:
-			r.writeFrame(frame)
-		case <-time.After(100 * time.Millisecond):
-			// Check recording state periodically
+		r.writeFrame(frame)
+		if release != nil {
+			release(frame)
 		}
 	}
 }
@@ -179,22 +302,40 @@ func (r *Recorder) writeFrames() {
 func (r *Recorder) writeFrame(frame *types.VideoFrame) {
 	r.mu.Lock()
 
-	if r.file == nil {
+	if r.file == nil || r.gopEndedLocked(frame) {
 		r.mu.Unlock()
 		return
 	}
 
-	var dataToWrite []byte
+	var dataToWrite, anchor []byte
+
+	if r.waitingKeyframe {
+		if !frame.IsIDR {
+			// Undecodable without the preceding keyframe
+			r.skippedFrames++
+			r.mu.Unlock()
+			return
+		}
+		r.waitingKeyframe = false
+	}
+
+	// Each GOP starts with the chain's anchor, so a clip cut at an IDR
+	// still carries the link before it
+	if frame.IsIDR && r.chain != nil {
+		anchor = r.chain.Anchor(frame.Timestamp)
+	}
 
 	// If this is the first IDR frame and we have cached headers, prepend them
-	if frame.IsIDR && !r.firstIDRWritten && len(r.vpsCache) > 0 && len(r.spsCache) > 0 && len(r.ppsCache) > 0 {
+	if frame.IsIDR && r.frameCount == 0 && len(r.vpsCache) > 0 && len(r.spsCache) > 0 && len(r.ppsCache) > 0 {
 		// Prepend VPS/SPS/PPS headers to ensure playability
-		dataToWrite = make([]byte, 0, len(r.vpsCache)+len(r.spsCache)+len(r.ppsCache)+len(frame.Data))
+		dataToWrite = make([]byte, 0, len(anchor)+len(r.vpsCache)+len(r.spsCache)+len(r.ppsCache)+len(frame.Data))
+		dataToWrite = append(dataToWrite, anchor...)
 		dataToWrite = append(dataToWrite, r.vpsCache...)
 		dataToWrite = append(dataToWrite, r.spsCache...)
 		dataToWrite = append(dataToWrite, r.ppsCache...)
 		dataToWrite = append(dataToWrite, frame.Data...)
-		r.firstIDRWritten = true
+	} else if anchor != nil {
+		dataToWrite = append(anchor, frame.Data...)
 	} else {
 		// Write frame as-is
 		dataToWrite = frame.Data
@@ -206,11 +347,15 @@ func (r *Recorder) writeFrame(frame *types.VideoFrame) {
 	r.bytesWritten += expectedLen
 	r.frameCount++
 	file := r.file // Capture file reference before releasing the lock
+	chain := r.chain
 
 	r.mu.Unlock()
 
 	// Write OUTSIDE lock — disk I/O won't block other operations
 	n, err := file.Write(dataToWrite)
+	if chain != nil {
+		chain.Write(dataToWrite[:n])
+	}
 	if err != nil {
 		// Log error but continue; reverse the optimistic counter updates
 		r.mu.Lock()
@@ -244,6 +389,16 @@ func (r *Recorder) GetStatus() RecordingStatus {
 	if r.recording {
 		duration = time.Since(r.startTime)
 	}
+	var spooled uint64
+	if r.spool != nil {
+		spooled = r.spool.total.Load()
+	}
+
+	var until *time.Time
+	if r.recording && !r.until.IsZero() {
+		u := r.until
+		until = &u
+	}
 
 	return RecordingStatus{
 		Recording:    r.recording,
@@ -252,6 +407,12 @@ func (r *Recorder) GetStatus() RecordingStatus {
 		BytesWritten: r.bytesWritten,
 		Duration:     duration,
 		StartTime:    r.startTime,
+
+		WaitingForKeyframe: r.recording && r.waitingKeyframe,
+		SkippedFrames:      r.skippedFrames,
+		SpooledFrames:      spooled,
+		StartupFrames:      r.startupFrames,
+		Until:              until,
 	}
 }
 
@@ -260,7 +421,6 @@ func (r *Recorder) Close() error {
 	if r.IsRecording() {
 		return r.Stop()
 	}
-	close(r.closeChan)
 	return nil
 }
 
@@ -272,4 +432,18 @@ type RecordingStatus struct {
 	BytesWritten uint64        `json:"bytes_written"`
 	Duration     time.Duration `json:"duration_ms"`
 	StartTime    time.Time     `json:"start_time"`
+
+	// True from Start until the first IDR is written; frames dropped
+	// meanwhile are counted in SkippedFrames.
+	WaitingForKeyframe bool   `json:"waiting_for_keyframe"`
+	SkippedFrames      uint64 `json:"skipped_frames"`
+
+	// Frames that overflowed the writer queue into the spool (SetSpool)
+	SpooledFrames uint64 `json:"spooled_frames"`
+
+	// Frames of the GOP in progress at Start, from the startup buffer
+	StartupFrames int `json:"startup_frames"`
+
+	// When a timed recording (RecordFor) stops; nil for an open-ended one
+	Until *time.Time `json:"until,omitempty"`
 }
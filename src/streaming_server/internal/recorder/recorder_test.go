@@ -0,0 +1,204 @@
+package recorder
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/watermark"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/types"
+)
+
+func TestRecorderWaitsForKeyframe(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRecorder(dir)
+	vps, sps, pps := []byte{0, 0, 0, 1, 0x40}, []byte{0, 0, 0, 1, 0x42}, []byte{0, 0, 0, 1, 0x44}
+	r.UpdateHeaders(vps, sps, pps)
+
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if !r.GetStatus().WaitingForKeyframe {
+		t.Error("expected waiting_for_keyframe right after Start")
+	}
+
+	leading := &types.VideoFrame{Data: []byte{0, 0, 0, 1, 0x02, 0xAA}}
+	idr := &types.VideoFrame{Data: []byte{0, 0, 0, 1, 0x26, 0xBB}, IsIDR: true}
+	trail := &types.VideoFrame{Data: []byte{0, 0, 0, 1, 0x02, 0xCC}}
+	for _, f := range []*types.VideoFrame{leading, idr, trail} {
+		if !r.SendFrame(f) {
+			t.Fatal("SendFrame dropped a frame")
+		}
+	}
+	if err := r.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	st := r.GetStatus()
+	if st.WaitingForKeyframe || st.SkippedFrames != 1 || st.FrameCount != 2 {
+		t.Errorf("status = %+v, want 1 skipped, 2 written, not waiting", st)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, st.Filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []byte
+	for _, b := range [][]byte{vps, sps, pps, idr.Data, trail.Data} {
+		want = append(want, b...)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("file = %x, want %x", got, want)
+	}
+}
+
+func TestRecorderWatermark(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRecorder(dir)
+	_, key, _ := ed25519.GenerateKey(nil)
+	if err := r.SetWatermark("pet-cam-1", key); err != nil {
+		t.Fatal(err)
+	}
+	r.UpdateHeaders([]byte{0, 0, 0, 1, 0x40, 0x01}, []byte{0, 0, 0, 1, 0x42, 0x01}, []byte{0, 0, 0, 1, 0x44, 0x01})
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 90 {
+		f := &types.VideoFrame{Data: []byte{0, 0, 0, 1, 0x02, 0x01, byte(i + 1)}, Timestamp: time.Unix(int64(i), 0)}
+		if i%30 == 0 {
+			f.Data, f.IsIDR = []byte{0, 0, 0, 1, 0x28, 0x01, byte(i + 1)}, true
+		}
+		for !r.SendFrame(f) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if err := r.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	clip := filepath.Join(dir, r.GetStatus().Filename)
+	m, err := watermark.ReadManifest(watermark.ManifestPath(clip))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.VerifySignature(key.Public().(ed25519.PublicKey)); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(clip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rep, err := watermark.Verify(f, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Anchors != 3 || m.Bytes != r.GetStatus().BytesWritten {
+		t.Errorf("report %+v, manifest %+v", rep, m)
+	}
+}
+
+func TestRecordFor(t *testing.T) {
+	r := NewRecorder(t.TempDir())
+	if _, err := r.RecordFor(MaxTimedDuration + time.Second); err == nil {
+		t.Error("duration above the limit accepted")
+	}
+	first, err := r.RecordFor(50 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Start(); !errors.Is(err, ErrAlreadyRecording) {
+		t.Errorf("Start during a timed recording: %v", err)
+	}
+	ext, err := r.RecordFor(200 * time.Millisecond)
+	if err != nil || !ext.Extended || ext.Filename != first.Filename || !ext.Until.After(first.Until) {
+		t.Fatalf("extend: %+v %v", ext, err)
+	}
+	if again, _ := r.RecordFor(time.Millisecond); !again.Until.Equal(ext.Until) {
+		t.Errorf("a shorter request moved the deadline to %v", again.Until)
+	}
+	if st := r.GetStatus(); st.Until == nil || !st.Until.Equal(ext.Until) {
+		t.Errorf("status until %v", st.Until)
+	}
+
+	send := func(idr bool) {
+		f := &types.VideoFrame{Data: []byte{0, 0, 0, 1, 0x02, 0x01}, IsIDR: idr}
+		for !r.SendFrame(f) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	send(true)
+	send(false)
+	// Past the deadline the GOP in progress is still written, up to the next IDR
+	for waiting := true; waiting; time.Sleep(5 * time.Millisecond) {
+		r.mu.RLock()
+		waiting = !r.stopAtIDR
+		r.mu.RUnlock()
+	}
+	send(false)
+	send(true)
+	deadline := time.Now().Add(time.Second)
+	for r.IsRecording() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if st := r.GetStatus(); st.Recording || st.FrameCount != 3 || st.Until != nil {
+		t.Errorf("after the deadline: %+v", st)
+	}
+
+	// An open-ended recording is not taken over
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.RecordFor(time.Second); !errors.Is(err, ErrAlreadyRecording) {
+		t.Errorf("RecordFor during an open-ended recording: %v", err)
+	}
+	r.Stop()
+}
+
+func TestRecorderStartupBuffer(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRecorder(dir)
+	var released int
+	r.SetRelease(func(*types.VideoFrame) { released++ })
+	r.SetStartupBuffer(16)
+	if !r.Buffering() {
+		t.Fatal("not buffering")
+	}
+
+	frame := func(b byte, idr bool) *types.VideoFrame {
+		return &types.VideoFrame{Data: []byte{0, 0, 0, 1, 0x02, b}, IsIDR: idr}
+	}
+	r.Buffer(frame(0xA0, false)) // mid-GOP: dropped
+	r.Buffer(frame(0xB0, true))
+	r.Buffer(frame(0xB1, false))
+	r.Buffer(frame(0xB2, false)) // outgrows 16 bytes: the GOP is let go
+	r.Buffer(frame(0xB3, false))
+	r.Buffer(frame(0xC0, true))
+	r.Buffer(frame(0xC1, false))
+	if released != 5 {
+		t.Errorf("%d frames released before Start, want 5", released)
+	}
+
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if r.Buffering() || r.Buffer(frame(0xC2, false)) {
+		t.Error("buffering while recording")
+	}
+	r.SendFrame(frame(0xC2, false))
+	if err := r.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	st := r.GetStatus()
+	if st.StartupFrames != 2 || st.FrameCount != 3 || st.SkippedFrames != 0 {
+		t.Errorf("status = %+v, want 2 startup frames, 3 written", st)
+	}
+	got, _ := os.ReadFile(filepath.Join(dir, st.Filename))
+	if want := []byte{0, 0, 0, 1, 0x02, 0xC0, 0, 0, 0, 1, 0x02, 0xC1, 0, 0, 0, 1, 0x02, 0xC2}; !bytes.Equal(got, want) {
+		t.Errorf("file = %x, want %x", got, want)
+	}
+}
@@ -0,0 +1,132 @@
+package recorder
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/types"
+)
+
+// spoolHeaderSize is the size of a spooled frame's header: frame number,
+// capture time (Unix ns), payload length, camera ID, IDR flag, width and
+// height.
+const spoolHeaderSize = 8 + 8 + 4 + 1 + 1 + 2 + 2
+
+// errSpoolFull is returned by frameSpool.Put beyond its size limit.
+var errSpoolFull = errors.New("recorder spool full")
+
+// frameSpool is the overflow of the writer queue (a fanout.Spill): while
+// the writer is stalled (typically on a slow SD card) and its queue is full,
+// frames are appended to a temporary file and read back in order once it
+// catches up, so the clip keeps every frame with its original timestamp.
+// The file is unlinked right after creation and shrinks back to empty
+// whenever the writer has caught up. The queue serializes its calls (see
+// fanout.Spill).
+type frameSpool struct {
+	f       *os.File
+	max     int64                   // bytes
+	release func(*types.VideoFrame) // frees a frame once copied to the file
+	w, r    int64                   // write and read offsets
+	n       int                     // frames in the file
+	total   atomic.Uint64           // frames spooled since creation
+}
+
+// newFrameSpool creates a spool file in dir holding at most maxBytes.
+func newFrameSpool(dir string, maxBytes int64, release func(*types.VideoFrame)) (*frameSpool, error) {
+	f, err := os.CreateTemp(dir, "recorder-spool-*")
+	if err != nil {
+		return nil, err
+	}
+	// Nothing to clean up after a crash: the file lives until f is closed
+	os.Remove(f.Name())
+	return &frameSpool{f: f, max: maxBytes, release: release}, nil
+}
+
+// Put appends frame and releases it.
+func (s *frameSpool) Put(frame *types.VideoFrame) error {
+	size := int64(spoolHeaderSize + len(frame.Data))
+	if s.w+size > s.max {
+		return errSpoolFull
+	}
+	buf := make([]byte, size)
+	binary.LittleEndian.PutUint64(buf[0:], frame.FrameNumber)
+	binary.LittleEndian.PutUint64(buf[8:], uint64(frame.Timestamp.UnixNano()))
+	binary.LittleEndian.PutUint32(buf[16:], uint32(len(frame.Data)))
+	buf[20] = byte(frame.CameraID)
+	if frame.IsIDR {
+		buf[21] = 1
+	}
+	binary.LittleEndian.PutUint16(buf[22:], uint16(frame.Width))
+	binary.LittleEndian.PutUint16(buf[24:], uint16(frame.Height))
+	copy(buf[spoolHeaderSize:], frame.Data)
+	if _, err := s.f.WriteAt(buf, s.w); err != nil {
+		return err
+	}
+	s.w += size
+	s.n++
+	s.total.Add(1)
+	if s.release != nil {
+		s.release(frame)
+	}
+	return nil
+}
+
+// Get reads back the oldest spooled frame into a new buffer. A read error
+// discards the spool.
+func (s *frameSpool) Get() (*types.VideoFrame, bool) {
+	if s.n == 0 {
+		return nil, false
+	}
+	var hdr [spoolHeaderSize]byte
+	if _, err := s.f.ReadAt(hdr[:], s.r); err != nil {
+		s.discard(err)
+		return nil, false
+	}
+	frame := &types.VideoFrame{
+		FrameNumber: binary.LittleEndian.Uint64(hdr[0:]),
+		Timestamp:   time.Unix(0, int64(binary.LittleEndian.Uint64(hdr[8:]))),
+		Data:        make([]byte, binary.LittleEndian.Uint32(hdr[16:])),
+		CameraID:    int(hdr[20]),
+		IsIDR:       hdr[21] == 1,
+		Width:       int(binary.LittleEndian.Uint16(hdr[22:])),
+		Height:      int(binary.LittleEndian.Uint16(hdr[24:])),
+	}
+	if _, err := s.f.ReadAt(frame.Data, s.r+spoolHeaderSize); err != nil {
+		s.discard(err)
+		return nil, false
+	}
+	s.r += int64(spoolHeaderSize + len(frame.Data))
+	s.n--
+	if s.n == 0 {
+		s.reset()
+	}
+	return frame, true
+}
+
+// Len returns the frames waiting in the file.
+func (s *frameSpool) Len() int {
+	return s.n
+}
+
+func (s *frameSpool) discard(err error) {
+	logger.Warn("Recorder", "Spool read failed, %d spooled frames lost: %v", s.n, err)
+	s.n = 0
+	s.reset()
+}
+
+// reset empties the file once everything has been read back.
+func (s *frameSpool) reset() {
+	s.r, s.w = 0, 0
+	if err := s.f.Truncate(0); err != nil {
+		logger.Debug("Recorder", "Spool truncate failed: %v", err)
+	}
+}
+
+// Close removes the spool.
+func (s *frameSpool) Close() error {
+	return s.f.Close()
+}
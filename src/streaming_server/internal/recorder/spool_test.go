@@ -0,0 +1,96 @@
+package recorder
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/types"
+)
+
+func TestFrameSpool(t *testing.T) {
+	var released []uint64
+	s, err := newFrameSpool(t.TempDir(), 3*(spoolHeaderSize+4), func(f *types.VideoFrame) {
+		released = append(released, f.FrameNumber)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	start := time.Date(2026, 3, 1, 12, 0, 0, 123456789, time.UTC)
+	for i := uint64(1); i <= 4; i++ {
+		f := &types.VideoFrame{
+			FrameNumber: i,
+			Timestamp:   start.Add(time.Duration(i) * 33 * time.Millisecond),
+			Data:        []byte{0, 0, 1, byte(i)},
+			IsIDR:       i == 1,
+			Width:       1920,
+			Height:      1080,
+		}
+		err := s.Put(f)
+		if (err == nil) != (i <= 3) {
+			t.Fatalf("put %d: %v", i, err)
+		}
+	}
+	if s.Len() != 3 || len(released) != 3 || s.total.Load() != 3 {
+		t.Fatalf("len %d, released %v", s.Len(), released)
+	}
+
+	for i := uint64(1); i <= 3; i++ {
+		f, ok := s.Get()
+		if !ok {
+			t.Fatalf("frame %d missing", i)
+		}
+		want := start.Add(time.Duration(i) * 33 * time.Millisecond)
+		if f.FrameNumber != i || !f.Timestamp.Equal(want) || !bytes.Equal(f.Data, []byte{0, 0, 1, byte(i)}) ||
+			f.IsIDR != (i == 1) || f.Width != 1920 || f.Height != 1080 {
+			t.Errorf("frame %d read back as %+v", i, f)
+		}
+	}
+	if _, ok := s.Get(); ok {
+		t.Error("Get past the end")
+	}
+
+	// Drained: the file is empty again and takes frames up to the limit
+	if fi, _ := s.f.Stat(); fi.Size() != 0 {
+		t.Errorf("spool file still %d bytes", fi.Size())
+	}
+	if err := s.Put(&types.VideoFrame{Data: []byte{1, 2, 3, 4}}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRecorderSpoolKeepsFrames(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRecorder(dir)
+	r.SetQueueSize(1)
+	r.SetSpool(t.TempDir(), 1<<20)
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	var want []byte
+	for i := 0; i < 50; i++ {
+		f := &types.VideoFrame{Data: []byte{0, 0, 0, 1, 0x02, byte(i)}, IsIDR: i == 0}
+		if i == 0 {
+			f.Data[4] = 0x26
+		}
+		want = append(want, f.Data...)
+		if !r.SendFrame(f) {
+			t.Fatalf("frame %d dropped", i)
+		}
+	}
+	if err := r.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, r.GetStatus().Filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("clip has %d bytes, want %d in order", len(got), len(want))
+	}
+}
@@ -0,0 +1,143 @@
+package recorder
+
+import (
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/codec"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/types"
+)
+
+// startupBuffer holds the GOP in progress while no recording is active, so
+// a recording starts at its IDR instead of dropping frames until the next
+// one, which takes as long as the encoder's keyframe interval. A GOP
+// outgrowing max is let go; the recording then waits for the next IDR as it
+// would without the buffer.
+type startupBuffer struct {
+	max      int64 // bytes
+	frames   []*types.VideoFrame
+	bytes    int64
+	overflow bool // the GOP in progress outgrew max
+}
+
+// add keeps frame if its GOP fits, releasing it otherwise and the frames of
+// a GOP it ends or overflows.
+func (b *startupBuffer) add(frame *types.VideoFrame, release func(*types.VideoFrame)) {
+	switch {
+	case frame.IsIDR:
+		b.clear(release)
+		b.overflow = false
+	case len(b.frames) == 0 || b.overflow:
+		// Mid-GOP without its IDR: undecodable
+		releaseFrame(frame, release)
+		return
+	}
+	if b.bytes+int64(len(frame.Data)) > b.max {
+		b.clear(release)
+		b.overflow = true
+		releaseFrame(frame, release)
+		return
+	}
+	b.frames = append(b.frames, frame)
+	b.bytes += int64(len(frame.Data))
+}
+
+// take hands the buffered GOP over to the caller.
+func (b *startupBuffer) take() []*types.VideoFrame {
+	frames := b.frames
+	b.frames, b.bytes = nil, 0
+	return frames
+}
+
+func (b *startupBuffer) clear(release func(*types.VideoFrame)) {
+	for _, f := range b.frames {
+		releaseFrame(f, release)
+	}
+	clear(b.frames)
+	b.frames, b.bytes = b.frames[:0], 0
+}
+
+func releaseFrame(frame *types.VideoFrame, release func(*types.VideoFrame)) {
+	if release != nil {
+		release(frame)
+	}
+}
+
+// SetStartupBuffer keeps up to maxBytes of the GOP in progress while not
+// recording (see Buffer), so Start does not wait for the next IDR. 0
+// disables it.
+func (r *Recorder) SetStartupBuffer(maxBytes int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.startup != nil {
+		r.startup.clear(r.release)
+	}
+	r.startup = nil
+	if maxBytes > 0 {
+		r.startup = &startupBuffer{max: maxBytes}
+	}
+}
+
+// Buffering reports whether frames should be offered to Buffer.
+func (r *Recorder) Buffering() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.startup != nil && !r.recording
+}
+
+// Buffer keeps frame for the next Start while not recording. Like
+// SendFrame, it returns false when it did not take frame (recording, or no
+// startup buffer); otherwise frame goes to the release function once
+// written or no longer needed.
+func (r *Recorder) Buffer(frame *types.VideoFrame) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.startup == nil || r.recording {
+		return false
+	}
+	r.startup.add(frame, r.release)
+	return true
+}
+
+// queueStartupLocked queues the buffered GOP ahead of the frames to come,
+// unless the writer queue (and spool) cannot take all of it: a clip missing
+// frames mid-GOP is worse than one starting at the next IDR. Caller holds
+// r.mu.
+func (r *Recorder) queueStartupLocked() {
+	r.startupFrames = 0
+	if r.startup == nil {
+		return
+	}
+	frames := r.startup.take()
+	if len(frames) > r.queueSize && r.spool == nil {
+		for _, f := range frames {
+			releaseFrame(f, r.release)
+		}
+		return
+	}
+	for i, f := range frames {
+		if !r.queue.Push(f) {
+			for _, rest := range frames[i:] {
+				releaseFrame(rest, r.release)
+			}
+			return
+		}
+		r.startupFrames++
+	}
+}
+
+// SetGOPTracker lets timed recordings wait for their last IDR as long as the
+// stream's GOPs take instead of assuming 1s ones.
+func (r *Recorder) SetGOPTracker(t *codec.GOPTracker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gop = t
+}
+
+// idrWaitLocked is how long a timed recording waits for the IDR ending it.
+// Caller holds r.mu.
+func (r *Recorder) idrWaitLocked() time.Duration {
+	if r.gop == nil {
+		return timedStopGrace
+	}
+	return r.gop.IDRWait(timedStopGrace)
+}
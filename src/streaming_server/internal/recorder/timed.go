@@ -0,0 +1,157 @@
+package recorder
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/types"
+)
+
+// ErrStopping is returned by RecordFor while a timed recording is already
+// closing its last GOP.
+var ErrStopping = errors.New("recording is stopping")
+
+// MaxTimedDuration bounds a RecordFor duration.
+const MaxTimedDuration = 30 * time.Minute
+
+// timedStopGrace bounds the wait for the IDR that ends a timed recording
+// (a GOP is 1s at the camera's settings), or is the least of it with a GOP
+// tracker; the clip is cut mid-GOP after it.
+const timedStopGrace = 2 * time.Second
+
+// Timed describes a timed recording.
+type Timed struct {
+	Filename string    `json:"filename"`
+	Until    time.Time `json:"until"`    // when it stops, at the next IDR
+	Extended bool      `json:"extended"` // an active timed recording was extended
+}
+
+// ParseDuration parses a ?duration= value, a Go duration ("30s", "2m") or
+// whole seconds ("30"), and checks it is a valid RecordFor duration.
+func ParseDuration(v string) (time.Duration, error) {
+	d, err := time.ParseDuration(v)
+	if n, aerr := strconv.Atoi(v); aerr == nil {
+		d, err = time.Duration(n)*time.Second, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", v)
+	}
+	return d, checkDuration(d)
+}
+
+func checkDuration(d time.Duration) error {
+	if d <= 0 || d > MaxTimedDuration {
+		return fmt.Errorf("duration must be between 0 and %v", MaxTimedDuration)
+	}
+	return nil
+}
+
+// RecordFor starts a recording that stops by itself d from now, at the
+// first IDR after that so the clip ends with a whole GOP. While a timed
+// recording is active, it is extended to d from now instead (never
+// shortened). An open-ended recording (Start) is not touched:
+// ErrAlreadyRecording.
+func (r *Recorder) RecordFor(d time.Duration) (Timed, error) {
+	if err := checkDuration(d); err != nil {
+		return Timed{}, err
+	}
+	until := time.Now().Add(d)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.recording {
+		switch {
+		case r.timedStop == nil:
+			return Timed{}, ErrAlreadyRecording
+		case r.gopClosed:
+			return Timed{}, ErrStopping
+		}
+		if until.After(r.until) {
+			r.until = until
+		}
+		r.stopAtIDR = false
+		return Timed{Filename: r.filename, Until: r.until, Extended: true}, nil
+	}
+
+	if err := r.startLocked(); err != nil {
+		return Timed{}, err
+	}
+	r.until = until
+	r.timedStop = make(chan struct{})
+	go r.stopWhenDue(r.timedStop)
+	return Timed{Filename: r.filename, Until: until}, nil
+}
+
+// stopWhenDue stops the timed recording identified by done (closed when it
+// stops otherwise) once its deadline passed and the GOP in progress ended.
+func (r *Recorder) stopWhenDue(done chan struct{}) {
+	for {
+		r.mu.Lock()
+		if r.timedStop != done {
+			r.mu.Unlock()
+			return
+		}
+		wait := time.Until(r.until)
+		var ended chan struct{}
+		if wait <= 0 {
+			r.stopAtIDR = true
+			r.gopEnded = make(chan struct{})
+			ended = r.gopEnded
+			wait = r.idrWaitLocked()
+		}
+		filename := r.filename
+		r.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-done:
+			timer.Stop()
+			return
+		case <-ended:
+			timer.Stop()
+		case <-timer.C:
+		}
+		if ended == nil {
+			continue // deadline reached, or moved by an extension
+		}
+
+		r.mu.Lock()
+		if r.timedStop != done {
+			r.mu.Unlock()
+			return
+		}
+		if !r.stopAtIDR {
+			r.mu.Unlock()
+			continue // extended while waiting for the IDR
+		}
+		if !r.gopClosed {
+			logger.Info("Recorder", "No IDR within %v, cutting %s mid-GOP", wait, filename)
+			r.gopClosed = true
+		}
+		r.mu.Unlock()
+
+		if err := r.Stop(); err != nil {
+			logger.Warn("Recorder", "Timed recording %s: %v", filename, err)
+			return
+		}
+		logger.Info("Recorder", "Timed recording %s finished", filename)
+		return
+	}
+}
+
+// gopEndedLocked reports whether frame is the IDR ending a timed recording;
+// frames from then on are not written. Caller holds r.mu.
+func (r *Recorder) gopEndedLocked(frame *types.VideoFrame) bool {
+	if r.gopClosed {
+		return true
+	}
+	if !r.stopAtIDR || !frame.IsIDR || r.waitingKeyframe {
+		return false
+	}
+	r.gopClosed = true
+	close(r.gopEnded)
+	return true
+}
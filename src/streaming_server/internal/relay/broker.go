@@ -0,0 +1,315 @@
+package relay
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+const (
+	// ConnectPath is where cameras open their tunnel.
+	ConnectPath = "/relay/connect"
+	// cameraCookie selects the camera for subsequent viewer requests.
+	cameraCookie = "relay_camera"
+	// maxRequestBody bounds tunneled request bodies (signaling offers, rules).
+	maxRequestBody = 1 << 20
+	// streamBuffer is the per-request frame backlog before a slow viewer is
+	// dropped; it keeps one stalled MJPEG viewer from blocking the tunnel.
+	streamBuffer = 256
+)
+
+type frame struct {
+	msg  message
+	data []byte
+}
+
+// tunnel is the broker side of one camera connection.
+type tunnel struct {
+	id   string
+	conn *wsConn
+
+	mu      sync.Mutex
+	nextID  uint32
+	streams map[uint32]chan frame
+	closed  bool
+}
+
+func (t *tunnel) open() (uint32, chan frame, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return 0, nil, false
+	}
+	t.nextID++
+	ch := make(chan frame, streamBuffer)
+	t.streams[t.nextID] = ch
+	return t.nextID, ch, true
+}
+
+func (t *tunnel) release(id uint32) {
+	t.mu.Lock()
+	if ch, ok := t.streams[id]; ok {
+		delete(t.streams, id)
+		close(ch)
+	}
+	t.mu.Unlock()
+}
+
+// dispatch delivers a frame to its stream, dropping the stream if the viewer
+// is not keeping up.
+func (t *tunnel) dispatch(f frame) {
+	t.mu.Lock()
+	ch, ok := t.streams[f.msg.ID]
+	if !ok {
+		t.mu.Unlock()
+		return
+	}
+	select {
+	case ch <- f:
+		t.mu.Unlock()
+	default:
+		delete(t.streams, f.msg.ID)
+		close(ch)
+		t.mu.Unlock()
+		t.conn.writeControl(message{Type: msgCancel, ID: f.msg.ID})
+	}
+}
+
+func (t *tunnel) closeAll() {
+	t.mu.Lock()
+	t.closed = true
+	for id, ch := range t.streams {
+		delete(t.streams, id)
+		close(ch)
+	}
+	t.mu.Unlock()
+}
+
+// Broker accepts camera tunnels and forwards viewer HTTP requests to them.
+//
+// Viewers pick a camera by visiting /cam/{id} (sets a cookie); with a single
+// camera connected no selection is needed.
+type Broker struct {
+	tokens map[string]string // camera ID -> token
+
+	mu      sync.Mutex
+	tunnels map[string]*tunnel
+
+	// ViewerUser/ViewerPassword enable HTTP basic auth for viewers.
+	ViewerUser     string
+	ViewerPassword string
+	PingInterval   time.Duration
+	ReadTimeout    time.Duration
+}
+
+// NewBroker creates a broker accepting the given camera ID -> token pairs.
+func NewBroker(tokens map[string]string) *Broker {
+	return &Broker{
+		tokens:       tokens,
+		tunnels:      make(map[string]*tunnel),
+		ViewerUser:   "viewer",
+		PingInterval: 20 * time.Second,
+		ReadTimeout:  60 * time.Second,
+	}
+}
+
+// Cameras returns the IDs of connected cameras.
+func (b *Broker) Cameras() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ids := make([]string, 0, len(b.tunnels))
+	for id := range b.tunnels {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (b *Broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == ConnectPath {
+		b.handleConnect(w, r)
+		return
+	}
+
+	if !b.viewerAuthorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="pet camera relay"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/relay/cameras":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"cameras": b.Cameras()})
+		return
+	case strings.HasPrefix(r.URL.Path, "/cam/"):
+		id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/cam/"), "/")
+		http.SetCookie(w, &http.Cookie{Name: cameraCookie, Value: id, Path: "/", HttpOnly: true, SameSite: http.SameSiteLaxMode})
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+
+	t := b.selectTunnel(r)
+	if t == nil {
+		http.Error(w, "camera not connected", http.StatusBadGateway)
+		return
+	}
+	b.forward(w, r, t)
+}
+
+func (b *Broker) viewerAuthorized(r *http.Request) bool {
+	if b.ViewerPassword == "" {
+		return true
+	}
+	user, pass, ok := r.BasicAuth()
+	return ok &&
+		subtle.ConstantTimeCompare([]byte(user), []byte(b.ViewerUser)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(pass), []byte(b.ViewerPassword)) == 1
+}
+
+func (b *Broker) selectTunnel(r *http.Request) *tunnel {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c, err := r.Cookie(cameraCookie); err == nil {
+		return b.tunnels[c.Value]
+	}
+	if len(b.tunnels) == 1 {
+		for _, t := range b.tunnels {
+			return t
+		}
+	}
+	return nil
+}
+
+func (b *Broker) handleConnect(w http.ResponseWriter, r *http.Request) {
+	id := r.Header.Get(headerCameraID)
+	want, ok := b.tokens[id]
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(want)) != 1 {
+		logger.Warn("Relay", "Rejected camera %q from %s", id, r.RemoteAddr)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := acceptWebSocket(w, r)
+	if err != nil {
+		logger.Debug("Relay", "Upgrade failed: %v", err)
+		return
+	}
+	conn.readTimeout = b.ReadTimeout
+	t := &tunnel{id: id, conn: conn, streams: make(map[uint32]chan frame)}
+
+	b.mu.Lock()
+	old := b.tunnels[id]
+	b.tunnels[id] = t
+	b.mu.Unlock()
+	if old != nil {
+		old.conn.Close() // camera reconnected; drop the stale tunnel
+	}
+	logger.Info("Relay", "Camera %q connected from %s", id, r.RemoteAddr)
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(b.PingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if conn.Ping() != nil {
+					conn.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		op, payload, err := conn.ReadMessage()
+		if err != nil {
+			logger.Info("Relay", "Camera %q disconnected: %v", id, err)
+			break
+		}
+		m, data, err := parseFrame(op, payload)
+		if err != nil {
+			continue
+		}
+		t.dispatch(frame{msg: m, data: data})
+	}
+	close(done)
+	conn.Close()
+	t.closeAll()
+
+	b.mu.Lock()
+	if b.tunnels[id] == t {
+		delete(b.tunnels, id)
+	}
+	b.mu.Unlock()
+}
+
+// forward sends r through the tunnel and streams the response back.
+func (b *Broker) forward(w http.ResponseWriter, r *http.Request, t *tunnel) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBody))
+	if err != nil {
+		http.Error(w, "bad request body", http.StatusBadRequest)
+		return
+	}
+
+	id, ch, ok := t.open()
+	if !ok {
+		http.Error(w, "camera not connected", http.StatusBadGateway)
+		return
+	}
+	defer t.release(id)
+
+	header := stripHop(r.Header)
+	header.Del("Authorization") // viewer credentials stay at the broker
+	req := message{Type: msgRequest, ID: id, Method: r.Method, URL: r.URL.RequestURI(), Header: header, Body: body}
+	if err := t.conn.writeControl(req); err != nil {
+		http.Error(w, "camera not connected", http.StatusBadGateway)
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	wroteHeader := false
+	for {
+		select {
+		case <-r.Context().Done():
+			t.conn.writeControl(message{Type: msgCancel, ID: id})
+			return
+		case f, ok := <-ch:
+			if !ok {
+				if !wroteHeader {
+					http.Error(w, "camera disconnected", http.StatusBadGateway)
+				}
+				return
+			}
+			switch {
+			case f.data != nil:
+				if _, err := w.Write(f.data); err != nil {
+					t.conn.writeControl(message{Type: msgCancel, ID: id})
+					return
+				}
+				if flusher != nil {
+					flusher.Flush()
+				}
+			case f.msg.Type == msgResponse:
+				for k, v := range f.msg.Header {
+					w.Header()[k] = v
+				}
+				w.WriteHeader(f.msg.Status)
+				wroteHeader = true
+			case f.msg.Type == msgEnd:
+				return
+			}
+		}
+	}
+}
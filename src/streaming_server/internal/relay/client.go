@@ -0,0 +1,323 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+// Status describes the relay connection for health endpoints.
+type Status struct {
+	Connected  bool      `json:"connected"`
+	Broker     string    `json:"broker"`
+	Since      time.Time `json:"since,omitempty"` // connected/disconnected since
+	Reconnects int       `json:"reconnects"`
+	LastError  string    `json:"last_error,omitempty"`
+	Streams    int       `json:"streams"` // in-flight tunneled requests
+}
+
+// Client keeps an outbound tunnel to the broker open and serves tunneled
+// requests with handler.
+type Client struct {
+	url      string
+	token    string
+	cameraID string
+	handler  http.Handler
+
+	mu      sync.Mutex
+	status  Status
+	stop    chan struct{}
+	stopped bool
+	conn    *wsConn
+
+	// Configurable parameters
+	TLSConfig    *tls.Config
+	PingInterval time.Duration
+	ReadTimeout  time.Duration // connection is dropped after no frames for this long
+	MinBackoff   time.Duration
+	MaxBackoff   time.Duration
+}
+
+// NewClient creates a relay client. brokerURL is the broker's ws(s)://
+// connect endpoint; token authenticates cameraID.
+func NewClient(brokerURL, token, cameraID string, handler http.Handler) *Client {
+	return &Client{
+		url:          brokerURL,
+		token:        token,
+		cameraID:     cameraID,
+		handler:      handler,
+		status:       Status{Broker: brokerURL, Since: time.Now()},
+		stop:         make(chan struct{}),
+		PingInterval: 20 * time.Second,
+		ReadTimeout:  60 * time.Second,
+		MinBackoff:   time.Second,
+		MaxBackoff:   time.Minute,
+	}
+}
+
+// Start connects in the background and reconnects until Stop.
+func (c *Client) Start() {
+	go c.run()
+}
+
+// Stop closes the tunnel and halts reconnection.
+func (c *Client) Stop() {
+	c.mu.Lock()
+	if !c.stopped {
+		close(c.stop)
+		c.stopped = true
+	}
+	conn := c.conn
+	c.mu.Unlock()
+
+	if conn != nil {
+		conn.Close()
+	}
+}
+
+// Status returns a snapshot of the connection state.
+func (c *Client) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status
+}
+
+func (c *Client) run() {
+	backoff := c.MinBackoff
+	for {
+		select {
+		case <-c.stop:
+			return
+		default:
+		}
+
+		start := time.Now()
+		err := c.connectAndServe()
+
+		c.mu.Lock()
+		wasConnected := c.status.Connected
+		c.status.Connected = false
+		c.status.Since = time.Now()
+		if err != nil {
+			c.status.LastError = err.Error()
+		}
+		c.status.Reconnects++
+		c.conn = nil
+		c.mu.Unlock()
+
+		select {
+		case <-c.stop:
+			return
+		default:
+		}
+		if wasConnected {
+			logger.Warn("Relay", "Disconnected from broker: %v", err)
+		} else {
+			logger.Debug("Relay", "Connect failed: %v", err)
+		}
+
+		// A connection that stayed up for a while resets the backoff
+		if time.Since(start) > c.MaxBackoff {
+			backoff = c.MinBackoff
+		}
+		select {
+		case <-c.stop:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, c.MaxBackoff)
+	}
+}
+
+func (c *Client) connectAndServe() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+c.token)
+	header.Set(headerCameraID, c.cameraID)
+	conn, err := dialWebSocket(ctx, c.url, header, c.TLSConfig)
+	cancel()
+	if err != nil {
+		return err
+	}
+	conn.readTimeout = c.ReadTimeout
+
+	c.mu.Lock()
+	if c.stopped {
+		c.mu.Unlock()
+		conn.Close()
+		return nil
+	}
+	c.conn = conn
+	c.status.Connected = true
+	c.status.Since = time.Now()
+	c.status.LastError = ""
+	c.mu.Unlock()
+	logger.Info("Relay", "Connected to broker %s as %q", c.url, c.cameraID)
+
+	return c.serve(conn)
+}
+
+// serve dispatches tunneled requests until the connection fails.
+func (c *Client) serve(conn *wsConn) error {
+	ctx, cancelAll := context.WithCancel(context.Background())
+	defer cancelAll()
+
+	// Keepalive pings; also detects half-open connections on the write side
+	go func() {
+		ticker := time.NewTicker(c.PingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := conn.Ping(); err != nil {
+					conn.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	var streamsMu sync.Mutex
+	streams := make(map[uint32]context.CancelFunc)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer conn.Close()
+
+	for {
+		op, payload, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		m, _, err := parseFrame(op, payload)
+		if err != nil {
+			logger.Debug("Relay", "Bad frame: %v", err)
+			continue
+		}
+
+		switch m.Type {
+		case msgRequest:
+			reqCtx, cancel := context.WithCancel(ctx)
+			streamsMu.Lock()
+			streams[m.ID] = cancel
+			streamsMu.Unlock()
+			c.addStreams(1)
+
+			wg.Add(1)
+			go func(m message) {
+				defer wg.Done()
+				defer c.addStreams(-1)
+				defer func() {
+					streamsMu.Lock()
+					delete(streams, m.ID)
+					streamsMu.Unlock()
+					cancel()
+				}()
+				c.handle(reqCtx, conn, m)
+			}(m)
+		case msgCancel:
+			streamsMu.Lock()
+			if cancel, ok := streams[m.ID]; ok {
+				cancel()
+			}
+			streamsMu.Unlock()
+		}
+	}
+}
+
+func (c *Client) addStreams(n int) {
+	c.mu.Lock()
+	c.status.Streams += n
+	c.mu.Unlock()
+}
+
+// handle runs one tunneled request against the local handler.
+func (c *Client) handle(ctx context.Context, conn *wsConn, m message) {
+	req, err := http.NewRequestWithContext(ctx, m.Method, "http://relay"+m.URL, bytes.NewReader(m.Body))
+	if err != nil {
+		conn.writeControl(message{Type: msgResponse, ID: m.ID, Status: http.StatusBadRequest})
+		conn.writeControl(message{Type: msgEnd, ID: m.ID, Error: err.Error()})
+		return
+	}
+	req.Header = m.Header
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	req.RequestURI = m.URL
+	req.RemoteAddr = "relay"
+
+	rw := &tunnelWriter{conn: conn, id: m.ID, header: http.Header{}, ctx: ctx}
+	c.handler.ServeHTTP(rw, req)
+	rw.finish()
+}
+
+// tunnelWriter is the http.ResponseWriter for a tunneled request.
+type tunnelWriter struct {
+	conn        *wsConn
+	id          uint32
+	ctx         context.Context
+	header      http.Header
+	wroteHeader bool
+	buf         []byte
+	err         error
+}
+
+func (w *tunnelWriter) Header() http.Header { return w.header }
+
+func (w *tunnelWriter) WriteHeader(status int) {
+	if w.wroteHeader || w.err != nil {
+		return
+	}
+	w.wroteHeader = true
+	w.err = w.conn.writeControl(message{Type: msgResponse, ID: w.id, Status: status, Header: stripHop(w.header)})
+}
+
+func (w *tunnelWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.header.Get("Content-Type") == "" {
+			w.header.Set("Content-Type", http.DetectContentType(p))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	w.buf = append(w.buf, p...)
+	for len(w.buf) >= dataChunkSize && w.err == nil {
+		w.err = w.conn.writeData(w.id, w.buf[:dataChunkSize])
+		w.buf = w.buf[dataChunkSize:]
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	return len(p), nil
+}
+
+// Flush sends buffered data immediately (SSE and MJPEG handlers flush per
+// event/frame).
+func (w *tunnelWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if len(w.buf) > 0 && w.err == nil {
+		w.err = w.conn.writeData(w.id, w.buf)
+		w.buf = w.buf[:0]
+	}
+}
+
+func (w *tunnelWriter) finish() {
+	w.Flush()
+	if w.err != nil {
+		return
+	}
+	w.conn.writeControl(message{Type: msgEnd, ID: w.id})
+}
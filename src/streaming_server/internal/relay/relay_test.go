@@ -0,0 +1,126 @@
+package relay
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRelay_EndToEnd(t *testing.T) {
+	sseDone := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		fmt.Fprintf(w, "%s?%s:%s", r.URL.Path, r.URL.RawQuery, body)
+	})
+	mux.HandleFunc("/big", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 3*dataChunkSize+17)))
+	})
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		defer close(sseDone)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; ; i++ {
+			fmt.Fprintf(w, "data: %d\n\n", i)
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(20 * time.Millisecond):
+			}
+		}
+	})
+
+	broker := NewBroker(map[string]string{"living": "secret"})
+	broker.ViewerPassword = "pw"
+	srv := httptest.NewServer(broker)
+	defer srv.Close()
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + ConnectPath
+
+	// Wrong token is rejected
+	bad := NewClient(wsURL, "nope", "living", mux)
+	bad.MinBackoff = time.Hour
+	bad.Start()
+	waitFor(t, "rejection", func() bool { return strings.Contains(bad.Status().LastError, "401") })
+	bad.Stop()
+
+	client := NewClient(wsURL, "secret", "living", mux)
+	client.Start()
+	defer client.Stop()
+	waitFor(t, "connect", func() bool { return client.Status().Connected })
+
+	get := func(method, path, body string) *http.Response {
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		req.SetBasicAuth("viewer", "pw")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	// Viewer auth
+	resp, _ := http.Get(srv.URL + "/echo")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated viewer status = %d", resp.StatusCode)
+	}
+
+	resp = get(http.MethodPost, "/echo?a=1", "offer-sdp")
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(data) != "/echo?a=1:offer-sdp" || resp.Header.Get("X-Method") != "POST" {
+		t.Fatalf("echo = %q (method header %q)", data, resp.Header.Get("X-Method"))
+	}
+
+	resp = get(http.MethodGet, "/big", "")
+	data, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if len(data) != 3*dataChunkSize+17 {
+		t.Fatalf("big body = %d bytes", len(data))
+	}
+
+	// Streaming response, cancelled when the viewer disconnects
+	resp = get(http.MethodGet, "/events", "")
+	br := bufio.NewReader(resp.Body)
+	line, _ := br.ReadString('\n')
+	if line != "data: 0\n" {
+		t.Fatalf("first SSE line = %q", line)
+	}
+	resp.Body.Close()
+	select {
+	case <-sseDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SSE handler not cancelled after viewer disconnect")
+	}
+	waitFor(t, "stream cleanup", func() bool { return client.Status().Streams == 0 })
+
+	if cams := broker.Cameras(); len(cams) != 1 || cams[0] != "living" {
+		t.Fatalf("cameras = %v", cams)
+	}
+
+	// Broker drop -> client reconnects
+	broker.mu.Lock()
+	broker.tunnels["living"].conn.Close()
+	broker.mu.Unlock()
+	waitFor(t, "reconnect", func() bool {
+		st := client.Status()
+		return st.Connected && st.Reconnects >= 1
+	})
+}
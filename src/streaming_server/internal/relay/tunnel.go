@@ -0,0 +1,86 @@
+// Package relay tunnels the web monitor's HTTP API through an outbound
+// WebSocket to a self-hosted broker, so the camera can be viewed remotely
+// without port forwarding.
+//
+// Protocol: control messages are JSON text frames (request, response, end,
+// cancel); response bodies are binary frames prefixed with the 4-byte
+// big-endian stream ID. Each HTTP request is one stream, so long-lived
+// responses (SSE, MJPEG) are multiplexed with ordinary API calls.
+package relay
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Message types.
+const (
+	msgRequest  = "request"  // broker -> camera
+	msgCancel   = "cancel"   // broker -> camera: viewer went away
+	msgResponse = "response" // camera -> broker: status + headers
+	msgEnd      = "end"      // camera -> broker: response complete
+)
+
+// Handshake headers sent by the camera.
+const (
+	headerCameraID = "X-Relay-Camera"
+)
+
+// dataChunkSize bounds a single binary data frame.
+const dataChunkSize = 32 << 10
+
+type message struct {
+	Type   string      `json:"type"`
+	ID     uint32      `json:"id"`
+	Method string      `json:"method,omitempty"`
+	URL    string      `json:"url,omitempty"` // path + query
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+	Status int         `json:"status,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+func (c *wsConn) writeControl(m message) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(opText, data)
+}
+
+func (c *wsConn) writeData(id uint32, data []byte) error {
+	frame := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(frame, id)
+	copy(frame[4:], data)
+	return c.WriteMessage(opBinary, frame)
+}
+
+// parseFrame decodes a message read from the tunnel. For binary frames only
+// ID is set and data holds the payload.
+func parseFrame(op byte, payload []byte) (m message, data []byte, err error) {
+	if op == opBinary {
+		if len(payload) < 4 {
+			return m, nil, errors.New("relay: short data frame")
+		}
+		m.ID = binary.BigEndian.Uint32(payload)
+		return m, payload[4:], nil
+	}
+	err = json.Unmarshal(payload, &m)
+	return m, nil, err
+}
+
+// hopHeaders are connection-specific and never forwarded.
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Connection", "Te", "Trailer",
+	"Transfer-Encoding", "Upgrade", "Content-Length",
+}
+
+func stripHop(h http.Header) http.Header {
+	out := h.Clone()
+	for _, k := range hopHeaders {
+		out.Del(k)
+	}
+	return out
+}
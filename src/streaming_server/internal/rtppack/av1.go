@@ -0,0 +1,147 @@
+package rtppack
+
+// AV1 OBU types used by the packetizer.
+const (
+	av1OBUSequenceHeader     = 1
+	av1OBUTemporalDelimiter  = 2
+	av1OBUTileList           = 8
+	av1AggregationHeaderSize = 1
+)
+
+// PacketizeAV1 splits one AV1 temporal unit (low-overhead bitstream format,
+// as an encoder writes it) into RTP packets per the AV1 RTP payload format:
+// temporal delimiters and tile lists are dropped, obu_size fields are
+// removed and every OBU element is length-prefixed (W=0). OBUs larger than
+// a packet continue in the next one (Y/Z bits). N is set on the first packet
+// when the unit starts a new coded video sequence (carries a sequence header).
+func PacketizeAV1(tu []byte, ssrc uint32, startSeq uint16, ts uint32, mtu int) (packets [][]byte, nextSeq uint16) {
+	obus := splitAV1OBUs(tu)
+	seq := startSeq
+	maxPayload := mtu - rtpHeaderSize - av1AggregationHeaderSize
+
+	newSequence := hasSequenceHeader(obus)
+
+	var payload []byte
+	continues := false // first element continues an OBU from the previous packet
+	flush := func(continuesNext bool) {
+		pkt := make([]byte, rtpHeaderSize+av1AggregationHeaderSize+len(payload))
+		writeRTPHeader(pkt, seq, ts, ssrc, false)
+		var agg byte
+		if continues {
+			agg |= 0x80 // Z
+		}
+		if continuesNext {
+			agg |= 0x40 // Y
+		}
+		if newSequence && len(packets) == 0 {
+			agg |= 0x08 // N
+		}
+		pkt[rtpHeaderSize] = agg
+		copy(pkt[rtpHeaderSize+av1AggregationHeaderSize:], payload)
+		packets = append(packets, pkt)
+		seq++
+		payload = payload[:0]
+		continues = continuesNext
+	}
+
+	for _, obu := range obus {
+		for len(obu) > 0 {
+			room := maxPayload - len(payload)
+			n := min(len(obu), room-leb128Len(uint(room)))
+			if n <= 0 {
+				flush(false)
+				continue
+			}
+			payload = appendLEB128(payload, uint(n))
+			payload = append(payload, obu[:n]...)
+			obu = obu[n:]
+			if len(obu) > 0 {
+				flush(true)
+			}
+		}
+	}
+	if len(payload) > 0 {
+		flush(false)
+	}
+	if len(packets) > 0 {
+		packets[len(packets)-1][1] |= 0x80 // Marker bit: end of temporal unit
+	}
+	return packets, seq
+}
+
+// splitAV1OBUs returns the OBUs of tu to transmit, each rewritten without
+// its obu_size field.
+func splitAV1OBUs(tu []byte) [][]byte {
+	var obus [][]byte
+	for len(tu) > 0 {
+		hdr := tu[0]
+		hdrLen := 1
+		if hdr&0x04 != 0 { // obu_extension_flag
+			hdrLen = 2
+		}
+		if len(tu) < hdrLen {
+			break
+		}
+		size := len(tu) - hdrLen
+		sizeLen := 0
+		if hdr&0x02 != 0 { // obu_has_size_field
+			v, n := readLEB128(tu[hdrLen:])
+			if n == 0 || uint(len(tu)-hdrLen-n) < v {
+				break // truncated unit
+			}
+			size, sizeLen = int(v), n
+		}
+		body := tu[hdrLen+sizeLen : hdrLen+sizeLen+size]
+		if t := (hdr >> 3) & 0x0F; t != av1OBUTemporalDelimiter && t != av1OBUTileList {
+			obu := make([]byte, 0, hdrLen+size)
+			obu = append(obu, hdr&^0x02)
+			obu = append(obu, tu[1:hdrLen]...)
+			obus = append(obus, append(obu, body...))
+		}
+		tu = tu[hdrLen+sizeLen+size:]
+	}
+	return obus
+}
+
+func hasSequenceHeader(obus [][]byte) bool {
+	for _, obu := range obus {
+		if (obu[0]>>3)&0x0F == av1OBUSequenceHeader {
+			return true
+		}
+	}
+	return false
+}
+
+// AV1Keyframe reports whether a temporal unit starts a new coded video
+// sequence, i.e. carries a sequence header, which encoders emit with every
+// key frame.
+func AV1Keyframe(tu []byte) bool {
+	return hasSequenceHeader(splitAV1OBUs(tu))
+}
+
+func readLEB128(b []byte) (v uint, n int) {
+	for i := 0; i < len(b) && i < 8; i++ {
+		v |= uint(b[i]&0x7F) << (7 * i)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+func appendLEB128(b []byte, v uint) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func leb128Len(v uint) int {
+	n := 1
+	for v >= 0x80 {
+		v >>= 7
+		n++
+	}
+	return n
+}
@@ -0,0 +1,102 @@
+package rtppack
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPacketizeVP9(t *testing.T) {
+	frame := bytes.Repeat([]byte{0x82}, 2500) // key frame header byte, then filler
+	packets, nextSeq := PacketizeVP9(frame, VP9Keyframe(frame), 0x1234, 0x12345678, 10, 3000, 1200)
+
+	if len(packets) != 3 || nextSeq != 13 {
+		t.Fatalf("got %d packets, nextSeq %d; want 3, 13", len(packets), nextSeq)
+	}
+	var payload []byte
+	for i, pkt := range packets {
+		desc := pkt[rtpHeaderSize]
+		if desc&0x80 == 0 || desc&0x40 != 0 {
+			t.Errorf("packet %d: descriptor %08b, want I set and P clear for a key frame", i, desc)
+		}
+		if got := (desc&0x08 != 0); got != (i == 0) {
+			t.Errorf("packet %d: B = %v", i, got)
+		}
+		if got := (desc&0x04 != 0); got != (i == len(packets)-1) {
+			t.Errorf("packet %d: E = %v", i, got)
+		}
+		if got := (pkt[1]&0x80 != 0); got != (i == len(packets)-1) {
+			t.Errorf("packet %d: marker = %v", i, got)
+		}
+		if id := uint16(pkt[rtpHeaderSize+1]&0x7F)<<8 | uint16(pkt[rtpHeaderSize+2]); id != 0x1234 {
+			t.Errorf("packet %d: picture ID %#x", i, id)
+		}
+		payload = append(payload, pkt[rtpHeaderSize+vp9DescriptorLen:]...)
+	}
+	if !bytes.Equal(payload, frame) {
+		t.Error("reassembled payload differs from the frame")
+	}
+
+	inter := []byte{0x86, 0x00}
+	if VP9Keyframe(inter) {
+		t.Error("inter frame reported as key frame")
+	}
+	if pkts, _ := PacketizeVP9(inter, false, 0, 0, 0, 0, 1200); pkts[0][rtpHeaderSize]&0x40 == 0 {
+		t.Error("P bit not set on an inter frame")
+	}
+}
+
+func TestPacketizeAV1(t *testing.T) {
+	obu := func(typ byte, payload []byte) []byte {
+		b := []byte{typ<<3 | 0x02} // obu_has_size_field
+		b = appendLEB128(b, uint(len(payload)))
+		return append(b, payload...)
+	}
+	seqHdr := []byte{1, 2, 3}
+	frameData := bytes.Repeat([]byte{0xAB}, 2000)
+	var tu []byte
+	tu = append(tu, obu(av1OBUTemporalDelimiter, nil)...)
+	tu = append(tu, obu(av1OBUSequenceHeader, seqHdr)...)
+	tu = append(tu, obu(6, frameData)...) // OBU_FRAME
+
+	if !AV1Keyframe(tu) {
+		t.Fatal("unit with a sequence header not reported as key frame")
+	}
+	packets, nextSeq := PacketizeAV1(tu, 0x12345678, 0, 0, 1200)
+	if len(packets) != 2 || nextSeq != 2 {
+		t.Fatalf("got %d packets, nextSeq %d; want 2, 2", len(packets), nextSeq)
+	}
+	if agg := packets[0][rtpHeaderSize]; agg != 0x48 { // Y, N
+		t.Errorf("first aggregation header %08b, want 01001000", agg)
+	}
+	if agg := packets[1][rtpHeaderSize]; agg != 0x80 { // Z
+		t.Errorf("second aggregation header %08b, want 10000000", agg)
+	}
+	if packets[0][1]&0x80 != 0 || packets[1][1]&0x80 == 0 {
+		t.Error("marker must be set on the last packet only")
+	}
+
+	// Reassemble the elements: temporal delimiter dropped, size fields removed
+	var obus [][]byte
+	var partial []byte
+	for _, pkt := range packets {
+		p := pkt[rtpHeaderSize+av1AggregationHeaderSize:]
+		for len(p) > 0 {
+			n, l := readLEB128(p)
+			partial = append(partial, p[l:l+int(n)]...)
+			p = p[l+int(n):]
+			if len(p) > 0 || pkt[rtpHeaderSize]&0x40 == 0 {
+				obus = append(obus, partial)
+				partial = nil
+			}
+		}
+	}
+	if len(obus) != 2 {
+		t.Fatalf("got %d OBUs, want 2", len(obus))
+	}
+	if !bytes.Equal(obus[0], append([]byte{av1OBUSequenceHeader << 3}, seqHdr...)) {
+		t.Errorf("sequence header OBU %x", obus[0])
+	}
+	if !bytes.Equal(obus[1], append([]byte{6 << 3}, frameData...)) {
+		t.Error("frame OBU differs")
+	}
+}
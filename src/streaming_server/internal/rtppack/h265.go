@@ -1,4 +1,5 @@
-// Package rtppack provides H.265 RTP packetization without pion/rtp dependency.
+// Package rtppack provides H.265 RTP packetization without pion/rtp dependency,
+// plus VP9 and AV1 for the transcoded fallback stream.
 package rtppack
 
 import (
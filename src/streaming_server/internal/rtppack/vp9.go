@@ -0,0 +1,49 @@
+package rtppack
+
+const vp9DescriptorLen = 3 // I|P|L|F|B|E|V|Z + M=1 15-bit picture ID
+
+// PacketizeVP9 splits one VP9 frame (or superframe) into RTP packets with
+// the RFC 9628 payload descriptor in its simplest form: a 15-bit picture
+// ID, begin/end-of-frame bits and the inter-predicted bit, no layering.
+// The marker bit is set on the last packet.
+func PacketizeVP9(frame []byte, key bool, pictureID uint16, ssrc uint32, startSeq uint16, ts uint32, mtu int) (packets [][]byte, nextSeq uint16) {
+	seq := startSeq
+	maxChunk := mtu - rtpHeaderSize - vp9DescriptorLen
+
+	for offset := 0; offset < len(frame); {
+		end := min(offset+maxChunk, len(frame))
+
+		desc := byte(0x80) // I: picture ID present
+		if !key {
+			desc |= 0x40 // P: inter-picture predicted
+		}
+		if offset == 0 {
+			desc |= 0x08 // B: start of frame
+		}
+		if end == len(frame) {
+			desc |= 0x04 // E: end of frame
+		}
+
+		pkt := make([]byte, rtpHeaderSize+vp9DescriptorLen+end-offset)
+		writeRTPHeader(pkt, seq, ts, ssrc, end == len(frame))
+		pkt[rtpHeaderSize] = desc
+		pkt[rtpHeaderSize+1] = 0x80 | byte(pictureID>>8)&0x7F // M=1
+		pkt[rtpHeaderSize+2] = byte(pictureID)
+		copy(pkt[rtpHeaderSize+vp9DescriptorLen:], frame[offset:end])
+
+		packets = append(packets, pkt)
+		seq++
+		offset = end
+	}
+	return packets, seq
+}
+
+// VP9Keyframe reports whether frame starts with a VP9 key frame (profiles
+// 0-2, which is all a 4:2:0 encoder produces).
+func VP9Keyframe(frame []byte) bool {
+	if len(frame) == 0 || frame[0]>>6 != 2 { // frame_marker
+		return false
+	}
+	// profile_low_bit, profile_high_bit, show_existing_frame, frame_type
+	return frame[0]&0x08 == 0 && frame[0]&0x04 == 0
+}
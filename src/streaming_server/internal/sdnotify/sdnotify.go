@@ -0,0 +1,288 @@
+// Package sdnotify implements the parts of the systemd service protocol the
+// servers use, without libsystemd: sd_notify readiness/status messages, the
+// service watchdog, and socket activation (LISTEN_FDS).
+//
+// Everything is a no-op when the process is not started by systemd, so the
+// binaries behave the same under Type=simple, supervise or a terminal.
+package sdnotify
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+// Notify sends one sd_notify message, e.g. "READY=1". It reports false
+// without error when NOTIFY_SOCKET is not set.
+func Notify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:] // abstract namespace
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Ready tells systemd startup has finished (Type=notify).
+func Ready(status string) {
+	msg := "READY=1"
+	if status != "" {
+		msg += "\nSTATUS=" + status
+	}
+	if sent, err := Notify(msg); err != nil {
+		logger.Warn("Systemd", "sd_notify READY failed: %v", err)
+	} else if sent {
+		logger.Info("Systemd", "Notified ready")
+	}
+}
+
+// Stopping tells systemd shutdown has begun.
+func Stopping() {
+	Notify("STOPPING=1")
+}
+
+// Status updates the free-form status shown by systemctl status.
+func Status(status string) {
+	Notify("STATUS=" + status)
+}
+
+// WatchdogInterval returns the configured WatchdogSec, or 0 when the
+// watchdog is not enabled for this process.
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// Watchdog feeds the systemd watchdog while the process is healthy. Every
+// half WatchdogSec it runs the liveness check; a check that fails or does not
+// return within the period (e.g. a deadlocked loop) skips the ping, so a hung
+// process is restarted by systemd instead of lingering.
+type Watchdog struct {
+	check func() error
+
+	mu      sync.Mutex
+	stop    chan struct{}
+	stopped bool
+}
+
+// NewWatchdog creates a watchdog feeder with the given liveness check.
+func NewWatchdog(check func() error) *Watchdog {
+	return &Watchdog{
+		check: check,
+		stop:  make(chan struct{}),
+	}
+}
+
+// Start begins feeding the watchdog if systemd enabled it.
+func (w *Watchdog) Start() {
+	interval := WatchdogInterval()
+	if interval == 0 {
+		return
+	}
+	logger.Info("Systemd", "Feeding watchdog every %v", interval/2)
+	go w.run(interval / 2)
+}
+
+// Stop halts feeding.
+func (w *Watchdog) Stop() {
+	w.mu.Lock()
+	if !w.stopped {
+		close(w.stop)
+		w.stopped = true
+	}
+	w.mu.Unlock()
+}
+
+func (w *Watchdog) run(period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			if err := w.runCheck(period); err != nil {
+				logger.Warn("Systemd", "Liveness check failed, withholding watchdog ping: %v", err)
+				continue
+			}
+			Notify("WATCHDOG=1")
+		}
+	}
+}
+
+func (w *Watchdog) runCheck(timeout time.Duration) error {
+	if w.check == nil {
+		return nil
+	}
+	done := make(chan error, 1)
+	go func() { done <- w.check() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("liveness check did not return within %v", timeout)
+	}
+}
+
+const listenFdsStart = 3
+
+var (
+	activationOnce sync.Once
+	activated      map[string]net.Listener
+)
+
+// activationListeners returns the sockets passed by systemd, keyed by
+// FileDescriptorName= (LISTEN_FDNAMES). Parsed once; the environment is
+// cleared so child processes do not inherit it.
+func activationListeners() map[string]net.Listener {
+	activationOnce.Do(func() {
+		activated = make(map[string]net.Listener)
+		if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+			return
+		}
+		n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+		if err != nil || n <= 0 {
+			return
+		}
+		names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+
+		for i := 0; i < n; i++ {
+			fd := listenFdsStart + i
+			syscall.CloseOnExec(fd)
+			name := "unknown"
+			if i < len(names) && names[i] != "" {
+				name = names[i]
+			}
+			f := os.NewFile(uintptr(fd), name)
+			ln, err := net.FileListener(f)
+			f.Close()
+			if err != nil {
+				logger.Warn("Systemd", "Activated fd %d (%s) is not a listener: %v", fd, name, err)
+				continue
+			}
+			activated[name] = ln
+		}
+	})
+	return activated
+}
+
+// Listen returns the socket-activated listener named name if systemd passed
+// one, otherwise it binds addr itself.
+func Listen(name, addr string) (net.Listener, error) {
+	if ln, ok := activationListeners()[name]; ok {
+		delete(activated, name)
+		logger.Info("Systemd", "Using socket-activated listener %q (%s)", name, ln.Addr())
+		return ln, nil
+	}
+	var lc net.ListenConfig
+	return lc.Listen(context.Background(), "tcp", addr)
+}
+
+// ListenUnix is Listen for a Unix domain socket at path, created with mode
+// (e.g. 0660 for the service's group). A stale socket left by a crash is
+// replaced; any other file at path is an error. The socket file is removed
+// when the listener is closed.
+func ListenUnix(name, path string, mode os.FileMode) (net.Listener, error) {
+	if ln, ok := activationListeners()[name]; ok {
+		delete(activated, name)
+		logger.Info("Systemd", "Using socket-activated listener %q (%s)", name, ln.Addr())
+		return ln, nil
+	}
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket == 0 {
+		return nil, fmt.Errorf("%s exists and is not a socket", path)
+	}
+	// Bound inside a private (0700) directory and moved into place once
+	// chmod'ed, so nobody can connect while it has umask permissions
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".sock-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, filepath.Base(path))
+	var lc net.ListenConfig
+	ln, err := lc.Listen(context.Background(), "unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	ul := ln.(*net.UnixListener)
+	ul.SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, mode); err != nil {
+		ul.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		ul.Close()
+		return nil, err
+	}
+	return &unixListener{UnixListener: ul, path: path}, nil
+}
+
+// unixListener is a Unix socket bound under another name: it reports and
+// removes path.
+type unixListener struct {
+	*net.UnixListener
+	path string
+}
+
+func (l *unixListener) Addr() net.Addr {
+	return &net.UnixAddr{Name: l.path, Net: "unix"}
+}
+
+func (l *unixListener) Close() error {
+	err := l.UnixListener.Close()
+	os.Remove(l.path)
+	return err
+}
+
+// ListenAddrs binds one server's TCP address and Unix socket, either of
+// which may be empty; systemd may activate them as name and name+"-unix".
+func ListenAddrs(name, addr, socket string, mode os.FileMode) ([]net.Listener, error) {
+	var lns []net.Listener
+	if addr != "" {
+		ln, err := Listen(name, addr)
+		if err != nil {
+			return nil, fmt.Errorf("listen %s: %w", addr, err)
+		}
+		lns = append(lns, ln)
+	}
+	if socket != "" {
+		ln, err := ListenUnix(name+"-unix", socket, mode)
+		if err != nil {
+			for _, l := range lns {
+				l.Close()
+			}
+			return nil, fmt.Errorf("listen %s: %w", socket, err)
+		}
+		lns = append(lns, ln)
+	}
+	return lns, nil
+}
@@ -0,0 +1,156 @@
+package sdnotify
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if sent, err := Notify("READY=1"); sent || err != nil {
+		t.Fatalf("without NOTIFY_SOCKET: sent=%v err=%v", sent, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	if sent, err := Notify("READY=1\nSTATUS=ok"); !sent || err != nil {
+		t.Fatalf("sent=%v err=%v", sent, err)
+	}
+	buf := make([]byte, 256)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); got != "READY=1\nSTATUS=ok" {
+		t.Fatalf("received %q", got)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	t.Setenv("WATCHDOG_PID", "")
+	if got := WatchdogInterval(); got != 30*time.Second {
+		t.Fatalf("interval = %v", got)
+	}
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))
+	if got := WatchdogInterval(); got != 0 {
+		t.Fatalf("other pid: interval = %v", got)
+	}
+	t.Setenv("WATCHDOG_USEC", "")
+	if got := WatchdogInterval(); got != 0 {
+		t.Fatalf("unset: interval = %v", got)
+	}
+}
+
+func TestWatchdogCheck(t *testing.T) {
+	w := NewWatchdog(func() error { return nil })
+	if err := w.runCheck(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	w = NewWatchdog(func() error { return errors.New("stalled") })
+	if err := w.runCheck(time.Second); err == nil || err.Error() != "stalled" {
+		t.Fatalf("err = %v", err)
+	}
+	block := make(chan struct{})
+	defer close(block)
+	w = NewWatchdog(func() error { <-block; return nil })
+	if err := w.runCheck(20 * time.Millisecond); err == nil || !strings.Contains(err.Error(), "did not return") {
+		t.Fatalf("hung check: err = %v", err)
+	}
+}
+
+// An address without a host (":8080", every default) must accept IPv6 as
+// well as IPv4 clients.
+func TestListenDualStack(t *testing.T) {
+	if probe, err := net.Listen("tcp6", "[::1]:0"); err != nil {
+		t.Skipf("no IPv6 loopback: %v", err)
+	} else {
+		probe.Close()
+	}
+	ln, err := Listen("test", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	for _, host := range []string{"127.0.0.1", "::1"} {
+		c, err := net.DialTimeout("tcp", net.JoinHostPort(host, port), time.Second)
+		if err != nil {
+			t.Fatalf("dial %s: %v", host, err)
+		}
+		c.Close()
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.sock")
+	ln, err := ListenUnix("test", path, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil || fi.Mode().Perm() != 0o600 {
+		t.Fatalf("socket mode %v, %v", fi.Mode(), err)
+	}
+	if ln.Addr().String() != path {
+		t.Errorf("addr %s", ln.Addr())
+	}
+	accepted := make(chan struct{})
+	go func() {
+		defer close(accepted)
+		if c, err := ln.Accept(); err == nil {
+			c.Close()
+		}
+	}()
+	c, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	<-accepted
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("bind directory left behind: %v", entries)
+	}
+
+	// A crashed process leaves the socket file behind: it is replaced
+	ln.(*unixListener).UnixListener.Close()
+	ln2, err := ListenUnix("test", path, 0o660)
+	if err != nil {
+		t.Fatalf("stale socket: %v", err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0o660 {
+		t.Fatalf("replaced socket mode %v, %v", fi.Mode(), err)
+	}
+	ln2.Close()
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("socket left after Close: %v", err)
+	}
+
+	regular := filepath.Join(t.TempDir(), "notes.txt")
+	os.WriteFile(regular, []byte("keep"), 0o644)
+	if _, err := ListenUnix("test", regular, 0o660); err == nil {
+		t.Fatal("replaced a regular file")
+	}
+}
@@ -0,0 +1,72 @@
+package sframe
+
+import (
+	"errors"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/codec"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/types"
+)
+
+// Protected NAL units keep their 2-byte header in the clear, so RTP
+// packetization (RFC 7798) and depacketization work unchanged, and carry
+// the SFrame ciphertext of the rest as their payload: emulation-prevented,
+// so no start code can appear in it, and closed with stopByte, so the last
+// byte is never zero. VPS/SPS/PPS stay in the clear; they describe the
+// stream, not the picture.
+const stopByte = 0x80
+
+// clearNAL reports whether NAL units of type t are sent unprotected.
+func clearNAL(t uint8) bool {
+	return t == types.NALTypeH265VPS || t == types.NALTypeH265SPS || t == types.NALTypeH265PPS
+}
+
+// ProtectH265 returns a copy of frame, as processed by codec.Processor,
+// with every NAL unit other than parameter sets encrypted. The NAL header is
+// authenticated along with the payload.
+func (s *Sender) ProtectH265(frame *types.VideoFrame) *types.VideoFrame {
+	out := &types.VideoFrame{
+		Timestamp:   frame.Timestamp,
+		FrameNumber: frame.FrameNumber,
+		IsIDR:       frame.IsIDR,
+		Width:       frame.Width,
+		Height:      frame.Height,
+		Timing:      frame.Timing,
+		Data:        make([]byte, 0, len(frame.Data)+len(frame.NALUs)*32),
+		NALUs:       make([]types.NALBound, 0, len(frame.NALUs)),
+	}
+	var sealed []byte
+	for _, nb := range frame.NALUs {
+		nal := frame.Data[nb.Offset : nb.Offset+nb.Length]
+		out.Data = append(out.Data, 0, 0, 0, 1)
+		start := len(out.Data)
+		if clearNAL(nb.Type) || len(nal) < 2 {
+			out.Data = append(out.Data, nal...)
+		} else {
+			sealed = s.Encrypt(sealed[:0], nal[2:], nal[:2])
+			out.Data = append(out.Data, nal[:2]...)
+			out.Data = codec.AppendEmulationPrevented(out.Data, sealed)
+			out.Data = append(out.Data, stopByte)
+		}
+		out.NALUs = append(out.NALUs, types.NALBound{Offset: start, Length: len(out.Data) - start, Type: nb.Type})
+	}
+	return out
+}
+
+// UnprotectH265 returns the clear NAL unit of one received NAL unit (without
+// start code), as sent by ProtectH265.
+func (r *Receiver) UnprotectH265(nal []byte) ([]byte, error) {
+	if len(nal) < 2 {
+		return nil, errors.New("sframe: short NAL unit")
+	}
+	if clearNAL(nal[0] >> 1 & 0x3f) {
+		return nal, nil
+	}
+	if len(nal) < 3 || nal[len(nal)-1] != stopByte {
+		return nil, errors.New("sframe: NAL unit is not protected")
+	}
+	payload, err := r.Decrypt(codec.RemoveEmulationPrevention(nal[2:len(nal)-1]), nal[:2])
+	if err != nil {
+		return nil, err
+	}
+	return append(nal[:2:2], payload...), nil
+}
@@ -0,0 +1,229 @@
+// Package sframe implements end-to-end media frame encryption in the format
+// of SFrame (RFC 9605) with the AES_128_GCM_SHA256_128 cipher suite, and its
+// application to H.265 access units for the WebRTC relay path.
+//
+// DTLS-SRTP protects each hop; when offers travel through the relay broker
+// (internal/relay), whoever runs the broker could answer in the camera's
+// place. Frames protected here can only be read by clients holding the
+// sender key, which the camera hands out wrapped under a key pre-shared with
+// each client profile (see WrapKey).
+package sframe
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"sync"
+)
+
+// CipherSuite is the RFC 9605 identifier of AES_128_GCM_SHA256_128.
+const CipherSuite = 0x0004
+
+const (
+	keySize   = 16 // Nk
+	nonceSize = 12 // Nn
+	tagSize   = 16 // Nt
+
+	// KeySize is the length of a base key.
+	KeySize = keySize
+)
+
+var (
+	// ErrAuth is returned for a frame that fails authentication.
+	ErrAuth = errors.New("sframe: authentication failed")
+	// ErrUnknownKey is returned for a frame under a KID the receiver lacks.
+	ErrUnknownKey = errors.New("sframe: unknown key id")
+)
+
+// keyState is the AEAD and salt derived from one base key (RFC 9605 §4.4.2).
+type keyState struct {
+	aead cipher.AEAD
+	salt [nonceSize]byte
+}
+
+func deriveKey(kid uint64, baseKey []byte) (*keyState, error) {
+	if len(baseKey) != KeySize {
+		return nil, fmt.Errorf("sframe: base key is %d bytes, want %d", len(baseKey), KeySize)
+	}
+	secret, err := hkdf.Extract(sha256.New, baseKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	suffix := binary.BigEndian.AppendUint64(nil, kid)
+	suffix = binary.BigEndian.AppendUint16(suffix, CipherSuite)
+	key, err := hkdf.Expand(sha256.New, secret, "SFrame 1.0 Secret key "+string(suffix), keySize)
+	if err != nil {
+		return nil, err
+	}
+	salt, err := hkdf.Expand(sha256.New, secret, "SFrame 1.0 Secret salt "+string(suffix), nonceSize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	ks := &keyState{aead: aead}
+	copy(ks.salt[:], salt)
+	return ks, nil
+}
+
+func (ks *keyState) nonce(ctr uint64) []byte {
+	n := ks.salt
+	var c [8]byte
+	binary.BigEndian.PutUint64(c[:], ctr)
+	for i := range c {
+		n[nonceSize-8+i] ^= c[i]
+	}
+	return n[:]
+}
+
+// Sender encrypts frames under one key. It is safe for concurrent use.
+type Sender struct {
+	kid uint64
+	ks  *keyState
+
+	mu  sync.Mutex
+	ctr uint64
+}
+
+// NewSender returns a Sender for baseKey, announced to receivers as kid.
+func NewSender(kid uint64, baseKey []byte) (*Sender, error) {
+	ks, err := deriveKey(kid, baseKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Sender{kid: kid, ks: ks}, nil
+}
+
+// KID returns the key ID frames are sent under.
+func (s *Sender) KID() uint64 { return s.kid }
+
+// Encrypt appends the SFrame ciphertext of plaintext (header, encrypted
+// payload and tag) to dst. metadata is authenticated but not sent.
+func (s *Sender) Encrypt(dst, plaintext, metadata []byte) []byte {
+	s.mu.Lock()
+	ctr := s.ctr
+	s.ctr++
+	s.mu.Unlock()
+
+	start := len(dst)
+	dst = appendHeader(dst, s.kid, ctr)
+	aad := append(dst[start:len(dst):len(dst)], metadata...)
+	return s.ks.aead.Seal(dst, s.ks.nonce(ctr), plaintext, aad)
+}
+
+// Receiver decrypts frames from any sender whose key it was given. It is
+// not safe for concurrent use.
+type Receiver struct {
+	keys map[uint64]*keyState
+}
+
+// NewReceiver returns a Receiver without keys.
+func NewReceiver() *Receiver {
+	return &Receiver{keys: make(map[uint64]*keyState)}
+}
+
+// AddKey installs the base key of kid, replacing any previous one.
+func (r *Receiver) AddKey(kid uint64, baseKey []byte) error {
+	ks, err := deriveKey(kid, baseKey)
+	if err != nil {
+		return err
+	}
+	r.keys[kid] = ks
+	return nil
+}
+
+// Decrypt authenticates and decrypts one SFrame ciphertext.
+func (r *Receiver) Decrypt(ciphertext, metadata []byte) ([]byte, error) {
+	kid, ctr, n, err := parseHeader(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	ks, ok := r.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w %d", ErrUnknownKey, kid)
+	}
+	if len(ciphertext)-n < tagSize {
+		return nil, errors.New("sframe: truncated ciphertext")
+	}
+	aad := append(ciphertext[:n:n], metadata...)
+	plain, err := ks.aead.Open(nil, ks.nonce(ctr), ciphertext[n:], aad)
+	if err != nil {
+		return nil, ErrAuth
+	}
+	return plain, nil
+}
+
+// appendHeader appends the SFrame header (RFC 9605 §4.3): a config byte
+// X|K|Y|C, then KID and CTR in big-endian when too large to inline (>= 8).
+func appendHeader(dst []byte, kid, ctr uint64) []byte {
+	config := byte(0)
+	var ext []byte
+	if kid < 8 {
+		config |= byte(kid) << 4
+	} else {
+		n := minBytes(kid)
+		config |= 0x80 | byte(n-1)<<4
+		ext = appendUint(ext, kid, n)
+	}
+	if ctr < 8 {
+		config |= byte(ctr)
+	} else {
+		n := minBytes(ctr)
+		config |= 0x08 | byte(n-1)
+		ext = appendUint(ext, ctr, n)
+	}
+	return append(append(dst, config), ext...)
+}
+
+// parseHeader returns the KID and CTR of an SFrame header and its length.
+func parseHeader(b []byte) (kid, ctr uint64, n int, err error) {
+	if len(b) < 1 {
+		return 0, 0, 0, errors.New("sframe: empty frame")
+	}
+	config := b[0]
+	n = 1
+	field := func(extended bool, v byte) (uint64, error) {
+		if !extended {
+			return uint64(v), nil
+		}
+		size := int(v) + 1
+		if len(b) < n+size {
+			return 0, errors.New("sframe: truncated header")
+		}
+		var x uint64
+		for _, c := range b[n : n+size] {
+			x = x<<8 | uint64(c)
+		}
+		n += size
+		return x, nil
+	}
+	if kid, err = field(config&0x80 != 0, config>>4&0x07); err != nil {
+		return 0, 0, 0, err
+	}
+	if ctr, err = field(config&0x08 != 0, config&0x07); err != nil {
+		return 0, 0, 0, err
+	}
+	return kid, ctr, n, nil
+}
+
+func minBytes(v uint64) int {
+	return max(1, (bits.Len64(v)+7)/8)
+}
+
+func appendUint(dst []byte, v uint64, n int) []byte {
+	for i := n - 1; i >= 0; i-- {
+		dst = append(dst, byte(v>>(8*i)))
+	}
+	return dst
+}
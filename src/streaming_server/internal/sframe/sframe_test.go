@@ -0,0 +1,180 @@
+package sframe
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/codec"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/types"
+)
+
+var testKey = []byte("0123456789abcdef")
+
+func TestHeader(t *testing.T) {
+	cases := []struct {
+		kid, ctr uint64
+		want     []byte
+	}{
+		{0, 0, []byte{0x00}},
+		{7, 7, []byte{0x77}},
+		{8, 0, []byte{0x80, 0x08}},
+		{0, 8, []byte{0x08, 0x08}},
+		{0x1234, 0x10000, []byte{0x9a, 0x12, 0x34, 0x01, 0x00, 0x00}},
+		{math.MaxUint64, math.MaxUint64, append([]byte{0xff}, bytes.Repeat([]byte{0xff}, 16)...)},
+	}
+	for _, c := range cases {
+		got := appendHeader(nil, c.kid, c.ctr)
+		if !bytes.Equal(got, c.want) {
+			t.Errorf("header(%d, %d) = %x, want %x", c.kid, c.ctr, got, c.want)
+		}
+		kid, ctr, n, err := parseHeader(append(got, 0xee))
+		if err != nil || kid != c.kid || ctr != c.ctr || n != len(c.want) {
+			t.Errorf("parse %x = %d, %d, %d, %v", got, kid, ctr, n, err)
+		}
+	}
+	if _, _, _, err := parseHeader([]byte{0x9a, 0x12}); err == nil {
+		t.Error("truncated header parsed")
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	s, err := NewSender(300, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewReceiver()
+	if err := r.AddKey(300, testKey); err != nil {
+		t.Fatal(err)
+	}
+	meta := []byte{0x02, 0x01}
+	var cts [][]byte
+	for i := range 10 {
+		plain := bytes.Repeat([]byte{byte(i)}, 100+i)
+		ct := s.Encrypt(nil, plain, meta)
+		if bytes.Contains(ct, plain[:16]) {
+			t.Fatalf("frame %d: plaintext visible in ciphertext", i)
+		}
+		got, err := r.Decrypt(ct, meta)
+		if err != nil || !bytes.Equal(got, plain) {
+			t.Fatalf("frame %d: Decrypt = %x, %v", i, got, err)
+		}
+		cts = append(cts, ct)
+	}
+	if bytes.Equal(cts[0][3:19], cts[1][3:19]) {
+		t.Error("counter not advancing: equal keystreams")
+	}
+
+	tampered := append([]byte(nil), cts[0]...)
+	tampered[len(tampered)-20] ^= 1
+	if _, err := r.Decrypt(tampered, meta); !errors.Is(err, ErrAuth) {
+		t.Errorf("tampered payload: %v", err)
+	}
+	if _, err := r.Decrypt(cts[0], []byte{0x26, 0x01}); !errors.Is(err, ErrAuth) {
+		t.Errorf("other metadata: %v", err)
+	}
+	if _, err := NewReceiver().Decrypt(cts[0], meta); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("without key: %v", err)
+	}
+}
+
+func TestProtectH265(t *testing.T) {
+	vps := []byte{0x40, 0x01, 0x0c, 0x01}
+	idr := append([]byte{0x26, 0x01}, bytes.Repeat([]byte{0, 0, 3, 1, 0, 0, 3, 0, 0xff}, 400)...)
+	sei := []byte{0x4e, 0x01, 0x05, 0x01, 0x80}
+	var data []byte
+	for _, nal := range [][]byte{vps, sei, idr} {
+		data = append(append(data, 0, 0, 0, 1), nal...)
+	}
+	frame := &types.VideoFrame{Data: data, FrameNumber: 30}
+	p := codec.NewProcessor()
+	if err := p.Process(frame); err != nil {
+		t.Fatal(err)
+	}
+
+	s, _ := NewSender(1, testKey)
+	r := NewReceiver()
+	r.AddKey(1, testKey)
+	for range 300 { // counters of every header size, ciphertexts ending in zero
+		out := s.ProtectH265(frame)
+		if out.FrameNumber != 30 || len(out.NALUs) != 3 {
+			t.Fatalf("protected frame %+v", out)
+		}
+		// The protected stream must split into the same NAL units again
+		reparsed := &types.VideoFrame{Data: append([]byte(nil), out.Data...)}
+		if err := p.Process(reparsed); err != nil {
+			t.Fatal(err)
+		}
+		if len(reparsed.NALUs) != 3 {
+			t.Fatalf("protected stream splits into %d NAL units", len(reparsed.NALUs))
+		}
+		for i, want := range [][]byte{vps, sei, idr} {
+			nb := reparsed.NALUs[i]
+			nal := reparsed.Data[nb.Offset : nb.Offset+nb.Length]
+			if i > 0 && bytes.Contains(nal, want[2:]) {
+				t.Fatalf("NAL unit %d sent in the clear", i)
+			}
+			got, err := r.UnprotectH265(nal)
+			if err != nil || !bytes.Equal(got, want) {
+				t.Fatalf("NAL unit %d: %x, %v", i, got, err)
+			}
+		}
+	}
+}
+
+func TestWrapKey(t *testing.T) {
+	secret := []byte("profile secret shared when pairing")
+	nonce := bytes.Repeat([]byte{7}, MinNonceSize)
+	g, err := WrapKey(secret, "phone", nonce, 9, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(g.WrappedKey, testKey) {
+		t.Fatal("key visible in grant")
+	}
+	key, err := UnwrapKey(secret, "phone", nonce, g)
+	if err != nil || !bytes.Equal(key, testKey) {
+		t.Fatalf("UnwrapKey = %x, %v", key, err)
+	}
+
+	other := bytes.Repeat([]byte{8}, MinNonceSize)
+	for name, unwrap := range map[string]func() ([]byte, error){
+		"secret":  func() ([]byte, error) { return UnwrapKey([]byte("another secret entirely"), "phone", nonce, g) },
+		"profile": func() ([]byte, error) { return UnwrapKey(secret, "tablet", nonce, g) },
+		"nonce":   func() ([]byte, error) { return UnwrapKey(secret, "phone", other, g) },
+		"kid": func() ([]byte, error) {
+			g2 := *g
+			g2.KID++
+			return UnwrapKey(secret, "phone", nonce, &g2)
+		},
+	} {
+		if _, err := unwrap(); !errors.Is(err, ErrAuth) {
+			t.Errorf("other %s: %v", name, err)
+		}
+	}
+	if _, err := WrapKey(secret, "phone", nonce[:8], 9, testKey); err == nil {
+		t.Error("short nonce accepted")
+	}
+}
+
+func TestLoadProfiles(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.json")
+	os.WriteFile(good, []byte(`{"phone": {"secret": "MDEyMzQ1Njc4OWFiY2RlZg==", "e2e": true}, "tv": {"secret": "MDEyMzQ1Njc4OWFiY2RlZg=="}}`), 0600)
+	profiles, err := LoadProfiles(good)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !profiles["phone"].E2E || profiles["tv"].E2E || !bytes.Equal(profiles["phone"].Secret, testKey) {
+		t.Errorf("profiles = %+v", profiles)
+	}
+
+	short := filepath.Join(dir, "short.json")
+	os.WriteFile(short, []byte(`{"phone": {"secret": "c2hvcnQ=", "e2e": true}}`), 0600)
+	if _, err := LoadProfiles(short); err == nil {
+		t.Error("short secret accepted")
+	}
+}
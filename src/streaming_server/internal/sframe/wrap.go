@@ -0,0 +1,123 @@
+package sframe
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// MinNonceSize is the shortest client nonce WrapKey accepts; a fresh nonce
+// per offer keeps a replayed grant from being accepted as current.
+const MinNonceSize = 16
+
+// Profile is a client profile allowed to request end-to-end encryption.
+type Profile struct {
+	// Secret is shared with the client out of band (e.g. when pairing);
+	// grants for the profile are wrapped under a key derived from it.
+	Secret []byte `json:"secret"`
+	// E2E turns frame encryption on for the profile. Offers from a
+	// profile with it off get the plain stream.
+	E2E bool `json:"e2e"`
+}
+
+// LoadProfiles reads client profiles from a JSON object of profile name to
+// Profile, with secrets in base64 (at least KeySize bytes).
+func LoadProfiles(path string) (map[string]Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var profiles map[string]Profile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for name, p := range profiles {
+		if len(p.Secret) < KeySize {
+			return nil, fmt.Errorf("%s: profile %q: secret must be at least %d bytes", path, name, KeySize)
+		}
+	}
+	return profiles, nil
+}
+
+// Grant is the sender key as handed to one client: AES-GCM sealed under a
+// key derived from the profile secret and the client's nonce, with the
+// profile name and KID authenticated.
+type Grant struct {
+	KID        uint64 `json:"kid"`
+	Suite      int    `json:"cipher_suite"`
+	WrappedKey []byte `json:"wrapped_key"` // 12-byte GCM nonce, sealed key
+}
+
+// WrapKey grants baseKey (the key of kid) to the holder of secret.
+func WrapKey(secret []byte, profile string, nonce []byte, kid uint64, baseKey []byte) (*Grant, error) {
+	aead, aad, err := wrapAEAD(secret, profile, nonce, kid)
+	if err != nil {
+		return nil, err
+	}
+	iv := make([]byte, aead.NonceSize(), aead.NonceSize()+len(baseKey)+aead.Overhead())
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+	return &Grant{KID: kid, Suite: CipherSuite, WrappedKey: aead.Seal(iv, iv, baseKey, aad)}, nil
+}
+
+// UnwrapKey returns the base key of a grant made by WrapKey for the same
+// secret, profile and nonce.
+func UnwrapKey(secret []byte, profile string, nonce []byte, g *Grant) ([]byte, error) {
+	if g.Suite != CipherSuite {
+		return nil, fmt.Errorf("sframe: cipher suite %#04x not supported", g.Suite)
+	}
+	aead, aad, err := wrapAEAD(secret, profile, nonce, g.KID)
+	if err != nil {
+		return nil, err
+	}
+	if len(g.WrappedKey) < aead.NonceSize() {
+		return nil, errors.New("sframe: truncated grant")
+	}
+	iv, sealed := g.WrappedKey[:aead.NonceSize()], g.WrappedKey[aead.NonceSize():]
+	key, err := aead.Open(nil, iv, sealed, aad)
+	if err != nil {
+		return nil, ErrAuth
+	}
+	return key, nil
+}
+
+func wrapAEAD(secret []byte, profile string, nonce []byte, kid uint64) (cipher.AEAD, []byte, error) {
+	if len(nonce) < MinNonceSize {
+		return nil, nil, fmt.Errorf("sframe: client nonce is %d bytes, want at least %d", len(nonce), MinNonceSize)
+	}
+	key, err := hkdf.Key(sha256.New, secret, nonce, "pet-camera e2e key wrap "+profile, keySize)
+	if err != nil {
+		return nil, nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	aad := binary.BigEndian.AppendUint64([]byte(profile+"\x00"), kid)
+	return aead, aad, nil
+}
+
+// DecodeNonce decodes a client nonce sent in base64.
+func DecodeNonce(s string) ([]byte, error) {
+	nonce, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("sframe: client nonce: %w", err)
+	}
+	if len(nonce) < MinNonceSize {
+		return nil, fmt.Errorf("sframe: client nonce is %d bytes, want at least %d", len(nonce), MinNonceSize)
+	}
+	return nonce, nil
+}
@@ -0,0 +1,145 @@
+package shm
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+// ClockDomain is the clock a frame timestamp was taken from.
+type ClockDomain uint32
+
+func (d ClockDomain) String() string {
+	switch d {
+	case ClockRealtime:
+		return "realtime"
+	case ClockMonotonic:
+		return "monotonic"
+	default:
+		return fmt.Sprintf("clock_%d", uint32(d))
+	}
+}
+
+// CaptureClock is the camera daemon's clock-domain handshake: the clock it
+// stamps frames with and that clock's offset to the wall clock.
+type CaptureClock struct {
+	Domain       ClockDomain
+	RealtimeBase time.Duration // CLOCK_REALTIME minus Domain's clock
+}
+
+const (
+	// clockRefresh is how often a FrameClock re-reads the handshake, which
+	// the daemon refreshes about once a second.
+	clockRefresh = time.Second
+	// calibrationFrames is how many frames the startup calibration takes.
+	calibrationFrames = 30
+)
+
+// Plausible capture-to-read latency of the calibration frames; outside it the
+// clocks still disagree after the handshake.
+const (
+	minPlausibleLatency = -50 * time.Millisecond
+	maxPlausibleLatency = 2 * time.Second
+)
+
+// ClockStatus is a FrameClock's handshake and calibration result.
+type ClockStatus struct {
+	Domain          string  `json:"domain"`
+	Published       bool    `json:"published"` // false: no handshake, realtime assumed
+	Calibrated      bool    `json:"calibrated"`
+	MedianLatencyMs float64 `json:"median_latency_ms"` // of the calibration frames, before correction
+	CorrectionMs    float64 `json:"correction_ms"`     // added to every timestamp (0 when consistent)
+}
+
+// FrameClock converts frame timestamps to this process's wall clock using the
+// daemon's handshake. The first frames calibrate it: if their median latency
+// is implausible (a daemon in another time namespace, or one without the
+// handshake that stamps CLOCK_MONOTONIC), later timestamps are shifted by
+// that median, so latencies are at least consistent relative to startup.
+type FrameClock struct {
+	read func() (CaptureClock, bool)
+	now  func() time.Time
+
+	mu         sync.Mutex
+	clock      CaptureClock
+	published  bool
+	nextRead   time.Time
+	samples    []time.Duration
+	calibrated bool
+	median     time.Duration
+	correction time.Duration
+}
+
+// Clock converts the timestamps of every frame the readers of this process
+// return.
+var Clock = NewFrameClock(ReadCaptureClock)
+
+// NewFrameClock creates a FrameClock reading the handshake with read.
+func NewFrameClock(read func() (CaptureClock, bool)) *FrameClock {
+	return &FrameClock{read: read, now: time.Now}
+}
+
+// Wall converts a frame timestamp as decoded from the SHM (seconds and
+// nanoseconds of the daemon's clock) to wall-clock time. Call it once per
+// new frame, as it is read: the first calibrationFrames calls calibrate.
+func (c *FrameClock) Wall(ts time.Time) time.Time {
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !now.Before(c.nextRead) {
+		c.clock, c.published = c.read()
+		if !c.published {
+			c.clock = CaptureClock{Domain: ClockRealtime}
+		}
+		c.nextRead = now.Add(clockRefresh)
+	}
+	wall := ts
+	if c.clock.Domain != ClockRealtime {
+		wall = ts.Add(c.clock.RealtimeBase)
+	}
+
+	if !c.calibrated {
+		c.samples = append(c.samples, now.Sub(wall))
+		if len(c.samples) >= calibrationFrames {
+			c.calibrateLocked()
+		}
+	}
+	return wall.Add(c.correction)
+}
+
+// calibrateLocked checks the latencies of the first frames. Caller holds c.mu.
+func (c *FrameClock) calibrateLocked() {
+	slices.Sort(c.samples)
+	c.median = c.samples[len(c.samples)/2]
+	c.samples = nil
+	c.calibrated = true
+
+	source := c.clock.Domain.String()
+	if !c.published {
+		source = "unpublished, assuming realtime"
+	}
+	if c.median >= minPlausibleLatency && c.median <= maxPlausibleLatency {
+		logger.Info("Clock", "Frame clock %s, median capture latency %v", source, c.median.Round(time.Millisecond))
+		return
+	}
+	c.correction = c.median
+	logger.Warn("Clock", "Frame clock (%s) is %v off the wall clock, correcting timestamps by it; latencies are relative to startup",
+		source, c.median.Round(time.Millisecond))
+}
+
+// Status returns the handshake and calibration result.
+func (c *FrameClock) Status() ClockStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return ClockStatus{
+		Domain:          c.clock.Domain.String(),
+		Published:       c.published,
+		Calibrated:      c.calibrated,
+		MedianLatencyMs: float64(c.median) / float64(time.Millisecond),
+		CorrectionMs:    float64(c.correction) / float64(time.Millisecond),
+	}
+}
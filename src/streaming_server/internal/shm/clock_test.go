@@ -0,0 +1,61 @@
+package shm
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFrameClockHandshake(t *testing.T) {
+	boot := time.Date(2026, 3, 1, 6, 0, 0, 0, time.UTC) // wall time at CLOCK_MONOTONIC 0
+	now := boot.Add(time.Hour)
+	base := boot.Sub(time.Unix(0, 0))
+	c := NewFrameClock(func() (CaptureClock, bool) {
+		return CaptureClock{Domain: ClockMonotonic, RealtimeBase: base}, true
+	})
+	c.now = func() time.Time { return now }
+
+	// A monotonic timestamp 20ms before now, as decoded from the SHM
+	for i := 0; i < calibrationFrames; i++ {
+		mono := time.Unix(0, 0).Add(now.Sub(boot) - 20*time.Millisecond)
+		if got := c.Wall(mono); !got.Equal(now.Add(-20 * time.Millisecond)) {
+			t.Fatalf("frame %d at %v, want %v", i, got, now.Add(-20*time.Millisecond))
+		}
+		now = now.Add(33 * time.Millisecond)
+	}
+	st := c.Status()
+	if !st.Calibrated || !st.Published || st.Domain != "monotonic" || st.MedianLatencyMs != 20 || st.CorrectionMs != 0 {
+		t.Errorf("status %+v", st)
+	}
+
+	// An NTP step moves the base; the next refresh picks it up
+	now = now.Add(clockRefresh)
+	base += 5 * time.Second
+	mono := time.Unix(0, 0).Add(now.Sub(boot))
+	if got := c.Wall(mono); !got.Equal(now.Add(5 * time.Second)) {
+		t.Errorf("after step %v", got)
+	}
+}
+
+func TestFrameClockCalibrationCorrects(t *testing.T) {
+	// No handshake, but the daemon stamps CLOCK_MONOTONIC: timestamps decode
+	// as 1970 and the calibration shifts them onto the wall clock
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	uptime := 3 * time.Hour
+	c := NewFrameClock(func() (CaptureClock, bool) { return CaptureClock{}, false })
+	c.now = func() time.Time { return now }
+	for i := 0; i < calibrationFrames; i++ {
+		if got := c.Wall(time.Unix(0, 0).Add(uptime)); i == 0 && got.Year() != 1970 {
+			t.Errorf("first frame corrected before calibration: %v", got)
+		}
+		now = now.Add(33 * time.Millisecond)
+		uptime += 33 * time.Millisecond
+	}
+	st := c.Status()
+	if st.Published || !st.Calibrated || st.CorrectionMs == 0 {
+		t.Fatalf("status %+v", st)
+	}
+	got := c.Wall(time.Unix(0, 0).Add(uptime))
+	if lag := now.Sub(got); lag < 0 || lag > 100*time.Millisecond {
+		t.Errorf("latency after correction %v", lag)
+	}
+}
@@ -0,0 +1,269 @@
+package shm
+
+/*
+#cgo CFLAGS: -I../../../capture
+
+#include <stdint.h>
+#include <time.h>
+#include <sys/mman.h>
+#include <sys/stat.h>
+#include <fcntl.h>
+#include <unistd.h>
+#include "shared_memory.h"
+
+// Report consumer demand into this process's slot of the capture control SHM.
+// The segment is opened per call: the camera daemon recreates it on restart,
+// and a long-lived mapping would keep writing into the unlinked old one.
+// Returns the slot index, or -1 if the SHM is missing or all slots are taken.
+static int control_report(int32_t pid, uint32_t consumers, uint32_t power) {
+    int fd = shm_open(SHM_NAME_CONTROL, O_RDWR, 0666);
+    if (fd == -1) return -1;
+    CaptureControl* ctl = (CaptureControl*)mmap(
+        NULL, sizeof(CaptureControl), PROT_READ | PROT_WRITE, MAP_SHARED, fd, 0);
+    close(fd);
+    if (ctl == MAP_FAILED) return -1;
+
+    struct timespec now;
+    clock_gettime(CLOCK_MONOTONIC, &now);
+    const int64_t now_ms = (int64_t)now.tv_sec * 1000 + now.tv_nsec / 1000000;
+
+    int idx = -1;
+    for (int i = 0; i < CONTROL_MAX_CLIENTS && idx < 0; i++) {
+        if (__atomic_load_n(&ctl->slots[i].pid, __ATOMIC_ACQUIRE) == pid) idx = i;
+    }
+    // Claim a free slot, or one left behind by a crashed consumer
+    for (int i = 0; i < CONTROL_MAX_CLIENTS && idx < 0; i++) {
+        int32_t owner = __atomic_load_n(&ctl->slots[i].pid, __ATOMIC_ACQUIRE);
+        int64_t hb = __atomic_load_n(&ctl->slots[i].heartbeat_ms, __ATOMIC_ACQUIRE);
+        if (owner != 0 && now_ms - hb <= CONTROL_STALE_MS) continue;
+        if (__atomic_compare_exchange_n(&ctl->slots[i].pid, &owner, pid, 0,
+                                        __ATOMIC_ACQ_REL, __ATOMIC_ACQUIRE)) {
+            idx = i;
+        }
+    }
+    if (idx >= 0) {
+        __atomic_store_n(&ctl->slots[idx].consumers, consumers, __ATOMIC_RELEASE);
+        __atomic_store_n(&ctl->slots[idx].power, power, __ATOMIC_RELEASE);
+        __atomic_store_n(&ctl->slots[idx].heartbeat_ms, now_ms, __ATOMIC_RELEASE);
+    }
+
+    munmap(ctl, sizeof(CaptureControl));
+    return idx;
+}
+
+// Release this process's slot on shutdown so the daemon stops waiting for it.
+static void control_release(int32_t pid) {
+    int fd = shm_open(SHM_NAME_CONTROL, O_RDWR, 0666);
+    if (fd == -1) return;
+    CaptureControl* ctl = (CaptureControl*)mmap(
+        NULL, sizeof(CaptureControl), PROT_READ | PROT_WRITE, MAP_SHARED, fd, 0);
+    close(fd);
+    if (ctl == MAP_FAILED) return;
+    for (int i = 0; i < CONTROL_MAX_CLIENTS; i++) {
+        int32_t owner = pid;
+        __atomic_compare_exchange_n(&ctl->slots[i].pid, &owner, 0, 0,
+                                    __ATOMIC_ACQ_REL, __ATOMIC_ACQUIRE);
+    }
+    munmap(ctl, sizeof(CaptureControl));
+}
+
+// Read the clock domain the camera daemon published (see CaptureClock in
+// shared_memory.h). Returns 0 on success, -1 if the SHM is missing, too
+// small (an older daemon) or nothing was published yet.
+static int control_read_clock(uint32_t* clock_id, int64_t* realtime_base_ns) {
+    int fd = shm_open(SHM_NAME_CONTROL, O_RDONLY, 0);
+    if (fd == -1) return -1;
+    struct stat st;
+    if (fstat(fd, &st) != 0 || st.st_size < (off_t)sizeof(CaptureControl)) {
+        close(fd);
+        return -1;
+    }
+    const CaptureControl* ctl = (const CaptureControl*)mmap(
+        NULL, sizeof(CaptureControl), PROT_READ, MAP_SHARED, fd, 0);
+    close(fd);
+    if (ctl == MAP_FAILED) return -1;
+
+    int ret = -1;
+    for (int tries = 0; tries < 100 && ret < 0; tries++) {
+        uint32_t seq = __atomic_load_n(&ctl->clock.seq, __ATOMIC_ACQUIRE);
+        if (seq == 0) break;
+        if (seq & 1) continue;
+        uint32_t id = __atomic_load_n(&ctl->clock.clock_id, __ATOMIC_RELAXED);
+        int64_t base = __atomic_load_n(&ctl->clock.realtime_base_ns, __ATOMIC_RELAXED);
+        __atomic_thread_fence(__ATOMIC_ACQUIRE);
+        if (__atomic_load_n(&ctl->clock.seq, __ATOMIC_RELAXED) == seq) {
+            *clock_id = id;
+            *realtime_base_ns = base;
+            ret = 0;
+        }
+    }
+    munmap((void*)ctl, sizeof(CaptureControl));
+    return ret;
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+// PowerMode is the capture power mode a process asks for while idle
+// (CAPTURE_POWER_* in shm_constants.h). The daemon thins out detector and
+// MJPEG frames to the deepest mode requested, unless some process reports
+// H.265 consumers.
+type PowerMode uint32
+
+const (
+	PowerFull    PowerMode = C.CAPTURE_POWER_FULL    // every frame
+	PowerLow     PowerMode = C.CAPTURE_POWER_LOW     // detector and MJPEG at 5 fps
+	PowerSuspend PowerMode = C.CAPTURE_POWER_SUSPEND // no detector frames, MJPEG at 1 fps
+)
+
+func (m PowerMode) String() string {
+	switch m {
+	case PowerFull:
+		return "full"
+	case PowerLow:
+		return "low"
+	case PowerSuspend:
+		return "suspend"
+	default:
+		return fmt.Sprintf("power_%d", uint32(m))
+	}
+}
+
+// Demand tells the camera daemon how many H.265 consumers this process has,
+// through the capture control SHM. With no consumers anywhere the daemon
+// pauses the encoder; the first consumer resumes it.
+//
+// Going idle is delayed by HoldOff so a page reload or a quick reconnect does
+// not flap the encoder. Becoming busy is reported on the next tick.
+type Demand struct {
+	// Interval between reports; must stay well under the daemon's stale timeout.
+	Interval time.Duration
+	// HoldOff keeps reporting demand this long after the last consumer leaves.
+	// Zero or negative never reports idle, i.e. the encoder is never paused.
+	HoldOff time.Duration
+
+	count func() int
+	hyst  demandHysteresis
+	power atomic.Uint32 // PowerMode
+
+	mu      sync.Mutex
+	stop    chan struct{}
+	done    chan struct{}
+	stopped bool
+}
+
+// NewDemand creates a demand reporter; count returns the current number of
+// consumers (WebRTC viewers, active recordings) and is polled each Interval.
+func NewDemand(count func() int) *Demand {
+	return &Demand{
+		Interval: 500 * time.Millisecond,
+		HoldOff:  30 * time.Second,
+		count:    count,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// SetPower sets the power mode requested from the next report on.
+func (d *Demand) SetPower(mode PowerMode) {
+	d.power.Store(uint32(mode))
+}
+
+// Start begins periodic reporting.
+func (d *Demand) Start() {
+	d.hyst.holdOff = d.HoldOff
+	go d.run()
+}
+
+// Stop halts reporting and releases the SHM slot.
+func (d *Demand) Stop() {
+	d.mu.Lock()
+	if !d.stopped {
+		close(d.stop)
+		d.stopped = true
+	}
+	d.mu.Unlock()
+	<-d.done
+}
+
+func (d *Demand) run() {
+	defer close(d.done)
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	pid := C.int32_t(os.Getpid())
+	defer C.control_release(pid)
+
+	lastReported := -1
+	for {
+		n := d.hyst.update(d.count(), time.Now())
+		slot := C.control_report(pid, C.uint32_t(n), C.uint32_t(d.power.Load()))
+		if slot >= 0 && (n > 0) != (lastReported > 0) {
+			if n > 0 {
+				logger.Info("Demand", "Consumers present (%d), requesting H.265 encode", n)
+			} else {
+				logger.Info("Demand", "No consumers for %v, encoder may pause", d.HoldOff)
+			}
+		}
+		if slot >= 0 {
+			lastReported = n
+		}
+
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Clock domains of frame timestamps (CAPTURE_CLOCK_* in shm_constants.h).
+const (
+	ClockRealtime  ClockDomain = C.CAPTURE_CLOCK_REALTIME
+	ClockMonotonic ClockDomain = C.CAPTURE_CLOCK_MONOTONIC
+)
+
+// ReadCaptureClock reads the clock domain the camera daemon published in the
+// capture control SHM. ok is false without one (no daemon, or one from
+// before the handshake).
+func ReadCaptureClock() (c CaptureClock, ok bool) {
+	var id C.uint32_t
+	var base C.int64_t
+	if C.control_read_clock(&id, &base) != 0 {
+		return CaptureClock{}, false
+	}
+	return CaptureClock{Domain: ClockDomain(id), RealtimeBase: time.Duration(base)}, true
+}
+
+// demandHysteresis reports the live count while there are consumers and keeps
+// reporting the last non-zero count until holdOff has passed without any.
+type demandHysteresis struct {
+	holdOff  time.Duration
+	lastBusy time.Time
+	lastN    int
+}
+
+func (h *demandHysteresis) update(n int, now time.Time) int {
+	if h.holdOff <= 0 && n == 0 {
+		return 1
+	}
+	if n > 0 {
+		h.lastBusy = now
+		h.lastN = n
+		return n
+	}
+	if h.lastN > 0 && now.Sub(h.lastBusy) < h.holdOff {
+		return h.lastN
+	}
+	h.lastN = 0
+	return 0
+}
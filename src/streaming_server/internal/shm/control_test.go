@@ -0,0 +1,37 @@
+package shm
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDemandHysteresis(t *testing.T) {
+	h := demandHysteresis{holdOff: 10 * time.Second}
+	t0 := time.Unix(1000, 0)
+
+	steps := []struct {
+		at   time.Duration
+		n    int
+		want int
+	}{
+		{0, 0, 0},                // idle from the start
+		{1 * time.Second, 2, 2},  // first consumer: report immediately
+		{2 * time.Second, 0, 2},  // viewer reloads: held
+		{3 * time.Second, 1, 1},  // back again
+		{4 * time.Second, 0, 1},  // left
+		{12 * time.Second, 0, 1}, // within hold-off of the last busy tick
+		{13 * time.Second, 0, 0}, // hold-off elapsed
+		{15 * time.Second, 0, 0}, // stays idle
+		{16 * time.Second, 3, 3}, // resume without delay
+	}
+	for _, s := range steps {
+		if got := h.update(s.n, t0.Add(s.at)); got != s.want {
+			t.Fatalf("t=%v n=%d: got %d, want %d", s.at, s.n, got, s.want)
+		}
+	}
+
+	never := demandHysteresis{}
+	if got := never.update(0, t0); got != 1 {
+		t.Fatalf("hold-off disabled: got %d, want 1", got)
+	}
+}
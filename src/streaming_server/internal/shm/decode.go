@@ -0,0 +1,225 @@
+package shm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+	"unicode/utf8"
+)
+
+// Bounds on what the camera stack writes. A snapshot outside them was torn
+// by a concurrent write or corrupted, and its sizes must not be trusted to
+// index memory.
+const (
+	MaxFrameDimension = 4096     // width or height in pixels
+	MaxH265FrameSize  = 16 << 20 // one encoded access unit
+	MaxNV12FrameSize  = MaxFrameDimension * MaxFrameDimension * 3 / 2
+)
+
+// ErrCorrupt is returned (wrapped) for a snapshot that fails validation.
+var ErrCorrupt = errors.New("corrupt shm snapshot")
+
+func corrupt(format string, args ...any) error {
+	return fmt.Errorf("%w: "+format, append([]any{ErrCorrupt}, args...)...)
+}
+
+// H265Frame is a validated H265ZeroCopyFrame.
+type H265Frame struct {
+	FrameNumber uint64
+	Timestamp   time.Time
+	CameraID    int
+	Width       int
+	Height      int
+	DataSize    int    // 0: nothing written yet
+	Desc        []byte // hb_mem_common_buf_t; aliases the snapshot
+	Version     uint32
+}
+
+// DecodeH265Frame decodes and validates an H265ZeroCopyFrame snapshot.
+func DecodeH265Frame(b []byte) (H265Frame, error) {
+	l := &h265FrameLayout
+	if uintptr(len(b)) < l.size {
+		return H265Frame{}, corrupt("H.265 frame is %d bytes, want %d", len(b), l.size)
+	}
+	f := H265Frame{
+		FrameNumber: u64(b, l.frameNumber),
+		CameraID:    int(i32(b, l.cameraID)),
+		Width:       int(i32(b, l.width)),
+		Height:      int(i32(b, l.height)),
+		DataSize:    int(u32(b, l.dataSize)),
+		Desc:        b[l.desc : l.desc+h265DescSize],
+		Version:     u32(b, l.version),
+	}
+	if f.DataSize == 0 {
+		return f, nil
+	}
+	ts, err := timespec(b, l.tvSec, l.tvNsec)
+	if err != nil {
+		return H265Frame{}, err
+	}
+	f.Timestamp = ts
+	if err := checkDimensions(f.Width, f.Height); err != nil {
+		return H265Frame{}, err
+	}
+	if f.DataSize > MaxH265FrameSize {
+		return H265Frame{}, corrupt("H.265 data_size %d exceeds %d", f.DataSize, MaxH265FrameSize)
+	}
+	return f, nil
+}
+
+// NV12Frame is a validated ZeroCopyFrame.
+type NV12Frame struct {
+	FrameNumber uint64
+	Timestamp   time.Time
+	CameraID    int
+	Width       int
+	Height      int
+	PlaneSizes  []int  // bytes to copy from each plane
+	Desc        []byte // hb_mem_graphic_buf_t; aliases the snapshot
+	Version     uint32 // 0: nothing written yet
+}
+
+// DataSize is the number of bytes the frame's planes hold together.
+func (f *NV12Frame) DataSize() int {
+	n := 0
+	for _, s := range f.PlaneSizes {
+		n += s
+	}
+	return n
+}
+
+// DecodeNV12Frame decodes and validates a ZeroCopyFrame snapshot. The planes
+// must hold an NV12 image of the frame's size: one contiguous plane, or a Y
+// plane followed by an interleaved UV plane.
+func DecodeNV12Frame(b []byte) (NV12Frame, error) {
+	l := &nv12FrameLayout
+	if uintptr(len(b)) < l.size {
+		return NV12Frame{}, corrupt("NV12 frame is %d bytes, want %d", len(b), l.size)
+	}
+	f := NV12Frame{
+		FrameNumber: u64(b, l.frameNumber),
+		CameraID:    int(i32(b, l.cameraID)),
+		Width:       int(i32(b, l.width)),
+		Height:      int(i32(b, l.height)),
+		Desc:        b[l.desc : l.desc+nv12DescSize],
+		Version:     u32(b, l.version),
+	}
+	if f.Version == 0 {
+		return f, nil
+	}
+	planes := i32(b, l.planeCnt)
+	if planes < 1 || planes > MaxPlanes {
+		return NV12Frame{}, corrupt("NV12 plane_cnt %d", planes)
+	}
+	ts, err := timespec(b, l.tvSec, l.tvNsec)
+	if err != nil {
+		return NV12Frame{}, err
+	}
+	f.Timestamp = ts
+	if err := checkDimensions(f.Width, f.Height); err != nil {
+		return NV12Frame{}, err
+	}
+	if f.Width%2 != 0 || f.Height%2 != 0 {
+		return NV12Frame{}, corrupt("NV12 frame %dx%d has odd dimensions", f.Width, f.Height)
+	}
+	total := 0
+	for i := range int(planes) {
+		size := u64(b, l.planeSize+uintptr(i)*8)
+		if size > MaxNV12FrameSize-uint64(total) {
+			return NV12Frame{}, corrupt("NV12 planes exceed %d bytes", MaxNV12FrameSize)
+		}
+		f.PlaneSizes = append(f.PlaneSizes, int(size))
+		total += int(size)
+	}
+	luma := f.Width * f.Height
+	if total < luma*3/2 || (planes == 2 && (f.PlaneSizes[0] < luma || f.PlaneSizes[1] < luma/2)) {
+		return NV12Frame{}, corrupt("NV12 planes %v too small for %dx%d", f.PlaneSizes, f.Width, f.Height)
+	}
+	return f, nil
+}
+
+// Detection is one entry of a validated LatestDetectionResult.
+type Detection struct {
+	ClassName  string
+	Confidence float32
+	X, Y, W, H int
+}
+
+// Detections is a validated LatestDetectionResult.
+type Detections struct {
+	FrameNumber uint64
+	Timestamp   float64 // seconds since the epoch
+	Entries     []Detection
+	Version     uint32
+}
+
+// DecodeDetections decodes and validates a LatestDetectionResult snapshot.
+func DecodeDetections(b []byte) (Detections, error) {
+	l := &detectionLayout
+	if uintptr(len(b)) < l.size {
+		return Detections{}, corrupt("detection result is %d bytes, want %d", len(b), l.size)
+	}
+	d := Detections{
+		FrameNumber: u64(b, l.frameNumber),
+		Timestamp:   math.Float64frombits(u64(b, l.timestamp)),
+		Version:     u32(b, l.version),
+	}
+	if math.IsNaN(d.Timestamp) || d.Timestamp < 0 || d.Timestamp > math.MaxInt64/1e9 {
+		return Detections{}, corrupt("detection timestamp %v", d.Timestamp)
+	}
+	n := i32(b, l.numDetections)
+	if n < 0 || n > MaxDetections {
+		return Detections{}, corrupt("num_detections %d", n)
+	}
+	for i := range uintptr(n) {
+		e := b[l.detections+i*l.entrySize : l.detections+(i+1)*l.entrySize]
+		name := e[l.className : l.className+uintptr(classNameSize)]
+		end := bytes.IndexByte(name, 0)
+		if end <= 0 || !utf8.Valid(name[:end]) {
+			return Detections{}, corrupt("detection %d class_name %q", i, name)
+		}
+		det := Detection{
+			ClassName:  string(name[:end]),
+			Confidence: math.Float32frombits(u32(e, l.confidence)),
+			X:          int(i32(e, l.x)),
+			Y:          int(i32(e, l.y)),
+			W:          int(i32(e, l.w)),
+			H:          int(i32(e, l.h)),
+		}
+		if !(det.Confidence >= 0 && det.Confidence <= 1) {
+			return Detections{}, corrupt("detection %d confidence %v", i, det.Confidence)
+		}
+		if det.W < 0 || det.H < 0 || det.W > MaxFrameDimension || det.H > MaxFrameDimension ||
+			det.X < -MaxFrameDimension || det.X > MaxFrameDimension ||
+			det.Y < -MaxFrameDimension || det.Y > MaxFrameDimension {
+			return Detections{}, corrupt("detection %d box %d,%d %dx%d", i, det.X, det.Y, det.W, det.H)
+		}
+		d.Entries = append(d.Entries, det)
+	}
+	return d, nil
+}
+
+func checkDimensions(w, h int) error {
+	if w < 1 || h < 1 || w > MaxFrameDimension || h > MaxFrameDimension {
+		return corrupt("frame size %dx%d", w, h)
+	}
+	return nil
+}
+
+// timespec reads a struct timespec with the board's 64-bit time_t and long.
+func timespec(b []byte, secOff, nsecOff uintptr) (time.Time, error) {
+	sec, nsec := int64(u64(b, secOff)), int64(u64(b, nsecOff))
+	if sec < 0 || nsec < 0 || nsec >= int64(time.Second) {
+		return time.Time{}, corrupt("timestamp %d.%09d", sec, nsec)
+	}
+	return time.Unix(sec, nsec), nil
+}
+
+// The structs are written by C on the same machine, hence native byte order.
+
+func u64(b []byte, off uintptr) uint64 { return binary.NativeEndian.Uint64(b[off:]) }
+func u32(b []byte, off uintptr) uint32 { return binary.NativeEndian.Uint32(b[off:]) }
+func i32(b []byte, off uintptr) int32  { return int32(binary.NativeEndian.Uint32(b[off:])) }
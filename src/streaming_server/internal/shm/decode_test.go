@@ -0,0 +1,266 @@
+package shm
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+// Writers of valid snapshots, laid out like the camera stack's structs.
+
+func putU64(b []byte, off uintptr, v uint64) { binary.NativeEndian.PutUint64(b[off:], v) }
+func putU32(b []byte, off uintptr, v uint32) { binary.NativeEndian.PutUint32(b[off:], v) }
+
+var testTime = time.Unix(1760000000, 123456789)
+
+func validH265Frame() []byte {
+	l := &h265FrameLayout
+	b := make([]byte, l.size)
+	putU64(b, l.frameNumber, 42)
+	putU64(b, l.tvSec, uint64(testTime.Unix()))
+	putU64(b, l.tvNsec, uint64(testTime.Nanosecond()))
+	putU32(b, l.cameraID, 1)
+	putU32(b, l.width, 1280)
+	putU32(b, l.height, 720)
+	putU32(b, l.dataSize, 30000)
+	b[l.desc] = 0xab
+	putU32(b, l.version, 7)
+	return b
+}
+
+func validNV12Frame() []byte {
+	l := &nv12FrameLayout
+	b := make([]byte, l.size)
+	putU64(b, l.frameNumber, 42)
+	putU64(b, l.tvSec, uint64(testTime.Unix()))
+	putU64(b, l.tvNsec, uint64(testTime.Nanosecond()))
+	putU32(b, l.width, 640)
+	putU32(b, l.height, 360)
+	putU64(b, l.planeSize, 640*360)
+	putU64(b, l.planeSize+8, 640*360/2)
+	putU32(b, l.planeCnt, 2)
+	b[l.desc] = 0xcd
+	putU32(b, l.version, 3)
+	return b
+}
+
+func validDetections() []byte {
+	l := &detectionLayout
+	b := make([]byte, l.size)
+	putU64(b, l.frameNumber, 40)
+	putU64(b, l.timestamp, math.Float64bits(1760000000.5))
+	putU32(b, l.numDetections, 2)
+	for i, name := range []string{"cat", "food_bowl"} {
+		e := b[l.detections+uintptr(i)*l.entrySize:]
+		copy(e[l.className:], name)
+		putU32(e, l.confidence, math.Float32bits(0.875))
+		putU32(e, l.x, 100)
+		putU32(e, l.y, uint32(120+i))
+		putU32(e, l.w, 64)
+		putU32(e, l.h, 48)
+	}
+	putU32(b, l.version, 9)
+	return b
+}
+
+func TestLayoutContract(t *testing.T) {
+	h := &h265FrameLayout
+	n := &nv12FrameLayout
+	d := &detectionLayout
+	for _, c := range []struct {
+		name      string
+		off, size uintptr
+		limit     uintptr
+	}{
+		{"H265 data_size", h.dataSize, 4, h.desc},
+		{"H265 hb_mem_buf_data", h.desc, h265DescSize, h.version},
+		{"H265 version", h.version, 4, h.size},
+		{"NV12 plane_size", n.planeSize, 8 * MaxPlanes, n.planeCnt},
+		{"NV12 hb_mem_buf_data", n.desc, nv12DescSize, n.version},
+		{"detections", d.detections, d.entrySize * MaxDetections, d.version},
+		{"class_name", d.className, uintptr(classNameSize), d.confidence},
+		{"bbox", d.x, 16, d.entrySize},
+	} {
+		if c.off+c.size > c.limit {
+			t.Errorf("%s at %d+%d overlaps the next field at %d", c.name, c.off, c.size, c.limit)
+		}
+	}
+	if n.tvNsec != n.tvSec+8 || h.tvNsec != h.tvSec+8 {
+		t.Errorf("struct timespec is not two 64-bit fields")
+	}
+}
+
+func TestDecodeH265Frame(t *testing.T) {
+	f, err := DecodeH265Frame(validH265Frame())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.FrameNumber != 42 || !f.Timestamp.Equal(testTime) || f.CameraID != 1 || f.Width != 1280 ||
+		f.Height != 720 || f.DataSize != 30000 || f.Desc[0] != 0xab || len(f.Desc) != h265DescSize || f.Version != 7 {
+		t.Errorf("decoded %+v", f)
+	}
+
+	empty, err := DecodeH265Frame(make([]byte, h265FrameLayout.size))
+	if err != nil || empty.DataSize != 0 {
+		t.Errorf("zeroed segment: %+v, %v", empty, err)
+	}
+}
+
+func TestDecodeNV12Frame(t *testing.T) {
+	f, err := DecodeNV12Frame(validNV12Frame())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.FrameNumber != 42 || !f.Timestamp.Equal(testTime) || f.Width != 640 || f.Height != 360 ||
+		f.DataSize() != 640*360*3/2 || len(f.Desc) != nv12DescSize || f.Desc[0] != 0xcd || f.Version != 3 {
+		t.Errorf("decoded %+v", f)
+	}
+
+	empty, err := DecodeNV12Frame(make([]byte, nv12FrameLayout.size))
+	if err != nil || empty.Version != 0 {
+		t.Errorf("zeroed segment: %+v, %v", empty, err)
+	}
+}
+
+func TestDecodeDetections(t *testing.T) {
+	d, err := DecodeDetections(validDetections())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.FrameNumber != 40 || d.Timestamp != 1760000000.5 || d.Version != 9 || len(d.Entries) != 2 {
+		t.Fatalf("decoded %+v", d)
+	}
+	want := Detection{ClassName: "food_bowl", Confidence: 0.875, X: 100, Y: 121, W: 64, H: 48}
+	if d.Entries[1] != want {
+		t.Errorf("entry 1 = %+v, want %+v", d.Entries[1], want)
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	h, n, d := &h265FrameLayout, &nv12FrameLayout, &detectionLayout
+	entry := func(off uintptr) uintptr { return d.detections + off }
+	cases := []struct {
+		name    string
+		valid   func() []byte
+		decode  func([]byte) error
+		corrupt func(b []byte)
+	}{
+		{"h265 data_size", validH265Frame, decodeH265, func(b []byte) { putU32(b, h.dataSize, math.MaxUint32) }},
+		{"h265 width", validH265Frame, decodeH265, func(b []byte) { putU32(b, h.width, 0) }},
+		{"h265 height", validH265Frame, decodeH265, func(b []byte) { putU32(b, h.height, math.MaxUint32) }},
+		{"h265 tv_nsec", validH265Frame, decodeH265, func(b []byte) { putU64(b, h.tvNsec, 1e9) }},
+		{"h265 short", validH265Frame, func(b []byte) error { return decodeH265(b[:h.version]) }, func([]byte) {}},
+		{"nv12 plane_cnt", validNV12Frame, decodeNV12, func(b []byte) { putU32(b, n.planeCnt, MaxPlanes+1) }},
+		{"nv12 plane_cnt 0", validNV12Frame, decodeNV12, func(b []byte) { putU32(b, n.planeCnt, 0) }},
+		{"nv12 plane_size", validNV12Frame, decodeNV12, func(b []byte) { putU64(b, n.planeSize, math.MaxUint64) }},
+		{"nv12 short plane", validNV12Frame, decodeNV12, func(b []byte) { putU64(b, n.planeSize+8, 100) }},
+		{"nv12 odd width", validNV12Frame, decodeNV12, func(b []byte) { putU32(b, n.width, 641) }},
+		{"nv12 negative height", validNV12Frame, decodeNV12, func(b []byte) { putU32(b, n.height, math.MaxUint32) }},
+		{"num_detections", validDetections, decodeDetections, func(b []byte) { putU32(b, d.numDetections, MaxDetections+1) }},
+		{"negative num_detections", validDetections, decodeDetections, func(b []byte) { putU32(b, d.numDetections, math.MaxUint32) }},
+		{"unterminated class_name", validDetections, decodeDetections, func(b []byte) {
+			for i := range classNameSize {
+				b[entry(d.className)+uintptr(i)] = 'x'
+			}
+		}},
+		{"empty class_name", validDetections, decodeDetections, func(b []byte) { b[entry(d.className)] = 0 }},
+		{"NaN confidence", validDetections, decodeDetections, func(b []byte) { putU32(b, entry(d.confidence), math.Float32bits(float32(math.NaN()))) }},
+		{"negative box", validDetections, decodeDetections, func(b []byte) { putU32(b, entry(d.w), math.MaxUint32) }},
+		{"NaN timestamp", validDetections, decodeDetections, func(b []byte) { putU64(b, d.timestamp, math.Float64bits(math.NaN())) }},
+	}
+	for _, c := range cases {
+		b := c.valid()
+		c.corrupt(b)
+		if err := c.decode(b); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("%s: err = %v, want ErrCorrupt", c.name, err)
+		}
+	}
+}
+
+func decodeH265(b []byte) error {
+	_, err := DecodeH265Frame(b)
+	return err
+}
+
+func decodeNV12(b []byte) error {
+	_, err := DecodeNV12Frame(b)
+	return err
+}
+
+func decodeDetections(b []byte) error {
+	_, err := DecodeDetections(b)
+	return err
+}
+
+// corruptRandomly overwrites a few random bytes or aligned words of b with
+// random or boundary values, the way a torn or stray write would.
+func corruptRandomly(rng *rand.Rand, b []byte) {
+	extremes := []uint32{0, 1, math.MaxInt32, math.MaxInt32 + 1, math.MaxUint32}
+	for range 1 + rng.IntN(4) {
+		switch rng.IntN(3) {
+		case 0:
+			b[rng.IntN(len(b))] = byte(rng.Uint32())
+		case 1:
+			putU32(b, uintptr(rng.IntN(len(b)/4)*4), extremes[rng.IntN(len(extremes))])
+		default:
+			putU32(b, uintptr(rng.IntN(len(b)/4)*4), rng.Uint32())
+		}
+	}
+}
+
+// Whatever a snapshot holds, decoding must not panic and every size it
+// accepts must be within bounds.
+func TestDecodeCorruptedBuffers(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	for i := range 20000 {
+		b := validH265Frame()
+		corruptRandomly(rng, b)
+		if f, err := DecodeH265Frame(b); err == nil && f.DataSize != 0 {
+			if f.DataSize > MaxH265FrameSize || f.Width < 1 || f.Width > MaxFrameDimension ||
+				f.Height < 1 || f.Height > MaxFrameDimension || len(f.Desc) != h265DescSize {
+				t.Fatalf("iteration %d: accepted %+v", i, f)
+			}
+		} else if err != nil && !errors.Is(err, ErrCorrupt) {
+			t.Fatalf("iteration %d: %v", i, err)
+		}
+
+		b = validNV12Frame()
+		corruptRandomly(rng, b)
+		if f, err := DecodeNV12Frame(b); err == nil && f.Version != 0 {
+			if len(f.PlaneSizes) < 1 || len(f.PlaneSizes) > MaxPlanes || f.DataSize() > MaxNV12FrameSize ||
+				f.DataSize() < f.Width*f.Height*3/2 || f.Width < 1 || f.Height < 1 {
+				t.Fatalf("iteration %d: accepted %+v", i, f)
+			}
+		} else if err != nil && !errors.Is(err, ErrCorrupt) {
+			t.Fatalf("iteration %d: %v", i, err)
+		}
+
+		b = validDetections()
+		corruptRandomly(rng, b)
+		if d, err := DecodeDetections(b); err == nil {
+			if len(d.Entries) > MaxDetections {
+				t.Fatalf("iteration %d: %d detections", i, len(d.Entries))
+			}
+			for _, e := range d.Entries {
+				if e.ClassName == "" || len(e.ClassName) >= classNameSize || !(e.Confidence >= 0 && e.Confidence <= 1) ||
+					e.W < 0 || e.H < 0 {
+					t.Fatalf("iteration %d: accepted %+v", i, e)
+				}
+			}
+		} else if !errors.Is(err, ErrCorrupt) {
+			t.Fatalf("iteration %d: %v", i, err)
+		}
+	}
+
+	// Truncated snapshots are rejected rather than read past.
+	for _, b := range [][]byte{validH265Frame(), validNV12Frame(), validDetections()} {
+		for n := range len(b) {
+			_ = decodeH265(b[:n])
+			_ = decodeNV12(b[:n])
+			_ = decodeDetections(b[:n])
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package shm
+
+import "sync/atomic"
+
+// DupGuard drops a frame whose frame_number matches the previous read. The
+// writer can stall with its index unchanged, and a reader that polls past a
+// wrap or re-reads the same slot would otherwise deliver the frame twice.
+// Only an exact repeat is dropped: a lower number means the camera restarted.
+//
+// Duplicate is not safe for concurrent use; Skipped may be read from any goroutine.
+type DupGuard struct {
+	last    uint64
+	primed  bool
+	skipped atomic.Uint64
+}
+
+// Duplicate reports whether frameNumber repeats the last frame seen, and
+// records it as the last frame otherwise.
+func (g *DupGuard) Duplicate(frameNumber uint64) bool {
+	if g.primed && frameNumber == g.last {
+		g.skipped.Add(1)
+		return true
+	}
+	g.last = frameNumber
+	g.primed = true
+	return false
+}
+
+// Skipped returns the number of duplicate frames dropped so far.
+func (g *DupGuard) Skipped() uint64 {
+	return g.skipped.Load()
+}
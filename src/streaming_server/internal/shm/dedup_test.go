@@ -0,0 +1,28 @@
+package shm
+
+import "testing"
+
+func TestDupGuard(t *testing.T) {
+	var g DupGuard
+	steps := []struct {
+		n    uint64
+		want bool
+	}{
+		{0, false}, // first frame is never a duplicate, even number 0
+		{0, true},  // writer stalled
+		{1, false},
+		{2, false},
+		{2, true},
+		{2, true},
+		{0, false}, // camera restarted and numbering reset
+		{1, false},
+	}
+	for i, s := range steps {
+		if got := g.Duplicate(s.n); got != s.want {
+			t.Fatalf("step %d frame %d: got %v, want %v", i, s.n, got, s.want)
+		}
+	}
+	if got := g.Skipped(); got != 3 {
+		t.Fatalf("Skipped() = %d, want 3", got)
+	}
+}
@@ -0,0 +1,81 @@
+package shm
+
+/*
+#cgo CFLAGS: -I../../../capture
+
+#include <semaphore.h>
+#include <time.h>
+#include "shared_memory.h"
+*/
+import "C"
+
+import "unsafe"
+
+// Field offsets of the shared_memory.h structs the decoders read, taken from
+// the C compiler so the Go side cannot drift from the header.
+var (
+	h265FrameLayout = struct {
+		size, frameNumber, tvSec, tvNsec, cameraID, width, height, dataSize, desc, version uintptr
+	}{
+		size:        C.sizeof_H265ZeroCopyFrame,
+		frameNumber: unsafe.Offsetof(C.H265ZeroCopyFrame{}.frame_number),
+		tvSec:       unsafe.Offsetof(C.H265ZeroCopyFrame{}.timestamp) + unsafe.Offsetof(C.struct_timespec{}.tv_sec),
+		tvNsec:      unsafe.Offsetof(C.H265ZeroCopyFrame{}.timestamp) + unsafe.Offsetof(C.struct_timespec{}.tv_nsec),
+		cameraID:    unsafe.Offsetof(C.H265ZeroCopyFrame{}.camera_id),
+		width:       unsafe.Offsetof(C.H265ZeroCopyFrame{}.width),
+		height:      unsafe.Offsetof(C.H265ZeroCopyFrame{}.height),
+		dataSize:    unsafe.Offsetof(C.H265ZeroCopyFrame{}.data_size),
+		desc:        unsafe.Offsetof(C.H265ZeroCopyFrame{}.hb_mem_buf_data),
+		version:     unsafe.Offsetof(C.H265ZeroCopyFrame{}.version),
+	}
+
+	nv12FrameLayout = struct {
+		size, frameNumber, tvSec, tvNsec, cameraID, width, height, planeSize, planeCnt, desc, version uintptr
+	}{
+		size:        C.sizeof_ZeroCopyFrame,
+		frameNumber: unsafe.Offsetof(C.ZeroCopyFrame{}.frame_number),
+		tvSec:       unsafe.Offsetof(C.ZeroCopyFrame{}.timestamp) + unsafe.Offsetof(C.struct_timespec{}.tv_sec),
+		tvNsec:      unsafe.Offsetof(C.ZeroCopyFrame{}.timestamp) + unsafe.Offsetof(C.struct_timespec{}.tv_nsec),
+		cameraID:    unsafe.Offsetof(C.ZeroCopyFrame{}.camera_id),
+		width:       unsafe.Offsetof(C.ZeroCopyFrame{}.width),
+		height:      unsafe.Offsetof(C.ZeroCopyFrame{}.height),
+		planeSize:   unsafe.Offsetof(C.ZeroCopyFrame{}.plane_size),
+		planeCnt:    unsafe.Offsetof(C.ZeroCopyFrame{}.plane_cnt),
+		desc:        unsafe.Offsetof(C.ZeroCopyFrame{}.hb_mem_buf_data),
+		version:     unsafe.Offsetof(C.ZeroCopyFrame{}.version),
+	}
+
+	detectionLayout = struct {
+		size, frameNumber, timestamp, numDetections, detections, version uintptr
+		entrySize, className, confidence, x, y, w, h                     uintptr
+	}{
+		size:          C.sizeof_LatestDetectionResult,
+		frameNumber:   unsafe.Offsetof(C.LatestDetectionResult{}.frame_number),
+		timestamp:     unsafe.Offsetof(C.LatestDetectionResult{}.timestamp),
+		numDetections: unsafe.Offsetof(C.LatestDetectionResult{}.num_detections),
+		detections:    unsafe.Offsetof(C.LatestDetectionResult{}.detections),
+		version:       unsafe.Offsetof(C.LatestDetectionResult{}.version),
+		entrySize:     C.sizeof_DetectionEntry,
+		className:     unsafe.Offsetof(C.DetectionEntry{}.class_name),
+		confidence:    unsafe.Offsetof(C.DetectionEntry{}.confidence),
+		x:             unsafe.Offsetof(C.DetectionEntry{}.bbox) + unsafe.Offsetof(C.DetectionBBox{}.x),
+		y:             unsafe.Offsetof(C.DetectionEntry{}.bbox) + unsafe.Offsetof(C.DetectionBBox{}.y),
+		w:             unsafe.Offsetof(C.DetectionEntry{}.bbox) + unsafe.Offsetof(C.DetectionBBox{}.w),
+		h:             unsafe.Offsetof(C.DetectionEntry{}.bbox) + unsafe.Offsetof(C.DetectionBBox{}.h),
+	}
+)
+
+// Array bounds from shm_constants.h.
+const (
+	MaxDetections = C.MAX_DETECTIONS
+	MaxPlanes     = C.ZEROCOPY_MAX_PLANES
+	classNameSize = len(C.DetectionEntry{}.class_name)
+	h265DescSize  = C.HB_MEM_COM_BUF_SIZE
+	nv12DescSize  = C.HB_MEM_GRAPHIC_BUF_SIZE
+)
+
+// h265FrameBytes views a local H265ZeroCopyFrame snapshot as the bytes
+// DecodeH265Frame reads.
+func h265FrameBytes(f *C.H265ZeroCopyFrame) []byte {
+	return unsafe.Slice((*byte)(unsafe.Pointer(f)), C.sizeof_H265ZeroCopyFrame)
+}
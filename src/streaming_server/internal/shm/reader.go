@@ -71,6 +71,11 @@ int import_h265_copy(const uint8_t* com_buf_data, uint32_t data_size,
     hb_mem_common_buf_t out_buf = {0};
     int ret = hb_mem_import_com_buf(&in_buf, &out_buf);
     if (ret != 0) return ret;
+    if (data_size > out_buf.size) {
+        // data_size came from SHM: never read past the imported mapping
+        hb_mem_free_buf(out_buf.fd);
+        return -EINVAL;
+    }
 
     hb_mem_invalidate_buf_with_vaddr((uint64_t)out_buf.virt_addr, out_buf.size);
     memcpy(dst, out_buf.virt_addr, data_size);
@@ -97,6 +102,11 @@ int import_h265_open(const uint8_t* com_buf_data, uint32_t data_size,
     hb_mem_common_buf_t out_buf = {0};
     int ret = hb_mem_import_com_buf(&in_buf, &out_buf);
     if (ret != 0) return ret;
+    if (data_size > out_buf.size) {
+        // data_size came from SHM: never read past the imported mapping
+        hb_mem_free_buf(out_buf.fd);
+        return -EINVAL;
+    }
 
     hb_mem_invalidate_buf_with_vaddr((uint64_t)out_buf.virt_addr, out_buf.size);
 
@@ -135,6 +145,21 @@ const (
 	FormatH265 = 4
 )
 
+// Importer copies the bitstream a frame's hb_mem buffer descriptor refers to
+// into dst, which is data_size bytes long. See SetImporter.
+type Importer func(desc, dst []byte) error
+
+// importer replaces the VPU import when set; only SetImporter writes it.
+var importer Importer
+
+// SetImporter makes readers opened afterwards resolve frame descriptors with
+// fn instead of importing VPU buffers through hb_mem, so an emulated camera
+// (the integration tests) can feed the real SHM segment on a machine without
+// the VPU. nil restores the VPU import.
+func SetImporter(fn Importer) {
+	importer = fn
+}
+
 // Reader reads H.265 frames from zero-copy shared memory
 type Reader struct {
 	shm         *C.H265ZeroCopyBuffer
@@ -142,6 +167,9 @@ type Reader struct {
 	lastVersion uint32
 	prevHandle  C.h265_import_handle_t
 	hasPrev     bool
+	dup         DupGuard
+	importer    Importer
+	importBuf   []byte // ReadLatest's frame data with an Importer
 }
 
 // Version returns the current SHM frame version (atomic read)
@@ -152,6 +180,12 @@ func (r *Reader) Version() uint32 {
 	return uint32(r.shm.frame.version)
 }
 
+// DuplicatesSkipped returns how many reads were dropped because the frame
+// number repeated the previous read.
+func (r *Reader) DuplicatesSkipped() uint64 {
+	return r.dup.Skipped()
+}
+
 // MeasureFrameInterval observes version changes to determine camera frame interval.
 // Returns measured interval and syncs to the frame boundary.
 func (r *Reader) MeasureFrameInterval(samples int) time.Duration {
@@ -161,16 +195,25 @@ func (r *Reader) MeasureFrameInterval(samples int) time.Duration {
 
 	ver := r.Version()
 
-	// Wait for first version change (sync to frame boundary)
+	// Wait for first version change (sync to frame boundary). The encoder may
+	// be paused for lack of consumers (see Demand), so don't wait forever.
+	deadline := time.Now().Add(2 * time.Second)
 	for r.Version() == ver {
+		if time.Now().After(deadline) {
+			return 33 * time.Millisecond
+		}
 		time.Sleep(100 * time.Microsecond)
 	}
 
 	// Measure intervals between subsequent version changes
 	start := time.Now()
+	deadline = start.Add(2 * time.Second)
 	ver = r.Version()
 	for i := 0; i < samples; i++ {
 		for r.Version() == ver {
+			if time.Now().After(deadline) {
+				return 33 * time.Millisecond
+			}
 			time.Sleep(100 * time.Microsecond)
 		}
 		ver = r.Version()
@@ -188,36 +231,42 @@ func (r *Reader) MeasureFrameInterval(samples int) time.Duration {
 	return interval
 }
 
-// NewReader creates a new H.265 zero-copy reader
+// NewReader creates a new H.265 zero-copy reader, waiting up to 30s for the
+// camera daemon to create the segment.
 func NewReader(shmName string) (*Reader, error) {
 	if shmName == "" {
 		shmName = "/pet_camera_h265_zc"
 	}
 
-	cName := C.CString(shmName)
-	defer C.free(unsafe.Pointer(cName))
-
-	var shm *C.H265ZeroCopyBuffer
 	for i := 0; i < 30; i++ {
-		shm = C.open_h265_zc(cName)
-		if shm != nil {
-			break
+		if r, err := OpenReader(shmName); err == nil {
+			return r, nil
 		}
 		if i%5 == 0 {
 			logger.Info("Reader", "Waiting for %s... (%d/30)", shmName, i+1)
 		}
 		time.Sleep(1 * time.Second)
 	}
+	return nil, fmt.Errorf("failed to open %s (timeout 30s)", shmName)
+}
 
+// OpenReader opens the H.265 zero-copy SHM once, failing immediately if
+// the camera daemon has not created it yet.
+func OpenReader(shmName string) (*Reader, error) {
+	cName := C.CString(shmName)
+	defer C.free(unsafe.Pointer(cName))
+
+	shm := C.open_h265_zc(cName)
 	if shm == nil {
-		return nil, fmt.Errorf("failed to open %s (timeout 30s)", shmName)
+		return nil, fmt.Errorf("failed to open %s", shmName)
 	}
 
 	logger.Info("Reader", "Opened H.265 zero-copy SHM: %s", shmName)
 
 	return &Reader{
-		shm:     shm,
-		shmName: shmName,
+		shm:      shm,
+		shmName:  shmName,
+		importer: importer,
 	}, nil
 }
 
@@ -246,10 +295,25 @@ func (r *Reader) ReadLatest() (*types.VideoFrame, error) {
 	if C.read_h265_frame(r.shm, &cFrame) != 0 {
 		return nil, nil
 	}
-	if cFrame.data_size == 0 {
+	f, err := DecodeH265Frame(h265FrameBytes(&cFrame))
+	if err != nil {
+		return nil, err
+	}
+	if f.DataSize == 0 || r.dup.Duplicate(f.FrameNumber) {
 		return nil, nil
 	}
 
+	if r.importer != nil {
+		if cap(r.importBuf) < f.DataSize {
+			r.importBuf = make([]byte, f.DataSize)
+		}
+		r.importBuf = r.importBuf[:f.DataSize]
+		if err := r.importer(f.Desc, r.importBuf); err != nil {
+			return nil, fmt.Errorf("import frame %d: %w", f.FrameNumber, err)
+		}
+		return newFrame(&f, r.importBuf), nil
+	}
+
 	// Release previous VPU buffer (SendFrame already consumed it synchronously)
 	if r.hasPrev {
 		C.import_h265_close(&r.prevHandle)
@@ -260,7 +324,7 @@ func (r *Reader) ReadLatest() (*types.VideoFrame, error) {
 	var handle C.h265_import_handle_t
 	ret := C.import_h265_open(
 		(*C.uint8_t)(unsafe.Pointer(&cFrame.hb_mem_buf_data[0])),
-		cFrame.data_size,
+		C.uint32_t(f.DataSize),
 		&handle,
 	)
 	if ret != 0 {
@@ -271,23 +335,26 @@ func (r *Reader) ReadLatest() (*types.VideoFrame, error) {
 	r.prevHandle = handle
 	r.hasPrev = true
 
-	timestamp := time.Unix(
-		int64(cFrame.timestamp.tv_sec),
-		int64(cFrame.timestamp.tv_nsec),
-	)
+	return newFrame(&f, data), nil
+}
 
+// newFrame wraps data with the metadata of the SHM frame it came from, its
+// timestamp converted to the wall clock.
+func newFrame(f *H265Frame, data []byte) *types.VideoFrame {
 	return &types.VideoFrame{
 		Data:        data,
-		Timestamp:   timestamp,
-		FrameNumber: uint64(cFrame.frame_number),
-		Width:       int(cFrame.width),
-		Height:      int(cFrame.height),
-		IsIDR:       false,
-	}, nil
+		Timestamp:   Clock.Wall(f.Timestamp),
+		FrameNumber: f.FrameNumber,
+		CameraID:    f.CameraID,
+		Width:       f.Width,
+		Height:      f.Height,
+	}
 }
 
 // ReadLatestCopy reads the latest H.265 frame with import+copy+free in one call.
 // Safe for async consumers (recorder). No VPU buffer lifetime dependency.
+// Returns a nil frame when the buffer is empty or still holds the frame
+// number of the previous read.
 func (r *Reader) ReadLatestCopy() (*types.VideoFrame, error) {
 	return r.ReadLatestCopyBuf(nil)
 }
@@ -304,11 +371,15 @@ func (r *Reader) ReadLatestCopyBuf(dst []byte) (*types.VideoFrame, error) {
 	if C.read_h265_frame(r.shm, &cFrame) != 0 {
 		return nil, nil
 	}
-	if cFrame.data_size == 0 {
+	f, err := DecodeH265Frame(h265FrameBytes(&cFrame))
+	if err != nil {
+		return nil, err
+	}
+	if f.DataSize == 0 || r.dup.Duplicate(f.FrameNumber) {
 		return nil, nil
 	}
 
-	dataSize := int(cFrame.data_size)
+	dataSize := f.DataSize
 	buf := dst
 	if cap(buf) < dataSize {
 		buf = make([]byte, dataSize)
@@ -316,9 +387,16 @@ func (r *Reader) ReadLatestCopyBuf(dst []byte) (*types.VideoFrame, error) {
 		buf = buf[:dataSize]
 	}
 
+	if r.importer != nil {
+		if err := r.importer(f.Desc, buf); err != nil {
+			return nil, fmt.Errorf("import frame %d: %w", f.FrameNumber, err)
+		}
+		return newFrame(&f, buf), nil
+	}
+
 	ret := C.import_h265_copy(
 		(*C.uint8_t)(unsafe.Pointer(&cFrame.hb_mem_buf_data[0])),
-		cFrame.data_size,
+		C.uint32_t(dataSize),
 		(*C.uint8_t)(unsafe.Pointer(&buf[0])),
 		C.uint32_t(dataSize),
 	)
@@ -326,17 +404,5 @@ func (r *Reader) ReadLatestCopyBuf(dst []byte) (*types.VideoFrame, error) {
 		return nil, fmt.Errorf("import_h265_copy failed: %d", ret)
 	}
 
-	timestamp := time.Unix(
-		int64(cFrame.timestamp.tv_sec),
-		int64(cFrame.timestamp.tv_nsec),
-	)
-
-	return &types.VideoFrame{
-		Data:        buf,
-		Timestamp:   timestamp,
-		FrameNumber: uint64(cFrame.frame_number),
-		Width:       int(cFrame.width),
-		Height:      int(cFrame.height),
-		IsIDR:       false,
-	}, nil
+	return newFrame(&f, buf), nil
 }
@@ -0,0 +1,23 @@
+package shm
+
+/*
+#cgo CFLAGS: -I../../../capture
+
+#include <semaphore.h>
+#include <time.h>
+#include "shared_memory.h"
+*/
+import "C"
+
+// SegmentSizes maps each SHM segment the camera stack creates to the size of
+// its C struct. A segment smaller than this was never ftruncate'd, so the
+// semaphores embedded in it are not initialized.
+func SegmentSizes() map[string]int64 {
+	return map[string]int64{
+		C.SHM_NAME_H265_ZC:    int64(C.sizeof_H265ZeroCopyBuffer),
+		C.SHM_NAME_MJPEG_ZC:   int64(C.sizeof_ZeroCopyFrameBuffer),
+		C.SHM_NAME_YOLO_ZC:    int64(C.sizeof_ZeroCopyFrameBuffer),
+		C.SHM_NAME_DETECTIONS: int64(C.sizeof_LatestDetectionResult),
+		C.SHM_NAME_CONTROL:    int64(C.sizeof_CaptureControl),
+	}
+}
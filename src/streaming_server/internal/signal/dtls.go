@@ -52,8 +52,9 @@ type DTLSSession struct {
 }
 
 // HandshakeDTLS performs DTLS handshake as server on the given packet connection.
+// ctx bounds the handshake; the session's establishment deadline is passed in.
 // The conn should already be multiplexed (STUN/DTLS/SRTP demuxed).
-func HandshakeDTLS(conn net.PacketConn, remoteAddr net.Addr, config *DTLSConfig) (*DTLSSession, error) {
+func HandshakeDTLS(ctx context.Context, conn net.PacketConn, remoteAddr net.Addr, config *DTLSConfig) (*DTLSSession, error) {
 	dtlsConfig := &dtls.Config{
 		Certificates:         []tls.Certificate{config.Certificate},
 		ExtendedMasterSecret: dtls.RequireExtendedMasterSecret,
@@ -75,7 +76,7 @@ func HandshakeDTLS(conn net.PacketConn, remoteAddr net.Addr, config *DTLSConfig)
 
 	// dtls.Server() returns immediately; handshake runs on first Read/Write.
 	// We must explicitly trigger it and wait for completion.
-	if err := dtlsConn.HandshakeContext(context.Background()); err != nil {
+	if err := dtlsConn.HandshakeContext(ctx); err != nil {
 		dtlsConn.Close()
 		return nil, fmt.Errorf("dtls: handshake failed: %w", err)
 	}
@@ -0,0 +1,113 @@
+package signal
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+)
+
+// Address families for host candidates.
+const (
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+)
+
+// DefaultFamilies offers an IPv4 and an IPv6 host candidate, IPv4 preferred.
+var DefaultFamilies = []string{FamilyIPv4, FamilyIPv6}
+
+// ParseFamilies parses a comma-separated address family list ("ipv6,ipv4"),
+// most preferred first.
+func ParseFamilies(list string) ([]string, error) {
+	var families []string
+	for _, f := range strings.Split(list, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f != FamilyIPv4 && f != FamilyIPv6 {
+			return nil, fmt.Errorf("address family must be %s or %s, got %q", FamilyIPv4, FamilyIPv6, f)
+		}
+		if slices.Contains(families, f) {
+			return nil, fmt.Errorf("address family %s listed twice", f)
+		}
+		families = append(families, f)
+	}
+	return families, nil
+}
+
+// AddressFamily returns FamilyIPv4 or FamilyIPv6 for ip; IPv4 peers on a
+// dual-stack socket (::ffff:a.b.c.d) are IPv4.
+func AddressFamily(ip net.IP) string {
+	if ip.To4() != nil {
+		return FamilyIPv4
+	}
+	return FamilyIPv6
+}
+
+// udpNetwork is the socket network serving families: "udp" binds [::] for
+// both (IPv4 arrives as mapped addresses), "udp4"/"udp6" only one.
+func udpNetwork(families []string) string {
+	v4, v6 := slices.Contains(families, FamilyIPv4), slices.Contains(families, FamilyIPv6)
+	switch {
+	case v4 && !v6:
+		return "udp4"
+	case v6 && !v4:
+		return "udp6"
+	}
+	return "udp"
+}
+
+// hostCandidates returns one address per family in order, from addrs (the
+// interface addresses). IPv4 takes the first non-loopback address; IPv6 a
+// global address, preferring a public one to a unique local one (fc00::/7),
+// since link-local addresses need a zone a browser cannot use. A family
+// without an address is left out; with none at all, loopback is returned so
+// local viewers still connect.
+func hostCandidates(families []string, addrs []net.Addr) []net.IP {
+	var v4, v6, ula net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() {
+			continue
+		}
+		ip := ipNet.IP
+		switch {
+		case ip.To4() != nil:
+			if v4 == nil {
+				v4 = ip.To4()
+			}
+		case !ip.IsGlobalUnicast():
+		case ip.IsPrivate():
+			if ula == nil {
+				ula = ip
+			}
+		case v6 == nil:
+			v6 = ip
+		}
+	}
+	if v6 == nil {
+		v6 = ula
+	}
+
+	var ips []net.IP
+	for _, f := range families {
+		if f == FamilyIPv4 && v4 != nil {
+			ips = append(ips, v4)
+		} else if f == FamilyIPv6 && v6 != nil {
+			ips = append(ips, v6)
+		}
+	}
+	if len(ips) == 0 {
+		if len(families) > 0 && families[0] == FamilyIPv6 {
+			return []net.IP{net.IPv6loopback}
+		}
+		return []net.IP{net.IPv4(127, 0, 0, 1).To4()}
+	}
+	return ips
+}
+
+// localCandidates gathers host candidates from the current interface
+// addresses; called per offer because IPv6 prefixes change under a running
+// server (ISP prefix delegation, privacy addresses).
+func localCandidates(families []string) []net.IP {
+	addrs, _ := net.InterfaceAddrs()
+	return hostCandidates(families, addrs)
+}
@@ -0,0 +1,75 @@
+package signal
+
+import (
+	"encoding/binary"
+	"net"
+	"slices"
+	"testing"
+)
+
+func TestParseFamilies(t *testing.T) {
+	got, err := ParseFamilies(" IPv6, ipv4")
+	if err != nil || !slices.Equal(got, []string{FamilyIPv6, FamilyIPv4}) {
+		t.Fatalf("ParseFamilies = %v, %v", got, err)
+	}
+	for _, bad := range []string{"", "ipv5", "ipv4,ipv4"} {
+		if _, err := ParseFamilies(bad); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+	for families, want := range map[string]string{"ipv4": "udp4", "ipv6": "udp6", "ipv6,ipv4": "udp"} {
+		f, _ := ParseFamilies(families)
+		if got := udpNetwork(f); got != want {
+			t.Errorf("udpNetwork(%s) = %s, want %s", families, got, want)
+		}
+	}
+}
+
+func TestHostCandidates(t *testing.T) {
+	ipNet := func(s string) net.Addr { return &net.IPNet{IP: net.ParseIP(s)} }
+	addrs := []net.Addr{
+		ipNet("127.0.0.1"), ipNet("::1"), ipNet("fe80::1"), // loopback, link-local: never
+		ipNet("fd00::5"), ipNet("192.168.1.20"), ipNet("2001:db8::10"), ipNet("10.0.0.2"),
+	}
+	str := func(ips []net.IP) []string {
+		var out []string
+		for _, ip := range ips {
+			out = append(out, ip.String())
+		}
+		return out
+	}
+
+	if got := str(hostCandidates([]string{FamilyIPv6, FamilyIPv4}, addrs)); !slices.Equal(got, []string{"2001:db8::10", "192.168.1.20"}) {
+		t.Errorf("dual stack = %v", got)
+	}
+	if got := str(hostCandidates([]string{FamilyIPv6}, addrs[:5])); !slices.Equal(got, []string{"fd00::5"}) {
+		t.Errorf("unique local fallback = %v", got)
+	}
+	if got := str(hostCandidates([]string{FamilyIPv6}, addrs[:3])); !slices.Equal(got, []string{"::1"}) {
+		t.Errorf("no address = %v", got)
+	}
+	if got := str(hostCandidates(DefaultFamilies, nil)); !slices.Equal(got, []string{"127.0.0.1"}) {
+		t.Errorf("no interfaces = %v", got)
+	}
+}
+
+func TestXORMappedAddressIPv6(t *testing.T) {
+	txn := [12]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+	addr := &net.UDPAddr{IP: net.ParseIP("2001:db8::10"), Port: 54321}
+	attr := buildXORMappedAddress(addr, txn)
+	if len(attr) != 20 || attr[1] != 0x02 {
+		t.Fatalf("attribute % x", attr)
+	}
+	if port := int(binary.BigEndian.Uint16(attr[2:4]) ^ uint16(stunMagicCookie>>16)); port != addr.Port {
+		t.Errorf("port %d", port)
+	}
+	key := binary.BigEndian.AppendUint32(nil, stunMagicCookie)
+	key = append(key, txn[:]...)
+	ip := make(net.IP, 16)
+	for i := range ip {
+		ip[i] = attr[4+i] ^ key[i]
+	}
+	if !ip.Equal(addr.IP) {
+		t.Errorf("address %v", ip)
+	}
+}
@@ -123,9 +123,9 @@ func (ice *ICELite) buildBindingResponse(txnID [12]byte, addr *net.UDPAddr) []by
 func buildXORMappedAddress(addr *net.UDPAddr, txnID [12]byte) []byte {
 	ip4 := addr.IP.To4()
 	if ip4 == nil {
-		// IPv6 - simplified, XOR with magic cookie + txn ID
-		buf := []byte{0, 0x02} // Family: IPv6
-		binary.BigEndian.AppendUint16(buf, uint16(addr.Port)^uint16(stunMagicCookie>>16))
+		// IPv6: XOR with magic cookie + txn ID
+		buf := []byte{0, 0x02} // Reserved, Family: IPv6
+		buf = binary.BigEndian.AppendUint16(buf, uint16(addr.Port)^uint16(stunMagicCookie>>16))
 		ip := addr.IP.To16()
 		xorKey := make([]byte, 16)
 		binary.BigEndian.PutUint32(xorKey[0:4], stunMagicCookie)
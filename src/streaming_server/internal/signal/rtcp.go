@@ -0,0 +1,113 @@
+package signal
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// RTCP packet types (RFC 3550, RFC 4585).
+const (
+	rtcpTypeSR    = 200
+	rtcpTypeRR    = 201
+	rtcpTypeRTPFB = 205 // transport-layer feedback (FMT 1 = generic NACK)
+	rtcpTypePSFB  = 206 // payload-specific feedback (FMT 1 = PLI, FMT 4 = FIR)
+)
+
+var errMalformedRTCP = errors.New("signal: malformed rtcp")
+
+// receptionReport is the report block a receiver sends about our SSRC.
+type receptionReport struct {
+	fractionLost uint8  // fraction lost since the previous report, /256
+	totalLost    int32  // cumulative packets lost (24-bit signed)
+	jitter       uint32 // interarrival jitter in RTP timestamp units
+}
+
+// rtcpFeedback summarizes one compound RTCP packet from the browser.
+type rtcpFeedback struct {
+	report   *receptionReport
+	nackSeqs []uint16
+	plis     int
+	firs     int
+}
+
+// isRTCP reports whether a muxed packet is RTCP rather than RTP (RFC 5761 Section 4).
+func isRTCP(pkt []byte) bool {
+	return len(pkt) >= 8 && pkt[0]>>6 == 2 && pkt[1] >= 192 && pkt[1] <= 223
+}
+
+// parseRTCP walks a decrypted compound RTCP packet and collects the reports
+// and feedback addressed to mediaSSRC.
+func parseRTCP(pkt []byte, mediaSSRC uint32) (rtcpFeedback, error) {
+	var fb rtcpFeedback
+	for len(pkt) > 0 {
+		if len(pkt) < 4 || pkt[0]>>6 != 2 {
+			return fb, errMalformedRTCP
+		}
+		count := int(pkt[0] & 0x1F) // RC for reports, FMT for feedback
+		size := (int(binary.BigEndian.Uint16(pkt[2:4])) + 1) * 4
+		if size > len(pkt) {
+			return fb, errMalformedRTCP
+		}
+		body := pkt[4:size]
+
+		switch pkt[1] {
+		case rtcpTypeSR, rtcpTypeRR:
+			offset := 4 // sender SSRC
+			if pkt[1] == rtcpTypeSR {
+				offset += 20 // sender info
+			}
+			for i := 0; i < count && offset+24 <= len(body); i++ {
+				block := body[offset : offset+24]
+				offset += 24
+				if binary.BigEndian.Uint32(block[0:4]) != mediaSSRC {
+					continue
+				}
+				lost := int32(uint32(block[5])<<16 | uint32(block[6])<<8 | uint32(block[7]))
+				if lost&0x800000 != 0 {
+					lost -= 1 << 24
+				}
+				fb.report = &receptionReport{
+					fractionLost: block[4],
+					totalLost:    lost,
+					jitter:       binary.BigEndian.Uint32(block[12:16]),
+				}
+			}
+
+		case rtcpTypeRTPFB:
+			if count != 1 || len(body) < 8 || binary.BigEndian.Uint32(body[4:8]) != mediaSSRC {
+				break
+			}
+			for fci := body[8:]; len(fci) >= 4; fci = fci[4:] {
+				pid := binary.BigEndian.Uint16(fci[0:2])
+				blp := binary.BigEndian.Uint16(fci[2:4])
+				fb.nackSeqs = append(fb.nackSeqs, pid)
+				for bit := uint16(0); bit < 16; bit++ {
+					if blp&(1<<bit) != 0 {
+						fb.nackSeqs = append(fb.nackSeqs, pid+bit+1)
+					}
+				}
+			}
+
+		case rtcpTypePSFB:
+			if len(body) < 8 {
+				break
+			}
+			switch count {
+			case 1:
+				if binary.BigEndian.Uint32(body[4:8]) == mediaSSRC {
+					fb.plis++
+				}
+			case 4:
+				// FIR carries the target SSRC in its FCI entries.
+				for fci := body[8:]; len(fci) >= 8; fci = fci[8:] {
+					if binary.BigEndian.Uint32(fci[0:4]) == mediaSSRC {
+						fb.firs++
+					}
+				}
+			}
+		}
+
+		pkt = pkt[size:]
+	}
+	return fb, nil
+}
@@ -9,6 +9,7 @@ import (
 	"math/big"
 	"net"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -20,8 +21,24 @@ type Offer struct {
 	Setup       string // "actpass" typically from browser
 	MID         string // media ID (e.g., "0" or "video")
 	PayloadType int    // dynamic PT for H.265
+
+	// Browsers without H.265 (PayloadType is then a default) may offer a
+	// codec the transcoder can produce: the profile 0 PT of each, by codec.
+	HasH265  bool
+	Fallback map[string]int
+
+	// End-to-end frame encryption requested in the offer JSON (not the
+	// SDP): the client profile and a fresh nonce for the key grant.
+	E2EProfile string
+	E2ENonce   []byte
 }
 
+// Codecs a transcoded fallback stream can use, as named in a=rtpmap.
+const (
+	CodecVP9 = "VP9"
+	CodecAV1 = "AV1"
+)
+
 var (
 	reICEUfrag    = regexp.MustCompile(`a=ice-ufrag:(\S+)`)
 	reICEPwd      = regexp.MustCompile(`a=ice-pwd:(\S+)`)
@@ -29,6 +46,8 @@ var (
 	reSetup       = regexp.MustCompile(`a=setup:(\S+)`)
 	reMID         = regexp.MustCompile(`a=mid:(\S+)`)
 	reRtpmap      = regexp.MustCompile(`a=rtpmap:(\d+)\s+H265/90000`)
+	reAnyRtpmap   = regexp.MustCompile(`a=rtpmap:(\d+)\s+(VP9|AV1)/90000`)
+	reProfile     = regexp.MustCompile(`a=fmtp:(\d+)\s.*\bprofile(?:-id)?=(\d+)`)
 )
 
 // ParseOffer extracts relevant fields from a browser SDP offer.
@@ -63,27 +82,62 @@ func ParseOffer(sdp string) (*Offer, error) {
 		offer.MID = "0"
 	}
 
-	if m := reRtpmap.FindStringSubmatch(sdp); len(m) > 1 {
-		fmt.Sscanf(m[1], "%d", &offer.PayloadType)
-	} else {
-		offer.PayloadType = 96 // default dynamic PT
+	offer.PayloadType = 96 // default dynamic PT
+	for _, m := range reRtpmap.FindAllStringSubmatch(sdp, -1) {
+		if pt, ok := parsePayloadType(m[1]); ok {
+			offer.PayloadType = pt
+			offer.HasH265 = true
+			break
+		}
+	}
+
+	// Fallback codecs: the first PT of each without a non-zero profile
+	// (the transcoder produces 8-bit 4:2:0, profile 0 for both)
+	profiles := map[string]string{}
+	for _, m := range reProfile.FindAllStringSubmatch(sdp, -1) {
+		profiles[m[1]] = m[2]
+	}
+	for _, m := range reAnyRtpmap.FindAllStringSubmatch(sdp, -1) {
+		if _, ok := offer.Fallback[m[2]]; ok {
+			continue
+		}
+		if p, ok := profiles[m[1]]; ok && p != "0" {
+			continue
+		}
+		pt, ok := parsePayloadType(m[1])
+		if !ok {
+			continue
+		}
+		if offer.Fallback == nil {
+			offer.Fallback = map[string]int{}
+		}
+		offer.Fallback[m[2]] = pt
 	}
 
 	return offer, nil
 }
 
+// parsePayloadType parses an rtpmap payload type. RTP has 7 bits for it; a
+// larger one would spill into the marker bit of every packet.
+func parsePayloadType(s string) (int, bool) {
+	pt, err := strconv.Atoi(s)
+	return pt, err == nil && pt >= 0 && pt <= 127
+}
+
 // AnswerParams holds local parameters for generating an SDP answer.
 type AnswerParams struct {
 	ICEUfrag        string
 	ICEPwd          string
-	DTLSFingerprint string // "XX:XX:XX:..." sha-256 hex
-	CandidateIP     net.IP
+	DTLSFingerprint string   // "XX:XX:XX:..." sha-256 hex
+	CandidateIPs    []net.IP // host candidates, most preferred first
 	CandidatePort   int
 	PayloadType     int
 	MID             string
+	Codec           string // rtpmap encoding name ("" = H265)
 }
 
-// GenerateAnswer creates an SDP answer string for send-only H.265 video.
+// GenerateAnswer creates an SDP answer string for send-only video, H.265
+// unless p.Codec names a fallback codec.
 func GenerateAnswer(p *AnswerParams) string {
 	sessID := randomSessionID()
 
@@ -97,8 +151,10 @@ func GenerateAnswer(p *AnswerParams) string {
 
 	// Media section
 	sb.WriteString(fmt.Sprintf("m=video %d UDP/TLS/RTP/SAVPF %d\r\n", p.CandidatePort, p.PayloadType))
-	sb.WriteString(fmt.Sprintf("c=IN IP4 %s\r\n", p.CandidateIP.String()))
-	sb.WriteString(fmt.Sprintf("a=rtcp:%d IN IP4 %s\r\n", p.CandidatePort, p.CandidateIP.String()))
+	// The connection address is the preferred candidate's; ICE overrides it
+	addrType, addr := sdpAddress(p.CandidateIPs[0])
+	sb.WriteString(fmt.Sprintf("c=IN %s %s\r\n", addrType, addr))
+	sb.WriteString(fmt.Sprintf("a=rtcp:%d IN %s %s\r\n", p.CandidatePort, addrType, addr))
 
 	// ICE
 	sb.WriteString(fmt.Sprintf("a=ice-ufrag:%s\r\n", p.ICEUfrag))
@@ -116,16 +172,40 @@ func GenerateAnswer(p *AnswerParams) string {
 	sb.WriteString("a=rtcp-rsize\r\n")
 
 	// Codec
-	sb.WriteString(fmt.Sprintf("a=rtpmap:%d H265/90000\r\n", p.PayloadType))
+	codec := p.Codec
+	if codec == "" {
+		codec = "H265"
+	}
+	sb.WriteString(fmt.Sprintf("a=rtpmap:%d %s/90000\r\n", p.PayloadType, codec))
+	if codec == CodecVP9 {
+		sb.WriteString(fmt.Sprintf("a=fmtp:%d profile-id=0\r\n", p.PayloadType))
+	}
+	sb.WriteString(fmt.Sprintf("a=rtcp-fb:%d nack\r\n", p.PayloadType)) // retransmitted from the session's rtx buffer
 
-	// Candidate
-	candidateAddr := p.CandidateIP.String()
-	sb.WriteString(fmt.Sprintf("a=candidate:1 1 udp 2130706431 %s %d typ host\r\n", candidateAddr, p.CandidatePort))
+	// Candidates: one per address family, all on the same dual-stack port
+	for i, ip := range p.CandidateIPs {
+		sb.WriteString(fmt.Sprintf("a=candidate:%d 1 udp %d %s %d typ host\r\n", i+1, hostPriority(i), ip.String(), p.CandidatePort))
+	}
 	sb.WriteString("a=end-of-candidates\r\n")
 
 	return sb.String()
 }
 
+// sdpAddress returns the SDP address type (IP4 or IP6) and address of ip.
+func sdpAddress(ip net.IP) (addrType, addr string) {
+	if AddressFamily(ip) == FamilyIPv4 {
+		return "IP4", ip.To4().String()
+	}
+	return "IP6", ip.String()
+}
+
+// hostPriority is the RFC 8445 priority of the i-th host candidate (type
+// preference 126, local preference counting down from 65535, component 1):
+// 2130706431 for the first.
+func hostPriority(i int) uint32 {
+	return 126<<24 | uint32(65535-i)<<8 | 255
+}
+
 // GenerateICECredentials creates random ICE ufrag and pwd.
 func GenerateICECredentials() (ufrag, pwd string) {
 	ufrag = randomString(4)
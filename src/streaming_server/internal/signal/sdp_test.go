@@ -0,0 +1,128 @@
+package signal
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/sframe"
+)
+
+const testOfferHead = "v=0\r\na=ice-ufrag:abcd\r\na=ice-pwd:0123456789012345678901\r\n" +
+	"a=fingerprint:sha-256 AA:BB\r\na=setup:actpass\r\na=mid:0\r\n"
+
+func TestParseOffer_Fallback(t *testing.T) {
+	offer, err := ParseOffer(testOfferHead +
+		"a=rtpmap:98 VP9/90000\r\na=fmtp:98 profile-id=2\r\n" +
+		"a=rtpmap:100 VP9/90000\r\na=fmtp:100 profile-id=0\r\n" +
+		"a=rtpmap:45 AV1/90000\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offer.HasH265 {
+		t.Error("HasH265 set without an H265 rtpmap")
+	}
+	if offer.Fallback["VP9"] != 100 || offer.Fallback["AV1"] != 45 {
+		t.Errorf("Fallback = %v, want VP9:100 AV1:45", offer.Fallback)
+	}
+
+	offer, err = ParseOffer(testOfferHead + "a=rtpmap:104 H265/90000\r\na=rtpmap:98 VP9/90000\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !offer.HasH265 || offer.PayloadType != 104 {
+		t.Errorf("H.265 offer: HasH265=%v PT=%d", offer.HasH265, offer.PayloadType)
+	}
+}
+
+func TestGenerateAnswer_Codec(t *testing.T) {
+	p := &AnswerParams{CandidateIPs: []net.IP{{127, 0, 0, 1}}, CandidatePort: 20000, PayloadType: 100, MID: "0", Codec: CodecVP9}
+	answer := GenerateAnswer(p)
+	if !strings.Contains(answer, "a=rtpmap:100 VP9/90000\r\n") || !strings.Contains(answer, "a=fmtp:100 profile-id=0\r\n") {
+		t.Errorf("VP9 answer lacks rtpmap/fmtp:\n%s", answer)
+	}
+	p.Codec = ""
+	if answer := GenerateAnswer(p); !strings.Contains(answer, "a=rtpmap:100 H265/90000\r\n") {
+		t.Errorf("default answer is not H.265:\n%s", answer)
+	}
+}
+
+func TestGenerateAnswer_DualStack(t *testing.T) {
+	p := &AnswerParams{
+		CandidateIPs:  []net.IP{net.ParseIP("2001:db8::10"), net.ParseIP("192.168.1.20")},
+		CandidatePort: 20000, PayloadType: 100, MID: "0",
+	}
+	answer := GenerateAnswer(p)
+	for _, want := range []string{
+		"c=IN IP6 2001:db8::10\r\n",
+		"a=rtcp:20000 IN IP6 2001:db8::10\r\n",
+		"a=candidate:1 1 udp 2130706431 2001:db8::10 20000 typ host\r\n",
+		"a=candidate:2 1 udp 2130706175 192.168.1.20 20000 typ host\r\n",
+	} {
+		if !strings.Contains(answer, want) {
+			t.Errorf("answer lacks %q:\n%s", want, answer)
+		}
+	}
+}
+
+// Offers as browsers post them to /offer, trimmed to the attributes the
+// parser reads: Safari with H.265, Chrome with VP9/AV1 only.
+var seedOffers = []string{
+	`{"type":"offer","sdp":"v=0\r\no=- 4611731400430051336 2 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\na=group:BUNDLE 0\r\nm=video 9 UDP/TLS/RTP/SAVPF 96 97 98\r\nc=IN IP4 0.0.0.0\r\na=ice-ufrag:Lz0O\r\na=ice-pwd:Fp2G6bGyLfWPW6bFC1QO3Cxo\r\na=fingerprint:sha-256 5B:2F:1A:00:6C:8E:9B:A1:0E:4D:76:C5:39:8A:2F:07:E3:91:B4:55:6D:2A:80:17:C9:03:F6:4E:1D:B2:38:AA\r\na=setup:actpass\r\na=mid:0\r\na=recvonly\r\na=rtcp-mux\r\na=rtpmap:96 H265/90000\r\na=fmtp:96 level-id=93;profile-id=1;tier-flag=0;tx-mode=SRST\r\na=rtpmap:97 rtx/90000\r\na=fmtp:97 apt=96\r\n"}`,
+	`{"type":"offer","sdp":"v=0\r\nm=video 9 UDP/TLS/RTP/SAVPF 98 100 45\r\na=ice-ufrag:abcd\r\na=ice-pwd:0123456789012345678901\r\na=fingerprint:sha-256 AA:BB\r\na=setup:actpass\r\na=mid:0\r\na=rtpmap:98 VP9/90000\r\na=fmtp:98 profile-id=2\r\na=rtpmap:100 VP9/90000\r\na=fmtp:100 profile-id=0\r\na=rtpmap:45 AV1/90000\r\n"}`,
+	`{"type":"offer","sdp":"a=ice-ufrag:u\na=ice-pwd:p\na=fingerprint:sha-256 F\na=rtpmap:300 H265/90000\na=rtpmap:101 H265/90000\n"}`,
+	`{"type":"offer","sdp":"a=ice-ufrag:u\na=ice-pwd:p\na=fingerprint:sha-256 F\na=rtpmap:96 H265/90000\n","e2e":{"profile":"phone","nonce":"AAECAwQFBgcICQoLDA0ODw=="}}`,
+	`{"type":"offer"}`,
+	`{"sdp":12}`,
+	``,
+}
+
+// FuzzDecodeOffer checks that any offer the parser accepts yields fields
+// that fit RTP and an answer that round-trips them.
+func FuzzDecodeOffer(f *testing.F) {
+	for _, s := range seedOffers {
+		f.Add([]byte(s))
+	}
+	f.Fuzz(func(t *testing.T, offerJSON []byte) {
+		offer, err := decodeOffer(offerJSON)
+		if err != nil {
+			return
+		}
+		if offer.ICEUfrag == "" || offer.ICEPwd == "" || offer.Fingerprint == "" || offer.MID == "" {
+			t.Fatalf("accepted offer with empty fields: %+v", offer)
+		}
+		for codec, pt := range offer.Fallback {
+			if pt < 0 || pt > 127 {
+				t.Fatalf("fallback %s PT %d does not fit RTP", codec, pt)
+			}
+		}
+		if offer.PayloadType < 0 || offer.PayloadType > 127 {
+			t.Fatalf("PT %d does not fit RTP", offer.PayloadType)
+		}
+		if offer.E2EProfile != "" && len(offer.E2ENonce) < sframe.MinNonceSize {
+			t.Fatalf("e2e request with a %d byte nonce", len(offer.E2ENonce))
+		}
+		answer := GenerateAnswer(&AnswerParams{
+			ICEUfrag: "ufrag", ICEPwd: "pwd", DTLSFingerprint: "AA:BB",
+			CandidateIPs: []net.IP{{127, 0, 0, 1}}, CandidatePort: 20000,
+			PayloadType: offer.PayloadType, MID: offer.MID,
+		})
+		back, err := ParseOffer(answer)
+		if err != nil || back.MID != offer.MID || back.PayloadType != offer.PayloadType || !back.HasH265 {
+			t.Fatalf("answer does not round-trip MID %q PT %d: %+v, %v\n%s", offer.MID, offer.PayloadType, back, err, answer)
+		}
+	})
+}
+
+func TestParseOffer_PayloadTypeRange(t *testing.T) {
+	offer, err := ParseOffer(testOfferHead + "a=rtpmap:300 H265/90000\r\na=rtpmap:101 H265/90000\r\na=rtpmap:999 AV1/90000\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !offer.HasH265 || offer.PayloadType != 101 {
+		t.Errorf("HasH265=%v PT=%d, want the first PT that fits RTP (101)", offer.HasH265, offer.PayloadType)
+	}
+	if _, ok := offer.Fallback[CodecAV1]; ok {
+		t.Errorf("Fallback = %v, want no AV1 with PT 999", offer.Fallback)
+	}
+}
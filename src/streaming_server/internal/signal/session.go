@@ -3,15 +3,33 @@ package signal
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"sync"
 	"time"
 
 	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/sframe"
 	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/srtp"
 )
 
+// ErrClosed is returned by HandleOffer after Close.
+var ErrClosed = errors.New("signal: server closed")
+
+// ErrMaxClients is returned by HandleOffer while every session slot is taken.
+var ErrMaxClients = errors.New("signal: max clients reached")
+
+// sessionState is a session's position in its lifecycle. Transitions only
+// move forward: connecting → connected → closed, or connecting → closed.
+type sessionState int
+
+const (
+	sessionConnecting sessionState = iota // offer accepted, ICE/DTLS running
+	sessionConnected                      // SRTP keys installed, receiving frames
+	sessionClosed                         // socket closed; runSession is exiting
+)
+
 // Session represents a single WebRTC client connection.
 type Session struct {
 	id          string
@@ -19,23 +37,55 @@ type Session struct {
 	remoteAddr  *net.UDPAddr
 	iceLite     *ICELite
 	srtpCtx     *srtp.Context
+	rtcpCtx     *srtp.RTCPContext // decrypts the browser's receiver reports / NACKs
 	ssrc        uint32
 	seq         uint16
-	payloadType uint8 // H.265 PT from SDP negotiation
+	fallback    string
+	payloadType uint8 // PT from SDP negotiation: H.265, or the transcoded fallback codec if set
+	e2e         bool  // receives the SFrame-protected stream (SendE2EFrame)
 	mu          sync.Mutex
-	closed      bool
+	state       sessionState
+	cancel      context.CancelFunc // cancels runSession's context
+	done        chan struct{}      // closed when runSession returns (nil if never started)
 	framesSent  uint64
+	createdAt   time.Time // offer accepted; SRTP must be ready within EstablishTimeout
+	stats       sessionStats
+	rtx         [rtxBufferSize]rtxPacket // recently sent SRTP packets for NACK
 }
 
+// reapInterval is how often sessions stuck before SRTP are swept.
+const reapInterval = 5 * time.Second
+
 // Server manages multiple WebRTC sessions.
 type Server struct {
+	// EstablishTimeout bounds ICE + DTLS: a session without SRTP keys after
+	// this long is reaped so it stops counting against maxClients.
+	EstablishTimeout time.Duration
+	// FallbackCodecs are the codecs a transcoder can send, in order of
+	// preference, to browsers whose offer lacks H.265 (empty = always
+	// answer H.265). Set before serving offers.
+	FallbackCodecs []string
+	// Families are the address families offered as host candidates, most
+	// preferred first (default DefaultFamilies). Sessions bind the wildcard
+	// address of these families. Set before serving offers.
+	Families []string
+	// E2E grants the frame key to the client profile of an offer that
+	// requests end-to-end encryption, or returns a nil grant when the
+	// profile has it turned off. nil refuses such offers. Set before
+	// serving offers.
+	E2E func(profile string, nonce []byte) (*sframe.Grant, error)
+
 	mu         sync.RWMutex
 	sessions   map[string]*Session
 	dtlsConfig *DTLSConfig
 	maxClients int
-	listenIP   net.IP
 	basePort   int // Starting UDP port for allocation
 	nextPort   int
+
+	onReap    func(id string)
+	closing   bool // Close called; new offers are refused
+	reapStop  chan struct{}
+	closeOnce sync.Once
 }
 
 // NewServer creates a new signaling server.
@@ -45,51 +95,157 @@ func NewServer(maxClients int) (*Server, error) {
 		return nil, err
 	}
 
-	// Find local IP
-	ip := getLocalIP()
+	s := &Server{
+		EstablishTimeout: 20 * time.Second,
+		Families:         DefaultFamilies,
+		sessions:         make(map[string]*Session),
+		dtlsConfig:       dtlsConfig,
+		maxClients:       maxClients,
+		basePort:         20000,
+		nextPort:         20000,
+		reapStop:         make(chan struct{}),
+	}
+	go s.reapLoop()
+	return s, nil
+}
 
-	return &Server{
-		sessions:   make(map[string]*Session),
-		dtlsConfig: dtlsConfig,
-		maxClients: maxClients,
-		listenIP:   ip,
-		basePort:   20000,
-		nextPort:   20000,
-	}, nil
+// SetOnReap sets a callback invoked for each session removed because it
+// never finished connecting.
+func (s *Server) SetOnReap(fn func(id string)) {
+	s.mu.Lock()
+	s.onReap = fn
+	s.mu.Unlock()
 }
 
-// HandleOffer processes a WebRTC offer and returns an answer.
-// Compatible with the existing HTTP API (same JSON format as pion version).
-func (s *Server) HandleOffer(offerJSON []byte) ([]byte, error) {
-	// Parse offer
+// decodeOffer parses the offer JSON ({"type": "offer", "sdp": ...}) posted
+// to /offer.
+func decodeOffer(offerJSON []byte) (*Offer, error) {
 	var sdpMsg struct {
 		SDP  string `json:"sdp"`
 		Type string `json:"type"`
+		E2E  *struct {
+			Profile string `json:"profile"`
+			Nonce   string `json:"nonce"` // base64
+		} `json:"e2e"`
 	}
 	if err := json.Unmarshal(offerJSON, &sdpMsg); err != nil {
 		return nil, fmt.Errorf("signal: parse offer json: %w", err)
 	}
-
 	offer, err := ParseOffer(sdpMsg.SDP)
 	if err != nil {
 		return nil, fmt.Errorf("signal: parse sdp: %w", err)
 	}
+	if e := sdpMsg.E2E; e != nil {
+		if e.Profile == "" {
+			return nil, errors.New("signal: e2e request without profile")
+		}
+		if offer.E2ENonce, err = sframe.DecodeNonce(e.Nonce); err != nil {
+			return nil, fmt.Errorf("signal: %w", err)
+		}
+		offer.E2EProfile = e.Profile
+	}
+	return offer, nil
+}
+
+// HandleOffer processes a WebRTC offer and returns an answer.
+// Compatible with the existing HTTP API (same JSON format as pion version).
+func (s *Server) HandleOffer(offerJSON []byte) ([]byte, error) {
+	s.mu.RLock()
+	closing := s.closing
+	s.mu.RUnlock()
+	if closing {
+		return nil, ErrClosed
+	}
+
+	offer, err := decodeOffer(offerJSON)
+	if err != nil {
+		return nil, err
+	}
+	answerSDP, sess, grant, err := s.accept(offer)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return answer in same JSON format as pion, plus the session ID so the
+	// client can find its own entry in Stats, and the frame key grant when
+	// the stream is end-to-end encrypted.
+	answer := map[string]any{
+		"type":       "answer",
+		"sdp":        answerSDP,
+		"session_id": sess.id,
+	}
+	if grant != nil {
+		answer["e2e"] = grant
+	}
+	return json.Marshal(answer)
+}
+
+// HandleSDP answers a bare SDP offer, as WHEP players post it, and returns
+// the answer and the new session's ID. End-to-end encryption needs the JSON
+// offer of HandleOffer.
+func (s *Server) HandleSDP(offerSDP string) (answerSDP, id string, err error) {
+	s.mu.RLock()
+	closing := s.closing
+	s.mu.RUnlock()
+	if closing {
+		return "", "", ErrClosed
+	}
+
+	offer, err := ParseOffer(offerSDP)
+	if err != nil {
+		return "", "", fmt.Errorf("signal: parse sdp: %w", err)
+	}
+	answerSDP, sess, _, err := s.accept(offer)
+	if err != nil {
+		return "", "", err
+	}
+	return answerSDP, sess.id, nil
+}
+
+// accept starts a session for offer and returns the answer SDP.
+func (s *Server) accept(offer *Offer) (string, *Session, *sframe.Grant, error) {
 	logger.Info("Signal", "Offer: PT=%d, MID=%s, ufrag=%s", offer.PayloadType, offer.MID, offer.ICEUfrag)
 
+	pt, fallback := offer.PayloadType, ""
+	if !offer.HasH265 {
+		for _, codec := range s.FallbackCodecs {
+			if fpt, ok := offer.Fallback[codec]; ok {
+				pt, fallback = fpt, codec
+				break
+			}
+		}
+		if fallback != "" {
+			logger.Info("Signal", "Offer without H.265: sending transcoded %s (PT=%d)", fallback, pt)
+		}
+	}
+
+	var grant *sframe.Grant
+	if offer.E2EProfile != "" {
+		if s.E2E == nil {
+			return "", nil, nil, errors.New("signal: end-to-end encryption not enabled")
+		}
+		if fallback != "" {
+			return "", nil, nil, errors.New("signal: end-to-end encryption requires H.265")
+		}
+		var err error
+		if grant, err = s.E2E(offer.E2EProfile, offer.E2ENonce); err != nil {
+			return "", nil, nil, fmt.Errorf("signal: e2e: %w", err)
+		}
+	}
+
 	// Check client limit
 	s.mu.RLock()
 	if len(s.sessions) >= s.maxClients {
 		s.mu.RUnlock()
-		return nil, fmt.Errorf("signal: max clients reached (%d)", s.maxClients)
+		return "", nil, nil, fmt.Errorf("%w (%d)", ErrMaxClients, s.maxClients)
 	}
 	s.mu.RUnlock()
 
 	// Allocate UDP port
 	port := s.allocatePort()
-	udpAddr := &net.UDPAddr{IP: s.listenIP, Port: port}
-	udpConn, err := net.ListenUDP("udp", udpAddr)
+	udpConn, err := net.ListenUDP(udpNetwork(s.Families), &net.UDPAddr{Port: port})
 	if err != nil {
-		return nil, fmt.Errorf("signal: listen udp %d: %w", port, err)
+		return "", nil, nil, fmt.Errorf("signal: listen udp %d: %w", port, err)
 	}
 
 	// Generate ICE credentials
@@ -100,65 +256,82 @@ func (s *Server) HandleOffer(offerJSON []byte) ([]byte, error) {
 		ICEUfrag:        localUfrag,
 		ICEPwd:          localPwd,
 		DTLSFingerprint: s.dtlsConfig.Fingerprint,
-		CandidateIP:     s.listenIP,
+		CandidateIPs:    localCandidates(s.Families),
 		CandidatePort:   port,
-		PayloadType:     offer.PayloadType,
+		PayloadType:     pt,
 		MID:             offer.MID,
+		Codec:           fallback,
 	})
 
 	// Create session
+	ctx, cancel := context.WithCancel(context.Background())
 	sess := &Session{
 		id:          fmt.Sprintf("ws-%d", port),
 		udpConn:     udpConn,
 		iceLite:     NewICELite(localUfrag, localPwd, offer.ICEUfrag, offer.ICEPwd),
 		ssrc:        0x12345678,
-		payloadType: uint8(offer.PayloadType),
+		payloadType: uint8(pt),
+		fallback:    fallback,
+		e2e:         grant != nil,
+		createdAt:   time.Now(),
+		cancel:      cancel,
+		done:        make(chan struct{}),
 	}
 
 	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		cancel()
+		udpConn.Close()
+		return "", nil, nil, ErrClosed
+	}
 	s.sessions[sess.id] = sess
 	s.mu.Unlock()
 
 	// Start ICE → DTLS → SRTP pipeline in background
-	go s.runSession(sess)
+	go s.runSession(ctx, sess)
 
 	logger.Info("Signal", "Session %s: offer accepted, port %d", sess.id, port)
-
-	// Return answer in same JSON format as pion
-	answerJSON, err := json.Marshal(map[string]string{
-		"type": "answer",
-		"sdp":  answerSDP,
-	})
-	if err != nil {
-		return nil, err
+	if grant != nil {
+		logger.Info("Signal", "Session %s: end-to-end encryption for profile %s", sess.id, offer.E2EProfile)
 	}
 
-	return answerJSON, nil
+	return answerSDP, sess, grant, nil
 }
 
-// runSession handles the ICE→DTLS→SRTP lifecycle for a session.
-func (s *Server) runSession(sess *Session) {
+// runSession handles the ICE→DTLS→SRTP lifecycle for a session. It exits
+// when the connection drops or sessCtx is cancelled by close.
+func (s *Server) runSession(sessCtx context.Context, sess *Session) {
+	defer close(sess.done)
 	defer s.removeSession(sess.id)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(sessCtx, s.EstablishTimeout)
 	defer cancel()
 
 	// Phase 1: Wait for STUN binding requests (ICE connectivity check)
 	remoteAddr, err := s.waitForICE(ctx, sess)
 	if err != nil {
 		logger.Warn("Signal", "Session %s: ICE failed: %v", sess.id, err)
+		if ctx.Err() != nil {
+			s.reap(sess.id, "ICE timeout")
+		}
 		return
 	}
+	sess.mu.Lock()
 	sess.remoteAddr = remoteAddr
+	sess.mu.Unlock()
 	logger.Info("Signal", "Session %s: ICE connected from %s", sess.id, remoteAddr)
 
 	// Phase 2: DTLS handshake
 	// Create a packet conn adapter for pion/dtls (filters STUN, passes DTLS)
 	dtlsAdapter := newDTLSPacketConn(sess.udpConn, sess.iceLite, remoteAddr)
 	logger.Info("Signal", "Session %s: starting DTLS handshake...", sess.id)
-	dtlsSess, err := HandshakeDTLS(dtlsAdapter, remoteAddr, s.dtlsConfig)
+	dtlsSess, err := HandshakeDTLS(ctx, dtlsAdapter, remoteAddr, s.dtlsConfig)
 	if err != nil {
 		logger.Warn("Signal", "Session %s: DTLS handshake failed: %v", sess.id, err)
+		if ctx.Err() != nil {
+			s.reap(sess.id, "DTLS timeout")
+		}
 		return
 	}
 	defer dtlsSess.Close()
@@ -177,8 +350,22 @@ func (s *Server) runSession(sess *Session) {
 		return
 	}
 
+	rtcpCtx, err := srtp.RemoteRTCPFromKeyMaterial(keyMaterial, 16, 14, false)
+	if err != nil {
+		logger.Warn("Signal", "Session %s: SRTCP context failed: %v", sess.id, err)
+		return
+	}
+
 	sess.mu.Lock()
+	if sess.state != sessionConnecting {
+		// Closed (reaped or disconnected) while handshaking
+		sess.mu.Unlock()
+		return
+	}
 	sess.srtpCtx = srtpCtx
+	sess.rtcpCtx = rtcpCtx
+	sess.state = sessionConnected
+	sess.stats.connectedAt = time.Now()
 	sess.mu.Unlock()
 
 	logger.Info("Signal", "Session %s: SRTP ready", sess.id)
@@ -199,8 +386,12 @@ func (s *Server) runSession(sess *Session) {
 			if resp != nil {
 				sess.udpConn.WriteToUDP(resp, addr)
 			}
+			continue
+		}
+		// Receiver reports and NACKs feed the session stats
+		if isRTCP(buf[:n]) {
+			sess.handleRTCP(buf[:n])
 		}
-		// Ignore RTCP or other packets
 	}
 }
 
@@ -233,8 +424,25 @@ func (s *Server) waitForICE(ctx context.Context, sess *Session) (*net.UDPAddr, e
 	}
 }
 
-// SendFrame sends SRTP-encrypted RTP packets to all connected sessions.
+// SendFrame sends SRTP-encrypted RTP packets to all connected H.265 sessions
+// without end-to-end encryption.
 func (s *Server) SendFrame(rtpPackets [][]byte) {
+	s.sendFrame("", false, rtpPackets)
+}
+
+// SendE2EFrame sends RTP packets of the SFrame-protected H.265 stream to the
+// sessions granted its key.
+func (s *Server) SendE2EFrame(rtpPackets [][]byte) {
+	s.sendFrame("", true, rtpPackets)
+}
+
+// SendFallbackFrame sends RTP packets of a transcoded stream to the sessions
+// that negotiated codec.
+func (s *Server) SendFallbackFrame(codec string, rtpPackets [][]byte) {
+	s.sendFrame(codec, false, rtpPackets)
+}
+
+func (s *Server) sendFrame(fallback string, e2e bool, rtpPackets [][]byte) {
 	s.mu.RLock()
 	sessions := make([]*Session, 0, len(s.sessions))
 	for _, sess := range s.sessions {
@@ -244,7 +452,7 @@ func (s *Server) SendFrame(rtpPackets [][]byte) {
 
 	for _, sess := range sessions {
 		sess.mu.Lock()
-		if sess.srtpCtx == nil || sess.closed {
+		if sess.state != sessionConnected || sess.fallback != fallback || sess.e2e != e2e {
 			sess.mu.Unlock()
 			continue
 		}
@@ -254,6 +462,9 @@ func (s *Server) SendFrame(rtpPackets [][]byte) {
 		sess.mu.Unlock()
 
 		pt := sess.payloadType
+		sent := make([]rtxPacket, 0, len(rtpPackets))
+		var sentBytes uint64
+		dropped := false
 		for _, pkt := range rtpPackets {
 			if len(pkt) < 12 {
 				continue
@@ -272,14 +483,27 @@ func (s *Server) SendFrame(rtpPackets [][]byte) {
 			encrypted := make([]byte, len(buf)+srtp.AuthTagLen)
 			encrypted, err := srtpCtx.EncryptRTP(encrypted, buf, 12, seq, ssrc)
 			if err != nil {
+				dropped = true
 				continue
 			}
 
-			conn.WriteToUDP(encrypted, remoteAddr)
+			if _, err := conn.WriteToUDP(encrypted, remoteAddr); err != nil {
+				dropped = true
+				continue
+			}
+			sent = append(sent, rtxPacket{seq: seq, data: encrypted})
+			sentBytes += uint64(len(encrypted))
 		}
 
 		sess.mu.Lock()
 		sess.framesSent++
+		if dropped {
+			sess.stats.framesDropped++
+		}
+		sess.stats.addSent(len(sent), sentBytes, time.Now())
+		for _, p := range sent {
+			sess.rtx[p.seq%rtxBufferSize] = p
+		}
 		sess.mu.Unlock()
 	}
 }
@@ -292,7 +516,24 @@ func (s *Server) GetClientCount() int {
 	count := 0
 	for _, sess := range s.sessions {
 		sess.mu.Lock()
-		if sess.srtpCtx != nil && !sess.closed {
+		if sess.state == sessionConnected {
+			count++
+		}
+		sess.mu.Unlock()
+	}
+	return count
+}
+
+// E2EClients returns the number of connected sessions receiving the
+// SFrame-protected stream.
+func (s *Server) E2EClients() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, sess := range s.sessions {
+		sess.mu.Lock()
+		if sess.state == sessionConnected && sess.e2e {
 			count++
 		}
 		sess.mu.Unlock()
@@ -300,35 +541,183 @@ func (s *Server) GetClientCount() int {
 	return count
 }
 
-// Close shuts down all sessions.
+// FallbackClients returns the number of connected sessions receiving the
+// transcoded codec.
+func (s *Server) FallbackClients(codec string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, sess := range s.sessions {
+		sess.mu.Lock()
+		if sess.state == sessionConnected && sess.fallback == codec {
+			count++
+		}
+		sess.mu.Unlock()
+	}
+	return count
+}
+
+// closeTimeout bounds how long Close waits for session goroutines.
+const closeTimeout = 5 * time.Second
+
+// Close refuses new offers, stops the reaper and disconnects every session,
+// waiting up to closeTimeout for their goroutines to exit.
 func (s *Server) Close() error {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.closing = true
+	s.mu.Unlock()
+	s.closeOnce.Do(func() {
+		if s.reapStop != nil {
+			close(s.reapStop)
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	return s.DisconnectAll(ctx)
+}
 
+// DisconnectAll closes every current session and waits until their
+// goroutines have exited or ctx is done, in which case ctx's error is
+// returned. Sessions are unregistered before their sockets close, so a
+// concurrent SendFrame either skips them or fails its write harmlessly.
+func (s *Server) DisconnectAll(ctx context.Context) error {
+	s.mu.Lock()
+	sessions := make([]*Session, 0, len(s.sessions))
 	for id, sess := range s.sessions {
-		sess.mu.Lock()
-		sess.closed = true
-		// srtpCtx is immutable software crypto — no Close needed, GC reclaims.
-		sess.udpConn.Close()
-		sess.mu.Unlock()
+		sessions = append(sessions, sess)
 		delete(s.sessions, id)
 	}
+	s.mu.Unlock()
+
+	for _, sess := range sessions {
+		sess.close()
+	}
+	for _, sess := range sessions {
+		if sess.done == nil {
+			continue
+		}
+		select {
+		case <-sess.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if len(sessions) > 0 {
+		logger.Info("Signal", "Disconnected %d session(s)", len(sessions))
+	}
 	return nil
 }
 
+// CloseSession ends the session id, as a WHEP DELETE does. It returns
+// false if there is no such session.
+func (s *Server) CloseSession(id string) bool {
+	s.mu.RLock()
+	_, ok := s.sessions[id]
+	s.mu.RUnlock()
+	if ok {
+		s.removeSession(id)
+	}
+	return ok
+}
+
+// removeSession unregisters and closes one session. Safe to call more than
+// once and concurrently with SendFrame.
 func (s *Server) removeSession(id string) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	delete(s.sessions, id)
+	s.mu.Unlock()
+
+	if ok && sess.close() {
+		logger.Info("Signal", "Session %s removed (sent: %d frames)", id, sess.frameCount())
+	}
+}
+
+// close moves the session to sessionClosed, cancels its context and closes
+// its socket (unblocking runSession's reads). Returns false if it was
+// already closed. srtpCtx is immutable software crypto, so a SendFrame still
+// holding it keeps working until its writes fail; GC reclaims it.
+func (sess *Session) close() bool {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.state == sessionClosed {
+		return false
+	}
+	sess.state = sessionClosed
+	if sess.cancel != nil {
+		sess.cancel()
+	}
+	sess.udpConn.Close()
+	return true
+}
+
+func (sess *Session) frameCount() uint64 {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return sess.framesSent
+}
+
+// reapLoop periodically sweeps sessions stuck before SRTP. runSession
+// normally gives up on its own at EstablishTimeout; the sweep catches
+// sessions whose goroutine is wedged somewhere the timeout does not reach.
+func (s *Server) reapLoop() {
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.reapStop:
+			return
+		case now := <-ticker.C:
+			s.reapStale(now)
+		}
+	}
+}
+
+// reapStale removes sessions that have not reached SRTP within
+// EstablishTimeout (plus one sweep interval of slack for runSession's own
+// timeout) and returns how many were removed.
+func (s *Server) reapStale(now time.Time) int {
+	deadline := s.EstablishTimeout + reapInterval
 
-	if sess, ok := s.sessions[id]; ok {
+	s.mu.RLock()
+	var stale []string
+	for id, sess := range s.sessions {
 		sess.mu.Lock()
-		sess.closed = true
-		// srtpCtx is immutable software crypto — no Close needed, GC reclaims.
-		sess.udpConn.Close()
+		if sess.state == sessionConnecting && now.Sub(sess.createdAt) > deadline {
+			stale = append(stale, id)
+		}
 		sess.mu.Unlock()
-		delete(s.sessions, id)
-		logger.Info("Signal", "Session %s removed (sent: %d frames)", id, sess.framesSent)
 	}
+	s.mu.RUnlock()
+
+	n := 0
+	for _, id := range stale {
+		if s.reap(id, "stuck connecting") {
+			n++
+		}
+	}
+	return n
+}
+
+// reap removes a session that never finished connecting and reports it.
+// Returns false if the session was already gone.
+func (s *Server) reap(id, reason string) bool {
+	s.mu.Lock()
+	sess, ok := s.sessions[id]
+	delete(s.sessions, id)
+	onReap := s.onReap
+	s.mu.Unlock()
+
+	if !ok || !sess.close() {
+		return false
+	}
+	logger.Warn("Signal", "Session %s reaped: %s after %v", id, reason, time.Since(sess.createdAt).Round(time.Second))
+	if onReap != nil {
+		onReap(id)
+	}
+	return true
 }
 
 func (s *Server) allocatePort() int {
@@ -342,19 +731,6 @@ func (s *Server) allocatePort() int {
 	return port
 }
 
-func getLocalIP() net.IP {
-	// Prefer non-loopback IPv4
-	addrs, _ := net.InterfaceAddrs()
-	for _, addr := range addrs {
-		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() {
-			if ip4 := ipNet.IP.To4(); ip4 != nil {
-				return ip4
-			}
-		}
-	}
-	return net.IPv4(127, 0, 0, 1)
-}
-
 // ----- DTLS packet conn adapter -----
 
 // dtlsPacketConn filters STUN packets out and passes only DTLS to pion/dtls.
@@ -1,10 +1,12 @@
 package signal
 
 import (
+	"context"
 	"net"
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/srtp"
 )
@@ -150,6 +152,7 @@ func newTestSession(t *testing.T, masterKey, masterSalt []byte) (*Server, *Sessi
 		srtpCtx:     srtpCtx,
 		ssrc:        0x12345678,
 		payloadType: 96,
+		state:       sessionConnected,
 	}
 
 	srv := &Server{
@@ -166,3 +169,95 @@ func newTestSession(t *testing.T, masterKey, masterSalt []byte) (*Server, *Sessi
 	}
 	return srv, sess, cleanup
 }
+
+// TestDisconnectAll_RaceWithSendFrame closes handshaking and connected
+// sessions while SendFrame, Stats and GetClientCount run concurrently, and
+// checks that every session goroutine has exited when DisconnectAll returns.
+func TestDisconnectAll_RaceWithSendFrame(t *testing.T) {
+	masterKey := testHex("E1F97A0D3E018BE0D64FA32C06DE4139")
+	masterSalt := testHex("0EC675AD498AFEEBB6960B3AABE6")
+
+	srv, connected, cleanup := newTestSession(t, masterKey, masterSalt)
+	defer cleanup()
+	srv.EstablishTimeout = time.Minute
+	srv.maxClients = 8
+
+	// Sessions still waiting for ICE, with their real goroutines running.
+	var handshaking []*Session
+	for i := 0; i < 4; i++ {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		sess := &Session{
+			id:        conn.LocalAddr().String(),
+			udpConn:   conn,
+			iceLite:   NewICELite("lu", "lp", "ru", "rp"),
+			createdAt: time.Now(),
+			cancel:    cancel,
+			done:      make(chan struct{}),
+		}
+		srv.mu.Lock()
+		srv.sessions[sess.id] = sess
+		srv.mu.Unlock()
+		go srv.runSession(ctx, sess)
+		handshaking = append(handshaking, sess)
+	}
+
+	packets := [][]byte{make([]byte, 12+256)}
+	packets[0][0], packets[0][1] = 0x80, 0x60
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				srv.SendFrame(packets)
+				srv.Stats()
+				srv.GetClientCount()
+			}
+		}()
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.DisconnectAll(ctx); err != nil {
+		t.Fatalf("DisconnectAll: %v", err)
+	}
+	close(stop)
+	wg.Wait()
+
+	for _, sess := range handshaking {
+		select {
+		case <-sess.done:
+		default:
+			t.Errorf("session %s goroutine still running", sess.id)
+		}
+	}
+	if n := srv.GetClientCount(); n != 0 {
+		t.Errorf("GetClientCount = %d after DisconnectAll", n)
+	}
+	if connected.state != sessionClosed {
+		t.Errorf("connected session state = %d, want closed", connected.state)
+	}
+
+	// Close after DisconnectAll is a no-op and refuses new offers.
+	if err := srv.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := srv.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+	if _, err := srv.HandleOffer([]byte(`{"type":"offer","sdp":""}`)); err != ErrClosed {
+		t.Errorf("HandleOffer after Close: err = %v, want ErrClosed", err)
+	}
+}
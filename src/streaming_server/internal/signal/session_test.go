@@ -0,0 +1,115 @@
+package signal
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/sframe"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/srtp"
+)
+
+func TestReapStale(t *testing.T) {
+	newConn := func() *net.UDPConn {
+		c, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { c.Close() })
+		return c
+	}
+
+	srtpCtx, err := srtp.NewContext(testHex("E1F97A0D3E018BE0D64FA32C06DE4139"), testHex("0EC675AD498AFEEBB6960B3AABE6"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	srv := &Server{
+		EstablishTimeout: 10 * time.Second,
+		sessions: map[string]*Session{
+			"stuck":      {id: "stuck", udpConn: newConn(), createdAt: now.Add(-time.Minute)},
+			"connecting": {id: "connecting", udpConn: newConn(), createdAt: now.Add(-time.Second)},
+			"connected":  {id: "connected", udpConn: newConn(), createdAt: now.Add(-time.Minute), srtpCtx: srtpCtx, state: sessionConnected},
+		},
+	}
+	var reaped []string
+	srv.SetOnReap(func(id string) { reaped = append(reaped, id) })
+
+	if n := srv.reapStale(now); n != 1 {
+		t.Fatalf("reapStale = %d, want 1", n)
+	}
+	if len(reaped) != 1 || reaped[0] != "stuck" {
+		t.Errorf("reaped = %v, want [stuck]", reaped)
+	}
+	if _, ok := srv.sessions["stuck"]; ok {
+		t.Error("stuck session still registered")
+	}
+	if len(srv.sessions) != 2 {
+		t.Errorf("sessions = %d, want 2", len(srv.sessions))
+	}
+
+	// Reaping again (e.g. runSession's own timeout firing) is a no-op.
+	if srv.reap("stuck", "ICE timeout") {
+		t.Error("second reap of the same session should report false")
+	}
+}
+
+func TestHandleOffer_E2E(t *testing.T) {
+	srv, err := NewServer(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.Families = []string{FamilyIPv4}
+	offer := func(profile string) string {
+		return `{"type":"offer","sdp":"` + strings.ReplaceAll(testOfferHead, "\r\n", `\r\n`) +
+			`a=rtpmap:96 H265/90000\r\n","e2e":{"profile":"` + profile + `","nonce":"AAECAwQFBgcICQoLDA0ODw=="}}`
+	}
+
+	if _, err := srv.HandleOffer([]byte(offer("phone"))); err == nil {
+		t.Fatal("e2e offer accepted without E2E")
+	}
+
+	grant := &sframe.Grant{KID: 3, Suite: sframe.CipherSuite, WrappedKey: []byte{1, 2, 3}}
+	srv.E2E = func(profile string, nonce []byte) (*sframe.Grant, error) {
+		switch profile {
+		case "phone":
+			return grant, nil
+		case "tv":
+			return nil, nil
+		}
+		return nil, errors.New("unknown profile")
+	}
+	for _, c := range []struct {
+		profile string
+		e2e     bool
+	}{{"phone", true}, {"tv", false}} {
+		answerJSON, err := srv.HandleOffer([]byte(offer(c.profile)))
+		if err != nil {
+			t.Fatalf("%s: %v", c.profile, err)
+		}
+		var answer struct {
+			SessionID string        `json:"session_id"`
+			E2E       *sframe.Grant `json:"e2e"`
+		}
+		if err := json.Unmarshal(answerJSON, &answer); err != nil {
+			t.Fatal(err)
+		}
+		if (answer.E2E != nil) != c.e2e || (c.e2e && answer.E2E.KID != grant.KID) {
+			t.Errorf("%s: answer grant %+v", c.profile, answer.E2E)
+		}
+		srv.mu.RLock()
+		sess := srv.sessions[answer.SessionID]
+		srv.mu.RUnlock()
+		if sess == nil || sess.e2e != c.e2e {
+			t.Errorf("%s: session %+v", c.profile, sess)
+		}
+	}
+	if _, err := srv.HandleOffer([]byte(offer("stranger"))); err == nil {
+		t.Error("unknown profile accepted")
+	}
+}
@@ -0,0 +1,231 @@
+package signal
+
+import (
+	"sort"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+// rtxBufferSize is how many recently sent SRTP packets each session keeps
+// for NACK retransmission (~2 s of video at typical bitrates).
+const rtxBufferSize = 512
+
+// reportStaleAfter is when a session without receiver reports is "unknown".
+const reportStaleAfter = 10 * time.Second
+
+// SessionStats is a point-in-time view of one session's connection quality,
+// built from local send counters and the browser's RTCP receiver reports.
+type SessionStats struct {
+	ID            string  `json:"id"`
+	Remote        string  `json:"remote,omitempty"`
+	Family        string  `json:"address_family,omitempty"` // ipv4 or ipv6, once ICE picked the remote
+	ICE           string  `json:"ice_state"`                // checking until the first binding request, then connected
+	Connected     bool    `json:"connected"`
+	Codec         string  `json:"codec"` // H265, or the transcoded fallback
+	E2E           bool    `json:"e2e"`   // frames are SFrame-protected end to end
+	UptimeSec     float64 `json:"uptime_sec"`
+	FramesSent    uint64  `json:"frames_sent"`
+	FramesDropped uint64  `json:"frames_dropped"`
+	PacketsSent   uint64  `json:"packets_sent"`
+	BytesSent     uint64  `json:"bytes_sent"`
+	BitrateKbps   float64 `json:"bitrate_kbps"`
+	FractionLost  float64 `json:"fraction_lost"` // 0-1, from the latest receiver report
+	PacketsLost   int32   `json:"packets_lost"`  // cumulative, as reported by the browser
+	JitterMs      float64 `json:"jitter_ms"`
+	NACKCount     uint64  `json:"nack_count"`
+	Retransmits   uint64  `json:"retransmits"`
+	PLICount      uint64  `json:"pli_count"`
+	ReportAgeSec  float64 `json:"report_age_sec"` // -1 before the first report
+	Quality       string  `json:"quality"`        // good, fair, poor, unknown
+}
+
+// sessionStats holds the mutable counters behind SessionStats.
+// Guarded by Session.mu.
+type sessionStats struct {
+	connectedAt   time.Time
+	framesDropped uint64
+	packetsSent   uint64
+	bytesSent     uint64
+	nacks         uint64
+	retransmits   uint64
+	plis          uint64
+	report        receptionReport
+	lastReport    time.Time
+
+	// 1-second bitrate window
+	bitrateKbps float64
+	windowStart time.Time
+	windowBytes uint64
+}
+
+// addSent records one frame's packets and refreshes the bitrate window.
+// Must be called with sess.mu held.
+func (st *sessionStats) addSent(packets int, bytes uint64, now time.Time) {
+	st.packetsSent += uint64(packets)
+	st.bytesSent += bytes
+	if st.windowStart.IsZero() {
+		st.windowStart = now
+		st.windowBytes = st.bytesSent
+		return
+	}
+	if elapsed := now.Sub(st.windowStart); elapsed >= time.Second {
+		st.bitrateKbps = float64(st.bytesSent-st.windowBytes) * 8 / 1000 / elapsed.Seconds()
+		st.windowStart = now
+		st.windowBytes = st.bytesSent
+	}
+}
+
+// quality grades a session from its latest receiver report.
+func (st *sessionStats) quality(now time.Time) string {
+	if st.lastReport.IsZero() || now.Sub(st.lastReport) > reportStaleAfter {
+		return "unknown"
+	}
+	loss := float64(st.report.fractionLost) / 256
+	jitterMs := float64(st.report.jitter) / 90
+	switch {
+	case loss >= 0.05 || jitterMs >= 50:
+		return "poor"
+	case loss >= 0.01 || jitterMs >= 20:
+		return "fair"
+	default:
+		return "good"
+	}
+}
+
+// Stats returns a snapshot of every session, ordered by ID.
+func (s *Server) Stats() []SessionStats {
+	s.mu.RLock()
+	sessions := make([]*Session, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.mu.RUnlock()
+
+	now := time.Now()
+	out := make([]SessionStats, 0, len(sessions))
+	for _, sess := range sessions {
+		out = append(out, sess.snapshot(now))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// SessionStats returns a snapshot of the session id, false once it is gone.
+func (s *Server) SessionStats(id string) (SessionStats, bool) {
+	s.mu.RLock()
+	sess, ok := s.sessions[id]
+	s.mu.RUnlock()
+	if !ok {
+		return SessionStats{}, false
+	}
+	return sess.snapshot(time.Now()), true
+}
+
+// snapshot builds the session's SessionStats.
+func (sess *Session) snapshot(now time.Time) SessionStats {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	st := &sess.stats
+	ss := SessionStats{
+		ID:            sess.id,
+		ICE:           "checking",
+		Connected:     sess.state == sessionConnected,
+		Codec:         "H265",
+		E2E:           sess.e2e,
+		FramesSent:    sess.framesSent,
+		FramesDropped: st.framesDropped,
+		PacketsSent:   st.packetsSent,
+		BytesSent:     st.bytesSent,
+		BitrateKbps:   st.bitrateKbps,
+		FractionLost:  float64(st.report.fractionLost) / 256,
+		PacketsLost:   st.report.totalLost,
+		JitterMs:      float64(st.report.jitter) / 90,
+		NACKCount:     st.nacks,
+		Retransmits:   st.retransmits,
+		PLICount:      st.plis,
+		ReportAgeSec:  -1,
+		Quality:       st.quality(now),
+	}
+	if sess.fallback != "" {
+		ss.Codec = sess.fallback
+	}
+	if sess.remoteAddr != nil {
+		ss.Remote = sess.remoteAddr.String()
+		ss.Family = AddressFamily(sess.remoteAddr.IP)
+		ss.ICE = "connected"
+	}
+	if !st.connectedAt.IsZero() {
+		ss.UptimeSec = now.Sub(st.connectedAt).Seconds()
+	}
+	if !st.lastReport.IsZero() {
+		ss.ReportAgeSec = now.Sub(st.lastReport).Seconds()
+	}
+	return ss
+}
+
+// DropFrame records a frame that the pipeline skipped before SendFrame
+// (e.g. sender busy), counting it against every connected session.
+func (s *Server) DropFrame() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, sess := range s.sessions {
+		sess.mu.Lock()
+		if sess.state == sessionConnected {
+			sess.stats.framesDropped++
+		}
+		sess.mu.Unlock()
+	}
+}
+
+// handleRTCP decrypts and applies one SRTCP packet from the browser,
+// retransmitting any NACKed packets still in the buffer.
+func (sess *Session) handleRTCP(pkt []byte) {
+	sess.mu.Lock()
+	rtcpCtx := sess.rtcpCtx
+	sess.mu.Unlock()
+	if rtcpCtx == nil {
+		return
+	}
+
+	plain, err := rtcpCtx.DecryptRTCP(pkt)
+	if err != nil {
+		logger.Debug("Signal", "Session %s: SRTCP decrypt: %v", sess.id, err)
+		return
+	}
+	fb, err := parseRTCP(plain, sess.ssrc)
+	if err != nil {
+		logger.Debug("Signal", "Session %s: RTCP parse: %v", sess.id, err)
+		return
+	}
+
+	var resend [][]byte
+	sess.mu.Lock()
+	if fb.report != nil {
+		sess.stats.report = *fb.report
+		sess.stats.lastReport = time.Now()
+	}
+	sess.stats.nacks += uint64(len(fb.nackSeqs))
+	sess.stats.plis += uint64(fb.plis + fb.firs)
+	for _, seq := range fb.nackSeqs {
+		if p := sess.rtx[seq%rtxBufferSize]; p.data != nil && p.seq == seq {
+			resend = append(resend, p.data)
+		}
+	}
+	sess.stats.retransmits += uint64(len(resend))
+	conn, remoteAddr, closed := sess.udpConn, sess.remoteAddr, sess.state == sessionClosed
+	sess.mu.Unlock()
+
+	if closed {
+		return
+	}
+	for _, p := range resend {
+		conn.WriteToUDP(p, remoteAddr)
+	}
+}
+
+// rtxPacket is one encrypted packet kept for retransmission.
+type rtxPacket struct {
+	seq  uint16
+	data []byte
+}
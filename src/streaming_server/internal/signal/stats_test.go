@@ -0,0 +1,132 @@
+package signal
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/srtp"
+)
+
+func TestParseRTCP_ReportAndFeedback(t *testing.T) {
+	compound := testHex(
+		// RR from 0x11223344: 25/256 lost, cumulative 16, jitter 900 (10 ms)
+		"81C90007" + "11223344" +
+			"12345678" + "19000010" + "0000FFFF" + "00000384" + "00000000" + "00000000" +
+			// Generic NACK: PID 100, BLP 0b101 -> 100, 101, 103
+			"81CD0003" + "11223344" + "12345678" + "00640005" +
+			// PLI
+			"81CE0002" + "11223344" + "12345678" +
+			// PLI for another SSRC is ignored
+			"81CE0002" + "11223344" + "DEADBEEF")
+
+	fb, err := parseRTCP(compound, 0x12345678)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fb.report == nil || fb.report.fractionLost != 25 || fb.report.totalLost != 16 || fb.report.jitter != 900 {
+		t.Fatalf("report = %+v", fb.report)
+	}
+	if len(fb.nackSeqs) != 3 || fb.nackSeqs[0] != 100 || fb.nackSeqs[1] != 101 || fb.nackSeqs[2] != 103 {
+		t.Fatalf("nack seqs = %v", fb.nackSeqs)
+	}
+	if fb.plis != 1 {
+		t.Fatalf("plis = %d", fb.plis)
+	}
+
+	if _, err := parseRTCP(compound[:30], 0x12345678); err == nil {
+		t.Fatal("truncated compound packet accepted")
+	}
+	if !isRTCP(compound) || isRTCP([]byte{0x80, 0x60, 0, 1, 0, 0, 0, 0}) {
+		t.Fatal("isRTCP misclassified RTP/RTCP")
+	}
+}
+
+func TestSession_StatsAndNACKRetransmit(t *testing.T) {
+	masterKey := testHex("E1F97A0D3E018BE0D64FA32C06DE4139")
+	masterSalt := testHex("0EC675AD498AFEEBB6960B3AABE6")
+
+	localConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer localConn.Close()
+	browser, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer browser.Close()
+
+	srtpCtx, _ := srtp.NewContext(masterKey, masterSalt)
+	rtcpCtx, _ := srtp.NewRTCPContext(masterKey, masterSalt)
+	browserRTCP, _ := srtp.NewRTCPContext(masterKey, masterSalt)
+
+	sess := &Session{
+		id:          "ws-test",
+		udpConn:     localConn,
+		remoteAddr:  browser.LocalAddr().(*net.UDPAddr),
+		srtpCtx:     srtpCtx,
+		rtcpCtx:     rtcpCtx,
+		ssrc:        0x12345678,
+		payloadType: 96,
+		state:       sessionConnected,
+	}
+	sess.stats.connectedAt = time.Now()
+	srv := &Server{sessions: map[string]*Session{sess.id: sess}, maxClients: 1}
+
+	packets := make([][]byte, 4)
+	for i := range packets {
+		p := make([]byte, 12+100)
+		p[0], p[1] = 0x80, 0x60
+		p[3] = byte(i)
+		p[8], p[9], p[10], p[11] = 0x12, 0x34, 0x56, 0x78
+		packets[i] = p
+	}
+	srv.SendFrame(packets)
+	srv.DropFrame()
+
+	buf := make([]byte, 1500)
+	browser.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for range packets {
+		if _, _, err := browser.ReadFromUDP(buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// RR (10% loss) + NACK for seq 2 and the never-sent seq 9
+	rtcp := testHex(
+		"81C90007" + "11223344" +
+			"12345678" + "1A000003" + "00000003" + "0000005A" + "00000000" + "00000000" +
+			"81CD0003" + "11223344" + "12345678" + "00020040")
+	enc, err := browserRTCP.EncryptRTCP(rtcp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess.handleRTCP(enc)
+
+	n, _, err := browser.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("no retransmission: %v", err)
+	}
+	if n != 112+srtp.AuthTagLen || buf[3] != 2 {
+		t.Fatalf("retransmitted seq %d len %d", buf[3], n)
+	}
+
+	stats := srv.Stats()
+	if len(stats) != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	st := stats[0]
+	if !st.Connected || st.FramesSent != 1 || st.FramesDropped != 1 || st.PacketsSent != 4 {
+		t.Fatalf("send counters = %+v", st)
+	}
+	if st.NACKCount != 2 || st.Retransmits != 1 || st.PacketsLost != 3 || st.JitterMs != 1 {
+		t.Fatalf("feedback counters = %+v", st)
+	}
+	if st.Quality != "poor" || st.ReportAgeSec < 0 {
+		t.Fatalf("quality = %s (report age %.1f)", st.Quality, st.ReportAgeSec)
+	}
+	if st.Family != FamilyIPv4 {
+		t.Fatalf("address family = %q", st.Family)
+	}
+}
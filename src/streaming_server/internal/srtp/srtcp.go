@@ -0,0 +1,140 @@
+package srtp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
+// srtcpIndexLen is the E-flag + 31-bit SRTCP index trailer (RFC 3711 Section 3.4).
+const srtcpIndexLen = 4
+
+// rtcpHeaderLen is the fixed RTCP header + sender SSRC left unencrypted.
+const rtcpHeaderLen = 8
+
+// RTCPContext performs SRTCP protection for one direction.
+//
+// The browser sends receiver reports and feedback (NACK, PLI) as SRTCP keyed
+// with its own write key, so the server needs a remote-side RTCPContext to
+// read them. RTCP is low-rate (a few packets per second), so this uses plain
+// software crypto without pooling.
+//
+// DecryptRTCP and EncryptRTCP are safe for concurrent use.
+type RTCPContext struct {
+	block   cipher.Block // immutable after construction
+	salt    []byte       // 14-byte session salt (immutable)
+	authKey []byte       // 20-byte session auth key (immutable)
+
+	mu    sync.Mutex
+	index uint32 // next outgoing SRTCP index (guarded by mu)
+}
+
+// NewRTCPContext derives SRTCP session keys (labels 3-5) from master key and salt.
+func NewRTCPContext(masterKey, masterSalt []byte) (*RTCPContext, error) {
+	kdfBlock, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("srtcp: kdf block: %w", err)
+	}
+
+	sessionKey, err := AesCmKeyDerivation(kdfBlock, LabelSRTCPEncryption, masterSalt, len(masterKey))
+	if err != nil {
+		return nil, fmt.Errorf("srtcp: derive session key: %w", err)
+	}
+	sessionSalt, err := AesCmKeyDerivation(kdfBlock, LabelSRTCPSalt, masterSalt, 14)
+	if err != nil {
+		return nil, fmt.Errorf("srtcp: derive session salt: %w", err)
+	}
+	sessionAuthKey, err := AesCmKeyDerivation(kdfBlock, LabelSRTCPAuthTag, masterSalt, 20)
+	if err != nil {
+		return nil, fmt.Errorf("srtcp: derive session auth key: %w", err)
+	}
+
+	block, err := aes.NewCipher(sessionKey)
+	if err != nil {
+		return nil, fmt.Errorf("srtcp: create cipher: %w", err)
+	}
+	return &RTCPContext{block: block, salt: sessionSalt, authKey: sessionAuthKey}, nil
+}
+
+// RemoteRTCPFromKeyMaterial creates an RTCPContext for packets sent by the
+// DTLS peer, i.e. keyed with the client write key when we are the server.
+// keyMaterial has the same layout as for FromKeyMaterial.
+func RemoteRTCPFromKeyMaterial(keyMaterial []byte, keyLen, saltLen int, isClient bool) (*RTCPContext, error) {
+	needed := 2*keyLen + 2*saltLen
+	if len(keyMaterial) < needed {
+		return nil, fmt.Errorf("srtcp: key material too short: %d < %d", len(keyMaterial), needed)
+	}
+
+	clientWriteKey := keyMaterial[:keyLen]
+	serverWriteKey := keyMaterial[keyLen : 2*keyLen]
+	clientWriteSalt := keyMaterial[2*keyLen : 2*keyLen+saltLen]
+	serverWriteSalt := keyMaterial[2*keyLen+saltLen : needed]
+
+	if isClient {
+		return NewRTCPContext(serverWriteKey, serverWriteSalt)
+	}
+	return NewRTCPContext(clientWriteKey, clientWriteSalt)
+}
+
+// DecryptRTCP verifies and decrypts an SRTCP packet, returning the plain
+// compound RTCP packet in a new slice.
+//
+// Layout: [header(8)] [encrypted payload] [E|index(4)] [auth tag(10)].
+func (c *RTCPContext) DecryptRTCP(pkt []byte) ([]byte, error) {
+	if len(pkt) < rtcpHeaderLen+srtcpIndexLen+AuthTagLen {
+		return nil, ErrShortPacket
+	}
+
+	tagStart := len(pkt) - AuthTagLen
+	if !hmac.Equal(c.authTag(pkt[:tagStart]), pkt[tagStart:]) {
+		return nil, ErrAuthTagMismatch
+	}
+
+	indexStart := tagStart - srtcpIndexLen
+	trailer := binary.BigEndian.Uint32(pkt[indexStart:tagStart])
+	out := make([]byte, indexStart)
+	copy(out, pkt[:indexStart])
+
+	if trailer&0x80000000 != 0 {
+		index := trailer & 0x7FFFFFFF
+		ssrc := binary.BigEndian.Uint32(pkt[4:8])
+		counter := GenerateCounter(uint16(index), index>>16, ssrc, c.salt)
+		xorBytesCTR(c.block, counter[:], out[rtcpHeaderLen:], pkt[rtcpHeaderLen:indexStart])
+	}
+	return out, nil
+}
+
+// EncryptRTCP encrypts a compound RTCP packet and appends the E|index
+// trailer and authentication tag.
+func (c *RTCPContext) EncryptRTCP(pkt []byte) ([]byte, error) {
+	if len(pkt) < rtcpHeaderLen {
+		return nil, ErrShortPacket
+	}
+
+	c.mu.Lock()
+	index := c.index
+	c.index = (c.index + 1) & 0x7FFFFFFF
+	c.mu.Unlock()
+
+	out := make([]byte, len(pkt)+srtcpIndexLen+AuthTagLen)
+	copy(out, pkt[:rtcpHeaderLen])
+	ssrc := binary.BigEndian.Uint32(pkt[4:8])
+	counter := GenerateCounter(uint16(index), index>>16, ssrc, c.salt)
+	xorBytesCTR(c.block, counter[:], out[rtcpHeaderLen:len(pkt)], pkt[rtcpHeaderLen:])
+
+	binary.BigEndian.PutUint32(out[len(pkt):], index|0x80000000)
+	tagStart := len(pkt) + srtcpIndexLen
+	copy(out[tagStart:], c.authTag(out[:tagStart]))
+	return out, nil
+}
+
+// authTag computes the truncated HMAC-SHA1 tag over the authenticated portion.
+func (c *RTCPContext) authTag(authenticated []byte) []byte {
+	mac := hmac.New(sha1.New, c.authKey)
+	mac.Write(authenticated)
+	return mac.Sum(nil)[:AuthTagLen]
+}
@@ -211,6 +211,66 @@ func TestAFALGBatchECB(t *testing.T) {
 	t.Logf("AF_ALG batch ECB: OK (available: %v)", afalgAvailable())
 }
 
+// TestRTCPContext_RoundTrip verifies SRTCP encrypt/decrypt with the remote
+// key selection used for browser receiver reports.
+func TestRTCPContext_RoundTrip(t *testing.T) {
+	clientKey := mustHex("E1F97A0D3E018BE0D64FA32C06DE4139")
+	serverKey := mustHex("0DCD213E4CBCF28F017F6994401E2889")
+	clientSalt := mustHex("0EC675AD498AFEEBB6960B3AABE6")
+	serverSalt := mustHex("62776038C06DC9419F6DD9433E7C")
+	keyMaterial := append(append(append(append([]byte{}, clientKey...), serverKey...), clientSalt...), serverSalt...)
+
+	// Browser (DTLS client) encrypts with its write key; server decrypts as remote.
+	browser, err := NewRTCPContext(clientKey, clientSalt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote, err := RemoteRTCPFromKeyMaterial(keyMaterial, 16, 14, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// RR with one report block: SSRC 0x11223344 reporting on 0x12345678.
+	rr := mustHex(
+		"81C90007" + "11223344" +
+			"12345678" + "0A000010" + "0000FFFF" + "00000020" + "00000000" + "00000000")
+
+	for i := 0; i < 3; i++ {
+		enc, err := browser.EncryptRTCP(rr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(enc) != len(rr)+4+AuthTagLen {
+			t.Fatalf("encrypted length = %d", len(enc))
+		}
+		if bytes.Equal(enc[8:len(rr)], rr[8:]) {
+			t.Fatal("payload was not encrypted")
+		}
+		dec, err := remote.DecryptRTCP(enc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(dec, rr) {
+			t.Fatalf("decrypted:\n  got  %x\n  want %x", dec, rr)
+		}
+
+		enc[10] ^= 0x01
+		if _, err := remote.DecryptRTCP(enc); err != ErrAuthTagMismatch {
+			t.Fatalf("tampered packet err = %v", err)
+		}
+	}
+
+	// The local (server write) key must not verify browser packets.
+	local, _ := RemoteRTCPFromKeyMaterial(keyMaterial, 16, 14, true)
+	enc, _ := browser.EncryptRTCP(rr)
+	if _, err := local.DecryptRTCP(enc); err != ErrAuthTagMismatch {
+		t.Fatalf("wrong-direction key err = %v", err)
+	}
+	if _, err := remote.DecryptRTCP(enc[:12]); err != ErrShortPacket {
+		t.Fatalf("short packet err = %v", err)
+	}
+}
+
 // BenchmarkEncryptRTP benchmarks SRTP encryption for a typical H.265 frame.
 func BenchmarkEncryptRTP(b *testing.B) {
 	masterKey := mustHex("E1F97A0D3E018BE0D64FA32C06DE4139")
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Local stores files in a directory. Network shares work as long as they
+// are mounted (NFS, SMB/CIFS).
+type Local struct {
+	dir string
+}
+
+// NewLocal returns a backend rooted at dir. The directory is created on
+// first write.
+func NewLocal(dir string) *Local {
+	return &Local{dir: filepath.Clean(dir)}
+}
+
+// Dir returns the root directory.
+func (l *Local) Dir() string {
+	return l.dir
+}
+
+// Path returns the filesystem path for name.
+func (l *Local) Path(name string) (string, error) {
+	if err := validName(name); err != nil {
+		return "", err
+	}
+	return filepath.Join(l.dir, name), nil
+}
+
+// Put writes to a temp file and renames it into place so readers never see
+// a partial clip.
+func (l *Local) Put(name string, r io.Reader) error {
+	path, err := l.Path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(l.dir, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(l.dir, "."+name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Open opens name for reading. The returned *os.File also implements
+// io.ReadSeeker.
+func (l *Local) Open(name string) (io.ReadCloser, error) {
+	path, err := l.Path(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Stat returns metadata for name.
+func (l *Local) Stat(name string) (FileInfo, error) {
+	path, err := l.Path(name)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return FileInfo{Name: name, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// List returns regular files in the directory, skipping in-flight temp files.
+func (l *Local) List() ([]FileInfo, error) {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []FileInfo{}, nil
+		}
+		return nil, err
+	}
+
+	files := make([]FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Name()[0] == '.' {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, FileInfo{Name: entry.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	return files, nil
+}
+
+// Remove deletes name.
+func (l *Local) Remove(name string) error {
+	path, err := l.Path(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func (l *Local) String() string {
+	return "file://" + l.dir
+}
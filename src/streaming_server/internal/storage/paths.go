@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Paths is an ordered list of local directories used as one namespace, for
+// recordings that fail over from a removable drive to internal storage: Put
+// writes to the active directory, the other calls look in every directory,
+// earlier ones first. A directory that is gone (unmounted drive) simply
+// holds nothing.
+type Paths struct {
+	dirs []*Local
+
+	mu     sync.RWMutex
+	active int
+}
+
+// NewPaths creates the namespace over dirs, the first one active.
+func NewPaths(dirs ...string) *Paths {
+	p := &Paths{}
+	for _, dir := range dirs {
+		p.dirs = append(p.dirs, NewLocal(dir))
+	}
+	return p
+}
+
+// Dirs returns the directories in order.
+func (p *Paths) Dirs() []string {
+	out := make([]string, len(p.dirs))
+	for i, l := range p.dirs {
+		out[i] = l.Dir()
+	}
+	return out
+}
+
+// SetActive makes dir the one Put writes to. It must be one of Dirs.
+func (p *Paths) SetActive(dir string) error {
+	for i, l := range p.dirs {
+		if l.Dir() == dir {
+			p.mu.Lock()
+			p.active = i
+			p.mu.Unlock()
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not a recording path", dir)
+}
+
+// Active returns the directory Put writes to.
+func (p *Paths) Active() *Local {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.dirs[p.active]
+}
+
+// Locate returns the directory holding name.
+func (p *Paths) Locate(name string) (*Local, error) {
+	if err := validName(name); err != nil {
+		return nil, err
+	}
+	for _, l := range p.dirs {
+		if _, err := l.Stat(name); err == nil {
+			return l, nil
+		}
+	}
+	return nil, ErrNotExist
+}
+
+// Put stores name in the active directory.
+func (p *Paths) Put(name string, r io.Reader) error {
+	return p.Active().Put(name, r)
+}
+
+// Open opens name from the first directory holding it.
+func (p *Paths) Open(name string) (io.ReadCloser, error) {
+	l, err := p.Locate(name)
+	if err != nil {
+		return nil, err
+	}
+	return l.Open(name)
+}
+
+// Stat returns metadata for name from the first directory holding it.
+func (p *Paths) Stat(name string) (FileInfo, error) {
+	l, err := p.Locate(name)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return l.Stat(name)
+}
+
+// List returns the files of every directory; a name in several is listed
+// once, from the first. Unreadable directories are skipped unless all are.
+func (p *Paths) List() ([]FileInfo, error) {
+	seen := map[string]bool{}
+	files := []FileInfo{}
+	var errs []error
+	for _, l := range p.dirs {
+		list, err := l.List()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, f := range list {
+			if !seen[f.Name] {
+				seen[f.Name] = true
+				files = append(files, f)
+			}
+		}
+	}
+	if len(errs) == len(p.dirs) {
+		return nil, errors.Join(errs...)
+	}
+	return files, nil
+}
+
+// Remove deletes name from every directory holding it.
+func (p *Paths) Remove(name string) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+	removed := false
+	for _, l := range p.dirs {
+		err := l.Remove(name)
+		switch {
+		case err == nil:
+			removed = true
+		case !errors.Is(err, ErrNotExist):
+			return err
+		}
+	}
+	if !removed {
+		return ErrNotExist
+	}
+	return nil
+}
+
+func (p *Paths) String() string {
+	return "paths(" + strings.Join(p.Dirs(), ",") + ")"
+}
@@ -0,0 +1,487 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// S3 requires every multipart part except the last to be >= 5 MiB.
+	minPartSize     = 5 << 20
+	defaultPartSize = 8 << 20
+
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// S3 stores objects in an S3-compatible bucket (AWS, MinIO, R2, ...) using
+// path-style requests signed with SigV4. Uploads are streamed in parts so a
+// clip never has to fit in memory.
+type S3 struct {
+	Endpoint  string // scheme://host[:port], no trailing slash
+	Region    string
+	Bucket    string
+	Prefix    string // key prefix, no leading slash, "" or ends with "/"
+	AccessKey string
+	SecretKey string
+	Token     string // optional session token
+	PartSize  int
+
+	HTTPClient *http.Client
+	now        func() time.Time
+}
+
+// NewS3FromURL parses s3://bucket/prefix?region=..&endpoint=.. .
+// Credentials come from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN; region and endpoint fall back to AWS_REGION and
+// AWS_ENDPOINT_URL.
+func NewS3FromURL(u *url.URL) (*S3, error) {
+	if u.Host == "" {
+		return nil, errors.New("s3 storage URL requires a bucket")
+	}
+	q := u.Query()
+	s := &S3{
+		Bucket:    u.Host,
+		Prefix:    strings.Trim(u.Path, "/"),
+		Region:    firstNonEmpty(q.Get("region"), os.Getenv("AWS_REGION"), "us-east-1"),
+		Endpoint:  firstNonEmpty(q.Get("endpoint"), os.Getenv("AWS_ENDPOINT_URL")),
+		AccessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		Token:     os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if s.Prefix != "" {
+		s.Prefix += "/"
+	}
+	if s.Endpoint == "" {
+		s.Endpoint = "https://s3." + s.Region + ".amazonaws.com"
+	}
+	s.Endpoint = strings.TrimSuffix(s.Endpoint, "/")
+	if s.AccessKey == "" || s.SecretKey == "" {
+		return nil, errors.New("s3 storage requires AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	return s, nil
+}
+
+func (s *S3) String() string {
+	return "s3://" + s.Bucket + "/" + s.Prefix
+}
+
+func (s *S3) objectURL(name string) string {
+	return s.Endpoint + "/" + s.Bucket + "/" + s.Prefix + name
+}
+
+// Put streams r to the bucket. Small objects use a single PUT; larger ones
+// use a multipart upload that is aborted on error.
+func (s *S3) Put(name string, r io.Reader) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+	partSize := max(s.PartSize, minPartSize)
+	if s.PartSize == 0 {
+		partSize = defaultPartSize
+	}
+
+	buf := make([]byte, partSize)
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return s.putObject(name, buf[:n])
+	}
+	if err != nil {
+		return err
+	}
+
+	uploadID, err := s.createMultipart(name)
+	if err != nil {
+		return err
+	}
+	var etags []string
+	for {
+		etag, err := s.uploadPart(name, uploadID, len(etags)+1, buf[:n])
+		if err != nil {
+			s.abortMultipart(name, uploadID)
+			return err
+		}
+		etags = append(etags, etag)
+
+		n, err = io.ReadFull(r, buf)
+		if err == io.EOF {
+			break
+		}
+		if err == io.ErrUnexpectedEOF {
+			etag, err := s.uploadPart(name, uploadID, len(etags)+1, buf[:n])
+			if err != nil {
+				s.abortMultipart(name, uploadID)
+				return err
+			}
+			etags = append(etags, etag)
+			break
+		}
+		if err != nil {
+			s.abortMultipart(name, uploadID)
+			return err
+		}
+	}
+	if err := s.completeMultipart(name, uploadID, etags); err != nil {
+		s.abortMultipart(name, uploadID)
+		return err
+	}
+	return nil
+}
+
+func (s *S3) putObject(name string, data []byte) error {
+	resp, err := s.do(http.MethodPut, s.objectURL(name), nil, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) createMultipart(name string) (string, error) {
+	resp, err := s.do(http.MethodPost, s.objectURL(name), url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("s3: decode CreateMultipartUpload: %w", err)
+	}
+	if result.UploadID == "" {
+		return "", errors.New("s3: empty UploadId")
+	}
+	return result.UploadID, nil
+}
+
+func (s *S3) uploadPart(name, uploadID string, part int, data []byte) (string, error) {
+	q := url.Values{"partNumber": {strconv.Itoa(part)}, "uploadId": {uploadID}}
+	resp, err := s.do(http.MethodPut, s.objectURL(name), q, data)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Header.Get("ETag"), nil
+}
+
+func (s *S3) completeMultipart(name, uploadID string, etags []string) error {
+	type part struct {
+		PartNumber int
+		ETag       string
+	}
+	body := struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []part   `xml:"Part"`
+	}{}
+	for i, etag := range etags {
+		body.Parts = append(body.Parts, part{PartNumber: i + 1, ETag: etag})
+	}
+	data, err := xml.Marshal(body)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(http.MethodPost, s.objectURL(name), url.Values{"uploadId": {uploadID}}, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// S3 may report a failure inside a 200 response.
+	respBody, _ := io.ReadAll(resp.Body)
+	if bytes.Contains(respBody, []byte("<Error>")) {
+		return fmt.Errorf("s3: CompleteMultipartUpload: %s", respBody)
+	}
+	return nil
+}
+
+func (s *S3) abortMultipart(name, uploadID string) {
+	resp, err := s.do(http.MethodDelete, s.objectURL(name), url.Values{"uploadId": {uploadID}}, nil)
+	if err == nil {
+		resp.Body.Close()
+	}
+}
+
+// Open starts a GET for name and returns the response body.
+func (s *S3) Open(name string) (io.ReadCloser, error) {
+	if err := validName(name); err != nil {
+		return nil, err
+	}
+	resp, err := s.do(http.MethodGet, s.objectURL(name), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Stat issues a HEAD for name.
+func (s *S3) Stat(name string) (FileInfo, error) {
+	if err := validName(name); err != nil {
+		return FileInfo{}, err
+	}
+	resp, err := s.do(http.MethodHead, s.objectURL(name), nil, nil)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	resp.Body.Close()
+
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return FileInfo{Name: name, Size: resp.ContentLength, ModTime: modTime}, nil
+}
+
+// List pages through ListObjectsV2 under the prefix. Objects in nested
+// "directories" are skipped.
+func (s *S3) List() ([]FileInfo, error) {
+	files := []FileInfo{}
+	token := ""
+	for {
+		q := url.Values{"list-type": {"2"}}
+		if s.Prefix != "" {
+			q.Set("prefix", s.Prefix)
+		}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		resp, err := s.do(http.MethodGet, s.Endpoint+"/"+s.Bucket, q, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key          string
+				Size         int64
+				LastModified time.Time
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("s3: decode ListObjectsV2: %w", err)
+		}
+
+		for _, obj := range result.Contents {
+			name := strings.TrimPrefix(obj.Key, s.Prefix)
+			if validName(name) != nil {
+				continue
+			}
+			files = append(files, FileInfo{Name: name, Size: obj.Size, ModTime: obj.LastModified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return files, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// Remove deletes name. S3 DELETE succeeds for missing keys, so existence is
+// checked first to match the local backend.
+func (s *S3) Remove(name string) error {
+	if _, err := s.Stat(name); err != nil {
+		return err
+	}
+	resp, err := s.do(http.MethodDelete, s.objectURL(name), nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do sends a signed request. Non-2xx responses are returned as errors
+// (404 maps to ErrNotExist).
+func (s *S3) do(method, rawURL string, query url.Values, body []byte) (*http.Response, error) {
+	if len(query) > 0 {
+		rawURL += "?" + canonicalQuery(query)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if body == nil {
+		req.Body = http.NoBody
+		req.ContentLength = 0
+	}
+
+	payloadHash := emptyPayloadHash
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.Token != "" {
+		req.Header.Set("X-Amz-Security-Token", s.Token)
+	}
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	s.sign(req, payloadHash, now())
+
+	client := s.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, ErrNotExist
+		}
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("s3: %s %s: %s: %s", method, req.URL.Path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// sign adds a SigV4 Authorization header covering Host and every header
+// already set on req.
+func (s *S3) sign(req *http.Request, payloadHash string, t time.Time) {
+	t = t.UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL.EscapedPath()),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	crHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(crHash[:])
+
+	key := signingKey(s.SecretKey, date, s.Region, "s3")
+	signature := hex.EncodeToString(hmacSHA256(key, []byte(stringToSign)))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signedHeaders, signature))
+}
+
+func signingKey(secret, date, region, service string) []byte {
+	k := hmacSHA256([]byte("AWS4"+secret), []byte(date))
+	k = hmacSHA256(k, []byte(region))
+	k = hmacSHA256(k, []byte(service))
+	return hmacSHA256(k, []byte("aws4_request"))
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// canonicalURI re-escapes an already-escaped path with SigV4 rules
+// (everything but unreserved characters and '/').
+func canonicalURI(escapedPath string) string {
+	if escapedPath == "" {
+		return "/"
+	}
+	path, err := url.PathUnescape(escapedPath)
+	if err != nil {
+		path = escapedPath
+	}
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		segments[i] = uriEncode(seg)
+	}
+	return strings.Join(segments, "/")
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vals := append([]string(nil), q[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
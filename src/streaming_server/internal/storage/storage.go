@@ -0,0 +1,76 @@
+// Package storage abstracts where finished recordings live so clips can be
+// kept on the SD card, a mounted network share (NFS/SMB), or streamed
+// straight to an S3-compatible bucket.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrNotExist is returned when the named object does not exist.
+var ErrNotExist = fs.ErrNotExist
+
+// FileInfo describes a stored object.
+type FileInfo struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// Storage is a flat namespace of recording files.
+//
+// Names are plain file names (no directories); implementations reject
+// anything else.
+type Storage interface {
+	// Put stores the contents of r under name, replacing any existing
+	// object. The object is only visible once Put returns nil.
+	Put(name string, r io.Reader) error
+	// Open returns a reader for name.
+	Open(name string) (io.ReadCloser, error)
+	// Stat returns metadata for name.
+	Stat(name string) (FileInfo, error)
+	// List returns every stored object.
+	List() ([]FileInfo, error)
+	// Remove deletes name. Removing a missing object returns ErrNotExist.
+	Remove(name string) error
+	// String describes the backend for logs.
+	String() string
+}
+
+// New creates a backend from spec:
+//
+//	""                   local directory defaultDir
+//	/mnt/nas/recordings  local (or NFS/SMB-mounted) directory
+//	file:///mnt/nas/rec  same as above
+//	s3://bucket/prefix   S3-compatible bucket (see NewS3FromURL)
+func New(spec, defaultDir string) (Storage, error) {
+	switch {
+	case spec == "":
+		return NewLocal(defaultDir), nil
+	case strings.HasPrefix(spec, "s3://"):
+		u, err := url.Parse(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid storage URL: %w", err)
+		}
+		return NewS3FromURL(u)
+	case strings.HasPrefix(spec, "file://"):
+		return NewLocal(strings.TrimPrefix(spec, "file://")), nil
+	case strings.Contains(spec, "://"):
+		return nil, fmt.Errorf("unsupported storage scheme: %s", spec)
+	default:
+		return NewLocal(spec), nil
+	}
+}
+
+func validName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return errors.New("invalid filename")
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package streamserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAttachesWhenCameraAppears(t *testing.T) {
+	// The server comes up before the camera daemon has created the segment
+	s, httpURL := startServer(t)
+	readyz := func() (int, map[string]any) {
+		code, body := getBody(t, httpURL+"/readyz")
+		var st map[string]any
+		if err := json.Unmarshal(body, &st); err != nil {
+			t.Fatalf("/readyz: %d %s", code, body)
+		}
+		return code, st
+	}
+
+	code, st := readyz()
+	if code != http.StatusServiceUnavailable || st["ready"] != false || st["camera"] != "waiting" || st["reason"] != "waiting for camera" {
+		t.Fatalf("before the camera: %d %v", code, st)
+	}
+	if code, _ := getBody(t, httpURL+"/health"); code != http.StatusOK {
+		t.Errorf("/health while waiting: %d", code)
+	}
+
+	newFakeCamera(t, false) // no frames are read without viewers
+	// Retried with backoff from attachMinBackoff: up to 0.5+1+2s after creation
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		code, st = readyz()
+		if code == http.StatusOK {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("not attached: %d %v", code, st)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if st["ready"] != true || st["camera"] != "attached" || st["reason"] != nil || !s.cameraAttached.Load() {
+		t.Errorf("after the camera: %v", st)
+	}
+}
@@ -0,0 +1,123 @@
+package streamserver
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// drainState is the server's drain mode. Once draining, new offers and
+// recordings are refused, viewers are told (through /api/webrtc/stats,
+// which the web monitor relays on its connections SSE stream) when to
+// reconnect, and Run exits after the grace period.
+//
+// The self-contained WebRTC stack has no data channel, so the notice cannot
+// be pushed over the peer connection itself.
+type drainState struct {
+	mu             sync.Mutex
+	active         bool
+	reconnectAfter time.Duration
+	done           chan struct{} // closed when the grace period is over; Run exits
+}
+
+func newDrainState() *drainState {
+	return &drainState{done: make(chan struct{})}
+}
+
+// begin enters drain mode. It returns false if already draining.
+func (d *drainState) begin(reconnectAfter, grace time.Duration) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.active {
+		return false
+	}
+	d.active = true
+	d.reconnectAfter = reconnectAfter
+	time.AfterFunc(grace, func() { close(d.done) })
+	return true
+}
+
+// status reports whether the server is draining and the reconnect delay.
+func (d *drainState) status() (bool, time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.active, d.reconnectAfter
+}
+
+// annotate adds drain_reconnect_after_sec to a client count/stats body
+// while draining.
+func (d *drainState) annotate(body map[string]interface{}) {
+	if active, after := d.status(); active {
+		body["drain_reconnect_after_sec"] = after.Seconds()
+	}
+}
+
+// retryAfter is the Retry-After value (whole seconds, at least 1) for
+// requests refused while draining.
+func (d *drainState) retryAfter() string {
+	_, after := d.status()
+	return strconv.Itoa(max(int((after+time.Second-1)/time.Second), 1))
+}
+
+// handleDrain serves POST /admin/drain[?reconnect_after=10s][&grace=3s]:
+// stop accepting offers, finalize the recording in progress, tell viewers
+// to reconnect after reconnect_after, and exit once grace has passed.
+func (s *Server) handleDrain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	reconnectAfter, grace := s.cfg.DrainReconnect, s.cfg.DrainGrace
+	for name, dst := range map[string]*time.Duration{"reconnect_after": &reconnectAfter, "grace": &grace} {
+		v := r.URL.Query().Get(name)
+		if v == "" {
+			continue
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			http.Error(w, name+" must be a non-negative duration (e.g. 10s)", http.StatusBadRequest)
+			return
+		}
+		*dst = d
+	}
+
+	if !s.drain.begin(reconnectAfter, grace) {
+		http.Error(w, "Already draining", http.StatusConflict)
+		return
+	}
+	clients := s.signal.GetClientCount()
+	log.Printf("[Drain] Draining: %d WebRTC client(s) told to reconnect after %v, exiting in %v", clients, reconnectAfter, grace)
+
+	finalized := false
+	if s.recorder.IsRecording() {
+		if err := s.recorder.Stop(); err != nil {
+			log.Printf("[Drain] Failed to finalize recording: %v", err)
+		} else {
+			finalized = true
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"draining":            true,
+		"clients":             clients,
+		"reconnect_after_sec": reconnectAfter.Seconds(),
+		"exit_in_sec":         grace.Seconds(),
+		"recording_finalized": finalized,
+	})
+}
+
+// refuseWhileDraining answers 503 with Retry-After and returns true when
+// the server is draining.
+func (s *Server) refuseWhileDraining(w http.ResponseWriter) bool {
+	if active, _ := s.drain.status(); !active {
+		return false
+	}
+	w.Header().Set("Retry-After", s.drain.retryAfter())
+	http.Error(w, "Server is draining", http.StatusServiceUnavailable)
+	return true
+}
@@ -0,0 +1,60 @@
+package streamserver
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/recorder"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/signal"
+)
+
+func TestDrain(t *testing.T) {
+	sig, err := signal.NewServer(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sig.Close()
+	s := &Server{cfg: DefaultConfig(), signal: sig, recorder: recorder.NewRecorder(t.TempDir()), drain: newDrainState()}
+
+	w := httptest.NewRecorder()
+	s.handleDrain(w, httptest.NewRequest("POST", "/admin/drain?grace=soon", nil))
+	if w.Code != 400 {
+		t.Fatalf("bad grace: status %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleDrain(w, httptest.NewRequest("POST", "/admin/drain?reconnect_after=1500ms&grace=10ms", nil))
+	if w.Code != 202 {
+		t.Fatalf("drain: status %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.handleDrain(w, httptest.NewRequest("POST", "/admin/drain", nil))
+	if w.Code != 409 {
+		t.Fatalf("second drain: status %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleOffer(w, httptest.NewRequest("POST", "/offer", strings.NewReader("{}")))
+	if w.Code != 503 || w.Header().Get("Retry-After") != "2" {
+		t.Fatalf("offer while draining: status %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	w = httptest.NewRecorder()
+	s.handleClientCount(w, httptest.NewRequest("GET", "/api/clients/count", nil))
+	var body map[string]float64
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["drain_reconnect_after_sec"] != 1.5 {
+		t.Fatalf("client count body %v", body)
+	}
+
+	select {
+	case <-s.drain.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("drain did not complete after the grace period")
+	}
+}
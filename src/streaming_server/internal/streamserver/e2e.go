@@ -0,0 +1,45 @@
+package streamserver
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/sframe"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/signal"
+)
+
+// newE2E loads the client profiles at path and makes signalSrv grant them
+// the key of a fresh sender. The key lives only in memory: after a restart
+// clients get the new one with their next offer.
+func newE2E(path string, signalSrv *signal.Server) (*sframe.Sender, error) {
+	profiles, err := sframe.LoadProfiles(path)
+	if err != nil {
+		return nil, err
+	}
+	var seed [sframe.KeySize + 2]byte
+	if _, err := rand.Read(seed[:]); err != nil {
+		return nil, err
+	}
+	baseKey := seed[:sframe.KeySize]
+	// A KID per run tells a client holding the previous run's key apart
+	kid := uint64(binary.BigEndian.Uint16(seed[sframe.KeySize:]))
+	sender, err := sframe.NewSender(kid, baseKey)
+	if err != nil {
+		return nil, err
+	}
+
+	signalSrv.E2E = func(profile string, nonce []byte) (*sframe.Grant, error) {
+		p, ok := profiles[profile]
+		if !ok {
+			return nil, fmt.Errorf("unknown client profile %q", profile)
+		}
+		if !p.E2E {
+			return nil, nil
+		}
+		return sframe.WrapKey(p.Secret, profile, nonce, kid, baseKey)
+	}
+	logger.Info("Signal", "End-to-end frame encryption for %d client profile(s), KID %d", len(profiles), kid)
+	return sender, nil
+}
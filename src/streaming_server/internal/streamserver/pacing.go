@@ -0,0 +1,141 @@
+package streamserver
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// maxPacing bounds Pipeline.WebRTCPacing; each frame of jitter buffer
+	// is a frame interval of added latency.
+	maxPacing = 4
+	// pacingGap is the capture-time gap (camera switch, encoder pause) after
+	// which the schedule starts over instead of waiting it out.
+	pacingGap = time.Second
+	// defaultPacingInterval is assumed until two frames were seen.
+	defaultPacingInterval = time.Second / 30
+)
+
+// pacer releases frames to the RTP packetizer at the cadence of their
+// capture timestamps. The reader polls the SHM, so frames reach the WebRTC
+// queue in bursts (two back to back after a missed tick), and sending them
+// as fast as the queue drains shows as judder in some browsers. The pacer
+// schedules each frame at a fixed offset from its capture time: the first
+// frame is held for the jitter buffer (frames x the capture interval), and
+// every later one as long as it takes to keep the capture spacing. Frames
+// late by more than the buffer, or early by more than it (the capture
+// clock drifted ahead), move the schedule, so the added latency stays
+// within about twice the buffer.
+//
+// A pacer is owned by the Stage 2 sender goroutine; only the counters are
+// read elsewhere.
+type pacer struct {
+	frames int
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) bool
+
+	interval time.Duration // smoothed capture interval
+	lastTS   time.Time     // capture time of the previous frame
+	anchorTS time.Time     // capture time of the frame the schedule starts from
+	anchorAt time.Time     // its release time
+
+	paced   atomic.Uint64 // frames held back
+	heldNs  atomic.Int64  // total time frames were held
+	late    atomic.Uint64 // frames due before they arrived
+	resyncs atomic.Uint64 // schedule restarts (gaps, drift, underruns)
+	lastInt atomic.Int64  // interval, for Stats
+}
+
+// newPacer returns a pacer with a jitter buffer of frames (1-2 is enough
+// for the reader's bursts).
+func newPacer(frames int) *pacer {
+	p := &pacer{
+		frames:   frames,
+		now:      time.Now,
+		sleep:    sleepCtx,
+		interval: defaultPacingInterval,
+	}
+	p.lastInt.Store(int64(defaultPacingInterval))
+	return p
+}
+
+// sleepCtx sleeps for d, returning false if ctx ends first.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// wait blocks until the release time of the frame captured at ts. It
+// returns false if ctx ended while waiting; the frame should still be sent
+// or released.
+func (p *pacer) wait(ctx context.Context, ts time.Time) bool {
+	now := p.now()
+	gap := ts.Sub(p.lastTS)
+	if !p.lastTS.IsZero() && gap > 0 && gap < pacingGap {
+		p.interval += (gap - p.interval) / 8
+		p.lastInt.Store(int64(p.interval))
+	}
+	buffer := time.Duration(p.frames) * p.interval
+	if p.anchorTS.IsZero() || gap <= 0 || gap >= pacingGap {
+		p.resync(ts, now.Add(buffer))
+	}
+	p.lastTS = ts
+
+	d := p.anchorAt.Add(ts.Sub(p.anchorTS)).Sub(now)
+	switch {
+	case d > buffer+p.interval:
+		p.resync(ts, now.Add(buffer))
+		d = buffer
+	case d < -buffer:
+		p.resync(ts, now)
+		d = 0
+	}
+	if d <= 0 {
+		p.late.Add(1)
+		return true
+	}
+	p.paced.Add(1)
+	p.heldNs.Add(int64(d))
+	return p.sleep(ctx, d)
+}
+
+// resync restarts the schedule with the frame captured at ts released at.
+func (p *pacer) resync(ts, at time.Time) {
+	if !p.anchorTS.IsZero() {
+		p.resyncs.Add(1)
+	}
+	p.anchorTS, p.anchorAt = ts, at
+}
+
+// PacingStats is the pacer's state in /debug/pipeline.
+type PacingStats struct {
+	Frames     int     `json:"frames"`      // jitter buffer
+	Paced      uint64  `json:"paced"`       // frames held back
+	AvgHeldMs  float64 `json:"avg_held_ms"` // mean hold of those
+	Late       uint64  `json:"late"`        // frames sent on arrival, already due
+	Resyncs    uint64  `json:"resyncs"`
+	IntervalMs float64 `json:"interval_ms"` // smoothed capture interval
+}
+
+// Stats returns the pacer's counters.
+func (p *pacer) Stats() PacingStats {
+	st := PacingStats{
+		Frames:     p.frames,
+		Paced:      p.paced.Load(),
+		Late:       p.late.Load(),
+		Resyncs:    p.resyncs.Load(),
+		IntervalMs: float64(p.lastInt.Load()) / float64(time.Millisecond),
+	}
+	if st.Paced > 0 {
+		st.AvgHeldMs = float64(p.heldNs.Load()) / float64(st.Paced) / float64(time.Millisecond)
+	}
+	return st
+}
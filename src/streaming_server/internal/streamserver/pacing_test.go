@@ -0,0 +1,80 @@
+package streamserver
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakePacer returns a pacer on a fake clock that sleeping advances.
+func fakePacer(frames int) (*pacer, *time.Time) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	p := newPacer(frames)
+	p.now = func() time.Time { return now }
+	p.sleep = func(_ context.Context, d time.Duration) bool {
+		now = now.Add(d)
+		return true
+	}
+	return p, &now
+}
+
+func TestPacerSmoothsBursts(t *testing.T) {
+	p, now := fakePacer(1)
+	var released []time.Time
+	capture := *now
+	const frame = 33 * time.Millisecond
+
+	// The reader delivers frames in pairs every 66ms, 10ms after capture
+	for i := 0; i < 40; i += 2 {
+		arrive := capture.Add(time.Duration(i+1)*frame + 10*time.Millisecond)
+		if now.Before(arrive) {
+			*now = arrive
+		}
+		for j := 0; j < 2; j++ {
+			p.wait(context.Background(), capture.Add(time.Duration(i+j)*frame))
+			released = append(released, *now)
+		}
+	}
+
+	for i := 4; i < len(released); i++ {
+		if d := released[i].Sub(released[i-1]); d < frame-2*time.Millisecond || d > frame+2*time.Millisecond {
+			t.Errorf("frame %d released %v after the previous one", i, d)
+		}
+	}
+	st := p.Stats()
+	if st.Resyncs != 0 || st.Paced < 35 || st.IntervalMs < 32 || st.IntervalMs > 34 {
+		t.Errorf("stats %+v", st)
+	}
+}
+
+func TestPacerResyncs(t *testing.T) {
+	p, now := fakePacer(2)
+	capture := *now
+	for i := 0; i < 5; i++ {
+		p.wait(context.Background(), capture.Add(time.Duration(i)*33*time.Millisecond))
+	}
+
+	// A stall: the next frame arrives a second late and goes out at once
+	*now = now.Add(time.Second)
+	p.wait(context.Background(), capture.Add(5*33*time.Millisecond))
+	if st := p.Stats(); st.Resyncs != 1 || st.Late != 1 {
+		t.Errorf("after stall %+v", st)
+	}
+
+	// A camera switch: timestamps jump, the schedule starts over
+	before := *now
+	p.wait(context.Background(), capture.Add(time.Hour))
+	if st := p.Stats(); st.Resyncs != 2 || now.Sub(before) > 70*time.Millisecond {
+		t.Errorf("after jump %+v, held %v", st, now.Sub(before))
+	}
+
+	// Cancelled while holding a frame
+	p.sleep = sleepCtx
+	p.now = time.Now
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p.anchorTS = time.Time{}
+	if p.wait(ctx, time.Now()) {
+		t.Error("wait ignored the cancelled context")
+	}
+}
@@ -0,0 +1,144 @@
+package streamserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"syscall"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/fanout"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+// frameBufSize is the capacity each pooled frame buffer is allocated with,
+// a typical H.265 frame. IDR frames can grow individual buffers past it.
+const frameBufSize = 512 * 1024
+
+// maxQueue bounds the configurable queues (20 s at 30 fps).
+const maxQueue = 600
+
+// Pipeline sizes the queues between the SHM reader and each sink. Every
+// queued frame pins one pooled frame buffer.
+type Pipeline struct {
+	WebRTCQueue         int // frames waiting for RTP packetization/SRTP (1 = always send the newest)
+	RecorderQueue       int // frames waiting for the recorder distributor
+	RecorderWriterQueue int // frames waiting for the recording file write
+
+	// Jitter buffer of the WebRTC pacer in frames: frames are released to
+	// the packetizer at their capture cadence (0 = as fast as the queue
+	// drains). Needs a WebRTCQueue above it.
+	WebRTCPacing int
+
+	// Overflow of the writer queue while the SD card stalls (0 MiB = drop)
+	SpoolDir   string
+	SpoolMaxMB int
+
+	// The GOP in progress kept while not recording, so a recording starts
+	// at its IDR rather than the next one (0 MiB = wait for the next IDR)
+	StartupMB int
+}
+
+// DefaultPipeline returns the sizes the server was tuned with: one frame
+// for WebRTC, two seconds for each recorder stage, and a 256 MiB spool in
+// the temporary directory, a tmpfs off the SD card on the board (see
+// spoolMB).
+func DefaultPipeline() Pipeline {
+	return Pipeline{WebRTCQueue: 1, RecorderQueue: 60, RecorderWriterQueue: 60, SpoolDir: os.TempDir(), SpoolMaxMB: 256}
+}
+
+// Validate checks that every queue is between 1 and maxQueue frames and
+// that the WebRTC queue can hold the pacer's jitter buffer.
+func (p Pipeline) Validate() error {
+	for _, q := range []struct {
+		name string
+		n    int
+	}{
+		{"webrtc-queue", p.WebRTCQueue},
+		{"recorder-queue", p.RecorderQueue},
+		{"recorder-writer-queue", p.RecorderWriterQueue},
+	} {
+		if q.n < 1 || q.n > maxQueue {
+			return fmt.Errorf("%s must be 1-%d frames, got %d", q.name, maxQueue, q.n)
+		}
+	}
+	if p.WebRTCPacing < 0 || p.WebRTCPacing > maxPacing {
+		return fmt.Errorf("webrtc-pacing must be 0-%d frames, got %d", maxPacing, p.WebRTCPacing)
+	}
+	if p.WebRTCPacing > 0 && p.WebRTCQueue <= p.WebRTCPacing {
+		return fmt.Errorf("webrtc-queue must be above webrtc-pacing to hold its frames, got %d <= %d", p.WebRTCQueue, p.WebRTCPacing)
+	}
+	if p.SpoolMaxMB < 0 {
+		return fmt.Errorf("recorder-spool-mb must not be negative, got %d", p.SpoolMaxMB)
+	}
+	if p.StartupMB < 0 {
+		return fmt.Errorf("recorder-startup-mb must not be negative, got %d", p.StartupMB)
+	}
+	return nil
+}
+
+// WorstCaseBytes is the frame buffer memory pinned when every queue and the
+// startup buffer are full. Spooled frames are copied out of their buffers
+// and do not count.
+func (p Pipeline) WorstCaseBytes() int {
+	return (p.WebRTCQueue+p.RecorderQueue+p.RecorderWriterQueue)*frameBufSize + p.StartupMB<<20
+}
+
+// spoolMB returns the spool size to use in MiB: SpoolMaxMB, clamped to half
+// the free space of SpoolDir, or 0 if SpoolDir is unusable. The default
+// directory is a tmpfs on the board, i.e. RAM (at most half of it), so the
+// clamp also keeps a long stall from exhausting memory. A spool on the
+// recordings' filesystem works but stalls along with the writer, so it is
+// only warned about.
+func (p Pipeline) spoolMB(recordPath string) int {
+	if p.SpoolMaxMB == 0 {
+		return 0
+	}
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(p.SpoolDir, &st); err != nil {
+		logger.Warn("Recorder", "Spool disabled, frames are dropped while the writer stalls: %v", err)
+		return 0
+	}
+	mb := p.SpoolMaxMB
+	if half := int(st.Bavail * uint64(st.Bsize) / 2 >> 20); mb > half {
+		logger.Warn("Recorder", "Spool limited to %d MiB, half the free space of %s", half, p.SpoolDir)
+		mb = half
+	}
+	if sameFilesystem(p.SpoolDir, recordPath) {
+		logger.Warn("Recorder", "Spool %s is on the recordings' filesystem; point -recorder-spool-dir elsewhere", p.SpoolDir)
+	}
+	return mb
+}
+
+// sameFilesystem reports whether a and b exist on the same device.
+func sameFilesystem(a, b string) bool {
+	sa, errA := os.Stat(a)
+	sb, errB := os.Stat(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return sa.Sys().(*syscall.Stat_t).Dev == sb.Sys().(*syscall.Stat_t).Dev
+}
+
+// handleDebugPipeline serves GET /debug/pipeline: configured queue sizes,
+// current occupancy and the worst-case frame buffer memory. Each sink is a
+// fanout queue with its consumer class and counters.
+func (s *Server) handleDebugPipeline(w http.ResponseWriter, r *http.Request) {
+	webrtc := fanout.Stats{Name: "webrtc", Class: fanout.RealTime.String()}
+	if q := s.webrtcQueue.Load(); q != nil {
+		webrtc = q.Stats()
+	}
+	resp := map[string]interface{}{
+		"mode":               s.cfg.pipelineMode(),
+		"frame_buffer_bytes": frameBufSize,
+		"worst_case_bytes":   s.cfg.Pipeline.WorstCaseBytes(),
+		"sinks":              []fanout.Stats{webrtc, s.recorderQueue.Stats(), s.recorder.QueueStats()},
+	}
+	if p := s.pacer.Load(); p != nil {
+		resp["pacing"] = p.Stats()
+	}
+	resp["gop"] = s.processor.GOP().Stats()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
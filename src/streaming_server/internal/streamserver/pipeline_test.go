@@ -0,0 +1,195 @@
+package streamserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/e2e"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/shm"
+)
+
+func TestPipelineValidate(t *testing.T) {
+	p := DefaultPipeline()
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.WorstCaseBytes(), 121*frameBufSize; got != want {
+		t.Fatalf("worst case %d, want %d", got, want)
+	}
+
+	for _, bad := range []Pipeline{
+		{WebRTCQueue: 0, RecorderQueue: 60, RecorderWriterQueue: 60},
+		{WebRTCQueue: 1, RecorderQueue: maxQueue + 1, RecorderWriterQueue: 60},
+		{WebRTCQueue: 1, RecorderQueue: 60, RecorderWriterQueue: -1},
+		{WebRTCQueue: 1, RecorderQueue: 60, RecorderWriterQueue: 60, SpoolMaxMB: -1},
+		{WebRTCQueue: 1, RecorderQueue: 60, RecorderWriterQueue: 60, WebRTCPacing: 1},
+		{WebRTCQueue: 8, RecorderQueue: 60, RecorderWriterQueue: 60, WebRTCPacing: maxPacing + 1},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%+v accepted", bad)
+		}
+	}
+}
+
+func TestPipelineSpoolMB(t *testing.T) {
+	dir := t.TempDir()
+	p := DefaultPipeline()
+	p.SpoolDir = dir
+	if got := p.spoolMB(dir); got != p.SpoolMaxMB {
+		t.Errorf("spool %d MiB, want %d", got, p.SpoolMaxMB)
+	}
+	p.SpoolMaxMB = 1 << 40 // more than any filesystem holds
+	if got := p.spoolMB(dir); got <= 0 || got >= p.SpoolMaxMB {
+		t.Errorf("oversized spool %d MiB, want clamped to the free space", got)
+	}
+	p.SpoolDir = filepath.Join(dir, "missing")
+	if got := p.spoolMB(dir); got != 0 {
+		t.Errorf("missing directory: spool %d MiB", got)
+	}
+}
+
+// fakeCameraName is the H.265 SHM segment of this test process's fake camera.
+func fakeCameraName() string {
+	return fmt.Sprintf("/streamserver_%d_h265_zc", os.Getpid())
+}
+
+// newFakeCamera creates the fake camera's segments (see e2e.FakeCamera).
+// With importFrames, readers opened afterwards resolve its frames; set it
+// only before the server starts, as the reader looks the importer up.
+func newFakeCamera(t *testing.T, importFrames bool) *e2e.FakeCamera {
+	t.Helper()
+	cam, err := e2e.NewFakeCamera(fmt.Sprintf("streamserver_%d", os.Getpid()))
+	if err != nil {
+		t.Skipf("no POSIX shared memory: %v", err)
+	}
+	if importFrames {
+		shm.SetImporter(cam.Import)
+	}
+	t.Cleanup(func() {
+		cam.Close()
+		if importFrames {
+			shm.SetImporter(nil)
+		}
+	})
+	return cam
+}
+
+// startServer starts a server on the fake camera's segment with HTTP on
+// loopback, and returns its base URL. The metrics server is off: it serves
+// from http.DefaultServeMux, once per process (scrape s.metrics.Handler).
+func startServer(t *testing.T, opts ...func(*Config)) (s *Server, httpURL string) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("starts the whole server")
+	}
+	t.Chdir(t.TempDir())
+	cfg := DefaultConfig()
+	cfg.ShmName = fakeCameraName()
+	cfg.HTTPAddr = freeAddr(t)
+	cfg.MetricsAddr = ""
+	cfg.PprofAddr = ""
+	cfg.ICEFamilies = "ipv4"
+	cfg.DegradeCPUHigh = 0
+	cfg.AccessLog = false
+	cfg.CrashDir = ""
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if err := os.MkdirAll(cfg.RecordPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Shutdown() })
+	return s, "http://" + cfg.HTTPAddr
+}
+
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+func getBody(t *testing.T, url string) (int, []byte) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, body
+}
+
+func TestLowLatencySendsFromReader(t *testing.T) {
+	cam := newFakeCamera(t, true)
+	cam.Start()
+	s, httpURL := startServer(t, func(c *Config) { c.LowLatency = true })
+
+	// Frames are read while recording even without viewers
+	if err := s.recorder.Start(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for s.metrics.Main.FramesSent.Load() < 10 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d frames sent, %d read", s.metrics.Main.FramesSent.Load(), s.metrics.FramesRead.Load())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if s.webrtcQueue.Load() != nil {
+		t.Error("low latency mode created the WebRTC send queue")
+	}
+
+	code, body := getBody(t, httpURL+"/debug/pipeline")
+	var pipeline struct {
+		Mode  string `json:"mode"`
+		Sinks []struct {
+			Name   string `json:"name"`
+			Pushed uint64 `json:"pushed"`
+		} `json:"sinks"`
+	}
+	if err := json.Unmarshal(body, &pipeline); err != nil || code != http.StatusOK {
+		t.Fatalf("/debug/pipeline: %d %s", code, body)
+	}
+	if pipeline.Mode != "low_latency" || len(pipeline.Sinks) == 0 || pipeline.Sinks[0].Name != "webrtc" || pipeline.Sinks[0].Pushed != 0 {
+		t.Errorf("/debug/pipeline %s", body)
+	}
+
+	w := httptest.NewRecorder()
+	s.metrics.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body = w.Body.Bytes()
+	var sent bool
+	for _, line := range strings.Split(string(body), "\n") {
+		if strings.HasPrefix(line, "streaming_frame_hop_latency_seconds_count{") && strings.Contains(line, `hop="processed_to_sent"`) {
+			sent = true
+			if !strings.Contains(line, `mode="low_latency"`) {
+				t.Errorf("hop latency series %s", line)
+			}
+		}
+	}
+	if !sent {
+		t.Errorf("no processed_to_sent latency in /metrics")
+	}
+}
@@ -0,0 +1,1124 @@
+// Package streamserver is the WebRTC streaming server: it reads H.265 frames
+// from shared memory and fans them out to WebRTC sessions and the recorder.
+package streamserver
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	_ "net/http/pprof" // Enable pprof
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/buildinfo"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/clock"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/codec"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/crash"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/degrade"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/fanout"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/httpcompress"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/httplog"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/metrics"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/recorder"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/rtppack"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/sdnotify"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/sframe"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/shm"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/signal"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/transcode"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/watermark"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/types"
+)
+
+// Config holds the streaming server settings (bound to flags by internal/config).
+type Config struct {
+	ShmName            string        // H.265 zero-copy shared memory name
+	HTTPAddr           string        // WebRTC signaling + recording API
+	MetricsAddr        string        // Prometheus /metrics
+	PprofAddr          string        // net/http/pprof
+	HTTPSocket         string        // Unix socket also serving the HTTP API ("" = none; with HTTPAddr "" the only one)
+	MetricsSocket      string        // Unix socket also serving /metrics ("" = none)
+	SocketMode         os.FileMode   // permissions of HTTPSocket and MetricsSocket
+	RecordPath         string        // raw recording output directory
+	MaxClients         int           // maximum WebRTC clients
+	ICEFamilies        string        // host candidate address families, preferred first ("ipv4,ipv6")
+	EncoderIdleHoldOff time.Duration // let the camera pause encoding after no consumers for this long (0 = never)
+	DegradeCPUHigh     float64       // CPU percent that steps up degradation (0 disables)
+	DegradeCPULow      float64       // CPU percent that steps degradation back down
+	Timezone           string        // display zone for file names (see clock.LoadLocation)
+	SEITimestamp       bool          // insert a capture-time SEI into every frame (WebRTC + recording)
+	SEIFrameNumber     bool          // insert a capture frame number SEI into every frame (WebRTC + recording)
+	CameraName         string        // camera name carried in the SEI (default: hostname)
+	TimingSampleEvery  int           // stream a latency sample every N frames on /api/webrtc/timing (0 disables)
+	Pipeline           Pipeline      // per-sink queue sizes
+	LowLatency         bool          // send WebRTC frames from the reader goroutine instead of through Pipeline.WebRTCQueue
+	AccessLog          bool          // log every HTTP request (method, path, status, bytes, duration)
+	Compress           bool          // gzip/deflate JSON and SSE responses for clients that accept it
+	CrashDir           string        // crash reports for recovered panics ("" = log only)
+	DrainReconnect     time.Duration // default delay viewers wait before reconnecting after POST /admin/drain
+	DrainGrace         time.Duration // default time between POST /admin/drain and exit
+
+	// Fallback for browsers without H.265: "" (disabled), "vp9" or "av1".
+	// While such a viewer is connected the stream is re-encoded once for all
+	// of them, in software unless TranscodeCommand wraps a hardware codec.
+	Transcode        string
+	TranscodeCommand string // run with transcode.FFmpegArgs: H.265 on stdin, IVF on stdout
+
+	// Client profiles (sframe.LoadProfiles) that may request end-to-end
+	// frame encryption in their offer; "" disables it.
+	E2EProfiles string
+
+	// Ed25519 device key (base64 seed, created if missing) that signs the
+	// hash chain manifest of every recording; "" disables watermarking.
+	// Anchors carry CameraName as the device ID.
+	DeviceKey string
+}
+
+// DefaultConfig returns the settings the systemd unit has always used.
+func DefaultConfig() Config {
+	return Config{
+		ShmName:            "/pet_camera_h265_zc",
+		HTTPAddr:           ":8081",
+		MetricsAddr:        ":9090",
+		PprofAddr:          ":6060",
+		SocketMode:         0o660,
+		RecordPath:         "./recordings",
+		MaxClients:         10,
+		ICEFamilies:        "ipv4,ipv6",
+		EncoderIdleHoldOff: 30 * time.Second,
+		DegradeCPUHigh:     90,
+		DegradeCPULow:      70,
+		Timezone:           "Asia/Tokyo",
+		TimingSampleEvery:  30,
+		Pipeline:           DefaultPipeline(),
+		AccessLog:          true,
+		Compress:           true,
+		CrashDir:           filepath.Join("recordings", "crash"),
+		DrainReconnect:     10 * time.Second,
+		DrainGrace:         3 * time.Second,
+		TranscodeCommand:   "ffmpeg",
+	}
+}
+
+// pipelineMode labels frame latency metrics so the two send paths can be compared.
+func (c Config) pipelineMode() string {
+	if c.LowLatency {
+		return "low_latency"
+	}
+	return "pipelined"
+}
+
+// joinAddrs lists a server's TCP address and Unix socket for the startup log.
+func joinAddrs(addr, socket string) string {
+	switch {
+	case socket == "":
+		return addr
+	case addr == "":
+		return "unix:" + socket
+	}
+	return addr + ", unix:" + socket
+}
+
+// decimateKeep is how many frames of each GOP (1 s) WebRTC still sends at
+// degrade.LevelDecimateWebRTC.
+const decimateKeep = 15
+
+// shutdownDrainTimeout bounds how long Shutdown waits for the recorder
+// distributor to hand over the frames queued when the reader stopped.
+const shutdownDrainTimeout = 5 * time.Second
+
+// Server is the main streaming server
+type Server struct {
+	cfg    Config
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup // distributeRecorder
+	// The frame reader runs under its own context, stopped first by
+	// Shutdown so the stages after it can drain (see stopPipeline)
+	readerCtx  context.Context
+	stopReader context.CancelFunc
+	readerWg   sync.WaitGroup // attachAndRead
+	metrics    *metrics.Metrics
+	shmReader  *shm.Reader // set by attachAndRead once the camera SHM exists
+	demand     *shm.Demand
+	degrade    *degrade.Controller
+	watchdog   *sdnotify.Watchdog
+	processor  *codec.Processor
+	signal     *signal.Server
+	recorder   *recorder.Recorder
+	httpServer *http.Server
+	timing     *timingHub
+	drain      *drainState
+	whep       *whepResources
+	e2e        *sframe.Sender // nil unless Config.E2EProfiles is set
+
+	// Consumer queues of the frame loop (see package fanout): readFrames'
+	// WebRTC send queue (RealTime, recreated with each run, for
+	// /debug/pipeline) and the recorder distributor's (Reliable)
+	webrtcQueue   atomic.Pointer[fanout.Queue[*types.VideoFrame]]
+	recorderQueue *fanout.Queue[*types.VideoFrame]
+	// Stage 2's pacer, nil without Pipeline.WebRTCPacing (for /debug/pipeline)
+	pacer atomic.Pointer[pacer]
+
+	// Unix nanos of the last readFrames tick (watchdog liveness)
+	loopBeat atomic.Int64
+	// Camera SHM attached and frames are being read
+	cameraAttached atomic.Bool
+	// Parameter sets last written to RecordPath (readFrames only)
+	paramSets codec.ParamSets
+
+	// Pool for recorder frame buffers — avoids per-frame heap allocation
+	recorderBufPool sync.Pool
+	// Pool for SHM read buffers — avoids per-frame allocation in ReadLatestCopy
+	shmBufPool sync.Pool
+}
+
+// Run creates and starts the server, blocks until ctx is cancelled or a
+// drain (POST /admin/drain) completes, then shuts it down.
+func Run(ctx context.Context, cfg Config) error {
+	loc, err := clock.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return fmt.Errorf("timezone %q: %w", cfg.Timezone, err)
+	}
+	clock.SetLocation(loc)
+	if err := cfg.Pipeline.Validate(); err != nil {
+		return err
+	}
+	if cfg.LowLatency && cfg.Pipeline.WebRTCPacing > 0 {
+		return fmt.Errorf("webrtc-pacing needs the WebRTC queue, which -low-latency bypasses")
+	}
+	if (cfg.SEITimestamp || cfg.DeviceKey != "") && cfg.CameraName == "" {
+		cfg.CameraName, _ = os.Hostname()
+	}
+
+	if err := os.MkdirAll(cfg.RecordPath, 0755); err != nil {
+		return fmt.Errorf("failed to create recordings directory: %w", err)
+	}
+	srv, err := NewServer(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create server: %w", err)
+	}
+	if err := srv.Start(); err != nil {
+		return fmt.Errorf("failed to start server: %w", err)
+	}
+	// Listeners are bound; the camera SHM attaches in the background
+	sdnotify.Ready("waiting for camera " + cfg.ShmName)
+
+	select {
+	case <-ctx.Done():
+	case <-srv.drain.done:
+		log.Println("Drain complete")
+	}
+	sdnotify.Stopping()
+	log.Println("Shutting down...")
+	if err := srv.Shutdown(); err != nil {
+		log.Printf("Error during shutdown: %v", err)
+	}
+	log.Println("Server stopped")
+	return nil
+}
+
+// NewServer creates a new streaming server
+func NewServer(cfg Config) (*Server, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Create metrics
+	m := metrics.New(metrics.CameraLabel(cfg.CameraName))
+	m.SetPipelineMode(cfg.pipelineMode())
+
+	// Create H.264 processor
+	processor := codec.NewProcessor()
+
+	// Create signal server (self-contained WebRTC: SDP + ICE-lite + DTLS + SRTP)
+	families, err := signal.ParseFamilies(cfg.ICEFamilies)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("ice families: %w", err)
+	}
+	signalSrv, err := signal.NewServer(cfg.MaxClients)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to create signal server: %w", err)
+	}
+	signalSrv.Families = families
+	signalSrv.SetOnReap(func(string) { m.WebRTCSessionsReaped.Add(1) })
+	if cfg.Transcode != "" {
+		if _, err := transcode.FFmpegArgs(strings.ToUpper(cfg.Transcode), 30); err != nil {
+			cancel()
+			return nil, err
+		}
+		signalSrv.FallbackCodecs = []string{strings.ToUpper(cfg.Transcode)}
+	}
+
+	var e2e *sframe.Sender
+	if cfg.E2EProfiles != "" {
+		if e2e, err = newE2E(cfg.E2EProfiles, signalSrv); err != nil {
+			cancel()
+			return nil, fmt.Errorf("e2e: %w", err)
+		}
+	}
+
+	// Create recorder
+	rec := recorder.NewRecorder(cfg.RecordPath)
+	rec.SetQueueSize(cfg.Pipeline.RecorderWriterQueue)
+	cfg.Pipeline.SpoolMaxMB = cfg.Pipeline.spoolMB(cfg.RecordPath)
+	rec.SetSpool(cfg.Pipeline.SpoolDir, int64(cfg.Pipeline.SpoolMaxMB)<<20)
+	rec.SetStartupBuffer(int64(cfg.Pipeline.StartupMB) << 20)
+	rec.SetGOPTracker(processor.GOP())
+	if cfg.DeviceKey != "" {
+		key, err := watermark.LoadOrCreateKey(cfg.DeviceKey)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("device key: %w", err)
+		}
+		if err := rec.SetWatermark(cfg.CameraName, key); err != nil {
+			cancel()
+			return nil, fmt.Errorf("device key: %w", err)
+		}
+		logger.Info("Recorder", "Watermarking recordings as %q, public key %s",
+			cfg.CameraName, base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)))
+	}
+
+	// Prime headers from the previous run before the HTTP API accepts /start:
+	// the H.265 SHM holds only the latest frame, so after a restart there is
+	// nothing to scan back through until the next IDR carries VPS/SPS/PPS.
+	paramSetsPath := filepath.Join(cfg.RecordPath, codec.ParamSetsFile)
+	if ps, err := codec.LoadParamSets(paramSetsPath); err != nil {
+		logger.Warn("Reader", "Failed to load parameter sets: %v", err)
+	} else if ps.Complete() {
+		processor.Prime(ps)
+		rec.UpdateHeaders(ps.VPS, ps.SPS, ps.PPS)
+		logger.Info("Reader", "Primed VPS/SPS/PPS from %s", paramSetsPath)
+	}
+
+	// Create HTTP server
+	mux := http.NewServeMux()
+	access := httplog.New("streaming")
+	access.Log = cfg.AccessLog
+	m.Register(access)
+	compress := httpcompress.New("streaming")
+	compress.Enabled = cfg.Compress
+	m.Register(compress)
+	m.Register(crash.Collector())
+	crash.SetDir(cfg.CrashDir)
+	httpServer := &http.Server{
+		Addr:    cfg.HTTPAddr,
+		Handler: access.Wrap(compress.Wrap(crash.Middleware(mux))),
+	}
+
+	readerCtx, stopReader := context.WithCancel(ctx)
+	srv := &Server{
+		cfg:        cfg,
+		ctx:        ctx,
+		cancel:     cancel,
+		readerCtx:  readerCtx,
+		stopReader: stopReader,
+		metrics:    m,
+		processor:  processor,
+		signal:     signalSrv,
+		recorder:   rec,
+		httpServer: httpServer,
+		timing:     newTimingHub(),
+		drain:      newDrainState(),
+		whep:       newWHEPResources(),
+		e2e:        e2e,
+		paramSets:  processor.ParamSets(),
+		recorderQueue: fanout.NewQueue("recorder", fanout.Options[*types.VideoFrame]{
+			Class:    fanout.Reliable,
+			Capacity: cfg.Pipeline.RecorderQueue,
+		}),
+		recorderBufPool: sync.Pool{
+			New: func() interface{} {
+				buf := make([]byte, 0, frameBufSize)
+				return &buf
+			},
+		},
+		shmBufPool: sync.Pool{
+			New: func() interface{} {
+				buf := make([]byte, 0, frameBufSize)
+				return &buf
+			},
+		},
+	}
+
+	// The writer hands each recorded frame's copy back to the pool
+	rec.SetRelease(srv.releaseRecorderFrame)
+
+	// Report viewers + recording to the camera daemon so it can pause the encoder
+	srv.demand = shm.NewDemand(func() int {
+		n := signalSrv.GetClientCount()
+		if rec.IsRecording() {
+			n++
+		}
+		return n
+	})
+	srv.demand.HoldOff = cfg.EncoderIdleHoldOff
+
+	// Shed WebRTC frames as the last resort when the board is out of CPU
+	if cfg.DegradeCPUHigh > 0 {
+		srv.degrade = degrade.NewController()
+		srv.degrade.High = cfg.DegradeCPUHigh
+		srv.degrade.Low = cfg.DegradeCPULow
+		srv.degrade.SetOnSample(func(st degrade.Status) {
+			m.DegradationLevel.Store(uint64(st.LevelNum))
+			m.CPUUsagePercent.Store(uint64(st.CPUPercent))
+		})
+	}
+
+	// Feed the systemd watchdog only while the frame loop keeps ticking
+	srv.watchdog = sdnotify.NewWatchdog(srv.checkAlive)
+
+	// Setup HTTP routes
+	srv.setupRoutes(mux)
+
+	return srv, nil
+}
+
+// frameLoopStallAfter is how long readFrames may go without a tick before
+// the process counts as hung. Frame interval re-measurement blocks for a few
+// frames at most.
+const frameLoopStallAfter = 10 * time.Second
+
+// checkAlive is the watchdog liveness check: the frame loop must be ticking
+// (it ticks even while idle with no viewers).
+func (s *Server) checkAlive() error {
+	last := s.loopBeat.Load()
+	if last == 0 {
+		return nil // waiting for camera or still measuring the frame interval
+	}
+	if since := time.Since(time.Unix(0, last)); since > frameLoopStallAfter {
+		return fmt.Errorf("frame loop stalled for %v", since.Round(time.Second))
+	}
+	return nil
+}
+
+// Start starts all server components
+func (s *Server) Start() error {
+	log.Printf("Starting streaming server...")
+	log.Printf("  Shared memory: %s", s.cfg.ShmName)
+	log.Printf("  HTTP server: %s", joinAddrs(s.cfg.HTTPAddr, s.cfg.HTTPSocket))
+	log.Printf("  Metrics server: %s", joinAddrs(s.cfg.MetricsAddr, s.cfg.MetricsSocket))
+	log.Printf("  pprof server: %s", s.cfg.PprofAddr)
+	log.Printf("  Recording path: %s", s.cfg.RecordPath)
+	log.Printf("  Pipeline mode: %s", s.cfg.pipelineMode())
+	p := s.cfg.Pipeline
+	log.Printf("  Pipeline queues: webrtc=%d recorder=%d writer=%d frames (up to %.1f MiB of %d KiB frame buffers)",
+		p.WebRTCQueue, p.RecorderQueue, p.RecorderWriterQueue, float64(p.WorstCaseBytes())/(1<<20), frameBufSize/1024)
+	if p.WebRTCPacing > 0 {
+		log.Printf("  WebRTC pacing: %d frame jitter buffer", p.WebRTCPacing)
+	}
+	if p.SpoolMaxMB > 0 {
+		log.Printf("  Recorder spool: up to %d MiB in %s", p.SpoolMaxMB, p.SpoolDir)
+	}
+
+	// Bind every listener before reporting ready; systemd socket activation
+	// hands them over pre-opened (FileDescriptorName=http/metrics/pprof, and
+	// http-unix/metrics-unix for the Unix sockets).
+	httpLns, err := sdnotify.ListenAddrs("http", s.cfg.HTTPAddr, s.cfg.HTTPSocket, s.cfg.SocketMode)
+	if err != nil {
+		return err
+	}
+	if len(httpLns) == 0 {
+		return fmt.Errorf("no HTTP listener: set -http or -http-socket")
+	}
+
+	// Start pprof server
+	if lns, err := sdnotify.ListenAddrs("pprof", s.cfg.PprofAddr, "", 0); err != nil {
+		log.Printf("pprof server error: %v", err)
+	} else {
+		for _, ln := range lns {
+			go func() {
+				log.Printf("Starting pprof server on %s", ln.Addr())
+				if err := http.Serve(ln, nil); err != nil {
+					log.Printf("pprof server error: %v", err)
+				}
+			}()
+		}
+	}
+
+	// Start metrics server
+	if lns, err := sdnotify.ListenAddrs("metrics", s.cfg.MetricsAddr, s.cfg.MetricsSocket, s.cfg.SocketMode); err != nil {
+		log.Printf("Metrics server error: %v", err)
+	} else {
+		for _, ln := range lns {
+			go func() {
+				log.Printf("Starting metrics server on %s", ln.Addr())
+				if err := s.metrics.Serve(ln); err != nil {
+					log.Printf("Metrics server error: %v", err)
+				}
+			}()
+		}
+	}
+
+	// Start HTTP server
+	for _, ln := range httpLns {
+		go func() {
+			log.Printf("Starting HTTP server on %s", ln.Addr())
+			if err := s.httpServer.Serve(ln); err != http.ErrServerClosed {
+				log.Printf("HTTP server error: %v", err)
+			}
+		}()
+	}
+
+	// Start goroutines
+	// attachAndRead: wait for the camera SHM, then the 2-stage pipeline —
+	// SHM read (ReadLatestCopy) + async WebRTC send
+	s.readerWg.Add(1)
+	go s.attachAndRead()
+	s.wg.Add(1)
+	go s.distributeRecorder()
+	s.demand.Start()
+	if s.degrade != nil {
+		s.degrade.Start()
+	}
+	s.watchdog.Start()
+
+	log.Println("Server started successfully")
+	return nil
+}
+
+// Backoff between attempts to open the camera SHM.
+const (
+	attachMinBackoff = 500 * time.Millisecond
+	attachMaxBackoff = 10 * time.Second
+)
+
+// attachAndRead opens the camera SHM, retrying with backoff until the capture
+// daemon has created it, then runs the frame loop. The HTTP, metrics and
+// pprof servers are already up, so boot order with the daemon does not
+// matter; /readyz reports "waiting for camera" meanwhile.
+func (s *Server) attachAndRead() {
+	defer s.readerWg.Done()
+
+	backoff := attachMinBackoff
+	for {
+		reader, err := shm.OpenReader(s.cfg.ShmName)
+		if err == nil {
+			// Only readFrames and Shutdown (after readerWg.Wait) touch shmReader
+			s.shmReader = reader
+			break
+		}
+		if backoff == attachMinBackoff {
+			logger.Info("Reader", "Waiting for camera: %v", err)
+		}
+		select {
+		case <-s.readerCtx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > attachMaxBackoff {
+			backoff = attachMaxBackoff
+		}
+	}
+
+	s.cameraAttached.Store(true)
+	sdnotify.Status("streaming " + s.cfg.ShmName)
+	// A panic in the frame loop is reported and the loop restarted, so
+	// viewers reconnect to a live stream instead of a dead process.
+	for crash.Do("frame-loop", s.readFrames) {
+		select {
+		case <-s.readerCtx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// persistParamSets saves the processor's parameter sets when they differ
+// from the ones on disk (normally once, or after an encoder reconfigure).
+func (s *Server) persistParamSets() {
+	ps := s.processor.ParamSets()
+	if ps.Equal(s.paramSets) {
+		return
+	}
+	path := filepath.Join(s.cfg.RecordPath, codec.ParamSetsFile)
+	if err := codec.SaveParamSets(path, ps); err != nil {
+		logger.Warn("Reader", "Failed to save parameter sets: %v", err)
+		return
+	}
+	s.paramSets = ps // the processor replaces, never mutates, its caches
+}
+
+// cameraState is "attached" once the camera SHM is open, else "waiting".
+func (s *Server) cameraState() string {
+	if s.cameraAttached.Load() {
+		return "attached"
+	}
+	return "waiting"
+}
+
+// readFrames reads frames from shared memory using a 2-stage pipeline.
+//
+// Stage 1 (this goroutine): ReadLatestCopy → Process → recorder copy → send queue
+// Stage 2 (sender goroutine): send queue → SendFrame (blocks per-frame on wg.Wait)
+//
+// ReadLatestCopy returns an independent Go-owned copy of the VPU buffer, so
+// the sender goroutine can hold frame.Data safely while Stage 1 immediately
+// calls ReadLatestCopy again for the next frame. This breaks the serialisation
+// that existed when ReadLatest (zero-copy, valid only until next ReadLatest)
+// was used together with the blocking SendFrame.
+//
+// With Config.LowLatency there is no Stage 2: Stage 1 packetizes and sends
+// each frame itself, trading a slower read loop for no queueing before the
+// RTP packetizer. The recorder stays on its own goroutine either way.
+func (s *Server) readFrames() {
+	sender := &webrtcSender{s: s, ssrc: 0x12345678, fallback: newFallbackStream(s)}
+	defer sender.fallback.close() // after Stage 2 below has exited
+
+	// Stage 2: async sender using self-contained WebRTC (signal package).
+	// Replaces pion's SendFrame with our own RTP packetization + SRTP encryption.
+	// The queue is RealTime: while the sender is busy, a new frame evicts the
+	// oldest queued one, so viewers always get the newest frames.
+	var sendQueue *fanout.Queue[*types.VideoFrame]
+	if !s.cfg.LowLatency {
+		sendQueue = fanout.NewQueue("webrtc", fanout.Options[*types.VideoFrame]{
+			Class:    fanout.RealTime,
+			Capacity: s.cfg.Pipeline.WebRTCQueue,
+			Release:  s.dropWebRTCFrame,
+		})
+		s.webrtcQueue.Store(sendQueue)
+		// Optionally smooth the reader's bursts: hold each frame until its
+		// capture-time slot (see pacer)
+		var pace *pacer
+		if n := s.cfg.Pipeline.WebRTCPacing; n > 0 {
+			pace = newPacer(n)
+			s.pacer.Store(pace)
+		}
+		paceCtx, stopPacing := context.WithCancel(s.ctx)
+		var sendWg sync.WaitGroup
+		sendWg.Add(1)
+		go func() {
+			defer sendWg.Done()
+			for {
+				frame, ok := sendQueue.Pop(context.Background())
+				if !ok {
+					return
+				}
+				if pace != nil {
+					pace.wait(paceCtx, frame.Timestamp)
+				}
+				crash.Do("webrtc-send", func() { sender.send(frame) })
+			}
+		}()
+
+		// Ensure the sender goroutine is drained and exited before readFrames returns.
+		defer func() {
+			stopPacing()
+			sendQueue.Close()
+			sendWg.Wait()
+		}()
+	}
+
+	// Measure camera frame interval and sync to frame boundary.
+	interval := s.shmReader.MeasureFrameInterval(5)
+	logger.Info("Reader", "Frame interval: %v (double-buffered)", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	missCount := 0
+	lastVer := s.shmReader.Version()
+	decimator := degrade.GOPDecimator{Keep: decimateKeep}
+
+	for {
+		select {
+		case <-s.readerCtx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.loopBeat.Store(time.Now().UnixNano())
+
+		// Skip reading if no clients and not recording.
+		if s.signal.GetClientCount() == 0 && !s.recorder.IsRecording() {
+			lastVer = s.shmReader.Version()
+			continue
+		}
+
+		// Check for new frame.
+		ver := s.shmReader.Version()
+		if ver == lastVer {
+			missCount++
+			// Camera switch or stall — re-sync after 5 consecutive misses.
+			if missCount > 5 {
+				interval = s.shmReader.MeasureFrameInterval(3)
+				ticker.Reset(interval)
+				lastVer = s.shmReader.Version()
+				missCount = 0
+				logger.Debug("Reader", "Re-synced frame interval: %v", interval)
+			}
+			continue
+		}
+		lastVer = ver
+		missCount = 0
+
+		// Read latest frame into a pooled buffer (import + memcpy + VPU free).
+		// frame.Data is a plain Go []byte; no VPU lifetime dependency.
+		// The Stage 2 sender goroutine returns frame.Data to shmBufPool after SendFrame.
+		shmBufPtr := s.shmBufPool.Get().(*[]byte)
+		frame, err := s.shmReader.ReadLatestCopyBuf(*shmBufPtr)
+		if err != nil {
+			s.shmBufPool.Put(shmBufPtr)
+			s.metrics.ReadErrors.Add(1)
+			logger.Warn("Reader", "Read error: %v", err)
+			continue
+		}
+		if frame == nil {
+			// Empty buffer or a re-delivered frame number.
+			s.shmBufPool.Put(shmBufPtr)
+			s.metrics.DuplicateFramesSkipped.Store(s.shmReader.DuplicatesSkipped())
+			continue
+		}
+
+		frame.Timing.Read = time.Now()
+		s.metrics.FramesRead.Add(1)
+		s.metrics.UpdateFrameLatency(frame.Timestamp)
+
+		// Process (NAL parsing, header extraction) — safe on our owned copy.
+		if err := s.processor.Process(frame); err != nil {
+			s.metrics.ProcessErrors.Add(1)
+			buf := frame.Data
+			s.shmBufPool.Put(&buf)
+			continue
+		}
+		if s.processor.HasHeaders() {
+			s.recorder.UpdateHeaders(s.processor.GetVPS(), s.processor.GetSPS(), s.processor.GetPPS())
+			if frame.IsIDR {
+				s.persistParamSets()
+			}
+		}
+		if s.cfg.SEITimestamp {
+			codec.InsertNAL(frame, codec.TimestampSEI(frame.Timestamp, s.cfg.CameraName))
+		}
+		if s.cfg.SEIFrameNumber {
+			codec.InsertNAL(frame, codec.FrameNumberSEI(frame.FrameNumber))
+		}
+		frame.Timing.Processed = time.Now()
+		s.metrics.FramesProcessed.Add(1)
+
+		// Recorder path: copy frame.Data into a pool buffer.
+		// This copy is separate from the WebRTC frame so that distributeRecorder
+		// can call recorderBufPool.Put after the recorder consumes it, while the
+		// WebRTC sender still holds frame.Data independently. Between
+		// recordings the copy goes to the startup buffer.
+		if s.recorder.IsRecording() || s.recorder.Buffering() {
+			bufPtr := s.recorderBufPool.Get().(*[]byte)
+			buf := (*bufPtr)[:0]
+			if cap(buf) < len(frame.Data) {
+				buf = make([]byte, len(frame.Data))
+			} else {
+				buf = buf[:len(frame.Data)]
+			}
+			copy(buf, frame.Data)
+			recFrame := *frame
+			recFrame.Data = buf
+			if !s.recorder.Buffer(&recFrame) && !s.recorderQueue.Push(&recFrame) {
+				s.recorderBufPool.Put(&buf)
+				s.metrics.RecorderFramesDropped.Add(1)
+			}
+		}
+
+		// Under heavy CPU pressure send only the head of each GOP.
+		if !decimator.Send(frame.IsIDR, s.decimating()) {
+			buf := frame.Data
+			s.shmBufPool.Put(&buf)
+			s.metrics.Main.FramesDecimated.Add(1)
+			continue
+		}
+
+		if sendQueue == nil {
+			// As in Stage 2, a panic loses this frame, not the loop
+			crash.Do("webrtc-send", func() { sender.send(frame) })
+			continue
+		}
+
+		// Hand frame off to the async sender (Stage 2). Never blocks: if the
+		// sender is still busy, the oldest queued frame is dropped instead —
+		// the recorder path above has already captured it independently.
+		sendQueue.Push(frame)
+	}
+}
+
+// dropWebRTCFrame releases a frame the WebRTC send queue evicted unsent.
+func (s *Server) dropWebRTCFrame(frame *types.VideoFrame) {
+	buf := frame.Data
+	s.shmBufPool.Put(&buf)
+	s.metrics.Main.FramesDropped.Add(1)
+	s.signal.DropFrame()
+	logger.Debug("Reader", "WebRTC sender busy, dropping frame %d", frame.FrameNumber)
+}
+
+// releaseRecorderFrame returns the buffer of a recorder frame copy to the pool.
+func (s *Server) releaseRecorderFrame(frame *types.VideoFrame) {
+	s.recorderBufPool.Put(&frame.Data)
+}
+
+// webrtcSender packetizes frames and sends them to every WebRTC client. It
+// is owned by a single goroutine: Stage 2, or the reader in low-latency mode.
+type webrtcSender struct {
+	s        *Server
+	ssrc     uint32
+	seq      uint16
+	e2eSeq   uint16          // sequence of the SFrame-protected stream
+	fallback *fallbackStream // nil unless Config.Transcode is set
+}
+
+// send packetizes frame, sends it, records its timing and returns frame.Data
+// to the SHM buffer pool.
+func (ws *webrtcSender) send(frame *types.VideoFrame) {
+	s := ws.s
+	ts := uint32(frame.FrameNumber * 3000) // 90kHz / 30fps = 3000 ticks
+	packets, nextSeq := rtppack.PacketizeH265(frame, ws.ssrc, ws.seq, ts, 1200)
+	ws.seq = nextSeq
+	s.signal.SendFrame(packets)
+	if s.e2e != nil && s.signal.E2EClients() > 0 {
+		packets, ws.e2eSeq = rtppack.PacketizeH265(s.e2e.ProtectH265(frame), ws.ssrc, ws.e2eSeq, ts, 1200)
+		s.signal.SendE2EFrame(packets)
+	}
+	ws.fallback.feed(frame)
+	s.metrics.Main.FramesSent.Add(1)
+	frame.Timing.Sent = time.Now()
+	s.metrics.ObserveFrameTiming(frame)
+	if n := s.cfg.TimingSampleEvery; n > 0 && frame.FrameNumber%uint64(n) == 0 {
+		s.timing.publish(newTimingSample(frame))
+	}
+	// Return the SHM read buffer to pool
+	buf := frame.Data
+	s.shmBufPool.Put(&buf)
+}
+
+// decimating reports whether CPU pressure has reached WebRTC decimation.
+func (s *Server) decimating() bool {
+	return s.degrade != nil && s.degrade.Level() >= degrade.LevelDecimateWebRTC
+}
+
+// distributeRecorder distributes frames to recorder until the queue is
+// closed and empty, or Shutdown gives up waiting for that.
+func (s *Server) distributeRecorder() {
+	defer s.wg.Done()
+
+	for {
+		frame, ok := s.recorderQueue.Pop(s.ctx)
+		if !ok {
+			return
+		}
+		if s.ctx.Err() != nil {
+			// Past the shutdown drain deadline: free what is left
+			s.releaseRecorderFrame(frame)
+			s.metrics.RecorderFramesDropped.Add(1)
+			continue
+		}
+		// frame.Data is already copied by readFrames (VPU buffer is
+		// transient); the writer releases it once written
+		sent := false
+		crash.Do("recorder", func() { sent = s.recorder.SendFrame(frame) })
+		if sent {
+			s.metrics.RecorderFramesSent.Add(1)
+		} else {
+			s.releaseRecorderFrame(frame)
+		}
+
+		// Update recording metrics
+		status := s.recorder.GetStatus()
+		if status.Recording {
+			s.metrics.RecordingActive.Store(1)
+			s.metrics.RecordingBytes.Store(status.BytesWritten)
+			s.metrics.RecordingFrames.Store(status.FrameCount)
+		} else {
+			s.metrics.RecordingActive.Store(0)
+		}
+	}
+}
+
+// stopPipeline stops the frame loop front to back, so a frame that was read
+// still reaches the recorder: the reader first (its WebRTC sender finishes
+// the frames it holds), then the recorder distributor once it has handed
+// over everything queued, then the rest of the server's goroutines. Frames
+// still queued after timeout are released and counted as dropped.
+func (s *Server) stopPipeline(timeout time.Duration) {
+	s.stopReader()
+	s.readerWg.Wait()
+
+	queued := s.recorderQueue.Len()
+	s.recorderQueue.Close()
+	drained := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		if queued > 0 {
+			logger.Info("Shutdown", "Drained %d queued recorder frames", queued)
+		}
+	case <-time.After(timeout):
+		logger.Warn("Shutdown", "Recorder queue not drained after %v, dropping %d frames", timeout, s.recorderQueue.Len())
+	}
+	s.cancel()
+	<-drained
+}
+
+// setupRoutes sets up HTTP routes
+func (s *Server) setupRoutes(mux *http.ServeMux) {
+	// CORS middleware
+	corsMiddleware := func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+			if r.Method == "OPTIONS" {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			next(w, r)
+		}
+	}
+
+	// WebRTC signaling
+	mux.HandleFunc("/offer", corsMiddleware(s.handleOffer))
+
+	// WHEP: standard players, with the server-sent events extension
+	mux.HandleFunc("/whep", s.handleWHEP)
+	mux.HandleFunc("/whep/", s.handleWHEPResource)
+
+	// Recording control
+	mux.HandleFunc("/start", corsMiddleware(s.handleStartRecording))
+	mux.HandleFunc("/stop", corsMiddleware(s.handleStopRecording))
+	mux.HandleFunc("/record", corsMiddleware(s.handleRecordFor))
+	mux.HandleFunc("/status", corsMiddleware(s.handleStatus))
+
+	// Client count API
+	mux.HandleFunc("/api/clients/count", corsMiddleware(s.handleClientCount))
+
+	// Per-session WebRTC quality stats (RTCP receiver reports, NACKs, drops)
+	mux.HandleFunc("/api/webrtc/stats", corsMiddleware(s.handleWebRTCStats))
+
+	// Per-frame pipeline latency samples (SSE)
+	mux.HandleFunc("/api/webrtc/timing", corsMiddleware(s.handleTiming))
+
+	// Queue sizes and occupancy
+	mux.HandleFunc("/debug/pipeline", s.handleDebugPipeline)
+
+	// Drain for restarts/updates
+	mux.HandleFunc("/admin/drain", s.handleDrain)
+
+	// Health check
+	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+}
+
+// maxOfferBytes bounds the /offer body.
+const maxOfferBytes = 64 << 10
+
+// handleOffer handles WebRTC offer
+func (s *Server) handleOffer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.refuseWhileDraining(w) {
+		return
+	}
+
+	// Browser offers are a few KiB; the parser never needs more
+	offerJSON, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxOfferBytes))
+	if err != nil {
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	answerJSON, err := s.signal.HandleOffer(offerJSON)
+	if err != nil {
+		log.Printf("[HTTP] WebRTC offer error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to handle offer: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	s.metrics.TotalClients.Add(1)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(answerJSON)
+}
+
+// handleStartRecording handles start recording request
+func (s *Server) handleStartRecording(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.refuseWhileDraining(w) {
+		return
+	}
+
+	if err := s.recorder.Start(); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to start recording: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	status := s.recorder.GetStatus()
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"status":  status,
+	})
+}
+
+// handleStopRecording handles stop recording request
+func (s *Server) handleStopRecording(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := s.recorder.Stop(); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to stop recording: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	status := s.recorder.GetStatus()
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"status":  status,
+	})
+}
+
+// handleRecordFor handles POST /record?duration=30s: a recording that
+// stops by itself at the first IDR after the duration, or the active timed
+// recording extended to end no earlier than that.
+func (s *Server) handleRecordFor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	d, err := recorder.ParseDuration(r.URL.Query().Get("duration"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !s.recorder.IsRecording() && s.refuseWhileDraining(w) {
+		return
+	}
+
+	timed, err := s.recorder.RecordFor(d)
+	switch {
+	case errors.Is(err, recorder.ErrAlreadyRecording), errors.Is(err, recorder.ErrStopping):
+		http.Error(w, fmt.Sprintf("Failed to start recording: %v", err), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("Failed to start recording: %v", err), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"filename": timed.Filename,
+		"until":    timed.Until,
+		"extended": timed.Extended,
+	})
+}
+
+// handleStatus handles status request
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	status := s.recorder.GetStatus()
+	json.NewEncoder(w).Encode(status)
+}
+
+// handleHealth handles health check
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":         "ok",
+		"camera":         s.cameraState(),
+		"webrtc_clients": s.signal.GetClientCount(),
+		"recording":      s.recorder.IsRecording(),
+		"has_headers":    s.processor.HasHeaders(),
+		"degradation":    s.degradationStatus(),
+		"capture_clock":  shm.Clock.Status(),
+		"server_info":    buildinfo.Get(),
+	})
+}
+
+// degradationStatus returns the CPU degradation state, or nil when disabled.
+func (s *Server) degradationStatus() *degrade.Status {
+	if s.degrade == nil {
+		return nil
+	}
+	st := s.degrade.Status()
+	return &st
+}
+
+// handleClientCount returns the current WebRTC client count and, while
+// draining, how long viewers should wait before reconnecting.
+func (s *Server) handleClientCount(w http.ResponseWriter, r *http.Request) {
+	body := map[string]interface{}{
+		"count": s.signal.GetClientCount(),
+	}
+	s.drain.annotate(body)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// handleWebRTCStats returns per-session connection quality.
+func (s *Server) handleWebRTCStats(w http.ResponseWriter, r *http.Request) {
+	body := map[string]interface{}{
+		"count":    s.signal.GetClientCount(),
+		"sessions": s.signal.Stats(),
+		"camera":   s.cameraState(),
+	}
+	s.drain.annotate(body)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// handleReadyz answers 200 once the camera SHM is attached and 503 with
+// "waiting for camera" before that. /health stays 200 throughout.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	body := map[string]interface{}{
+		"ready":  s.cameraAttached.Load(),
+		"camera": s.cameraState(),
+	}
+	if !s.cameraAttached.Load() {
+		body["reason"] = "waiting for camera"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(body)
+}
+
+// Shutdown gracefully shuts down the server
+func (s *Server) Shutdown() error {
+	s.stopPipeline(shutdownDrainTimeout)
+
+	// Stop recording if active: the writer finishes every frame it was
+	// handed before the file is closed
+	if s.recorder.IsRecording() {
+		s.recorder.Stop()
+	}
+
+	// Close components
+	s.watchdog.Stop()
+	s.demand.Stop()
+	if s.degrade != nil {
+		s.degrade.Stop()
+	}
+	s.recorder.Close()
+	s.signal.Close()
+	if s.shmReader != nil {
+		s.shmReader.Close()
+	}
+
+	// Shutdown HTTP server
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.httpServer.Shutdown(ctx)
+}
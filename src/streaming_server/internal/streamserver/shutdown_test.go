@@ -0,0 +1,97 @@
+package streamserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/fanout"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/metrics"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/recorder"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/types"
+)
+
+// newPipelineServer returns a server with just the recorder half of the
+// frame loop, recording.
+func newPipelineServer(t *testing.T, queue int) *Server {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	readerCtx, stopReader := context.WithCancel(ctx)
+	rec := recorder.NewRecorder(t.TempDir())
+	rec.SetQueueSize(queue)
+	s := &Server{
+		cfg:        DefaultConfig(),
+		ctx:        ctx,
+		cancel:     cancel,
+		readerCtx:  readerCtx,
+		stopReader: stopReader,
+		metrics:    metrics.New(""),
+		recorder:   rec,
+		recorderQueue: fanout.NewQueue("recorder", fanout.Options[*types.VideoFrame]{
+			Class:    fanout.Reliable,
+			Capacity: queue,
+		}),
+	}
+	rec.SetRelease(s.releaseRecorderFrame)
+	if err := rec.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rec.Close() })
+	return s
+}
+
+func TestShutdownDrainsRecorderFrames(t *testing.T) {
+	const frames = 2000
+	s := newPipelineServer(t, frames)
+
+	// A reader pushing as fast as it can until stopped, like readFrames
+	pushed := 0
+	s.readerWg.Add(1)
+	go func() {
+		defer s.readerWg.Done()
+		for n := 0; n < frames && s.readerCtx.Err() == nil; n++ {
+			frame := &types.VideoFrame{FrameNumber: uint64(n), IsIDR: n%30 == 0, Data: []byte{0, 0, 0, 1, 0x02, byte(n)}}
+			if !s.recorderQueue.Push(frame) {
+				t.Errorf("frame %d refused", n)
+				return
+			}
+			pushed++
+		}
+	}()
+	s.wg.Add(1)
+	go s.distributeRecorder()
+	time.Sleep(time.Millisecond) // shut down mid-burst
+
+	s.stopPipeline(time.Minute)
+	if err := s.recorder.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	st := s.recorder.GetStatus()
+	if st.FrameCount != uint64(pushed) || s.metrics.RecorderFramesDropped.Load() != 0 {
+		t.Errorf("read %d frames, recorded %d, dropped %d", pushed, st.FrameCount, s.metrics.RecorderFramesDropped.Load())
+	}
+	if s.ctx.Err() == nil {
+		t.Error("server context still live after stopPipeline")
+	}
+}
+
+func TestShutdownDrainDeadline(t *testing.T) {
+	s := newPipelineServer(t, 10)
+	for n := 0; n < 5; n++ {
+		s.recorderQueue.Push(&types.VideoFrame{IsIDR: true, Data: []byte{0, 0, 0, 1, 0x26}})
+	}
+	// No distributor picks them up before the deadline: shutdown frees them
+	s.wg.Add(1)
+	start := time.Now()
+	go func() {
+		<-s.ctx.Done()
+		s.distributeRecorder()
+	}()
+	s.stopPipeline(20 * time.Millisecond)
+	if time.Since(start) > time.Second {
+		t.Errorf("stopPipeline took %v", time.Since(start))
+	}
+	if n := s.metrics.RecorderFramesDropped.Load(); n != 5 || s.recorderQueue.Len() != 0 {
+		t.Errorf("dropped %d, %d left queued", n, s.recorderQueue.Len())
+	}
+}
@@ -0,0 +1,107 @@
+package streamserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/fanout"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/types"
+)
+
+// TimingSample is the compact per-frame latency record streamed on
+// /api/webrtc/timing. Hops are milliseconds since the camera wrote the frame
+// to SHM; a client adds its own receive/render time for glass-to-glass.
+type TimingSample struct {
+	Frame       uint64  `json:"frame"`
+	CaptureMs   int64   `json:"capture_unix_ms"`
+	ReadMs      float64 `json:"read_ms"`
+	ProcessedMs float64 `json:"processed_ms"`
+	SentMs      float64 `json:"sent_ms"`
+}
+
+func newTimingSample(f *types.VideoFrame) TimingSample {
+	return TimingSample{
+		Frame:       f.FrameNumber,
+		CaptureMs:   f.Timestamp.UnixMilli(),
+		ReadMs:      hopMs(f.Timestamp, f.Timing.Read),
+		ProcessedMs: hopMs(f.Timestamp, f.Timing.Processed),
+		SentMs:      hopMs(f.Timestamp, f.Timing.Sent),
+	}
+}
+
+// hopMs returns to-from in milliseconds, or 0 if the stage was not reached.
+func hopMs(from, to time.Time) float64 {
+	if to.IsZero() {
+		return 0
+	}
+	return float64(to.Sub(from).Microseconds()) / 1000
+}
+
+// timingHub fans timing samples out to SSE subscribers through BestEffort
+// queues: slow subscribers miss samples rather than stall the sender.
+type timingHub struct {
+	mu   sync.Mutex
+	subs map[*fanout.Queue[TimingSample]]struct{}
+}
+
+func newTimingHub() *timingHub {
+	return &timingHub{subs: make(map[*fanout.Queue[TimingSample]]struct{})}
+}
+
+func (h *timingHub) subscribe() (*fanout.Queue[TimingSample], func()) {
+	q := fanout.NewQueue("timing", fanout.Options[TimingSample]{Class: fanout.BestEffort, Capacity: 8})
+	h.mu.Lock()
+	h.subs[q] = struct{}{}
+	h.mu.Unlock()
+	return q, func() {
+		h.mu.Lock()
+		delete(h.subs, q)
+		h.mu.Unlock()
+		q.Close()
+	}
+}
+
+func (h *timingHub) publish(s TimingSample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for q := range h.subs {
+		q.Push(s)
+	}
+}
+
+// handleTiming streams a TimingSample every Config.TimingSampleEvery frames
+// as Server-Sent Events.
+func (s *Server) handleTiming(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.TimingSampleEvery <= 0 {
+		http.Error(w, "Frame timing disabled", http.StatusNotFound)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	flusher.Flush()
+
+	samples, unsubscribe := s.timing.subscribe()
+	defer unsubscribe()
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	defer context.AfterFunc(s.ctx, cancel)()
+	for {
+		sample, ok := samples.Pop(ctx)
+		if !ok {
+			return
+		}
+		data, _ := json.Marshal(sample)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,45 @@
+package streamserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/types"
+)
+
+func TestTimingSample(t *testing.T) {
+	capture := time.Unix(1700000000, 0)
+	f := &types.VideoFrame{
+		FrameNumber: 90,
+		Timestamp:   capture,
+		Timing: types.FrameTiming{
+			Read:      capture.Add(2 * time.Millisecond),
+			Processed: capture.Add(2500 * time.Microsecond),
+		},
+	}
+	got := newTimingSample(f)
+	want := TimingSample{Frame: 90, CaptureMs: capture.UnixMilli(), ReadMs: 2, ProcessedMs: 2.5}
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestTimingHubDropsForSlowSubscriber(t *testing.T) {
+	h := newTimingHub()
+	q, unsubscribe := h.subscribe()
+	for i := 0; i < 20; i++ {
+		h.publish(TimingSample{Frame: uint64(i)})
+	}
+	if st := q.Stats(); st.Queued != st.Capacity || st.Dropped != 12 {
+		t.Fatalf("buffered %d samples, want %d (%+v)", st.Queued, st.Capacity, st)
+	}
+	if s, _ := q.TryPop(); s.Frame != 0 {
+		t.Fatalf("first sample frame %d, want 0", s.Frame)
+	}
+
+	unsubscribe()
+	h.publish(TimingSample{})
+	if len(h.subs) != 0 {
+		t.Fatal("subscriber not removed")
+	}
+}
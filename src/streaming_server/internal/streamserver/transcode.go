@@ -0,0 +1,122 @@
+package streamserver
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/metrics"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/rtppack"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/transcode"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/types"
+)
+
+// transcodeRetry is how long after a failed encoder start the next IDR may
+// try again.
+const transcodeRetry = 30 * time.Second
+
+// fallbackStream feeds the H.265 frames into a transcoder while at least
+// one session negotiated its codec, and sends the re-encoded frames to
+// those sessions. feed and close are called by the WebRTC sender only.
+type fallbackStream struct {
+	s     *Server
+	codec string
+	start func() (transcode.Transcoder, error)
+
+	tc         transcode.Transcoder
+	retryAfter time.Time
+	sendWg     sync.WaitGroup
+	stats      *metrics.Stream // profile series, present while tc runs
+
+	// owned by the goroutine draining tc.Frames()
+	ssrc      uint32
+	seq       uint16
+	pictureID uint16
+}
+
+// newFallbackStream returns nil when transcoding is disabled (cfg.Transcode
+// empty), otherwise a stream starting cfg.TranscodeCommand on demand.
+func newFallbackStream(s *Server) *fallbackStream {
+	codec := strings.ToUpper(s.cfg.Transcode)
+	if codec == "" {
+		return nil
+	}
+	return &fallbackStream{
+		s:     s,
+		codec: codec,
+		ssrc:  0x12345678,
+		start: func() (transcode.Transcoder, error) {
+			args, err := transcode.FFmpegArgs(codec, 30)
+			if err != nil {
+				return nil, err
+			}
+			return transcode.StartCommand(codec, s.cfg.TranscodeCommand, args...)
+		},
+	}
+}
+
+// feed passes frame to the transcoder, starting it on an IDR once a
+// fallback session is connected and stopping it when none is left.
+func (fs *fallbackStream) feed(frame *types.VideoFrame) {
+	if fs == nil {
+		return
+	}
+	if fs.s.signal.FallbackClients(fs.codec) == 0 {
+		fs.close()
+		return
+	}
+	if fs.tc == nil {
+		// The encoder must start on an IDR with its parameter sets
+		if !frame.IsIDR || time.Now().Before(fs.retryAfter) {
+			return
+		}
+		tc, err := fs.start()
+		if err != nil {
+			logger.Warn("Transcode", "Cannot start %s transcoder: %v", fs.codec, err)
+			fs.retryAfter = time.Now().Add(transcodeRetry)
+			return
+		}
+		fs.tc = tc
+		fs.stats = fs.s.metrics.Profile(fs.profile())
+		fs.sendWg.Add(1)
+		go fs.send(tc, fs.stats)
+	}
+	if !fs.tc.Encode(frame.Data) {
+		fs.stats.FramesDropped.Add(1)
+	}
+}
+
+// send packetizes the transcoder's output until it exits.
+func (fs *fallbackStream) send(tc transcode.Transcoder, stats *metrics.Stream) {
+	defer fs.sendWg.Done()
+	for f := range tc.Frames() {
+		ts := uint32(f.PTS * 90000 / time.Second)
+		var packets [][]byte
+		switch fs.codec {
+		case "VP9":
+			packets, fs.seq = rtppack.PacketizeVP9(f.Data, f.Key, fs.pictureID, fs.ssrc, fs.seq, ts, 1200)
+			fs.pictureID = (fs.pictureID + 1) & 0x7FFF
+		case "AV1":
+			packets, fs.seq = rtppack.PacketizeAV1(f.Data, fs.ssrc, fs.seq, ts, 1200)
+		}
+		fs.s.signal.SendFallbackFrame(fs.codec, packets)
+		stats.FramesSent.Add(1)
+	}
+}
+
+// close stops the transcoder, if running, and waits for its frames to be sent.
+func (fs *fallbackStream) close() {
+	if fs == nil || fs.tc == nil {
+		return
+	}
+	fs.tc.Close()
+	fs.sendWg.Wait()
+	fs.tc = nil
+	fs.s.metrics.RemoveProfile(fs.profile())
+}
+
+// profile is the metrics "profile" label of the fallback stream.
+func (fs *fallbackStream) profile() string {
+	return strings.ToLower(fs.codec)
+}
@@ -0,0 +1,330 @@
+package streamserver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/signal"
+)
+
+// WHEP (draft-ietf-wish-whep) lets standard players pull the stream:
+//
+//	POST   /whep               application/sdp offer → 201, answer, Location /whep/{id}
+//	DELETE /whep/{id}          ends the session
+//	POST   /whep/{id}/events   JSON array of event names → 201, Location of the stream
+//	GET    /whep/{id}/events   the Server-Sent Events stream (?events=a,b, default all)
+//
+// The server is ICE-lite and puts every candidate in the answer, so there is
+// no trickle ICE: PATCH is 405.
+
+// whepEventsRel is the link relation of the server-sent events extension.
+const whepEventsRel = "urn:ietf:params:whep:ext:core:server-sent-events"
+
+// WHEP events. active, inactive, reconnect and viewercount are the draft's;
+// ice and quality are this server's, with the session's ICE state and
+// connection quality (signal.SessionStats).
+const (
+	whepEventActive      = "active"
+	whepEventInactive    = "inactive"
+	whepEventReconnect   = "reconnect"
+	whepEventViewerCount = "viewercount"
+	whepEventICE         = "ice"
+	whepEventQuality     = "quality"
+)
+
+// whepEvents are the events a player can subscribe to, as advertised.
+var whepEvents = []string{whepEventActive, whepEventInactive, whepEventReconnect, whepEventViewerCount, whepEventICE, whepEventQuality}
+
+const (
+	// whepEventInterval is how often an event stream looks for changes.
+	whepEventInterval = time.Second
+	// whepQualityInterval is the longest gap between quality events while
+	// the grade stays the same.
+	whepQualityInterval = 5 * time.Second
+	// maxWHEPOfferBytes bounds a WHEP offer.
+	maxWHEPOfferBytes = 64 << 10
+)
+
+// whepResources maps the unguessable WHEP resource IDs handed to players to
+// their signal sessions, so a resource URL neither reveals nor outlives its
+// session (session IDs are reused with their ports).
+type whepResources struct {
+	mu       sync.Mutex
+	sessions map[string]string // resource ID → session ID
+}
+
+func newWHEPResources() *whepResources {
+	return &whepResources{sessions: make(map[string]string)}
+}
+
+func (wr *whepResources) add(sessionID string) string {
+	var b [12]byte
+	rand.Read(b[:])
+	id := hex.EncodeToString(b[:])
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	wr.sessions[id] = sessionID
+	return id
+}
+
+// session returns the live session of resource id, forgetting the resource
+// once its session is gone.
+func (wr *whepResources) session(sig *signal.Server, id string) (string, bool) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	sessionID, ok := wr.sessions[id]
+	if !ok {
+		return "", false
+	}
+	if _, live := sig.SessionStats(sessionID); !live {
+		delete(wr.sessions, id)
+		return "", false
+	}
+	return sessionID, true
+}
+
+func (wr *whepResources) remove(id string) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	delete(wr.sessions, id)
+}
+
+// prune forgets the resources of ended sessions.
+func (wr *whepResources) prune(sig *signal.Server) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	for id, sessionID := range wr.sessions {
+		if _, live := sig.SessionStats(sessionID); !live {
+			delete(wr.sessions, id)
+		}
+	}
+}
+
+// whepCORS allows browser players on other origins, which need the
+// Location and Link headers to find their resource and events.
+func whepCORS(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, PATCH, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match")
+	w.Header().Set("Access-Control-Expose-Headers", "Location, Link, ETag")
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Accept-Post", "application/sdp")
+		w.WriteHeader(http.StatusNoContent)
+		return true
+	}
+	return false
+}
+
+// handleWHEP serves POST /whep: the player's SDP offer creates a session.
+func (s *Server) handleWHEP(w http.ResponseWriter, r *http.Request) {
+	if whepCORS(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if ct := r.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/sdp") {
+		http.Error(w, "Content-Type must be application/sdp", http.StatusUnsupportedMediaType)
+		return
+	}
+	if s.refuseWhileDraining(w) {
+		return
+	}
+	offer, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWHEPOfferBytes))
+	if err != nil {
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	answer, sessionID, err := s.signal.HandleSDP(string(offer))
+	switch {
+	case errors.Is(err, signal.ErrMaxClients), errors.Is(err, signal.ErrClosed):
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		log.Printf("[WHEP] Offer error: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to handle offer: %v", err), http.StatusBadRequest)
+		return
+	}
+	s.metrics.TotalClients.Add(1)
+	s.whep.prune(s.signal)
+	id := s.whep.add(sessionID)
+	log.Printf("[WHEP] Session %s: resource %s", sessionID, id)
+
+	resource := "/whep/" + id
+	w.Header().Set("Location", resource)
+	w.Header().Set("ETag", `"`+id+`"`)
+	w.Header().Add("Link", fmt.Sprintf(`<%s/events>; rel="%s"; events="%s"`, resource, whepEventsRel, strings.Join(whepEvents, ",")))
+	w.Header().Set("Content-Type", "application/sdp")
+	w.WriteHeader(http.StatusCreated)
+	io.WriteString(w, answer)
+}
+
+// handleWHEPResource serves /whep/{id} and /whep/{id}/events.
+func (s *Server) handleWHEPResource(w http.ResponseWriter, r *http.Request) {
+	if whepCORS(w, r) {
+		return
+	}
+	id, sub, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/whep/"), "/")
+	sessionID, ok := s.whep.session(s.signal, id)
+	if !ok {
+		http.Error(w, "WHEP resource not found", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case sub == "" && r.Method == http.MethodDelete:
+		s.signal.CloseSession(sessionID)
+		s.whep.remove(id)
+		log.Printf("[WHEP] Session %s: deleted by the player", sessionID)
+		w.WriteHeader(http.StatusOK)
+	case sub == "" && r.Method == http.MethodPatch:
+		// ICE-lite with every candidate in the answer: nothing to trickle
+		http.Error(w, "Trickle ICE not supported", http.StatusMethodNotAllowed)
+	case sub == "events" && r.Method == http.MethodPost:
+		s.subscribeWHEPEvents(w, r, id)
+	case sub == "events" && r.Method == http.MethodGet:
+		want := whepEvents
+		if q := r.URL.Query().Get("events"); q != "" {
+			want = strings.Split(q, ",")
+		}
+		s.streamWHEPEvents(w, r, sessionID, want)
+	case sub == "" || sub == "events":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// subscribeWHEPEvents answers the events extension's POST, a JSON array of
+// event names, with the URL of a stream of those it knows.
+func (s *Server) subscribeWHEPEvents(w http.ResponseWriter, r *http.Request, id string) {
+	var names []string
+	if err := json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&names); err != nil {
+		http.Error(w, "Body must be a JSON array of event names", http.StatusBadRequest)
+		return
+	}
+	var known []string
+	for _, name := range names {
+		if slices.Contains(whepEvents, name) && !slices.Contains(known, name) {
+			known = append(known, name)
+		}
+	}
+	if len(known) == 0 {
+		http.Error(w, "No supported events requested", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/whep/%s/events?events=%s", id, strings.Join(known, ",")))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// whepEventState is what an event stream last told the player.
+type whepEventState struct {
+	ice         string
+	active      *bool
+	viewers     int // -1 before the first viewercount
+	reconnect   bool
+	quality     string
+	qualitySent time.Time
+}
+
+// whepEvent is one event to send.
+type whepEvent struct {
+	name string
+	data any
+}
+
+// next compares the session's stats, the viewer count and the drain state
+// with what was sent and returns the events that changed, in want.
+func (st *whepEventState) next(want []string, stats signal.SessionStats, live, camera bool, viewers int, draining bool, reconnectAfter time.Duration, now time.Time) []whepEvent {
+	var out []whepEvent
+	send := func(name string, data any) {
+		if slices.Contains(want, name) {
+			out = append(out, whepEvent{name, data})
+		}
+	}
+
+	ice := stats.ICE
+	if !live {
+		ice = "closed"
+	}
+	if ice != st.ice {
+		st.ice = ice
+		send(whepEventICE, map[string]any{"state": ice, "remote": stats.Remote, "address_family": stats.Family})
+	}
+
+	active := live && stats.Connected && camera
+	if st.active == nil || *st.active != active {
+		st.active = &active
+		if active {
+			send(whepEventActive, map[string]any{})
+		} else if live && stats.Connected {
+			send(whepEventInactive, map[string]any{})
+		}
+	}
+
+	if viewers != st.viewers {
+		st.viewers = viewers
+		send(whepEventViewerCount, map[string]any{"viewercount": viewers})
+	}
+
+	if draining && !st.reconnect {
+		st.reconnect = true
+		send(whepEventReconnect, map[string]any{"url": "/whep", "delay": reconnectAfter.Seconds()})
+	}
+
+	if live && stats.Connected && (stats.Quality != st.quality || now.Sub(st.qualitySent) >= whepQualityInterval) {
+		st.quality, st.qualitySent = stats.Quality, now
+		send(whepEventQuality, stats)
+	}
+	return out
+}
+
+// streamWHEPEvents sends the wanted events of sessionID as Server-Sent
+// Events until the session ends or the player goes away.
+func (s *Server) streamWHEPEvents(w http.ResponseWriter, r *http.Request, sessionID string, want []string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	flusher.Flush()
+
+	st := whepEventState{viewers: -1}
+	ticker := time.NewTicker(whepEventInterval)
+	defer ticker.Stop()
+	for {
+		stats, live := s.signal.SessionStats(sessionID)
+		draining, reconnectAfter := s.drain.status()
+		for _, ev := range st.next(want, stats, live, s.cameraAttached.Load(), s.signal.GetClientCount(), draining, reconnectAfter, time.Now()) {
+			data, _ := json.Marshal(ev.data)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.name, data)
+		}
+		flusher.Flush()
+		if !live {
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package streamserver
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/metrics"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/signal"
+)
+
+const whepTestOffer = "v=0\r\no=- 1 1 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\nm=video 9 UDP/TLS/RTP/SAVPF 96\r\n" +
+	"a=mid:0\r\na=ice-ufrag:abcd\r\na=ice-pwd:0123456789012345678901\r\n" +
+	"a=fingerprint:sha-256 AA:BB\r\na=setup:active\r\na=recvonly\r\na=rtpmap:96 H265/90000\r\n"
+
+func TestWHEP(t *testing.T) {
+	sig, err := signal.NewServer(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sig.Close()
+	s := &Server{ctx: context.Background(), cfg: DefaultConfig(), signal: sig, metrics: metrics.New(""), drain: newDrainState(), whep: newWHEPResources()}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/whep", s.handleWHEP)
+	mux.HandleFunc("/whep/", s.handleWHEPResource)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	do := func(method, path, contentType, body string) *http.Response {
+		req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	if resp := do("OPTIONS", "/whep", "", ""); resp.StatusCode != 204 || !strings.Contains(resp.Header.Get("Access-Control-Expose-Headers"), "Location") {
+		t.Errorf("OPTIONS: %d %v", resp.StatusCode, resp.Header)
+	}
+	if resp := do("POST", "/whep", "application/json", `{}`); resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("JSON offer: %d", resp.StatusCode)
+	}
+	resp := do("POST", "/whep", "application/sdp", whepTestOffer)
+	location, link := resp.Header.Get("Location"), resp.Header.Get("Link")
+	if resp.StatusCode != 201 || !strings.HasPrefix(location, "/whep/") || resp.Header.Get("Content-Type") != "application/sdp" {
+		t.Fatalf("offer: %d %v", resp.StatusCode, resp.Header)
+	}
+	if !strings.Contains(link, location+"/events") || !strings.Contains(link, whepEventsRel) {
+		t.Errorf("Link %q", link)
+	}
+	if resp := do("POST", "/whep", "application/sdp", whepTestOffer); resp.StatusCode != 503 {
+		t.Errorf("offer beyond max clients: %d", resp.StatusCode)
+	}
+	if resp := do("PATCH", location, "application/trickle-ice-sdpfrag", "a=end-of-candidates"); resp.StatusCode != 405 {
+		t.Errorf("PATCH: %d", resp.StatusCode)
+	}
+
+	resp = do("POST", location+"/events", "application/json", `["ice", "layers", "viewercount", "ice"]`)
+	events := resp.Header.Get("Location")
+	if resp.StatusCode != 201 || events != location+"/events?events=ice,viewercount" {
+		t.Fatalf("subscribe: %d %q", resp.StatusCode, events)
+	}
+	if resp := do("POST", location+"/events", "application/json", `["scte35"]`); resp.StatusCode != 400 {
+		t.Errorf("subscribe to unknown events only: %d", resp.StatusCode)
+	}
+
+	// The stream starts with the current state and ends with the session
+	stream := do("GET", events, "", "")
+	got := make(chan string, 10)
+	go func() {
+		sc := bufio.NewScanner(stream.Body)
+		for sc.Scan() {
+			if line := sc.Text(); line != "" {
+				got <- line
+			}
+		}
+		close(got)
+	}()
+	var lines []string
+	for len(lines) < 4 {
+		select {
+		case line := <-got:
+			lines = append(lines, line)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("events so far: %q", lines)
+		}
+	}
+	if lines[0] != "event: ice" || !strings.Contains(lines[1], `"state":"checking"`) ||
+		lines[2] != "event: viewercount" || lines[3] != `data: {"viewercount":0}` {
+		t.Errorf("first events %q", lines)
+	}
+
+	if resp := do("DELETE", location, "", ""); resp.StatusCode != 200 || sig.GetClientCount() != 0 {
+		t.Fatalf("DELETE: %d, %d clients", resp.StatusCode, sig.GetClientCount())
+	}
+	for line := range got {
+		lines = append(lines, line)
+	}
+	if !slices.Contains(lines, `data: {"address_family":"","remote":"","state":"closed"}`) {
+		t.Errorf("no closed event: %q", lines)
+	}
+	if resp := do("DELETE", location, "", ""); resp.StatusCode != 404 {
+		t.Errorf("second DELETE: %d", resp.StatusCode)
+	}
+}
+
+func TestWHEPEventState(t *testing.T) {
+	all := whepEvents
+	now := time.Now()
+	names := func(evs []whepEvent) []string {
+		var out []string
+		for _, ev := range evs {
+			out = append(out, ev.name)
+		}
+		return out
+	}
+	st := whepEventState{viewers: -1}
+	connected := signal.SessionStats{ICE: "connected", Connected: true, Quality: "good"}
+
+	if got := names(st.next(all, signal.SessionStats{ICE: "checking"}, true, true, 1, false, 0, now)); !slices.Equal(got, []string{"ice", "viewercount"}) {
+		t.Errorf("checking: %v", got)
+	}
+	if got := names(st.next(all, connected, true, true, 1, false, 0, now)); !slices.Equal(got, []string{"ice", "active", "quality"}) {
+		t.Errorf("connected: %v", got)
+	}
+	if got := names(st.next(all, connected, true, true, 1, false, 0, now.Add(time.Second))); len(got) != 0 {
+		t.Errorf("unchanged: %v", got)
+	}
+	if got := names(st.next(all, connected, true, false, 2, false, 0, now.Add(2*time.Second))); !slices.Equal(got, []string{"inactive", "viewercount"}) {
+		t.Errorf("camera gone: %v", got)
+	}
+	poor := connected
+	poor.Quality = "poor"
+	if got := names(st.next(all, poor, true, false, 2, true, 10*time.Second, now.Add(3*time.Second))); !slices.Equal(got, []string{"reconnect", "quality"}) {
+		t.Errorf("draining, poor: %v", got)
+	}
+	if got := names(st.next(all, poor, true, false, 2, true, 10*time.Second, now.Add(3*time.Second+whepQualityInterval))); !slices.Equal(got, []string{"quality"}) {
+		t.Errorf("periodic quality: %v", got)
+	}
+	if got := names(st.next([]string{"quality"}, signal.SessionStats{}, false, false, 1, true, 0, now.Add(time.Minute))); len(got) != 0 {
+		t.Errorf("closed, quality only: %v", got)
+	}
+}
@@ -0,0 +1,308 @@
+// Package supervisor runs the camera stack's helper processes (capture
+// daemon, detector, streaming server) as children of one Go process,
+// restarting them with backoff when they exit and folding their output into
+// the structured logger.
+package supervisor
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+// Spec describes one supervised child.
+type Spec struct {
+	Name    string   // log module and status key, e.g. "capture"
+	Command string   // run via /bin/sh -c "exec <Command>", like the systemd units
+	Dir     string   // working directory ("" = inherit)
+	Env     []string // extra KEY=VALUE entries on top of the parent environment
+}
+
+// ChildStatus is a point-in-time view of one child.
+type ChildStatus struct {
+	Name      string  `json:"name"`
+	Running   bool    `json:"running"`
+	Healthy   bool    `json:"healthy"` // running for at least HealthyAfter
+	PID       int     `json:"pid,omitempty"`
+	Restarts  int     `json:"restarts"`
+	UptimeSec float64 `json:"uptime_sec"`
+	LastExit  string  `json:"last_exit,omitempty"`
+	NextStart float64 `json:"next_start,omitempty"` // unix seconds of the pending restart
+}
+
+// Supervisor starts children, restarts them when they exit and stops them
+// together. Backoff doubles from MinBackoff to MaxBackoff and resets once a
+// child has stayed up for StableAfter.
+type Supervisor struct {
+	// Configurable parameters
+	MinBackoff   time.Duration
+	MaxBackoff   time.Duration
+	StableAfter  time.Duration // uptime that resets the backoff
+	HealthyAfter time.Duration // uptime before a child counts as healthy
+	StopTimeout  time.Duration // SIGTERM grace period before SIGKILL
+
+	mu       sync.Mutex
+	children []*child
+	stop     chan struct{}
+	stopped  bool
+	wg       sync.WaitGroup
+}
+
+type child struct {
+	spec Spec
+
+	mu        sync.Mutex
+	cmd       *exec.Cmd
+	startedAt time.Time
+	restarts  int
+	lastExit  string
+	nextStart time.Time
+}
+
+// New creates a supervisor with default backoff settings.
+func New() *Supervisor {
+	return &Supervisor{
+		MinBackoff:   1 * time.Second,
+		MaxBackoff:   30 * time.Second,
+		StableAfter:  60 * time.Second,
+		HealthyAfter: 3 * time.Second,
+		StopTimeout:  5 * time.Second,
+		stop:         make(chan struct{}),
+	}
+}
+
+// Add registers a child. Must be called before Start.
+func (s *Supervisor) Add(spec Spec) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.children = append(s.children, &child{spec: spec})
+}
+
+// Start launches every registered child.
+func (s *Supervisor) Start() {
+	s.mu.Lock()
+	children := s.children
+	s.mu.Unlock()
+	for _, c := range children {
+		s.wg.Add(1)
+		go s.run(c)
+	}
+}
+
+// Stop terminates all children (SIGTERM, then SIGKILL after StopTimeout)
+// and waits for their supervision loops to exit.
+func (s *Supervisor) Stop() {
+	s.mu.Lock()
+	if !s.stopped {
+		close(s.stop)
+		s.stopped = true
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Status returns every child's state in registration order.
+func (s *Supervisor) Status() []ChildStatus {
+	s.mu.Lock()
+	children := s.children
+	s.mu.Unlock()
+
+	now := time.Now()
+	out := make([]ChildStatus, 0, len(children))
+	for _, c := range children {
+		c.mu.Lock()
+		st := ChildStatus{
+			Name:     c.spec.Name,
+			Restarts: c.restarts,
+			LastExit: c.lastExit,
+		}
+		if c.cmd != nil && c.cmd.Process != nil {
+			st.Running = true
+			st.PID = c.cmd.Process.Pid
+			up := now.Sub(c.startedAt)
+			st.UptimeSec = up.Seconds()
+			st.Healthy = up >= s.HealthyAfter
+		} else if !c.nextStart.IsZero() {
+			st.NextStart = float64(c.nextStart.UnixNano()) / 1e9
+		}
+		c.mu.Unlock()
+		out = append(out, st)
+	}
+	return out
+}
+
+// Healthy reports whether every child is up past HealthyAfter.
+func (s *Supervisor) Healthy() bool {
+	for _, st := range s.Status() {
+		if !st.Healthy {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Supervisor) run(c *child) {
+	defer s.wg.Done()
+	backoff := s.MinBackoff
+
+	for {
+		started := time.Now()
+		err := s.runOnce(c)
+		ranFor := time.Since(started)
+
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+
+		if ranFor >= s.StableAfter {
+			backoff = s.MinBackoff
+		}
+		c.mu.Lock()
+		c.restarts++
+		c.nextStart = time.Now().Add(backoff)
+		c.mu.Unlock()
+		logger.Warn("Supervisor", "%s exited after %v (%v), restarting in %v",
+			c.spec.Name, ranFor.Round(time.Millisecond), err, backoff)
+
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > s.MaxBackoff {
+			backoff = s.MaxBackoff
+		}
+	}
+}
+
+// runOnce starts the child and blocks until it exits or the supervisor stops.
+func (s *Supervisor) runOnce(c *child) error {
+	cmd := exec.Command("/bin/sh", "-c", "exec "+c.spec.Command)
+	cmd.Dir = c.spec.Dir
+	cmd.Env = append(os.Environ(), c.spec.Env...)
+	// Own process group so a terminal Ctrl-C reaches only the supervisor,
+	// which then stops children in order.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		s.recordExit(c, err)
+		return err
+	}
+
+	c.mu.Lock()
+	c.cmd = cmd
+	c.startedAt = time.Now()
+	c.nextStart = time.Time{}
+	c.mu.Unlock()
+	logger.Info("Supervisor", "Started %s (pid %d): %s", c.spec.Name, cmd.Process.Pid, c.spec.Command)
+
+	var logs sync.WaitGroup
+	logs.Add(2)
+	go func() { defer logs.Done(); forwardLog(c.spec.Name, stdout) }()
+	go func() { defer logs.Done(); forwardLog(c.spec.Name, stderr) }()
+
+	exited := make(chan error, 1)
+	go func() {
+		logs.Wait() // pipes must be drained before Wait closes them
+		exited <- cmd.Wait()
+	}()
+
+	select {
+	case err = <-exited:
+	case <-s.stop:
+		err = s.terminate(c.spec.Name, cmd, exited)
+	}
+	s.recordExit(c, err)
+	return err
+}
+
+// terminate sends SIGTERM to the child's process group and escalates to
+// SIGKILL if it has not exited within StopTimeout.
+func (s *Supervisor) terminate(name string, cmd *exec.Cmd, exited <-chan error) error {
+	pgid := -cmd.Process.Pid
+	syscall.Kill(pgid, syscall.SIGTERM)
+	select {
+	case err := <-exited:
+		logger.Info("Supervisor", "Stopped %s", name)
+		return err
+	case <-time.After(s.StopTimeout):
+		logger.Warn("Supervisor", "%s did not exit within %v, killing", name, s.StopTimeout)
+		syscall.Kill(pgid, syscall.SIGKILL)
+		return <-exited
+	}
+}
+
+func (s *Supervisor) recordExit(c *child, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cmd = nil
+	if err == nil {
+		c.lastExit = "exit status 0"
+	} else {
+		c.lastExit = err.Error()
+	}
+}
+
+// forwardLog re-logs each child output line under the child's name, keeping
+// the child's own severity when the line carries a recognizable level tag.
+func forwardLog(name string, r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		switch lineLevel(line) {
+		case logger.DEBUG:
+			logger.Debug(name, "%s", line)
+		case logger.WARN:
+			logger.Warn(name, "%s", line)
+		case logger.ERROR:
+			logger.Error(name, "%s", line)
+		default:
+			logger.Info(name, "%s", line)
+		}
+	}
+}
+
+// lineLevel guesses a child line's severity from the upper-case level tag
+// near its start, as written by the C daemons ("[ERROR]"), Python logging
+// ("ERROR:root", " - WARNING - ") and our own logger ("[WARN]").
+func lineLevel(line string) logger.LogLevel {
+	words := strings.FieldsFunc(line, func(r rune) bool { return r < 'A' || r > 'Z' })
+	if len(words) > 3 {
+		words = words[:3]
+	}
+	for _, w := range words {
+		switch w {
+		case "ERROR", "FATAL", "CRITICAL":
+			return logger.ERROR
+		case "WARN", "WARNING":
+			return logger.WARN
+		case "DEBUG":
+			return logger.DEBUG
+		case "INFO":
+			return logger.INFO
+		}
+	}
+	return logger.INFO
+}
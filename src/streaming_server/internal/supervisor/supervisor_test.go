@@ -0,0 +1,60 @@
+package supervisor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+func TestSupervisor_RestartAndStop(t *testing.T) {
+	s := New()
+	s.MinBackoff = 10 * time.Millisecond
+	s.MaxBackoff = 20 * time.Millisecond
+	s.HealthyAfter = 0
+	s.StopTimeout = time.Second
+	s.Add(Spec{Name: "crasher", Command: "sh -c 'echo [ERROR] boom; exit 3'"})
+	s.Add(Spec{Name: "sleeper", Command: "sleep 30"})
+	s.Start()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		st := s.Status()
+		if st[0].Restarts >= 3 && st[1].Running {
+			if st[0].LastExit != "exit status 3" {
+				t.Fatalf("last exit = %q", st[0].LastExit)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("status = %+v", st)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	start := time.Now()
+	s.Stop()
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("stop took %v", d)
+	}
+	for _, st := range s.Status() {
+		if st.Running {
+			t.Fatalf("%s still running after Stop", st.Name)
+		}
+	}
+}
+
+func TestLineLevel(t *testing.T) {
+	cases := map[string]logger.LogLevel{
+		"[ERROR] [Pipeline] encoder failed":            logger.ERROR,
+		"2026-01-01 10:00:00 - detector - WARNING - x": logger.WARN,
+		"INFO:root:loaded model":                       logger.INFO,
+		"DEBUG: frame 12":                              logger.DEBUG,
+		"plain line":                                   logger.INFO,
+	}
+	for line, want := range cases {
+		if got := lineLevel(line); got != want {
+			t.Errorf("lineLevel(%q) = %v, want %v", line, got, want)
+		}
+	}
+}
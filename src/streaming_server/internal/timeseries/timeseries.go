@@ -0,0 +1,208 @@
+// Package timeseries keeps downsampled metric history on the device, for
+// installations without Prometheus. Each series is a set of fixed-size rings
+// of buckets at increasing widths (by default 10s for an hour, 1m for a day,
+// 15m for 30 days); a sample is added to the current bucket of every ring,
+// so memory is constant and old data fades to coarser resolution instead of
+// being kept raw.
+package timeseries
+
+import (
+	"cmp"
+	"encoding/gob"
+	"errors"
+	"io/fs"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Tier is one ring: Len buckets of width Step.
+type Tier struct {
+	Step time.Duration
+	Len  int
+}
+
+// DefaultTiers cover 1 hour at 10s, 24 hours at 1m and 30 days at 15m
+// (4680 buckets per series).
+var DefaultTiers = []Tier{
+	{Step: 10 * time.Second, Len: 360},
+	{Step: time.Minute, Len: 1440},
+	{Step: 15 * time.Minute, Len: 2880},
+}
+
+// Point is one bucket of a query result.
+type Point struct {
+	T   int64   `json:"t"` // bucket start, Unix seconds
+	Avg float64 `json:"avg"`
+	Max float64 `json:"max"`
+}
+
+// bucket accumulates the samples of one Step-wide interval.
+type bucket struct {
+	Start int64 // Unix seconds; 0 = empty
+	Sum   float64
+	Max   float64
+	N     uint32
+}
+
+// ring holds one tier of a series; bucket i covers the interval whose
+// index (start / step) is i modulo Len.
+type ring struct {
+	Step    int64 // seconds
+	Buckets []bucket
+}
+
+func newRing(t Tier) *ring {
+	return &ring{Step: int64(t.Step / time.Second), Buckets: make([]bucket, t.Len)}
+}
+
+func (r *ring) add(t int64, v float64) {
+	start := t - t%r.Step
+	b := &r.Buckets[int(start/r.Step)%len(r.Buckets)]
+	if b.Start != start {
+		*b = bucket{Start: start, Max: v}
+	}
+	b.Sum += v
+	b.Max = max(b.Max, v)
+	b.N++
+}
+
+// span returns how far back the ring reaches.
+func (r *ring) span() int64 {
+	return r.Step * int64(len(r.Buckets))
+}
+
+// Store is a set of named series. It is safe for concurrent use.
+type Store struct {
+	tiers []Tier
+
+	mu     sync.RWMutex
+	series map[string][]*ring
+}
+
+// New creates an empty store with the given tiers, finest first
+// (DefaultTiers if none). Steps must be whole seconds.
+func New(tiers ...Tier) *Store {
+	if len(tiers) == 0 {
+		tiers = DefaultTiers
+	}
+	return &Store{tiers: tiers, series: make(map[string][]*ring)}
+}
+
+// Add records v for series name at t.
+func (s *Store) Add(name string, t time.Time, v float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rings := s.series[name]
+	if rings == nil {
+		for _, t := range s.tiers {
+			rings = append(rings, newRing(t))
+		}
+		s.series[name] = rings
+	}
+	for _, r := range rings {
+		r.add(t.Unix(), v)
+	}
+}
+
+// Names returns the series names, sorted.
+func (s *Store) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make([]string, 0, len(s.series))
+	for name := range s.series {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Query returns the buckets of series name starting in [from, to], oldest
+// first, from the finest tier reaching back to from (the coarsest if none
+// does), and that tier's step. ok is false for an unknown series.
+func (s *Store) Query(name string, from, to time.Time) (points []Point, step time.Duration, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rings := s.series[name]
+	if rings == nil {
+		return nil, 0, false
+	}
+	r := rings[len(rings)-1]
+	for _, c := range rings {
+		if to.Unix()-c.span() <= from.Unix() {
+			r = c
+			break
+		}
+	}
+	points = []Point{}
+	for _, b := range r.Buckets {
+		if b.N == 0 || b.Start+r.Step <= from.Unix() || b.Start > to.Unix() {
+			continue
+		}
+		points = append(points, Point{T: b.Start, Avg: b.Sum / float64(b.N), Max: b.Max})
+	}
+	slices.SortFunc(points, func(a, b Point) int { return cmp.Compare(a.T, b.T) })
+	return points, time.Duration(r.Step) * time.Second, true
+}
+
+// Save writes the store to a gob file atomically (temp + rename).
+func (s *Store) Save(path string) error {
+	s.mu.RLock()
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		s.mu.RUnlock()
+		return err
+	}
+	err = gob.NewEncoder(f).Encode(s.series)
+	s.mu.RUnlock()
+	if err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load reads a store saved by Save. A missing file is not an error; series
+// saved with different tiers are dropped.
+func (s *Store) Load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	var saved map[string][]*ring
+	if err := gob.NewDecoder(f).Decode(&saved); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, rings := range saved {
+		if s.matchesTiers(rings) {
+			s.series[name] = rings
+		}
+	}
+	return nil
+}
+
+func (s *Store) matchesTiers(rings []*ring) bool {
+	if len(rings) != len(s.tiers) {
+		return false
+	}
+	for i, t := range s.tiers {
+		if rings[i] == nil || rings[i].Step != int64(t.Step/time.Second) || len(rings[i].Buckets) != t.Len {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package timeseries
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStoreTiers(t *testing.T) {
+	s := New(Tier{Step: 10 * time.Second, Len: 6}, Tier{Step: time.Minute, Len: 10})
+	base := time.Unix(1_800_000_000, 0) // a multiple of 60s
+	// Two minutes of 10s samples: 0..11
+	for i := range 12 {
+		s.Add("fps", base.Add(time.Duration(i)*10*time.Second), float64(i))
+	}
+
+	// The last minute fits the 10s ring; older buckets were overwritten
+	pts, step, ok := s.Query("fps", base.Add(60*time.Second), base.Add(119*time.Second))
+	if !ok || step != 10*time.Second || len(pts) != 6 || pts[0].Avg != 6 || pts[5].Avg != 11 {
+		t.Fatalf("fine query: %v %v %+v", ok, step, pts)
+	}
+
+	// Two minutes need the 1m ring: averages of 0..5 and 6..11
+	pts, step, _ = s.Query("fps", base, base.Add(119*time.Second))
+	want := []Point{{T: base.Unix(), Avg: 2.5, Max: 5}, {T: base.Unix() + 60, Avg: 8.5, Max: 11}}
+	if step != time.Minute || !reflect.DeepEqual(pts, want) {
+		t.Fatalf("coarse query: %v %+v", step, pts)
+	}
+
+	if _, _, ok := s.Query("cpu", base, base); ok {
+		t.Fatal("unknown series found")
+	}
+	if got := s.Names(); !reflect.DeepEqual(got, []string{"fps"}) {
+		t.Fatalf("names %v", got)
+	}
+}
+
+func TestStoreSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ts.gob")
+	now := time.Unix(1_800_000_000, 0)
+	s := New()
+	s.Add("cpu_percent", now, 42)
+	if err := s.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := New()
+	if err := loaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if pts, _, _ := loaded.Query("cpu_percent", now.Add(-time.Minute), now); len(pts) != 1 || pts[0].Avg != 42 {
+		t.Fatalf("reloaded %+v", pts)
+	}
+
+	// Different tiers: the saved series is dropped, not misread
+	other := New(Tier{Step: time.Second, Len: 10})
+	if err := other.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if len(other.Names()) != 0 {
+		t.Fatalf("series with other tiers loaded: %v", other.Names())
+	}
+	if err := New().Load(filepath.Join(t.TempDir(), "missing.gob")); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,266 @@
+// Package transcode re-encodes the camera's H.265 stream for WebRTC viewers
+// whose browser cannot decode it (e.g. Android WebViews with only VP9/AV1
+// in hardware). A Transcoder is started by the streaming server only while
+// such a viewer is connected, since software encoding costs a lot of CPU.
+package transcode
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/rtppack"
+)
+
+// Frame is one encoded frame: a VP9 frame/superframe or an AV1 temporal unit.
+type Frame struct {
+	Data []byte
+	Key  bool
+	PTS  time.Duration
+}
+
+// Transcoder is a pluggable encoder (external process, cgo library or
+// board codec) fed H.265 access units and producing frames of Codec.
+type Transcoder interface {
+	// Codec is the rtpmap encoding name produced ("VP9" or "AV1").
+	Codec() string
+	// Encode queues one H.265 access unit (Annex B, parameter sets before
+	// each IDR). It must not block; it reports false if the unit was
+	// dropped. au is only valid during the call.
+	Encode(au []byte) bool
+	// Frames delivers encoded frames; closed when the transcoder exits.
+	Frames() <-chan Frame
+	// Close stops the encoder and releases its resources.
+	Close() error
+}
+
+// FFmpegArgs returns the ffmpeg arguments for a low-latency software
+// transcode of H.265 Annex B on stdin to IVF on stdout. Key frames follow
+// the camera's IDRs so a new viewer waits at most one camera GOP.
+func FFmpegArgs(codec string, fps int) ([]string, error) {
+	args := []string{
+		"-hide_banner", "-loglevel", "error",
+		"-fflags", "nobuffer", "-flags", "low_delay",
+		"-f", "hevc", "-framerate", fmt.Sprint(fps), "-i", "pipe:0",
+		"-an", "-force_key_frames", "source", "-b:v", "1M",
+	}
+	switch codec {
+	case "VP9":
+		args = append(args, "-c:v", "libvpx-vp9", "-deadline", "realtime", "-cpu-used", "8", "-row-mt", "1", "-lag-in-frames", "0")
+	case "AV1":
+		args = append(args, "-c:v", "libaom-av1", "-usage", "realtime", "-cpu-used", "10", "-row-mt", "1", "-lag-in-frames", "0")
+	default:
+		return nil, fmt.Errorf("transcode: unsupported codec %q (want VP9 or AV1)", codec)
+	}
+	return append(args, "-f", "ivf", "pipe:1"), nil
+}
+
+// Command is a Transcoder running an external encoder process that reads
+// H.265 Annex B on stdin and writes IVF on stdout, such as ffmpeg with
+// FFmpegArgs or a wrapper around the board's codec.
+type Command struct {
+	codec string
+	cmd   *exec.Cmd
+	in    chan []byte
+	out   chan Frame
+	done  chan struct{} // closed when the process has exited
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// commandQueue bounds the access units waiting for the encoder's stdin
+// (~130 ms at 30 fps); beyond that the encoder is too slow and units drop.
+const commandQueue = 4
+
+// StartCommand starts name with args, encoding to codec.
+func StartCommand(codec, name string, args ...string) (*Command, error) {
+	cmd := exec.Command(name, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr := &tailWriter{}
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("transcode: start %s: %w", name, err)
+	}
+	c := &Command{
+		codec: codec,
+		cmd:   cmd,
+		in:    make(chan []byte, commandQueue),
+		out:   make(chan Frame, commandQueue),
+		done:  make(chan struct{}),
+	}
+	logger.Info("Transcode", "Started %s encoder (pid %d)", codec, cmd.Process.Pid)
+
+	go func() {
+		defer stdin.Close()
+		for au := range c.in {
+			if _, err := stdin.Write(au); err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		defer close(c.out)
+		err := readIVF(stdout, func(f Frame) {
+			select {
+			case c.out <- f:
+			default: // sender busy; the next key frame resyncs viewers
+			}
+		})
+		if waitErr := cmd.Wait(); err == nil {
+			err = waitErr
+		}
+		c.mu.Lock()
+		closed := c.closed
+		c.mu.Unlock()
+		if !closed {
+			logger.Warn("Transcode", "%s encoder exited: %v (%s)", codec, err, stderr.String())
+		}
+		close(c.done)
+	}()
+	return c, nil
+}
+
+// Codec returns the encoding name produced.
+func (c *Command) Codec() string { return c.codec }
+
+// Frames returns the encoded frames.
+func (c *Command) Frames() <-chan Frame { return c.out }
+
+// Encode queues a copy of au for the encoder.
+func (c *Command) Encode(au []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return false
+	}
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.in <- append([]byte(nil), au...):
+		return true
+	default:
+		return false
+	}
+}
+
+// closeTimeout is how long Close waits for the encoder to flush and exit
+// after its stdin closes before killing it.
+const closeTimeout = 2 * time.Second
+
+// Close ends the encoder's input and waits for it to exit, killing it
+// after closeTimeout.
+func (c *Command) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		<-c.done
+		return nil
+	}
+	c.closed = true
+	close(c.in)
+	c.mu.Unlock()
+
+	select {
+	case <-c.done:
+	case <-time.After(closeTimeout):
+		c.cmd.Process.Kill()
+		<-c.done
+	}
+	logger.Info("Transcode", "Stopped %s encoder", c.codec)
+	return nil
+}
+
+// IVF container sizes; ivfMaxFrame rejects a corrupt frame size field.
+const (
+	ivfHeaderSize      = 32
+	ivfFrameHeaderSize = 12
+	ivfMaxFrame        = 8 << 20
+)
+
+// readIVF parses an IVF stream, calling fn for every frame, until EOF.
+func readIVF(r io.Reader, fn func(Frame)) error {
+	hdr := make([]byte, ivfHeaderSize)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return fmt.Errorf("ivf header: %w", err)
+	}
+	if string(hdr[:4]) != "DKIF" {
+		return errors.New("ivf header: bad signature")
+	}
+	fourcc := string(hdr[8:12])
+	den := binary.LittleEndian.Uint32(hdr[16:20])
+	num := binary.LittleEndian.Uint32(hdr[20:24])
+	if den == 0 || num == 0 {
+		den, num = 1000, 1
+	}
+
+	fh := make([]byte, ivfFrameHeaderSize)
+	for {
+		if _, err := io.ReadFull(r, fh); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("ivf frame header: %w", err)
+		}
+		size := binary.LittleEndian.Uint32(fh[:4])
+		if size > ivfMaxFrame {
+			return fmt.Errorf("ivf frame: %d bytes", size)
+		}
+		pts := binary.LittleEndian.Uint64(fh[4:12])
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return fmt.Errorf("ivf frame: %w", err)
+		}
+		f := Frame{
+			Data: data,
+			PTS:  time.Duration(pts) * time.Second * time.Duration(num) / time.Duration(den),
+		}
+		switch fourcc {
+		case "VP90":
+			f.Key = rtppack.VP9Keyframe(data)
+		case "AV01":
+			f.Key = rtppack.AV1Keyframe(data)
+		}
+		fn(f)
+	}
+}
+
+// tailWriter keeps the last line written (the encoder's error message).
+type tailWriter struct {
+	mu   sync.Mutex
+	last string
+}
+
+func (t *tailWriter) Write(p []byte) (int, error) {
+	if line := strings.TrimSpace(string(p)); line != "" {
+		if i := strings.LastIndexByte(line, '\n'); i >= 0 {
+			line = line[i+1:]
+		}
+		t.mu.Lock()
+		t.last = line
+		t.mu.Unlock()
+	}
+	return len(p), nil
+}
+
+func (t *tailWriter) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.last
+}
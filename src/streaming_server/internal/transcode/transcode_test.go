@@ -0,0 +1,52 @@
+package transcode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestReadIVF(t *testing.T) {
+	var buf bytes.Buffer
+	hdr := make([]byte, ivfHeaderSize)
+	copy(hdr, "DKIF")
+	copy(hdr[8:], "VP90")
+	binary.LittleEndian.PutUint32(hdr[16:], 30) // time base 1/30
+	binary.LittleEndian.PutUint32(hdr[20:], 1)
+	buf.Write(hdr)
+	for i, frame := range [][]byte{{0x82, 0x49}, {0x86, 0x00, 0x01}} {
+		fh := make([]byte, ivfFrameHeaderSize)
+		binary.LittleEndian.PutUint32(fh, uint32(len(frame)))
+		binary.LittleEndian.PutUint64(fh[4:], uint64(i*15))
+		buf.Write(fh)
+		buf.Write(frame)
+	}
+
+	var frames []Frame
+	if err := readIVF(&buf, func(f Frame) { frames = append(frames, f) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("got %d frames, want 2", len(frames))
+	}
+	if !frames[0].Key || frames[1].Key {
+		t.Errorf("key flags %v %v, want true false", frames[0].Key, frames[1].Key)
+	}
+	if frames[1].PTS != 500*time.Millisecond || len(frames[1].Data) != 3 {
+		t.Errorf("second frame: pts %v, %d bytes", frames[1].PTS, len(frames[1].Data))
+	}
+}
+
+func TestFFmpegArgs(t *testing.T) {
+	if _, err := FFmpegArgs("H264", 30); err == nil {
+		t.Error("unsupported codec accepted")
+	}
+	args, err := FFmpegArgs("AV1", 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args[len(args)-1] != "pipe:1" {
+		t.Errorf("output is %q, want pipe:1", args[len(args)-1])
+	}
+}
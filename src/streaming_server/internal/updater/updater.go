@@ -0,0 +1,435 @@
+// Package updater replaces an installed binary with a signed release build,
+// restarts its systemd unit and rolls back to the previous build when the
+// new one fails its health check within a grace period.
+//
+// A release is described by a JSON manifest:
+//
+//	{"version": "1.4.0", "url": "streaming-server-linux-arm64",
+//	 "sha256": "<hex>", "signature": "<base64 ed25519, see SignedMessage>"}
+//
+// url may be relative to the manifest. Only builds signed with the
+// configured public key, and only versions newer than the installed build,
+// are installed: an old manifest replayed later cannot downgrade.
+package updater
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+// maxBinarySize bounds release downloads.
+const maxBinarySize = 256 << 20
+
+// Manifest describes one release build.
+type Manifest struct {
+	Version   string `json:"version"`
+	URL       string `json:"url"`
+	SHA256    string `json:"sha256"`
+	Signature string `json:"signature"`
+}
+
+// SignedMessage is what a release signature covers: the manifest's version
+// and digest, then the build. A signed build cannot be offered under
+// another version.
+func SignedMessage(version, sha256Hex string, build []byte) []byte {
+	msg := make([]byte, 0, len(version)+len(sha256Hex)+len(build)+32)
+	msg = fmt.Appendf(msg, "petcam-release\n%s\n%s\n", version, sha256Hex)
+	return append(msg, build...)
+}
+
+// GitHubManifestURL is where the manifest of the latest GitHub release of
+// repo ("owner/name") is published, as a release asset named asset.
+func GitHubManifestURL(repo, asset string) string {
+	return "https://github.com/" + repo + "/releases/latest/download/" + asset
+}
+
+// Result reports what Update did.
+type Result struct {
+	Version    string `json:"version"`
+	Updated    bool   `json:"updated"`     // a new build was installed and passed the health check
+	RolledBack bool   `json:"rolled_back"` // the new build failed and the previous one was restored
+}
+
+// Updater installs releases of one binary run by one systemd unit.
+type Updater struct {
+	ManifestURL  string
+	PublicKey    ed25519.PublicKey
+	Binary       string        // installed binary; the previous build is kept at Binary+".prev"
+	Unit         string        // systemd unit restarted after the swap ("" = do not restart)
+	HealthURL    string        // must answer 200 after the restart ("" = skip the check)
+	Grace        time.Duration // how long the new build must stay healthy
+	PollInterval time.Duration // health check interval
+	DrainURL     string        // POSTed before the restart so viewers reconnect cleanly ("" = none)
+	DrainWait    time.Duration // time between the drain request and the restart
+
+	Client *http.Client
+	// Restart restarts the unit (default: systemctl restart).
+	Restart func(ctx context.Context, unit string) error
+	// Installed reports the version of the installed build (default: the
+	// first word before "(commit" in the output of Binary -version).
+	Installed func(ctx context.Context) (string, error)
+}
+
+// New creates an updater with the default timings.
+func New(manifestURL string, publicKey ed25519.PublicKey, binary string) *Updater {
+	u := &Updater{
+		ManifestURL:  manifestURL,
+		PublicKey:    publicKey,
+		Binary:       binary,
+		Grace:        60 * time.Second,
+		PollInterval: 2 * time.Second,
+		DrainWait:    5 * time.Second,
+		Client:       &http.Client{Timeout: 5 * time.Minute},
+		Restart:      systemctlRestart,
+	}
+	u.Installed = u.binaryVersion
+	return u
+}
+
+func systemctlRestart(ctx context.Context, unit string) error {
+	out, err := exec.CommandContext(ctx, "systemctl", "restart", unit).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl restart %s: %v: %s", unit, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// binaryVersion runs Binary -version, which prints buildinfo.String()
+// (after the command name for petcam).
+func (u *Updater) binaryVersion(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, u.Binary, "-version").Output()
+	if err != nil {
+		return "", fmt.Errorf("%s -version: %w", u.Binary, err)
+	}
+	before, _, ok := strings.Cut(string(out), " (commit ")
+	fields := strings.Fields(before)
+	if !ok || len(fields) == 0 {
+		return "", fmt.Errorf("%s -version: unexpected output %q", u.Binary, bytes.TrimSpace(out))
+	}
+	return fields[len(fields)-1], nil
+}
+
+// Check fetches the manifest and reports whether its version is newer than
+// the installed build. An older version is an error (a replayed manifest);
+// so is an installed build without a release version, e.g. "dev".
+func (u *Updater) Check(ctx context.Context) (*Manifest, bool, error) {
+	var m Manifest
+	body, err := u.get(ctx, u.ManifestURL, 1<<20)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := json.Unmarshal(body, &m); err != nil {
+		return nil, false, fmt.Errorf("manifest: %w", err)
+	}
+	if m.URL == "" || m.SHA256 == "" || m.Signature == "" {
+		return nil, false, errors.New("manifest: url, sha256 and signature are required")
+	}
+	offered, ok := parseVersion(m.Version)
+	if !ok {
+		return nil, false, fmt.Errorf("manifest: version %q is not a release version", m.Version)
+	}
+	version, err := u.Installed(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	installed, ok := parseVersion(version)
+	if !ok {
+		return nil, false, fmt.Errorf("installed build %s has no release version (%q); install a release build by hand first", u.Binary, version)
+	}
+	switch c := offered.compare(installed); {
+	case c < 0:
+		return nil, false, fmt.Errorf("manifest version %s is older than the installed %s", m.Version, version)
+	case c == 0:
+		return &m, false, nil
+	}
+	return &m, true, nil
+}
+
+// releaseVersion is a version as scripts/build.sh stamps it (git describe):
+// [v]MAJOR.MINOR.PATCH, optionally -N-g<commit> for N commits after the tag
+// and -dirty.
+type releaseVersion struct {
+	parts [3]int
+	ahead int
+}
+
+func parseVersion(s string) (releaseVersion, bool) {
+	var v releaseVersion
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "v"), "-dirty")
+	core, rest, _ := strings.Cut(s, "-")
+	nums := strings.Split(core, ".")
+	if len(nums) != 3 {
+		return v, false
+	}
+	for i, n := range nums {
+		x, err := strconv.Atoi(n)
+		if err != nil || x < 0 {
+			return v, false
+		}
+		v.parts[i] = x
+	}
+	if rest != "" {
+		// Pre-releases (1.4.0-rc1) are not ordered; only describe suffixes are
+		n, commit, ok := strings.Cut(rest, "-g")
+		ahead, err := strconv.Atoi(n)
+		if !ok || err != nil || ahead < 0 || commit == "" {
+			return v, false
+		}
+		v.ahead = ahead
+	}
+	return v, true
+}
+
+func (v releaseVersion) compare(o releaseVersion) int {
+	for i := range v.parts {
+		if c := cmp.Compare(v.parts[i], o.parts[i]); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(v.ahead, o.ahead)
+}
+
+// Update installs the manifest's build if it differs from the installed
+// one, restarts the unit and waits out the grace period, rolling back on
+// failure. A rollback is reported with an error.
+func (u *Updater) Update(ctx context.Context) (Result, error) {
+	m, newer, err := u.Check(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	res := Result{Version: m.Version}
+	if !newer {
+		logger.Info("Updater", "%s is already at %s", u.Binary, m.Version)
+		return res, nil
+	}
+
+	data, err := u.download(ctx, m)
+	if err != nil {
+		return res, err
+	}
+	if err := u.swap(data); err != nil {
+		return res, err
+	}
+	logger.Info("Updater", "Installed %s at %s (previous build kept at %s)", m.Version, u.Binary, u.prevPath())
+
+	err = u.restartAndVerify(ctx)
+	if err == nil {
+		res.Updated = true
+		return res, nil
+	}
+	logger.Error("Updater", "Version %s failed: %v; rolling back", m.Version, err)
+	// Roll back even if the update was interrupted
+	if rbErr := u.rollback(context.WithoutCancel(ctx)); rbErr != nil {
+		return res, fmt.Errorf("%v; rollback failed: %w", err, rbErr)
+	}
+	res.RolledBack = true
+	return res, fmt.Errorf("version %s rolled back: %w", m.Version, err)
+}
+
+// download fetches the build and checks its digest and signature.
+func (u *Updater) download(ctx context.Context, m *Manifest) ([]byte, error) {
+	base, err := url.Parse(u.ManifestURL)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := url.Parse(m.URL)
+	if err != nil {
+		return nil, fmt.Errorf("manifest url: %w", err)
+	}
+	data, err := u.get(ctx, base.ResolveReference(ref).String(), maxBinarySize)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != m.SHA256 {
+		return nil, fmt.Errorf("sha256 mismatch: got %x, manifest says %s", sum, m.SHA256)
+	}
+	sig, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("signature: %w", err)
+	}
+	if !ed25519.Verify(u.PublicKey, SignedMessage(m.Version, m.SHA256, data), sig) {
+		return nil, errors.New("signature does not verify with the configured public key")
+	}
+	return data, nil
+}
+
+func (u *Updater) get(ctx context.Context, rawURL string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := u.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("GET %s: larger than %d bytes", rawURL, limit)
+	}
+	return data, nil
+}
+
+func (u *Updater) prevPath() string { return u.Binary + ".prev" }
+
+// swap writes data next to the binary and renames it into place, keeping
+// a hard link to the running build as Binary+".prev". The binary path
+// exists at every step.
+func (u *Updater) swap(data []byte) error {
+	info, err := os.Stat(u.Binary)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(u.Binary), filepath.Base(u.Binary)+".new-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Remove(u.prevPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Link(u.Binary, u.prevPath()); err != nil {
+		return fmt.Errorf("keep previous build: %w", err)
+	}
+	return os.Rename(tmp.Name(), u.Binary)
+}
+
+// rollback restores Binary+".prev" and restarts the unit.
+func (u *Updater) rollback(ctx context.Context) error {
+	if err := os.Rename(u.prevPath(), u.Binary); err != nil {
+		return err
+	}
+	if u.Unit == "" {
+		return nil
+	}
+	return u.Restart(ctx, u.Unit)
+}
+
+// restartAndVerify drains and restarts the unit, then requires the health
+// check to pass and keep passing until the grace period ends. Failures
+// before the first success are startup time, not errors.
+func (u *Updater) restartAndVerify(ctx context.Context) error {
+	if u.Unit == "" {
+		return nil
+	}
+	if u.DrainURL != "" {
+		if err := u.drain(ctx); err != nil {
+			logger.Warn("Updater", "Drain failed, restarting anyway: %v", err)
+		} else {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(u.DrainWait):
+			}
+		}
+	}
+	if err := u.Restart(ctx, u.Unit); err != nil {
+		return err
+	}
+	if u.HealthURL == "" {
+		return nil
+	}
+
+	deadline := time.Now().Add(u.Grace)
+	healthy := false
+	var lastErr error
+	for {
+		lastErr = u.health(ctx)
+		switch {
+		case lastErr == nil:
+			healthy = true
+		case healthy:
+			return fmt.Errorf("health check failed after startup: %w", lastErr)
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(u.PollInterval):
+		}
+	}
+	if !healthy {
+		return fmt.Errorf("not healthy within %v: %w", u.Grace, lastErr)
+	}
+	return nil
+}
+
+func (u *Updater) drain(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.DrainURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := u.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("POST %s: %s", u.DrainURL, resp.Status)
+	}
+	return nil
+}
+
+func (u *Updater) health(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.HealthURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := u.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", u.HealthURL, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package updater
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// release serves a signed manifest and build, plus a /health endpoint
+// controlled by healthy.
+func release(t *testing.T, build []byte, key ed25519.PrivateKey, healthy *atomic.Bool) *httptest.Server {
+	return releaseAs(t, "2.0.0", "2.0.0", build, key, healthy)
+}
+
+// releaseAs is release with the build signed as version but offered in
+// the manifest as offered.
+func releaseAs(t *testing.T, version, offered string, build []byte, key ed25519.PrivateKey, healthy *atomic.Bool) *httptest.Server {
+	sum := sha256.Sum256(build)
+	digest := hex.EncodeToString(sum[:])
+	manifest, _ := json.Marshal(Manifest{
+		Version:   offered,
+		URL:       "petcam-linux-arm64",
+		SHA256:    digest,
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, SignedMessage(version, digest, build))),
+	})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/releases/manifest.json", func(w http.ResponseWriter, r *http.Request) { w.Write(manifest) })
+	mux.HandleFunc("/releases/petcam-linux-arm64", func(w http.ResponseWriter, r *http.Request) { w.Write(build) })
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestUpdater(t *testing.T, srv *httptest.Server, pub ed25519.PublicKey) (*Updater, *int) {
+	bin := filepath.Join(t.TempDir(), "petcam")
+	if err := os.WriteFile(bin, []byte("v1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	u := New(srv.URL+"/releases/manifest.json", pub, bin)
+	u.Unit = "pet-camera-streaming.service"
+	u.HealthURL = srv.URL + "/health"
+	u.Grace = 50 * time.Millisecond
+	u.PollInterval = 10 * time.Millisecond
+	restarts := 0
+	u.Restart = func(context.Context, string) error { restarts++; return nil }
+	// The test builds are "v1", "v2", ...: version N.0.0
+	u.Installed = func(context.Context) (string, error) {
+		data, err := os.ReadFile(bin)
+		return strings.TrimPrefix(string(data), "v") + ".0.0", err
+	}
+	return u, &restarts
+}
+
+func TestUpdate(t *testing.T) {
+	pub, key, _ := ed25519.GenerateKey(nil)
+	var healthy atomic.Bool
+	healthy.Store(true)
+	u, restarts := newTestUpdater(t, release(t, []byte("v2"), key, &healthy), pub)
+
+	res, err := u.Update(context.Background())
+	if err != nil || !res.Updated || res.Version != "2.0.0" || *restarts != 1 {
+		t.Fatalf("res %+v, err %v, restarts %d", res, err, *restarts)
+	}
+	if got, _ := os.ReadFile(u.Binary); string(got) != "v2" {
+		t.Fatalf("binary %q", got)
+	}
+	if got, _ := os.ReadFile(u.prevPath()); string(got) != "v1" {
+		t.Fatalf("previous build %q", got)
+	}
+
+	// Same build again: nothing to do.
+	if res, err := u.Update(context.Background()); err != nil || res.Updated || *restarts != 1 {
+		t.Fatalf("second update: res %+v, err %v", res, err)
+	}
+}
+
+func TestUpdateRollsBackWhenUnhealthy(t *testing.T) {
+	pub, key, _ := ed25519.GenerateKey(nil)
+	var healthy atomic.Bool
+	u, restarts := newTestUpdater(t, release(t, []byte("v2"), key, &healthy), pub)
+
+	res, err := u.Update(context.Background())
+	if err == nil || !res.RolledBack || *restarts != 2 {
+		t.Fatalf("res %+v, err %v, restarts %d", res, err, *restarts)
+	}
+	if got, _ := os.ReadFile(u.Binary); string(got) != "v1" {
+		t.Fatalf("binary after rollback %q", got)
+	}
+}
+
+func TestUpdateRejectsForeignSignature(t *testing.T) {
+	pub, _, _ := ed25519.GenerateKey(nil)
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+	var healthy atomic.Bool
+	healthy.Store(true)
+	u, restarts := newTestUpdater(t, release(t, []byte("v2"), otherKey, &healthy), pub)
+
+	if _, err := u.Update(context.Background()); err == nil || *restarts != 0 {
+		t.Fatalf("err %v, restarts %d", err, *restarts)
+	}
+	if got, _ := os.ReadFile(u.Binary); string(got) != "v1" {
+		t.Fatalf("binary %q", got)
+	}
+}
+
+func TestUpdateRefusesDowngrade(t *testing.T) {
+	pub, key, _ := ed25519.GenerateKey(nil)
+	var healthy atomic.Bool
+	healthy.Store(true)
+	u, restarts := newTestUpdater(t, release(t, []byte("v2"), key, &healthy), pub)
+	os.WriteFile(u.Binary, []byte("v3"), 0755)
+
+	if _, err := u.Update(context.Background()); err == nil || !strings.Contains(err.Error(), "older") || *restarts != 0 {
+		t.Fatalf("err %v, restarts %d", err, *restarts)
+	}
+	if got, _ := os.ReadFile(u.Binary); string(got) != "v3" {
+		t.Fatalf("binary %q", got)
+	}
+}
+
+func TestUpdateRejectsVersionNotSigned(t *testing.T) {
+	pub, key, _ := ed25519.GenerateKey(nil)
+	var healthy atomic.Bool
+	healthy.Store(true)
+	// An old signed build relabelled as a newer version
+	u, restarts := newTestUpdater(t, releaseAs(t, "2.0.0", "9.0.0", []byte("v2"), key, &healthy), pub)
+
+	if _, err := u.Update(context.Background()); err == nil || *restarts != 0 {
+		t.Fatalf("err %v, restarts %d", err, *restarts)
+	}
+	if got, _ := os.ReadFile(u.Binary); string(got) != "v1" {
+		t.Fatalf("binary %q", got)
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	order := []string{"1.3.9", "v1.4.0", "1.4.0-dirty", "v1.4.0-2-g1a2b3c4", "v1.4.0-10-g5d6e7f8-dirty", "1.10.0", "2.0.0"}
+	for i := 1; i < len(order); i++ {
+		a, okA := parseVersion(order[i-1])
+		b, okB := parseVersion(order[i])
+		if !okA || !okB || a.compare(b) > 0 {
+			t.Errorf("%s > %s (parsed %v %v)", order[i-1], order[i], okA, okB)
+		}
+	}
+	if v, _ := parseVersion("1.4.0"); v.compare(releaseVersion{parts: [3]int{1, 4, 0}}) != 0 {
+		t.Error("1.4.0 != 1.4.0")
+	}
+	for _, bad := range []string{"dev", "1a2b3c4", "1.4", "1.4.0-rc1", "1.4.x"} {
+		if _, ok := parseVersion(bad); ok {
+			t.Errorf("parsed %q", bad)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Package watermark makes recordings tamper-evident. A hash chain runs over
+// the bytes of a recording as they are written; at every IDR frame the
+// current link is embedded in the stream as an SEI (user data unregistered)
+// anchor carrying the device ID and capture time, and when the recording
+// stops the final link is written to a manifest signed with the device's
+// Ed25519 key. Verify recomputes the chain from a clip and reports the
+// first segment that does not match.
+//
+// With anchors o_1..o_n (byte offsets of their start codes, o_0 = 0) and
+// link_0 = SHA-256("petcam hash chain v1\x00" || device ID):
+//
+//	link_i = SHA-256(link_{i-1} || bytes[o_{i-1}:o_i])   carried by anchor i
+//	final  = SHA-256(link_n || bytes[o_n:])               signed in the manifest
+//
+// Each anchor sits at the start of the segment after it, so the anchors
+// themselves (and their timestamps) are covered as well.
+package watermark
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/codec"
+)
+
+// AnchorSEIUUID identifies the anchor SEI. Its payload is the anchor
+// sequence number (uint32), the stream bytes before the anchor (uint64),
+// the capture time in Unix microseconds (uint64), all big-endian, the
+// 32-byte link and the device ID in UTF-8.
+var AnchorSEIUUID = [16]byte{'p', 'e', 't', 'c', 'a', 'm', '-', 'h', 'a', 's', 'h', 'c', 'h', 'a', 'i', 'n'}
+
+// MaxDeviceID is the longest device ID an anchor carries; it keeps the SEI
+// payload size in one byte.
+const MaxDeviceID = 128
+
+const anchorFixedSize = 4 + 8 + 8 + sha256.Size
+
+// Link is one value of the chain.
+type Link [sha256.Size]byte
+
+// Anchor is a decoded anchor SEI.
+type Anchor struct {
+	Seq      uint32
+	Offset   uint64 // stream bytes before the anchor
+	Time     time.Time
+	Link     Link
+	DeviceID string
+}
+
+// Chain hashes a recording as it is written. It is not safe for concurrent
+// use.
+type Chain struct {
+	deviceID string
+	link     Link
+	segment  hash.Hash
+	anchors  uint32
+	bytes    uint64
+}
+
+// NewChain starts the chain of a recording made by deviceID.
+func NewChain(deviceID string) (*Chain, error) {
+	if len(deviceID) > MaxDeviceID {
+		return nil, fmt.Errorf("watermark: device ID is %d bytes, at most %d", len(deviceID), MaxDeviceID)
+	}
+	c := &Chain{deviceID: deviceID, link: seed(deviceID)}
+	c.startSegment()
+	return c, nil
+}
+
+func seed(deviceID string) Link {
+	return sha256.Sum256([]byte("petcam hash chain v1\x00" + deviceID))
+}
+
+func (c *Chain) startSegment() {
+	c.segment = sha256.New()
+	c.segment.Write(c.link[:])
+}
+
+// Write adds bytes written to the recording. It never fails.
+func (c *Chain) Write(p []byte) (int, error) {
+	c.segment.Write(p)
+	c.bytes += uint64(len(p))
+	return len(p), nil
+}
+
+// Anchor closes the current segment and returns the Annex-B anchor SEI to
+// write next, stamped with capture time t. The caller passes the SEI to
+// Write once it is written, like any other bytes.
+func (c *Chain) Anchor(t time.Time) []byte {
+	c.segment.Sum(c.link[:0])
+	c.anchors++
+	a := Anchor{Seq: c.anchors, Offset: c.bytes, Time: t, Link: c.link, DeviceID: c.deviceID}
+	c.startSegment()
+	return a.SEI()
+}
+
+// Final returns the link over everything written so far, the number of
+// anchors and the bytes written.
+func (c *Chain) Final() (final Link, anchors uint32, bytes uint64) {
+	c.segment.Sum(final[:0])
+	return final, c.anchors, c.bytes
+}
+
+// SEI encodes a as an Annex-B prefix SEI NAL.
+func (a *Anchor) SEI() []byte {
+	payload := make([]byte, 0, anchorFixedSize+len(a.DeviceID))
+	payload = binary.BigEndian.AppendUint32(payload, a.Seq)
+	payload = binary.BigEndian.AppendUint64(payload, a.Offset)
+	payload = binary.BigEndian.AppendUint64(payload, uint64(a.Time.UnixMicro()))
+	payload = append(payload, a.Link[:]...)
+	payload = append(payload, a.DeviceID...)
+	return codec.UserDataSEI(AnchorSEIUUID, payload)
+}
+
+// ParseAnchor decodes an anchor SEI. nal starts at the 2-byte NAL header
+// (no start code).
+func ParseAnchor(nal []byte) (*Anchor, bool) {
+	uuid, payload, ok := codec.ParseUserDataSEI(nal)
+	if !ok || uuid != AnchorSEIUUID || len(payload) < anchorFixedSize {
+		return nil, false
+	}
+	a := &Anchor{
+		Seq:      binary.BigEndian.Uint32(payload),
+		Offset:   binary.BigEndian.Uint64(payload[4:]),
+		Time:     time.UnixMicro(int64(binary.BigEndian.Uint64(payload[12:]))),
+		DeviceID: string(payload[anchorFixedSize:]),
+	}
+	copy(a.Link[:], payload[20:anchorFixedSize])
+	return a, true
+}
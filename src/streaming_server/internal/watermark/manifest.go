@@ -0,0 +1,149 @@
+package watermark
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ManifestVersion is the manifest format written by this package.
+const ManifestVersion = 1
+
+// Manifest describes one finished recording. Everything but Signature is
+// signed.
+type Manifest struct {
+	Version   int       `json:"version"`
+	File      string    `json:"file"` // base name of the clip
+	DeviceID  string    `json:"device_id"`
+	Started   time.Time `json:"started"`
+	Stopped   time.Time `json:"stopped"`
+	Anchors   uint32    `json:"anchors"`
+	Bytes     uint64    `json:"bytes"`
+	Final     string    `json:"final"`      // hex of the final link
+	PublicKey []byte    `json:"public_key"` // Ed25519, base64
+	Signature []byte    `json:"signature,omitempty"`
+}
+
+// ManifestPath returns where the manifest of clip is written.
+func ManifestPath(clip string) string {
+	return clip + ".manifest.json"
+}
+
+// Manifest returns the unsigned manifest of a recording written to file.
+func (c *Chain) Manifest(file string, started, stopped time.Time) *Manifest {
+	final, anchors, n := c.Final()
+	return &Manifest{
+		Version:  ManifestVersion,
+		File:     filepath.Base(file),
+		DeviceID: c.deviceID,
+		Started:  started.UTC(),
+		Stopped:  stopped.UTC(),
+		Anchors:  anchors,
+		Bytes:    n,
+		Final:    hex.EncodeToString(final[:]),
+	}
+}
+
+// signedBytes is the message a signature covers: the manifest as JSON,
+// without the signature.
+func (m *Manifest) signedBytes() ([]byte, error) {
+	unsigned := *m
+	unsigned.Signature = nil
+	return json.Marshal(&unsigned)
+}
+
+// Sign sets PublicKey and Signature.
+func (m *Manifest) Sign(key ed25519.PrivateKey) error {
+	m.PublicKey = key.Public().(ed25519.PublicKey)
+	msg, err := m.signedBytes()
+	if err != nil {
+		return err
+	}
+	m.Signature = ed25519.Sign(key, msg)
+	return nil
+}
+
+// VerifySignature checks the signature against pub, or against the key in
+// the manifest when pub is nil. The latter only shows the manifest is
+// intact, not which device made it.
+func (m *Manifest) VerifySignature(pub ed25519.PublicKey) error {
+	if m.Version != ManifestVersion {
+		return fmt.Errorf("watermark: manifest version %d not supported", m.Version)
+	}
+	if len(m.PublicKey) != ed25519.PublicKeySize {
+		return errors.New("watermark: manifest has no public key")
+	}
+	if pub != nil && !pub.Equal(ed25519.PublicKey(m.PublicKey)) {
+		return fmt.Errorf("watermark: manifest signed by %s, not the given device key",
+			base64.StdEncoding.EncodeToString(m.PublicKey))
+	}
+	msg, err := m.signedBytes()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(m.PublicKey, msg, m.Signature) {
+		return errors.New("watermark: manifest signature is invalid")
+	}
+	return nil
+}
+
+// WriteManifest writes m to path, replacing any previous file.
+func WriteManifest(path string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ReadManifest reads a manifest written by WriteManifest.
+func ReadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// LoadOrCreateKey reads the device key at path, a base64 Ed25519 seed, or
+// creates one (mode 0600) if the file does not exist.
+func LoadOrCreateKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		seed := make([]byte, ed25519.SeedSize)
+		if _, err := rand.Read(seed); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(seed)+"\n"), 0600); err != nil {
+			return nil, err
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("%s: want a base64 Ed25519 seed (%d bytes)", path, ed25519.SeedSize)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
@@ -0,0 +1,132 @@
+package watermark
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+)
+
+// anchorPrefix starts every anchor SEI: a 4-byte start code, the SEI NAL
+// header and payload type 5. The payload size byte and AnchorSEIUUID
+// follow; none of them contain zeros, so emulation prevention never
+// touches them.
+var anchorPrefix = []byte{0, 0, 0, 1, 0x4e, 0x01, 0x05}
+
+const verifyWindow = 1 << 20
+
+// Report is what Verify found in a clip, up to the first mismatch.
+type Report struct {
+	DeviceID string
+	Anchors  uint32 // anchors whose link matched
+	Bytes    uint64 // bytes read
+	First    time.Time
+	Last     time.Time // capture time of the last matching anchor
+}
+
+// Verify recomputes the hash chain of a clip read from r and checks it
+// against every anchor in it and against m, whose signature the caller has
+// already checked. The error names the first segment that does not match.
+func Verify(r io.Reader, m *Manifest) (*Report, error) {
+	rep := &Report{DeviceID: m.DeviceID}
+	br := bufio.NewReaderSize(r, verifyWindow)
+	link := seed(m.DeviceID)
+	var (
+		seg    hash.Hash
+		offset uint64 // start of the current segment
+	)
+	newSegment := func() {
+		seg = sha256.New()
+		seg.Write(link[:])
+		offset = rep.Bytes
+	}
+	consume := func(p []byte) {
+		seg.Write(p)
+		rep.Bytes += uint64(len(p))
+		br.Discard(len(p))
+	}
+	newSegment()
+
+	for {
+		buf, err := br.Peek(verifyWindow)
+		eof := err == io.EOF
+		if err != nil && !eof {
+			return rep, err
+		}
+		i := bytes.Index(buf, anchorPrefix)
+		if i < 0 {
+			if eof {
+				consume(buf)
+				break
+			}
+			consume(buf[:len(buf)-len(anchorPrefix)+1])
+			continue
+		}
+		if i > 0 {
+			// Peek again with the anchor at the start of the window
+			consume(buf[:i])
+			continue
+		}
+		if len(buf) < 8+len(AnchorSEIUUID) || !bytes.Equal(buf[8:8+len(AnchorSEIUUID)], AnchorSEIUUID[:]) {
+			consume(buf[:1]) // some other user data SEI
+			continue
+		}
+
+		end := nalEnd(buf)
+		a, ok := ParseAnchor(buf[len(startCode):end])
+		if !ok {
+			return rep, fmt.Errorf("anchor %d at byte %d is corrupt", rep.Anchors+1, rep.Bytes)
+		}
+		if a.DeviceID != m.DeviceID {
+			return rep, fmt.Errorf("anchor %d at byte %d names device %q, manifest %q", a.Seq, rep.Bytes, a.DeviceID, m.DeviceID)
+		}
+		if a.Seq != rep.Anchors+1 {
+			return rep, fmt.Errorf("anchor %d follows anchor %d at byte %d: segments were removed or reordered", a.Seq, rep.Anchors, rep.Bytes)
+		}
+		var got Link
+		seg.Sum(got[:0])
+		if got != a.Link {
+			return rep, fmt.Errorf("bytes %d-%d before anchor %d (captured %s) were altered",
+				offset, rep.Bytes, a.Seq, a.Time.Format(time.RFC3339))
+		}
+		rep.Anchors = a.Seq
+		if rep.First.IsZero() {
+			rep.First = a.Time
+		}
+		rep.Last = a.Time
+		link = got
+		newSegment()
+		consume(buf[:end])
+	}
+
+	var final Link
+	seg.Sum(final[:0])
+	if rep.Anchors < m.Anchors {
+		return rep, fmt.Errorf("clip ends after anchor %d of %d: it was cut", rep.Anchors, m.Anchors)
+	}
+	if rep.Anchors > m.Anchors {
+		return rep, fmt.Errorf("clip has %d anchors, manifest %d", rep.Anchors, m.Anchors)
+	}
+	if hex.EncodeToString(final[:]) != m.Final || rep.Bytes != m.Bytes {
+		return rep, fmt.Errorf("bytes %d-%d after anchor %d were altered (manifest: %d bytes)",
+			offset, rep.Bytes, rep.Anchors, m.Bytes)
+	}
+	return rep, nil
+}
+
+var startCode = anchorPrefix[:4]
+
+// nalEnd returns the end of the NAL unit starting with a 4-byte start code
+// at buf[0]: the next start code, or the end of buf.
+func nalEnd(buf []byte) int {
+	for j := len(startCode); j+2 < len(buf); j++ {
+		if buf[j] == 0 && buf[j+1] == 0 && buf[j+2] <= 1 {
+			return j
+		}
+	}
+	return len(buf)
+}
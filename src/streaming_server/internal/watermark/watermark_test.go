@@ -0,0 +1,163 @@
+package watermark
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"math/rand/v2"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/codec"
+)
+
+// clip is a recording written through a Chain, with the offsets of its
+// anchors.
+type clip struct {
+	data    []byte
+	anchors []int
+	m       *Manifest
+}
+
+func record(t *testing.T, gops int) *clip {
+	t.Helper()
+	c, err := NewChain("pet-cam-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rng := rand.New(rand.NewPCG(1, 2))
+	var buf bytes.Buffer
+	cl := &clip{}
+	start := time.UnixMicro(1760600000000000)
+	write := func(p []byte) {
+		buf.Write(p)
+		c.Write(p)
+	}
+	for g := range gops {
+		ts := start.Add(time.Duration(g) * time.Second)
+		cl.anchors = append(cl.anchors, buf.Len())
+		write(c.Anchor(ts))
+		// Another user data SEI, and GOPs spanning the verifier's window
+		write(codec.TimestampSEI(ts, "pet-cam-1"))
+		for f := range 30 {
+			payload := make([]byte, 2000+rng.IntN(20000))
+			for i := range payload {
+				payload[i] = byte(1 + rng.IntN(255))
+			}
+			nalType := byte(1)
+			if f == 0 {
+				nalType = 20
+			}
+			write(append([]byte{0, 0, 0, 1, nalType << 1, 1}, payload...))
+		}
+	}
+	cl.data = buf.Bytes()
+	cl.m = c.Manifest("recording.hevc", start, start.Add(time.Duration(gops)*time.Second))
+	return cl
+}
+
+func TestVerify(t *testing.T) {
+	cl := record(t, 6)
+	if len(cl.data) < 2*verifyWindow {
+		t.Fatalf("clip of %d bytes does not span the verify window", len(cl.data))
+	}
+	rep, err := Verify(bytes.NewReader(cl.data), cl.m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Anchors != 6 || rep.Bytes != uint64(len(cl.data)) || rep.DeviceID != "pet-cam-1" ||
+		rep.Last.Sub(rep.First) != 5*time.Second {
+		t.Errorf("report = %+v", rep)
+	}
+
+	flip := func(off int) []byte {
+		d := append([]byte(nil), cl.data...)
+		d[off] ^= 0x40
+		return d
+	}
+	a := cl.anchors
+	cases := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"altered GOP", flip(a[3] + 5000), "before anchor 5"},
+		{"altered anchor time", flip(a[2] + 40), "before anchor 4"},
+		{"altered last GOP", flip(len(cl.data) - 10), "after anchor 6"},
+		{"cut", cl.data[:a[4]], "ends after anchor 4 of 6"},
+		{"removed GOP", append(append([]byte(nil), cl.data[:a[2]]...), cl.data[a[3]:]...), "removed or reordered"},
+		{"appended", append(append([]byte(nil), cl.data...), 0, 0, 0, 1, 2, 1, 0xaa), "after anchor 6"},
+	}
+	for _, c := range cases {
+		_, err := Verify(bytes.NewReader(c.data), cl.m)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: %v, want %q", c.name, err, c.want)
+		}
+	}
+
+	other := *cl.m
+	other.DeviceID = "pet-cam-2"
+	if _, err := Verify(bytes.NewReader(cl.data), &other); err == nil || !strings.Contains(err.Error(), "names device") {
+		t.Errorf("other device: %v", err)
+	}
+}
+
+func TestAnchorRoundTrip(t *testing.T) {
+	a := Anchor{Seq: 7, Offset: 1 << 40, Time: time.UnixMicro(0x0000_0100_0000_0002), DeviceID: "cam"}
+	a.Link[3] = 1 // zeros around it exercise emulation prevention
+	sei := a.SEI()
+	got, ok := ParseAnchor(sei[4:])
+	if !ok || *got != a {
+		t.Fatalf("ParseAnchor = %+v, %v", got, ok)
+	}
+	if _, ok := ParseAnchor(codec.TimestampSEI(time.Now(), "cam")[4:]); ok {
+		t.Error("timestamp SEI parsed as anchor")
+	}
+	if _, err := NewChain(strings.Repeat("x", MaxDeviceID+1)); err == nil {
+		t.Error("long device ID accepted")
+	}
+}
+
+func TestManifestSignature(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "keys", "device.key")
+	key, err := LoadOrCreateKey(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := LoadOrCreateKey(keyPath)
+	if err != nil || !key.Equal(again) {
+		t.Fatalf("reloaded key differs: %v", err)
+	}
+
+	m := record(t, 1).m
+	if err := m.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "recording.hevc.manifest.json")
+	if err := WriteManifest(path, m); err != nil {
+		t.Fatal(err)
+	}
+	m, err = ReadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := key.Public().(ed25519.PublicKey)
+	if err := m.VerifySignature(pub); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.VerifySignature(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+	if err := m.VerifySignature(otherPub); err == nil {
+		t.Error("accepted for another device key")
+	}
+	forged := *m
+	forged.Final = strings.Repeat("0", 64)
+	if err := forged.VerifySignature(pub); err == nil {
+		t.Error("altered manifest accepted")
+	}
+}
@@ -0,0 +1,217 @@
+package webmonitor
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Activity event types.
+const (
+	EventFeedingStarted  = "feeding_started"
+	EventFeedingEnded    = "feeding_ended"
+	EventDrinkingStarted = "drinking_started"
+	EventDrinkingEnded   = "drinking_ended"
+)
+
+// activityKind maps a bowl class to the activity it indicates.
+type activityKind struct {
+	bowlClass string
+	started   string
+	ended     string
+}
+
+var activityKinds = []activityKind{
+	{bowlClass: "food_bowl", started: EventFeedingStarted, ended: EventFeedingEnded},
+	{bowlClass: "water_bowl", started: EventDrinkingStarted, ended: EventDrinkingEnded},
+}
+
+type activityState struct {
+	overlapSince time.Time // start of the current overlap streak
+	lastOverlap  time.Time
+	active       bool
+	startedAt    time.Time
+	class        string
+	petID        string
+	bowl         BoundingBox
+}
+
+// ActivityTracker synthesizes feeding/drinking events from sustained overlap
+// between a pet bbox and a bowl bbox.
+type ActivityTracker struct {
+	mu      sync.Mutex
+	emit    func(Event)
+	state   []activityState // indexed like activityKinds
+	stop    chan struct{}
+	stopped bool
+
+	// Configurable parameters
+	MinOverlap float64       // fraction of the bowl bbox covered by the pet bbox (0-1)
+	StartAfter time.Duration // overlap must be sustained this long before *_started
+	EndAfter   time.Duration // *_ended after no overlap for this long
+}
+
+// NewActivityTracker creates a tracker emitting events via emit.
+func NewActivityTracker(emit func(Event)) *ActivityTracker {
+	return &ActivityTracker{
+		emit:       emit,
+		state:      make([]activityState, len(activityKinds)),
+		stop:       make(chan struct{}),
+		MinOverlap: 0.3,
+		StartAfter: 3 * time.Second,
+		EndAfter:   5 * time.Second,
+	}
+}
+
+// Start begins the periodic end-of-activity check. Endings must be detected
+// even when the detector stops publishing non-empty results.
+func (t *ActivityTracker) Start() {
+	go t.run()
+}
+
+// Stop halts the tracker.
+func (t *ActivityTracker) Stop() {
+	t.mu.Lock()
+	if !t.stopped {
+		close(t.stop)
+		t.stopped = true
+	}
+	t.mu.Unlock()
+}
+
+func (t *ActivityTracker) run() {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.stop:
+			return
+		case now := <-ticker.C:
+			t.Tick(now)
+		}
+	}
+}
+
+// Observe updates overlap state from a detection result.
+func (t *ActivityTracker) Observe(det *DetectionResult, now time.Time) {
+	if det == nil {
+		return
+	}
+
+	var events []Event
+	t.mu.Lock()
+	for i, kind := range activityKinds {
+		pet, bowl, ok := petBowlOverlap(det.Detections, kind.bowlClass, t.MinOverlap)
+		if !ok {
+			continue
+		}
+		st := &t.state[i]
+		if st.lastOverlap.IsZero() || now.Sub(st.lastOverlap) > t.EndAfter {
+			st.overlapSince = now
+		}
+		st.lastOverlap = now
+		st.class = pet.ClassName
+		if pet.PetID != "" {
+			st.petID = pet.PetID
+		} else if !st.active {
+			st.petID = ""
+		}
+		st.bowl = bowl
+		if !st.active && now.Sub(st.overlapSince) >= t.StartAfter {
+			st.active = true
+			st.startedAt = st.overlapSince
+			events = append(events, activityEvent(kind.started, st, now, nil))
+		}
+	}
+	events = append(events, t.expireLocked(now)...)
+	t.mu.Unlock()
+
+	t.emitAll(events)
+}
+
+// Tick ends activities whose overlap has lapsed.
+func (t *ActivityTracker) Tick(now time.Time) {
+	t.mu.Lock()
+	events := t.expireLocked(now)
+	t.mu.Unlock()
+
+	t.emitAll(events)
+}
+
+func (t *ActivityTracker) expireLocked(now time.Time) []Event {
+	var events []Event
+	for i, kind := range activityKinds {
+		st := &t.state[i]
+		if !st.active || now.Sub(st.lastOverlap) < t.EndAfter {
+			continue
+		}
+		st.active = false
+		duration := st.lastOverlap.Sub(st.startedAt)
+		events = append(events, activityEvent(kind.ended, st, st.lastOverlap, map[string]string{
+			"duration_sec": fmt.Sprintf("%.1f", duration.Seconds()),
+		}))
+	}
+	return events
+}
+
+func (t *ActivityTracker) emitAll(events []Event) {
+	if t.emit == nil {
+		return
+	}
+	for _, ev := range events {
+		t.emit(ev)
+	}
+}
+
+func activityEvent(eventType string, st *activityState, at time.Time, data map[string]string) Event {
+	bowl := st.bowl
+	if st.petID != "" {
+		if data == nil {
+			data = make(map[string]string)
+		}
+		data["pet_id"] = st.petID
+	}
+	return Event{
+		Type:      eventType,
+		Timestamp: float64(at.UnixNano()) / 1e9,
+		Class:     st.class,
+		BBox:      &bowl,
+		Data:      data,
+	}
+}
+
+// petBowlOverlap returns the first pet/bowl pair where the pet covers at least
+// minOverlap of the bowl bbox.
+func petBowlOverlap(dets []Detection, bowlClass string, minOverlap float64) (Detection, BoundingBox, bool) {
+	for _, bowl := range dets {
+		if bowl.ClassName != bowlClass {
+			continue
+		}
+		bowlArea := bowl.BBox.W * bowl.BBox.H
+		if bowlArea <= 0 {
+			continue
+		}
+		for _, pet := range dets {
+			if !isPetClass(pet.ClassName) {
+				continue
+			}
+			inter := intersectionArea(pet.BBox, bowl.BBox)
+			if float64(inter)/float64(bowlArea) >= minOverlap {
+				return pet, bowl.BBox, true
+			}
+		}
+	}
+	return Detection{}, BoundingBox{}, false
+}
+
+func intersectionArea(a, b BoundingBox) int {
+	x0 := max(a.X, b.X)
+	y0 := max(a.Y, b.Y)
+	x1 := min(a.X+a.W, b.X+b.W)
+	y1 := min(a.Y+a.H, b.Y+b.H)
+	if x1 <= x0 || y1 <= y0 {
+		return 0
+	}
+	return (x1 - x0) * (y1 - y0)
+}
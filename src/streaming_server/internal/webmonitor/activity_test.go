@@ -0,0 +1,134 @@
+package webmonitor
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+)
+
+func catAtBowl(bowlClass string, overlap bool) *DetectionResult {
+	cat := BoundingBox{X: 800, Y: 100, W: 200, H: 200}
+	if overlap {
+		cat = BoundingBox{X: 120, Y: 380, W: 200, H: 200}
+	}
+	return &DetectionResult{
+		NumDetections: 2,
+		Detections: []Detection{
+			{ClassName: bowlClass, Confidence: 0.8, BBox: BoundingBox{X: 100, Y: 400, W: 120, H: 80}},
+			{ClassName: "cat", Confidence: 0.9, BBox: cat},
+		},
+	}
+}
+
+func TestActivityTracker_FeedingStartedEnded(t *testing.T) {
+	var got []Event
+	tr := NewActivityTracker(func(ev Event) { got = append(got, ev) })
+
+	start := time.Now()
+	// Brief overlap shorter than StartAfter: no event
+	tr.Observe(catAtBowl("food_bowl", true), start)
+	tr.Observe(catAtBowl("food_bowl", false), start.Add(time.Second))
+	tr.Tick(start.Add(10 * time.Second))
+	if len(got) != 0 {
+		t.Fatalf("unexpected events for brief overlap: %+v", got)
+	}
+
+	// Sustained overlap
+	base := start.Add(20 * time.Second)
+	for i := 0; i <= 10; i++ {
+		tr.Observe(catAtBowl("food_bowl", true), base.Add(time.Duration(i)*time.Second))
+	}
+	if len(got) != 1 || got[0].Type != EventFeedingStarted || got[0].Class != "cat" {
+		t.Fatalf("expected feeding_started, got %+v", got)
+	}
+
+	// Cat leaves
+	tr.Tick(base.Add(12 * time.Second))
+	if len(got) != 1 {
+		t.Fatal("ended too early")
+	}
+	tr.Tick(base.Add(16 * time.Second))
+	if len(got) != 2 || got[1].Type != EventFeedingEnded {
+		t.Fatalf("expected feeding_ended, got %+v", got)
+	}
+	if got[1].Data["duration_sec"] != "10.0" {
+		t.Errorf("duration_sec = %q, want 10.0", got[1].Data["duration_sec"])
+	}
+}
+
+func TestActivityTracker_Drinking(t *testing.T) {
+	var got []Event
+	tr := NewActivityTracker(func(ev Event) { got = append(got, ev) })
+	start := time.Now()
+	for i := 0; i <= 4; i++ {
+		tr.Observe(catAtBowl("water_bowl", true), start.Add(time.Duration(i)*time.Second))
+	}
+	if len(got) != 1 || got[0].Type != EventDrinkingStarted {
+		t.Fatalf("expected drinking_started, got %+v", got)
+	}
+}
+
+func TestEventStore_QueryAndPersist(t *testing.T) {
+	s := NewEventStore(time.Hour)
+	var heard []string
+	s.AddListener(func(ev Event) { heard = append(heard, ev.Type) })
+
+	now := float64(time.Now().Unix())
+	s.Append(Event{Type: EventFeedingStarted, Timestamp: now - 30})
+	s.Append(Event{Type: EventDrinkingStarted, Timestamp: now - 20})
+	s.Append(Event{Type: EventFeedingEnded, Timestamp: now - 10})
+	s.Append(Event{Type: EventFeedingStarted, Timestamp: now - 7200}) // outside window, trimmed on next append
+	s.Append(Event{Type: EventFeedingEnded, Timestamp: now})
+
+	if len(heard) != 5 {
+		t.Fatalf("listener calls = %d, want 5", len(heard))
+	}
+	if got := s.Query(now-25, nil, 0); len(got) != 3 {
+		t.Fatalf("since query = %d events, want 3", len(got))
+	}
+	if got := s.Query(0, []string{EventFeedingStarted, EventFeedingEnded}, 2); len(got) != 2 || got[1].Timestamp != now {
+		t.Fatalf("type+limit query = %+v", got)
+	}
+
+	path := filepath.Join(t.TempDir(), "events.gob")
+	if err := s.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewEventStore(time.Hour)
+	if err := loaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	ev := loaded.Append(Event{Type: "x"})
+	if ev.ID <= 5 {
+		t.Errorf("ID after load = %d, want > 5", ev.ID)
+	}
+}
+
+func TestRulesEngine_EventCondition(t *testing.T) {
+	e := NewRulesEngine(kv.Doc{})
+	fired := 0
+	e.SetOnAction(func(Rule, RuleAction, RuleTrigger) { fired++ })
+	if _, err := e.Put(Rule{
+		Name:       "record feeding",
+		Enabled:    true,
+		Conditions: []RuleCondition{{Event: EventFeedingStarted}},
+		Actions:    []RuleAction{{Type: RuleActionRecord}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	e.Evaluate(now)
+	e.ObserveEvent(Event{Type: EventDrinkingStarted}, now)
+	e.Evaluate(now)
+	if fired != 0 {
+		t.Fatal("fired for non-matching event")
+	}
+	e.ObserveEvent(Event{Type: EventFeedingStarted, Class: "cat"}, now.Add(time.Second))
+	e.Evaluate(now.Add(time.Second))
+	if fired != 1 {
+		t.Fatalf("fired = %d, want 1", fired)
+	}
+}
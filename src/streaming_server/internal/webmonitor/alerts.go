@@ -0,0 +1,469 @@
+package webmonitor
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/eventbus"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+// Alert severities.
+const (
+	AlertInfo     = "info"
+	AlertWarning  = "warning"
+	AlertCritical = "critical"
+)
+
+// Alert actions carried by AlertEvent.
+const (
+	alertSnapshot = "snapshot"
+	alertRaised   = "raised"
+	alertUpdated  = "updated"
+	alertAcked    = "acked"
+	alertCleared  = "cleared"
+)
+
+// Alert is an operational problem shown in the dashboard's bell menu.
+// Repeats of the same condition (same Key) update one alert instead of
+// stacking up.
+type Alert struct {
+	ID        uint64  `json:"id"`
+	Key       string  `json:"key"`
+	Source    string  `json:"source"` // log, detector, watchdog, disk, system
+	Severity  string  `json:"severity"`
+	Message   string  `json:"message"`
+	FirstSeen float64 `json:"first_seen"`
+	LastSeen  float64 `json:"last_seen"`
+	Count     int     `json:"count"`
+	Acked     bool    `json:"acked"`
+
+	text Message // Message by catalog key, when raised with RaiseMessage
+}
+
+// In returns a with its message rendered in locale.
+func (a Alert) In(locale string) Alert {
+	if a.text.Key != "" {
+		a.Message = a.text.In(locale)
+	}
+	return a
+}
+
+// AlertEvent is one message on /api/alerts/stream.
+type AlertEvent struct {
+	Action string  `json:"action"`
+	Alert  *Alert  `json:"alert,omitempty"`
+	Alerts []Alert `json:"alerts,omitempty"` // snapshot only
+}
+
+// In returns ev with its alert messages rendered in locale.
+func (ev AlertEvent) In(locale string) AlertEvent {
+	if ev.Alert != nil {
+		a := ev.Alert.In(locale)
+		ev.Alert = &a
+	}
+	ev.Alerts = localizeAlerts(ev.Alerts, locale)
+	return ev
+}
+
+// localizeAlerts returns alerts with their messages rendered in locale.
+func localizeAlerts(alerts []Alert, locale string) []Alert {
+	if alerts == nil {
+		return nil
+	}
+	out := make([]Alert, len(alerts))
+	for i, a := range alerts {
+		out[i] = a.In(locale)
+	}
+	return out
+}
+
+func severityRank(s string) int {
+	switch s {
+	case AlertCritical:
+		return 2
+	case AlertWarning:
+		return 1
+	}
+	return 0
+}
+
+// AlertCenter holds the active alerts and publishes changes on its "alerts"
+// event bus topic, to SSE clients and other subscribers.
+// An alert stays active until it is cleared, either by a user or by its
+// source once the condition is gone (e.g. the detector resumes).
+type AlertCenter struct {
+	MaxAlerts int // oldest alerts are dropped beyond this
+
+	mu         sync.Mutex
+	alerts     []*Alert // oldest first
+	nextID     uint64
+	topic      *eventbus.Topic[AlertEvent]
+	clients    map[int]func() // cancels SSE subscriptions
+	nextClient int
+}
+
+// NewAlertCenter creates an empty alert center.
+func NewAlertCenter() *AlertCenter {
+	return &AlertCenter{
+		MaxAlerts: 100,
+		nextID:    1,
+		topic:     eventbus.NewTopic[AlertEvent](nil, "alerts"),
+		clients:   make(map[int]func()),
+	}
+}
+
+// Topic returns the topic alert changes are published on (never snapshots).
+func (c *AlertCenter) Topic() *eventbus.Topic[AlertEvent] {
+	return c.topic
+}
+
+// Raise creates the alert for key, or refreshes it if already active. A
+// refresh that escalates the severity un-acks it.
+func (c *AlertCenter) Raise(source, key, severity, message string) Alert {
+	return c.raise(source, key, severity, message, Message{})
+}
+
+// RaiseMessage is Raise with a message from the catalog, served in each
+// reader's locale (Message holds the English text).
+func (c *AlertCenter) RaiseMessage(source, key, severity string, text Message) Alert {
+	return c.raise(source, key, severity, text.String(), text)
+}
+
+func (c *AlertCenter) raise(source, key, severity, message string, text Message) Alert {
+	now := float64(time.Now().UnixNano()) / 1e9
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, a := range c.alerts {
+		if a.Key != key {
+			continue
+		}
+		if severityRank(severity) > severityRank(a.Severity) {
+			a.Severity = severity
+			a.Acked = false
+		}
+		a.Message, a.text = message, text
+		a.LastSeen = now
+		a.Count++
+		c.broadcastLocked(alertUpdated, *a)
+		return *a
+	}
+
+	a := &Alert{
+		ID:        c.nextID,
+		Key:       key,
+		Source:    source,
+		Severity:  severity,
+		Message:   message,
+		text:      text,
+		FirstSeen: now,
+		LastSeen:  now,
+		Count:     1,
+	}
+	c.nextID++
+	c.alerts = append(c.alerts, a)
+	if len(c.alerts) > c.MaxAlerts {
+		c.alerts = c.alerts[len(c.alerts)-c.MaxAlerts:]
+	}
+	c.broadcastLocked(alertRaised, *a)
+	return *a
+}
+
+// Resolve clears the alert for key, if active.
+func (c *AlertCenter) Resolve(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, a := range c.alerts {
+		if a.Key == key {
+			c.removeLocked(i)
+			return
+		}
+	}
+}
+
+// Ack marks an alert as seen. It returns false for an unknown id.
+func (c *AlertCenter) Ack(id uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, a := range c.alerts {
+		if a.ID == id {
+			if !a.Acked {
+				a.Acked = true
+				c.broadcastLocked(alertAcked, *a)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Clear removes an alert. It returns false for an unknown id.
+func (c *AlertCenter) Clear(id uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, a := range c.alerts {
+		if a.ID == id {
+			c.removeLocked(i)
+			return true
+		}
+	}
+	return false
+}
+
+func (c *AlertCenter) removeLocked(i int) {
+	a := *c.alerts[i]
+	c.alerts = append(c.alerts[:i], c.alerts[i+1:]...)
+	c.broadcastLocked(alertCleared, a)
+}
+
+// Active returns the active alerts, oldest first, and how many are unacked.
+func (c *AlertCenter) Active() ([]Alert, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.activeLocked()
+}
+
+func (c *AlertCenter) activeLocked() ([]Alert, int) {
+	out := make([]Alert, len(c.alerts))
+	unacked := 0
+	for i, a := range c.alerts {
+		out[i] = *a
+		if !a.Acked {
+			unacked++
+		}
+	}
+	return out, unacked
+}
+
+// Subscribe returns a channel that first receives a snapshot of the active
+// alerts, then every change.
+func (c *AlertCenter) Subscribe() (int, <-chan AlertEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := c.nextClient
+	c.nextClient++
+	active, _ := c.activeLocked()
+	ch, cancel := c.topic.SubscribeChan("sse", 32, AlertEvent{Action: alertSnapshot, Alerts: active})
+	c.clients[id] = cancel
+	return id, ch
+}
+
+// Unsubscribe removes a client.
+func (c *AlertCenter) Unsubscribe(id int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cancel, ok := c.clients[id]; ok {
+		delete(c.clients, id)
+		cancel()
+	}
+}
+
+// broadcastLocked publishes a change; an SSE client that cannot keep up
+// misses the event and catches up on reconnect (snapshot).
+func (c *AlertCenter) broadcastLocked(action string, a Alert) {
+	c.topic.Publish(AlertEvent{Action: action, Alert: &a})
+}
+
+// alertLogSkip lists modules whose problems already raise dedicated alerts.
+var alertLogSkip = map[string]bool{
+	"DetectionHealth": true, // detector:stale
+	"Systemd":         true, // watchdog:liveness
+}
+
+// alertFromLog is the log hook turning WARN/ERROR lines into alerts. Lines
+// from the same call site share a key, so a repeating warning bumps one
+// alert's count.
+func (c *AlertCenter) alertFromLog(e logger.Entry) {
+	if alertLogSkip[e.Module] {
+		return
+	}
+	severity := AlertWarning
+	if e.Level >= logger.ERROR {
+		severity = AlertCritical
+	}
+	c.Raise("log", "log:"+e.Module+":"+e.Format, severity, "["+e.Module+"] "+e.Message)
+}
+
+// DiskMonitor raises a disk alert when free space on the recordings volume
+// drops below WarnFree (warning) or CriticalFree (critical), and resolves
+// it once space is back.
+type DiskMonitor struct {
+	Interval     time.Duration
+	WarnFree     float64 // fraction of the volume
+	CriticalFree float64
+
+	path    string
+	alerts  *AlertCenter
+	mu      sync.Mutex
+	stop    chan struct{}
+	stopped bool
+
+	// Last statfs result in bytes, for the disk metrics
+	free atomic.Uint64
+	size atomic.Uint64
+}
+
+const diskAlertKey = "disk:free"
+
+// NewDiskMonitor creates a monitor for the volume holding path.
+func NewDiskMonitor(path string, alerts *AlertCenter) *DiskMonitor {
+	return &DiskMonitor{
+		Interval:     time.Minute,
+		WarnFree:     0.10,
+		CriticalFree: 0.03,
+		path:         path,
+		alerts:       alerts,
+		stop:         make(chan struct{}),
+	}
+}
+
+// Start begins checking.
+func (d *DiskMonitor) Start() {
+	go d.run()
+}
+
+// Stop halts checking.
+func (d *DiskMonitor) Stop() {
+	d.mu.Lock()
+	if !d.stopped {
+		close(d.stop)
+		d.stopped = true
+	}
+	d.mu.Unlock()
+}
+
+func (d *DiskMonitor) run() {
+	d.check()
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+			d.check()
+		}
+	}
+}
+
+func (d *DiskMonitor) check() {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(d.path, &st); err != nil || st.Blocks == 0 {
+		return
+	}
+	d.free.Store(st.Bavail * uint64(st.Bsize))
+	d.size.Store(st.Blocks * uint64(st.Bsize))
+	free := float64(st.Bavail) / float64(st.Blocks)
+	freeMiB := st.Bavail * uint64(st.Bsize) >> 20
+	switch {
+	case free < d.CriticalFree:
+		d.alerts.RaiseMessage("disk", diskAlertKey, AlertCritical, msg("alert.disk_full", freeMiB, free*100))
+	case free < d.WarnFree:
+		d.alerts.RaiseMessage("disk", diskAlertKey, AlertWarning, msg("alert.disk_low", freeMiB, free*100))
+	default:
+		d.alerts.Resolve(diskAlertKey)
+	}
+}
+
+// Usage returns the free and total bytes of the volume at the last check
+// (zero before the first).
+func (d *DiskMonitor) Usage() (free, size uint64) {
+	return d.free.Load(), d.size.Load()
+}
+
+// checkAliveAlerting wraps checkAlive for the systemd watchdog, raising a
+// critical alert while the liveness check fails.
+func (s *Server) checkAliveAlerting() error {
+	err := s.checkAlive()
+	if err != nil {
+		s.alerts.RaiseMessage("watchdog", "watchdog:liveness", AlertCritical, msg("alert.liveness_failed", err.Error()))
+	} else {
+		s.alerts.Resolve("watchdog:liveness")
+	}
+	return err
+}
+
+// handleAlerts serves GET /api/alerts.
+func (s *Server) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONWithStatus(w, map[string]any{"error": "method not allowed"}, http.StatusMethodNotAllowed)
+		return
+	}
+	active, unacked := s.alerts.Active()
+	writeJSON(w, map[string]any{"alerts": localizeAlerts(active, s.requestLocale(r)), "unacked": unacked})
+}
+
+// handleAlert serves POST /api/alerts/{id}/ack and /api/alerts/{id}/clear.
+func (s *Server) handleAlert(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/api/alerts/")
+	idStr, action, _ := strings.Cut(rest, "/")
+	if r.Method != http.MethodPost {
+		writeJSONWithStatus(w, map[string]any{"error": "method not allowed"}, http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		writeJSONWithStatus(w, map[string]any{"error": "invalid alert id"}, http.StatusBadRequest)
+		return
+	}
+	var ok bool
+	switch action {
+	case "ack":
+		ok = s.alerts.Ack(id)
+	case "clear":
+		ok = s.alerts.Clear(id)
+	default:
+		writeJSONWithStatus(w, map[string]any{"error": "unknown action (want ack or clear)"}, http.StatusNotFound)
+		return
+	}
+	if !ok {
+		writeJSONWithStatus(w, map[string]any{"error": "alert not found"}, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAlertsStream serves GET /api/alerts/stream: SSE "alerts" events,
+// starting with a snapshot of the active alerts.
+func (s *Server) handleAlertsStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	id, ch := s.alerts.Subscribe()
+	defer s.alerts.Unsubscribe(id)
+	locale := s.requestLocale(r)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ctx := r.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-ch:
+			if _, err := fmt.Fprint(w, "event: alerts\n"); err != nil {
+				return
+			}
+			if err := writeSSE(w, ev.In(locale)); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-time.After(30 * time.Second):
+			if _, err := fmt.Fprintf(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package webmonitor
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+func TestAlertCenter_RaiseAckResolve(t *testing.T) {
+	c := NewAlertCenter()
+	id, ch := c.Subscribe()
+	defer c.Unsubscribe(id)
+	if ev := <-ch; ev.Action != alertSnapshot || len(ev.Alerts) != 0 {
+		t.Fatalf("first event %+v, want empty snapshot", ev)
+	}
+
+	a := c.Raise("detector", "detector:stale", AlertWarning, "stale")
+	if !c.Ack(a.ID) {
+		t.Fatal("ack failed")
+	}
+	// Repeat at a higher severity: same alert, un-acked.
+	c.Raise("detector", "detector:stale", AlertCritical, "offline")
+	active, unacked := c.Active()
+	if len(active) != 1 || unacked != 1 || active[0].Count != 2 || active[0].Severity != AlertCritical || active[0].Message != "offline" {
+		t.Fatalf("active %+v unacked %d", active, unacked)
+	}
+
+	c.Resolve("detector:stale")
+	if active, _ := c.Active(); len(active) != 0 {
+		t.Fatalf("still active after resolve: %+v", active)
+	}
+
+	var actions []string
+	for range 4 {
+		actions = append(actions, (<-ch).Action)
+	}
+	if got := strings.Join(actions, ","); got != "raised,acked,updated,cleared" {
+		t.Fatalf("events %s", got)
+	}
+}
+
+func TestAlertFromLog(t *testing.T) {
+	c := NewAlertCenter()
+	hook := logger.Register(logger.HookFunc(c.alertFromLog), logger.HookOptions{MinLevel: logger.WARN})
+	defer hook.Unregister()
+
+	l := logger.New(logger.INFO, &strings.Builder{}, false)
+	l.Info("Recorder", "not an alert")
+	l.Error("Systemd", "skipped: has a dedicated alert")
+	l.Warn("Recorder", "write failed: %v", "EIO")
+	l.Warn("Recorder", "write failed: %v", "ENOSPC")
+
+	// Delivery is asynchronous.
+	deadline := time.Now().Add(2 * time.Second)
+	var active []Alert
+	for time.Now().Before(deadline) {
+		if active, _ = c.Active(); len(active) == 1 && active[0].Count == 2 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(active) != 1 || active[0].Count != 2 || active[0].Message != "[Recorder] write failed: ENOSPC" {
+		t.Fatalf("active %+v", active)
+	}
+}
+
+func TestHandleAlert(t *testing.T) {
+	s := &Server{alerts: NewAlertCenter()}
+	a := s.alerts.Raise("disk", diskAlertKey, AlertWarning, "low")
+
+	w := httptest.NewRecorder()
+	s.handleAlert(w, httptest.NewRequest("POST", "/api/alerts/99/ack", nil))
+	if w.Code != 404 {
+		t.Fatalf("unknown id: status %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleAlert(w, httptest.NewRequest("POST", "/api/alerts/1/ack", nil))
+	if w.Code != 204 {
+		t.Fatalf("ack: status %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleAlerts(w, httptest.NewRequest("GET", "/api/alerts", nil))
+	var body struct {
+		Alerts  []Alert `json:"alerts"`
+		Unacked int     `json:"unacked"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Alerts) != 1 || body.Alerts[0].ID != a.ID || !body.Alerts[0].Acked || body.Unacked != 0 {
+		t.Fatalf("body %+v", body)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleAlert(w, httptest.NewRequest("POST", "/api/alerts/1/clear", nil))
+	if active, _ := s.alerts.Active(); w.Code != 204 || len(active) != 0 {
+		t.Fatalf("clear: status %d, active %+v", w.Code, active)
+	}
+}
@@ -0,0 +1,61 @@
+package webmonitor
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/eventbus"
+)
+
+func TestAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	a, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	topics := newBusTopics(eventbus.New())
+	alerts := NewAlertCenter()
+	auditTopic(a, topics.events)
+	auditTopic(a, alerts.Topic())
+
+	topics.events.Publish(Event{ID: 1, Type: EventRecordingStarted})
+	alerts.Raise("disk", diskAlertKey, AlertWarning, "low")
+	topics.detections.Publish(&DetectionResult{}) // not audited
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var topicsSeen []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e struct {
+			Topic string         `json:"topic"`
+			Data  map[string]any `json:"data"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("line %q: %v", sc.Text(), err)
+		}
+		topicsSeen = append(topicsSeen, e.Topic)
+		switch e.Topic {
+		case "events":
+			if e.Data["type"] != EventRecordingStarted {
+				t.Errorf("event entry %v", e.Data)
+			}
+		case "alerts":
+			if e.Data["action"] != alertRaised {
+				t.Errorf("alert entry %v", e.Data)
+			}
+		}
+	}
+	if len(topicsSeen) != 2 {
+		t.Fatalf("audited topics %v, want events and alerts", topicsSeen)
+	}
+}
@@ -1,6 +1,7 @@
 package webmonitor
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -9,16 +10,18 @@ import (
 	"net/http"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/buildinfo"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/clock"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/crash"
 	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/shm"
 	pb "github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/proto"
 	"google.golang.org/protobuf/proto"
 )
 
-// Cached timezone for overlay rendering (avoid allocation per frame)
-var jstTimezone = time.FixedZone("JST", 9*3600)
-
 // labelCache holds pre-rendered RGBA label images, keyed by text.
 // Re-rendered only when detection version changes.
 type labelCache struct {
@@ -31,57 +34,168 @@ type cachedLabel struct {
 	x, y int // Position on NV12 frame
 }
 
+// FrameConsumer is what a FrameBroadcaster subscriber does with the frames.
+// WebRTC viewers never subscribe (they get the camera's H.265 stream), so
+// with only WebRTC viewers connected nothing is overlaid or JPEG-encoded.
+type FrameConsumer int
+
+const (
+	ConsumerMJPEG    FrameConsumer = iota // multipart /stream viewer (counted as an MJPEG viewer)
+	ConsumerSnapshot                      // waits for one overlaid frame (/frame.jpg?overlay=true)
+)
+
+func (k FrameConsumer) String() string {
+	if k == ConsumerSnapshot {
+		return "snapshot"
+	}
+	return "mjpeg"
+}
+
 // FrameBroadcaster manages fanout of JPEG frames to multiple clients.
 type FrameBroadcaster struct {
-	mu                sync.Mutex
-	clients           map[int]chan []byte
-	nextID            int
-	shm               *shmReader
-	monitor           *Monitor
-	stop              chan struct{}
-	stopped           bool
-	onChange          chan<- struct{} // Notifies connection count changes
-	frameBroadcastBuf []chan []byte   // Reusable snapshot slice to avoid per-broadcast allocation
-	ttLabelCache      labelCache      // TrueType label cache (re-rendered on detection change)
+	mu                 sync.Mutex
+	clients            map[int]chan []byte
+	connectedAt        map[int]time.Time // per-client subscribe time (viewer list)
+	nextID             int
+	shm                *shmReader
+	monitor            *Monitor
+	stop               chan struct{}
+	stopped            bool
+	onChange           chan<- struct{} // Notifies connection count changes
+	frameBroadcastBuf  []chan []byte   // Reusable snapshot slice to avoid per-broadcast allocation
+	frameBroadcastData [][]byte        // frame for each frameBroadcastBuf entry (full or follow crop)
+	ttLabelCache       labelCache      // TrueType label cache (re-rendered on detection change)
+	frameDivisor       int             // generate one frame every N ticks (CPU degradation); guarded by mu
+	clientBuffer       int             // per-client frame queue
+	dup                shm.DupGuard    // skips re-encoding a frame already broadcast; run goroutine only
+
+	// Adaptive encode cost (nil = fixed quality and resolution)
+	quality *MJPEGQualityController
+	halfBuf []byte // half-resolution NV12 scratch; run goroutine only
+
+	// Digital pan/zoom for ProfileFollow viewers (nil = they get the full frame)
+	follow    *PetFollow
+	followers map[int]bool // ProfileFollow subscribers; guarded by mu
+	cropBuf   []byte       // cropped NV12 scratch; run goroutine only
+
+	maskOverlay func() []Mask // ignore masks to outline (nil or empty = none)
+
+	// Encode accounting: frames encoded, and camera frames not encoded
+	// because no MJPEG or snapshot consumer was subscribed
+	kinds    map[int]FrameConsumer // guarded by mu
+	encoded  atomic.Uint64
+	skipped  atomic.Uint64
+	skipFrom uint64 // frame number the skipped count continues from; run goroutine only
 }
 
 // NewFrameBroadcaster creates a broadcaster that generates overlay frames and fans them out.
 func NewFrameBroadcaster(shm *shmReader, monitor *Monitor, onChange chan<- struct{}) *FrameBroadcaster {
 	return &FrameBroadcaster{
-		clients:  make(map[int]chan []byte),
-		shm:      shm,
-		monitor:  monitor,
-		stop:     make(chan struct{}),
-		onChange: onChange,
+		clients:      make(map[int]chan []byte),
+		connectedAt:  make(map[int]time.Time),
+		kinds:        make(map[int]FrameConsumer),
+		followers:    make(map[int]bool),
+		shm:          shm,
+		monitor:      monitor,
+		stop:         make(chan struct{}),
+		onChange:     onChange,
+		clientBuffer: defaultClientBuffer,
 	}
 }
 
-// Subscribe adds a new client and returns a channel for receiving frames.
+// SetFrameDivisor makes the broadcaster generate only every nth frame
+// (1 = full rate). Used to shed overlay/JPEG work under CPU pressure.
+func (fb *FrameBroadcaster) SetFrameDivisor(n int) {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	fb.frameDivisor = n
+}
+
+// SetQualityController enables adaptive JPEG quality and resolution driven
+// by the encode time of each frame. Call before Start.
+func (fb *FrameBroadcaster) SetQualityController(c *MJPEGQualityController) {
+	fb.quality = c
+}
+
+// SetFollow enables the ProfileFollow crop. Call before Start.
+func (fb *FrameBroadcaster) SetFollow(f *PetFollow) {
+	fb.follow = f
+}
+
+// SetMaskOverlay sets the source of the ignore masks outlined on the
+// overlay. Call before Start.
+func (fb *FrameBroadcaster) SetMaskOverlay(masks func() []Mask) {
+	fb.maskOverlay = masks
+}
+
+// Subscribe adds a new MJPEG viewer and returns a channel for receiving frames.
 func (fb *FrameBroadcaster) Subscribe() (int, <-chan []byte) {
+	return fb.SubscribeAs(ConsumerMJPEG)
+}
+
+// SubscribeProfile adds a new MJPEG viewer that is sent the given profile.
+func (fb *FrameBroadcaster) SubscribeProfile(p StreamProfile) (int, <-chan []byte) {
+	id, ch := fb.SubscribeAs(ConsumerMJPEG)
+	if p == ProfileFollow {
+		fb.mu.Lock()
+		if _, ok := fb.clients[id]; ok {
+			fb.followers[id] = true
+		}
+		fb.mu.Unlock()
+	}
+	return id, ch
+}
+
+// SubscribeAs adds a new client of the given kind and returns a channel for
+// receiving frames. Only MJPEG viewers count as viewers.
+func (fb *FrameBroadcaster) SubscribeAs(kind FrameConsumer) (int, <-chan []byte) {
 	fb.mu.Lock()
 	id := fb.nextID
 	fb.nextID++
-	ch := make(chan []byte, 4) // Buffer 4 frames to absorb network jitter
+	ch := make(chan []byte, fb.clientBuffer) // absorbs network jitter
 	fb.clients[id] = ch
-	logger.Debug("FrameBroadcaster", "Client #%d subscribed (total clients: %d)", id, len(fb.clients))
+	fb.kinds[id] = kind
+	if kind == ConsumerMJPEG {
+		fb.connectedAt[id] = time.Now()
+	}
+	logger.Debug("FrameBroadcaster", "Client #%d (%s) subscribed (total clients: %d)", id, kind, len(fb.clients))
 	fb.mu.Unlock()
 
-	fb.notifyChange()
+	if kind == ConsumerMJPEG {
+		fb.notifyChange()
+	}
 	return id, ch
 }
 
+// NextFrame subscribes as a snapshot consumer and returns the next frame
+// the broadcaster encodes, overlay included, or nil if ctx ends first.
+func (fb *FrameBroadcaster) NextFrame(ctx context.Context) []byte {
+	id, ch := fb.SubscribeAs(ConsumerSnapshot)
+	defer fb.Unsubscribe(id)
+	select {
+	case data := <-ch:
+		return data
+	case <-ctx.Done():
+		return nil
+	}
+}
+
 // Unsubscribe removes a client.
 func (fb *FrameBroadcaster) Unsubscribe(id int) {
 	fb.mu.Lock()
 	removed := false
 	if ch, ok := fb.clients[id]; ok {
 		close(ch)
+		kind := fb.kinds[id]
 		delete(fb.clients, id)
-		removed = true
-		logger.Debug("FrameBroadcaster", "Client #%d unsubscribed (remaining clients: %d)", id, len(fb.clients))
-
-		if len(fb.clients) == 0 {
-			logger.Info("FrameBroadcaster", "No clients remaining - frame generation will be skipped")
+		delete(fb.connectedAt, id)
+		delete(fb.kinds, id)
+		delete(fb.followers, id)
+		removed = kind == ConsumerMJPEG
+		logger.Debug("FrameBroadcaster", "Client #%d (%s) unsubscribed (remaining clients: %d)", id, kind, len(fb.clients))
+
+		if removed && len(fb.clients) == 0 {
+			logger.Info("FrameBroadcaster", "No MJPEG clients remaining - frame generation will be skipped")
 		}
 	}
 	fb.mu.Unlock()
@@ -111,6 +225,7 @@ func (fb *FrameBroadcaster) run() {
 	ticker := time.NewTicker(33 * time.Millisecond)
 	defer ticker.Stop()
 
+	tick := 0
 	for {
 		select {
 		case <-fb.stop:
@@ -120,36 +235,94 @@ func (fb *FrameBroadcaster) run() {
 
 		fb.mu.Lock()
 		clientCount := len(fb.clients)
+		followCount := len(fb.followers)
+		divisor := fb.frameDivisor
 		fb.mu.Unlock()
 
 		if clientCount == 0 {
-			// No clients — save CPU by sleeping instead of polling SHM
+			// No MJPEG or snapshot consumer (WebRTC viewers alone need no
+			// JPEG) — save CPU by sleeping instead of polling SHM
+			fb.countSkipped()
 			time.Sleep(100 * time.Millisecond)
 			continue
 		}
+		fb.skipFrom = 0
 
-		var jpegData []byte
-		if fb.shm != nil {
-			jpegData = fb.generateOverlay()
+		tick++
+		if divisor > 1 && tick%divisor != 0 {
+			continue
 		}
 
-		if jpegData == nil {
+		if fb.shm == nil {
+			continue
+		}
+		wantFollow := fb.follow != nil && followCount > 0
+		full, cropped := fb.generateOverlay(!wantFollow || followCount < clientCount, wantFollow)
+		if full == nil && cropped == nil {
 			continue
 		}
 
-		fb.broadcast(jpegData)
+		fb.broadcastFrames(full, cropped)
 	}
 }
 
-func (fb *FrameBroadcaster) generateOverlay() []byte {
+// DuplicatesSkipped returns how many polls found the frame already broadcast.
+func (fb *FrameBroadcaster) DuplicatesSkipped() uint64 {
+	return fb.dup.Skipped()
+}
+
+// EncodeStats returns how many frames were JPEG-encoded and how many camera
+// frames went unencoded because no MJPEG or snapshot consumer was subscribed.
+func (fb *FrameBroadcaster) EncodeStats() (encoded, skipped uint64) {
+	return fb.encoded.Load(), fb.skipped.Load()
+}
+
+// Consumers returns the subscriber count of each kind.
+func (fb *FrameBroadcaster) Consumers() map[FrameConsumer]int {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	out := map[FrameConsumer]int{ConsumerMJPEG: 0, ConsumerSnapshot: 0}
+	for _, kind := range fb.kinds {
+		out[kind]++
+	}
+	return out
+}
+
+// countSkipped adds the camera frames produced since the previous idle poll
+// to the skipped-encode count. It reads only the frame number, not pixels.
+func (fb *FrameBroadcaster) countSkipped() {
 	if fb.shm == nil {
-		return nil
+		return
+	}
+	n, ok := fb.shm.LatestFrameNumber()
+	if !ok {
+		return
+	}
+	fb.skipped.Add(framesSince(fb.skipFrom, n))
+	fb.skipFrom = n
+}
+
+// framesSince returns how many frames follow from up to n; 0 without a
+// starting point or when numbering restarted with the camera.
+func framesSince(from, n uint64) uint64 {
+	if from == 0 || n <= from {
+		return 0
+	}
+	return n - from
+}
+
+// generateOverlay draws the overlay on the latest frame and encodes the
+// whole frame if wantFull and the ProfileFollow crop if wantFollow.
+func (fb *FrameBroadcaster) generateOverlay(wantFull, wantFollow bool) (full, cropped []byte) {
+	defer crash.Recover("mjpeg-overlay") // drop this frame, keep streaming
+	if fb.shm == nil {
+		return nil, nil
 	}
 
 	// Zero-copy: Get frame reference without copying
 	frame, ok := fb.shm.LatestFrame()
-	if !ok {
-		return nil
+	if !ok || fb.dup.Duplicate(frame.FrameNumber) {
+		return nil, nil
 	}
 
 	// Get latest detection (only if fresh - within 30 frames of current frame)
@@ -165,15 +338,16 @@ func (fb *FrameBroadcaster) generateOverlay() []byte {
 	}
 	fb.monitor.mu.Unlock()
 
-	// NV12: draw overlay then HW JPEG encode
+	// NV12: draw overlay then HW JPEG encode. Camera JPEGs cannot be
+	// cropped without a decode, so followers get them whole.
 	if frame.Format != formatNV12 {
-		return frame.Data
+		return frame.Data, nil
 	}
 
 	var rects []overlayRect
 
 	// Stats text (bitmap — ASCII only, fast)
-	timeStr := frame.Timestamp.In(jstTimezone).Format("2006/01/02 15:04:05")
+	timeStr := frame.Timestamp.In(clock.Location()).Format("2006/01/02 15:04:05")
 	stats := fmt.Sprintf("Frame: %d  Time: %s", frame.FrameNumber, timeStr)
 	statsTexts := []overlayText{
 		{x: 10, y: 10, text: stats, textY: 235, bgY: 16, scale: 2},
@@ -194,6 +368,11 @@ func (fb *FrameBroadcaster) generateOverlay() []byte {
 
 	// Draw stats + bboxes via C bitmap (fast path)
 	drawOverlay(frame.Data, frame.Width, frame.Height, rects, statsTexts)
+	if fb.maskOverlay != nil {
+		if masks := fb.maskOverlay(); len(masks) > 0 {
+			drawMaskOutlines(frame.Data, frame.Width, frame.Height, masks)
+		}
+	}
 
 	// TrueType labels: re-render only when detection version changes
 	detVersion := 0
@@ -249,24 +428,73 @@ func (fb *FrameBroadcaster) generateOverlay() []byte {
 		blendRGBAOnNV12(frame.Data, frame.Width, frame.Height, cl.img, cl.x, cl.y)
 	}
 
-	jpegData, err := nv12ToJPEG(frame.Data, frame.Width, frame.Height)
+	if wantFollow {
+		// The crop is already small; it skips the half-resolution step
+		x, y, cw, ch := fb.follow.Window(frame.Width, frame.Height, time.Now())
+		if n := cw * ch * 3 / 2; cap(fb.cropBuf) < n {
+			fb.cropBuf = make([]byte, n)
+		} else {
+			fb.cropBuf = fb.cropBuf[:n]
+		}
+		nv12Crop(fb.cropBuf, frame.Data, frame.Width, frame.Height, x, y, cw, ch)
+		if data, err := nv12ToJPEG(fb.cropBuf, cw, ch); err == nil {
+			cropped = data
+			fb.encoded.Add(1)
+		}
+	}
+	if !wantFull {
+		return nil, cropped
+	}
+
+	start := time.Now()
+	data, w, h := frame.Data, frame.Width, frame.Height
+	if fb.quality.Half() && w%4 == 0 && h%4 == 0 {
+		if n := (w / 2) * (h / 2) * 3 / 2; cap(fb.halfBuf) < n {
+			fb.halfBuf = make([]byte, n)
+		} else {
+			fb.halfBuf = fb.halfBuf[:n]
+		}
+		nv12Half(fb.halfBuf, data, w, h)
+		data, w, h = fb.halfBuf, w/2, h/2
+	}
+	jpegData, err := nv12ToJPEG(data, w, h)
 	if err != nil {
-		return nil
+		return nil, cropped
 	}
-	return jpegData
+	fb.quality.Observe(time.Since(start))
+	fb.encoded.Add(1)
+	return jpegData, cropped
 }
 
 func (fb *FrameBroadcaster) broadcast(data []byte) {
+	fb.broadcastFrames(data, nil)
+}
+
+// broadcastFrames sends cropped to ProfileFollow viewers and full to
+// everyone else. A nil frame falls back to the other one.
+func (fb *FrameBroadcaster) broadcastFrames(full, cropped []byte) {
+	if full == nil {
+		full = cropped
+	}
+	if cropped == nil {
+		cropped = full
+	}
 	fb.mu.Lock()
 	fb.frameBroadcastBuf = fb.frameBroadcastBuf[:0]
-	for _, ch := range fb.clients {
+	fb.frameBroadcastData = fb.frameBroadcastData[:0]
+	for id, ch := range fb.clients {
+		data := full
+		if fb.followers[id] {
+			data = cropped
+		}
 		fb.frameBroadcastBuf = append(fb.frameBroadcastBuf, ch)
+		fb.frameBroadcastData = append(fb.frameBroadcastData, data)
 	}
 	fb.mu.Unlock()
 
-	for _, ch := range fb.frameBroadcastBuf {
+	for i, ch := range fb.frameBroadcastBuf {
 		select {
-		case ch <- data:
+		case ch <- fb.frameBroadcastData[i]:
 			// Sent successfully
 		default:
 			// Client too slow, skip this frame for this client
@@ -278,7 +506,7 @@ func (fb *FrameBroadcaster) broadcast(data []byte) {
 func (fb *FrameBroadcaster) GetClientCount() int {
 	fb.mu.Lock()
 	defer fb.mu.Unlock()
-	return len(fb.clients)
+	return len(fb.connectedAt)
 }
 
 func (fb *FrameBroadcaster) notifyChange() {
@@ -304,7 +532,7 @@ type DetectionBroadcaster struct {
 	mu               sync.Mutex
 	clients          map[int]chan *SerializedEvent // Channel carries pre-serialized data
 	nextID           int
-	shm              *shmReader
+	source           DetectionSource
 	monitor          *Monitor
 	stop             chan struct{}
 	stopped          bool
@@ -313,6 +541,9 @@ type DetectionBroadcaster struct {
 	onDetection      func()                 // Callback when detection with objects occurs
 	onDetectionData  func(*DetectionResult) // Callback with detection data
 
+	// Drops unwanted detections before any callback or client sees them
+	filter func([]Detection) []Detection
+
 	// Rate monitoring
 	broadcastCount  int
 	lastRateLogTime time.Time
@@ -322,16 +553,19 @@ type DetectionBroadcaster struct {
 	lastEmptyLogTime time.Time
 
 	detectionBroadcastBuf []chan *SerializedEvent // Reusable snapshot slice to avoid per-broadcast allocation
+	clientBuffer          int                     // per-client event queue
 }
 
 // NewDetectionBroadcaster creates a broadcaster for detection events.
-func NewDetectionBroadcaster(shm *shmReader, monitor *Monitor, onChange chan<- struct{}) *DetectionBroadcaster {
+// source is nil when no detector input is available.
+func NewDetectionBroadcaster(source DetectionSource, monitor *Monitor, onChange chan<- struct{}) *DetectionBroadcaster {
 	return &DetectionBroadcaster{
-		clients:  make(map[int]chan *SerializedEvent),
-		shm:      shm,
-		monitor:  monitor,
-		stop:     make(chan struct{}),
-		onChange: onChange,
+		clients:      make(map[int]chan *SerializedEvent),
+		source:       source,
+		monitor:      monitor,
+		stop:         make(chan struct{}),
+		onChange:     onChange,
+		clientBuffer: defaultClientBuffer,
 	}
 }
 
@@ -348,7 +582,7 @@ func (db *DetectionBroadcaster) Subscribe() (int, <-chan *SerializedEvent) {
 	db.mu.Lock()
 	id := db.nextID
 	db.nextID++
-	ch := make(chan *SerializedEvent, 4) // Buffer 4 events to absorb network jitter
+	ch := make(chan *SerializedEvent, db.clientBuffer) // absorbs network jitter
 	db.clients[id] = ch
 	logger.Debug("DetectionBroadcaster", "Client #%d subscribed (total clients: %d)", id, len(db.clients))
 	db.mu.Unlock()
@@ -404,15 +638,18 @@ func (db *DetectionBroadcaster) run() {
 		default:
 		}
 
-		if db.shm == nil {
+		if db.source == nil {
 			time.Sleep(1 * time.Second)
 			continue
 		}
 
-		// Check if detection daemon has written at least once
-		if det, ok := db.shm.LatestDetection(); ok && det.Version > 0 {
-			logger.Info("DetectionBroadcaster", "Detection daemon initialized (version=%d)", det.Version)
-			db.lastEventVersion = det.Version
+		// Check if detection daemon has written at least once. Ask the
+		// Monitor: it shares the source with other pollers (status,
+		// timeseries), and a version one of them consumed is not new to
+		// the source any more.
+		if version := db.monitor.DetectionVersion(); version > 0 {
+			logger.Info("DetectionBroadcaster", "Detection daemon initialized (version=%d)", version)
+			db.lastEventVersion = version
 			break
 		}
 
@@ -437,7 +674,7 @@ func (db *DetectionBroadcaster) run() {
 		default:
 		}
 
-		if db.shm == nil {
+		if db.source == nil {
 			time.Sleep(100 * time.Millisecond)
 			continue
 		}
@@ -468,7 +705,7 @@ func (db *DetectionBroadcaster) run() {
 		// Replaces 33ms polling: blocks until Python detector posts sem,
 		// or 100ms timeout (to re-check stop/client state).
 		// Accumulated sem_posts are harmless — version check below skips duplicates.
-		db.shm.WaitDetectionUpdate(100) // 100ms timeout
+		db.source.WaitDetectionUpdate(100) // 100ms timeout
 		db.monitor.mu.Lock()
 		db.monitor.refreshFromSharedMemoryLocked()
 
@@ -506,13 +743,47 @@ func (db *DetectionBroadcaster) SetOnDetectionData(callback func(*DetectionResul
 	db.onDetectionData = callback
 }
 
+// SetFilter sets a function applied to every result's detections before
+// the callbacks and SSE clients see them; a result left without detections
+// is dropped.
+func (db *DetectionBroadcaster) SetFilter(filter func([]Detection) []Detection) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.filter = filter
+}
+
+// Publish injects a detection result from a non-SHM source (e.g. the motion
+// fallback) into the same callback and SSE path as daemon detections.
+func (db *DetectionBroadcaster) Publish(det *DetectionResult) {
+	if det == nil || len(det.Detections) == 0 {
+		return
+	}
+	if db.monitor != nil {
+		db.monitor.prepare(det)
+	}
+	db.processAndBroadcast(det)
+}
+
 // processAndBroadcast pre-serializes detection result to both formats and broadcasts
 func (db *DetectionBroadcaster) processAndBroadcast(det *DetectionResult) {
+	defer crash.Recover("detection-broadcast") // skip this result, keep the stream
 	// Notify callbacks
 	db.mu.Lock()
 	callback := db.onDetection
 	dataCallback := db.onDetectionData
+	filter := db.filter
 	db.mu.Unlock()
+	if filter != nil {
+		// Results from the Monitor are already filtered, so this only
+		// changes injected ones (motion fallback, forwarder), which nothing
+		// else shares yet
+		if kept := filter(det.Detections); len(kept) != len(det.Detections) {
+			det.Detections, det.NumDetections = kept, len(kept)
+		}
+		if len(det.Detections) == 0 {
+			return
+		}
+	}
 	if callback != nil {
 		callback()
 	}
@@ -538,6 +809,10 @@ func (db *DetectionBroadcaster) processAndBroadcast(det *DetectionResult) {
 		"frame_number": det.FrameNumber,
 		"timestamp":    det.Timestamp,
 		"detections":   convertDetectionsToJSON(det.Detections),
+		"frame":        frameOf(det.Frame),
+	}
+	if det.Source != "" {
+		jsonEvent["source"] = det.Source
 	}
 	jsonData, err := json.Marshal(jsonEvent)
 	if err != nil {
@@ -550,6 +825,7 @@ func (db *DetectionBroadcaster) processAndBroadcast(det *DetectionResult) {
 		FrameNumber: uint64(det.FrameNumber),
 		Timestamp:   det.Timestamp,
 		Detections:  convertDetectionsToProto(det.Detections),
+		Frame:       convertFrameToProto(det.Frame),
 	}
 	pbData, err := proto.Marshal(pbEvent)
 	if err != nil {
@@ -582,6 +858,12 @@ func convertDetectionsToJSON(detections []Detection) []map[string]interface{} {
 			"class_id":   0,
 			"class_name": d.ClassName,
 		}
+		if d.PetID != "" {
+			result[i]["pet_id"] = d.PetID
+		}
+		if d.BBoxNorm != nil {
+			result[i]["bbox_norm"] = d.BBoxNorm
+		}
 	}
 	return result
 }
@@ -590,21 +872,47 @@ func convertDetectionsToJSON(detections []Detection) []map[string]interface{} {
 func convertDetectionsToProto(detections []Detection) []*pb.Detection {
 	result := make([]*pb.Detection, len(detections))
 	for i, d := range detections {
-		result[i] = &pb.Detection{
-			Bbox: &pb.BBox{
-				X: int32(d.BBox.X),
-				Y: int32(d.BBox.Y),
-				W: int32(d.BBox.W),
-				H: int32(d.BBox.H),
-			},
-			Confidence: float32(d.Confidence),
-			ClassId:    0,
-			Label:      d.ClassName,
-		}
+		result[i] = convertDetectionToProto(d)
 	}
 	return result
 }
 
+// convertDetectionToProto converts one detection to Protobuf format
+func convertDetectionToProto(d Detection) *pb.Detection {
+	pd := &pb.Detection{
+		Bbox: &pb.BBox{
+			X: int32(d.BBox.X),
+			Y: int32(d.BBox.Y),
+			W: int32(d.BBox.W),
+			H: int32(d.BBox.H),
+		},
+		Confidence: float32(d.Confidence),
+		ClassId:    0,
+		Label:      d.ClassName,
+		PetId:      d.PetID,
+	}
+	if n := d.BBoxNorm; n != nil {
+		pd.BboxNorm = &pb.NormBBox{X: float32(n.X), Y: float32(n.Y), W: float32(n.W), H: float32(n.H)}
+	}
+	return pd
+}
+
+// convertFrameToProto converts a result's frame to Protobuf format, the
+// reference frame when it has none
+func convertFrameToProto(f *DetectionFrame) *pb.DetectionFrame {
+	f = frameOf(f)
+	pf := &pb.DetectionFrame{Width: int32(f.Width), Height: int32(f.Height)}
+	if in := f.Detector; in != nil {
+		c := in.content()
+		pf.Detector = &pb.DetectorInput{
+			Width:   int32(in.Width),
+			Height:  int32(in.Height),
+			Content: &pb.BBox{X: int32(c.X), Y: int32(c.Y), W: int32(c.W), H: int32(c.H)},
+		}
+	}
+	return pf
+}
+
 func (db *DetectionBroadcaster) broadcast(event *SerializedEvent) {
 	db.mu.Lock()
 	db.detectionBroadcastBuf = db.detectionBroadcastBuf[:0]
@@ -642,35 +950,86 @@ func (db *DetectionBroadcaster) notifyChange() {
 // StatusBroadcaster manages fanout of status events to multiple SSE clients.
 // Pre-serializes both JSON and Protobuf formats for efficiency.
 type StatusBroadcaster struct {
-	mu       sync.Mutex
-	clients  map[int]chan *SerializedEvent // Channel carries pre-serialized data
-	nextID   int
-	shm      *shmReader
-	monitor  *Monitor
-	stop     chan struct{}
-	stopped  bool
-	interval time.Duration
-	onChange chan<- struct{}
+	mu        sync.Mutex
+	clients   map[int]chan *SerializedEvent // Channel carries pre-serialized data
+	nextID    int
+	shm       *shmReader
+	monitor   *Monitor
+	stop      chan struct{}
+	stopped   bool
+	interval  time.Duration
+	onChange  chan<- struct{}
+	health    func() DetectionHealthStatus // Optional detection daemon health source
+	viewers   func() Viewers               // Optional viewer list source
+	recording func() RecordingStatus       // Optional active recording source
+	quality   func() MJPEGQualityStatus    // Optional adaptive MJPEG quality source
+	power     func() PowerSaverStatus      // Optional power-saving state (JSON events only)
+	lighting  func() LightingStatus        // Optional day/night state (JSON events only)
+
+	clientBuffer int // per-client event queue
 }
 
 // NewStatusBroadcaster creates a broadcaster for status events.
 func NewStatusBroadcaster(shm *shmReader, monitor *Monitor, interval time.Duration, onChange chan<- struct{}) *StatusBroadcaster {
 	return &StatusBroadcaster{
-		clients:  make(map[int]chan *SerializedEvent),
-		shm:      shm,
-		monitor:  monitor,
-		stop:     make(chan struct{}),
-		interval: interval,
-		onChange: onChange,
+		clients:      make(map[int]chan *SerializedEvent),
+		shm:          shm,
+		monitor:      monitor,
+		stop:         make(chan struct{}),
+		interval:     interval,
+		onChange:     onChange,
+		clientBuffer: defaultClientBuffer,
 	}
 }
 
+// SetDetectionHealth sets the source of detection daemon health included in status events.
+func (sb *StatusBroadcaster) SetDetectionHealth(health func() DetectionHealthStatus) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.health = health
+}
+
+// SetViewers sets the source of the viewer counts included in status events.
+func (sb *StatusBroadcaster) SetViewers(viewers func() Viewers) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.viewers = viewers
+}
+
+// SetRecording sets the source of the active recording included in status events.
+func (sb *StatusBroadcaster) SetRecording(recording func() RecordingStatus) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.recording = recording
+}
+
+// SetMJPEGQuality sets the source of the adaptive MJPEG setting included in status events.
+func (sb *StatusBroadcaster) SetMJPEGQuality(quality func() MJPEGQualityStatus) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.quality = quality
+}
+
+// SetPower sets the source of the power-saving state included in JSON status events.
+func (sb *StatusBroadcaster) SetPower(power func() PowerSaverStatus) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.power = power
+}
+
+// SetLighting sets the source of the day/night state included in JSON status events.
+func (sb *StatusBroadcaster) SetLighting(lighting func() LightingStatus) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.lighting = lighting
+}
+
 // Subscribe adds a new client and returns a channel for receiving status events.
 func (sb *StatusBroadcaster) Subscribe() (int, <-chan *SerializedEvent) {
 	sb.mu.Lock()
 	id := sb.nextID
 	sb.nextID++
-	ch := make(chan *SerializedEvent, 4) // Buffer 4 events to absorb network jitter
+	ch := make(chan *SerializedEvent, sb.clientBuffer) // absorbs network jitter
 	sb.clients[id] = ch
 	logger.Debug("StatusBroadcaster", "Client #%d subscribed (total clients: %d)", id, len(sb.clients))
 	sb.mu.Unlock()
@@ -742,12 +1101,62 @@ func (sb *StatusBroadcaster) run() {
 }
 
 func (sb *StatusBroadcaster) generateSerializedEvent() *SerializedEvent {
+	defer crash.Recover("status-broadcast")
 	// Get snapshot from monitor
 	monitorStats, shmStats, latest, history := sb.monitor.Snapshot()
 	timestamp := float64(time.Now().Unix())
 
+	sb.mu.Lock()
+	healthFn := sb.health
+	viewersFn := sb.viewers
+	recordingFn := sb.recording
+	qualityFn := sb.quality
+	powerFn := sb.power
+	lightingFn := sb.lighting
+	sb.mu.Unlock()
+	var health *DetectionHealthStatus
+	if healthFn != nil {
+		st := healthFn()
+		health = &st
+	}
+	var viewers *Viewers
+	if viewersFn != nil {
+		v := viewersFn()
+		viewers = &v
+	}
+	var recording *RecordingStatus
+	if recordingFn != nil {
+		rs := recordingFn()
+		recording = &rs
+	}
+	var quality *MJPEGQualityStatus
+	if qualityFn != nil {
+		q := qualityFn()
+		quality = &q
+	}
+
 	// Build JSON directly from Go structs (no Protobuf intermediate)
 	jsonEvent := sb.buildJSONStatus(monitorStats, shmStats, latest, history, timestamp)
+	if health != nil {
+		jsonEvent["detector_health"] = health
+	}
+	if viewers != nil {
+		jsonEvent["viewers"] = viewers
+	}
+	if recording != nil {
+		jsonEvent["recording"] = recording
+	}
+	if quality != nil {
+		jsonEvent["mjpeg_quality"] = quality
+	}
+	if powerFn != nil {
+		jsonEvent["power"] = powerFn()
+	}
+	if lightingFn != nil {
+		jsonEvent["lighting"] = lightingFn()
+	}
+	info := buildinfo.Get()
+	jsonEvent["server_info"] = info
 	jsonData, err := json.Marshal(jsonEvent)
 	if err != nil {
 		logger.Error("StatusBroadcaster", "JSON marshal error: %v", err)
@@ -756,6 +1165,30 @@ func (sb *StatusBroadcaster) generateSerializedEvent() *SerializedEvent {
 
 	// Build Protobuf
 	pbEvent := sb.buildProtoStatus(monitorStats, shmStats, latest, history, timestamp)
+	if health != nil {
+		pbEvent.DetectorHealth = &pb.DetectorHealth{
+			Healthy:        health.Healthy,
+			StaleSeconds:   health.StaleSeconds,
+			Alerting:       health.Alerting,
+			MotionFallback: health.MotionFallback,
+		}
+	}
+	if viewers != nil {
+		pbEvent.Viewers = viewers.toProto()
+	}
+	if recording != nil {
+		pbEvent.Recording = recording.toProto()
+	}
+	if quality != nil {
+		pbEvent.MjpegQuality = quality.toProto()
+	}
+	pbEvent.ServerInfo = &pb.ServerInfo{
+		Version:       info.Version,
+		Commit:        info.Commit,
+		BuildDate:     info.BuildDate,
+		GoVersion:     info.GoVersion,
+		UptimeSeconds: info.UptimeSeconds,
+	}
 	pbData, err := proto.Marshal(pbEvent)
 	if err != nil {
 		logger.Error("StatusBroadcaster", "Protobuf marshal error: %v", err)
@@ -803,6 +1236,7 @@ func (sb *StatusBroadcaster) buildJSONStatus(
 			"num_detections": latest.NumDetections,
 			"version":        latest.Version,
 			"detections":     convertDetectionsToJSON(latest.Detections),
+			"frame":          frameOf(latest.Frame),
 		}
 	}
 
@@ -815,6 +1249,7 @@ func (sb *StatusBroadcaster) buildJSONStatus(
 			"num_detections": h.NumDetections,
 			"version":        h.Version,
 			"detections":     convertDetectionsToJSON(h.Detections),
+			"frame":          frameOf(h.Frame),
 		}
 	}
 
@@ -871,17 +1306,7 @@ func (sb *StatusBroadcaster) buildProtoStatus(
 func convertDetectionResultToProto(det *DetectionResult) *pb.DetectionResult {
 	pbDetections := make([]*pb.Detection, len(det.Detections))
 	for i, d := range det.Detections {
-		pbDetections[i] = &pb.Detection{
-			Bbox: &pb.BBox{
-				X: int32(d.BBox.X),
-				Y: int32(d.BBox.Y),
-				W: int32(d.BBox.W),
-				H: int32(d.BBox.H),
-			},
-			Confidence: float32(d.Confidence),
-			ClassId:    0,
-			Label:      d.ClassName,
-		}
+		pbDetections[i] = convertDetectionToProto(d)
 	}
 
 	return &pb.DetectionResult{
@@ -890,6 +1315,7 @@ func convertDetectionResultToProto(det *DetectionResult) *pb.DetectionResult {
 		NumDetections: int32(det.NumDetections),
 		Version:       int32(det.Version),
 		Detections:    pbDetections,
+		Frame:         convertFrameToProto(det.Frame),
 	}
 }
 
@@ -897,23 +1323,14 @@ func convertDetectionResultToProto(det *DetectionResult) *pb.DetectionResult {
 func convertDetectionEventToProto(det *DetectionEvent) *pb.DetectionEvent {
 	pbDetections := make([]*pb.Detection, len(det.Detections))
 	for i, d := range det.Detections {
-		pbDetections[i] = &pb.Detection{
-			Bbox: &pb.BBox{
-				X: int32(d.BBox.X),
-				Y: int32(d.BBox.Y),
-				W: int32(d.BBox.W),
-				H: int32(d.BBox.H),
-			},
-			Confidence: float32(d.Confidence),
-			ClassId:    0,
-			Label:      d.ClassName,
-		}
+		pbDetections[i] = convertDetectionToProto(d)
 	}
 
 	return &pb.DetectionEvent{
 		FrameNumber: uint64(det.FrameNumber),
 		Timestamp:   det.Timestamp,
 		Detections:  pbDetections,
+		Frame:       convertFrameToProto(det.Frame),
 	}
 }
 
@@ -921,23 +1338,14 @@ func convertDetectionEventToProto(det *DetectionEvent) *pb.DetectionEvent {
 func convertDetectionResultToEvent(det *DetectionResult) *pb.DetectionEvent {
 	pbDetections := make([]*pb.Detection, len(det.Detections))
 	for i, d := range det.Detections {
-		pbDetections[i] = &pb.Detection{
-			Bbox: &pb.BBox{
-				X: int32(d.BBox.X),
-				Y: int32(d.BBox.Y),
-				W: int32(d.BBox.W),
-				H: int32(d.BBox.H),
-			},
-			Confidence: float32(d.Confidence),
-			ClassId:    0,
-			Label:      d.ClassName,
-		}
+		pbDetections[i] = convertDetectionToProto(d)
 	}
 
 	return &pb.DetectionEvent{
 		FrameNumber: uint64(det.FrameNumber),
 		Timestamp:   det.Timestamp,
 		Detections:  pbDetections,
+		Frame:       convertFrameToProto(det.Frame),
 	}
 }
 
@@ -980,6 +1388,35 @@ type ConnectionCounts struct {
 	StatusSSE    int   `json:"status_sse"`
 	Total        int   `json:"total"`
 	Timestamp    int64 `json:"timestamp"`
+
+	// Per-session quality from the streaming server's RTCP stats
+	WebRTCSessions []WebRTCSessionStats `json:"webrtc_sessions,omitempty"`
+
+	// Streaming server camera SHM state: "attached", "waiting" ("" = unknown)
+	Camera string `json:"camera,omitempty"`
+
+	// Set while the streaming server drains (POST /admin/drain): viewers
+	// should drop WebRTC and reconnect after this many seconds
+	DrainReconnectAfter float64 `json:"drain_reconnect_after_sec,omitempty"`
+}
+
+// WebRTCSessionStats mirrors the streaming server's per-session quality
+// snapshot (/api/webrtc/stats), trimmed to what the UI shows.
+type WebRTCSessionStats struct {
+	ID            string  `json:"id"`
+	Connected     bool    `json:"connected"`
+	FramesSent    uint64  `json:"frames_sent"`
+	FramesDropped uint64  `json:"frames_dropped"`
+	BitrateKbps   float64 `json:"bitrate_kbps"`
+	UptimeSec     float64 `json:"uptime_sec"`
+	FractionLost  float64 `json:"fraction_lost"`
+	PacketsLost   int32   `json:"packets_lost"`
+	JitterMs      float64 `json:"jitter_ms"`
+	NACKCount     uint64  `json:"nack_count"`
+	Retransmits   uint64  `json:"retransmits"`
+	PLICount      uint64  `json:"pli_count"`
+	Quality       string  `json:"quality"`
+	AddressFamily string  `json:"address_family,omitempty"` // ipv4 or ipv6
 }
 
 // ConnectionBroadcaster manages fanout of connection count events to multiple SSE clients.
@@ -999,24 +1436,27 @@ type ConnectionBroadcaster struct {
 	detectionBroadcaster *DetectionBroadcaster
 	statusBroadcaster    *StatusBroadcaster
 
-	// WebRTC client count fetcher (HTTP call to WebRTC server)
-	webrtcCountURL string
+	// WebRTC stats fetcher (HTTP call to WebRTC server)
+	webrtcStatsURL string
 
-	// Cache last WebRTC count (fetched on demand)
-	lastWebRTCCount int
+	// Cache last WebRTC count and session stats (fetched on demand)
+	lastWebRTCCount    int
+	lastWebRTCSessions []WebRTCSessionStats
+	lastCamera         string
+	lastDrain          float64
 }
 
 // NewConnectionBroadcaster creates a broadcaster for connection count events.
 // Returns the broadcaster and a notification channel that other broadcasters should send to.
 func NewConnectionBroadcaster(
-	webrtcCountURL string,
+	webrtcStatsURL string,
 ) (*ConnectionBroadcaster, chan<- struct{}) {
 	onChange := make(chan struct{}, 16) // Buffered to avoid blocking senders
 	return &ConnectionBroadcaster{
 		clients:        make(map[int]chan []byte),
 		stop:           make(chan struct{}),
 		onChange:       onChange,
-		webrtcCountURL: webrtcCountURL,
+		webrtcStatsURL: webrtcStatsURL,
 	}, onChange
 }
 
@@ -1091,18 +1531,23 @@ func (cb *ConnectionBroadcaster) GetCounts() ConnectionCounts {
 		StatusSSE:    cb.statusBroadcaster.GetClientCount(),
 		WebRTC:       cb.lastWebRTCCount,
 		Timestamp:    time.Now().Unix(),
+
+		WebRTCSessions: cb.lastWebRTCSessions,
+		Camera:         cb.lastCamera,
+
+		DrainReconnectAfter: cb.lastDrain,
 	}
 	counts.Total = counts.WebRTC + counts.MJPEG + counts.DetectionSSE + counts.StatusSSE
 	return counts
 }
 
 func (cb *ConnectionBroadcaster) fetchWebRTCCount() int {
-	if cb.webrtcCountURL == "" {
+	if cb.webrtcStatsURL == "" {
 		return 0
 	}
 
 	client := &http.Client{Timeout: 500 * time.Millisecond}
-	resp, err := client.Get(cb.webrtcCountURL)
+	resp, err := client.Get(cb.webrtcStatsURL)
 	if err != nil {
 		logger.Debug("ConnectionBroadcaster", "Failed to fetch WebRTC count: %v", err)
 		return cb.lastWebRTCCount // Return cached value on error
@@ -1114,12 +1559,18 @@ func (cb *ConnectionBroadcaster) fetchWebRTCCount() int {
 	}
 
 	var result struct {
-		Count int `json:"count"`
+		Count    int                  `json:"count"`
+		Sessions []WebRTCSessionStats `json:"sessions"`
+		Camera   string               `json:"camera"`
+		Drain    float64              `json:"drain_reconnect_after_sec"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return cb.lastWebRTCCount
 	}
 	cb.lastWebRTCCount = result.Count
+	cb.lastWebRTCSessions = result.Sessions
+	cb.lastCamera = result.Camera
+	cb.lastDrain = result.Drain
 	return result.Count
 }
 
@@ -1152,9 +1603,11 @@ func (cb *ConnectionBroadcaster) run() {
 				continue
 			}
 
-			oldCount := cb.lastWebRTCCount
+			// Sessions carry live quality stats, so push every tick while
+			// any WebRTC viewer is connected, not only on count changes.
+			oldCount, oldCamera := cb.lastWebRTCCount, cb.lastCamera
 			newCount := cb.fetchWebRTCCount()
-			if newCount != oldCount {
+			if newCount != oldCount || newCount > 0 || cb.lastCamera != oldCamera {
 				cb.broadcastCounts()
 			}
 		}
@@ -0,0 +1,113 @@
+package webmonitor
+
+import (
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/eventbus"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+// CameraEvent types: the detection daemon's health as seen by DetectionHealth.
+const (
+	CameraDetectorStale     = "detector_stale"     // no new results for DetectionStaleAfter
+	CameraDetectorResumed   = "detector_resumed"   // results again after stale
+	CameraDetectorOffline   = "detector_offline"   // stale for DetectionAlertAfter
+	CameraDetectorRecovered = "detector_recovered" // results again after offline
+)
+
+// CameraEvent is a health change of the camera pipeline, published on the
+// "camera" topic.
+type CameraEvent struct {
+	Type     string        `json:"type"`
+	At       time.Time     `json:"at"`
+	StaleFor time.Duration `json:"stale_for,omitempty"` // CameraDetectorOffline
+}
+
+// busTopics are the web monitor's event bus topics. Producers only publish;
+// the consumers (history, rollups, rules, alerts, push, audit log, SSE
+// clients) subscribe in NewServer. The alert topic is AlertCenter.Topic.
+type busTopics struct {
+	detections    *eventbus.Topic[*DetectionResult] // every result, after pet identification
+	events        *eventbus.Topic[Event]            // synthesized, recording and system events (EventStore)
+	camera        *eventbus.Topic[CameraEvent]      // detector health changes
+	notifications *eventbus.Topic[PushMessage]      // push notifications to send
+}
+
+func newBusTopics(b *eventbus.Bus) busTopics {
+	return busTopics{
+		detections:    eventbus.NewTopic[*DetectionResult](b, "detections"),
+		events:        eventbus.NewTopic[Event](b, "events"),
+		camera:        eventbus.NewTopic[CameraEvent](b, "camera"),
+		notifications: eventbus.NewTopic[PushMessage](b, "notifications"),
+	}
+}
+
+// notificationBuffer bounds the push notifications waiting for the notifier;
+// each one saves a snapshot and posts to every subscription.
+const notificationBuffer = 16
+
+// publishDetectionHealth publishes the detector health changes seen by
+// s.detectionHealth on the camera topic.
+func (s *Server) publishDetectionHealth() {
+	s.detectionHealth.SetOnAlert(func(staleFor time.Duration) {
+		s.topics.camera.Publish(CameraEvent{Type: CameraDetectorOffline, At: time.Now(), StaleFor: staleFor})
+	})
+	s.detectionHealth.SetOnRecover(func() {
+		s.topics.camera.Publish(CameraEvent{Type: CameraDetectorRecovered, At: time.Now()})
+	})
+	s.detectionHealth.SetOnStaleChange(func(stale bool, at time.Time) {
+		if stale {
+			s.topics.camera.Publish(CameraEvent{Type: CameraDetectorStale, At: at})
+		} else {
+			s.topics.camera.Publish(CameraEvent{Type: CameraDetectorResumed, At: at})
+		}
+	})
+}
+
+// subscribeCamera wires the camera topic to logging, detection history
+// gaps, alerts and push notifications.
+func (s *Server) subscribeCamera() {
+	s.topics.camera.Subscribe("log", func(ev CameraEvent) {
+		switch ev.Type {
+		case CameraDetectorOffline:
+			logger.Error("DetectionHealth", "Detection daemon stale for %v (no new detection version)", ev.StaleFor.Round(time.Second))
+		case CameraDetectorRecovered:
+			logger.Info("DetectionHealth", "Detection daemon recovered")
+		}
+	})
+	s.topics.camera.Subscribe("history", func(ev CameraEvent) {
+		switch ev.Type {
+		case CameraDetectorStale:
+			s.detectionHistory.BeginGap(GapDetectorStale, ev.At)
+		case CameraDetectorResumed:
+			s.detectionHistory.EndGap(GapDetectorStale, ev.At)
+		}
+	})
+	s.topics.camera.Subscribe("alerts", func(ev CameraEvent) {
+		switch ev.Type {
+		case CameraDetectorStale:
+			s.alerts.RaiseMessage("detector", "detector:stale", AlertWarning, msg("alert.detector_stale", ev.At.Format(time.TimeOnly)))
+		case CameraDetectorOffline:
+			s.alerts.RaiseMessage("detector", "detector:stale", AlertCritical, msg("alert.detector_offline", ev.StaleFor.Round(time.Second)))
+		case CameraDetectorResumed:
+			s.alerts.Resolve("detector:stale")
+		}
+	})
+	s.topics.camera.Subscribe("notify", func(ev CameraEvent) {
+		if ev.Type == CameraDetectorOffline {
+			s.topics.notifications.Publish(localizedPush(
+				msg("push.detector_offline.title"),
+				msg("push.detector_offline.body", ev.StaleFor.Round(time.Second)),
+				"detector-health",
+			))
+		}
+	})
+}
+
+// runNotifier sends the notifications published on the bus until the
+// subscription is cancelled.
+func (s *Server) runNotifier(ch <-chan PushMessage) {
+	for msg := range ch {
+		s.notify(msg)
+	}
+}
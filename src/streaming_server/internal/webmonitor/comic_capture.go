@@ -20,6 +20,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unsafe"
 )
@@ -91,6 +92,7 @@ type ComicCapture struct {
 	stop              chan struct{}
 	done              chan struct{}
 	stitchCh          chan stitchRequest
+	paused            atomic.Bool // CPU degradation: skip panel capture and stitching
 
 	// Configurable parameters
 	DetectionThreshold  time.Duration // continuous cat before capture starts
@@ -99,7 +101,8 @@ type ComicCapture struct {
 	MaxPanels           int
 	RateLimitWindow     time.Duration
 	RateLimitMax        int
-	SkipStitch          bool // Skip nano2D composition (for testing)
+	SkipStitch          bool          // Skip nano2D composition (for testing)
+	Detector            DetectorInput // what the shm detections are measured in (-detector-input)
 }
 
 func NewComicCapture(src frameSource, outputDir string) *ComicCapture {
@@ -130,6 +133,12 @@ func (cc *ComicCapture) Stop() {
 	close(cc.stitchCh)
 }
 
+// SetPaused suspends automatic capture while CPU is overloaded. A session in
+// progress resumes where it left off; manual CaptureComic still works.
+func (cc *ComicCapture) SetPaused(paused bool) {
+	cc.paused.Store(paused)
+}
+
 func (cc *ComicCapture) run() {
 	defer close(cc.done)
 	ticker := time.NewTicker(500 * time.Millisecond)
@@ -140,6 +149,9 @@ func (cc *ComicCapture) run() {
 		case <-cc.stop:
 			return
 		case <-ticker.C:
+			if cc.paused.Load() {
+				continue
+			}
 			if needsStitch := cc.tick(time.Now()); needsStitch {
 				cc.finalizeSession()
 			}
@@ -155,6 +167,9 @@ func (cc *ComicCapture) tick(now time.Time) bool {
 	// Poll detection SHM
 	det, ok := cc.src.LatestDetection()
 	if ok {
+		if !det.Frame.mapped() {
+			mapDetections(det, cc.Detector)
+		}
 		cc.lastDetResult = det
 		cc.lastVersionChange = now
 		if hasPet(det) {
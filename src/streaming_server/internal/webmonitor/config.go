@@ -1,48 +1,251 @@
 package webmonitor
 
 import (
+	"os"
 	"path/filepath"
 	"time"
 )
 
 // Config defines the runtime configuration for the web monitor server.
 type Config struct {
-	Addr                 string
-	AssetsDir            string
-	BuildAssetsDir       string
-	FrameShmName         string // NV12 frame SHM for MJPEG streaming
-	StreamShmName        string // H.265 zero-copy SHM for recording
-	DetectionShmName     string
-	WebRTCBaseURL        string
-	TargetFPS            int
-	StatusInterval       time.Duration
-	DetectionInterval    time.Duration
-	MJPEGInterval        time.Duration
-	RecordingOutputPath  string
-	TLSCertFile          string
-	TLSKeyFile           string
-	JPEGQuality          int    // JPEG encoding quality (1-100, default 85)
-	DetectionHistoryPath string // gob file for persisting detection history across restarts
-	DetectPort           string // local Python detector port (default "8083")
+	Addr                      string
+	HTTPOnlyAddr              string // plain-HTTP listener for MJPEG/API clients that cannot do TLS ("" disables)
+	Socket                    string // Unix socket serving the same handler, plain HTTP ("" = none; with Addr "" the only listener)
+	SocketMode                os.FileMode
+	AssetsDir                 string
+	BuildAssetsDir            string
+	FrameShmName              string // NV12 frame SHM for MJPEG streaming
+	StreamShmName             string // H.265 zero-copy SHM for recording
+	ProxyShmName              string // H.265 SHM of a low-res substream, recorded as a proxy ("" = none)
+	DetectionShmName          string
+	DetectionSource           string // detector input: "shm" (default), "ndjson" (Unix socket) or "grpc"
+	DetectionAddr             string // socket path (ndjson) or host:port (grpc) for DetectionSource
+	WebRTCBaseURL             string
+	TargetFPS                 int
+	StatusInterval            time.Duration
+	DetectionInterval         time.Duration
+	MJPEGInterval             time.Duration
+	RecordingOutputPath       string
+	RecordingStorage          string        // "" (RecordingOutputPath), a mounted directory, or s3://bucket/prefix
+	RecordingFallback         string        // comma-separated directories recorded to, in order, while RecordingOutputPath fails
+	RecordingPathCheck        time.Duration // how often the recording paths are probed
+	TLSCertFile               string
+	TLSKeyFile                string
+	JPEGQuality               int           // JPEG encoding quality (1-100, default 85)
+	DetectionHistoryPath      string        // gob file for persisting detection history across restarts
+	DetectionHistoryDepth     int           // recent results in /api/status detection_history (default 8)
+	DetectorInput             DetectorInput // the image the detector boxes in, mapped to 1280x720 (zero = 1280x720 already)
+	DetectionBBoxNorm         bool          // add bbox_norm (0-1 fractions) to every detection sent
+	DetectionHistoryRetention time.Duration // window served by /api/detections/history (default 24h)
+	LogBufferLines            int           // recent log lines kept per level for /api/logs
+	AccessLog                 bool          // log every HTTP request (method, path, status, bytes, duration)
+	Compress                  bool          // gzip/deflate JSON and SSE responses for clients that accept it
+	CrashDir                  string        // crash reports for recovered panics ("" = log only)
+	RollupPath                string        // gob file for the per-minute/per-hour detection rollups
+	DetectPort                string        // local Python detector port (default "8083")
+	SettingsPath              string        // settings store of the API-managed configuration (kv.Open spec)
+	RulesPath                 string        // legacy JSON files, imported into SettingsPath once (see settingsDocs)
+	DetectionClassesPath      string
+	MasksPath                 string
+	UIConfigPath              string
+	Locale                    string        // default locale of user-facing strings ("en", "ja")
+	DayMinConfidence          float64       // confidence floor by day, until set via /api/detection/classes
+	NightMinConfidence        float64       // ... while the IR night camera is active
+	TimeseriesPath            string        // gob file for the /api/timeseries metric history ("" = not persisted)
+	StatusHistoryPath         string        // kv store (kv.Open spec) of the per-minute status snapshots ("" = in memory)
+	StatusHistoryRetention    time.Duration // window served by /api/status/history (default 7 days, 0 disables)
+	EventsPath                string        // gob file for persisting synthesized events across restarts
+	Timezone                  string        // overlay clock and file name zone (see clock.LoadLocation)
+	SEITimestamp              bool          // insert a capture-time SEI into recorded H.265 frames
+	CameraName                string        // camera name carried in the SEI (default: hostname)
+	StatePath                 string        // JSON monitor state (active recording, recent detections) saved periodically
+	StateSaveInterval         time.Duration
+	ResumeRecording           bool          // resume a recording interrupted by a restart
+	ResumeWindow              time.Duration // only resume if the saved state is at most this old
+	ResumeGrace               time.Duration // time for viewers to resume heartbeats after a resume
+	PeersPath                 string        // legacy JSON file (see RulesPath)
+	ScrubInterval             time.Duration // re-verify finished recordings this often (0 disables)
+	RecordStopTrim            StopTrim      // what stopping does with the GOP in progress
+	RecordGOPWait             time.Duration // TrimGOP: least wait for the next IDR
+
+	// Web Push (VAPID)
+	PushKeyPath           string // PEM VAPID private key, generated on first run ("" disables push)
+	PushSubscriptionsPath string // legacy JSON list of browser subscriptions (see RulesPath)
+	PushSubject           string // VAPID contact URI (mailto: or https:)
+
+	// Disk-backed outbound queue: push notifications and uploads to remote
+	// storage are retried with backoff across outages and restarts
+	OutboxPath string // queue directory ("" = send once, as before)
+
+	// Per-client queues (frames for MJPEG, events for detection/status SSE)
+	MJPEGClientBuffer int
+	MJPEGMaxPerIP     int     // concurrent /stream responses per client IP (0 = unlimited)
+	MJPEGMaxKbps      float64 // total MJPEG bandwidth across viewers (0 = unlimited)
+	SSEClientBuffer   int
+
+	// Adaptive MJPEG encode cost: quality, then resolution, is lowered while
+	// the mean encode time per frame exceeds the budget (0 = fixed quality)
+	MJPEGEncodeBudget time.Duration
+	MJPEGMinQuality   int
+
+	// ICE servers handed to browsers (comma-separated stun:/turn: URLs)
+	ICEServers     string
+	TURNUsername   string
+	TURNCredential string // from env only
+
+	// REST TURN: time-limited credentials derived per request from a secret
+	// shared with the TURN server, instead of TURNCredential (TURNUsername
+	// becomes the name part of the issued usernames)
+	TURNSecretFile    string // created if missing; re-read on SIGHUP
+	TURNCredentialTTL time.Duration
+
+	// Remote access relay (outbound WSS tunnel to a self-hosted broker)
+	RelayURL      string // ws(s)://broker/relay/connect ("" disables)
+	RelayToken    string
+	RelayCameraID string
+
+	// External recording triggers: POST /api/triggers/fire and an MQTT command topic
+	Triggers     TriggerList // named triggers and how long each records
+	MQTTURL      string      // mqtt(s)://[user@]broker[:port] ("" disables)
+	MQTTTopic    string      // messages on <MQTTTopic>/<trigger> fire the trigger
+	MQTTPassword string      // from env only; overrides the URL's
+
+	// Reverse proxy for the detection daemon's debug UI at /detector/
+	DetectorProxyURL   string // upstream base URL, e.g. http://127.0.0.1:8084 ("" disables)
+	DetectorProxyToken string // required Bearer token or Basic auth password; from env only
+
+	// Expiring signed links to clips and snapshots under /share/
+	ShareKeyFile string        // HMAC key; created if missing ("" disables sharing)
+	ShareMaxTTL  time.Duration // longest validity POST /api/share may ask for
+	AdminToken   string        // Bearer token for admin endpoints; from env only
+
+	// Named read-only access tokens with expiry and stream/rate limits
+	TokensPath string // legacy JSON file (see RulesPath); any token makes access require one
+
+	// Motion fallback (frame differencing while the detection daemon is down)
+	MotionFallback    bool
+	MotionSensitivity int     // per-cell luma delta (0-255)
+	MotionMinArea     float64 // fraction of changed cells (0-1)
+
+	// Secondary inference (downscaled frames pushed to an external model)
+	ForwardURL      string        // http(s):// JSON endpoint or grpc://host:port ("" disables)
+	ForwardInterval time.Duration // minimum gap between forwarded frames
+	ForwardMaxWidth int           // downscale frames to at most this width
+
+	// Pet identification (crops of pet boxes matched against enrolled embeddings)
+	PetEmbedURL       string  // embedding service: POST image/jpeg → {"embedding": [...]} ("" disables)
+	PetProfilesPath   string  // legacy JSON file (see RulesPath)
+	PetMatchThreshold float64 // cosine similarity required to assign a pet
+
+	// Sound events (RMS threshold on audio fed through Server.FeedAudio)
+	SoundThresholdDB float64 // dBFS level that counts as loud
+
+	// Detection daemon health
+	DetectionStaleAfter time.Duration // unhealthy after no new detection version for this long
+	DetectionAlertAfter time.Duration // log an alert after no new detection version for this long
+
+	// Encoder gating: report recordings as H.265 demand to the camera daemon
+	EncoderIdleHoldOff time.Duration // keep the encoder running this long after the last consumer (0 = never pause)
+
+	// CPU degradation: shed MJPEG fps, then comic capture, when the board is saturated
+	DegradeCPUHigh float64 // CPU percent that steps degradation up (0 disables)
+	DegradeCPULow  float64 // CPU percent that steps degradation down
+
+	// Power saving: thin out detector/MJPEG frames while nobody needs the camera
+	PowerSaveIdleAfter    time.Duration // no viewer/recording/schedule this long → 5 fps (0 disables)
+	PowerSaveSuspendAfter time.Duration // ... this long → detector suspended, motion wakes (0 = never)
+	PowerSaveHours        string        // "HH:MM-HH:MM" window power saving is allowed in ("" = any time)
+
+	// Pan-tilt mount
+	PTZBackend     string // pwm:<pwmchip dir>?pan=0&tilt=1 or pelco:<tty>?baud=9600&addr=1 ("" = none)
+	PTZPresetsPath string // legacy JSON file (see RulesPath)
+	PTZTrack       bool   // start with auto-tracking of the most confident pet on
+
+	// Digital pan/zoom for /stream?profile=follow viewers
+	FollowZoom float64 // crop 1/FollowZoom of the frame around the pet (<= 1 sends the full frame)
+
+	// Audit log of events, alerts, detector health and push notifications
+	AuditLogPath string // JSON lines file, appended to ("" = disabled)
 }
 
 // DefaultConfig returns a config aligned with the existing Flask monitor behavior.
 func DefaultConfig() Config {
 	return Config{
-		Addr:                 ":8080",
-		AssetsDir:            filepath.Clean("../web"),
-		BuildAssetsDir:       filepath.Clean("../../build/web"),
-		FrameShmName:         "/pet_camera_mjpeg_zc",
-		StreamShmName:        "/pet_camera_h265_zc",
-		DetectionShmName:     "/pet_camera_detections",
-		WebRTCBaseURL:        "http://localhost:8081",
-		TargetFPS:            30,
-		StatusInterval:       2 * time.Second,
-		DetectionInterval:    33 * time.Millisecond,
-		MJPEGInterval:        33 * time.Millisecond,
-		RecordingOutputPath:  "./recordings",
-		JPEGQuality:          65,
-		DetectionHistoryPath: filepath.Join("recordings", "detection_history.gob"),
-		DetectPort:           "8083",
+		Addr:                      ":8080",
+		SocketMode:                0o660,
+		AssetsDir:                 filepath.Clean("../web"),
+		BuildAssetsDir:            filepath.Clean("../../build/web"),
+		FrameShmName:              "/pet_camera_mjpeg_zc",
+		StreamShmName:             "/pet_camera_h265_zc",
+		DetectionShmName:          "/pet_camera_detections",
+		DetectionSource:           "shm",
+		WebRTCBaseURL:             "http://localhost:8081",
+		TargetFPS:                 30,
+		StatusInterval:            2 * time.Second,
+		DetectionInterval:         33 * time.Millisecond,
+		MJPEGInterval:             33 * time.Millisecond,
+		RecordingOutputPath:       "./recordings",
+		RecordingPathCheck:        10 * time.Second,
+		JPEGQuality:               65,
+		DetectionHistoryPath:      filepath.Join("recordings", "detection_history.gob"),
+		DetectionHistoryDepth:     8,
+		DetectionHistoryRetention: 24 * time.Hour,
+		LogBufferLines:            500,
+		AccessLog:                 true,
+		Compress:                  true,
+		MQTTTopic:                 "pet-camera/trigger",
+		CrashDir:                  filepath.Join("recordings", "crash"),
+		RollupPath:                filepath.Join("recordings", "rollups.gob"),
+		DetectPort:                "8083",
+		SettingsPath:              filepath.Join("recordings", "settings.db"),
+		RulesPath:                 filepath.Join("recordings", "rules.json"),
+		DetectionClassesPath:      filepath.Join("recordings", "detection_classes.json"),
+		MasksPath:                 filepath.Join("recordings", "masks.json"),
+		UIConfigPath:              filepath.Join("recordings", "ui.json"),
+		Locale:                    "ja",
+		NightMinConfidence:        0.5,
+		TimeseriesPath:            filepath.Join("recordings", "timeseries.gob"),
+		StatusHistoryPath:         filepath.Join("recordings", "status_history.db"),
+		StatusHistoryRetention:    7 * 24 * time.Hour,
+		EventsPath:                filepath.Join("recordings", "events.gob"),
+		Timezone:                  "Asia/Tokyo",
+		StatePath:                 filepath.Join("recordings", "state.json"),
+		StateSaveInterval:         30 * time.Second,
+		ResumeRecording:           true,
+		ResumeWindow:              5 * time.Minute,
+		ResumeGrace:               30 * time.Second,
+		RecordStopTrim:            TrimCut,
+		RecordGOPWait:             2 * time.Second,
+		PeersPath:                 filepath.Join("recordings", "peers.json"),
+		TokensPath:                filepath.Join("recordings", "tokens.json"),
+		ScrubInterval:             24 * time.Hour,
+		PushKeyPath:               filepath.Join("recordings", "vapid_private.pem"),
+		PushSubscriptionsPath:     filepath.Join("recordings", "push_subscriptions.json"),
+		PushSubject:               "mailto:admin@localhost",
+		OutboxPath:                filepath.Join("recordings", "outbox"),
+		MotionFallback:            true,
+		MotionSensitivity:         20,
+		MotionMinArea:             0.01,
+		ForwardInterval:           time.Second,
+		ForwardMaxWidth:           640,
+		PetProfilesPath:           filepath.Join("recordings", "pets.json"),
+		PetMatchThreshold:         0.8,
+		SoundThresholdDB:          -30,
+		ICEServers:                "stun:stun.l.google.com:19302",
+		TURNCredentialTTL:         24 * time.Hour,
+		MJPEGClientBuffer:         defaultClientBuffer,
+		MJPEGEncodeBudget:         20 * time.Millisecond,
+		MJPEGMinQuality:           40,
+		SSEClientBuffer:           defaultClientBuffer,
+		DetectionStaleAfter:       30 * time.Second,
+		DetectionAlertAfter:       5 * time.Minute,
+		EncoderIdleHoldOff:        30 * time.Second,
+		DegradeCPUHigh:            90,
+		DegradeCPULow:             70,
+		PowerSaveSuspendAfter:     10 * time.Minute,
+		PTZPresetsPath:            filepath.Join("recordings", "ptz_presets.json"),
+		FollowZoom:                2,
+		ShareKeyFile:              filepath.Join("recordings", "share.key"),
+		ShareMaxTTL:               7 * 24 * time.Hour,
 	}
 }
@@ -0,0 +1,313 @@
+package webmonitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+)
+
+// Bounds on the allowlist and on the classes remembered as seen; the
+// daemon knows a few dozen classes at most.
+const (
+	maxAllowedClasses = 64
+	maxSeenClasses    = 256
+)
+
+// ClassFilter is the allowlist of detection classes of interest. Detections
+// of other classes are dropped where results enter the monitor (see
+// Monitor.Filter and DetectionBroadcaster.SetFilter), so they never reach
+// the overlay, detection events, history, rollups, rules or recording
+// triggers. An empty allowlist keeps every class.
+//
+// The detection daemon still detects every class: its control channel has
+// no class setting, so the allowlist is enforced here only.
+//
+// Detections below the confidence floor of the current lighting profile are
+// dropped the same way (motion fallback results excepted).
+type ClassFilter struct {
+	doc kv.Doc
+
+	mu       sync.RWMutex
+	allowed  []string        // sorted; empty = all classes
+	allow    map[string]bool // set of allowed
+	seen     map[string]bool // every class seen, for the settings UI
+	profiles map[Lighting]FilterProfile
+	defaults map[Lighting]FilterProfile // until profiles are saved
+	lighting Lighting                   // selects profiles[LightingNight] at night, the day profile otherwise
+
+	dropped atomic.Uint64
+}
+
+// FilterProfile is the confidence floor applied under one lighting. The IR
+// night image is monochrome, which makes the detector both less sure of
+// real pets and more prone to false positives.
+type FilterProfile struct {
+	MinConfidence float64            `json:"min_confidence"`
+	Classes       map[string]float64 `json:"classes,omitempty"` // per-class floor, overriding MinConfidence
+}
+
+// floor returns the minimum confidence for class.
+func (p FilterProfile) floor(class string) float64 {
+	if v, ok := p.Classes[class]; ok {
+		return v
+	}
+	return p.MinConfidence
+}
+
+func (p FilterProfile) validate() error {
+	if p.MinConfidence < 0 || p.MinConfidence > 1 {
+		return fmt.Errorf("min_confidence %v out of range (0-1)", p.MinConfidence)
+	}
+	if len(p.Classes) > maxAllowedClasses {
+		return fmt.Errorf("at most %d per-class floors", maxAllowedClasses)
+	}
+	for c, v := range p.Classes {
+		if v < 0 || v > 1 {
+			return fmt.Errorf("floor %v for %q out of range (0-1)", v, c)
+		}
+	}
+	return nil
+}
+
+// NewClassFilter creates a filter persisted to doc (the zero Doc = not
+// persisted).
+func NewClassFilter(doc kv.Doc) *ClassFilter {
+	profiles := map[Lighting]FilterProfile{LightingDay: {}, LightingNight: {}}
+	return &ClassFilter{
+		doc:      doc,
+		seen:     make(map[string]bool),
+		profiles: profiles,
+		defaults: maps.Clone(profiles),
+	}
+}
+
+// SetDefaultProfiles sets the day and night profiles used until some are
+// saved through the API. Call before Load.
+func (f *ClassFilter) SetDefaultProfiles(day, night FilterProfile) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.profiles = map[Lighting]FilterProfile{LightingDay: day, LightingNight: night}
+	f.defaults = maps.Clone(f.profiles)
+}
+
+// SetLighting selects the profile applied from now on.
+func (f *ClassFilter) SetLighting(l Lighting) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lighting = l
+}
+
+// activeLocked returns the profile of the current lighting.
+func (f *ClassFilter) activeLocked() FilterProfile {
+	if f.lighting == LightingNight {
+		return f.profiles[LightingNight]
+	}
+	return f.profiles[LightingDay]
+}
+
+// keepLocked reports whether d passes the allowlist and the active floor.
+func (f *ClassFilter) keepLocked(d Detection, profile FilterProfile) bool {
+	if len(f.allow) > 0 && !f.allow[d.ClassName] {
+		return false
+	}
+	// Motion fallback results are classed "motion" and rate area, not confidence
+	return d.ClassName == DetectionSourceMotion || d.Confidence >= profile.floor(d.ClassName)
+}
+
+// Load reads the persisted allowlist and profiles; without a document
+// every class is kept under the default profiles.
+func (f *ClassFilter) Load() error {
+	var saved struct {
+		Classes  []string                   `json:"classes"`
+		Profiles map[Lighting]FilterProfile `json:"profiles"`
+	}
+	if _, err := f.doc.Load(&saved); err != nil {
+		return err
+	}
+	classes, err := normalizeClasses(saved.Classes)
+	if err != nil {
+		return err
+	}
+	if err := validateProfiles(saved.Profiles); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.setLocked(classes)
+	f.profiles = maps.Clone(f.defaults)
+	maps.Copy(f.profiles, saved.Profiles)
+	return nil
+}
+
+// validateProfiles accepts day and night profiles with floors in 0-1.
+func validateProfiles(profiles map[Lighting]FilterProfile) error {
+	for l, p := range profiles {
+		if l != LightingDay && l != LightingNight {
+			return fmt.Errorf("unknown lighting profile %q (day, night)", l)
+		}
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("%s profile: %w", l, err)
+		}
+	}
+	return nil
+}
+
+// normalizeClasses trims, deduplicates and sorts class names.
+func normalizeClasses(classes []string) ([]string, error) {
+	if len(classes) > maxAllowedClasses {
+		return nil, fmt.Errorf("at most %d classes", maxAllowedClasses)
+	}
+	out := make([]string, 0, len(classes))
+	for _, c := range classes {
+		c = strings.TrimSpace(c)
+		if c == "" || len(c) > 31 { // the daemon's class name field is 32 bytes, NUL-terminated
+			return nil, fmt.Errorf("invalid class name %q", c)
+		}
+		out = append(out, c)
+	}
+	slices.Sort(out)
+	return slices.Compact(out), nil
+}
+
+func (f *ClassFilter) setLocked(classes []string) {
+	f.allowed = classes
+	f.allow = make(map[string]bool, len(classes))
+	for _, c := range classes {
+		f.allow[c] = true
+	}
+}
+
+// Set replaces the allowlist (empty = all classes) and persists it.
+func (f *ClassFilter) Set(classes []string) ([]string, error) {
+	return f.Update(classes, nil)
+}
+
+// Update replaces the allowlist and the given lighting profiles (others are
+// kept) and persists both.
+func (f *ClassFilter) Update(classes []string, profiles map[Lighting]FilterProfile) ([]string, error) {
+	classes, err := normalizeClasses(classes)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateProfiles(profiles); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	merged := maps.Clone(f.profiles)
+	maps.Copy(merged, profiles)
+	if err := f.doc.Save(map[string]any{"classes": classes, "profiles": merged}); err != nil {
+		return nil, err
+	}
+	f.setLocked(classes)
+	f.profiles = merged
+	return classes, nil
+}
+
+// Filter returns the allowed detections of dets, reusing dets when all of
+// them are allowed and a new slice otherwise.
+func (f *ClassFilter) Filter(dets []Detection) []Detection {
+	f.mu.RLock()
+	profile := f.activeLocked()
+	keepAll := true
+	for _, d := range dets {
+		if !f.seen[d.ClassName] {
+			keepAll = false // record it below
+		} else if !f.keepLocked(d, profile) {
+			keepAll = false
+		}
+	}
+	f.mu.RUnlock()
+	if keepAll {
+		return dets
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	profile = f.activeLocked()
+	var kept []Detection
+	for _, d := range dets {
+		if len(f.seen) < maxSeenClasses {
+			f.seen[d.ClassName] = true
+		}
+		if f.keepLocked(d, profile) {
+			kept = append(kept, d)
+		}
+	}
+	if len(kept) == len(dets) {
+		return dets
+	}
+	f.dropped.Add(uint64(len(dets) - len(kept)))
+	return kept
+}
+
+// ClassFilterStatus is the GET /api/detection/classes response.
+type ClassFilterStatus struct {
+	Classes  []string                   `json:"classes"`            // allowlist; empty = all classes
+	Seen     []string                   `json:"seen"`               // classes detected since startup
+	Dropped  uint64                     `json:"dropped"`            // detections dropped since startup
+	Profiles map[Lighting]FilterProfile `json:"profiles"`           // confidence floors by lighting
+	Lighting Lighting                   `json:"lighting,omitempty"` // selects the profile in use
+}
+
+// Status returns the allowlist, the classes seen and the drop count.
+func (f *ClassFilter) Status() ClassFilterStatus {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	st := ClassFilterStatus{
+		Classes:  slices.Clone(f.allowed),
+		Dropped:  f.dropped.Load(),
+		Profiles: maps.Clone(f.profiles),
+		Lighting: f.lighting,
+	}
+	if st.Classes == nil {
+		st.Classes = []string{}
+	}
+	st.Seen = make([]string, 0, len(f.seen))
+	for c := range f.seen {
+		st.Seen = append(st.Seen, c)
+	}
+	slices.Sort(st.Seen)
+	return st
+}
+
+// handleDetectionClasses serves GET and PUT /api/detection/classes. PUT
+// takes {"classes": ["cat"]}; an empty list allows every class again. An
+// optional "profiles" object replaces the day and/or night profile.
+func (s *Server) handleDetectionClasses(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, s.classFilter.Status())
+	case http.MethodPut:
+		var req struct {
+			Classes  []string                   `json:"classes"`
+			Profiles map[Lighting]FilterProfile `json:"profiles"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": "invalid JSON: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if _, err := normalizeClasses(req.Classes); err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if err := validateProfiles(req.Profiles); err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if _, err := s.classFilter.Update(req.Classes, req.Profiles); err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, s.classFilter.Status())
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,92 @@
+package webmonitor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+)
+
+func TestClassFilter(t *testing.T) {
+	doc := classesDoc.in(kv.NewMemory())
+	f := NewClassFilter(doc)
+	dets := []Detection{{ClassName: "cat"}, {ClassName: "person"}, {ClassName: "cat"}}
+
+	if got := f.Filter(dets); len(got) != 3 {
+		t.Fatalf("empty allowlist kept %d of 3", len(got))
+	}
+	if _, err := f.Set([]string{" cat ", "cat"}); err != nil {
+		t.Fatal(err)
+	}
+	got := f.Filter(dets)
+	if len(got) != 2 || got[0].ClassName != "cat" || got[1].ClassName != "cat" {
+		t.Fatalf("filtered %+v", got)
+	}
+	if len(dets) != 3 || dets[1].ClassName != "person" {
+		t.Fatal("input modified")
+	}
+	st := f.Status()
+	if !reflect.DeepEqual(st.Classes, []string{"cat"}) || !reflect.DeepEqual(st.Seen, []string{"cat", "person"}) || st.Dropped != 1 {
+		t.Fatalf("status %+v", st)
+	}
+
+	// Persisted across restarts
+	g := NewClassFilter(doc)
+	if err := g.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if got := g.Filter([]Detection{{ClassName: "dog"}}); len(got) != 0 {
+		t.Fatalf("reloaded filter kept %+v", got)
+	}
+
+	for _, bad := range [][]string{{""}, {strings.Repeat("x", 32)}} {
+		if _, err := f.Set(bad); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
+
+// feedSource is a DetectionSource fed through push.
+type feedSource struct{ *detectionFeed }
+
+func (feedSource) Close() {}
+
+func TestMonitorFilter(t *testing.T) {
+	feed := newDetectionFeed()
+	m := NewMonitor(30, nil)
+	m.SetDetectionSource(feedSource{feed})
+	f := NewClassFilter(kv.Doc{})
+	f.Set([]string{"cat"})
+	m.Filter = f.Filter
+
+	feed.push(&DetectionResult{NumDetections: 1, Detections: []Detection{{ClassName: "person"}}})
+	m.mu.Lock()
+	m.refreshFromSharedMemoryLocked()
+	det, history := m.latestDetection, len(m.detectionHistory)
+	m.mu.Unlock()
+	if det == nil || len(det.Detections) != 0 || det.NumDetections != 0 || history != 0 {
+		t.Fatalf("unwanted class reached the monitor: %+v (history %d)", det, history)
+	}
+}
+
+func TestHandleDetectionClasses(t *testing.T) {
+	s := &Server{classFilter: NewClassFilter(classesDoc.in(kv.NewMemory()))}
+	rec := httptest.NewRecorder()
+	s.handleDetectionClasses(rec, httptest.NewRequest(http.MethodPut, "/api/detection/classes", strings.NewReader(`{"classes":["cat"]}`)))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"classes":["cat"]`) {
+		t.Fatalf("PUT: %d %s", rec.Code, rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	s.handleDetectionClasses(rec, httptest.NewRequest(http.MethodPut, "/api/detection/classes", strings.NewReader(`{"classes":[""]}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid PUT: %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	s.handleDetectionClasses(rec, httptest.NewRequest(http.MethodGet, "/api/detection/classes", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"classes":["cat"]`) {
+		t.Fatalf("GET: %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,174 @@
+package webmonitor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DetectionFrame describes a result's coordinates: bbox is in pixels of a
+// Width x Height frame covering the whole video (the 1280x720 reference
+// every box is kept in), mapped there from the Detector input the detector
+// measured it in. A detector reporting its own input (ndjson, gRPC) sets
+// only Detector: its boxes are not mapped yet.
+type DetectionFrame struct {
+	Width    int            `json:"width"`
+	Height   int            `json:"height"`
+	Detector *DetectorInput `json:"detector,omitempty"` // nil: measured in the reference frame
+}
+
+// DetectorInput is the image a detector ran on: its resolution and the
+// rectangle the video occupies in it. A letterboxed input has the video
+// inside with bars around it; a cropped one has it extend past the edges
+// (negative offsets, larger size). A zero Content is the whole image.
+type DetectorInput struct {
+	Width   int         `json:"width"`
+	Height  int         `json:"height"`
+	Content BoundingBox `json:"content"`
+}
+
+// NormBBox is a bounding box in fractions (0-1) of the video's width and
+// height, independent of any resolution.
+type NormBBox struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	W float64 `json:"w"`
+	H float64 `json:"h"`
+}
+
+// referenceFrame is the frame of results measured in the reference itself.
+var referenceFrame = DetectionFrame{Width: detectionRefW, Height: detectionRefH}
+
+// mapped reports whether the boxes of a result with frame f are in the
+// reference frame already.
+func (f *DetectionFrame) mapped() bool {
+	return f != nil && f.Width > 0
+}
+
+// content returns the video's rectangle in the input.
+func (in DetectorInput) content() BoundingBox {
+	if in.Content.W <= 0 || in.Content.H <= 0 {
+		return BoundingBox{W: in.Width, H: in.Height}
+	}
+	return in.Content
+}
+
+// isReference reports whether boxes measured in the input are already in the
+// reference frame. The zero input is the reference.
+func (in DetectorInput) isReference() bool {
+	return in == DetectorInput{} || in.content() == BoundingBox{W: detectionRefW, H: detectionRefH}
+}
+
+// toReference maps a box from the input to the reference frame, clipped to
+// the video (a letterboxed detector may box into the bars).
+func (in DetectorInput) toReference(b BoundingBox) BoundingBox {
+	c := in.content()
+	x0 := min(max((b.X-c.X)*detectionRefW/c.W, 0), detectionRefW)
+	y0 := min(max((b.Y-c.Y)*detectionRefH/c.H, 0), detectionRefH)
+	x1 := min(max((b.X+b.W-c.X)*detectionRefW/c.W, 0), detectionRefW)
+	y1 := min(max((b.Y+b.H-c.Y)*detectionRefH/c.H, 0), detectionRefH)
+	return BoundingBox{X: x0, Y: y0, W: x1 - x0, H: y1 - y0}
+}
+
+// Validate checks the input is usable to map boxes.
+func (in DetectorInput) Validate() error {
+	if in == (DetectorInput{}) {
+		return nil
+	}
+	if in.Width <= 0 || in.Height <= 0 {
+		return fmt.Errorf("detector input %dx%d: size must be positive", in.Width, in.Height)
+	}
+	if c := in.Content; c != (BoundingBox{}) && (c.W <= 0 || c.H <= 0) {
+		return fmt.Errorf("detector input content %dx%d: size must be positive", c.W, c.H)
+	}
+	return nil
+}
+
+// String formats the input as -detector-input takes it.
+func (in DetectorInput) String() string {
+	if in == (DetectorInput{}) {
+		return ""
+	}
+	s := fmt.Sprintf("%dx%d", in.Width, in.Height)
+	if c := in.Content; c != (BoundingBox{}) {
+		s += fmt.Sprintf("@%d,%d,%d,%d", c.X, c.Y, c.W, c.H)
+	}
+	return s
+}
+
+// Set parses a -detector-input value (flag.Value): WxH, or WxH@x,y,w,h with
+// the video's rectangle in the input ("640x640@0,140,640,360" for a
+// letterboxed 16:9 video). Empty is the reference frame.
+func (in *DetectorInput) Set(s string) error {
+	if s == "" {
+		*in = DetectorInput{}
+		return nil
+	}
+	size, content, hasContent := strings.Cut(s, "@")
+	var v DetectorInput
+	w, h, ok := strings.Cut(size, "x")
+	var err error
+	if v.Width, err = strconv.Atoi(w); err != nil || !ok {
+		return fmt.Errorf("want WxH or WxH@x,y,w,h, got %q", s)
+	}
+	if v.Height, err = strconv.Atoi(h); err != nil {
+		return fmt.Errorf("want WxH or WxH@x,y,w,h, got %q", s)
+	}
+	if hasContent {
+		parts := strings.Split(content, ",")
+		if len(parts) != 4 {
+			return fmt.Errorf("want WxH@x,y,w,h, got %q", s)
+		}
+		var n [4]int
+		for i, p := range parts {
+			if n[i], err = strconv.Atoi(strings.TrimSpace(p)); err != nil {
+				return fmt.Errorf("want WxH@x,y,w,h, got %q", s)
+			}
+		}
+		v.Content = BoundingBox{X: n[0], Y: n[1], W: n[2], H: n[3]}
+	}
+	if err := v.Validate(); err != nil {
+		return err
+	}
+	*in = v
+	return nil
+}
+
+// mapDetections brings det's boxes into the reference frame: from the input
+// det names in its frame (a detector that reports it), or else from
+// fallback (-detector-input). det.Frame then describes the result.
+func mapDetections(det *DetectionResult, fallback DetectorInput) {
+	in := fallback
+	if det.Frame != nil && det.Frame.Detector != nil {
+		in = *det.Frame.Detector
+	}
+	if in.Validate() != nil || in.isReference() {
+		det.Frame = &referenceFrame
+		return
+	}
+	for i := range det.Detections {
+		det.Detections[i].BBox = in.toReference(det.Detections[i].BBox)
+	}
+	in.Content = in.content() // spelled out for clients
+	det.Frame = &DetectionFrame{Width: detectionRefW, Height: detectionRefH, Detector: &in}
+}
+
+// frameOf returns the frame to report for a result, the reference for one
+// that did not say.
+func frameOf(f *DetectionFrame) *DetectionFrame {
+	if f == nil {
+		return &referenceFrame
+	}
+	return f
+}
+
+// normBBox returns b as fractions of the reference frame.
+func normBBox(b BoundingBox) NormBBox {
+	clamp := func(v float64) float64 { return min(max(v, 0), 1) }
+	return NormBBox{
+		X: clamp(float64(b.X) / detectionRefW),
+		Y: clamp(float64(b.Y) / detectionRefH),
+		W: clamp(float64(b.W) / detectionRefW),
+		H: clamp(float64(b.H) / detectionRefH),
+	}
+}
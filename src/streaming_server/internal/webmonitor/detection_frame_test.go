@@ -0,0 +1,94 @@
+package webmonitor
+
+import (
+	"testing"
+
+	pb "github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/proto"
+)
+
+func TestDetectorInputFlag(t *testing.T) {
+	for _, s := range []string{"", "640x640", "640x640@0,140,640,360", "1280x960@0,-120,1280,1200"} {
+		var in DetectorInput
+		if err := in.Set(s); err != nil {
+			t.Errorf("Set(%q): %v", s, err)
+			continue
+		}
+		if got := in.String(); got != s {
+			t.Errorf("Set(%q).String() = %q", s, got)
+		}
+	}
+	for _, s := range []string{"640", "640x", "x640", "0x640", "640x640@0,0,640", "640x640@0,0,0,360", "640x640@a,b,c,d"} {
+		var in DetectorInput
+		if err := in.Set(s); err == nil {
+			t.Errorf("Set(%q) accepted: %+v", s, in)
+		}
+	}
+}
+
+func TestMapDetections(t *testing.T) {
+	// A 16:9 video letterboxed into a 640x640 input: boxes into the bars are clipped
+	letterbox := DetectorInput{Width: 640, Height: 640, Content: BoundingBox{Y: 140, W: 640, H: 360}}
+	det := &DetectionResult{Detections: []Detection{
+		{BBox: BoundingBox{X: 320, Y: 230, W: 160, H: 90}},
+		{BBox: BoundingBox{X: 0, Y: 100, W: 64, H: 76}},
+	}}
+	mapDetections(det, letterbox)
+	if b := det.Detections[0].BBox; b != (BoundingBox{X: 640, Y: 180, W: 320, H: 180}) {
+		t.Errorf("letterboxed box %+v", b)
+	}
+	if b := det.Detections[1].BBox; b != (BoundingBox{X: 0, Y: 0, W: 128, H: 72}) {
+		t.Errorf("box into the bar %+v", b)
+	}
+	if f := det.Frame; f.Width != 1280 || f.Height != 720 || f.Detector == nil || *f.Detector != letterbox {
+		t.Errorf("frame %+v", f)
+	}
+
+	// A detector reporting its input wins over the configured one
+	det = &DetectionResult{
+		Detections: []Detection{{BBox: BoundingBox{X: 320, Y: 180, W: 320, H: 180}}},
+		Frame:      &DetectionFrame{Detector: &DetectorInput{Width: 640, Height: 360}},
+	}
+	mapDetections(det, letterbox)
+	if b := det.Detections[0].BBox; b != (BoundingBox{X: 640, Y: 360, W: 640, H: 360}) {
+		t.Errorf("reported input box %+v", b)
+	}
+
+	// Already in the reference: untouched
+	det = &DetectionResult{Detections: []Detection{{BBox: BoundingBox{X: 10, Y: 20, W: 30, H: 40}}}}
+	mapDetections(det, DetectorInput{})
+	if b := det.Detections[0].BBox; b != (BoundingBox{X: 10, Y: 20, W: 30, H: 40}) || det.Frame.Detector != nil {
+		t.Errorf("reference box %+v, frame %+v", b, det.Frame)
+	}
+}
+
+func TestMonitorPrepare(t *testing.T) {
+	m := NewMonitor(30, nil)
+	m.Detector = DetectorInput{Width: 640, Height: 360}
+	m.NormalizeBBoxes = true
+	det := &DetectionResult{Detections: []Detection{{BBox: BoundingBox{X: 160, Y: 90, W: 320, H: 180}}}}
+	m.prepare(det)
+	m.prepare(det) // a result passing twice is mapped once
+	d := det.Detections[0]
+	if d.BBox != (BoundingBox{X: 320, Y: 180, W: 640, H: 360}) {
+		t.Errorf("bbox %+v", d.BBox)
+	}
+	if d.BBoxNorm == nil || *d.BBoxNorm != (NormBBox{X: 0.25, Y: 0.25, W: 0.5, H: 0.5}) {
+		t.Errorf("bbox_norm %+v", d.BBoxNorm)
+	}
+}
+
+func TestDetectionFrameProto(t *testing.T) {
+	ev := &pb.DetectionEvent{
+		Detections: []*pb.Detection{{Bbox: &pb.BBox{X: 160, Y: 90, W: 320, H: 180}, Label: "cat"}},
+		Frame:      &pb.DetectionFrame{Detector: &pb.DetectorInput{Width: 640, Height: 360}},
+	}
+	det := detectionResultFromProto(ev)
+	if det.Frame.mapped() || det.Frame.Detector == nil || det.Frame.Detector.Width != 640 {
+		t.Fatalf("frame %+v", det.Frame)
+	}
+	mapDetections(det, DetectorInput{})
+	f := convertFrameToProto(det.Frame)
+	if f.GetWidth() != 1280 || f.GetHeight() != 720 || f.GetDetector().GetWidth() != 640 || f.GetDetector().GetContent().GetW() != 640 {
+		t.Errorf("proto frame %+v", f)
+	}
+}
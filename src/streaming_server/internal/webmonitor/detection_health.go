@@ -0,0 +1,194 @@
+package webmonitor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+// DetectionHealthStatus is a point-in-time view of detection daemon liveness.
+type DetectionHealthStatus struct {
+	Healthy        bool    `json:"healthy"`
+	Version        int     `json:"version"`
+	LastUpdate     float64 `json:"last_update"` // unix seconds of the last version change (0 = never)
+	StaleSeconds   float64 `json:"stale_seconds"`
+	Alerting       bool    `json:"alerting"`
+	MotionFallback bool    `json:"motion_fallback"`
+	Suspended      bool    `json:"suspended"` // detector frames stopped by power saving
+}
+
+// DetectionHealth watches the detection SHM version counter. The daemon bumps
+// the version on every inference (even with 0 detections), so a version that
+// stops advancing means the daemon is hung or not running.
+type DetectionHealth struct {
+	version func() int
+
+	mu          sync.Mutex
+	lastVersion int
+	lastChange  time.Time
+	seen        bool // at least one non-zero version observed
+	alerting    bool
+	stale       bool
+	suspended   bool
+	onAlert     func(staleFor time.Duration)
+	onRecover   func()
+	onStale     func(stale bool, at time.Time)
+	stop        chan struct{}
+	stopped     bool
+
+	// Configurable parameters
+	StaleAfter time.Duration // unhealthy after this long without a version change
+	AlertAfter time.Duration // onAlert fires once after this long without a version change
+	Interval   time.Duration // polling interval
+}
+
+// NewDetectionHealth creates a tracker polling version for the detection version counter.
+func NewDetectionHealth(version func() int) *DetectionHealth {
+	return &DetectionHealth{
+		version:    version,
+		stop:       make(chan struct{}),
+		StaleAfter: 30 * time.Second,
+		AlertAfter: 5 * time.Minute,
+		Interval:   1 * time.Second,
+	}
+}
+
+// SetOnAlert sets a callback fired once when the daemon has been stale for AlertAfter.
+func (h *DetectionHealth) SetOnAlert(callback func(staleFor time.Duration)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onAlert = callback
+}
+
+// SetOnRecover sets a callback fired when the daemon resumes after an alert.
+func (h *DetectionHealth) SetOnRecover(callback func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onRecover = callback
+}
+
+// SetOnStaleChange sets a callback fired when the daemon crosses StaleAfter
+// (stale=true, at = its last publish) and when it resumes (stale=false).
+func (h *DetectionHealth) SetOnStaleChange(callback func(stale bool, at time.Time)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onStale = callback
+}
+
+// SetSuspended tells the tracker the camera has stopped feeding the daemon
+// (power saving), so a version that stops advancing is expected: staleness
+// is not counted while suspended.
+func (h *DetectionHealth) SetSuspended(suspended bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.suspended = suspended
+	h.lastChange = time.Now()
+}
+
+// Start begins polling.
+func (h *DetectionHealth) Start() {
+	h.mu.Lock()
+	h.lastChange = time.Now()
+	h.mu.Unlock()
+	go h.run()
+}
+
+// Stop halts polling.
+func (h *DetectionHealth) Stop() {
+	h.mu.Lock()
+	if !h.stopped {
+		close(h.stop)
+		h.stopped = true
+	}
+	h.mu.Unlock()
+}
+
+func (h *DetectionHealth) run() {
+	ticker := time.NewTicker(h.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.stop:
+			return
+		case now := <-ticker.C:
+			h.observe(h.version(), now)
+		}
+	}
+}
+
+// observe records a version sample and fires stale/alert/recover callbacks on transitions.
+func (h *DetectionHealth) observe(version int, now time.Time) {
+	h.mu.Lock()
+	var alert func(time.Duration)
+	var recovered func()
+	var staleChange func(bool, time.Time)
+	var staleAt time.Time
+	var staleFor time.Duration
+
+	if version != 0 && version != h.lastVersion {
+		if !h.seen {
+			logger.Info("DetectionHealth", "Detection daemon is publishing (version=%d)", version)
+		}
+		h.lastVersion = version
+		h.lastChange = now
+		h.seen = true
+		if h.alerting {
+			h.alerting = false
+			recovered = h.onRecover
+		}
+		if h.stale {
+			h.stale = false
+			staleChange, staleAt = h.onStale, now
+		}
+	} else if h.suspended {
+		h.lastChange = now
+	} else {
+		staleFor = now.Sub(h.lastChange)
+		if !h.stale && staleFor >= h.StaleAfter {
+			h.stale = true
+			staleChange, staleAt = h.onStale, h.lastChange
+		}
+		if !h.alerting && staleFor >= h.AlertAfter {
+			h.alerting = true
+			alert = h.onAlert
+		}
+	}
+	stale := h.stale
+	h.mu.Unlock()
+
+	if staleChange != nil {
+		staleChange(stale, staleAt)
+	}
+
+	if alert != nil {
+		alert(staleFor)
+	}
+	if recovered != nil {
+		recovered()
+	}
+}
+
+// Status returns the current health snapshot.
+func (h *DetectionHealth) Status() DetectionHealthStatus {
+	return h.statusAt(time.Now())
+}
+
+func (h *DetectionHealth) statusAt(now time.Time) DetectionHealthStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stale := now.Sub(h.lastChange)
+	st := DetectionHealthStatus{
+		Healthy:      h.seen && stale < h.StaleAfter,
+		Version:      h.lastVersion,
+		StaleSeconds: stale.Seconds(),
+		Alerting:     h.alerting,
+		Suspended:    h.suspended,
+	}
+	if h.seen {
+		st.LastUpdate = float64(h.lastChange.UnixNano()) / 1e9
+	}
+	return st
+}
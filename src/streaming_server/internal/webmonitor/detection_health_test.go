@@ -0,0 +1,154 @@
+package webmonitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/eventbus"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/webpush"
+)
+
+func TestDetectionHealthTransitions(t *testing.T) {
+	h := NewDetectionHealth(func() int { return 0 })
+	h.StaleAfter = 10 * time.Second
+	h.AlertAfter = 60 * time.Second
+
+	alerts, recoveries := 0, 0
+	h.SetOnAlert(func(time.Duration) { alerts++ })
+	h.SetOnRecover(func() { recoveries++ })
+	var staleEvents []bool
+	var staleSince time.Time
+	h.SetOnStaleChange(func(stale bool, at time.Time) {
+		staleEvents = append(staleEvents, stale)
+		if stale {
+			staleSince = at
+		}
+	})
+
+	start := time.Now()
+	h.lastChange = start
+
+	// Never published: unhealthy
+	if st := h.statusAt(start.Add(time.Second)); st.Healthy {
+		t.Error("expected unhealthy before first version")
+	}
+
+	h.observe(1, start.Add(2*time.Second))
+	if st := h.statusAt(start.Add(3 * time.Second)); !st.Healthy || st.Version != 1 {
+		t.Errorf("expected healthy at version 1, got %+v", st)
+	}
+
+	// Version stops advancing
+	h.observe(1, start.Add(20*time.Second))
+	if st := h.statusAt(start.Add(20 * time.Second)); st.Healthy {
+		t.Error("expected unhealthy after StaleAfter")
+	}
+	if alerts != 0 {
+		t.Fatalf("alert fired before AlertAfter")
+	}
+	if len(staleEvents) != 1 || !staleEvents[0] || !staleSince.Equal(start.Add(2*time.Second)) {
+		t.Fatalf("stale events %v since %v, want one starting at the last publish", staleEvents, staleSince)
+	}
+
+	h.observe(1, start.Add(70*time.Second))
+	h.observe(1, start.Add(80*time.Second))
+	if alerts != 1 {
+		t.Fatalf("alerts = %d, want 1", alerts)
+	}
+	if st := h.statusAt(start.Add(80 * time.Second)); !st.Alerting {
+		t.Error("expected alerting state")
+	}
+
+	// Daemon resumes
+	h.observe(2, start.Add(90*time.Second))
+	if recoveries != 1 {
+		t.Fatalf("recoveries = %d, want 1", recoveries)
+	}
+	if len(staleEvents) != 2 || staleEvents[1] {
+		t.Fatalf("stale events %v, want [true false]", staleEvents)
+	}
+	if st := h.statusAt(start.Add(91 * time.Second)); !st.Healthy || st.Alerting {
+		t.Errorf("expected healthy after recovery, got %+v", st)
+	}
+}
+
+func TestDetectorOfflineNotifies(t *testing.T) {
+	n, err := NewPushNotifier(filepath.Join(t.TempDir(), "vapid.pem"), kv.Doc{}, "mailto:test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = n.Subscribe(testSubscription("https://push.example.com/a"), "en")
+	sent := make(chan PushMessage, 4)
+	n.sender = func(_ context.Context, _ webpush.Subscription, payload []byte) error {
+		var m PushMessage
+		json.Unmarshal(payload, &m)
+		sent <- m
+		return nil
+	}
+
+	h := NewDetectionHealth(func() int { return 0 })
+	h.StaleAfter = 10 * time.Second
+	h.AlertAfter = 60 * time.Second
+	s := &Server{
+		push:             n,
+		alerts:           NewAlertCenter(),
+		detectionHealth:  h,
+		detectionHistory: NewDetectionHistory(time.Hour),
+		topics:           newBusTopics(eventbus.New()),
+	}
+	s.publishDetectionHealth()
+	s.subscribeCamera()
+	notifications, cancel := s.topics.notifications.SubscribeChan("push", notificationBuffer)
+	defer cancel()
+	go s.runNotifier(notifications)
+
+	start := time.Now()
+	h.lastChange = start
+	h.observe(1, start.Add(time.Second))
+	h.observe(1, start.Add(20*time.Second)) // stale: alert only
+	h.observe(1, start.Add(70*time.Second)) // offline
+	select {
+	case m := <-sent:
+		if m.Title != "Detector offline" || m.Tag != "detector-health" || m.URL != "/" {
+			t.Errorf("notification %+v", m)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification for the offline detector")
+	}
+	if active, _ := s.alerts.Active(); len(active) != 1 || active[0].Severity != AlertCritical {
+		t.Errorf("alerts %+v", active)
+	}
+
+	h.observe(2, start.Add(80*time.Second))
+	select {
+	case m := <-sent:
+		t.Errorf("notification on recovery: %+v", m)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestHandleReadyz_NoFrameShm(t *testing.T) {
+	s := &Server{
+		monitor:         NewMonitor(30, nil),
+		detectionHealth: NewDetectionHealth(func() int { return 0 }),
+	}
+	rec := httptest.NewRecorder()
+	s.handleReadyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if healthy, ok := body["detection_daemon_healthy"].(bool); !ok || healthy {
+		t.Errorf("detection_daemon_healthy = %v, want false", body["detection_daemon_healthy"])
+	}
+}
@@ -1,10 +1,12 @@
 package webmonitor
 
 import (
+	"bytes"
 	"encoding/gob"
 	"errors"
 	"io/fs"
 	"os"
+	"slices"
 	"sync"
 	"time"
 )
@@ -13,15 +15,44 @@ import (
 type DetectionHistoryRecord struct {
 	Timestamp float64  `json:"timestamp"`
 	Classes   []string `json:"classes"`
+	PetIDs    []string `json:"pet_ids,omitempty"` // identified pets (see PetIdentifier)
 }
 
+// Gap reasons.
+const (
+	GapMonitorDown   = "monitor_down"   // streaming server was not running
+	GapDetectorStale = "detector_stale" // detection daemon stopped publishing
+)
+
+// HistoryGap marks a span with no detection coverage, so an empty stretch
+// of history can be told apart from "nothing was seen". End is 0 while the
+// gap is still open.
+type HistoryGap struct {
+	Start  float64 `json:"start"`
+	End    float64 `json:"end,omitempty"`
+	Reason string  `json:"reason"`
+}
+
+// minRestartGap is the shortest downtime recorded as a monitor_down gap;
+// below it a restart is indistinguishable from the save interval.
+const minRestartGap = 2 * time.Minute
+
 // DetectionHistory stores a rolling window of detection summaries.
 type DetectionHistory struct {
 	mu      sync.RWMutex
 	records []DetectionHistoryRecord
+	gaps    []HistoryGap
 	window  time.Duration
 }
 
+// historySnapshot is the gob file layout. Files written before gaps were
+// tracked hold a bare []DetectionHistoryRecord.
+type historySnapshot struct {
+	Records []DetectionHistoryRecord
+	Gaps    []HistoryGap
+	SavedAt float64
+}
+
 // NewDetectionHistory creates a history store with the given retention window.
 func NewDetectionHistory(window time.Duration) *DetectionHistory {
 	return &DetectionHistory{
@@ -38,11 +69,15 @@ func (h *DetectionHistory) Record(det *DetectionResult) {
 
 	seen := make(map[string]struct{})
 	classes := make([]string, 0, len(det.Detections))
+	var petIDs []string
 	for _, d := range det.Detections {
 		if _, ok := seen[d.ClassName]; !ok {
 			seen[d.ClassName] = struct{}{}
 			classes = append(classes, d.ClassName)
 		}
+		if d.PetID != "" && !slices.Contains(petIDs, d.PetID) {
+			petIDs = append(petIDs, d.PetID)
+		}
 	}
 
 	h.mu.Lock()
@@ -51,9 +86,14 @@ func (h *DetectionHistory) Record(det *DetectionResult) {
 	h.records = append(h.records, DetectionHistoryRecord{
 		Timestamp: det.Timestamp,
 		Classes:   classes,
+		PetIDs:    petIDs,
 	})
 
-	// Trim old records
+	h.trimLocked()
+}
+
+// trimLocked drops records and closed gaps that fell out of the window.
+func (h *DetectionHistory) trimLocked() {
 	cutoff := float64(time.Now().Unix()) - h.window.Seconds()
 	trimIdx := 0
 	for trimIdx < len(h.records) && h.records[trimIdx].Timestamp < cutoff {
@@ -62,6 +102,38 @@ func (h *DetectionHistory) Record(det *DetectionResult) {
 	if trimIdx > 0 {
 		h.records = h.records[trimIdx:]
 	}
+	h.gaps = slices.DeleteFunc(h.gaps, func(g HistoryGap) bool {
+		return g.End != 0 && g.End < cutoff
+	})
+}
+
+// BeginGap opens a gap with the given reason at t. It is a no-op if a gap
+// with that reason is already open.
+func (h *DetectionHistory) BeginGap(reason string, t time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, g := range h.gaps {
+		if g.Reason == reason && g.End == 0 {
+			return
+		}
+	}
+	h.gaps = append(h.gaps, HistoryGap{Start: unixSeconds(t), Reason: reason})
+	h.trimLocked()
+}
+
+// EndGap closes the open gap with the given reason, if any.
+func (h *DetectionHistory) EndGap(reason string, t time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := range h.gaps {
+		if h.gaps[i].Reason == reason && h.gaps[i].End == 0 {
+			h.gaps[i].End = unixSeconds(t)
+		}
+	}
+}
+
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / 1e9
 }
 
 // Records returns all records within the retention window.
@@ -74,14 +146,46 @@ func (h *DetectionHistory) Records() []DetectionHistoryRecord {
 	return out
 }
 
+// Gaps returns the gaps within the retention window, oldest first.
+func (h *DetectionHistory) Gaps() []HistoryGap {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return slices.Clone(h.gaps)
+}
+
+// Page returns up to limit records older than before (0 = newest), in
+// ascending time order, and whether older records remain.
+func (h *DetectionHistory) Page(before float64, limit int) ([]DetectionHistoryRecord, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	end := len(h.records)
+	if before > 0 {
+		end, _ = slices.BinarySearchFunc(h.records, before, func(r DetectionHistoryRecord, t float64) int {
+			switch {
+			case r.Timestamp < t:
+				return -1
+			case r.Timestamp > t:
+				return 1
+			}
+			return 0
+		})
+	}
+	start := max(end-limit, 0)
+	return slices.Clone(h.records[start:end]), start > 0
+}
+
 // Save writes all records to a gob file atomically (temp + rename).
 func (h *DetectionHistory) Save(path string) error {
 	h.mu.RLock()
-	records := make([]DetectionHistoryRecord, len(h.records))
-	copy(records, h.records)
+	snap := historySnapshot{
+		Records: slices.Clone(h.records),
+		Gaps:    slices.Clone(h.gaps),
+		SavedAt: unixSeconds(time.Now()),
+	}
 	h.mu.RUnlock()
 
-	if len(records) == 0 {
+	if len(snap.Records) == 0 && len(snap.Gaps) == 0 {
 		return nil
 	}
 
@@ -90,7 +194,7 @@ func (h *DetectionHistory) Save(path string) error {
 	if err != nil {
 		return err
 	}
-	if err := gob.NewEncoder(f).Encode(records); err != nil {
+	if err := gob.NewEncoder(f).Encode(snap); err != nil {
 		f.Close()
 		os.Remove(tmp)
 		return err
@@ -102,30 +206,45 @@ func (h *DetectionHistory) Save(path string) error {
 	return os.Rename(tmp, path)
 }
 
-// Load reads records from a gob file, keeping only those within the retention window.
+// Load reads records and gaps from a gob file, keeping only those within
+// the retention window. Gaps left open by the previous run are closed at
+// its last save, and the downtime since then is recorded as a
+// monitor_down gap.
 func (h *DetectionHistory) Load(path string) error {
-	f, err := os.Open(path)
+	data, err := os.ReadFile(path)
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
 			return nil
 		}
 		return err
 	}
-	defer f.Close()
 
-	var records []DetectionHistoryRecord
-	if err := gob.NewDecoder(f).Decode(&records); err != nil {
-		return err
+	var snap historySnapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&snap); err != nil {
+		// Legacy layout: records only.
+		if gob.NewDecoder(bytes.NewReader(data)).Decode(&snap.Records) != nil {
+			return err
+		}
 	}
 
-	cutoff := float64(time.Now().Unix()) - h.window.Seconds()
-	trimIdx := 0
-	for trimIdx < len(records) && records[trimIdx].Timestamp < cutoff {
-		trimIdx++
+	lastSeen := snap.SavedAt
+	if lastSeen == 0 && len(snap.Records) > 0 {
+		lastSeen = snap.Records[len(snap.Records)-1].Timestamp
+	}
+	for i := range snap.Gaps {
+		if snap.Gaps[i].End == 0 {
+			snap.Gaps[i].End = lastSeen
+		}
+	}
+	now := time.Now()
+	if lastSeen > 0 && unixSeconds(now)-lastSeen >= minRestartGap.Seconds() {
+		snap.Gaps = append(snap.Gaps, HistoryGap{Start: lastSeen, End: unixSeconds(now), Reason: GapMonitorDown})
 	}
 
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	h.records = records[trimIdx:]
+	h.records = snap.Records
+	h.gaps = snap.Gaps
+	h.trimLocked()
 	return nil
 }
@@ -1,6 +1,7 @@
 package webmonitor
 
 import (
+	"encoding/gob"
 	"os"
 	"path/filepath"
 	"testing"
@@ -104,3 +105,74 @@ func TestSaveEmptyNoFile(t *testing.T) {
 		t.Error("expected no file for empty history")
 	}
 }
+
+func TestLoadLegacyAddsDowntimeGap(t *testing.T) {
+	now := float64(time.Now().Unix())
+	path := filepath.Join(t.TempDir(), "history.gob")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := []DetectionHistoryRecord{{Timestamp: now - 600, Classes: []string{"cat"}}}
+	if err := gob.NewEncoder(f).Encode(legacy); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	h := NewDetectionHistory(24 * time.Hour)
+	if err := h.Load(path); err != nil {
+		t.Fatalf("Load legacy: %v", err)
+	}
+	if len(h.Records()) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(h.Records()))
+	}
+	gaps := h.Gaps()
+	if len(gaps) != 1 || gaps[0].Reason != GapMonitorDown || gaps[0].Start != now-600 || gaps[0].End < now {
+		t.Fatalf("gaps = %+v", gaps)
+	}
+}
+
+func TestGapsRoundTrip(t *testing.T) {
+	h := NewDetectionHistory(24 * time.Hour)
+	start := time.Now().Add(-time.Minute)
+	h.BeginGap(GapDetectorStale, start)
+	h.BeginGap(GapDetectorStale, start.Add(time.Second)) // already open
+	if gaps := h.Gaps(); len(gaps) != 1 || gaps[0].End != 0 {
+		t.Fatalf("open gaps = %+v", gaps)
+	}
+
+	// Saved while the gap was open: closed at the save on reload.
+	path := filepath.Join(t.TempDir(), "history.gob")
+	if err := h.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	h2 := NewDetectionHistory(24 * time.Hour)
+	if err := h2.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	gaps := h2.Gaps()
+	if len(gaps) != 1 || gaps[0].End == 0 {
+		t.Fatalf("reloaded gaps = %+v (restart under 2m must not add monitor_down)", gaps)
+	}
+}
+
+func TestHistoryPage(t *testing.T) {
+	h := NewDetectionHistory(24 * time.Hour)
+	now := float64(time.Now().Unix())
+	for i := range 5 {
+		h.Record(&DetectionResult{Timestamp: now - float64(50-i*10), Detections: []Detection{{ClassName: "cat"}}})
+	}
+
+	page, more := h.Page(0, 2)
+	if len(page) != 2 || !more || page[0].Timestamp != now-20 || page[1].Timestamp != now-10 {
+		t.Fatalf("first page %+v more=%v", page, more)
+	}
+	page, more = h.Page(page[0].Timestamp, 2)
+	if len(page) != 2 || !more || page[1].Timestamp != now-30 {
+		t.Fatalf("second page %+v more=%v", page, more)
+	}
+	page, more = h.Page(page[0].Timestamp, 2)
+	if len(page) != 1 || more || page[0].Timestamp != now-50 {
+		t.Fatalf("last page %+v more=%v", page, more)
+	}
+}
@@ -0,0 +1,406 @@
+package webmonitor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	pb "github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/proto"
+)
+
+// Detection source kinds (Config.DetectionSource).
+const (
+	detectionSourceSHM    = "shm"    // C shared-memory daemon (default)
+	detectionSourceNDJSON = "ndjson" // JSON lines pushed to a Unix socket
+	detectionSourceGRPC   = "grpc"   // server-streaming petcamera.DetectionService
+)
+
+// DetectionSource supplies detection results to the Monitor and the
+// DetectionBroadcaster. The shared-memory reader is one implementation;
+// the others let a detector feed the monitor without the C daemon.
+type DetectionSource interface {
+	// LatestDetection returns the newest result, or false when nothing new
+	// arrived since the previous call.
+	LatestDetection() (*DetectionResult, bool)
+	// WaitDetectionUpdate blocks until a new result may be available or
+	// timeoutMs elapses.
+	WaitDetectionUpdate(timeoutMs int) bool
+	Close()
+}
+
+// newDetectionSource opens the source selected by cfg. shm is the reader
+// already opened for frames; it is reused for the shm source and may be nil.
+func newDetectionSource(cfg Config, shm *shmReader) (DetectionSource, error) {
+	switch cfg.DetectionSource {
+	case "", detectionSourceSHM:
+		if shm == nil {
+			return nil, nil
+		}
+		return shm, nil
+	case detectionSourceNDJSON:
+		return newNDJSONDetectionSource(cfg.DetectionAddr)
+	case detectionSourceGRPC:
+		return newGRPCDetectionSource(cfg.DetectionAddr)
+	}
+	return nil, fmt.Errorf("unknown detection source %q (want shm, ndjson or grpc)", cfg.DetectionSource)
+}
+
+// detectionFeed holds the latest pushed result for sources that receive
+// detections asynchronously. Versions are assigned on arrival so the
+// broadcaster's duplicate check works as it does for shared memory.
+type detectionFeed struct {
+	mu      sync.Mutex
+	latest  *DetectionResult
+	version int
+	read    int
+	notify  chan struct{}
+	done    chan struct{}
+	closed  bool
+}
+
+func newDetectionFeed() *detectionFeed {
+	return &detectionFeed{
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+}
+
+func (f *detectionFeed) push(det *DetectionResult) {
+	f.mu.Lock()
+	f.version++
+	det.Version = f.version
+	det.NumDetections = len(det.Detections)
+	f.latest = det
+	f.mu.Unlock()
+	select {
+	case f.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (f *detectionFeed) LatestDetection() (*DetectionResult, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.latest == nil || f.version == f.read {
+		return nil, false
+	}
+	f.read = f.version
+	det := *f.latest
+	return &det, true
+}
+
+func (f *detectionFeed) WaitDetectionUpdate(timeoutMs int) bool {
+	f.mu.Lock()
+	pending := f.version != f.read
+	f.mu.Unlock()
+	if pending {
+		return true
+	}
+	timer := time.NewTimer(time.Duration(timeoutMs) * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case <-f.notify:
+		return true
+	case <-timer.C:
+	case <-f.done:
+	}
+	return false
+}
+
+// closeFeed marks the feed closed; it reports false if it already was.
+func (f *detectionFeed) closeFeed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return false
+	}
+	close(f.done)
+	f.closed = true
+	return true
+}
+
+// ndjsonDetectionSource listens on a Unix socket for detectors that write
+// one DetectionResult JSON object per line (the /api/detections shape).
+// Several detectors may connect; results are merged in arrival order.
+type ndjsonDetectionSource struct {
+	*detectionFeed
+	path     string
+	listener net.Listener
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+func newNDJSONDetectionSource(path string) (*ndjsonDetectionSource, error) {
+	if path == "" {
+		return nil, errors.New("ndjson detection source needs -detection-addr (socket path)")
+	}
+	os.Remove(path) // stale socket from a previous run
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	s := &ndjsonDetectionSource{
+		detectionFeed: newDetectionFeed(),
+		path:          path,
+		listener:      ln,
+		conns:         make(map[net.Conn]struct{}),
+	}
+	go s.accept()
+	logger.Info("DetectionSource", "Listening for ndjson detections on %s", path)
+	return s, nil
+}
+
+func (s *ndjsonDetectionSource) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.done:
+			default:
+				logger.Warn("DetectionSource", "ndjson accept failed: %v", err)
+			}
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		go s.read(conn)
+	}
+}
+
+func (s *ndjsonDetectionSource) read(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+	logger.Info("DetectionSource", "ndjson detector connected")
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var det DetectionResult
+		if err := json.Unmarshal(line, &det); err != nil {
+			logger.Warn("DetectionSource", "Skipping malformed ndjson line: %v", err)
+			continue
+		}
+		s.push(&det)
+	}
+	if err := scanner.Err(); err != nil {
+		logger.Warn("DetectionSource", "ndjson detector read failed: %v", err)
+	}
+	logger.Info("DetectionSource", "ndjson detector disconnected")
+}
+
+// Close stops listening, drops connected detectors and removes the socket.
+func (s *ndjsonDetectionSource) Close() {
+	if !s.closeFeed() {
+		return
+	}
+	s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	os.Remove(s.path)
+}
+
+// grpcStreamPath is the method called on the detector's gRPC server.
+const grpcStreamPath = "/petcamera.DetectionService/StreamDetections"
+
+// grpcDetectionSource subscribes to a detector's DetectionService stream
+// over plaintext HTTP/2 and reconnects with backoff when it ends. Only the
+// subset of gRPC needed for one uncompressed server stream is implemented,
+// so no gRPC library is required.
+type grpcDetectionSource struct {
+	*detectionFeed
+	addr   string
+	client *http.Client
+	cancel context.CancelFunc
+	ctx    context.Context
+}
+
+func newGRPCDetectionSource(addr string) (*grpcDetectionSource, error) {
+	if addr == "" {
+		return nil, errors.New("grpc detection source needs -detection-addr (host:port)")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &grpcDetectionSource{
+		detectionFeed: newDetectionFeed(),
+		addr:          addr,
+		client:        h2cClient(0),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+	go s.run()
+	return s, nil
+}
+
+func (s *grpcDetectionSource) run() {
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := s.stream()
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+		if time.Since(start) > 30*time.Second {
+			backoff = time.Second // the stream was healthy for a while
+		}
+		logger.Warn("DetectionSource", "gRPC stream from %s ended: %v (retry in %v)", s.addr, err, backoff)
+		select {
+		case <-s.done:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+// stream runs one StreamDetections call until the server ends it.
+func (s *grpcDetectionSource) stream() error {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, "http://"+s.addr+grpcStreamPath,
+		bytes.NewReader(grpcFrame(nil))) // empty StreamDetectionsRequest
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/grpc+proto")
+	req.Header.Set("TE", "trailers")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	if err := grpcStatus(resp.Header); err != nil {
+		return err // trailers-only response
+	}
+	logger.Info("DetectionSource", "Streaming detections from gRPC %s", s.addr)
+
+	r := bufio.NewReader(resp.Body)
+	for {
+		msg, err := readGRPCFrame(r)
+		if err == io.EOF {
+			if err := grpcStatus(resp.Trailer); err != nil {
+				return err
+			}
+			return errors.New("stream closed by server")
+		}
+		if err != nil {
+			return err
+		}
+		var ev pb.DetectionEvent
+		if err := proto.Unmarshal(msg, &ev); err != nil {
+			return fmt.Errorf("decode DetectionEvent: %w", err)
+		}
+		s.push(detectionResultFromProto(&ev))
+	}
+}
+
+// Close cancels the stream.
+func (s *grpcDetectionSource) Close() {
+	if s.closeFeed() {
+		s.cancel()
+	}
+}
+
+// h2cClient returns a client speaking plaintext HTTP/2 (gRPC without TLS).
+func h2cClient(timeout time.Duration) *http.Client {
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	return &http.Client{Timeout: timeout, Transport: &http.Transport{Protocols: protocols}}
+}
+
+// grpcFrame wraps msg in a gRPC length-prefixed message (uncompressed).
+func grpcFrame(msg []byte) []byte {
+	out := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(out[1:], uint32(len(msg)))
+	return append(out, msg...)
+}
+
+// readGRPCFrame reads one length-prefixed message; io.EOF means the stream
+// ended cleanly between messages.
+func readGRPCFrame(r io.Reader) ([]byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errors.New("truncated gRPC frame header")
+		}
+		return nil, err
+	}
+	if hdr[0] != 0 {
+		return nil, errors.New("compressed gRPC messages are not supported")
+	}
+	n := binary.BigEndian.Uint32(hdr[1:])
+	if n > 4<<20 {
+		return nil, fmt.Errorf("gRPC message too large (%d bytes)", n)
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, fmt.Errorf("truncated gRPC message: %w", err)
+	}
+	return msg, nil
+}
+
+// grpcStatus returns the error carried by grpc-status, if any.
+func grpcStatus(h http.Header) error {
+	code := h.Get("Grpc-Status")
+	if code == "" || code == "0" {
+		return nil
+	}
+	return fmt.Errorf("grpc-status %s: %s", code, h.Get("Grpc-Message"))
+}
+
+func detectionResultFromProto(ev *pb.DetectionEvent) *DetectionResult {
+	det := &DetectionResult{
+		FrameNumber: int(ev.GetFrameNumber()),
+		Timestamp:   ev.GetTimestamp(),
+		Detections:  make([]Detection, 0, len(ev.GetDetections())),
+	}
+	for _, d := range ev.GetDetections() {
+		b := d.GetBbox()
+		det.Detections = append(det.Detections, Detection{
+			ClassName:  d.GetLabel(),
+			Confidence: float64(d.GetConfidence()),
+			PetID:      d.GetPetId(),
+			BBox: BoundingBox{
+				X: int(b.GetX()),
+				Y: int(b.GetY()),
+				W: int(b.GetW()),
+				H: int(b.GetH()),
+			},
+		})
+	}
+	if in := ev.GetFrame().GetDetector(); in != nil {
+		c := in.GetContent()
+		det.Frame = &DetectionFrame{Detector: &DetectorInput{
+			Width:   int(in.GetWidth()),
+			Height:  int(in.GetHeight()),
+			Content: BoundingBox{X: int(c.GetX()), Y: int(c.GetY()), W: int(c.GetW()), H: int(c.GetH())},
+		}}
+	}
+	return det
+}
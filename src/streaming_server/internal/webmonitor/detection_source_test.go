@@ -0,0 +1,94 @@
+package webmonitor
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/proto"
+)
+
+func waitDetection(t *testing.T, src DetectionSource) *DetectionResult {
+	t.Helper()
+	for range 50 {
+		src.WaitDetectionUpdate(100)
+		if det, ok := src.LatestDetection(); ok {
+			return det
+		}
+	}
+	t.Fatal("no detection received")
+	return nil
+}
+
+func TestNDJSONDetectionSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "det.sock")
+	src, err := newDetectionSource(Config{DetectionSource: "ndjson", DetectionAddr: path}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("not json\n" +
+		`{"frame_number":7,"timestamp":1.5,"detections":[{"class_name":"cat","confidence":0.9,"bbox":{"x":1,"y":2,"w":3,"h":4}}]}` + "\n"))
+
+	det := waitDetection(t, src)
+	if det.FrameNumber != 7 || det.NumDetections != 1 || det.Version != 1 || det.Detections[0].ClassName != "cat" {
+		t.Fatalf("got %+v", det)
+	}
+	if _, ok := src.LatestDetection(); ok {
+		t.Fatal("same result returned twice")
+	}
+}
+
+func TestGRPCDetectionSource(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != grpcStreamPath || r.ProtoMajor != 2 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		msg, _ := proto.Marshal(&pb.DetectionEvent{
+			FrameNumber: 42,
+			Detections:  []*pb.Detection{{Label: "dog", Confidence: 0.8, Bbox: &pb.BBox{X: 10, W: 20}}},
+		})
+		w.Write(grpcFrame(msg))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	defer srv.Close()
+
+	src, err := newDetectionSource(Config{DetectionSource: "grpc", DetectionAddr: strings.TrimPrefix(srv.URL, "http://")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	det := waitDetection(t, src)
+	if det.FrameNumber != 42 || len(det.Detections) != 1 || det.Detections[0].ClassName != "dog" || det.Detections[0].BBox.W != 20 {
+		t.Fatalf("got %+v", det)
+	}
+}
+
+func TestNewDetectionSource_Unknown(t *testing.T) {
+	if _, err := newDetectionSource(Config{DetectionSource: "carrier-pigeon"}, nil); err == nil {
+		t.Fatal("expected error for unknown source")
+	}
+	if src, err := newDetectionSource(Config{}, nil); src != nil || err != nil {
+		t.Fatalf("shm without reader: %v, %v", src, err)
+	}
+}
@@ -0,0 +1,100 @@
+package webmonitor
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// vttCueMax bounds a cue when the next sidecar line is further away: a
+// detection stays on screen this long after it was logged.
+const vttCueMax = 2 * time.Second
+
+// vttCue is the payload of one detection metadata cue.
+type vttCue struct {
+	Class      string      `json:"class"`
+	Confidence float64     `json:"confidence"`
+	BBox       BoundingBox `json:"bbox"` // 1280x720 coordinates, like detection events
+}
+
+// readSidecar parses a detection sidecar. Malformed lines (a write cut
+// short by a crash) are skipped.
+func readSidecar(r io.Reader) []sidecarEntry {
+	var entries []sidecarEntry
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var e sidecarEntry
+		if json.Unmarshal(scanner.Bytes(), &e) != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// writeDetectionVTT writes sidecar entries as a WebVTT metadata track
+// (<track kind="metadata">): one cue per entry, from its offset until the
+// next entry (at most vttCueMax later), carrying the detection as JSON.
+// Offsets are relative to the recording's first frame, which is where a
+// player's media timeline starts.
+func writeDetectionVTT(w io.Writer, entries []sidecarEntry) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("WEBVTT - pet camera detections\n")
+	for i, e := range entries {
+		start := time.Duration(e.Offset * float64(time.Second))
+		end := start + vttCueMax
+		if i+1 < len(entries) {
+			if next := time.Duration(entries[i+1].Offset * float64(time.Second)); next > start && next < end {
+				end = next
+			}
+		}
+		payload, err := json.Marshal(vttCue{Class: e.Class, Confidence: e.Confidence, BBox: e.BBox})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(bw, "\n%d\n%s --> %s\n%s\n", i+1, vttTimestamp(start), vttTimestamp(end), payload)
+	}
+	return bw.Flush()
+}
+
+// vttTimestamp formats d as a WebVTT timestamp (hh:mm:ss.ttt).
+func vttTimestamp(d time.Duration) string {
+	d = max(d, 0).Round(time.Millisecond)
+	h := d / time.Hour
+	m := d % time.Hour / time.Minute
+	s := d % time.Minute / time.Second
+	ms := d % time.Second / time.Millisecond
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", h, m, s, ms)
+}
+
+// handleRecordingVTT serves GET /api/recordings/{filename}/detections.vtt:
+// the recording's detection sidecar as a WebVTT metadata track, so any
+// player (or third-party dashboard) can render the boxes in sync with the
+// video. A recording without detections gets an empty track.
+func (s *Server) handleRecordingVTT(w http.ResponseWriter, r *http.Request, filename string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !strings.HasSuffix(filename, ".mp4") && !strings.HasSuffix(filename, ".hevc") {
+		writeJSONWithStatus(w, map[string]any{"error": "not a recording"}, http.StatusBadRequest)
+		return
+	}
+	st := s.recorder.Storage()
+	if _, err := st.Stat(filename); err != nil {
+		writeJSONWithStatus(w, map[string]any{"error": "recording not found: " + filename}, http.StatusNotFound)
+		return
+	}
+	var entries []sidecarEntry
+	if rc, err := st.Open(sidecarName(filename)); err == nil {
+		entries = readSidecar(rc)
+		rc.Close()
+	}
+	w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	writeDetectionVTT(w, entries)
+}
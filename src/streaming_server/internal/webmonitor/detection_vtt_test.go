@@ -0,0 +1,70 @@
+package webmonitor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteDetectionVTT(t *testing.T) {
+	entries := readSidecar(strings.NewReader(`{"t":1.5,"class":"cat","confidence":0.9,"bbox":{"x":10,"y":20,"w":30,"h":40}}
+{"t":2,"class":"cat","confidence":0.8
+{"t":2.25,"class":"dog","confidence":0.7,"bbox":{"x":1,"y":2,"w":3,"h":4}}
+{"t":3725,"class":"cat","confidence":0.6,"bbox":{"x":0,"y":0,"w":1,"h":1}}
+`))
+	if len(entries) != 3 {
+		t.Fatalf("parsed %d entries, want 3 (torn line skipped)", len(entries))
+	}
+	var b strings.Builder
+	if err := writeDetectionVTT(&b, entries); err != nil {
+		t.Fatal(err)
+	}
+	want := `WEBVTT - pet camera detections
+
+1
+00:00:01.500 --> 00:00:02.250
+{"class":"cat","confidence":0.9,"bbox":{"x":10,"y":20,"w":30,"h":40}}
+
+2
+00:00:02.250 --> 00:00:04.250
+{"class":"dog","confidence":0.7,"bbox":{"x":1,"y":2,"w":3,"h":4}}
+
+3
+01:02:05.000 --> 01:02:07.000
+{"class":"cat","confidence":0.6,"bbox":{"x":0,"y":0,"w":1,"h":1}}
+`
+	if b.String() != want {
+		t.Fatalf("got\n%s\nwant\n%s", b.String(), want)
+	}
+	if got := vttTimestamp(-time.Second); got != "00:00:00.000" {
+		t.Fatalf("negative offset %q", got)
+	}
+}
+
+func TestHandleRecordingVTT(t *testing.T) {
+	dir := t.TempDir()
+	s := &Server{recorder: NewRecorder(dir, "/nonexistent")}
+	os.WriteFile(filepath.Join(dir, "recording_20261016_120000.mp4"), []byte("mp4"), 0644)
+	os.WriteFile(filepath.Join(dir, "recording_20261016_120000"+detectionSidecarExt), []byte(`{"t":1,"class":"cat","confidence":0.9}`+"\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "recording_20261016_130000.mp4"), []byte("mp4"), 0644)
+
+	get := func(name string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.handleRecordingDownload(rec, httptest.NewRequest(http.MethodGet, "/api/recordings/"+name+"/detections.vtt", nil))
+		return rec
+	}
+	rec := get("recording_20261016_120000.mp4")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/vtt; charset=utf-8" || !strings.Contains(rec.Body.String(), `"class":"cat"`) {
+		t.Fatalf("%d %q\n%s", rec.Code, rec.Header().Get("Content-Type"), rec.Body)
+	}
+	if rec := get("recording_20261016_130000.mp4"); rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "WEBVTT - pet camera detections" {
+		t.Fatalf("no sidecar: %d\n%s", rec.Code, rec.Body)
+	}
+	if rec := get("recording_20261016_140000.mp4"); rec.Code != http.StatusNotFound {
+		t.Fatalf("missing recording: %d", rec.Code)
+	}
+}
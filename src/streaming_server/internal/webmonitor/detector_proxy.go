@@ -0,0 +1,65 @@
+package webmonitor
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+)
+
+const detectorProxyPrefix = "/detector"
+
+// newDetectorProxy returns the /detector/ handler forwarding to the
+// detection daemon's debug UI at upstream, so it is reachable through the
+// monitor's origin instead of a separate port forward. Every request must
+// carry token as a Bearer token or as the Basic auth password (any user
+// name), which lets a browser log in through its own prompt.
+func newDetectorProxy(upstream, token string) (http.Handler, error) {
+	target, err := url.Parse(upstream)
+	if err != nil || target.Scheme == "" || target.Host == "" {
+		return nil, fmt.Errorf("detector proxy: invalid upstream %q", upstream)
+	}
+	rp := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.SetXForwarded()
+			// Root-relative links in the debug UI can use the prefix
+			pr.Out.Header.Set("X-Forwarded-Prefix", detectorProxyPrefix)
+			pr.Out.Header.Del("Authorization")
+			pr.Out.Header.Del("Cookie")
+		},
+		FlushInterval: -1, // live debug views (SSE, MJPEG) must not be buffered
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			if r.Context().Err() != nil {
+				return
+			}
+			writeJSONWithStatus(w, map[string]any{"error": "detector unreachable: " + err.Error()}, http.StatusBadGateway)
+		},
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			writeJSONWithStatus(w, map[string]any{"error": "detector proxy disabled: PET_CAMERA_DETECTOR_PROXY_TOKEN is not set"}, http.StatusServiceUnavailable)
+			return
+		}
+		if !detectorProxyAuthorized(r, token) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="detector", charset="UTF-8"`)
+			writeJSONWithStatus(w, map[string]any{"error": "unauthorized"}, http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == detectorProxyPrefix {
+			http.Redirect(w, r, detectorProxyPrefix+"/", http.StatusMovedPermanently)
+			return
+		}
+		http.StripPrefix(detectorProxyPrefix, rp).ServeHTTP(w, r)
+	}), nil
+}
+
+func detectorProxyAuthorized(r *http.Request, token string) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		_, got, ok = r.BasicAuth()
+	}
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
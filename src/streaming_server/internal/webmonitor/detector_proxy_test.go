@@ -0,0 +1,97 @@
+package webmonitor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+)
+
+func TestDetectorProxy(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			http.Error(w, "credentials leaked", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(r.URL.Path + " " + r.Header.Get("X-Forwarded-Prefix")))
+	}))
+	defer upstream.Close()
+
+	h, err := newDetectorProxy(upstream.URL, "s3cr3t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(target string, auth func(*http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		if auth != nil {
+			auth(req)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get("/detector/debug", nil); rec.Code != 401 || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("no credentials: status %d", rec.Code)
+	}
+	if rec := get("/detector/debug", func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") }); rec.Code != 401 {
+		t.Fatalf("wrong token: status %d", rec.Code)
+	}
+	rec := get("/detector/debug", func(r *http.Request) { r.SetBasicAuth("admin", "s3cr3t") })
+	if rec.Code != 200 || rec.Body.String() != "/debug /detector" {
+		t.Fatalf("basic auth: status %d, body %q", rec.Code, rec.Body.String())
+	}
+	if rec := get("/detector", func(r *http.Request) { r.Header.Set("Authorization", "Bearer s3cr3t") }); rec.Code != 301 {
+		t.Fatalf("bare prefix: status %d", rec.Code)
+	}
+
+	if _, err := newDetectorProxy("127.0.0.1:8084", "s3cr3t"); err == nil {
+		t.Fatal("upstream without scheme accepted")
+	}
+}
+
+func TestDetectorProxyWithAccessTokens(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("debug ui"))
+	}))
+	defer upstream.Close()
+	proxy, err := newDetectorProxy(upstream.URL, "s3cr3t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := NewTokenStore(kv.Doc{})
+	_, secret, _ := ts.Create(AccessToken{Name: "neighbor"}, time.Now())
+	s := &Server{cfg: Config{AdminToken: "admin"}, tokens: ts}
+	mux := http.NewServeMux()
+	mux.Handle("/detector/", proxy)
+	mux.HandleFunc("/api/status", func(w http.ResponseWriter, r *http.Request) {})
+	h := s.tokenMiddleware(mux)
+	get := func(target string, auth func(*http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		if auth != nil {
+			auth(req)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The browser's Basic auth prompt still logs in once API tokens exist
+	rec := get("/detector/debug", func(r *http.Request) { r.SetBasicAuth("admin", "s3cr3t") })
+	if rec.Code != 200 || rec.Body.String() != "debug ui" {
+		t.Fatalf("basic auth: status %d, body %q", rec.Code, rec.Body.String())
+	}
+	rec = get("/detector/debug", nil)
+	if rec.Code != 401 || rec.Header().Get("WWW-Authenticate") != `Basic realm="detector", charset="UTF-8"` {
+		t.Fatalf("no credentials: status %d, %q", rec.Code, rec.Header().Get("WWW-Authenticate"))
+	}
+	// An access token is no detector credential, and the detector's is no API token
+	if rec := get("/detector/debug", func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+secret) }); rec.Code != 401 {
+		t.Fatalf("access token: status %d", rec.Code)
+	}
+	if rec := get("/api/status", func(r *http.Request) { r.SetBasicAuth("admin", "s3cr3t") }); rec.Code != 401 {
+		t.Fatalf("detector credential on the API: status %d", rec.Code)
+	}
+}
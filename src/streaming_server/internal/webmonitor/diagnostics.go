@@ -0,0 +1,70 @@
+package webmonitor
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/diagnostics"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/shm"
+)
+
+// DiagnosticsOptions derives the environment checks for a monitor config:
+// every camera SHM segment, the monitor, streaming server and detector ports,
+// and the recordings directory.
+func DiagnosticsOptions(cfg Config) diagnostics.Options {
+	var ports []diagnostics.Port
+	if cfg.Addr != "" { // a Unix socket only is not probed
+		ports = append(ports, diagnostics.Port{
+			Name:  "web monitor",
+			Addr:  localAddr(cfg.Addr),
+			Probe: "/api/status",
+			TLS:   cfg.TLSCertFile != "" && cfg.TLSKeyFile != "",
+		})
+	}
+	if u, err := url.Parse(cfg.WebRTCBaseURL); err == nil && u.Host != "" {
+		ports = append(ports, diagnostics.Port{
+			Name:  "streaming server",
+			Addr:  u.Host,
+			Probe: "/health",
+			TLS:   u.Scheme == "https",
+		})
+	}
+	if cfg.DetectPort != "" {
+		ports = append(ports, diagnostics.Port{Name: "detector", Addr: net.JoinHostPort("127.0.0.1", cfg.DetectPort)})
+	}
+	return diagnostics.Options{
+		ShmNames:      diagnostics.DefaultShmNames,
+		ShmSizes:      shm.SegmentSizes(),
+		Ports:         ports,
+		RecordingsDir: cfg.RecordingOutputPath,
+	}
+}
+
+// localAddr turns a listen address (":8080") into one we can dial.
+func localAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// handleDiagnostics serves GET /api/diagnostics: all environment checks with
+// hints, for the UI's diagnostics panel and `petcam doctor`.
+func (s *Server) handleDiagnostics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	results := diagnostics.Run(DiagnosticsOptions(s.cfg))
+	writeJSON(w, map[string]any{
+		"ok":         !diagnostics.Failed(results),
+		"results":    results,
+		"checked_at": float64(time.Now().Unix()),
+	})
+}
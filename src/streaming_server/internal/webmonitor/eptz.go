@@ -0,0 +1,139 @@
+package webmonitor
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// StreamProfile selects what picture an MJPEG viewer is sent.
+type StreamProfile string
+
+const (
+	ProfileFull   StreamProfile = ""       // the whole frame
+	ProfileFollow StreamProfile = "follow" // digital crop that keeps the pet centered (ePTZ)
+)
+
+// ParseStreamProfile parses the ?profile= query value of /stream.
+func ParseStreamProfile(s string) (StreamProfile, bool) {
+	switch p := StreamProfile(s); p {
+	case ProfileFull, "full":
+		return ProfileFull, true
+	case ProfileFollow:
+		return p, true
+	}
+	return ProfileFull, false
+}
+
+// Crop windows are aligned for the hardware JPEG encoder (16-pixel MCU
+// rows and columns) and NV12 chroma (even offsets).
+const (
+	followAlign           = 16
+	defaultFollowSmooth   = 0.15
+	defaultFollowRecenter = 3 * time.Second
+)
+
+// PetFollow is the electronic pan/zoom for ProfileFollow viewers: a crop of
+// 1/Zoom of the frame whose center eases toward the most confident pet, and
+// back to the frame center once no pet has been seen for Recenter.
+type PetFollow struct {
+	Zoom      float64       // > 1; 2 crops the middle quarter of the area
+	Smoothing float64       // fraction of the way to the pet moved per frame (0-1]
+	Recenter  time.Duration // without a pet this long, ease back to the middle
+
+	mu     sync.Mutex
+	tx, ty float64 // target center, normalized to the frame
+	x, y   float64 // smoothed center
+	seen   time.Time
+}
+
+// NewPetFollow returns a follower that crops 1/zoom of the frame, starting
+// at the frame center.
+func NewPetFollow(zoom float64) *PetFollow {
+	return &PetFollow{
+		Zoom:      zoom,
+		Smoothing: defaultFollowSmooth,
+		Recenter:  defaultFollowRecenter,
+		tx:        0.5, ty: 0.5, x: 0.5, y: 0.5,
+	}
+}
+
+// Observe sets the target to the center of the most confident pet in det.
+// Results without a pet leave the target alone until Recenter passes.
+func (f *PetFollow) Observe(det *DetectionResult, now time.Time) {
+	x, y, ok := petCenter(det)
+	if !ok {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tx, f.ty = x, y
+	f.seen = now
+}
+
+// petCenter returns the center of the most confident pet in det, normalized
+// to the frame.
+func petCenter(det *DetectionResult) (x, y float64, ok bool) {
+	var best *Detection
+	for i := range det.Detections {
+		d := &det.Detections[i]
+		if isPetClass(d.ClassName) && (best == nil || d.Confidence > best.Confidence) {
+			best = d
+		}
+	}
+	if best == nil {
+		return 0, 0, false
+	}
+	x = (float64(best.BBox.X) + float64(best.BBox.W)/2) / detectionRefW
+	y = (float64(best.BBox.Y) + float64(best.BBox.H)/2) / detectionRefH
+	return x, y, true
+}
+
+// Window advances the smoothed center by one frame and returns the crop
+// rectangle for a w×h frame. Frames too small to crop come back whole.
+func (f *PetFollow) Window(w, h int, now time.Time) (x, y, cw, ch int) {
+	cw = alignDown(int(float64(w)/f.Zoom), followAlign)
+	ch = alignDown(int(float64(h)/f.Zoom), followAlign)
+	if cw == 0 || ch == 0 || cw >= w || ch >= h {
+		return 0, 0, w, h
+	}
+
+	f.mu.Lock()
+	if now.Sub(f.seen) > f.Recenter {
+		f.tx, f.ty = 0.5, 0.5
+	}
+	f.x += (f.tx - f.x) * f.Smoothing
+	f.y += (f.ty - f.y) * f.Smoothing
+	cx, cy := f.x, f.y
+	f.mu.Unlock()
+
+	x = clampInt(int(math.Round(cx*float64(w)))-cw/2, 0, w-cw) &^ 1
+	y = clampInt(int(math.Round(cy*float64(h)))-ch/2, 0, h-ch) &^ 1
+	return x, y, cw, ch
+}
+
+// Center returns the smoothed crop center, normalized to the frame.
+func (f *PetFollow) Center() (x, y float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.x, f.y
+}
+
+func alignDown(v, a int) int { return v / a * a }
+
+func clampInt(v, lo, hi int) int {
+	return max(lo, min(v, hi))
+}
+
+// nv12Crop copies the cw×ch rectangle at (x, y) of a w-wide NV12 frame into
+// dst. x and y must be even.
+func nv12Crop(dst, src []byte, w, h, x, y, cw, ch int) {
+	for row := range ch {
+		copy(dst[row*cw:(row+1)*cw], src[(y+row)*w+x:])
+	}
+	suv := src[w*h:]
+	duv := dst[cw*ch:]
+	for row := range ch / 2 {
+		copy(duv[row*cw:(row+1)*cw], suv[(y/2+row)*w+x:])
+	}
+}
@@ -0,0 +1,95 @@
+package webmonitor
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestParseStreamProfile(t *testing.T) {
+	for in, want := range map[string]StreamProfile{"": ProfileFull, "full": ProfileFull, "follow": ProfileFollow} {
+		if got, ok := ParseStreamProfile(in); !ok || got != want {
+			t.Errorf("%q = %q, %v", in, got, ok)
+		}
+	}
+	if _, ok := ParseStreamProfile("zoom"); ok {
+		t.Error("unknown profile accepted")
+	}
+}
+
+func TestPetFollowWindow(t *testing.T) {
+	f := NewPetFollow(2)
+	f.Smoothing = 1 // jump straight to the target
+	now := time.Now()
+
+	if x, y, cw, ch := f.Window(1280, 720, now); x != 320 || y != 184 || cw != 640 || ch != 352 {
+		t.Fatalf("initial window %d,%d %dx%d", x, y, cw, ch)
+	}
+	// A cat in the bottom-right corner pins the window there; the person is ignored
+	f.Observe(&DetectionResult{Detections: []Detection{
+		{ClassName: "person", Confidence: 0.9, BBox: BoundingBox{X: 0, Y: 0, W: 100, H: 100}},
+		{ClassName: "cat", Confidence: 0.5, BBox: BoundingBox{X: 1180, Y: 620, W: 100, H: 100}},
+	}}, now)
+	if x, y, _, _ := f.Window(1280, 720, now); x != 640 || y != 368 {
+		t.Errorf("window not clamped to the corner: %d,%d", x, y)
+	}
+	// Results without a pet keep the target until Recenter passes
+	f.Observe(&DetectionResult{}, now)
+	if x, _, _, _ := f.Window(1280, 720, now.Add(time.Second)); x != 640 {
+		t.Errorf("lost the pet early: x=%d", x)
+	}
+	if x, y, _, _ := f.Window(1280, 720, now.Add(f.Recenter+time.Second)); x != 320 || y != 184 {
+		t.Errorf("did not recenter: %d,%d", x, y)
+	}
+
+	// Smoothing eases part of the way per frame
+	f.Smoothing = 0.5
+	f.Observe(&DetectionResult{Detections: []Detection{{ClassName: "dog", BBox: BoundingBox{X: 1230, Y: 310, W: 50, H: 100}}}}, now)
+	f.Window(1280, 720, now)
+	if cx, _ := f.Center(); cx < 0.73 || cx > 0.75 {
+		t.Errorf("smoothed center %.3f, want ~0.74", cx)
+	}
+
+	if _, _, cw, ch := NewPetFollow(100).Window(320, 240, now); cw != 320 || ch != 240 {
+		t.Errorf("tiny crop not widened to the frame: %dx%d", cw, ch)
+	}
+}
+
+func TestNV12Crop(t *testing.T) {
+	const w, h = 8, 4
+	src := make([]byte, w*h*3/2)
+	for i := range src {
+		src[i] = byte(i)
+	}
+	dst := make([]byte, 4*2*3/2)
+	nv12Crop(dst, src, w, h, 2, 2, 4, 2)
+	want := []byte{
+		18, 19, 20, 21, // Y row 2
+		26, 27, 28, 29, // Y row 3
+		42, 43, 44, 45, // UV row 1
+	}
+	if !bytes.Equal(dst, want) {
+		t.Errorf("crop %v, want %v", dst, want)
+	}
+}
+
+func TestBroadcastFollowProfile(t *testing.T) {
+	fb := NewFrameBroadcaster(nil, nil, make(chan struct{}, 16))
+	_, full := fb.SubscribeProfile(ProfileFull)
+	id, follow := fb.SubscribeProfile(ProfileFollow)
+	_, snap := fb.SubscribeAs(ConsumerSnapshot)
+
+	fb.broadcastFrames([]byte("full"), []byte("crop"))
+	if string(<-full) != "full" || string(<-snap) != "full" || string(<-follow) != "crop" {
+		t.Fatal("frames routed to the wrong profile")
+	}
+	// Without a crop (camera JPEG, or no follower configured) everyone gets the frame
+	fb.broadcast([]byte("jpeg"))
+	if string(<-follow) != "jpeg" {
+		t.Fatal("follower starved without a crop")
+	}
+	fb.Unsubscribe(id)
+	if len(fb.followers) != 0 {
+		t.Errorf("follower left after unsubscribe: %v", fb.followers)
+	}
+}
@@ -0,0 +1,245 @@
+package webmonitor
+
+import (
+	"crypto/rand"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is a synthesized, timestamped occurrence (e.g. "feeding_started")
+// derived from the detection stream.
+//
+// EventID correlates what one trigger caused: a rule firing passes the ID
+// of the event that fired it (or a new one) on to its notification and
+// recording, so the push message, the rule_fired and recording events, the
+// clip's stats and the audit log lines can be joined on it. Unlike ID it is
+// unique across restarts.
+type Event struct {
+	ID        uint64            `json:"id"`
+	EventID   string            `json:"event_id,omitempty"`
+	Type      string            `json:"type"`
+	Timestamp float64           `json:"timestamp"`
+	Class     string            `json:"class,omitempty"`
+	BBox      *BoundingBox      `json:"bbox,omitempty"`
+	Data      map[string]string `json:"data,omitempty"`
+}
+
+// EventStore keeps a rolling window of events and fans new ones out to listeners.
+type EventStore struct {
+	mu        sync.RWMutex
+	events    []Event
+	nextID    uint64
+	window    time.Duration
+	listeners []func(Event)
+}
+
+// NewEventStore creates a store with the given retention window.
+func NewEventStore(window time.Duration) *EventStore {
+	return &EventStore{
+		events: make([]Event, 0, 1024),
+		nextID: 1,
+		window: window,
+	}
+}
+
+// AddListener registers a callback invoked for every appended event.
+func (s *EventStore) AddListener(listener func(Event)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// newEventID returns a random correlation ID (see Event.EventID).
+func newEventID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Append assigns an ID (and timestamp and EventID if unset), stores the
+// event and notifies listeners.
+func (s *EventStore) Append(ev Event) Event {
+	if ev.Timestamp == 0 {
+		ev.Timestamp = float64(time.Now().UnixNano()) / 1e9
+	}
+	if ev.EventID == "" {
+		ev.EventID = newEventID()
+	}
+
+	s.mu.Lock()
+	ev.ID = s.nextID
+	s.nextID++
+	s.events = append(s.events, ev)
+
+	cutoff := float64(time.Now().Unix()) - s.window.Seconds()
+	trimIdx := 0
+	for trimIdx < len(s.events) && s.events[trimIdx].Timestamp < cutoff {
+		trimIdx++
+	}
+	if trimIdx > 0 {
+		s.events = s.events[trimIdx:]
+	}
+	listeners := s.listeners
+	s.mu.Unlock()
+
+	for _, l := range listeners {
+		l(ev)
+	}
+	return ev
+}
+
+// Query returns events newer than since (unix seconds), optionally filtered by
+// type, oldest first. limit <= 0 means no limit (the newest are kept).
+func (s *EventStore) Query(since float64, types []string, limit int) []Event {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]Event, 0)
+	for _, ev := range s.events {
+		if ev.Timestamp <= since {
+			continue
+		}
+		if len(types) > 0 && !containsString(types, ev.Type) {
+			continue
+		}
+		out = append(out, ev)
+	}
+	if limit > 0 && len(out) > limit {
+		out = out[len(out)-limit:]
+	}
+	return out
+}
+
+// Correlated returns the events carrying eventID, oldest first.
+func (s *EventStore) Correlated(eventID string) []Event {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]Event, 0)
+	for _, ev := range s.events {
+		if ev.EventID == eventID {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Save writes events to a gob file atomically (temp file + rename).
+func (s *EventStore) Save(path string) error {
+	s.mu.RLock()
+	events := make([]Event, len(s.events))
+	copy(events, s.events)
+	s.mu.RUnlock()
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(f).Encode(events); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load reads events from a gob file, keeping only those within the retention window.
+func (s *EventStore) Load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	var events []Event
+	if err := gob.NewDecoder(f).Decode(&events); err != nil {
+		return err
+	}
+
+	cutoff := float64(time.Now().Unix()) - s.window.Seconds()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ev := range events {
+		if ev.Timestamp >= cutoff {
+			s.events = append(s.events, ev)
+		}
+		if ev.ID >= s.nextID {
+			s.nextID = ev.ID + 1
+		}
+	}
+	return nil
+}
+
+// handleEvents serves GET /api/events?since=<unix>&type=a,b&limit=N, or
+// with ?event_id= every event of one trigger.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	if id := q.Get("event_id"); id != "" {
+		events := s.events.Correlated(id)
+		writeJSON(w, map[string]any{"events": labelEvents(events, s.requestLocale(r)), "total": len(events)})
+		return
+	}
+	since, _ := strconv.ParseFloat(q.Get("since"), 64)
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if limit <= 0 {
+		limit = 500
+	}
+	var types []string
+	if t := q.Get("type"); t != "" {
+		types = strings.Split(t, ",")
+	}
+
+	events := s.events.Query(since, types, limit)
+	writeJSON(w, map[string]any{"events": labelEvents(events, s.requestLocale(r)), "total": len(events)})
+}
+
+// labeledEvent is an Event with its type's label in the reader's locale.
+type labeledEvent struct {
+	Event
+	Label string `json:"label,omitempty"` // absent for types without one
+}
+
+func labelEvents(events []Event, locale string) []labeledEvent {
+	out := make([]labeledEvent, len(events))
+	for i, ev := range events {
+		out[i].Event = ev
+		if key := "event." + ev.Type; hasMessage(key) {
+			out[i].Label = msg(key).In(locale)
+		}
+	}
+	return out
+}
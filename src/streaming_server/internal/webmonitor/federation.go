@@ -0,0 +1,527 @@
+package webmonitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+// LocalCameraID identifies this instance in aggregated results.
+const LocalCameraID = "local"
+
+// Peer is another web monitor instance shown in the combined dashboard.
+type Peer struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	URL   string `json:"url"`             // base URL, e.g. http://bedroom-cam:8080
+	Token string `json:"token,omitempty"` // sent as Bearer token to the peer
+}
+
+// PeerStatus is a peer with its last health check. The token is never
+// returned over the API.
+type PeerStatus struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	URL       string  `json:"url"`
+	HasToken  bool    `json:"has_token"`
+	Online    bool    `json:"online"`
+	LastSeen  float64 `json:"last_seen,omitempty"` // unix seconds
+	LatencyMs int64   `json:"latency_ms,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// FederatedEvent is an event tagged with the camera it came from.
+type FederatedEvent struct {
+	Event
+	Camera string `json:"camera"`
+}
+
+type peerHealth struct {
+	online   bool
+	lastSeen time.Time
+	latency  time.Duration
+	err      string
+}
+
+var (
+	peerIDPattern  = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+	peerIDReplacer = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// Federation manages remote camera peers: persistence, health polling,
+// request proxying and event aggregation.
+type Federation struct {
+	mu      sync.Mutex
+	doc     kv.Doc
+	peers   []Peer
+	health  map[string]*peerHealth
+	proxies map[string]*httputil.ReverseProxy
+	client  *http.Client
+	stop    chan struct{}
+	stopped bool
+
+	// Configurable parameters
+	PollInterval time.Duration
+	Timeout      time.Duration // per-request timeout for health checks and event fetches
+}
+
+// NewFederation creates a peer registry persisted to doc (the zero Doc = in
+// memory).
+func NewFederation(doc kv.Doc) *Federation {
+	return &Federation{
+		doc:          doc,
+		health:       make(map[string]*peerHealth),
+		proxies:      make(map[string]*httputil.ReverseProxy),
+		client:       &http.Client{},
+		stop:         make(chan struct{}),
+		PollInterval: 30 * time.Second,
+		Timeout:      5 * time.Second,
+	}
+}
+
+// Validate normalizes and checks a peer definition.
+func (p *Peer) Validate() error {
+	p.URL = strings.TrimRight(strings.TrimSpace(p.URL), "/")
+	u, err := url.Parse(p.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an http(s) base URL")
+	}
+	if p.ID == "" {
+		p.ID = strings.Trim(peerIDReplacer.ReplaceAllString(strings.ToLower(p.Name), "-"), "-")
+	}
+	if !peerIDPattern.MatchString(p.ID) || p.ID == LocalCameraID {
+		return fmt.Errorf("invalid peer id %q (lowercase letters, digits, - and _)", p.ID)
+	}
+	if p.Name == "" {
+		p.Name = p.ID
+	}
+	return nil
+}
+
+// Load replaces the peers with the persisted ones; without a document
+// there are none.
+func (f *Federation) Load() error {
+	var peers []Peer
+	if _, err := f.doc.Load(&peers); err != nil {
+		return err
+	}
+	for i := range peers {
+		if err := peers[i].Validate(); err != nil {
+			return fmt.Errorf("peer %q: %w", peers[i].ID, err)
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.peers = peers
+	f.proxies = make(map[string]*httputil.ReverseProxy)
+	return nil
+}
+
+// saveLocked persists the peers. They hold tokens; the settings store is
+// private to the monitor's user.
+func (f *Federation) saveLocked() error {
+	return f.doc.Save(f.peers)
+}
+
+// Put adds or replaces a peer. An empty token on update keeps the old one.
+func (f *Federation) Put(p Peer) (Peer, error) {
+	if err := p.Validate(); err != nil {
+		return Peer{}, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := range f.peers {
+		if f.peers[i].ID == p.ID {
+			if p.Token == "" {
+				p.Token = f.peers[i].Token
+			}
+			f.peers[i] = p
+			delete(f.proxies, p.ID)
+			delete(f.health, p.ID)
+			return p, f.saveLocked()
+		}
+	}
+	f.peers = append(f.peers, p)
+	return p, f.saveLocked()
+}
+
+// Delete removes a peer. Returns false if it does not exist.
+func (f *Federation) Delete(id string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := range f.peers {
+		if f.peers[i].ID == id {
+			f.peers = append(f.peers[:i], f.peers[i+1:]...)
+			delete(f.proxies, id)
+			delete(f.health, id)
+			return true, f.saveLocked()
+		}
+	}
+	return false, nil
+}
+
+// Peers returns a copy of the configured peers.
+func (f *Federation) Peers() []Peer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Peer(nil), f.peers...)
+}
+
+// List returns peers with their health.
+func (f *Federation) List() []PeerStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := make([]PeerStatus, 0, len(f.peers))
+	for _, p := range f.peers {
+		st := PeerStatus{ID: p.ID, Name: p.Name, URL: p.URL, HasToken: p.Token != ""}
+		if h := f.health[p.ID]; h != nil {
+			st.Online = h.online
+			st.LatencyMs = h.latency.Milliseconds()
+			st.Error = h.err
+			if !h.lastSeen.IsZero() {
+				st.LastSeen = float64(h.lastSeen.UnixNano()) / 1e9
+			}
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+// Start begins periodic health checks.
+func (f *Federation) Start() {
+	go f.run()
+}
+
+// Stop halts health checks.
+func (f *Federation) Stop() {
+	f.mu.Lock()
+	if !f.stopped {
+		close(f.stop)
+		f.stopped = true
+	}
+	f.mu.Unlock()
+}
+
+func (f *Federation) run() {
+	f.CheckAll()
+	ticker := time.NewTicker(f.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+			f.CheckAll()
+		}
+	}
+}
+
+// CheckAll probes every peer's /readyz concurrently.
+func (f *Federation) CheckAll() {
+	var wg sync.WaitGroup
+	for _, p := range f.Peers() {
+		wg.Add(1)
+		go func(p Peer) {
+			defer wg.Done()
+			f.check(p)
+		}(p)
+	}
+	wg.Wait()
+}
+
+func (f *Federation) check(p Peer) {
+	start := time.Now()
+	resp, err := f.get(p, "/readyz")
+	h := &peerHealth{latency: time.Since(start)}
+	if err != nil {
+		h.err = err.Error()
+	} else {
+		resp.Body.Close()
+		// 503 means reachable but the camera is not streaming yet
+		h.online = resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusServiceUnavailable
+		if !h.online {
+			h.err = resp.Status
+		}
+	}
+
+	f.mu.Lock()
+	prev := f.health[p.ID]
+	if h.online {
+		h.lastSeen = time.Now()
+	} else if prev != nil {
+		h.lastSeen = prev.lastSeen
+	}
+	f.health[p.ID] = h
+	f.mu.Unlock()
+
+	if prev != nil && prev.online != h.online {
+		if h.online {
+			logger.Info("Federation", "Peer %s online", p.ID)
+		} else {
+			logger.Warn("Federation", "Peer %s offline: %s", p.ID, h.err)
+		}
+	}
+}
+
+func (f *Federation) get(p Peer, path string) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), f.Timeout)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL+path, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if p.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.Token)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelReadCloser{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// Events merges local events with every peer's /api/events, newest last.
+// Unreachable peers are skipped.
+func (f *Federation) Events(local []Event, since float64, types []string, limit int) []FederatedEvent {
+	out := make([]FederatedEvent, 0, len(local))
+	for _, ev := range local {
+		out = append(out, FederatedEvent{Event: ev, Camera: LocalCameraID})
+	}
+
+	q := url.Values{}
+	if since > 0 {
+		q.Set("since", strconv.FormatFloat(since, 'f', -1, 64))
+	}
+	if len(types) > 0 {
+		q.Set("type", strings.Join(types, ","))
+	}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, p := range f.Peers() {
+		wg.Add(1)
+		go func(p Peer) {
+			defer wg.Done()
+			resp, err := f.get(p, "/api/events?"+q.Encode())
+			if err != nil {
+				logger.Debug("Federation", "Events from %s: %v", p.ID, err)
+				return
+			}
+			defer resp.Body.Close()
+			var body struct {
+				Events []Event `json:"events"`
+			}
+			if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&body) != nil {
+				return
+			}
+			mu.Lock()
+			for _, ev := range body.Events {
+				out = append(out, FederatedEvent{Event: ev, Camera: p.ID})
+			}
+			mu.Unlock()
+		}(p)
+	}
+	wg.Wait()
+
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Timestamp < out[j].Timestamp })
+	if limit > 0 && len(out) > limit {
+		out = out[len(out)-limit:]
+	}
+	return out
+}
+
+// cancelReadCloser releases the request context when the body is closed.
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelReadCloser) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// federationProxyAllowed lists peer paths reachable through the proxy:
+// live view, detections, status, snapshots, signaling and recordings.
+// /api/peers is excluded so federations cannot loop.
+func federationProxyAllowed(path string) bool {
+	if path == "/stream" {
+		return true
+	}
+	return strings.HasPrefix(path, "/api/") && !strings.HasPrefix(path, "/api/peers")
+}
+
+// proxy returns the reverse proxy for a peer, creating it on first use.
+func (f *Federation) proxy(id string) (*httputil.ReverseProxy, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if rp, ok := f.proxies[id]; ok {
+		return rp, true
+	}
+	var peer *Peer
+	for i := range f.peers {
+		if f.peers[i].ID == id {
+			peer = &f.peers[i]
+			break
+		}
+	}
+	if peer == nil {
+		return nil, false
+	}
+	target, err := url.Parse(peer.URL)
+	if err != nil {
+		return nil, false
+	}
+	token := peer.Token
+	rp := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.Out.Header.Del("Cookie")
+			pr.Out.Header.Del("Authorization")
+			if token != "" {
+				pr.Out.Header.Set("Authorization", "Bearer "+token)
+			}
+		},
+		FlushInterval: -1, // SSE and MJPEG must not be buffered
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			if r.Context().Err() != nil {
+				return // viewer went away
+			}
+			writeJSONWithStatus(w, map[string]any{"error": "peer unreachable: " + err.Error()}, http.StatusBadGateway)
+		},
+	}
+	f.proxies[id] = rp
+	return rp, true
+}
+
+// handlePeers serves GET/POST /api/peers.
+func (s *Server) handlePeers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, map[string]any{"peers": s.federation.List()})
+	case http.MethodPost:
+		var p Peer
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": "invalid JSON: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		saved, err := s.federation.Put(p)
+		if err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+		go s.federation.check(saved)
+		writeJSONWithStatus(w, map[string]any{"id": saved.ID}, http.StatusCreated)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handlePeer serves PUT/DELETE /api/peers/{id} and proxies
+// /api/peers/{id}/proxy/{path} to the peer.
+func (s *Server) handlePeer(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/api/peers/")
+	id, sub, _ := strings.Cut(rest, "/")
+	if id == "" {
+		http.Error(w, "Invalid peer id", http.StatusBadRequest)
+		return
+	}
+
+	if sub != "" {
+		path, ok := strings.CutPrefix(sub, "proxy")
+		if !ok || !federationProxyAllowed(path) {
+			writeJSONWithStatus(w, map[string]any{"error": "path not proxied"}, http.StatusForbidden)
+			return
+		}
+		rp, ok := s.federation.proxy(id)
+		if !ok {
+			writeJSONWithStatus(w, map[string]any{"error": "not found"}, http.StatusNotFound)
+			return
+		}
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = path
+		r2.URL.RawPath = ""
+		rp.ServeHTTP(w, r2)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var p Peer
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": "invalid JSON: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		p.ID = id
+		saved, err := s.federation.Put(p)
+		if err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+		go s.federation.check(saved)
+		writeJSON(w, map[string]any{"id": saved.ID})
+	case http.MethodDelete:
+		found, err := s.federation.Delete(id)
+		if err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			writeJSONWithStatus(w, map[string]any{"error": "not found"}, http.StatusNotFound)
+			return
+		}
+		writeJSON(w, map[string]any{"deleted": true})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleFederatedEvents serves GET /api/federation/events: local and peer
+// events merged by timestamp, each tagged with its camera.
+func (s *Server) handleFederatedEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	since, _ := strconv.ParseFloat(q.Get("since"), 64)
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if limit <= 0 {
+		limit = 500
+	}
+	var types []string
+	if t := q.Get("type"); t != "" {
+		types = strings.Split(t, ",")
+	}
+
+	events := s.federation.Events(s.events.Query(since, types, limit), since, types, limit)
+	writeJSON(w, map[string]any{"events": events, "total": len(events)})
+}
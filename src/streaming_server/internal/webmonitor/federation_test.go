@@ -0,0 +1,137 @@
+package webmonitor
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+)
+
+func newFakePeer(t *testing.T, token string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable) // reachable, no frame SHM
+	})
+	mux.HandleFunc("/api/events", func(w http.ResponseWriter, r *http.Request) {
+		now := float64(time.Now().Unix())
+		writeJSON(w, map[string]any{"events": []Event{
+			{ID: 1, Type: EventDrinkingStarted, Timestamp: now - 15},
+		}})
+	})
+	mux.HandleFunc("/api/snapshot", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write([]byte("jpeg"))
+	})
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("Cookie") != "" {
+			http.Error(w, "cookie leaked", http.StatusBadRequest)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+}
+
+func TestFederation_PeersProxyAndEvents(t *testing.T) {
+	peer := newFakePeer(t, "s3cr3t")
+	defer peer.Close()
+
+	doc := peersDoc.in(kv.NewMemory())
+	s := &Server{federation: NewFederation(doc), events: NewEventStore(time.Hour)}
+	now := float64(time.Now().Unix())
+	s.events.Append(Event{Type: EventFeedingStarted, Timestamp: now - 30})
+	s.events.Append(Event{Type: EventFeedingEnded, Timestamp: now - 5})
+
+	do := func(method, target, body string, h http.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Cookie", "session=local")
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec
+	}
+
+	// Invalid peers are rejected
+	if rec := do(http.MethodPost, "/api/peers", `{"name":"x","url":"ftp://x"}`, s.handlePeers); rec.Code != http.StatusBadRequest {
+		t.Fatalf("bad url status = %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/peers", `{"id":"local","url":"http://x"}`, s.handlePeers); rec.Code != http.StatusBadRequest {
+		t.Fatalf("reserved id status = %d", rec.Code)
+	}
+
+	rec := do(http.MethodPost, "/api/peers", `{"name":"Bed Room","url":"`+peer.URL+`/","token":"s3cr3t"}`, s.handlePeers)
+	if rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), `"bed-room"`) {
+		t.Fatalf("create = %d %s", rec.Code, rec.Body)
+	}
+
+	s.federation.CheckAll()
+	rec = do(http.MethodGet, "/api/peers", "", s.handlePeers)
+	if strings.Contains(rec.Body.String(), "s3cr3t") {
+		t.Fatalf("token leaked in list: %s", rec.Body)
+	}
+	var list struct{ Peers []PeerStatus }
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	if len(list.Peers) != 1 || !list.Peers[0].Online || !list.Peers[0].HasToken {
+		t.Fatalf("peers = %+v", list.Peers)
+	}
+
+	// Proxy
+	rec = do(http.MethodGet, "/api/peers/bed-room/proxy/api/snapshot", "", s.handlePeer)
+	if rec.Code != http.StatusOK || rec.Body.String() != "jpeg" {
+		t.Fatalf("proxy snapshot = %d %q", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodGet, "/api/peers/bed-room/proxy/api/peers", "", s.handlePeer); rec.Code != http.StatusForbidden {
+		t.Fatalf("recursive proxy status = %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/api/peers/nope/proxy/api/snapshot", "", s.handlePeer); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown peer status = %d", rec.Code)
+	}
+
+	// Aggregated events, sorted by time and tagged by camera
+	rec = do(http.MethodGet, "/api/federation/events", "", s.handleFederatedEvents)
+	var agg struct{ Events []FederatedEvent }
+	json.Unmarshal(rec.Body.Bytes(), &agg)
+	var cams []string
+	for _, ev := range agg.Events {
+		cams = append(cams, ev.Camera+":"+ev.Type)
+	}
+	want := "local:feeding_started,bed-room:drinking_started,local:feeding_ended"
+	if strings.Join(cams, ",") != want {
+		t.Fatalf("events = %v, want %s", cams, want)
+	}
+
+	// Persistence keeps the token; update without token preserves it
+	loaded := NewFederation(doc)
+	if err := loaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if p := loaded.Peers(); len(p) != 1 || p[0].Token != "s3cr3t" || p[0].URL != peer.URL {
+		t.Fatalf("loaded = %+v", p)
+	}
+	if rec := do(http.MethodPut, "/api/peers/bed-room", `{"name":"Bedroom","url":"`+peer.URL+`"}`, s.handlePeer); rec.Code != http.StatusOK {
+		t.Fatalf("update = %d", rec.Code)
+	}
+	if p := s.federation.Peers(); p[0].Token != "s3cr3t" || p[0].Name != "Bedroom" {
+		t.Fatalf("after update = %+v", p)
+	}
+	rec = do(http.MethodGet, "/api/peers/bed-room/proxy/api/snapshot", "", s.handlePeer)
+	body, _ := io.ReadAll(rec.Body)
+	if string(body) != "jpeg" {
+		t.Fatalf("proxy after update = %q", body)
+	}
+
+	if rec := do(http.MethodDelete, "/api/peers/bed-room", "", s.handlePeer); rec.Code != http.StatusOK {
+		t.Fatalf("delete = %d", rec.Code)
+	}
+	if len(s.federation.List()) != 0 {
+		t.Fatal("peer not deleted")
+	}
+}
@@ -0,0 +1,436 @@
+package webmonitor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+const (
+	filmstripTileWidth       = 160 // same scale as the recording thumbnail
+	filmstripColumns         = 10
+	filmstripMaxTiles        = 200 // longer clips get a wider interval
+	filmstripDefaultInterval = 10
+	filmstripMaxInterval     = 600
+)
+
+// FilmstripLayout describes a sprite sheet of a recording: tile i shows
+// the keyframe at or before i*Interval seconds and sits at column i%Columns,
+// row i/Columns.
+type FilmstripLayout struct {
+	Interval   float64   `json:"interval"` // seconds per tile (raised for long clips)
+	Duration   float64   `json:"duration"`
+	TileWidth  int       `json:"tile_width"`
+	TileHeight int       `json:"tile_height"`
+	Columns    int       `json:"columns"`
+	Count      int       `json:"count"`
+	Keyframes  []float64 `json:"keyframes"` // time of the frame shown in each tile
+	Image      string    `json:"image"`     // sprite sheet URL, relative to the layout
+}
+
+// Filmstrips builds sprite sheets for the playback scrubber lazily and
+// caches them in a directory, one JPEG plus layout per recording and
+// interval. Builds run one at a time: each tile is an ffmpeg seek.
+type Filmstrips struct {
+	dir string
+	mu  sync.Mutex // serializes builds
+
+	// extract returns one frame at t seconds as a JPEG of the given width
+	// (ffmpegFrame; replaced in tests).
+	extract func(mp4Path string, t float64, width int) ([]byte, error)
+}
+
+// NewFilmstrips returns a Filmstrips caching in dir.
+func NewFilmstrips(dir string) *Filmstrips {
+	return &Filmstrips{dir: dir, extract: ffmpegFrame}
+}
+
+func (f *Filmstrips) cachePaths(recording string, interval int) (jpg, layout string) {
+	stem := strings.TrimSuffix(recording, filepath.Ext(recording))
+	base := filepath.Join(f.dir, fmt.Sprintf("%s_%ds", stem, interval))
+	return base + ".jpg", base + ".json"
+}
+
+// Get returns the layout and sprite sheet path of the MP4 at mp4Path for
+// tiles every interval seconds, building them unless a cached copy newer
+// than the recording exists.
+func (f *Filmstrips) Get(mp4Path string, interval int) (*FilmstripLayout, string, error) {
+	jpgPath, layoutPath := f.cachePaths(filepath.Base(mp4Path), interval)
+	if l, err := f.cached(mp4Path, jpgPath, layoutPath); err == nil {
+		return l, jpgPath, nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// Another request may have built it while this one waited
+	if l, err := f.cached(mp4Path, jpgPath, layoutPath); err == nil {
+		return l, jpgPath, nil
+	}
+	l, err := f.build(mp4Path, interval, jpgPath, layoutPath)
+	if err != nil {
+		return nil, "", err
+	}
+	return l, jpgPath, nil
+}
+
+func (f *Filmstrips) cached(mp4Path, jpgPath, layoutPath string) (*FilmstripLayout, error) {
+	src, err := os.Stat(mp4Path)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range []string{jpgPath, layoutPath} {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if info.ModTime().Before(src.ModTime()) {
+			return nil, errors.New("stale")
+		}
+	}
+	data, err := os.ReadFile(layoutPath)
+	if err != nil {
+		return nil, err
+	}
+	var l FilmstripLayout
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (f *Filmstrips) build(mp4Path string, interval int, jpgPath, layoutPath string) (*FilmstripLayout, error) {
+	keyframes, duration, err := mp4Keyframes(mp4Path)
+	if err != nil {
+		return nil, err
+	}
+	l := &FilmstripLayout{Interval: float64(interval), Duration: duration, Columns: filmstripColumns}
+	if n := math.Ceil(duration / l.Interval); n > filmstripMaxTiles {
+		l.Interval = math.Ceil(duration / filmstripMaxTiles)
+	}
+	l.Count = max(1, int(math.Ceil(duration/l.Interval)))
+	l.Keyframes = make([]float64, l.Count)
+	for i := range l.Keyframes {
+		l.Keyframes[i] = keyframeAt(keyframes, float64(i)*l.Interval)
+	}
+
+	// One ffmpeg seek per distinct keyframe; a GOP longer than the
+	// interval repeats its tile
+	tiles := make(map[float64]image.Image)
+	for _, t := range l.Keyframes {
+		if _, ok := tiles[t]; ok {
+			continue
+		}
+		data, err := f.extract(mp4Path, t, filmstripTileWidth)
+		if err == nil {
+			tiles[t], err = jpeg.Decode(bytes.NewReader(data))
+		}
+		if err != nil {
+			logger.Debug("Filmstrip", "%s at %.2fs: %v", filepath.Base(mp4Path), t, err)
+			tiles[t] = nil
+		}
+	}
+	for _, img := range tiles {
+		if img != nil {
+			l.TileWidth, l.TileHeight = img.Bounds().Dx(), img.Bounds().Dy()
+			break
+		}
+	}
+	if l.TileWidth == 0 {
+		return nil, fmt.Errorf("no frame could be extracted from %s", filepath.Base(mp4Path))
+	}
+
+	rows := (l.Count + l.Columns - 1) / l.Columns
+	sheet := image.NewRGBA(image.Rect(0, 0, min(l.Count, l.Columns)*l.TileWidth, rows*l.TileHeight))
+	for i, t := range l.Keyframes {
+		img := tiles[t]
+		if img == nil {
+			continue // left black
+		}
+		at := image.Pt((i%l.Columns)*l.TileWidth, (i/l.Columns)*l.TileHeight)
+		draw.Draw(sheet, image.Rectangle{Min: at, Max: at.Add(image.Pt(l.TileWidth, l.TileHeight))}, img, img.Bounds().Min, draw.Src)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, sheet, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	l.Image = fmt.Sprintf("filmstrip.jpg?interval=%d", interval)
+	layout, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(f.dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(jpgPath, buf.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(layoutPath, layout); err != nil {
+		return nil, err
+	}
+	logger.Info("Filmstrip", "Built %s: %d tiles every %.0fs from %d keyframes",
+		filepath.Base(jpgPath), l.Count, l.Interval, len(tiles))
+	return l, nil
+}
+
+// Remove deletes the cached sheets of a recording.
+func (f *Filmstrips) Remove(recording string) {
+	stem := strings.TrimSuffix(recording, filepath.Ext(recording))
+	matches, _ := filepath.Glob(filepath.Join(f.dir, stem+"_*s.*"))
+	for _, m := range matches {
+		os.Remove(m)
+	}
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// keyframeAt returns the last keyframe at or before t (the first if none).
+func keyframeAt(keyframes []float64, t float64) float64 {
+	best := keyframes[0]
+	for _, k := range keyframes {
+		if k > t {
+			break
+		}
+		best = k
+	}
+	return best
+}
+
+// ffmpegFrame decodes the frame at t (a keyframe, so the seek decodes
+// nothing else) scaled to width.
+func ffmpegFrame(mp4Path string, t float64, width int) ([]byte, error) {
+	cmd := exec.Command("nice", "-n", "19",
+		"ffmpeg", "-v", "error",
+		"-ss", strconv.FormatFloat(t, 'f', 3, 64),
+		"-i", mp4Path,
+		"-frames:v", "1",
+		"-vf", fmt.Sprintf("scale=%d:-2", width),
+		"-q:v", "5",
+		"-f", "image2pipe", "-c:v", "mjpeg", "-",
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// mp4Keyframes reads the index (moov) of an MP4 and returns the decode
+// times in seconds of the video track's sync samples, ascending, and the
+// track's duration.
+func mp4Keyframes(path string) ([]float64, float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	size := info.Size()
+
+	var moov []byte
+	hdr := make([]byte, 16)
+	for pos := int64(0); pos < size && moov == nil; {
+		box, err := readBoxHeader(f, hdr, pos, size)
+		if err != nil {
+			return nil, 0, err
+		}
+		if box.typ == "moov" {
+			if box.end-box.start-box.header > maxMoovSize {
+				return nil, 0, fmt.Errorf("moov box too large (%d bytes)", box.end-box.start)
+			}
+			moov = make([]byte, box.end-box.start-box.header)
+			if _, err := io.ReadFull(f, moov); err != nil {
+				return nil, 0, fmt.Errorf("moov truncated: %w", err)
+			}
+		} else if _, err := f.Seek(box.end, io.SeekStart); err != nil {
+			return nil, 0, err
+		}
+		pos = box.end
+	}
+	if moov == nil {
+		return nil, 0, errors.New("no moov box (index missing)")
+	}
+
+	var result error = errors.New("no video track")
+	var keyframes []float64
+	var duration float64
+	eachBox(moov, func(typ string, trak []byte) bool {
+		if typ != "trak" {
+			return true
+		}
+		mdia := childBox(trak, "mdia")
+		if hdlr := childBox(mdia, "hdlr"); len(hdlr) < 12 || string(hdlr[8:12]) != "vide" {
+			return true
+		}
+		keyframes, duration, result = videoKeyframes(mdia)
+		return false
+	})
+	return keyframes, duration, result
+}
+
+// videoKeyframes computes keyframe times from mdhd, stts and stss.
+func videoKeyframes(mdia []byte) ([]float64, float64, error) {
+	mdhd := childBox(mdia, "mdhd")
+	var timescale uint32
+	switch {
+	case len(mdhd) >= 24 && mdhd[0] == 0:
+		timescale = binary.BigEndian.Uint32(mdhd[12:])
+	case len(mdhd) >= 32 && mdhd[0] == 1:
+		timescale = binary.BigEndian.Uint32(mdhd[20:])
+	}
+	if timescale == 0 {
+		return nil, 0, errors.New("mdhd: no timescale")
+	}
+	stbl := childBox(childBox(mdia, "minf"), "stbl")
+
+	stts := childBox(stbl, "stts")
+	if len(stts) < 8 {
+		return nil, 0, errors.New("stts missing")
+	}
+	n := int(binary.BigEndian.Uint32(stts[4:]))
+	if len(stts)-8 < n*8 {
+		return nil, 0, fmt.Errorf("stts lists %d entries but holds %d", n, (len(stts)-8)/8)
+	}
+	// Decode time of each sample, run-length expanded lazily below
+	type run struct{ count, delta uint64 }
+	runs := make([]run, n)
+	var samples, total uint64
+	for i := range runs {
+		runs[i] = run{uint64(binary.BigEndian.Uint32(stts[8+i*8:])), uint64(binary.BigEndian.Uint32(stts[12+i*8:]))}
+		samples += runs[i].count
+		total += runs[i].count * runs[i].delta
+	}
+	timeOf := func(sample uint64) float64 { // 1-based
+		var t uint64
+		s := sample - 1
+		for _, r := range runs {
+			if s < r.count {
+				return float64(t+s*r.delta) / float64(timescale)
+			}
+			t += r.count * r.delta
+			s -= r.count
+		}
+		return float64(t) / float64(timescale)
+	}
+
+	var keyframes []float64
+	if stss := childBox(stbl, "stss"); stss != nil {
+		if len(stss) < 8 {
+			return nil, 0, errors.New("stss truncated")
+		}
+		m := int(binary.BigEndian.Uint32(stss[4:]))
+		if len(stss)-8 < m*4 {
+			return nil, 0, fmt.Errorf("stss lists %d entries but holds %d", m, (len(stss)-8)/4)
+		}
+		for i := range m {
+			if s := uint64(binary.BigEndian.Uint32(stss[8+i*4:])); s >= 1 && s <= samples {
+				keyframes = append(keyframes, timeOf(s))
+			}
+		}
+	} else if samples > 0 {
+		keyframes = []float64{0} // every sample is a sync sample; the first will do
+	}
+	if len(keyframes) == 0 {
+		return nil, 0, errors.New("no keyframes in index")
+	}
+	return keyframes, float64(total) / float64(timescale), nil
+}
+
+// eachBox calls fn for every box directly inside buf until fn returns
+// false. Malformed trailing data is ignored.
+func eachBox(buf []byte, fn func(typ string, body []byte) bool) {
+	r := bytes.NewReader(buf)
+	hdr := make([]byte, 16)
+	for pos := int64(0); pos < int64(len(buf)); {
+		box, err := readBoxHeader(r, hdr, pos, int64(len(buf)))
+		if err != nil || !fn(box.typ, buf[box.start+box.header:box.end]) {
+			return
+		}
+		r.Seek(box.end, io.SeekStart)
+		pos = box.end
+	}
+}
+
+// childBox returns the body of the first box of type typ inside buf.
+func childBox(buf []byte, typ string) []byte {
+	var found []byte
+	eachBox(buf, func(t string, body []byte) bool {
+		if t == typ {
+			found = body
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// handleFilmstrip serves GET /api/recordings/{filename}/filmstrip.json and
+// filmstrip.jpg?interval=N: the layout and sprite sheet of a finished MP4
+// for the playback scrubber.
+func (s *Server) handleFilmstrip(w http.ResponseWriter, r *http.Request, filename, part string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.filmstrips == nil || s.recorder.isRemote() {
+		writeJSONWithStatus(w, map[string]any{"error": "filmstrips need local recording storage"}, http.StatusNotImplemented)
+		return
+	}
+	if !strings.HasSuffix(filename, ".mp4") {
+		writeJSONWithStatus(w, map[string]any{"error": "filmstrips need a finished MP4 recording"}, http.StatusBadRequest)
+		return
+	}
+	interval := filmstripDefaultInterval
+	if v := r.URL.Query().Get("interval"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > filmstripMaxInterval {
+			writeJSONWithStatus(w, map[string]any{"error": fmt.Sprintf("interval must be 1-%d seconds", filmstripMaxInterval)}, http.StatusBadRequest)
+			return
+		}
+		interval = n
+	}
+	mp4Path, err := s.recorder.GetRecordingPath(filename)
+	if err != nil {
+		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusNotFound)
+		return
+	}
+
+	layout, jpgPath, err := s.filmstrips.Get(mp4Path, interval)
+	if err != nil {
+		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusUnprocessableEntity)
+		return
+	}
+	if part == "filmstrip.json" {
+		writeJSON(w, layout)
+		return
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	http.ServeFile(w, r, jpgPath)
+}
@@ -0,0 +1,187 @@
+package webmonitor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// filmstripMP4 builds an MP4 index with a sound track followed by a video
+// track of samples of delta ticks at 90 kHz, with the given sync samples
+// (nil = no stss box).
+func filmstripMP4(samples, delta uint32, sync []uint32) []byte {
+	hdlr := func(kind string) []byte {
+		return buildBox("hdlr", make([]byte, 8), []byte(kind), make([]byte, 12))
+	}
+	mdhd := make([]byte, 24)
+	binary.BigEndian.PutUint32(mdhd[12:], 90000)
+	stts := binary.BigEndian.AppendUint32(make([]byte, 4), 1)
+	stts = binary.BigEndian.AppendUint32(stts, samples)
+	stts = binary.BigEndian.AppendUint32(stts, delta)
+	stbl := [][]byte{buildBox("stts", stts)}
+	if sync != nil {
+		stss := binary.BigEndian.AppendUint32(make([]byte, 4), uint32(len(sync)))
+		for _, s := range sync {
+			stss = binary.BigEndian.AppendUint32(stss, s)
+		}
+		stbl = append(stbl, buildBox("stss", stss))
+	}
+	moov := buildBox("moov",
+		buildBox("trak", buildBox("mdia", hdlr("soun"))),
+		buildBox("trak", buildBox("mdia", buildBox("mdhd", mdhd), hdlr("vide"),
+			buildBox("minf", buildBox("stbl", stbl...)))))
+	return bytes.Join([][]byte{buildBox("ftyp", []byte("isom")), buildBox("mdat", []byte("frames")), moov}, nil)
+}
+
+// fakeFrames extracts a 160x90 tile whose gray level is the time in seconds.
+func fakeFrames(calls *int) func(string, float64, int) ([]byte, error) {
+	return func(_ string, t float64, width int) ([]byte, error) {
+		*calls++
+		img := image.NewGray(image.Rect(0, 0, width, 90))
+		for i := range img.Pix {
+			img.Pix[i] = uint8(t * 8)
+		}
+		var buf bytes.Buffer
+		err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100})
+		return buf.Bytes(), err
+	}
+}
+
+func TestMP4Keyframes(t *testing.T) {
+	var sync []uint32
+	for s := uint32(1); s <= 900; s += 60 { // 2s GOP at 30fps
+		sync = append(sync, s)
+	}
+	path := filepath.Join(t.TempDir(), "recording_1.mp4")
+	os.WriteFile(path, filmstripMP4(900, 3000, sync), 0644)
+	kf, duration, err := mp4Keyframes(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kf) != 15 || kf[0] != 0 || kf[1] != 2 || kf[14] != 28 || duration != 30 {
+		t.Fatalf("keyframes %v, duration %v", kf, duration)
+	}
+
+	os.WriteFile(path, bytes.Join([][]byte{buildBox("ftyp"), buildBox("mdat")}, nil), 0644)
+	if _, _, err := mp4Keyframes(path); err == nil {
+		t.Error("MP4 without index accepted")
+	}
+}
+
+func TestFilmstrips_BuildAndCache(t *testing.T) {
+	dir := t.TempDir()
+	var sync []uint32
+	for s := uint32(1); s <= 900; s += 60 {
+		sync = append(sync, s)
+	}
+	mp4 := filepath.Join(dir, "recording_1.mp4")
+	os.WriteFile(mp4, filmstripMP4(900, 3000, sync), 0644)
+
+	f := NewFilmstrips(filepath.Join(dir, ".filmstrips"))
+	calls := 0
+	f.extract = fakeFrames(&calls)
+	l, jpgPath, err := f.Get(mp4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Count != 30 || l.Interval != 1 || l.TileWidth != 160 || l.TileHeight != 90 || l.Keyframes[3] != 2 {
+		t.Fatalf("layout %+v", l)
+	}
+	if calls != 15 {
+		t.Errorf("%d extractions for 15 keyframes", calls)
+	}
+
+	data, _ := os.ReadFile(jpgPath)
+	sheet, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := sheet.Bounds(); b.Dx() != 1600 || b.Dy() != 270 {
+		t.Fatalf("sheet %v", b)
+	}
+	// Tile 13 (row 1, column 3) shows the keyframe at 12s
+	gray := color.GrayModel.Convert(sheet.At(3*160+80, 90+45)).(color.Gray)
+	if d := int(gray.Y) - 12*8; d < -3 || d > 3 {
+		t.Errorf("tile 13 gray %d, want ~%d", gray.Y, 12*8)
+	}
+
+	if _, _, err := f.Get(mp4, 1); err != nil || calls != 15 {
+		t.Errorf("cached sheet rebuilt: %d extractions, %v", calls, err)
+	}
+	future := time.Now().Add(time.Hour)
+	os.Chtimes(mp4, future, future)
+	if _, _, err := f.Get(mp4, 1); err != nil || calls != 30 {
+		t.Errorf("stale sheet not rebuilt: %d extractions, %v", calls, err)
+	}
+
+	// 3000s without stss: interval widened to stay at the tile limit
+	long := filepath.Join(dir, "recording_2.mp4")
+	os.WriteFile(long, filmstripMP4(1000, 3*90000, nil), 0644)
+	l, _, err = f.Get(long, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Count != filmstripMaxTiles || l.Interval != 15 {
+		t.Errorf("long clip layout: %d tiles every %vs", l.Count, l.Interval)
+	}
+
+	f.Remove("recording_1.mp4")
+	if m, _ := filepath.Glob(filepath.Join(dir, ".filmstrips", "recording_1_*")); len(m) != 0 {
+		t.Errorf("cache left after Remove: %v", m)
+	}
+}
+
+func TestHandleFilmstrip(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "recording_1.mp4"), filmstripMP4(300, 3000, []uint32{1, 151}), 0644)
+	os.WriteFile(filepath.Join(dir, "recording_1.hevc"), []byte("raw"), 0644)
+	s := &Server{recorder: NewRecorder(dir, "/nonexistent"), filmstrips: NewFilmstrips(filepath.Join(dir, ".filmstrips"))}
+	calls := 0
+	s.filmstrips.extract = fakeFrames(&calls)
+	srv := httptest.NewServer(http.HandlerFunc(s.handleRecordingDownload))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/recordings/recording_1.mp4/filmstrip.json?interval=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var l FilmstripLayout
+	json.NewDecoder(resp.Body).Decode(&l)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || l.Count != 2 || l.Image != "filmstrip.jpg?interval=5" {
+		t.Fatalf("status %d, layout %+v", resp.StatusCode, l)
+	}
+
+	resp, err = http.Get(srv.URL + "/api/recordings/recording_1.mp4/" + l.Image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "image/jpeg" || calls != 2 {
+		t.Errorf("sheet: status %d, type %q, %d extractions", resp.StatusCode, resp.Header.Get("Content-Type"), calls)
+	}
+
+	for path, want := range map[string]int{
+		"recording_1.hevc/filmstrip.json":           http.StatusBadRequest,
+		"recording_1.mp4/filmstrip.json?interval=0": http.StatusBadRequest,
+		"recording_9.mp4/filmstrip.jpg":             http.StatusNotFound,
+	} {
+		resp, err := http.Get(srv.URL + "/api/recordings/" + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("GET %s = %d, want %d", path, resp.StatusCode, want)
+		}
+	}
+}
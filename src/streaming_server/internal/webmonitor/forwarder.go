@@ -0,0 +1,286 @@
+package webmonitor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	pb "github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/proto"
+)
+
+// DetectionSourceRemote marks results returned by the secondary inference
+// service (-forward-url).
+const DetectionSourceRemote = "remote"
+
+// grpcAnnotatePath is the unary method called for grpc:// forward URLs.
+const grpcAnnotatePath = "/petcamera.InferenceService/Annotate"
+
+// nv12Source supplies raw frames to the forwarder.
+type nv12Source interface {
+	LatestNV12() (*NV12Frame, bool)
+}
+
+// ForwarderStats counts forwarded frames for /metrics.
+type ForwarderStats struct {
+	Sent        uint64        // frames delivered to the service
+	Failed      uint64        // encode, transport or decode failures
+	Annotations uint64        // detections received back
+	LastLatency time.Duration // round trip of the last successful request
+}
+
+// FrameForwarder pushes downscaled JPEGs to an external inference service
+// (e.g. pose estimation on another machine) at a capped rate and publishes
+// the returned detections with Source "remote". The service answers with
+// boxes in the sent image's pixels; they are rescaled to the 1280x720
+// detection space.
+//
+// http(s):// URLs receive a POST with an image/jpeg body and answer with a
+// DetectionResult JSON object. grpc://host:port URLs are called as
+// petcamera.InferenceService/Annotate over plaintext HTTP/2.
+type FrameForwarder struct {
+	Interval time.Duration // minimum gap between frames
+	MaxWidth int           // frames are downscaled by an integer factor to at most this width
+	Quality  int           // JPEG quality
+
+	url     string
+	src     nv12Source
+	publish func(*DetectionResult)
+	client  *http.Client
+
+	mu    sync.Mutex
+	stats ForwarderStats
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// NewFrameForwarder creates a forwarder sending frames from src to url.
+func NewFrameForwarder(url string, src nv12Source, publish func(*DetectionResult)) *FrameForwarder {
+	client := &http.Client{Timeout: 5 * time.Second}
+	if strings.HasPrefix(url, "grpc://") {
+		client = h2cClient(5 * time.Second)
+	}
+	return &FrameForwarder{
+		Interval: time.Second,
+		MaxWidth: 640,
+		Quality:  80,
+		url:      url,
+		src:      src,
+		publish:  publish,
+		client:   client,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start begins forwarding.
+func (f *FrameForwarder) Start() {
+	go f.run()
+}
+
+// Stop halts forwarding and waits for the in-flight request.
+func (f *FrameForwarder) Stop() {
+	close(f.stop)
+	<-f.done
+}
+
+// Stats returns the forwarding counters.
+func (f *FrameForwarder) Stats() ForwarderStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stats
+}
+
+func (f *FrameForwarder) run() {
+	defer close(f.done)
+	// Requests run inline, so a slow service lowers the rate instead of
+	// queueing frames (the ticker drops missed ticks).
+	ticker := time.NewTicker(f.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+		}
+		det, err := f.forwardOnce()
+		if err != nil {
+			f.mu.Lock()
+			f.stats.Failed++
+			failed := f.stats.Failed
+			f.mu.Unlock()
+			if failed == 1 || failed%60 == 0 {
+				logger.Warn("Forwarder", "Forward to %s failed (%d total): %v", f.url, failed, err)
+			}
+			continue
+		}
+		if det != nil && f.publish != nil {
+			f.publish(det)
+		}
+	}
+}
+
+// forwardOnce sends the latest frame and returns the service's detections
+// (nil when there was no frame or nothing was detected).
+func (f *FrameForwarder) forwardOnce() (*DetectionResult, error) {
+	frame, ok := f.src.LatestNV12()
+	if !ok {
+		return nil, nil
+	}
+	img := downscaleNV12(frame.Data, frame.Width, frame.Height, f.MaxWidth)
+	if img == nil {
+		return nil, fmt.Errorf("bad NV12 frame %dx%d (%d bytes)", frame.Width, frame.Height, len(frame.Data))
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: f.Quality}); err != nil {
+		return nil, err
+	}
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+
+	start := time.Now()
+	var det *DetectionResult
+	var err error
+	if strings.HasPrefix(f.url, "grpc://") {
+		det, err = f.annotateGRPC(buf.Bytes(), w, h)
+	} else {
+		det, err = f.annotateHTTP(buf.Bytes(), w, h)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	f.stats.Sent++
+	f.stats.Annotations += uint64(len(det.Detections))
+	f.stats.LastLatency = time.Since(start)
+	f.mu.Unlock()
+
+	if len(det.Detections) == 0 {
+		return nil, nil
+	}
+	// Boxes are in the sent frame's pixels unless the annotator says otherwise
+	if det.Frame == nil || det.Frame.Detector == nil {
+		det.Frame = &DetectionFrame{Detector: &DetectorInput{Width: w, Height: h}}
+	}
+	mapDetections(det, DetectorInput{})
+	det.NumDetections = len(det.Detections)
+	det.Source = DetectionSourceRemote
+	if det.Timestamp == 0 {
+		det.Timestamp = float64(start.UnixNano()) / 1e9
+	}
+	return det, nil
+}
+
+func (f *FrameForwarder) annotateHTTP(jpg []byte, w, h int) (*DetectionResult, error) {
+	req, err := http.NewRequest(http.MethodPost, f.url, bytes.NewReader(jpg))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "image/jpeg")
+	req.Header.Set("X-Frame-Width", fmt.Sprint(w))
+	req.Header.Set("X-Frame-Height", fmt.Sprint(h))
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	var det DetectionResult
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&det); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	return &det, nil
+}
+
+func (f *FrameForwarder) annotateGRPC(jpg []byte, w, h int) (*DetectionResult, error) {
+	msg, err := proto.Marshal(&pb.InferenceFrame{
+		Jpeg:      jpg,
+		Width:     uint32(w),
+		Height:    uint32(h),
+		Timestamp: float64(time.Now().UnixNano()) / 1e9,
+	})
+	if err != nil {
+		return nil, err
+	}
+	addr := strings.TrimPrefix(f.url, "grpc://")
+	req, err := http.NewRequest(http.MethodPost, "http://"+addr+grpcAnnotatePath,
+		bytes.NewReader(grpcFrame(msg)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/grpc+proto")
+	req.Header.Set("TE", "trailers")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	if err := grpcStatus(resp.Header); err != nil {
+		return nil, err
+	}
+	body, err := readGRPCFrame(bufio.NewReader(resp.Body))
+	if err != nil {
+		if err == io.EOF {
+			io.Copy(io.Discard, resp.Body)
+			if err := grpcStatus(resp.Trailer); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("empty gRPC response")
+		}
+		return nil, err
+	}
+	var ev pb.DetectionEvent
+	if err := proto.Unmarshal(body, &ev); err != nil {
+		return nil, fmt.Errorf("decode DetectionEvent: %w", err)
+	}
+	return detectionResultFromProto(&ev), nil
+}
+
+// downscaleNV12 converts an NV12 frame to a 4:2:0 image, skipping pixels
+// by the smallest integer factor that brings the width to maxWidth or less.
+func downscaleNV12(nv12 []byte, width, height, maxWidth int) *image.YCbCr {
+	if width <= 0 || height <= 0 || len(nv12) < width*height*3/2 {
+		return nil
+	}
+	factor := 1
+	if maxWidth > 0 {
+		factor = max(1, (width+maxWidth-1)/maxWidth)
+	}
+	w, h := width/factor&^1, height/factor&^1
+	if w == 0 || h == 0 {
+		return nil
+	}
+	img := image.NewYCbCr(image.Rect(0, 0, w, h), image.YCbCrSubsampleRatio420)
+	for y := range h {
+		src := nv12[y*factor*width:]
+		dst := img.Y[y*img.YStride:]
+		for x := range w {
+			dst[x] = src[x*factor]
+		}
+	}
+	uv := nv12[width*height:]
+	for cy := range h / 2 {
+		src := uv[cy*factor*width:]
+		for cx := range w / 2 {
+			i := cy*img.CStride + cx
+			img.Cb[i] = src[cx*factor*2]
+			img.Cr[i] = src[cx*factor*2+1]
+		}
+	}
+	return img
+}
@@ -0,0 +1,56 @@
+package webmonitor
+
+import (
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeNV12 struct{ frame *NV12Frame }
+
+func (f fakeNV12) LatestNV12() (*NV12Frame, bool) { return f.frame, f.frame != nil }
+
+func TestFrameForwarder_HTTPRoundTrip(t *testing.T) {
+	var gotW, gotH int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		img, err := jpeg.Decode(r.Body)
+		if err != nil {
+			t.Errorf("decode forwarded JPEG: %v", err)
+			return
+		}
+		gotW, gotH = img.Bounds().Dx(), img.Bounds().Dy()
+		w.Write([]byte(`{"detections":[{"class_name":"cat_sitting","confidence":0.7,"bbox":{"x":160,"y":90,"w":320,"h":180}}]}`))
+	}))
+	defer srv.Close()
+
+	frame := &NV12Frame{Data: make([]byte, 1920*1080*3/2), Width: 1920, Height: 1080}
+	f := NewFrameForwarder(srv.URL, fakeNV12{frame}, nil)
+	det, err := f.forwardOnce()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotW != 640 || gotH != 360 {
+		t.Fatalf("forwarded %dx%d, want 640x360", gotW, gotH)
+	}
+	if det.Source != DetectionSourceRemote || det.NumDetections != 1 {
+		t.Fatalf("got %+v", det)
+	}
+	// 640x360 pixels rescaled to the 1280x720 detection space.
+	if b := det.Detections[0].BBox; b != (BoundingBox{X: 320, Y: 180, W: 640, H: 360}) {
+		t.Fatalf("bbox = %+v", b)
+	}
+	if st := f.Stats(); st.Sent != 1 || st.Annotations != 1 {
+		t.Fatalf("stats = %+v", st)
+	}
+}
+
+func TestDownscaleNV12(t *testing.T) {
+	if img := downscaleNV12(make([]byte, 10), 64, 64, 32); img != nil {
+		t.Fatal("short buffer accepted")
+	}
+	img := downscaleNV12(make([]byte, 640*480*3/2), 640, 480, 1000)
+	if img.Rect.Dx() != 640 || img.Rect.Dy() != 480 {
+		t.Fatalf("no-op downscale changed size to %v", img.Rect)
+	}
+}
@@ -0,0 +1,340 @@
+package webmonitor
+
+import (
+	"net/http"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/crash"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// detectionHealthStatus merges daemon liveness with the motion fallback state.
+func (s *Server) detectionHealthStatus() DetectionHealthStatus {
+	if s.detectionHealth == nil {
+		return DetectionHealthStatus{}
+	}
+	st := s.detectionHealth.Status()
+	st.MotionFallback = s.motionDetector != nil && s.motionDetector.Active()
+	return st
+}
+
+// ReadyCheck is an extra readiness condition reported by /readyz under its
+// name (e.g. supervised child processes).
+type ReadyCheck struct {
+	Name  string
+	Check func() (ready bool, detail any)
+}
+
+// AddReadyCheck registers an extra readiness condition. Call before serving.
+func (s *Server) AddReadyCheck(name string, check func() (bool, any)) {
+	s.readyChecks = append(s.readyChecks, ReadyCheck{Name: name, Check: check})
+}
+
+// Ready reports whether the frame SHM is attached and every extra ready
+// check passes, i.e. whether /readyz would answer 200.
+func (s *Server) Ready() bool {
+	ready, _ := s.readiness()
+	return ready
+}
+
+func (s *Server) readiness() (bool, map[string]any) {
+	frameShm := s.monitor != nil && s.monitor.shm != nil
+	detection := s.detectionHealthStatus()
+
+	ready := frameShm
+	body := map[string]any{
+		"frame_shm":                frameShm,
+		"detection_daemon_healthy": detection.Healthy,
+		"detection_daemon":         detection,
+	}
+	for _, rc := range s.readyChecks {
+		ok, detail := rc.Check()
+		ready = ready && ok
+		body[rc.Name] = detail
+	}
+	return ready, body
+}
+
+// checkAlive is the systemd watchdog liveness check: the monitor state lock
+// must be obtainable, i.e. no loop is deadlocked holding it.
+func (s *Server) checkAlive() error {
+	if s.monitor != nil {
+		s.monitor.Snapshot()
+	}
+	s.detectionHealthStatus()
+	return nil
+}
+
+// handleReadyz reports readiness. The server is ready once the frame SHM is
+// attached; the detection daemon is reported but does not gate readiness
+// (the motion fallback keeps events flowing while it is down).
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ready, body := s.readiness()
+	body["ready"] = ready
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+	if s.relay != nil {
+		body["relay"] = s.relay.Status()
+	}
+	if s.mqtt != nil {
+		body["mqtt"] = s.mqtt.Status()
+	}
+	if s.recordingPaths != nil {
+		body["recording_paths"] = s.recordingPaths.Status()
+	}
+	if s.degrade != nil {
+		body["degradation"] = s.degrade.Status()
+	}
+	writeJSONWithStatus(w, body, status)
+}
+
+// newMetricsHandler builds the Prometheus handler for web monitor metrics
+// and the Grafana dashboard of them.
+func (s *Server) newMetricsHandler() (handler, dashboard http.Handler) {
+	reg := prometheus.NewRegistry()
+	// Every series carries the camera label, as in the streaming server
+	registry := prometheus.WrapRegistererWith(prometheus.Labels{"camera": metrics.CameraLabel(s.cfg.CameraName)}, reg)
+
+	registry.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "detection_daemon_healthy",
+			Help: "Detection daemon is publishing new results (0=stale, 1=healthy)",
+		},
+		func() float64 { return float64(boolToInt(s.detectionHealthStatus().Healthy)) },
+	))
+
+	registry.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "detection_daemon_stale_seconds",
+			Help: "Seconds since the detection version last advanced",
+		},
+		func() float64 { return s.detectionHealthStatus().StaleSeconds },
+	))
+
+	registry.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "detection_motion_fallback_active",
+			Help: "Motion fallback is generating events (0=dormant, 1=active)",
+		},
+		func() float64 { return float64(boolToInt(s.detectionHealthStatus().MotionFallback)) },
+	))
+
+	registry.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "webmonitor_duplicate_frames_skipped_total",
+			Help: "MJPEG polls skipped because the SHM frame number had already been broadcast",
+		},
+		func() float64 { return float64(s.broadcaster.DuplicatesSkipped()) },
+	))
+
+	registry.MustRegister(prometheus.NewCounterFunc(
+		prometheus.CounterOpts{
+			Name: "webmonitor_mjpeg_encodes_total",
+			Help: "Frames overlaid and JPEG-encoded for MJPEG and snapshot consumers",
+		},
+		func() float64 { encoded, _ := s.broadcaster.EncodeStats(); return float64(encoded) },
+	))
+
+	registry.MustRegister(prometheus.NewCounterFunc(
+		prometheus.CounterOpts{
+			Name: "webmonitor_mjpeg_encodes_skipped_total",
+			Help: "Camera frames not encoded because no MJPEG or snapshot consumer was connected (e.g. WebRTC viewers only)",
+		},
+		func() float64 { _, skipped := s.broadcaster.EncodeStats(); return float64(skipped) },
+	))
+
+	for _, kind := range []FrameConsumer{ConsumerMJPEG, ConsumerSnapshot} {
+		registry.MustRegister(prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name:        "webmonitor_frame_consumers",
+				Help:        "Frame broadcaster subscribers by kind; encoding runs only while one exists",
+				ConstLabels: prometheus.Labels{"kind": kind.String()},
+			},
+			func() float64 { return float64(s.broadcaster.Consumers()[kind]) },
+		))
+	}
+
+	registry.MustRegister(prometheus.NewCounterFunc(
+		prometheus.CounterOpts{
+			Name: "webmonitor_mjpeg_bytes_sent_total",
+			Help: "Bytes written to MJPEG viewers (see /api/streams for per-stream counts)",
+		},
+		func() float64 { return float64(s.mjpegAccounting.BytesTotal()) },
+	))
+
+	if s.forwarder != nil {
+		registry.MustRegister(prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "forwarder_frames_sent_total",
+				Help: "Frames delivered to the secondary inference service",
+			},
+			func() float64 { return float64(s.forwarder.Stats().Sent) },
+		))
+
+		registry.MustRegister(prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "forwarder_errors_total",
+				Help: "Frames that could not be forwarded or whose response could not be decoded",
+			},
+			func() float64 { return float64(s.forwarder.Stats().Failed) },
+		))
+
+		registry.MustRegister(prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "forwarder_annotations_total",
+				Help: "Detections returned by the secondary inference service",
+			},
+			func() float64 { return float64(s.forwarder.Stats().Annotations) },
+		))
+
+		registry.MustRegister(prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "forwarder_latency_seconds",
+				Help: "Round trip of the last successful forwarded frame",
+			},
+			func() float64 { return s.forwarder.Stats().LastLatency.Seconds() },
+		))
+	}
+
+	if s.scrubber != nil {
+		registry.MustRegister(prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "recordings_scrub_checked_total",
+				Help: "Recordings verified by the storage scrubber since start",
+			},
+			func() float64 { return float64(s.scrubber.Stats().Checked) },
+		))
+
+		registry.MustRegister(prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "recordings_scrub_corrupt",
+				Help: "Recordings the last scrub pass found corrupt",
+			},
+			func() float64 { return float64(s.scrubber.Stats().Corrupt) },
+		))
+
+		registry.MustRegister(prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "recordings_scrub_last_run_timestamp_seconds",
+				Help: "Unix time the last scrub pass finished (0 = not yet)",
+			},
+			func() float64 {
+				if t := s.scrubber.Stats().LastRun; !t.IsZero() {
+					return float64(t.Unix())
+				}
+				return 0
+			},
+		))
+
+		registry.MustRegister(prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "recordings_scrub_duration_seconds",
+				Help: "Duration of the last scrub pass",
+			},
+			func() float64 { return s.scrubber.Stats().LastRunTook.Seconds() },
+		))
+	}
+
+	if s.access != nil {
+		registry.MustRegister(s.access)
+	}
+	if s.compress != nil {
+		registry.MustRegister(s.compress)
+	}
+	registry.MustRegister(crash.Collector())
+	if s.outbox != nil {
+		registry.MustRegister(s.outbox)
+	}
+	if s.bus != nil {
+		registry.MustRegister(s.bus)
+	}
+
+	if s.alerts != nil {
+		registry.MustRegister(prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "webmonitor_alerts_active",
+				Help: "Active operational alerts (see /api/alerts)",
+			},
+			func() float64 { active, _ := s.alerts.Active(); return float64(len(active)) },
+		))
+
+		registry.MustRegister(prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "webmonitor_alerts_unacked",
+				Help: "Active alerts not yet acknowledged",
+			},
+			func() float64 { _, unacked := s.alerts.Active(); return float64(unacked) },
+		))
+	}
+
+	if s.degrade != nil {
+		registry.MustRegister(prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "webmonitor_degradation_level",
+				Help: "CPU degradation level (0=normal, 1=reduce MJPEG, 2=pause annotated, 3=decimate WebRTC)",
+			},
+			func() float64 { return float64(s.degrade.Level()) },
+		))
+
+		registry.MustRegister(prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "webmonitor_cpu_usage_percent",
+				Help: "System CPU usage sampled by the degradation controller",
+			},
+			func() float64 { return s.degrade.Status().CPUPercent },
+		))
+	}
+
+	if s.diskMonitor != nil {
+		registry.MustRegister(prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "recordings_disk_free_bytes",
+				Help: "Free space on the recordings volume",
+			},
+			func() float64 { free, _ := s.diskMonitor.Usage(); return float64(free) },
+		))
+
+		registry.MustRegister(prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "recordings_disk_size_bytes",
+				Help: "Size of the recordings volume",
+			},
+			func() float64 { _, size := s.diskMonitor.Usage(); return float64(size) },
+		))
+	}
+
+	if s.recordingPaths != nil {
+		for i, st := range s.recordingPaths.Status() {
+			registry.MustRegister(prometheus.NewGaugeFunc(
+				prometheus.GaugeOpts{
+					Name:        "recording_path_healthy",
+					Help:        "1 if the recording path took a synced write at the last check",
+					ConstLabels: prometheus.Labels{"path": st.Path},
+				},
+				func() float64 { return float64(boolToInt(s.recordingPaths.Status()[i].Healthy)) },
+			))
+			registry.MustRegister(prometheus.NewGaugeFunc(
+				prometheus.GaugeOpts{
+					Name:        "recording_path_active",
+					Help:        "1 for the recording path new recordings go to",
+					ConstLabels: prometheus.Labels{"path": st.Path},
+				},
+				func() float64 { return float64(boolToInt(s.recordingPaths.Status()[i].Active)) },
+			))
+		}
+
+		registry.MustRegister(prometheus.NewCounterFunc(
+			prometheus.CounterOpts{
+				Name: "recording_path_failovers_total",
+				Help: "Times recordings moved off a failed recording path",
+			},
+			func() float64 { return float64(s.recordingPaths.Failovers()) },
+		))
+	}
+
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{}), metrics.DashboardHandler(reg, "Pet camera web monitor")
+}
@@ -0,0 +1,220 @@
+package webmonitor
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// fallbackLocale renders keys a locale lacks. Logs, the audit log and the
+// English fields kept next to a Message are rendered in it.
+const fallbackLocale = "en"
+
+// catalog holds the server's user-facing strings by locale and key: alert
+// messages, push notifications, event labels and the dashboard labels
+// (keys "ui.*", see /api/ui/config). Values are fmt formats.
+var catalog = map[string]map[string]string{
+	"en": {
+		"ui.title":                  "Smart Pet Camera Monitor",
+		"ui.tab.live":               "Live",
+		"ui.tab.tracking":           "Tracking",
+		"ui.tab.album":              "Album",
+		"ui.panel.diagnostics":      "Diagnostics",
+		"ui.panel.history":          "History",
+		"ui.panel.cameras":          "Cameras",
+		"ui.video.h265_unsupported": "This browser cannot play H.265. Download the clip to watch it.",
+
+		"alert.disk_full":          "Recordings volume almost full: %d MiB (%.1f%%) free",
+		"alert.disk_low":           "Recordings volume low on space: %d MiB (%.1f%%) free",
+		"alert.liveness_failed":    "Liveness check failed: %s",
+		"alert.detector_stale":     "Detector stale: no detection results since %s",
+		"alert.detector_offline":   "Detector offline: no detection results for %v",
+		"alert.monitor_restarted":  "Monitor restarted (state saved %v before boot)",
+		"alert.recording_fallback": "Recording to fallback path %s: %s unavailable (%s)",
+		"alert.recording_no_path":  "No recording path is writable: %s failed (%s)",
+
+		"push.test.title":             "Pet Camera",
+		"push.test.body":              "Test notification",
+		"push.detector_offline.title": "Detector offline",
+		"push.detector_offline.body":  "No detection results for %v",
+		"push.detected":               "%s detected",
+
+		"class.cat":    "Cat",
+		"class.dog":    "Dog",
+		"class.bird":   "Bird",
+		"class.person": "Person",
+		"class.motion": "Motion",
+
+		"event.feeding_started":        "Feeding started",
+		"event.feeding_ended":          "Feeding ended",
+		"event.drinking_started":       "Drinking started",
+		"event.drinking_ended":         "Drinking ended",
+		"event.lighting_changed":       "Lighting changed",
+		"event.power_state":            "Power state changed",
+		"event.recording_started":      "Recording started",
+		"event.recording_stopped":      "Recording stopped",
+		"event.recording_resumed":      "Recording resumed",
+		"event.rule_fired":             "Rule fired",
+		"event.cpu_degraded":           "CPU overloaded, output reduced",
+		"event.cpu_recovered":          "CPU load recovered",
+		"event.clock_jump":             "Clock adjusted",
+		"event.zone_entered":           "Entered zone",
+		"event.zone_left":              "Left zone",
+		"event.trigger_fired":          "External trigger",
+		"event.recording_path_changed": "Recording path changed",
+	},
+	"ja": {
+		"ui.title":                  "スマートペットカメラ モニター",
+		"ui.tab.live":               "ライブ",
+		"ui.tab.tracking":           "トラッキング",
+		"ui.tab.album":              "アルバム",
+		"ui.panel.diagnostics":      "診断",
+		"ui.panel.history":          "履歴",
+		"ui.panel.cameras":          "カメラ",
+		"ui.video.h265_unsupported": "H.265 の再生に非対応のブラウザです。ダウンロードしてご覧ください。",
+
+		"alert.disk_full":          "録画ボリュームの空きがほとんどありません: 残り %d MiB (%.1f%%)",
+		"alert.disk_low":           "録画ボリュームの空きが少なくなっています: 残り %d MiB (%.1f%%)",
+		"alert.liveness_failed":    "死活監視に失敗しました: %s",
+		"alert.detector_stale":     "検出が止まっています: %s 以降、検出結果がありません",
+		"alert.detector_offline":   "検出器がオフラインです: %v 検出結果がありません",
+		"alert.monitor_restarted":  "モニターが再起動しました (起動の %v 前に状態を保存)",
+		"alert.recording_fallback": "予備の録画先 %s に録画しています: %s が使えません (%s)",
+		"alert.recording_no_path":  "書き込める録画先がありません: %s が使えません (%s)",
+
+		"push.test.title":             "ペットカメラ",
+		"push.test.body":              "テスト通知",
+		"push.detector_offline.title": "検出器オフライン",
+		"push.detector_offline.body":  "%v 検出結果がありません",
+		"push.detected":               "%sを検出しました",
+
+		"class.cat":    "猫",
+		"class.dog":    "犬",
+		"class.bird":   "鳥",
+		"class.person": "人",
+		"class.motion": "動き",
+
+		"event.feeding_started":        "食事開始",
+		"event.feeding_ended":          "食事終了",
+		"event.drinking_started":       "水飲み開始",
+		"event.drinking_ended":         "水飲み終了",
+		"event.lighting_changed":       "照明の切り替え",
+		"event.power_state":            "電源状態の変更",
+		"event.recording_started":      "録画開始",
+		"event.recording_stopped":      "録画停止",
+		"event.recording_resumed":      "録画再開",
+		"event.rule_fired":             "ルール発動",
+		"event.cpu_degraded":           "CPU 高負荷のため出力を制限",
+		"event.cpu_recovered":          "CPU 負荷が回復",
+		"event.clock_jump":             "時刻の補正",
+		"event.zone_entered":           "ゾーンに入った",
+		"event.zone_left":              "ゾーンから出た",
+		"event.trigger_fired":          "外部トリガー",
+		"event.recording_path_changed": "録画先の切り替え",
+	},
+}
+
+// Message is a user-facing string kept as a catalog key and its arguments,
+// so it can be rendered in each reader's locale when served. Arguments
+// that are Messages themselves are rendered in the same locale.
+type Message struct {
+	Key  string
+	Args []any
+}
+
+// msg returns the Message of key with args.
+func msg(key string, args ...any) Message {
+	return Message{Key: key, Args: args}
+}
+
+// In renders m in locale, falling back to English and then to the key.
+func (m Message) In(locale string) string {
+	format, ok := catalog[locale][m.Key]
+	if !ok {
+		if format, ok = catalog[fallbackLocale][m.Key]; !ok {
+			return m.Key
+		}
+	}
+	if len(m.Args) == 0 {
+		return format
+	}
+	args := make([]any, len(m.Args))
+	for i, a := range m.Args {
+		if inner, ok := a.(Message); ok {
+			a = inner.In(locale)
+		}
+		args[i] = a
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// String renders m in English.
+func (m Message) String() string {
+	return m.In(fallbackLocale)
+}
+
+// hasMessage reports whether key is in the catalog.
+func hasMessage(key string) bool {
+	_, ok := catalog[fallbackLocale][key]
+	return ok
+}
+
+// classLabel is the localized name of a detection class, or the class
+// name itself for classes without one.
+func classLabel(class string) any {
+	if hasMessage("class." + class) {
+		return msg("class." + class)
+	}
+	return class
+}
+
+// locales returns the locales with a catalog, sorted.
+func locales() []string {
+	return slices.Sorted(maps.Keys(catalog))
+}
+
+// matchLocale maps a language tag ("ja", "ja-JP", "en_US") to a catalog
+// locale, "" when there is none.
+func matchLocale(tag string) string {
+	base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+	base, _, _ = strings.Cut(base, "_")
+	if catalog[base] == nil {
+		return ""
+	}
+	return base
+}
+
+// explicitLocale returns the locale a request asked for: ?locale=, else
+// the locale of the access token it was let in with; "" for neither.
+func explicitLocale(r *http.Request) string {
+	if l := matchLocale(r.URL.Query().Get("locale")); l != "" {
+		return l
+	}
+	if t, ok := requestToken(r); ok {
+		return t.Locale
+	}
+	return ""
+}
+
+// configLocale is the -locale setting, English if unsupported.
+func configLocale(cfg Config) string {
+	if l := matchLocale(cfg.Locale); l != "" {
+		return l
+	}
+	return fallbackLocale
+}
+
+func (s *Server) defaultLocale() string {
+	return configLocale(s.cfg)
+}
+
+// requestLocale selects the locale of the user-facing strings in a
+// response: the request's own (see explicitLocale), else -locale.
+func (s *Server) requestLocale(r *http.Request) string {
+	if l := explicitLocale(r); l != "" {
+		return l
+	}
+	return s.defaultLocale()
+}
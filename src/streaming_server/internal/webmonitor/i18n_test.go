@@ -0,0 +1,130 @@
+package webmonitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/webpush"
+)
+
+func TestCatalogComplete(t *testing.T) {
+	for locale, messages := range catalog {
+		if len(messages) != len(catalog[fallbackLocale]) {
+			t.Errorf("%s: %d messages, %s has %d", locale, len(messages), fallbackLocale, len(catalog[fallbackLocale]))
+		}
+		for key, en := range catalog[fallbackLocale] {
+			got, ok := messages[key]
+			if !ok {
+				t.Errorf("%s: missing %q", locale, key)
+			} else if strings.Count(got, "%")-strings.Count(got, "%%")*2 != strings.Count(en, "%")-strings.Count(en, "%%")*2 {
+				t.Errorf("%s: %q has other verbs than in %s", locale, key, fallbackLocale)
+			}
+		}
+	}
+}
+
+func TestMessageIn(t *testing.T) {
+	m := msg("push.detected", classLabel("cat"))
+	if got := m.In("ja"); got != "猫を検出しました" {
+		t.Errorf("ja: %q", got)
+	}
+	if got := m.In("fr"); got != "Cat detected" || m.String() != got {
+		t.Errorf("fallback: %q", got)
+	}
+	if got := msg("push.detected", classLabel("hamster")).In("ja"); got != "hamsterを検出しました" {
+		t.Errorf("class without label: %q", got)
+	}
+	if got := msg("no.such.key").In("ja"); got != "no.such.key" {
+		t.Errorf("unknown key: %q", got)
+	}
+	for tag, want := range map[string]string{"ja-JP": "ja", "EN_us": "en", "fr": "", "": ""} {
+		if got := matchLocale(tag); got != want {
+			t.Errorf("matchLocale(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
+
+func TestRequestLocale(t *testing.T) {
+	s := &Server{cfg: Config{Locale: "ja"}, alerts: NewAlertCenter()}
+	s.alerts.RaiseMessage("disk", diskAlertKey, AlertWarning, msg("alert.disk_low", 512, 4.5))
+	s.alerts.Raise("log", "log:x", AlertWarning, "[Recorder] write failed")
+
+	get := func(target string, token *AccessToken) []Alert {
+		req := httptest.NewRequest("GET", target, nil)
+		if token != nil {
+			req = req.WithContext(context.WithValue(req.Context(), tokenCtxKey{}, *token))
+		}
+		rec := httptest.NewRecorder()
+		s.handleAlerts(rec, req)
+		var resp struct{ Alerts []Alert }
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp.Alerts
+	}
+	en := &AccessToken{Locale: "en"}
+	for _, c := range []struct {
+		target string
+		token  *AccessToken
+		want   string
+	}{
+		{"/api/alerts", nil, "録画ボリュームの空きが少なくなっています: 残り 512 MiB (4.5%)"},
+		{"/api/alerts", en, "Recordings volume low on space: 512 MiB (4.5%) free"},
+		{"/api/alerts?locale=ja-JP", en, "録画ボリュームの空きが少なくなっています: 残り 512 MiB (4.5%)"},
+	} {
+		alerts := get(c.target, c.token)
+		if len(alerts) != 2 || alerts[0].Message != c.want || alerts[1].Message != "[Recorder] write failed" {
+			t.Errorf("%s (token %v): %+v", c.target, c.token, alerts)
+		}
+	}
+	// The stored alert keeps the English text for the audit log
+	if active, _ := s.alerts.Active(); active[0].Message != "Recordings volume low on space: 512 MiB (4.5%) free" {
+		t.Errorf("stored %q", active[0].Message)
+	}
+}
+
+func TestPushLocalePerSubscription(t *testing.T) {
+	dir := t.TempDir()
+	subs := pushDoc.in(kv.NewMemory())
+	n, err := NewPushNotifier(filepath.Join(dir, "vapid.pem"), subs, "mailto:test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.DefaultLocale = "ja"
+	_ = n.Subscribe(testSubscription("https://push.example.com/en"), "en")
+	_ = n.Subscribe(testSubscription("https://push.example.com/default"), "")
+
+	var mu sync.Mutex
+	got := make(map[string]PushMessage)
+	n.sender = func(_ context.Context, sub webpush.Subscription, payload []byte) error {
+		var m PushMessage
+		json.Unmarshal(payload, &m)
+		mu.Lock()
+		got[sub.Endpoint] = m
+		mu.Unlock()
+		return nil
+	}
+	n.Notify(localizedPush(msg("push.detector_offline.title"), msg("push.detector_offline.body", 90*time.Second), "detector-health"))
+	if m := got["https://push.example.com/en"]; m.Title != "Detector offline" || m.Body != "No detection results for 1m30s" {
+		t.Errorf("en: %+v", m)
+	}
+	if m := got["https://push.example.com/default"]; m.Title != "検出器オフライン" || m.Tag != "detector-health" {
+		t.Errorf("default: %+v", m)
+	}
+
+	// The locale is kept with the subscription
+	reloaded, err := NewPushNotifier(filepath.Join(dir, "vapid.pem"), subs, "mailto:test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.subs[0].Locale != "en" || reloaded.subs[0].Keys.Auth != "auth" {
+		t.Errorf("reloaded %+v", reloaded.subs[0])
+	}
+}
@@ -0,0 +1,157 @@
+package webmonitor
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ICEServer is one RTCPeerConnection iceServers entry handed to browsers.
+// The streaming server is ICE-lite and never contacts these itself.
+type ICEServer struct {
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+}
+
+// ParseICEServers parses a comma-separated list of stun:, stuns:, turn: and
+// turns: URLs (RFC 7064/7065). STUN URLs become one entry and TURN URLs
+// another carrying the TURN credentials. Any malformed entry is an error.
+func ParseICEServers(list, turnUsername, turnCredential string) ([]ICEServer, error) {
+	servers, err := parseICEServers(list)
+	if err != nil {
+		return nil, err
+	}
+	for i, srv := range servers {
+		if !isTURN(srv) {
+			continue
+		}
+		if turnUsername == "" || turnCredential == "" {
+			return nil, fmt.Errorf("TURN servers %v need a username and credential", srv.URLs)
+		}
+		servers[i].Username, servers[i].Credential = turnUsername, turnCredential
+	}
+	return servers, nil
+}
+
+// parseICEServers is ParseICEServers leaving the TURN entry without
+// credentials, for a TURNSecret to fill in per request.
+func parseICEServers(list string) ([]ICEServer, error) {
+	var stun, turn []string
+	for i, raw := range strings.Split(list, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		scheme, err := validateICEURL(raw)
+		if err != nil {
+			return nil, fmt.Errorf("ICE server %d %q: %w", i+1, raw, err)
+		}
+		if strings.HasPrefix(scheme, "turn") {
+			turn = append(turn, raw)
+		} else {
+			stun = append(stun, raw)
+		}
+	}
+
+	var servers []ICEServer
+	if len(stun) > 0 {
+		servers = append(servers, ICEServer{URLs: stun})
+	}
+	if len(turn) > 0 {
+		servers = append(servers, ICEServer{URLs: turn})
+	}
+	return servers, nil
+}
+
+// isTURN reports whether srv is the TURN entry (parseICEServers keeps STUN
+// and TURN URLs apart).
+func isTURN(srv ICEServer) bool {
+	return len(srv.URLs) > 0 && strings.HasPrefix(strings.ToLower(srv.URLs[0]), "turn")
+}
+
+// validateICEURL checks scheme:host[:port][?transport=udp|tcp] and returns
+// the scheme.
+func validateICEURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	scheme := strings.ToLower(u.Scheme)
+	switch scheme {
+	case "stun", "stuns", "turn", "turns":
+	default:
+		return "", fmt.Errorf("scheme must be stun, stuns, turn or turns")
+	}
+	// stun:host:port parses as an opaque URL; "stun://host" is not valid
+	if u.Opaque == "" || u.User != nil || u.Fragment != "" {
+		return "", fmt.Errorf("want %s:host[:port]", scheme)
+	}
+
+	hostport := u.Opaque
+	host, port := hostport, ""
+	if h, p, err := net.SplitHostPort(hostport); err == nil {
+		host, port = h, p
+	} else if strings.Count(hostport, ":") > 0 && !strings.HasPrefix(hostport, "[") {
+		return "", fmt.Errorf("invalid host:port %q", hostport)
+	}
+	if host == "" || strings.ContainsAny(host, "/@ ") {
+		return "", fmt.Errorf("missing or invalid host")
+	}
+	if port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return "", fmt.Errorf("invalid port %q", port)
+		}
+	}
+
+	if u.RawQuery != "" {
+		if scheme == "stun" || scheme == "stuns" {
+			return "", fmt.Errorf("query not allowed for %s", scheme)
+		}
+		q, err := url.ParseQuery(u.RawQuery)
+		if err != nil {
+			return "", err
+		}
+		for k := range q {
+			if k != "transport" {
+				return "", fmt.Errorf("unknown parameter %q", k)
+			}
+		}
+		if t := q.Get("transport"); t != "udp" && t != "tcp" {
+			return "", fmt.Errorf("transport must be udp or tcp")
+		}
+	}
+	return scheme, nil
+}
+
+// handleWebRTCConfig serves GET /api/webrtc/config: the ICE servers the
+// browser should use for its RTCPeerConnection. With a TURN secret the TURN
+// entry carries freshly issued credentials and the response their expiry,
+// after which the browser should fetch the config again.
+func (s *Server) handleWebRTCConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	servers := slices.Clone(s.iceServers)
+	if servers == nil {
+		servers = []ICEServer{}
+	}
+	resp := map[string]any{"ice_servers": servers}
+	if s.turnSecret != nil {
+		for i, srv := range servers {
+			if isTURN(srv) {
+				user, cred, expires := s.turnSecret.Credentials(s.cfg.TURNUsername, time.Now())
+				servers[i].Username, servers[i].Credential = user, cred
+				resp["expires_at"] = expires.Unix()
+			}
+		}
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, resp)
+}
@@ -0,0 +1,126 @@
+package webmonitor
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseICEServers(t *testing.T) {
+	got, err := ParseICEServers(" stun:stun.l.google.com:19302, stun:[2001:db8::1]:3478 ,turn:turn.example.com?transport=tcp,turns:turn.example.com:5349", "user", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ICEServer{
+		{URLs: []string{"stun:stun.l.google.com:19302", "stun:[2001:db8::1]:3478"}},
+		{URLs: []string{"turn:turn.example.com?transport=tcp", "turns:turn.example.com:5349"}, Username: "user", Credential: "secret"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v\nwant %+v", got, want)
+	}
+
+	if got, err := ParseICEServers("", "", ""); err != nil || got != nil {
+		t.Fatalf("empty list: %v, %v", got, err)
+	}
+
+	for _, bad := range []string{
+		"stun.l.google.com:19302",
+		"stun://stun.example.com",
+		"http:example.com",
+		"stun:",
+		"stun:host:0",
+		"stun:host:port",
+		"stun:host?transport=udp",
+		"turn:host?transport=sctp",
+		"turn:host?foo=bar",
+		"stun:a:b:c",
+	} {
+		if _, err := ParseICEServers("stun:ok.example.com,"+bad, "u", "p"); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+
+	if _, err := ParseICEServers("turn:turn.example.com", "", ""); err == nil {
+		t.Error("TURN without credentials accepted")
+	}
+}
+
+func TestTURNSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "turn.secret")
+	ts, err := LoadTURNSecret(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0600 {
+		t.Fatalf("secret file %v, %v", fi, err)
+	}
+
+	// Known answer: base64(HMAC-SHA1("north", "1700003600:petcam"))
+	if err := os.WriteFile(path, []byte("north\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	user, cred, expires := ts.Credentials("petcam", time.Unix(1700000000, 0))
+	mac := hmac.New(sha1.New, []byte("north"))
+	mac.Write([]byte("1700003600:petcam"))
+	if want := base64.StdEncoding.EncodeToString(mac.Sum(nil)); user != "1700003600:petcam" || cred != want || expires.Unix() != 1700003600 {
+		t.Fatalf("got %q %q %v, want 1700003600:petcam %q", user, cred, expires, want)
+	}
+
+	// A bad reload keeps the previous secret
+	os.WriteFile(path, []byte("  \n"), 0600)
+	if err := ts.Reload(); err == nil {
+		t.Fatal("empty secret accepted")
+	}
+	if _, again, _ := ts.Credentials("petcam", time.Unix(1700000000, 0)); again != cred {
+		t.Fatal("secret changed by failed reload")
+	}
+
+	// An existing file is not overwritten
+	if ts2, err := LoadTURNSecret(path, time.Hour); err == nil {
+		t.Fatalf("loaded %v from empty file", ts2)
+	}
+}
+
+func TestWebRTCConfigTURNSecret(t *testing.T) {
+	ts, err := LoadTURNSecret(filepath.Join(t.TempDir(), "turn.secret"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	servers, err := parseICEServers("stun:stun.example.com,turn:turn.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{cfg: Config{TURNUsername: "cam1"}, iceServers: servers, turnSecret: ts}
+
+	rec := httptest.NewRecorder()
+	s.handleWebRTCConfig(rec, httptest.NewRequest(http.MethodGet, "/api/webrtc/config", nil))
+	var resp struct {
+		ICEServers []ICEServer `json:"ice_servers"`
+		ExpiresAt  int64       `json:"expires_at"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.ICEServers) != 2 || resp.ICEServers[0].Username != "" {
+		t.Fatalf("servers %+v", resp.ICEServers)
+	}
+	turn := resp.ICEServers[1]
+	if turn.Username != strconv.FormatInt(resp.ExpiresAt, 10)+":cam1" || turn.Credential == "" {
+		t.Fatalf("TURN entry %+v, expires %d", turn, resp.ExpiresAt)
+	}
+	if s.iceServers[1].Username != "" {
+		t.Fatal("configured servers modified")
+	}
+}
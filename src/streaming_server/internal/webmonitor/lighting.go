@@ -0,0 +1,157 @@
+package webmonitor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+// Lighting is the scene illumination as seen through the active camera. The
+// capture daemon switches to the IR night camera in the dark, whose image is
+// monochrome, and writes the active camera into every frame header.
+type Lighting string
+
+const (
+	LightingDay   Lighting = "day"
+	LightingNight Lighting = "night"
+	LightingMixed Lighting = "mixed" // a recording spanning a camera switch
+)
+
+// cameraNight is CAMERA_MODE_NIGHT (capture/camera_switcher.h).
+const cameraNight = 1
+
+// EventLightingChanged is appended when the capture side switches cameras.
+const EventLightingChanged = "lighting_changed"
+
+const lightingPollInterval = time.Second
+
+// lightingForCamera maps a frame header camera_id to the lighting it means.
+func lightingForCamera(id int) Lighting {
+	if id == cameraNight {
+		return LightingNight
+	}
+	return LightingDay
+}
+
+// LightingStatus is the lighting carried in /api/status and status events.
+type LightingStatus struct {
+	Lighting Lighting `json:"lighting"`        // "" until the first frame
+	Since    float64  `json:"since,omitempty"` // Unix seconds of the last switch
+}
+
+// LightingWatcher follows the active camera of the frame SHM and reports
+// day/night switches. The capture daemon already applies hysteresis to the
+// switch, so every change of camera is taken as is.
+type LightingWatcher struct {
+	source func() (cameraID int, ok bool)
+
+	mu       sync.Mutex
+	current  Lighting
+	since    time.Time
+	onChange func(from, to Lighting)
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewLightingWatcher creates a watcher reading the active camera from source.
+func NewLightingWatcher(source func() (int, bool)) *LightingWatcher {
+	return &LightingWatcher{source: source, stop: make(chan struct{})}
+}
+
+// SetOnChange sets the callback run (without locks held) on each switch,
+// including the first lighting seen, which comes from "".
+func (lw *LightingWatcher) SetOnChange(fn func(from, to Lighting)) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	lw.onChange = fn
+}
+
+// Start polls the source until Stop.
+func (lw *LightingWatcher) Start() {
+	go func() {
+		ticker := time.NewTicker(lightingPollInterval)
+		defer ticker.Stop()
+		for {
+			lw.Poll(time.Now())
+			select {
+			case <-lw.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop ends polling.
+func (lw *LightingWatcher) Stop() {
+	lw.stopOnce.Do(func() { close(lw.stop) })
+}
+
+// Poll reads the active camera once and reports a switch.
+func (lw *LightingWatcher) Poll(now time.Time) {
+	id, ok := lw.source()
+	if !ok {
+		return
+	}
+	to := lightingForCamera(id)
+	lw.mu.Lock()
+	from := lw.current
+	if from == to {
+		lw.mu.Unlock()
+		return
+	}
+	lw.current, lw.since = to, now
+	fn := lw.onChange
+	lw.mu.Unlock()
+
+	if from != "" {
+		logger.Info("Lighting", "Switched %s -> %s", from, to)
+	}
+	if fn != nil {
+		fn(from, to)
+	}
+}
+
+// Current returns the lighting, "" before the first frame.
+func (lw *LightingWatcher) Current() Lighting {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.current
+}
+
+// Status returns the lighting and when it last switched.
+func (lw *LightingWatcher) Status() LightingStatus {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	st := LightingStatus{Lighting: lw.current}
+	if !lw.since.IsZero() {
+		st.Since = float64(lw.since.Unix())
+	}
+	return st
+}
+
+// startLighting follows the active camera of shm (nil = no frames yet, so
+// no lighting): each switch selects the class filter's lighting profile and
+// is recorded as an event.
+func (s *Server) startLighting(shm *shmReader) {
+	s.lighting = NewLightingWatcher(func() (int, bool) {
+		if shm == nil {
+			return 0, false
+		}
+		return shm.LatestCameraID()
+	})
+	s.lighting.SetOnChange(func(from, to Lighting) {
+		s.classFilter.SetLighting(to)
+		if from == "" {
+			return
+		}
+		s.events.Append(Event{
+			Type: EventLightingChanged,
+			Data: map[string]string{"from": string(from), "lighting": string(to)},
+		})
+	})
+	s.statusBroadcaster.SetLighting(s.lighting.Status)
+	s.lighting.Start()
+}
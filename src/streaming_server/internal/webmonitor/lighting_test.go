@@ -0,0 +1,87 @@
+package webmonitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+)
+
+func TestLightingWatcher(t *testing.T) {
+	camera, ok := 0, false
+	lw := NewLightingWatcher(func() (int, bool) { return camera, ok })
+	var switches []string
+	lw.SetOnChange(func(from, to Lighting) { switches = append(switches, string(from)+">"+string(to)) })
+
+	now := time.Now()
+	lw.Poll(now)
+	if lw.Current() != "" || len(switches) != 0 {
+		t.Fatal("lighting reported without frames")
+	}
+	ok = true
+	lw.Poll(now)
+	lw.Poll(now.Add(time.Second))
+	camera = cameraNight
+	lw.Poll(now.Add(2 * time.Second))
+	if lw.Current() != LightingNight || len(switches) != 2 || switches[0] != ">day" || switches[1] != "day>night" {
+		t.Fatalf("lighting %q, switches %v", lw.Current(), switches)
+	}
+	if st := lw.Status(); st.Since != float64(now.Add(2*time.Second).Unix()) {
+		t.Errorf("status %+v", st)
+	}
+}
+
+func TestNightFilterProfile(t *testing.T) {
+	f := NewClassFilter(kv.Doc{})
+	f.SetDefaultProfiles(FilterProfile{}, FilterProfile{MinConfidence: 0.5, Classes: map[string]float64{"cat": 0.3}})
+	dets := []Detection{
+		{ClassName: "cat", Confidence: 0.35},
+		{ClassName: "dog", Confidence: 0.45},
+		{ClassName: "motion", Confidence: 0.1},
+	}
+	if got := f.Filter(dets); len(got) != 3 {
+		t.Fatalf("day profile dropped %d", 3-len(got))
+	}
+	f.SetLighting(LightingNight)
+	got := f.Filter(dets)
+	if len(got) != 2 || got[0].ClassName != "cat" || got[1].ClassName != "motion" {
+		t.Fatalf("night profile kept %+v", got)
+	}
+	if st := f.Status(); st.Lighting != LightingNight || st.Profiles[LightingNight].MinConfidence != 0.5 || st.Dropped != 1 {
+		t.Fatalf("status %+v", st)
+	}
+	for _, bad := range []map[Lighting]FilterProfile{
+		{"dusk": {}},
+		{LightingNight: {MinConfidence: 1.5}},
+		{LightingDay: {Classes: map[string]float64{"cat": -1}}},
+	} {
+		if _, err := f.Update(nil, bad); err == nil {
+			t.Errorf("%v accepted", bad)
+		}
+	}
+}
+
+func TestRecordingLighting(t *testing.T) {
+	for _, c := range []struct {
+		day, night uint64
+		want       Lighting
+	}{{0, 0, ""}, {100, 0, LightingDay}, {5, 95, LightingNight}, {80, 20, LightingMixed}} {
+		if got := recordingLighting(c.day, c.night); got != c.want {
+			t.Errorf("%d day/%d night = %q, want %q", c.day, c.night, got, c.want)
+		}
+	}
+
+	r := NewRecorder(t.TempDir(), "")
+	r.updateLightingIndex("recording_20260101_220000.hevc", LightingNight)
+	r.updateLightingIndex("recording_20260102_120000.hevc", LightingDay)
+	recs := []RecordingInfo{{Name: "recording_20260101_220000.mp4"}, {Name: "recording_20260102_120000.mp4"}, {Name: "old.mp4"}}
+	r.annotateLighting(recs)
+	if recs[0].Lighting != LightingNight || recs[1].Lighting != LightingDay || recs[2].Lighting != "" {
+		t.Fatalf("annotated %+v", recs)
+	}
+	r.updateLightingIndex("recording_20260101_220000.mp4", "")
+	r.annotateLighting(recs)
+	if recs[0].Lighting != "" {
+		t.Errorf("deleted recording still tagged %q", recs[0].Lighting)
+	}
+}
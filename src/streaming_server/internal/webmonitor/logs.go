@@ -0,0 +1,115 @@
+package webmonitor
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+// attachLogHooks registers the monitor's log sinks (alerts for WARN/ERROR,
+// the /api/logs ring buffers) and returns a function removing them.
+func (s *Server) attachLogHooks() (detach func()) {
+	s.logHooks = []*logger.Registration{
+		logger.Register(logger.HookFunc(s.alerts.alertFromLog), logger.HookOptions{MinLevel: logger.WARN}),
+		logger.Register(s.logRing, logger.HookOptions{}),
+	}
+	return func() {
+		for _, h := range s.logHooks {
+			h.Unregister()
+		}
+	}
+}
+
+// parseLogLevel reads ?level= (default: everything).
+func parseLogLevel(r *http.Request) (logger.LogLevel, error) {
+	v := r.URL.Query().Get("level")
+	if v == "" {
+		return logger.DEBUG, nil
+	}
+	return logger.ParseLevel(v)
+}
+
+// handleLogs serves GET /api/logs?level=warn&limit=200 (also /debug/logs):
+// recent log lines that passed -log-level, oldest first.
+func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	level, err := parseLogLevel(r)
+	if err != nil {
+		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusBadRequest)
+		return
+	}
+	limit := 200
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeJSONWithStatus(w, map[string]any{"error": "limit must be a non-negative integer"}, http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	var dropped uint64
+	for _, h := range s.logHooks {
+		dropped += h.Dropped()
+	}
+	writeJSON(w, map[string]any{
+		"entries": s.logRing.Entries(level, limit),
+		"dropped": dropped,
+	})
+}
+
+// handleLogsStream serves GET /api/logs/stream?level=: SSE "log" events for
+// new log lines. Each client gets its own logger hook; a client that falls
+// behind misses lines rather than slowing logging down.
+func (s *Server) handleLogsStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	level, err := parseLogLevel(r)
+	if err != nil {
+		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	entries := make(chan logger.Entry, 64)
+	hook := logger.Register(logger.HookFunc(func(e logger.Entry) {
+		select {
+		case entries <- e:
+		default:
+		}
+	}), logger.HookOptions{MinLevel: level, Buffer: 64})
+	defer hook.Unregister()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	flusher.Flush()
+
+	ctx := r.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-entries:
+			if _, err := fmt.Fprint(w, "event: log\n"); err != nil {
+				return
+			}
+			if err := writeSSE(w, e); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-time.After(30 * time.Second):
+			if _, err := fmt.Fprintf(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package webmonitor
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+func TestHandleLogs(t *testing.T) {
+	s := &Server{logRing: logger.NewLevelRing(10)}
+	now := time.Now()
+	s.logRing.Fire(logger.Entry{Time: now, Level: logger.INFO, Module: "Main", Message: "started"})
+	s.logRing.Fire(logger.Entry{Time: now.Add(time.Second), Level: logger.WARN, Module: "Recorder", Message: "slow disk"})
+
+	w := httptest.NewRecorder()
+	s.handleLogs(w, httptest.NewRequest("GET", "/api/logs?level=warn&limit=200", nil))
+	var body struct {
+		Entries []struct {
+			Level   string `json:"level"`
+			Module  string `json:"module"`
+			Message string `json:"message"`
+		} `json:"entries"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Entries) != 1 || body.Entries[0].Level != "WARN" || body.Entries[0].Message != "slow disk" {
+		t.Fatalf("entries %+v", body.Entries)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleLogs(w, httptest.NewRequest("GET", "/api/logs?level=loud", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("bad level: status %d", w.Code)
+	}
+}
+
+func TestHandleLogsStream(t *testing.T) {
+	s := &Server{}
+	srv := httptest.NewServer(http.HandlerFunc(s.handleLogsStream))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "?level=warn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// The hook is registered before the headers are flushed.
+	l := logger.New(logger.DEBUG, io.Discard, false)
+	l.Info("Main", "filtered out")
+	l.Warn("Recorder", "queue full")
+
+	lines := bufio.NewScanner(resp.Body)
+	for lines.Scan() {
+		if data, ok := strings.CutPrefix(lines.Text(), "data: "); ok {
+			if !strings.Contains(data, `"message":"queue full"`) {
+				t.Fatalf("got %s", data)
+			}
+			return
+		}
+	}
+	t.Fatal("stream ended without a log event")
+}
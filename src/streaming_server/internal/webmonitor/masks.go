@@ -0,0 +1,395 @@
+package webmonitor
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+)
+
+// Bounds on the masks a user can draw.
+const (
+	maxMasks        = 32
+	maxMaskVertices = 64
+	maxMaskName     = 64
+)
+
+// MaskPoint is a polygon vertex in detection coordinates (1280x720).
+type MaskPoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// Mask is an ignore region, e.g. around a TV that keeps producing false
+// detections. A detection whose bbox center falls inside the polygon is
+// dropped, for every class or only the listed ones.
+type Mask struct {
+	ID      string      `json:"id"`
+	Name    string      `json:"name,omitempty"`
+	Points  []MaskPoint `json:"points"`
+	Classes []string    `json:"classes,omitempty"` // empty = every class
+}
+
+// Validate checks the polygon and normalizes the class list.
+func (m *Mask) Validate() error {
+	if len(m.Name) > maxMaskName {
+		return fmt.Errorf("name longer than %d bytes", maxMaskName)
+	}
+	if err := validatePolygon("mask", m.Points); err != nil {
+		return err
+	}
+	classes, err := normalizeClasses(m.Classes)
+	if err != nil {
+		return err
+	}
+	m.Classes = classes
+	return nil
+}
+
+// validatePolygon checks the vertex count and that every vertex is inside
+// the detection frame; what names the polygon in errors.
+func validatePolygon(what string, pts []MaskPoint) error {
+	if len(pts) < 3 || len(pts) > maxMaskVertices {
+		return fmt.Errorf("%s needs 3-%d points, got %d", what, maxMaskVertices, len(pts))
+	}
+	for i, p := range pts {
+		if p.X < 0 || p.X > detectionRefW || p.Y < 0 || p.Y > detectionRefH {
+			return fmt.Errorf("point %d (%d,%d) outside the %dx%d frame", i, p.X, p.Y, detectionRefW, detectionRefH)
+		}
+	}
+	return nil
+}
+
+// contains reports whether (x, y) is inside the polygon (even-odd rule).
+func (m *Mask) contains(x, y float64) bool {
+	return polygonContains(m.Points, x, y)
+}
+
+// polygonContains reports whether (x, y) is inside pts (even-odd rule).
+func polygonContains(pts []MaskPoint, x, y float64) bool {
+	in := false
+	for i, j := 0, len(pts)-1; i < len(pts); j, i = i, i+1 {
+		xi, yi := float64(pts[i].X), float64(pts[i].Y)
+		xj, yj := float64(pts[j].X), float64(pts[j].Y)
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			in = !in
+		}
+	}
+	return in
+}
+
+// suppresses reports whether the mask drops d.
+func (m *Mask) suppresses(d Detection) bool {
+	if len(m.Classes) > 0 && !slices.Contains(m.Classes, d.ClassName) {
+		return false
+	}
+	return m.contains(float64(d.BBox.X)+float64(d.BBox.W)/2, float64(d.BBox.Y)+float64(d.BBox.H)/2)
+}
+
+// MaskSet holds the ignore masks, applied where results enter the monitor
+// after the class filter, persisted to doc (the zero Doc = in memory).
+type MaskSet struct {
+	doc kv.Doc
+
+	mu      sync.RWMutex
+	masks   []Mask
+	overlay bool // draw the masks on the MJPEG overlay
+
+	suppressed atomic.Uint64
+}
+
+// NewMaskSet creates an empty mask set persisted to doc.
+func NewMaskSet(doc kv.Doc) *MaskSet {
+	return &MaskSet{doc: doc}
+}
+
+// maskFile is the persisted form.
+type maskFile struct {
+	Masks   []Mask `json:"masks"`
+	Overlay bool   `json:"overlay"`
+}
+
+// Load replaces the masks with the persisted ones; without a document
+// there are none.
+func (ms *MaskSet) Load() error {
+	var saved maskFile
+	if _, err := ms.doc.Load(&saved); err != nil {
+		return err
+	}
+	for i := range saved.Masks {
+		if err := saved.Masks[i].Validate(); err != nil {
+			return fmt.Errorf("mask %q: %w", saved.Masks[i].ID, err)
+		}
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.masks, ms.overlay = saved.Masks, saved.Overlay
+	return nil
+}
+
+// saveLocked persists the masks.
+func (ms *MaskSet) saveLocked() error {
+	return ms.doc.Save(maskFile{Masks: ms.masks, Overlay: ms.overlay})
+}
+
+// MaskStatus is the GET /api/masks response.
+type MaskStatus struct {
+	Masks      []Mask `json:"masks"`
+	Overlay    bool   `json:"overlay"`
+	Suppressed uint64 `json:"suppressed"` // detections dropped since startup
+}
+
+// Status returns the masks, the overlay setting and the suppression count.
+func (ms *MaskSet) Status() MaskStatus {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	st := MaskStatus{Masks: slices.Clone(ms.masks), Overlay: ms.overlay, Suppressed: ms.suppressed.Load()}
+	if st.Masks == nil {
+		st.Masks = []Mask{}
+	}
+	return st
+}
+
+// errTooManyMasks is returned by Put when the set is full.
+var errTooManyMasks = fmt.Errorf("at most %d masks", maxMasks)
+
+// Put adds a mask (empty ID) or replaces the mask with m's ID.
+func (ms *MaskSet) Put(m Mask) (Mask, error) {
+	if err := m.Validate(); err != nil {
+		return Mask{}, err
+	}
+	if m.ID == "" {
+		var b [6]byte
+		_, _ = rand.Read(b[:])
+		m.ID = hex.EncodeToString(b[:])
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if i := slices.IndexFunc(ms.masks, func(x Mask) bool { return x.ID == m.ID }); i >= 0 {
+		ms.masks[i] = m
+	} else if len(ms.masks) >= maxMasks {
+		return Mask{}, errTooManyMasks
+	} else {
+		ms.masks = append(ms.masks, m)
+	}
+	return m, ms.saveLocked()
+}
+
+// Delete removes a mask. Returns false if it does not exist.
+func (ms *MaskSet) Delete(id string) (bool, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	i := slices.IndexFunc(ms.masks, func(x Mask) bool { return x.ID == id })
+	if i < 0 {
+		return false, nil
+	}
+	ms.masks = slices.Delete(ms.masks, i, i+1)
+	return true, ms.saveLocked()
+}
+
+// SetOverlay turns drawing the masks on the MJPEG overlay on or off.
+func (ms *MaskSet) SetOverlay(on bool) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.overlay = on
+	return ms.saveLocked()
+}
+
+// OverlayMasks returns the masks to draw, nil while the overlay is off.
+func (ms *MaskSet) OverlayMasks() []Mask {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	if !ms.overlay {
+		return nil
+	}
+	return slices.Clone(ms.masks)
+}
+
+// Filter returns the detections outside every mask, reusing dets when none
+// is masked and a new slice otherwise.
+func (ms *MaskSet) Filter(dets []Detection) []Detection {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	if len(ms.masks) == 0 {
+		return dets
+	}
+	masked := func(d Detection) bool {
+		for i := range ms.masks {
+			if ms.masks[i].suppresses(d) {
+				return true
+			}
+		}
+		return false
+	}
+	if !slices.ContainsFunc(dets, masked) {
+		return dets
+	}
+	var kept []Detection
+	for _, d := range dets {
+		if !masked(d) {
+			kept = append(kept, d)
+		}
+	}
+	ms.suppressed.Add(uint64(len(dets) - len(kept)))
+	return kept
+}
+
+// handleMasks serves GET /api/masks, POST (add a mask) and PUT with
+// {"overlay": bool} to draw the masks on the MJPEG overlay.
+func (s *Server) handleMasks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, s.masks.Status())
+	case http.MethodPost:
+		var m Mask
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": "invalid JSON: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		m.ID = ""
+		saved, err := s.masks.Put(m)
+		if err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, maskErrorStatus(err))
+			return
+		}
+		writeJSONWithStatus(w, saved, http.StatusCreated)
+	case http.MethodPut:
+		var req struct {
+			Overlay *bool `json:"overlay"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Overlay == nil {
+			writeJSONWithStatus(w, map[string]any{"error": `expected {"overlay": true|false}`}, http.StatusBadRequest)
+			return
+		}
+		if err := s.masks.SetOverlay(*req.Overlay); err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, s.masks.Status())
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleMask serves PUT (replace) and DELETE on /api/masks/{id}.
+func (s *Server) handleMask(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/masks/")
+	if id == "" || strings.Contains(id, "/") {
+		http.Error(w, "Invalid mask id", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var m Mask
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": "invalid JSON: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		m.ID = id
+		saved, err := s.masks.Put(m)
+		if err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, maskErrorStatus(err))
+			return
+		}
+		writeJSON(w, saved)
+	case http.MethodDelete:
+		found, err := s.masks.Delete(id)
+		if err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			writeJSONWithStatus(w, map[string]any{"error": "not found"}, http.StatusNotFound)
+			return
+		}
+		writeJSON(w, map[string]any{"deleted": true, "id": id})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// maskErrorStatus maps a Put error: a full set is a conflict, anything
+// else is rejected like an invalid mask.
+func maskErrorStatus(err error) int {
+	if errors.Is(err, errTooManyMasks) {
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
+// Mask outline color on the MJPEG overlay (red, BT.601)
+const (
+	maskOutlineY = 81
+	maskOutlineU = 90
+	maskOutlineV = 240
+)
+
+// drawMaskOutlines draws the edges of masks, 2 px wide, on a w×h NV12 frame.
+func drawMaskOutlines(nv12 []byte, w, h int, masks []Mask) {
+	if len(nv12) < w*h*3/2 {
+		return
+	}
+	plot := func(x, y int) {
+		x, y = x&^1, y&^1 // cover one whole chroma sample
+		if x < 0 || y < 0 || x+1 >= w || y+1 >= h {
+			return
+		}
+		for _, o := range [...]int{y*w + x, y*w + x + 1, (y+1)*w + x, (y+1)*w + x + 1} {
+			nv12[o] = maskOutlineY
+		}
+		uv := w*h + (y/2)*w + x
+		nv12[uv], nv12[uv+1] = maskOutlineU, maskOutlineV
+	}
+	for _, m := range masks {
+		for i := range m.Points {
+			a, b := m.Points[i], m.Points[(i+1)%len(m.Points)]
+			drawLine(a.X*w/detectionRefW, a.Y*h/detectionRefH, b.X*w/detectionRefW, b.Y*h/detectionRefH, plot)
+		}
+	}
+}
+
+// drawLine calls plot for each point of the line from (x0, y0) to (x1, y1)
+// (Bresenham).
+func drawLine(x0, y0, x1, y1 int, plot func(x, y int)) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	err := dx + dy
+	for {
+		plot(x0, y0)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
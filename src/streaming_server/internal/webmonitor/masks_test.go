@@ -0,0 +1,122 @@
+package webmonitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+)
+
+// tvMask is a quadrilateral around a TV in the top-left of the frame.
+var tvMask = Mask{Name: "tv", Points: []MaskPoint{{100, 100}, {500, 80}, {520, 380}, {90, 400}}}
+
+func TestMaskFilter(t *testing.T) {
+	doc := masksDoc.in(kv.NewMemory())
+	ms := NewMaskSet(doc)
+	dets := []Detection{
+		{ClassName: "dog", BBox: BoundingBox{X: 200, Y: 200, W: 100, H: 60}},  // center inside
+		{ClassName: "cat", BBox: BoundingBox{X: 480, Y: 60, W: 100, H: 60}},   // overlaps, center outside
+		{ClassName: "cat", BBox: BoundingBox{X: 800, Y: 400, W: 100, H: 100}}, // elsewhere
+	}
+	if got := ms.Filter(dets); len(got) != 3 {
+		t.Fatalf("empty set dropped %d", 3-len(got))
+	}
+	saved, err := ms.Put(tvMask)
+	if err != nil || saved.ID == "" {
+		t.Fatalf("put: %+v, %v", saved, err)
+	}
+	got := ms.Filter(dets)
+	if len(got) != 2 || got[0].ClassName != "cat" || len(dets) != 3 {
+		t.Fatalf("filtered %+v", got)
+	}
+
+	// Class-limited masks leave other classes alone
+	saved.Classes = []string{"person"}
+	if _, err := ms.Put(saved); err != nil {
+		t.Fatal(err)
+	}
+	if got := ms.Filter(dets); len(got) != 3 {
+		t.Fatalf("person-only mask dropped %+v", got)
+	}
+	if st := ms.Status(); len(st.Masks) != 1 || st.Suppressed != 1 {
+		t.Fatalf("status %+v", st)
+	}
+
+	// Persisted across restarts
+	ms.SetOverlay(true)
+	reloaded := NewMaskSet(doc)
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if m := reloaded.OverlayMasks(); len(m) != 1 || m[0].ID != saved.ID || m[0].Classes[0] != "person" {
+		t.Fatalf("reloaded %+v", m)
+	}
+
+	for _, bad := range []Mask{
+		{Points: tvMask.Points[:2]},
+		{Points: []MaskPoint{{0, 0}, {1281, 0}, {0, 10}}},
+		{Points: tvMask.Points, Classes: []string{""}},
+	} {
+		if _, err := ms.Put(bad); err == nil {
+			t.Errorf("%+v accepted", bad)
+		}
+	}
+}
+
+func TestHandleMasks(t *testing.T) {
+	s := &Server{masks: NewMaskSet(kv.Doc{})}
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if strings.HasPrefix(path, "/api/masks/") {
+			s.handleMask(rec, req)
+		} else {
+			s.handleMasks(rec, req)
+		}
+		return rec
+	}
+
+	body, _ := json.Marshal(tvMask)
+	rec := do(http.MethodPost, "/api/masks", string(body))
+	var m Mask
+	json.Unmarshal(rec.Body.Bytes(), &m)
+	if rec.Code != http.StatusCreated || m.ID == "" {
+		t.Fatalf("POST: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPut, "/api/masks/"+m.ID, `{"points":[{"x":0,"y":0}]}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid PUT: %d", rec.Code)
+	}
+	if rec := do(http.MethodPut, "/api/masks", `{"overlay":true}`); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"overlay":true`) {
+		t.Errorf("overlay: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodDelete, "/api/masks/"+m.ID, ""); rec.Code != http.StatusOK {
+		t.Errorf("DELETE: %d", rec.Code)
+	}
+	if rec := do(http.MethodDelete, "/api/masks/"+m.ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("second DELETE: %d", rec.Code)
+	}
+	for range maxMasks {
+		s.masks.Put(tvMask)
+	}
+	if rec := do(http.MethodPost, "/api/masks", string(body)); rec.Code != http.StatusConflict {
+		t.Errorf("POST to a full set: %d", rec.Code)
+	}
+}
+
+func TestDrawMaskOutlines(t *testing.T) {
+	const w, h = 64, 36 // 1/20 of the detection frame
+	frame := make([]byte, w*h*3/2)
+	drawMaskOutlines(frame, w, h, []Mask{{Points: []MaskPoint{{200, 200}, {1000, 200}, {1000, 600}, {200, 600}}}})
+	if frame[10*w+30] != maskOutlineY || frame[30*w+50] != maskOutlineY {
+		t.Error("edges not drawn")
+	}
+	if frame[20*w+30] != 0 {
+		t.Error("interior drawn")
+	}
+	if uv := w*h + 5*w + 30; frame[uv] != maskOutlineU || frame[uv+1] != maskOutlineV {
+		t.Error("chroma not set")
+	}
+}
@@ -0,0 +1,159 @@
+package webmonitor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	pb "github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/proto"
+)
+
+// MJPEGQualityStatus is the current adaptive MJPEG encode setting as
+// included in status events.
+type MJPEGQualityStatus struct {
+	Quality    int     `json:"quality"`
+	MaxQuality int     `json:"max_quality"`
+	Scale      int     `json:"scale"` // resolution divisor: 1 = full, 2 = half
+	EncodeMs   float64 `json:"encode_ms"`
+	BudgetMs   float64 `json:"budget_ms"`
+}
+
+func (s MJPEGQualityStatus) toProto() *pb.MJPEGQuality {
+	return &pb.MJPEGQuality{
+		Quality:    int32(s.Quality),
+		MaxQuality: int32(s.MaxQuality),
+		Scale:      int32(s.Scale),
+		EncodeMs:   s.EncodeMs,
+		BudgetMs:   s.BudgetMs,
+	}
+}
+
+// MJPEGQualityController lowers the MJPEG encode cost when the JPEG encode
+// of a frame takes longer than Budget, and restores it when load drops. Quality steps down to MinQuality first, then the resolution
+// is halved; recovery goes in reverse order. Decisions are made on the mean
+// over Window frames, and recovery only below half the budget, so the
+// hardware encoder (which is re-created on every quality change) is not
+// reconfigured on each frame.
+type MJPEGQualityController struct {
+	Budget     time.Duration
+	MinQuality int
+	Step       int // quality change per window
+	Window     int // frames averaged per decision
+
+	mu       sync.Mutex
+	max      int
+	quality  int
+	scale    int
+	sum      time.Duration
+	n        int
+	lastMean time.Duration
+	apply    func(quality int) // SetJPEGQuality; replaced in tests
+}
+
+// NewMJPEGQualityController creates a controller starting at maxQuality,
+// full resolution.
+func NewMJPEGQualityController(maxQuality int, budget time.Duration) *MJPEGQualityController {
+	return &MJPEGQualityController{
+		Budget:     budget,
+		MinQuality: 40,
+		Step:       5,
+		Window:     30,
+		max:        maxQuality,
+		quality:    maxQuality,
+		scale:      1,
+		apply:      SetJPEGQuality,
+	}
+}
+
+// Observe records the encode time of one frame.
+func (c *MJPEGQualityController) Observe(d time.Duration) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sum += d
+	c.n++
+	if c.n < c.Window {
+		return
+	}
+	mean := c.sum / time.Duration(c.n)
+	c.sum, c.n, c.lastMean = 0, 0, mean
+
+	quality, scale := c.quality, c.scale
+	switch {
+	case mean > c.Budget:
+		if quality > c.MinQuality {
+			quality = max(quality-c.Step, c.MinQuality)
+		} else {
+			scale = 2
+		}
+	case mean < c.Budget/2:
+		if scale > 1 {
+			scale = 1
+		} else if quality < c.max {
+			quality = min(quality+c.Step, c.max)
+		}
+	}
+	if quality == c.quality && scale == c.scale {
+		return
+	}
+	logger.Info("MJPEGQuality", "Encode %.1fms (budget %.1fms): quality %d -> %d, scale 1/%d -> 1/%d",
+		durationMs(mean), durationMs(c.Budget), c.quality, quality, c.scale, scale)
+	if quality != c.quality {
+		c.apply(quality)
+	}
+	c.quality, c.scale = quality, scale
+}
+
+// Half reports whether frames should be encoded at half resolution.
+func (c *MJPEGQualityController) Half() bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.scale == 2
+}
+
+// Status returns the current setting.
+func (c *MJPEGQualityController) Status() MJPEGQualityStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return MJPEGQualityStatus{
+		Quality:    c.quality,
+		MaxQuality: c.max,
+		Scale:      c.scale,
+		EncodeMs:   durationMs(c.lastMean),
+		BudgetMs:   durationMs(c.Budget),
+	}
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// nv12Half downscales an NV12 frame to half width and height by dropping
+// every other pixel and row, which is cheap enough to pay for itself in
+// encode time. dst must hold (w/2)*(h/2)*3/2 bytes; w and h must be
+// multiples of 4 so the half-size chroma plane stays aligned.
+func nv12Half(dst, src []byte, w, h int) {
+	hw, hh := w/2, h/2
+	for y := range hh {
+		srow := src[2*y*w:]
+		drow := dst[y*hw:]
+		for x := range hw {
+			drow[x] = srow[2*x]
+		}
+	}
+	suv := src[w*h:]
+	duv := dst[hw*hh:]
+	for y := range hh / 2 {
+		srow := suv[2*y*w:]
+		drow := duv[y*hw:]
+		for x := 0; x < hw; x += 2 {
+			drow[x] = srow[2*x]
+			drow[x+1] = srow[2*x+1]
+		}
+	}
+}
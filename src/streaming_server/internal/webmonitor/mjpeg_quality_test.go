@@ -0,0 +1,74 @@
+package webmonitor
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMJPEGQualityController_DegradeAndRecover(t *testing.T) {
+	c := NewMJPEGQualityController(65, 20*time.Millisecond)
+	c.MinQuality, c.Step, c.Window = 55, 5, 2
+	var applied []int
+	c.apply = func(q int) { applied = append(applied, q) }
+	window := func(d time.Duration) {
+		c.Observe(d)
+		c.Observe(d)
+	}
+
+	// Over budget: quality first, then resolution
+	window(30 * time.Millisecond)
+	window(30 * time.Millisecond)
+	if st := c.Status(); st.Quality != 55 || st.Scale != 1 {
+		t.Fatalf("after two slow windows: %+v", st)
+	}
+	window(30 * time.Millisecond)
+	if !c.Half() {
+		t.Fatal("resolution not halved at minimum quality")
+	}
+
+	// Between half the budget and the budget: hold
+	window(15 * time.Millisecond)
+	if st := c.Status(); st.Quality != 55 || st.Scale != 2 || st.EncodeMs != 15 {
+		t.Fatalf("in the hold band: %+v", st)
+	}
+
+	// Fast: resolution first, then quality back up to the configured maximum
+	window(5 * time.Millisecond)
+	if c.Half() {
+		t.Fatal("resolution not restored")
+	}
+	window(5 * time.Millisecond)
+	window(5 * time.Millisecond)
+	window(5 * time.Millisecond)
+	if st := c.Status(); st.Quality != 65 || st.Scale != 1 {
+		t.Fatalf("after recovery: %+v", st)
+	}
+	if want := []int{60, 55, 60, 65}; !slices.Equal(applied, want) {
+		t.Fatalf("applied qualities %v, want %v", applied, want)
+	}
+}
+
+func TestNV12Half(t *testing.T) {
+	const w, h = 8, 4
+	src := make([]byte, w*h*3/2)
+	for i := range w * h {
+		src[i] = byte(i) // luma = row*8 + col
+	}
+	for i := range w * h / 2 {
+		src[w*h+i] = byte(100 + i)
+	}
+	dst := make([]byte, (w/2)*(h/2)*3/2)
+	nv12Half(dst, src, w, h)
+
+	wantY := []byte{0, 2, 4, 6, 16, 18, 20, 22}
+	for i, v := range wantY {
+		if dst[i] != v {
+			t.Fatalf("luma %v, want %v", dst[:len(wantY)], wantY)
+		}
+	}
+	// First chroma row, every other U/V pair
+	if uv := dst[len(wantY):]; uv[0] != 100 || uv[1] != 101 || uv[2] != 104 || uv[3] != 105 {
+		t.Fatalf("chroma %v", uv)
+	}
+}
@@ -0,0 +1,202 @@
+package webmonitor
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MJPEGStreamStats is one MJPEG subscriber as served by /api/streams.
+type MJPEGStreamStats struct {
+	ID            uint64  `json:"id"`
+	Session       string  `json:"session"`
+	RemoteIP      string  `json:"remote_ip"`
+	UserAgent     string  `json:"user_agent,omitempty"`
+	StartedAt     float64 `json:"started_at"`
+	UptimeSec     float64 `json:"uptime_sec"`
+	BytesSent     uint64  `json:"bytes_sent"`
+	FramesSent    uint64  `json:"frames_sent"`
+	FramesSkipped uint64  `json:"frames_skipped"` // held back by the bandwidth cap
+	BitrateKbps   float64 `json:"bitrate_kbps"`   // over the last second
+}
+
+// mjpegStream is the accounting for one /stream response.
+type mjpegStream struct {
+	reg  *MJPEGStreams
+	info MJPEGStreamStats // ID, Session, RemoteIP, UserAgent, StartedAt are fixed
+
+	// guarded by reg.mu
+	windowStart time.Time
+	windowBytes uint64
+}
+
+// MJPEGStreams tracks MJPEG subscribers: bytes and frames per stream, an
+// optional limit of concurrent streams per client IP and an optional cap on
+// the total bandwidth of all streams. Over the cap, frames are skipped
+// (lower fps for everyone) rather than queued.
+type MJPEGStreams struct {
+	MaxPerIP int     // concurrent streams per remote IP (0 = unlimited)
+	MaxKbps  float64 // total MJPEG bandwidth (0 = unlimited)
+
+	mu         sync.Mutex
+	streams    map[uint64]*mjpegStream
+	nextID     uint64
+	tokens     float64 // bytes the cap still allows
+	refilledAt time.Time
+	bytesTotal uint64 // all streams, including closed ones
+}
+
+// NewMJPEGStreams creates an empty registry without limits.
+func NewMJPEGStreams() *MJPEGStreams {
+	return &MJPEGStreams{streams: make(map[uint64]*mjpegStream), nextID: 1}
+}
+
+// remoteIP is the host part of r.RemoteAddr. Viewers behind the relay or a
+// reverse proxy share the proxy's address.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Open registers a stream for r. It returns nil when the client's IP
+// already has MaxPerIP streams; streams of the same session do not count,
+// since a new stream replaces them.
+func (m *MJPEGStreams) Open(r *http.Request, session string) *mjpegStream {
+	ip := remoteIP(r)
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.MaxPerIP > 0 {
+		n := 0
+		for _, st := range m.streams {
+			if st.info.RemoteIP == ip && st.info.Session != session {
+				n++
+			}
+		}
+		if n >= m.MaxPerIP {
+			return nil
+		}
+	}
+	st := &mjpegStream{
+		reg: m,
+		info: MJPEGStreamStats{
+			ID:        m.nextID,
+			Session:   session,
+			RemoteIP:  ip,
+			UserAgent: r.UserAgent(),
+			StartedAt: float64(now.UnixNano()) / 1e9,
+		},
+		windowStart: now,
+	}
+	m.nextID++
+	m.streams[st.info.ID] = st
+	return st
+}
+
+// Close removes the stream.
+func (st *mjpegStream) Close() {
+	st.reg.mu.Lock()
+	delete(st.reg.streams, st.info.ID)
+	st.reg.mu.Unlock()
+}
+
+// allow reports whether a part of n bytes may be sent under the bandwidth
+// cap and counts it as skipped if not. The budget refills continuously, holds
+// at most one second and may go negative, so frames larger than a second of
+// budget still get through at the capped average rate.
+func (st *mjpegStream) allow(n int) bool {
+	if st == nil {
+		return true
+	}
+	m := st.reg
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.MaxKbps <= 0 {
+		return true
+	}
+	rate := m.MaxKbps * 1000 / 8
+	now := time.Now()
+	if m.refilledAt.IsZero() {
+		m.tokens = rate
+	} else {
+		m.tokens = min(m.tokens+now.Sub(m.refilledAt).Seconds()*rate, rate)
+	}
+	m.refilledAt = now
+	if m.tokens <= 0 {
+		st.info.FramesSkipped++
+		return false
+	}
+	m.tokens -= float64(n)
+	return true
+}
+
+// sent records a written part of n bytes.
+func (st *mjpegStream) sent(n int) {
+	if st == nil {
+		return
+	}
+	m := st.reg
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st.info.BytesSent += uint64(n)
+	st.info.FramesSent++
+	m.bytesTotal += uint64(n)
+	st.windowBytes += uint64(n)
+	if elapsed := now.Sub(st.windowStart); elapsed >= time.Second {
+		st.info.BitrateKbps = float64(st.windowBytes*8) / elapsed.Seconds() / 1000
+		st.windowStart, st.windowBytes = now, 0
+	}
+}
+
+// Stats returns the open streams, oldest first.
+func (m *MJPEGStreams) Stats() []MJPEGStreamStats {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]MJPEGStreamStats, 0, len(m.streams))
+	for _, st := range m.streams {
+		info := st.info
+		info.UptimeSec = now.Sub(time.Unix(0, int64(info.StartedAt*1e9))).Seconds()
+		// A stream that stopped receiving frames has no current bitrate
+		if now.Sub(st.windowStart) > 2*time.Second {
+			info.BitrateKbps = 0
+		}
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// BytesTotal returns the bytes sent to all MJPEG streams since start.
+func (m *MJPEGStreams) BytesTotal() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.bytesTotal
+}
+
+// handleStreams serves GET /api/streams.
+func (s *Server) handleStreams(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONWithStatus(w, map[string]any{"error": "method not allowed"}, http.StatusMethodNotAllowed)
+		return
+	}
+	streams := s.mjpegAccounting.Stats()
+	var kbps float64
+	for _, st := range streams {
+		kbps += st.BitrateKbps
+	}
+	writeJSON(w, map[string]any{
+		"mjpeg":       streams,
+		"total_kbps":  kbps,
+		"bytes_total": s.mjpegAccounting.BytesTotal(),
+		"max_kbps":    s.mjpegAccounting.MaxKbps,
+		"max_per_ip":  s.mjpegAccounting.MaxPerIP,
+	})
+}
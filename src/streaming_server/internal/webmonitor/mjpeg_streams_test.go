@@ -0,0 +1,70 @@
+package webmonitor
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMJPEGStreams_PerIPLimit(t *testing.T) {
+	m := NewMJPEGStreams()
+	m.MaxPerIP = 1
+
+	r1 := httptest.NewRequest("GET", "/stream", nil)
+	r1.RemoteAddr = "192.168.1.20:50000"
+	first := m.Open(r1, "tab-a")
+	if first == nil {
+		t.Fatal("first stream refused")
+	}
+	r2 := httptest.NewRequest("GET", "/stream", nil)
+	r2.RemoteAddr = "192.168.1.20:50001"
+	if m.Open(r2, "tab-b") != nil {
+		t.Fatal("second stream from the same IP accepted")
+	}
+	// The same session reconnecting replaces its stream, so it is not refused.
+	replacement := m.Open(r2, "tab-a")
+	if replacement == nil {
+		t.Fatal("reconnect of the same session refused")
+	}
+	r3 := httptest.NewRequest("GET", "/stream", nil)
+	r3.RemoteAddr = "192.168.1.21:50000"
+	if m.Open(r3, "tab-c") == nil {
+		t.Fatal("stream from another IP refused")
+	}
+
+	first.Close()
+	replacement.Close()
+	if m.Open(r2, "tab-b") == nil {
+		t.Fatal("stream refused after the others closed")
+	}
+}
+
+func TestMJPEGStreams_BandwidthCapAndStats(t *testing.T) {
+	s := &Server{mjpegAccounting: NewMJPEGStreams()}
+	s.mjpegAccounting.MaxKbps = 8 // 1000 bytes/s
+	st := s.mjpegAccounting.Open(httptest.NewRequest("GET", "/stream", nil), "tab-a")
+
+	// A frame larger than the budget still goes out; the debt then holds
+	// back the following frames.
+	for range 3 {
+		if st.allow(4000) {
+			st.sent(4000)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	s.handleStreams(w, httptest.NewRequest("GET", "/api/streams", nil))
+	var body struct {
+		MJPEG      []MJPEGStreamStats `json:"mjpeg"`
+		BytesTotal uint64             `json:"bytes_total"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.MJPEG) != 1 || body.BytesTotal != 4000 {
+		t.Fatalf("body %+v", body)
+	}
+	if got := body.MJPEG[0]; got.FramesSent != 1 || got.FramesSkipped != 2 || got.BytesSent != 4000 || got.RemoteIP != "192.0.2.1" {
+		t.Fatalf("stream %+v", got)
+	}
+}
@@ -18,16 +18,43 @@ type Monitor struct {
 	latestDetection   *DetectionResult
 	lastDetectionSent int
 	shm               *shmReader
+	detections        DetectionSource
+
+	// HistoryDepth is the number of recent non-empty results kept for
+	// /api/status (detection_history).
+	HistoryDepth int
+
+	// Filter, if set, drops unwanted detections from every new result
+	// before the overlay, status or detection events see it.
+	Filter func([]Detection) []Detection
+
+	// Detector is the input results without a frame of their own were
+	// measured in (-detector-input); their boxes are mapped from it to the
+	// reference frame. NormalizeBBoxes adds each box's BBoxNorm.
+	Detector        DetectorInput
+	NormalizeBBoxes bool
 }
 
 // NewMonitor creates a Monitor with the given target FPS and shared memory reader.
 func NewMonitor(targetFPS int, shm *shmReader) *Monitor {
-	return &Monitor{
+	m := &Monitor{
 		startTime:    time.Now(),
 		targetFPS:    targetFPS,
 		frameCounter: 0,
 		shm:          shm,
+		HistoryDepth: 8,
 	}
+	if shm != nil {
+		m.detections = shm
+	}
+	return m
+}
+
+// SetDetectionSource replaces the shared-memory detection input.
+func (m *Monitor) SetDetectionSource(src DetectionSource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.detections = src
 }
 
 // Snapshot returns the current monitor and shared memory stats.
@@ -62,6 +89,40 @@ func (m *Monitor) Snapshot() (MonitorStats, SharedMemoryStats, *DetectionResult,
 	return monitorStats, shmStats, m.latestDetection, historyCopy
 }
 
+// Detections returns the latest detection and the recent non-empty ones.
+func (m *Monitor) Detections() (*DetectionResult, []DetectionResult) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	history := make([]DetectionResult, len(m.detectionHistory))
+	copy(history, m.detectionHistory)
+	return m.latestDetection, history
+}
+
+// RestoreDetections seeds the detection state saved by a previous process.
+// Live SHM data replaces it as soon as the detector publishes.
+func (m *Monitor) RestoreDetections(latest *DetectionResult, history []DetectionResult) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.latestDetection == nil && latest != nil {
+		m.latestDetection = latest
+		m.lastDetectionSent = latest.Version // not a new result; don't broadcast it
+	}
+	if len(m.detectionHistory) == 0 {
+		m.detectionHistory = history[:min(len(history), m.HistoryDepth)]
+	}
+}
+
+// DetectionVersion returns the latest detection version published to SHM.
+func (m *Monitor) DetectionVersion() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.refreshFromSharedMemoryLocked()
+	return m.detectionVersion
+}
+
 // NextDetectionEvent returns a detection event for SSE.
 func (m *Monitor) NextDetectionEvent() DetectionEvent {
 	m.mu.Lock()
@@ -81,6 +142,7 @@ func (m *Monitor) NextDetectionEvent() DetectionEvent {
 		event.FrameNumber = m.latestDetection.FrameNumber
 		event.Timestamp = m.latestDetection.Timestamp
 		event.Detections = m.latestDetection.Detections
+		event.Frame = m.latestDetection.Frame
 		m.lastDetectionSent = m.latestDetection.Version
 	}
 
@@ -95,38 +157,62 @@ func (m *Monitor) UpdateDetection(result DetectionResult) {
 	m.detectionVersion++
 	result.Version = m.detectionVersion
 	result.NumDetections = len(result.Detections)
+	m.prepare(&result)
 	m.latestDetection = &result
 	if result.NumDetections > 0 {
-		m.detectionHistory = append([]DetectionResult{result}, m.detectionHistory...)
-		if len(m.detectionHistory) > 8 {
-			m.detectionHistory = m.detectionHistory[:8]
-		}
+		m.pushHistoryLocked(result)
 	}
 }
 
 func (m *Monitor) refreshFromSharedMemoryLocked() {
 	if m.shm == nil {
 		m.updateSyntheticStatsLocked()
-		return
-	}
-
-	if stats, ok := m.shm.Stats(); ok {
+	} else if stats, ok := m.shm.Stats(); ok {
 		m.frameCounter = stats.TotalFramesWritten
-		m.detectionVersion = stats.DetectionVersion
+		if _, fromSHM := m.detections.(*shmReader); fromSHM {
+			m.detectionVersion = stats.DetectionVersion
+		}
 	}
 
-	if detection, ok := m.shm.LatestDetection(); ok && detection != nil {
+	if m.detections == nil {
+		return
+	}
+	if detection, ok := m.detections.LatestDetection(); ok && detection != nil {
+		m.prepare(detection)
+		if m.Filter != nil {
+			detection.Detections = m.Filter(detection.Detections)
+			detection.NumDetections = len(detection.Detections)
+		}
 		m.latestDetection = detection
 		m.detectionVersion = detection.Version
 		if detection.NumDetections > 0 {
-			m.detectionHistory = append([]DetectionResult{*detection}, m.detectionHistory...)
-			if len(m.detectionHistory) > 8 {
-				m.detectionHistory = m.detectionHistory[:8]
-			}
+			m.pushHistoryLocked(*detection)
+		}
+	}
+}
+
+// prepare brings a new result's boxes into the reference frame, before
+// anything measures them against masks or zones.
+func (m *Monitor) prepare(det *DetectionResult) {
+	if !det.Frame.mapped() {
+		mapDetections(det, m.Detector)
+	}
+	if m.NormalizeBBoxes {
+		for i := range det.Detections {
+			n := normBBox(det.Detections[i].BBox)
+			det.Detections[i].BBoxNorm = &n
 		}
 	}
 }
 
+// pushHistoryLocked prepends det to the recent history, keeping HistoryDepth entries.
+func (m *Monitor) pushHistoryLocked(det DetectionResult) {
+	m.detectionHistory = append([]DetectionResult{det}, m.detectionHistory...)
+	if len(m.detectionHistory) > m.HistoryDepth {
+		m.detectionHistory = m.detectionHistory[:m.HistoryDepth]
+	}
+}
+
 func (m *Monitor) updateSyntheticStatsLocked() {
 	elapsed := time.Since(m.startTime).Seconds()
 	framesProcessed := int(elapsed * float64(m.targetFPS))
@@ -0,0 +1,228 @@
+package webmonitor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+// Motion grid resolution. The NV12 Y plane is averaged into gridW x gridH
+// cells before differencing, so cost is independent of the frame size.
+const (
+	motionGridW = 64
+	motionGridH = 36
+
+	// Detection coordinate space (same as the YOLO daemon output).
+	detectionRefW = 1280
+	detectionRefH = 720
+
+	// DetectionSourceMotion marks results synthesized by the motion fallback.
+	DetectionSourceMotion = "motion"
+)
+
+// MotionDetector is a frame-differencing fallback trigger used while the
+// detection daemon is not publishing results. It emits "motion" detections
+// (in 1280x720 detection coordinates) through the same publish path as YOLO.
+type MotionDetector struct {
+	src     frameSource
+	publish func(*DetectionResult)
+
+	mu                sync.Mutex
+	prevGrid          []uint8
+	lastVersionChange time.Time
+	active            bool
+	stop              chan struct{}
+	done              chan struct{}
+
+	// Configurable parameters
+	Sensitivity int           // per-cell mean luma delta (0-255) counted as changed
+	MinArea     float64       // fraction of changed cells (0-1) required to trigger
+	StaleAfter  time.Duration // daemon considered down after this long without a new version
+	Interval    time.Duration // sampling interval
+	Cooldown    time.Duration // minimum gap between emitted motion events
+	lastEmit    time.Time
+}
+
+// NewMotionDetector creates a motion detector reading frames from src and
+// emitting results via publish.
+func NewMotionDetector(src frameSource, publish func(*DetectionResult)) *MotionDetector {
+	return &MotionDetector{
+		src:         src,
+		publish:     publish,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+		Sensitivity: 20,
+		MinArea:     0.01,
+		StaleAfter:  10 * time.Second,
+		Interval:    500 * time.Millisecond,
+		Cooldown:    1 * time.Second,
+	}
+}
+
+// Start begins the sampling loop.
+func (md *MotionDetector) Start() {
+	md.mu.Lock()
+	md.lastVersionChange = time.Now()
+	md.mu.Unlock()
+	go md.run()
+}
+
+// Stop halts the sampling loop and waits for it to exit.
+func (md *MotionDetector) Stop() {
+	close(md.stop)
+	<-md.done
+}
+
+// Active reports whether the fallback is currently generating events.
+func (md *MotionDetector) Active() bool {
+	md.mu.Lock()
+	defer md.mu.Unlock()
+	return md.active
+}
+
+func (md *MotionDetector) run() {
+	defer close(md.done)
+	ticker := time.NewTicker(md.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-md.stop:
+			return
+		case now := <-ticker.C:
+			if det := md.tick(now); det != nil && md.publish != nil {
+				md.publish(det)
+			}
+		}
+	}
+}
+
+// tick samples one frame and returns a motion result to publish, or nil.
+func (md *MotionDetector) tick(now time.Time) *DetectionResult {
+	md.mu.Lock()
+	defer md.mu.Unlock()
+
+	// A new detection version means the daemon is alive; stay dormant.
+	if _, ok := md.src.LatestDetection(); ok {
+		md.lastVersionChange = now
+	}
+	stale := now.Sub(md.lastVersionChange) > md.StaleAfter
+	if stale != md.active {
+		md.active = stale
+		md.prevGrid = nil
+		if stale {
+			logger.Warn("Motion", "Detection daemon stale for %v, motion fallback active", md.StaleAfter)
+		} else {
+			logger.Info("Motion", "Detection daemon resumed, motion fallback dormant")
+		}
+	}
+	if !md.active {
+		return nil
+	}
+
+	frame, ok := md.src.LatestNV12()
+	if !ok {
+		return nil
+	}
+	grid := lumaGrid(frame.Data, frame.Width, frame.Height)
+	if grid == nil {
+		return nil
+	}
+	prev := md.prevGrid
+	md.prevGrid = grid
+	if prev == nil {
+		return nil
+	}
+
+	bbox, area, ok := diffGrid(prev, grid, md.Sensitivity)
+	if !ok || area < md.MinArea {
+		return nil
+	}
+	if !md.lastEmit.IsZero() && now.Sub(md.lastEmit) < md.Cooldown {
+		return nil
+	}
+	md.lastEmit = now
+
+	confidence := area * 4
+	if confidence > 1 {
+		confidence = 1
+	}
+	return &DetectionResult{
+		Timestamp:     float64(now.UnixNano()) / 1e9,
+		NumDetections: 1,
+		Source:        DetectionSourceMotion,
+		Detections: []Detection{{
+			ClassName:  "motion",
+			Confidence: confidence,
+			BBox:       bbox,
+		}},
+		Frame: &referenceFrame, // grid cells are mapped to the reference already
+	}
+}
+
+// lumaGrid averages the NV12 Y plane into a motionGridW x motionGridH grid.
+// Samples every other pixel in each direction to halve the cost.
+func lumaGrid(nv12 []byte, width, height int) []uint8 {
+	if width < motionGridW || height < motionGridH || len(nv12) < width*height {
+		return nil
+	}
+	grid := make([]uint8, motionGridW*motionGridH)
+	for gy := 0; gy < motionGridH; gy++ {
+		y0 := gy * height / motionGridH
+		y1 := (gy + 1) * height / motionGridH
+		for gx := 0; gx < motionGridW; gx++ {
+			x0 := gx * width / motionGridW
+			x1 := (gx + 1) * width / motionGridW
+			sum, n := 0, 0
+			for y := y0; y < y1; y += 2 {
+				row := nv12[y*width:]
+				for x := x0; x < x1; x += 2 {
+					sum += int(row[x])
+					n++
+				}
+			}
+			if n > 0 {
+				grid[gy*motionGridW+gx] = uint8(sum / n)
+			}
+		}
+	}
+	return grid
+}
+
+// diffGrid compares two luma grids and returns the union bbox of changed cells
+// (in detection coordinates) and the changed-cell fraction.
+func diffGrid(prev, cur []uint8, sensitivity int) (BoundingBox, float64, bool) {
+	if len(prev) != len(cur) || len(cur) != motionGridW*motionGridH {
+		return BoundingBox{}, 0, false
+	}
+	minX, minY, maxX, maxY := motionGridW, motionGridH, -1, -1
+	changed := 0
+	for gy := 0; gy < motionGridH; gy++ {
+		for gx := 0; gx < motionGridW; gx++ {
+			i := gy*motionGridW + gx
+			d := int(cur[i]) - int(prev[i])
+			if d < 0 {
+				d = -d
+			}
+			if d < sensitivity {
+				continue
+			}
+			changed++
+			minX = min(minX, gx)
+			minY = min(minY, gy)
+			maxX = max(maxX, gx)
+			maxY = max(maxY, gy)
+		}
+	}
+	if changed == 0 {
+		return BoundingBox{}, 0, false
+	}
+	bbox := BoundingBox{
+		X: minX * detectionRefW / motionGridW,
+		Y: minY * detectionRefH / motionGridH,
+		W: (maxX - minX + 1) * detectionRefW / motionGridW,
+		H: (maxY - minY + 1) * detectionRefH / motionGridH,
+	}
+	return bbox, float64(changed) / float64(len(cur)), true
+}
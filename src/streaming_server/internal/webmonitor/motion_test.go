@@ -0,0 +1,119 @@
+package webmonitor
+
+import (
+	"testing"
+	"time"
+)
+
+// motionFrameSource is a minimal frameSource for motion tests.
+type motionFrameSource struct {
+	det   *DetectionResult
+	frame *NV12Frame
+}
+
+func (m *motionFrameSource) LatestDetection() (*DetectionResult, bool) {
+	det := m.det
+	m.det = nil
+	return det, det != nil
+}
+
+func (m *motionFrameSource) LatestNV12() (*NV12Frame, bool) {
+	return m.frame, m.frame != nil
+}
+
+func flatNV12(w, h int, luma byte) []byte {
+	data := make([]byte, w*h*3/2)
+	for i := range data {
+		data[i] = 128
+	}
+	for i := 0; i < w*h; i++ {
+		data[i] = luma
+	}
+	return data
+}
+
+func TestDiffGridBBox(t *testing.T) {
+	prev := make([]uint8, motionGridW*motionGridH)
+	cur := make([]uint8, motionGridW*motionGridH)
+	// Change a 4x2 block at cells (10..13, 5..6)
+	for gy := 5; gy <= 6; gy++ {
+		for gx := 10; gx <= 13; gx++ {
+			cur[gy*motionGridW+gx] = 100
+		}
+	}
+
+	bbox, area, ok := diffGrid(prev, cur, 20)
+	if !ok {
+		t.Fatal("expected motion")
+	}
+	want := BoundingBox{X: 200, Y: 100, W: 80, H: 40}
+	if bbox != want {
+		t.Errorf("bbox = %+v, want %+v", bbox, want)
+	}
+	if wantArea := 8.0 / float64(motionGridW*motionGridH); area != wantArea {
+		t.Errorf("area = %v, want %v", area, wantArea)
+	}
+
+	if _, _, ok := diffGrid(prev, cur, 200); ok {
+		t.Error("expected no motion above sensitivity threshold")
+	}
+}
+
+func TestMotionDetectorDormantWhileDaemonAlive(t *testing.T) {
+	src := &motionFrameSource{frame: &NV12Frame{Data: flatNV12(640, 360, 50), Width: 640, Height: 360}}
+	md := NewMotionDetector(src, nil)
+	start := time.Now()
+	md.lastVersionChange = start
+
+	for i := 1; i <= 40; i++ {
+		src.det = &DetectionResult{Version: i}
+		src.frame.Data = flatNV12(640, 360, byte(50+i*4))
+		if det := md.tick(start.Add(time.Duration(i) * time.Second)); det != nil {
+			t.Fatalf("tick %d: unexpected motion event while daemon alive", i)
+		}
+	}
+	if md.Active() {
+		t.Error("fallback should be dormant")
+	}
+}
+
+func TestMotionDetectorFallback(t *testing.T) {
+	src := &motionFrameSource{frame: &NV12Frame{Data: flatNV12(640, 360, 50), Width: 640, Height: 360}}
+	md := NewMotionDetector(src, nil)
+	start := time.Now()
+	md.lastVersionChange = start
+
+	// Stale: first tick primes the reference grid
+	now := start.Add(md.StaleAfter + time.Second)
+	if det := md.tick(now); det != nil {
+		t.Fatal("first active tick should only prime the grid")
+	}
+	if !md.Active() {
+		t.Fatal("fallback should be active after StaleAfter")
+	}
+
+	src.frame = &NV12Frame{Data: flatNV12(640, 360, 200), Width: 640, Height: 360}
+	now = now.Add(md.Interval)
+	det := md.tick(now)
+	if det == nil {
+		t.Fatal("expected motion event")
+	}
+	if det.Source != DetectionSourceMotion || len(det.Detections) != 1 || det.Detections[0].ClassName != "motion" {
+		t.Errorf("unexpected result: %+v", det)
+	}
+	if bb := det.Detections[0].BBox; bb.W != detectionRefW || bb.H != detectionRefH {
+		t.Errorf("full-frame change bbox = %+v", bb)
+	}
+
+	// Within cooldown: suppressed
+	src.frame = &NV12Frame{Data: flatNV12(640, 360, 50), Width: 640, Height: 360}
+	if det := md.tick(now.Add(md.Interval)); det != nil {
+		t.Error("expected cooldown to suppress event")
+	}
+
+	// Daemon resumes: dormant again
+	src.det = &DetectionResult{Version: 1}
+	if det := md.tick(now.Add(5 * time.Second)); det != nil || md.Active() {
+		t.Error("fallback should go dormant when daemon resumes")
+	}
+}
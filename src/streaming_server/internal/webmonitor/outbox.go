@@ -0,0 +1,76 @@
+package webmonitor
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/outbox"
+)
+
+// outboxDeadListed bounds the dead letters listed by GET /api/outbox.
+const outboxDeadListed = 50
+
+// requireOutbox writes 503 when the outbound queue is disabled.
+func (s *Server) requireOutbox(w http.ResponseWriter) bool {
+	if s.outbox == nil {
+		writeJSONWithStatus(w, map[string]any{"error": "outbound queue disabled"}, http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// handleOutbox serves GET /api/outbox: queue depths, counters and the most
+// recent dead letters.
+func (s *Server) handleOutbox(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.requireOutbox(w) {
+		return
+	}
+	writeJSON(w, struct {
+		outbox.Stats
+		DeadLetters []outbox.Job `json:"dead_letters"`
+	}{s.outbox.Stats(), s.outbox.DeadLetters(outboxDeadListed)})
+}
+
+// handleOutboxFlush serves POST /api/outbox/flush: retry every pending job
+// now instead of after its backoff, e.g. once the internet is back. With
+// {"dead": true} the dead letters are retried too.
+func (s *Server) handleOutboxFlush(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.requireOutbox(w) {
+		return
+	}
+	var req struct {
+		Dead bool `json:"dead"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": "invalid JSON: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	n, err := s.outbox.Flush(req.Dead)
+	if err != nil {
+		writeJSONWithStatus(w, map[string]any{"error": err.Error(), "flushed": n}, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]any{"flushed": n})
+}
+
+// handleOutboxDead serves DELETE /api/outbox/dead: discard the dead letters.
+func (s *Server) handleOutboxDead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.requireOutbox(w) {
+		return
+	}
+	writeJSON(w, map[string]any{"purged": s.outbox.PurgeDead()})
+}
@@ -0,0 +1,469 @@
+package webmonitor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+// maxPetSamples bounds the reference embeddings kept per enrolled pet; the
+// oldest sample is dropped when a new one is enrolled.
+const maxPetSamples = 20
+
+// PetProfile is an enrolled pet with its reference embeddings.
+type PetProfile struct {
+	ID         string      `json:"id"`
+	Name       string      `json:"name"`
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+// PetProfileInfo is a profile as returned by /api/pets (embeddings omitted).
+type PetProfileInfo struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Samples int    `json:"samples"`
+}
+
+func (p *PetProfile) info() PetProfileInfo {
+	return PetProfileInfo{ID: p.ID, Name: p.Name, Samples: len(p.Embeddings)}
+}
+
+// petTrack remembers who was identified at a box, so results between
+// embedding requests inherit the identity by overlap.
+type petTrack struct {
+	bbox  BoundingBox
+	petID string
+	seen  time.Time
+}
+
+// PetIdentifier tells enrolled pets apart. Pet boxes are cropped from the
+// NV12 frame and sent as JPEGs to an embedding service (POST image/jpeg,
+// answering {"embedding": [...]}); the embedding is matched against the
+// enrolled profiles by cosine similarity.
+//
+// Embedding requests run in the background at most once per Interval, so
+// the detection path never waits on the service: Annotate labels each pet
+// detection from the identity last seen at an overlapping box.
+type PetIdentifier struct {
+	Interval  time.Duration // minimum gap between embedding requests
+	Threshold float64       // cosine similarity required to assign a pet
+	TrackTTL  time.Duration // how long an identity sticks to a box without a new match
+
+	url    string
+	src    nv12Source
+	doc    kv.Doc
+	client *http.Client
+
+	mu          sync.Mutex
+	profiles    []PetProfile
+	tracks      []petTrack
+	last        *DetectionResult // latest result with a pet, for enrollment
+	lastRequest time.Time
+	inFlight    bool
+}
+
+// NewPetIdentifier creates an identifier using the embedding service at
+// url, with profiles persisted to doc (the zero Doc = in memory).
+func NewPetIdentifier(url string, src nv12Source, doc kv.Doc) *PetIdentifier {
+	return &PetIdentifier{
+		Interval:  time.Second,
+		Threshold: 0.8,
+		TrackTTL:  5 * time.Second,
+		url:       url,
+		src:       src,
+		doc:       doc,
+		client:    &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Load replaces the profiles with the persisted ones; without a document
+// there are none.
+func (pi *PetIdentifier) Load() error {
+	var profiles []PetProfile
+	if _, err := pi.doc.Load(&profiles); err != nil {
+		return err
+	}
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+	pi.profiles = profiles
+	return nil
+}
+
+// saveLocked persists the profiles.
+func (pi *PetIdentifier) saveLocked() error {
+	return pi.doc.Save(pi.profiles)
+}
+
+// Profiles lists the enrolled pets.
+func (pi *PetIdentifier) Profiles() []PetProfileInfo {
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+	out := make([]PetProfileInfo, 0, len(pi.profiles))
+	for i := range pi.profiles {
+		out = append(out, pi.profiles[i].info())
+	}
+	return out
+}
+
+// Create adds a pet without samples, or renames an existing one.
+func (pi *PetIdentifier) Create(id, name string) (PetProfileInfo, error) {
+	id = strings.Trim(peerIDReplacer.ReplaceAllString(strings.ToLower(id), "-"), "-")
+	if !peerIDPattern.MatchString(id) {
+		return PetProfileInfo{}, fmt.Errorf("invalid pet id %q (lowercase letters, digits, - and _)", id)
+	}
+	if name == "" {
+		name = id
+	}
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+	for i := range pi.profiles {
+		if pi.profiles[i].ID == id {
+			pi.profiles[i].Name = name
+			return pi.profiles[i].info(), pi.saveLocked()
+		}
+	}
+	pi.profiles = append(pi.profiles, PetProfile{ID: id, Name: name})
+	return pi.profiles[len(pi.profiles)-1].info(), pi.saveLocked()
+}
+
+// Delete removes a pet. Returns false if it does not exist.
+func (pi *PetIdentifier) Delete(id string) (bool, error) {
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+	for i := range pi.profiles {
+		if pi.profiles[i].ID == id {
+			pi.profiles = append(pi.profiles[:i], pi.profiles[i+1:]...)
+			return true, pi.saveLocked()
+		}
+	}
+	return false, nil
+}
+
+// Enroll adds a reference sample to pet id: embedding if given, otherwise
+// the embedding of bbox (detection coordinates) in the current frame, or of
+// the most confident pet in the latest detection when bbox is nil.
+func (pi *PetIdentifier) Enroll(id string, bbox *BoundingBox, embedding []float32) (PetProfileInfo, error) {
+	if embedding == nil {
+		if bbox == nil {
+			pi.mu.Lock()
+			last := pi.last
+			pi.mu.Unlock()
+			best := -1.0
+			if last != nil {
+				for _, d := range last.Detections {
+					if isPetClass(d.ClassName) && d.Confidence > best {
+						b := d.BBox
+						bbox, best = &b, d.Confidence
+					}
+				}
+			}
+			if bbox == nil {
+				return PetProfileInfo{}, errors.New("no pet in view; pass a bbox")
+			}
+		}
+		frame, ok := pi.src.LatestNV12()
+		if !ok {
+			return PetProfileInfo{}, errors.New("no frame available")
+		}
+		var err error
+		if embedding, err = pi.embedBox(frame, *bbox); err != nil {
+			return PetProfileInfo{}, err
+		}
+	}
+
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+	for i := range pi.profiles {
+		p := &pi.profiles[i]
+		if p.ID != id {
+			continue
+		}
+		if len(p.Embeddings) > 0 && len(p.Embeddings[0]) != len(embedding) {
+			return PetProfileInfo{}, fmt.Errorf("embedding has %d dimensions, profile has %d", len(embedding), len(p.Embeddings[0]))
+		}
+		p.Embeddings = append(p.Embeddings, embedding)
+		if len(p.Embeddings) > maxPetSamples {
+			p.Embeddings = p.Embeddings[len(p.Embeddings)-maxPetSamples:]
+		}
+		return p.info(), pi.saveLocked()
+	}
+	return PetProfileInfo{}, errPetNotFound
+}
+
+var errPetNotFound = errors.New("pet not found")
+
+// Annotate sets PetID on pet detections from recent identifications and
+// schedules a new identification when one is due. It never blocks on the
+// embedding service.
+func (pi *PetIdentifier) Annotate(det *DetectionResult) {
+	if det == nil || !hasPet(det) {
+		return
+	}
+	now := time.Now()
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+
+	pi.last = det
+	live := pi.tracks[:0]
+	for _, t := range pi.tracks {
+		if now.Sub(t.seen) <= pi.TrackTTL {
+			live = append(live, t)
+		}
+	}
+	pi.tracks = live
+	for i := range det.Detections {
+		d := &det.Detections[i]
+		if !isPetClass(d.ClassName) {
+			continue
+		}
+		if t := pi.matchTrackLocked(d.BBox); t != nil {
+			d.PetID = t.petID
+			t.bbox, t.seen = d.BBox, now
+		}
+	}
+
+	if pi.inFlight || now.Sub(pi.lastRequest) < pi.Interval || len(pi.profiles) == 0 {
+		return
+	}
+	frame, ok := pi.src.LatestNV12()
+	if !ok {
+		return
+	}
+	pi.inFlight = true
+	pi.lastRequest = now
+	boxes := make([]BoundingBox, 0, len(det.Detections))
+	for _, d := range det.Detections {
+		if isPetClass(d.ClassName) {
+			boxes = append(boxes, d.BBox)
+		}
+	}
+	go pi.identify(frame, boxes)
+}
+
+// matchTrackLocked returns the track overlapping bbox the most (IoU ≥ 0.3).
+func (pi *PetIdentifier) matchTrackLocked(bbox BoundingBox) *petTrack {
+	var best *petTrack
+	bestIoU := 0.3
+	for i := range pi.tracks {
+		if iou := bboxIoU(pi.tracks[i].bbox, bbox); iou >= bestIoU {
+			best, bestIoU = &pi.tracks[i], iou
+		}
+	}
+	return best
+}
+
+// identify embeds each box and records the matching pet as a track.
+func (pi *PetIdentifier) identify(frame *NV12Frame, boxes []BoundingBox) {
+	defer func() {
+		pi.mu.Lock()
+		pi.inFlight = false
+		pi.mu.Unlock()
+	}()
+	for _, bbox := range boxes {
+		emb, err := pi.embedBox(frame, bbox)
+		if err != nil {
+			logger.Debug("PetID", "Embedding failed: %v", err)
+			return
+		}
+		pi.mu.Lock()
+		id, sim := pi.matchLocked(emb)
+		if t := pi.matchTrackLocked(bbox); t != nil {
+			*t = petTrack{bbox: bbox, petID: id, seen: time.Now()}
+		} else if id != "" {
+			pi.tracks = append(pi.tracks, petTrack{bbox: bbox, petID: id, seen: time.Now()})
+		}
+		pi.mu.Unlock()
+		logger.Debug("PetID", "Box %+v → %q (similarity %.2f)", bbox, id, sim)
+	}
+}
+
+// matchLocked returns the pet whose closest sample is most similar to emb,
+// or "" when none reaches Threshold.
+func (pi *PetIdentifier) matchLocked(emb []float32) (string, float64) {
+	bestID, best := "", -1.0
+	for _, p := range pi.profiles {
+		for _, ref := range p.Embeddings {
+			if sim := cosineSimilarity(emb, ref); sim > best {
+				bestID, best = p.ID, sim
+			}
+		}
+	}
+	if best < pi.Threshold {
+		return "", best
+	}
+	return bestID, best
+}
+
+// embedBox crops bbox (detection coordinates) from frame and asks the
+// embedding service for its vector.
+func (pi *PetIdentifier) embedBox(frame *NV12Frame, bbox BoundingBox) ([]float32, error) {
+	img := cropNV12(frame, bbox)
+	if img == nil {
+		return nil, fmt.Errorf("box %+v is outside the %dx%d frame", bbox, frame.Width, frame.Height)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		return nil, err
+	}
+	resp, err := pi.client.Post(pi.url, "image/jpeg", &buf)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embedding service: HTTP %d", resp.StatusCode)
+	}
+	var out struct {
+		Embedding []float32 `json:"embedding"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&out); err != nil {
+		return nil, fmt.Errorf("embedding service: %w", err)
+	}
+	if len(out.Embedding) == 0 {
+		return nil, errors.New("embedding service returned an empty embedding")
+	}
+	return out.Embedding, nil
+}
+
+// cropNV12 cuts bbox, given in 1280x720 detection coordinates, out of an
+// NV12 frame of any size. The crop is aligned to even pixels for 4:2:0.
+func cropNV12(frame *NV12Frame, bbox BoundingBox) *image.YCbCr {
+	w, h := frame.Width, frame.Height
+	if w <= 0 || h <= 0 || len(frame.Data) < w*h*3/2 {
+		return nil
+	}
+	x0 := max(0, bbox.X*w/detectionRefW) &^ 1
+	y0 := max(0, bbox.Y*h/detectionRefH) &^ 1
+	x1 := min(w, (bbox.X+bbox.W)*w/detectionRefW) &^ 1
+	y1 := min(h, (bbox.Y+bbox.H)*h/detectionRefH) &^ 1
+	if x1-x0 < 2 || y1-y0 < 2 {
+		return nil
+	}
+	img := image.NewYCbCr(image.Rect(0, 0, x1-x0, y1-y0), image.YCbCrSubsampleRatio420)
+	for y := y0; y < y1; y++ {
+		copy(img.Y[(y-y0)*img.YStride:], frame.Data[y*w+x0:y*w+x1])
+	}
+	uv := frame.Data[w*h:]
+	for cy := y0 / 2; cy < y1/2; cy++ {
+		row := uv[cy*w:]
+		for cx := x0 / 2; cx < x1/2; cx++ {
+			i := (cy-y0/2)*img.CStride + cx - x0/2
+			img.Cb[i] = row[cx*2]
+			img.Cr[i] = row[cx*2+1]
+		}
+	}
+	return img
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return -1
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return -1
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+func bboxIoU(a, b BoundingBox) float64 {
+	inter := intersectionArea(a, b)
+	union := a.W*a.H + b.W*b.H - inter
+	if union <= 0 {
+		return 0
+	}
+	return float64(inter) / float64(union)
+}
+
+// handlePets serves GET/POST /api/pets.
+func (s *Server) handlePets(w http.ResponseWriter, r *http.Request) {
+	if s.petID == nil {
+		writeJSONWithStatus(w, map[string]any{"error": "pet identification not configured"}, http.StatusServiceUnavailable)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, map[string]any{"pets": s.petID.Profiles()})
+	case http.MethodPost:
+		var req struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": "invalid JSON: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if req.ID == "" {
+			req.ID = req.Name
+		}
+		info, err := s.petID.Create(req.ID, req.Name)
+		if err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+		writeJSONWithStatus(w, info, http.StatusCreated)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handlePet serves DELETE /api/pets/{id} and POST /api/pets/{id}/enroll.
+func (s *Server) handlePet(w http.ResponseWriter, r *http.Request) {
+	if s.petID == nil {
+		writeJSONWithStatus(w, map[string]any{"error": "pet identification not configured"}, http.StatusServiceUnavailable)
+		return
+	}
+	id, sub, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/pets/"), "/")
+	switch {
+	case sub == "" && r.Method == http.MethodDelete:
+		ok, err := s.petID.Delete(id)
+		if err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			writeJSONWithStatus(w, map[string]any{"error": "not found"}, http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case sub == "enroll" && r.Method == http.MethodPost:
+		// Optional body: {"bbox": {...}} or {"embedding": [...]}; empty
+		// enrolls the most confident pet currently in view.
+		var req struct {
+			BBox      *BoundingBox `json:"bbox"`
+			Embedding []float32    `json:"embedding"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			writeJSONWithStatus(w, map[string]any{"error": "invalid JSON: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		info, err := s.petID.Enroll(id, req.BBox, req.Embedding)
+		switch {
+		case errors.Is(err, errPetNotFound):
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusNotFound)
+		case err != nil:
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusUnprocessableEntity)
+		default:
+			writeJSON(w, info)
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,88 @@
+package webmonitor
+
+import (
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+)
+
+func TestPetIdentifier_AnnotatesFromEmbedding(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		img, err := jpeg.Decode(r.Body)
+		if err != nil {
+			t.Errorf("decode crop: %v", err)
+			return
+		}
+		// bbox 640x360 in detection space = 320x180 in a 640x360 frame
+		if b := img.Bounds(); b.Dx() != 320 || b.Dy() != 180 {
+			t.Errorf("crop %v, want 320x180", b)
+		}
+		w.Write([]byte(`{"embedding":[0.9,0.1]}`))
+	}))
+	defer srv.Close()
+
+	doc := petsDoc.in(kv.NewMemory())
+	frame := &NV12Frame{Data: make([]byte, 640*360*3/2), Width: 640, Height: 360}
+	pi := NewPetIdentifier(srv.URL, fakeNV12{frame}, doc)
+	for id, emb := range map[string][]float32{"mike": {1, 0}, "chatora": {0, 1}} {
+		if _, err := pi.Create(id, ""); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := pi.Enroll(id, nil, emb); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result := func() *DetectionResult {
+		return &DetectionResult{Detections: []Detection{
+			{ClassName: "cat", Confidence: 0.9, BBox: BoundingBox{X: 100, Y: 100, W: 640, H: 360}},
+			{ClassName: "food_bowl", Confidence: 0.9, BBox: BoundingBox{X: 0, Y: 600, W: 100, H: 100}},
+		}}
+	}
+	pi.Annotate(result()) // schedules the first embedding request
+	deadline := time.Now().Add(5 * time.Second)
+	var det *DetectionResult
+	for time.Now().Before(deadline) {
+		det = result()
+		det.Detections[0].BBox.X += 10 // moved a little: still the same track
+		pi.Annotate(det)
+		if det.Detections[0].PetID != "" {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if det.Detections[0].PetID != "mike" || det.Detections[1].PetID != "" {
+		t.Fatalf("pet ids %q/%q, want mike on the cat only", det.Detections[0].PetID, det.Detections[1].PetID)
+	}
+
+	// Profiles persist, embeddings are not exposed.
+	reloaded := NewPetIdentifier(srv.URL, fakeNV12{frame}, doc)
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.Profiles(); len(got) != 2 || got[0].Samples != 1 {
+		t.Fatalf("reloaded profiles %+v", got)
+	}
+	if _, err := reloaded.Enroll("mike", nil, []float32{1, 2, 3}); err == nil || !strings.Contains(err.Error(), "dimensions") {
+		t.Fatalf("dimension mismatch: err = %v", err)
+	}
+	if _, err := reloaded.Enroll("nobody", nil, []float32{1, 0}); err != errPetNotFound {
+		t.Fatalf("unknown pet: err = %v", err)
+	}
+}
+
+func TestPetMatchThreshold(t *testing.T) {
+	pi := NewPetIdentifier("", nil, kv.Doc{})
+	pi.profiles = []PetProfile{{ID: "mike", Embeddings: [][]float32{{1, 0}}}}
+	if id, _ := pi.matchLocked([]float32{0.5, 0.5}); id != "" {
+		t.Fatalf("similarity 0.71 matched %q", id)
+	}
+	if id, _ := pi.matchLocked([]float32{2, 0.1}); id != "mike" {
+		t.Fatalf("near-identical embedding matched %q", id)
+	}
+}
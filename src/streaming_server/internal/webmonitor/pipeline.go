@@ -0,0 +1,111 @@
+package webmonitor
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Per-client queue bounds for the MJPEG and SSE broadcasters. A slow client
+// whose queue is full misses frames/events instead of stalling the others.
+const (
+	defaultClientBuffer = 4
+	maxClientBuffer     = 64
+
+	// mjpegFrameEstimate is a typical annotated 720p JPEG, used only for the
+	// startup memory estimate.
+	mjpegFrameEstimate = 100 * 1024
+)
+
+// validateClientBuffers checks the configured per-client queue sizes.
+func validateClientBuffers(cfg Config) error {
+	for _, b := range []struct {
+		name string
+		n    int
+	}{
+		{"mjpeg-client-buffer", cfg.MJPEGClientBuffer},
+		{"sse-client-buffer", cfg.SSEClientBuffer},
+	} {
+		if b.n < 1 || b.n > maxClientBuffer {
+			return fmt.Errorf("%s must be 1-%d, got %d", b.name, maxClientBuffer, b.n)
+		}
+	}
+	return nil
+}
+
+// SinkStats is one broadcaster's entry in /debug/pipeline.
+type SinkStats struct {
+	Name         string `json:"name"`
+	Clients      int    `json:"clients"`
+	ClientBuffer int    `json:"client_buffer"`
+	Queued       int    `json:"queued"`     // items waiting across all clients
+	MaxQueued    int    `json:"max_queued"` // fullest client queue
+}
+
+func queueStats[T any](name string, buffer int, clients map[int]chan T) SinkStats {
+	st := SinkStats{Name: name, Clients: len(clients), ClientBuffer: buffer}
+	for _, ch := range clients {
+		n := len(ch)
+		st.Queued += n
+		st.MaxQueued = max(st.MaxQueued, n)
+	}
+	return st
+}
+
+// SetClientBuffer sets the per-client frame queue for new subscribers.
+func (fb *FrameBroadcaster) SetClientBuffer(n int) {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	fb.clientBuffer = n
+}
+
+// QueueStats reports the MJPEG client queues.
+func (fb *FrameBroadcaster) QueueStats() SinkStats {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	return queueStats("mjpeg", fb.clientBuffer, fb.clients)
+}
+
+// SetClientBuffer sets the per-client event queue for new subscribers.
+func (db *DetectionBroadcaster) SetClientBuffer(n int) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.clientBuffer = n
+}
+
+// QueueStats reports the detection SSE client queues.
+func (db *DetectionBroadcaster) QueueStats() SinkStats {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return queueStats("detection_sse", db.clientBuffer, db.clients)
+}
+
+// SetClientBuffer sets the per-client event queue for new subscribers.
+func (sb *StatusBroadcaster) SetClientBuffer(n int) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.clientBuffer = n
+}
+
+// QueueStats reports the status SSE client queues.
+func (sb *StatusBroadcaster) QueueStats() SinkStats {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return queueStats("status_sse", sb.clientBuffer, sb.clients)
+}
+
+// handleDebugPipeline serves GET /debug/pipeline: per-client queue sizes and
+// current occupancy of each broadcaster, and the event bus counters.
+func (s *Server) handleDebugPipeline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, map[string]any{
+		"sinks": []SinkStats{
+			s.broadcaster.QueueStats(),
+			s.detectionBroadcaster.QueueStats(),
+			s.statusBroadcaster.QueueStats(),
+		},
+		"event_bus": s.bus.Stats(),
+	})
+}
@@ -0,0 +1,83 @@
+package webmonitor
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestClientBufferConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	if err := validateClientBuffers(cfg); err != nil {
+		t.Fatal(err)
+	}
+	cfg.SSEClientBuffer = 0
+	if err := validateClientBuffers(cfg); err == nil {
+		t.Fatal("zero SSE buffer accepted")
+	}
+
+	fb := NewFrameBroadcaster(nil, nil, make(chan struct{}, 16))
+	fb.SetClientBuffer(8)
+	_, ch := fb.Subscribe()
+	fb.Subscribe()
+	if cap(ch) != 8 {
+		t.Fatalf("client queue cap %d, want 8", cap(ch))
+	}
+	fb.broadcast([]byte("jpeg"))
+	if st := fb.QueueStats(); st.Clients != 2 || st.ClientBuffer != 8 || st.Queued != 2 || st.MaxQueued != 1 {
+		t.Fatalf("stats %+v", st)
+	}
+}
+
+func TestFrameConsumers(t *testing.T) {
+	onChange := make(chan struct{}, 16)
+	fb := NewFrameBroadcaster(nil, nil, onChange)
+	mjpeg, _ := fb.Subscribe()
+	snap, _ := fb.SubscribeAs(ConsumerSnapshot)
+	if len(onChange) != 1 {
+		t.Fatalf("%d change notifications, want 1 (snapshots are not viewers)", len(onChange))
+	}
+	if n := fb.GetClientCount(); n != 1 || len(fb.Viewers()) != 1 {
+		t.Fatalf("client count %d, viewers %d, want 1", n, len(fb.Viewers()))
+	}
+	if c := fb.Consumers(); c[ConsumerMJPEG] != 1 || c[ConsumerSnapshot] != 1 {
+		t.Fatalf("consumers %v", c)
+	}
+	fb.Unsubscribe(snap)
+	fb.Unsubscribe(mjpeg)
+	if c := fb.Consumers(); c[ConsumerMJPEG] != 0 || c[ConsumerSnapshot] != 0 {
+		t.Fatalf("consumers after unsubscribe %v", c)
+	}
+
+	// NextFrame returns the next broadcast frame, or nil when ctx ends
+	go func() {
+		for fb.Consumers()[ConsumerSnapshot] == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		fb.broadcast([]byte("jpeg"))
+	}()
+	if got := fb.NextFrame(context.Background()); string(got) != "jpeg" {
+		t.Fatalf("NextFrame %q", got)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if got := fb.NextFrame(ctx); got != nil {
+		t.Fatalf("NextFrame after cancel %q", got)
+	}
+	if c := fb.Consumers(); c[ConsumerSnapshot] != 0 {
+		t.Fatalf("snapshot consumer left subscribed: %v", c)
+	}
+}
+
+func TestFramesSince(t *testing.T) {
+	for _, tc := range []struct{ from, n, want uint64 }{
+		{0, 100, 0}, // first idle poll: no starting point
+		{100, 130, 30},
+		{130, 130, 0},
+		{130, 5, 0}, // camera restarted
+	} {
+		if got := framesSince(tc.from, tc.n); got != tc.want {
+			t.Errorf("framesSince(%d, %d) = %d, want %d", tc.from, tc.n, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package webmonitor
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/clock"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/shm"
+)
+
+// Power-saving states. Each maps to the capture power mode requested from
+// the camera daemon through the control SHM.
+const (
+	PowerActive    = "active"    // full frame rate
+	PowerIdle      = "idle"      // detector and MJPEG frames at 5 fps
+	PowerSuspended = "suspended" // no detector frames, MJPEG at 1 fps for motion wake-up
+)
+
+// Wake reasons reported by the coordinator (besides the busy reasons
+// returned by its busy callback).
+const (
+	PowerWakeDaytime   = "daytime"   // outside the power-saving hours
+	PowerWakeDetection = "detection" // the detector saw something
+	PowerWakeMotion    = "motion"    // the motion fallback saw something
+	PowerWakeIdle      = "idle"      // nothing needed full power for IdleAfter/SuspendAfter
+)
+
+// PowerSaverStatus is the coordinator's state in /api/status and status events.
+type PowerSaverStatus struct {
+	State   string  `json:"state"`
+	Mode    string  `json:"mode"`     // capture power mode requested (full/low/suspend)
+	Since   float64 `json:"since"`    // unix seconds of the last transition
+	Reason  string  `json:"reason"`   // why the last transition happened
+	IdleFor float64 `json:"idle_for"` // seconds nothing has needed full power (0 while busy)
+}
+
+// PowerSaver lets the board cool down while nobody needs the camera. With
+// no viewers, recording or open rule schedule, inside the power-saving
+// hours, it steps from active to idle after IdleAfter and to suspended after
+// SuspendAfter, asking the camera daemon to thin out detector and MJPEG
+// frames. Any viewer, recording or schedule, the end of the hours, and any
+// detection or motion wakes it to active at once.
+//
+// Suspended stops the detector's input, so only the motion fallback (which
+// keeps reading MJPEG frames) can wake it on motion; without the fallback
+// SuspendAfter must stay 0.
+type PowerSaver struct {
+	// Configurable parameters
+	IdleAfter    time.Duration // quiet this long before idle
+	SuspendAfter time.Duration // quiet this long before suspended (0 = never suspend)
+	Hours        *RuleSchedule // power saving only inside this window (nil = any time)
+	Interval     time.Duration // evaluation interval
+
+	busy    func(now time.Time) string // reason full power is needed now, "" if none
+	request func(shm.PowerMode)
+
+	mu        sync.Mutex
+	state     string
+	since     time.Time
+	reason    string
+	quietFrom time.Time // start of the current quiet period (zero while busy)
+	onChange  func(from, to, reason string)
+	stop      chan struct{}
+	stopped   bool
+}
+
+// NewPowerSaver creates a coordinator polling busy for a wake reason and
+// passing capture power modes to request (e.g. shm.Demand.SetPower).
+func NewPowerSaver(busy func(now time.Time) string, request func(shm.PowerMode)) *PowerSaver {
+	return &PowerSaver{
+		IdleAfter:    2 * time.Minute,
+		SuspendAfter: 10 * time.Minute,
+		Interval:     time.Second,
+		busy:         busy,
+		request:      request,
+		state:        PowerActive,
+		since:        time.Now(),
+		reason:       "start",
+		stop:         make(chan struct{}),
+	}
+}
+
+// ParsePowerHours parses "HH:MM-HH:MM" (in the -timezone zone, wrapping
+// past midnight when the end is earlier) into a daily window; "" means any
+// time.
+func ParsePowerHours(s string) (*RuleSchedule, error) {
+	if s == "" {
+		return nil, nil
+	}
+	start, end, ok := strings.Cut(s, "-")
+	if !ok {
+		return nil, fmt.Errorf("power-saving hours %q: want HH:MM-HH:MM", s)
+	}
+	for _, c := range []string{start, end} {
+		if _, err := parseClock(c); err != nil {
+			return nil, fmt.Errorf("power-saving hours %q: %w", s, err)
+		}
+	}
+	return &RuleSchedule{Start: start, End: end}, nil
+}
+
+// SetOnChange sets a callback fired after every state change.
+func (p *PowerSaver) SetOnChange(callback func(from, to, reason string)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onChange = callback
+}
+
+// Start begins evaluating.
+func (p *PowerSaver) Start() {
+	go p.run()
+}
+
+// Stop halts evaluating and requests full power.
+func (p *PowerSaver) Stop() {
+	p.mu.Lock()
+	if p.stopped {
+		p.mu.Unlock()
+		return
+	}
+	close(p.stop)
+	p.stopped = true
+	p.mu.Unlock()
+	p.request(shm.PowerFull)
+}
+
+func (p *PowerSaver) run() {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case now := <-ticker.C:
+			p.evaluate(now)
+		}
+	}
+}
+
+// evaluate steps the state machine for the conditions at now.
+func (p *PowerSaver) evaluate(now time.Time) {
+	reason := p.busy(now)
+	if reason == "" && p.Hours != nil && !p.Hours.contains(now.In(clock.Location())) {
+		reason = PowerWakeDaytime
+	}
+	p.mu.Lock()
+	if reason != "" {
+		p.quietFrom = time.Time{}
+		p.setLocked(PowerActive, reason, now)
+		return
+	}
+	if p.quietFrom.IsZero() {
+		p.quietFrom = now
+	}
+	quiet := now.Sub(p.quietFrom)
+	switch {
+	case p.SuspendAfter > 0 && quiet >= p.SuspendAfter:
+		p.setLocked(PowerSuspended, PowerWakeIdle, now)
+	case quiet >= p.IdleAfter && p.state == PowerActive:
+		p.setLocked(PowerIdle, PowerWakeIdle, now)
+	default:
+		p.mu.Unlock()
+	}
+}
+
+// Wake returns to active at once and restarts the quiet period, e.g. on a
+// detection. It is cheap while already active.
+func (p *PowerSaver) Wake(reason string) {
+	now := time.Now()
+	p.mu.Lock()
+	p.quietFrom = now
+	p.setLocked(PowerActive, reason, now)
+}
+
+// setLocked switches to state and requests its power mode, then unlocks
+// p.mu and reports the change.
+func (p *PowerSaver) setLocked(state, reason string, now time.Time) {
+	from := p.state
+	if from == state {
+		p.mu.Unlock()
+		return
+	}
+	p.state, p.reason, p.since = state, reason, now
+	p.request(powerMode(state))
+	onChange := p.onChange
+	p.mu.Unlock()
+
+	logger.Info("Power", "%s -> %s (%s)", from, state, reason)
+	if onChange != nil {
+		onChange(from, state, reason)
+	}
+}
+
+// State returns the current state.
+func (p *PowerSaver) State() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state
+}
+
+// Status returns the current state and why it was entered.
+func (p *PowerSaver) Status() PowerSaverStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st := PowerSaverStatus{
+		State:  p.state,
+		Mode:   powerMode(p.state).String(),
+		Since:  float64(p.since.UnixNano()) / 1e9,
+		Reason: p.reason,
+	}
+	if !p.quietFrom.IsZero() {
+		st.IdleFor = time.Since(p.quietFrom).Seconds()
+	}
+	return st
+}
+
+func powerMode(state string) shm.PowerMode {
+	switch state {
+	case PowerIdle:
+		return shm.PowerLow
+	case PowerSuspended:
+		return shm.PowerSuspend
+	default:
+		return shm.PowerFull
+	}
+}
+
+// startPowerSaver runs the power-saving coordinator on this server's
+// viewers, recording and rule schedules, woken by detections.
+func (s *Server) startPowerSaver() {
+	p := NewPowerSaver(s.powerBusyReason, s.demand.SetPower)
+	p.IdleAfter = s.cfg.PowerSaveIdleAfter
+	p.SuspendAfter = s.cfg.PowerSaveSuspendAfter
+	if p.SuspendAfter > 0 && s.motionDetector == nil {
+		logger.Warn("Power", "Suspend disabled: waking on motion needs the motion fallback")
+		p.SuspendAfter = 0
+	}
+	if hours, err := ParsePowerHours(s.cfg.PowerSaveHours); err != nil {
+		logger.Warn("Power", "Ignoring hours: %v", err)
+	} else {
+		p.Hours = hours
+	}
+	p.SetOnChange(s.applyPowerState)
+	s.busCancels = append(s.busCancels, s.topics.detections.Subscribe("power", func(det *DetectionResult) {
+		if len(det.Detections) == 0 {
+			return
+		}
+		if det.Source == DetectionSourceMotion {
+			p.Wake(PowerWakeMotion)
+		} else {
+			p.Wake(PowerWakeDetection)
+		}
+	}))
+	s.powerSaver = p
+	s.statusBroadcaster.SetPower(p.Status)
+	p.Start()
+	logger.Info("Power", "Power saving: idle after %v, suspend after %v, hours %q",
+		p.IdleAfter, p.SuspendAfter, s.cfg.PowerSaveHours)
+}
+
+// powerBusyReason returns why the camera needs full power now, "" if
+// nothing does.
+func (s *Server) powerBusyReason(now time.Time) string {
+	if v := s.connectionBroadcaster.Viewers(); v.WebRTC+v.MJPEG > 0 {
+		return "viewer"
+	}
+	if s.recorder.IsRecording() {
+		return "recording"
+	}
+	if s.rules != nil && s.rules.Scheduled(now) {
+		return "schedule"
+	}
+	return ""
+}
+
+// applyPowerState stops detector health from counting the suspended
+// detector as stale and records the transition as an event.
+func (s *Server) applyPowerState(from, to, reason string) {
+	if s.detectionHealth != nil {
+		s.detectionHealth.SetSuspended(to == PowerSuspended)
+	}
+	s.events.Append(Event{
+		Type: "power_state",
+		Data: map[string]string{
+			"from":   from,
+			"state":  to,
+			"reason": reason,
+		},
+	})
+}
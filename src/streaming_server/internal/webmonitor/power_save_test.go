@@ -0,0 +1,129 @@
+package webmonitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/clock"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/shm"
+)
+
+func TestPowerSaverStateMachine(t *testing.T) {
+	busy := ""
+	var modes []shm.PowerMode
+	p := NewPowerSaver(func(time.Time) string { return busy }, func(m shm.PowerMode) { modes = append(modes, m) })
+	p.IdleAfter = time.Minute
+	p.SuspendAfter = 5 * time.Minute
+	var changes []string
+	p.SetOnChange(func(from, to, reason string) { changes = append(changes, to+":"+reason) })
+
+	t0 := time.Now()
+	steps := []struct {
+		at   time.Duration
+		busy string
+		want string
+	}{
+		{0, "viewer", PowerActive},
+		{time.Second, "", PowerActive},    // quiet period starts
+		{61 * time.Second, "", PowerIdle}, // IdleAfter
+		{2 * time.Minute, "", PowerIdle},
+		{301 * time.Second, "", PowerSuspended},       // SuspendAfter
+		{302 * time.Second, "recording", PowerActive}, // busy wakes at once
+		{303 * time.Second, "", PowerActive},          // new quiet period
+		{363 * time.Second, "", PowerIdle},
+	}
+	for _, s := range steps {
+		busy = s.busy
+		p.evaluate(t0.Add(s.at))
+		if got := p.State(); got != s.want {
+			t.Fatalf("t=%v busy=%q: %s, want %s", s.at, s.busy, got, s.want)
+		}
+	}
+	want := []shm.PowerMode{shm.PowerLow, shm.PowerSuspend, shm.PowerFull, shm.PowerLow}
+	if len(modes) != len(want) {
+		t.Fatalf("requested %v, want %v", modes, want)
+	}
+	for i := range want {
+		if modes[i] != want[i] {
+			t.Fatalf("requested %v, want %v", modes, want)
+		}
+	}
+	if changes[2] != "active:recording" {
+		t.Errorf("changes %v", changes)
+	}
+
+	p.Wake(PowerWakeMotion)
+	if st := p.Status(); st.State != PowerActive || st.Reason != PowerWakeMotion || st.Mode != "full" {
+		t.Errorf("after wake: %+v", st)
+	}
+	// The quiet period restarts at the wake-up
+	p.evaluate(time.Now().Add(30 * time.Second))
+	if p.State() != PowerActive {
+		t.Error("idle again before IdleAfter since the wake-up")
+	}
+}
+
+func TestPowerSaverHours(t *testing.T) {
+	hours, err := ParsePowerHours("23:00-06:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPowerSaver(func(time.Time) string { return "" }, func(shm.PowerMode) {})
+	p.IdleAfter = time.Minute
+	p.SuspendAfter = 0
+	p.Hours = hours
+
+	day := time.Date(2026, 10, 16, 14, 0, 0, 0, clock.Location())
+	p.evaluate(day)
+	p.evaluate(day.Add(time.Hour))
+	if st := p.Status(); st.State != PowerActive || st.IdleFor != 0 {
+		t.Fatalf("outside hours: %+v", st)
+	}
+
+	night := time.Date(2026, 10, 17, 1, 0, 0, 0, clock.Location())
+	p.evaluate(night)
+	p.evaluate(night.Add(2 * time.Minute))
+	if p.State() != PowerIdle {
+		t.Fatalf("inside hours: %s", p.State())
+	}
+	p.evaluate(night.Add(6 * time.Hour)) // 07:00
+	if st := p.Status(); st.State != PowerActive || st.Reason != PowerWakeDaytime {
+		t.Fatalf("after hours: %+v", st)
+	}
+
+	for _, bad := range []string{"23:00", "25:00-06:00", "23:00-6"} {
+		if _, err := ParsePowerHours(bad); err == nil {
+			t.Errorf("ParsePowerHours(%q) accepted", bad)
+		}
+	}
+	if h, err := ParsePowerHours(""); h != nil || err != nil {
+		t.Errorf("empty hours: %v, %v", h, err)
+	}
+}
+
+func TestDetectionHealthSuspended(t *testing.T) {
+	h := NewDetectionHealth(func() int { return 0 })
+	h.StaleAfter = 10 * time.Second
+	h.AlertAfter = 20 * time.Second
+	stale := 0
+	h.SetOnStaleChange(func(bool, time.Time) { stale++ })
+
+	start := time.Now()
+	h.observe(1, start)
+	h.SetSuspended(true)
+	h.observe(1, start.Add(30*time.Second))
+	if st := h.statusAt(start.Add(30 * time.Second)); !st.Healthy || !st.Suspended || st.Alerting || stale != 0 {
+		t.Fatalf("suspended detector counted stale: %+v, %d stale events", st, stale)
+	}
+
+	// Staleness counts again from the resume
+	h.SetSuspended(false)
+	h.observe(1, time.Now().Add(5*time.Second))
+	if stale != 0 {
+		t.Fatal("stale right after resume")
+	}
+	h.observe(1, time.Now().Add(11*time.Second))
+	if stale != 1 {
+		t.Fatal("not stale after StaleAfter since resume")
+	}
+}
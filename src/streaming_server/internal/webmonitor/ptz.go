@@ -0,0 +1,89 @@
+package webmonitor
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/ptz"
+)
+
+// startPTZ opens the pan-tilt mount named by cfg.PTZBackend and, with
+// tracking enabled, centers the most confident pet detection.
+func (s *Server) startPTZ() {
+	backend, err := ptz.Open(s.cfg.PTZBackend)
+	if err != nil {
+		logger.Warn("PTZ", "Disabled: %v", err)
+		return
+	}
+	c := ptz.NewController(backend, ptz.DefaultLimits(), ptzPresetsDoc.in(s.settings))
+	if err := c.LoadPresets(); err != nil {
+		logger.Warn("PTZ", "Failed to load presets: %v", err)
+	}
+	if s.cfg.PTZTrack {
+		enabled := true
+		c.Apply(ptz.Command{Action: ptz.ActionTrack, Enabled: &enabled})
+	}
+	s.busCancels = append(s.busCancels, s.topics.detections.Subscribe("ptz", func(det *DetectionResult) {
+		s.trackPet(det, time.Now())
+	}))
+	s.ptz = c
+	logger.Info("PTZ", "Mount %s (tracking %v)", backend.Name(), s.cfg.PTZTrack)
+}
+
+// trackPet nudges the mount toward the most confident pet in det while
+// auto-tracking is on.
+func (s *Server) trackPet(det *DetectionResult, now time.Time) {
+	if !s.ptz.Tracking() {
+		return
+	}
+	x, y, ok := petCenter(det)
+	if !ok {
+		return
+	}
+	if _, err := s.ptz.Track(x, y, now); err != nil {
+		logger.Warn("PTZ", "Tracking move failed: %v", err)
+	}
+}
+
+// handlePTZ serves GET /api/ptz (status) and POST /api/ptz with a
+// ptz.Command, or POST /api/ptz/{action} with the rest of the command.
+func (s *Server) handlePTZ(w http.ResponseWriter, r *http.Request) {
+	if s.ptz == nil {
+		writeJSONWithStatus(w, map[string]any{"error": "no PTZ mount configured (-ptz)"}, http.StatusNotImplemented)
+		return
+	}
+	action := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/ptz"), "/")
+	switch {
+	case r.Method == http.MethodGet && action == "":
+		writeJSON(w, s.ptz.Status())
+		return
+	case r.Method != http.MethodPost:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var cmd ptz.Command
+	if err := json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&cmd); err != nil && err != io.EOF {
+		writeJSONWithStatus(w, map[string]any{"error": "invalid JSON: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if action != "" {
+		cmd.Action = action
+	}
+	st, err := s.ptz.Apply(cmd)
+	switch {
+	case errors.Is(err, ptz.ErrUnknownPreset):
+		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusNotFound)
+	case errors.Is(err, ptz.ErrBackend):
+		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusBadGateway)
+	case err != nil:
+		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusBadRequest)
+	default:
+		writeJSON(w, st)
+	}
+}
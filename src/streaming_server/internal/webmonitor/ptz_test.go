@@ -0,0 +1,91 @@
+package webmonitor
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/ptz"
+)
+
+type testMount struct{ fail bool }
+
+func (m *testMount) SetPosition(ptz.Position) error {
+	if m.fail {
+		return errors.New("no ack")
+	}
+	return nil
+}
+func (m *testMount) Name() string { return "test" }
+func (m *testMount) Close() error { return nil }
+
+func TestHandlePTZ(t *testing.T) {
+	s := &Server{}
+	srv := httptest.NewServer(http.HandlerFunc(s.handlePTZ))
+	defer srv.Close()
+	if resp, _ := http.Get(srv.URL + "/api/ptz"); resp.StatusCode != http.StatusNotImplemented {
+		t.Fatalf("unconfigured: %d", resp.StatusCode)
+	}
+
+	mount := &testMount{}
+	s.ptz = ptz.NewController(mount, ptz.DefaultLimits(), kv.Doc{})
+	post := func(path, body string) (int, ptz.Status) {
+		resp, err := http.Post(srv.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var st ptz.Status
+		json.NewDecoder(resp.Body).Decode(&st)
+		return resp.StatusCode, st
+	}
+
+	if code, st := post("/api/ptz", `{"action":"move","pan":20,"tilt":-5}`); code != http.StatusOK || st.Position != (ptz.Position{Pan: 20, Tilt: -5}) {
+		t.Fatalf("move: %d %+v", code, st)
+	}
+	if code, st := post("/api/ptz/relative", `{"pan":-30}`); code != http.StatusOK || st.Position.Pan != -10 {
+		t.Fatalf("relative: %d %+v", code, st)
+	}
+	if code, _ := post("/api/ptz/save_preset", `{"name":"sofa"}`); code != http.StatusOK {
+		t.Fatalf("save preset: %d", code)
+	}
+	if code, st := post("/api/ptz/home", ``); code != http.StatusOK || st.Position != (ptz.Position{}) || st.Presets["sofa"].Pan != -10 {
+		t.Fatalf("home: %d %+v", code, st)
+	}
+	for path, want := range map[string]int{
+		"/api/ptz/preset": http.StatusNotFound,
+		"/api/ptz/zoom":   http.StatusBadRequest,
+	} {
+		if code, _ := post(path, `{"name":"bed"}`); code != want {
+			t.Errorf("POST %s = %d, want %d", path, code, want)
+		}
+	}
+	mount.fail = true
+	if code, _ := post("/api/ptz/home", ``); code != http.StatusBadGateway {
+		t.Errorf("backend failure: %d", code)
+	}
+}
+
+func TestTrackPet(t *testing.T) {
+	s := &Server{ptz: ptz.NewController(&testMount{}, ptz.DefaultLimits(), kv.Doc{})}
+	det := &DetectionResult{Detections: []Detection{
+		{ClassName: "person", Confidence: 0.99, BBox: BoundingBox{X: 0, Y: 0, W: 100, H: 100}},
+		{ClassName: "cat", Confidence: 0.6, BBox: BoundingBox{X: 1180, Y: 310, W: 100, H: 100}},
+		{ClassName: "dog", Confidence: 0.4, BBox: BoundingBox{X: 0, Y: 310, W: 100, H: 100}},
+	}}
+	s.trackPet(det, time.Now())
+	if s.ptz.Status().Moves != 0 {
+		t.Fatal("tracked with tracking off")
+	}
+	on := true
+	s.ptz.Apply(ptz.Command{Action: ptz.ActionTrack, Enabled: &on})
+	s.trackPet(det, time.Now())
+	if p := s.ptz.Status().Position; p.Pan <= 0 || p.Tilt != 0 {
+		t.Errorf("did not pan toward the cat: %+v", p)
+	}
+}
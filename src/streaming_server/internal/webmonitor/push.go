@@ -0,0 +1,360 @@
+package webmonitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/outbox"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/webpush"
+)
+
+// PushMessage is the JSON payload delivered to the service worker.
+type PushMessage struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	Tag   string `json:"tag,omitempty"`   // same tag replaces the previous notification
+	Image string `json:"image,omitempty"` // snapshot URL (path on this server)
+	URL   string `json:"url,omitempty"`   // opened on click
+
+	EventID string `json:"event_id,omitempty"` // trigger's correlation ID (see Event.EventID)
+
+	title, body Message // catalog texts of Title and Body, rendered per subscription
+}
+
+// localizedPush returns a notification with catalog texts, rendered in
+// each subscription's locale when sent (Title and Body hold the English).
+func localizedPush(title, body Message, tag string) PushMessage {
+	return PushMessage{Title: title.String(), Body: body.String(), Tag: tag, title: title, body: body}
+}
+
+// In returns msg with its catalog texts rendered in locale.
+func (msg PushMessage) In(locale string) PushMessage {
+	if msg.title.Key != "" {
+		msg.Title = msg.title.In(locale)
+	}
+	if msg.body.Key != "" {
+		msg.Body = msg.body.In(locale)
+	}
+	return msg
+}
+
+// pushSubscription is a stored subscription with the locale of the page
+// that registered it.
+type pushSubscription struct {
+	webpush.Subscription
+	Locale string `json:"locale,omitempty"` // "" = PushNotifier.DefaultLocale
+}
+
+// PushNotifier manages browser push subscriptions and fans alerts out to them.
+type PushNotifier struct {
+	DefaultLocale string // of subscriptions registered without one
+
+	mu     sync.Mutex
+	doc    kv.Doc
+	subs   []pushSubscription
+	client *webpush.Client
+	sender func(ctx context.Context, sub webpush.Subscription, payload []byte) error
+}
+
+// NewPushNotifier loads (or creates) the VAPID key at keyPath and loads
+// the subscription list from subs (the zero Doc = in memory). The key stays
+// a file: subscriptions are bound to it, and it is not a setting.
+func NewPushNotifier(keyPath string, subs kv.Doc, subject string) (*PushNotifier, error) {
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0o755); err != nil {
+		return nil, err
+	}
+	keys, err := webpush.LoadOrCreateVAPIDKeys(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	n := &PushNotifier{
+		doc:           subs,
+		client:        webpush.NewClient(keys, subject),
+		DefaultLocale: fallbackLocale,
+	}
+	n.sender = func(ctx context.Context, sub webpush.Subscription, payload []byte) error {
+		return n.client.Send(ctx, sub, payload, time.Hour)
+	}
+	if err := n.Load(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// Load replaces the subscriptions with the persisted ones.
+func (n *PushNotifier) Load() error {
+	var subs []pushSubscription
+	if _, err := n.doc.Load(&subs); err != nil {
+		return err
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.subs = subs
+	return nil
+}
+
+// PublicKey returns the VAPID application server key.
+func (n *PushNotifier) PublicKey() string {
+	return n.client.Keys.PublicKey()
+}
+
+// Count returns the number of subscriptions.
+func (n *PushNotifier) Count() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.subs)
+}
+
+// Subscribe adds (or refreshes) a subscription keyed by endpoint, notified
+// in locale ("" = DefaultLocale).
+func (n *PushNotifier) Subscribe(sub webpush.Subscription, locale string) error {
+	if sub.Endpoint == "" || sub.Keys.P256dh == "" || sub.Keys.Auth == "" {
+		return errors.New("subscription requires endpoint, keys.p256dh and keys.auth")
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	ps := pushSubscription{Subscription: sub, Locale: locale}
+	for i := range n.subs {
+		if n.subs[i].Endpoint == sub.Endpoint {
+			n.subs[i] = ps
+			return n.saveLocked()
+		}
+	}
+	n.subs = append(n.subs, ps)
+	return n.saveLocked()
+}
+
+// Unsubscribe removes a subscription. Returns false if it was not registered.
+func (n *PushNotifier) Unsubscribe(endpoint string) (bool, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for i := range n.subs {
+		if n.subs[i].Endpoint == endpoint {
+			n.subs = append(n.subs[:i], n.subs[i+1:]...)
+			return true, n.saveLocked()
+		}
+	}
+	return false, nil
+}
+
+func (n *PushNotifier) saveLocked() error {
+	return n.doc.Save(n.subs)
+}
+
+// localeLocked returns the locale sub is notified in. Caller holds n.mu.
+func (n *PushNotifier) localeLocked(sub pushSubscription) string {
+	if sub.Locale != "" {
+		return sub.Locale
+	}
+	return n.DefaultLocale
+}
+
+// Notify sends msg to every subscription. Subscriptions reported gone by the
+// push service are dropped. Blocks until all sends complete.
+func (n *PushNotifier) Notify(msg PushMessage) {
+	n.mu.Lock()
+	subs := make([]webpush.Subscription, len(n.subs))
+	payloads := make(map[string][]byte) // by locale
+	subPayload := make([][]byte, len(n.subs))
+	for i, sub := range n.subs {
+		subs[i] = sub.Subscription
+		locale := n.localeLocked(sub)
+		if payloads[locale] == nil {
+			payload, err := json.Marshal(msg.In(locale))
+			if err != nil {
+				n.mu.Unlock()
+				logger.Error("Push", "Marshal error: %v", err)
+				return
+			}
+			payloads[locale] = payload
+		}
+		subPayload[i] = payloads[locale]
+	}
+	n.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var goneMu sync.Mutex
+	var gone []string
+	for i, sub := range subs {
+		wg.Add(1)
+		go func(sub webpush.Subscription, payload []byte) {
+			defer wg.Done()
+			err := n.sender(ctx, sub, payload)
+			switch {
+			case errors.Is(err, webpush.ErrSubscriptionGone):
+				goneMu.Lock()
+				gone = append(gone, sub.Endpoint)
+				goneMu.Unlock()
+			case err != nil:
+				logger.Warn("Push", "Send failed: %v", err)
+			}
+		}(sub, subPayload[i])
+	}
+	wg.Wait()
+
+	for _, endpoint := range gone {
+		if _, err := n.Unsubscribe(endpoint); err != nil {
+			logger.Warn("Push", "Failed to persist subscriptions: %v", err)
+		}
+	}
+	if len(gone) > 0 {
+		logger.Info("Push", "Dropped %d expired subscription(s)", len(gone))
+	}
+}
+
+// pushJobKind is the outbox job kind of push notifications.
+const pushJobKind = "push"
+
+// pushJob is the outbox payload of one notification to one subscription,
+// rendered in its locale.
+type pushJob struct {
+	Endpoint string      `json:"endpoint"`
+	Message  PushMessage `json:"message"`
+}
+
+// Enqueue queues msg for every subscription in q, so notifications raised
+// while the push services are unreachable are delivered once they are back.
+func (n *PushNotifier) Enqueue(q *outbox.Queue, msg PushMessage) error {
+	n.mu.Lock()
+	jobs := make([]pushJob, len(n.subs))
+	for i, sub := range n.subs {
+		jobs[i] = pushJob{Endpoint: sub.Endpoint, Message: msg.In(n.localeLocked(sub))}
+	}
+	n.mu.Unlock()
+	for _, job := range jobs {
+		if err := q.Enqueue(pushJobKind, job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliver is the outbox handler of push jobs. A subscription that has gone
+// away in the meantime is dropped along with the job.
+func (n *PushNotifier) deliver(ctx context.Context, payload json.RawMessage) error {
+	var job pushJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return outbox.Permanent(err)
+	}
+	n.mu.Lock()
+	i := slices.IndexFunc(n.subs, func(s pushSubscription) bool { return s.Endpoint == job.Endpoint })
+	var sub webpush.Subscription
+	if i >= 0 {
+		sub = n.subs[i].Subscription
+	}
+	n.mu.Unlock()
+	if i < 0 {
+		return nil
+	}
+	data, err := json.Marshal(job.Message)
+	if err != nil {
+		return outbox.Permanent(err)
+	}
+	err = n.sender(ctx, sub, data)
+	switch {
+	case errors.Is(err, webpush.ErrSubscriptionGone):
+		if _, err := n.Unsubscribe(job.Endpoint); err != nil {
+			logger.Warn("Push", "Failed to persist subscriptions: %v", err)
+		}
+		logger.Info("Push", "Dropped expired subscription")
+		return nil
+	case errors.Is(err, webpush.ErrPayloadTooLarge):
+		return outbox.Permanent(err)
+	}
+	return err
+}
+
+// notify sends an alert with a fresh snapshot to push subscribers. Safe to
+// call when push is disabled.
+func (s *Server) notify(msg PushMessage) {
+	if s.push == nil || s.push.Count() == 0 {
+		return
+	}
+	msg.URL = "/"
+	if s.snapshots != nil {
+		if name, err := s.snapshots.Save(); err == nil {
+			msg.Image = "/api/snapshots/" + name
+		} else {
+			logger.Debug("Push", "Snapshot skipped: %v", err)
+		}
+	}
+	if s.outbox != nil {
+		err := s.push.Enqueue(s.outbox, msg)
+		if err == nil {
+			return
+		}
+		logger.Warn("Push", "Failed to queue notification, sending once: %v", err)
+	}
+	s.push.Notify(msg)
+}
+
+// handlePushKey serves GET /api/push/vapid-public-key.
+func (s *Server) handlePushKey(w http.ResponseWriter, r *http.Request) {
+	if s.push == nil {
+		writeJSONWithStatus(w, map[string]any{"error": "push notifications not configured"}, http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, map[string]any{"public_key": s.push.PublicKey()})
+}
+
+// handlePushSubscribe serves POST/DELETE /api/push/subscription with a
+// PushSubscription JSON body. Notifications are sent in the request's
+// locale (?locale= or the token's), else in -locale.
+func (s *Server) handlePushSubscribe(w http.ResponseWriter, r *http.Request) {
+	if s.push == nil {
+		writeJSONWithStatus(w, map[string]any{"error": "push notifications not configured"}, http.StatusServiceUnavailable)
+		return
+	}
+
+	var sub webpush.Subscription
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		writeJSONWithStatus(w, map[string]any{"error": "invalid JSON: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		if err := s.push.Subscribe(sub, explicitLocale(r)); err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+		writeJSONWithStatus(w, map[string]any{"subscribed": true, "total": s.push.Count()}, http.StatusCreated)
+	case http.MethodDelete:
+		found, err := s.push.Unsubscribe(sub.Endpoint)
+		if err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, map[string]any{"unsubscribed": found})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handlePushTest serves POST /api/push/test (sends a test notification).
+func (s *Server) handlePushTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.push == nil {
+		writeJSONWithStatus(w, map[string]any{"error": "push notifications not configured"}, http.StatusServiceUnavailable)
+		return
+	}
+	go s.notify(localizedPush(msg("push.test.title"), msg("push.test.body"), "test"))
+	writeJSON(w, map[string]any{"status": "sent", "subscriptions": s.push.Count()})
+}
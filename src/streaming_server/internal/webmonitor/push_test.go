@@ -0,0 +1,153 @@
+package webmonitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/outbox"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/webpush"
+)
+
+func testSubscription(endpoint string) webpush.Subscription {
+	var sub webpush.Subscription
+	sub.Endpoint = endpoint
+	sub.Keys.P256dh = "p256dh"
+	sub.Keys.Auth = "auth"
+	return sub
+}
+
+func TestPushNotifier_SubscribePersistAndDropGone(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "vapid.pem")
+	subs := pushDoc.in(kv.NewMemory())
+
+	n, err := NewPushNotifier(keyPath, subs, "mailto:test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.PublicKey() == "" {
+		t.Fatal("empty VAPID public key")
+	}
+	_ = n.Subscribe(testSubscription("https://push.example.com/a"), "")
+	_ = n.Subscribe(testSubscription("https://push.example.com/b"), "")
+	_ = n.Subscribe(testSubscription("https://push.example.com/a"), "") // refresh, not duplicate
+	if n.Count() != 2 {
+		t.Fatalf("count = %d, want 2", n.Count())
+	}
+
+	var mu sync.Mutex
+	var sent []string
+	n.sender = func(_ context.Context, sub webpush.Subscription, payload []byte) error {
+		mu.Lock()
+		sent = append(sent, sub.Endpoint)
+		mu.Unlock()
+		if strings.HasSuffix(sub.Endpoint, "/b") {
+			return webpush.ErrSubscriptionGone
+		}
+		return nil
+	}
+	n.Notify(PushMessage{Title: "Cat", Body: "detected"})
+	if len(sent) != 2 {
+		t.Fatalf("sent to %d subscriptions, want 2", len(sent))
+	}
+
+	reloaded, err := NewPushNotifier(keyPath, subs, "mailto:test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Count() != 1 {
+		t.Fatalf("persisted count = %d, want 1 after dropping gone subscription", reloaded.Count())
+	}
+	if reloaded.PublicKey() != n.PublicKey() {
+		t.Error("VAPID key not persisted")
+	}
+}
+
+func TestHandlePushSubscribe(t *testing.T) {
+	n, err := NewPushNotifier(filepath.Join(t.TempDir(), "vapid.pem"), kv.Doc{}, "mailto:test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{push: n}
+
+	body := `{"endpoint":"https://push.example.com/x","keys":{"p256dh":"k","auth":"a"}}`
+	rec := httptest.NewRecorder()
+	s.handlePushSubscribe(rec, httptest.NewRequest(http.MethodPost, "/api/push/subscription", strings.NewReader(body)))
+	if rec.Code != http.StatusCreated || n.Count() != 1 {
+		t.Fatalf("subscribe: code=%d count=%d", rec.Code, n.Count())
+	}
+
+	rec = httptest.NewRecorder()
+	s.handlePushSubscribe(rec, httptest.NewRequest(http.MethodPost, "/api/push/subscription", strings.NewReader(`{"endpoint":""}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid subscription: expected 400, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handlePushSubscribe(rec, httptest.NewRequest(http.MethodDelete, "/api/push/subscription", strings.NewReader(body)))
+	if rec.Code != http.StatusOK || n.Count() != 0 {
+		t.Fatalf("unsubscribe: code=%d count=%d", rec.Code, n.Count())
+	}
+}
+
+func TestPushNotifier_QueuedDelivery(t *testing.T) {
+	dir := t.TempDir()
+	n, err := NewPushNotifier(filepath.Join(dir, "vapid.pem"), kv.Doc{}, "mailto:test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := outbox.Open(filepath.Join(dir, "outbox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = n.Subscribe(testSubscription("https://push.example.com/a"), "")
+	_ = n.Subscribe(testSubscription("https://push.example.com/b"), "")
+
+	// One job per subscription, each delivered on its own
+	var sent []string
+	online := false
+	n.sender = func(_ context.Context, sub webpush.Subscription, payload []byte) error {
+		switch {
+		case strings.HasSuffix(sub.Endpoint, "/b"):
+			return webpush.ErrSubscriptionGone
+		case !online:
+			return errors.New("network is unreachable")
+		}
+		sent = append(sent, string(payload))
+		return nil
+	}
+	queued := make(chan json.RawMessage, 2)
+	q.Register(pushJobKind, func(_ context.Context, payload json.RawMessage) error {
+		queued <- payload
+		return nil
+	})
+	if err := n.Enqueue(q, PushMessage{Title: "Cat", Body: "detected"}); err != nil {
+		t.Fatal(err)
+	}
+	if st := q.Stats(); st.Pending[pushJobKind] != 2 {
+		t.Fatalf("queued %+v", st)
+	}
+	q.Start()
+	defer q.Stop()
+	jobs := []json.RawMessage{<-queued, <-queued}
+
+	if err := n.deliver(context.Background(), jobs[0]); err == nil {
+		t.Error("offline delivery reported success")
+	}
+	online = true
+	if err := n.deliver(context.Background(), jobs[0]); err != nil || len(sent) != 1 || !strings.Contains(sent[0], `"detected"`) {
+		t.Errorf("delivery: %v, sent %v", err, sent)
+	}
+	// A gone subscription is dropped, and so is its job
+	if err := n.deliver(context.Background(), jobs[1]); err != nil || n.Count() != 1 {
+		t.Errorf("gone subscription: %v, %d left", err, n.Count())
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"path/filepath"
 	"sync"
 	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/clock"
 )
 
 // RecorderState emulates the Flask recorder API shape.
@@ -33,7 +35,7 @@ func (r *RecorderState) Start(filename string) (string, error) {
 	}
 
 	if filename == "" {
-		timestamp := time.Now().Format("20060102_150405")
+		timestamp := clock.FileStamp(time.Now())
 		filename = fmt.Sprintf("recording_%s.h264", timestamp)
 	}
 
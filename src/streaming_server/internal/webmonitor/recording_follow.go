@@ -0,0 +1,99 @@
+package webmonitor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+// followPoll is how often a follower checks the active raw stream for growth.
+const followPoll = 200 * time.Millisecond
+
+// errNotActive means the requested file is not the one being recorded.
+var errNotActive = errors.New("not the active recording")
+
+// OpenActive opens the raw stream being recorded, if filename is it. writing
+// reports whether the recorder still has that file open; it stays true
+// across a clock-jump rename and turns false only once the last frame has
+// been written and the file closed.
+func (r *Recorder) OpenActive(filename string) (f *os.File, writing func() bool, err error) {
+	r.mu.RLock()
+	active, name := r.file, r.filename
+	r.mu.RUnlock()
+	if active == nil || name != filename {
+		return nil, nil, errNotActive
+	}
+	f, err = os.Open(filepath.Join(r.dir(), name))
+	if err != nil {
+		return nil, nil, err
+	}
+	writing = func() bool {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		return r.file == active
+	}
+	return f, writing, nil
+}
+
+// serveActiveRecording streams the recording in progress and keeps
+// following the file as it grows (like tail -f) until the recording stops,
+// so a clip can be downloaded during a live incident instead of after
+// conversion. The length is unknown, so the response is chunked.
+// It returns false if filename is not being recorded.
+func (s *Server) serveActiveRecording(w http.ResponseWriter, r *http.Request, filename string) bool {
+	f, writing, err := s.recorder.OpenActive(filename)
+	if errors.Is(err, errNotActive) {
+		return false
+	}
+	if err != nil {
+		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+		return true
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	w.Header().Set("Content-Type", "video/hevc")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Recording-Active", "true")
+	flusher, _ := w.(http.Flusher)
+
+	ticker := time.NewTicker(followPoll)
+	defer ticker.Stop()
+	buf := make([]byte, 64*1024)
+	var sent int64
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return true
+			}
+			sent += int64(n)
+			continue
+		}
+		if err != nil && err != io.EOF {
+			logger.Warn("Recorder", "Follow %s: %v", filename, err)
+			return true
+		}
+		// Caught up. Check writing before the final read so bytes
+		// written just before the file was closed are not lost.
+		if !writing() {
+			m, _ := io.CopyBuffer(w, f, buf)
+			logger.Debug("Recorder", "Follow %s finished: %d bytes", filename, sent+m)
+			return true
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		select {
+		case <-r.Context().Done():
+			return true
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package webmonitor
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestServeActiveRecording_FollowsUntilStop(t *testing.T) {
+	dir := t.TempDir()
+	rec := NewRecorder(dir, "/nonexistent")
+	name := "recording_20261016_120000.hevc"
+	file, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte("first-"))
+	rec.mu.Lock()
+	rec.recording, rec.file, rec.filename = true, file, name
+	rec.mu.Unlock()
+
+	s := &Server{recorder: rec}
+	srv := httptest.NewServer(http.HandlerFunc(s.handleRecordingDownload))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/recordings/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Recording-Active") != "true" {
+		t.Fatalf("status %d, active header %q", resp.StatusCode, resp.Header.Get("X-Recording-Active"))
+	}
+
+	got := make(chan []byte)
+	go func() {
+		b, _ := io.ReadAll(resp.Body)
+		got <- b
+	}()
+
+	// Grow the file, then stop: the follower must deliver the tail written
+	// after the request started and end once the file is closed.
+	time.Sleep(2 * followPoll)
+	file.Write([]byte("second-"))
+	time.Sleep(2 * followPoll)
+	file.Write([]byte("last"))
+	rec.mu.Lock()
+	rec.recording, rec.file = false, nil
+	rec.mu.Unlock()
+	file.Close()
+
+	select {
+	case b := <-got:
+		if want := []byte("first-second-last"); !bytes.Equal(b, want) {
+			t.Fatalf("body %q, want %q", b, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("follower did not finish after stop")
+	}
+}
+
+func TestOpenActive_OtherFile(t *testing.T) {
+	rec := NewRecorder(t.TempDir(), "/nonexistent")
+	if _, _, err := rec.OpenActive("recording_20261016_120000.hevc"); err != errNotActive {
+		t.Fatalf("idle recorder: err = %v, want errNotActive", err)
+	}
+}
@@ -0,0 +1,116 @@
+package webmonitor
+
+import (
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+// lightingIndexFile, inside the recordings directory, maps each recording
+// (by name without extension, so raw and MP4 share an entry) to the
+// lighting it was recorded in.
+const lightingIndexFile = ".lighting.json"
+
+// recordingLighting classifies a recording by the cameras its frames came
+// from: mixed when the minority is at least a tenth of the frames, "" for a
+// recording without frames.
+func recordingLighting(day, night uint64) Lighting {
+	total := day + night
+	switch {
+	case total == 0:
+		return ""
+	case min(day, night)*10 >= total:
+		return LightingMixed
+	case night > day:
+		return LightingNight
+	default:
+		return LightingDay
+	}
+}
+
+func recordingStem(name string) string {
+	return name[:len(name)-len(filepath.Ext(name))]
+}
+
+// countLightingLocked counts a written frame from cameraID. Caller holds r.mu.
+func (r *Recorder) countLightingLocked(cameraID int) {
+	if lightingForCamera(cameraID) == LightingNight {
+		r.nightFrames++
+	} else {
+		r.dayFrames++
+	}
+}
+
+// loadIndex reads the index file name of r's recordings directories,
+// merged when there are failover paths (earlier paths win); a missing or
+// damaged file is an empty index. Caller holds r.indexMu.
+func loadIndex[T any](r *Recorder, name string) map[string]T {
+	index := map[string]T{}
+	dirs := r.dirs()
+	for i := len(dirs) - 1; i >= 0; i-- {
+		maps.Copy(index, loadIndexIn[T](dirs[i], name))
+	}
+	return index
+}
+
+func loadIndexIn[T any](dir, name string) map[string]T {
+	index := map[string]T{}
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err == nil {
+		json.Unmarshal(data, &index)
+	}
+	return index
+}
+
+// updateIndex sets the entry of recording in the index file name, next to
+// the recording, to v, or with remove deletes it wherever it is.
+func updateIndex[T any](r *Recorder, name, recording string, v T, remove bool) {
+	r.indexMu.Lock()
+	defer r.indexMu.Unlock()
+	stem := recordingStem(recording)
+	if !remove {
+		dir := r.recordingDir(recording)
+		index := loadIndexIn[T](dir, name)
+		index[stem] = v
+		saveIndex(dir, name, index)
+		return
+	}
+	for _, dir := range r.dirs() {
+		index := loadIndexIn[T](dir, name)
+		if _, ok := index[stem]; ok {
+			delete(index, stem)
+			saveIndex(dir, name, index)
+		}
+	}
+}
+
+func saveIndex[T any](dir, name string, index map[string]T) {
+	data, err := json.Marshal(index)
+	if err == nil {
+		path := filepath.Join(dir, name)
+		if err = os.WriteFile(path+".tmp", data, 0o644); err == nil {
+			err = os.Rename(path+".tmp", path)
+		}
+	}
+	if err != nil {
+		logger.Warn("Recorder", "Failed to update %s: %v", name, err)
+	}
+}
+
+// updateLightingIndex sets (or with "" removes) the lighting of recording.
+func (r *Recorder) updateLightingIndex(recording string, l Lighting) {
+	updateIndex(r, lightingIndexFile, recording, l, l == "")
+}
+
+// annotateLighting sets the Lighting of each listed recording.
+func (r *Recorder) annotateLighting(recordings []RecordingInfo) {
+	r.indexMu.Lock()
+	index := loadIndex[Lighting](r, lightingIndexFile)
+	r.indexMu.Unlock()
+	for i := range recordings {
+		recordings[i].Lighting = index[recordingStem(recordings[i].Name)]
+	}
+}
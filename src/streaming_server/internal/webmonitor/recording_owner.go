@@ -0,0 +1,139 @@
+package webmonitor
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	pb "github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/proto"
+)
+
+// Recording event types, appended when a recording starts or stops through
+// the API or a rule.
+const (
+	EventRecordingStarted = "recording_started"
+	EventRecordingStopped = "recording_stopped"
+)
+
+// ErrAlreadyRecording is returned by Recorder.Start while another recording
+// is active.
+var ErrAlreadyRecording = errors.New("already recording")
+
+// maxOwnerName bounds the client-supplied owner label, in characters.
+const maxOwnerName = 32
+
+// ruleOwnerID owns the recordings started by rules.
+const ruleOwnerID = "rules"
+
+// RecordingOwner attributes a recording to whoever started it. Browser
+// owners are identified by a hash of their session cookie, so status events
+// never carry the cookie itself; rules and resumed recordings use fixed IDs.
+type RecordingOwner struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	Via  string `json:"via,omitempty"` // external triggers: http or mqtt
+}
+
+// RecordingStatus is the recording summary carried in status events, so
+// every open dashboard shows a recording no matter who started it.
+type RecordingStatus struct {
+	Active    bool           `json:"active"`
+	File      string         `json:"file,omitempty"`
+	Owner     RecordingOwner `json:"owner"`
+	StartedAt float64        `json:"started_at,omitempty"` // Unix seconds
+	EventID   string         `json:"event_id,omitempty"`   // trigger's correlation ID
+}
+
+// RecordingStatus returns the active recording, if any.
+func (r *Recorder) RecordingStatus() RecordingStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if !r.recording {
+		return RecordingStatus{}
+	}
+	return RecordingStatus{
+		Active:    true,
+		File:      r.filename,
+		Owner:     r.owner,
+		StartedAt: float64(r.startTime.Unix()),
+		EventID:   r.eventID,
+	}
+}
+
+func (rs RecordingStatus) toProto() *pb.RecordingState {
+	return &pb.RecordingState{
+		Active:    rs.Active,
+		File:      rs.File,
+		Owner:     &pb.RecordingOwner{Id: rs.Owner.ID, Name: rs.Owner.Name},
+		StartedAt: rs.StartedAt,
+		EventId:   rs.EventID,
+	}
+}
+
+// requestOwner identifies the browser behind r as a recording owner. The
+// optional JSON body {"name": "..."} labels it for the other viewers.
+func (s *Server) requestOwner(w http.ResponseWriter, r *http.Request) RecordingOwner {
+	owner := RecordingOwner{ID: anonViewerID("session", s.getSessionID(w, r))}
+	var body struct {
+		Name string `json:"name"`
+	}
+	if data, err := io.ReadAll(io.LimitReader(r.Body, 1024)); err == nil && len(data) > 0 {
+		if json.Unmarshal(data, &body) == nil {
+			name := []rune(strings.TrimSpace(body.Name))
+			owner.Name = string(name[:min(len(name), maxOwnerName)])
+		}
+	}
+	return owner
+}
+
+// startRecording starts a recording for owner, caused by the trigger
+// eventID, and announces it to every dashboard through the event stream.
+func (s *Server) startRecording(owner RecordingOwner, eventID string) (string, error) {
+	filename, err := s.recorder.Start(owner, eventID)
+	if err != nil {
+		return "", err
+	}
+	s.events.Append(Event{
+		EventID: eventID,
+		Type:    EventRecordingStarted,
+		Data:    map[string]string{"file": filename, "owner": owner.ID, "owner_name": owner.Name},
+	})
+	go s.saveState()
+	return filename, nil
+}
+
+// stopRecording stops the active recording on behalf of by, which need not
+// be the owner: anyone in the household can stop a recording.
+func (s *Server) stopRecording(by RecordingOwner) (string, error) {
+	return s.endRecording(by, s.recorder.Stop)
+}
+
+// endRecording stops the active recording with stop and announces it.
+func (s *Server) endRecording(by RecordingOwner, stop func() (string, error)) (string, error) {
+	rs := s.recorder.RecordingStatus()
+	filename, err := stop()
+	if err != nil {
+		return "", err
+	}
+	s.events.Append(Event{
+		EventID: rs.EventID,
+		Type:    EventRecordingStopped,
+		Data:    map[string]string{"file": filename, "owner": rs.Owner.ID, "stopped_by": by.ID},
+	})
+	go s.saveState()
+	return filename, nil
+}
+
+// writeRecordingConflict answers a start attempt while another recording is
+// active with 409 and the current owner.
+func (s *Server) writeRecordingConflict(w http.ResponseWriter) {
+	rs := s.recorder.RecordingStatus()
+	writeJSONWithStatus(w, map[string]any{
+		"error":      ErrAlreadyRecording.Error(),
+		"file":       rs.File,
+		"owner":      rs.Owner,
+		"started_at": rs.StartedAt,
+	}, http.StatusConflict)
+}
@@ -0,0 +1,72 @@
+package webmonitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleRecordingStart_ConflictReportsOwner(t *testing.T) {
+	rec := NewRecorder(t.TempDir(), "/nonexistent")
+	owner := RecordingOwner{ID: anonViewerID("session", "first"), Name: "Mom"}
+	rec.recording = true
+	rec.filename = "recording_20261016_120000.hevc"
+	rec.startTime = time.Unix(1760600000, 0)
+	rec.owner = owner
+
+	s := &Server{recorder: rec, events: NewEventStore(time.Hour)}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/recording/start", strings.NewReader(`{"name":"Dad"}`))
+	req.AddCookie(&http.Cookie{Name: "stream_sid", Value: "second"})
+	w := httptest.NewRecorder()
+	s.handleRecordingStart(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want 409", w.Code)
+	}
+	var body struct {
+		File  string         `json:"file"`
+		Owner RecordingOwner `json:"owner"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Owner != owner || body.File != rec.filename {
+		t.Fatalf("conflict body = %+v, want owner %+v file %s", body, owner, rec.filename)
+	}
+	if got := s.events.Query(0, []string{EventRecordingStarted}, 0); len(got) != 0 {
+		t.Fatalf("conflict appended %d recording_started events", len(got))
+	}
+
+	st := rec.RecordingStatus()
+	if !st.Active || st.Owner != owner || st.StartedAt != 1760600000 {
+		t.Fatalf("RecordingStatus() = %+v", st)
+	}
+	if pb := st.toProto(); pb.Owner.GetName() != "Mom" || pb.GetFile() != rec.filename {
+		t.Fatalf("toProto() = %+v", pb)
+	}
+}
+
+func TestRequestOwner(t *testing.T) {
+	s := &Server{}
+	req := httptest.NewRequest(http.MethodPost, "/api/recording/start",
+		strings.NewReader(`{"name":"  a very long household member name that is cut  "}`))
+	req.AddCookie(&http.Cookie{Name: "stream_sid", Value: "abc"})
+	owner := s.requestOwner(httptest.NewRecorder(), req)
+	if owner.ID != anonViewerID("session", "abc") {
+		t.Fatalf("ID = %q", owner.ID)
+	}
+	if len(owner.Name) != maxOwnerName || strings.HasPrefix(owner.Name, " ") {
+		t.Fatalf("Name = %q", owner.Name)
+	}
+
+	// Empty body (the original UI) still yields an owner.
+	req = httptest.NewRequest(http.MethodPost, "/api/recording/start", nil)
+	req.AddCookie(&http.Cookie{Name: "stream_sid", Value: "abc"})
+	if got := s.requestOwner(httptest.NewRecorder(), req); got.ID != owner.ID || got.Name != "" {
+		t.Fatalf("empty body owner = %+v", got)
+	}
+}
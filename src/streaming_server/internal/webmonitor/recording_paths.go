@@ -0,0 +1,341 @@
+package webmonitor
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+// EventRecordingPathChanged is appended when recordings move to another
+// recording path, with data from, to and reason (failover or recovery).
+const EventRecordingPathChanged = "recording_path_changed"
+
+// recordingPathAlertKey is the alert raised while recording on a fallback
+// path or on none.
+const recordingPathAlertKey = "recording:path"
+
+// failoverOwnerID stops a recording moved to another path.
+const failoverOwnerID = "failover"
+
+// Limits of the failover.
+const (
+	recordingProbeTimeout = 5 * time.Second  // a hung drive counts as failed
+	failoverWait          = 30 * time.Second // for the stopped segment's conversion to give up
+)
+
+// ParseRecordingPaths returns the primary path followed by the
+// comma-separated fallbacks, cleaned and without duplicates.
+func ParseRecordingPaths(primary, fallbacks string) []string {
+	paths := []string{filepath.Clean(primary)}
+	for _, p := range strings.Split(fallbacks, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		if p = filepath.Clean(p); !slices.Contains(paths, p) {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// RecordingPathStatus is one path in GET /api/recording/paths.
+type RecordingPathStatus struct {
+	Path      string  `json:"path"`
+	Healthy   bool    `json:"healthy"`
+	Active    bool    `json:"active"`
+	Error     string  `json:"error,omitempty"`
+	CheckedAt float64 `json:"checked_at,omitempty"` // Unix seconds
+}
+
+// RecordingPaths keeps recordings on the first healthy of an ordered list of
+// directories, e.g. a USB drive, then internal storage. Each check writes
+// and syncs a probe file in every path. When the active path fails,
+// switchTo moves recordings (an active one included) to the first healthy
+// path; once an earlier path is healthy again, they move back as soon as
+// the recorder is idle.
+type RecordingPaths struct {
+	Interval time.Duration
+
+	paths    []string
+	switchTo func(from, to string, failover bool) error
+	alerts   *AlertCenter
+	probe    func(dir string, create bool) error
+
+	mu        sync.Mutex
+	status    []RecordingPathStatus
+	active    int
+	failovers int
+	stop      chan struct{}
+	stopped   bool
+}
+
+// NewRecordingPaths watches paths, the first one active. switchTo moves
+// recordings between them; alerts (optional) says while no recording is on
+// the first path.
+func NewRecordingPaths(paths []string, switchTo func(from, to string, failover bool) error, alerts *AlertCenter) *RecordingPaths {
+	p := &RecordingPaths{
+		Interval: 10 * time.Second,
+		paths:    paths,
+		switchTo: switchTo,
+		alerts:   alerts,
+		probe:    probeRecordingPath,
+		status:   make([]RecordingPathStatus, len(paths)),
+		stop:     make(chan struct{}),
+	}
+	for i, dir := range paths {
+		p.status[i] = RecordingPathStatus{Path: dir, Active: i == 0}
+	}
+	return p
+}
+
+// Start begins checking.
+func (p *RecordingPaths) Start() {
+	go p.run()
+}
+
+// Stop halts checking.
+func (p *RecordingPaths) Stop() {
+	p.mu.Lock()
+	if !p.stopped {
+		close(p.stop)
+		p.stopped = true
+	}
+	p.mu.Unlock()
+}
+
+func (p *RecordingPaths) run() {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.Check()
+		}
+	}
+}
+
+// Check probes every path and moves recordings if needed.
+func (p *RecordingPaths) Check() {
+	errs := make([]error, len(p.paths))
+	var wg sync.WaitGroup
+	for i, dir := range p.paths {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = p.probeWithTimeout(dir, i == len(p.paths)-1)
+		}()
+	}
+	wg.Wait()
+
+	now := float64(time.Now().UnixNano()) / 1e9
+	want := -1
+	p.mu.Lock()
+	for i, err := range errs {
+		st := &p.status[i]
+		st.Healthy, st.Error, st.CheckedAt = err == nil, "", now
+		if err != nil {
+			st.Error = err.Error()
+		} else if want < 0 {
+			want = i
+		}
+	}
+	active := p.active
+	p.mu.Unlock()
+
+	failover := errs[active] != nil
+	switch {
+	case want < 0:
+		logger.Warn("Recorder", "No recording path is writable: %v", errors.Join(errs...))
+		p.raise(AlertCritical, msg("alert.recording_no_path", p.paths[active], errs[active].Error()))
+		return
+	case want == active:
+		p.alert(active, errs)
+		return
+	case !failover && want > active:
+		return // a later path recovered; stay where we are
+	}
+
+	if err := p.switchTo(p.paths[active], p.paths[want], failover); err != nil {
+		if failover || !errors.Is(err, errBusy) {
+			logger.Warn("Recorder", "Failed to move recordings from %s to %s: %v", p.paths[active], p.paths[want], err)
+		}
+		return
+	}
+	if failover {
+		logger.Warn("Recorder", "Recording path %s failed (%v), recording to %s", p.paths[active], errs[active], p.paths[want])
+	} else {
+		logger.Info("Recorder", "Recording path %s is back, recording to it again", p.paths[want])
+	}
+
+	p.mu.Lock()
+	p.status[active].Active, p.status[want].Active = false, true
+	p.active = want
+	if failover {
+		p.failovers++
+	}
+	p.mu.Unlock()
+	p.alert(want, errs)
+}
+
+// alert raises the fallback alert while active is not the first path.
+func (p *RecordingPaths) alert(active int, errs []error) {
+	if active == 0 {
+		if p.alerts != nil {
+			p.alerts.Resolve(recordingPathAlertKey)
+		}
+		return
+	}
+	reason := "unavailable"
+	if errs[0] != nil {
+		reason = errs[0].Error()
+	}
+	p.raise(AlertWarning, msg("alert.recording_fallback", p.paths[active], p.paths[0], reason))
+}
+
+func (p *RecordingPaths) raise(severity string, text Message) {
+	if p.alerts != nil {
+		p.alerts.RaiseMessage("disk", recordingPathAlertKey, severity, text)
+	}
+}
+
+func (p *RecordingPaths) probeWithTimeout(dir string, create bool) error {
+	done := make(chan error, 1)
+	go func() { done <- p.probe(dir, create) }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(recordingProbeTimeout):
+		return fmt.Errorf("%s: no response in %v", dir, recordingProbeTimeout)
+	}
+}
+
+// Status returns every path, in order.
+func (p *RecordingPaths) Status() []RecordingPathStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]RecordingPathStatus, len(p.status))
+	copy(out, p.status)
+	return out
+}
+
+// Failovers returns how often the active path failed since start.
+func (p *RecordingPaths) Failovers() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.failovers
+}
+
+// probeRecordingPath checks that dir is a directory taking a synced write.
+// Only the last path is created: the directory of an unmounted drive must
+// not be recreated on the filesystem under its mount point.
+func probeRecordingPath(dir string, create bool) error {
+	if create {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	f, err := os.CreateTemp(dir, ".probe-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString("ok"); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// switchRecordingPath moves recordings from one path to another. On
+// failover the active recording is stopped and continued on the new path
+// under the same owner and event ID, so a timed recording keeps its
+// deadline and both segments share the event; otherwise the move waits for
+// the recorder to be idle (errBusy).
+func (s *Server) switchRecordingPath(from, to string, failover bool) error {
+	if !failover {
+		if err := s.recorder.SetOutputPath(to); err != nil {
+			return err
+		}
+		s.appendPathChanged(from, to, "recovery")
+		return nil
+	}
+
+	// Held throughout, so a timed recording does not see the gap
+	s.timed.mu.Lock()
+	defer s.timed.mu.Unlock()
+	rs := s.recorder.RecordingStatus()
+	if rs.Active {
+		if _, err := s.endRecording(RecordingOwner{ID: failoverOwnerID}, s.recorder.Stop); err != nil {
+			logger.Warn("Recorder", "Failed to stop %s on %s: %v", rs.File, from, err)
+		}
+	}
+	// The segment's conversion fails fast on a lost drive; wait it out
+	deadline := time.Now().Add(failoverWait)
+	for {
+		err := s.recorder.SetOutputPath(to)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errBusy) || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	s.appendPathChanged(from, to, "failover")
+	if rs.Active {
+		if _, err := s.startRecording(rs.Owner, rs.EventID); err != nil {
+			logger.Warn("Recorder", "Failed to continue %s on %s: %v", rs.File, to, err)
+		}
+	}
+	return nil
+}
+
+func (s *Server) appendPathChanged(from, to, reason string) {
+	s.events.Append(Event{
+		Type: EventRecordingPathChanged,
+		Data: map[string]string{"from": from, "to": to, "reason": reason},
+	})
+}
+
+// handleRecordingPaths serves GET /api/recording/paths: the recording paths
+// in order, their health and the active one.
+func (s *Server) handleRecordingPaths(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.recordingPaths == nil {
+		// A single path: checked now, never failed over
+		st := RecordingPathStatus{Path: s.recorder.dir(), Healthy: true, Active: true, CheckedAt: float64(time.Now().UnixNano()) / 1e9}
+		if err := probeRecordingPath(st.Path, false); err != nil {
+			st.Healthy, st.Error = false, err.Error()
+		}
+		writeJSON(w, map[string]any{"paths": []RecordingPathStatus{st}, "failovers": 0})
+		return
+	}
+	writeJSON(w, map[string]any{
+		"paths":     s.recordingPaths.Status(),
+		"failovers": s.recordingPaths.Failovers(),
+	})
+}
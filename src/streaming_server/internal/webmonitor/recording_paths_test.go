@@ -0,0 +1,138 @@
+package webmonitor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/storage"
+)
+
+func TestParseRecordingPaths(t *testing.T) {
+	got := ParseRecordingPaths("./recordings", " /mnt/usb/rec/, ,recordings,/data/rec")
+	want := []string{"recordings", "/mnt/usb/rec", "/data/rec"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRecordingPathsCheck(t *testing.T) {
+	failed := map[string]bool{}
+	var moves []string
+	busy := false
+	alerts := NewAlertCenter()
+	p := NewRecordingPaths([]string{"usb", "emmc"}, func(from, to string, failover bool) error {
+		if busy && !failover {
+			return errBusy
+		}
+		moves = append(moves, from+">"+to)
+		return nil
+	}, alerts)
+	p.probe = func(dir string, create bool) error {
+		if create != (dir == "emmc") {
+			t.Errorf("probe %s create=%v", dir, create)
+		}
+		if failed[dir] {
+			return errors.New("input/output error")
+		}
+		return nil
+	}
+	alertMessage := func() string {
+		active, _ := alerts.Active()
+		if len(active) == 0 {
+			return ""
+		}
+		return active[0].Severity + ": " + active[0].Message
+	}
+
+	p.Check()
+	if len(moves) != 0 || alertMessage() != "" {
+		t.Fatalf("healthy: moves %q, alert %q", moves, alertMessage())
+	}
+
+	failed["usb"] = true
+	p.Check()
+	st := p.Status()
+	if !slices.Equal(moves, []string{"usb>emmc"}) || st[0].Healthy || st[0].Active || !st[1].Active || st[0].Error == "" {
+		t.Fatalf("failover: moves %q, status %+v", moves, st)
+	}
+	if p.Failovers() != 1 || alertMessage() != "warning: Recording to fallback path emmc: usb unavailable (input/output error)" {
+		t.Errorf("failover: %d, alert %q", p.Failovers(), alertMessage())
+	}
+
+	failed["emmc"] = true
+	p.Check()
+	if alertMessage() != "critical: No recording path is writable: emmc failed (input/output error)" {
+		t.Errorf("all failed: alert %q", alertMessage())
+	}
+
+	// Recovery waits for the recorder to be idle
+	failed["usb"], failed["emmc"], busy = false, false, true
+	p.Check()
+	if len(moves) != 1 || !p.Status()[1].Active {
+		t.Fatalf("recovery while busy: moves %q", moves)
+	}
+	busy = false
+	p.Check()
+	if !slices.Equal(moves, []string{"usb>emmc", "emmc>usb"}) || !p.Status()[0].Active || p.Failovers() != 1 || alertMessage() != "" {
+		t.Errorf("recovery: moves %q, status %+v, alert %q", moves, p.Status(), alertMessage())
+	}
+}
+
+func TestProbeRecordingPath(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "rec")
+	if err := probeRecordingPath(dir, false); err == nil {
+		t.Error("missing path not created, want error")
+	}
+	if err := probeRecordingPath(dir, true); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("probe left %v", entries)
+	}
+}
+
+func TestRecorderSwitchPath(t *testing.T) {
+	usb, emmc := t.TempDir(), t.TempDir()
+	r := NewRecorder(usb, "")
+	r.SetStorage(storage.NewPaths(usb, emmc))
+
+	r.recording = true
+	if err := r.SetOutputPath(emmc); !errors.Is(err, errBusy) {
+		t.Fatalf("while recording: %v", err)
+	}
+	r.recording = false
+	if err := r.SetOutputPath(t.TempDir()); err == nil {
+		t.Error("switched to an unknown path")
+	}
+	if err := r.SetOutputPath(emmc); err != nil || r.dir() != emmc {
+		t.Fatalf("switch: %v, dir %s", err, r.dir())
+	}
+
+	// Clips are indexed next to the recording and listed with its path
+	os.WriteFile(filepath.Join(usb, "recording_20261016_070000.mp4"), []byte("a"), 0o644)
+	os.WriteFile(filepath.Join(emmc, "recording_20261016_080000.mp4"), []byte("b"), 0o644)
+	r.updateLightingIndex("recording_20261016_070000.mp4", LightingNight)
+	r.updateLightingIndex("recording_20261016_080000.mp4", LightingDay)
+	r.indexStoppedLocked("recording_20261016_080000.hevc")
+	if _, err := os.Stat(filepath.Join(usb, lightingIndexFile)); err != nil {
+		t.Errorf("usb index: %v", err)
+	}
+	recs := []RecordingInfo{{Name: "recording_20261016_070000.mp4"}, {Name: "recording_20261016_080000.mp4"}}
+	r.annotateLighting(recs)
+	r.annotateClipStats(recs)
+	if recs[0].Lighting != LightingNight || recs[1].Lighting != LightingDay || recs[1].Path != emmc {
+		t.Errorf("recordings %+v", recs)
+	}
+	if got, err := r.GetRecordingPath("recording_20261016_070000.mp4"); err != nil || got != filepath.Join(usb, "recording_20261016_070000.mp4") {
+		t.Errorf("path %s: %v", got, err)
+	}
+
+	r.updateLightingIndex("recording_20261016_070000.mp4", "")
+	r.annotateLighting(recs)
+	if recs[0].Lighting != "" {
+		t.Errorf("removed lighting %q", recs[0].Lighting)
+	}
+}
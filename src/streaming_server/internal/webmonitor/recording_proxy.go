@@ -0,0 +1,162 @@
+package webmonitor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/codec"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/shm"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/types"
+)
+
+// proxyInfix marks the low-resolution proxy of a recording:
+// recording_<stamp>.proxy.hevc while recording, recording_<stamp>.proxy.mp4
+// once finished. It is listed with its main recording, not on its own.
+const proxyInfix = ".proxy"
+
+// proxyName returns the proxy file name for a raw or MP4 recording.
+func proxyName(recording string) string {
+	ext := filepath.Ext(recording)
+	return recording[:len(recording)-len(ext)] + proxyInfix + ext
+}
+
+// isProxyName reports whether name is a proxy file.
+func isProxyName(name string) bool {
+	ext := filepath.Ext(name)
+	return strings.HasSuffix(name[:len(name)-len(ext)], proxyInfix)
+}
+
+// proxyTrack records the substream next to the main recording. Its loop
+// owns the file and reader and closes done once both are closed.
+type proxyTrack struct {
+	filename string // guarded by Recorder.mu (renamed on clock jumps)
+	file     *os.File
+	reader   *shm.Reader
+	bytes    atomic.Uint64
+	done     chan struct{}
+}
+
+// SetProxyShm makes each recording also record the low-resolution H.265
+// substream in shmName, when the camera publishes one, as a proxy file for
+// editing over slow links. Call before recording.
+func (r *Recorder) SetProxyShm(shmName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.proxyShmName = shmName
+}
+
+// startProxyLocked starts the proxy of the recording just started, if the
+// substream exists. r.mu must be held.
+func (r *Recorder) startProxyLocked() {
+	r.proxy = nil
+	if r.proxyShmName == "" {
+		return
+	}
+	reader, err := shm.NewReader(r.proxyShmName)
+	if err != nil {
+		logger.Info("Recorder", "No proxy substream (%s): %v", r.proxyShmName, err)
+		return
+	}
+	name := proxyName(r.filename)
+	file, err := os.Create(filepath.Join(r.dir(), name))
+	if err != nil {
+		reader.Close()
+		logger.Warn("Recorder", "Failed to create proxy file: %v", err)
+		return
+	}
+	p := &proxyTrack{filename: name, file: file, reader: reader, done: make(chan struct{})}
+	r.proxy = p
+	go r.proxyLoop(p, r.stopCh)
+	logger.Info("Recorder", "Recording proxy %s from %s", name, r.proxyShmName)
+}
+
+// proxyLoop writes substream frames to the proxy file from its first IDR
+// until stop is closed.
+func (r *Recorder) proxyLoop(p *proxyTrack, stop <-chan struct{}) {
+	defer close(p.done)
+	defer p.reader.Close()
+	defer p.file.Close()
+
+	ticker := time.NewTicker(33 * time.Millisecond)
+	defer ticker.Stop()
+	processor := codec.NewProcessor()
+	started := false
+	var lastFrameNum uint64
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		ch := make(chan *types.VideoFrame, 1)
+		go func() {
+			f, err := p.reader.ReadLatest()
+			if err != nil {
+				logger.Debug("Recorder", "Proxy read error: %v", err)
+			}
+			ch <- f
+		}()
+		var frame *types.VideoFrame
+		select {
+		case <-stop:
+			<-ch // the reader is closed on return
+			return
+		case frame = <-ch:
+		}
+		if frame == nil || frame.FrameNumber == lastFrameNum {
+			continue
+		}
+		lastFrameNum = frame.FrameNumber
+
+		if err := processor.Process(frame); err != nil {
+			logger.Debug("Recorder", "Proxy process error: %v", err)
+		}
+		if !started {
+			if !frame.IsIDR {
+				continue
+			}
+			if headers, _ := processor.PrependHeaders(frame.Data); len(headers) > len(frame.Data) {
+				frame.Data = headers
+			}
+			started = true
+		}
+		n, err := p.file.Write(frame.Data)
+		p.bytes.Add(uint64(n))
+		if err != nil {
+			logger.Warn("Recorder", "Proxy write error: %v", err)
+		}
+	}
+}
+
+// finalizeProxy remuxes a raw proxy file to MP4, tagged with the recording
+// it belongs to, and uploads it like finalizeRaw does the main file.
+func (r *Recorder) finalizeProxy(rawName string, startedAt time.Time) {
+	rawPath := filepath.Join(r.dir(), rawName)
+	ext := filepath.Ext(rawName)
+	mp4Name := rawName[:len(rawName)-len(ext)] + ".mp4"
+	mp4Path := filepath.Join(r.dir(), mp4Name)
+	main := strings.TrimSuffix(mp4Name[:len(mp4Name)-len(".mp4")], proxyInfix) + ".mp4"
+
+	if err := remuxMP4(rawPath, mp4Path, startedAt, []string{"comment=proxy of: " + main}, nil); err != nil {
+		logger.Warn("Recorder", "Proxy MP4 conversion failed: %v", err)
+		return
+	}
+	if err := writeChecksum(mp4Path); err != nil {
+		logger.Warn("Recorder", "Failed to write checksum: %v", err)
+	}
+	if err := os.Remove(rawPath); err != nil {
+		logger.Warn("Recorder", "Failed to delete raw proxy: %v", err)
+	}
+	logger.Info("Recorder", "Proxy MP4 conversion complete: %s", mp4Name)
+
+	if r.isRemote() {
+		r.upload(mp4Path)
+		r.upload(filepath.Join(r.dir(), checksumName(mp4Name)))
+	}
+}
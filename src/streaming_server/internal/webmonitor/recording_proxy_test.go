@@ -0,0 +1,89 @@
+package webmonitor
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProxyName(t *testing.T) {
+	for in, want := range map[string]string{
+		"recording_20261016_120000.hevc": "recording_20261016_120000.proxy.hevc",
+		"recording_20261016_120000.mp4":  "recording_20261016_120000.proxy.mp4",
+	} {
+		if got := proxyName(in); got != want {
+			t.Errorf("proxyName(%q) = %q, want %q", in, got, want)
+		}
+		if isProxyName(in) || !isProxyName(want) {
+			t.Errorf("isProxyName wrong for %q / %q", in, want)
+		}
+	}
+}
+
+func TestRecordingProxy_ListDownloadDelete(t *testing.T) {
+	dir := t.TempDir()
+	rec := NewRecorder(dir, "/nonexistent")
+	files := map[string]string{
+		"recording_1.mp4":          "full",
+		"recording_1.proxy.mp4":    "proxy",
+		"recording_1.proxy.sha256": "x",
+		"recording_2.mp4":          "no proxy",
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recs, err := rec.ListRecordings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxies := map[string]string{}
+	for _, r := range recs {
+		proxies[r.Name] = r.Proxy
+	}
+	if len(recs) != 2 || proxies["recording_1.mp4"] != "recording_1.proxy.mp4" || proxies["recording_2.mp4"] != "" {
+		t.Fatalf("ListRecordings = %+v", recs)
+	}
+
+	s := &Server{recorder: rec}
+	srv := httptest.NewServer(http.HandlerFunc(s.handleRecordingDownload))
+	defer srv.Close()
+	get := func(query string) (int, string) {
+		resp, err := http.Get(srv.URL + "/api/recordings/" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(b)
+	}
+	for query, want := range map[string]string{
+		"recording_1.mp4":               "full",
+		"recording_1.mp4?quality=full":  "full",
+		"recording_1.mp4?quality=proxy": "proxy",
+	} {
+		if code, body := get(query); code != http.StatusOK || body != want {
+			t.Errorf("GET %s = %d %q, want %q", query, code, body, want)
+		}
+	}
+	if code, _ := get("recording_2.mp4?quality=proxy"); code != http.StatusNotFound {
+		t.Errorf("missing proxy: %d", code)
+	}
+	if code, _ := get("recording_1.mp4?quality=4k"); code != http.StatusBadRequest {
+		t.Errorf("unknown quality: %d", code)
+	}
+
+	if err := rec.DeleteRecording("recording_1.mp4"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"recording_1.proxy.mp4", "recording_1.proxy.sha256"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s left after delete", name)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package webmonitor
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+// detectionSidecarExt names the detection log kept next to each recording
+// (recording_<stamp>.detections.jsonl). It is appended while recording, so a
+// clip recovered after a crash still has it.
+const detectionSidecarExt = ".detections.jsonl"
+
+// sidecarInterval limits the log to about two lines per second; a detection
+// more confident than any logged so far is always written.
+const sidecarInterval = 500 * time.Millisecond
+
+// sidecarEntry is one line of the detection sidecar: the most confident
+// detection of a result, t seconds into the recording.
+type sidecarEntry struct {
+	Offset     float64     `json:"t"`
+	Class      string      `json:"class"`
+	Confidence float64     `json:"confidence"`
+	BBox       BoundingBox `json:"bbox"`
+}
+
+// sidecarName returns the sidecar file name for a raw or MP4 recording.
+func sidecarName(recording string) string {
+	return recording[:len(recording)-len(filepath.Ext(recording))] + detectionSidecarExt
+}
+
+// ObserveDetection appends det's most confident detection to the active
+// recording's sidecar.
+func (r *Recorder) ObserveDetection(det *DetectionResult) {
+	if det == nil || len(det.Detections) == 0 {
+		return
+	}
+	best := det.Detections[0]
+	for _, d := range det.Detections[1:] {
+		if d.Confidence > best.Confidence {
+			best = d
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.recording {
+		return
+	}
+	offset := time.Since(r.startTime)
+	if r.sidecar != nil && offset-r.sidecarLast < sidecarInterval && best.Confidence <= r.sidecarBest {
+		return
+	}
+	if r.sidecar == nil {
+		f, err := os.OpenFile(filepath.Join(r.dir(), sidecarName(r.filename)), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			logger.Warn("Recorder", "Failed to open detection sidecar: %v", err)
+			return
+		}
+		r.sidecar = f
+	}
+	line, _ := json.Marshal(sidecarEntry{
+		Offset:     offset.Seconds(),
+		Class:      best.ClassName,
+		Confidence: best.Confidence,
+		BBox:       best.BBox,
+	})
+	if _, err := r.sidecar.Write(append(line, '\n')); err != nil {
+		logger.Warn("Recorder", "Failed to write detection sidecar: %v", err)
+		return
+	}
+	r.sidecarLast = offset
+	r.sidecarBest = max(r.sidecarBest, best.Confidence)
+}
+
+// closeSidecarLocked closes the active sidecar. Caller holds r.mu.
+func (r *Recorder) closeSidecarLocked() {
+	if r.sidecar != nil {
+		r.sidecar.Close()
+		r.sidecar = nil
+	}
+	r.sidecarLast = 0
+	r.sidecarBest = 0
+}
+
+// bestDetectionOffset returns the offset of the most confident detection in
+// a sidecar. Malformed lines (a write cut short by a crash) are skipped.
+func bestDetectionOffset(path string) (float64, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+
+	var best sidecarEntry
+	found := false
+	for _, e := range readSidecar(f) {
+		if !found || e.Confidence > best.Confidence {
+			best, found = e, true
+		}
+	}
+	return best.Offset, found
+}
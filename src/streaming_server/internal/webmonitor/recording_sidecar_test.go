@@ -0,0 +1,67 @@
+package webmonitor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestObserveDetection_LogsBestAndThrottles(t *testing.T) {
+	dir := t.TempDir()
+	rec := NewRecorder(dir, "/nonexistent")
+	rec.recording = true
+	rec.filename = "recording_20261016_120000.hevc"
+	rec.startTime = time.Now()
+
+	result := func(conf ...float64) *DetectionResult {
+		det := &DetectionResult{}
+		for _, c := range conf {
+			det.Detections = append(det.Detections, Detection{ClassName: "cat", Confidence: c})
+		}
+		return det
+	}
+	rec.ObserveDetection(result(0.4, 0.6)) // first: written (0.6)
+	rec.ObserveDetection(result(0.5))      // throttled: not more confident
+	rec.ObserveDetection(result(0.9))      // more confident: written at once
+	rec.ObserveDetection(&DetectionResult{})
+	rec.mu.Lock()
+	rec.closeSidecarLocked()
+	rec.mu.Unlock()
+
+	path := filepath.Join(dir, "recording_20261016_120000"+detectionSidecarExt)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Fatalf("sidecar has %d lines, want 2:\n%s", lines, data)
+	}
+
+	// A torn last line (crash mid-write) is ignored.
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"t":9,"confidence":0.99`)
+	f.Close()
+	if _, ok := bestDetectionOffset(path); !ok {
+		t.Fatal("bestDetectionOffset found nothing")
+	}
+	seek := thumbnailSeekTimes(path, 5)
+	if len(seek) != 2 || seek[0] == "9.00" || seek[0] == "5.00" || seek[1] != "0" {
+		t.Fatalf("seek times %v, want [<best logged offset> 0]", seek)
+	}
+}
+
+func TestThumbnailSeekTimes_Fallbacks(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "none"+detectionSidecarExt)
+	if got, want := thumbnailSeekTimes(missing, 2.5), []string{"2.50", "0"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("first detection fallback: got %v, want %v", got, want)
+	}
+	if got, want := thumbnailSeekTimes(missing, -1), []string{"0"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("first IDR fallback: got %v, want %v", got, want)
+	}
+	if got := sidecarName("recording_20261016_120000.mp4"); got != "recording_20261016_120000"+detectionSidecarExt {
+		t.Fatalf("sidecarName = %q", got)
+	}
+}
@@ -0,0 +1,217 @@
+package webmonitor
+
+import (
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/clock"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/storage"
+)
+
+// statsIndexFile, next to the lighting index, maps each recording to how it
+// was started and how long it ran.
+const statsIndexFile = ".stats.json"
+
+// Recording triggers counted by /api/recordings/stats.
+const (
+	TriggerManual   = "manual" // started from a browser or the API
+	TriggerRule     = "rule"
+	TriggerExternal = "external" // a named trigger fired over HTTP or MQTT
+	TriggerUnknown  = "unknown"  // recorded before triggers were indexed
+)
+
+// statsRateWindow is how far back the recording rate behind the headroom
+// prediction looks.
+const statsRateWindow = 7 * 24 * time.Hour
+
+type clipStats struct {
+	Trigger  string    `json:"trigger"`
+	Duration float64   `json:"duration"`           // seconds
+	EventID  string    `json:"event_id,omitempty"` // see Event.EventID
+	Cut      *CutPoint `json:"cut,omitempty"`
+
+	// Which external trigger started it, and how it arrived
+	TriggerName string `json:"trigger_name,omitempty"`
+	TriggerVia  string `json:"trigger_via,omitempty"`
+
+	Path string `json:"path,omitempty"` // recording path it landed on, with failover paths
+}
+
+func recordingTrigger(owner RecordingOwner) string {
+	switch {
+	case owner.ID == ruleOwnerID:
+		return TriggerRule
+	case strings.HasPrefix(owner.ID, triggerOwnerPrefix):
+		return TriggerExternal
+	}
+	return TriggerManual
+}
+
+// indexStoppedLocked saves the lighting, trigger, length and cut point of
+// the recording that just stopped and returns its lighting. Caller holds r.mu.
+func (r *Recorder) indexStoppedLocked(filename string) Lighting {
+	lighting := recordingLighting(r.dayFrames, r.nightFrames)
+	if lighting != "" {
+		r.updateLightingIndex(filename, lighting)
+	}
+	st := clipStats{
+		Trigger:  recordingTrigger(r.owner),
+		Duration: r.lastDuration.Seconds(),
+		EventID:  r.eventID,
+		Cut:      r.cutPointLocked(),
+	}
+	if st.Trigger == TriggerExternal {
+		st.TriggerName, st.TriggerVia = r.owner.Name, r.owner.Via
+	}
+	if r.paths.Load() != nil {
+		st.Path = r.dir()
+	}
+	updateIndex(r, statsIndexFile, filename, st, false)
+	return lighting
+}
+
+// annotateClipStats sets the EventID, Cut, external trigger and recording
+// path of each listed recording.
+func (r *Recorder) annotateClipStats(recordings []RecordingInfo) {
+	r.indexMu.Lock()
+	index := loadIndex[clipStats](r, statsIndexFile)
+	r.indexMu.Unlock()
+	for i := range recordings {
+		st := index[recordingStem(recordings[i].Name)]
+		recordings[i].EventID = st.EventID
+		recordings[i].Cut = st.Cut
+		recordings[i].TriggerName = st.TriggerName
+		recordings[i].TriggerVia = st.TriggerVia
+		recordings[i].Path = st.Path
+	}
+}
+
+// DayUsage is what was recorded on one local calendar day.
+type DayUsage struct {
+	Date  string `json:"date"`  // YYYY-MM-DD in the configured time zone
+	Bytes int64  `json:"bytes"` // every file: clips, proxies, thumbnails, sidecars
+	Clips int    `json:"clips"`
+}
+
+// StorageHeadroom predicts when the recordings volume fills up at the
+// current recording rate.
+type StorageHeadroom struct {
+	FreeBytes  uint64  `json:"free_bytes"`
+	SizeBytes  uint64  `json:"size_bytes"`
+	DailyBytes int64   `json:"daily_bytes"`         // average per day over the last week
+	DaysLeft   float64 `json:"days_left,omitempty"` // free / daily; absent while nothing is recorded
+}
+
+// RecordingStats is served by /api/recordings/stats.
+type RecordingStats struct {
+	Clips       int              `json:"clips"`
+	TotalBytes  int64            `json:"total_bytes"`
+	Days        []DayUsage       `json:"days"` // oldest first
+	Triggers    map[string]int   `json:"triggers"`
+	AvgDuration float64          `json:"avg_duration_sec,omitempty"` // over clips with a known length
+	Headroom    *StorageHeadroom `json:"headroom,omitempty"`         // absent without a local volume
+}
+
+// recordingStats aggregates the files of the recordings directory. A raw
+// file and its MP4 count as one clip. free and size describe the volume
+// (size 0 = unknown).
+func recordingStats(files []storage.FileInfo, index map[string]clipStats, now time.Time, loc *time.Location, free, size uint64) RecordingStats {
+	st := RecordingStats{Days: []DayUsage{}, Triggers: map[string]int{}}
+	days := map[string]*DayUsage{}
+	clips := map[string]bool{}
+	var durationSum float64
+	var timed int
+	var recent int64
+	oldest := now
+	for _, f := range files {
+		date := f.ModTime.In(loc).Format("2006-01-02")
+		day := days[date]
+		if day == nil {
+			day = &DayUsage{Date: date}
+			days[date] = day
+		}
+		day.Bytes += f.Size
+		st.TotalBytes += f.Size
+		if now.Sub(f.ModTime) < statsRateWindow {
+			recent += f.Size
+		}
+		if f.ModTime.Before(oldest) {
+			oldest = f.ModTime
+		}
+
+		ext := filepath.Ext(f.Name)
+		if ext != ".mp4" && ext != ".hevc" && ext != ".h264" || isProxyName(f.Name) {
+			continue
+		}
+		stem := recordingStem(f.Name)
+		if clips[stem] {
+			continue
+		}
+		clips[stem] = true
+		day.Clips++
+		st.Clips++
+		cs, ok := index[stem]
+		if !ok {
+			st.Triggers[TriggerUnknown]++
+			continue
+		}
+		st.Triggers[cs.Trigger]++
+		if cs.Duration > 0 {
+			durationSum += cs.Duration
+			timed++
+		}
+	}
+	for _, day := range days {
+		st.Days = append(st.Days, *day)
+	}
+	sort.Slice(st.Days, func(i, j int) bool { return st.Days[i].Date < st.Days[j].Date })
+	if timed > 0 {
+		st.AvgDuration = durationSum / float64(timed)
+	}
+
+	if size > 0 {
+		// A young directory is judged by the days it has existed (at least one)
+		window := max(min(now.Sub(oldest), statsRateWindow), 24*time.Hour)
+		h := &StorageHeadroom{FreeBytes: free, SizeBytes: size}
+		h.DailyBytes = int64(float64(recent) * float64(24*time.Hour) / float64(window))
+		if h.DailyBytes > 0 {
+			h.DaysLeft = float64(free) / float64(h.DailyBytes)
+		}
+		st.Headroom = h
+	}
+	return st
+}
+
+// Stats aggregates the recordings; free and size describe the volume
+// (size 0 = unknown).
+func (r *Recorder) Stats(now time.Time, free, size uint64) (RecordingStats, error) {
+	files, err := r.Storage().List()
+	if err != nil {
+		return RecordingStats{}, err
+	}
+	r.indexMu.Lock()
+	index := loadIndex[clipStats](r, statsIndexFile)
+	r.indexMu.Unlock()
+	return recordingStats(files, index, now, clock.Location(), free, size), nil
+}
+
+// handleRecordingStats serves GET /api/recordings/stats.
+func (s *Server) handleRecordingStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var free, size uint64
+	if s.diskMonitor != nil {
+		free, size = s.diskMonitor.Usage()
+	}
+	st, err := s.recorder.Stats(time.Now(), free, size)
+	if err != nil {
+		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, st)
+}
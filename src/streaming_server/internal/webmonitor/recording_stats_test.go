@@ -0,0 +1,78 @@
+package webmonitor
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/storage"
+)
+
+func TestRecordingStats(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	const mib = 1 << 20
+	files := []storage.FileInfo{
+		{Name: "recording_20260301_100000.mp4", Size: 300 * mib, ModTime: now.Add(-9 * day)},
+		{Name: "recording_20260301_100000.jpg", Size: mib, ModTime: now.Add(-9 * day)},
+		{Name: "recording_20260308_100000.mp4", Size: 100 * mib, ModTime: now.Add(-2 * day)},
+		{Name: proxyName("recording_20260308_100000.mp4"), Size: 10 * mib, ModTime: now.Add(-2 * day)},
+		{Name: "recording_20260310_100000.hevc", Size: 40 * mib, ModTime: now.Add(-time.Hour)},
+		{Name: "recording_20260310_100000.mp4", Size: 30 * mib, ModTime: now.Add(-time.Minute)},
+	}
+	index := map[string]clipStats{
+		"recording_20260308_100000": {Trigger: TriggerRule, Duration: 60},
+		"recording_20260310_100000": {Trigger: TriggerManual, Duration: 20},
+	}
+	st := recordingStats(files, index, now, time.UTC, 1400*mib, 4000*mib)
+
+	if st.Clips != 3 || st.TotalBytes != 481*mib {
+		t.Fatalf("clips %d, bytes %d", st.Clips, st.TotalBytes)
+	}
+	if len(st.Days) != 3 || st.Days[0].Date != "2026-03-01" || st.Days[0].Bytes != 301*mib || st.Days[2].Clips != 1 {
+		t.Fatalf("days %+v", st.Days)
+	}
+	if st.Triggers[TriggerRule] != 1 || st.Triggers[TriggerManual] != 1 || st.Triggers[TriggerUnknown] != 1 {
+		t.Errorf("triggers %v", st.Triggers)
+	}
+	if st.AvgDuration != 40 {
+		t.Errorf("average %v", st.AvgDuration)
+	}
+	// 180 MiB in the last week = ~25.7 MiB/day, so 1400 MiB last ~54 days
+	h := st.Headroom
+	if h == nil || h.DailyBytes != 180*mib/7 || math.Abs(h.DaysLeft-1400.0*7/180) > 0.01 {
+		t.Fatalf("headroom %+v", h)
+	}
+
+	// A day-old directory is judged by that one day; no volume, no prediction
+	st = recordingStats(files[4:], nil, now, time.UTC, 0, 0)
+	if st.Headroom != nil || st.Triggers[TriggerUnknown] != 1 {
+		t.Errorf("unknown volume: %+v", st)
+	}
+	if st = recordingStats(files[4:], nil, now, time.UTC, mib, mib); st.Headroom.DailyBytes != 70*mib {
+		t.Errorf("young directory rate %d", st.Headroom.DailyBytes)
+	}
+}
+
+func TestRecordingTrigger(t *testing.T) {
+	if recordingTrigger(RecordingOwner{ID: ruleOwnerID, Name: "cat seen"}) != TriggerRule ||
+		recordingTrigger(RecordingOwner{ID: "session:abc"}) != TriggerManual {
+		t.Error("wrong trigger")
+	}
+	r := NewRecorder(t.TempDir(), "")
+	r.owner = RecordingOwner{ID: ruleOwnerID}
+	r.lastDuration = 90 * time.Second
+	r.eventID = "3f9a0c1b2d4e5f60"
+	r.indexStoppedLocked("recording_20260101_220000.hevc")
+	r.indexMu.Lock()
+	index := loadIndex[clipStats](r, statsIndexFile)
+	r.indexMu.Unlock()
+	if cs := index["recording_20260101_220000"]; cs.Trigger != TriggerRule || cs.Duration != 90 {
+		t.Fatalf("index %v", index)
+	}
+	recs := []RecordingInfo{{Name: "recording_20260101_220000.mp4"}, {Name: "recording_20260101_230000.mp4"}}
+	r.annotateClipStats(recs)
+	if recs[0].EventID != r.eventID || recs[1].EventID != "" {
+		t.Errorf("event IDs %+v", recs)
+	}
+}
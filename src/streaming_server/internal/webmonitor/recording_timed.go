@@ -0,0 +1,134 @@
+package webmonitor
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/recorder"
+)
+
+// timedRecording is the deadline of the active recording when it was
+// started by POST /api/record or a rule: such a recording needs no
+// heartbeats and stops by itself at the end of the GOP running at its
+// deadline. The recording is identified by its event ID, which survives
+// the rename after a clock jump.
+type timedRecording struct {
+	mu       sync.Mutex
+	eventID  string // "" while no timed recording is active
+	until    time.Time
+	stopping bool // the deadline passed, the last GOP is being written
+}
+
+// TimedRecording is the POST /api/record response.
+type TimedRecording struct {
+	File     string    `json:"file"`
+	Until    time.Time `json:"until"`    // when it stops, at the end of that GOP
+	Extended bool      `json:"extended"` // an active timed recording was extended
+	EventID  string    `json:"event_id"`
+}
+
+// recordFor records for d on behalf of owner. While a timed recording is
+// active, it is extended to end no earlier than d from now instead, so
+// overlapping requests share one clip. Other recordings are left alone
+// (ErrAlreadyRecording).
+func (s *Server) recordFor(owner RecordingOwner, eventID string, d time.Duration) (TimedRecording, error) {
+	until := time.Now().Add(d)
+	s.timed.mu.Lock()
+	defer s.timed.mu.Unlock()
+
+	if rs := s.recorder.RecordingStatus(); rs.Active {
+		switch {
+		case s.timed.eventID == "" || rs.EventID != s.timed.eventID:
+			return TimedRecording{}, ErrAlreadyRecording
+		case s.timed.stopping:
+			return TimedRecording{}, errStopping
+		}
+		if until.After(s.timed.until) {
+			s.timed.until = until
+		}
+		return TimedRecording{File: rs.File, Until: s.timed.until, Extended: true, EventID: rs.EventID}, nil
+	}
+
+	filename, err := s.startRecording(owner, eventID)
+	if err != nil {
+		return TimedRecording{}, err
+	}
+	s.timed.eventID, s.timed.until, s.timed.stopping = eventID, until, false
+	go s.runTimed(owner, eventID)
+	return TimedRecording{File: filename, Until: until, EventID: eventID}, nil
+}
+
+// runTimed keeps the timed recording of eventID alive until its deadline,
+// then stops it at the end of the GOP in progress.
+func (s *Server) runTimed(owner RecordingOwner, eventID string) {
+	wait := time.Second
+	for {
+		time.Sleep(wait)
+		s.timed.mu.Lock()
+		if rs := s.recorder.RecordingStatus(); !rs.Active || rs.EventID != eventID {
+			// Stopped elsewhere
+			if s.timed.eventID == eventID {
+				s.timed.eventID = ""
+			}
+			s.timed.mu.Unlock()
+			return
+		}
+		left := time.Until(s.timed.until)
+		s.timed.stopping = left <= 0
+		s.timed.mu.Unlock()
+		if left > 0 {
+			s.recorder.Heartbeat()
+			wait = min(left, time.Second)
+			continue
+		}
+
+		filename, err := s.endRecording(owner, s.recorder.StopAtGOP)
+		s.timed.mu.Lock()
+		if s.timed.eventID == eventID {
+			s.timed.eventID = ""
+		}
+		s.timed.mu.Unlock()
+		if err != nil {
+			logger.Warn("Recorder", "Timed recording stop failed: %v", err)
+			return
+		}
+		logger.Info("Recorder", "Timed recording %s finished (%s)", filename, owner.Name)
+		return
+	}
+}
+
+// handleRecordFor serves POST /api/record?duration=30s: a recording that
+// stops by itself after the duration, at the end of the GOP then in
+// progress, or the active timed recording extended. The optional JSON body
+// {"name": "..."} labels the owner like /api/recording/start.
+func (s *Server) handleRecordFor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	d, err := recorder.ParseDuration(r.URL.Query().Get("duration"))
+	if err == nil && d > MaxRecordingDuration {
+		err = errors.New("duration exceeds the maximum recording duration")
+	}
+	if err != nil {
+		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	rec, err := s.recordFor(s.requestOwner(w, r), newEventID(), d)
+	switch {
+	case errors.Is(err, ErrAlreadyRecording):
+		s.writeRecordingConflict(w)
+		return
+	case errors.Is(err, errStopping):
+		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusConflict)
+		return
+	case err != nil:
+		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, rec)
+}
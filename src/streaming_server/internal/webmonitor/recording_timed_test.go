@@ -0,0 +1,56 @@
+package webmonitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandleRecordFor(t *testing.T) {
+	rec := NewRecorder(t.TempDir(), "/nonexistent")
+	s := &Server{recorder: rec, events: NewEventStore(time.Hour)}
+	post := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.handleRecordFor(w, httptest.NewRequest(http.MethodPost, "/api/record"+query, nil))
+		return w
+	}
+
+	for _, bad := range []string{"", "?duration=soon", "?duration=0", "?duration=-5s", "?duration=2h"} {
+		if w := post(bad); w.Code != http.StatusBadRequest {
+			t.Errorf("%q: %d", bad, w.Code)
+		}
+	}
+
+	// A recording someone started by hand is not taken over
+	rec.recording, rec.filename, rec.eventID = true, "recording_20261016_120000.hevc", "manual"
+	if w := post("?duration=30s"); w.Code != http.StatusConflict {
+		t.Errorf("during a manual recording: %d %s", w.Code, w.Body)
+	}
+
+	// A timed one is extended, never shortened
+	until := time.Now().Add(10 * time.Second)
+	rec.eventID = "timed"
+	s.timed.eventID, s.timed.until = "timed", until
+	w := post("?duration=60")
+	var got TimedRecording
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("extend: %d %s", w.Code, w.Body)
+	}
+	if !got.Extended || got.File != rec.filename || got.EventID != "timed" || got.Until.Sub(until) < 49*time.Second {
+		t.Errorf("extend: %+v", got)
+	}
+	json.Unmarshal(post("?duration=1s").Body.Bytes(), &got)
+	if !got.Until.Equal(s.timed.until) || time.Until(got.Until) < 50*time.Second {
+		t.Errorf("a shorter request moved the deadline to %v", got.Until)
+	}
+
+	s.timed.stopping = true
+	if w := post("?duration=30s"); w.Code != http.StatusConflict {
+		t.Errorf("while stopping: %d", w.Code)
+	}
+	if got := s.events.Query(0, []string{EventRecordingStarted}, 0); len(got) != 0 {
+		t.Errorf("%d recording_started events without a new recording", len(got))
+	}
+}
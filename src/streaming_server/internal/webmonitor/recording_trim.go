@@ -0,0 +1,136 @@
+package webmonitor
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/types"
+)
+
+// StopTrim is what Stop does with the GOP in progress. A raw H.265 clip cut
+// mid-GOP ends with frames referencing pictures of a GOP that never closes,
+// which some players refuse or render as garbage.
+type StopTrim string
+
+const (
+	// TrimCut stops at once and records the cut point (see CutPoint), so a
+	// player or export can drop the partial GOP.
+	TrimCut StopTrim = "cut"
+	// TrimGOP keeps writing until the next IDR, bounded by the GOP wait, so
+	// the clip ends on a GOP boundary. The IDR itself is not written.
+	TrimGOP StopTrim = "gop"
+)
+
+func (t StopTrim) String() string { return string(t) }
+
+// Set parses a -record-stop-trim value (flag.Value).
+func (t *StopTrim) Set(s string) error {
+	switch v := StopTrim(s); v {
+	case TrimCut, TrimGOP:
+		*t = v
+		return nil
+	}
+	return fmt.Errorf("want %q or %q", TrimCut, TrimGOP)
+}
+
+// errStopping is returned by Stop while another Stop waits for the GOP.
+var errStopping = errors.New("recording is already stopping")
+
+// CutPoint is where a recording ended relative to its last GOP.
+type CutPoint struct {
+	Frames     uint64  `json:"frames"`       // frames in the clip
+	LastIDR    uint64  `json:"last_idr"`     // index of the last GOP's IDR frame
+	LastIDRSec float64 `json:"last_idr_sec"` // its capture time from the first frame
+	Complete   bool    `json:"complete"`     // the clip ends on a GOP boundary
+}
+
+// SetStopTrim sets the stop policy and, for TrimGOP, how long Stop waits for
+// the next IDR at least (a GOP is 1s at the camera's settings). Once the
+// recording measured its GOPs, Stop waits out longer ones (see
+// codec.GOPTracker.IDRWait).
+func (r *Recorder) SetStopTrim(trim StopTrim, gopWait time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopTrim = trim
+	r.gopWait = gopWait
+}
+
+// finishGOP lets the active recording run up to the end of its GOP before
+// Stop closes it, if the policy asks for that or always is set. It fails
+// while another Stop is already waiting.
+func (r *Recorder) finishGOP(always bool) error {
+	r.mu.Lock()
+	if r.finishing != nil {
+		r.mu.Unlock()
+		return errStopping
+	}
+	if !r.recording || (!always && r.stopTrim != TrimGOP) || r.gopWait <= 0 || r.waitingKeyframe {
+		r.mu.Unlock()
+		return nil
+	}
+	done := make(chan struct{})
+	r.finishing = done
+	wait := r.gopWait
+	if r.h264Processor != nil {
+		wait = r.h264Processor.GOP().IDRWait(wait)
+	}
+	filename := r.filename
+	r.mu.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		logger.Info("Recorder", "No IDR within %v, cutting %s mid-GOP", wait, filename)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.finishing = nil
+	return nil
+}
+
+// gopEndedLocked reports whether frame is the IDR closing the GOP a
+// finishing Stop waits for; frames from then on are not written. Caller
+// holds r.mu.
+func (r *Recorder) gopEndedLocked(frame *types.VideoFrame) bool {
+	if r.gopClosed {
+		return true
+	}
+	if r.finishing == nil || !frame.IsIDR {
+		return false
+	}
+	r.gopClosed = true
+	close(r.finishing)
+	return true
+}
+
+// countFrameLocked tracks the GOP structure of a written frame for the cut
+// point. Caller holds r.mu.
+func (r *Recorder) countFrameLocked(frame *types.VideoFrame) {
+	if r.frameCount == 0 {
+		r.firstFrameAt = frame.Timestamp
+	}
+	if frame.IsIDR {
+		r.lastIDR = r.frameCount
+		r.lastIDRAt = frame.Timestamp
+	}
+	r.frameCount++
+}
+
+// cutPointLocked returns where the recording ended, nil without frames.
+// Caller holds r.mu.
+func (r *Recorder) cutPointLocked() *CutPoint {
+	if r.frameCount == 0 {
+		return nil
+	}
+	return &CutPoint{
+		Frames:     r.frameCount,
+		LastIDR:    r.lastIDR,
+		LastIDRSec: r.lastIDRAt.Sub(r.firstFrameAt).Seconds(),
+		Complete:   r.gopClosed,
+	}
+}
@@ -0,0 +1,109 @@
+package webmonitor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/codec"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/types"
+)
+
+// trimRecorder returns a recorder in the state Start leaves it in, without
+// the SHM reader: frames are fed with writeFrame.
+func trimRecorder(t *testing.T, trim StopTrim, wait time.Duration) *Recorder {
+	dir := t.TempDir()
+	r := NewRecorder(dir, "")
+	r.SetStopTrim(trim, wait)
+	f, err := os.Create(filepath.Join(dir, "recording_test.hevc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	r.file, r.recording, r.waitingKeyframe = f, true, true
+	return r
+}
+
+func gopFrame(n int, idr bool) *types.VideoFrame {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	return &types.VideoFrame{
+		FrameNumber: uint64(n),
+		Timestamp:   start.Add(time.Duration(n) * 100 * time.Millisecond),
+		Data:        []byte{0, 0, 0, 1, 0x02, byte(n)},
+		IsIDR:       idr,
+	}
+}
+
+func TestStopTrimGOP(t *testing.T) {
+	r := trimRecorder(t, TrimGOP, 2*time.Second)
+	p := codec.NewProcessor()
+	for n := 0; n < 6; n++ {
+		r.writeFrame(gopFrame(n, n%5 == 0), p) // IDR at 0 and 5
+	}
+
+	done := make(chan error)
+	go func() { done <- r.finishGOP(false) }()
+	for deadline := time.Now().Add(2 * time.Second); ; {
+		r.mu.RLock()
+		waiting := r.finishing != nil
+		r.mu.RUnlock()
+		if waiting {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("finishGOP did not start waiting")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := r.finishGOP(false); err != errStopping {
+		t.Errorf("second stop: %v", err)
+	}
+
+	// The GOP from frame 5 runs until the next IDR, which is not written
+	for n := 6; n < 12; n++ {
+		r.writeFrame(gopFrame(n, n%5 == 0), p)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("finishGOP did not return at the IDR")
+	}
+	cut := r.cutPointLocked()
+	if *cut != (CutPoint{Frames: 10, LastIDR: 5, LastIDRSec: 0.5, Complete: true}) {
+		t.Errorf("cut %+v", cut)
+	}
+}
+
+func TestStopTrimCut(t *testing.T) {
+	r := trimRecorder(t, TrimCut, 2*time.Second)
+	p := codec.NewProcessor()
+	r.writeFrame(gopFrame(0, false), p) // skipped: before the first IDR
+	for n := 1; n < 8; n++ {
+		r.writeFrame(gopFrame(n, n == 1 || n == 6), p)
+	}
+	if err := r.finishGOP(false); err != nil {
+		t.Fatal(err)
+	}
+	if cut := r.cutPointLocked(); *cut != (CutPoint{Frames: 7, LastIDR: 5, LastIDRSec: 0.5}) {
+		t.Errorf("cut %+v", cut)
+	}
+
+	// No IDR within the wait: the clip is cut mid-GOP after all
+	r = trimRecorder(t, TrimGOP, 10*time.Millisecond)
+	r.writeFrame(gopFrame(0, true), p)
+	if err := r.finishGOP(false); err != nil {
+		t.Fatal(err)
+	}
+	if cut := r.cutPointLocked(); cut.Complete || cut.Frames != 1 {
+		t.Errorf("cut %+v", cut)
+	}
+
+	var trim StopTrim
+	if trim.Set("gop") != nil || trim != TrimGOP || trim.Set("end") == nil {
+		t.Errorf("Set: %q", trim)
+	}
+}
@@ -0,0 +1,391 @@
+package webmonitor
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/clock"
+)
+
+// Rollup resolutions.
+const (
+	rollupMinute = "minute"
+	rollupHour   = "hour"
+)
+
+// Zone grid: the 1280x720 detection space is split into rollupZoneCols x
+// rollupZoneRows cells named "r<row>c<col>"; a detection belongs to the
+// cell holding its bbox center.
+const (
+	rollupZoneCols = 3
+	rollupZoneRows = 3
+)
+
+// rollupCell identifies one counter inside a time bucket.
+type rollupCell struct {
+	Class string
+	Zone  string
+}
+
+// RollupPoint is one counter returned by /api/analytics/rollup. Grid cells
+// carry a count; named zones (see ZoneOccupancy), keyed by zone ID, carry
+// the seconds a pet of the class spent in them.
+type RollupPoint struct {
+	Time        int64   `json:"t"` // bucket start, Unix seconds
+	Class       string  `json:"class"`
+	Zone        string  `json:"zone"`
+	Count       uint32  `json:"count"`
+	OccupiedSec float64 `json:"occupied_sec,omitempty"`
+}
+
+// CalendarDay is one day of the month heatmap.
+type CalendarDay struct {
+	Date  string     `json:"date"` // YYYY-MM-DD in the camera's time zone
+	Count uint32     `json:"count"`
+	Hours [24]uint32 `json:"hours"`
+}
+
+// DetectionRollup keeps detection counts pre-aggregated per minute and per
+// hour, by class and zone, so month-level queries never scan raw history.
+// Counts are result frames in which the class appeared in the zone (a cat
+// seen for one second at 5 fps counts 5). Buckets are updated as results
+// arrive and trimmed past their retention.
+type DetectionRollup struct {
+	MinuteRetention time.Duration
+	HourRetention   time.Duration
+
+	mu         sync.RWMutex
+	minutes    map[int64]map[rollupCell]uint32 // bucket start (Unix s) → counts
+	hours      map[int64]map[rollupCell]uint32
+	occMinutes map[int64]map[rollupCell]float64 // bucket start → seconds occupied, by zone ID
+	occHours   map[int64]map[rollupCell]float64
+	lastTrim   int64 // hour bucket of the last trim
+}
+
+// rollupSnapshot is the gob file layout.
+type rollupSnapshot struct {
+	Minutes          map[int64]map[rollupCell]uint32
+	Hours            map[int64]map[rollupCell]uint32
+	OccupancyMinutes map[int64]map[rollupCell]float64
+	OccupancyHours   map[int64]map[rollupCell]float64
+}
+
+// NewDetectionRollup creates empty rollups (2 days of minutes, 400 days of hours).
+func NewDetectionRollup() *DetectionRollup {
+	return &DetectionRollup{
+		MinuteRetention: 48 * time.Hour,
+		HourRetention:   400 * 24 * time.Hour,
+		minutes:         make(map[int64]map[rollupCell]uint32),
+		hours:           make(map[int64]map[rollupCell]uint32),
+		occMinutes:      make(map[int64]map[rollupCell]float64),
+		occHours:        make(map[int64]map[rollupCell]float64),
+	}
+}
+
+// rollupZone returns the grid cell holding the bbox center.
+func rollupZone(b BoundingBox) string {
+	col := min(max((b.X+b.W/2)*rollupZoneCols/detectionRefW, 0), rollupZoneCols-1)
+	row := min(max((b.Y+b.H/2)*rollupZoneRows/detectionRefH, 0), rollupZoneRows-1)
+	return "r" + strconv.Itoa(row) + "c" + strconv.Itoa(col)
+}
+
+// Record adds one detection result.
+func (r *DetectionRollup) Record(det *DetectionResult) {
+	if det == nil || len(det.Detections) == 0 {
+		return
+	}
+	at := time.Now()
+	if det.Timestamp > 0 {
+		at = time.Unix(0, int64(det.Timestamp*1e9))
+	}
+	cells := make(map[rollupCell]struct{}, len(det.Detections))
+	for _, d := range det.Detections {
+		cells[rollupCell{Class: d.ClassName, Zone: rollupZone(d.BBox)}] = struct{}{}
+	}
+	minute := at.Truncate(time.Minute).Unix()
+	hour := at.Truncate(time.Hour).Unix()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for cell := range cells {
+		bump(r.minutes, minute, cell)
+		bump(r.hours, hour, cell)
+	}
+	if hour != r.lastTrim {
+		r.lastTrim = hour
+		r.trimLocked(at)
+	}
+}
+
+func bump(buckets map[int64]map[rollupCell]uint32, t int64, cell rollupCell) {
+	b := buckets[t]
+	if b == nil {
+		b = make(map[rollupCell]uint32)
+		buckets[t] = b
+	}
+	b[cell]++
+}
+
+// RecordOccupancy adds the time a pet of class spent in zone between from
+// and to, split across the minute and hour buckets it spans.
+func (r *DetectionRollup) RecordOccupancy(zone, class string, from, to time.Time) {
+	if !to.After(from) {
+		return
+	}
+	cell := rollupCell{Class: class, Zone: zone}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, res := range []struct {
+		buckets map[int64]map[rollupCell]float64
+		step    time.Duration
+	}{{r.occMinutes, time.Minute}, {r.occHours, time.Hour}} {
+		for t := from; t.Before(to); {
+			start := t.Truncate(res.step)
+			end := start.Add(res.step)
+			if end.After(to) {
+				end = to
+			}
+			b := res.buckets[start.Unix()]
+			if b == nil {
+				b = make(map[rollupCell]float64)
+				res.buckets[start.Unix()] = b
+			}
+			b[cell] += end.Sub(t).Seconds()
+			t = end
+		}
+	}
+}
+
+func (r *DetectionRollup) trimLocked(now time.Time) {
+	minuteCutoff := now.Add(-r.MinuteRetention).Unix()
+	for t := range r.minutes {
+		if t < minuteCutoff {
+			delete(r.minutes, t)
+		}
+	}
+	for t := range r.occMinutes {
+		if t < minuteCutoff {
+			delete(r.occMinutes, t)
+		}
+	}
+	hourCutoff := now.Add(-r.HourRetention).Unix()
+	for t := range r.hours {
+		if t < hourCutoff {
+			delete(r.hours, t)
+		}
+	}
+	for t := range r.occHours {
+		if t < hourCutoff {
+			delete(r.occHours, t)
+		}
+	}
+}
+
+// Query returns counters in [from, to) at the given resolution, filtered
+// by class and zone ("" matches all), ordered by time.
+func (r *DetectionRollup) Query(resolution string, from, to time.Time, class, zone string) ([]RollupPoint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	buckets, occupancy, step := r.hours, r.occHours, time.Hour
+	switch resolution {
+	case rollupHour, "":
+	case rollupMinute:
+		buckets, occupancy, step = r.minutes, r.occMinutes, time.Minute
+	default:
+		return nil, fmt.Errorf("unknown resolution %q (want minute or hour)", resolution)
+	}
+	if n := to.Sub(from) / step; n > 50000 {
+		return nil, fmt.Errorf("range spans %d %ss (max 50000)", n, resolution)
+	}
+
+	points := []RollupPoint{}
+	for t := from.Truncate(step); t.Before(to); t = t.Add(step) {
+		start := len(points)
+		for cell, n := range buckets[t.Unix()] {
+			if (class == "" || cell.Class == class) && (zone == "" || cell.Zone == zone) {
+				points = append(points, RollupPoint{Time: t.Unix(), Class: cell.Class, Zone: cell.Zone, Count: n})
+			}
+		}
+		for cell, sec := range occupancy[t.Unix()] {
+			if (class == "" || cell.Class == class) && (zone == "" || cell.Zone == zone) {
+				points = append(points, RollupPoint{Time: t.Unix(), Class: cell.Class, Zone: cell.Zone, OccupiedSec: math.Round(sec*10) / 10})
+			}
+		}
+		sort.Slice(points[start:], func(i, j int) bool {
+			a, b := points[start+i], points[start+j]
+			return a.Class < b.Class || (a.Class == b.Class && a.Zone < b.Zone)
+		})
+	}
+	return points, nil
+}
+
+// Calendar returns per-day and per-hour totals for the month containing
+// month, in loc. class filters by detection class ("" counts all).
+func (r *DetectionRollup) Calendar(month time.Time, loc *time.Location, class string) []CalendarDay {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	first := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, loc)
+	var days []CalendarDay
+	for d := first; d.Month() == first.Month(); d = d.AddDate(0, 0, 1) {
+		day := CalendarDay{Date: d.Format("2006-01-02")}
+		next := d.AddDate(0, 0, 1)
+		// Step by wall-clock hour so DST days get 23 or 25 buckets.
+		for t := d; t.Before(next); t = t.Add(time.Hour) {
+			var n uint32
+			for cell, c := range r.hours[t.Truncate(time.Hour).Unix()] {
+				if class == "" || cell.Class == class {
+					n += c
+				}
+			}
+			day.Hours[t.Hour()] += n
+			day.Count += n
+		}
+		days = append(days, day)
+	}
+	return days
+}
+
+// Save writes the rollups to a gob file atomically (temp + rename).
+func (r *DetectionRollup) Save(path string) error {
+	r.mu.RLock()
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		r.mu.RUnlock()
+		return err
+	}
+	err = gob.NewEncoder(f).Encode(rollupSnapshot{
+		Minutes:          r.minutes,
+		Hours:            r.hours,
+		OccupancyMinutes: r.occMinutes,
+		OccupancyHours:   r.occHours,
+	})
+	r.mu.RUnlock()
+	if err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load reads rollups saved by Save. A missing file is not an error.
+func (r *DetectionRollup) Load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	var snap rollupSnapshot
+	if err := gob.NewDecoder(f).Decode(&snap); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if snap.Minutes != nil {
+		r.minutes = snap.Minutes
+	}
+	if snap.Hours != nil {
+		r.hours = snap.Hours
+	}
+	if snap.OccupancyMinutes != nil {
+		r.occMinutes = snap.OccupancyMinutes
+	}
+	if snap.OccupancyHours != nil {
+		r.occHours = snap.OccupancyHours
+	}
+	r.trimLocked(time.Now())
+	return nil
+}
+
+// handleAnalyticsCalendar serves GET /api/analytics/calendar?month=YYYY-MM[&class=cat].
+func (s *Server) handleAnalyticsCalendar(w http.ResponseWriter, r *http.Request) {
+	loc := clock.Location()
+	month := time.Now().In(loc)
+	if v := r.URL.Query().Get("month"); v != "" {
+		m, err := time.ParseInLocation("2006-01", v, loc)
+		if err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": "month must be YYYY-MM"}, http.StatusBadRequest)
+			return
+		}
+		month = m
+	}
+	class := r.URL.Query().Get("class")
+	days := s.rollup.Calendar(month, loc, class)
+	var peak uint32
+	for _, d := range days {
+		peak = max(peak, d.Count)
+	}
+	writeJSON(w, map[string]any{
+		"month": month.Format("2006-01"),
+		"class": class,
+		"max":   peak,
+		"days":  days,
+	})
+}
+
+// handleAnalyticsRollup serves GET /api/analytics/rollup?from=&to=&resolution=minute|hour[&class=][&zone=].
+// from/to are RFC 3339 or Unix seconds; the default range is the last 24 hours.
+func (s *Server) handleAnalyticsRollup(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	to := time.Now()
+	from := to.Add(-24 * time.Hour)
+	for name, dst := range map[string]*time.Time{"from": &from, "to": &to} {
+		v := q.Get(name)
+		if v == "" {
+			continue
+		}
+		t, err := parseQueryTime(v)
+		if err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": name + ": " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		*dst = t
+	}
+	resolution := q.Get("resolution")
+	if resolution == "" {
+		resolution = rollupHour
+	}
+	points, err := s.rollup.Query(resolution, from, to, q.Get("class"), q.Get("zone"))
+	if err != nil {
+		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, map[string]any{
+		"resolution": resolution,
+		"from":       from.Unix(),
+		"to":         to.Unix(),
+		"points":     points,
+	})
+}
+
+// parseQueryTime accepts RFC 3339 or Unix seconds.
+func parseQueryTime(v string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, errors.New("want RFC 3339 or Unix seconds")
+	}
+	return t, nil
+}
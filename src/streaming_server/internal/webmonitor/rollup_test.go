@@ -0,0 +1,91 @@
+package webmonitor
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDetectionRollup_CalendarAndQuery(t *testing.T) {
+	jst := time.FixedZone("JST", 9*3600)
+	r := NewDetectionRollup()
+	r.HourRetention = 100 * 365 * 24 * time.Hour // keep the fixed dates below
+	r.MinuteRetention = r.HourRetention
+	record := func(at time.Time, dets ...Detection) {
+		r.Record(&DetectionResult{Timestamp: float64(at.UnixNano()) / 1e9, Detections: dets})
+	}
+	cat := Detection{ClassName: "cat", BBox: BoundingBox{X: 0, Y: 0, W: 100, H: 100}}       // r0c0
+	dog := Detection{ClassName: "dog", BBox: BoundingBox{X: 1200, Y: 650, W: 40, H: 40}}    // r2c2
+	catTwin := Detection{ClassName: "cat", BBox: BoundingBox{X: 20, Y: 20, W: 100, H: 100}} // same cell: counted once
+	base := time.Date(2026, 10, 1, 0, 30, 0, 0, jst)                                        // local midnight hour, previous UTC day
+	record(base, cat, catTwin)
+	record(base.Add(time.Minute), cat, dog)
+	record(time.Date(2026, 10, 31, 23, 59, 0, 0, jst), cat)
+	record(time.Date(2026, 11, 1, 0, 0, 0, 0, jst), cat) // next month
+
+	days := r.Calendar(time.Date(2026, 10, 15, 0, 0, 0, 0, jst), jst, "")
+	if len(days) != 31 {
+		t.Fatalf("%d days", len(days))
+	}
+	if d := days[0]; d.Date != "2026-10-01" || d.Count != 3 || d.Hours[0] != 3 {
+		t.Fatalf("day 1 = %+v", d)
+	}
+	if d := days[30]; d.Count != 1 || d.Hours[23] != 1 {
+		t.Fatalf("day 31 = %+v", d)
+	}
+	if d := r.Calendar(base, jst, "dog")[0]; d.Count != 1 {
+		t.Fatalf("dog filter: %+v", d)
+	}
+
+	points, err := r.Query(rollupMinute, base, base.Add(2*time.Minute), "cat", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 2 || points[0].Count != 1 || points[0].Zone != "r0c0" || points[1].Time != base.Add(time.Minute).Unix() {
+		t.Fatalf("minute points %+v", points)
+	}
+	if _, err := r.Query("day", base, base, "", ""); err == nil {
+		t.Fatal("unknown resolution accepted")
+	}
+
+	// Round trip through the gob file.
+	path := filepath.Join(t.TempDir(), "rollups.gob")
+	if err := r.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewDetectionRollup()
+	loaded.HourRetention = r.HourRetention
+	loaded.MinuteRetention = r.MinuteRetention
+	if err := loaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if d := loaded.Calendar(base, jst, "")[0]; d.Count != 3 {
+		t.Fatalf("after reload: %+v", d)
+	}
+}
+
+func TestHandleAnalyticsCalendar(t *testing.T) {
+	s := &Server{rollup: NewDetectionRollup()}
+	s.rollup.Record(&DetectionResult{Timestamp: float64(time.Now().Unix()), Detections: []Detection{{ClassName: "cat"}}})
+
+	w := httptest.NewRecorder()
+	s.handleAnalyticsCalendar(w, httptest.NewRequest("GET", "/api/analytics/calendar", nil))
+	var body struct {
+		Max  uint32        `json:"max"`
+		Days []CalendarDay `json:"days"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Max != 1 || len(body.Days) < 28 {
+		t.Fatalf("max %d, %d days", body.Max, len(body.Days))
+	}
+
+	w = httptest.NewRecorder()
+	s.handleAnalyticsCalendar(w, httptest.NewRequest("GET", "/api/analytics/calendar?month=october", nil))
+	if w.Code != 400 {
+		t.Fatalf("bad month: status %d", w.Code)
+	}
+}
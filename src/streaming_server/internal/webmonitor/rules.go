@@ -0,0 +1,695 @@
+package webmonitor
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+// Rule action types.
+const (
+	RuleActionNotify   = "notify"
+	RuleActionRecord   = "record"
+	RuleActionSnapshot = "snapshot"
+)
+
+// Rule condition states.
+const (
+	RuleStatePresent = "present"
+	RuleStateAbsent  = "absent"
+)
+
+// rulePresenceGap is how long a class may go unseen before a "present" streak
+// ends. The detector publishes every inference, so a few seconds is enough to
+// ride out dropped frames without hiding real departures.
+const rulePresenceGap = 5 * time.Second
+
+// RuleDuration is a time.Duration that marshals as a Go duration string ("6h").
+// Unmarshal also accepts a number of seconds.
+type RuleDuration time.Duration
+
+// MarshalJSON implements json.Marshaler.
+func (d RuleDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *RuleDuration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		v, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		*d = RuleDuration(v)
+		return nil
+	}
+	var secs float64
+	if err := json.Unmarshal(data, &secs); err != nil {
+		return fmt.Errorf("duration must be a string or seconds: %s", data)
+	}
+	*d = RuleDuration(secs * float64(time.Second))
+	return nil
+}
+
+// RuleCondition matches a detection class, optionally inside a zone, that has
+// been present (or absent) for at least Duration. When Event is set the
+// condition matches synthesized events (e.g. "feeding_started") instead of
+// detections: "present" holds briefly after the event, "absent" holds once no
+// such event has occurred for Duration.
+type RuleCondition struct {
+	Event         string       `json:"event,omitempty"` // event type; "" = match detections
+	Class         string       `json:"class"`           // "" matches any class
+	State         string       `json:"state,omitempty"` // "present" (default) or "absent"
+	Zone          *BoundingBox `json:"zone,omitempty"`  // detection coordinates (1280x720); bbox center must be inside
+	MinConfidence float64      `json:"min_confidence,omitempty"`
+	Duration      RuleDuration `json:"duration,omitempty"`
+}
+
+// RuleSchedule restricts a rule to a daily local-time window. End before
+// Start wraps past midnight. Empty Days means every day (0 = Sunday).
+type RuleSchedule struct {
+	Start string `json:"start"` // "HH:MM"
+	End   string `json:"end"`   // "HH:MM"
+	Days  []int  `json:"days,omitempty"`
+}
+
+// RuleAction is executed when all conditions of a rule become true.
+type RuleAction struct {
+	Type     string       `json:"type"` // notify, record, snapshot
+	Message  string       `json:"message,omitempty"`
+	Duration RuleDuration `json:"duration,omitempty"` // record length (default 30s)
+}
+
+// Rule combines conditions (AND), an optional schedule and actions.
+type Rule struct {
+	ID         string          `json:"id"`
+	Name       string          `json:"name"`
+	Enabled    bool            `json:"enabled"`
+	Conditions []RuleCondition `json:"conditions"`
+	Schedule   *RuleSchedule   `json:"schedule,omitempty"`
+	Actions    []RuleAction    `json:"actions"`
+	Cooldown   RuleDuration    `json:"cooldown,omitempty"`
+}
+
+// RuleStatus is a rule plus its runtime state, returned by /api/rules.
+type RuleStatus struct {
+	Rule
+	Active    bool    `json:"active"`
+	LastFired float64 `json:"last_fired,omitempty"` // unix seconds
+}
+
+// Validate checks a rule for unsupported values.
+func (r *Rule) Validate() error {
+	if len(r.Conditions) == 0 {
+		return errors.New("rule needs at least one condition")
+	}
+	if len(r.Actions) == 0 {
+		return errors.New("rule needs at least one action")
+	}
+	for i := range r.Conditions {
+		c := &r.Conditions[i]
+		if c.State == "" {
+			c.State = RuleStatePresent
+		}
+		if c.State != RuleStatePresent && c.State != RuleStateAbsent {
+			return fmt.Errorf("condition %d: invalid state %q", i, c.State)
+		}
+		if c.Duration < 0 {
+			return fmt.Errorf("condition %d: negative duration", i)
+		}
+	}
+	for i, a := range r.Actions {
+		switch a.Type {
+		case RuleActionNotify, RuleActionRecord, RuleActionSnapshot:
+		default:
+			return fmt.Errorf("action %d: invalid type %q", i, a.Type)
+		}
+	}
+	if r.Schedule != nil {
+		if _, err := parseClock(r.Schedule.Start); err != nil {
+			return fmt.Errorf("schedule start: %w", err)
+		}
+		if _, err := parseClock(r.Schedule.End); err != nil {
+			return fmt.Errorf("schedule end: %w", err)
+		}
+		for _, d := range r.Schedule.Days {
+			if d < 0 || d > 6 {
+				return fmt.Errorf("schedule day %d out of range (0-6)", d)
+			}
+		}
+	}
+	return nil
+}
+
+// parseClock parses "HH:MM" into minutes since midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (want HH:MM)", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// contains reports whether now falls inside the schedule window.
+func (s *RuleSchedule) contains(now time.Time) bool {
+	if len(s.Days) > 0 {
+		found := false
+		for _, d := range s.Days {
+			if time.Weekday(d) == now.Weekday() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	start, err1 := parseClock(s.Start)
+	end, err2 := parseClock(s.End)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	m := now.Hour()*60 + now.Minute()
+	if start <= end {
+		return m >= start && m < end
+	}
+	return m >= start || m < end
+}
+
+// matches reports whether a detection satisfies the class/zone/confidence filter.
+func (c *RuleCondition) matches(d Detection) bool {
+	if c.Class != "" && c.Class != d.ClassName {
+		return false
+	}
+	if d.Confidence < c.MinConfidence {
+		return false
+	}
+	if c.Zone != nil {
+		cx := d.BBox.X + d.BBox.W/2
+		cy := d.BBox.Y + d.BBox.H/2
+		z := c.Zone
+		if cx < z.X || cx >= z.X+z.W || cy < z.Y || cy >= z.Y+z.H {
+			return false
+		}
+	}
+	return true
+}
+
+// RuleTrigger is what made a rule fire: the last detection or event that
+// matched one of its "present" conditions.
+type RuleTrigger struct {
+	EventID     string       `json:"event_id"` // the matched event's, or new for a detection
+	Class       string       `json:"class,omitempty"`
+	BBox        *BoundingBox `json:"bbox,omitempty"`
+	FrameNumber int          `json:"frame_number,omitempty"` // detection result; 0 for events
+}
+
+// conditionState tracks the current presence streak of one condition.
+type conditionState struct {
+	since    time.Time // start of the current presence streak
+	lastSeen time.Time
+	trigger  RuleTrigger // last match
+}
+
+type ruleState struct {
+	created   time.Time
+	conds     []conditionState
+	active    bool
+	lastFired time.Time
+}
+
+// RulesEngine evaluates user-defined rules against the detection stream and
+// dispatches actions. Rules are persisted in the settings store and can be
+// changed at runtime without a restart.
+type RulesEngine struct {
+	mu       sync.Mutex
+	doc      kv.Doc
+	rules    []Rule
+	state    map[string]*ruleState
+	onFire   func(rule Rule, trig RuleTrigger)
+	onAction func(rule Rule, action RuleAction, trig RuleTrigger)
+	stop     chan struct{}
+	stopped  bool
+}
+
+// NewRulesEngine creates an engine persisting rules to doc (the zero Doc =
+// in-memory only).
+func NewRulesEngine(doc kv.Doc) *RulesEngine {
+	return &RulesEngine{
+		doc:   doc,
+		state: make(map[string]*ruleState),
+		stop:  make(chan struct{}),
+	}
+}
+
+// SetOnFire sets the callback invoked once per firing, before its actions.
+func (e *RulesEngine) SetOnFire(callback func(rule Rule, trig RuleTrigger)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onFire = callback
+}
+
+// SetOnAction sets the callback invoked for each action of a firing rule.
+func (e *RulesEngine) SetOnAction(callback func(rule Rule, action RuleAction, trig RuleTrigger)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onAction = callback
+}
+
+// Load replaces the rules with the persisted ones; without a document
+// there are none.
+func (e *RulesEngine) Load() error {
+	var rules []Rule
+	if _, err := e.doc.Load(&rules); err != nil {
+		return err
+	}
+	for i := range rules {
+		if err := rules[i].Validate(); err != nil {
+			return fmt.Errorf("rule %q: %w", rules[i].ID, err)
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = rules
+	e.state = make(map[string]*ruleState)
+	return nil
+}
+
+// saveLocked persists rules and then makes them current, so a failed save
+// leaves the engine as it was.
+func (e *RulesEngine) saveLocked(rules []Rule) error {
+	if err := e.doc.Save(rules); err != nil {
+		return err
+	}
+	e.rules = rules
+	return nil
+}
+
+// List returns all rules with runtime state.
+func (e *RulesEngine) List() []RuleStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	out := make([]RuleStatus, 0, len(e.rules))
+	for _, r := range e.rules {
+		st := RuleStatus{Rule: r}
+		if rs := e.state[r.ID]; rs != nil {
+			st.Active = rs.active
+			if !rs.lastFired.IsZero() {
+				st.LastFired = float64(rs.lastFired.UnixNano()) / 1e9
+			}
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+// Scheduled reports whether an enabled rule with a schedule is inside its
+// window at now, i.e. the user expects the camera to be watching.
+func (e *RulesEngine) Scheduled(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range e.rules {
+		if r.Enabled && r.Schedule != nil && r.Schedule.contains(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// Put creates or replaces a rule. An empty ID is assigned. Runtime state of
+// a replaced rule is reset.
+func (e *RulesEngine) Put(rule Rule) (Rule, error) {
+	if err := rule.Validate(); err != nil {
+		return Rule{}, err
+	}
+	if rule.ID == "" {
+		var b [6]byte
+		_, _ = rand.Read(b[:])
+		rule.ID = hex.EncodeToString(b[:])
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	rules := slices.Clone(e.rules)
+	if i := slices.IndexFunc(rules, func(r Rule) bool { return r.ID == rule.ID }); i >= 0 {
+		rules[i] = rule
+	} else {
+		rules = append(rules, rule)
+	}
+	if err := e.saveLocked(rules); err != nil {
+		return Rule{}, err
+	}
+	delete(e.state, rule.ID)
+	return rule, nil
+}
+
+// Delete removes a rule. Returns false if it does not exist.
+func (e *RulesEngine) Delete(id string) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	i := slices.IndexFunc(e.rules, func(r Rule) bool { return r.ID == id })
+	if i < 0 {
+		return false, nil
+	}
+	if err := e.saveLocked(slices.Delete(slices.Clone(e.rules), i, i+1)); err != nil {
+		return true, err
+	}
+	delete(e.state, id)
+	return true, nil
+}
+
+// stateLocked returns the runtime state for a rule, creating it on first use.
+func (e *RulesEngine) stateLocked(r *Rule, now time.Time) *ruleState {
+	rs := e.state[r.ID]
+	if rs == nil || len(rs.conds) != len(r.Conditions) {
+		rs = &ruleState{created: now, conds: make([]conditionState, len(r.Conditions))}
+		e.state[r.ID] = rs
+	}
+	return rs
+}
+
+// Observe updates condition presence from a detection result.
+func (e *RulesEngine) Observe(det *DetectionResult, now time.Time) {
+	if det == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for i := range e.rules {
+		r := &e.rules[i]
+		if !r.Enabled {
+			continue
+		}
+		rs := e.stateLocked(r, now)
+		for ci := range r.Conditions {
+			c := &r.Conditions[ci]
+			if c.Event != "" {
+				continue
+			}
+			for _, d := range det.Detections {
+				if !c.matches(d) {
+					continue
+				}
+				rs.conds[ci].see(now)
+				rs.conds[ci].trigger = RuleTrigger{Class: d.ClassName, BBox: &d.BBox, FrameNumber: det.FrameNumber}
+				break
+			}
+		}
+	}
+}
+
+// ObserveEvent updates event conditions from a synthesized event.
+func (e *RulesEngine) ObserveEvent(ev Event, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for i := range e.rules {
+		r := &e.rules[i]
+		if !r.Enabled {
+			continue
+		}
+		rs := e.stateLocked(r, now)
+		for ci := range r.Conditions {
+			c := &r.Conditions[ci]
+			if c.Event == "" || c.Event != ev.Type || (c.Class != "" && c.Class != ev.Class) {
+				continue
+			}
+			rs.conds[ci].see(now)
+			rs.conds[ci].trigger = RuleTrigger{EventID: ev.EventID, Class: ev.Class, BBox: ev.BBox}
+		}
+	}
+}
+
+// see extends the current presence streak or starts a new one.
+func (cs *conditionState) see(now time.Time) {
+	if cs.lastSeen.IsZero() || now.Sub(cs.lastSeen) > rulePresenceGap {
+		cs.since = now
+	}
+	cs.lastSeen = now
+}
+
+// holds reports whether a condition is currently satisfied.
+func (c *RuleCondition) holds(cs conditionState, created, now time.Time) bool {
+	visible := !cs.lastSeen.IsZero() && now.Sub(cs.lastSeen) <= rulePresenceGap
+	if c.State == RuleStateAbsent {
+		if visible {
+			return false
+		}
+		ref := cs.lastSeen
+		if ref.IsZero() {
+			ref = created
+		}
+		return now.Sub(ref) >= max(time.Duration(c.Duration), rulePresenceGap)
+	}
+	return visible && now.Sub(cs.since) >= time.Duration(c.Duration)
+}
+
+// trigger returns what made rs fire: the most recent match of a "present"
+// condition of r, with a new EventID unless that was an event.
+func (rs *ruleState) trigger(r *Rule) RuleTrigger {
+	var trig RuleTrigger
+	var last time.Time
+	for ci, c := range r.Conditions {
+		if cs := rs.conds[ci]; c.State == RuleStatePresent && cs.lastSeen.After(last) {
+			trig, last = cs.trigger, cs.lastSeen
+		}
+	}
+	if trig.EventID == "" {
+		trig.EventID = newEventID()
+	}
+	return trig
+}
+
+type firedRule struct {
+	rule Rule
+	trig RuleTrigger
+}
+
+// Evaluate checks all rules and dispatches actions for rules that became true.
+// Rules are edge-triggered: a rule fires once when its conditions become true
+// and re-arms after they become false (subject to Cooldown).
+func (e *RulesEngine) Evaluate(now time.Time) {
+	e.mu.Lock()
+	var fired []firedRule
+	for i := range e.rules {
+		r := &e.rules[i]
+		if !r.Enabled {
+			continue
+		}
+		rs := e.stateLocked(r, now)
+
+		ok := r.Schedule == nil || r.Schedule.contains(now)
+		for ci := range r.Conditions {
+			if !ok {
+				break
+			}
+			ok = r.Conditions[ci].holds(rs.conds[ci], rs.created, now)
+		}
+
+		if !ok {
+			rs.active = false
+			continue
+		}
+		if rs.active {
+			continue
+		}
+		rs.active = true
+		if !rs.lastFired.IsZero() && now.Sub(rs.lastFired) < time.Duration(r.Cooldown) {
+			continue
+		}
+		rs.lastFired = now
+		fired = append(fired, firedRule{rule: *r, trig: rs.trigger(r)})
+	}
+	onFire, onAction := e.onFire, e.onAction
+	e.mu.Unlock()
+
+	for _, f := range fired {
+		if onFire != nil {
+			onFire(f.rule, f.trig)
+		}
+		for _, a := range f.rule.Actions {
+			logger.Info("Rules", "Rule %q (%s) fired: %s (event %s)", f.rule.Name, f.rule.ID, a.Type, f.trig.EventID)
+			if onAction != nil {
+				onAction(f.rule, a, f.trig)
+			}
+		}
+	}
+}
+
+// Start begins periodic evaluation.
+func (e *RulesEngine) Start() {
+	go e.run()
+}
+
+// Stop halts evaluation.
+func (e *RulesEngine) Stop() {
+	e.mu.Lock()
+	if !e.stopped {
+		close(e.stop)
+		e.stopped = true
+	}
+	e.mu.Unlock()
+}
+
+func (e *RulesEngine) run() {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.stop:
+			return
+		case now := <-ticker.C:
+			e.Evaluate(now)
+		}
+	}
+}
+
+// EventRuleFired is appended once per rule firing, carrying the trigger's
+// EventID that its notification and recording share.
+const EventRuleFired = "rule_fired"
+
+// recordRuleFired appends the rule_fired event of a firing.
+func (s *Server) recordRuleFired(rule Rule, trig RuleTrigger) {
+	data := map[string]string{"rule_id": rule.ID, "rule": rule.Name}
+	if trig.FrameNumber != 0 {
+		data["frame_number"] = strconv.Itoa(trig.FrameNumber)
+	}
+	s.events.Append(Event{
+		EventID: trig.EventID,
+		Type:    EventRuleFired,
+		Class:   trig.Class,
+		BBox:    trig.BBox,
+		Data:    data,
+	})
+}
+
+// runRuleAction executes a fired rule action against the server's subsystems.
+func (s *Server) runRuleAction(rule Rule, action RuleAction, trig RuleTrigger) {
+	message := action.Message
+	if message == "" {
+		message = rule.Name
+	}
+
+	switch action.Type {
+	case RuleActionNotify:
+		logger.Warn("Rules", "Notification: %s", message)
+		push := PushMessage{Title: rule.Name, Body: message, Tag: "rule-" + rule.ID, EventID: trig.EventID}
+		if action.Message == "" && trig.Class != "" {
+			// Without a message of its own, say what fired it in the subscriber's locale
+			push.body = msg("push.detected", classLabel(trig.Class))
+			push.Body = push.body.String()
+		}
+		s.topics.notifications.Publish(push)
+	case RuleActionRecord:
+		duration := time.Duration(action.Duration)
+		if duration <= 0 {
+			duration = 30 * time.Second
+		}
+		owner := RecordingOwner{ID: ruleOwnerID, Name: rule.Name}
+		go func() {
+			rec, err := s.recordFor(owner, trig.EventID, min(duration, MaxRecordingDuration))
+			if err != nil {
+				logger.Warn("Rules", "Record skipped (%s): %v", rule.Name, err)
+				return
+			}
+			logger.Info("Rules", "Recording %s until %s (%s)", rec.File, rec.Until.Format(time.TimeOnly), rule.Name)
+		}()
+	case RuleActionSnapshot:
+		if s.comicCapture == nil {
+			logger.Warn("Rules", "Snapshot skipped: comic capture not available")
+			return
+		}
+		go func() {
+			if _, err := s.comicCapture.CaptureComic(message); err != nil {
+				logger.Warn("Rules", "Snapshot failed: %v", err)
+			}
+		}()
+	}
+}
+
+// handleRules serves GET (list) and POST (create) on /api/rules.
+func (s *Server) handleRules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, map[string]any{"rules": s.rules.List()})
+	case http.MethodPost:
+		rule := Rule{Enabled: true} // new rules are enabled unless the body says otherwise
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": "invalid JSON: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		rule.ID = ""
+		if err := rule.Validate(); err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+		saved, err := s.rules.Put(rule)
+		if err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		writeJSONWithStatus(w, saved, http.StatusCreated)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleRule serves PUT (replace) and DELETE on /api/rules/{id}.
+func (s *Server) handleRule(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/rules/")
+	if id == "" || strings.Contains(id, "/") {
+		http.Error(w, "Invalid rule id", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var rule Rule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": "invalid JSON: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		rule.ID = id
+		if err := rule.Validate(); err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+		saved, err := s.rules.Put(rule)
+		if err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, saved)
+	case http.MethodDelete:
+		found, err := s.rules.Delete(id)
+		if err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			writeJSONWithStatus(w, map[string]any{"error": "not found"}, http.StatusNotFound)
+			return
+		}
+		writeJSON(w, map[string]any{"deleted": true, "id": id})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
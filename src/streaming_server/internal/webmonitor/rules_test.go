@@ -0,0 +1,281 @@
+package webmonitor
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+)
+
+func detectionOf(class string, bbox BoundingBox) *DetectionResult {
+	return &DetectionResult{
+		NumDetections: 1,
+		Detections:    []Detection{{ClassName: class, Confidence: 0.9, BBox: bbox}},
+	}
+}
+
+func TestRulesEngine_BowlVisibleNoCat(t *testing.T) {
+	e := NewRulesEngine(kv.Doc{})
+	var fired []string
+	e.SetOnAction(func(r Rule, a RuleAction, _ RuleTrigger) { fired = append(fired, r.Name+":"+a.Type) })
+
+	_, err := e.Put(Rule{
+		Name:    "check feeder",
+		Enabled: true,
+		Conditions: []RuleCondition{
+			{Class: "food_bowl"},
+			{Class: "cat", State: RuleStateAbsent, Duration: RuleDuration(6 * time.Hour)},
+		},
+		Actions: []RuleAction{{Type: RuleActionNotify}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	bowl := detectionOf("food_bowl", BoundingBox{X: 100, Y: 100, W: 50, H: 50})
+	e.Observe(bowl, start)
+	e.Observe(detectionOf("cat", BoundingBox{X: 500, Y: 300, W: 200, H: 200}), start)
+
+	// Cat seen recently: no fire
+	e.Observe(bowl, start.Add(3*time.Hour))
+	e.Evaluate(start.Add(3 * time.Hour))
+	if len(fired) != 0 {
+		t.Fatalf("fired too early: %v", fired)
+	}
+
+	// 6h+ without a cat while the bowl is visible
+	now := start.Add(6*time.Hour + time.Minute)
+	e.Observe(bowl, now)
+	e.Evaluate(now)
+	if len(fired) != 1 || fired[0] != "check feeder:notify" {
+		t.Fatalf("fired = %v, want one notify", fired)
+	}
+
+	// Edge-triggered: still true, no repeat
+	e.Observe(bowl, now.Add(time.Second))
+	e.Evaluate(now.Add(time.Second))
+	if len(fired) != 1 {
+		t.Fatalf("rule re-fired while still active: %v", fired)
+	}
+}
+
+func TestRulesEngine_PresentDurationAndZone(t *testing.T) {
+	e := NewRulesEngine(kv.Doc{})
+	fired := 0
+	e.SetOnAction(func(Rule, RuleAction, RuleTrigger) { fired++ })
+	if _, err := e.Put(Rule{
+		Name:       "cat at bowl",
+		Enabled:    true,
+		Conditions: []RuleCondition{{Class: "cat", Zone: &BoundingBox{X: 0, Y: 0, W: 400, H: 400}, Duration: RuleDuration(3 * time.Second)}},
+		Actions:    []RuleAction{{Type: RuleActionSnapshot}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	outside := detectionOf("cat", BoundingBox{X: 800, Y: 500, W: 100, H: 100})
+	inside := detectionOf("cat", BoundingBox{X: 100, Y: 100, W: 100, H: 100})
+
+	for i := 0; i < 5; i++ {
+		now := start.Add(time.Duration(i) * time.Second)
+		e.Observe(outside, now)
+		e.Evaluate(now)
+	}
+	if fired != 0 {
+		t.Fatal("fired for detection outside zone")
+	}
+
+	for i := 0; i < 5; i++ {
+		now := start.Add(time.Duration(10+i) * time.Second)
+		e.Observe(inside, now)
+		e.Evaluate(now)
+	}
+	if fired != 1 {
+		t.Fatalf("fired = %d, want 1 after 3s in zone", fired)
+	}
+}
+
+func TestRulesEngine_TriggerEventID(t *testing.T) {
+	e := NewRulesEngine(kv.Doc{})
+	var fires, actions []RuleTrigger
+	e.SetOnFire(func(_ Rule, trig RuleTrigger) { fires = append(fires, trig) })
+	e.SetOnAction(func(_ Rule, _ RuleAction, trig RuleTrigger) { actions = append(actions, trig) })
+	e.Put(Rule{
+		Name:       "feeding",
+		Enabled:    true,
+		Conditions: []RuleCondition{{Event: "feeding_started"}},
+		Actions:    []RuleAction{{Type: RuleActionNotify}, {Type: RuleActionRecord}},
+	})
+	e.Put(Rule{
+		Name:       "cat",
+		Enabled:    true,
+		Conditions: []RuleCondition{{Class: "cat"}},
+		Actions:    []RuleAction{{Type: RuleActionNotify}},
+	})
+
+	now := time.Now()
+	det := detectionOf("cat", BoundingBox{X: 10, Y: 10, W: 50, H: 50})
+	det.FrameNumber = 4711
+	e.Observe(det, now)
+	e.ObserveEvent(Event{EventID: "ev1", Type: "feeding_started", Class: "cat"}, now)
+	e.Evaluate(now)
+
+	if len(fires) != 2 || len(actions) != 3 {
+		t.Fatalf("fired %d rules, %d actions", len(fires), len(actions))
+	}
+	// An event passes its ID on to every action; a detection gets a new one
+	if fires[0].EventID != "ev1" || actions[0].EventID != "ev1" || actions[1].EventID != "ev1" {
+		t.Errorf("event trigger %+v, actions %+v", fires[0], actions[:2])
+	}
+	if trig := fires[1]; trig.EventID == "" || trig.EventID == "ev1" || trig.FrameNumber != 4711 || trig.Class != "cat" || actions[2] != trig {
+		t.Errorf("detection trigger %+v, action %+v", trig, actions[2])
+	}
+}
+
+func TestRuleSchedule_WrapsMidnight(t *testing.T) {
+	s := &RuleSchedule{Start: "22:00", End: "06:00"}
+	at := func(h, m int) time.Time { return time.Date(2026, 1, 5, h, m, 0, 0, time.Local) }
+	if !s.contains(at(23, 0)) || !s.contains(at(5, 59)) {
+		t.Error("expected inside overnight window")
+	}
+	if s.contains(at(12, 0)) || s.contains(at(6, 0)) {
+		t.Error("expected outside overnight window")
+	}
+}
+
+func TestRulesEngine_Persistence(t *testing.T) {
+	doc := rulesDoc.in(kv.NewMemory())
+	e := NewRulesEngine(doc)
+	saved, err := e.Put(Rule{
+		Name:       "night cat",
+		Enabled:    true,
+		Conditions: []RuleCondition{{Class: "cat"}},
+		Schedule:   &RuleSchedule{Start: "22:00", End: "06:00"},
+		Actions:    []RuleAction{{Type: RuleActionRecord, Duration: RuleDuration(time.Minute)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.ID == "" {
+		t.Fatal("expected generated ID")
+	}
+
+	e2 := NewRulesEngine(doc)
+	if err := e2.Load(); err != nil {
+		t.Fatal(err)
+	}
+	rules := e2.List()
+	if len(rules) != 1 || rules[0].ID != saved.ID || time.Duration(rules[0].Actions[0].Duration) != time.Minute {
+		t.Fatalf("loaded rules = %+v", rules)
+	}
+}
+
+func TestHandleRules_CRUD(t *testing.T) {
+	s := &Server{rules: NewRulesEngine(kv.Doc{})}
+
+	body := `{"name":"cat","enabled":true,"conditions":[{"class":"cat","duration":"10s"}],"actions":[{"type":"notify"}]}`
+	rec := httptest.NewRecorder()
+	s.handleRules(rec, httptest.NewRequest(http.MethodPost, "/api/rules", strings.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST: expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created Rule
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+
+	// Created enabled unless the body says otherwise
+	for body, want := range map[string]bool{
+		`{"conditions":[{"class":"cat"}],"actions":[{"type":"notify"}]}`:                 true,
+		`{"enabled":false,"conditions":[{"class":"cat"}],"actions":[{"type":"notify"}]}`: false,
+	} {
+		rec = httptest.NewRecorder()
+		s.handleRules(rec, httptest.NewRequest(http.MethodPost, "/api/rules", strings.NewReader(body)))
+		var rule Rule
+		if err := json.Unmarshal(rec.Body.Bytes(), &rule); err != nil || rec.Code != http.StatusCreated || rule.Enabled != want {
+			t.Errorf("POST %s: %d %s", body, rec.Code, rec.Body.String())
+		}
+		s.rules.Delete(rule.ID)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleRules(rec, httptest.NewRequest(http.MethodPost, "/api/rules", strings.NewReader(`{"conditions":[{"class":"cat"}],"actions":[{"type":"explode"}]}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid action: expected 400, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleRule(rec, httptest.NewRequest(http.MethodDelete, "/api/rules/"+created.ID, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("DELETE: expected 200, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleRule(rec, httptest.NewRequest(http.MethodDelete, "/api/rules/"+created.ID, nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("second DELETE: expected 404, got %d", rec.Code)
+	}
+}
+
+// failingStore is a settings store whose writes fail while fail is set.
+type failingStore struct {
+	*kv.Memory
+	fail bool
+}
+
+func (f *failingStore) Put(bucket, key string, value []byte) error {
+	if f.fail {
+		return errors.New("no space left on device")
+	}
+	return f.Memory.Put(bucket, key, value)
+}
+
+func TestRulesEngine_FailedSaveKeepsRules(t *testing.T) {
+	store := &failingStore{Memory: kv.NewMemory()}
+	e := NewRulesEngine(rulesDoc.in(store))
+	kept, err := e.Put(Rule{Name: "kept", Enabled: true, Conditions: []RuleCondition{{Class: "cat"}}, Actions: []RuleAction{{Type: RuleActionNotify}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := func() (out []string) {
+		for _, r := range e.List() {
+			out = append(out, r.Name)
+		}
+		return out
+	}
+
+	store.fail = true
+	if _, err := e.Put(Rule{Name: "new", Conditions: []RuleCondition{{Class: "dog"}}, Actions: []RuleAction{{Type: RuleActionNotify}}}); err == nil {
+		t.Error("create: no error")
+	}
+	replaced := kept
+	replaced.Name = "replaced"
+	if _, err := e.Put(replaced); err == nil {
+		t.Error("replace: no error")
+	}
+	if found, err := e.Delete(kept.ID); !found || err == nil {
+		t.Errorf("delete: found %v, error %v", found, err)
+	}
+	if got := names(); len(got) != 1 || got[0] != "kept" {
+		t.Fatalf("rules after failed saves: %q", got)
+	}
+
+	// The handlers report the failure as a server error
+	s := &Server{rules: e}
+	rec := httptest.NewRecorder()
+	s.handleRules(rec, httptest.NewRequest(http.MethodPost, "/api/rules", strings.NewReader(`{"conditions":[{"class":"cat"}],"actions":[{"type":"notify"}]}`)))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("POST: %d, want 500", rec.Code)
+	}
+
+	store.fail = false
+	if found, err := e.Delete(kept.ID); !found || err != nil || len(names()) != 0 {
+		t.Errorf("delete after recovery: %v %v, rules %q", found, err, names())
+	}
+}
@@ -0,0 +1,391 @@
+package webmonitor
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/storage"
+)
+
+// checksumExt names the SHA-256 written next to each MP4 when it is
+// finalized (sha256sum format), so later bit rot can be told apart from a
+// file that was always broken.
+const checksumExt = ".sha256"
+
+// maxMoovSize bounds the index box read into memory (a 30 minute clip has a
+// moov of a few hundred KiB).
+const maxMoovSize = 32 << 20
+
+// checksumName returns the checksum file name for an MP4.
+func checksumName(mp4 string) string {
+	return mp4[:len(mp4)-len(filepath.Ext(mp4))] + checksumExt
+}
+
+// writeChecksum stores the SHA-256 of a finished local MP4 next to it.
+func writeChecksum(mp4Path string) error {
+	f, err := os.Open(mp4Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	line := hex.EncodeToString(h.Sum(nil)) + "  " + filepath.Base(mp4Path) + "\n"
+	return os.WriteFile(checksumName(mp4Path), []byte(line), 0644)
+}
+
+// ScrubResult is the last check of one recording.
+type ScrubResult struct {
+	OK        bool      `json:"ok"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// ScrubStats summarizes scrubbing for /metrics.
+type ScrubStats struct {
+	Checked     uint64    // files checked since start
+	Corrupt     int       // files currently flagged
+	LastRun     time.Time // end of the last completed pass
+	LastRunTook time.Duration
+}
+
+// Scrubber periodically re-reads finished recordings from storage and
+// checks that each MP4 still parses, that its sample index points inside
+// the media data, and that it matches the checksum stored at finalize.
+// Corrupt files are flagged in the recordings listing.
+type Scrubber struct {
+	Interval time.Duration // time between passes
+	MinAge   time.Duration // skip files modified more recently (still being written)
+
+	recorder *Recorder
+	mu       sync.Mutex
+	results  map[string]ScrubResult
+	stats    ScrubStats
+	stop     chan struct{}
+	stopped  bool
+}
+
+// NewScrubber creates a scrubber for the recorder's storage.
+func NewScrubber(recorder *Recorder, interval time.Duration) *Scrubber {
+	return &Scrubber{
+		Interval: interval,
+		MinAge:   10 * time.Minute,
+		recorder: recorder,
+		results:  make(map[string]ScrubResult),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start begins periodic scrubbing; the first pass runs after one minute so
+// it does not compete with startup.
+func (sc *Scrubber) Start() {
+	go sc.run()
+}
+
+// Stop halts scrubbing after the current file.
+func (sc *Scrubber) Stop() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if !sc.stopped {
+		close(sc.stop)
+		sc.stopped = true
+	}
+}
+
+func (sc *Scrubber) run() {
+	timer := time.NewTimer(time.Minute)
+	defer timer.Stop()
+	for {
+		select {
+		case <-sc.stop:
+			return
+		case <-timer.C:
+		}
+		sc.Pass()
+		timer.Reset(sc.Interval)
+	}
+}
+
+// Pass checks every finished MP4 once.
+func (sc *Scrubber) Pass() {
+	start := time.Now()
+	st := sc.recorder.Storage()
+	files, err := st.List()
+	if err != nil {
+		logger.Warn("Scrub", "Failed to list %s: %v", st, err)
+		return
+	}
+	names := make(map[string]bool, len(files))
+	for _, f := range files {
+		names[f.Name] = true
+	}
+
+	seen := make(map[string]bool)
+	for _, f := range files {
+		if filepath.Ext(f.Name) != ".mp4" || time.Since(f.ModTime) < sc.MinAge {
+			continue
+		}
+		select {
+		case <-sc.stop:
+			return
+		default:
+		}
+		seen[f.Name] = true
+		res := ScrubResult{OK: true, CheckedAt: time.Now()}
+		if err := scrubFile(st, f, names[checksumName(f.Name)]); err != nil {
+			res = ScrubResult{Error: err.Error(), CheckedAt: res.CheckedAt}
+			logger.Warn("Scrub", "%s is corrupt: %v", f.Name, err)
+		}
+		sc.mu.Lock()
+		sc.results[f.Name] = res
+		sc.stats.Checked++
+		sc.mu.Unlock()
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	corrupt := 0
+	for name, res := range sc.results {
+		if !names[name] {
+			delete(sc.results, name) // deleted since
+			continue
+		}
+		if !res.OK {
+			corrupt++
+		}
+	}
+	sc.stats.Corrupt = corrupt
+	sc.stats.LastRun = time.Now()
+	sc.stats.LastRunTook = time.Since(start)
+	logger.Info("Scrub", "Checked %d recordings in %v, %d corrupt", len(seen), sc.stats.LastRunTook.Round(time.Millisecond), corrupt)
+}
+
+// Result returns the last check of name, if any.
+func (sc *Scrubber) Result(name string) (ScrubResult, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	res, ok := sc.results[name]
+	return res, ok
+}
+
+// Stats returns the scrub counters.
+func (sc *Scrubber) Stats() ScrubStats {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.stats
+}
+
+// Annotate flags recordings the last pass found corrupt.
+func (sc *Scrubber) Annotate(recs []RecordingInfo) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	for i := range recs {
+		if res, ok := sc.results[recs[i].Name]; ok && !res.OK {
+			recs[i].Corrupt = true
+			recs[i].ScrubError = res.Error
+		}
+	}
+}
+
+// scrubFile reads one recording in a single pass, validating the container
+// while hashing it, then compares the hash with the stored checksum.
+func scrubFile(st storage.Storage, info storage.FileInfo, hasChecksum bool) error {
+	rc, err := st.Open(info.Name)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	h := sha256.New()
+	if err := checkMP4(io.TeeReader(bufio.NewReaderSize(rc, 256<<10), h), info.Size); err != nil {
+		return err
+	}
+	if !hasChecksum {
+		return nil // recorded before checksums were stored
+	}
+	return verifyChecksum(st, info.Name, h)
+}
+
+// verifyChecksum compares h with the checksum stored for name.
+func verifyChecksum(st storage.Storage, name string, h hash.Hash) error {
+	rc, err := st.Open(checksumName(name))
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	line, err := io.ReadAll(io.LimitReader(rc, 1024))
+	if err != nil {
+		return err
+	}
+	want, _, _ := strings.Cut(strings.TrimSpace(string(line)), " ")
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		return fmt.Errorf("checksum mismatch: stored %.12s, now %.12s", want, got)
+	}
+	return nil
+}
+
+// mp4Box is a box header read by checkMP4.
+type mp4Box struct {
+	typ        string
+	start, end int64 // file offsets of the whole box
+	header     int64
+}
+
+// checkMP4 reads a whole MP4 from r (size bytes long) and checks that the
+// top-level boxes tile the file exactly (no truncation or trailing
+// garbage), that ftyp, moov and mdat are present, and that every chunk
+// offset in the sample tables points into an mdat.
+func checkMP4(r io.Reader, size int64) error {
+	var (
+		pos     int64
+		moov    []byte
+		moovPos int64
+		mdats   [][2]int64
+		first   = true
+		hasFtyp bool
+	)
+	hdr := make([]byte, 16)
+	for pos < size {
+		box, err := readBoxHeader(r, hdr, pos, size)
+		if err != nil {
+			return err
+		}
+		if first && box.typ != "ftyp" {
+			return fmt.Errorf("first box is %q, not ftyp", box.typ)
+		}
+		first = false
+		body := box.end - box.start - box.header
+		switch box.typ {
+		case "ftyp":
+			hasFtyp = true
+		case "moov":
+			if body > maxMoovSize {
+				return fmt.Errorf("moov box too large (%d bytes)", body)
+			}
+			moov = make([]byte, body)
+			if _, err := io.ReadFull(r, moov); err != nil {
+				return fmt.Errorf("moov truncated: %w", err)
+			}
+			moovPos = box.start + box.header
+			body = 0
+		case "mdat":
+			mdats = append(mdats, [2]int64{box.start + box.header, box.end})
+		}
+		if body > 0 {
+			if n, err := io.CopyN(io.Discard, r, body); err != nil {
+				return fmt.Errorf("%s box truncated at %d: %w", box.typ, box.start+box.header+n, err)
+			}
+		}
+		pos = box.end
+	}
+	if n, _ := io.CopyN(io.Discard, r, 1); n > 0 {
+		return fmt.Errorf("file longer than its listed size %d", size)
+	}
+	switch {
+	case !hasFtyp:
+		return errors.New("no ftyp box")
+	case moov == nil:
+		return errors.New("no moov box (index missing)")
+	case len(mdats) == 0:
+		return errors.New("no mdat box")
+	}
+	return checkChunkOffsets(moov, moovPos, mdats)
+}
+
+// readBoxHeader reads the box header at pos and checks it fits in size.
+func readBoxHeader(r io.Reader, hdr []byte, pos, size int64) (mp4Box, error) {
+	if _, err := io.ReadFull(r, hdr[:8]); err != nil {
+		return mp4Box{}, fmt.Errorf("box header at %d truncated: %w", pos, err)
+	}
+	box := mp4Box{typ: string(hdr[4:8]), start: pos, header: 8}
+	n := int64(binary.BigEndian.Uint32(hdr[:4]))
+	switch n {
+	case 0: // extends to end of file
+		n = size - pos
+	case 1:
+		if _, err := io.ReadFull(r, hdr[8:16]); err != nil {
+			return mp4Box{}, fmt.Errorf("box header at %d truncated: %w", pos, err)
+		}
+		n = int64(binary.BigEndian.Uint64(hdr[8:16]))
+		box.header = 16
+	}
+	if n < box.header || pos+n > size {
+		return mp4Box{}, fmt.Errorf("%q box at %d has invalid size %d (file is %d bytes)", box.typ, pos, n, size)
+	}
+	box.end = pos + n
+	return box, nil
+}
+
+// checkChunkOffsets walks moov → trak → mdia → minf → stbl and checks every
+// stco/co64 entry against the mdat ranges.
+func checkChunkOffsets(moov []byte, moovPos int64, mdats [][2]int64) error {
+	inMdat := func(off int64) bool {
+		for _, m := range mdats {
+			if off >= m[0] && off < m[1] {
+				return true
+			}
+		}
+		return false
+	}
+	var walk func(buf []byte, base int64) error
+	walk = func(buf []byte, base int64) error {
+		r := bytes.NewReader(buf)
+		hdr := make([]byte, 16)
+		var pos int64
+		for pos < int64(len(buf)) {
+			box, err := readBoxHeader(r, hdr, pos, int64(len(buf)))
+			if err != nil {
+				return fmt.Errorf("moov: %w", err)
+			}
+			body := buf[box.start+box.header : box.end]
+			r.Seek(box.end, io.SeekStart)
+			switch box.typ {
+			case "trak", "mdia", "minf", "stbl":
+				if err := walk(body, base+box.start+box.header); err != nil {
+					return err
+				}
+			case "stco", "co64":
+				width := 4
+				if box.typ == "co64" {
+					width = 8
+				}
+				if len(body) < 8 {
+					return fmt.Errorf("%s at %d truncated", box.typ, base+box.start)
+				}
+				count := int(binary.BigEndian.Uint32(body[4:8]))
+				if len(body)-8 < count*width {
+					return fmt.Errorf("%s at %d lists %d chunks but holds %d", box.typ, base+box.start, count, (len(body)-8)/width)
+				}
+				for i := 0; i < count; i++ {
+					var off int64
+					if width == 4 {
+						off = int64(binary.BigEndian.Uint32(body[8+i*4:]))
+					} else {
+						off = int64(binary.BigEndian.Uint64(body[8+i*8:]))
+					}
+					if !inMdat(off) {
+						return fmt.Errorf("chunk %d offset %d is outside media data", i, off)
+					}
+				}
+			}
+			pos = box.end
+		}
+		return nil
+	}
+	return walk(moov, moovPos)
+}
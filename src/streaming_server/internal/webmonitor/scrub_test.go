@@ -0,0 +1,112 @@
+package webmonitor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func buildBox(typ string, body ...[]byte) []byte {
+	payload := bytes.Join(body, nil)
+	out := binary.BigEndian.AppendUint32(nil, uint32(8+len(payload)))
+	return append(append(out, typ...), payload...)
+}
+
+// testMP4 builds ftyp + mdat + moov whose single stco entry points at
+// chunkOffset (the mdat payload starts at 20).
+func testMP4(chunkOffset uint32) []byte {
+	stco := binary.BigEndian.AppendUint32(make([]byte, 4), 1) // version/flags, count
+	stco = binary.BigEndian.AppendUint32(stco, chunkOffset)
+	moov := buildBox("moov", buildBox("mvhd", make([]byte, 4)),
+		buildBox("trak", buildBox("mdia", buildBox("minf", buildBox("stbl", buildBox("stco", stco))))))
+	return bytes.Join([][]byte{
+		buildBox("ftyp", []byte("isom")),
+		buildBox("mdat", []byte("frame-data")),
+		moov,
+	}, nil)
+}
+
+func TestCheckMP4(t *testing.T) {
+	good := testMP4(20)
+	cases := []struct {
+		name string
+		data []byte
+		want string // error substring, "" = valid
+	}{
+		{"valid", good, ""},
+		{"truncated", good[:len(good)-3], "invalid size"},
+		{"index outside mdat", testMP4(1 << 20), "outside media data"},
+		{"no moov", bytes.Join([][]byte{buildBox("ftyp"), buildBox("mdat", []byte("x"))}, nil), "no moov"},
+		{"not mp4", append(buildBox("free"), good...), "not ftyp"},
+	}
+	for _, c := range cases {
+		err := checkMP4(bytes.NewReader(c.data), int64(len(c.data)))
+		switch {
+		case c.want == "" && err != nil:
+			t.Errorf("%s: %v", c.name, err)
+		case c.want != "" && (err == nil || !strings.Contains(err.Error(), c.want)):
+			t.Errorf("%s: err = %v, want %q", c.name, err, c.want)
+		}
+	}
+}
+
+func TestScrubberPass_ChecksumAndListing(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	okPath := write("recording_20261016_100000.mp4", testMP4(20))
+	rotPath := write("recording_20261016_110000.mp4", testMP4(20))
+	write("recording_20261016_120000.mp4", testMP4(20)) // legacy: no checksum
+	for _, p := range []string{okPath, rotPath} {
+		if err := writeChecksum(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Flip a byte inside the media data: still parses, checksum no longer matches.
+	data, _ := os.ReadFile(rotPath)
+	data[22] ^= 0xff
+	write(filepath.Base(rotPath), data)
+
+	rec := NewRecorder(dir, "/nonexistent")
+	sc := NewScrubber(rec, 0)
+	sc.MinAge = 0
+	sc.Pass()
+
+	if st := sc.Stats(); st.Checked != 3 || st.Corrupt != 1 {
+		t.Fatalf("stats = %+v, want 3 checked, 1 corrupt", st)
+	}
+	recs, err := rec.ListRecordings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc.Annotate(recs)
+	for _, r := range recs {
+		wantCorrupt := r.Name == filepath.Base(rotPath)
+		if r.Corrupt != wantCorrupt {
+			t.Errorf("%s: corrupt = %v (%s)", r.Name, r.Corrupt, r.ScrubError)
+		}
+		if wantCorrupt && !strings.Contains(r.ScrubError, "checksum mismatch") {
+			t.Errorf("%s: scrub error %q", r.Name, r.ScrubError)
+		}
+	}
+
+	// Deleted recordings drop out of the results on the next pass.
+	if err := rec.DeleteRecording(filepath.Base(rotPath)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(checksumName(rotPath)); !os.IsNotExist(err) {
+		t.Fatalf("checksum not deleted with its recording: %v", err)
+	}
+	sc.Pass()
+	if st := sc.Stats(); st.Corrupt != 0 {
+		t.Fatalf("corrupt = %d after delete", st.Corrupt)
+	}
+}
@@ -0,0 +1,189 @@
+package webmonitor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/clock"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/crash"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/sdnotify"
+)
+
+// Run creates the monitor server and serves it (HTTPS when a TLS cert is
+// configured, plus the optional plain-HTTP listener) until ctx is cancelled.
+// setup, if non-nil, runs before listening, e.g. to add readiness checks.
+func Run(ctx context.Context, cfg Config, setup func(*Server)) error {
+	loc, err := clock.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return fmt.Errorf("timezone %q: %w", cfg.Timezone, err)
+	}
+	clock.SetLocation(loc)
+	logger.Info("Main", "Time zone: %s (NTP synced: %v)", loc, clock.NTPSynced())
+
+	var iceServers []ICEServer
+	var turnSecret *TURNSecret
+	if cfg.TURNSecretFile != "" {
+		if turnSecret, err = LoadTURNSecret(cfg.TURNSecretFile, cfg.TURNCredentialTTL); err != nil {
+			return fmt.Errorf("-turn-secret-file: %w", err)
+		}
+		iceServers, err = parseICEServers(cfg.ICEServers)
+		logger.Info("Main", "TURN credentials: issued from %s, valid %v", cfg.TURNSecretFile, cfg.TURNCredentialTTL)
+	} else {
+		iceServers, err = ParseICEServers(cfg.ICEServers, cfg.TURNUsername, cfg.TURNCredential)
+	}
+	if err != nil {
+		return fmt.Errorf("-stun: %w", err)
+	}
+
+	if cfg.ShareMaxTTL <= 0 {
+		return fmt.Errorf("-share-max-ttl must be positive, got %v", cfg.ShareMaxTTL)
+	}
+	if _, err := ParsePowerHours(cfg.PowerSaveHours); err != nil {
+		return fmt.Errorf("-power-save-hours: %w", err)
+	}
+	if err := (FilterProfile{MinConfidence: cfg.DayMinConfidence}).validate(); err != nil {
+		return fmt.Errorf("-day-min-confidence: %w", err)
+	}
+	if err := (FilterProfile{MinConfidence: cfg.NightMinConfidence}).validate(); err != nil {
+		return fmt.Errorf("-night-min-confidence: %w", err)
+	}
+	if err := validateClientBuffers(cfg); err != nil {
+		return err
+	}
+	logger.Info("Main", "Client buffers: MJPEG %d frames (~%d KiB per viewer), SSE %d events",
+		cfg.MJPEGClientBuffer, cfg.MJPEGClientBuffer*mjpegFrameEstimate/1024, cfg.SSEClientBuffer)
+
+	crash.SetDir(cfg.CrashDir)
+	SetJPEGQuality(cfg.JPEGQuality)
+	logger.Info("Main", "JPEG quality: %d", cfg.JPEGQuality)
+
+	server := NewServer(cfg)
+	server.iceServers = iceServers
+	server.turnSecret = turnSecret
+	if setup != nil {
+		setup(server)
+	}
+
+	// Bind before reporting ready; systemd socket activation hands the
+	// sockets over pre-opened (FileDescriptorName=http/http-unix/http-only).
+	lns, err := sdnotify.ListenAddrs("http", cfg.Addr, cfg.Socket, cfg.SocketMode)
+	if err == nil && len(lns) == 0 {
+		err = fmt.Errorf("no HTTP listener: set -http or -socket")
+	}
+	if err != nil {
+		server.Shutdown()
+		return err
+	}
+
+	serveErr := make(chan error, 3)
+	var httpOnlyServer *http.Server
+	if cfg.HTTPOnlyAddr != "" {
+		httpOnlyServer = &http.Server{Addr: cfg.HTTPOnlyAddr, Handler: server.Handler()}
+		if onlyLn, err := sdnotify.Listen("http-only", cfg.HTTPOnlyAddr); err != nil {
+			logger.Error("Main", "HTTP-only server error: %v", err)
+		} else {
+			go func() {
+				logger.Info("Main", "HTTP-only server listening on %s (MJPEG/API)", cfg.HTTPOnlyAddr)
+				if err := httpOnlyServer.Serve(onlyLn); err != nil && err != http.ErrServerClosed {
+					logger.Error("Main", "HTTP-only server error: %v", err)
+				}
+			}()
+		}
+	}
+
+	httpServer := &http.Server{Addr: cfg.Addr, Handler: server.Handler()}
+	logger.Info("Main", "Assets: %s (build: %s)", cfg.AssetsDir, cfg.BuildAssetsDir)
+	for _, ln := range lns {
+		go func() {
+			var err error
+			if ln.Addr().Network() == "unix" {
+				// Local clients only: the file mode is the access control
+				logger.Info("Main", "Go web monitor listening on unix:%s (HTTP)", ln.Addr())
+				err = httpServer.Serve(ln)
+			} else if cfg.TLSCertFile != "" && cfg.TLSKeyFile != "" {
+				logger.Info("Main", "Go web monitor listening on %s (HTTPS)", ln.Addr())
+				logger.Info("Main", "TLS cert: %s", cfg.TLSCertFile)
+				err = httpServer.ServeTLS(ln, cfg.TLSCertFile, cfg.TLSKeyFile)
+			} else {
+				logger.Info("Main", "Go web monitor listening on %s (HTTP)", ln.Addr())
+				err = httpServer.Serve(ln)
+			}
+			if err != nil && err != http.ErrServerClosed {
+				serveErr <- err
+			}
+		}()
+	}
+
+	detachLogs := server.attachLogHooks()
+	defer detachLogs()
+	watchdog := sdnotify.NewWatchdog(server.checkAliveAlerting)
+	watchdog.Start()
+	go notifyWhenReady(ctx, server)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+wait:
+	for {
+		select {
+		case <-ctx.Done():
+			break wait
+		case err = <-serveErr:
+			break wait
+		case <-hup:
+			server.Reload()
+		}
+	}
+
+	sdnotify.Stopping()
+	watchdog.Stop()
+	logger.Info("Main", "Shutting down...")
+	server.Shutdown()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if httpOnlyServer != nil {
+		httpOnlyServer.Shutdown(shutdownCtx)
+	}
+	if shutdownErr := httpServer.Shutdown(shutdownCtx); shutdownErr != nil {
+		logger.Warn("Main", "HTTP shutdown error: %v", shutdownErr)
+	}
+	logger.Info("Main", "Server stopped")
+	return err
+}
+
+// Reload re-reads what can change without a restart (SIGHUP, systemctl
+// reload): the TURN secret. A failed reload keeps the previous secret. The
+// API-managed settings, tokens included, are in the settings store, which
+// only the API changes.
+func (s *Server) Reload() {
+	logger.Info("Main", "Reloading configuration")
+	if s.turnSecret != nil {
+		if err := s.turnSecret.Reload(); err != nil {
+			logger.Warn("Main", "TURN secret reload failed, keeping the previous secret: %v", err)
+		} else {
+			logger.Info("Main", "TURN secret reloaded from %s", s.cfg.TURNSecretFile)
+		}
+	}
+}
+
+// notifyWhenReady sends READY=1 once the listeners are serving and /readyz
+// passes (frame SHM attached, supervised children up).
+func notifyWhenReady(ctx context.Context, server *Server) {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for !server.Ready() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+	sdnotify.Ready("serving")
+}
@@ -2,8 +2,10 @@ package webmonitor
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -16,7 +18,22 @@ import (
 	"sync"
 	"time"
 
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/buildinfo"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/clock"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/crash"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/degrade"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/eventbus"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/httpcompress"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/httplog"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
 	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/mqtt"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/outbox"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/ptz"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/relay"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/shm"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/storage"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/timeseries"
 )
 
 type mjpegStreamEntry struct {
@@ -29,19 +46,78 @@ type Server struct {
 	cfg                   Config
 	monitor               *Monitor
 	recorder              *Recorder
+	timed                 timedRecording // the active recording's deadline, if it has one
+	filmstrips            *Filmstrips    // scrubber sprite sheets, cached under the recording path
 	webrtc                *http.Client
 	broadcaster           *FrameBroadcaster
 	detectionBroadcaster  *DetectionBroadcaster
+	detections            DetectionSource // detector input; nil when unavailable
 	statusBroadcaster     *StatusBroadcaster
 	connectionBroadcaster *ConnectionBroadcaster
 	heatmapBroadcaster    *HeatmapBroadcaster
 	comicCapture          *ComicCapture
+	motionDetector        *MotionDetector
+	forwarder             *FrameForwarder // nil unless ForwardURL is set
+	detectorProxy         http.Handler    // nil unless DetectorProxyURL is set
+	petID                 *PetIdentifier  // nil unless PetEmbedURL is set
+	sound                 *SoundDetector
+	iceServers            []ICEServer   // browser RTCPeerConnection config (set by Run)
+	turnSecret            *TURNSecret   // nil unless TURNSecretFile is set (set by Run)
+	shares                *ShareSigner  // nil without a usable ShareKeyFile
+	outbox                *outbox.Queue // nil without OutboxPath
+	tokens                *TokenStore
+	detectionHealth       *DetectionHealth
 	detectionHistory      *DetectionHistory
+	rollup                *DetectionRollup
+	rules                 *RulesEngine
+	classFilter           *ClassFilter
+	masks                 *MaskSet
+	uiConfig              *UIConfigStore
+	settings              kv.Store // where the API-managed configuration persists
+	events                *EventStore
+	activity              *ActivityTracker
+	zones                 *ZoneSet
+	occupancy             *ZoneOccupancy
+	snapshots             *Snapshotter
+	push                  *PushNotifier
+	relay                 *relay.Client
+	triggers              *Triggers
+	mqtt                  *mqtt.Client // nil unless MQTTURL is set
+	federation            *Federation
+	demand                *shm.Demand
+	powerSaver            *PowerSaver      // nil unless PowerSaveIdleAfter is set
+	lighting              *LightingWatcher // day/night from the active camera
+	ptz                   *ptz.Controller  // nil unless PTZBackend is set
+	degrade               *degrade.Controller
+	stateSaver            *StateSaver
+	timeseries            *timeseries.Store
+	metricsSampler        *MetricsSampler
+	statusHistory         *StatusHistory // nil when StatusHistoryRetention is 0
+	statusHistoryStore    kv.Store
+	scrubber              *Scrubber // nil when ScrubInterval is 0
+	alerts                *AlertCenter
+	bus                   *eventbus.Bus
+	topics                busTopics
+	busCancels            []func()  // bus subscriptions owned by the server
+	audit                 *AuditLog // nil unless AuditLogPath is set
+	logRing               *logger.LevelRing
+	access                *httplog.Middleware
+	compress              *httpcompress.Middleware
+	logHooks              []*logger.Registration
+	diskMonitor           *DiskMonitor    // nil for S3 storage
+	recordingPaths        *RecordingPaths // nil without RecordingFallback
+	clockJumps            *clock.JumpDetector
+	metrics               http.Handler
+	dashboard             http.Handler // Grafana JSON of metrics
+	readyChecks           []ReadyCheck
 
 	// Per-session MJPEG stream tracking: cancel old stream when same browser reconnects
 	mjpegStreamsMu sync.Mutex
 	mjpegStreams   map[string]mjpegStreamEntry // key: session ID (cookie-based)
 	mjpegStreamSeq int                         // monotonic ID generator
+
+	// Per-stream bytes, per-IP stream limit and total bandwidth cap (/api/streams)
+	mjpegAccounting *MJPEGStreams
 }
 
 // NewServer returns a configured monitor server.
@@ -64,21 +140,76 @@ func NewServer(cfg Config) *Server {
 	}
 
 	monitor := NewMonitor(cfg.TargetFPS, shm)
+	if cfg.DetectionHistoryDepth > 0 {
+		monitor.HistoryDepth = cfg.DetectionHistoryDepth
+	}
+	monitor.Detector = cfg.DetectorInput
+	monitor.NormalizeBBoxes = cfg.DetectionBBoxNorm
+	detections, err := newDetectionSource(cfg, shm)
+	if err != nil {
+		logger.Warn("Server", "Detection source %q unavailable: %v", cfg.DetectionSource, err)
+	}
+	monitor.SetDetectionSource(detections)
 
-	// Build WebRTC client count URL
-	webrtcCountURL := strings.TrimRight(cfg.WebRTCBaseURL, "/") + "/api/clients/count"
+	// API-managed configuration (rules, masks, tokens, ...), one document
+	// per subsystem
+	settings := openSettings(cfg)
+
+	// Classes of interest: other detections are dropped on arrival
+	classFilter := NewClassFilter(classesDoc.in(settings))
+	classFilter.SetDefaultProfiles(FilterProfile{MinConfidence: cfg.DayMinConfidence}, FilterProfile{MinConfidence: cfg.NightMinConfidence})
+	if err := classFilter.Load(); err != nil {
+		logger.Warn("Server", "Failed to load detection classes: %v", err)
+	}
+	// Ignore masks: detections centered inside one are dropped next
+	masks := NewMaskSet(masksDoc.in(settings))
+	if err := masks.Load(); err != nil {
+		logger.Warn("Server", "Failed to load masks: %v", err)
+	}
+	if cfg.Locale != "" && matchLocale(cfg.Locale) == "" {
+		logger.Warn("Server", "Unsupported locale %q (%v), using %s", cfg.Locale, locales(), fallbackLocale)
+	}
+	uiConfig := NewUIConfigStore(uiDoc.in(settings))
+	if err := uiConfig.Load(); err != nil {
+		logger.Warn("Server", "Failed to load UI config: %v", err)
+	}
+	filter := func(dets []Detection) []Detection {
+		return masks.Filter(classFilter.Filter(dets))
+	}
+	monitor.Filter = filter
+
+	// Build WebRTC stats URL (client count + per-session quality)
+	webrtcStatsURL := strings.TrimRight(cfg.WebRTCBaseURL, "/") + "/api/webrtc/stats"
 
 	// Create ConnectionBroadcaster first to get the onChange channel
-	connectionBroadcaster, onChange := NewConnectionBroadcaster(webrtcCountURL)
+	connectionBroadcaster, onChange := NewConnectionBroadcaster(webrtcStatsURL)
 
 	// Create other broadcasters with the onChange channel for notifications
 	broadcaster := NewFrameBroadcaster(shm, monitor, onChange)
+	detectionBroadcaster := NewDetectionBroadcaster(detections, monitor, onChange)
+	statusBroadcaster := NewStatusBroadcaster(shm, monitor, cfg.StatusInterval, onChange)
+	if cfg.MJPEGClientBuffer > 0 {
+		broadcaster.SetClientBuffer(cfg.MJPEGClientBuffer)
+	}
+	if cfg.MJPEGEncodeBudget > 0 {
+		quality := NewMJPEGQualityController(cfg.JPEGQuality, cfg.MJPEGEncodeBudget)
+		quality.MinQuality = min(cfg.MJPEGMinQuality, cfg.JPEGQuality)
+		broadcaster.SetQualityController(quality)
+		statusBroadcaster.SetMJPEGQuality(quality.Status)
+	}
+	var follow *PetFollow
+	if cfg.FollowZoom > 1 {
+		follow = NewPetFollow(cfg.FollowZoom)
+		broadcaster.SetFollow(follow)
+	}
+	if cfg.SSEClientBuffer > 0 {
+		detectionBroadcaster.SetClientBuffer(cfg.SSEClientBuffer)
+		statusBroadcaster.SetClientBuffer(cfg.SSEClientBuffer)
+	}
+	detectionBroadcaster.SetFilter(filter) // injected results (motion, forwarder)
+	broadcaster.SetMaskOverlay(masks.OverlayMasks)
 	broadcaster.Start()
-
-	detectionBroadcaster := NewDetectionBroadcaster(shm, monitor, onChange)
 	detectionBroadcaster.Start()
-
-	statusBroadcaster := NewStatusBroadcaster(shm, monitor, cfg.StatusInterval, onChange)
 	statusBroadcaster.Start()
 
 	// Wire up ConnectionBroadcaster with references to other broadcasters
@@ -92,7 +223,40 @@ func NewServer(cfg Config) *Server {
 	}
 
 	recorder := NewRecorder(cfg.RecordingOutputPath, streamShmName)
-	detectionHistory := NewDetectionHistory(24 * time.Hour)
+	var recordingPaths []string
+	switch {
+	case cfg.RecordingFallback == "":
+	case cfg.RecordingStorage != "":
+		logger.Warn("Server", "Ignoring -recording-fallback: recordings go to %s", cfg.RecordingStorage)
+	default:
+		recordingPaths = ParseRecordingPaths(cfg.RecordingOutputPath, cfg.RecordingFallback)
+	}
+	if len(recordingPaths) > 1 {
+		st := storage.NewPaths(recordingPaths...)
+		recorder.SetStorage(st)
+		logger.Info("Server", "Recording storage: %s", st)
+	} else if st, err := storage.New(cfg.RecordingStorage, cfg.RecordingOutputPath); err != nil {
+		logger.Warn("Server", "Recording storage disabled, using %s: %v", cfg.RecordingOutputPath, err)
+	} else {
+		recorder.SetStorage(st)
+		logger.Info("Server", "Recording storage: %s", st)
+	}
+	if cfg.ProxyShmName != "" {
+		recorder.SetProxyShm(cfg.ProxyShmName)
+	}
+	recorder.SetStopTrim(cfg.RecordStopTrim, cfg.RecordGOPWait)
+	if cfg.SEITimestamp {
+		camera := cfg.CameraName
+		if camera == "" {
+			camera, _ = os.Hostname()
+		}
+		recorder.SetTimestampSEI(camera)
+	}
+	retention := cfg.DetectionHistoryRetention
+	if retention <= 0 {
+		retention = 24 * time.Hour
+	}
+	detectionHistory := NewDetectionHistory(retention)
 
 	// Load persisted detection history from previous run
 	if cfg.DetectionHistoryPath != "" {
@@ -103,14 +267,97 @@ func NewServer(cfg Config) *Server {
 		}
 	}
 
+	// Per-minute/per-hour rollups for calendar and range queries
+	rollup := NewDetectionRollup()
+	if cfg.RollupPath != "" {
+		if err := rollup.Load(cfg.RollupPath); err != nil {
+			logger.Warn("Server", "Failed to load detection rollups: %v", err)
+		}
+	}
+
 	// Wire up detection callback for recording thumbnail
 	detectionBroadcaster.SetOnDetection(func() {
 		recorder.NotifyDetection()
 	})
-	// Wire up detection history recording
+	// In-process event bus: producers publish, subsystems subscribe below
+	bus := eventbus.New()
+	topics := newBusTopics(bus)
+
+	// User-defined notification rules
+	rules := NewRulesEngine(rulesDoc.in(settings))
+	if err := rules.Load(); err != nil {
+		logger.Warn("Server", "Failed to load rules: %v", err)
+	}
+	rules.Start()
+
+	// Synthesized events (feeding/drinking) feed the events store and rules
+	events := NewEventStore(7 * 24 * time.Hour)
+	if cfg.EventsPath != "" {
+		if err := events.Load(cfg.EventsPath); err != nil {
+			logger.Warn("Server", "Failed to load events: %v", err)
+		}
+	}
+	events.AddListener(topics.events.Publish)
+	topics.events.Subscribe("log", func(ev Event) {
+		logger.Info("Events", "%s (class=%s)", ev.Type, ev.Class)
+	})
+	topics.events.Subscribe("rules", func(ev Event) {
+		rules.ObserveEvent(ev, time.Now())
+	})
+	activity := NewActivityTracker(func(ev Event) { events.Append(ev) })
+	activity.Start()
+	// Named zones and who is in them
+	zones := NewZoneSet(zonesDoc.in(settings))
+	if err := zones.Load(); err != nil {
+		logger.Warn("Server", "Failed to load zones: %v", err)
+	}
+	occupancy := NewZoneOccupancy(zones, func(ev Event) { events.Append(ev) }, rollup.RecordOccupancy)
+	occupancy.Start()
+	sound := NewSoundDetector(func(ev Event) { events.Append(ev) })
+	sound.ThresholdDB = cfg.SoundThresholdDB
+
+	// Pet identification with its own SHM reader (crops pet boxes from frames)
+	var petID *PetIdentifier
+	if cfg.PetEmbedURL != "" {
+		if petShm, err := newSHMReader(cfg.FrameShmName, cfg.DetectionShmName); err == nil {
+			petID = NewPetIdentifier(cfg.PetEmbedURL, petShm, petsDoc.in(settings))
+			if cfg.PetMatchThreshold > 0 {
+				petID.Threshold = cfg.PetMatchThreshold
+			}
+			if err := petID.Load(); err != nil {
+				logger.Warn("PetID", "Failed to load pet profiles: %v", err)
+			}
+			logger.Info("PetID", "Identification enabled (%d enrolled, threshold %.2f)", len(petID.Profiles()), petID.Threshold)
+		} else {
+			logger.Warn("PetID", "Disabled: SHM reader failed: %v", err)
+		}
+	}
+
+	// Publish detections after pet identification; history recording,
+	// rollups, recording sidecars, activity synthesis and rules subscribe
 	detectionBroadcaster.SetOnDetectionData(func(det *DetectionResult) {
-		detectionHistory.Record(det)
+		if petID != nil {
+			petID.Annotate(det) // before anything records or serializes det
+		}
+		topics.detections.Publish(det)
+	})
+	topics.detections.Subscribe("history", detectionHistory.Record)
+	topics.detections.Subscribe("rollup", rollup.Record)
+	topics.detections.Subscribe("recorder", recorder.ObserveDetection)
+	topics.detections.Subscribe("activity", func(det *DetectionResult) {
+		activity.Observe(det, time.Now())
+	})
+	topics.detections.Subscribe("zones", func(det *DetectionResult) {
+		occupancy.Observe(det, time.Now())
 	})
+	topics.detections.Subscribe("rules", func(det *DetectionResult) {
+		rules.Observe(det, time.Now())
+	})
+	if follow != nil {
+		topics.detections.Subscribe("follow", func(det *DetectionResult) {
+			follow.Observe(det, time.Now())
+		})
+	}
 
 	// Start heatmap broadcaster (watches base_diff grid file from Python detector)
 	heatmapBroadcaster := NewHeatmapBroadcaster("/tmp/base_diff_grid.json")
@@ -121,26 +368,346 @@ func NewServer(cfg Config) *Server {
 	if comicShm, err := newSHMReader(cfg.FrameShmName, cfg.DetectionShmName); err == nil {
 		comicsDir := filepath.Join(cfg.RecordingOutputPath, "comics")
 		comicCapture = NewComicCapture(comicShm, comicsDir)
+		comicCapture.Detector = cfg.DetectorInput
 		comicCapture.Start()
 		log.Printf("[Comic] Started (frame=%s, detection=%s, output=%s)", cfg.FrameShmName, cfg.DetectionShmName, comicsDir)
 	} else {
 		log.Printf("[Comic] Disabled: SHM reader failed: %v", err)
 	}
 
-	return &Server{
+	// Motion fallback with its own SHM reader (independent detection version tracking)
+	var motionDetector *MotionDetector
+	if cfg.MotionFallback {
+		if motionShm, err := newSHMReader(cfg.FrameShmName, cfg.DetectionShmName); err == nil {
+			motionDetector = NewMotionDetector(motionShm, detectionBroadcaster.Publish)
+			if cfg.MotionSensitivity > 0 {
+				motionDetector.Sensitivity = cfg.MotionSensitivity
+			}
+			if cfg.MotionMinArea > 0 {
+				motionDetector.MinArea = cfg.MotionMinArea
+			}
+			motionDetector.Start()
+			logger.Info("Motion", "Fallback enabled (sensitivity=%d, min_area=%.3f)", motionDetector.Sensitivity, motionDetector.MinArea)
+		} else {
+			logger.Warn("Motion", "Fallback disabled: SHM reader failed: %v", err)
+		}
+	}
+
+	// Secondary inference with its own SHM reader
+	var forwarder *FrameForwarder
+	if cfg.ForwardURL != "" {
+		if forwardShm, err := newSHMReader(cfg.FrameShmName, cfg.DetectionShmName); err == nil {
+			forwarder = NewFrameForwarder(cfg.ForwardURL, forwardShm, detectionBroadcaster.Publish)
+			if cfg.ForwardInterval > 0 {
+				forwarder.Interval = cfg.ForwardInterval
+			}
+			if cfg.ForwardMaxWidth > 0 {
+				forwarder.MaxWidth = cfg.ForwardMaxWidth
+			}
+			forwarder.Start()
+			logger.Info("Forwarder", "Forwarding frames to %s (every %v, max width %d)", cfg.ForwardURL, forwarder.Interval, forwarder.MaxWidth)
+		} else {
+			logger.Warn("Forwarder", "Disabled: SHM reader failed: %v", err)
+		}
+	}
+
+	mjpegAccounting := NewMJPEGStreams()
+	mjpegAccounting.MaxPerIP = cfg.MJPEGMaxPerIP
+	mjpegAccounting.MaxKbps = cfg.MJPEGMaxKbps
+
+	// Detector debug UI through this origin
+	var detectorProxy http.Handler
+	if cfg.DetectorProxyURL != "" {
+		if h, err := newDetectorProxy(cfg.DetectorProxyURL, cfg.DetectorProxyToken); err == nil {
+			detectorProxy = h
+			logger.Info("DetectorProxy", "Serving %s at /detector/", cfg.DetectorProxyURL)
+			if cfg.DetectorProxyToken == "" {
+				logger.Warn("DetectorProxy", "PET_CAMERA_DETECTOR_PROXY_TOKEN is not set; /detector/ refuses all requests")
+			}
+		} else {
+			logger.Warn("DetectorProxy", "Disabled: %v", err)
+		}
+	}
+
+	// Detection daemon liveness (version counter must keep advancing)
+	detectionHealth := NewDetectionHealth(monitor.DetectionVersion)
+	if cfg.DetectionStaleAfter > 0 {
+		detectionHealth.StaleAfter = cfg.DetectionStaleAfter
+	}
+	if cfg.DetectionAlertAfter > 0 {
+		detectionHealth.AlertAfter = cfg.DetectionAlertAfter
+	}
+
+	// Snapshots (push notification thumbnails) with their own SHM reader
+	var snapshotShm *shmReader
+	if reader, err := newSHMReader(cfg.FrameShmName, cfg.DetectionShmName); err == nil {
+		snapshotShm = reader
+	}
+	snapshots := NewSnapshotter(snapshotShm, filepath.Join(cfg.RecordingOutputPath, "snapshots"))
+
+	// Web Push (VAPID key next to recordings, subscriptions in the settings)
+	var push *PushNotifier
+	if cfg.PushKeyPath != "" {
+		if n, err := NewPushNotifier(cfg.PushKeyPath, pushDoc.in(settings), cfg.PushSubject); err == nil {
+			push = n
+			push.DefaultLocale = configLocale(cfg)
+			logger.Info("Push", "Web Push enabled (%d subscription(s))", n.Count())
+		} else {
+			logger.Warn("Push", "Web Push disabled: %v", err)
+		}
+	}
+
+	// Outbound queue for notifications and uploads (survives outages)
+	var queue *outbox.Queue
+	if cfg.OutboxPath != "" {
+		if q, err := outbox.Open(cfg.OutboxPath); err == nil {
+			queue = q
+			recorder.SetOutbox(q)
+			if push != nil {
+				q.Register(pushJobKind, push.deliver)
+			}
+			q.Start()
+		} else {
+			logger.Warn("Outbox", "Outbound queue disabled, sending once: %v", err)
+		}
+	}
+
+	// Signed /share/ links (key persisted next to recordings)
+	var shares *ShareSigner
+	if cfg.ShareKeyFile != "" {
+		if sh, err := LoadShareSigner(cfg.ShareKeyFile); err == nil {
+			shares = sh
+			if cfg.AdminToken == "" {
+				logger.Info("Share", "Shared links are served, but issuing them needs PET_CAMERA_ADMIN_TOKEN")
+			}
+		} else {
+			logger.Warn("Share", "Sharing disabled: %v", err)
+		}
+	}
+
+	// Named read-only access tokens (managed via /api/tokens)
+	tokens := NewTokenStore(tokensDoc.in(settings))
+	if err := tokens.Load(); err != nil {
+		logger.Warn("Tokens", "Failed to load access tokens: %v", err)
+	} else if tokens.Enabled() && cfg.AdminToken == "" {
+		logger.Warn("Tokens", "Access tokens exist but PET_CAMERA_ADMIN_TOKEN is not set: only token holders can open the monitor")
+	}
+
+	// Federated peers (other cameras shown in the combined dashboard)
+	federation := NewFederation(peersDoc.in(settings))
+	if err := federation.Load(); err != nil {
+		logger.Warn("Server", "Failed to load peers: %v", err)
+	}
+	federation.Start()
+
+	s := &Server{
 		cfg:                   cfg,
 		monitor:               monitor,
 		recorder:              recorder,
+		filmstrips:            NewFilmstrips(filepath.Join(cfg.RecordingOutputPath, ".filmstrips")),
 		webrtc:                &http.Client{Timeout: 5 * time.Second},
 		broadcaster:           broadcaster,
 		detectionBroadcaster:  detectionBroadcaster,
+		detections:            detections,
 		statusBroadcaster:     statusBroadcaster,
 		connectionBroadcaster: connectionBroadcaster,
 		heatmapBroadcaster:    heatmapBroadcaster,
 		comicCapture:          comicCapture,
+		motionDetector:        motionDetector,
+		forwarder:             forwarder,
+		detectorProxy:         detectorProxy,
+		petID:                 petID,
+		detectionHealth:       detectionHealth,
 		detectionHistory:      detectionHistory,
+		rollup:                rollup,
+		rules:                 rules,
+		classFilter:           classFilter,
+		masks:                 masks,
+		uiConfig:              uiConfig,
+		settings:              settings,
+		events:                events,
+		activity:              activity,
+		zones:                 zones,
+		occupancy:             occupancy,
+		triggers:              NewTriggers(cfg.Triggers),
+		sound:                 sound,
+		snapshots:             snapshots,
+		push:                  push,
+		outbox:                queue,
+		shares:                shares,
+		tokens:                tokens,
+		federation:            federation,
 		mjpegStreams:          make(map[string]mjpegStreamEntry),
+		mjpegAccounting:       mjpegAccounting,
+		alerts:                NewAlertCenter(),
+		bus:                   bus,
+		topics:                topics,
+		logRing:               logger.NewLevelRing(max(cfg.LogBufferLines, 1)),
+		access:                httplog.New("webmonitor"),
+		compress:              httpcompress.New("webmonitor"),
+	}
+	tokens.SetLiveSessions(s.webrtcSessionIDs)
+	statusBroadcaster.SetDetectionHealth(s.detectionHealthStatus)
+	statusBroadcaster.SetViewers(connectionBroadcaster.Viewers)
+	statusBroadcaster.SetRecording(recorder.RecordingStatus)
+	rules.SetOnFire(s.recordRuleFired)
+	rules.SetOnAction(s.runRuleAction)
+	bus.Attach(s.alerts.Topic())
+	s.subscribeCamera()
+	notifications, cancelNotifications := topics.notifications.SubscribeChan("push", notificationBuffer)
+	s.busCancels = append(s.busCancels, cancelNotifications)
+	go s.runNotifier(notifications)
+	if cfg.AuditLogPath != "" {
+		if audit, err := OpenAuditLog(cfg.AuditLogPath); err == nil {
+			auditTopic(audit, topics.events)
+			auditTopic(audit, s.alerts.Topic())
+			auditTopic(audit, topics.camera)
+			auditTopic(audit, topics.notifications)
+			s.audit = audit
+			logger.Info("Server", "Audit log: %s", cfg.AuditLogPath)
+		} else {
+			logger.Warn("Server", "Audit log disabled: %v", err)
+		}
+	}
+
+	s.publishDetectionHealth()
+	detectionHealth.Start()
+
+	// CPU guardrail: shed MJPEG fps, then comic capture, under sustained load
+	if cfg.DegradeCPUHigh > 0 {
+		s.degrade = degrade.NewController()
+		s.degrade.High = cfg.DegradeCPUHigh
+		s.degrade.Low = cfg.DegradeCPULow
+		s.degrade.SetOnChange(s.applyDegradation)
+		s.degrade.Start()
+	}
+	if cfg.ScrubInterval > 0 {
+		s.scrubber = NewScrubber(recorder, cfg.ScrubInterval)
+		s.scrubber.Start()
+	}
+	if diskPath := cmp.Or(cfg.RecordingStorage, cfg.RecordingOutputPath); !strings.HasPrefix(diskPath, "s3://") {
+		s.diskMonitor = NewDiskMonitor(diskPath, s.alerts)
+		s.diskMonitor.Start()
+	}
+	if len(recordingPaths) > 1 {
+		// Checked once now, so a recording resumed below lands on a working path
+		s.recordingPaths = NewRecordingPaths(recordingPaths, s.switchRecordingPath, s.alerts)
+		if cfg.RecordingPathCheck > 0 {
+			s.recordingPaths.Interval = cfg.RecordingPathCheck
+		}
+		s.recordingPaths.Check()
+		s.recordingPaths.Start()
+	}
+	s.access.Log = cfg.AccessLog
+	s.compress.Enabled = cfg.Compress
+	s.metrics, s.dashboard = s.newMetricsHandler()
+
+	// Reload state from the previous run before reporting demand, so a
+	// resumed recording keeps the encoder running
+	s.restoreState()
+	s.stateSaver = NewStateSaver(s.persistAll)
+	if cfg.StateSaveInterval > 0 {
+		s.stateSaver.Interval = cfg.StateSaveInterval
+	}
+	s.stateSaver.Start()
+
+	// On-device metric history for /api/timeseries
+	s.timeseries = timeseries.New()
+	if cfg.TimeseriesPath != "" {
+		if err := s.timeseries.Load(cfg.TimeseriesPath); err != nil {
+			logger.Warn("Server", "Failed to load metric history: %v", err)
+		}
+	}
+	s.metricsSampler = NewMetricsSampler(s.timeseries, cfg.TimeseriesPath, s.readMetrics)
+	s.metricsSampler.Start()
+
+	// Per-minute status snapshots for /api/status/history
+	if cfg.StatusHistoryRetention > 0 {
+		store, err := kv.Open(cfg.StatusHistoryPath)
+		if err != nil {
+			logger.Warn("Server", "Status history store unavailable, keeping it in memory: %v", err)
+			store = kv.NewMemory()
+		}
+		s.statusHistoryStore = store
+		s.statusHistory = NewStatusHistory(store, cfg.StatusHistoryRetention, s.readStatusSnapshot)
+		s.statusHistory.Start()
+	}
+
+	s.clockJumps = clock.NewJumpDetector()
+	s.clockJumps.SetOnJump(s.handleClockJump)
+	s.clockJumps.Start()
+
+	s.demand = newEncoderDemand(recorder, cfg.EncoderIdleHoldOff)
+	s.demand.Start()
+	s.startLighting(shm)
+	if cfg.PowerSaveIdleAfter > 0 {
+		s.startPowerSaver()
+	}
+	if cfg.PTZBackend != "" {
+		s.startPTZ()
+	}
+
+	// Remote access: tunnel the full HTTP API through the broker
+	if cfg.RelayURL != "" {
+		cameraID := cfg.RelayCameraID
+		if cameraID == "" {
+			cameraID, _ = os.Hostname()
+		}
+		s.relay = relay.NewClient(cfg.RelayURL, cfg.RelayToken, cameraID, s.Handler())
+		s.relay.Start()
+	}
+
+	// External triggers over MQTT (HTTP is POST /api/triggers/fire)
+	if cfg.MQTTURL != "" {
+		s.startMQTT(cfg)
+	}
+	return s
+}
+
+// mjpegDegradedDivisor is the MJPEG frame divisor at degrade.LevelReduceMJPEG
+// (30 fps -> 10 fps).
+const mjpegDegradedDivisor = 3
+
+// applyDegradation applies the steps this process owns and records the
+// transition as an event. WebRTC decimation is applied by the streaming
+// server, which runs its own controller on the same CPU readings.
+func (s *Server) applyDegradation(from, to degrade.Level, st degrade.Status) {
+	divisor := 1
+	if to >= degrade.LevelReduceMJPEG {
+		divisor = mjpegDegradedDivisor
+	}
+	s.broadcaster.SetFrameDivisor(divisor)
+	if s.comicCapture != nil {
+		s.comicCapture.SetPaused(to >= degrade.LevelPauseAnnotated)
+	}
+
+	evType := "cpu_degraded"
+	if to < from {
+		evType = "cpu_recovered"
 	}
+	s.events.Append(Event{
+		Type: evType,
+		Data: map[string]string{
+			"from":        from.String(),
+			"level":       to.String(),
+			"cpu_percent": strconv.FormatFloat(st.CPUPercent, 'f', 0, 64),
+			"goroutines":  strconv.Itoa(st.Goroutines),
+		},
+	})
+}
+
+// newEncoderDemand reports recordings as H.265 demand to the camera daemon.
+// The recorder is this process's only H.265 consumer; WebRTC viewers are
+// reported by the streaming server.
+func newEncoderDemand(recorder *Recorder, holdOff time.Duration) *shm.Demand {
+	d := shm.NewDemand(func() int {
+		if recorder.IsRecording() {
+			return 1
+		}
+		return 0
+	})
+	d.HoldOff = holdOff
+	return d
 }
 
 // Handler exposes the HTTP handler for the server.
@@ -153,28 +720,89 @@ func (s *Server) Handler() http.Handler {
 	mux.HandleFunc("/stream", s.handleStream)
 	mux.HandleFunc("/api/status", s.handleStatus)
 	mux.HandleFunc("/api/status/stream", s.handleStatusStream)
+	mux.HandleFunc("/api/status/history", s.handleStatusHistory)
 	mux.HandleFunc("/api/detections/stream", s.handleDetectionsStream)
 	mux.HandleFunc("/api/connections", s.handleConnections)
 	mux.HandleFunc("/api/connections/stream", s.handleConnectionsStream)
+	mux.HandleFunc("/api/streams", s.handleStreams)
 	mux.HandleFunc("/api/camera_status", s.handleCameraStatus)
 	mux.HandleFunc("/api/debug/switch-camera", s.handleCameraSwitch)
 	mux.HandleFunc("/api/recording/start", s.handleRecordingStart)
 	mux.HandleFunc("/api/recording/stop", s.handleRecordingStop)
 	mux.HandleFunc("/api/recording/status", s.handleRecordingStatus)
 	mux.HandleFunc("/api/recording/heartbeat", s.handleRecordingHeartbeat)
+	mux.HandleFunc("/api/recording/paths", s.handleRecordingPaths)
+	mux.HandleFunc("/api/record", s.handleRecordFor)
+	mux.HandleFunc("/api/triggers", s.handleTriggers)
+	mux.HandleFunc("/api/triggers/fire", s.handleTriggerFire)
 	mux.HandleFunc("/api/recordings", s.handleRecordingsList)
+	mux.HandleFunc("/api/recordings/stats", s.handleRecordingStats)
 	mux.HandleFunc("/api/recordings/", s.handleRecordingDownload)
 	mux.HandleFunc("/api/webrtc/offer", s.handleWebRTCOffer)
+	mux.HandleFunc("/api/webrtc/config", s.handleWebRTCConfig)
 	mux.HandleFunc("/api/comics", s.handleComicsList)
 	mux.HandleFunc("/api/comics/", s.handleComicServe)
 	mux.HandleFunc("/api/comic-capture", s.handleComicCaptureNow)
 	mux.HandleFunc("/api/detections/history", s.handleDetectionHistory)
+	mux.HandleFunc("/api/analytics/calendar", s.handleAnalyticsCalendar)
+	mux.HandleFunc("/api/analytics/rollup", s.handleAnalyticsRollup)
 	mux.HandleFunc("/api/base_diff", s.handleBaseDiff)
 	mux.HandleFunc("/api/base_diff/stream", s.handleBaseDiffStream)
 	mux.HandleFunc("/api/config", handleConfig)
+	mux.HandleFunc("/api/ui/config", s.handleUIConfig)
+	mux.HandleFunc("/api/backup", s.handleBackup)
+	mux.HandleFunc("/api/restore", s.handleRestore)
+	mux.HandleFunc("/api/events", s.handleEvents)
+	mux.HandleFunc("/api/snapshot", s.handleSnapshot)
+	mux.HandleFunc("/frame.jpg", s.handleFrameJPEG)
+	mux.HandleFunc("/api/snapshots/", s.handleSnapshotServe)
+	mux.HandleFunc("/api/share", s.handleShare)
+	mux.HandleFunc("/api/tokens", s.handleTokens)
+	mux.HandleFunc("/api/tokens/", s.handleToken)
+	mux.HandleFunc("/share/clips/", s.handleSharedClip)
+	mux.HandleFunc("/share/snapshots/", s.handleSharedSnapshot)
+	mux.HandleFunc("/api/peers", s.handlePeers)
+	mux.HandleFunc("/api/peers/", s.handlePeer)
+	mux.HandleFunc("/api/federation/events", s.handleFederatedEvents)
+	mux.HandleFunc("/api/push/vapid-public-key", s.handlePushKey)
+	mux.HandleFunc("/api/push/subscription", s.handlePushSubscribe)
+	mux.HandleFunc("/api/push/test", s.handlePushTest)
+	mux.HandleFunc("/api/outbox", s.handleOutbox)
+	mux.HandleFunc("/api/outbox/flush", s.handleOutboxFlush)
+	mux.HandleFunc("/api/outbox/dead", s.handleOutboxDead)
+	mux.Handle("/sw.js", assetHandler)
+	mux.HandleFunc("/api/pets", s.handlePets)
+	mux.HandleFunc("/api/pets/", s.handlePet)
+	mux.HandleFunc("/api/ptz", s.handlePTZ)
+	mux.HandleFunc("/api/ptz/", s.handlePTZ)
+	mux.HandleFunc("/api/rules", s.handleRules)
+	mux.HandleFunc("/api/detection/classes", s.handleDetectionClasses)
+	mux.HandleFunc("/api/masks", s.handleMasks)
+	mux.HandleFunc("/api/masks/", s.handleMask)
+	mux.HandleFunc("/api/zones", s.handleZones)
+	mux.HandleFunc("/api/zones/", s.handleZone)
+	mux.HandleFunc("/api/zones/state", s.handleZonesState)
+	mux.HandleFunc("/api/zones/stream", s.handleZonesStream)
+	mux.HandleFunc("/api/timeseries", s.handleTimeseries)
+	mux.HandleFunc("/api/rules/", s.handleRule)
+	mux.HandleFunc("/api/alerts", s.handleAlerts)
+	mux.HandleFunc("/api/alerts/stream", s.handleAlertsStream)
+	mux.HandleFunc("/api/alerts/", s.handleAlert)
+	mux.HandleFunc("/api/logs", s.handleLogs)
+	mux.HandleFunc("/api/logs/stream", s.handleLogsStream)
+	mux.HandleFunc("/api/diagnostics", s.handleDiagnostics)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+	mux.HandleFunc("/debug/pipeline", s.handleDebugPipeline)
+	mux.HandleFunc("/debug/logs", s.handleLogs)
+	mux.Handle("/metrics", s.metrics)
+	mux.Handle("/metrics/dashboard.json", s.dashboard)
 	mux.HandleFunc("/detect", s.handleDetectProxy)
+	if s.detectorProxy != nil {
+		mux.Handle("/detector", s.detectorProxy)
+		mux.Handle("/detector/", s.detectorProxy)
+	}
 
-	return mux
+	return s.access.Wrap(s.compress.Wrap(crash.Middleware(s.tokenMiddleware(s.shareMiddleware(mux)))))
 }
 
 func handleConfig(w http.ResponseWriter, r *http.Request) {
@@ -182,10 +810,42 @@ func handleConfig(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, `{"album_url":%q}`, albumBaseURL)
 }
 
+// maxHistoryPage caps ?limit= on /api/detections/history.
+const maxHistoryPage = 5000
+
+// handleDetectionHistory serves GET /api/detections/history?limit=&before=.
+// Records come newest page first (ascending within the page); pass
+// next_before as ?before= to fetch the previous page.
 func (s *Server) handleDetectionHistory(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	records := s.detectionHistory.Records()
-	json.NewEncoder(w).Encode(records)
+	q := r.URL.Query()
+	limit := maxHistoryPage
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeJSONWithStatus(w, map[string]any{"error": "limit must be a positive integer"}, http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxHistoryPage)
+	}
+	var before float64
+	if v := q.Get("before"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": "before must be Unix seconds"}, http.StatusBadRequest)
+			return
+		}
+		before = t
+	}
+	records, hasMore := s.detectionHistory.Page(before, limit)
+	resp := map[string]any{
+		"records":  records,
+		"gaps":     s.detectionHistory.Gaps(),
+		"has_more": hasMore,
+	}
+	if hasMore && len(records) > 0 {
+		resp["next_before"] = records[0].Timestamp
+	}
+	writeJSON(w, resp)
 }
 
 func (s *Server) handleBaseDiff(w http.ResponseWriter, r *http.Request) {
@@ -217,10 +877,34 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	profile, ok := ParseStreamProfile(r.URL.Query().Get("profile"))
+	if !ok {
+		writeJSONWithStatus(w, map[string]any{"error": "unknown profile (full, follow)"}, http.StatusBadRequest)
+		return
+	}
+
 	// Session-based dedup: cancel stale MJPEG stream from the same browser tab/device
 	sessionID := s.getSessionID(w, r)
 
-	ctx, cancel := context.WithCancel(r.Context())
+	acct := s.mjpegAccounting.Open(r, sessionID)
+	if acct == nil {
+		writeJSONWithStatus(w, map[string]any{"error": "too many MJPEG streams from this address"}, http.StatusTooManyRequests)
+		return
+	}
+
+	parent := r.Context()
+	if tok, ok := requestToken(r); ok {
+		tctx, release, ok := s.tokens.OpenMJPEG(parent, tok, sessionID, time.Now())
+		if !ok {
+			acct.Close()
+			writeJSONWithStatus(w, map[string]any{"error": "stream limit reached for this token"}, http.StatusTooManyRequests)
+			return
+		}
+		defer release()
+		parent = tctx
+	}
+
+	ctx, cancel := context.WithCancel(parent)
 	defer cancel()
 
 	s.mjpegStreamsMu.Lock()
@@ -233,8 +917,9 @@ func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
 	s.mjpegStreams[sessionID] = mjpegStreamEntry{id: myID, cancel: cancel}
 	s.mjpegStreamsMu.Unlock()
 
-	id, frameCh := s.broadcaster.Subscribe()
+	id, frameCh := s.broadcaster.SubscribeProfile(profile)
 	defer func() {
+		acct.Close()
 		s.broadcaster.Unsubscribe(id)
 		s.mjpegStreamsMu.Lock()
 		if entry, ok := s.mjpegStreams[sessionID]; ok && entry.id == myID {
@@ -243,7 +928,7 @@ func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
 		s.mjpegStreamsMu.Unlock()
 	}()
 
-	streamMJPEGFromChannel(w, r.WithContext(ctx), frameCh)
+	streamMJPEGFromChannel(w, r.WithContext(ctx), frameCh, acct)
 }
 
 // cancelMJPEGForSession cancels any active MJPEG stream for the given session.
@@ -292,8 +977,16 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 		"shared_memory":     shmStats,
 		"latest_detection":  latest,
 		"detection_history": history,
+		"detector_health":   s.detectionHealthStatus(),
+		"server_info":       buildinfo.Get(),
 		"timestamp":         float64(time.Now().Unix()),
 	}
+	if s.powerSaver != nil {
+		payload["power"] = s.powerSaver.Status()
+	}
+	if s.lighting != nil {
+		payload["lighting"] = s.lighting.Status()
+	}
 	writeJSON(w, payload)
 }
 
@@ -375,7 +1068,13 @@ func (s *Server) handleRecordingStart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	filename, err := s.recorder.Start()
+	owner := s.requestOwner(w, r)
+	eventID := newEventID()
+	filename, err := s.startRecording(owner, eventID)
+	if errors.Is(err, ErrAlreadyRecording) {
+		s.writeRecordingConflict(w)
+		return
+	}
 	if err != nil {
 		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusBadRequest)
 		return
@@ -384,7 +1083,11 @@ func (s *Server) handleRecordingStart(w http.ResponseWriter, r *http.Request) {
 	payload := map[string]any{
 		"status":     "recording",
 		"file":       filename,
+		"owner":      owner,
 		"started_at": float64(time.Now().Unix()),
+		"event_id":   eventID,
+		// Frames are dropped until the next IDR (at most one GOP)
+		"waiting_for_keyframe": s.recorder.WaitingForKeyframe(),
 	}
 	writeJSON(w, payload)
 }
@@ -396,7 +1099,7 @@ func (s *Server) handleRecordingStop(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("[Recorder] Stop API called")
-	filename, err := s.recorder.Stop()
+	filename, err := s.stopRecording(s.requestOwner(w, r))
 	if err != nil {
 		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusBadRequest)
 		return
@@ -437,12 +1140,16 @@ func (s *Server) handleRecordingsList(w http.ResponseWriter, r *http.Request) {
 		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
 		return
 	}
+	if s.scrubber != nil {
+		s.scrubber.Annotate(recordings)
+	}
 
 	writeJSON(w, map[string]any{"recordings": recordings})
 }
 
 func (s *Server) handleRecordingDownload(w http.ResponseWriter, r *http.Request) {
-	// Extract path parts: /api/recordings/{filename} or /api/recordings/{filename}/thumbnail
+	// Extract path parts: /api/recordings/{filename}, /api/recordings/{filename}/thumbnail,
+	// /api/recordings/{filename}/detections.vtt or /api/recordings/{filename}/filmstrip.{json,jpg}
 	path := r.URL.Path
 	prefix := "/api/recordings/"
 	if !strings.HasPrefix(path, prefix) {
@@ -461,6 +1168,14 @@ func (s *Server) handleRecordingDownload(w http.ResponseWriter, r *http.Request)
 		s.handleThumbnailRegenerate(w, r, pathParts[0])
 		return
 	}
+	if len(pathParts) == 2 && pathParts[1] == "detections.vtt" {
+		s.handleRecordingVTT(w, r, pathParts[0])
+		return
+	}
+	if len(pathParts) == 2 && (pathParts[1] == "filmstrip.json" || pathParts[1] == "filmstrip.jpg") {
+		s.handleFilmstrip(w, r, pathParts[0], pathParts[1])
+		return
+	}
 
 	filename := pathParts[0]
 
@@ -474,17 +1189,41 @@ func (s *Server) handleRecordingDownload(w http.ResponseWriter, r *http.Request)
 			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusNotFound)
 			return
 		}
+		if s.filmstrips != nil {
+			s.filmstrips.Remove(filename)
+		}
 		writeJSON(w, map[string]any{"deleted": true, "filename": filename})
 		return
 	}
 
-	// GET - download file
-	filePath, err := s.recorder.GetRecordingPath(filename)
-	if err != nil {
-		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusNotFound)
+	// GET ?quality=proxy - the low-res copy recorded alongside
+	switch r.URL.Query().Get("quality") {
+	case "", "full":
+	case "proxy":
+		if !isProxyName(filename) {
+			filename = proxyName(filename)
+		}
+	default:
+		writeJSONWithStatus(w, map[string]any{"error": "quality must be full or proxy"}, http.StatusBadRequest)
 		return
 	}
 
+	// GET - the recording in progress is followed until it stops
+	if s.serveActiveRecording(w, r, filename) {
+		return
+	}
+
+	// GET - download file
+	var filePath string
+	if !s.recorder.isRemote() {
+		var err error
+		filePath, err = s.recorder.GetRecordingPath(filename)
+		if err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusNotFound)
+			return
+		}
+	}
+
 	// Set download headers based on file type
 	if strings.HasSuffix(filename, ".mp4") {
 		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
@@ -497,9 +1236,31 @@ func (s *Server) handleRecordingDownload(w http.ResponseWriter, r *http.Request)
 		// No Content-Disposition = display in browser
 	}
 
+	if filePath == "" {
+		s.serveStoredRecording(w, filename)
+		return
+	}
 	http.ServeFile(w, r, filePath)
 }
 
+// serveStoredRecording streams a recording from non-local storage.
+func (s *Server) serveStoredRecording(w http.ResponseWriter, filename string) {
+	rc, info, err := s.recorder.OpenRecording(filename)
+	if err != nil {
+		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusNotFound)
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	if !info.ModTime.IsZero() {
+		w.Header().Set("Last-Modified", info.ModTime.UTC().Format(http.TimeFormat))
+	}
+	if _, err := io.Copy(w, rc); err != nil {
+		logger.Debug("Server", "Recording stream aborted: %v", err)
+	}
+}
+
 func (s *Server) handleThumbnailRegenerate(w http.ResponseWriter, r *http.Request, filename string) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -536,6 +1297,16 @@ func (s *Server) handleWebRTCOffer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	tok, limited := requestToken(r)
+	var session string
+	if c, err := r.Cookie("stream_sid"); err == nil {
+		session = c.Value
+	}
+	if limited && !s.tokens.AllowWebRTC(tok, session, time.Now()) {
+		writeJSONWithStatus(w, map[string]any{"error": "stream limit reached for this token"}, http.StatusTooManyRequests)
+		return
+	}
+
 	// Cancel any active MJPEG stream for this session (1 stream per session)
 	s.cancelMJPEGForSession(r)
 
@@ -583,6 +1354,18 @@ func (s *Server) handleWebRTCOffer(w http.ResponseWriter, r *http.Request) {
 	} else {
 		w.Header().Set("Content-Type", "application/json")
 	}
+	// Draining streaming server: tell the browser when to retry
+	if ra := resp.Header.Get("Retry-After"); ra != "" {
+		w.Header().Set("Retry-After", ra)
+	}
+	if limited && resp.StatusCode == http.StatusOK {
+		var answer struct {
+			SessionID string `json:"session_id"`
+		}
+		if json.Unmarshal(respBody, &answer) == nil && answer.SessionID != "" {
+			s.tokens.AddWebRTC(tok, answer.SessionID, time.Now())
+		}
+	}
 	w.WriteHeader(resp.StatusCode)
 	_, _ = w.Write(respBody)
 }
@@ -641,19 +1424,93 @@ func (s *Server) handleConnectionsStream(w http.ResponseWriter, r *http.Request)
 
 // Shutdown stops background goroutines and persists state.
 func (s *Server) Shutdown() {
+	if s.relay != nil {
+		s.relay.Stop()
+	}
+	if s.mqtt != nil {
+		s.mqtt.Stop()
+	}
+	if s.lighting != nil {
+		s.lighting.Stop()
+	}
+	if s.powerSaver != nil {
+		s.powerSaver.Stop()
+	}
+	if s.ptz != nil {
+		s.ptz.Close()
+	}
+	if s.demand != nil {
+		s.demand.Stop()
+	}
+	if s.degrade != nil {
+		s.degrade.Stop()
+	}
 	if s.heatmapBroadcaster != nil {
 		s.heatmapBroadcaster.Stop()
 	}
 	if s.comicCapture != nil {
 		s.comicCapture.Stop()
 	}
-	if s.cfg.DetectionHistoryPath != "" {
-		if err := s.detectionHistory.Save(s.cfg.DetectionHistoryPath); err != nil {
-			logger.Warn("Server", "Failed to save detection history: %v", err)
-		} else {
-			logger.Info("Server", "Saved detection history")
-		}
+	if s.motionDetector != nil {
+		s.motionDetector.Stop()
+	}
+	if s.forwarder != nil {
+		s.forwarder.Stop()
+	}
+	if s.detectionHealth != nil {
+		s.detectionHealth.Stop()
+	}
+	if s.rules != nil {
+		s.rules.Stop()
+	}
+	if s.federation != nil {
+		s.federation.Stop()
+	}
+	if s.activity != nil {
+		s.activity.Stop()
+	}
+	if s.occupancy != nil {
+		s.occupancy.Stop()
+	}
+	if s.stateSaver != nil {
+		s.stateSaver.Stop()
+	}
+	if s.metricsSampler != nil {
+		s.metricsSampler.Stop()
+	}
+	if s.statusHistory != nil {
+		s.statusHistory.Stop()
+		s.statusHistoryStore.Close()
+	}
+	if s.recordingPaths != nil {
+		s.recordingPaths.Stop()
+	}
+	if s.diskMonitor != nil {
+		s.diskMonitor.Stop()
+	}
+	if s.scrubber != nil {
+		s.scrubber.Stop()
 	}
+	if s.clockJumps != nil {
+		s.clockJumps.Stop()
+	}
+	for _, cancel := range s.busCancels {
+		cancel()
+	}
+	if s.audit != nil {
+		s.audit.Close()
+	}
+	if s.outbox != nil {
+		s.outbox.Stop() // unsent jobs stay on disk for the next start
+	}
+	if s.settings != nil {
+		s.settings.Close()
+	}
+	if _, fromSHM := s.detections.(*shmReader); s.detections != nil && !fromSHM {
+		s.detections.Close() // the shm reader is shared with the frame broadcaster
+	}
+	s.persistAll()
+	logger.Info("Server", "Saved state")
 }
 
 func (s *Server) handleComicsList(w http.ResponseWriter, r *http.Request) {
@@ -798,3 +1655,25 @@ func writeJSONWithStatus(w http.ResponseWriter, payload any, status int) {
 		_, _ = fmt.Fprintf(w, `{"error":"%s"}`, err.Error())
 	}
 }
+
+// handleClockJump records a wall-clock step (typically the first NTP sync
+// after booting with a stale RTC) and corrects the active recording's name.
+func (s *Server) handleClockJump(j clock.Jump) {
+	logger.Warn("Server", "Wall clock jumped by %v (NTP synced: %v)", j.Delta, j.NTPSynced)
+	s.events.Append(Event{
+		Type: "clock_jump",
+		Data: map[string]string{
+			"delta_sec":  strconv.FormatFloat(j.Delta.Seconds(), 'f', 1, 64),
+			"ntp_synced": strconv.FormatBool(j.NTPSynced),
+		},
+	})
+	if s.recorder != nil {
+		s.recorder.ClockJumped(j)
+	}
+}
+
+// FeedAudio passes one chunk of mono S16 PCM from the audio capture to the
+// sound event detector.
+func (s *Server) FeedAudio(samples []int16) {
+	s.sound.Feed(samples, time.Now())
+}
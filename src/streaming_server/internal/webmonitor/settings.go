@@ -0,0 +1,216 @@
+package webmonitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+// settingsDoc is where a configuration-bearing subsystem keeps its state in
+// the settings store, and the JSON file it kept it in before.
+type settingsDoc struct {
+	bucket, key string
+	legacy      func(Config) string
+}
+
+func (d settingsDoc) in(store kv.Store) kv.Doc {
+	return kv.Doc{Store: store, Bucket: d.bucket, Key: d.key}
+}
+
+var (
+	classesDoc    = settingsDoc{"detection_classes", "filter", func(c Config) string { return c.DetectionClassesPath }}
+	masksDoc      = settingsDoc{"masks", "masks", func(c Config) string { return c.MasksPath }}
+	uiDoc         = settingsDoc{"ui", "config", func(c Config) string { return c.UIConfigPath }}
+	rulesDoc      = settingsDoc{"rules", "rules", func(c Config) string { return c.RulesPath }}
+	petsDoc       = settingsDoc{"pets", "profiles", func(c Config) string { return c.PetProfilesPath }}
+	pushDoc       = settingsDoc{"push", "subscriptions", func(c Config) string { return c.PushSubscriptionsPath }}
+	tokensDoc     = settingsDoc{"tokens", "tokens", func(c Config) string { return c.TokensPath }}
+	peersDoc      = settingsDoc{"peers", "peers", func(c Config) string { return c.PeersPath }}
+	ptzPresetsDoc = settingsDoc{"ptz", "presets", func(c Config) string { return c.PTZPresetsPath }}
+	zonesDoc      = settingsDoc{"zones", "zones", func(Config) string { return "" }} // no JSON file before the store
+
+	settingsDocs = []settingsDoc{classesDoc, masksDoc, uiDoc, rulesDoc, petsDoc, pushDoc, tokensDoc, peersDoc, ptzPresetsDoc, zonesDoc}
+)
+
+// settingsMigrations are the steps of the settings schema, in order. Only
+// ever append: a store records how many of them it has seen.
+func settingsMigrations(cfg Config) []kv.Migration {
+	return []kv.Migration{
+		{Name: "import JSON files", Up: func(s kv.Store) error { return importLegacySettings(s, cfg) }},
+	}
+}
+
+// importLegacySettings copies the JSON file each subsystem kept before the
+// settings store into its document, and renames the file to *.migrated so
+// an edit to it is not mistaken for a setting.
+func importLegacySettings(s kv.Store, cfg Config) error {
+	for _, d := range settingsDocs {
+		path := d.legacy(cfg)
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.Put(d.bucket, d.key, data); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if err := os.Rename(path, path+".migrated"); err != nil {
+			return err
+		}
+		logger.Info("Settings", "Imported %s into %s/%s", path, d.bucket, d.key)
+	}
+	return nil
+}
+
+// openSettings opens the settings store at cfg.SettingsPath and brings its
+// schema up to date. A store that cannot be opened is replaced by one in
+// memory, so the monitor still starts, with every setting at its default.
+func openSettings(cfg Config) kv.Store {
+	store, err := kv.Open(cfg.SettingsPath)
+	if err != nil {
+		logger.Warn("Settings", "Settings store unavailable, changes will not persist: %v", err)
+		store = kv.NewMemory()
+	}
+	applied, err := kv.Migrate(store, settingsMigrations(cfg))
+	if err != nil {
+		logger.Warn("Settings", "Migrating %s: %v", store, err)
+	}
+	for _, name := range applied {
+		logger.Info("Settings", "Applied migration %q to %s", name, store)
+	}
+	return store
+}
+
+// settingsBackupFormat marks a file as a settings backup.
+const settingsBackupFormat = "petcam-settings"
+
+// maxBackupBytes bounds a /api/restore body.
+const maxBackupBytes = 16 << 20
+
+// SettingsBackup is the GET /api/backup response and the POST /api/restore
+// body: every document of the settings store.
+type SettingsBackup struct {
+	Format  string      `json:"format"`
+	Schema  int         `json:"schema"`
+	Created time.Time   `json:"created"`
+	Buckets kv.Snapshot `json:"buckets"`
+}
+
+// handleBackup serves GET /api/backup (admin only) as a download.
+func (s *Server) handleBackup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.requireAdmin(w, r) {
+		return
+	}
+	schema, err := kv.SchemaVersion(s.settings)
+	if err != nil {
+		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	snap, err := s.settings.Snapshot()
+	if err != nil {
+		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="petcam-settings-%s.json"`, now.Format("20060102-150405")))
+	writeJSON(w, SettingsBackup{Format: settingsBackupFormat, Schema: schema, Created: now.UTC(), Buckets: snap})
+}
+
+// handleRestore serves POST /api/restore (admin only): the backup replaces
+// every document, older schemas are migrated, and the subsystems reload.
+func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.requireAdmin(w, r) {
+		return
+	}
+	var backup SettingsBackup
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBackupBytes)).Decode(&backup); err != nil {
+		writeJSONWithStatus(w, map[string]any{"error": "invalid backup: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	migrations := settingsMigrations(s.cfg)
+	switch {
+	case backup.Format != settingsBackupFormat:
+		writeJSONWithStatus(w, map[string]any{"error": fmt.Sprintf("not a settings backup (format %q)", backup.Format)}, http.StatusBadRequest)
+		return
+	case backup.Schema < 0 || backup.Schema > len(migrations):
+		writeJSONWithStatus(w, map[string]any{"error": fmt.Sprintf("backup schema %d is not supported by this version (up to %d)", backup.Schema, len(migrations))}, http.StatusBadRequest)
+		return
+	}
+	if err := backup.Buckets.Validate(); err != nil {
+		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	if err := s.settings.Restore(backup.Buckets); err != nil {
+		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	// A backup from before a migration gets it now. The first one imports
+	// JSON files, which were renamed when it first ran.
+	if err := kv.SetSchemaVersion(s.settings, backup.Schema); err != nil {
+		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	applied, err := kv.Migrate(s.settings, migrations)
+	if err != nil {
+		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	reloadErrors := s.reloadSettings()
+	logger.Info("Settings", "Restored %d bucket(s) from a backup of %s (schema %d)", len(backup.Buckets), backup.Created.Format(time.RFC3339), backup.Schema)
+	writeJSON(w, map[string]any{
+		"restored":      len(backup.Buckets),
+		"migrated":      applied,
+		"reload_errors": reloadErrors,
+	})
+}
+
+// reloadSettings makes every subsystem read its document again, returning
+// the errors by subsystem.
+func (s *Server) reloadSettings() map[string]string {
+	loaders := map[string]func() error{
+		classesDoc.bucket: s.classFilter.Load,
+		masksDoc.bucket:   s.masks.Load,
+		uiDoc.bucket:      s.uiConfig.Load,
+		rulesDoc.bucket:   s.rules.Load,
+		tokensDoc.bucket:  s.tokens.Load,
+		peersDoc.bucket:   s.federation.Load,
+		zonesDoc.bucket:   s.zones.Load,
+	}
+	if s.petID != nil {
+		loaders[petsDoc.bucket] = s.petID.Load
+	}
+	if s.push != nil {
+		loaders[pushDoc.bucket] = s.push.Load
+	}
+	if s.ptz != nil {
+		loaders[ptzPresetsDoc.bucket] = s.ptz.LoadPresets
+	}
+	errs := make(map[string]string)
+	for name, load := range loaders {
+		if err := load(); err != nil {
+			logger.Warn("Settings", "Reloading %s: %v", name, err)
+			errs[name] = err.Error()
+		}
+	}
+	return errs
+}
@@ -0,0 +1,123 @@
+package webmonitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+)
+
+func TestLegacySettingsImport(t *testing.T) {
+	dir := t.TempDir()
+	cfg := DefaultConfig()
+	cfg.SettingsPath = filepath.Join(dir, "settings.db")
+	cfg.RulesPath = filepath.Join(dir, "rules.json")
+	cfg.MasksPath = filepath.Join(dir, "masks.json")
+	cfg.TokensPath = "" // not configured
+	legacy, _ := json.MarshalIndent([]Rule{{
+		ID:         "r1",
+		Name:       "cat",
+		Enabled:    true,
+		Conditions: []RuleCondition{{Class: "cat"}},
+		Actions:    []RuleAction{{Type: RuleActionRecord, Duration: RuleDuration(time.Minute)}},
+	}}, "", "  ")
+	os.WriteFile(cfg.RulesPath, legacy, 0o644)
+	os.WriteFile(cfg.MasksPath, []byte(`{"masks": [], "overlay": true}`), 0o644)
+
+	store := openSettings(cfg)
+	rules := NewRulesEngine(rulesDoc.in(store))
+	if err := rules.Load(); err != nil || len(rules.List()) != 1 {
+		t.Fatalf("imported rules: %v %v", rules.List(), err)
+	}
+	if _, err := os.Stat(cfg.RulesPath + ".migrated"); err != nil {
+		t.Errorf("rules file not renamed: %v", err)
+	}
+	if v, _ := kv.SchemaVersion(store); v != len(settingsMigrations(cfg)) {
+		t.Errorf("schema %d", v)
+	}
+	store.Close()
+
+	// Imported once: a file put back later is ignored
+	os.WriteFile(cfg.MasksPath, []byte(`{"masks": [], "overlay": false}`), 0o644)
+	store = openSettings(cfg)
+	defer store.Close()
+	masks := NewMaskSet(masksDoc.in(store))
+	masks.Load()
+	if !masks.overlay {
+		t.Error("masks imported again")
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	store := kv.NewMemory()
+	s := &Server{
+		cfg:         Config{AdminToken: "secret"},
+		settings:    store,
+		classFilter: NewClassFilter(classesDoc.in(store)),
+		masks:       NewMaskSet(masksDoc.in(store)),
+		uiConfig:    NewUIConfigStore(uiDoc.in(store)),
+		rules:       NewRulesEngine(rulesDoc.in(store)),
+		tokens:      NewTokenStore(tokensDoc.in(store)),
+		federation:  NewFederation(peersDoc.in(store)),
+		zones:       NewZoneSet(zonesDoc.in(store)),
+	}
+	kv.Migrate(store, settingsMigrations(s.cfg))
+	do := func(h http.HandlerFunc, method, token, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		h(rec, req)
+		return rec
+	}
+
+	if _, err := s.uiConfig.Set(UIConfig{Theme: ThemeLight}); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if _, _, err := s.tokens.Create(AccessToken{Name: "grandma"}, now); err != nil {
+		t.Fatal(err)
+	}
+	if rec := do(s.handleBackup, "GET", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("backup without admin token: %d", rec.Code)
+	}
+	rec := do(s.handleBackup, "GET", "secret", "")
+	var backup SettingsBackup
+	if err := json.Unmarshal(rec.Body.Bytes(), &backup); err != nil || rec.Code != 200 {
+		t.Fatalf("backup: %d %s", rec.Code, rec.Body)
+	}
+	if backup.Format != settingsBackupFormat || backup.Schema != 1 || len(backup.Buckets) != 2 ||
+		!strings.Contains(rec.Header().Get("Content-Disposition"), "petcam-settings-") {
+		t.Fatalf("backup %+v", backup)
+	}
+
+	// Change everything, then restore
+	s.uiConfig.Reset()
+	s.tokens.Delete(s.tokens.List(now)[0].ID)
+	s.masks.SetOverlay(true)
+
+	for _, bad := range []string{
+		`{"format": "other", "schema": 1, "buckets": {}}`,
+		`{"format": "petcam-settings", "schema": 99, "buckets": {}}`,
+		`{"format": "petcam-settings", "schema": 1, "buckets": {"_meta": {"schema": 5}}}`,
+		`not json`,
+	} {
+		if rec := do(s.handleRestore, "POST", "secret", bad); rec.Code != http.StatusBadRequest {
+			t.Errorf("restore %s: %d", bad, rec.Code)
+		}
+	}
+	data, _ := json.Marshal(backup)
+	if rec := do(s.handleRestore, "POST", "secret", string(data)); rec.Code != 200 || !strings.Contains(rec.Body.String(), `"restored":2`) {
+		t.Fatalf("restore: %d %s", rec.Code, rec.Body)
+	}
+	if theme := s.uiConfig.View("", "en").Theme; theme != ThemeLight || len(s.tokens.List(now)) != 1 || s.masks.Status().Overlay {
+		t.Errorf("not restored: theme %s, %d tokens, overlay %v", theme, len(s.tokens.List(now)), s.masks.Status().Overlay)
+	}
+}
@@ -0,0 +1,321 @@
+package webmonitor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+// sharePrefix is where signed links point; requests under it need a valid
+// signature instead of any other credential.
+const sharePrefix = "/share/"
+
+const (
+	shareKeyBytes   = 32
+	shareDefaultTTL = 24 * time.Hour
+	shareMaxClip    = 10 * time.Minute
+	shareClipDir    = ".clips" // trimmed clips, inside the recordings directory
+)
+
+var (
+	errShareExpired   = errors.New("link expired")
+	errShareSignature = errors.New("invalid signature")
+)
+
+// ShareSigner signs expiring links to clips and snapshots: exp is the
+// expiry in Unix seconds and sig is base64url(HMAC-SHA256(key, path + "?" +
+// the other query parameters, sorted)), so neither the time range nor the
+// expiry can be changed without invalidating the link.
+type ShareSigner struct {
+	key []byte
+}
+
+// LoadShareSigner reads the key from path, first creating the file with a
+// random key (mode 0600) if it does not exist. Replacing the file and
+// restarting revokes every link issued so far.
+func LoadShareSigner(path string) (*ShareSigner, error) {
+	if err := createSecretFile(path, shareKeyBytes); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return nil, fmt.Errorf("%s: empty share key", path)
+	}
+	return &ShareSigner{key: []byte(key)}, nil
+}
+
+func (s *ShareSigner) mac(path string, q url.Values) string {
+	m := hmac.New(sha256.New, s.key)
+	m.Write([]byte(path + "?" + q.Encode()))
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+// Sign returns path with q, exp and sig as a link valid until expires.
+func (s *ShareSigner) Sign(path string, q url.Values, expires time.Time) string {
+	signed := url.Values{}
+	for k, v := range q {
+		signed[k] = v
+	}
+	signed.Set("exp", strconv.FormatInt(expires.Unix(), 10))
+	signed.Set("sig", s.mac(path, signed))
+	return path + "?" + signed.Encode()
+}
+
+// Verify checks the signature and expiry of a signed link.
+func (s *ShareSigner) Verify(u *url.URL, now time.Time) error {
+	q := u.Query()
+	sig := q.Get("sig")
+	q.Del("sig")
+	if sig == "" || !hmac.Equal([]byte(sig), []byte(s.mac(u.Path, q))) {
+		return errShareSignature
+	}
+	exp, err := strconv.ParseInt(q.Get("exp"), 10, 64)
+	if err != nil {
+		return errShareSignature
+	}
+	if now.Unix() >= exp {
+		return errShareExpired
+	}
+	return nil
+}
+
+// shareMiddleware lets requests under /share/ through only with a valid,
+// unexpired signature; other paths pass unchanged.
+func (s *Server) shareMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, sharePrefix) {
+			if s.shares == nil {
+				writeJSONWithStatus(w, map[string]any{"error": "sharing disabled"}, http.StatusServiceUnavailable)
+				return
+			}
+			if err := s.shares.Verify(r.URL, time.Now()); err != nil {
+				writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireAdmin reports whether r carries the admin token as a Bearer token,
+// answering the request itself when not.
+func (s *Server) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if s.cfg.AdminToken == "" {
+		writeJSONWithStatus(w, map[string]any{"error": "admin endpoints disabled: PET_CAMERA_ADMIN_TOKEN is not set"}, http.StatusServiceUnavailable)
+		return false
+	}
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(s.cfg.AdminToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		writeJSONWithStatus(w, map[string]any{"error": "unauthorized"}, http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// ShareRequest is the body of POST /api/share: a recording (optionally
+// trimmed to start..end seconds) or a saved snapshot.
+type ShareRequest struct {
+	Recording string   `json:"recording,omitempty"`
+	Start     *float64 `json:"start,omitempty"`
+	End       *float64 `json:"end,omitempty"`
+	Snapshot  string   `json:"snapshot,omitempty"`
+	TTL       string   `json:"ttl,omitempty"` // Go duration, default 24h
+}
+
+// shareLink validates req and returns the unsigned path and query.
+func (s *Server) shareLink(req ShareRequest) (string, url.Values, error) {
+	q := url.Values{}
+	switch {
+	case req.Recording != "" && req.Snapshot != "":
+		return "", nil, fmt.Errorf("set either recording or snapshot")
+	case req.Snapshot != "":
+		if req.Start != nil || req.End != nil {
+			return "", nil, fmt.Errorf("start/end only apply to recordings")
+		}
+		if filepath.Base(req.Snapshot) != req.Snapshot || !strings.HasSuffix(req.Snapshot, ".jpg") {
+			return "", nil, fmt.Errorf("invalid snapshot name")
+		}
+		if _, err := os.Stat(filepath.Join(s.snapshots.dir, req.Snapshot)); err != nil {
+			return "", nil, fmt.Errorf("snapshot not found: %s", req.Snapshot)
+		}
+		return sharePrefix + "snapshots/" + req.Snapshot, q, nil
+	case req.Recording != "":
+		if !strings.HasSuffix(req.Recording, ".mp4") {
+			return "", nil, fmt.Errorf("only mp4 recordings can be shared")
+		}
+		if _, err := s.recorder.GetRecordingPath(req.Recording); err != nil {
+			return "", nil, err
+		}
+		if (req.Start == nil) != (req.End == nil) {
+			return "", nil, fmt.Errorf("set both start and end, or neither")
+		}
+		if req.Start != nil {
+			start, end := *req.Start, *req.End
+			if start < 0 || end <= start {
+				return "", nil, fmt.Errorf("need 0 <= start < end")
+			}
+			if end-start > shareMaxClip.Seconds() {
+				return "", nil, fmt.Errorf("clip longer than %v", shareMaxClip)
+			}
+			q.Set("start", strconv.FormatFloat(start, 'f', 3, 64))
+			q.Set("end", strconv.FormatFloat(end, 'f', 3, 64))
+		}
+		return sharePrefix + "clips/" + req.Recording, q, nil
+	}
+	return "", nil, fmt.Errorf("set recording or snapshot")
+}
+
+// handleShare serves POST /api/share (admin only): a signed link to a clip
+// or snapshot, valid for the requested ttl.
+func (s *Server) handleShare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.requireAdmin(w, r) {
+		return
+	}
+	if s.shares == nil {
+		writeJSONWithStatus(w, map[string]any{"error": "sharing disabled"}, http.StatusServiceUnavailable)
+		return
+	}
+	var req ShareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONWithStatus(w, map[string]any{"error": "invalid JSON: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	ttl := min(shareDefaultTTL, s.cfg.ShareMaxTTL)
+	if req.TTL != "" {
+		d, err := time.ParseDuration(req.TTL)
+		if err != nil || d <= 0 || d > s.cfg.ShareMaxTTL {
+			writeJSONWithStatus(w, map[string]any{"error": fmt.Sprintf("ttl must be a duration up to %v", s.cfg.ShareMaxTTL)}, http.StatusBadRequest)
+			return
+		}
+		ttl = d
+	}
+	path, q, err := s.shareLink(req)
+	if err != nil {
+		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusBadRequest)
+		return
+	}
+	expires := time.Now().Add(ttl).Truncate(time.Second)
+	link := s.shares.Sign(path, q, expires)
+	logger.Info("Share", "Issued link to %s (expires %s)", path, expires.Format(time.RFC3339))
+	writeJSONWithStatus(w, map[string]any{"url": link, "expires_at": expires.Unix()}, http.StatusCreated)
+}
+
+// handleSharedSnapshot serves GET /share/snapshots/{filename}.
+func (s *Server) handleSharedSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.serveSnapshotFile(w, r, strings.TrimPrefix(r.URL.Path, sharePrefix+"snapshots/"))
+}
+
+// handleSharedClip serves GET /share/clips/{filename}, trimmed to
+// start..end when the link has them.
+func (s *Server) handleSharedClip(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	filename := strings.TrimPrefix(r.URL.Path, sharePrefix+"clips/")
+	q := r.URL.Query()
+	var (
+		path string
+		err  error
+	)
+	if q.Has("start") {
+		start, _ := strconv.ParseFloat(q.Get("start"), 64)
+		end, _ := strconv.ParseFloat(q.Get("end"), 64)
+		path, err = s.recorder.ExportClip(filename, start, end)
+	} else {
+		path, err = s.recorder.GetRecordingPath(filename)
+	}
+	if err != nil {
+		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "video/mp4")
+	w.Header().Set("Cache-Control", "private, no-store")
+	http.ServeFile(w, r, path)
+}
+
+// clipName is the cache name of filename trimmed to start..end.
+func clipName(filename string, start, end float64) string {
+	return fmt.Sprintf("%s_%.3f-%.3f.mp4", recordingStem(filename), start, end)
+}
+
+// ExportClip returns the path of the MP4 recording filename trimmed to
+// start..end seconds, cutting it on first use. The stream is copied, not
+// re-encoded: the cut starts at the IDR before start and the MP4 edit list
+// hides the frames before start, so players begin on the requested frame.
+func (r *Recorder) ExportClip(filename string, start, end float64) (string, error) {
+	src, err := r.GetRecordingPath(filename)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(filename, ".mp4") {
+		return "", fmt.Errorf("only mp4 files supported")
+	}
+	if start < 0 || end <= start {
+		return "", fmt.Errorf("invalid clip range")
+	}
+	r.clipMu.Lock()
+	defer r.clipMu.Unlock()
+	dir := filepath.Join(r.dir(), shareClipDir)
+	dst := filepath.Join(dir, clipName(filename, start, end))
+	if _, err := os.Stat(dst); err == nil {
+		return dst, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	tmp := dst + ".tmp"
+	cmd := exec.Command("nice", "-n", "19",
+		"ffmpeg", "-y",
+		"-ss", strconv.FormatFloat(start, 'f', 3, 64),
+		"-i", src,
+		"-t", strconv.FormatFloat(end-start, 'f', 3, 64),
+		"-c", "copy",
+		"-movflags", "+faststart",
+		"-f", "mp4", tmp,
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("clip export failed: %v\n%s", err, string(output))
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		return "", err
+	}
+	logger.Info("Recorder", "Exported clip %s", filepath.Base(dst))
+	return dst, nil
+}
+
+// removeClips deletes the exported clips of recording filename.
+func (r *Recorder) removeClips(filename string) {
+	matches, _ := filepath.Glob(filepath.Join(r.dir(), shareClipDir, recordingStem(filename)+"_*.mp4"))
+	for _, m := range matches {
+		os.Remove(m)
+	}
+}
@@ -0,0 +1,97 @@
+package webmonitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShareSigner(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "share.key")
+	s, err := LoadShareSigner(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	link := s.Sign("/share/clips/a.mp4", url.Values{"start": {"12.000"}, "end": {"32.000"}}, now.Add(time.Hour))
+	u, _ := url.Parse(link)
+	if err := s.Verify(u, now); err != nil {
+		t.Fatalf("%s: %v", link, err)
+	}
+	if err := s.Verify(u, now.Add(2*time.Hour)); err != errShareExpired {
+		t.Errorf("expired link: %v", err)
+	}
+
+	// The key survives restarts; any change to the link breaks it
+	reloaded, err := LoadShareSigner(path)
+	if err != nil || reloaded.Verify(u, now) != nil {
+		t.Fatalf("reloaded key rejects link: %v", err)
+	}
+	for _, tamper := range []string{
+		strings.Replace(link, "end=32.000", "end=99.000", 1),
+		strings.Replace(link, "a.mp4", "b.mp4", 1),
+		strings.Replace(link, "exp=", "exp=9", 1),
+		link[:strings.Index(link, "&sig=")],
+	} {
+		u, _ := url.Parse(tamper)
+		if err := s.Verify(u, now); err != errShareSignature {
+			t.Errorf("%s: %v", tamper, err)
+		}
+	}
+}
+
+func TestHandleShare(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "snapshot_1.jpg"), []byte("jpeg"), 0o644)
+	signer, _ := LoadShareSigner(filepath.Join(dir, "share.key"))
+	s := &Server{
+		cfg:       Config{AdminToken: "secret", ShareMaxTTL: time.Hour},
+		shares:    signer,
+		snapshots: &Snapshotter{dir: dir},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/share", s.handleShare)
+	mux.HandleFunc("/share/snapshots/", s.handleSharedSnapshot)
+	h := s.shareMiddleware(mux)
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodPost, "/api/share", "wrong", `{"snapshot":"snapshot_1.jpg"}`); rec.Code != http.StatusUnauthorized {
+		t.Errorf("non-admin: %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/share", "secret", `{"snapshot":"snapshot_1.jpg","ttl":"2h"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("ttl above max: %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/share", "secret", `{"snapshot":"missing.jpg"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("missing snapshot: %d", rec.Code)
+	}
+	rec := do(http.MethodPost, "/api/share", "secret", `{"snapshot":"snapshot_1.jpg"}`)
+	var resp struct {
+		URL       string `json:"url"`
+		ExpiresAt int64  `json:"expires_at"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusCreated || resp.ExpiresAt <= time.Now().Unix() {
+		t.Fatalf("POST: %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := do(http.MethodGet, resp.URL, "", ""); rec.Code != http.StatusOK || rec.Body.String() != "jpeg" {
+		t.Errorf("signed GET: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodGet, "/share/snapshots/snapshot_1.jpg", "", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("unsigned GET: %d", rec.Code)
+	}
+}
@@ -152,7 +152,7 @@ static int read_zc_frame(ZeroCopyFrameBuffer* shm, ZeroCopyFrame* out) {
 // Import NV12 data from zero-copy frame via hb_mem (H.265 pattern: local copy, no consumed handshake)
 static int import_zc_nv12(ZeroCopyFrame* f, uint8_t* dst, int dst_size, int* out_w, int* out_h) {
     if (!f || !dst) return -1;
-    if (f->plane_cnt < 1) return -1;
+    if (f->plane_cnt < 1 || f->plane_cnt > ZEROCOPY_MAX_PLANES) return -1;
 
     int total_size = 0;
     for (int i = 0; i < f->plane_cnt; i++) total_size += f->plane_size[i];
@@ -171,9 +171,13 @@ static int import_zc_nv12(ZeroCopyFrame* f, uint8_t* dst, int dst_size, int* out
     hb_mem_graphic_buf_t out_gbuf = {0};
     if (hb_mem_import_graph_buf(&in_gbuf, &out_gbuf) != 0) return -3;
 
+    // plane_size came from SHM: never read past the imported mapping
+    int fits = out_gbuf.plane_cnt >= f->plane_cnt;
+    for (int i = 0; fits && i < f->plane_cnt; i++) fits = f->plane_size[i] <= out_gbuf.size[i];
+
     // Copy plane data
     int offset = 0;
-    for (int i = 0; i < f->plane_cnt && i < out_gbuf.plane_cnt; i++) {
+    for (int i = 0; fits && i < f->plane_cnt; i++) {
         hb_mem_invalidate_buf_with_vaddr((uint64_t)out_gbuf.virt_addr[i], out_gbuf.size[i]);
         memcpy(dst + offset, out_gbuf.virt_addr[i], f->plane_size[i]);
         offset += f->plane_size[i];
@@ -183,6 +187,7 @@ static int import_zc_nv12(ZeroCopyFrame* f, uint8_t* dst, int dst_size, int* out
     for (int i = 0; i < out_gbuf.plane_cnt; i++) {
         if (out_gbuf.fd[i] > 0) hb_mem_free_buf(out_gbuf.fd[i]);
     }
+    if (!fits) return -4;
 
     *out_w = f->width;
     *out_h = f->height;
@@ -309,10 +314,12 @@ static int nv12_to_jpeg_turbo(const uint8_t* nv12, int width, int height, uint8_
 import "C"
 
 import (
-	"bytes"
 	"fmt"
 	time "time"
 	"unsafe"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/shm"
 )
 
 const (
@@ -442,7 +449,12 @@ func (r *shmReader) LatestFrame() (*frameSnapshot, bool) {
 	if C.read_zc_frame(r.frameShm, &cFrame) != 0 {
 		return nil, false
 	}
-	if cFrame.version == 0 || cFrame.plane_cnt < 1 {
+	f, err := shm.DecodeNV12Frame(unsafe.Slice((*byte)(unsafe.Pointer(&cFrame)), C.sizeof_ZeroCopyFrame))
+	if err != nil {
+		logger.Debug("SHM", "NV12 frame: %v", err)
+		return nil, false
+	}
+	if f.Version == 0 || f.DataSize() > frameBufSize {
 		return nil, false
 	}
 
@@ -457,20 +469,13 @@ func (r *shmReader) LatestFrame() (*frameSnapshot, bool) {
 		return nil, false
 	}
 
-	data := buf[:dataSize]
-
-	timestamp := time.Unix(
-		int64(cFrame.timestamp.tv_sec),
-		int64(cFrame.timestamp.tv_nsec),
-	)
-
 	return &frameSnapshot{
-		FrameNumber: uint64(cFrame.frame_number),
-		Timestamp:   timestamp,
-		Width:       int(outW),
-		Height:      int(outH),
+		FrameNumber: f.FrameNumber,
+		Timestamp:   shm.Clock.Wall(f.Timestamp),
+		Width:       f.Width,
+		Height:      f.Height,
 		Format:      formatNV12,
-		Data:        data,
+		Data:        buf[:dataSize],
 	}, true
 }
 
@@ -496,30 +501,24 @@ func (r *shmReader) LatestDetection() (*DetectionResult, bool) {
 
 	r.lastDetVer = version
 
+	d, err := shm.DecodeDetections(unsafe.Slice((*byte)(unsafe.Pointer(&snapshot)), C.sizeof_LatestDetectionResult))
+	if err != nil {
+		logger.Warn("SHM", "Detection result %d: %v", version, err)
+		return nil, false
+	}
+
 	result := DetectionResult{
-		FrameNumber:   int(snapshot.frame_number),
-		Timestamp:     float64(snapshot.timestamp),
-		NumDetections: int(snapshot.num_detections),
+		FrameNumber:   int(d.FrameNumber),
+		Timestamp:     d.Timestamp,
+		NumDetections: len(d.Entries),
 		Version:       int(version),
 	}
-
-	if result.NumDetections > 0 {
-		result.Detections = make([]Detection, 0, result.NumDetections)
-		for i := 0; i < result.NumDetections && i < int(C.MAX_DETECTIONS); i++ {
-			det := snapshot.detections[i]
-			classBytes := C.GoBytes(unsafe.Pointer(&det.class_name[0]), 32)
-			className := string(bytes.TrimRight(classBytes, "\x00"))
-			result.Detections = append(result.Detections, Detection{
-				ClassName:  className,
-				Confidence: float64(det.confidence),
-				BBox: BoundingBox{
-					X: int(det.bbox.x),
-					Y: int(det.bbox.y),
-					W: int(det.bbox.w),
-					H: int(det.bbox.h),
-				},
-			})
-		}
+	for _, det := range d.Entries {
+		result.Detections = append(result.Detections, Detection{
+			ClassName:  det.ClassName,
+			Confidence: float64(det.Confidence),
+			BBox:       BoundingBox{X: det.X, Y: det.Y, W: det.W, H: det.H},
+		})
 	}
 
 	return &result, true
@@ -551,6 +550,16 @@ func (r *shmReader) LatestNV12() (*NV12Frame, bool) {
 }
 
 func (r *shmReader) LatestJPEG() ([]byte, bool) {
+	frame, ok := r.LatestJPEGFrame()
+	if !ok {
+		return nil, false
+	}
+	return frame.Data, true
+}
+
+// LatestJPEGFrame returns the latest frame encoded as JPEG, keeping its
+// number and capture time.
+func (r *shmReader) LatestJPEGFrame() (*frameSnapshot, bool) {
 	frame, ok := r.LatestFrame()
 	if !ok {
 		return nil, false
@@ -558,7 +567,7 @@ func (r *shmReader) LatestJPEG() ([]byte, bool) {
 
 	// If already JPEG, return as-is
 	if frame.Format == formatJPEG && len(frame.Data) > 0 {
-		return frame.Data, true
+		return frame, true
 	}
 
 	// If NV12, convert to JPEG
@@ -567,12 +576,39 @@ func (r *shmReader) LatestJPEG() ([]byte, bool) {
 		if err != nil {
 			return nil, false
 		}
-		return jpegData, true
+		frame.Data, frame.Format = jpegData, formatJPEG
+		return frame, true
 	}
 
 	return nil, false
 }
 
+// LatestFrameNumber returns the number of the latest frame without
+// importing its pixels, for cheap polling.
+func (r *shmReader) LatestFrameNumber() (uint64, bool) {
+	if r.frameShm == nil {
+		return 0, false
+	}
+	var cFrame C.ZeroCopyFrame
+	if C.read_zc_frame(r.frameShm, &cFrame) != 0 || cFrame.version == 0 {
+		return 0, false
+	}
+	return uint64(cFrame.frame_number), true
+}
+
+// LatestCameraID returns the camera (0 = day, 1 = IR night) that wrote the
+// latest frame, without importing its pixels.
+func (r *shmReader) LatestCameraID() (int, bool) {
+	if r.frameShm == nil {
+		return 0, false
+	}
+	var cFrame C.ZeroCopyFrame
+	if C.read_zc_frame(r.frameShm, &cFrame) != 0 || cFrame.version == 0 {
+		return 0, false
+	}
+	return int(cFrame.camera_id), true
+}
+
 // nv12ToJPEG converts NV12 format to JPEG using hardware encoder with software fallback
 func nv12ToJPEG(nv12Data []byte, width, height int) ([]byte, error) {
 	return nv12ToJPEGHardware(nv12Data, width, height)
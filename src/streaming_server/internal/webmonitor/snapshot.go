@@ -0,0 +1,236 @@
+package webmonitor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/clock"
+)
+
+// Snapshotter captures single JPEG frames on demand. It owns a dedicated SHM
+// reader so callers outside the broadcaster goroutines can grab a frame.
+type Snapshotter struct {
+	mu  sync.Mutex
+	shm *shmReader
+	dir string
+}
+
+// NewSnapshotter creates a snapshotter saving files under dir. shm may be nil.
+func NewSnapshotter(shm *shmReader, dir string) *Snapshotter {
+	return &Snapshotter{shm: shm, dir: dir}
+}
+
+// JPEG returns the latest frame encoded as JPEG.
+func (s *Snapshotter) JPEG() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shm == nil {
+		return nil, fmt.Errorf("frame SHM not connected")
+	}
+	data, ok := s.shm.LatestJPEG()
+	if !ok {
+		return nil, fmt.Errorf("no frame available from SHM")
+	}
+	return data, nil
+}
+
+// Frame returns the latest frame encoded as JPEG with its number and
+// capture time.
+func (s *Snapshotter) Frame() (*frameSnapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shm == nil {
+		return nil, fmt.Errorf("frame SHM not connected")
+	}
+	frame, ok := s.shm.LatestJPEGFrame()
+	if !ok {
+		return nil, fmt.Errorf("no frame available from SHM")
+	}
+	return frame, nil
+}
+
+// FrameNumber returns the latest frame's number without encoding it.
+func (s *Snapshotter) FrameNumber() (uint64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shm == nil {
+		return 0, false
+	}
+	return s.shm.LatestFrameNumber()
+}
+
+// Save captures the latest frame to dir and returns the filename.
+func (s *Snapshotter) Save() (string, error) {
+	data, err := s.JPEG()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("snapshot_%s.jpg", time.Now().In(clock.Location()).Format(clock.FileStampLayout+".000"))
+	if err := os.WriteFile(filepath.Join(s.dir, name), data, 0o644); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// handleSnapshot serves GET /api/snapshot (the current frame as JPEG).
+func (s *Server) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	data, err := s.snapshots.JPEG()
+	if err != nil {
+		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(data)
+}
+
+// handleSnapshotServe serves GET /api/snapshots/{filename}.
+func (s *Server) handleSnapshotServe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.serveSnapshotFile(w, r, strings.TrimPrefix(r.URL.Path, "/api/snapshots/"))
+}
+
+// serveSnapshotFile serves the saved snapshot filename.
+func (s *Server) serveSnapshotFile(w http.ResponseWriter, r *http.Request, filename string) {
+	filename = filepath.Base(filename)
+	if filename == "" || filename == "." || !strings.HasSuffix(filename, ".jpg") {
+		http.Error(w, "Invalid filename", http.StatusBadRequest)
+		return
+	}
+	filePath := filepath.Join(s.snapshots.dir, filename)
+	if _, err := os.Stat(filePath); err != nil {
+		writeJSONWithStatus(w, map[string]any{"error": "not found"}, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	http.ServeFile(w, r, filePath)
+}
+
+// Long-poll bounds for GET /frame.jpg?wait=true.
+const (
+	framePollInterval = 15 * time.Millisecond
+	frameWaitDefault  = 10 * time.Second
+	frameWaitMax      = 60 * time.Second
+)
+
+// waitNewFrame polls current until it reports a frame number other than
+// after (numbers restart with the camera, so any change is new) or ctx
+// ends. It returns the last number seen and whether it changed.
+func waitNewFrame(ctx context.Context, after uint64, current func() (uint64, bool)) (uint64, bool) {
+	ticker := time.NewTicker(framePollInterval)
+	defer ticker.Stop()
+	for {
+		if n, ok := current(); ok && n != after {
+			return n, true
+		}
+		select {
+		case <-ctx.Done():
+			n, _ := current()
+			return n, false
+		case <-ticker.C:
+		}
+	}
+}
+
+// handleFrameJPEG serves GET /frame.jpg: one JPEG with X-Frame-Number and
+// X-Frame-Timestamp headers, for clients that cannot parse multipart MJPEG.
+// With wait=true it long-polls until a frame newer than after (default: the
+// current one) exists, answering 204 after timeout (default 10s, max 60s).
+func (s *Server) handleFrameJPEG(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	if overlay, _ := strconv.ParseBool(q.Get("overlay")); overlay {
+		s.serveOverlayFrame(w, r)
+		return
+	}
+	if wait, _ := strconv.ParseBool(q.Get("wait")); wait {
+		timeout := frameWaitDefault
+		if v := q.Get("timeout"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				writeJSONWithStatus(w, map[string]any{"error": "invalid timeout"}, http.StatusBadRequest)
+				return
+			}
+			timeout = min(d, frameWaitMax)
+		}
+		after, ok := s.snapshots.FrameNumber()
+		if v := q.Get("after"); v != "" {
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				writeJSONWithStatus(w, map[string]any{"error": "invalid after"}, http.StatusBadRequest)
+				return
+			}
+			after, ok = n, true
+		}
+		if ok {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			n, changed := waitNewFrame(ctx, after, s.snapshots.FrameNumber)
+			cancel()
+			if !changed {
+				if r.Context().Err() != nil {
+					return
+				}
+				w.Header().Set("X-Frame-Number", strconv.FormatUint(n, 10))
+				w.Header().Set("Cache-Control", "no-store")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+	}
+
+	frame, err := s.snapshots.Frame()
+	if err != nil {
+		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Length", strconv.Itoa(len(frame.Data)))
+	w.Header().Set("X-Frame-Number", strconv.FormatUint(frame.FrameNumber, 10))
+	w.Header().Set("X-Frame-Timestamp", strconv.FormatFloat(float64(frame.Timestamp.UnixMilli())/1000, 'f', 3, 64))
+	w.Write(frame.Data)
+}
+
+// serveOverlayFrame serves GET /frame.jpg?overlay=true: the next frame the
+// MJPEG broadcaster encodes, with the timestamp and detection overlay.
+// Waiting subscribes as a snapshot consumer, so this works (and makes the
+// broadcaster encode) while no MJPEG viewer is connected. It answers 204
+// if no frame is encoded within frameWaitDefault.
+func (s *Server) serveOverlayFrame(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), frameWaitDefault)
+	data := s.broadcaster.NextFrame(ctx)
+	cancel()
+	w.Header().Set("Cache-Control", "no-store")
+	if data == nil {
+		if r.Context().Err() == nil {
+			w.WriteHeader(http.StatusNoContent)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
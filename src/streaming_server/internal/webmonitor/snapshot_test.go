@@ -0,0 +1,36 @@
+package webmonitor
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWaitNewFrame(t *testing.T) {
+	var frame atomic.Uint64
+	frame.Store(41)
+	current := func() (uint64, bool) { return frame.Load(), true }
+
+	go func() {
+		time.Sleep(3 * framePollInterval)
+		frame.Store(42)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if n, ok := waitNewFrame(ctx, 41, current); !ok || n != 42 {
+		t.Fatalf("waitNewFrame = %d, %v; want 42, true", n, ok)
+	}
+
+	// A lower number (camera restarted) counts as new
+	frame.Store(3)
+	if n, ok := waitNewFrame(ctx, 42, current); !ok || n != 3 {
+		t.Fatalf("after restart: %d, %v; want 3, true", n, ok)
+	}
+
+	short, cancelShort := context.WithTimeout(context.Background(), 2*framePollInterval)
+	defer cancelShort()
+	if n, ok := waitNewFrame(short, 3, current); ok || n != 3 {
+		t.Fatalf("timeout: %d, %v; want 3, false", n, ok)
+	}
+}
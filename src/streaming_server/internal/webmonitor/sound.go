@@ -0,0 +1,102 @@
+package webmonitor
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// EventSoundDetected is emitted when the audio level stays above
+// SoundDetector.ThresholdDB (barking, meowing).
+const EventSoundDetected = "sound_detected"
+
+// SoundDetector turns PCM audio into sound_detected events using an RMS
+// threshold. It has no audio source of its own: the audio capture reader
+// feeds it through Server.FeedAudio once that SHM exists.
+type SoundDetector struct {
+	emit func(Event)
+
+	mu        sync.Mutex
+	loudSince time.Time // start of the current loud streak (zero = quiet)
+	peakDB    float64   // loudest chunk in the current streak
+	emitted   bool      // event already sent for this streak
+	lastEmit  time.Time
+	levelDB   float64 // latest chunk level
+
+	// Configurable parameters
+	ThresholdDB float64       // RMS level in dBFS counted as loud
+	MinDuration time.Duration // loud streak required before emitting
+	Cooldown    time.Duration // minimum gap between events
+}
+
+// NewSoundDetector creates a detector sending events to emit.
+func NewSoundDetector(emit func(Event)) *SoundDetector {
+	return &SoundDetector{
+		emit:        emit,
+		levelDB:     math.Inf(-1),
+		ThresholdDB: -30,
+		MinDuration: 300 * time.Millisecond,
+		Cooldown:    10 * time.Second,
+	}
+}
+
+// rmsDBFS returns the RMS level of signed 16-bit samples in dB relative to
+// full scale (0 dBFS = full-scale square wave, silence = -Inf).
+func rmsDBFS(samples []int16) float64 {
+	if len(samples) == 0 {
+		return math.Inf(-1)
+	}
+	var sum float64
+	for _, s := range samples {
+		v := float64(s) / 32768
+		sum += v * v
+	}
+	return 10 * math.Log10(sum/float64(len(samples)))
+}
+
+// Feed processes one chunk of mono S16 samples captured at at. Chunks should
+// be short (tens of milliseconds) relative to MinDuration.
+func (d *SoundDetector) Feed(samples []int16, at time.Time) {
+	db := rmsDBFS(samples)
+
+	d.mu.Lock()
+	d.levelDB = db
+	if db < d.ThresholdDB {
+		d.loudSince = time.Time{}
+		d.emitted = false
+		d.mu.Unlock()
+		return
+	}
+	if d.loudSince.IsZero() {
+		d.loudSince = at
+		d.peakDB = db
+	}
+	d.peakDB = math.Max(d.peakDB, db)
+
+	var ev *Event
+	if !d.emitted && at.Sub(d.loudSince) >= d.MinDuration && at.Sub(d.lastEmit) >= d.Cooldown {
+		d.emitted = true
+		d.lastEmit = at
+		ev = &Event{
+			Type:      EventSoundDetected,
+			Timestamp: float64(at.UnixNano()) / 1e9,
+			Data: map[string]string{
+				"db":          strconv.FormatFloat(d.peakDB, 'f', 1, 64),
+				"duration_ms": strconv.FormatInt(at.Sub(d.loudSince).Milliseconds(), 10),
+			},
+		}
+	}
+	d.mu.Unlock()
+
+	if ev != nil && d.emit != nil {
+		d.emit(*ev)
+	}
+}
+
+// LevelDB returns the level of the latest chunk in dBFS.
+func (d *SoundDetector) LevelDB() float64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.levelDB
+}
@@ -0,0 +1,71 @@
+package webmonitor
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func tone(amplitude int16, n int) []int16 {
+	out := make([]int16, n)
+	for i := range out {
+		if i%2 == 0 {
+			out[i] = amplitude
+		} else {
+			out[i] = -amplitude
+		}
+	}
+	return out
+}
+
+func TestRMSDBFS(t *testing.T) {
+	if got := rmsDBFS(tone(32767, 64)); math.Abs(got) > 0.01 {
+		t.Fatalf("full scale = %.2f dBFS, want 0", got)
+	}
+	if got := rmsDBFS(tone(3277, 64)); math.Abs(got+20) > 0.1 {
+		t.Fatalf("1/10 scale = %.2f dBFS, want -20", got)
+	}
+	if !math.IsInf(rmsDBFS(make([]int16, 64)), -1) {
+		t.Fatal("silence should be -Inf")
+	}
+}
+
+func TestSoundDetector(t *testing.T) {
+	var got []Event
+	d := NewSoundDetector(func(ev Event) { got = append(got, ev) })
+	loud, quiet := tone(16384, 480), tone(100, 480) // -6 dBFS, ~-50 dBFS
+	base := time.Unix(1700000000, 0)
+	chunk := 50 * time.Millisecond
+
+	at := base
+	feed := func(samples []int16, n int) {
+		for i := 0; i < n; i++ {
+			d.Feed(samples, at)
+			at = at.Add(chunk)
+		}
+	}
+
+	feed(loud, 4) // 150ms: too short
+	feed(quiet, 2)
+	if len(got) != 0 {
+		t.Fatalf("short burst emitted %v", got)
+	}
+
+	feed(loud, 10) // 450ms streak: one event
+	if len(got) != 1 || got[0].Type != EventSoundDetected || got[0].Data["db"] != "-6.0" {
+		t.Fatalf("got %+v", got)
+	}
+
+	feed(quiet, 2)
+	feed(loud, 10) // inside cooldown
+	if len(got) != 1 {
+		t.Fatalf("cooldown ignored: %d events", len(got))
+	}
+
+	at = at.Add(d.Cooldown)
+	feed(quiet, 1)
+	feed(loud, 10)
+	if len(got) != 2 {
+		t.Fatalf("after cooldown: %d events, want 2", len(got))
+	}
+}
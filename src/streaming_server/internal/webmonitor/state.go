@@ -0,0 +1,186 @@
+package webmonitor
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+// ServerState is the monitor state kept in Config.StatePath so a restart does
+// not lose the dashboard's recent detections or an active recording.
+// Detection history and events are persisted next to it in their own files.
+type ServerState struct {
+	SavedAt          time.Time         `json:"saved_at"`
+	Recording        *RecordingState   `json:"recording,omitempty"` // set while a recording was active
+	LatestDetection  *DetectionResult  `json:"latest_detection,omitempty"`
+	RecentDetections []DetectionResult `json:"recent_detections,omitempty"`
+}
+
+// RecordingState identifies the recording active when the state was saved.
+type RecordingState struct {
+	File      string         `json:"file"`
+	StartedAt time.Time      `json:"started_at"`
+	Owner     RecordingOwner `json:"owner"`
+	EventID   string         `json:"event_id,omitempty"`
+}
+
+// loadServerState reads a saved state; a missing file yields nil, nil.
+func loadServerState(path string) (*ServerState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var st ServerState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// save writes the state atomically (temp file + rename).
+func (st *ServerState) save(path string) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// StateSaver calls save every Interval, so a crash loses at most one
+// interval of state instead of everything since the last clean shutdown.
+type StateSaver struct {
+	Interval time.Duration
+
+	save    func()
+	mu      sync.Mutex
+	stop    chan struct{}
+	stopped bool
+}
+
+// NewStateSaver creates a saver with the default interval.
+func NewStateSaver(save func()) *StateSaver {
+	return &StateSaver{
+		Interval: 30 * time.Second,
+		save:     save,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start begins periodic saving.
+func (ss *StateSaver) Start() {
+	go ss.run()
+}
+
+// Stop halts periodic saving. It does not save; the caller saves once more
+// after stopping everything that mutates state.
+func (ss *StateSaver) Stop() {
+	ss.mu.Lock()
+	if !ss.stopped {
+		close(ss.stop)
+		ss.stopped = true
+	}
+	ss.mu.Unlock()
+}
+
+func (ss *StateSaver) run() {
+	ticker := time.NewTicker(ss.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ss.stop:
+			return
+		case <-ticker.C:
+			ss.save()
+		}
+	}
+}
+
+// saveState writes the monitor state file.
+func (s *Server) saveState() {
+	if s.cfg.StatePath == "" {
+		return
+	}
+	st := ServerState{SavedAt: time.Now()}
+	if file, startedAt, owner, ok := s.recorder.ActiveRecording(); ok {
+		st.Recording = &RecordingState{File: file, StartedAt: startedAt, Owner: owner, EventID: s.recorder.RecordingStatus().EventID}
+	}
+	st.LatestDetection, st.RecentDetections = s.monitor.Detections()
+	if err := st.save(s.cfg.StatePath); err != nil {
+		logger.Warn("State", "Failed to save state: %v", err)
+	}
+}
+
+// persistAll saves the state file, detection history and events.
+func (s *Server) persistAll() {
+	s.saveState()
+	if s.events != nil && s.cfg.EventsPath != "" {
+		if err := s.events.Save(s.cfg.EventsPath); err != nil {
+			logger.Warn("Server", "Failed to save events: %v", err)
+		}
+	}
+	if s.cfg.DetectionHistoryPath != "" {
+		if err := s.detectionHistory.Save(s.cfg.DetectionHistoryPath); err != nil {
+			logger.Warn("Server", "Failed to save detection history: %v", err)
+		}
+	}
+	if s.rollup != nil && s.cfg.RollupPath != "" {
+		if err := s.rollup.Save(s.cfg.RollupPath); err != nil {
+			logger.Warn("Server", "Failed to save detection rollups: %v", err)
+		}
+	}
+}
+
+// restoreState reloads the saved monitor state, resumes a recording that was
+// active when the previous process went down, and converts raw recordings
+// that were left unconverted.
+func (s *Server) restoreState() {
+	if s.cfg.StatePath != "" {
+		st, err := loadServerState(s.cfg.StatePath)
+		if err != nil {
+			logger.Warn("State", "Failed to load state: %v", err)
+		}
+		if st != nil {
+			down := time.Since(st.SavedAt).Round(time.Second)
+			s.alerts.RaiseMessage("system", "system:restart", AlertInfo, msg("alert.monitor_restarted", down))
+			s.monitor.RestoreDetections(st.LatestDetection, st.RecentDetections)
+			if st.Recording != nil {
+				s.resumeRecording(st)
+			}
+		}
+	}
+	s.recorder.RecoverInterrupted()
+}
+
+func (s *Server) resumeRecording(st *ServerState) {
+	if !s.cfg.ResumeRecording {
+		return
+	}
+	if down := time.Since(st.SavedAt); down > s.cfg.ResumeWindow {
+		logger.Info("State", "Not resuming %s: state is %v old", st.Recording.File, down.Round(time.Second))
+		return
+	}
+	file, err := s.recorder.Resume(s.cfg.ResumeGrace, st.Recording.Owner, st.Recording.EventID)
+	if err != nil {
+		logger.Warn("State", "Failed to resume recording %s: %v", st.Recording.File, err)
+		return
+	}
+	logger.Info("State", "Resumed interrupted recording %s as %s", st.Recording.File, file)
+	s.events.Append(Event{
+		EventID: st.Recording.EventID,
+		Type:    "recording_resumed",
+		Data:    map[string]string{"previous": st.Recording.File, "file": file},
+	})
+}
@@ -0,0 +1,62 @@
+package webmonitor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestServerState_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	st, err := loadServerState(path)
+	if err != nil || st != nil {
+		t.Fatalf("missing file: got %v, %v; want nil, nil", st, err)
+	}
+
+	saved := &ServerState{
+		SavedAt:   time.Unix(1700000000, 0).UTC(),
+		Recording: &RecordingState{File: "recording_20250101_120000.hevc", StartedAt: time.Unix(1699999000, 0).UTC()},
+		LatestDetection: &DetectionResult{
+			FrameNumber: 42,
+			Detections:  []Detection{{ClassName: "cat", Confidence: 0.9}},
+		},
+	}
+	if err := saved.save(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Error("temp file left behind")
+	}
+
+	loaded, err := loadServerState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, saved) {
+		t.Errorf("roundtrip mismatch:\n got %+v\nwant %+v", loaded, saved)
+	}
+}
+
+func TestInterruptedRaws(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"recording_1.hevc": "data",
+		"recording_2.hevc": "data", // active
+		"recording_3.hevc": "",     // empty
+		"recording_4.mp4":  "data",
+		"other.hevc":       "data",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := interruptedRaws(dir, "recording_2.hevc")
+	if want := []string{"recording_1.hevc"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("interruptedRaws = %v, want %v", got, want)
+	}
+}
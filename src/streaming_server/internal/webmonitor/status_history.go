@@ -0,0 +1,296 @@
+package webmonitor
+
+import (
+	"net/http"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+const (
+	statusHistorySample = 10 * time.Second // readings folded into each minute
+	statusHistoryBucket = "status"
+	// statusHistoryKey names the document of an hour's snapshots (UTC);
+	// keys sort by time.
+	statusHistoryKey = "2006-01-02T15"
+)
+
+// StatusSnapshot is one minute of /api/status for /api/status/history: the
+// state at the end of the minute, with the worst of the readings taken
+// during it, so a glitch shorter than a minute still shows.
+type StatusSnapshot struct {
+	Time             int64    `json:"t"` // Unix seconds, start of the minute
+	FPS              float64  `json:"fps"`
+	MinFPS           float64  `json:"min_fps"`
+	FramesProcessed  int      `json:"frames_processed"`
+	Detections       int      `json:"detection_count"`
+	DetectorHealthy  bool     `json:"detector_healthy"` // throughout the minute
+	MaxDetectorStale float64  `json:"max_detector_stale_seconds"`
+	MotionFallback   bool     `json:"motion_fallback,omitempty"`
+	MJPEGViewers     int      `json:"mjpeg_viewers"`
+	Recording        bool     `json:"recording"`
+	Power            string   `json:"power,omitempty"`
+	Lighting         Lighting `json:"lighting,omitempty"`
+	Degrade          string   `json:"degrade,omitempty"`
+	MaxCPUPercent    float64  `json:"max_cpu_percent,omitempty"`
+	Goroutines       int      `json:"goroutines"`
+	HeapMB           float64  `json:"heap_mb"`
+	Alerts           int      `json:"alerts"` // active
+}
+
+// fold adds reading r, taken later in the same minute.
+func (s *StatusSnapshot) fold(r StatusSnapshot) {
+	minFPS := min(s.MinFPS, r.FPS)
+	stale := max(s.MaxDetectorStale, r.MaxDetectorStale)
+	cpu := max(s.MaxCPUPercent, r.MaxCPUPercent)
+	healthy := s.DetectorHealthy && r.DetectorHealthy
+	*s = r
+	s.MinFPS, s.MaxDetectorStale, s.MaxCPUPercent, s.DetectorHealthy = minFPS, stale, cpu, healthy
+}
+
+// StatusHistory keeps a snapshot of the status per minute in a kv store for
+// the retention window, so a glitch can be looked into after the fact. Each
+// hour is one document of up to 60 snapshots, rewritten every minute.
+type StatusHistory struct {
+	store     kv.Store
+	retention time.Duration
+	read      func() StatusSnapshot
+
+	mu      sync.Mutex
+	minute  *StatusSnapshot // being folded
+	hourKey string
+	hour    []StatusSnapshot // the document under hourKey
+	stop    chan struct{}
+	done    chan struct{}
+	stopped bool
+}
+
+// NewStatusHistory creates a history recording readings from read into
+// store.
+func NewStatusHistory(store kv.Store, retention time.Duration, read func() StatusSnapshot) *StatusHistory {
+	return &StatusHistory{
+		store:     store,
+		retention: retention,
+		read:      read,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start begins sampling.
+func (h *StatusHistory) Start() {
+	go h.run()
+}
+
+// Stop halts sampling and writes the minute in progress.
+func (h *StatusHistory) Stop() {
+	h.mu.Lock()
+	if !h.stopped {
+		close(h.stop)
+		h.stopped = true
+	}
+	h.mu.Unlock()
+	<-h.done
+}
+
+func (h *StatusHistory) run() {
+	defer close(h.done)
+	h.prune(time.Now())
+	ticker := time.NewTicker(statusHistorySample)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.stop:
+			h.flush()
+			return
+		case now := <-ticker.C:
+			h.observe(now, h.read())
+		}
+	}
+}
+
+// observe folds reading r taken at now into its minute, writing the
+// previous minute once r starts a new one.
+func (h *StatusHistory) observe(now time.Time, r StatusSnapshot) {
+	r.Time = now.Truncate(time.Minute).Unix()
+	r.MinFPS = r.FPS
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.minute != nil && h.minute.Time == r.Time {
+		h.minute.fold(r)
+		return
+	}
+	h.flushLocked()
+	h.minute = &r
+}
+
+func (h *StatusHistory) flush() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.flushLocked()
+}
+
+// flushLocked writes the minute in progress into its hour's document.
+// Caller holds h.mu.
+func (h *StatusHistory) flushLocked() {
+	if h.minute == nil {
+		return
+	}
+	snap := *h.minute
+	h.minute = nil
+	key := time.Unix(snap.Time, 0).UTC().Format(statusHistoryKey)
+	if key != h.hourKey {
+		// A new hour, or one written before a restart: continue its document
+		h.hour = nil
+		if _, err := (kv.Doc{Store: h.store, Bucket: statusHistoryBucket, Key: key}).Load(&h.hour); err != nil {
+			logger.Warn("StatusHistory", "Failed to load %s: %v", key, err)
+		}
+		if h.hourKey != "" {
+			defer h.prune(time.Unix(snap.Time, 0))
+		}
+		h.hourKey = key
+	}
+	h.hour = append(h.hour, snap)
+	if err := (kv.Doc{Store: h.store, Bucket: statusHistoryBucket, Key: key}).Save(h.hour); err != nil {
+		logger.Warn("StatusHistory", "Failed to save %s: %v", key, err)
+	}
+}
+
+// prune deletes the hours that ended before the retention window.
+func (h *StatusHistory) prune(now time.Time) {
+	keys, err := h.store.Keys(statusHistoryBucket)
+	if err != nil {
+		logger.Warn("StatusHistory", "Failed to list snapshots: %v", err)
+		return
+	}
+	oldest := now.Add(-h.retention).UTC().Format(statusHistoryKey)
+	for _, key := range keys {
+		if key >= oldest {
+			break
+		}
+		if err := h.store.Delete(statusHistoryBucket, key); err != nil {
+			logger.Warn("StatusHistory", "Failed to delete %s: %v", key, err)
+		}
+	}
+}
+
+// Query returns the snapshots of minutes in [from, to], oldest first,
+// including the minute in progress.
+func (h *StatusHistory) Query(from, to time.Time) ([]StatusSnapshot, error) {
+	keys, err := h.store.Keys(statusHistoryBucket)
+	if err != nil {
+		return nil, err
+	}
+	first := from.UTC().Format(statusHistoryKey)
+	last := to.UTC().Format(statusHistoryKey)
+	out := []StatusSnapshot{}
+	keep := func(s StatusSnapshot) bool {
+		return s.Time >= from.Truncate(time.Minute).Unix() && s.Time <= to.Unix()
+	}
+	for _, key := range keys {
+		if key < first || key > last {
+			continue
+		}
+		var hour []StatusSnapshot
+		if _, err := (kv.Doc{Store: h.store, Bucket: statusHistoryBucket, Key: key}).Load(&hour); err != nil {
+			return nil, err
+		}
+		for _, s := range hour {
+			if keep(s) {
+				out = append(out, s)
+			}
+		}
+	}
+	h.mu.Lock()
+	if h.minute != nil && keep(*h.minute) {
+		out = append(out, *h.minute)
+	}
+	h.mu.Unlock()
+	slices.SortStableFunc(out, func(a, b StatusSnapshot) int { return int(a.Time - b.Time) })
+	return out, nil
+}
+
+// readStatusSnapshot takes a reading of what /api/status reports, plus the
+// process's own health.
+func (s *Server) readStatusSnapshot() StatusSnapshot {
+	stats, _, _, _ := s.monitor.Snapshot()
+	health := s.detectionHealthStatus()
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	snap := StatusSnapshot{
+		FPS:              stats.CurrentFPS,
+		FramesProcessed:  stats.FramesProcessed,
+		Detections:       stats.DetectionCount,
+		DetectorHealthy:  health.Healthy,
+		MaxDetectorStale: health.StaleSeconds,
+		MotionFallback:   health.MotionFallback,
+		MJPEGViewers:     s.broadcaster.GetClientCount(),
+		Goroutines:       runtime.NumGoroutine(),
+		HeapMB:           float64(mem.HeapAlloc) / (1 << 20),
+	}
+	if s.recorder != nil {
+		snap.Recording = s.recorder.RecordingStatus().Active
+	}
+	if s.powerSaver != nil {
+		snap.Power = s.powerSaver.Status().State
+	}
+	if s.lighting != nil {
+		snap.Lighting = s.lighting.Status().Lighting
+	}
+	if s.degrade != nil {
+		st := s.degrade.Status()
+		snap.Degrade, snap.MaxCPUPercent = st.Level, st.CPUPercent
+	}
+	if s.alerts != nil {
+		active, _ := s.alerts.Active()
+		snap.Alerts = len(active)
+	}
+	return snap
+}
+
+// handleStatusHistory serves GET /api/status/history?from=&to=. from/to are
+// RFC 3339 or Unix seconds; the default range is the last hour.
+func (s *Server) handleStatusHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.statusHistory == nil {
+		writeJSONWithStatus(w, map[string]any{"error": "status history disabled"}, http.StatusNotFound)
+		return
+	}
+	q := r.URL.Query()
+	to := time.Now()
+	from := to.Add(-time.Hour)
+	for name, dst := range map[string]*time.Time{"from": &from, "to": &to} {
+		v := q.Get(name)
+		if v == "" {
+			continue
+		}
+		t, err := parseQueryTime(v)
+		if err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": name + ": " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		*dst = t
+	}
+	if to.Before(from) {
+		writeJSONWithStatus(w, map[string]any{"error": "to is before from"}, http.StatusBadRequest)
+		return
+	}
+	snaps, err := s.statusHistory.Query(from, to)
+	if err != nil {
+		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]any{
+		"from":      from.Unix(),
+		"to":        to.Unix(),
+		"step_sec":  60,
+		"snapshots": snaps,
+	})
+}
@@ -0,0 +1,89 @@
+package webmonitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+)
+
+func TestStatusHistory(t *testing.T) {
+	store := kv.NewMemory()
+	h := NewStatusHistory(store, 2*time.Hour, nil)
+	start := time.Date(2026, 3, 1, 3, 58, 0, 0, time.UTC)
+	at := func(sec int) time.Time { return start.Add(time.Duration(sec) * time.Second) }
+
+	// 3:58: a dip to 4 fps and a stale detector mid-minute
+	h.observe(at(0), StatusSnapshot{FPS: 30, DetectorHealthy: true})
+	h.observe(at(20), StatusSnapshot{FPS: 4, MaxDetectorStale: 12})
+	h.observe(at(40), StatusSnapshot{FPS: 29, DetectorHealthy: true, Recording: true})
+	// 3:59 and 4:00, crossing into the next hour's document
+	h.observe(at(60), StatusSnapshot{FPS: 30, DetectorHealthy: true})
+	h.observe(at(120), StatusSnapshot{FPS: 30, DetectorHealthy: true})
+
+	snaps, err := h.Query(start, at(180))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snaps) != 3 {
+		t.Fatalf("got %d snapshots: %+v", len(snaps), snaps)
+	}
+	first := snaps[0]
+	if first.Time != start.Unix() || first.FPS != 29 || first.MinFPS != 4 || first.MaxDetectorStale != 12 || first.DetectorHealthy || !first.Recording {
+		t.Errorf("3:58 folded into %+v", first)
+	}
+	if snaps[2].Time != at(120).Unix() {
+		t.Errorf("minute in progress %+v", snaps[2])
+	}
+	if keys, _ := store.Keys(statusHistoryBucket); len(keys) != 1 || keys[0] != "2026-03-01T03" {
+		t.Errorf("hour documents before 4:00 is written %q", keys)
+	}
+	if snaps, _ := h.Query(at(60), at(60)); len(snaps) != 1 || snaps[0].Time != at(60).Unix() {
+		t.Errorf("one-minute range %+v", snaps)
+	}
+
+	// A restart in the same hour continues its document
+	h.flush()
+	if keys, _ := store.Keys(statusHistoryBucket); len(keys) != 2 {
+		t.Errorf("hour documents %q", keys)
+	}
+	h = NewStatusHistory(store, 2*time.Hour, nil)
+	h.observe(at(180), StatusSnapshot{FPS: 30})
+	h.flush()
+	if snaps, _ := h.Query(at(60), at(300)); len(snaps) != 3 {
+		t.Errorf("after restart %+v", snaps)
+	}
+
+	// Hours before the retention window are dropped
+	h.prune(at(2*3600 + 120))
+	if keys, _ := store.Keys(statusHistoryBucket); len(keys) != 1 || keys[0] != "2026-03-01T04" {
+		t.Errorf("after prune %q", keys)
+	}
+}
+
+func TestStatusHistoryHandler(t *testing.T) {
+	h := NewStatusHistory(kv.NewMemory(), time.Hour, nil)
+	now := time.Now()
+	h.observe(now.Add(-2*time.Minute), StatusSnapshot{FPS: 30})
+	h.observe(now, StatusSnapshot{FPS: 30})
+	s := &Server{statusHistory: h}
+
+	rec := httptest.NewRecorder()
+	s.handleStatusHistory(rec, httptest.NewRequest(http.MethodGet, "/api/status/history", nil))
+	var resp struct {
+		Snapshots []StatusSnapshot `json:"snapshots"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || rec.Code != http.StatusOK || len(resp.Snapshots) != 2 {
+		t.Errorf("default range: %d %+v %v", rec.Code, resp, err)
+	}
+	for _, q := range []string{"?from=yesterday", "?from=200&to=100"} {
+		rec = httptest.NewRecorder()
+		s.handleStatusHistory(rec, httptest.NewRequest(http.MethodGet, "/api/status/history"+q, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: %d", q, rec.Code)
+		}
+	}
+}
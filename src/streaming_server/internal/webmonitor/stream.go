@@ -68,7 +68,7 @@ func init() {
 type jpegProvider func() ([]byte, bool)
 
 // streamMJPEGFromChannel streams MJPEG from a channel (fanout pattern).
-func streamMJPEGFromChannel(w http.ResponseWriter, r *http.Request, frameCh <-chan []byte) {
+func streamMJPEGFromChannel(w http.ResponseWriter, r *http.Request, frameCh <-chan []byte, acct *mjpegStream) {
 	ctx := r.Context()
 	flusher, ok := w.(http.Flusher)
 	if !ok {
@@ -103,6 +103,11 @@ func streamMJPEGFromChannel(w http.ResponseWriter, r *http.Request, frameCh <-ch
 			jpegData = cachedBlankJPEG
 		}
 
+		// Over the MJPEG bandwidth cap: skip this frame, the next one is newer anyway
+		if !acct.allow(len(jpegData)) {
+			continue
+		}
+
 		// Write frame in single syscall for TCP efficiency; reuse buffer to avoid per-frame allocation
 		buf.Reset()
 		fmt.Fprintf(&buf, "--frame\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n", len(jpegData))
@@ -113,6 +118,7 @@ func streamMJPEGFromChannel(w http.ResponseWriter, r *http.Request, frameCh <-ch
 			return
 		}
 		flusher.Flush()
+		acct.sent(buf.Len())
 	}
 }
 
@@ -2,6 +2,9 @@ package webmonitor
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -10,11 +13,15 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/clock"
 	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/codec"
 	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/outbox"
 	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/shm"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/storage"
 	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/types"
 )
 
@@ -30,8 +37,15 @@ type Recorder struct {
 	mu sync.RWMutex
 
 	// Configuration
-	outputPath string
-	shmName    string
+	outputPath   atomic.Pointer[string]        // local working directory (raw stream, conversion); see dir
+	paths        atomic.Pointer[storage.Paths] // recording paths failed over between (nil = outputPath only)
+	shmName      string
+	storage      storage.Storage // where finished clips live
+	remote       bool            // storage is not outputPath itself
+	seiCamera    string          // camera name for the timestamp SEI
+	seiEnabled   bool            // insert a timestamp SEI into every recorded frame
+	proxyShmName string          // low-res substream recorded as a proxy ("" = none)
+	outbox       *outbox.Queue   // retries uploads across outages (nil = upload once)
 
 	// Runtime state
 	shmReader            *shm.Reader
@@ -47,7 +61,32 @@ type Recorder struct {
 	bytesWritten         uint64
 	lastHeartbeat        time.Time
 	stopReason           string
-	firstDetectionOffset float64 // seconds from recording start when first detection occurred (-1 = none)
+	firstDetectionOffset float64        // seconds from recording start when first detection occurred (-1 = none)
+	waitingKeyframe      bool           // started, no IDR written yet (pre-IDR frames are dropped)
+	wallStart            time.Time      // wall-clock start, corrected after a clock jump
+	clockJump            time.Duration  // total clock correction applied during this recording
+	skippedFrames        uint64         // frames dropped while waiting for the first IDR
+	owner                RecordingOwner // who started the recording
+	eventID              string         // trigger's correlation ID (see Event.EventID)
+	stopTrim             StopTrim       // what Stop does with the GOP in progress
+	gopWait              time.Duration  // TrimGOP: least wait for the next IDR
+	finishing            chan struct{}  // closed at the IDR ending the GOP a Stop waits for
+	gopClosed            bool           // that IDR was seen; no more frames are written
+	firstFrameAt         time.Time      // capture time of the first written frame
+	lastIDR              uint64         // index of the last written IDR frame
+	lastIDRAt            time.Time      // its capture time
+	sidecar              *os.File       // detection log (see ObserveDetection)
+	sidecarLast          time.Duration  // offset of the last sidecar line
+	sidecarBest          float64        // highest confidence logged so far
+	proxy                *proxyTrack    // substream recording, nil without one
+	dayFrames            uint64         // frames written from the day camera
+	nightFrames          uint64         // frames written from the IR night camera
+	indexMu              sync.Mutex     // guards the lighting and stats index files
+	clipMu               sync.Mutex     // serializes shared clip exports
+
+	// Last seen VPS/SPS/PPS, kept across recordings and restarts (outputPath/.paramsets)
+	paramSets       codec.ParamSets
+	paramSetsLoaded bool
 
 	// Control
 	stopCh chan struct{}
@@ -56,19 +95,120 @@ type Recorder struct {
 
 // NewRecorder creates a new H.264 recorder
 func NewRecorder(outputPath, shmName string) *Recorder {
-	return &Recorder{
-		outputPath: outputPath,
-		shmName:    shmName,
+	r := &Recorder{
+		shmName:  shmName,
+		storage:  storage.NewLocal(outputPath),
+		stopTrim: TrimCut,
 	}
+	r.outputPath.Store(&outputPath)
+	return r
 }
 
-// Start begins recording H.264 frames to a new file
-func (r *Recorder) Start() (string, error) {
+// dir returns the directory recordings are written to.
+func (r *Recorder) dir() string {
+	return *r.outputPath.Load()
+}
+
+// dirs returns every directory recordings may be in, the failover paths in
+// order or the output directory.
+func (r *Recorder) dirs() []string {
+	if paths := r.paths.Load(); paths != nil {
+		return paths.Dirs()
+	}
+	return []string{r.dir()}
+}
+
+// SetStorage sets where finished clips are kept. When st is not the local
+// output directory, outputPath becomes a scratch area: the raw stream and MP4
+// conversion happen there, then the MP4 and thumbnail are uploaded and the
+// local copies removed.
+func (r *Recorder) SetStorage(st storage.Storage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.storage = st
+	switch st := st.(type) {
+	case *storage.Local:
+		r.remote = st.Dir() != filepath.Clean(r.dir())
+	case *storage.Paths:
+		r.paths.Store(st)
+		r.remote = false
+	default:
+		r.remote = true
+	}
+}
+
+// errBusy is returned by SetOutputPath while a recording or its conversion
+// still uses the directory.
+var errBusy = errors.New("recording or conversion in progress")
+
+// SetOutputPath moves new recordings to dir, one of the paths of a
+// storage.Paths storage, which keeps listing the clips in the others. It
+// fails with errBusy while a recording or its conversion is in progress.
+func (r *Recorder) SetOutputPath(dir string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.recording || r.converting {
+		return errBusy
+	}
+	if paths := r.paths.Load(); paths != nil {
+		if err := paths.SetActive(dir); err != nil {
+			return err
+		}
+	}
+	r.outputPath.Store(&dir)
+	return nil
+}
+
+// recordingDir returns the directory holding the recording name: with
+// failover paths the one it landed on, else the output directory.
+func (r *Recorder) recordingDir(name string) string {
+	if paths := r.paths.Load(); paths != nil {
+		if l, err := paths.Locate(name); err == nil {
+			return l.Dir()
+		}
+	}
+	return r.dir()
+}
+
+// SetOutbox queues uploads to remote storage in q, so clips finished while
+// the storage is unreachable are uploaded once it is back.
+func (r *Recorder) SetOutbox(q *outbox.Queue) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.outbox = q
+	q.Register(uploadJobKind, r.deliverUpload)
+}
+
+// SetTimestampSEI makes recordings carry the capture time and camera name
+// in a per-frame SEI (see codec.TimestampSEI). Call before recording.
+func (r *Recorder) SetTimestampSEI(camera string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seiEnabled = true
+	r.seiCamera = camera
+}
+
+// Storage returns the backend holding finished clips.
+func (r *Recorder) Storage() storage.Storage {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.storage
+}
+
+func (r *Recorder) isRemote() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.remote
+}
+
+// Start begins recording H.264 frames to a new file on behalf of owner.
+// It returns ErrAlreadyRecording while another recording is active.
+func (r *Recorder) Start(owner RecordingOwner, eventID string) (string, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	if r.recording {
-		return "", fmt.Errorf("already recording")
+		return "", ErrAlreadyRecording
 	}
 
 	if r.converting {
@@ -76,14 +216,24 @@ func (r *Recorder) Start() (string, error) {
 	}
 
 	// Ensure output directory exists
-	if err := os.MkdirAll(r.outputPath, 0755); err != nil {
+	if err := os.MkdirAll(r.dir(), 0755); err != nil {
 		return "", fmt.Errorf("failed to create output directory: %w", err)
 	}
 
+	// Prime headers so an IDR without in-band VPS/SPS/PPS is still playable
+	if !r.paramSetsLoaded {
+		r.paramSetsLoaded = true
+		if ps, err := codec.LoadParamSets(filepath.Join(r.dir(), codec.ParamSetsFile)); err != nil {
+			logger.Warn("Recorder", "Failed to load parameter sets: %v", err)
+		} else {
+			r.paramSets = ps
+		}
+	}
+
 	// Generate filename with timestamp
-	timestamp := time.Now().Format("20060102_150405")
+	timestamp := clock.FileStamp(time.Now())
 	r.filename = fmt.Sprintf("recording_%s.hevc", timestamp)
-	filepath := filepath.Join(r.outputPath, r.filename)
+	filepath := filepath.Join(r.dir(), r.filename)
 
 	// Create file
 	file, err := os.Create(filepath)
@@ -99,9 +249,12 @@ func (r *Recorder) Start() (string, error) {
 		return "", fmt.Errorf("failed to open shared memory: %w", err)
 	}
 
+	processor := codec.NewProcessor()
+	processor.Prime(r.paramSets)
+
 	// Initialize state
 	r.shmReader = reader
-	r.h264Processor = codec.NewProcessor()
+	r.h264Processor = processor
 	r.file = file
 	r.recording = true
 	r.startTime = time.Now()
@@ -110,18 +263,42 @@ func (r *Recorder) Start() (string, error) {
 	r.lastHeartbeat = time.Now()
 	r.stopReason = ""
 	r.firstDetectionOffset = -1 // -1 means no detection yet
+	r.waitingKeyframe = true
+	r.skippedFrames = 0
+	r.gopClosed = false
+	r.lastIDR = 0
+	r.dayFrames, r.nightFrames = 0, 0
+	r.wallStart = r.startTime.Round(0)
+	r.clockJump = 0
+	r.owner = owner
+	r.eventID = eventID
 	r.stopCh = make(chan struct{})
+	r.startProxyLocked()
 
 	// Start recording goroutine
 	r.wg.Add(1)
 	go r.recordLoop()
 
-	logger.Info("Recorder", "Started recording to %s", filepath)
+	logger.Info("Recorder", "Started recording to %s (owner=%s, event=%s)", filepath, owner.ID, eventID)
 	return r.filename, nil
 }
 
-// Stop stops recording and returns the filename
+// Stop stops recording and returns the filename. With TrimGOP it first
+// waits for the GOP in progress to end.
 func (r *Recorder) Stop() (string, error) {
+	return r.stop(false)
+}
+
+// StopAtGOP is Stop that waits for the GOP in progress to end whatever the
+// stop policy, for recordings whose end nobody chose to the frame.
+func (r *Recorder) StopAtGOP() (string, error) {
+	return r.stop(true)
+}
+
+func (r *Recorder) stop(atGOP bool) (string, error) {
+	if err := r.finishGOP(atGOP); err != nil {
+		return "", err
+	}
 	r.mu.Lock()
 
 	if !r.recording {
@@ -137,6 +314,8 @@ func (r *Recorder) Stop() (string, error) {
 	r.recording = false
 	filename := r.filename
 	detectionOffset := r.firstDetectionOffset
+	wallStart := r.wallStart
+	proxy := r.proxy
 
 	r.mu.Unlock()
 
@@ -154,18 +333,21 @@ func (r *Recorder) Stop() (string, error) {
 		r.file = nil
 	}
 
+	r.closeSidecarLocked()
+
 	// Close SHM reader
 	if r.shmReader != nil {
 		r.shmReader.Close()
 		r.shmReader = nil
 	}
 
-	logger.Info("Recorder", "Stopped recording: %s (frames=%d, bytes=%d, firstDetection=%.2fs)",
-		filename, r.frameCount, r.bytesWritten, detectionOffset)
+	lighting := r.indexStoppedLocked(filename)
+	logger.Info("Recorder", "Stopped recording: %s (frames=%d, bytes=%d, firstDetection=%.2fs, lighting=%s)",
+		filename, r.frameCount, r.bytesWritten, detectionOffset, lighting)
 
 	// Start MP4 conversion in background
 	r.converting = true
-	go r.convertToMP4(filename, detectionOffset)
+	go r.convertToMP4(filename, detectionOffset, wallStart, proxy)
 
 	return filename, nil
 }
@@ -177,7 +359,6 @@ func (r *Recorder) recordLoop() {
 	ticker := time.NewTicker(33 * time.Millisecond) // ~30fps
 	defer ticker.Stop()
 
-	firstIDRWritten := false
 	var lastFrameNum uint64
 
 	for {
@@ -200,14 +381,17 @@ func (r *Recorder) recordLoop() {
 			return
 		}
 
-		if time.Since(r.lastHeartbeat) > HeartbeatTimeout {
+		// A finishing Stop bounds the recording itself
+		finishing := r.finishing != nil
+
+		if !finishing && time.Since(r.lastHeartbeat) > HeartbeatTimeout {
 			r.mu.RUnlock()
 			logger.Warn("Recorder", "Heartbeat timeout, auto-stopping recording")
 			r.autoStop("heartbeat timeout")
 			return
 		}
 
-		if time.Since(r.startTime) > MaxRecordingDuration {
+		if !finishing && time.Since(r.startTime) > MaxRecordingDuration {
 			r.mu.RUnlock()
 			logger.Warn("Recorder", "Max duration reached, auto-stopping recording")
 			r.autoStop("max duration reached")
@@ -216,6 +400,7 @@ func (r *Recorder) recordLoop() {
 
 		reader := r.shmReader
 		processor := r.h264Processor
+		seiEnabled, seiCamera := r.seiEnabled, r.seiCamera
 		r.mu.RUnlock()
 
 		type readResult struct {
@@ -251,46 +436,79 @@ func (r *Recorder) recordLoop() {
 		if err := processor.Process(frame); err != nil {
 			logger.Debug("Recorder", "Process error: %v", err)
 		}
+		if seiEnabled {
+			codec.InsertNAL(frame, codec.TimestampSEI(frame.Timestamp, seiCamera))
+		}
 
-		r.mu.Lock()
-		if r.file == nil || !r.recording {
-			r.mu.Unlock()
+		if !r.writeFrame(frame, processor) {
 			return
 		}
+	}
+}
 
-		// ReadLatestCopy already copied data to Go heap — safe to use directly
+// writeFrame appends frame to the raw file. It returns false once the
+// recording is closed.
+func (r *Recorder) writeFrame(frame *types.VideoFrame, processor *codec.Processor) bool {
+	r.mu.Lock()
+	if r.file == nil || !r.recording {
+		r.mu.Unlock()
+		return false
+	}
+	if r.gopEndedLocked(frame) {
+		r.mu.Unlock()
+		return true
+	}
 
-		// Wait for first IDR before writing anything
-		if !firstIDRWritten {
-			if !frame.IsIDR {
-				r.mu.Unlock()
-				continue
-			}
-			// Prepend VPS/SPS/PPS headers
-			headers, _ := processor.PrependHeaders(frame.Data)
-			if len(headers) > len(frame.Data) {
-				frame.Data = headers
-			}
-			firstIDRWritten = true
-		}
-		dataToWrite := frame.Data
+	// ReadLatestCopy already copied data to Go heap — safe to use directly
 
-		n, err := r.file.Write(dataToWrite)
-		if err != nil {
-			logger.Warn("Recorder", "Write error: %v", err)
+	// Wait for first IDR before writing anything
+	if r.waitingKeyframe {
+		if !frame.IsIDR {
+			r.skippedFrames++
 			r.mu.Unlock()
-			continue
+			return true
+		}
+		r.waitingKeyframe = false
+		if r.skippedFrames > 0 {
+			logger.Info("Recorder", "First IDR after %d skipped frames", r.skippedFrames)
 		}
+		// Prepend VPS/SPS/PPS headers
+		headers, _ := processor.PrependHeaders(frame.Data)
+		if len(headers) > len(frame.Data) {
+			frame.Data = headers
+		}
+	}
+	dataToWrite := frame.Data
 
-		r.frameCount++
-		r.bytesWritten += uint64(n)
+	n, err := r.file.Write(dataToWrite)
+	if err != nil {
+		logger.Warn("Recorder", "Write error: %v", err)
 		r.mu.Unlock()
+		return true
+	}
+
+	r.countFrameLocked(frame)
+	r.bytesWritten += uint64(n)
+	r.countLightingLocked(frame.CameraID)
+	var newParamSets codec.ParamSets
+	if frame.IsIDR && processor.HasHeaders() && !processor.ParamSets().Equal(r.paramSets) {
+		newParamSets = processor.ParamSets()
+		r.paramSets = newParamSets
+	}
+	r.mu.Unlock()
+
+	if newParamSets.Complete() {
+		if err := codec.SaveParamSets(filepath.Join(r.dir(), codec.ParamSetsFile), newParamSets); err != nil {
+			logger.Warn("Recorder", "Failed to save parameter sets: %v", err)
+		}
 	}
+	return true
 }
 
 // convertToMP4 converts H.264 file to MP4 using ffmpeg (background task)
-// detectionOffset is the timestamp (in seconds) of first detection, or -1 if none
-func (r *Recorder) convertToMP4(h264Filename string, detectionOffset float64) {
+// detectionOffset is the timestamp (in seconds) of first detection, or -1 if none.
+// The proxy (if any) is converted once its loop has closed the file.
+func (r *Recorder) convertToMP4(h264Filename string, detectionOffset float64, startedAt time.Time, proxy *proxyTrack) {
 	// Ensure converting flag is cleared when done
 	defer func() {
 		r.mu.Lock()
@@ -299,89 +517,270 @@ func (r *Recorder) convertToMP4(h264Filename string, detectionOffset float64) {
 		logger.Info("Recorder", "Post-processing complete, ready for new recording")
 	}()
 
-	h264Path := filepath.Join(r.outputPath, h264Filename)
+	r.mu.Lock()
+	r.convertProgress = 0
+	totalUs := r.lastDuration.Microseconds()
+	r.mu.Unlock()
+
+	r.finalizeRaw(h264Filename, detectionOffset, startedAt, func(us int64) {
+		if totalUs <= 0 {
+			return
+		}
+		progress := float64(us) / float64(totalUs)
+		if progress > 1.0 {
+			progress = 1.0
+		}
+		r.mu.Lock()
+		r.convertProgress = progress
+		r.mu.Unlock()
+	})
+
+	if proxy != nil {
+		<-proxy.done
+		r.mu.RLock()
+		name := proxy.filename
+		r.mu.RUnlock()
+		r.finalizeProxy(name, startedAt)
+	}
+}
+
+// finalizeRaw remuxes a raw stream file to MP4, generates its thumbnail,
+// deletes the raw file and uploads the result to storage. startedAt (if
+// known) is written as the MP4's UTC creation_time. progress (optional)
+// receives ffmpeg's output position in microseconds. Proxy files are
+// handed to finalizeProxy.
+func (r *Recorder) finalizeRaw(h264Filename string, detectionOffset float64, startedAt time.Time, progress func(outUs int64)) {
+	if isProxyName(h264Filename) {
+		r.finalizeProxy(h264Filename, startedAt)
+		return
+	}
+	h264Path := filepath.Join(r.dir(), h264Filename)
 	ext := filepath.Ext(h264Filename)
 	mp4Filename := h264Filename[:len(h264Filename)-len(ext)] + ".mp4"
-	mp4Path := filepath.Join(r.outputPath, mp4Filename)
+	mp4Path := filepath.Join(r.dir(), mp4Filename)
 
 	logger.Info("Recorder", "Starting MP4 conversion: %s -> %s", h264Filename, mp4Filename)
 
-	r.mu.Lock()
-	r.convertProgress = 0
-	totalUs := r.lastDuration.Microseconds()
-	r.mu.Unlock()
+	var metadata []string
+	if _, err := os.Stat(filepath.Join(r.dir(), proxyName(h264Filename))); err == nil {
+		metadata = append(metadata, "comment=proxy: "+proxyName(mp4Filename))
+	}
+	if err := remuxMP4(h264Path, mp4Path, startedAt, metadata, progress); err != nil {
+		logger.Warn("Recorder", "MP4 conversion failed: %v", err)
+		return
+	}
+
+	logger.Info("Recorder", "MP4 conversion complete: %s", mp4Filename)
+
+	// Generate thumbnail at first detection time, or fallback to default
+	r.generateThumbnail(mp4Path, detectionOffset)
 
+	// Checksum for the storage scrubber (see Scrubber)
+	if err := writeChecksum(mp4Path); err != nil {
+		logger.Warn("Recorder", "Failed to write checksum: %v", err)
+	}
+
+	// Delete H.264 file after successful conversion
+	if err := os.Remove(h264Path); err != nil {
+		logger.Warn("Recorder", "Failed to delete H.264 file: %v", err)
+	} else {
+		logger.Info("Recorder", "Deleted H.264 file: %s", h264Filename)
+	}
+
+	if r.isRemote() {
+		r.upload(mp4Path)
+		r.upload(mp4Path[:len(mp4Path)-4] + ".jpg")
+		r.upload(filepath.Join(r.dir(), sidecarName(mp4Filename)))
+		r.upload(filepath.Join(r.dir(), checksumName(mp4Filename)))
+	}
+}
+
+// remuxMP4 copies the raw H.265 stream at rawPath into an MP4 container.
+// metadata holds extra key=value tags.
+func remuxMP4(rawPath, mp4Path string, startedAt time.Time, metadata []string, progress func(outUs int64)) error {
 	// Run ffmpeg with progress reporting to stdout
-	cmd := exec.Command("nice", "-n", "19",
+	args := []string{"-n", "19",
 		"ffmpeg", "-y",
 		"-f", "hevc",
-		"-i", h264Path,
+		"-i", rawPath,
 		"-c", "copy",
-		"-progress", "pipe:1",
-		"-nostats",
-		mp4Path,
-	)
+	}
+	if !startedAt.IsZero() {
+		args = append(args, "-metadata", "creation_time="+startedAt.UTC().Format(time.RFC3339))
+	}
+	for _, kv := range metadata {
+		args = append(args, "-metadata", kv)
+	}
+	args = append(args, "-progress", "pipe:1", "-nostats", mp4Path)
+	cmd := exec.Command("nice", args...)
 	cmd.Stderr = io.Discard
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		logger.Warn("Recorder", "Failed to create stdout pipe: %v", err)
-		return
+		return fmt.Errorf("stdout pipe: %w", err)
 	}
 
 	if err := cmd.Start(); err != nil {
-		logger.Warn("Recorder", "Failed to start ffmpeg: %v", err)
-		return
+		return fmt.Errorf("start ffmpeg: %w", err)
 	}
 
 	// Parse ffmpeg progress lines (out_time_us=<microseconds>)
 	scanner := bufio.NewScanner(stdout)
 	for scanner.Scan() {
 		line := scanner.Text()
-		if strings.HasPrefix(line, "out_time_us=") {
+		if progress != nil && strings.HasPrefix(line, "out_time_us=") {
 			usStr := strings.TrimPrefix(line, "out_time_us=")
-			if us, err := strconv.ParseInt(usStr, 10, 64); err == nil && totalUs > 0 {
-				progress := float64(us) / float64(totalUs)
-				if progress > 1.0 {
-					progress = 1.0
-				}
-				r.mu.Lock()
-				r.convertProgress = progress
-				r.mu.Unlock()
+			if us, err := strconv.ParseInt(usStr, 10, 64); err == nil {
+				progress(us)
 			}
 		}
 	}
 
-	if err := cmd.Wait(); err != nil {
-		logger.Warn("Recorder", "MP4 conversion failed: %v", err)
+	return cmd.Wait()
+}
+
+// Resume starts a new recording to continue one interrupted by a restart.
+// Viewers keeping it alive need time to reconnect, so the first heartbeat
+// may arrive up to grace later instead of HeartbeatTimeout.
+func (r *Recorder) Resume(grace time.Duration, owner RecordingOwner, eventID string) (string, error) {
+	filename, err := r.Start(owner, eventID)
+	if err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	r.lastHeartbeat = time.Now().Add(grace - HeartbeatTimeout)
+	r.mu.Unlock()
+	return filename, nil
+}
+
+// WaitingForKeyframe reports whether the active recording has not yet
+// written its first IDR.
+func (r *Recorder) WaitingForKeyframe() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.recording && r.waitingKeyframe
+}
+
+// ActiveRecording returns the file being recorded, when it started and who
+// started it.
+func (r *Recorder) ActiveRecording() (string, time.Time, RecordingOwner, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if !r.recording {
+		return "", time.Time{}, RecordingOwner{}, false
+	}
+	return r.filename, r.startTime, r.owner, true
+}
+
+// RecoverInterrupted converts raw stream files left behind by a crash or
+// restart (never stopped, so never converted) in the background. The file
+// being recorded right now is skipped.
+func (r *Recorder) RecoverInterrupted() {
+	active, _, _, _ := r.ActiveRecording()
+	raws := interruptedRaws(r.dir(), active)
+	if len(raws) == 0 {
 		return
 	}
+	logger.Info("Recorder", "Recovering %d interrupted recording(s)", len(raws))
+	go func() {
+		for _, name := range raws {
+			r.finalizeRaw(name, -1, time.Time{}, nil)
+		}
+	}()
+}
 
-	logger.Info("Recorder", "MP4 conversion complete: %s", mp4Filename)
+// interruptedRaws lists raw recording files in dir other than active.
+func interruptedRaws(dir, active string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var raws []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || name == active || !strings.HasPrefix(name, "recording_") {
+			continue
+		}
+		if filepath.Ext(name) != ".hevc" {
+			continue
+		}
+		if info, err := e.Info(); err != nil || info.Size() == 0 {
+			continue
+		}
+		raws = append(raws, name)
+	}
+	return raws
+}
 
-	// Generate thumbnail at first detection time, or fallback to default
-	r.generateThumbnail(mp4Path, detectionOffset)
+// uploadJobKind is the outbox job kind of uploads.
+const uploadJobKind = "upload"
 
-	// Delete H.264 file after successful conversion
-	if err := os.Remove(h264Path); err != nil {
-		logger.Warn("Recorder", "Failed to delete H.264 file: %v", err)
-	} else {
-		logger.Info("Recorder", "Deleted H.264 file: %s", h264Filename)
+// uploadJob is the outbox payload of an upload.
+type uploadJob struct {
+	Path string `json:"path"` // local file
+}
+
+// upload moves a finished local file into storage. The local copy is kept if
+// the upload fails so the clip is not lost; with an outbox the upload is
+// retried until it succeeds.
+func (r *Recorder) upload(localPath string) {
+	r.mu.RLock()
+	q := r.outbox
+	r.mu.RUnlock()
+	if q != nil {
+		err := q.Enqueue(uploadJobKind, uploadJob{Path: localPath})
+		if err == nil {
+			return
+		}
+		logger.Warn("Recorder", "Failed to queue upload, trying once: %v", err)
+	}
+	if err := r.uploadFile(localPath); err != nil {
+		logger.Warn("Recorder", "%v", err)
 	}
 }
 
-// generateThumbnail generates a JPG thumbnail from the MP4 file
-// detectionOffset is the preferred timestamp (in seconds), or -1 to use default fallback
-func (r *Recorder) generateThumbnail(mp4Path string, detectionOffset float64) {
-	thumbPath := mp4Path[:len(mp4Path)-4] + ".jpg"
-	logger.Info("Recorder", "Generating thumbnail: %s (detectionOffset=%.2f)", filepath.Base(thumbPath), detectionOffset)
+// uploadFile puts one local file into storage and removes it. A file that
+// is gone (deleted, or uploaded before a restart) is not an error.
+func (r *Recorder) uploadFile(localPath string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return outbox.Permanent(fmt.Errorf("upload skipped: %w", err))
+	}
+	name := filepath.Base(localPath)
+	st := r.Storage()
+	start := time.Now()
+	err = st.Put(name, f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to upload %s to %s: %w", name, st, err)
+	}
+	logger.Info("Recorder", "Uploaded %s to %s in %v", name, st, time.Since(start).Round(time.Millisecond))
+	if err := os.Remove(localPath); err != nil {
+		logger.Warn("Recorder", "Failed to delete uploaded file: %v", err)
+	}
+	return nil
+}
 
-	// Build seek times to try: detection offset (if valid), then 3s, then 0s
-	var seekTimes []string
-	if detectionOffset >= 0 {
-		seekTimes = append(seekTimes, fmt.Sprintf("%.2f", detectionOffset))
+// deliverUpload is the outbox handler of upload jobs.
+func (r *Recorder) deliverUpload(ctx context.Context, payload json.RawMessage) error {
+	var job uploadJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return outbox.Permanent(err)
 	}
-	seekTimes = append(seekTimes, "3", "0")
+	return r.uploadFile(job.Path)
+}
+
+// generateThumbnail generates a JPG thumbnail from the MP4 file, showing the
+// most confident detection in the clip's sidecar so event clips show the pet.
+// detectionOffset (first detection, in seconds, or -1) is used when there is
+// no sidecar; the first IDR (0s) is the last resort.
+func (r *Recorder) generateThumbnail(mp4Path string, detectionOffset float64) {
+	thumbPath := mp4Path[:len(mp4Path)-4] + ".jpg"
+	seekTimes := thumbnailSeekTimes(filepath.Join(filepath.Dir(mp4Path), sidecarName(filepath.Base(mp4Path))), detectionOffset)
+	logger.Info("Recorder", "Generating thumbnail: %s (seek=%v)", filepath.Base(thumbPath), seekTimes)
 
 	for i, seekTime := range seekTimes {
 		cmd := exec.Command("nice", "-n", "19",
@@ -421,6 +820,17 @@ func (r *Recorder) generateThumbnail(mp4Path string, detectionOffset float64) {
 	logger.Warn("Recorder", "Thumbnail generation failed for: %s", filepath.Base(mp4Path))
 }
 
+// thumbnailSeekTimes returns the offsets to try for a thumbnail, best first.
+func thumbnailSeekTimes(sidecarPath string, detectionOffset float64) []string {
+	var seekTimes []string
+	if best, ok := bestDetectionOffset(sidecarPath); ok {
+		seekTimes = append(seekTimes, fmt.Sprintf("%.2f", best))
+	} else if detectionOffset >= 0 {
+		seekTimes = append(seekTimes, fmt.Sprintf("%.2f", detectionOffset))
+	}
+	return append(seekTimes, "0")
+}
+
 // RegenerateThumbnail regenerates thumbnail at specified timestamp
 func (r *Recorder) RegenerateThumbnail(filename string, timestamp float64) error {
 	// Validate filename
@@ -431,6 +841,9 @@ func (r *Recorder) RegenerateThumbnail(filename string, timestamp float64) error
 	if !strings.HasSuffix(filename, ".mp4") {
 		return fmt.Errorf("only mp4 files supported")
 	}
+	if r.isRemote() {
+		return fmt.Errorf("thumbnail regeneration not supported for %s", r.Storage())
+	}
 
 	thumbPath := mp4Path[:len(mp4Path)-4] + ".jpg"
 
@@ -463,8 +876,11 @@ func (r *Recorder) autoStop(reason string) {
 	r.lastDuration = time.Since(r.startTime)
 	r.stopReason = reason
 	r.recording = false
+	close(r.stopCh) // stops the proxy loop
 	filename := r.filename
 	detectionOffset := r.firstDetectionOffset
+	wallStart := r.wallStart
+	proxy := r.proxy
 	r.mu.Unlock()
 
 	// Close file
@@ -474,10 +890,12 @@ func (r *Recorder) autoStop(reason string) {
 		r.file.Close()
 		r.file = nil
 	}
+	r.closeSidecarLocked()
 	if r.shmReader != nil {
 		r.shmReader.Close()
 		r.shmReader = nil
 	}
+	r.indexStoppedLocked(filename)
 	r.mu.Unlock()
 
 	logger.Info("Recorder", "Auto-stopped recording: %s (reason=%s)", filename, reason)
@@ -486,7 +904,7 @@ func (r *Recorder) autoStop(reason string) {
 	r.mu.Lock()
 	r.converting = true
 	r.mu.Unlock()
-	go r.convertToMP4(filename, detectionOffset)
+	go r.convertToMP4(filename, detectionOffset, wallStart, proxy)
 }
 
 // Heartbeat updates the last heartbeat time to prevent auto-stop
@@ -541,6 +959,12 @@ func (r *Recorder) Status() map[string]any {
 		duration = r.lastDuration
 	}
 
+	var proxy string
+	var proxyBytes uint64
+	if r.proxy != nil {
+		proxy, proxyBytes = r.proxy.filename, r.proxy.bytes.Load()
+	}
+
 	return map[string]any{
 		"recording":        r.recording,
 		"converting":       r.converting,
@@ -550,61 +974,103 @@ func (r *Recorder) Status() map[string]any {
 		"bytes_written":    r.bytesWritten,
 		"duration_ms":      duration.Milliseconds(),
 		"stop_reason":      r.stopReason,
+
+		"waiting_for_keyframe": r.recording && r.waitingKeyframe,
+		"skipped_frames":       r.skippedFrames,
+		"started_at_utc":       r.wallStart.UTC().Format(time.RFC3339),
+		"clock_jump_sec":       r.clockJump.Seconds(),
+		"owner":                r.owner,
+		"event_id":             r.eventID,
+		"proxy":                proxy,
+		"proxy_bytes_written":  proxyBytes,
+		"lighting":             recordingLighting(r.dayFrames, r.nightFrames),
+		"stop_trim":            r.stopTrim,
+		"stopping":             r.finishing != nil,
+	}
+}
+
+// ClockJumped corrects the active recording after a wall-clock step (e.g.
+// NTP sync after boot): the start time is recomputed from the monotonic
+// elapsed time and the raw file is renamed to match, so the clip is listed
+// under the time it was really recorded.
+func (r *Recorder) ClockJumped(j clock.Jump) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.recording {
+		return
+	}
+
+	// time.Since uses the monotonic reading, which the jump did not move
+	r.wallStart = time.Now().Round(0).Add(-time.Since(r.startTime))
+	r.clockJump += j.Delta
+
+	name := fmt.Sprintf("recording_%s%s", clock.FileStamp(r.wallStart), filepath.Ext(r.filename))
+	if name == r.filename {
+		return
+	}
+	// The open file keeps writing to the same inode after the rename
+	if err := os.Rename(filepath.Join(r.dir(), r.filename), filepath.Join(r.dir(), name)); err != nil {
+		logger.Warn("Recorder", "Failed to rename %s after clock jump: %v", r.filename, err)
+		return
+	}
+	// The sidecar is also open; it follows its recording the same way
+	if err := os.Rename(filepath.Join(r.dir(), sidecarName(r.filename)), filepath.Join(r.dir(), sidecarName(name))); err != nil && !os.IsNotExist(err) {
+		logger.Warn("Recorder", "Failed to rename detection sidecar after clock jump: %v", err)
+	}
+	if r.proxy != nil {
+		if err := os.Rename(filepath.Join(r.dir(), r.proxy.filename), filepath.Join(r.dir(), proxyName(name))); err != nil {
+			logger.Warn("Recorder", "Failed to rename proxy after clock jump: %v", err)
+		} else {
+			r.proxy.filename = proxyName(name)
+		}
 	}
+	logger.Info("Recorder", "Clock jump %+v: renamed %s to %s", j.Delta, r.filename, name)
+	r.filename = name
 }
 
 // ListRecordings returns a list of recording files
 func (r *Recorder) ListRecordings() ([]RecordingInfo, error) {
-	entries, err := os.ReadDir(r.outputPath)
+	files, err := r.Storage().List()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return []RecordingInfo{}, nil
-		}
 		return nil, err
 	}
 
-	// First pass: collect thumbnail files
+	// First pass: collect thumbnail and proxy files
 	thumbnails := make(map[string]bool)
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-		name := entry.Name()
-		if strings.HasSuffix(name, ".jpg") {
-			thumbnails[name] = true
+	proxies := make(map[string]bool)
+	for _, f := range files {
+		if strings.HasSuffix(f.Name, ".jpg") {
+			thumbnails[f.Name] = true
+		} else if isProxyName(f.Name) {
+			proxies[f.Name] = true
 		}
 	}
 
-	var recordings []RecordingInfo
+	recordings := []RecordingInfo{}
 	var missingThumbnails []string
+	remote := r.isRemote()
 
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-
-		name := entry.Name()
+	for _, f := range files {
+		name := f.Name
 		ext := filepath.Ext(name)
-		if ext != ".mp4" && ext != ".hevc" && ext != ".h264" {
-			continue
-		}
-
-		info, err := entry.Info()
-		if err != nil {
+		if ext != ".mp4" && ext != ".hevc" && ext != ".h264" || isProxyName(name) {
 			continue
 		}
 
 		rec := RecordingInfo{
 			Name:      name,
-			SizeBytes: info.Size(),
-			CreatedAt: info.ModTime(),
+			SizeBytes: f.Size,
+			CreatedAt: f.ModTime,
+		}
+		if proxies[proxyName(name)] {
+			rec.Proxy = proxyName(name)
 		}
 
 		// Check for corresponding thumbnail
 		thumbName := name[:len(name)-len(ext)] + ".jpg"
 		if thumbnails[thumbName] {
 			rec.Thumbnail = thumbName
-		} else if ext == ".mp4" {
+		} else if ext == ".mp4" && !remote {
 			// MP4 without thumbnail - queue for generation
 			missingThumbnails = append(missingThumbnails, name)
 		}
@@ -612,6 +1078,9 @@ func (r *Recorder) ListRecordings() ([]RecordingInfo, error) {
 		recordings = append(recordings, rec)
 	}
 
+	r.annotateLighting(recordings)
+	r.annotateClipStats(recordings)
+
 	// Generate missing thumbnails in background
 	if len(missingThumbnails) > 0 {
 		go r.generateMissingThumbnails(missingThumbnails)
@@ -623,7 +1092,7 @@ func (r *Recorder) ListRecordings() ([]RecordingInfo, error) {
 // generateMissingThumbnails generates thumbnails for MP4 files that don't have them
 func (r *Recorder) generateMissingThumbnails(filenames []string) {
 	for _, filename := range filenames {
-		mp4Path := filepath.Join(r.outputPath, filename)
+		mp4Path := filepath.Join(r.recordingDir(filename), filename)
 		thumbPath := mp4Path[:len(mp4Path)-4] + ".jpg"
 
 		// Double-check thumbnail doesn't exist (avoid race condition)
@@ -636,15 +1105,19 @@ func (r *Recorder) generateMissingThumbnails(filenames []string) {
 	}
 }
 
-// GetRecordingPath returns the full path to a recording file
+// GetRecordingPath returns the full path to a recording file. Fails for
+// remote storage; use OpenRecording instead.
 func (r *Recorder) GetRecordingPath(filename string) (string, error) {
 	// Sanitize filename to prevent directory traversal
 	cleanName := filepath.Base(filename)
 	if cleanName != filename {
 		return "", fmt.Errorf("invalid filename")
 	}
+	if r.isRemote() {
+		return "", fmt.Errorf("recording %s is in %s", filename, r.Storage())
+	}
 
-	fullPath := filepath.Join(r.outputPath, cleanName)
+	fullPath := filepath.Join(r.recordingDir(cleanName), cleanName)
 
 	// Check if file exists
 	if _, err := os.Stat(fullPath); err != nil {
@@ -654,29 +1127,56 @@ func (r *Recorder) GetRecordingPath(filename string) (string, error) {
 	return fullPath, nil
 }
 
-// DeleteRecording deletes a recording file and its corresponding thumbnail
-func (r *Recorder) DeleteRecording(filename string) error {
-	path, err := r.GetRecordingPath(filename)
+// OpenRecording opens a recording from storage for streaming.
+func (r *Recorder) OpenRecording(filename string) (io.ReadCloser, storage.FileInfo, error) {
+	st := r.Storage()
+	info, err := st.Stat(filename)
 	if err != nil {
-		return err
+		return nil, storage.FileInfo{}, fmt.Errorf("recording not found: %s", filename)
+	}
+	rc, err := st.Open(filename)
+	if err != nil {
+		return nil, storage.FileInfo{}, err
 	}
+	return rc, info, nil
+}
 
-	// Delete the recording file
-	if err := os.Remove(path); err != nil {
+// DeleteRecording deletes a recording file and its corresponding thumbnail
+func (r *Recorder) DeleteRecording(filename string) error {
+	if filepath.Base(filename) != filename {
+		return fmt.Errorf("invalid filename")
+	}
+	st := r.Storage()
+	if err := st.Remove(filename); err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			return fmt.Errorf("recording not found: %s", filename)
+		}
 		return err
 	}
 
 	// Also delete corresponding thumbnail if it exists
 	ext := filepath.Ext(filename)
 	if ext == ".mp4" || ext == ".hevc" || ext == ".h264" {
-		thumbPath := path[:len(path)-len(ext)] + ".jpg"
-		if _, err := os.Stat(thumbPath); err == nil {
-			if err := os.Remove(thumbPath); err != nil {
-				logger.Warn("Recorder", "Failed to delete thumbnail: %v", err)
-			} else {
-				logger.Info("Recorder", "Deleted thumbnail: %s", filepath.Base(thumbPath))
+		thumbName := filename[:len(filename)-len(ext)] + ".jpg"
+		if err := st.Remove(thumbName); err == nil {
+			logger.Info("Recorder", "Deleted thumbnail: %s", thumbName)
+		} else if !errors.Is(err, storage.ErrNotExist) {
+			logger.Warn("Recorder", "Failed to delete thumbnail: %v", err)
+		}
+		if err := st.Remove(sidecarName(filename)); err != nil && !errors.Is(err, storage.ErrNotExist) {
+			logger.Warn("Recorder", "Failed to delete detection sidecar: %v", err)
+		}
+		if err := st.Remove(checksumName(filename)); err != nil && !errors.Is(err, storage.ErrNotExist) {
+			logger.Warn("Recorder", "Failed to delete checksum: %v", err)
+		}
+		for _, name := range []string{proxyName(filename), checksumName(proxyName(filename))} {
+			if err := st.Remove(name); err != nil && !errors.Is(err, storage.ErrNotExist) {
+				logger.Warn("Recorder", "Failed to delete proxy: %v", err)
 			}
 		}
+		r.updateLightingIndex(filename, "")
+		updateIndex(r, statsIndexFile, filename, clipStats{}, true)
+		r.removeClips(filename)
 	}
 
 	return nil
@@ -688,4 +1188,18 @@ type RecordingInfo struct {
 	SizeBytes int64     `json:"size_bytes"`
 	CreatedAt time.Time `json:"created_at"`
 	Thumbnail string    `json:"thumbnail,omitempty"`
+	Proxy     string    `json:"proxy,omitempty"`    // low-res copy, downloaded with ?quality=proxy
+	Lighting  Lighting  `json:"lighting,omitempty"` // day, night or mixed; unset for clips recorded before tagging
+	EventID   string    `json:"event_id,omitempty"` // correlation ID of what started it (see Event.EventID)
+	Cut       *CutPoint `json:"cut,omitempty"`      // where it stopped relative to its last GOP
+
+	// Set when an external trigger started it (see POST /api/triggers/fire)
+	TriggerName string `json:"trigger_name,omitempty"`
+	TriggerVia  string `json:"trigger_via,omitempty"` // http or mqtt
+
+	Path string `json:"path,omitempty"` // recording path it landed on (see RecordingPaths)
+
+	// Set when the storage scrubber found the file unplayable or changed
+	Corrupt    bool   `json:"corrupt,omitempty"`
+	ScrubError string `json:"scrub_error,omitempty"`
 }
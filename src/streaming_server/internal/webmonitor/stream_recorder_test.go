@@ -0,0 +1,67 @@
+package webmonitor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/storage"
+)
+
+func TestRecorder_RemoteStorage(t *testing.T) {
+	scratch := t.TempDir()
+	remote := storage.NewLocal(t.TempDir()) // stands in for a NAS mount
+
+	r := NewRecorder(scratch, "/unused")
+	if r.isRemote() {
+		t.Fatal("default storage should be the output directory")
+	}
+	r.SetStorage(storage.NewLocal(scratch))
+	if r.isRemote() {
+		t.Fatal("same directory should not be remote")
+	}
+	r.SetStorage(remote)
+	if !r.isRemote() {
+		t.Fatal("different directory should be remote")
+	}
+
+	for _, name := range []string{"recording_1.mp4", "recording_1.jpg"} {
+		path := filepath.Join(scratch, name)
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		r.upload(path)
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s not removed from scratch after upload", name)
+		}
+	}
+
+	recs, err := r.ListRecordings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 1 || recs[0].Name != "recording_1.mp4" || recs[0].Thumbnail != "recording_1.jpg" {
+		t.Fatalf("ListRecordings = %+v", recs)
+	}
+	if _, err := r.GetRecordingPath("recording_1.mp4"); err == nil {
+		t.Error("GetRecordingPath should fail for remote storage")
+	}
+	rc, info, err := r.OpenRecording("recording_1.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc.Close()
+	if info.Size != int64(len("recording_1.mp4")) {
+		t.Errorf("size = %d", info.Size)
+	}
+
+	if err := r.DeleteRecording("recording_1.mp4"); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := remote.List(); len(files) != 0 {
+		t.Errorf("files left after delete: %+v", files)
+	}
+	if err := r.DeleteRecording("recording_1.mp4"); err == nil {
+		t.Error("deleting a missing recording should fail")
+	}
+}
@@ -0,0 +1,275 @@
+package webmonitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/degrade"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/timeseries"
+)
+
+const (
+	metricsSampleInterval = 10 * time.Second // the finest timeseries tier
+	metricsSaveInterval   = 10 * time.Minute // the history is ~150 KB per series: not every state save
+	maxTimeseriesRange    = 30 * 24 * time.Hour
+)
+
+// Series recorded by MetricsSampler.
+const (
+	seriesFPS          = "fps"                  // camera frames per second (frame SHM)
+	seriesCPU          = "cpu_percent"          // system CPU busy percent
+	seriesViewers      = "viewers"              // MJPEG streams + WebRTC sessions
+	seriesMJPEGKbps    = "mjpeg_kbps"           // all MJPEG streams
+	seriesMJPEGEncode  = "mjpeg_encode_ms"      // only with adaptive MJPEG quality
+	seriesWebRTCKbps   = "webrtc_kbps"          // all WebRTC sessions
+	seriesWebRTCJitter = "webrtc_jitter_ms"     // mean RTCP jitter: the latency viewers see
+	seriesWebRTCDrops  = "webrtc_drops_per_sec" // frames dropped by all WebRTC sessions
+)
+
+// metricsReading is one sampling tick's raw input; counters are cumulative.
+type metricsReading struct {
+	frames       int
+	mjpegBytes   uint64
+	mjpegStreams int
+	encodeMs     float64              // <0 without adaptive MJPEG quality
+	sessions     []WebRTCSessionStats // nil when the WebRTC server is unreachable
+	cpu          float64
+	cpuOK        bool
+}
+
+// MetricsSampler turns periodic readings into the on-device metric history
+// served by /api/timeseries, for installations without Prometheus. Counters
+// become rates between consecutive readings; the store is saved every
+// metricsSaveInterval and on Stop.
+type MetricsSampler struct {
+	store *timeseries.Store
+	path  string // "" = not persisted
+	read  func() metricsReading
+	cpu   *degrade.CPUMeter
+
+	prev      metricsReading
+	prevAt    time.Time
+	prevDrops map[string]uint64 // per WebRTC session; nil = no previous reading
+
+	mu      sync.Mutex
+	stop    chan struct{}
+	done    chan struct{}
+	stopped bool
+}
+
+// NewMetricsSampler creates a sampler recording into store.
+func NewMetricsSampler(store *timeseries.Store, path string, read func() metricsReading) *MetricsSampler {
+	return &MetricsSampler{
+		store: store,
+		path:  path,
+		read:  read,
+		cpu:   degrade.NewCPUMeter(),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+}
+
+// Start begins sampling.
+func (ms *MetricsSampler) Start() {
+	go ms.run()
+}
+
+// Stop halts sampling and saves the history.
+func (ms *MetricsSampler) Stop() {
+	ms.mu.Lock()
+	if !ms.stopped {
+		close(ms.stop)
+		ms.stopped = true
+	}
+	ms.mu.Unlock()
+	<-ms.done
+}
+
+func (ms *MetricsSampler) run() {
+	defer close(ms.done)
+	defer ms.save()
+	ticker := time.NewTicker(metricsSampleInterval)
+	defer ticker.Stop()
+	lastSave := time.Now()
+	for {
+		select {
+		case <-ms.stop:
+			return
+		case now := <-ticker.C:
+			r := ms.read()
+			r.cpu, r.cpuOK = ms.cpu.Sample()
+			ms.record(now, r)
+			if now.Sub(lastSave) >= metricsSaveInterval {
+				ms.save()
+				lastSave = now
+			}
+		}
+	}
+}
+
+func (ms *MetricsSampler) save() {
+	if ms.path == "" {
+		return
+	}
+	if err := ms.store.Save(ms.path); err != nil {
+		logger.Warn("Timeseries", "Failed to save metric history: %v", err)
+	}
+}
+
+// record adds reading r taken at now. Rates need a previous reading and are
+// skipped when a counter went backwards (camera or server restart).
+func (ms *MetricsSampler) record(now time.Time, r metricsReading) {
+	if dt := now.Sub(ms.prevAt).Seconds(); !ms.prevAt.IsZero() && dt > 0 {
+		if r.frames >= ms.prev.frames {
+			ms.store.Add(seriesFPS, now, float64(r.frames-ms.prev.frames)/dt)
+		}
+		if r.mjpegBytes >= ms.prev.mjpegBytes {
+			ms.store.Add(seriesMJPEGKbps, now, float64(r.mjpegBytes-ms.prev.mjpegBytes)*8/1000/dt)
+		}
+		if r.sessions != nil && ms.prevDrops != nil {
+			var drops uint64
+			for _, sess := range r.sessions {
+				if prev, ok := ms.prevDrops[sess.ID]; ok && sess.FramesDropped >= prev {
+					drops += sess.FramesDropped - prev
+				}
+			}
+			ms.store.Add(seriesWebRTCDrops, now, float64(drops)/dt)
+		}
+	}
+	if r.cpuOK {
+		ms.store.Add(seriesCPU, now, r.cpu)
+	}
+	if r.encodeMs >= 0 {
+		ms.store.Add(seriesMJPEGEncode, now, r.encodeMs)
+	}
+	viewers := r.mjpegStreams
+	ms.prevDrops = nil
+	if r.sessions != nil {
+		var kbps, jitter float64
+		ms.prevDrops = make(map[string]uint64, len(r.sessions))
+		for _, sess := range r.sessions {
+			kbps += sess.BitrateKbps
+			jitter += sess.JitterMs
+			ms.prevDrops[sess.ID] = sess.FramesDropped
+		}
+		ms.store.Add(seriesWebRTCKbps, now, kbps)
+		if len(r.sessions) > 0 {
+			ms.store.Add(seriesWebRTCJitter, now, jitter/float64(len(r.sessions)))
+		}
+		viewers += len(r.sessions)
+	}
+	ms.store.Add(seriesViewers, now, float64(viewers))
+	ms.prev, ms.prevAt = r, now
+}
+
+// readMetrics takes a metricsReading from the monitor, the MJPEG stream
+// accounting and the WebRTC server.
+func (s *Server) readMetrics() metricsReading {
+	stats, _, _, _ := s.monitor.Snapshot()
+	r := metricsReading{
+		frames:       stats.FramesProcessed,
+		mjpegBytes:   s.mjpegAccounting.BytesTotal(),
+		mjpegStreams: s.broadcaster.GetClientCount(),
+		encodeMs:     -1,
+	}
+	if s.broadcaster.quality != nil {
+		r.encodeMs = s.broadcaster.quality.Status().EncodeMs
+	}
+	if sessions, err := fetchWebRTCSessions(s.connectionBroadcaster.webrtcStatsURL); err == nil {
+		r.sessions = sessions
+	} else {
+		logger.Debug("Timeseries", "Failed to fetch WebRTC stats: %v", err)
+	}
+	return r
+}
+
+// fetchWebRTCSessions returns the WebRTC server's sessions (never nil on
+// success).
+func fetchWebRTCSessions(url string) ([]WebRTCSessionStats, error) {
+	if url == "" {
+		return nil, fmt.Errorf("no WebRTC stats URL")
+	}
+	client := &http.Client{Timeout: 500 * time.Millisecond}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	var result struct {
+		Sessions []WebRTCSessionStats `json:"sessions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if result.Sessions == nil {
+		result.Sessions = []WebRTCSessionStats{}
+	}
+	return result.Sessions, nil
+}
+
+// parseTimeseriesRange accepts a Go duration or whole days ("7d").
+func parseTimeseriesRange(v string) (time.Duration, error) {
+	var d time.Duration
+	if days, ok := strings.CutSuffix(v, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid range %q", v)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(v); err != nil {
+			return 0, fmt.Errorf("invalid range %q", v)
+		}
+	}
+	if d <= 0 || d > maxTimeseriesRange {
+		return 0, fmt.Errorf("range must be between 0 and %v", maxTimeseriesRange)
+	}
+	return d, nil
+}
+
+// handleTimeseries serves GET /api/timeseries?metric=fps&range=24h: the
+// metric's history over the last range (default 1h) at the finest
+// resolution kept that far back. Without metric it lists the metrics.
+func (s *Server) handleTimeseries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	metric := q.Get("metric")
+	if metric == "" {
+		writeJSON(w, map[string]any{"metrics": s.timeseries.Names()})
+		return
+	}
+	rng := time.Hour
+	if v := q.Get("range"); v != "" {
+		var err error
+		if rng, err = parseTimeseriesRange(v); err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	to := time.Now()
+	points, step, ok := s.timeseries.Query(metric, to.Add(-rng), to)
+	if !ok {
+		writeJSONWithStatus(w, map[string]any{"error": "unknown metric: " + metric}, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]any{
+		"metric":   metric,
+		"from":     to.Add(-rng).Unix(),
+		"to":       to.Unix(),
+		"step_sec": int(step / time.Second),
+		"points":   points,
+	})
+}
@@ -0,0 +1,89 @@
+package webmonitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/timeseries"
+)
+
+func TestMetricsSamplerRates(t *testing.T) {
+	store := timeseries.New(timeseries.Tier{Step: 10 * time.Second, Len: 6})
+	ms := NewMetricsSampler(store, "", nil)
+	base := time.Unix(1_800_000_000, 0)
+
+	ms.record(base, metricsReading{frames: 100, mjpegBytes: 0, encodeMs: -1,
+		sessions: []WebRTCSessionStats{{ID: "a", FramesDropped: 5, BitrateKbps: 800, JitterMs: 4}}})
+	ms.record(base.Add(10*time.Second), metricsReading{frames: 400, mjpegBytes: 125_000, mjpegStreams: 1, encodeMs: 12,
+		sessions: []WebRTCSessionStats{
+			{ID: "a", FramesDropped: 25, BitrateKbps: 1000, JitterMs: 6},
+			{ID: "b", FramesDropped: 90, BitrateKbps: 500, JitterMs: 10}, // new session: no drop rate yet
+		}})
+	// Camera restart: the frame counter went back, no fps sample
+	ms.record(base.Add(20*time.Second), metricsReading{frames: 3, mjpegBytes: 125_000, encodeMs: -1, cpu: 40, cpuOK: true})
+
+	want := map[string][]float64{ // per bucket
+		seriesFPS:          {30},
+		seriesMJPEGKbps:    {100, 0},
+		seriesWebRTCDrops:  {2},
+		seriesWebRTCKbps:   {800, 1500},
+		seriesWebRTCJitter: {4, 8},
+		seriesMJPEGEncode:  {12},
+		seriesCPU:          {40},
+		seriesViewers:      {1, 3, 0},
+	}
+	for name, values := range want {
+		points, _, ok := store.Query(name, base, base.Add(time.Minute))
+		if !ok || len(points) != len(values) {
+			t.Errorf("%s: %+v, want %v", name, points, values)
+			continue
+		}
+		for i, p := range points {
+			if p.Avg != values[i] {
+				t.Errorf("%s[%d] = %v, want %v", name, i, p.Avg, values[i])
+			}
+		}
+	}
+	if names := store.Names(); len(names) != len(want) {
+		t.Errorf("series %v", names)
+	}
+}
+
+func TestHandleTimeseries(t *testing.T) {
+	s := &Server{timeseries: timeseries.New()}
+	s.timeseries.Add(seriesFPS, time.Now().Add(-2*time.Hour), 15)
+	s.timeseries.Add(seriesFPS, time.Now(), 30)
+
+	get := func(query string) (int, map[string]any) {
+		rec := httptest.NewRecorder()
+		s.handleTimeseries(rec, httptest.NewRequest(http.MethodGet, "/api/timeseries"+query, nil))
+		var body map[string]any
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		return rec.Code, body
+	}
+
+	if code, body := get(""); code != http.StatusOK || len(body["metrics"].([]any)) != 1 {
+		t.Fatalf("list: %d %v", code, body)
+	}
+	code, body := get("?metric=fps")
+	if code != http.StatusOK || body["step_sec"] != 10.0 || len(body["points"].([]any)) != 1 {
+		t.Fatalf("1h: %d %v", code, body)
+	}
+	code, body = get("?metric=fps&range=1d")
+	if code != http.StatusOK || body["step_sec"] != 60.0 || len(body["points"].([]any)) != 2 {
+		t.Fatalf("1d: %d %v", code, body)
+	}
+	for query, want := range map[string]int{
+		"?metric=nope":          http.StatusNotFound,
+		"?metric=fps&range=x":   http.StatusBadRequest,
+		"?metric=fps&range=90d": http.StatusBadRequest,
+		"?metric=fps&range=-1h": http.StatusBadRequest,
+	} {
+		if code, _ := get(query); code != want {
+			t.Errorf("%s: %d, want %d", query, code, want)
+		}
+	}
+}
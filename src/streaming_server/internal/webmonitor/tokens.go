@@ -0,0 +1,634 @@
+package webmonitor
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+)
+
+// Bounds on the access tokens an admin can issue.
+const (
+	maxAccessTokens = 32
+	maxTokenName    = 64
+	tokenSecretSize = 24
+)
+
+// tokenCookie carries the token of a browser that opened /?token=..., so
+// the page's fetches, event streams and <img src="/stream"> send it too.
+const tokenCookie = "petcam_token"
+
+// webrtcGrantGrace is how long a WebRTC session counts against its token
+// before the streaming server has to list it.
+const webrtcGrantGrace = 15 * time.Second
+
+// AccessToken is a named read-only credential, e.g. for a neighbor who feeds
+// the cat. Once any token exists, the monitor requires one (or the admin
+// token) for everything but the page itself.
+type AccessToken struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Hash       string     `json:"hash"` // hex SHA-256 of the secret, which is only shown once
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	MaxStreams int        `json:"max_streams,omitempty"`  // concurrent MJPEG + WebRTC streams (0 = unlimited)
+	RatePerMin int        `json:"rate_per_min,omitempty"` // requests per minute (0 = unlimited)
+	Locale     string     `json:"locale,omitempty"`       // of user-facing strings served to it ("" = -locale)
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Validate checks the limits and the name.
+func (t *AccessToken) Validate() error {
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" || len(t.Name) > maxTokenName {
+		return fmt.Errorf("name must be 1-%d bytes", maxTokenName)
+	}
+	if t.MaxStreams < 0 || t.RatePerMin < 0 {
+		return errors.New("max_streams and rate_per_min must not be negative")
+	}
+	if t.Locale != "" && catalog[t.Locale] == nil {
+		return fmt.Errorf("unknown locale %q (%v)", t.Locale, locales())
+	}
+	return nil
+}
+
+func (t *AccessToken) expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// TokenStatus is a token as served by /api/tokens, without its hash.
+type TokenStatus struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	Expired    bool       `json:"expired"`
+	MaxStreams int        `json:"max_streams"`
+	RatePerMin int        `json:"rate_per_min"`
+	Locale     string     `json:"locale,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Streams    int        `json:"streams"`             // open right now
+	LastUsed   float64    `json:"last_used,omitempty"` // unix seconds, since startup
+}
+
+// tokenUsage is the runtime state of one token. Not persisted.
+type tokenUsage struct {
+	allowance  float64 // requests the rate limit still allows
+	refilledAt time.Time
+	lastUsed   time.Time
+	mjpeg      map[string]int // open /stream responses by browser session
+	cancels    map[uint64]context.CancelFunc
+}
+
+// webrtcGrant is a WebRTC session opened with a token.
+type webrtcGrant struct {
+	token string
+	at    time.Time
+}
+
+// TokenStore holds the access tokens, persisted to doc (the zero Doc = in
+// memory), and enforces their stream and rate limits.
+type TokenStore struct {
+	doc kv.Doc
+
+	mu     sync.Mutex
+	tokens []AccessToken
+	usage  map[string]*tokenUsage
+	webrtc map[string]webrtcGrant // streaming server session ID → grant
+	nextID uint64
+
+	// live lists the WebRTC sessions the streaming server still has (nil
+	// when it cannot tell)
+	live func() map[string]bool
+}
+
+// NewTokenStore creates an empty store persisted to doc.
+func NewTokenStore(doc kv.Doc) *TokenStore {
+	return &TokenStore{
+		doc:    doc,
+		usage:  make(map[string]*tokenUsage),
+		webrtc: make(map[string]webrtcGrant),
+	}
+}
+
+// SetLiveSessions sets how the store learns which WebRTC sessions are open.
+func (ts *TokenStore) SetLiveSessions(fn func() map[string]bool) {
+	ts.live = fn
+}
+
+// Load reads persisted tokens, replacing the current ones; streams opened
+// with a token that is gone are closed. Without a document there are none.
+func (ts *TokenStore) Load() error {
+	var tokens []AccessToken
+	if _, err := ts.doc.Load(&tokens); err != nil {
+		return err
+	}
+	for i := range tokens {
+		if err := tokens[i].Validate(); err != nil {
+			return fmt.Errorf("token %q: %w", tokens[i].ID, err)
+		}
+		if len(tokens[i].Hash) != sha256.Size*2 {
+			return fmt.Errorf("token %q: invalid hash", tokens[i].ID)
+		}
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.tokens = tokens
+	for id := range ts.usage {
+		if !slices.ContainsFunc(tokens, func(t AccessToken) bool { return t.ID == id }) {
+			ts.revokeLocked(id)
+		}
+	}
+	return nil
+}
+
+// saveLocked persists the tokens; only their hashes are stored.
+func (ts *TokenStore) saveLocked() error {
+	return ts.doc.Save(ts.tokens)
+}
+
+// Enabled reports whether any token exists, i.e. whether access is
+// restricted.
+func (ts *TokenStore) Enabled() bool {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return len(ts.tokens) > 0
+}
+
+func hashTokenSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Lookup returns the token whose secret this is, expired or not.
+func (ts *TokenStore) Lookup(secret string) (AccessToken, bool) {
+	hash := hashTokenSecret(secret)
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	for _, t := range ts.tokens {
+		if subtle.ConstantTimeCompare([]byte(t.Hash), []byte(hash)) == 1 {
+			return t, true
+		}
+	}
+	return AccessToken{}, false
+}
+
+var (
+	errTooManyTokens = fmt.Errorf("at most %d tokens", maxAccessTokens)
+	errTokenNotFound = errors.New("not found")
+)
+
+// Create adds a token and returns it with its secret, which is not stored.
+func (ts *TokenStore) Create(t AccessToken, now time.Time) (AccessToken, string, error) {
+	if err := t.Validate(); err != nil {
+		return AccessToken{}, "", err
+	}
+	var id [6]byte
+	secret := make([]byte, tokenSecretSize)
+	if _, err := rand.Read(id[:]); err != nil {
+		return AccessToken{}, "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return AccessToken{}, "", err
+	}
+	t.ID = hex.EncodeToString(id[:])
+	t.CreatedAt = now.Truncate(time.Second)
+	s := base64.RawURLEncoding.EncodeToString(secret)
+	t.Hash = hashTokenSecret(s)
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if len(ts.tokens) >= maxAccessTokens {
+		return AccessToken{}, "", errTooManyTokens
+	}
+	ts.tokens = append(ts.tokens, t)
+	return t, s, ts.saveLocked()
+}
+
+// Update replaces the name, expiry and limits of a token; its secret stays.
+// Streams already open are closed if the token is now expired.
+func (ts *TokenStore) Update(id string, t AccessToken, now time.Time) (AccessToken, error) {
+	if err := t.Validate(); err != nil {
+		return AccessToken{}, err
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	i := slices.IndexFunc(ts.tokens, func(x AccessToken) bool { return x.ID == id })
+	if i < 0 {
+		return AccessToken{}, errTokenNotFound
+	}
+	t.ID, t.Hash, t.CreatedAt = id, ts.tokens[i].Hash, ts.tokens[i].CreatedAt
+	ts.tokens[i] = t
+	if t.expired(now) {
+		ts.revokeLocked(id)
+	}
+	return t, ts.saveLocked()
+}
+
+// Delete removes a token and closes its MJPEG streams. Returns false if it
+// does not exist.
+func (ts *TokenStore) Delete(id string) (bool, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	i := slices.IndexFunc(ts.tokens, func(x AccessToken) bool { return x.ID == id })
+	if i < 0 {
+		return false, nil
+	}
+	ts.tokens = slices.Delete(ts.tokens, i, i+1)
+	ts.revokeLocked(id)
+	return true, ts.saveLocked()
+}
+
+// revokeLocked closes the token's MJPEG streams and forgets its usage.
+// WebRTC sessions run until the browser leaves.
+func (ts *TokenStore) revokeLocked(id string) {
+	if u := ts.usage[id]; u != nil {
+		for _, cancel := range u.cancels {
+			cancel()
+		}
+	}
+	delete(ts.usage, id)
+}
+
+func (ts *TokenStore) usageLocked(id string) *tokenUsage {
+	u := ts.usage[id]
+	if u == nil {
+		u = &tokenUsage{mjpeg: make(map[string]int), cancels: make(map[uint64]context.CancelFunc)}
+		ts.usage[id] = u
+	}
+	return u
+}
+
+// Allow takes one request from the token's rate limit. When it is used up,
+// Allow returns false and how long until the next request is allowed. The
+// budget refills continuously and holds at most a minute's worth.
+func (ts *TokenStore) Allow(t AccessToken, now time.Time) (bool, time.Duration) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	u := ts.usageLocked(t.ID)
+	u.lastUsed = now
+	if t.RatePerMin <= 0 {
+		return true, 0
+	}
+	limit := float64(t.RatePerMin)
+	if u.refilledAt.IsZero() {
+		u.allowance = limit
+	} else {
+		u.allowance = min(limit, u.allowance+now.Sub(u.refilledAt).Minutes()*limit)
+	}
+	u.refilledAt = now
+	if u.allowance < 1 {
+		return false, time.Duration((1 - u.allowance) / limit * float64(time.Minute))
+	}
+	u.allowance--
+	return true, 0
+}
+
+// streamsLocked counts the token's open streams, leaving out the MJPEG
+// streams of session (they are replaced by the new stream). live is the
+// streaming server's session list or nil.
+func (ts *TokenStore) streamsLocked(id, session string, live map[string]bool, now time.Time) int {
+	n := 0
+	if u := ts.usage[id]; u != nil {
+		for s, c := range u.mjpeg {
+			if s != session {
+				n += c
+			}
+		}
+	}
+	for sid, g := range ts.webrtc {
+		if live != nil && !live[sid] && now.Sub(g.at) > webrtcGrantGrace {
+			delete(ts.webrtc, sid)
+			continue
+		}
+		if g.token == id {
+			n++
+		}
+	}
+	return n
+}
+
+// hasWebRTCLocked reports whether the token may have WebRTC sessions open.
+func (ts *TokenStore) hasWebRTCLocked(id string) bool {
+	for _, g := range ts.webrtc {
+		if g.token == id {
+			return true
+		}
+	}
+	return false
+}
+
+// liveSessions asks for the streaming server's sessions, but only when the
+// token has WebRTC sessions that may have ended.
+func (ts *TokenStore) liveSessions(t AccessToken) map[string]bool {
+	if t.MaxStreams <= 0 || ts.live == nil {
+		return nil
+	}
+	ts.mu.Lock()
+	need := ts.hasWebRTCLocked(t.ID)
+	ts.mu.Unlock()
+	if !need {
+		return nil
+	}
+	return ts.live()
+}
+
+// OpenMJPEG registers a /stream response of the browser session. It returns
+// a context that ends when the token expires or is revoked and a release
+// func, or ok=false when the token already has MaxStreams streams.
+func (ts *TokenStore) OpenMJPEG(ctx context.Context, t AccessToken, session string, now time.Time) (context.Context, func(), bool) {
+	live := ts.liveSessions(t)
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if t.MaxStreams > 0 && ts.streamsLocked(t.ID, session, live, now) >= t.MaxStreams {
+		return nil, nil, false
+	}
+	var cancel context.CancelFunc
+	if t.ExpiresAt != nil {
+		ctx, cancel = context.WithDeadline(ctx, *t.ExpiresAt)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	u := ts.usageLocked(t.ID)
+	u.mjpeg[session]++
+	ts.nextID++
+	key := ts.nextID
+	u.cancels[key] = cancel
+	release := func() {
+		cancel()
+		ts.mu.Lock()
+		defer ts.mu.Unlock()
+		if ts.usage[t.ID] != u {
+			return // revoked meanwhile
+		}
+		delete(u.cancels, key)
+		if u.mjpeg[session]--; u.mjpeg[session] <= 0 {
+			delete(u.mjpeg, session)
+		}
+	}
+	return ctx, release, true
+}
+
+// AllowWebRTC reports whether the token may open another stream; the
+// browser session's MJPEG stream does not count, since WebRTC replaces it.
+func (ts *TokenStore) AllowWebRTC(t AccessToken, session string, now time.Time) bool {
+	if t.MaxStreams <= 0 {
+		return true
+	}
+	live := ts.liveSessions(t)
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.streamsLocked(t.ID, session, live, now) < t.MaxStreams
+}
+
+// AddWebRTC counts the streaming server session sid against the token
+// until the streaming server no longer lists it.
+func (ts *TokenStore) AddWebRTC(t AccessToken, sid string, now time.Time) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.webrtc[sid] = webrtcGrant{token: t.ID, at: now}
+}
+
+// List returns the tokens with their current usage.
+func (ts *TokenStore) List(now time.Time) []TokenStatus {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	out := make([]TokenStatus, 0, len(ts.tokens))
+	for _, t := range ts.tokens {
+		st := TokenStatus{
+			ID:         t.ID,
+			Name:       t.Name,
+			ExpiresAt:  t.ExpiresAt,
+			Expired:    t.expired(now),
+			MaxStreams: t.MaxStreams,
+			RatePerMin: t.RatePerMin,
+			Locale:     t.Locale,
+			CreatedAt:  t.CreatedAt,
+			Streams:    ts.streamsLocked(t.ID, "", nil, now),
+		}
+		if u := ts.usage[t.ID]; u != nil && !u.lastUsed.IsZero() {
+			st.LastUsed = float64(u.lastUsed.Unix())
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+type tokenCtxKey struct{}
+
+// requestToken returns the access token the request was let in with, if
+// it was a named token rather than the admin token or open access.
+func requestToken(r *http.Request) (AccessToken, bool) {
+	t, ok := r.Context().Value(tokenCtxKey{}).(AccessToken)
+	return t, ok
+}
+
+// tokenExempt reports whether path is served without a token: the page
+// itself (so /?token= can set the cookie), its assets, signed links, and
+// the detector proxy, which checks its own token (a browser logs in to it
+// with Basic auth, which carries no access token).
+func tokenExempt(path string) bool {
+	return path == "/" || path == "/sw.js" || path == "/readyz" ||
+		strings.HasPrefix(path, "/assets/") || strings.HasPrefix(path, sharePrefix) ||
+		path == detectorProxyPrefix || strings.HasPrefix(path, detectorProxyPrefix+"/")
+}
+
+// tokenWritable lists what a read-only token may POST: watching over
+// WebRTC starts with an offer.
+func tokenWritable(path string) bool {
+	return path == "/api/webrtc/offer"
+}
+
+// requestSecret finds the credential of r: a Bearer token, the token query
+// parameter or the cookie set from it.
+func requestSecret(r *http.Request) (secret string, fromQuery bool) {
+	if got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return got, false
+	}
+	if got := r.URL.Query().Get("token"); got != "" {
+		return got, true
+	}
+	if c, err := r.Cookie(tokenCookie); err == nil {
+		return c.Value, false
+	}
+	return "", false
+}
+
+// setTokenCookie remembers a token passed as ?token= for the browser's
+// later requests.
+func setTokenCookie(w http.ResponseWriter, secret string, expires *time.Time) {
+	c := &http.Cookie{
+		Name:     tokenCookie,
+		Value:    secret,
+		Path:     "/",
+		MaxAge:   30 * 86400,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if expires != nil {
+		c.MaxAge = max(int(time.Until(*expires).Seconds()), 1)
+	}
+	http.SetCookie(w, c)
+}
+
+// authenticate resolves a credential: admin is true for the admin token,
+// ok for the admin token or an unexpired named token.
+func (s *Server) authenticate(secret string, now time.Time) (t AccessToken, admin, ok bool) {
+	if secret == "" {
+		return AccessToken{}, false, false
+	}
+	if s.cfg.AdminToken != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(s.cfg.AdminToken)) == 1 {
+		return AccessToken{}, true, true
+	}
+	t, found := s.tokens.Lookup(secret)
+	return t, false, found && !t.expired(now)
+}
+
+// tokenMiddleware restricts access once access tokens exist: every request
+// outside tokenExempt needs the admin token or an unexpired named token,
+// and named tokens are read-only and rate limited.
+func (s *Server) tokenMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.tokens == nil || !s.tokens.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		now := time.Now()
+		secret, fromQuery := requestSecret(r)
+		t, admin, ok := s.authenticate(secret, now)
+		if ok && fromQuery {
+			setTokenCookie(w, secret, t.ExpiresAt)
+		}
+		if tokenExempt(r.URL.Path) || admin {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="petcam"`)
+			writeJSONWithStatus(w, map[string]any{"error": "unauthorized"}, http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead && !tokenWritable(r.URL.Path) {
+			writeJSONWithStatus(w, map[string]any{"error": "read-only token"}, http.StatusForbidden)
+			return
+		}
+		if ok, wait := s.tokens.Allow(t, now); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeJSONWithStatus(w, map[string]any{"error": "rate limit exceeded"}, http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tokenCtxKey{}, t)))
+	})
+}
+
+// webrtcSessionIDs asks the streaming server which WebRTC sessions exist;
+// nil when it cannot tell.
+func (s *Server) webrtcSessionIDs() map[string]bool {
+	resp, err := s.webrtc.Get(strings.TrimRight(s.cfg.WebRTCBaseURL, "/") + "/api/webrtc/stats")
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	var stats struct {
+		Sessions []WebRTCSessionStats `json:"sessions"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&stats) != nil {
+		return nil
+	}
+	ids := make(map[string]bool, len(stats.Sessions))
+	for _, st := range stats.Sessions {
+		ids[st.ID] = true
+	}
+	return ids
+}
+
+// TokenRequest is the body of POST /api/tokens and PUT /api/tokens/{id}.
+type TokenRequest struct {
+	Name       string     `json:"name"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"` // RFC 3339; absent = never
+	MaxStreams int        `json:"max_streams,omitempty"`
+	RatePerMin int        `json:"rate_per_min,omitempty"`
+	Locale     string     `json:"locale,omitempty"`
+}
+
+func (req TokenRequest) token() AccessToken {
+	return AccessToken{Name: req.Name, ExpiresAt: req.ExpiresAt, MaxStreams: req.MaxStreams, RatePerMin: req.RatePerMin, Locale: req.Locale}
+}
+
+// handleTokens serves GET/POST /api/tokens (admin only).
+func (s *Server) handleTokens(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAdmin(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, map[string]any{"tokens": s.tokens.List(time.Now())})
+	case http.MethodPost:
+		var req TokenRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": "invalid JSON: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		t, secret, err := s.tokens.Create(req.token(), time.Now())
+		if err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+		logger.Info("Tokens", "Issued access token %s (%s)", t.ID, t.Name)
+		writeJSONWithStatus(w, map[string]any{"id": t.ID, "token": secret}, http.StatusCreated)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleToken serves PUT/DELETE /api/tokens/{id} (admin only).
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAdmin(w, r) {
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/api/tokens/")
+	switch r.Method {
+	case http.MethodPut:
+		var req TokenRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": "invalid JSON: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		t, err := s.tokens.Update(id, req.token(), time.Now())
+		if errors.Is(err, errTokenNotFound) {
+			writeJSONWithStatus(w, map[string]any{"error": "not found"}, http.StatusNotFound)
+			return
+		} else if err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, map[string]any{"id": t.ID})
+	case http.MethodDelete:
+		found, err := s.tokens.Delete(id)
+		if err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			writeJSONWithStatus(w, map[string]any{"error": "not found"}, http.StatusNotFound)
+			return
+		}
+		logger.Info("Tokens", "Revoked access token %s", id)
+		writeJSON(w, map[string]any{"deleted": true})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
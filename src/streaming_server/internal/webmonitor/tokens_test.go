@@ -0,0 +1,145 @@
+package webmonitor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+)
+
+func TestTokenStore(t *testing.T) {
+	doc := tokensDoc.in(kv.NewMemory())
+	ts := NewTokenStore(doc)
+	now := time.Now()
+	if _, _, err := ts.Create(AccessToken{Name: " "}, now); err == nil {
+		t.Error("nameless token accepted")
+	}
+	tok, secret, err := ts.Create(AccessToken{Name: "neighbor", RatePerMin: 2, MaxStreams: 1}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the hash is kept, and it survives a restart
+	reloaded := NewTokenStore(doc)
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := reloaded.Lookup(secret); !ok || got.ID != tok.ID || got.Hash == secret {
+		t.Fatalf("lookup after reload: %+v %v", got, ok)
+	}
+	if _, ok := reloaded.Lookup("guess"); ok {
+		t.Error("unknown secret matched")
+	}
+
+	// Two requests per minute, refilled continuously
+	for i, want := range []bool{true, true, false} {
+		if ok, _ := ts.Allow(tok, now); ok != want {
+			t.Errorf("request %d: allowed %v", i, ok)
+		}
+	}
+	if ok, wait := ts.Allow(tok, now.Add(10*time.Second)); ok || wait != 20*time.Second {
+		t.Errorf("still limited: %v, retry in %v", ok, wait)
+	}
+	if ok, _ := ts.Allow(tok, now.Add(40*time.Second)); !ok {
+		t.Error("allowance not refilled")
+	}
+
+	// One stream: a second browser is refused, the same one replaces its stream
+	ctx, release, ok := ts.OpenMJPEG(context.Background(), tok, "tab1", now)
+	if !ok {
+		t.Fatal("first stream refused")
+	}
+	if _, _, ok := ts.OpenMJPEG(context.Background(), tok, "tab2", now); ok {
+		t.Error("second stream allowed")
+	}
+	if !ts.AllowWebRTC(tok, "tab1", now) || ts.AllowWebRTC(tok, "tab2", now) {
+		t.Error("WebRTC should only replace the session's own MJPEG stream")
+	}
+	release()
+	ts.AddWebRTC(tok, "ws-50000", now)
+	if ts.AllowWebRTC(tok, "tab2", now) {
+		t.Error("WebRTC session not counted")
+	}
+	ts.live = func() map[string]bool { return map[string]bool{} }
+	if !ts.AllowWebRTC(tok, "tab2", now.Add(time.Minute)) {
+		t.Error("ended WebRTC session still counted")
+	}
+
+	// Revoking closes the open streams
+	ctx, release, _ = ts.OpenMJPEG(context.Background(), tok, "tab1", now)
+	defer release()
+	if found, err := ts.Delete(tok.ID); !found || err != nil {
+		t.Fatal(found, err)
+	}
+	if ctx.Err() == nil {
+		t.Error("stream of revoked token still open")
+	}
+	if ts.Enabled() {
+		t.Error("store without tokens restricts access")
+	}
+}
+
+func TestTokenMiddleware(t *testing.T) {
+	ts := NewTokenStore(kv.Doc{})
+	s := &Server{cfg: Config{AdminToken: "admin"}, tokens: ts}
+	var seen AccessToken
+	h := s.tokenMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = requestToken(r)
+	}))
+	do := func(method, target, bearer string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, nil)
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodGet, "/api/status", ""); rec.Code != http.StatusOK {
+		t.Fatalf("open access without tokens: %d", rec.Code)
+	}
+	tok, secret, _ := ts.Create(AccessToken{Name: "neighbor", RatePerMin: 2}, time.Now())
+	past := time.Now().Add(-time.Hour)
+	_, expired, _ := ts.Create(AccessToken{Name: "last week", ExpiresAt: &past}, time.Now())
+
+	for _, tc := range []struct {
+		method, target, bearer string
+		want                   int
+	}{
+		{http.MethodGet, "/", "", http.StatusOK},
+		{http.MethodGet, "/assets/app.js", "", http.StatusOK},
+		{http.MethodGet, "/api/status", "", http.StatusUnauthorized},
+		{http.MethodGet, "/api/status", expired, http.StatusUnauthorized},
+		{http.MethodPost, "/api/recording/start", "admin", http.StatusOK},
+		{http.MethodGet, "/stream", secret, http.StatusOK},
+		{http.MethodPost, "/api/recording/start", secret, http.StatusForbidden},
+		{http.MethodPost, "/api/webrtc/offer", secret, http.StatusOK},
+		{http.MethodGet, "/api/status", secret, http.StatusTooManyRequests},
+	} {
+		if rec := do(tc.method, tc.target, tc.bearer); rec.Code != tc.want {
+			t.Errorf("%s %s (%q): %d, want %d", tc.method, tc.target, tc.bearer, rec.Code, tc.want)
+		}
+	}
+	if seen.ID != tok.ID {
+		t.Errorf("handler saw token %+v", seen)
+	}
+
+	// ?token= is remembered in a cookie for the page's own requests
+	ts.Update(tok.ID, AccessToken{Name: "neighbor"}, time.Now())
+	rec := do(http.MethodGet, "/?token="+secret, "")
+	cookies := rec.Result().Cookies()
+	if rec.Code != http.StatusOK || len(cookies) != 1 || cookies[0].Name != tokenCookie || cookies[0].Value != secret {
+		t.Fatalf("query token: %d %v", rec.Code, cookies)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/status", nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("cookie: %d", rec.Code)
+	}
+}
@@ -0,0 +1,263 @@
+package webmonitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/logger"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/mqtt"
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/recorder"
+)
+
+// EventTriggerFired is appended each time an external trigger fires,
+// carrying the EventID its recording shares.
+const EventTriggerFired = "trigger_fired"
+
+// How an external trigger arrived.
+const (
+	TriggerViaHTTP = "http"
+	TriggerViaMQTT = "mqtt"
+)
+
+// triggerOwnerPrefix + trigger name owns the recordings a trigger starts.
+const triggerOwnerPrefix = "trigger:"
+
+// defaultTriggerDuration is how long a trigger records unless configured.
+const defaultTriggerDuration = 30 * time.Second
+
+var triggerNameRE = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// errUnknownTrigger is returned by fireTrigger for a name not in -triggers.
+var errUnknownTrigger = errors.New("unknown trigger")
+
+// TriggerDef is a named external trigger, e.g. a door sensor on the cat
+// flap, that records for Duration when it fires.
+type TriggerDef struct {
+	Name     string
+	Duration time.Duration
+}
+
+// TriggerList is the -triggers flag: comma-separated name[=duration], e.g.
+// "cat_flap=45s,doorbell".
+type TriggerList []TriggerDef
+
+func (l TriggerList) String() string {
+	parts := make([]string, len(l))
+	for i, t := range l {
+		parts[i] = t.Name + "=" + t.Duration.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set parses a -triggers value (flag.Value).
+func (l *TriggerList) Set(s string) error {
+	var out TriggerList
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, dur, hasDur := strings.Cut(part, "=")
+		def := TriggerDef{Name: name, Duration: defaultTriggerDuration}
+		if !triggerNameRE.MatchString(name) {
+			return fmt.Errorf("trigger %q: want lowercase letters, digits, _ and - (at most 32)", name)
+		}
+		if slices.ContainsFunc(out, func(t TriggerDef) bool { return t.Name == name }) {
+			return fmt.Errorf("trigger %q listed twice", name)
+		}
+		if hasDur {
+			d, err := recorder.ParseDuration(dur)
+			if err != nil {
+				return fmt.Errorf("trigger %q: %w", name, err)
+			}
+			if d > MaxRecordingDuration {
+				return fmt.Errorf("trigger %q: duration exceeds the maximum recording duration", name)
+			}
+			def.Duration = d
+		}
+		out = append(out, def)
+	}
+	*l = out
+	return nil
+}
+
+// TriggerInfo is one trigger in GET /api/triggers.
+type TriggerInfo struct {
+	Name        string  `json:"name"`
+	DurationSec float64 `json:"duration_sec"`
+	Fired       int     `json:"fired"`                // since start
+	LastFired   float64 `json:"last_fired,omitempty"` // Unix seconds
+	LastVia     string  `json:"last_via,omitempty"`
+	LastSource  string  `json:"last_source,omitempty"`
+}
+
+// Triggers holds the configured triggers and counts their firings.
+type Triggers struct {
+	mu   sync.Mutex
+	defs TriggerList
+	info map[string]*TriggerInfo
+}
+
+// NewTriggers creates the set of configured triggers.
+func NewTriggers(defs TriggerList) *Triggers {
+	t := &Triggers{defs: defs, info: make(map[string]*TriggerInfo, len(defs))}
+	for _, d := range defs {
+		t.info[d.Name] = &TriggerInfo{Name: d.Name, DurationSec: d.Duration.Seconds()}
+	}
+	return t
+}
+
+// fired looks up name and counts a firing. Returns false for an unknown name.
+func (t *Triggers) fired(name, via, source string, at time.Time) (TriggerDef, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	i := slices.IndexFunc(t.defs, func(d TriggerDef) bool { return d.Name == name })
+	if i < 0 {
+		return TriggerDef{}, false
+	}
+	info := t.info[name]
+	info.Fired++
+	info.LastFired = float64(at.UnixNano()) / 1e9
+	info.LastVia, info.LastSource = via, source
+	return t.defs[i], true
+}
+
+// List returns the triggers in configured order.
+func (t *Triggers) List() []TriggerInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]TriggerInfo, len(t.defs))
+	for i, d := range t.defs {
+		out[i] = *t.info[d.Name]
+	}
+	return out
+}
+
+// TriggerFired is the POST /api/triggers/fire response.
+type TriggerFired struct {
+	Trigger   string         `json:"trigger"`
+	EventID   string         `json:"event_id"`
+	Recording TimedRecording `json:"recording"`
+}
+
+// fireTrigger records a firing of the trigger name, received via HTTP or
+// MQTT from source (free-form, e.g. the sensor's name), and records for the
+// trigger's duration like POST /api/record, extending the active timed
+// recording. The trigger_fired event is appended even when another
+// recording keeps this one from starting.
+func (s *Server) fireTrigger(name, via, source string) (TriggerFired, error) {
+	source = strings.TrimSpace(source)
+	if r := []rune(source); len(r) > maxOwnerName {
+		source = string(r[:maxOwnerName])
+	}
+	def, ok := s.triggers.fired(name, via, source, time.Now())
+	if !ok {
+		return TriggerFired{}, errUnknownTrigger
+	}
+	data := map[string]string{"trigger": name, "via": via}
+	if source != "" {
+		data["source"] = source
+	}
+	ev := s.events.Append(Event{Type: EventTriggerFired, Data: data})
+
+	owner := RecordingOwner{ID: triggerOwnerPrefix + name, Name: name, Via: via}
+	rec, err := s.recordFor(owner, ev.EventID, def.Duration)
+	return TriggerFired{Trigger: name, EventID: ev.EventID, Recording: rec}, err
+}
+
+// handleTriggers serves GET /api/triggers: the configured triggers, their
+// firings and the MQTT connection.
+func (s *Server) handleTriggers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body := map[string]any{"triggers": s.triggers.List()}
+	if s.mqtt != nil {
+		body["mqtt"] = s.mqtt.Status()
+	}
+	writeJSON(w, body)
+}
+
+// handleTriggerFire serves POST /api/triggers/fire with {"trigger": "cat_flap",
+// "source": "..."}, or ?trigger=cat_flap for senders that cannot set a body.
+func (s *Server) handleTriggerFire(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req := struct {
+		Trigger string `json:"trigger"`
+		Source  string `json:"source"`
+	}{Trigger: r.URL.Query().Get("trigger"), Source: r.URL.Query().Get("source")}
+	if data, err := io.ReadAll(io.LimitReader(r.Body, 4096)); err == nil && len(strings.TrimSpace(string(data))) > 0 {
+		if err := json.Unmarshal(data, &req); err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": "invalid JSON: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Trigger == "" {
+		writeJSONWithStatus(w, map[string]any{"error": "trigger is required"}, http.StatusBadRequest)
+		return
+	}
+
+	fired, err := s.fireTrigger(req.Trigger, TriggerViaHTTP, req.Source)
+	switch {
+	case errors.Is(err, errUnknownTrigger):
+		writeJSONWithStatus(w, map[string]any{"error": err.Error(), "trigger": req.Trigger}, http.StatusNotFound)
+		return
+	case errors.Is(err, ErrAlreadyRecording):
+		s.writeRecordingConflict(w)
+		return
+	case errors.Is(err, errStopping):
+		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusConflict)
+		return
+	case err != nil:
+		writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	logger.Info("Triggers", "%s fired via HTTP: recording %s until %s", req.Trigger, fired.Recording.File, fired.Recording.Until.Format(time.TimeOnly))
+	writeJSON(w, fired)
+}
+
+// startMQTT subscribes to <topic>/+ on the broker; a message on
+// <topic>/cat_flap fires cat_flap. The payload is optional: {"source": "..."}
+// or plain text naming the sender.
+func (s *Server) startMQTT(cfg Config) {
+	host, _ := os.Hostname()
+	prefix := strings.TrimSuffix(cfg.MQTTTopic, "/")
+	client, err := mqtt.NewClient(cfg.MQTTURL, "pet-camera-"+host, []string{prefix + "/+"}, s.handleMQTTMessage)
+	if err != nil {
+		logger.Warn("Triggers", "MQTT disabled: %v", err)
+		return
+	}
+	client.Password = cfg.MQTTPassword
+	s.mqtt = client
+	s.mqtt.Start()
+}
+
+func (s *Server) handleMQTTMessage(m mqtt.Message) {
+	name := m.Topic[strings.LastIndexByte(m.Topic, '/')+1:]
+	source := strings.TrimSpace(string(m.Payload))
+	var payload struct {
+		Source string `json:"source"`
+	}
+	if json.Unmarshal(m.Payload, &payload) == nil {
+		source = payload.Source
+	}
+	fired, err := s.fireTrigger(name, TriggerViaMQTT, source)
+	if err != nil {
+		logger.Warn("Triggers", "%s via MQTT (%s): %v", name, m.Topic, err)
+		return
+	}
+	logger.Info("Triggers", "%s fired via MQTT: recording %s until %s", name, fired.Recording.File, fired.Recording.Until.Format(time.TimeOnly))
+}
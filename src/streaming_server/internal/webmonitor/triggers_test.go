@@ -0,0 +1,92 @@
+package webmonitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/mqtt"
+)
+
+func TestTriggerList(t *testing.T) {
+	var l TriggerList
+	if err := l.Set("cat_flap=45s, doorbell,"); err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 2 || l[0] != (TriggerDef{"cat_flap", 45 * time.Second}) || l[1] != (TriggerDef{"doorbell", defaultTriggerDuration}) {
+		t.Errorf("parsed %+v", l)
+	}
+	if l.String() != "cat_flap=45s,doorbell=30s" {
+		t.Errorf("String() = %q", l.String())
+	}
+	for _, bad := range []string{"Cat", "a/b", "flap=soon", "flap=2h", "flap,flap", "=30s"} {
+		if err := new(TriggerList).Set(bad); err == nil {
+			t.Errorf("accepted %q", bad)
+		}
+	}
+}
+
+func TestTriggerFire(t *testing.T) {
+	var defs TriggerList
+	defs.Set("cat_flap=45s")
+	rec := NewRecorder(t.TempDir(), "/nonexistent")
+	s := &Server{recorder: rec, events: NewEventStore(time.Hour), triggers: NewTriggers(defs)}
+	post := func(query, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.handleTriggerFire(w, httptest.NewRequest(http.MethodPost, "/api/triggers/fire"+query, strings.NewReader(body)))
+		return w
+	}
+
+	if w := post("", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("no trigger: %d", w.Code)
+	}
+	if w := post("?trigger=doorbell", ""); w.Code != http.StatusNotFound {
+		t.Errorf("unknown trigger: %d", w.Code)
+	}
+
+	// A recording someone started by hand is not taken over, but the firing is kept
+	rec.recording, rec.filename, rec.eventID = true, "recording_20261016_120000.hevc", "manual"
+	if w := post("", `{"trigger":"cat_flap","source":"flap sensor"}`); w.Code != http.StatusConflict {
+		t.Errorf("during a manual recording: %d %s", w.Code, w.Body)
+	}
+	fired := s.events.Query(0, []string{EventTriggerFired}, 0)
+	if len(fired) != 1 || fired[0].Data["trigger"] != "cat_flap" || fired[0].Data["via"] != TriggerViaHTTP || fired[0].Data["source"] != "flap sensor" {
+		t.Fatalf("events %+v", fired)
+	}
+
+	// An active timed recording is extended by the trigger's duration
+	rec.eventID = "timed"
+	s.timed.eventID, s.timed.until = "timed", time.Now()
+	w := post("?trigger=cat_flap", "")
+	var got TriggerFired
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("fire: %d %s", w.Code, w.Body)
+	}
+	if got.Trigger != "cat_flap" || got.EventID == "" || !got.Recording.Extended || time.Until(got.Recording.Until) < 44*time.Second {
+		t.Errorf("fire: %+v", got)
+	}
+
+	s.handleMQTTMessage(mqtt.Message{Topic: "pet-camera/trigger/cat_flap", Payload: []byte(`{"source":"zigbee"}`)})
+	s.handleMQTTMessage(mqtt.Message{Topic: "pet-camera/trigger/garage", Payload: []byte("door")})
+	info := s.triggers.List()
+	if len(info) != 1 || info[0].Fired != 3 || info[0].LastVia != TriggerViaMQTT || info[0].LastSource != "zigbee" {
+		t.Errorf("info %+v", info)
+	}
+}
+
+func TestTriggerAttribution(t *testing.T) {
+	if recordingTrigger(RecordingOwner{ID: triggerOwnerPrefix + "cat_flap"}) != TriggerExternal {
+		t.Error("trigger owner not external")
+	}
+	r := NewRecorder(t.TempDir(), "")
+	r.owner = RecordingOwner{ID: triggerOwnerPrefix + "cat_flap", Name: "cat_flap", Via: TriggerViaMQTT}
+	r.indexStoppedLocked("recording_20261016_070000.hevc")
+	recs := []RecordingInfo{{Name: "recording_20261016_070000.mp4"}}
+	r.annotateClipStats(recs)
+	if recs[0].TriggerName != "cat_flap" || recs[0].TriggerVia != TriggerViaMQTT {
+		t.Errorf("recording %+v", recs[0])
+	}
+}
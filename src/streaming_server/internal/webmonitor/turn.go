@@ -0,0 +1,106 @@
+package webmonitor
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// turnSecretBytes is the entropy of a generated TURN secret (hex-encoded in
+// the file, so coturn's static-auth-secret can take the file contents).
+const turnSecretBytes = 32
+
+// TURNSecret issues time-limited TURN credentials from a secret shared with
+// the TURN server ("REST API for access to TURN services", coturn's
+// use-auth-secret): the username is "<expiry unix time>:<name>" and the
+// credential base64(HMAC-SHA1(secret, username)). The TURN server checks
+// them without a user list, and a leaked pair stops working at expiry.
+type TURNSecret struct {
+	path string
+	ttl  time.Duration
+
+	mu     sync.RWMutex
+	secret []byte
+}
+
+// LoadTURNSecret reads the secret from path, first creating the file with a
+// random secret (mode 0600) if it does not exist. Credentials are valid for
+// ttl.
+func LoadTURNSecret(path string, ttl time.Duration) (*TURNSecret, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("credential TTL must be positive, got %v", ttl)
+	}
+	if err := createSecretFile(path, turnSecretBytes); err != nil {
+		return nil, err
+	}
+	t := &TURNSecret{path: path, ttl: ttl}
+	if err := t.Reload(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// createSecretFile writes n random bytes, hex-encoded, to a new file at path
+// (mode 0600). An existing file is left alone.
+func createSecretFile(path string, n int) error {
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(hex.EncodeToString(buf) + "\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Reload re-reads the secret file; on error the previous secret stays in
+// use. Rotate by adding the new secret to the TURN server, replacing the
+// file and reloading, then dropping the old secret from the TURN server once
+// the credentials issued with it have expired (one TTL later).
+func (t *TURNSecret) Reload() error {
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		return err
+	}
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return fmt.Errorf("%s: empty TURN secret", t.path)
+	}
+	t.mu.Lock()
+	t.secret = []byte(secret)
+	t.mu.Unlock()
+	return nil
+}
+
+// Credentials returns a username/credential pair for name valid until
+// expires.
+func (t *TURNSecret) Credentials(name string, now time.Time) (username, credential string, expires time.Time) {
+	expires = now.Add(t.ttl).Truncate(time.Second)
+	username = strconv.FormatInt(expires.Unix(), 10)
+	if name != "" {
+		username += ":" + name
+	}
+	t.mu.RLock()
+	mac := hmac.New(sha1.New, t.secret)
+	t.mu.RUnlock()
+	mac.Write([]byte(username))
+	return username, base64.StdEncoding.EncodeToString(mac.Sum(nil)), expires
+}
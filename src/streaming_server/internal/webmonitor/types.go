@@ -13,22 +13,27 @@ type Detection struct {
 	ClassName  string      `json:"class_name"`
 	Confidence float64     `json:"confidence"`
 	BBox       BoundingBox `json:"bbox"`
+	PetID      string      `json:"pet_id,omitempty"`    // enrolled pet (see PetIdentifier)
+	BBoxNorm   *NormBBox   `json:"bbox_norm,omitempty"` // with -detection-bbox-norm
 }
 
 // DetectionResult mirrors the JSON shape used by the Flask monitor APIs.
 type DetectionResult struct {
-	FrameNumber   int         `json:"frame_number"`
-	Timestamp     float64     `json:"timestamp"`
-	NumDetections int         `json:"num_detections"`
-	Version       int         `json:"version"`
-	Detections    []Detection `json:"detections"`
+	FrameNumber   int             `json:"frame_number"`
+	Timestamp     float64         `json:"timestamp"`
+	NumDetections int             `json:"num_detections"`
+	Version       int             `json:"version"`
+	Detections    []Detection     `json:"detections"`
+	Source        string          `json:"source,omitempty"` // "" = detection daemon, "motion" = fallback
+	Frame         *DetectionFrame `json:"frame,omitempty"`  // what the boxes were measured in
 }
 
 // DetectionEvent is the payload for /api/detections/stream.
 type DetectionEvent struct {
-	FrameNumber int         `json:"frame_number"`
-	Timestamp   float64     `json:"timestamp"`
-	Detections  []Detection `json:"detections"`
+	FrameNumber int             `json:"frame_number"`
+	Timestamp   float64         `json:"timestamp"`
+	Detections  []Detection     `json:"detections"`
+	Frame       *DetectionFrame `json:"frame,omitempty"`
 }
 
 // MonitorStats mirrors the JSON shape used by the Flask monitor APIs.
@@ -0,0 +1,225 @@
+package webmonitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+)
+
+// uiPanels are the dashboard panels a deployment can switch off, in display
+// order. The live video and its controls are always shown.
+var uiPanels = []string{"tracking", "diagnostics", "history", "cameras", "album"}
+
+// Dashboard themes.
+const (
+	ThemeDark  = "dark"
+	ThemeLight = "light"
+)
+
+// maxUIString bounds a label override; labels are headings and tab names.
+const maxUIString = 200
+
+// uiLabels returns the dashboard's built-in labels in locale: the "ui.*"
+// catalog keys without the prefix.
+func uiLabels(locale string) map[string]string {
+	labels := make(map[string]string)
+	for key := range catalog[fallbackLocale] {
+		if name, ok := strings.CutPrefix(key, "ui."); ok {
+			labels[name] = msg(key).In(locale)
+		}
+	}
+	return labels
+}
+
+// UIConfig is the dashboard layout a deployment chose: which panels appear,
+// the theme, the default locale and label overrides per locale.
+type UIConfig struct {
+	Panels    []string                     `json:"panels"`              // enabled panels of uiPanels, in display order
+	Theme     string                       `json:"theme"`               // ThemeDark or ThemeLight
+	Locale    string                       `json:"locale,omitempty"`    // default locale ("en", "ja"; "" = -locale)
+	Overrides map[string]map[string]string `json:"overrides,omitempty"` // locale -> key -> label
+}
+
+// defaultUIConfig shows every panel in the dark theme, in the server's
+// locale.
+func defaultUIConfig() UIConfig {
+	return UIConfig{Panels: slices.Clone(uiPanels), Theme: ThemeDark}
+}
+
+// normalize fills in defaults for omitted fields and validates the rest.
+// Panels are kept in display order; a missing list enables every panel.
+func (c *UIConfig) normalize() error {
+	if c.Panels == nil {
+		c.Panels = slices.Clone(uiPanels)
+	}
+	enabled := make(map[string]bool, len(c.Panels))
+	for _, p := range c.Panels {
+		if !slices.Contains(uiPanels, p) {
+			return fmt.Errorf("unknown panel %q (%v)", p, uiPanels)
+		}
+		enabled[p] = true
+	}
+	c.Panels = slices.DeleteFunc(slices.Clone(uiPanels), func(p string) bool { return !enabled[p] })
+
+	switch c.Theme {
+	case "":
+		c.Theme = ThemeDark
+	case ThemeDark, ThemeLight:
+	default:
+		return fmt.Errorf("unknown theme %q (%s, %s)", c.Theme, ThemeDark, ThemeLight)
+	}
+	if c.Locale != "" && catalog[c.Locale] == nil {
+		return fmt.Errorf("unknown locale %q (%v)", c.Locale, locales())
+	}
+	for locale, labels := range c.Overrides {
+		if catalog[locale] == nil {
+			return fmt.Errorf("overrides for unknown locale %q", locale)
+		}
+		for key, v := range labels {
+			if !hasMessage("ui." + key) {
+				return fmt.Errorf("unknown label %q", key)
+			}
+			if v == "" || len(v) > maxUIString {
+				return fmt.Errorf("label %q must be 1-%d bytes", key, maxUIString)
+			}
+		}
+	}
+	return nil
+}
+
+// UIConfigStore holds the dashboard configuration served at /api/ui/config.
+type UIConfigStore struct {
+	doc kv.Doc
+
+	mu  sync.RWMutex
+	cfg UIConfig
+}
+
+// NewUIConfigStore creates a store persisted to doc (the zero Doc = not
+// persisted) holding the default configuration.
+func NewUIConfigStore(doc kv.Doc) *UIConfigStore {
+	return &UIConfigStore{doc: doc, cfg: defaultUIConfig()}
+}
+
+// Load reads the persisted configuration, the default without one.
+func (s *UIConfigStore) Load() error {
+	var cfg UIConfig
+	ok, err := s.doc.Load(&cfg)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		cfg = defaultUIConfig()
+	} else if err := cfg.normalize(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg = cfg
+	return nil
+}
+
+// Set validates cfg, fills in omitted fields and persists it.
+func (s *UIConfigStore) Set(cfg UIConfig) (UIConfig, error) {
+	if err := cfg.normalize(); err != nil {
+		return UIConfig{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.doc.Save(cfg); err != nil {
+		return UIConfig{}, err
+	}
+	s.cfg = cfg
+	return cfg, nil
+}
+
+// Reset restores the default configuration and deletes the persisted one.
+func (s *UIConfigStore) Reset() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.doc.Delete(); err != nil {
+		return err
+	}
+	s.cfg = defaultUIConfig()
+	return nil
+}
+
+// UIConfigView is the GET /api/ui/config response: the configuration and
+// the labels resolved for one locale.
+type UIConfigView struct {
+	UIConfig
+	Strings         map[string]string `json:"strings"`          // labels of the requested locale, overrides applied
+	RequestedLocale string            `json:"requested_locale"` // locale of strings
+	AvailablePanels []string          `json:"available_panels"`
+	Locales         []string          `json:"locales"`
+}
+
+// View resolves the labels for locale; "" selects the configured locale,
+// or def when none is configured.
+func (s *UIConfigStore) View(locale, def string) UIConfigView {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if locale == "" {
+		locale = s.cfg.Locale
+	}
+	if locale == "" {
+		locale = def
+	}
+	strs := uiLabels(locale)
+	maps.Copy(strs, s.cfg.Overrides[locale])
+	cfg := s.cfg
+	cfg.Panels = slices.Clone(cfg.Panels)
+	return UIConfigView{
+		UIConfig:        cfg,
+		Strings:         strs,
+		RequestedLocale: locale,
+		AvailablePanels: slices.Clone(uiPanels),
+		Locales:         locales(),
+	}
+}
+
+// handleUIConfig serves /api/ui/config. GET returns the dashboard
+// configuration with its labels in the request's locale (?locale= or the
+// token's locale, else the configured one); PUT replaces the configuration
+// and DELETE restores the default, both admin only.
+func (s *Server) handleUIConfig(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, s.uiConfig.View(explicitLocale(r), s.defaultLocale()))
+	case http.MethodPut:
+		if !s.requireAdmin(w, r) {
+			return
+		}
+		var cfg UIConfig
+		if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": "invalid JSON: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if err := cfg.normalize(); err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if _, err := s.uiConfig.Set(cfg); err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, s.uiConfig.View("", s.defaultLocale()))
+	case http.MethodDelete:
+		if !s.requireAdmin(w, r) {
+			return
+		}
+		if err := s.uiConfig.Reset(); err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, s.uiConfig.View("", s.defaultLocale()))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
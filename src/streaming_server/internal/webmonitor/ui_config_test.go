@@ -0,0 +1,70 @@
+package webmonitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+)
+
+func TestUIConfigHandler(t *testing.T) {
+	doc := uiDoc.in(kv.NewMemory())
+	s := &Server{cfg: Config{AdminToken: "secret", Locale: "ja"}, uiConfig: NewUIConfigStore(doc)}
+	do := func(method, query, token, body string) (int, UIConfigView) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/api/ui/config"+query, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		s.handleUIConfig(rec, req)
+		var v UIConfigView
+		json.Unmarshal(rec.Body.Bytes(), &v)
+		return rec.Code, v
+	}
+
+	code, v := do("GET", "", "", "")
+	if code != 200 || !slices.Equal(v.Panels, uiPanels) || v.Theme != ThemeDark || v.Strings["panel.history"] != "履歴" {
+		t.Fatalf("default: %d %+v", code, v)
+	}
+
+	body := `{"panels": ["album", "tracking"], "theme": "light", "locale": "en", "overrides": {"en": {"title": "Mike Cam"}}}`
+	if code, _ := do("PUT", "", "", body); code != http.StatusUnauthorized {
+		t.Errorf("PUT without admin token: %d", code)
+	}
+	for _, bad := range []string{
+		`{"panels": ["video"]}`,
+		`{"theme": "blue"}`,
+		`{"locale": "fr"}`,
+		`{"overrides": {"en": {"nope": "x"}}}`,
+		`{"overrides": {"en": {"title": ""}}}`,
+	} {
+		if code, _ := do("PUT", "", "secret", bad); code != http.StatusBadRequest {
+			t.Errorf("PUT %s: %d", bad, code)
+		}
+	}
+	code, v = do("PUT", "", "secret", body)
+	if code != 200 || !slices.Equal(v.Panels, []string{"tracking", "album"}) || v.Theme != ThemeLight ||
+		v.Strings["title"] != "Mike Cam" || v.Strings["tab.live"] != "Live" {
+		t.Fatalf("PUT: %d %+v", code, v)
+	}
+	if _, v := do("GET", "?locale=ja", "", ""); v.RequestedLocale != "ja" || v.Strings["title"] != "スマートペットカメラ モニター" {
+		t.Errorf("?locale=ja: %+v", v)
+	}
+
+	// Persisted across restarts
+	reloaded := NewUIConfigStore(doc)
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if v := reloaded.View("", "ja"); v.Locale != "en" || v.Strings["title"] != "Mike Cam" {
+		t.Errorf("reloaded %+v", v)
+	}
+
+	if code, v := do("DELETE", "", "secret", ""); code != 200 || len(v.Panels) != len(uiPanels) || v.Overrides != nil {
+		t.Errorf("DELETE: %d %+v", code, v)
+	}
+}
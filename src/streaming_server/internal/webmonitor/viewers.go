@@ -0,0 +1,96 @@
+package webmonitor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"time"
+
+	pb "github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/proto"
+)
+
+// ViewerInfo describes one live video viewer without identifying it: the ID
+// is a hash of the internal connection ID, stable while it stays connected.
+type ViewerInfo struct {
+	ID          string  `json:"id"`
+	Kind        string  `json:"kind"`         // "webrtc" or "mjpeg"
+	ConnectedAt float64 `json:"connected_at"` // Unix seconds
+	Quality     string  `json:"quality"`      // webrtc: good/fair/poor/unknown, mjpeg: full/reduced
+}
+
+// Viewers is the viewer summary carried in status events.
+type Viewers struct {
+	WebRTC  int          `json:"webrtc"`
+	MJPEG   int          `json:"mjpeg"`
+	Clients []ViewerInfo `json:"clients"`
+}
+
+// anonViewerID hashes an internal connection ID so status events (which
+// every dashboard receives) do not expose session IDs or addresses.
+func anonViewerID(kind, id string) string {
+	sum := sha256.Sum256([]byte(kind + ":" + id))
+	return hex.EncodeToString(sum[:4])
+}
+
+// Viewers returns the MJPEG stream subscribers.
+func (fb *FrameBroadcaster) Viewers() []ViewerInfo {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	quality := "full"
+	if fb.frameDivisor > 1 {
+		quality = "reduced"
+	}
+	out := make([]ViewerInfo, 0, len(fb.connectedAt))
+	for id, at := range fb.connectedAt {
+		out = append(out, ViewerInfo{
+			ID:          anonViewerID("mjpeg", strconv.Itoa(id)),
+			Kind:        "mjpeg",
+			ConnectedAt: float64(at.Unix()),
+			Quality:     quality,
+		})
+	}
+	return out
+}
+
+// Viewers combines the WebRTC sessions last reported by the streaming server
+// with the local MJPEG subscribers, oldest connection first.
+func (cb *ConnectionBroadcaster) Viewers() Viewers {
+	counts := cb.GetCounts()
+	v := Viewers{WebRTC: counts.WebRTC, MJPEG: counts.MJPEG}
+
+	now := time.Now()
+	for _, s := range counts.WebRTCSessions {
+		if !s.Connected {
+			continue
+		}
+		v.Clients = append(v.Clients, ViewerInfo{
+			ID:          anonViewerID("webrtc", s.ID),
+			Kind:        "webrtc",
+			ConnectedAt: float64(now.Add(-time.Duration(s.UptimeSec * float64(time.Second))).Unix()),
+			Quality:     s.Quality,
+		})
+	}
+	if cb.frameBroadcaster != nil {
+		v.Clients = append(v.Clients, cb.frameBroadcaster.Viewers()...)
+	}
+	sort.Slice(v.Clients, func(i, j int) bool { return v.Clients[i].ConnectedAt < v.Clients[j].ConnectedAt })
+	return v
+}
+
+func (v *Viewers) toProto() *pb.Viewers {
+	out := &pb.Viewers{
+		Webrtc:  int32(v.WebRTC),
+		Mjpeg:   int32(v.MJPEG),
+		Clients: make([]*pb.ViewerInfo, len(v.Clients)),
+	}
+	for i, c := range v.Clients {
+		out.Clients[i] = &pb.ViewerInfo{
+			Id:          c.ID,
+			Kind:        c.Kind,
+			ConnectedAt: c.ConnectedAt,
+			Quality:     c.Quality,
+		}
+	}
+	return out
+}
@@ -0,0 +1,42 @@
+package webmonitor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestViewers(t *testing.T) {
+	cb, onChange := NewConnectionBroadcaster("")
+	fb := NewFrameBroadcaster(nil, nil, onChange)
+	cb.SetBroadcasters(fb, NewDetectionBroadcaster(nil, nil, onChange), NewStatusBroadcaster(nil, nil, 0, onChange))
+
+	id, _ := fb.Subscribe()
+	fb.Subscribe()
+	fb.Unsubscribe(id)
+
+	cb.lastWebRTCCount = 2
+	cb.lastWebRTCSessions = []WebRTCSessionStats{
+		{ID: "session-secret", Connected: true, UptimeSec: 3600, Quality: "good"},
+		{ID: "connecting", Connected: false},
+	}
+
+	v := cb.Viewers()
+	if v.WebRTC != 2 || v.MJPEG != 1 {
+		t.Fatalf("counts webrtc=%d mjpeg=%d", v.WebRTC, v.MJPEG)
+	}
+	if len(v.Clients) != 2 {
+		t.Fatalf("got %d clients, want 2 (connected WebRTC + MJPEG)", len(v.Clients))
+	}
+	// Oldest first: the hour-old WebRTC session
+	if v.Clients[0].Kind != "webrtc" || v.Clients[0].Quality != "good" || v.Clients[1].Kind != "mjpeg" {
+		t.Fatalf("clients %+v", v.Clients)
+	}
+	if strings.Contains(v.Clients[0].ID, "secret") || len(v.Clients[0].ID) != 8 {
+		t.Fatalf("viewer ID not anonymized: %q", v.Clients[0].ID)
+	}
+
+	pbv := v.toProto()
+	if pbv.Webrtc != 2 || len(pbv.Clients) != 2 || pbv.Clients[1].Quality != "full" {
+		t.Fatalf("proto %+v", pbv)
+	}
+}
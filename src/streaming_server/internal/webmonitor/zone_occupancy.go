@@ -0,0 +1,335 @@
+package webmonitor
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Zone occupancy event types.
+const (
+	EventZoneEntered = "zone_entered"
+	EventZoneLeft    = "zone_left"
+)
+
+// occupantKey is who is in which zone: an enrolled pet, or a pet of a class
+// when identification has not named it.
+type occupantKey struct {
+	zone, class, petID string
+}
+
+type occupant struct {
+	zoneName  string
+	firstSeen time.Time
+	lastSeen  time.Time
+	entered   bool
+	credited  time.Time // occupied time up to here is in the rollups
+	bbox      BoundingBox
+}
+
+// ZoneOccupancy is the per-zone occupancy state machine, fed by every
+// detection result after pet identification. A pet seen in a zone for
+// EnterAfter has entered it (zone_entered); once unseen there for
+// LeaveAfter it has left (zone_left, with the stay's duration). Occupied
+// time is credited to the rollups as it accrues, so a cat asleep on the bed
+// for hours shows up before it wakes.
+type ZoneOccupancy struct {
+	zones  *ZoneSet
+	emit   func(Event)
+	record func(zone, class string, from, to time.Time) // occupied time, for the rollups
+
+	mu        sync.Mutex
+	occupants map[occupantKey]*occupant
+	stop      chan struct{}
+	stopped   bool
+
+	EnterAfter time.Duration // seen inside this long before zone_entered
+	LeaveAfter time.Duration // zone_left after unseen this long
+}
+
+// NewZoneOccupancy creates the state machine for zones, emitting events via
+// emit and crediting occupied time via record (either may be nil).
+func NewZoneOccupancy(zones *ZoneSet, emit func(Event), record func(zone, class string, from, to time.Time)) *ZoneOccupancy {
+	return &ZoneOccupancy{
+		zones:      zones,
+		emit:       emit,
+		record:     record,
+		occupants:  make(map[occupantKey]*occupant),
+		stop:       make(chan struct{}),
+		EnterAfter: 3 * time.Second,
+		LeaveAfter: 10 * time.Second,
+	}
+}
+
+// Start begins the periodic leave check, so a pet that walks out of the
+// frame leaves its zone even while no results arrive.
+func (o *ZoneOccupancy) Start() {
+	go o.run()
+}
+
+// Stop halts the periodic check.
+func (o *ZoneOccupancy) Stop() {
+	o.mu.Lock()
+	if !o.stopped {
+		close(o.stop)
+		o.stopped = true
+	}
+	o.mu.Unlock()
+}
+
+func (o *ZoneOccupancy) run() {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-o.stop:
+			return
+		case now := <-ticker.C:
+			o.Tick(now)
+		}
+	}
+}
+
+// Observe updates the occupants from a detection result.
+func (o *ZoneOccupancy) Observe(det *DetectionResult, now time.Time) {
+	if det == nil {
+		return
+	}
+	zones := o.zones.Zones()
+
+	var events []Event
+	o.mu.Lock()
+	for _, z := range zones {
+		for _, d := range det.Detections {
+			if !z.holds(d) {
+				continue
+			}
+			key, occ := o.occupantLocked(z, d, now)
+			occ.lastSeen, occ.bbox = now, d.BBox
+			if !occ.entered && now.Sub(occ.firstSeen) >= o.EnterAfter {
+				occ.entered, occ.credited = true, occ.firstSeen
+				events = append(events, o.eventLocked(EventZoneEntered, key, occ, now))
+			}
+		}
+	}
+	events = append(events, o.updateLocked(zones, now)...)
+	o.mu.Unlock()
+
+	o.emitAll(events)
+}
+
+// occupantLocked returns the occupant d is, creating it. A detection
+// without a pet ID is the pet of its class already in the zone, so an
+// identification that comes and goes does not split a stay.
+func (o *ZoneOccupancy) occupantLocked(z Zone, d Detection, now time.Time) (occupantKey, *occupant) {
+	key := occupantKey{zone: z.ID, class: d.ClassName, petID: d.PetID}
+	if occ, ok := o.occupants[key]; ok {
+		return key, occ
+	}
+	unnamed := occupantKey{zone: z.ID, class: d.ClassName}
+	if d.PetID == "" {
+		for k, occ := range o.occupants {
+			if k.zone == z.ID && k.class == d.ClassName {
+				return k, occ
+			}
+		}
+	} else if occ, ok := o.occupants[unnamed]; ok {
+		// Identified after all: the stay continues under the pet's name
+		delete(o.occupants, unnamed)
+		o.occupants[key] = occ
+		return key, occ
+	}
+	occ := &occupant{zoneName: z.Name, firstSeen: now}
+	o.occupants[key] = occ
+	return key, occ
+}
+
+// Tick credits occupied time and lets pets that were not seen leave.
+func (o *ZoneOccupancy) Tick(now time.Time) {
+	zones := o.zones.Zones()
+	o.mu.Lock()
+	events := o.updateLocked(zones, now)
+	o.mu.Unlock()
+
+	o.emitAll(events)
+}
+
+// updateLocked credits the time each occupant was seen since the last
+// update, ends the stays that lapsed and forgets occupants of zones that
+// were deleted.
+func (o *ZoneOccupancy) updateLocked(zones []Zone, now time.Time) []Event {
+	var events []Event
+	for key, occ := range o.occupants {
+		i := slices.IndexFunc(zones, func(z Zone) bool { return z.ID == key.zone })
+		if i < 0 {
+			delete(o.occupants, key)
+			continue
+		}
+		occ.zoneName = zones[i].Name
+		if occ.entered && occ.lastSeen.After(occ.credited) {
+			if o.record != nil {
+				o.record(key.zone, key.class, occ.credited, occ.lastSeen)
+			}
+			occ.credited = occ.lastSeen
+		}
+		if now.Sub(occ.lastSeen) < o.LeaveAfter {
+			continue
+		}
+		if occ.entered {
+			events = append(events, o.eventLocked(EventZoneLeft, key, occ, occ.lastSeen))
+		}
+		delete(o.occupants, key)
+	}
+	return events
+}
+
+func (o *ZoneOccupancy) eventLocked(eventType string, key occupantKey, occ *occupant, at time.Time) Event {
+	bbox := occ.bbox
+	data := map[string]string{"zone_id": key.zone, "zone": occ.zoneName}
+	if key.petID != "" {
+		data["pet_id"] = key.petID
+	}
+	if eventType == EventZoneLeft {
+		data["duration_sec"] = fmt.Sprintf("%.1f", occ.lastSeen.Sub(occ.firstSeen).Seconds())
+	}
+	return Event{
+		Type:      eventType,
+		Timestamp: float64(at.UnixNano()) / 1e9,
+		Class:     key.class,
+		BBox:      &bbox,
+		Data:      data,
+	}
+}
+
+func (o *ZoneOccupancy) emitAll(events []Event) {
+	if o.emit == nil {
+		return
+	}
+	for _, ev := range events {
+		o.emit(ev)
+	}
+}
+
+// ZoneState is one zone in /api/zones/state.
+type ZoneState struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	Occupied  bool           `json:"occupied"`
+	Occupants []ZoneOccupant `json:"occupants"`
+}
+
+// ZoneOccupant is a pet currently in a zone.
+type ZoneOccupant struct {
+	Class       string       `json:"class"`
+	PetID       string       `json:"pet_id,omitempty"`
+	Since       float64      `json:"since"` // Unix seconds, first seen in the zone
+	LastSeen    float64      `json:"last_seen"`
+	DurationSec float64      `json:"duration_sec"`
+	BBox        *BoundingBox `json:"bbox,omitempty"`
+}
+
+// State returns every zone with the pets that have entered it, in zone
+// order, longest stay first.
+func (o *ZoneOccupancy) State(now time.Time) []ZoneState {
+	zones := o.zones.Zones()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	out := make([]ZoneState, len(zones))
+	for i, z := range zones {
+		st := ZoneState{ID: z.ID, Name: z.Name, Occupants: []ZoneOccupant{}}
+		for key, occ := range o.occupants {
+			if key.zone != z.ID || !occ.entered {
+				continue
+			}
+			bbox := occ.bbox
+			st.Occupants = append(st.Occupants, ZoneOccupant{
+				Class:       key.class,
+				PetID:       key.petID,
+				Since:       float64(occ.firstSeen.UnixNano()) / 1e9,
+				LastSeen:    float64(occ.lastSeen.UnixNano()) / 1e9,
+				DurationSec: now.Sub(occ.firstSeen).Seconds(),
+				BBox:        &bbox,
+			})
+		}
+		slices.SortFunc(st.Occupants, func(a, b ZoneOccupant) int {
+			return cmp.Or(cmp.Compare(a.Since, b.Since), strings.Compare(a.Class, b.Class), strings.Compare(a.PetID, b.PetID))
+		})
+		st.Occupied = len(st.Occupants) > 0
+		out[i] = st
+	}
+	return out
+}
+
+// handleZonesState serves GET /api/zones/state: who is in which zone now.
+func (s *Server) handleZonesState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	now := time.Now()
+	writeJSON(w, map[string]any{
+		"timestamp": float64(now.UnixNano()) / 1e9,
+		"zones":     s.occupancy.State(now),
+	})
+}
+
+// handleZonesStream serves GET /api/zones/stream: SSE "state" with every
+// zone on connect, then a "zone" event per entry or exit carrying the
+// change and the zones after it.
+func (s *Server) handleZonesStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	ch, cancel := s.topics.events.SubscribeChan("zones_sse", 32)
+	defer cancel()
+	locale := s.requestLocale(r)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	if _, err := fmt.Fprint(w, "event: state\n"); err != nil {
+		return
+	}
+	if err := writeSSE(w, map[string]any{"zones": s.occupancy.State(time.Now())}); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	ctx := r.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			if ev.Type != EventZoneEntered && ev.Type != EventZoneLeft {
+				continue
+			}
+			if _, err := fmt.Fprint(w, "event: zone\n"); err != nil {
+				return
+			}
+			payload := map[string]any{
+				"change": labelEvents([]Event{ev}, locale)[0],
+				"zones":  s.occupancy.State(time.Now()),
+			}
+			if err := writeSSE(w, payload); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-time.After(30 * time.Second):
+			if _, err := fmt.Fprintf(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
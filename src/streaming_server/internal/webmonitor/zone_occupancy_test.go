@@ -0,0 +1,139 @@
+package webmonitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+)
+
+// bedZone is the left half of the frame.
+var bedZone = Zone{Name: "bed", Points: []MaskPoint{{0, 0}, {640, 0}, {640, 720}, {0, 720}}}
+
+func catOnBed(petID string, onBed bool) *DetectionResult {
+	cat := Detection{ClassName: "cat", PetID: petID, BBox: BoundingBox{X: 900, Y: 300, W: 200, H: 200}}
+	if onBed {
+		cat.BBox.X = 200
+	}
+	return &DetectionResult{Detections: []Detection{
+		cat,
+		{ClassName: "food_bowl", BBox: BoundingBox{X: 100, Y: 600, W: 80, H: 60}}, // not a pet: never occupies
+	}}
+}
+
+func TestZoneSet(t *testing.T) {
+	doc := zonesDoc.in(kv.NewMemory())
+	zs := NewZoneSet(doc)
+	for _, z := range []Zone{
+		{Points: bedZone.Points},
+		{Name: "bed", Points: bedZone.Points[:2]},
+		{Name: "bed", Points: []MaskPoint{{0, 0}, {1300, 0}, {0, 720}}},
+	} {
+		if _, err := zs.Put(z); err == nil {
+			t.Errorf("accepted %+v", z)
+		}
+	}
+	saved, err := zs.Put(bedZone)
+	if err != nil || saved.ID == "" {
+		t.Fatalf("put: %+v, %v", saved, err)
+	}
+	reloaded := NewZoneSet(doc)
+	if err := reloaded.Load(); err != nil || len(reloaded.Zones()) != 1 || reloaded.Zones()[0].Name != "bed" {
+		t.Fatalf("reloaded %+v, %v", reloaded.Zones(), err)
+	}
+	if ok, _ := zs.Delete(saved.ID); !ok || len(zs.Zones()) != 0 {
+		t.Errorf("delete: %v, %+v", ok, zs.Zones())
+	}
+}
+
+func TestZoneOccupancy(t *testing.T) {
+	zs := NewZoneSet(kv.Doc{})
+	bed, _ := zs.Put(bedZone)
+	var got []Event
+	rollup := NewDetectionRollup()
+	o := NewZoneOccupancy(zs, func(ev Event) { got = append(got, ev) }, rollup.RecordOccupancy)
+
+	start := time.Date(2026, 10, 16, 9, 59, 10, 0, time.UTC)
+	at := func(sec int) time.Time { return start.Add(time.Duration(sec) * time.Second) }
+
+	// Passing through, shorter than EnterAfter: nothing
+	o.Observe(catOnBed("", true), at(0))
+	o.Observe(catOnBed("", false), at(1))
+	o.Tick(at(20))
+	if len(got) != 0 || len(o.occupants) != 0 {
+		t.Fatalf("brief visit: %+v", got)
+	}
+
+	// Settles on the bed, identified halfway: one stay
+	for sec := 30; sec <= 60; sec++ {
+		petID := ""
+		if sec > 45 {
+			petID = "mike"
+		}
+		o.Observe(catOnBed(petID, true), at(sec))
+	}
+	if len(got) != 1 || got[0].Type != EventZoneEntered || got[0].Data["zone"] != "bed" || got[0].Data["zone_id"] != bed.ID {
+		t.Fatalf("entered: %+v", got)
+	}
+	st := o.State(at(60))
+	if len(st) != 1 || !st[0].Occupied || len(st[0].Occupants) != 1 {
+		t.Fatalf("state %+v", st)
+	}
+	if occ := st[0].Occupants[0]; occ.PetID != "mike" || occ.DurationSec != 30 {
+		t.Errorf("occupant %+v", occ)
+	}
+
+	// Walks off: left after LeaveAfter, with the whole stay
+	o.Observe(catOnBed("mike", false), at(61))
+	o.Tick(at(65))
+	if len(got) != 1 {
+		t.Fatal("left too early")
+	}
+	o.Tick(at(71))
+	if len(got) != 2 || got[1].Type != EventZoneLeft || got[1].Data["duration_sec"] != "30.0" || got[1].Data["pet_id"] != "mike" {
+		t.Fatalf("left: %+v", got)
+	}
+	if st := o.State(at(71)); st[0].Occupied {
+		t.Errorf("still occupied: %+v", st)
+	}
+
+	// The 30 s stay straddles 10:00 in the minute rollups
+	points, err := rollup.Query(rollupMinute, at(0), at(120), "cat", bed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 2 || points[0].OccupiedSec != 20 || points[1].OccupiedSec != 10 {
+		t.Errorf("minute occupancy %+v", points)
+	}
+	if points, _ := rollup.Query(rollupHour, at(0), at(120), "", bed.ID); len(points) != 2 || points[0].OccupiedSec+points[1].OccupiedSec != 30 {
+		t.Errorf("hour occupancy %+v", points)
+	}
+}
+
+func TestZonesStateHandler(t *testing.T) {
+	zs := NewZoneSet(kv.Doc{})
+	zs.Put(bedZone)
+	s := &Server{zones: zs, occupancy: NewZoneOccupancy(zs, nil, nil)}
+	now := time.Now()
+	s.occupancy.Observe(catOnBed("", true), now.Add(-5*time.Second))
+	s.occupancy.Observe(catOnBed("", true), now)
+
+	rec := httptest.NewRecorder()
+	s.handleZonesState(rec, httptest.NewRequest(http.MethodGet, "/api/zones/state", nil))
+	var resp struct {
+		Zones []ZoneState `json:"zones"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || len(resp.Zones) != 1 || !resp.Zones[0].Occupied {
+		t.Fatalf("state %d %+v %v", rec.Code, resp, err)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleZones(rec, httptest.NewRequest(http.MethodPost, "/api/zones", strings.NewReader(`{"name":"","points":[]}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid zone: %d", rec.Code)
+	}
+}
@@ -0,0 +1,209 @@
+package webmonitor
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/internal/kv"
+)
+
+// maxZones bounds the zones a user can draw.
+const maxZones = 16
+
+// Zone is a named region of the room, e.g. "bed" or "window", whose
+// occupancy is tracked (see ZoneOccupancy). A detection is in the zone when
+// its bbox center falls inside the polygon.
+type Zone struct {
+	ID      string      `json:"id"`
+	Name    string      `json:"name"`
+	Points  []MaskPoint `json:"points"`
+	Classes []string    `json:"classes,omitempty"` // empty = every pet class
+}
+
+// Validate checks the name and polygon and normalizes the class list.
+func (z *Zone) Validate() error {
+	z.Name = strings.TrimSpace(z.Name)
+	if z.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(z.Name) > maxMaskName {
+		return fmt.Errorf("name longer than %d bytes", maxMaskName)
+	}
+	if err := validatePolygon("zone", z.Points); err != nil {
+		return err
+	}
+	classes, err := normalizeClasses(z.Classes)
+	if err != nil {
+		return err
+	}
+	z.Classes = classes
+	return nil
+}
+
+// holds reports whether d counts as being in the zone.
+func (z *Zone) holds(d Detection) bool {
+	if len(z.Classes) > 0 {
+		if !slices.Contains(z.Classes, d.ClassName) {
+			return false
+		}
+	} else if !isPetClass(d.ClassName) {
+		return false
+	}
+	return polygonContains(z.Points, float64(d.BBox.X)+float64(d.BBox.W)/2, float64(d.BBox.Y)+float64(d.BBox.H)/2)
+}
+
+// ZoneSet holds the zones, persisted to doc (the zero Doc = in memory).
+type ZoneSet struct {
+	doc kv.Doc
+
+	mu    sync.RWMutex
+	zones []Zone
+}
+
+// NewZoneSet creates an empty zone set persisted to doc.
+func NewZoneSet(doc kv.Doc) *ZoneSet {
+	return &ZoneSet{doc: doc}
+}
+
+// Load replaces the zones with the persisted ones; without a document
+// there are none.
+func (zs *ZoneSet) Load() error {
+	var saved []Zone
+	if _, err := zs.doc.Load(&saved); err != nil {
+		return err
+	}
+	for i := range saved {
+		if err := saved[i].Validate(); err != nil {
+			return fmt.Errorf("zone %q: %w", saved[i].ID, err)
+		}
+	}
+	zs.mu.Lock()
+	defer zs.mu.Unlock()
+	zs.zones = saved
+	return nil
+}
+
+// Zones returns the zones.
+func (zs *ZoneSet) Zones() []Zone {
+	zs.mu.RLock()
+	defer zs.mu.RUnlock()
+	out := slices.Clone(zs.zones)
+	if out == nil {
+		out = []Zone{}
+	}
+	return out
+}
+
+// errTooManyZones is returned by Put when the set is full.
+var errTooManyZones = fmt.Errorf("at most %d zones", maxZones)
+
+// Put adds a zone (empty ID) or replaces the zone with z's ID.
+func (zs *ZoneSet) Put(z Zone) (Zone, error) {
+	if err := z.Validate(); err != nil {
+		return Zone{}, err
+	}
+	if z.ID == "" {
+		var b [6]byte
+		_, _ = rand.Read(b[:])
+		z.ID = hex.EncodeToString(b[:])
+	}
+
+	zs.mu.Lock()
+	defer zs.mu.Unlock()
+	if i := slices.IndexFunc(zs.zones, func(x Zone) bool { return x.ID == z.ID }); i >= 0 {
+		zs.zones[i] = z
+	} else if len(zs.zones) >= maxZones {
+		return Zone{}, errTooManyZones
+	} else {
+		zs.zones = append(zs.zones, z)
+	}
+	return z, zs.doc.Save(zs.zones)
+}
+
+// Delete removes a zone. Returns false if it does not exist.
+func (zs *ZoneSet) Delete(id string) (bool, error) {
+	zs.mu.Lock()
+	defer zs.mu.Unlock()
+	i := slices.IndexFunc(zs.zones, func(x Zone) bool { return x.ID == id })
+	if i < 0 {
+		return false, nil
+	}
+	zs.zones = slices.Delete(zs.zones, i, i+1)
+	return true, zs.doc.Save(zs.zones)
+}
+
+// handleZones serves GET /api/zones and POST (add a zone).
+func (s *Server) handleZones(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, map[string]any{"zones": s.zones.Zones()})
+	case http.MethodPost:
+		var z Zone
+		if err := json.NewDecoder(r.Body).Decode(&z); err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": "invalid JSON: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		z.ID = ""
+		saved, err := s.zones.Put(z)
+		if err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, zoneErrorStatus(err))
+			return
+		}
+		writeJSONWithStatus(w, saved, http.StatusCreated)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleZone serves PUT (replace) and DELETE on /api/zones/{id}.
+func (s *Server) handleZone(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/zones/")
+	if id == "" || strings.Contains(id, "/") {
+		http.Error(w, "Invalid zone id", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var z Zone
+		if err := json.NewDecoder(r.Body).Decode(&z); err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": "invalid JSON: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		z.ID = id
+		saved, err := s.zones.Put(z)
+		if err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, zoneErrorStatus(err))
+			return
+		}
+		writeJSON(w, saved)
+	case http.MethodDelete:
+		found, err := s.zones.Delete(id)
+		if err != nil {
+			writeJSONWithStatus(w, map[string]any{"error": err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			writeJSONWithStatus(w, map[string]any{"error": "not found"}, http.StatusNotFound)
+			return
+		}
+		writeJSON(w, map[string]any{"deleted": true, "id": id})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// zoneErrorStatus maps a Put error like maskErrorStatus.
+func zoneErrorStatus(err error) int {
+	if errors.Is(err, errTooManyZones) {
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
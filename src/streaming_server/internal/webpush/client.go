@@ -0,0 +1,79 @@
+package webpush
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrSubscriptionGone is returned when the push service reports the
+// subscription expired or was revoked (404/410); callers should drop it.
+var ErrSubscriptionGone = errors.New("webpush: subscription gone")
+
+// Subscription mirrors the browser PushSubscription JSON.
+type Subscription struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+// Client sends encrypted, VAPID-signed push messages.
+type Client struct {
+	Keys    *VAPIDKeys
+	Subject string // contact URI, e.g. "mailto:admin@example.com"
+	HTTP    *http.Client
+}
+
+// NewClient creates a client with a 10s HTTP timeout.
+func NewClient(keys *VAPIDKeys, subject string) *Client {
+	return &Client{
+		Keys:    keys,
+		Subject: subject,
+		HTTP:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Send delivers payload to sub. ttl is how long the push service may queue
+// the message while the browser is offline.
+func (c *Client) Send(ctx context.Context, sub Subscription, payload []byte, ttl time.Duration) error {
+	body, err := Encrypt(payload, sub.Keys.P256dh, sub.Keys.Auth)
+	if err != nil {
+		return err
+	}
+	auth, err := c.Keys.authorization(sub.Endpoint, c.Subject, time.Now())
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", strconv.Itoa(int(ttl.Seconds())))
+	req.Header.Set("Urgency", "high")
+	req.Header.Set("Authorization", auth)
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrSubscriptionGone
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("webpush: push service returned %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+// Package webpush implements Web Push message encryption (RFC 8291,
+// aes128gcm) and VAPID authentication (RFC 8292) using only the standard
+// library.
+package webpush
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	// recordSize is the aes128gcm record size. Payloads are sent as a single
+	// record, so plaintext + delimiter + tag must fit.
+	recordSize = 4096
+
+	// MaxPayload is the largest payload that fits in one record.
+	MaxPayload = recordSize - 16 - 1
+)
+
+var ErrPayloadTooLarge = errors.New("webpush: payload too large")
+
+// decodeKey decodes base64url (padded or not) as sent by browsers.
+func decodeKey(s string) ([]byte, error) {
+	if b, err := base64.RawURLEncoding.DecodeString(s); err == nil {
+		return b, nil
+	}
+	return base64.URLEncoding.DecodeString(s)
+}
+
+// Encrypt encrypts payload for a subscription's p256dh public key and auth
+// secret (both base64url), returning the aes128gcm request body.
+func Encrypt(payload []byte, p256dh, auth string) ([]byte, error) {
+	uaPubBytes, err := decodeKey(p256dh)
+	if err != nil {
+		return nil, fmt.Errorf("webpush: invalid p256dh: %w", err)
+	}
+	authSecret, err := decodeKey(auth)
+	if err != nil {
+		return nil, fmt.Errorf("webpush: invalid auth: %w", err)
+	}
+	asPriv, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return encrypt(payload, uaPubBytes, authSecret, asPriv, salt)
+}
+
+func encrypt(payload, uaPubBytes, authSecret []byte, asPriv *ecdh.PrivateKey, salt []byte) ([]byte, error) {
+	if len(payload) > MaxPayload {
+		return nil, ErrPayloadTooLarge
+	}
+	uaPub, err := ecdh.P256().NewPublicKey(uaPubBytes)
+	if err != nil {
+		return nil, fmt.Errorf("webpush: invalid p256dh: %w", err)
+	}
+	if len(authSecret) != 16 {
+		return nil, fmt.Errorf("webpush: auth secret must be 16 bytes, got %d", len(authSecret))
+	}
+
+	shared, err := asPriv.ECDH(uaPub)
+	if err != nil {
+		return nil, err
+	}
+	asPubBytes := asPriv.PublicKey().Bytes()
+
+	cek, nonce, err := deriveKeys(shared, authSecret, salt, uaPubBytes, asPubBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// Single (last) record: payload || 0x02 delimiter, no padding
+	plaintext := make([]byte, len(payload)+1)
+	copy(plaintext, payload)
+	plaintext[len(payload)] = 0x02
+
+	// Header: salt(16) || rs(4) || idlen(1) || keyid(as public key)
+	body := make([]byte, 0, 16+4+1+len(asPubBytes)+len(plaintext)+gcm.Overhead())
+	body = append(body, salt...)
+	body = binary.BigEndian.AppendUint32(body, recordSize)
+	body = append(body, byte(len(asPubBytes)))
+	body = append(body, asPubBytes...)
+	return gcm.Seal(body, nonce, plaintext, nil), nil
+}
+
+// deriveKeys derives the content encryption key and nonce (RFC 8291 §3.4).
+func deriveKeys(shared, authSecret, salt, uaPub, asPub []byte) (cek, nonce []byte, err error) {
+	prkKey, err := hkdf.Extract(sha256.New, shared, authSecret)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyInfo := "WebPush: info\x00" + string(uaPub) + string(asPub)
+	ikm, err := hkdf.Expand(sha256.New, prkKey, keyInfo, 32)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, nil, err
+	}
+	cek, err = hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce, err = hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cek, nonce, nil
+}
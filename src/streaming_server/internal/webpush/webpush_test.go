@@ -0,0 +1,174 @@
+package webpush
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// decrypt is the user-agent side of RFC 8291, used to verify Encrypt.
+func decrypt(t *testing.T, body []byte, uaPriv *ecdh.PrivateKey, authSecret []byte) []byte {
+	t.Helper()
+	salt := body[:16]
+	rs := binary.BigEndian.Uint32(body[16:20])
+	if rs != recordSize {
+		t.Fatalf("rs = %d", rs)
+	}
+	idlen := int(body[20])
+	asPubBytes := body[21 : 21+idlen]
+	ciphertext := body[21+idlen:]
+
+	asPub, err := ecdh.P256().NewPublicKey(asPubBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared, err := uaPriv.ECDH(asPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cek, nonce, err := deriveKeys(shared, authSecret, salt, uaPriv.PublicKey().Bytes(), asPubBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := aes.NewCipher(cek)
+	gcm, _ := cipher.NewGCM(block)
+	plain, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if plain[len(plain)-1] != 0x02 {
+		t.Fatalf("missing last-record delimiter")
+	}
+	return plain[:len(plain)-1]
+}
+
+func newUA(t *testing.T) (*ecdh.PrivateKey, []byte, Subscription) {
+	t.Helper()
+	uaPriv, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := make([]byte, 16)
+	_, _ = rand.Read(auth)
+	var sub Subscription
+	sub.Keys.P256dh = base64.RawURLEncoding.EncodeToString(uaPriv.PublicKey().Bytes())
+	sub.Keys.Auth = base64.RawURLEncoding.EncodeToString(auth)
+	return uaPriv, auth, sub
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	uaPriv, auth, sub := newUA(t)
+	payload := []byte(`{"title":"Cat detected"}`)
+
+	body, err := Encrypt(payload, sub.Keys.P256dh, sub.Keys.Auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decrypt(t, body, uaPriv, auth); string(got) != string(payload) {
+		t.Fatalf("decrypted %q, want %q", got, payload)
+	}
+
+	if _, err := Encrypt(make([]byte, MaxPayload+1), sub.Keys.P256dh, sub.Keys.Auth); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Fatalf("expected ErrPayloadTooLarge, got %v", err)
+	}
+}
+
+func TestVAPIDAuthorization(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vapid.pem")
+	keys, err := LoadOrCreateVAPIDKeys(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := LoadOrCreateVAPIDKeys(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys.PublicKey() != reloaded.PublicKey() {
+		t.Fatal("public key changed after reload")
+	}
+
+	header, err := keys.authorization("https://push.example.com/send/abc", "mailto:a@example.com", time.Unix(1000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jwt, k string
+	for _, part := range strings.Split(strings.TrimPrefix(header, "vapid "), ", ") {
+		switch {
+		case strings.HasPrefix(part, "t="):
+			jwt = part[2:]
+		case strings.HasPrefix(part, "k="):
+			k = part[2:]
+		}
+	}
+	if k != keys.PublicKey() {
+		t.Errorf("k = %q, want public key", k)
+	}
+
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("jwt has %d parts", len(parts))
+	}
+	claimsJSON, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var claims map[string]any
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims["aud"] != "https://push.example.com" {
+		t.Errorf("aud = %v", claims["aud"])
+	}
+
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+	if !ecdsa.Verify(&keys.private.PublicKey, digest[:], r, s) {
+		t.Fatal("JWT signature does not verify")
+	}
+}
+
+func TestClientSend(t *testing.T) {
+	uaPriv, auth, sub := newUA(t)
+	keys, _ := GenerateVAPIDKeys()
+
+	var got []byte
+	status := http.StatusCreated
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "aes128gcm" || !strings.HasPrefix(r.Header.Get("Authorization"), "vapid t=") {
+			t.Errorf("missing push headers: %v", r.Header)
+		}
+		body, _ := io.ReadAll(r.Body)
+		got = decrypt(t, body, uaPriv, auth)
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+	sub.Endpoint = ts.URL + "/push/1"
+
+	c := NewClient(keys, "mailto:a@example.com")
+	if err := c.Send(context.Background(), sub, []byte("hello"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "hello" {
+		t.Fatalf("push service received %q", got)
+	}
+
+	status = http.StatusGone
+	if err := c.Send(context.Background(), sub, []byte("hello"), time.Minute); !errors.Is(err, ErrSubscriptionGone) {
+		t.Fatalf("expected ErrSubscriptionGone, got %v", err)
+	}
+}
@@ -0,0 +1,356 @@
+// Package client is a Go client for the pet camera's HTTP API as served by
+// the web monitor (port 8080): status, WebRTC signaling, recording control,
+// server-sent event streams, snapshots and recording downloads.
+//
+// Every call takes a context. Idempotent requests (GET) are retried on
+// network errors and 502/503/504 answers; requests that change state are
+// sent once. The web monitor has no WebSocket endpoints, so event streams
+// are read as server-sent events.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client talks to one camera. Its fields may be changed before the first
+// call.
+type Client struct {
+	// BaseURL is the web monitor's URL, e.g. "http://camera.local:8080".
+	BaseURL string
+	// HTTP sends the requests. Its Timeout should be zero: streams and
+	// downloads run until their context ends; calls are bounded by Timeout.
+	HTTP *http.Client
+	// Timeout bounds each non-streaming call that has no context deadline.
+	Timeout time.Duration
+	// Token is sent as a Bearer token when set.
+	Token string
+	// Retries is how often a failed GET is retried, RetryDelay the wait
+	// before the first retry (doubling after each).
+	Retries    int
+	RetryDelay time.Duration
+}
+
+// New creates a client for baseURL: an http(s) URL, or "unix:/path" for a
+// web monitor listening on a Unix socket (-socket). The client keeps
+// cookies, so recordings it starts are attributed to one session.
+func New(baseURL string) *Client {
+	jar, _ := cookiejar.New(nil)
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if path, ok := strings.CutPrefix(baseURL, "unix:"); ok {
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		}
+		baseURL = "http://localhost"
+	}
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTP:       &http.Client{Transport: transport, Jar: jar},
+		Timeout:    10 * time.Second,
+		Retries:    2,
+		RetryDelay: 250 * time.Millisecond,
+	}
+}
+
+// APIError is a non-2xx answer. Message is the API's "error" field, or the
+// HTTP status text when the body has none.
+type APIError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.StatusCode, e.Message)
+}
+
+// IsStatus reports whether err is an APIError with the given status code.
+func IsStatus(err error, code int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == code
+}
+
+// retryable reports whether a GET that failed with err or code is worth
+// repeating.
+func retryable(err error, code int) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	return code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+// send issues a request and returns the response when it is 2xx; the caller
+// closes the body. GETs are retried (see Client.Retries).
+func (c *Client) send(ctx context.Context, method, path string, body []byte, header http.Header) (*http.Response, error) {
+	attempts := 1
+	if method == http.MethodGet {
+		attempts += max(c.Retries, 0)
+	}
+	delay := c.RetryDelay
+	for attempt := 1; ; attempt++ {
+		resp, err := c.sendOnce(ctx, method, path, body, header)
+		if err == nil && resp.StatusCode < 300 {
+			return resp, nil
+		}
+		again := err != nil && retryable(err, 0)
+		if err == nil {
+			again = retryable(nil, resp.StatusCode)
+			err = readAPIError(resp, method, path)
+		}
+		if attempt >= attempts || !again {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (c *Client) sendOnce(ctx context.Context, method, path string, body []byte, header http.Header) (*http.Response, error) {
+	var rd io.Reader
+	if body != nil {
+		rd = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, rd)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	return resp, nil
+}
+
+// readAPIError turns a non-2xx response into an APIError and closes it.
+func readAPIError(resp *http.Response, method, path string) error {
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var body struct {
+		Error string `json:"error"`
+	}
+	msg := http.StatusText(resp.StatusCode)
+	if json.Unmarshal(data, &body) == nil && body.Error != "" {
+		msg = body.Error
+	} else if text := strings.TrimSpace(string(data)); text != "" && len(text) < 200 {
+		msg = text
+	}
+	return &APIError{Method: method, Path: path, StatusCode: resp.StatusCode, Message: msg}
+}
+
+// call sends a JSON request (in may be nil) and decodes the JSON answer into
+// out (may be nil), within Client.Timeout.
+func (c *Client) call(ctx context.Context, method, path string, in, out any) error {
+	if _, ok := ctx.Deadline(); !ok && c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+	resp, err := c.send(ctx, method, path, body, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s: decode: %w", method, path, err)
+	}
+	return nil
+}
+
+// Status returns GET /api/status.
+func (c *Client) Status(ctx context.Context) (*Status, error) {
+	var st Status
+	if err := c.call(ctx, http.MethodGet, "/api/status", nil, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// ICEServers returns the ICE servers for a peer connection
+// (GET /api/webrtc/config) and when their TURN credentials expire (zero
+// without expiring credentials).
+func (c *Client) ICEServers(ctx context.Context) ([]ICEServer, time.Time, error) {
+	var resp struct {
+		ICEServers []ICEServer `json:"ice_servers"`
+		ExpiresAt  int64       `json:"expires_at"`
+	}
+	if err := c.call(ctx, http.MethodGet, "/api/webrtc/config", nil, &resp); err != nil {
+		return nil, time.Time{}, err
+	}
+	var expires time.Time
+	if resp.ExpiresAt > 0 {
+		expires = time.Unix(resp.ExpiresAt, 0)
+	}
+	return resp.ICEServers, expires, nil
+}
+
+// Offer sends a WebRTC offer (POST /api/webrtc/offer) and returns the
+// answer. The camera is ICE-lite and answers with all its candidates, so
+// there is no trickle step.
+func (c *Client) Offer(ctx context.Context, offer SessionDescription) (*SessionDescription, error) {
+	var answer SessionDescription
+	if err := c.call(ctx, http.MethodPost, "/api/webrtc/offer", offer, &answer); err != nil {
+		return nil, err
+	}
+	return &answer, nil
+}
+
+// StartRecording starts a recording (POST /api/recording/start) owned by
+// this client's session; name labels the owner on dashboards ("" = none).
+// A recording already running is an APIError with status 409.
+func (c *Client) StartRecording(ctx context.Context, name string) (*RecordingStarted, error) {
+	var in any
+	if name != "" {
+		in = map[string]string{"name": name}
+	}
+	var started RecordingStarted
+	if err := c.call(ctx, http.MethodPost, "/api/recording/start", in, &started); err != nil {
+		return nil, err
+	}
+	return &started, nil
+}
+
+// RecordFor records for d without heartbeats (POST /api/record): the
+// recording stops by itself at the end of the GOP running d from now. An
+// active timed recording is extended instead; a recording started with
+// StartRecording is a 409.
+func (c *Client) RecordFor(ctx context.Context, d time.Duration, name string) (*TimedRecording, error) {
+	var in any
+	if name != "" {
+		in = map[string]string{"name": name}
+	}
+	var rec TimedRecording
+	if err := c.call(ctx, http.MethodPost, "/api/record?duration="+url.QueryEscape(d.String()), in, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// Heartbeat keeps the active recording alive (POST
+// /api/recording/heartbeat); the recorder stops on its own when heartbeats
+// cease.
+func (c *Client) Heartbeat(ctx context.Context) error {
+	return c.call(ctx, http.MethodPost, "/api/recording/heartbeat", nil, nil)
+}
+
+// StopRecording stops the active recording (POST /api/recording/stop).
+func (c *Client) StopRecording(ctx context.Context) (*RecordingStopped, error) {
+	var stopped RecordingStopped
+	if err := c.call(ctx, http.MethodPost, "/api/recording/stop", nil, &stopped); err != nil {
+		return nil, err
+	}
+	return &stopped, nil
+}
+
+// RecorderStatus returns GET /api/recording/status.
+func (c *Client) RecorderStatus(ctx context.Context) (*RecorderStatus, error) {
+	var st RecorderStatus
+	if err := c.call(ctx, http.MethodGet, "/api/recording/status", nil, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// Recordings lists the finished recordings (GET /api/recordings).
+func (c *Client) Recordings(ctx context.Context) ([]Recording, error) {
+	var resp struct {
+		Recordings []Recording `json:"recordings"`
+	}
+	if err := c.call(ctx, http.MethodGet, "/api/recordings", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Recordings, nil
+}
+
+// Events returns up to limit events after since (Unix seconds) from
+// GET /api/events, optionally only of the given types.
+func (c *Client) Events(ctx context.Context, since float64, limit int, types ...string) ([]Event, error) {
+	q := url.Values{}
+	if since > 0 {
+		q.Set("since", strconv.FormatFloat(since, 'f', -1, 64))
+	}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	if len(types) > 0 {
+		q.Set("type", strings.Join(types, ","))
+	}
+	var resp struct {
+		Events []Event `json:"events"`
+	}
+	if err := c.call(ctx, http.MethodGet, "/api/events?"+q.Encode(), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Events, nil
+}
+
+// Snapshot returns the current frame as JPEG (GET /api/snapshot).
+func (c *Client) Snapshot(ctx context.Context) ([]byte, error) {
+	if _, ok := ctx.Deadline(); !ok && c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	resp, err := c.send(ctx, http.MethodGet, "/api/snapshot", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// DownloadRecording copies recording name (as listed by Recordings) to w
+// and returns the bytes written. Only ctx bounds it: clips can be large.
+func (c *Client) DownloadRecording(ctx context.Context, name string, w io.Writer) (int64, error) {
+	return c.download(ctx, "/api/recordings/"+url.PathEscape(name), w)
+}
+
+// DownloadProxy copies the low-res proxy of recording name (listed when
+// Recording.Proxy is set) to w.
+func (c *Client) DownloadProxy(ctx context.Context, name string, w io.Writer) (int64, error) {
+	return c.download(ctx, "/api/recordings/"+url.PathEscape(name)+"?quality=proxy", w)
+}
+
+func (c *Client) download(ctx context.Context, path string, w io.Writer) (int64, error) {
+	resp, err := c.send(ctx, http.MethodGet, path, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return io.Copy(w, resp.Body)
+}
@@ -0,0 +1,180 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T, h http.Handler) *Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	c := New(srv.URL)
+	c.RetryDelay = time.Millisecond
+	return c
+}
+
+func TestRetriesOnlyIdempotentCalls(t *testing.T) {
+	var gets, posts atomic.Int32
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			posts.Add(1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"error":"Go server unavailable"}`)
+			return
+		}
+		if gets.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"monitor":{"current_fps":29.5},"timestamp":1}`)
+	}))
+	ctx := context.Background()
+
+	st, err := c.Status(ctx)
+	if err != nil || st.Monitor.CurrentFPS != 29.5 || gets.Load() != 3 {
+		t.Fatalf("Status = %+v, %v after %d GETs", st, err, gets.Load())
+	}
+
+	_, err = c.Offer(ctx, SessionDescription{Type: "offer", SDP: "v=0"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable ||
+		apiErr.Message != "Go server unavailable" || posts.Load() != 1 {
+		t.Fatalf("Offer err = %v after %d POSTs", err, posts.Load())
+	}
+}
+
+func TestRecordingSessionKeepsCookie(t *testing.T) {
+	var owner string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/recording/start", func(w http.ResponseWriter, r *http.Request) {
+		if owner != "" {
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, `{"error":"recording already active"}`)
+			return
+		}
+		owner = "s1"
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: owner, Path: "/"})
+		fmt.Fprint(w, `{"status":"recording","file":"a.h265","owner":{"id":"s1"}}`)
+	})
+	mux.HandleFunc("POST /api/recording/stop", func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie("session"); err != nil || cookie.Value != owner {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{"status":"stopped","file":"a.h265","stats":{"frame_count":42}}`)
+	})
+	c := newTestClient(t, mux)
+	ctx := context.Background()
+
+	started, err := c.StartRecording(ctx, "test")
+	if err != nil || started.File != "a.h265" || started.Owner.ID != "s1" {
+		t.Fatalf("StartRecording = %+v, %v", started, err)
+	}
+	if _, err := c.StartRecording(ctx, ""); !IsStatus(err, http.StatusConflict) {
+		t.Fatalf("second StartRecording err = %v, want 409", err)
+	}
+	stopped, err := c.StopRecording(ctx)
+	if err != nil || stopped.Stats.FrameCount != 42 {
+		t.Fatalf("StopRecording = %+v, %v", stopped, err)
+	}
+}
+
+func TestRecordFor(t *testing.T) {
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/record" || r.URL.Query().Get("duration") != "1m30s" {
+			t.Errorf("%s %s", r.Method, r.URL)
+		}
+		fmt.Fprint(w, `{"file":"a.hevc","until":"2026-10-16T12:01:30Z","extended":true,"event_id":"e1"}`)
+	}))
+	rec, err := c.RecordFor(context.Background(), 90*time.Second, "")
+	if err != nil || rec.File != "a.hevc" || !rec.Extended || rec.Until.Minute() != 1 {
+		t.Fatalf("RecordFor = %+v, %v", rec, err)
+	}
+}
+
+func TestStreamReconnects(t *testing.T) {
+	var conns atomic.Int32
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		switch conns.Add(1) {
+		case 1:
+			fmt.Fprint(w, ": keepalive\n\nid: 7\nevent: alerts\ndata: {\"a\":1}\n\n")
+		default:
+			if r.Header.Get("Last-Event-ID") != "7" {
+				t.Errorf("Last-Event-ID = %q", r.Header.Get("Last-Event-ID"))
+			}
+			fmt.Fprint(w, "data: line1\ndata: line2\n\n")
+		}
+	}))
+
+	var events []SSEEvent
+	done := errors.New("done")
+	err := c.Stream(context.Background(), "/api/alerts/stream", func(ev SSEEvent) error {
+		events = append(events, ev)
+		if len(events) == 2 {
+			return done
+		}
+		return nil
+	})
+	if err != done || conns.Load() != 2 {
+		t.Fatalf("Stream err = %v after %d connections", err, conns.Load())
+	}
+	if events[0].Event != "alerts" || events[0].ID != "7" || string(events[0].Data) != `{"a":1}` {
+		t.Errorf("first event %+v", events[0])
+	}
+	if events[1].Event != "" || string(events[1].Data) != "line1\nline2" {
+		t.Errorf("second event %+v", events[1])
+	}
+}
+
+func TestStreamStopsOnClientError(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{}`)
+	})
+	c := newTestClient(t, mux)
+	noop := func(SSEEvent) error { return nil }
+	if err := c.Stream(context.Background(), "/api/nope", noop); !IsStatus(err, http.StatusNotFound) {
+		t.Fatalf("Stream err = %v, want 404", err)
+	}
+	if err := c.Stream(context.Background(), "/api/status", noop); !errors.Is(err, ErrNotEventStream) {
+		t.Fatalf("Stream err = %v, want ErrNotEventStream", err)
+	}
+}
+
+func TestUnixSocketDownload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "monitor.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/recordings/clip 1.mp4" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("mp4 data"))
+	})}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+
+	c := New("unix:" + path)
+	var buf bytes.Buffer
+	if n, err := c.DownloadRecording(context.Background(), "clip 1.mp4", &buf); err != nil || n != 8 || buf.String() != "mp4 data" {
+		t.Fatalf("DownloadRecording = %d %q, %v", n, buf.String(), err)
+	}
+	if _, err := c.DownloadRecording(context.Background(), "missing.mp4", &buf); !IsStatus(err, http.StatusNotFound) {
+		t.Fatalf("missing download err = %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SSEEvent is one server-sent event. Event is "" for unnamed events.
+type SSEEvent struct {
+	Event string
+	ID    string
+	Data  []byte
+}
+
+// ErrNotEventStream is returned by Stream when path answers with something
+// other than text/event-stream: a wrong path, not a dropped connection.
+var ErrNotEventStream = errors.New("not an event stream")
+
+// maxReconnectDelay caps the wait between stream reconnects.
+const maxReconnectDelay = 10 * time.Second
+
+// Stream reads the server-sent event stream at path (e.g.
+// "/api/alerts/stream") and calls fn for every event until ctx ends or fn
+// returns an error, which Stream returns. A dropped connection, or a stream
+// that cannot be opened, is retried after RetryDelay, doubling up to 10s; an
+// answer other than 5xx ends the stream with its APIError, one that is not
+// an event stream with ErrNotEventStream. The context's error is returned
+// when it ends.
+func (c *Client) Stream(ctx context.Context, path string, fn func(SSEEvent) error) error {
+	delay := c.RetryDelay
+	lastID := ""
+	for {
+		received, fnErr, err := c.streamOnce(ctx, path, lastID, func(ev SSEEvent) error {
+			if ev.ID != "" {
+				lastID = ev.ID
+			}
+			return fn(ev)
+		})
+		if fnErr != nil {
+			return fnErr
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode < 500 || errors.Is(err, ErrNotEventStream) {
+			return err
+		}
+		if received {
+			delay = c.RetryDelay
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(max(delay*2, time.Millisecond), maxReconnectDelay)
+	}
+}
+
+// streamOnce reads one connection of the stream until it ends (err, nil
+// when the server closed it) or fn fails (fnErr); received reports whether
+// any event arrived.
+func (c *Client) streamOnce(ctx context.Context, path, lastID string, fn func(SSEEvent) error) (received bool, fnErr, err error) {
+	header := http.Header{"Accept": {"text/event-stream"}}
+	if lastID != "" {
+		header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := c.sendOnce(ctx, http.MethodGet, path, nil, header)
+	if err != nil {
+		return false, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return false, nil, readAPIError(resp, http.MethodGet, path)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		return false, nil, fmt.Errorf("GET %s: %w (Content-Type %q)", path, ErrNotEventStream, ct)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4<<20) // status events with history are large
+	var ev SSEEvent
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if data == nil {
+				ev = SSEEvent{}
+				continue
+			}
+			ev.Data = []byte(strings.Join(data, "\n"))
+			received = true
+			if err := fn(ev); err != nil {
+				return received, err, nil
+			}
+			ev, data = SSEEvent{}, nil
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue // comment, e.g. keepalive
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			ev.Event = value
+		case "id":
+			ev.ID = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	return received, nil, scanner.Err()
+}
+
+// StatusStream calls fn with every status update from /api/status/stream;
+// see Stream for reconnects and the returned error.
+func (c *Client) StatusStream(ctx context.Context, fn func(*Status) error) error {
+	return c.Stream(ctx, "/api/status/stream", func(ev SSEEvent) error {
+		var st Status
+		if err := json.Unmarshal(ev.Data, &st); err != nil {
+			return err
+		}
+		return fn(&st)
+	})
+}
+
+// DetectionStream calls fn with every detection update from
+// /api/detections/stream; see Stream for reconnects and the returned error.
+func (c *Client) DetectionStream(ctx context.Context, fn func(*DetectionEvent) error) error {
+	return c.Stream(ctx, "/api/detections/stream", func(ev SSEEvent) error {
+		var de DetectionEvent
+		if err := json.Unmarshal(ev.Data, &de); err != nil {
+			return err
+		}
+		return fn(&de)
+	})
+}
@@ -0,0 +1,157 @@
+package client
+
+import "time"
+
+// BoundingBox is a detection's box in frame pixels.
+type BoundingBox struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// Detection is one detected object.
+type Detection struct {
+	ClassName  string      `json:"class_name"`
+	Confidence float64     `json:"confidence"`
+	BBox       BoundingBox `json:"bbox"`
+	PetID      string      `json:"pet_id,omitempty"`
+}
+
+// DetectionResult is the detections of one frame.
+type DetectionResult struct {
+	FrameNumber   int         `json:"frame_number"`
+	Timestamp     float64     `json:"timestamp"`
+	NumDetections int         `json:"num_detections"`
+	Version       int         `json:"version"`
+	Detections    []Detection `json:"detections"`
+	Source        string      `json:"source,omitempty"`
+}
+
+// MonitorStats is the web monitor's frame and detection counters.
+type MonitorStats struct {
+	FramesProcessed int     `json:"frames_processed"`
+	CurrentFPS      float64 `json:"current_fps"`
+	DetectionCount  int     `json:"detection_count"`
+	TargetFPS       int     `json:"target_fps"`
+}
+
+// SharedMemoryStats is the camera's shared memory counters.
+type SharedMemoryStats struct {
+	FrameCount         int `json:"frame_count"`
+	TotalFramesWritten int `json:"total_frames_written"`
+	DetectionVersion   int `json:"detection_version"`
+	HasDetection       int `json:"has_detection"`
+}
+
+// Status is GET /api/status, and each event of StatusStream.
+type Status struct {
+	Monitor          MonitorStats      `json:"monitor"`
+	SharedMemory     SharedMemoryStats `json:"shared_memory"`
+	LatestDetection  *DetectionResult  `json:"latest_detection"`
+	DetectionHistory []DetectionResult `json:"detection_history"`
+	Timestamp        float64           `json:"timestamp"`
+}
+
+// DetectionEvent is one event of DetectionStream.
+type DetectionEvent struct {
+	FrameNumber int         `json:"frame_number"`
+	Timestamp   float64     `json:"timestamp"`
+	Detections  []Detection `json:"detections"`
+}
+
+// ICEServer is a STUN or TURN server for the peer connection.
+type ICEServer struct {
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+}
+
+// SessionDescription is a WebRTC offer or answer; SessionID is set on
+// answers.
+type SessionDescription struct {
+	Type      string `json:"type"`
+	SDP       string `json:"sdp"`
+	SessionID string `json:"session_id,omitempty"`
+	E2E       *E2E   `json:"e2e,omitempty"`
+}
+
+// E2E requests end-to-end frame encryption in an offer (Profile, Nonce) and
+// carries the frame key grant in the answer (the rest). An answer without
+// it means the profile receives the plain stream.
+type E2E struct {
+	Profile     string `json:"profile,omitempty"`
+	Nonce       []byte `json:"nonce,omitempty"` // fresh per offer, at least 16 bytes
+	KID         uint64 `json:"kid,omitempty"`
+	CipherSuite int    `json:"cipher_suite,omitempty"`
+	WrappedKey  []byte `json:"wrapped_key,omitempty"`
+}
+
+// RecordingOwner identifies who started a recording.
+type RecordingOwner struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+// RecordingStarted is the answer to StartRecording.
+type RecordingStarted struct {
+	Status             string         `json:"status"` // "recording"
+	File               string         `json:"file"`
+	Owner              RecordingOwner `json:"owner"`
+	StartedAt          float64        `json:"started_at"`
+	WaitingForKeyframe bool           `json:"waiting_for_keyframe"`
+}
+
+// TimedRecording is the answer to RecordFor.
+type TimedRecording struct {
+	File     string    `json:"file"`
+	Until    time.Time `json:"until"`
+	Extended bool      `json:"extended"` // an active timed recording was extended
+	EventID  string    `json:"event_id"`
+}
+
+// RecordingStopped is the answer to StopRecording.
+type RecordingStopped struct {
+	Status    string         `json:"status"` // "stopped"
+	File      string         `json:"file"`
+	Stats     RecorderStatus `json:"stats"`
+	StoppedAt float64        `json:"stopped_at"`
+}
+
+// RecorderStatus is GET /api/recording/status.
+type RecorderStatus struct {
+	Recording          bool           `json:"recording"`
+	Converting         bool           `json:"converting"`
+	Filename           string         `json:"filename"`
+	FrameCount         int            `json:"frame_count"`
+	BytesWritten       int64          `json:"bytes_written"`
+	DurationMs         int64          `json:"duration_ms"`
+	StopReason         string         `json:"stop_reason"`
+	WaitingForKeyframe bool           `json:"waiting_for_keyframe"`
+	SkippedFrames      int            `json:"skipped_frames"`
+	StartedAtUTC       string         `json:"started_at_utc"`
+	ClockJumpSec       float64        `json:"clock_jump_sec"`
+	Owner              RecordingOwner `json:"owner"`
+}
+
+// Recording is a finished recording as listed by Recordings.
+type Recording struct {
+	Name       string    `json:"name"`
+	SizeBytes  int64     `json:"size_bytes"`
+	CreatedAt  time.Time `json:"created_at"`
+	Thumbnail  string    `json:"thumbnail,omitempty"`
+	Proxy      string    `json:"proxy,omitempty"` // low-res copy, see DownloadProxy
+	Corrupt    bool      `json:"corrupt,omitempty"`
+	ScrubError string    `json:"scrub_error,omitempty"`
+}
+
+// Event is an occurrence derived from the detection stream
+// ("feeding_started", "recording_started", ...).
+type Event struct {
+	ID        uint64            `json:"id"`
+	Type      string            `json:"type"`
+	Timestamp float64           `json:"timestamp"`
+	Class     string            `json:"class,omitempty"`
+	BBox      *BoundingBox      `json:"bbox,omitempty"`
+	Data      map[string]string `json:"data,omitempty"`
+}
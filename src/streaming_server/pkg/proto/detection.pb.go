@@ -89,19 +89,90 @@ func (x *BBox) GetH() int32 {
 	return 0
 }
 
+// Bounding box in fractions (0-1) of the video's width and height.
+type NormBBox struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	X             float32                `protobuf:"fixed32,1,opt,name=x,proto3" json:"x,omitempty"`
+	Y             float32                `protobuf:"fixed32,2,opt,name=y,proto3" json:"y,omitempty"`
+	W             float32                `protobuf:"fixed32,3,opt,name=w,proto3" json:"w,omitempty"`
+	H             float32                `protobuf:"fixed32,4,opt,name=h,proto3" json:"h,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NormBBox) Reset() {
+	*x = NormBBox{}
+	mi := &file_proto_detection_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NormBBox) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NormBBox) ProtoMessage() {}
+
+func (x *NormBBox) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_detection_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NormBBox.ProtoReflect.Descriptor instead.
+func (*NormBBox) Descriptor() ([]byte, []int) {
+	return file_proto_detection_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *NormBBox) GetX() float32 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+func (x *NormBBox) GetY() float32 {
+	if x != nil {
+		return x.Y
+	}
+	return 0
+}
+
+func (x *NormBBox) GetW() float32 {
+	if x != nil {
+		return x.W
+	}
+	return 0
+}
+
+func (x *NormBBox) GetH() float32 {
+	if x != nil {
+		return x.H
+	}
+	return 0
+}
+
 type Detection struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Bbox          *BBox                  `protobuf:"bytes,1,opt,name=bbox,proto3" json:"bbox,omitempty"`
 	Confidence    float32                `protobuf:"fixed32,2,opt,name=confidence,proto3" json:"confidence,omitempty"`
 	ClassId       int32                  `protobuf:"varint,3,opt,name=class_id,json=classId,proto3" json:"class_id,omitempty"`
 	Label         string                 `protobuf:"bytes,4,opt,name=label,proto3" json:"label,omitempty"`
+	PetId         string                 `protobuf:"bytes,5,opt,name=pet_id,json=petId,proto3" json:"pet_id,omitempty"`          // enrolled pet, when identification is enabled
+	BboxNorm      *NormBBox              `protobuf:"bytes,6,opt,name=bbox_norm,json=bboxNorm,proto3" json:"bbox_norm,omitempty"` // with webmonitor -detection-bbox-norm
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Detection) Reset() {
 	*x = Detection{}
-	mi := &file_proto_detection_proto_msgTypes[1]
+	mi := &file_proto_detection_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -113,7 +184,7 @@ func (x *Detection) String() string {
 func (*Detection) ProtoMessage() {}
 
 func (x *Detection) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_detection_proto_msgTypes[1]
+	mi := &file_proto_detection_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -126,7 +197,7 @@ func (x *Detection) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Detection.ProtoReflect.Descriptor instead.
 func (*Detection) Descriptor() ([]byte, []int) {
-	return file_proto_detection_proto_rawDescGZIP(), []int{1}
+	return file_proto_detection_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *Detection) GetBbox() *BBox {
@@ -157,18 +228,159 @@ func (x *Detection) GetLabel() string {
 	return ""
 }
 
+func (x *Detection) GetPetId() string {
+	if x != nil {
+		return x.PetId
+	}
+	return ""
+}
+
+func (x *Detection) GetBboxNorm() *NormBBox {
+	if x != nil {
+		return x.BboxNorm
+	}
+	return nil
+}
+
+// The image a detector ran on, and where the video lies in it: inside for
+// a letterboxed input, extending past its edges for a cropped one.
+type DetectorInput struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Width         int32                  `protobuf:"varint,1,opt,name=width,proto3" json:"width,omitempty"`
+	Height        int32                  `protobuf:"varint,2,opt,name=height,proto3" json:"height,omitempty"`
+	Content       *BBox                  `protobuf:"bytes,3,opt,name=content,proto3" json:"content,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DetectorInput) Reset() {
+	*x = DetectorInput{}
+	mi := &file_proto_detection_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DetectorInput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DetectorInput) ProtoMessage() {}
+
+func (x *DetectorInput) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_detection_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DetectorInput.ProtoReflect.Descriptor instead.
+func (*DetectorInput) Descriptor() ([]byte, []int) {
+	return file_proto_detection_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *DetectorInput) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *DetectorInput) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *DetectorInput) GetContent() *BBox {
+	if x != nil {
+		return x.Content
+	}
+	return nil
+}
+
+// What the boxes of a result are measured in. The monitor sends every box
+// in pixels of a width x height frame covering the whole video (1280x720),
+// with the detector input it mapped them from. A detector feeding the
+// monitor sets only detector, with its boxes in that input's pixels.
+type DetectionFrame struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Width         int32                  `protobuf:"varint,1,opt,name=width,proto3" json:"width,omitempty"`
+	Height        int32                  `protobuf:"varint,2,opt,name=height,proto3" json:"height,omitempty"`
+	Detector      *DetectorInput         `protobuf:"bytes,3,opt,name=detector,proto3" json:"detector,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DetectionFrame) Reset() {
+	*x = DetectionFrame{}
+	mi := &file_proto_detection_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DetectionFrame) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DetectionFrame) ProtoMessage() {}
+
+func (x *DetectionFrame) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_detection_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DetectionFrame.ProtoReflect.Descriptor instead.
+func (*DetectionFrame) Descriptor() ([]byte, []int) {
+	return file_proto_detection_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *DetectionFrame) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *DetectionFrame) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *DetectionFrame) GetDetector() *DetectorInput {
+	if x != nil {
+		return x.Detector
+	}
+	return nil
+}
+
 type DetectionEvent struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	FrameNumber   uint64                 `protobuf:"varint,1,opt,name=frame_number,json=frameNumber,proto3" json:"frame_number,omitempty"`
 	Timestamp     float64                `protobuf:"fixed64,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
 	Detections    []*Detection           `protobuf:"bytes,3,rep,name=detections,proto3" json:"detections,omitempty"`
+	Frame         *DetectionFrame        `protobuf:"bytes,4,opt,name=frame,proto3" json:"frame,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *DetectionEvent) Reset() {
 	*x = DetectionEvent{}
-	mi := &file_proto_detection_proto_msgTypes[2]
+	mi := &file_proto_detection_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -180,7 +392,7 @@ func (x *DetectionEvent) String() string {
 func (*DetectionEvent) ProtoMessage() {}
 
 func (x *DetectionEvent) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_detection_proto_msgTypes[2]
+	mi := &file_proto_detection_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -193,7 +405,7 @@ func (x *DetectionEvent) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DetectionEvent.ProtoReflect.Descriptor instead.
 func (*DetectionEvent) Descriptor() ([]byte, []int) {
-	return file_proto_detection_proto_rawDescGZIP(), []int{2}
+	return file_proto_detection_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *DetectionEvent) GetFrameNumber() uint64 {
@@ -217,6 +429,13 @@ func (x *DetectionEvent) GetDetections() []*Detection {
 	return nil
 }
 
+func (x *DetectionEvent) GetFrame() *DetectionFrame {
+	if x != nil {
+		return x.Frame
+	}
+	return nil
+}
+
 type MonitorStats struct {
 	state           protoimpl.MessageState `protogen:"open.v1"`
 	FramesProcessed int32                  `protobuf:"varint,1,opt,name=frames_processed,json=framesProcessed,proto3" json:"frames_processed,omitempty"`
@@ -229,7 +448,7 @@ type MonitorStats struct {
 
 func (x *MonitorStats) Reset() {
 	*x = MonitorStats{}
-	mi := &file_proto_detection_proto_msgTypes[3]
+	mi := &file_proto_detection_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -241,7 +460,7 @@ func (x *MonitorStats) String() string {
 func (*MonitorStats) ProtoMessage() {}
 
 func (x *MonitorStats) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_detection_proto_msgTypes[3]
+	mi := &file_proto_detection_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -254,7 +473,7 @@ func (x *MonitorStats) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use MonitorStats.ProtoReflect.Descriptor instead.
 func (*MonitorStats) Descriptor() ([]byte, []int) {
-	return file_proto_detection_proto_rawDescGZIP(), []int{3}
+	return file_proto_detection_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *MonitorStats) GetFramesProcessed() int32 {
@@ -297,7 +516,7 @@ type SharedMemoryStats struct {
 
 func (x *SharedMemoryStats) Reset() {
 	*x = SharedMemoryStats{}
-	mi := &file_proto_detection_proto_msgTypes[4]
+	mi := &file_proto_detection_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -309,7 +528,7 @@ func (x *SharedMemoryStats) String() string {
 func (*SharedMemoryStats) ProtoMessage() {}
 
 func (x *SharedMemoryStats) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_detection_proto_msgTypes[4]
+	mi := &file_proto_detection_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -322,7 +541,7 @@ func (x *SharedMemoryStats) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SharedMemoryStats.ProtoReflect.Descriptor instead.
 func (*SharedMemoryStats) Descriptor() ([]byte, []int) {
-	return file_proto_detection_proto_rawDescGZIP(), []int{4}
+	return file_proto_detection_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *SharedMemoryStats) GetFrameCount() int32 {
@@ -360,13 +579,14 @@ type DetectionResult struct {
 	NumDetections int32                  `protobuf:"varint,3,opt,name=num_detections,json=numDetections,proto3" json:"num_detections,omitempty"`
 	Version       int32                  `protobuf:"varint,4,opt,name=version,proto3" json:"version,omitempty"`
 	Detections    []*Detection           `protobuf:"bytes,5,rep,name=detections,proto3" json:"detections,omitempty"`
+	Frame         *DetectionFrame        `protobuf:"bytes,6,opt,name=frame,proto3" json:"frame,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *DetectionResult) Reset() {
 	*x = DetectionResult{}
-	mi := &file_proto_detection_proto_msgTypes[5]
+	mi := &file_proto_detection_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -378,7 +598,7 @@ func (x *DetectionResult) String() string {
 func (*DetectionResult) ProtoMessage() {}
 
 func (x *DetectionResult) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_detection_proto_msgTypes[5]
+	mi := &file_proto_detection_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -391,7 +611,7 @@ func (x *DetectionResult) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DetectionResult.ProtoReflect.Descriptor instead.
 func (*DetectionResult) Descriptor() ([]byte, []int) {
-	return file_proto_detection_proto_rawDescGZIP(), []int{5}
+	return file_proto_detection_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *DetectionResult) GetFrameNumber() uint64 {
@@ -429,32 +649,38 @@ func (x *DetectionResult) GetDetections() []*Detection {
 	return nil
 }
 
-type StatusEvent struct {
-	state            protoimpl.MessageState `protogen:"open.v1"`
-	Monitor          *MonitorStats          `protobuf:"bytes,1,opt,name=monitor,proto3" json:"monitor,omitempty"`
-	SharedMemory     *SharedMemoryStats     `protobuf:"bytes,2,opt,name=shared_memory,json=sharedMemory,proto3" json:"shared_memory,omitempty"`
-	LatestDetection  *DetectionResult       `protobuf:"bytes,3,opt,name=latest_detection,json=latestDetection,proto3" json:"latest_detection,omitempty"`
-	DetectionHistory []*DetectionResult     `protobuf:"bytes,4,rep,name=detection_history,json=detectionHistory,proto3" json:"detection_history,omitempty"`
-	Timestamp        float64                `protobuf:"fixed64,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
-	unknownFields    protoimpl.UnknownFields
-	sizeCache        protoimpl.SizeCache
+func (x *DetectionResult) GetFrame() *DetectionFrame {
+	if x != nil {
+		return x.Frame
+	}
+	return nil
 }
 
-func (x *StatusEvent) Reset() {
-	*x = StatusEvent{}
-	mi := &file_proto_detection_proto_msgTypes[6]
+type DetectorHealth struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Healthy        bool                   `protobuf:"varint,1,opt,name=healthy,proto3" json:"healthy,omitempty"`
+	StaleSeconds   float64                `protobuf:"fixed64,2,opt,name=stale_seconds,json=staleSeconds,proto3" json:"stale_seconds,omitempty"`
+	Alerting       bool                   `protobuf:"varint,3,opt,name=alerting,proto3" json:"alerting,omitempty"`
+	MotionFallback bool                   `protobuf:"varint,4,opt,name=motion_fallback,json=motionFallback,proto3" json:"motion_fallback,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *DetectorHealth) Reset() {
+	*x = DetectorHealth{}
+	mi := &file_proto_detection_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *StatusEvent) String() string {
+func (x *DetectorHealth) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*StatusEvent) ProtoMessage() {}
+func (*DetectorHealth) ProtoMessage() {}
 
-func (x *StatusEvent) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_detection_proto_msgTypes[6]
+func (x *DetectorHealth) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_detection_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -465,47 +691,684 @@ func (x *StatusEvent) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use StatusEvent.ProtoReflect.Descriptor instead.
-func (*StatusEvent) Descriptor() ([]byte, []int) {
-	return file_proto_detection_proto_rawDescGZIP(), []int{6}
+// Deprecated: Use DetectorHealth.ProtoReflect.Descriptor instead.
+func (*DetectorHealth) Descriptor() ([]byte, []int) {
+	return file_proto_detection_proto_rawDescGZIP(), []int{9}
 }
 
-func (x *StatusEvent) GetMonitor() *MonitorStats {
+func (x *DetectorHealth) GetHealthy() bool {
 	if x != nil {
-		return x.Monitor
+		return x.Healthy
 	}
-	return nil
+	return false
 }
 
-func (x *StatusEvent) GetSharedMemory() *SharedMemoryStats {
+func (x *DetectorHealth) GetStaleSeconds() float64 {
 	if x != nil {
-		return x.SharedMemory
+		return x.StaleSeconds
 	}
-	return nil
+	return 0
 }
 
-func (x *StatusEvent) GetLatestDetection() *DetectionResult {
+func (x *DetectorHealth) GetAlerting() bool {
 	if x != nil {
-		return x.LatestDetection
+		return x.Alerting
 	}
-	return nil
+	return false
 }
 
-func (x *StatusEvent) GetDetectionHistory() []*DetectionResult {
+func (x *DetectorHealth) GetMotionFallback() bool {
 	if x != nil {
-		return x.DetectionHistory
+		return x.MotionFallback
 	}
-	return nil
+	return false
 }
 
-func (x *StatusEvent) GetTimestamp() float64 {
+type ViewerInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`                                        // anonymized, stable for the connection
+	Kind          string                 `protobuf:"bytes,2,opt,name=kind,proto3" json:"kind,omitempty"`                                    // "webrtc" or "mjpeg"
+	ConnectedAt   float64                `protobuf:"fixed64,3,opt,name=connected_at,json=connectedAt,proto3" json:"connected_at,omitempty"` // Unix seconds
+	Quality       string                 `protobuf:"bytes,4,opt,name=quality,proto3" json:"quality,omitempty"`                              // webrtc: good/fair/poor/unknown, mjpeg: full/reduced
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ViewerInfo) Reset() {
+	*x = ViewerInfo{}
+	mi := &file_proto_detection_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ViewerInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ViewerInfo) ProtoMessage() {}
+
+func (x *ViewerInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_detection_proto_msgTypes[10]
 	if x != nil {
-		return x.Timestamp
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ViewerInfo.ProtoReflect.Descriptor instead.
+func (*ViewerInfo) Descriptor() ([]byte, []int) {
+	return file_proto_detection_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ViewerInfo) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ViewerInfo) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *ViewerInfo) GetConnectedAt() float64 {
+	if x != nil {
+		return x.ConnectedAt
 	}
 	return 0
 }
 
-var File_proto_detection_proto protoreflect.FileDescriptor
+func (x *ViewerInfo) GetQuality() string {
+	if x != nil {
+		return x.Quality
+	}
+	return ""
+}
+
+type Viewers struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Webrtc        int32                  `protobuf:"varint,1,opt,name=webrtc,proto3" json:"webrtc,omitempty"`
+	Mjpeg         int32                  `protobuf:"varint,2,opt,name=mjpeg,proto3" json:"mjpeg,omitempty"`
+	Clients       []*ViewerInfo          `protobuf:"bytes,3,rep,name=clients,proto3" json:"clients,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Viewers) Reset() {
+	*x = Viewers{}
+	mi := &file_proto_detection_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Viewers) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Viewers) ProtoMessage() {}
+
+func (x *Viewers) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_detection_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Viewers.ProtoReflect.Descriptor instead.
+func (*Viewers) Descriptor() ([]byte, []int) {
+	return file_proto_detection_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Viewers) GetWebrtc() int32 {
+	if x != nil {
+		return x.Webrtc
+	}
+	return 0
+}
+
+func (x *Viewers) GetMjpeg() int32 {
+	if x != nil {
+		return x.Mjpeg
+	}
+	return 0
+}
+
+func (x *Viewers) GetClients() []*ViewerInfo {
+	if x != nil {
+		return x.Clients
+	}
+	return nil
+}
+
+type RecordingOwner struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`     // anonymized browser session, or "rules"/"resume"
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"` // optional label supplied by the client
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RecordingOwner) Reset() {
+	*x = RecordingOwner{}
+	mi := &file_proto_detection_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RecordingOwner) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecordingOwner) ProtoMessage() {}
+
+func (x *RecordingOwner) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_detection_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecordingOwner.ProtoReflect.Descriptor instead.
+func (*RecordingOwner) Descriptor() ([]byte, []int) {
+	return file_proto_detection_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *RecordingOwner) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *RecordingOwner) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type RecordingState struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Active        bool                   `protobuf:"varint,1,opt,name=active,proto3" json:"active,omitempty"`
+	File          string                 `protobuf:"bytes,2,opt,name=file,proto3" json:"file,omitempty"`
+	Owner         *RecordingOwner        `protobuf:"bytes,3,opt,name=owner,proto3" json:"owner,omitempty"`
+	StartedAt     float64                `protobuf:"fixed64,4,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"` // Unix seconds
+	EventId       string                 `protobuf:"bytes,5,opt,name=event_id,json=eventId,proto3" json:"event_id,omitempty"`         // correlation ID of the trigger that started it
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RecordingState) Reset() {
+	*x = RecordingState{}
+	mi := &file_proto_detection_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RecordingState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecordingState) ProtoMessage() {}
+
+func (x *RecordingState) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_detection_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecordingState.ProtoReflect.Descriptor instead.
+func (*RecordingState) Descriptor() ([]byte, []int) {
+	return file_proto_detection_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *RecordingState) GetActive() bool {
+	if x != nil {
+		return x.Active
+	}
+	return false
+}
+
+func (x *RecordingState) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+func (x *RecordingState) GetOwner() *RecordingOwner {
+	if x != nil {
+		return x.Owner
+	}
+	return nil
+}
+
+func (x *RecordingState) GetStartedAt() float64 {
+	if x != nil {
+		return x.StartedAt
+	}
+	return 0
+}
+
+func (x *RecordingState) GetEventId() string {
+	if x != nil {
+		return x.EventId
+	}
+	return ""
+}
+
+// Adaptive MJPEG encode settings (webmonitor -mjpeg-encode-budget).
+type MJPEGQuality struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Quality       int32                  `protobuf:"varint,1,opt,name=quality,proto3" json:"quality,omitempty"`                         // current JPEG quality
+	MaxQuality    int32                  `protobuf:"varint,2,opt,name=max_quality,json=maxQuality,proto3" json:"max_quality,omitempty"` // configured -jpeg-quality
+	Scale         int32                  `protobuf:"varint,3,opt,name=scale,proto3" json:"scale,omitempty"`                             // resolution divisor (1 = full, 2 = half)
+	EncodeMs      float64                `protobuf:"fixed64,4,opt,name=encode_ms,json=encodeMs,proto3" json:"encode_ms,omitempty"`      // mean encode time over the last window
+	BudgetMs      float64                `protobuf:"fixed64,5,opt,name=budget_ms,json=budgetMs,proto3" json:"budget_ms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MJPEGQuality) Reset() {
+	*x = MJPEGQuality{}
+	mi := &file_proto_detection_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MJPEGQuality) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MJPEGQuality) ProtoMessage() {}
+
+func (x *MJPEGQuality) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_detection_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MJPEGQuality.ProtoReflect.Descriptor instead.
+func (*MJPEGQuality) Descriptor() ([]byte, []int) {
+	return file_proto_detection_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *MJPEGQuality) GetQuality() int32 {
+	if x != nil {
+		return x.Quality
+	}
+	return 0
+}
+
+func (x *MJPEGQuality) GetMaxQuality() int32 {
+	if x != nil {
+		return x.MaxQuality
+	}
+	return 0
+}
+
+func (x *MJPEGQuality) GetScale() int32 {
+	if x != nil {
+		return x.Scale
+	}
+	return 0
+}
+
+func (x *MJPEGQuality) GetEncodeMs() float64 {
+	if x != nil {
+		return x.EncodeMs
+	}
+	return 0
+}
+
+func (x *MJPEGQuality) GetBudgetMs() float64 {
+	if x != nil {
+		return x.BudgetMs
+	}
+	return 0
+}
+
+// Build of the web monitor (ldflags, see internal/buildinfo).
+type ServerInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Version       string                 `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	Commit        string                 `protobuf:"bytes,2,opt,name=commit,proto3" json:"commit,omitempty"`
+	BuildDate     string                 `protobuf:"bytes,3,opt,name=build_date,json=buildDate,proto3" json:"build_date,omitempty"` // RFC 3339
+	GoVersion     string                 `protobuf:"bytes,4,opt,name=go_version,json=goVersion,proto3" json:"go_version,omitempty"`
+	UptimeSeconds float64                `protobuf:"fixed64,5,opt,name=uptime_seconds,json=uptimeSeconds,proto3" json:"uptime_seconds,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerInfo) Reset() {
+	*x = ServerInfo{}
+	mi := &file_proto_detection_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerInfo) ProtoMessage() {}
+
+func (x *ServerInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_detection_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerInfo.ProtoReflect.Descriptor instead.
+func (*ServerInfo) Descriptor() ([]byte, []int) {
+	return file_proto_detection_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *ServerInfo) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *ServerInfo) GetCommit() string {
+	if x != nil {
+		return x.Commit
+	}
+	return ""
+}
+
+func (x *ServerInfo) GetBuildDate() string {
+	if x != nil {
+		return x.BuildDate
+	}
+	return ""
+}
+
+func (x *ServerInfo) GetGoVersion() string {
+	if x != nil {
+		return x.GoVersion
+	}
+	return ""
+}
+
+func (x *ServerInfo) GetUptimeSeconds() float64 {
+	if x != nil {
+		return x.UptimeSeconds
+	}
+	return 0
+}
+
+type StatusEvent struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Monitor          *MonitorStats          `protobuf:"bytes,1,opt,name=monitor,proto3" json:"monitor,omitempty"`
+	SharedMemory     *SharedMemoryStats     `protobuf:"bytes,2,opt,name=shared_memory,json=sharedMemory,proto3" json:"shared_memory,omitempty"`
+	LatestDetection  *DetectionResult       `protobuf:"bytes,3,opt,name=latest_detection,json=latestDetection,proto3" json:"latest_detection,omitempty"`
+	DetectionHistory []*DetectionResult     `protobuf:"bytes,4,rep,name=detection_history,json=detectionHistory,proto3" json:"detection_history,omitempty"`
+	Timestamp        float64                `protobuf:"fixed64,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	DetectorHealth   *DetectorHealth        `protobuf:"bytes,6,opt,name=detector_health,json=detectorHealth,proto3" json:"detector_health,omitempty"`
+	Viewers          *Viewers               `protobuf:"bytes,7,opt,name=viewers,proto3" json:"viewers,omitempty"`
+	Recording        *RecordingState        `protobuf:"bytes,8,opt,name=recording,proto3" json:"recording,omitempty"`
+	MjpegQuality     *MJPEGQuality          `protobuf:"bytes,9,opt,name=mjpeg_quality,json=mjpegQuality,proto3" json:"mjpeg_quality,omitempty"`
+	ServerInfo       *ServerInfo            `protobuf:"bytes,10,opt,name=server_info,json=serverInfo,proto3" json:"server_info,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *StatusEvent) Reset() {
+	*x = StatusEvent{}
+	mi := &file_proto_detection_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatusEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusEvent) ProtoMessage() {}
+
+func (x *StatusEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_detection_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusEvent.ProtoReflect.Descriptor instead.
+func (*StatusEvent) Descriptor() ([]byte, []int) {
+	return file_proto_detection_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *StatusEvent) GetMonitor() *MonitorStats {
+	if x != nil {
+		return x.Monitor
+	}
+	return nil
+}
+
+func (x *StatusEvent) GetSharedMemory() *SharedMemoryStats {
+	if x != nil {
+		return x.SharedMemory
+	}
+	return nil
+}
+
+func (x *StatusEvent) GetLatestDetection() *DetectionResult {
+	if x != nil {
+		return x.LatestDetection
+	}
+	return nil
+}
+
+func (x *StatusEvent) GetDetectionHistory() []*DetectionResult {
+	if x != nil {
+		return x.DetectionHistory
+	}
+	return nil
+}
+
+func (x *StatusEvent) GetTimestamp() float64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *StatusEvent) GetDetectorHealth() *DetectorHealth {
+	if x != nil {
+		return x.DetectorHealth
+	}
+	return nil
+}
+
+func (x *StatusEvent) GetViewers() *Viewers {
+	if x != nil {
+		return x.Viewers
+	}
+	return nil
+}
+
+func (x *StatusEvent) GetRecording() *RecordingState {
+	if x != nil {
+		return x.Recording
+	}
+	return nil
+}
+
+func (x *StatusEvent) GetMjpegQuality() *MJPEGQuality {
+	if x != nil {
+		return x.MjpegQuality
+	}
+	return nil
+}
+
+func (x *StatusEvent) GetServerInfo() *ServerInfo {
+	if x != nil {
+		return x.ServerInfo
+	}
+	return nil
+}
+
+// Detector input over gRPC (webmonitor -detection-source=grpc). A detector
+// that does not use the shared-memory daemon serves this and streams one
+// DetectionEvent per inference.
+type StreamDetectionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamDetectionsRequest) Reset() {
+	*x = StreamDetectionsRequest{}
+	mi := &file_proto_detection_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamDetectionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamDetectionsRequest) ProtoMessage() {}
+
+func (x *StreamDetectionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_detection_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamDetectionsRequest.ProtoReflect.Descriptor instead.
+func (*StreamDetectionsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_detection_proto_rawDescGZIP(), []int{17}
+}
+
+// Secondary inference (webmonitor -forward-url=grpc://host:port). The
+// monitor sends a downscaled JPEG and ingests the returned detections,
+// whose boxes are in the sent image's pixel coordinates.
+type InferenceFrame struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Jpeg          []byte                 `protobuf:"bytes,1,opt,name=jpeg,proto3" json:"jpeg,omitempty"`
+	Width         uint32                 `protobuf:"varint,2,opt,name=width,proto3" json:"width,omitempty"`
+	Height        uint32                 `protobuf:"varint,3,opt,name=height,proto3" json:"height,omitempty"`
+	FrameNumber   uint64                 `protobuf:"varint,4,opt,name=frame_number,json=frameNumber,proto3" json:"frame_number,omitempty"`
+	Timestamp     float64                `protobuf:"fixed64,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InferenceFrame) Reset() {
+	*x = InferenceFrame{}
+	mi := &file_proto_detection_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InferenceFrame) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InferenceFrame) ProtoMessage() {}
+
+func (x *InferenceFrame) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_detection_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InferenceFrame.ProtoReflect.Descriptor instead.
+func (*InferenceFrame) Descriptor() ([]byte, []int) {
+	return file_proto_detection_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *InferenceFrame) GetJpeg() []byte {
+	if x != nil {
+		return x.Jpeg
+	}
+	return nil
+}
+
+func (x *InferenceFrame) GetWidth() uint32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *InferenceFrame) GetHeight() uint32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *InferenceFrame) GetFrameNumber() uint64 {
+	if x != nil {
+		return x.FrameNumber
+	}
+	return 0
+}
+
+func (x *InferenceFrame) GetTimestamp() float64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+var File_proto_detection_proto protoreflect.FileDescriptor
 
 const file_proto_detection_proto_rawDesc = "" +
 	"\n" +
@@ -514,20 +1377,36 @@ const file_proto_detection_proto_rawDesc = "" +
 	"\x01x\x18\x01 \x01(\x05R\x01x\x12\f\n" +
 	"\x01y\x18\x02 \x01(\x05R\x01y\x12\f\n" +
 	"\x01w\x18\x03 \x01(\x05R\x01w\x12\f\n" +
-	"\x01h\x18\x04 \x01(\x05R\x01h\"\x81\x01\n" +
+	"\x01h\x18\x04 \x01(\x05R\x01h\"B\n" +
+	"\bNormBBox\x12\f\n" +
+	"\x01x\x18\x01 \x01(\x02R\x01x\x12\f\n" +
+	"\x01y\x18\x02 \x01(\x02R\x01y\x12\f\n" +
+	"\x01w\x18\x03 \x01(\x02R\x01w\x12\f\n" +
+	"\x01h\x18\x04 \x01(\x02R\x01h\"\xca\x01\n" +
 	"\tDetection\x12#\n" +
 	"\x04bbox\x18\x01 \x01(\v2\x0f.petcamera.BBoxR\x04bbox\x12\x1e\n" +
 	"\n" +
 	"confidence\x18\x02 \x01(\x02R\n" +
 	"confidence\x12\x19\n" +
 	"\bclass_id\x18\x03 \x01(\x05R\aclassId\x12\x14\n" +
-	"\x05label\x18\x04 \x01(\tR\x05label\"\x87\x01\n" +
+	"\x05label\x18\x04 \x01(\tR\x05label\x12\x15\n" +
+	"\x06pet_id\x18\x05 \x01(\tR\x05petId\x120\n" +
+	"\tbbox_norm\x18\x06 \x01(\v2\x13.petcamera.NormBBoxR\bbboxNorm\"h\n" +
+	"\rDetectorInput\x12\x14\n" +
+	"\x05width\x18\x01 \x01(\x05R\x05width\x12\x16\n" +
+	"\x06height\x18\x02 \x01(\x05R\x06height\x12)\n" +
+	"\acontent\x18\x03 \x01(\v2\x0f.petcamera.BBoxR\acontent\"t\n" +
+	"\x0eDetectionFrame\x12\x14\n" +
+	"\x05width\x18\x01 \x01(\x05R\x05width\x12\x16\n" +
+	"\x06height\x18\x02 \x01(\x05R\x06height\x124\n" +
+	"\bdetector\x18\x03 \x01(\v2\x18.petcamera.DetectorInputR\bdetector\"\xb8\x01\n" +
 	"\x0eDetectionEvent\x12!\n" +
 	"\fframe_number\x18\x01 \x01(\x04R\vframeNumber\x12\x1c\n" +
 	"\ttimestamp\x18\x02 \x01(\x01R\ttimestamp\x124\n" +
 	"\n" +
 	"detections\x18\x03 \x03(\v2\x14.petcamera.DetectionR\n" +
-	"detections\"\xa2\x01\n" +
+	"detections\x12/\n" +
+	"\x05frame\x18\x04 \x01(\v2\x19.petcamera.DetectionFrameR\x05frame\"\xa2\x01\n" +
 	"\fMonitorStats\x12)\n" +
 	"\x10frames_processed\x18\x01 \x01(\x05R\x0fframesProcessed\x12\x1f\n" +
 	"\vcurrent_fps\x18\x02 \x01(\x01R\n" +
@@ -540,7 +1419,7 @@ const file_proto_detection_proto_rawDesc = "" +
 	"frameCount\x120\n" +
 	"\x14total_frames_written\x18\x02 \x01(\x05R\x12totalFramesWritten\x12+\n" +
 	"\x11detection_version\x18\x03 \x01(\x05R\x10detectionVersion\x12#\n" +
-	"\rhas_detection\x18\x04 \x01(\x05R\fhasDetection\"\xc9\x01\n" +
+	"\rhas_detection\x18\x04 \x01(\x05R\fhasDetection\"\xfa\x01\n" +
 	"\x0fDetectionResult\x12!\n" +
 	"\fframe_number\x18\x01 \x01(\x04R\vframeNumber\x12\x1c\n" +
 	"\ttimestamp\x18\x02 \x01(\x01R\ttimestamp\x12%\n" +
@@ -548,13 +1427,73 @@ const file_proto_detection_proto_rawDesc = "" +
 	"\aversion\x18\x04 \x01(\x05R\aversion\x124\n" +
 	"\n" +
 	"detections\x18\x05 \x03(\v2\x14.petcamera.DetectionR\n" +
-	"detections\"\xb1\x02\n" +
+	"detections\x12/\n" +
+	"\x05frame\x18\x06 \x01(\v2\x19.petcamera.DetectionFrameR\x05frame\"\x94\x01\n" +
+	"\x0eDetectorHealth\x12\x18\n" +
+	"\ahealthy\x18\x01 \x01(\bR\ahealthy\x12#\n" +
+	"\rstale_seconds\x18\x02 \x01(\x01R\fstaleSeconds\x12\x1a\n" +
+	"\balerting\x18\x03 \x01(\bR\balerting\x12'\n" +
+	"\x0fmotion_fallback\x18\x04 \x01(\bR\x0emotionFallback\"m\n" +
+	"\n" +
+	"ViewerInfo\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04kind\x18\x02 \x01(\tR\x04kind\x12!\n" +
+	"\fconnected_at\x18\x03 \x01(\x01R\vconnectedAt\x12\x18\n" +
+	"\aquality\x18\x04 \x01(\tR\aquality\"h\n" +
+	"\aViewers\x12\x16\n" +
+	"\x06webrtc\x18\x01 \x01(\x05R\x06webrtc\x12\x14\n" +
+	"\x05mjpeg\x18\x02 \x01(\x05R\x05mjpeg\x12/\n" +
+	"\aclients\x18\x03 \x03(\v2\x15.petcamera.ViewerInfoR\aclients\"4\n" +
+	"\x0eRecordingOwner\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\"\xa7\x01\n" +
+	"\x0eRecordingState\x12\x16\n" +
+	"\x06active\x18\x01 \x01(\bR\x06active\x12\x12\n" +
+	"\x04file\x18\x02 \x01(\tR\x04file\x12/\n" +
+	"\x05owner\x18\x03 \x01(\v2\x19.petcamera.RecordingOwnerR\x05owner\x12\x1d\n" +
+	"\n" +
+	"started_at\x18\x04 \x01(\x01R\tstartedAt\x12\x19\n" +
+	"\bevent_id\x18\x05 \x01(\tR\aeventId\"\x99\x01\n" +
+	"\fMJPEGQuality\x12\x18\n" +
+	"\aquality\x18\x01 \x01(\x05R\aquality\x12\x1f\n" +
+	"\vmax_quality\x18\x02 \x01(\x05R\n" +
+	"maxQuality\x12\x14\n" +
+	"\x05scale\x18\x03 \x01(\x05R\x05scale\x12\x1b\n" +
+	"\tencode_ms\x18\x04 \x01(\x01R\bencodeMs\x12\x1b\n" +
+	"\tbudget_ms\x18\x05 \x01(\x01R\bbudgetMs\"\xa3\x01\n" +
+	"\n" +
+	"ServerInfo\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\tR\aversion\x12\x16\n" +
+	"\x06commit\x18\x02 \x01(\tR\x06commit\x12\x1d\n" +
+	"\n" +
+	"build_date\x18\x03 \x01(\tR\tbuildDate\x12\x1d\n" +
+	"\n" +
+	"go_version\x18\x04 \x01(\tR\tgoVersion\x12%\n" +
+	"\x0euptime_seconds\x18\x05 \x01(\x01R\ruptimeSeconds\"\xd2\x04\n" +
 	"\vStatusEvent\x121\n" +
 	"\amonitor\x18\x01 \x01(\v2\x17.petcamera.MonitorStatsR\amonitor\x12A\n" +
 	"\rshared_memory\x18\x02 \x01(\v2\x1c.petcamera.SharedMemoryStatsR\fsharedMemory\x12E\n" +
 	"\x10latest_detection\x18\x03 \x01(\v2\x1a.petcamera.DetectionResultR\x0flatestDetection\x12G\n" +
 	"\x11detection_history\x18\x04 \x03(\v2\x1a.petcamera.DetectionResultR\x10detectionHistory\x12\x1c\n" +
-	"\ttimestamp\x18\x05 \x01(\x01R\ttimestampBFZDgithub.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/protob\x06proto3"
+	"\ttimestamp\x18\x05 \x01(\x01R\ttimestamp\x12B\n" +
+	"\x0fdetector_health\x18\x06 \x01(\v2\x19.petcamera.DetectorHealthR\x0edetectorHealth\x12,\n" +
+	"\aviewers\x18\a \x01(\v2\x12.petcamera.ViewersR\aviewers\x127\n" +
+	"\trecording\x18\b \x01(\v2\x19.petcamera.RecordingStateR\trecording\x12<\n" +
+	"\rmjpeg_quality\x18\t \x01(\v2\x17.petcamera.MJPEGQualityR\fmjpegQuality\x126\n" +
+	"\vserver_info\x18\n" +
+	" \x01(\v2\x15.petcamera.ServerInfoR\n" +
+	"serverInfo\"\x19\n" +
+	"\x17StreamDetectionsRequest\"\x93\x01\n" +
+	"\x0eInferenceFrame\x12\x12\n" +
+	"\x04jpeg\x18\x01 \x01(\fR\x04jpeg\x12\x14\n" +
+	"\x05width\x18\x02 \x01(\rR\x05width\x12\x16\n" +
+	"\x06height\x18\x03 \x01(\rR\x06height\x12!\n" +
+	"\fframe_number\x18\x04 \x01(\x04R\vframeNumber\x12\x1c\n" +
+	"\ttimestamp\x18\x05 \x01(\x01R\ttimestamp2g\n" +
+	"\x10DetectionService\x12S\n" +
+	"\x10StreamDetections\x12\".petcamera.StreamDetectionsRequest\x1a\x19.petcamera.DetectionEvent0\x012T\n" +
+	"\x10InferenceService\x12@\n" +
+	"\bAnnotate\x12\x19.petcamera.InferenceFrame\x1a\x19.petcamera.DetectionEventBFZDgithub.com/dj-oyu/rdk-x5_smart-pet-camera/streaming-server/pkg/protob\x06proto3"
 
 var (
 	file_proto_detection_proto_rawDescOnce sync.Once
@@ -568,29 +1507,57 @@ func file_proto_detection_proto_rawDescGZIP() []byte {
 	return file_proto_detection_proto_rawDescData
 }
 
-var file_proto_detection_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_proto_detection_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
 var file_proto_detection_proto_goTypes = []any{
-	(*BBox)(nil),              // 0: petcamera.BBox
-	(*Detection)(nil),         // 1: petcamera.Detection
-	(*DetectionEvent)(nil),    // 2: petcamera.DetectionEvent
-	(*MonitorStats)(nil),      // 3: petcamera.MonitorStats
-	(*SharedMemoryStats)(nil), // 4: petcamera.SharedMemoryStats
-	(*DetectionResult)(nil),   // 5: petcamera.DetectionResult
-	(*StatusEvent)(nil),       // 6: petcamera.StatusEvent
+	(*BBox)(nil),                    // 0: petcamera.BBox
+	(*NormBBox)(nil),                // 1: petcamera.NormBBox
+	(*Detection)(nil),               // 2: petcamera.Detection
+	(*DetectorInput)(nil),           // 3: petcamera.DetectorInput
+	(*DetectionFrame)(nil),          // 4: petcamera.DetectionFrame
+	(*DetectionEvent)(nil),          // 5: petcamera.DetectionEvent
+	(*MonitorStats)(nil),            // 6: petcamera.MonitorStats
+	(*SharedMemoryStats)(nil),       // 7: petcamera.SharedMemoryStats
+	(*DetectionResult)(nil),         // 8: petcamera.DetectionResult
+	(*DetectorHealth)(nil),          // 9: petcamera.DetectorHealth
+	(*ViewerInfo)(nil),              // 10: petcamera.ViewerInfo
+	(*Viewers)(nil),                 // 11: petcamera.Viewers
+	(*RecordingOwner)(nil),          // 12: petcamera.RecordingOwner
+	(*RecordingState)(nil),          // 13: petcamera.RecordingState
+	(*MJPEGQuality)(nil),            // 14: petcamera.MJPEGQuality
+	(*ServerInfo)(nil),              // 15: petcamera.ServerInfo
+	(*StatusEvent)(nil),             // 16: petcamera.StatusEvent
+	(*StreamDetectionsRequest)(nil), // 17: petcamera.StreamDetectionsRequest
+	(*InferenceFrame)(nil),          // 18: petcamera.InferenceFrame
 }
 var file_proto_detection_proto_depIdxs = []int32{
-	0, // 0: petcamera.Detection.bbox:type_name -> petcamera.BBox
-	1, // 1: petcamera.DetectionEvent.detections:type_name -> petcamera.Detection
-	1, // 2: petcamera.DetectionResult.detections:type_name -> petcamera.Detection
-	3, // 3: petcamera.StatusEvent.monitor:type_name -> petcamera.MonitorStats
-	4, // 4: petcamera.StatusEvent.shared_memory:type_name -> petcamera.SharedMemoryStats
-	5, // 5: petcamera.StatusEvent.latest_detection:type_name -> petcamera.DetectionResult
-	5, // 6: petcamera.StatusEvent.detection_history:type_name -> petcamera.DetectionResult
-	7, // [7:7] is the sub-list for method output_type
-	7, // [7:7] is the sub-list for method input_type
-	7, // [7:7] is the sub-list for extension type_name
-	7, // [7:7] is the sub-list for extension extendee
-	0, // [0:7] is the sub-list for field type_name
+	0,  // 0: petcamera.Detection.bbox:type_name -> petcamera.BBox
+	1,  // 1: petcamera.Detection.bbox_norm:type_name -> petcamera.NormBBox
+	0,  // 2: petcamera.DetectorInput.content:type_name -> petcamera.BBox
+	3,  // 3: petcamera.DetectionFrame.detector:type_name -> petcamera.DetectorInput
+	2,  // 4: petcamera.DetectionEvent.detections:type_name -> petcamera.Detection
+	4,  // 5: petcamera.DetectionEvent.frame:type_name -> petcamera.DetectionFrame
+	2,  // 6: petcamera.DetectionResult.detections:type_name -> petcamera.Detection
+	4,  // 7: petcamera.DetectionResult.frame:type_name -> petcamera.DetectionFrame
+	10, // 8: petcamera.Viewers.clients:type_name -> petcamera.ViewerInfo
+	12, // 9: petcamera.RecordingState.owner:type_name -> petcamera.RecordingOwner
+	6,  // 10: petcamera.StatusEvent.monitor:type_name -> petcamera.MonitorStats
+	7,  // 11: petcamera.StatusEvent.shared_memory:type_name -> petcamera.SharedMemoryStats
+	8,  // 12: petcamera.StatusEvent.latest_detection:type_name -> petcamera.DetectionResult
+	8,  // 13: petcamera.StatusEvent.detection_history:type_name -> petcamera.DetectionResult
+	9,  // 14: petcamera.StatusEvent.detector_health:type_name -> petcamera.DetectorHealth
+	11, // 15: petcamera.StatusEvent.viewers:type_name -> petcamera.Viewers
+	13, // 16: petcamera.StatusEvent.recording:type_name -> petcamera.RecordingState
+	14, // 17: petcamera.StatusEvent.mjpeg_quality:type_name -> petcamera.MJPEGQuality
+	15, // 18: petcamera.StatusEvent.server_info:type_name -> petcamera.ServerInfo
+	17, // 19: petcamera.DetectionService.StreamDetections:input_type -> petcamera.StreamDetectionsRequest
+	18, // 20: petcamera.InferenceService.Annotate:input_type -> petcamera.InferenceFrame
+	5,  // 21: petcamera.DetectionService.StreamDetections:output_type -> petcamera.DetectionEvent
+	5,  // 22: petcamera.InferenceService.Annotate:output_type -> petcamera.DetectionEvent
+	21, // [21:23] is the sub-list for method output_type
+	19, // [19:21] is the sub-list for method input_type
+	19, // [19:19] is the sub-list for extension type_name
+	19, // [19:19] is the sub-list for extension extendee
+	0,  // [0:19] is the sub-list for field type_name
 }
 
 func init() { file_proto_detection_proto_init() }
@@ -604,9 +1571,9 @@ func file_proto_detection_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_detection_proto_rawDesc), len(file_proto_detection_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   7,
+			NumMessages:   19,
 			NumExtensions: 0,
-			NumServices:   0,
+			NumServices:   2,
 		},
 		GoTypes:           file_proto_detection_proto_goTypes,
 		DependencyIndexes: file_proto_detection_proto_depIdxs,
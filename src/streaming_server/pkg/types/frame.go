@@ -7,10 +7,20 @@ type VideoFrame struct {
 	Data        []byte     // Raw video data (NAL units)
 	Timestamp   time.Time  // Frame capture timestamp
 	FrameNumber uint64     // Sequential frame number
+	CameraID    int        // Camera that captured the frame (0 = day, 1 = IR night)
 	IsIDR       bool       // True if this frame contains an IDR
 	Width       int        // Frame width
 	Height      int        // Frame height
 	NALUs       []NALBound // NAL unit boundaries (set by Processor.Process)
+	Timing      FrameTiming
+}
+
+// FrameTiming records when a frame passed each pipeline stage after the
+// camera wrote it to SHM (VideoFrame.Timestamp). Zero means not reached.
+type FrameTiming struct {
+	Read      time.Time // copied out of SHM
+	Processed time.Time // NAL parsing done
+	Sent      time.Time // RTP packets handed to every WebRTC session
 }
 
 // NALBound describes the location of a NAL unit within VideoFrame.Data.
@@ -50,6 +60,7 @@ const (
 	NALTypeH265VPS      uint8 = 32
 	NALTypeH265SPS      uint8 = 33
 	NALTypeH265PPS      uint8 = 34
+	NALTypeH265SEI      uint8 = 39 // prefix SEI
 )
 
 // StreamConfig holds configuration for the streaming server
@@ -24,6 +24,9 @@ fi
 cp "$SCRIPT_DIR/src/styles/monitor.css" "$OUT_DIR/monitor.css"
 CSS_HASH=$(md5sum "$OUT_DIR/monitor.css" | cut -c1-8)
 
+# Service worker must keep a stable URL (served at /sw.js)
+cp "$SCRIPT_DIR/sw.js" "$OUT_DIR/sw.js"
+
 # Generate index.html from template with hashed filenames
 sed -e "s/{{APP_JS}}/$APP_JS/" \
     -e "s/{{CSS_HASH}}/$CSS_HASH/" \
@@ -6,11 +6,15 @@ import { VideoControls } from './components/VideoControls';
 import { RecordingsModal } from './components/RecordingsModal';
 import { useSidebar, TrackingView } from './components/Sidebar';
 import { AlbumView } from './components/AlbumView';
+import { CamerasView } from './components/CamerasView';
+import { DiagnosticsView } from './components/DiagnosticsView';
+import { HistoryView } from './components/HistoryView';
 import { MobileTabBar } from './components/MobileTabBar';
 import { useSSE } from './hooks/useSSE';
 import { useRecording } from './hooks/useRecording';
 import type { StatusEvent } from './lib/protobuf';
 import { AppStore } from './lib/store';
+import { loadUIConfig, panelEnabled, t } from './lib/uiConfig';
 
 type PreviewState =
   | { type: 'image'; url: string; name: string }
@@ -38,7 +42,7 @@ function ThumbnailPreview({
         {preview.type === 'video' ? (
           videoError.value ? (
             <div class="thumbnail-preview-fallback">
-              H.265 の再生に非対応のブラウザです。ダウンロードしてご覧ください。
+              {t('video.h265_unsupported')}
             </div>
           ) : (
             <video
@@ -63,12 +67,19 @@ export function App() {
   const store = useModel(AppStore);
 
   const sidebar = useSidebar();
+  const { toggle: toggleRecording, syncRemote: syncRecording } = useRecording(store.recording);
 
   const onStatus = useCallback(
     (data: StatusEvent) => {
       sidebar.updateTrajectory(data.latest_detection);
+      store.detectorHealth.value = data.detector_health;
+      if (data.viewers) {
+        store.viewers.value = data.viewers;
+        store.viewerCount.value = String(data.viewers.webrtc + data.viewers.mjpeg);
+      }
+      syncRecording(data.recording);
     },
-    [sidebar.updateTrajectory],
+    [sidebar.updateTrajectory, syncRecording],
   );
 
   const videoPlayer = useVideoPlayer({ onStatus });
@@ -77,8 +88,18 @@ export function App() {
     onDetection: videoPlayer.handleDetection,
     onStatus: videoPlayer.handleStatus,
     onViewerCount: (count) => { store.viewerCount.value = String(count); },
+    onWebRTCStats: (sessions) => {
+      const id = videoPlayer.webrtcSessionId();
+      store.webrtcStats.value = sessions.find((s) => s.id === id) ?? null;
+    },
+    onCameraState: (state) => { store.cameraState.value = state; },
+    onDrain: videoPlayer.reconnectAfterDrain,
   });
 
+  useEffect(() => {
+    loadUIConfig();
+  }, []);
+
   useEffect(() => {
     // Start after DOM mount so videoRef is attached
     videoPlayer.startWebRTC();
@@ -86,8 +107,6 @@ export function App() {
     return () => sse.stop();
   }, []);
 
-  const { toggle: toggleRecording } = useRecording(store.recording);
-
   // Escape キー: store の dismissTopModal が signal を直接読むため deps 不要
   useEffect(() => {
     const handler = (e: KeyboardEvent) => {
@@ -163,19 +182,28 @@ export function App() {
               onToggleRecording={toggleRecording}
               onOpenRecordings={store.openRecordings}
               viewerCount={store.viewerCount.value}
+              viewers={store.viewers.value}
+              detectorHealth={store.detectorHealth.value}
+              webrtcStats={store.webrtcStats.value}
+              cameraWaiting={store.cameraState.value === 'waiting'}
             />
           </div>
-          <div class={mobileTab === 'album' ? 'mobile-hidden' : ''}>
-            <TrackingView
-              canvasRef={sidebar.canvasRef}
-              ganttCanvasRef={sidebar.ganttCanvasRef}
-              legendEntries={sidebar.legendEntries.value}
-            />
-          </div>
+          {panelEnabled('tracking') && (
+            <div class={mobileTab === 'album' ? 'mobile-hidden' : ''}>
+              <TrackingView
+                canvasRef={sidebar.canvasRef}
+                ganttCanvasRef={sidebar.ganttCanvasRef}
+                legendEntries={sidebar.legendEntries.value}
+              />
+            </div>
+          )}
         </div>
 
         <div class={`sidebar ${mobileTab === 'tracking' ? 'mobile-hidden' : ''}`}>
-          <AlbumView />
+          {panelEnabled('diagnostics') && <DiagnosticsView />}
+          {panelEnabled('history') && <HistoryView />}
+          {panelEnabled('cameras') && <CamerasView />}
+          {panelEnabled('album') && <AlbumView />}
         </div>
 
         <Show when={store.recordingsOpen}>
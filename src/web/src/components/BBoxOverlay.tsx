@@ -7,6 +7,7 @@ const STALE_THRESHOLD_MS = 1500;
 export function useBBoxOverlay(videoRef: preact.RefObject<HTMLVideoElement | null>) {
   const canvasRef = useRef<HTMLCanvasElement>(null);
   const detectionsRef = useRef<Detection[]>([]);
+  const detFrameRef = useRef({ width: 1280, height: 720 });
   const lastEventTimeRef = useRef(0);
   const frameInfoRef = useRef({
     baseFrameNumber: 0,
@@ -66,8 +67,8 @@ export function useBBoxOverlay(videoRef: preact.RefObject<HTMLVideoElement | nul
 
       for (const det of detectionsRef.current) {
         const color = classHex(det.class_name);
-        const scaleX = canvas.width / 1280;
-        const scaleY = canvas.height / 720;
+        const scaleX = canvas.width / detFrameRef.current.width;
+        const scaleY = canvas.height / detFrameRef.current.height;
         const x = det.bbox.x * scaleX;
         const y = det.bbox.y * scaleY;
         const w = det.bbox.w * scaleX;
@@ -142,6 +143,9 @@ export function useBBoxOverlay(videoRef: preact.RefObject<HTMLVideoElement | nul
   const handleDetection = useCallback((event: DetectionEvent) => {
     lastEventTimeRef.current = performance.now();
     detectionsRef.current = event.detections || [];
+    if (event.frame && event.frame.width > 0 && event.frame.height > 0) {
+      detFrameRef.current = { width: event.frame.width, height: event.frame.height };
+    }
   }, []);
 
   const handleStatus = useCallback((event: StatusEvent) => {
@@ -0,0 +1,108 @@
+import { useEffect } from 'preact/hooks';
+import { useSignal } from '@preact/signals';
+import { t } from '../lib/uiConfig';
+
+interface PeerStatus {
+  id: string;
+  name: string;
+  online: boolean;
+  latency_ms?: number;
+  error?: string;
+}
+
+interface FederatedEvent {
+  id: number;
+  type: string;
+  timestamp: number;
+  class?: string;
+  camera: string;
+}
+
+const EVENT_LABELS: Record<string, string> = {
+  feeding_started: 'ごはん開始',
+  feeding_ended: 'ごはん終了',
+  drinking_started: '水飲み開始',
+  drinking_ended: '水飲み終了',
+};
+
+const PEER_POLL_MS = 30_000;
+const SNAPSHOT_POLL_MS = 3_000;
+
+function formatTime(ts: number): string {
+  const d = new Date(ts * 1000);
+  const pad = (n: number) => String(n).padStart(2, '0');
+  return `${pad(d.getMonth() + 1)}/${pad(d.getDate())} ${pad(d.getHours())}:${pad(d.getMinutes())}`;
+}
+
+/** Federated cameras (/api/peers) with proxied snapshots and merged events. Hidden when no peers are registered. */
+export function CamerasView() {
+  const peers = useSignal<PeerStatus[]>([]);
+  const events = useSignal<FederatedEvent[]>([]);
+  const tick = useSignal(Date.now());
+
+  useEffect(() => {
+    const load = () => {
+      fetch('/api/peers')
+        .then(r => r.json())
+        .then(d => { peers.value = d.peers ?? []; })
+        .catch(() => {});
+      fetch('/api/federation/events?limit=20')
+        .then(r => r.json())
+        .then(d => { events.value = (d.events ?? []).slice().reverse(); })
+        .catch(() => {});
+    };
+    load();
+    const peerTimer = setInterval(load, PEER_POLL_MS);
+    const snapTimer = setInterval(() => { tick.value = Date.now(); }, SNAPSHOT_POLL_MS);
+    return () => {
+      clearInterval(peerTimer);
+      clearInterval(snapTimer);
+    };
+  }, []);
+
+  if (peers.value.length === 0) return null;
+
+  const names: Record<string, string> = { local: 'このカメラ' };
+  for (const p of peers.value) names[p.id] = p.name;
+
+  return (
+    <div class="panel cameras-panel">
+      <h2>{t('panel.cameras')}</h2>
+      <div class="cameras-grid">
+        {peers.value.map(p => (
+          <a
+            key={p.id}
+            class="camera-card"
+            href={`/api/peers/${encodeURIComponent(p.id)}/proxy/stream`}
+            target="_blank"
+            title={p.online ? `${p.latency_ms ?? 0} ms` : p.error}
+          >
+            {p.online ? (
+              <img
+                src={`/api/peers/${encodeURIComponent(p.id)}/proxy/api/snapshot?t=${tick.value}`}
+                alt={p.name}
+              />
+            ) : (
+              <div class="camera-card-offline">オフライン</div>
+            )}
+            <span class="camera-card-name">
+              <span class={`camera-dot ${p.online ? 'online' : ''}`} />
+              {p.name}
+            </span>
+          </a>
+        ))}
+      </div>
+      {events.value.length > 0 && (
+        <ul class="camera-events">
+          {events.value.map(ev => (
+            <li key={`${ev.camera}-${ev.id}`}>
+              <span class="camera-events-time">{formatTime(ev.timestamp)}</span>
+              <span class="camera-events-cam">{names[ev.camera] ?? ev.camera}</span>
+              <span>{EVENT_LABELS[ev.type] ?? ev.type}{ev.class ? ` (${ev.class})` : ''}</span>
+            </li>
+          ))}
+        </ul>
+      )}
+    </div>
+  );
+}
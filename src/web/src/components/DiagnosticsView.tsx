@@ -0,0 +1,57 @@
+import { useEffect, useCallback } from 'preact/hooks';
+import { useSignal } from '@preact/signals';
+import { t } from '../lib/uiConfig';
+
+interface DiagnosticResult {
+  name: string;
+  status: 'ok' | 'warn' | 'fail';
+  detail: string;
+  hint?: string;
+}
+
+const POLL_MS = 60_000;
+
+/** Environment checks from /api/diagnostics. Hidden while every check passes. */
+export function DiagnosticsView() {
+  const results = useSignal<DiagnosticResult[]>([]);
+  const loading = useSignal(false);
+
+  const load = useCallback(() => {
+    loading.value = true;
+    fetch('/api/diagnostics')
+      .then(r => r.json())
+      .then(d => { results.value = d.results ?? []; })
+      .catch(() => {})
+      .finally(() => { loading.value = false; });
+  }, []);
+
+  useEffect(() => {
+    load();
+    const timer = setInterval(load, POLL_MS);
+    return () => clearInterval(timer);
+  }, []);
+
+  const problems = results.value.filter(r => r.status !== 'ok');
+  if (problems.length === 0) return null;
+
+  return (
+    <div class="panel diagnostics-panel">
+      <div class="diagnostics-header">
+        <h2>{t('panel.diagnostics')}</h2>
+        <button class="recordings-refresh" onClick={load} disabled={loading.value}>再チェック</button>
+      </div>
+      <ul class="diagnostics-list">
+        {problems.map(r => (
+          <li key={r.name} class={`diagnostics-item ${r.status}`}>
+            <div class="diagnostics-name">
+              <span class="diagnostics-badge">{r.status === 'fail' ? 'NG' : '注意'}</span>
+              {r.name}
+            </div>
+            <div class="diagnostics-detail">{r.detail}</div>
+            {r.hint && <div class="diagnostics-hint">{r.hint}</div>}
+          </li>
+        ))}
+      </ul>
+    </div>
+  );
+}
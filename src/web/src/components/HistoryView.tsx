@@ -0,0 +1,109 @@
+import { useEffect, useCallback } from 'preact/hooks';
+import { useSignal } from '@preact/signals';
+import { t } from '../lib/uiConfig';
+
+interface Point {
+  t: number;
+  avg: number;
+  max: number;
+}
+
+interface Chart {
+  metric: string;
+  label: string;
+  unit: string;
+}
+
+const CHARTS: Chart[] = [
+  { metric: 'fps', label: 'FPS', unit: '' },
+  { metric: 'cpu_percent', label: 'CPU', unit: '%' },
+  { metric: 'webrtc_kbps', label: 'WebRTC', unit: 'kbps' },
+  { metric: 'webrtc_jitter_ms', label: 'ジッター', unit: 'ms' },
+  { metric: 'mjpeg_kbps', label: 'MJPEG', unit: 'kbps' },
+  { metric: 'viewers', label: '視聴者', unit: '' },
+];
+
+const RANGES = ['1h', '24h', '7d'] as const;
+const POLL_MS = 60_000;
+const WIDTH = 200;
+const HEIGHT = 36;
+
+/** SVG polyline of bucket averages, scaled to the series maximum. */
+function Sparkline({ points }: { points: Point[] }) {
+  if (points.length < 2) return <svg class="history-spark" viewBox={`0 0 ${WIDTH} ${HEIGHT}`} />;
+  const t0 = points[0].t;
+  const span = points[points.length - 1].t - t0 || 1;
+  const top = Math.max(...points.map(p => p.avg)) || 1;
+  const coords = points
+    .map(p => `${((p.t - t0) / span * WIDTH).toFixed(1)},${(HEIGHT - p.avg / top * (HEIGHT - 2) - 1).toFixed(1)}`)
+    .join(' ');
+  return (
+    <svg class="history-spark" viewBox={`0 0 ${WIDTH} ${HEIGHT}`} preserveAspectRatio="none">
+      <polyline points={coords} />
+    </svg>
+  );
+}
+
+/** On-device metric history from /api/timeseries. Hidden until samples exist. */
+export function HistoryView() {
+  const range = useSignal<(typeof RANGES)[number]>('1h');
+  const series = useSignal<Record<string, Point[]>>({});
+
+  const load = useCallback(() => {
+    fetch('/api/timeseries')
+      .then(r => r.json())
+      .then(async (d: { metrics?: string[] }) => {
+        const available = new Set(d.metrics ?? []);
+        const next: Record<string, Point[]> = {};
+        await Promise.all(CHARTS.filter(c => available.has(c.metric)).map(c =>
+          fetch(`/api/timeseries?metric=${c.metric}&range=${range.value}`)
+            .then(r => r.json())
+            .then(s => { next[c.metric] = s.points ?? []; })
+            .catch(() => {})
+        ));
+        series.value = next;
+      })
+      .catch(() => {});
+  }, []);
+
+  useEffect(() => {
+    load();
+    const timer = setInterval(load, POLL_MS);
+    return () => clearInterval(timer);
+  }, []);
+
+  const charts = CHARTS.filter(c => series.value[c.metric]?.length);
+  if (charts.length === 0) return null;
+
+  return (
+    <div class="panel history-panel">
+      <div class="history-header">
+        <h2>{t('panel.history')}</h2>
+        <div class="history-ranges">
+          {RANGES.map(r => (
+            <button
+              key={r}
+              class={`history-range ${range.value === r ? 'active' : ''}`}
+              onClick={() => { range.value = r; load(); }}
+            >
+              {r}
+            </button>
+          ))}
+        </div>
+      </div>
+      {charts.map(c => {
+        const points = series.value[c.metric];
+        const last = points[points.length - 1];
+        return (
+          <div key={c.metric} class="history-row">
+            <span class="history-label">{c.label}</span>
+            <Sparkline points={points} />
+            <span class="history-value">
+              {last.avg.toFixed(last.avg < 10 ? 1 : 0)}{c.unit}
+            </span>
+          </div>
+        );
+      })}
+    </div>
+  );
+}
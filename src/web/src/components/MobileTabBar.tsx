@@ -1,4 +1,5 @@
 import type { MobileTab } from '../lib/store';
+import { t } from '../lib/uiConfig';
 
 interface Props {
   activeTab: MobileTab;
@@ -13,21 +14,21 @@ export function MobileTabBar({ activeTab, onTabChange }: Props) {
         onClick={() => onTabChange('live')}
       >
         <span class="mobile-tab-icon tab-icon-live" />
-        <span>Live</span>
+        <span>{t('tab.live')}</span>
       </button>
       <button
         class={`mobile-tab ${activeTab === 'tracking' ? 'active' : ''}`}
         onClick={() => onTabChange('tracking')}
       >
         <span class="mobile-tab-icon tab-icon-tracking" />
-        <span>Tracking</span>
+        <span>{t('tab.tracking')}</span>
       </button>
       <button
         class={`mobile-tab ${activeTab === 'album' ? 'active' : ''}`}
         onClick={() => onTabChange('album')}
       >
         <span class="mobile-tab-icon tab-icon-album" />
-        <span>Album</span>
+        <span>{t('tab.album')}</span>
       </button>
     </nav>
   );
@@ -5,6 +5,15 @@ interface Recording {
   name: string;
   size_bytes: number;
   thumbnail?: string;
+  corrupt?: boolean;
+  scrub_error?: string;
+}
+
+interface RecordingStats {
+  days: { date: string; bytes: number; clips: number }[];
+  triggers: Record<string, number>;
+  avg_duration_sec?: number;
+  headroom?: { free_bytes: number; daily_bytes: number; days_left?: number };
 }
 
 interface Props {
@@ -19,6 +28,17 @@ function formatFileSize(bytes: number): string {
   return (bytes / 1024 / 1024).toFixed(1) + ' MB';
 }
 
+function formatDuration(sec: number): string {
+  const s = Math.round(sec);
+  return `${Math.floor(s / 60)}:${String(s % 60).padStart(2, '0')}`;
+}
+
+function formatHeadroom(h: NonNullable<RecordingStats['headroom']>): string {
+  if (!h.days_left) return `${formatFileSize(h.free_bytes)} free`;
+  const days = h.days_left < 10 ? h.days_left.toFixed(1) : Math.round(h.days_left);
+  return `${formatFileSize(h.free_bytes)} free, full in ~${days} days at ${formatFileSize(h.daily_bytes)}/day`;
+}
+
 function parseRecordingDate(filename: string): Date | null {
   const match = filename.match(/recording_(\d{4})(\d{2})(\d{2})_(\d{2})(\d{2})(\d{2})/);
   if (!match) return null;
@@ -33,6 +53,7 @@ function formatDate(date: Date | null): string {
 
 export function RecordingsModal({ onClose, onOpenThumbnail, onPlayVideo }: Props) {
   const recordings = useSignal<Recording[]>([]);
+  const stats = useSignal<RecordingStats | null>(null);
   const lastPlayed = useSignal<string | null>(null);
 
   const fetchRecordings = useCallback(async () => {
@@ -42,6 +63,10 @@ export function RecordingsModal({ onClose, onOpenThumbnail, onPlayVideo }: Props
       const data = await res.json();
       recordings.value = data.recordings || [];
     } catch { /* ignore */ }
+    try {
+      const res = await fetch('/api/recordings/stats');
+      if (res.ok) stats.value = await res.json();
+    } catch { /* ignore */ }
   }, []);
 
   // マウント時に一度フェッチ
@@ -51,6 +76,8 @@ export function RecordingsModal({ onClose, onOpenThumbnail, onPlayVideo }: Props
 
   const recs = recordings.value;
   const totalBytes = recs.reduce((sum, r) => sum + r.size_bytes, 0);
+  const st = stats.value;
+  const today = st?.days[st.days.length - 1];
 
   const downloadRecording = (name: string) => {
     const a = document.createElement('a');
@@ -92,6 +119,14 @@ export function RecordingsModal({ onClose, onOpenThumbnail, onPlayVideo }: Props
                   <span class="recordings-summary">{recs.length} files / {formatFileSize(totalBytes)}</span>
                   <button class="recordings-refresh" onClick={fetchRecordings}>Refresh</button>
                 </div>
+                {st && (
+                  <div class="recordings-stats recordings-summary">
+                    {today && <span>Latest day ({today.date}): {today.clips} clips / {formatFileSize(today.bytes)}</span>}
+                    <span>{Object.entries(st.triggers).map(([t, n]) => `${t} ${n}`).join(' · ')}</span>
+                    {st.avg_duration_sec !== undefined && <span>Avg clip {formatDuration(st.avg_duration_sec)}</span>}
+                    {st.headroom && <span>{formatHeadroom(st.headroom)}</span>}
+                  </div>
+                )}
                 {recs.map((rec) => {
                   const date = parseRecordingDate(rec.name);
                   const thumbUrl = rec.thumbnail ? `/api/recordings/${encodeURIComponent(rec.thumbnail)}` : null;
@@ -114,6 +149,7 @@ export function RecordingsModal({ onClose, onOpenThumbnail, onPlayVideo }: Props
                         <div class="recording-date">
                           {formatDate(date)}
                           {isH264 && <span style="color:#f0c040;font-size:11px;"> (converting)</span>}
+                          {rec.corrupt && <span style="color:#f06060;font-size:11px;" title={rec.scrub_error}> (corrupt)</span>}
                         </div>
                         <div class="recording-size">{formatFileSize(rec.size_bytes)}</div>
                       </div>
@@ -536,7 +536,8 @@ export function useSidebar() {
   useEffect(() => {
     fetch('/api/detections/history')
       .then((r) => r.json())
-      .then((records: { timestamp: number; classes: string[] }[]) => {
+      .then((page: { records: { timestamp: number; classes: string[] }[] }) => {
+        const records = page.records;
         if (Array.isArray(records) && records.length > 0) {
           ganttRef.current = records;
           const classSet = new Set<string>();
@@ -1,6 +1,9 @@
 import { useCallback, useRef } from 'preact/hooks';
 import { useSignal, useSignalEffect } from '@preact/signals';
 import type { RecordingState } from '../hooks/useRecording';
+import type { DetectorHealth, Viewers } from '../lib/protobuf';
+import type { WebRTCSessionStats } from '../hooks/useSSE';
+import { usePush } from '../hooks/usePush';
 
 interface Props {
   mode: 'webrtc' | 'mjpeg';
@@ -10,6 +13,39 @@ interface Props {
   onToggleRecording: () => void;
   onOpenRecordings: () => void;
   viewerCount: string;
+  viewers: Viewers | null;
+  detectorHealth: DetectorHealth | null;
+  webrtcStats: WebRTCSessionStats | null;
+  cameraWaiting: boolean;
+}
+
+const QUALITY_LABELS: Record<WebRTCSessionStats['quality'], string> = {
+  good: '良好',
+  fair: 'やや不安定',
+  poor: '不安定',
+  unknown: '計測中',
+};
+
+function qualityTitle(s: WebRTCSessionStats): string {
+  return [
+    `${Math.round(s.bitrate_kbps)} kbps`,
+    `loss ${(s.fraction_lost * 100).toFixed(1)}% (${s.packets_lost})`,
+    `jitter ${s.jitter_ms.toFixed(0)} ms`,
+    `NACK ${s.nack_count} / rtx ${s.retransmits}`,
+    `drop ${s.frames_dropped}/${s.frames_sent}`,
+    ...(s.address_family ? [s.address_family === 'ipv6' ? 'IPv6' : 'IPv4'] : []),
+  ].join('\n');
+}
+
+function viewersTitle(v: Viewers | null): string {
+  if (!v) return '';
+  const total = v.webrtc + v.mjpeg;
+  const now = Date.now() / 1000;
+  const lines = v.clients.map((c) => {
+    const min = Math.max(0, Math.round((now - c.connected_at) / 60));
+    return `${c.kind === 'webrtc' ? 'HD' : 'Lite'} #${c.id.slice(0, 4)} · ${min} min · ${c.quality}`;
+  });
+  return [`${total} viewer${total === 1 ? '' : 's'} watching`, ...lines].join('\n');
 }
 
 type CaptureState = 'idle' | 'input' | 'capturing' | 'ok' | 'error';
@@ -22,10 +58,15 @@ export function VideoControls({
   onToggleRecording,
   onOpenRecordings,
   viewerCount,
+  viewers,
+  detectorHealth,
+  webrtcStats,
+  cameraWaiting,
 }: Props) {
   const captureState = useSignal<CaptureState>('idle');
   const captionText = useSignal('');
   const inputRef = useRef<HTMLInputElement>(null);
+  const push = usePush();
 
   // Focus input when bubble opens
   useSignalEffect(() => {
@@ -81,7 +122,7 @@ export function VideoControls({
 
   const recBtnClass = [
     'record-btn',
-    recording.isRecording ? 'recording' : '',
+    recording.isRecording || recording.remoteOwner ? 'recording' : '',
     recording.isStopping ? 'stopping' : '',
     recording.isConverting ? 'converting' : '',
   ]
@@ -90,13 +131,13 @@ export function VideoControls({
 
   const statusClass = [
     'record-status',
-    recording.isRecording ? 'recording' : '',
+    recording.isRecording || recording.remoteOwner ? 'recording' : '',
     recording.isConverting ? 'converting' : '',
   ]
     .filter(Boolean)
     .join(' ');
 
-  const showStatus = recording.isRecording || recording.isConverting;
+  const showStatus = recording.isRecording || recording.isConverting || !!recording.remoteOwner;
 
   const cs = captureState.value;
   const captureBtnClass = [
@@ -119,11 +160,40 @@ export function VideoControls({
           </button>
         </div>
       </div>
-      <div class="viewer-count">
+      <div class="viewer-count" title={viewersTitle(viewers)}>
         <span class="viewer-icon">👁</span>
         <span>{viewerCount}</span>
       </div>
+      {mode === 'webrtc' && webrtcStats && (
+        <div class={`quality-badge ${webrtcStats.quality}`} title={qualityTitle(webrtcStats)}>
+          <span class="quality-dot" />
+          {QUALITY_LABELS[webrtcStats.quality]}
+        </div>
+      )}
+      {cameraWaiting && (
+        <div class="detector-badge" title="Streaming server is waiting for the camera daemon's shared memory">
+          Waiting for camera
+        </div>
+      )}
+      {detectorHealth && !detectorHealth.healthy && (
+        <div
+          class={`detector-badge ${detectorHealth.alerting ? 'alerting' : ''}`}
+          title={`Detector stale for ${Math.round(detectorHealth.stale_seconds)}s`}
+        >
+          {detectorHealth.motion_fallback ? 'Motion only' : 'Detector offline'}
+        </div>
+      )}
       <div class="controls-primary">
+        {push.state.value !== 'unsupported' && (
+          <button
+            class={`btn-push ${push.state.value === 'on' ? 'active' : ''}`}
+            title={push.state.value === 'denied' ? 'Notifications blocked' : 'Push notifications'}
+            onClick={push.toggle}
+            disabled={push.state.value === 'busy'}
+          >
+            {push.state.value === 'on' ? '🔔' : '🔕'}
+          </button>
+        )}
         <button class="btn-recordings" title="Recordings" onClick={onOpenRecordings}>
           <span class="recordings-icon" />
         </button>
@@ -157,7 +227,7 @@ export function VideoControls({
         </div>
         <button
           class={recBtnClass}
-          title="REC"
+          title={recording.remoteOwner ? `REC by ${recording.remoteOwner}` : 'REC'}
           onClick={onToggleRecording}
           disabled={recording.isConverting || recording.isStopping}
         >
@@ -14,6 +14,7 @@ export function useVideoPlayer(options: UseVideoPlayerOptions = {}) {
   const videoRef = useRef<HTMLVideoElement>(null);
   const mjpegRef = useRef<HTMLImageElement>(null);
   const fallbackAttempted = useRef(false);
+  const drainTimer = useRef<ReturnType<typeof setTimeout> | null>(null);
 
   const { canvasRef, handleDetection, handleStatus } = useBBoxOverlay(videoRef);
 
@@ -79,9 +80,24 @@ export function useVideoPlayer(options: UseVideoPlayerOptions = {}) {
     webrtc.start().catch(() => {});
   }, [webrtc]);
 
+  // Streaming server drain (restart/update): close the peer connection now,
+  // so its failure does not trigger the MJPEG fallback, and reconnect once
+  // the server is back. The notice repeats on every connections event.
+  const reconnectAfterDrain = useCallback((afterSec: number) => {
+    if (drainTimer.current || mode.peek() !== 'webrtc') return;
+    webrtc.stop();
+    drainTimer.current = setTimeout(() => {
+      drainTimer.current = null;
+      if (mode.peek() !== 'webrtc') return;
+      fallbackAttempted.current = false;
+      webrtc.start().catch(() => {});
+    }, afterSec * 1000);
+  }, [webrtc]);
+
   // Cleanup on unmount
   useEffect(() => {
     return () => {
+      if (drainTimer.current) clearTimeout(drainTimer.current);
       webrtc.stop();
       stopMJPEG();
     };
@@ -113,5 +129,7 @@ export function useVideoPlayer(options: UseVideoPlayerOptions = {}) {
     mjpegRef,
     handleDetection: wrappedDetection,
     handleStatus: wrappedStatus,
+    webrtcSessionId: webrtc.sessionId,
+    reconnectAfterDrain,
   };
 }
@@ -0,0 +1,69 @@
+import { useSignal } from '@preact/signals';
+import { useCallback, useEffect } from 'preact/hooks';
+
+export type PushState = 'unsupported' | 'off' | 'on' | 'busy' | 'denied';
+
+function urlBase64ToUint8Array(base64: string): Uint8Array {
+  const padding = '='.repeat((4 - (base64.length % 4)) % 4);
+  const raw = atob((base64 + padding).replace(/-/g, '+').replace(/_/g, '/'));
+  return Uint8Array.from(raw, (c) => c.charCodeAt(0));
+}
+
+const supported = () =>
+  typeof window !== 'undefined' && 'serviceWorker' in navigator && 'PushManager' in window;
+
+/** Web Push subscription toggle (VAPID key from /api/push/vapid-public-key). */
+export function usePush() {
+  const state = useSignal<PushState>(supported() ? 'off' : 'unsupported');
+
+  useEffect(() => {
+    if (!supported()) return;
+    navigator.serviceWorker
+      .register('/sw.js')
+      .then((reg) => reg.pushManager.getSubscription())
+      .then((sub) => { state.value = sub ? 'on' : 'off'; })
+      .catch(() => { state.value = 'unsupported'; });
+  }, []);
+
+  const toggle = useCallback(async () => {
+    if (state.value === 'unsupported' || state.value === 'busy') return;
+    const prev = state.value;
+    state.value = 'busy';
+    try {
+      const reg = await navigator.serviceWorker.ready;
+      const existing = await reg.pushManager.getSubscription();
+      if (existing) {
+        await fetch('/api/push/subscription', {
+          method: 'DELETE',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify(existing.toJSON()),
+        });
+        await existing.unsubscribe();
+        state.value = 'off';
+        return;
+      }
+      if ((await Notification.requestPermission()) !== 'granted') {
+        state.value = 'denied';
+        return;
+      }
+      const res = await fetch('/api/push/vapid-public-key');
+      if (!res.ok) throw new Error(`vapid key: ${res.status}`);
+      const { public_key } = await res.json();
+      const sub = await reg.pushManager.subscribe({
+        userVisibleOnly: true,
+        applicationServerKey: urlBase64ToUint8Array(public_key),
+      });
+      await fetch('/api/push/subscription', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(sub.toJSON()),
+      });
+      state.value = 'on';
+    } catch (e) {
+      console.warn('[Push]', e);
+      state.value = prev;
+    }
+  }, []);
+
+  return { state, toggle };
+}
@@ -1,19 +1,26 @@
 import { useRef, useCallback, useEffect } from 'preact/hooks';
 import type { Signal } from '@preact/signals';
+import type { RecordingInfo, RecordingOwner } from '../lib/protobuf';
 
 export interface RecordingState {
   isRecording: boolean;
   isConverting: boolean;
   isStopping: boolean;
   statusText: string;
+  /** Label of another viewer (or rule) recording right now; unset otherwise. */
+  remoteOwner?: string;
 }
 
+const ownerLabel = (owner: RecordingOwner | null | undefined) =>
+  owner ? owner.name || owner.id : 'another viewer';
+
 export function useRecording(recordingState: Signal<RecordingState>) {
   const startTimeRef = useRef<number>(0);
   const timerRef = useRef<ReturnType<typeof setInterval> | null>(null);
   const heartbeatRef = useRef<ReturnType<typeof setInterval> | null>(null);
   const isRecordingRef = useRef(false);
   const isStoppingRef = useRef(false);
+  const ownerIdRef = useRef('');
 
   const clearIntervals = useCallback(() => {
     if (timerRef.current) { clearInterval(timerRef.current); timerRef.current = null; }
@@ -91,7 +98,13 @@ export function useRecording(recordingState: Signal<RecordingState>) {
     try {
       const res = await fetch('/api/recording/start', { method: 'POST' });
       const data = await res.json();
+      if (res.status === 409) {
+        alert(`Already recording (started by ${ownerLabel(data.owner)})`);
+        recordingState.value = { ...recordingState.peek(), statusText: '' };
+        return null;
+      }
       if (!res.ok) throw new Error(data.error || 'Failed to start recording');
+      ownerIdRef.current = data.owner?.id ?? '';
 
       isRecordingRef.current = true;
       startTimeRef.current = Date.now();
@@ -139,18 +152,52 @@ export function useRecording(recordingState: Signal<RecordingState>) {
     }
   }, [clearIntervals, waitForConversion]);
 
+  // Stop a recording another viewer started (anyone may stop it).
+  const stopRemote = useCallback(async (label: string) => {
+    if (!confirm(`Stop the recording started by ${label}?`)) return;
+    try {
+      const res = await fetch('/api/recording/stop', { method: 'POST' });
+      if (!res.ok) {
+        const data = await res.json();
+        throw new Error(data.error || 'Failed to stop recording');
+      }
+    } catch (error) {
+      alert('Recording stop failed: ' + (error as Error).message);
+    }
+  }, []);
+
+  // Mirror the server's recording state from status events, so a recording
+  // started by another viewer or a rule shows up here too.
+  const syncRemote = useCallback((remote: RecordingInfo | null) => {
+    if (isRecordingRef.current || isStoppingRef.current) return;
+    const current = recordingState.peek();
+    if (remote?.active && remote.owner?.id !== ownerIdRef.current) {
+      const label = ownerLabel(remote.owner);
+      if (current.remoteOwner !== label) {
+        recordingState.value = { isRecording: false, isConverting: false, isStopping: false, statusText: `REC by ${label}`, remoteOwner: label };
+      }
+    } else if (current.remoteOwner) {
+      recordingState.value = { isRecording: false, isConverting: false, isStopping: false, statusText: '' };
+    }
+  }, []);
+
   const toggle = useCallback(async () => {
     if (isStoppingRef.current) return;
+    const remoteOwner = recordingState.peek().remoteOwner;
+    if (!isRecordingRef.current && remoteOwner) {
+      await stopRemote(remoteOwner);
+      return;
+    }
     if (isRecordingRef.current) {
       await stop();
     } else {
       await start();
     }
-  }, [start, stop]);
+  }, [start, stop, stopRemote]);
 
   useEffect(() => {
     return () => clearIntervals();
   }, [clearIntervals]);
 
-  return { toggle };
+  return { toggle, syncRemote };
 }
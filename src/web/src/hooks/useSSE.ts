@@ -2,10 +2,34 @@ import { useRef, useEffect, useCallback } from 'preact/hooks';
 import { base64ToBytes, decodeDetectionEvent, decodeStatusEvent } from '../lib/protobuf';
 import type { DetectionEvent, StatusEvent } from '../lib/protobuf';
 
+/** Per-session WebRTC quality from the streaming server's RTCP stats. */
+export interface WebRTCSessionStats {
+  id: string;
+  connected: boolean;
+  frames_sent: number;
+  frames_dropped: number;
+  bitrate_kbps: number;
+  fraction_lost: number;
+  packets_lost: number;
+  jitter_ms: number;
+  nack_count: number;
+  retransmits: number;
+  pli_count: number;
+  quality: 'good' | 'fair' | 'poor' | 'unknown';
+  address_family?: 'ipv4' | 'ipv6';
+}
+
+/** Streaming server camera SHM state ('' = streaming server unreachable). */
+export type CameraState = 'attached' | 'waiting' | '';
+
 interface SSEOptions {
   onDetection?: (event: DetectionEvent) => void;
   onStatus?: (event: StatusEvent) => void;
   onViewerCount?: (count: number) => void;
+  onWebRTCStats?: (sessions: WebRTCSessionStats[]) => void;
+  onCameraState?: (state: CameraState) => void;
+  /** Streaming server is draining: drop WebRTC and reconnect after this many seconds. */
+  onDrain?: (reconnectAfterSec: number) => void;
 }
 
 function createSSE(
@@ -95,6 +119,11 @@ export function useSSE(options: SSEOptions) {
       try {
         const d = JSON.parse(data);
         optionsRef.current.onViewerCount?.((d.webrtc || 0) + (d.mjpeg || 0));
+        optionsRef.current.onWebRTCStats?.(d.webrtc_sessions ?? []);
+        optionsRef.current.onCameraState?.(d.camera ?? '');
+        if (d.drain_reconnect_after_sec !== undefined) {
+          optionsRef.current.onDrain?.(d.drain_reconnect_after_sec);
+        }
       } catch { /* ignore */ }
     };
 
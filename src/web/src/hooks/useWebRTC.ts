@@ -4,12 +4,28 @@ export interface WebRTCState {
   connectionState: string;
 }
 
+const DEFAULT_ICE_SERVERS: RTCIceServer[] = [{ urls: 'stun:stun.l.google.com:19302' }];
+
+// ICE servers configured on the monitor (-stun); falls back to the default
+async function fetchIceServers(): Promise<RTCIceServer[]> {
+  try {
+    const res = await fetch('/api/webrtc/config');
+    if (!res.ok) return DEFAULT_ICE_SERVERS;
+    const config = await res.json();
+    return config.ice_servers ?? DEFAULT_ICE_SERVERS;
+  } catch {
+    return DEFAULT_ICE_SERVERS;
+  }
+}
+
 export function useWebRTC(
   videoRef: preact.RefObject<HTMLVideoElement | null>,
   onError?: (error: Error) => void,
 ) {
   const pcRef = useRef<RTCPeerConnection | null>(null);
   const stateRef = useRef<string>('disconnected');
+  // Streaming-server session ID, used to find this viewer in /api/connections stats
+  const sessionIdRef = useRef<string | null>(null);
 
   const stop = useCallback(() => {
     if (pcRef.current) {
@@ -22,6 +38,7 @@ export function useWebRTC(
       video.srcObject = null;
     }
     stateRef.current = 'disconnected';
+    sessionIdRef.current = null;
   }, [videoRef]);
 
   const start = useCallback(async () => {
@@ -33,7 +50,7 @@ export function useWebRTC(
 
     try {
       const pc = new RTCPeerConnection({
-        iceServers: [{ urls: 'stun:stun.l.google.com:19302' }],
+        iceServers: await fetchIceServers(),
         bundlePolicy: 'max-bundle',
         rtcpMuxPolicy: 'require',
       });
@@ -68,7 +85,8 @@ export function useWebRTC(
       }
 
       const answer = await response.json();
-      await pc.setRemoteDescription(new RTCSessionDescription(answer));
+      sessionIdRef.current = answer.session_id ?? null;
+      await pc.setRemoteDescription(new RTCSessionDescription({ type: answer.type, sdp: answer.sdp }));
 
       video.play().catch(() => {});
     } catch (error) {
@@ -77,10 +95,11 @@ export function useWebRTC(
   }, [videoRef, stop, onError]);
 
   const isConnected = useCallback(() => stateRef.current === 'connected', []);
+  const sessionId = useCallback(() => sessionIdRef.current, []);
 
   useEffect(() => {
     return () => stop();
   }, [stop]);
 
-  return { start, stop, isConnected };
+  return { start, stop, isConnected, sessionId };
 }
@@ -10,12 +10,29 @@ export interface Detection {
   confidence: number;
   class_id: number;
   class_name: string;
+  pet_id?: string;
+  bbox_norm?: BBox;
+}
+
+export interface DetectorInput {
+  width: number;
+  height: number;
+  content: BBox;
+}
+
+// Frame the bboxes are in (1280x720, the whole video), and the detector
+// input they were mapped from, if any.
+export interface DetectionFrame {
+  width: number;
+  height: number;
+  detector?: DetectorInput;
 }
 
 export interface DetectionEvent {
   frame_number: number;
   timestamp: number;
   detections: Detection[];
+  frame?: DetectionFrame;
 }
 
 export interface MonitorStats {
@@ -38,6 +55,39 @@ export interface DetectionResult {
   num_detections: number;
   version: number;
   detections: Detection[];
+  frame?: DetectionFrame;
+}
+
+export interface DetectorHealth {
+  healthy: boolean;
+  stale_seconds: number;
+  alerting: boolean;
+  motion_fallback: boolean;
+}
+
+export interface ViewerInfo {
+  id: string;
+  kind: 'webrtc' | 'mjpeg';
+  connected_at: number;
+  quality: string;
+}
+
+export interface Viewers {
+  webrtc: number;
+  mjpeg: number;
+  clients: ViewerInfo[];
+}
+
+export interface RecordingOwner {
+  id: string;
+  name: string;
+}
+
+export interface RecordingInfo {
+  active: boolean;
+  file: string;
+  owner: RecordingOwner | null;
+  started_at: number;
 }
 
 export interface StatusEvent {
@@ -46,6 +96,9 @@ export interface StatusEvent {
   latest_detection: DetectionResult | null;
   detection_history: DetectionResult[];
   timestamp: number;
+  detector_health: DetectorHealth | null;
+  viewers: Viewers | null;
+  recording: RecordingInfo | null;
 }
 
 class ProtobufDecoder {
@@ -152,6 +205,55 @@ function decodeBBox(bytes: Uint8Array): BBox {
   return bbox;
 }
 
+function decodeNormBBox(bytes: Uint8Array): BBox {
+  const d = new ProtobufDecoder(bytes);
+  const bbox: BBox = { x: 0, y: 0, w: 0, h: 0 };
+  while (d.remaining > 0) {
+    const tag = d.readTag();
+    if (!tag) break;
+    switch (tag.fieldNumber) {
+      case 1: bbox.x = d.readFloat(); break;
+      case 2: bbox.y = d.readFloat(); break;
+      case 3: bbox.w = d.readFloat(); break;
+      case 4: bbox.h = d.readFloat(); break;
+      default: d.skipField(tag.wireType);
+    }
+  }
+  return bbox;
+}
+
+function decodeDetectorInput(bytes: Uint8Array): DetectorInput {
+  const d = new ProtobufDecoder(bytes);
+  const input: DetectorInput = { width: 0, height: 0, content: { x: 0, y: 0, w: 0, h: 0 } };
+  while (d.remaining > 0) {
+    const tag = d.readTag();
+    if (!tag) break;
+    switch (tag.fieldNumber) {
+      case 1: input.width = d.readVarint(); break;
+      case 2: input.height = d.readVarint(); break;
+      case 3: input.content = decodeBBox(d.readBytes()); break;
+      default: d.skipField(tag.wireType);
+    }
+  }
+  return input;
+}
+
+function decodeDetectionFrame(bytes: Uint8Array): DetectionFrame {
+  const d = new ProtobufDecoder(bytes);
+  const frame: DetectionFrame = { width: 0, height: 0 };
+  while (d.remaining > 0) {
+    const tag = d.readTag();
+    if (!tag) break;
+    switch (tag.fieldNumber) {
+      case 1: frame.width = d.readVarint(); break;
+      case 2: frame.height = d.readVarint(); break;
+      case 3: frame.detector = decodeDetectorInput(d.readBytes()); break;
+      default: d.skipField(tag.wireType);
+    }
+  }
+  return frame;
+}
+
 function decodeDetection(bytes: Uint8Array): Detection {
   const d = new ProtobufDecoder(bytes);
   const det: Detection = { bbox: { x: 0, y: 0, w: 0, h: 0 }, confidence: 0, class_id: 0, class_name: '' };
@@ -163,6 +265,8 @@ function decodeDetection(bytes: Uint8Array): Detection {
       case 2: det.confidence = d.readFloat(); break;
       case 3: det.class_id = d.readVarint(); break;
       case 4: det.class_name = d.readString(); break;
+      case 5: det.pet_id = d.readString(); break;
+      case 6: det.bbox_norm = decodeNormBBox(d.readBytes()); break;
       default: d.skipField(tag.wireType);
     }
   }
@@ -179,6 +283,7 @@ export function decodeDetectionEvent(bytes: Uint8Array): DetectionEvent {
       case 1: event.frame_number = d.readVarint64(); break;
       case 2: event.timestamp = d.readDouble(); break;
       case 3: event.detections.push(decodeDetection(d.readBytes())); break;
+      case 4: event.frame = decodeDetectionFrame(d.readBytes()); break;
       default: d.skipField(tag.wireType);
     }
   }
@@ -240,12 +345,95 @@ function decodeDetectionResult(bytes: Uint8Array): DetectionResult {
       case 3: result.num_detections = d.readVarint(); break;
       case 4: result.version = d.readVarint(); break;
       case 5: result.detections.push(decodeDetection(d.readBytes())); break;
+      case 6: result.frame = decodeDetectionFrame(d.readBytes()); break;
       default: d.skipField(tag.wireType);
     }
   }
   return result;
 }
 
+function decodeDetectorHealth(bytes: Uint8Array): DetectorHealth {
+  const d = new ProtobufDecoder(bytes);
+  const health: DetectorHealth = { healthy: false, stale_seconds: 0, alerting: false, motion_fallback: false };
+  while (d.remaining > 0) {
+    const tag = d.readTag();
+    if (!tag) break;
+    switch (tag.fieldNumber) {
+      case 1: health.healthy = d.readVarint() !== 0; break;
+      case 2: health.stale_seconds = d.readDouble(); break;
+      case 3: health.alerting = d.readVarint() !== 0; break;
+      case 4: health.motion_fallback = d.readVarint() !== 0; break;
+      default: d.skipField(tag.wireType);
+    }
+  }
+  return health;
+}
+
+function decodeViewerInfo(bytes: Uint8Array): ViewerInfo {
+  const d = new ProtobufDecoder(bytes);
+  const viewer: ViewerInfo = { id: '', kind: 'webrtc', connected_at: 0, quality: '' };
+  while (d.remaining > 0) {
+    const tag = d.readTag();
+    if (!tag) break;
+    switch (tag.fieldNumber) {
+      case 1: viewer.id = d.readString(); break;
+      case 2: viewer.kind = d.readString() as ViewerInfo['kind']; break;
+      case 3: viewer.connected_at = d.readDouble(); break;
+      case 4: viewer.quality = d.readString(); break;
+      default: d.skipField(tag.wireType);
+    }
+  }
+  return viewer;
+}
+
+function decodeViewers(bytes: Uint8Array): Viewers {
+  const d = new ProtobufDecoder(bytes);
+  const viewers: Viewers = { webrtc: 0, mjpeg: 0, clients: [] };
+  while (d.remaining > 0) {
+    const tag = d.readTag();
+    if (!tag) break;
+    switch (tag.fieldNumber) {
+      case 1: viewers.webrtc = d.readVarint(); break;
+      case 2: viewers.mjpeg = d.readVarint(); break;
+      case 3: viewers.clients.push(decodeViewerInfo(d.readBytes())); break;
+      default: d.skipField(tag.wireType);
+    }
+  }
+  return viewers;
+}
+
+function decodeRecordingOwner(bytes: Uint8Array): RecordingOwner {
+  const d = new ProtobufDecoder(bytes);
+  const owner: RecordingOwner = { id: '', name: '' };
+  while (d.remaining > 0) {
+    const tag = d.readTag();
+    if (!tag) break;
+    switch (tag.fieldNumber) {
+      case 1: owner.id = d.readString(); break;
+      case 2: owner.name = d.readString(); break;
+      default: d.skipField(tag.wireType);
+    }
+  }
+  return owner;
+}
+
+function decodeRecordingInfo(bytes: Uint8Array): RecordingInfo {
+  const d = new ProtobufDecoder(bytes);
+  const rec: RecordingInfo = { active: false, file: '', owner: null, started_at: 0 };
+  while (d.remaining > 0) {
+    const tag = d.readTag();
+    if (!tag) break;
+    switch (tag.fieldNumber) {
+      case 1: rec.active = d.readVarint() !== 0; break;
+      case 2: rec.file = d.readString(); break;
+      case 3: rec.owner = decodeRecordingOwner(d.readBytes()); break;
+      case 4: rec.started_at = d.readDouble(); break;
+      default: d.skipField(tag.wireType);
+    }
+  }
+  return rec;
+}
+
 export function decodeStatusEvent(bytes: Uint8Array): StatusEvent {
   const d = new ProtobufDecoder(bytes);
   const event: StatusEvent = {
@@ -254,6 +442,9 @@ export function decodeStatusEvent(bytes: Uint8Array): StatusEvent {
     latest_detection: null,
     detection_history: [],
     timestamp: 0,
+    detector_health: null,
+    viewers: null,
+    recording: null,
   };
   while (d.remaining > 0) {
     const tag = d.readTag();
@@ -264,6 +455,9 @@ export function decodeStatusEvent(bytes: Uint8Array): StatusEvent {
       case 3: event.latest_detection = decodeDetectionResult(d.readBytes()); break;
       case 4: event.detection_history.push(decodeDetectionResult(d.readBytes())); break;
       case 5: event.timestamp = d.readDouble(); break;
+      case 6: event.detector_health = decodeDetectorHealth(d.readBytes()); break;
+      case 7: event.viewers = decodeViewers(d.readBytes()); break;
+      case 8: event.recording = decodeRecordingInfo(d.readBytes()); break;
       default: d.skipField(tag.wireType);
     }
   }
@@ -1,10 +1,16 @@
 import { signal, action, createModel } from "@preact/signals";
 import type { RecordingState } from "../hooks/useRecording";
+import type { DetectorHealth, Viewers } from "./protobuf";
+import type { CameraState, WebRTCSessionStats } from "../hooks/useSSE";
 
 export type MobileTab = 'live' | 'tracking' | 'album';
 
 export const AppStore = createModel(() => {
   const viewerCount = signal("-");
+  const viewers = signal<Viewers | null>(null);
+  const detectorHealth = signal<DetectorHealth | null>(null);
+  const webrtcStats = signal<WebRTCSessionStats | null>(null);
+  const cameraState = signal<CameraState>('');
   const mobileTab = signal<MobileTab>("live");
   const recordingsOpen = signal(false);
   const thumbnailPreview = signal<
@@ -54,6 +60,10 @@ export const AppStore = createModel(() => {
 
   return {
     viewerCount,
+    viewers,
+    detectorHealth,
+    webrtcStats,
+    cameraState,
     mobileTab,
     recordingsOpen,
     thumbnailPreview,
@@ -0,0 +1,56 @@
+import { signal } from "@preact/signals";
+
+export type UIPanel = 'tracking' | 'diagnostics' | 'history' | 'cameras' | 'album';
+
+// GET /api/ui/config のレスポンス (webmonitor/ui_config.go UIConfigView)
+export interface UIConfig {
+  panels: UIPanel[];
+  theme: 'dark' | 'light';
+  locale: string;
+  requested_locale: string;
+  strings: Record<string, string>;
+}
+
+// 取得前・失敗時はサーバー側のデフォルトと同じ (全パネル、ダーク、日本語)
+const fallbackStrings: Record<string, string> = {
+  'title': 'スマートペットカメラ モニター',
+  'tab.live': 'ライブ',
+  'tab.tracking': 'トラッキング',
+  'tab.album': 'アルバム',
+  'panel.diagnostics': '診断',
+  'panel.history': '履歴',
+  'panel.cameras': 'カメラ',
+  'video.h265_unsupported': 'H.265 の再生に非対応のブラウザです。ダウンロードしてご覧ください。',
+};
+
+export const uiConfig = signal<UIConfig>({
+  panels: ['tracking', 'diagnostics', 'history', 'cameras', 'album'],
+  theme: 'dark',
+  locale: 'ja',
+  requested_locale: 'ja',
+  strings: fallbackStrings,
+});
+
+export function panelEnabled(panel: UIPanel): boolean {
+  return uiConfig.value.panels.includes(panel);
+}
+
+export function t(key: string): string {
+  return uiConfig.value.strings[key] ?? fallbackStrings[key] ?? key;
+}
+
+// ?locale= で表示言語を一時的に切り替えられる
+export async function loadUIConfig(): Promise<void> {
+  const locale = new URLSearchParams(location.search).get('locale');
+  try {
+    const r = await fetch('/api/ui/config' + (locale ? `?locale=${encodeURIComponent(locale)}` : ''));
+    if (!r.ok) return;
+    const c = await r.json() as UIConfig;
+    uiConfig.value = c;
+    document.documentElement.dataset.theme = c.theme;
+    document.documentElement.lang = c.requested_locale;
+    document.title = t('title');
+  } catch {
+    // 旧サーバー: デフォルトのまま
+  }
+}